@@ -0,0 +1,46 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// BreakGlassIsEnabled returns true if the break-glass account can be unlocked.
+func (c *VDICluster) BreakGlassIsEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.BreakGlass != nil {
+		return c.Spec.Auth.BreakGlass.Enabled && c.Spec.Auth.BreakGlass.PublicKey != ""
+	}
+	return false
+}
+
+// GetBreakGlassUsername returns the username sessions of the break-glass account are
+// issued for.
+func (c *VDICluster) GetBreakGlassUsername() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.BreakGlass != nil && c.Spec.Auth.BreakGlass.Username != "" {
+		return c.Spec.Auth.BreakGlass.Username
+	}
+	return "break-glass"
+}
+
+// GetBreakGlassPublicKey returns the base64 encoded PEM public key unlock challenges must
+// be signed with.
+func (c *VDICluster) GetBreakGlassPublicKey() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.BreakGlass != nil {
+		return c.Spec.Auth.BreakGlass.PublicKey
+	}
+	return ""
+}
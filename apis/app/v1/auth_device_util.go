@@ -0,0 +1,70 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// DeviceTrustIsEnabled returns true if device trust checks are configured for the cluster.
+func (c *VDICluster) DeviceTrustIsEnabled() bool {
+	return len(c.GetDeviceTrustIssuers()) > 0
+}
+
+// GetDeviceTrustIssuers returns the issuers trusted to assert device posture.
+func (c *VDICluster) GetDeviceTrustIssuers() []DeviceTrustIssuer {
+	if c.Spec.Auth != nil && c.Spec.Auth.DeviceTrust != nil {
+		return c.Spec.Auth.DeviceTrust.Issuers
+	}
+	return nil
+}
+
+// GetDeviceTrustRoles returns the roles that require a trusted device. An empty list means
+// all users require one.
+func (c *VDICluster) GetDeviceTrustRoles() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.DeviceTrust != nil {
+		return c.Spec.Auth.DeviceTrust.Roles
+	}
+	return nil
+}
+
+// DeviceTrustUsesClientCerts returns true if any of the device trust issuers verify
+// TLS client certificates. The app server only requests client certificates when this
+// is true.
+func (c *VDICluster) DeviceTrustUsesClientCerts() bool {
+	for _, issuer := range c.GetDeviceTrustIssuers() {
+		if issuer.Method == DeviceTrustClientCert {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHeader returns the request header containing assertions from this issuer.
+func (d *DeviceTrustIssuer) GetHeader() string {
+	if d.Header != "" {
+		return d.Header
+	}
+	return "X-Device-Assertion"
+}
+
+// GetAudience returns the audience that assertions from this issuer must be issued for.
+func (d *DeviceTrustIssuer) GetAudience() string {
+	if d.Audience != "" {
+		return d.Audience
+	}
+	return "kvdi"
+}
@@ -159,3 +159,11 @@ func (c *VDICluster) GetLDAPDoUserStatusCheck() bool {
 	}
 	return false
 }
+
+// GetLDAPUserAttributes returns the LDAP attributes to copy into the attributes of users.
+func (c *VDICluster) GetLDAPUserAttributes() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
+		return c.Spec.Auth.LDAPAuth.UserAttributes
+	}
+	return nil
+}
@@ -138,3 +138,11 @@ func (c *VDICluster) PreserveOIDCTokens() bool {
 	}
 	return false
 }
+
+// GetOIDCAttributeClaims returns the ID token claims to copy into the attributes of users.
+func (c *VDICluster) GetOIDCAttributeClaims() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		return c.Spec.Auth.OIDCAuth.AttributeClaims
+	}
+	return nil
+}
@@ -21,11 +21,13 @@ package v1
 
 import (
 	"fmt"
+	"strings"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/version"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -34,6 +36,19 @@ func (c *VDICluster) GetAppName() string {
 	return fmt.Sprintf("%s-app", c.GetName())
 }
 
+// GetAppPathPrefix returns the path prefix the app is served under, with a leading slash and
+// without a trailing one. An empty string is returned when the app is served at the root.
+func (c *VDICluster) GetAppPathPrefix() string {
+	if c.Spec.App == nil {
+		return ""
+	}
+	prefix := strings.Trim(c.Spec.App.PathPrefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
 // GetServiceAnnotations returns the annotations to apply to the kvdi app service.
 func (c *VDICluster) GetServiceAnnotations() map[string]string {
 	annotations := c.GetAnnotations()
@@ -96,14 +111,45 @@ func (c *VDICluster) EnableCORS() bool {
 	return false
 }
 
-// AuditLogEnabled returns true if auditing events should be logged to stdout.
+// AuditLogEnabled returns true if auditing events should be recorded.
 func (c *VDICluster) AuditLogEnabled() bool {
 	if c.Spec.App != nil {
-		return c.Spec.App.AuditLog
+		return c.Spec.App.AuditLog || c.Spec.App.Audit != nil
 	}
 	return false
 }
 
+// TunnelsEnabled returns true if the app should accept reverse tunnels from desktop
+// proxies.
+func (c *VDICluster) TunnelsEnabled() bool {
+	if c.Spec.App != nil && c.Spec.App.Tunnels != nil {
+		return c.Spec.App.Tunnels.Enabled
+	}
+	return false
+}
+
+// GetTunnelAddress returns the address desktop proxies should dial to establish
+// reverse tunnels.
+func (c *VDICluster) GetTunnelAddress() string {
+	if c.Spec.App != nil && c.Spec.App.Tunnels != nil && c.Spec.App.Tunnels.Address != "" {
+		return c.Spec.App.Tunnels.Address
+	}
+	return fmt.Sprintf("%s.%s.svc:%d", c.GetAppName(), c.GetCoreNamespace(), v1.TunnelPort)
+}
+
+// GetGateways returns the additional gateways clients may connect to desktops through.
+func (c *VDICluster) GetGateways() []GatewayConfig {
+	if c.Spec.App != nil {
+		return c.Spec.App.Gateways
+	}
+	return nil
+}
+
+// GetAppInternalURL returns the URL desktops inside the cluster can reach the API at.
+func (c *VDICluster) GetAppInternalURL() string {
+	return fmt.Sprintf("https://%s.%s.svc:%d", c.GetAppName(), c.GetCoreNamespace(), v1.PublicWebPort)
+}
+
 // GetAppSecretsName returns the name of the secret to use for app secrets.
 func (c *VDICluster) GetAppSecretsName() string {
 	if c.Spec.Secrets != nil && c.Spec.Secrets.K8SSecret != nil && c.Spec.Secrets.K8SSecret.SecretName != "" {
@@ -150,3 +196,16 @@ func (c *VDICluster) GetAppServerTLSNamespacedName() types.NamespacedName {
 		Namespace: c.GetCoreNamespace(),
 	}
 }
+
+// GetDisplayBandwidth returns the display bandwidth in bytes per second each app instance
+// shares between display connections. Zero means there is no limit.
+func (c *VDICluster) GetDisplayBandwidth() int64 {
+	if c.Spec.App == nil || c.Spec.App.DisplayBandwidth == "" {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(c.Spec.App.DisplayBandwidth)
+	if err != nil {
+		return 0
+	}
+	return quantity.Value()
+}
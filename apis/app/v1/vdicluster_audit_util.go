@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// GetAuditConfig returns the configuration for the audit log. When only `auditLog` is set,
+// the default configuration is returned.
+func (c *VDICluster) GetAuditConfig() *AuditConfig {
+	if c.Spec.App != nil && c.Spec.App.Audit != nil {
+		return c.Spec.App.Audit
+	}
+	return &AuditConfig{}
+}
+
+// GetAuditSink returns where auditing events are recorded.
+func (c *VDICluster) GetAuditSink() AuditSinkType {
+	if sink := c.GetAuditConfig().Sink; sink != "" {
+		return sink
+	}
+	return AuditSinkStdout
+}
+
+// GetAuditFilePath returns the path of the file auditing events are appended to when
+// using the file sink.
+func (c *VDICluster) GetAuditFilePath() string {
+	if path := c.GetAuditConfig().FilePath; path != "" {
+		return path
+	}
+	return v1.DefaultAuditFilePath
+}
+
+// GetAuditRetention returns the number of recent auditing events each app instance
+// keeps in memory.
+func (c *VDICluster) GetAuditRetention() int {
+	if retention := c.GetAuditConfig().Retention; retention > 0 {
+		return retention
+	}
+	return v1.DefaultAuditRetention
+}
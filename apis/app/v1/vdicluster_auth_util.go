@@ -104,6 +104,50 @@ func (c *VDICluster) GetTokenDuration() time.Duration {
 	return v1.DefaultSessionLength
 }
 
+// GetAccessTokenTTL returns the duration for a new access token to live in sessions that
+// can be refreshed or renewed. It defaults to the token duration.
+func (c *VDICluster) GetAccessTokenTTL() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.AccessTokenTTL != "" {
+		if duration, err := time.ParseDuration(c.Spec.Auth.AccessTokenTTL); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return c.GetTokenDuration()
+}
+
+// GetIdleTimeout returns how long a user session may go without activity before a new login
+// is required. A zero value means there is no idle timeout.
+func (c *VDICluster) GetIdleTimeout() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.IdleTimeout != "" {
+		if duration, err := time.ParseDuration(c.Spec.Auth.IdleTimeout); err == nil {
+			return duration
+		}
+	}
+	return time.Duration(0)
+}
+
+// GetMaxSessionAge returns the absolute maximum age of a user session. A zero value means
+// sessions may be renewed indefinitely.
+func (c *VDICluster) GetMaxSessionAge() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.MaxSessionAge != "" {
+		if duration, err := time.ParseDuration(c.Spec.Auth.MaxSessionAge); err == nil {
+			return duration
+		}
+	}
+	return time.Duration(0)
+}
+
+// GetDeletionRetention returns how long deleted users and roles are kept so they can be
+// restored. A zero value means they are deleted permanently right away.
+func (c *VDICluster) GetDeletionRetention() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.DeletionRetention != "" {
+		if duration, err := time.ParseDuration(c.Spec.Auth.DeletionRetention); err == nil && duration >= 0 {
+			return duration
+		}
+	}
+	return v1.DefaultDeletionRetention
+}
+
 // GetAdminRole returns an admin role for this VDICluster.
 func (c *VDICluster) GetAdminRole() *rbacv1.VDIRole {
 	var annotations map[string]string
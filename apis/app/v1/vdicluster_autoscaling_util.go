@@ -0,0 +1,55 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// GetAutoscaling returns the configuration for autoscaling hints, or nil if none is
+// configured.
+func (c *VDICluster) GetAutoscaling() *AutoscalingConfig {
+	if c.Spec.Desktops != nil {
+		return c.Spec.Desktops.Autoscaling
+	}
+	return nil
+}
+
+// GetLeadTime returns how long before a window opens the schedule becomes active.
+func (c *CapacitySchedule) GetLeadTime() time.Duration {
+	if c.LeadTime != "" {
+		if dur, err := time.ParseDuration(c.LeadTime); err == nil && dur >= 0 {
+			return dur
+		}
+	}
+	return v1.DefaultCapacityScheduleLeadTime
+}
+
+// IsActive returns true if a window of the schedule is open at the given time, or opens
+// within the lead time. Schedules with an invalid time zone are never active.
+func (c *CapacitySchedule) IsActive(now time.Time) bool {
+	if active, err := c.Schedule.IsActive(now); err == nil && active {
+		return true
+	}
+	active, err := c.Schedule.IsActive(now.Add(c.GetLeadTime()))
+	return err == nil && active
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// BootstrapPasswordKey is the key in a bootstrap user's password secret holding their
+// initial password.
+const BootstrapPasswordKey = "password"
+
+// GetBootstrapUsers returns the local users to create when the cluster is first installed.
+func (c *VDICluster) GetBootstrapUsers() []BootstrapUser {
+	if c.Spec.Bootstrap != nil {
+		return c.Spec.Bootstrap.Users
+	}
+	return nil
+}
+
+// GetBootstrapConfigMaps returns the names of the ConfigMaps holding the roles and templates
+// to create when the cluster is first installed.
+func (c *VDICluster) GetBootstrapConfigMaps() []string {
+	if c.Spec.Bootstrap != nil {
+		return c.Spec.Bootstrap.ConfigMaps
+	}
+	return nil
+}
+
+// IsBootstrapped returns true if the object with the given bootstrap key has already been
+// created from the bootstrap configuration.
+func (c *VDICluster) IsBootstrapped(key string) bool {
+	for _, k := range c.Status.Bootstrapped {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -26,6 +26,7 @@ import (
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -113,6 +114,46 @@ func (c *VDICluster) GetUserdataVolumeSpec() *corev1.PersistentVolumeClaimSpec {
 	return nil
 }
 
+// UserdataIsEnabled returns true if desktops mount a persistent volume for the $HOME
+// of their user, either selected from existing PVCs or created from the userdataSpec.
+func (c *VDICluster) UserdataIsEnabled() bool {
+	if selector := c.GetUserdataSelector(); selector != nil && selector.IsValid() {
+		return true
+	}
+	return c.GetUserdataVolumeSpec() != nil
+}
+
+// GetUserdataReclaimPolicy returns the policy for user $HOME volumes once their user is
+// deleted.
+func (c *VDICluster) GetUserdataReclaimPolicy() corev1.PersistentVolumeReclaimPolicy {
+	if c.Spec.UserdataReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
+		return corev1.PersistentVolumeReclaimDelete
+	}
+	return corev1.PersistentVolumeReclaimRetain
+}
+
+// GetUserdataQuota returns the maximum size in bytes of a user's $HOME directory.
+// A zero value means no quota is enforced.
+func (c *VDICluster) GetUserdataQuota() int64 {
+	if c.Spec.UserdataQuota != nil && c.Spec.UserdataQuota.Limit != "" {
+		quantity, err := resource.ParseQuantity(c.Spec.UserdataQuota.Limit)
+		if err != nil {
+			return 0
+		}
+		return quantity.Value()
+	}
+	return 0
+}
+
+// GetUserdataQuotaWarningThreshold returns the percentage of the userdata quota at which
+// usage should be reported as a warning.
+func (c *VDICluster) GetUserdataQuotaWarningThreshold() int {
+	if c.Spec.UserdataQuota != nil && c.Spec.UserdataQuota.WarningThreshold > 0 && c.Spec.UserdataQuota.WarningThreshold <= 100 {
+		return c.Spec.UserdataQuota.WarningThreshold
+	}
+	return 90
+}
+
 // GetUserdataVolumeName returns the name of the userdata volume for the given user.
 func (c *VDICluster) GetUserdataVolumeName(username string) string {
 	return fmt.Sprintf("%s-%s-userdata", c.GetName(), username)
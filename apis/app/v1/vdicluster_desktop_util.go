@@ -38,6 +38,86 @@ func (c *VDICluster) GetMaxSessionLength() time.Duration {
 	return time.Duration(0)
 }
 
+// GetDisplayIdleTimeout returns how long a display connection may go without input before
+// it is closed. A zero value means idle connections are never closed.
+func (c *VDICluster) GetDisplayIdleTimeout() time.Duration {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.DisplayIdleTimeout != "" {
+		if dur, err := time.ParseDuration(c.Spec.Desktops.DisplayIdleTimeout); err == nil {
+			return dur
+		}
+	}
+	return time.Duration(0)
+}
+
+// GetIdlePolicy returns the policy for terminating idle desktop sessions, if one is
+// configured.
+func (c *VDICluster) GetIdlePolicy() *IdlePolicy {
+	if c.Spec.Desktops != nil {
+		return c.Spec.Desktops.IdlePolicy
+	}
+	return nil
+}
+
+// GetIdleTimeout returns how long a session may go without input before it is terminated.
+// A zero value means idle sessions are left running.
+func (p *IdlePolicy) GetIdleTimeout() time.Duration {
+	if p == nil || p.IdleTimeout == "" {
+		return time.Duration(0)
+	}
+	dur, err := time.ParseDuration(p.IdleTimeout)
+	if err != nil || dur < 0 {
+		return time.Duration(0)
+	}
+	return dur
+}
+
+// GetWarnBefore returns how long before an idle session is terminated to warn its user.
+// It is never longer than the idle timeout.
+func (p *IdlePolicy) GetWarnBefore() time.Duration {
+	warn := v1.DefaultIdleWarnBefore
+	if p != nil && p.WarnBefore != "" {
+		if dur, err := time.ParseDuration(p.WarnBefore); err == nil && dur >= 0 {
+			warn = dur
+		}
+	}
+	if timeout := p.GetIdleTimeout(); warn > timeout {
+		return timeout
+	}
+	return warn
+}
+
+// Defaults for resuming display connections.
+const (
+	defaultDisplayResumeWindow     = 30 * time.Second
+	defaultDisplayResumeBufferSize = 4 * 1024 * 1024
+)
+
+// DisplayResumeEnabled returns true if display connections may be resumed after the client
+// drops.
+func (c *VDICluster) DisplayResumeEnabled() bool {
+	return c.Spec.Desktops != nil && c.Spec.Desktops.DisplayResume != nil
+}
+
+// GetDisplayResumeWindow returns how long the connection to a desktop is held open after a
+// resumable display client drops.
+func (c *VDICluster) GetDisplayResumeWindow() time.Duration {
+	if c.DisplayResumeEnabled() && c.Spec.Desktops.DisplayResume.Window != "" {
+		if dur, err := time.ParseDuration(c.Spec.Desktops.DisplayResume.Window); err == nil {
+			return dur
+		}
+	}
+	return defaultDisplayResumeWindow
+}
+
+// GetDisplayResumeBufferSize returns the number of bytes of display output to keep for
+// resuming clients.
+func (c *VDICluster) GetDisplayResumeBufferSize() int {
+	if c.DisplayResumeEnabled() && c.Spec.Desktops.DisplayResume.BufferSize > 0 {
+		return c.Spec.Desktops.DisplayResume.BufferSize
+	}
+	return defaultDisplayResumeBufferSize
+}
+
 // GetMaxSessionsPerUser returns the maximum number of sessions a user can run for this VDICluster.
 func (c *VDICluster) GetMaxSessionsPerUser() int {
 	if c.Spec.Desktops != nil {
@@ -46,6 +126,15 @@ func (c *VDICluster) GetMaxSessionsPerUser() int {
 	return 0
 }
 
+// GetMaxConcurrentBootsPerNode returns the maximum number of desktops that may boot at the
+// same time on a single node.
+func (c *VDICluster) GetMaxConcurrentBootsPerNode() int {
+	if c.Spec.Desktops != nil {
+		return c.Spec.Desktops.MaxConcurrentBootsPerNode
+	}
+	return 0
+}
+
 // GetUserDesktopSelector returns a selector that can be used to find desktops for a given user.
 func (c *VDICluster) GetUserDesktopSelector(username string) map[string]string {
 	return map[string]string{
@@ -53,3 +142,50 @@ func (c *VDICluster) GetUserDesktopSelector(username string) map[string]string {
 		v1.VDIClusterLabel: c.GetName(),
 	}
 }
+
+// GetLintSeverity returns the configured severity for the given lint rule, or the
+// provided default if there is no override.
+func (c *VDICluster) GetLintSeverity(rule string, def LintSeverity) LintSeverity {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Lint != nil {
+		if severity, ok := c.Spec.Desktops.Lint.Rules[rule]; ok && severity != "" {
+			return severity
+		}
+	}
+	return def
+}
+
+// SSHGatewayEnabled returns true if SSH access to desktop sessions is enabled.
+func (c *VDICluster) SSHGatewayEnabled() bool {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.SSH != nil {
+		return c.Spec.Desktops.SSH.Enabled
+	}
+	return false
+}
+
+// GetSSHCertificateTTL returns how long SSH user certificates should be valid for.
+func (c *VDICluster) GetSSHCertificateTTL() time.Duration {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.SSH != nil && c.Spec.Desktops.SSH.CertificateTTL != "" {
+		dur, err := time.ParseDuration(c.Spec.Desktops.SSH.CertificateTTL)
+		if err == nil {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// GetDefaultRiskLevel returns the risk level assumed for templates that do not declare one.
+func (c *VDICluster) GetDefaultRiskLevel() string {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Sandbox != nil && c.Spec.Desktops.Sandbox.DefaultRiskLevel != "" {
+		return c.Spec.Desktops.Sandbox.DefaultRiskLevel
+	}
+	return "low"
+}
+
+// GetSandboxRuntimeClass returns the runtime class that desktops of the given risk level
+// should run with, or an empty string if the sandbox policy does not cover it.
+func (c *VDICluster) GetSandboxRuntimeClass(riskLevel string) string {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Sandbox != nil {
+		return c.Spec.Desktops.Sandbox.RuntimeClasses[riskLevel]
+	}
+	return ""
+}
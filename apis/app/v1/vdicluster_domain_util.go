@@ -0,0 +1,138 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainJoinIsEnabled returns true if desktops can be joined to a Kerberos realm.
+func (c *VDICluster) DomainJoinIsEnabled() bool {
+	return c.Spec.Desktops != nil && c.Spec.Desktops.DomainJoin != nil && c.Spec.Desktops.DomainJoin.Realm != ""
+}
+
+// GetDomainRealm returns the Kerberos realm desktops are joined to.
+func (c *VDICluster) GetDomainRealm() string {
+	if !c.DomainJoinIsEnabled() {
+		return ""
+	}
+	return strings.ToUpper(c.Spec.Desktops.DomainJoin.Realm)
+}
+
+// GetDomainName returns the DNS domain of the Kerberos realm.
+func (c *VDICluster) GetDomainName() string {
+	if !c.DomainJoinIsEnabled() {
+		return ""
+	}
+	if c.Spec.Desktops.DomainJoin.Domain != "" {
+		return c.Spec.Desktops.DomainJoin.Domain
+	}
+	return strings.ToLower(c.Spec.Desktops.DomainJoin.Realm)
+}
+
+// GetDomainKDCs returns the KDCs configured for the realm.
+func (c *VDICluster) GetDomainKDCs() []string {
+	if !c.DomainJoinIsEnabled() {
+		return nil
+	}
+	return c.Spec.Desktops.DomainJoin.KDCs
+}
+
+// GetDomainAdminServer returns the admin server for the realm.
+func (c *VDICluster) GetDomainAdminServer() string {
+	if !c.DomainJoinIsEnabled() {
+		return ""
+	}
+	if c.Spec.Desktops.DomainJoin.AdminServer != "" {
+		return c.Spec.Desktops.DomainJoin.AdminServer
+	}
+	if kdcs := c.GetDomainKDCs(); len(kdcs) > 0 {
+		return kdcs[0]
+	}
+	return ""
+}
+
+// GetKRB5Config returns the contents of the krb5.conf to place in domain joined desktops.
+func (c *VDICluster) GetKRB5Config() string {
+	if !c.DomainJoinIsEnabled() {
+		return ""
+	}
+	if c.Spec.Desktops.DomainJoin.KRB5Config != "" {
+		return c.Spec.Desktops.DomainJoin.KRB5Config
+	}
+	realm, domain := c.GetDomainRealm(), c.GetDomainName()
+	kdcs := c.GetDomainKDCs()
+
+	var b strings.Builder
+	b.WriteString("[libdefaults]\n")
+	fmt.Fprintf(&b, "  default_realm = %s\n", realm)
+	fmt.Fprintf(&b, "  dns_lookup_kdc = %t\n", len(kdcs) == 0)
+	b.WriteString("  dns_lookup_realm = false\n")
+	b.WriteString("  rdns = false\n")
+	b.WriteString("  default_ccache_name = FILE:/tmp/krb5cc_%{uid}\n")
+	if len(kdcs) > 0 {
+		b.WriteString("\n[realms]\n")
+		fmt.Fprintf(&b, "  %s = {\n", realm)
+		for _, kdc := range kdcs {
+			fmt.Fprintf(&b, "    kdc = %s\n", kdc)
+		}
+		if admin := c.GetDomainAdminServer(); admin != "" {
+			fmt.Fprintf(&b, "    admin_server = %s\n", admin)
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("\n[domain_realm]\n")
+	fmt.Fprintf(&b, "  .%s = %s\n", domain, realm)
+	fmt.Fprintf(&b, "  %s = %s\n", domain, realm)
+	return b.String()
+}
+
+// GetSSSDConfig returns the contents of the sssd.conf to place in a domain joined desktop
+// using the given host's keytab. `$(HOSTNAME)` is replaced with the host in a configured
+// sssd.conf.
+func (c *VDICluster) GetSSSDConfig(host string) string {
+	if !c.DomainJoinIsEnabled() {
+		return ""
+	}
+	if c.Spec.Desktops.DomainJoin.SSSDConfig != "" {
+		return strings.Replace(c.Spec.Desktops.DomainJoin.SSSDConfig, "$(HOSTNAME)", host, -1)
+	}
+	realm, domain := c.GetDomainRealm(), c.GetDomainName()
+
+	var b strings.Builder
+	b.WriteString("[sssd]\n")
+	b.WriteString("config_file_version = 2\n")
+	b.WriteString("services = nss, pam\n")
+	fmt.Fprintf(&b, "domains = %s\n", domain)
+	fmt.Fprintf(&b, "\n[domain/%s]\n", domain)
+	b.WriteString("id_provider = ad\n")
+	b.WriteString("access_provider = ad\n")
+	fmt.Fprintf(&b, "ad_domain = %s\n", domain)
+	fmt.Fprintf(&b, "krb5_realm = %s\n", realm)
+	fmt.Fprintf(&b, "ad_hostname = %s.%s\n", host, domain)
+	b.WriteString("krb5_store_password_if_offline = true\n")
+	b.WriteString("cache_credentials = true\n")
+	b.WriteString("ldap_id_mapping = true\n")
+	b.WriteString("use_fully_qualified_names = false\n")
+	b.WriteString("fallback_homedir = /home/%u\n")
+	b.WriteString("default_shell = /bin/bash\n")
+	return b.String()
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WakeRequestedAtKey is the key in the wake ConfigMap where the wake-up listener records
+// the time of the last wake-up request.
+const WakeRequestedAtKey = "requestedAt"
+
+// AsleepReplicasAnnotation is the annotation on the manager deployment where the number
+// of replicas it ran with is kept while it is scaled down for energy saving.
+const AsleepReplicasAnnotation = "kvdi.io/asleep-replicas"
+
+var defaultBusinessDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// EnergySavingIsEnabled returns true if the stack should be scaled down outside of
+// business hours.
+func (c *VDICluster) EnergySavingIsEnabled() bool {
+	return c.Spec.EnergySaving != nil && c.Spec.EnergySaving.Enabled
+}
+
+// PauseIdleSessions returns true if idle sessions should be paused outside of business
+// hours.
+func (c *VDICluster) PauseIdleSessions() bool {
+	return c.EnergySavingIsEnabled() && c.Spec.EnergySaving.PauseIdleSessions
+}
+
+// ScaleManager returns true if the manager should be scaled down along with the app
+// while the stack is asleep.
+func (c *VDICluster) ScaleManager() bool {
+	return c.EnergySavingIsEnabled() && c.Spec.EnergySaving.ScaleManager
+}
+
+// GetManagerDeployment returns the name and namespace of the manager deployment.
+func (c *VDICluster) GetManagerDeployment() (name, namespace string) {
+	name, namespace = "kvdi-controller-manager", "kvdi-system"
+	if c.Spec.EnergySaving != nil {
+		if c.Spec.EnergySaving.ManagerDeployment != "" {
+			name = c.Spec.EnergySaving.ManagerDeployment
+		}
+		if c.Spec.EnergySaving.ManagerNamespace != "" {
+			namespace = c.Spec.EnergySaving.ManagerNamespace
+		}
+	}
+	return name, namespace
+}
+
+// GetBusinessDays returns the days of the week that are business days. Unrecognized
+// values are ignored.
+func (c *VDICluster) GetBusinessDays() []time.Weekday {
+	if c.Spec.EnergySaving == nil || len(c.Spec.EnergySaving.Days) == 0 {
+		return defaultBusinessDays
+	}
+	days := make([]time.Weekday, 0)
+	for _, day := range c.Spec.EnergySaving.Days {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if len(day) < 3 {
+			continue
+		}
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if strings.HasPrefix(strings.ToLower(wd.String()), day[:3]) {
+				days = append(days, wd)
+				break
+			}
+		}
+	}
+	if len(days) == 0 {
+		return defaultBusinessDays
+	}
+	return days
+}
+
+// GetWakeDuration returns how long the stack stays awake after a wake-up request outside
+// of business hours.
+func (c *VDICluster) GetWakeDuration() time.Duration {
+	if c.Spec.EnergySaving != nil && c.Spec.EnergySaving.WakeDuration != "" {
+		if dur, err := time.ParseDuration(c.Spec.EnergySaving.WakeDuration); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// GetBusinessHours returns the start and end of the business hours that are either in
+// progress at the given time or start next.
+func (c *VDICluster) GetBusinessHours(now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	startHour, startMinute := parseClockTime(c.getBusinessStartTime(), 8)
+	endHour, endMinute := parseClockTime(c.getBusinessEndTime(), 18)
+	dur := time.Duration(endHour-startHour)*time.Hour + time.Duration(endMinute-startMinute)*time.Minute
+	if dur <= 0 {
+		dur += 24 * time.Hour
+	}
+	days := c.GetBusinessDays()
+	// start from the previous day in case business hours span midnight
+	for offset := -1; offset <= 7; offset++ {
+		day := now.AddDate(0, 0, offset)
+		start = time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, time.UTC)
+		if !businessDay(days, start.Weekday()) {
+			continue
+		}
+		end = start.Add(dur)
+		if end.After(now) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// InBusinessHours returns true if the given time falls within business hours.
+func (c *VDICluster) InBusinessHours(now time.Time) bool {
+	start, end := c.GetBusinessHours(now)
+	if start.IsZero() {
+		return false
+	}
+	return !now.Before(start) && now.Before(end)
+}
+
+// GetWakeListenerName returns the name of the wake-up listener deployment for this
+// VDICluster.
+func (c *VDICluster) GetWakeListenerName() string {
+	return fmt.Sprintf("%s-wake", c.GetAppName())
+}
+
+// GetWakeConfigMapName returns the name of the ConfigMap where wake-up requests are
+// recorded.
+func (c *VDICluster) GetWakeConfigMapName() string {
+	return c.GetWakeListenerName()
+}
+
+func (c *VDICluster) getBusinessStartTime() string {
+	if c.Spec.EnergySaving != nil {
+		return c.Spec.EnergySaving.StartTime
+	}
+	return ""
+}
+
+func (c *VDICluster) getBusinessEndTime() string {
+	if c.Spec.EnergySaving != nil {
+		return c.Spec.EnergySaving.EndTime
+	}
+	return ""
+}
+
+// parseClockTime parses a `HH:MM` time of day, falling back to the given hour.
+func parseClockTime(val string, defaultHour int) (hour, minute int) {
+	if val == "" {
+		return defaultHour, 0
+	}
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return defaultHour, 0
+	}
+	return t.Hour(), t.Minute()
+}
+
+func businessDay(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
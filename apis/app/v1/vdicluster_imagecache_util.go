@@ -0,0 +1,94 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultImageCacheImage    = "registry:2"
+	defaultImageCacheUpstream = "https://registry-1.docker.io"
+	defaultImageCacheNodePort = 30500
+)
+
+// ImageCacheIsEnabled returns true if the manager should deploy a read-through registry
+// cache for desktop images.
+func (c *VDICluster) ImageCacheIsEnabled() bool {
+	return c.Spec.Desktops != nil && c.Spec.Desktops.ImageCache != nil && c.Spec.Desktops.ImageCache.Enabled
+}
+
+// GetImageCacheName returns the name of the registry cache deployment and service.
+func (c *VDICluster) GetImageCacheName() string {
+	return fmt.Sprintf("%s-image-cache", c.GetAppName())
+}
+
+// GetImageCacheImage returns the image to run the registry cache with.
+func (c *VDICluster) GetImageCacheImage() string {
+	if c.ImageCacheIsEnabled() && c.Spec.Desktops.ImageCache.Image != "" {
+		return c.Spec.Desktops.ImageCache.Image
+	}
+	return defaultImageCacheImage
+}
+
+// GetImageCacheUpstream returns the URL of the registry the cache pulls images from.
+func (c *VDICluster) GetImageCacheUpstream() string {
+	if c.ImageCacheIsEnabled() && c.Spec.Desktops.ImageCache.Upstream != "" {
+		upstream := strings.TrimSuffix(c.Spec.Desktops.ImageCache.Upstream, "/")
+		if !strings.Contains(upstream, "://") {
+			upstream = "https://" + upstream
+		}
+		return upstream
+	}
+	return defaultImageCacheUpstream
+}
+
+// GetImageCacheRegistry returns the registry host, as it appears in image references, of
+// the images served by the cache. Docker Hub is returned as `docker.io`.
+func (c *VDICluster) GetImageCacheRegistry() string {
+	u, err := url.Parse(c.GetImageCacheUpstream())
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	switch u.Host {
+	case "registry-1.docker.io", "index.docker.io", "docker.io":
+		return "docker.io"
+	}
+	return u.Host
+}
+
+// GetImageCacheNodePort returns the node port the registry cache is exposed on.
+func (c *VDICluster) GetImageCacheNodePort() int32 {
+	if c.ImageCacheIsEnabled() && c.Spec.Desktops.ImageCache.NodePort != 0 {
+		return c.Spec.Desktops.ImageCache.NodePort
+	}
+	return defaultImageCacheNodePort
+}
+
+// GetImageCacheMirror returns the registry host that container runtimes pull cached
+// images from, or an empty string if the cache is not enabled.
+func (c *VDICluster) GetImageCacheMirror() string {
+	if !c.ImageCacheIsEnabled() {
+		return ""
+	}
+	return fmt.Sprintf("localhost:%d", c.GetImageCacheNodePort())
+}
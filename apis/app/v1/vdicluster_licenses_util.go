@@ -0,0 +1,53 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// GetLicensePools returns the license pools templates can require seats from.
+func (c *VDICluster) GetLicensePools() []LicensePool {
+	if c.Spec.Desktops != nil {
+		return c.Spec.Desktops.LicensePools
+	}
+	return nil
+}
+
+// GetLicensePool returns the license pool with the given name, or nil if it does not
+// exist.
+func (c *VDICluster) GetLicensePool(name string) *LicensePool {
+	for _, pool := range c.GetLicensePools() {
+		if pool.Name == name {
+			return &pool
+		}
+	}
+	return nil
+}
+
+// GetOnExhausted returns what happens to launches when the pool has no seats left.
+func (p *LicensePool) GetOnExhausted() LicenseExhaustedPolicy {
+	if p.OnExhausted == "" {
+		return LicenseExhaustedBlock
+	}
+	return p.OnExhausted
+}
+
+// QueuesLaunches returns true if launches are queued instead of rejected when the pool
+// has no seats left.
+func (p *LicensePool) QueuesLaunches() bool {
+	return p.GetOnExhausted() == LicenseExhaustedQueue
+}
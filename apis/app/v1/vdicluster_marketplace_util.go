@@ -0,0 +1,40 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// GetTemplateIndexes returns the remote template indexes the cluster subscribes to.
+func (c *VDICluster) GetTemplateIndexes() []TemplateIndex {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Marketplace != nil {
+		return c.Spec.Desktops.Marketplace.Indexes
+	}
+	return nil
+}
+
+// GetTemplateIndex returns the template index with the given name, or nil if the cluster
+// does not subscribe to it.
+func (c *VDICluster) GetTemplateIndex(name string) *TemplateIndex {
+	for _, idx := range c.GetTemplateIndexes() {
+		if idx.Name == name {
+			out := idx
+			return &out
+		}
+	}
+	return nil
+}
@@ -35,6 +35,15 @@ func (c *VDICluster) CreateAppServiceMonitor() bool {
 	return false
 }
 
+// CreateProxyServiceMonitor returns true if the cluster specifies that desktop proxies
+// should serve metrics and a ServiceMonitor should be created for them.
+func (c *VDICluster) CreateProxyServiceMonitor() bool {
+	if c.Spec.Metrics != nil && c.Spec.Metrics.ServiceMonitor != nil {
+		return c.Spec.Metrics.ServiceMonitor.Proxies
+	}
+	return false
+}
+
 // CreatePrometheusCR returns true if the cluster specifies to create a
 // Prometheus CR.
 func (c *VDICluster) CreatePrometheusCR() bool {
@@ -0,0 +1,60 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// GetMiddlewareWebhooks returns the external webhooks filtering API requests.
+func (c *VDICluster) GetMiddlewareWebhooks() []MiddlewareWebhook {
+	if c.Spec.App != nil && c.Spec.App.Middleware != nil {
+		return c.Spec.App.Middleware.Webhooks
+	}
+	return nil
+}
+
+// GetStage returns the stage at which the webhook runs.
+func (w *MiddlewareWebhook) GetStage() MiddlewareStage {
+	if w.Stage != "" {
+		return w.Stage
+	}
+	return MiddlewareStagePostAuth
+}
+
+// GetTimeout returns how long to wait for the webhook to respond.
+func (w *MiddlewareWebhook) GetTimeout() time.Duration {
+	if w.Timeout != "" {
+		if dur, err := time.ParseDuration(w.Timeout); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultMiddlewareWebhookTimeout
+}
+
+// GetFailurePolicy returns what happens to requests when the webhook fails.
+func (w *MiddlewareWebhook) GetFailurePolicy() MiddlewareFailurePolicy {
+	if w.FailurePolicy != "" {
+		return w.FailurePolicy
+	}
+	return MiddlewareFailureFail
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// ImagePolicyIsEnabled returns true if desktop images must satisfy a signature policy.
+func (c *VDICluster) ImagePolicyIsEnabled() bool {
+	return c.GetImagePolicy() != nil
+}
+
+// GetImagePolicy returns the signature policy for desktop images, or nil if none is
+// configured.
+func (c *VDICluster) GetImagePolicy() *ImagePolicyConfig {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.ImagePolicy != nil && len(c.Spec.Desktops.ImagePolicy.Keys) > 0 {
+		return c.Spec.Desktops.ImagePolicy
+	}
+	return nil
+}
+
+// GetTemplateSigningKeys returns the public keys that templates installed from a template
+// index must be signed by. An empty list means template signatures are not required.
+func (c *VDICluster) GetTemplateSigningKeys() []SigningKey {
+	if policy := c.GetImagePolicy(); policy != nil && policy.VerifyTemplates {
+		return policy.Keys
+	}
+	return nil
+}
+
+// GetImages returns the glob patterns for the images the policy applies to.
+func (p *ImagePolicyConfig) GetImages() []string {
+	if len(p.Images) > 0 {
+		return p.Images
+	}
+	return []string{"*"}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// Keys of the credentials for recording sessions in the secrets backend.
+const (
+	// RecordingAccessKeyIDKey is the key of the access key ID.
+	RecordingAccessKeyIDKey = "accessKeyID"
+	// RecordingSecretAccessKeyKey is the key of the secret access key.
+	RecordingSecretAccessKeyKey = "secretAccessKey"
+)
+
+// GetRecordingConfig returns the configuration for recording sessions, or nil if it is not
+// configured.
+func (c *VDICluster) GetRecordingConfig() *RecordingConfig {
+	if c.Spec.App != nil {
+		return c.Spec.App.Recording
+	}
+	return nil
+}
+
+// RecordingEnabled returns true if object storage is configured for recording sessions.
+func (c *VDICluster) RecordingEnabled() bool {
+	cfg := c.GetRecordingConfig()
+	return cfg != nil && cfg.Endpoint != "" && cfg.Bucket != "" && cfg.CredentialsSecret != ""
+}
+
+// GetRecordingRegion returns the region requests to the recordings bucket are signed for.
+func (c *VDICluster) GetRecordingRegion() string {
+	if cfg := c.GetRecordingConfig(); cfg != nil && cfg.Region != "" {
+		return cfg.Region
+	}
+	return v1.DefaultRecordingRegion
+}
+
+// GetRecordingPrefix returns the prefix of the keys recordings are written under.
+func (c *VDICluster) GetRecordingPrefix() string {
+	if cfg := c.GetRecordingConfig(); cfg != nil && cfg.Prefix != "" {
+		return cfg.Prefix
+	}
+	return v1.DefaultRecordingPrefix
+}
+
+// GetRecordingFrameInterval returns how often frames of recorded sessions are captured.
+func (c *VDICluster) GetRecordingFrameInterval() time.Duration {
+	if cfg := c.GetRecordingConfig(); cfg != nil && cfg.FrameInterval != "" {
+		if dur, err := time.ParseDuration(cfg.FrameInterval); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultRecordingFrameInterval
+}
+
+// GetRecordingFrameMaxWidth returns the maximum width frames of recorded sessions are
+// captured at.
+func (c *VDICluster) GetRecordingFrameMaxWidth() int {
+	if cfg := c.GetRecordingConfig(); cfg != nil && cfg.FrameMaxWidth > 0 {
+		return cfg.FrameMaxWidth
+	}
+	return v1.DefaultRecordingFrameMaxWidth
+}
+
+// GetRecordingRetention returns how long recordings are kept after they were last written
+// to, or zero if they are kept forever.
+func (c *VDICluster) GetRecordingRetention() time.Duration {
+	if cfg := c.GetRecordingConfig(); cfg != nil && cfg.Retention != "" {
+		if dur, err := time.ParseDuration(cfg.Retention); err == nil {
+			return dur
+		}
+	}
+	return time.Duration(0)
+}
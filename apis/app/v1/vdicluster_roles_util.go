@@ -46,6 +46,22 @@ func (c *VDICluster) GetRoles(cl client.Client) ([]*rbacv1.VDIRole, error) {
 	return roleList.Trim(), nil
 }
 
+// GetTeams returns a list of all the VDITeams that apply to this cluster instance. Teams are
+// matched to clusters with the same label used for VDIRoles.
+func (c *VDICluster) GetTeams(cl client.Client) ([]rbacv1.VDITeam, error) {
+	teamList := &rbacv1.VDITeamList{}
+	err := cl.List(
+		context.TODO(),
+		teamList,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels{v1.RoleClusterRefLabel: c.GetName()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return teamList.Items, nil
+}
+
 // GetLaunchTemplatesRole returns a launch-templates role for a cluster. A role like this
 // is created for every cluster for convenience. It is the default role applied to anonymous
 // users, and for non-grouped OIDC users.
@@ -64,7 +80,7 @@ func (c *VDICluster) GetLaunchTemplatesRole() *rbacv1.VDIRole {
 	} else {
 		role.Rules = []rbacv1.Rule{
 			{
-				Verbs:            []rbacv1.Verb{rbacv1.VerbRead, rbacv1.VerbUse, rbacv1.VerbLaunch},
+				Verbs:            []rbacv1.Verb{rbacv1.VerbRead, rbacv1.VerbUse, rbacv1.VerbLaunch, rbacv1.VerbUseFileTransfer, rbacv1.VerbUseClipboard, rbacv1.VerbUseMicrophone},
 				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
 				ResourcePatterns: []string{".*"},
 				Namespaces:       []string{c.GetCoreNamespace()},
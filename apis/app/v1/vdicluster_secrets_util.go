@@ -19,7 +19,10 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 
 package v1
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// SecretsBackendK8s represents using a kubernetes secret for secret storage.
@@ -38,6 +41,20 @@ func (c *VDICluster) GetSecretsBackend() string {
 	return SecretsBackendK8s
 }
 
+// GetVaultSecretsPath returns the path in vault this VDICluster stores its secrets under.
+// Isolated clusters keep their secrets under a sub-path named after the cluster, so that
+// tenants sharing a vault server and secrets path do not read each other's secrets.
+func (c *VDICluster) GetVaultSecretsPath() string {
+	if c.Spec.Secrets == nil || c.Spec.Secrets.Vault == nil {
+		return ""
+	}
+	path := c.Spec.Secrets.Vault.GetSecretsPath()
+	if c.IsIsolated() {
+		return fmt.Sprintf("%s/%s", path, c.GetName())
+	}
+	return path
+}
+
 // GetAuthRole returns the auth role to use when connecting to a vault server.
 func (v *VaultConfig) GetAuthRole() string {
 	if v.AuthRole != "" {
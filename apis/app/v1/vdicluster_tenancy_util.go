@@ -0,0 +1,89 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsIsolated returns true if this VDICluster is isolated from the other VDIClusters in the
+// management cluster.
+func (c *VDICluster) IsIsolated() bool {
+	return c.Spec.Tenancy != nil && c.Spec.Tenancy.Isolated
+}
+
+// GetTenantNamespaces returns the namespaces this VDICluster claims. The app namespace is
+// always claimed, and isolated clusters additionally claim the namespaces in their tenancy
+// configuration.
+func (c *VDICluster) GetTenantNamespaces() []string {
+	namespaces := []string{c.GetCoreNamespace()}
+	if !c.IsIsolated() {
+		return namespaces
+	}
+	for _, ns := range c.Spec.Tenancy.Namespaces {
+		if ns != "" && !hasNamespace(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// OwnsNamespace returns true if the given namespace is one of the namespaces claimed by this
+// VDICluster. Clusters that are not isolated do not restrict their sessions to the namespaces
+// they claim, so this only answers for what the cluster itself claims.
+func (c *VDICluster) OwnsNamespace(ns string) bool {
+	return hasNamespace(c.GetTenantNamespaces(), ns)
+}
+
+// OwnsObject returns true if the given role, team, or template may be used by this VDICluster.
+// Objects labeled for another cluster are never usable, and isolated clusters only use the
+// objects labeled for them.
+func (c *VDICluster) OwnsObject(obj metav1.Object) bool {
+	ref, ok := obj.GetLabels()[v1.RoleClusterRefLabel]
+	if !ok || ref == "" {
+		return !c.IsIsolated()
+	}
+	return ref == c.GetName()
+}
+
+// ClaimObject labels the given object for this VDICluster if it is isolated, so that it is
+// usable by this cluster alone.
+func (c *VDICluster) ClaimObject(obj metav1.Object) {
+	if !c.IsIsolated() {
+		return
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[v1.RoleClusterRefLabel] = c.GetName()
+	obj.SetLabels(labels)
+}
+
+func hasNamespace(namespaces []string, ns string) bool {
+	for _, n := range namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// TicketingIsEnabled returns true if sessions can be launched against tickets from an
+// external ticketing system.
+func (c *VDICluster) TicketingIsEnabled() bool {
+	return c.GetTicketing() != nil
+}
+
+// GetTicketing returns the configuration for the external ticketing system, or nil if none
+// is configured.
+func (c *VDICluster) GetTicketing() *TicketingConfig {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Ticketing != nil && c.Spec.Desktops.Ticketing.URL != "" {
+		return c.Spec.Desktops.Ticketing
+	}
+	return nil
+}
+
+// GetTicketRevalidateInterval returns how often the tickets of running sessions are
+// revalidated.
+func (c *VDICluster) GetTicketRevalidateInterval() time.Duration {
+	if cfg := c.GetTicketing(); cfg != nil && cfg.RevalidateInterval != "" {
+		if dur, err := time.ParseDuration(cfg.RevalidateInterval); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultTicketRevalidateInterval
+}
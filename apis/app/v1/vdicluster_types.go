@@ -40,19 +40,37 @@ type VDIClusterSpec struct {
 	// created volumes to `Retain`, you may want to set it explicitly on your storage-class
 	// controller as an extra safeguard.
 	UserdataSpec *corev1.PersistentVolumeClaimSpec `json:"userdataSpec,omitempty"`
+	// What to do with a user's $HOME volume created from the `userdataSpec` once the user
+	// is permanently deleted. `Retain` (the default) keeps the volume so it can be recovered
+	// by an administrator, `Delete` removes it along with the user. Volumes left behind by
+	// deleted sessions are always retained and freed for the user's next desktop.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	UserdataReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"userdataReclaimPolicy,omitempty"`
 	// A configuration for selecting pre-existing PVCs to use as the $HOME directory for
 	// sessions. This configuration takes precedence over `userdataSpec`.
 	UserdataSelector *UserdataSelector `json:"userdataSelector,omitempty"`
+	// A storage quota to enforce on user $HOME directories while sessions are running.
+	// Usage is tracked by the `kvdi-proxy` sidecar, which refuses uploads that would
+	// exceed the quota and reports usage via the API.
+	UserdataQuota *UserdataQuota `json:"userdataQuota,omitempty"`
 	// App configurations.
 	App *AppConfig `json:"app,omitempty"`
 	// Authentication configurations
 	Auth *AuthConfig `json:"auth,omitempty"`
+	// Users, roles, and templates to create when the cluster is first installed.
+	Bootstrap *BootstrapConfig `json:"bootstrap,omitempty"`
 	// Global desktop configurations
 	Desktops *DesktopsConfig `json:"desktops,omitempty"`
 	// Secrets backend configurations
 	Secrets *SecretsConfig `json:"secrets,omitempty"`
 	// Metrics configurations.
 	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	// Configurations for scaling the kVDI stack down to a minimal footprint outside of
+	// business hours.
+	EnergySaving *EnergySavingConfig `json:"energySaving,omitempty"`
+	// Configurations for isolating this VDICluster from the other VDIClusters sharing the
+	// same management cluster.
+	Tenancy *TenancyConfig `json:"tenancy,omitempty"`
 }
 
 // UserdataSelector represents a means for selecting pre-existing userdata PVCs based off
@@ -69,22 +87,351 @@ type UserdataSelector struct {
 	MatchLabel string `json:"matchLabel,omitempty"`
 }
 
+// UserdataQuota represents a per-user storage quota for $HOME directories.
+type UserdataQuota struct {
+	// The maximum amount of storage a user's $HOME directory may consume, expressed
+	// as a Kubernetes quantity (e.g. `10Gi`).
+	Limit string `json:"limit,omitempty"`
+	// The percentage of the limit at which usage is reported as a warning. Defaults
+	// to 90.
+	WarningThreshold int `json:"warningThreshold,omitempty"`
+}
+
 // IsValid returns true if this is a usable selector.
 func (u *UserdataSelector) IsValid() bool {
 	return u.MatchName != "" || u.MatchLabel != ""
 }
 
+// BootstrapConfig declares the users, roles, and templates the manager creates when the
+// cluster is first installed, so an environment can be set up declaratively. Each object
+// is only created once. Objects that already exist are left alone, and objects that were
+// bootstrapped are not recreated after they are deleted. The objects that have been
+// bootstrapped are recorded in the status of the VDICluster.
+type BootstrapConfig struct {
+	// Users to create with the local authentication provider. Users are only bootstrapped
+	// when the cluster is using local authentication.
+	Users []BootstrapUser `json:"users,omitempty"`
+	// The names of ConfigMaps in the app namespace holding `VDIRole` and `Template`
+	// manifests to create. Each key may hold one or more YAML or JSON documents separated
+	// by `---`. Roles are bound to this cluster regardless of their labels.
+	ConfigMaps []string `json:"configMaps,omitempty"`
+}
+
+// BootstrapUser represents a local user created when the cluster is first installed.
+type BootstrapUser struct {
+	// The name of the user.
+	Name string `json:"name"`
+	// The names of the roles to bind the user to.
+	Roles []string `json:"roles,omitempty"`
+	// The name of a secret in the app namespace holding the initial password for the user
+	// under the `password` key.
+	PasswordSecret string `json:"passwordSecret"`
+}
+
 // DesktopsConfig represents global configurations for desktop
 // sessions.
 type DesktopsConfig struct {
 	// When configured, desktop sessions will be forcefully terminated when
 	// the time limit is reached.
 	MaxSessionLength string `json:"maxSessionLength,omitempty"`
+	// How long a display connection may go without input from the client before it is
+	// closed with an `idle-timeout` reason. The session keeps running and the user may
+	// reconnect. When unset, idle display connections are never closed.
+	DisplayIdleTimeout string `json:"displayIdleTimeout,omitempty"`
+	// Configurations for resuming display connections after brief network loss. When
+	// unset, the connection to the desktop is closed as soon as the client drops.
+	DisplayResume *DisplayResumeConfig `json:"displayResume,omitempty"`
+	// A policy for terminating desktop sessions that go without input. Templates may
+	// override it with their own policy. When unset, idle sessions are left running.
+	IdlePolicy *IdlePolicy `json:"idlePolicy,omitempty"`
 	// The maximum number of sessions a user can run at a time. A zero value (or undefined)
 	// means no limit. When using a `userdataSpec`, you might want to set this value to 1 if
 	// you aren't using ReadWriteMany volumes. The storage controller would inevitably enforce
 	// this behavior anyway, but you would save the `kvdi-manager` some extra work.
 	SessionsPerUser int `json:"sessionsPerUser,omitempty"`
+	// The maximum number of desktop sessions that may boot at the same time on a single node.
+	// Pods beyond the limit wait in an init container until earlier sessions on the node are
+	// running, so a wave of launches does not saturate the node's disk and network while
+	// pulling images. Pods stuck on an error, or still booting ten minutes after they were
+	// admitted, stop counting against the limit. A zero value (or undefined) means no limit.
+	MaxConcurrentBootsPerNode int `json:"maxConcurrentBootsPerNode,omitempty"`
+	// Configurations for the template linter. Lint results are reported in the status
+	// of each template and via the `/api/templates/validate` endpoint.
+	Lint *LintConfig `json:"lint,omitempty"`
+	// Configurations for SSH access to desktop sessions.
+	SSH *SSHConfig `json:"ssh,omitempty"`
+	// A policy for sandboxing desktop pods based on the risk level of their template.
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+	// Configurations for joining desktops to a Kerberos realm or Active Directory domain.
+	DomainJoin *DomainJoinConfig `json:"domainJoin,omitempty"`
+	// Remote template indexes that community templates can be installed from.
+	Marketplace *MarketplaceConfig `json:"marketplace,omitempty"`
+	// A policy requiring desktop images, and optionally marketplace templates, to be signed
+	// by trusted keys.
+	ImagePolicy *ImagePolicyConfig `json:"imagePolicy,omitempty"`
+	// An external ticketing system that sessions of templates requiring a ticket are
+	// launched against.
+	Ticketing *TicketingConfig `json:"ticketing,omitempty"`
+	// Pools of license seats (e.g. MATLAB seats) that templates can require. Sessions hold
+	// their seats until they are deleted.
+	LicensePools []LicensePool `json:"licensePools,omitempty"`
+	// Configurations for the capacity hints published for node autoscalers.
+	Autoscaling *AutoscalingConfig `json:"autoscaling,omitempty"`
+	// A read-through registry cache deployed by the manager. Templates using the `mirror`
+	// image streaming mode without their own mirror host pull images through it.
+	ImageCache *ImageCacheConfig `json:"imageCache,omitempty"`
+}
+
+// ImageCacheConfig represents a read-through registry cache deployed alongside the app.
+// The cache is exposed on a NodePort so that the container runtime on each node can
+// reach it at `localhost:<nodePort>`. Container runtimes pull from `localhost` registries
+// over plain HTTP without further configuration.
+type ImageCacheConfig struct {
+	// Set to true to deploy the cache.
+	Enabled bool `json:"enabled,omitempty"`
+	// The image to run the cache with. It must be compatible with the `registry:2` proxy
+	// configuration. Defaults to `registry:2`.
+	Image string `json:"image,omitempty"`
+	// The registry to cache images from. Only images from this registry are pulled through
+	// the cache. Defaults to `https://registry-1.docker.io`.
+	Upstream string `json:"upstream,omitempty"`
+	// The node port the cache is exposed on. Defaults to 30500.
+	NodePort int32 `json:"nodePort,omitempty"`
+	// A volume claim spec for the cached layers. When unset the layers are kept in an
+	// emptyDir and are lost when the cache restarts.
+	VolumeSpec *corev1.PersistentVolumeClaimSpec `json:"volumeSpec,omitempty"`
+	// Resource requirements for the cache.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// AutoscalingConfig represents configurations for the capacity hints published for node
+// autoscalers. The app computes the number of desktops the cluster should have capacity for
+// from the running sessions, the launches waiting for a node, a warm pool of spare
+// capacity, and scheduled targets. The hints are served at `/api/autoscaling` and as
+// Prometheus metrics, so that tools such as KEDA, the cluster autoscaler, or Karpenter can
+// scale desktop node pools ahead of demand instead of reacting to pending pods.
+type AutoscalingConfig struct {
+	// The number of desktops to keep spare capacity for beyond the running and queued
+	// sessions, so new launches do not wait for a node to be provisioned.
+	WarmPool int `json:"warmPool,omitempty"`
+	// The number of desktops a single node can run. When set, the hints include the number
+	// of nodes needed for the desired desktops.
+	DesktopsPerNode int `json:"desktopsPerNode,omitempty"`
+	// Scheduled capacity targets, such as a class starting at 9am. While a schedule is
+	// active the desired desktops are at least its minimum.
+	Schedules []CapacitySchedule `json:"schedules,omitempty"`
+}
+
+// CapacitySchedule represents a minimum number of desktops to have capacity for during
+// a recurring window of time.
+type CapacitySchedule struct {
+	// A name for the schedule, reported in the hints while it is active.
+	Name string `json:"name"`
+	// The minimum number of desktops to have capacity for while the schedule is active.
+	MinDesktops int `json:"minDesktops"`
+	// The windows during which the schedule is active.
+	Schedule v1.Schedule `json:"schedule"`
+	// How long before a window opens the schedule becomes active, to give new nodes time
+	// to be provisioned. Defaults to `15m`.
+	LeadTime string `json:"leadTime,omitempty"`
+}
+
+// IdlePolicy represents a policy for terminating desktop sessions that go without input.
+// Input is tracked by the kvdi-proxy in each desktop and counts key and pointer events from
+// display clients as well as anything typed over SSH. Users are warned in-session through
+// the kvdi-agent before their session is terminated.
+type IdlePolicy struct {
+	// How long a session may go without input before it is terminated. When unset, or
+	// zero, idle sessions are left running.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// How long before the session is terminated to warn the user. Input received after
+	// the warning keeps the session running. Defaults to `5m`.
+	WarnBefore string `json:"warnBefore,omitempty"`
+}
+
+// DisplayResumeConfig represents configurations for resuming display connections. Clients
+// opt in by connecting with a `resume` ID. When such a client drops, the connection to the
+// desktop is held open for the resume window, and recent output from the desktop is kept so
+// a client reconnecting with the same ID and the number of bytes it received picks up where
+// it left off without missing frames.
+type DisplayResumeConfig struct {
+	// How long the connection to the desktop is held open after the client drops.
+	// Defaults to `30s`.
+	Window string `json:"window,omitempty"`
+	// The number of bytes of recent display output kept for resuming clients. Resumes that
+	// missed more than this start a new connection. Defaults to 4MiB.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// How long clients wait before their first reconnect attempt, to spread out the load
+	// when many clients drop at once. The wait doubles after every failed attempt until the
+	// window closes. Defaults to `500ms`.
+	InitialBackoff string `json:"initialBackoff,omitempty"`
+	// The longest clients wait between reconnect attempts. Defaults to `8s`.
+	MaxBackoff string `json:"maxBackoff,omitempty"`
+}
+
+// LicenseExhaustedPolicy represents what happens to launches when a license pool has no
+// seats left.
+// +kubebuilder:validation:Enum=Block;Queue
+type LicenseExhaustedPolicy string
+
+const (
+	// LicenseExhaustedBlock rejects launches while the pool has no seats left.
+	LicenseExhaustedBlock LicenseExhaustedPolicy = "Block"
+	// LicenseExhaustedQueue creates sessions while the pool has no seats left, and holds
+	// them until seats free up in the order they were launched.
+	LicenseExhaustedQueue LicenseExhaustedPolicy = "Queue"
+)
+
+// LicensePool represents a pool of license seats shared by the sessions of the templates
+// that require them.
+type LicensePool struct {
+	// The name of the pool, referenced by templates.
+	Name string `json:"name"`
+	// The number of seats in the pool. May be omitted when a query hook is configured.
+	Seats int `json:"seats,omitempty"`
+	// The URL of a hook reporting the seats left on the license server, to account for
+	// seats checked out outside of kVDI (e.g. a wrapper around FlexLM `lmstat`). It receives
+	// a GET and must respond with a JSON body containing the number of `available` seats.
+	// When `seats` is also set, the lower of the two counts is used.
+	QueryURL string `json:"queryURL,omitempty"`
+	// A key in the secrets backend holding a token to send as a bearer token with requests
+	// to the query hook.
+	QueryTokenSecret string `json:"queryTokenSecret,omitempty"`
+	// What happens to launches when the pool has no seats left. Defaults to `Block`.
+	OnExhausted LicenseExhaustedPolicy `json:"onExhausted,omitempty"`
+}
+
+// TicketingConfig represents a webhook validating the tickets or change IDs that sessions
+// are launched against. Sessions are terminated once their ticket is closed or expires.
+type TicketingConfig struct {
+	// The URL of the webhook. It receives a POST with a JSON body containing the `ticket`,
+	// `user`, `template`, and `session` (when revalidating a running session). It must
+	// respond with a JSON body containing whether the ticket is `valid`, and optionally a
+	// `reason` and an `expiresAt` time in RFC3339 format.
+	URL string `json:"url"`
+	// A key in the secrets backend holding a token to send as a bearer token with requests
+	// to the webhook.
+	TokenSecret string `json:"tokenSecret,omitempty"`
+	// How often to revalidate the tickets of running sessions. Defaults to 5m.
+	RevalidateInterval string `json:"revalidateInterval,omitempty"`
+}
+
+// ImagePolicyConfig represents a signature and attestation policy for the images used by
+// desktop sessions. Signatures and attestations are expected in the format pushed to the
+// registry by `cosign sign` and `cosign attest`. Sessions whose images do not satisfy the
+// policy are not started.
+type ImagePolicyConfig struct {
+	// Glob patterns for the images the policy applies to (e.g. `ghcr.io/my-org/*`). Defaults
+	// to every image in the desktop pod.
+	Images []string `json:"images,omitempty"`
+	// The public keys trusted to sign images. A signature from any one of them is sufficient.
+	Keys []SigningKey `json:"keys"`
+	// The in-toto predicate types that must be attested for each image and signed by one of
+	// the trusted keys (e.g. `https://slsa.dev/provenance/v0.2`).
+	RequireAttestations []string `json:"requireAttestations,omitempty"`
+	// Set to true to only log policy violations instead of blocking launches.
+	AuditOnly bool `json:"auditOnly,omitempty"`
+	// Set to true to require templates installed from a template index to carry a signature
+	// from one of the trusted keys, in addition to the index signature.
+	VerifyTemplates bool `json:"verifyTemplates,omitempty"`
+}
+
+// SigningKey represents a public key trusted to sign images and templates.
+type SigningKey struct {
+	// A name for the key, used when reporting which key verified an image.
+	Name string `json:"name"`
+	// The base64 encoded PEM public key. RSA, ECDSA, and Ed25519 keys are supported.
+	PublicKey string `json:"publicKey"`
+}
+
+// MarketplaceConfig represents the remote template indexes subscribed to by the cluster.
+// Templates listed in an index can be browsed and installed through the API.
+type MarketplaceConfig struct {
+	// The indexes to subscribe to.
+	Indexes []TemplateIndex `json:"indexes,omitempty"`
+}
+
+// TemplateIndex represents a remote index of desktop templates. The index is a YAML or
+// JSON document listing templates along with the URL and sha256 digest of each manifest.
+// A detached signature for the index, in the format produced by `cosign sign-blob`, must
+// be served at the same URL with a `.sig` suffix.
+type TemplateIndex struct {
+	// A name for the index. Installed templates are annotated with the index they came from.
+	Name string `json:"name"`
+	// The URL of the index document.
+	URL string `json:"url"`
+	// The base64 encoded PEM public key used to verify the signature of the index. RSA,
+	// ECDSA, and Ed25519 keys are supported.
+	PublicKey string `json:"publicKey"`
+}
+
+// DomainJoinConfig represents the Kerberos realm or Active Directory domain that desktops
+// opting in through their template are joined to. Keytabs for a pool of hosts are uploaded
+// to the secrets backend through the `/api/domain/hosts` endpoints, and every desktop leases
+// a host of its own for as long as it runs. kVDI renders `krb5.conf` and `sssd.conf` for the
+// leased host into the session along with its keytab. The kVDI ubuntu images start `sssd`
+// when the `DOMAIN_REALM` environment variable is set.
+type DomainJoinConfig struct {
+	// The Kerberos realm to join, e.g. `CORP.EXAMPLE.COM`.
+	Realm string `json:"realm"`
+	// The DNS domain of the realm. Defaults to the lowercased realm.
+	Domain string `json:"domain,omitempty"`
+	// The KDCs for the realm. When unset they are discovered through DNS.
+	KDCs []string `json:"kdcs,omitempty"`
+	// The admin server for the realm. Defaults to the first KDC.
+	AdminServer string `json:"adminServer,omitempty"`
+	// A full `krb5.conf` to use instead of the generated one.
+	KRB5Config string `json:"krb5Config,omitempty"`
+	// A full `sssd.conf` to use instead of the generated one. `$(HOSTNAME)` is replaced with
+	// the host leased by each desktop.
+	SSSDConfig string `json:"sssdConfig,omitempty"`
+}
+
+// SandboxConfig maps template risk levels to the runtime classes desktop pods run with. This
+// allows, for example, all `high` risk templates to automatically run under gVisor without
+// each template author having to configure it. A runtime class chosen by this policy takes
+// precedence over one requested by the template.
+type SandboxConfig struct {
+	// A map of template risk levels (`low`, `medium`, or `high`) to the name of the
+	// RuntimeClass pods of that level should run with.
+	RuntimeClasses map[string]string `json:"runtimeClasses,omitempty"`
+	// The risk level assumed for templates that do not declare one. Defaults to `low`.
+	DefaultRiskLevel string `json:"defaultRiskLevel,omitempty"`
+}
+
+// SSHConfig represents configurations for the SSH gateway. When enabled, kVDI signs
+// short-lived SSH certificates for session owners and proxies SSH connections to the
+// desktop through the API. Desktop images must run an SSH server on port 22 that trusts
+// the CA provided in the `SSH_TRUSTED_CA_KEYS` environment variable.
+type SSHConfig struct {
+	// Set to true to enable the SSH gateway.
+	Enabled bool `json:"enabled,omitempty"`
+	// How long issued user certificates are valid for. Defaults to `1h`.
+	CertificateTTL string `json:"certificateTTL,omitempty"`
+}
+
+// LintSeverity represents the severity of a template lint rule.
+// +kubebuilder:validation:Enum=off;info;warning;error
+type LintSeverity string
+
+const (
+	// LintSeverityOff disables a lint rule.
+	LintSeverityOff LintSeverity = "off"
+	// LintSeverityInfo reports findings as informational.
+	LintSeverityInfo LintSeverity = "info"
+	// LintSeverityWarning reports findings as warnings.
+	LintSeverityWarning LintSeverity = "warning"
+	// LintSeverityError reports findings as errors. Templates with error findings
+	// are rejected by the API.
+	LintSeverityError LintSeverity = "error"
+)
+
+// LintConfig represents configurations for the template linter.
+type LintConfig struct {
+	// Overrides for the severity of individual lint rules. The keys are the names of
+	// the rules and the values one of `off`, `info`, `warning`, or `error`. Rules not
+	// present in the map use their default severity.
+	Rules map[string]LintSeverity `json:"rules,omitempty"`
 }
 
 // AppConfig represents app configurations for the VDI cluster
@@ -94,8 +441,15 @@ type AppConfig struct {
 	Image string `json:"image,omitempty"`
 	// Whether to add CORS headers to API requests
 	CORSEnabled bool `json:"corsEnabled,omitempty"`
-	// Whether to log auditing events to stdout
+	// Whether to log auditing events to stdout. This is the same as setting `audit` with
+	// the default `stdout` sink.
 	AuditLog bool `json:"auditLog,omitempty"`
+	// Configurations for where auditing events are recorded. Setting this enables the
+	// audit log.
+	Audit *AuditConfig `json:"audit,omitempty"`
+	// Configurations for recording desktop sessions to object storage. Setting this
+	// enables recording for templates with `display.record` set.
+	Recording *RecordingConfig `json:"recording,omitempty"`
 	// The number of app replicas to run
 	Replicas int32 `json:"replicas,omitempty"`
 	// The type of service to create in front of the app instance.
@@ -107,6 +461,182 @@ type AppConfig struct {
 	TLS *TLSConfig `json:"tls,omitempty"`
 	// Resource requirements to place on the app pods
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Configurations for accepting reverse tunnels from desktop proxies.
+	Tunnels *TunnelConfig `json:"tunnels,omitempty"`
+	// A path prefix to serve the app under (e.g. `/vdi/`), for hosting kVDI behind a proxy
+	// that serves other apps on the same hostname. The API, websockets, and UI are all served
+	// under the prefix. The API also remains available at the root of the app service, for
+	// probes and for desktops reaching the app from inside the cluster.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Additional gateways clients may connect to desktops through, such as app instances
+	// exposed in other zones or federated clusters. Clients measure their latency to each
+	// gateway, and display connections are routed through the one with the lowest latency.
+	Gateways []GatewayConfig `json:"gateways,omitempty"`
+	// The display bandwidth, in bytes per second, each app instance shares between the
+	// display connections passing through it (e.g. `50Mi`). When the limit is reached,
+	// sessions receive bandwidth in proportion to the `bandwidthWeight` of their users'
+	// roles, so one session streaming video cannot starve the others. Defaults to no limit.
+	DisplayBandwidth string `json:"displayBandwidth,omitempty"`
+	// Deployment-specific middleware to run on API requests. Middleware compiled into the
+	// app always runs, these configurations add external webhook filters.
+	Middleware *MiddlewareConfig `json:"middleware,omitempty"`
+}
+
+// MiddlewareStage represents the point in the handling of an API request at which a
+// middleware runs.
+// +kubebuilder:validation:Enum=PreAuth;PostAuth
+type MiddlewareStage string
+
+const (
+	// MiddlewareStagePreAuth middleware runs on every API request before the user session
+	// is validated, so it may add or rewrite the headers read by the auth provider.
+	MiddlewareStagePreAuth MiddlewareStage = "PreAuth"
+	// MiddlewareStagePostAuth middleware runs on authenticated API requests after the user
+	// session and grants are validated.
+	MiddlewareStagePostAuth MiddlewareStage = "PostAuth"
+)
+
+// MiddlewareFailurePolicy represents what happens to API requests when a webhook filter
+// cannot be reached or returns an error.
+// +kubebuilder:validation:Enum=Ignore;Fail
+type MiddlewareFailurePolicy string
+
+const (
+	// MiddlewareFailureIgnore lets requests through unfiltered.
+	MiddlewareFailureIgnore MiddlewareFailurePolicy = "Ignore"
+	// MiddlewareFailureFail rejects requests.
+	MiddlewareFailureFail MiddlewareFailurePolicy = "Fail"
+)
+
+// MiddlewareConfig represents deployment-specific middleware for the API.
+type MiddlewareConfig struct {
+	// External webhooks filtering API requests, run in order after any middleware compiled
+	// into the app for the same stage.
+	Webhooks []MiddlewareWebhook `json:"webhooks,omitempty"`
+}
+
+// MiddlewareWebhook represents an external webhook filtering API requests, and optionally
+// their responses. The webhook receives a POST with a JSON body containing the `stage`,
+// `method`, `path`, `query`, and `headers` of the request, and the `user` making it for
+// `PostAuth` webhooks. Session tokens, authorization headers, and cookies are never sent.
+// When filtering a response, the body also contains the `response` with its `status`,
+// `headers`, and JSON `body`. The webhook must respond with a JSON body containing whether
+// the request is `allowed`, and optionally a `reason` and `status` (401 or 403) to deny it
+// with, `setHeaders` and `removeHeaders` to apply to the request (or the response), and a
+// replacement `body` for the response.
+type MiddlewareWebhook struct {
+	// A unique name for the webhook, used in logs.
+	Name string `json:"name"`
+	// The URL of the webhook.
+	URL string `json:"url"`
+	// A key in the secrets backend holding a token to send as a bearer token with requests
+	// to the webhook.
+	TokenSecret string `json:"tokenSecret,omitempty"`
+	// The stage at which the webhook runs. Defaults to `PostAuth`.
+	Stage MiddlewareStage `json:"stage,omitempty"`
+	// Regular expressions matched against the path of API requests (e.g.
+	// `^/api/sessions`). Defaults to every request.
+	Paths []string `json:"paths,omitempty"`
+	// Set to true to also send JSON responses to the webhook, for it to inspect or redact
+	// them. Websocket connections and other responses are not filtered.
+	Responses bool `json:"responses,omitempty"`
+	// How long to wait for the webhook to respond. Defaults to 5s.
+	Timeout string `json:"timeout,omitempty"`
+	// What happens to requests when the webhook cannot be reached or returns an error.
+	// Defaults to `Fail`.
+	FailurePolicy MiddlewareFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// GatewayConfig represents an additional endpoint serving the kVDI API for this cluster.
+type GatewayConfig struct {
+	// A unique name for the gateway.
+	Name string `json:"name"`
+	// The external URL of the gateway, including any path prefix (e.g.
+	// `https://us-east.vdi.example.com`). The gateway must share the JWT secret of the
+	// cluster so tokens issued by one gateway are accepted by the others.
+	URL string `json:"url"`
+	// The zone or region the gateway is in, for display purposes.
+	Zone string `json:"zone,omitempty"`
+}
+
+// AuditSinkType represents a destination for auditing events.
+// +kubebuilder:validation:Enum=stdout;file;webhook;event
+type AuditSinkType string
+
+const (
+	// AuditSinkStdout logs auditing events to stdout along with the rest of the app logs.
+	AuditSinkStdout AuditSinkType = "stdout"
+	// AuditSinkFile appends auditing events to a file as JSON lines.
+	AuditSinkFile AuditSinkType = "file"
+	// AuditSinkWebhook sends each auditing event in a POST request to a webhook.
+	AuditSinkWebhook AuditSinkType = "webhook"
+	// AuditSinkEvent records auditing events as Kubernetes Events on the VDICluster.
+	AuditSinkEvent AuditSinkType = "event"
+)
+
+// AuditConfig represents configurations for the audit log. Every authenticated API request
+// is recorded with the user, the actions it required, whether it was allowed, the source
+// IP, and the time. Each app instance also keeps its most recent events in memory so they
+// can be queried through the API.
+type AuditConfig struct {
+	// Where to record auditing events. Defaults to `stdout`.
+	Sink AuditSinkType `json:"sink,omitempty"`
+	// The path of the file to append events to when using the `file` sink. The file is
+	// created if it does not exist. Defaults to `/var/log/kvdi/audit.log`.
+	FilePath string `json:"filePath,omitempty"`
+	// The URL events are sent to when using the `webhook` sink. Each event is sent in a
+	// POST request with a JSON body.
+	WebhookURL string `json:"webhookURL,omitempty"`
+	// A key in the secrets backend holding a token to send as a bearer token with requests
+	// to the webhook.
+	WebhookTokenSecret string `json:"webhookTokenSecret,omitempty"`
+	// The number of recent events each app instance keeps in memory to serve from the API.
+	// Defaults to 1000.
+	Retention int `json:"retention,omitempty"`
+}
+
+// RecordingConfig represents configurations for recording desktop sessions to object
+// storage. Any store speaking the S3 API may be used, such as Amazon S3, MinIO, or Google
+// Cloud Storage with HMAC keys. Sessions are recorded while clients are connected to their
+// display, as frames captured on an interval along with when clients connect, disconnect,
+// and send input. The contents of input, such as keystrokes, are not recorded. Users need
+// the `read` verb on `recordings` to list and play them back.
+type RecordingConfig struct {
+	// The URL of the object storage endpoint (e.g. `https://s3.us-east-1.amazonaws.com`,
+	// `https://storage.googleapis.com`, or `http://minio.minio:9000`). Buckets are
+	// addressed by path.
+	Endpoint string `json:"endpoint"`
+	// The region of the bucket, used for signing requests. Defaults to `us-east-1`.
+	Region string `json:"region,omitempty"`
+	// The bucket to write recordings to.
+	Bucket string `json:"bucket"`
+	// The prefix of the keys recordings are written under. Defaults to `recordings/`.
+	Prefix string `json:"prefix,omitempty"`
+	// The key in the secrets backend holding the credentials for the bucket. The value is a
+	// map with an `accessKeyID` and `secretAccessKey`.
+	CredentialsSecret string `json:"credentialsSecret"`
+	// How often a frame of the display is captured (e.g. `2s`). Defaults to `5s`.
+	FrameInterval string `json:"frameInterval,omitempty"`
+	// The maximum width of captured frames. Larger displays are scaled down to fit.
+	// Defaults to 1280.
+	FrameMaxWidth int `json:"frameMaxWidth,omitempty"`
+	// How long recordings are kept after they were last written to before they are
+	// deleted (e.g. `720h`). Defaults to keeping them forever.
+	Retention string `json:"retention,omitempty"`
+}
+
+// TunnelConfig contains configurations for accepting reverse tunnels from desktop proxies.
+// Desktops booted from templates with `reverseTunnel` enabled dial out to the app instead
+// of waiting for the app to connect to them, so they can run behind NAT or firewalls the
+// app cannot reach. When running more than one replica of the app, replicas without a
+// tunnel parked for a session relay their connections through a replica that has one.
+type TunnelConfig struct {
+	// Set to true to have the app listen for reverse tunnels.
+	Enabled bool `json:"enabled,omitempty"`
+	// The address desktop proxies dial to establish tunnels, in the format of `host:port`.
+	// Defaults to the app service inside the cluster. Set this when desktops run in
+	// clusters or networks that cannot resolve the app service.
+	Address string `json:"address,omitempty"`
 }
 
 // TLSConfig contains TLS configurations for kVDI.
@@ -135,6 +665,10 @@ type ServiceMonitorConfig struct {
 	// in your prometheus-operator configuration (usually `{"release": "<helm_release_name>"}`).
 	// Defaults to `{"release": "prometheus"}`.
 	Labels map[string]string `json:"labels,omitempty"`
+	// Set to true to have the kvdi-proxy of each desktop serve metrics about its streams,
+	// and to create a ServiceMonitor object for scraping them. The ServiceMonitor selects
+	// desktop services in all namespaces.
+	Proxies bool `json:"proxies,omitempty"`
 }
 
 // PrometheusConfig contains configuration options for a prometheus deployment.
@@ -163,6 +697,24 @@ type AuthConfig struct {
 	// you may want to set this to a higher value (e.g. 8-10h) since the refresh token
 	// flow will not be able to lookup a user's grants from the provider. Defaults to `15m`.
 	TokenDuration string `json:"tokenDuration,omitempty"`
+	// How long access tokens are valid for in sessions that can be refreshed or renewed, so
+	// that a short lifetime can be used with sliding renewal while sessions that cannot be
+	// refreshed (such as OIDC logins) keep the longer `tokenDuration`. Defaults to the value
+	// of `tokenDuration`.
+	AccessTokenTTL string `json:"accessTokenTTL,omitempty"`
+	// How long a user session may go without refreshing or renewing its tokens before a new
+	// login is required. Every refresh or renewal counts as activity. When unset, refresh
+	// tokens remain valid until they are used or revoked.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// The absolute maximum age of a user session, measured from the original login. Tokens are
+	// never issued past this age regardless of activity. When unset, sessions may be renewed
+	// indefinitely.
+	MaxSessionAge string `json:"maxSessionAge,omitempty"`
+	// How long deleted users and roles are kept before they are purged permanently (e.g.
+	// `168h`). Until then they can be listed and restored through the API, along with the
+	// roles bound to them. Set to `0s` to delete them permanently right away. Defaults to
+	// `72h`.
+	DeletionRetention string `json:"deletionRetention,omitempty"`
 	// The rules to apply to the default role created for this cluster. These are the rules applied to
 	// anonymous users (if allowed) and non-grouped OIDC users. They can also be used for convenience
 	// when getting started. The defaults only allow for launching templates in the `appNamespace`.
@@ -173,6 +725,71 @@ type AuthConfig struct {
 	LDAPAuth *LDAPConfig `json:"ldapAuth,omitempty"`
 	// Use OIDC for authentication
 	OIDCAuth *OIDCConfig `json:"oidcAuth,omitempty"`
+	// Require a trusted device before granting display access to users with sensitive roles.
+	DeviceTrust *DeviceTrustConfig `json:"deviceTrust,omitempty"`
+	// A sealed local admin account for regaining access when the auth provider is
+	// unavailable. The account cannot be used unless this is configured and enabled.
+	BreakGlass *BreakGlassConfig `json:"breakGlass,omitempty"`
+}
+
+// BreakGlassConfig represents a sealed admin account that does not depend on the auth
+// provider. It is unlocked by signing a one-time challenge issued by the API with a private
+// key held out-of-band, such as on a hardware token, in a KMS, or in a sealed secret. Every
+// challenge and unlock attempt is written to the audit log, even when audit logging is
+// otherwise disabled.
+type BreakGlassConfig struct {
+	// Set to true to allow the account to be unlocked.
+	Enabled bool `json:"enabled,omitempty"`
+	// The username sessions of the unlocked account are issued for. Defaults to
+	// `break-glass`.
+	Username string `json:"username,omitempty"`
+	// The base64 encoded PEM public key that unlock challenges must be signed with. RSA and
+	// ECDSA signatures are expected over the SHA-256 digest of the challenge, Ed25519
+	// signatures over the challenge itself.
+	PublicKey string `json:"publicKey"`
+}
+
+// DeviceTrustConfig configures device posture checks. When configured, users holding one of
+// the configured roles must present a signed device assertion, either at login or when connecting,
+// before they are allowed to access a desktop display.
+type DeviceTrustConfig struct {
+	// The names of the VDIRoles that require a trusted device. When empty, all users require
+	// a trusted device.
+	Roles []string `json:"roles,omitempty"`
+	// The issuers trusted to assert device posture. An assertion verified by any one of them
+	// is sufficient.
+	Issuers []DeviceTrustIssuer `json:"issuers,omitempty"`
+}
+
+// DeviceTrustMethod represents a method for presenting a device assertion.
+// +kubebuilder:validation:Enum=clientCert;header
+type DeviceTrustMethod string
+
+const (
+	// DeviceTrustClientCert verifies a TLS client certificate presented to the app server.
+	DeviceTrustClientCert DeviceTrustMethod = "clientCert"
+	// DeviceTrustHeader verifies a signed JWT presented in a request header, such as one
+	// injected by a managed browser.
+	DeviceTrustHeader DeviceTrustMethod = "header"
+)
+
+// DeviceTrustIssuer represents a source of signed device assertions.
+type DeviceTrustIssuer struct {
+	// A name for the issuer. This is recorded in user sessions that were verified by it.
+	Name string `json:"name"`
+	// The method used to present assertions from this issuer.
+	Method DeviceTrustMethod `json:"method"`
+	// The base64 encoded PEM used to verify assertions. For the `clientCert` method this is
+	// the CA bundle that signs device certificates. For the `header` method this is the certificate
+	// or public key that signs assertion tokens.
+	Certificate string `json:"certificate"`
+	// The request header containing the assertion when using the `header` method. Defaults
+	// to `X-Device-Assertion`.
+	Header string `json:"header,omitempty"`
+	// The audience (`aud` claim) that assertions must be issued for when using the `header`
+	// method, so that tokens the issuer signs for other services are not accepted. Defaults
+	// to `kvdi`.
+	Audience string `json:"audience,omitempty"`
 }
 
 // SecretsConfig configurese the backend for secrets management.
@@ -231,6 +848,10 @@ type LDAPConfig struct {
 	// When set to true, the authentication provider will query the user's attributes for the `userStatusAttribute`
 	// and make sure it matches the value in `userStatusEnabledValue` before attemtping to bind.
 	DoStatusCheck bool `json:"doStatusCheck,omitempty"`
+	// LDAP attributes of the user to copy into their kvdi attributes, so they can be matched
+	// by the conditions of rules (e.g. `department`). Multi-valued attributes use their
+	// first value.
+	UserAttributes []string `json:"userAttributes,omitempty"`
 }
 
 // IsUndefined returns true if the given LDAPConfig object is not actually configured.
@@ -294,6 +915,10 @@ type OIDCConfig struct {
 	// only use this setting in trusted environments where access to the necessary kubernetes APIs is only available to
 	// a select group of administrators, and the risk of the user using a compromised browser is minimal.
 	PreserveTokens bool `json:"preserveTokens,omitempty"`
+	// Claims of the ID token to copy into the user's kvdi attributes, so they can be matched
+	// by the conditions of rules (e.g. `department`). String, number, and boolean claims are
+	// supported.
+	AttributeClaims []string `json:"attributeClaims,omitempty"`
 }
 
 // IsUndefined returns true if the given OIDCConfig object is not actually configured.
@@ -329,8 +954,65 @@ type VaultConfig struct {
 // It checks that required values are present.
 func (v *VaultConfig) IsUndefined() bool { return v.Address == "" }
 
+// EnergySavingConfig represents configurations for scaling the kVDI stack down outside
+// of business hours. While asleep, the app deployment is scaled to zero and a small
+// wake-up listener serves its traffic instead. The first request to the listener scales
+// the app back up. The manager keeps running so it can act on wake-up requests, unless
+// ScaleManager is set.
+type EnergySavingConfig struct {
+	// Set to true to scale the stack down outside of business hours.
+	Enabled bool `json:"enabled,omitempty"`
+	// The days of the week that are business days (e.g. `Monday` or `mon`). Defaults to
+	// Monday through Friday.
+	Days []string `json:"days,omitempty"`
+	// The time of day business hours start, in 24-hour `HH:MM` format and UTC. Defaults
+	// to `08:00`.
+	StartTime string `json:"startTime,omitempty"`
+	// The time of day business hours end, in 24-hour `HH:MM` format and UTC. Defaults to
+	// `18:00`. Business hours spanning midnight are supported by setting an end time earlier
+	// than the start time.
+	EndTime string `json:"endTime,omitempty"`
+	// Set to true to also stop the pods of sessions that have no connected users outside
+	// of business hours. Paused sessions are started again when the stack wakes up.
+	PauseIdleSessions bool `json:"pauseIdleSessions,omitempty"`
+	// How long the stack stays awake after a wake-up request outside of business hours.
+	// Defaults to one hour. The stack will not go back to sleep while any session has a
+	// connected user.
+	WakeDuration string `json:"wakeDuration,omitempty"`
+	// Set to true to also scale the manager down while the stack is asleep. The wake-up
+	// listener scales it back up on the first request or when business hours start. Only
+	// enable this when the manager serves no other VDIClusters.
+	ScaleManager bool `json:"scaleManager,omitempty"`
+	// The name of the manager deployment to scale down when ScaleManager is set. Defaults
+	// to `kvdi-controller-manager`.
+	ManagerDeployment string `json:"managerDeployment,omitempty"`
+	// The namespace of the manager deployment. Defaults to `kvdi-system`.
+	ManagerNamespace string `json:"managerNamespace,omitempty"`
+}
+
+// TenancyConfig represents the isolation of a VDICluster from the other VDIClusters in the
+// management cluster. An isolated VDICluster owns a set of namespaces that no other VDICluster
+// may run its app or sessions in, only uses the templates, roles, and teams labeled for it
+// with `kvdi.io/cluster-ref`, and keeps its vault secrets under a path named after it.
+type TenancyConfig struct {
+	// Set to true to isolate this VDICluster from the other VDIClusters in the management
+	// cluster. Templates created through the API of an isolated VDICluster are labeled for it
+	// automatically.
+	Isolated bool `json:"isolated,omitempty"`
+	// The namespaces sessions of this VDICluster may run in, in addition to the app namespace.
+	// A namespace may only belong to one isolated VDICluster, and is unavailable to the
+	// sessions of all other VDIClusters.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
 // VDIClusterStatus defines the observed state of VDICluster
-type VDIClusterStatus struct{}
+type VDIClusterStatus struct {
+	// Set while the stack is scaled down for energy saving.
+	Asleep bool `json:"asleep,omitempty"`
+	// The objects created from the bootstrap configuration, in the form `users/<name>`,
+	// `roles/<name>`, or `templates/<name>`.
+	Bootstrapped []string `json:"bootstrapped,omitempty"`
+}
 
 //+kubebuilder:object:root=true
 //+kubebuilder:resource:path=vdiclusters,scope=Cluster
@@ -34,6 +34,16 @@ import (
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 	*out = *in
+	if in.Audit != nil {
+		in, out := &in.Audit, &out.Audit
+		*out = new(AuditConfig)
+		**out = **in
+	}
+	if in.Recording != nil {
+		in, out := &in.Recording, &out.Recording
+		*out = new(RecordingConfig)
+		**out = **in
+	}
 	if in.ServiceAnnotations != nil {
 		in, out := &in.ServiceAnnotations, &out.ServiceAnnotations
 		*out = make(map[string]string, len(*in))
@@ -47,6 +57,21 @@ func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 		**out = **in
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Tunnels != nil {
+		in, out := &in.Tunnels, &out.Tunnels
+		*out = new(TunnelConfig)
+		**out = **in
+	}
+	if in.Gateways != nil {
+		in, out := &in.Gateways, &out.Gateways
+		*out = make([]GatewayConfig, len(*in))
+		copy(*out, *in)
+	}
+	if in.Middleware != nil {
+		in, out := &in.Middleware, &out.Middleware
+		*out = new(MiddlewareConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppConfig.
@@ -59,6 +84,21 @@ func (in *AppConfig) DeepCopy() *AppConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditConfig) DeepCopyInto(out *AuditConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditConfig.
+func (in *AuditConfig) DeepCopy() *AuditConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 	*out = *in
@@ -84,6 +124,16 @@ func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 		*out = new(OIDCConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DeviceTrust != nil {
+		in, out := &in.DeviceTrust, &out.DeviceTrust
+		*out = new(DeviceTrustConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BreakGlass != nil {
+		in, out := &in.BreakGlass, &out.BreakGlass
+		*out = new(BreakGlassConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthConfig.
@@ -96,9 +146,169 @@ func (in *AuthConfig) DeepCopy() *AuthConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingConfig) DeepCopyInto(out *AutoscalingConfig) {
+	*out = *in
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]CapacitySchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingConfig.
+func (in *AutoscalingConfig) DeepCopy() *AutoscalingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BootstrapConfig) DeepCopyInto(out *BootstrapConfig) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]BootstrapUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BootstrapConfig.
+func (in *BootstrapConfig) DeepCopy() *BootstrapConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(BootstrapConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BootstrapUser) DeepCopyInto(out *BootstrapUser) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BootstrapUser.
+func (in *BootstrapUser) DeepCopy() *BootstrapUser {
+	if in == nil {
+		return nil
+	}
+	out := new(BootstrapUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BreakGlassConfig) DeepCopyInto(out *BreakGlassConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BreakGlassConfig.
+func (in *BreakGlassConfig) DeepCopy() *BreakGlassConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(BreakGlassConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacitySchedule) DeepCopyInto(out *CapacitySchedule) {
+	*out = *in
+	in.Schedule.DeepCopyInto(&out.Schedule)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacitySchedule.
+func (in *CapacitySchedule) DeepCopy() *CapacitySchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacitySchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DesktopsConfig) DeepCopyInto(out *DesktopsConfig) {
 	*out = *in
+	if in.DisplayResume != nil {
+		in, out := &in.DisplayResume, &out.DisplayResume
+		*out = new(DisplayResumeConfig)
+		**out = **in
+	}
+	if in.IdlePolicy != nil {
+		in, out := &in.IdlePolicy, &out.IdlePolicy
+		*out = new(IdlePolicy)
+		**out = **in
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = new(LintConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSH != nil {
+		in, out := &in.SSH, &out.SSH
+		*out = new(SSHConfig)
+		**out = **in
+	}
+	if in.Sandbox != nil {
+		in, out := &in.Sandbox, &out.Sandbox
+		*out = new(SandboxConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DomainJoin != nil {
+		in, out := &in.DomainJoin, &out.DomainJoin
+		*out = new(DomainJoinConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Marketplace != nil {
+		in, out := &in.Marketplace, &out.Marketplace
+		*out = new(MarketplaceConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePolicy != nil {
+		in, out := &in.ImagePolicy, &out.ImagePolicy
+		*out = new(ImagePolicyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ticketing != nil {
+		in, out := &in.Ticketing, &out.Ticketing
+		*out = new(TicketingConfig)
+		**out = **in
+	}
+	if in.LicensePools != nil {
+		in, out := &in.LicensePools, &out.LicensePools
+		*out = make([]LicensePool, len(*in))
+		copy(*out, *in)
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(AutoscalingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageCache != nil {
+		in, out := &in.ImageCache, &out.ImageCache
+		*out = new(ImageCacheConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopsConfig.
@@ -111,6 +321,116 @@ func (in *DesktopsConfig) DeepCopy() *DesktopsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceTrustConfig) DeepCopyInto(out *DeviceTrustConfig) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Issuers != nil {
+		in, out := &in.Issuers, &out.Issuers
+		*out = make([]DeviceTrustIssuer, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceTrustConfig.
+func (in *DeviceTrustConfig) DeepCopy() *DeviceTrustConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceTrustConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceTrustIssuer) DeepCopyInto(out *DeviceTrustIssuer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceTrustIssuer.
+func (in *DeviceTrustIssuer) DeepCopy() *DeviceTrustIssuer {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceTrustIssuer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisplayResumeConfig) DeepCopyInto(out *DisplayResumeConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisplayResumeConfig.
+func (in *DisplayResumeConfig) DeepCopy() *DisplayResumeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DisplayResumeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DomainJoinConfig) DeepCopyInto(out *DomainJoinConfig) {
+	*out = *in
+	if in.KDCs != nil {
+		in, out := &in.KDCs, &out.KDCs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DomainJoinConfig.
+func (in *DomainJoinConfig) DeepCopy() *DomainJoinConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DomainJoinConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnergySavingConfig) DeepCopyInto(out *EnergySavingConfig) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnergySavingConfig.
+func (in *EnergySavingConfig) DeepCopy() *EnergySavingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EnergySavingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayConfig) DeepCopyInto(out *GatewayConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfig.
+func (in *GatewayConfig) DeepCopy() *GatewayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GrafanaConfig) DeepCopyInto(out *GrafanaConfig) {
 	*out = *in
@@ -126,6 +446,72 @@ func (in *GrafanaConfig) DeepCopy() *GrafanaConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdlePolicy) DeepCopyInto(out *IdlePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdlePolicy.
+func (in *IdlePolicy) DeepCopy() *IdlePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IdlePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageCacheConfig) DeepCopyInto(out *ImageCacheConfig) {
+	*out = *in
+	if in.VolumeSpec != nil {
+		in, out := &in.VolumeSpec, &out.VolumeSpec
+		*out = new(corev1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageCacheConfig.
+func (in *ImageCacheConfig) DeepCopy() *ImageCacheConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageCacheConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePolicyConfig) DeepCopyInto(out *ImagePolicyConfig) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]SigningKey, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequireAttestations != nil {
+		in, out := &in.RequireAttestations, &out.RequireAttestations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyConfig.
+func (in *ImagePolicyConfig) DeepCopy() *ImagePolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K8SSecretConfig) DeepCopyInto(out *K8SSecretConfig) {
 	*out = *in
@@ -149,6 +535,11 @@ func (in *LDAPConfig) DeepCopyInto(out *LDAPConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.UserAttributes != nil {
+		in, out := &in.UserAttributes, &out.UserAttributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPConfig.
@@ -161,6 +552,43 @@ func (in *LDAPConfig) DeepCopy() *LDAPConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicensePool) DeepCopyInto(out *LicensePool) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicensePool.
+func (in *LicensePool) DeepCopy() *LicensePool {
+	if in == nil {
+		return nil
+	}
+	out := new(LicensePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintConfig) DeepCopyInto(out *LintConfig) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make(map[string]LintSeverity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintConfig.
+func (in *LintConfig) DeepCopy() *LintConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LintConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LocalAuthConfig) DeepCopyInto(out *LocalAuthConfig) {
 	*out = *in
@@ -176,6 +604,26 @@ func (in *LocalAuthConfig) DeepCopy() *LocalAuthConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MarketplaceConfig) DeepCopyInto(out *MarketplaceConfig) {
+	*out = *in
+	if in.Indexes != nil {
+		in, out := &in.Indexes, &out.Indexes
+		*out = make([]TemplateIndex, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MarketplaceConfig.
+func (in *MarketplaceConfig) DeepCopy() *MarketplaceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MarketplaceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsConfig) DeepCopyInto(out *MetricsConfig) {
 	*out = *in
@@ -206,6 +654,48 @@ func (in *MetricsConfig) DeepCopy() *MetricsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MiddlewareConfig) DeepCopyInto(out *MiddlewareConfig) {
+	*out = *in
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]MiddlewareWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MiddlewareConfig.
+func (in *MiddlewareConfig) DeepCopy() *MiddlewareConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MiddlewareConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MiddlewareWebhook) DeepCopyInto(out *MiddlewareWebhook) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MiddlewareWebhook.
+func (in *MiddlewareWebhook) DeepCopy() *MiddlewareWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(MiddlewareWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
 	*out = *in
@@ -219,6 +709,11 @@ func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AttributeClaims != nil {
+		in, out := &in.AttributeClaims, &out.AttributeClaims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCConfig.
@@ -247,6 +742,58 @@ func (in *PrometheusConfig) DeepCopy() *PrometheusConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordingConfig) DeepCopyInto(out *RecordingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordingConfig.
+func (in *RecordingConfig) DeepCopy() *RecordingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHConfig) DeepCopyInto(out *SSHConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHConfig.
+func (in *SSHConfig) DeepCopy() *SSHConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxConfig) DeepCopyInto(out *SandboxConfig) {
+	*out = *in
+	if in.RuntimeClasses != nil {
+		in, out := &in.RuntimeClasses, &out.RuntimeClasses
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxConfig.
+func (in *SandboxConfig) DeepCopy() *SandboxConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretsConfig) DeepCopyInto(out *SecretsConfig) {
 	*out = *in
@@ -294,6 +841,21 @@ func (in *ServiceMonitorConfig) DeepCopy() *ServiceMonitorConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SigningKey) DeepCopyInto(out *SigningKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SigningKey.
+func (in *SigningKey) DeepCopy() *SigningKey {
+	if in == nil {
+		return nil
+	}
+	out := new(SigningKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 	*out = *in
@@ -309,6 +871,86 @@ func (in *TLSConfig) DeepCopy() *TLSConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateIndex) DeepCopyInto(out *TemplateIndex) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateIndex.
+func (in *TemplateIndex) DeepCopy() *TemplateIndex {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateIndex)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenancyConfig) DeepCopyInto(out *TenancyConfig) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenancyConfig.
+func (in *TenancyConfig) DeepCopy() *TenancyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TenancyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TicketingConfig) DeepCopyInto(out *TicketingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TicketingConfig.
+func (in *TicketingConfig) DeepCopy() *TicketingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TicketingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TunnelConfig) DeepCopyInto(out *TunnelConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TunnelConfig.
+func (in *TunnelConfig) DeepCopy() *TunnelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TunnelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserdataQuota) DeepCopyInto(out *UserdataQuota) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserdataQuota.
+func (in *UserdataQuota) DeepCopy() *UserdataQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(UserdataQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserdataSelector) DeepCopyInto(out *UserdataSelector) {
 	*out = *in
@@ -330,7 +972,7 @@ func (in *VDICluster) DeepCopyInto(out *VDICluster) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDICluster.
@@ -401,6 +1043,11 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 		*out = new(UserdataSelector)
 		**out = **in
 	}
+	if in.UserdataQuota != nil {
+		in, out := &in.UserdataQuota, &out.UserdataQuota
+		*out = new(UserdataQuota)
+		**out = **in
+	}
 	if in.App != nil {
 		in, out := &in.App, &out.App
 		*out = new(AppConfig)
@@ -411,10 +1058,15 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 		*out = new(AuthConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Bootstrap != nil {
+		in, out := &in.Bootstrap, &out.Bootstrap
+		*out = new(BootstrapConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Desktops != nil {
 		in, out := &in.Desktops, &out.Desktops
 		*out = new(DesktopsConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Secrets != nil {
 		in, out := &in.Secrets, &out.Secrets
@@ -426,6 +1078,16 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 		*out = new(MetricsConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.EnergySaving != nil {
+		in, out := &in.EnergySaving, &out.EnergySaving
+		*out = new(EnergySavingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tenancy != nil {
+		in, out := &in.Tenancy, &out.Tenancy
+		*out = new(TenancyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIClusterSpec.
@@ -441,6 +1103,11 @@ func (in *VDIClusterSpec) DeepCopy() *VDIClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VDIClusterStatus) DeepCopyInto(out *VDIClusterStatus) {
 	*out = *in
+	if in.Bootstrapped != nil {
+		in, out := &in.Bootstrapped, &out.Bootstrapped
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIClusterStatus.
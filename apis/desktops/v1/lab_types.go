@@ -0,0 +1,126 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabSpec defines the desired state of Lab
+type LabSpec struct {
+	// The VDICluster this Lab belongs to.
+	VDICluster string `json:"vdiCluster"`
+	// The Template to launch desktops from for each member of the roster.
+	Template string `json:"template"`
+	// A service account to tie to the desktops launched for the roster.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// The users who are allowed to manage the lab. Instructors can view the roster's
+	// sessions, broadcast messages, lock screens, and collect files.
+	Instructors []string `json:"instructors,omitempty"`
+	// The users and teams that desktops are provisioned for.
+	Roster LabRoster `json:"roster,omitempty"`
+	// The windows during which desktops are provisioned for the roster. Desktops are
+	// removed when no window is open. When empty, desktops are always provisioned.
+	Schedule []MaintenanceWindow `json:"schedule,omitempty"`
+	// Volumes that are shared between every desktop in the lab.
+	SharedVolumes []LabSharedVolume `json:"sharedVolumes,omitempty"`
+}
+
+// LabRoster represents the members of a lab.
+type LabRoster struct {
+	// The names of users in the roster.
+	Users []string `json:"users,omitempty"`
+	// The names of VDITeams in the roster. Members of the team and of all its
+	// descendants are included.
+	Teams []string `json:"teams,omitempty"`
+}
+
+// LabSharedVolume represents a volume that is mounted into every desktop in a lab.
+type LabSharedVolume struct {
+	// The name of the volume. Must be unique within the lab.
+	Name string `json:"name"`
+	// Where to mount the volume in each desktop.
+	MountPath string `json:"mountPath"`
+	// Whether the volume is mounted read-only in desktops.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// The name of an existing PersistentVolumeClaim to use for this volume. When not set,
+	// a claim is created for the lab from the ClaimSpec.
+	ExistingClaim string `json:"existingClaim,omitempty"`
+	// The spec for the PersistentVolumeClaim to create for this volume. Since the claim
+	// is mounted by every desktop, it should usually request the `ReadWriteMany` access
+	// mode.
+	ClaimSpec *corev1.PersistentVolumeClaimSpec `json:"claimSpec,omitempty"`
+}
+
+// LabStatus defines the observed state of Lab
+type LabStatus struct {
+	// Whether a scheduled window is currently open for the lab.
+	Active bool `json:"active,omitempty"`
+	// The sessions currently provisioned for the roster.
+	Sessions []LabSession `json:"sessions,omitempty"`
+	// Whether the screens of the roster are currently locked by an instructor.
+	Locked bool `json:"locked,omitempty"`
+	// The message shown to users whose screens are locked.
+	LockMessage string `json:"lockMessage,omitempty"`
+	// The last message broadcast to the roster.
+	LastBroadcast string `json:"lastBroadcast,omitempty"`
+	// The time of the last broadcast.
+	LastBroadcastTime metav1.Time `json:"lastBroadcastTime,omitempty"`
+}
+
+// LabSession represents a desktop session provisioned for a member of a lab.
+type LabSession struct {
+	// The user the session belongs to.
+	User string `json:"user"`
+	// The name of the session.
+	Name string `json:"name"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Template",type="string",JSONPath=".spec.template"
+//+kubebuilder:printcolumn:name="Active",type="boolean",JSONPath=".status.active"
+//+kubebuilder:printcolumn:name="Locked",type="boolean",JSONPath=".status.locked"
+
+// Lab is the Schema for the labs API. A lab provisions desktops from a single template
+// for a roster of users on a schedule.
+type Lab struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LabSpec   `json:"spec,omitempty"`
+	Status LabStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// LabList contains a list of Lab
+type LabList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Lab `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Lab{}, &LabList{})
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetTemplateName returns the name of the template desktops are launched from.
+func (l *Lab) GetTemplateName() string { return l.Spec.Template }
+
+// GetTemplate retrieves the Template for this Lab.
+func (l *Lab) GetTemplate(c client.Client) (*Template, error) {
+	nn := types.NamespacedName{Name: l.GetTemplateName(), Namespace: metav1.NamespaceAll}
+	found := &Template{}
+	return found, c.Get(context.TODO(), nn, found)
+}
+
+// GetVDICluster retrieves the VDICluster for this Lab.
+func (l *Lab) GetVDICluster(c client.Client) (*appv1.VDICluster, error) {
+	nn := types.NamespacedName{Name: l.Spec.VDICluster, Namespace: metav1.NamespaceAll}
+	found := &appv1.VDICluster{}
+	return found, c.Get(context.TODO(), nn, found)
+}
+
+// GetInstructors returns the users allowed to manage this Lab.
+func (l *Lab) GetInstructors() []string { return l.Spec.Instructors }
+
+// IsInstructor returns true if the given user is an instructor for this Lab.
+func (l *Lab) IsInstructor(username string) bool {
+	for _, instructor := range l.Spec.Instructors {
+		if instructor == username {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRosterUsers returns the users explicitly named in the roster.
+func (l *Lab) GetRosterUsers() []string { return l.Spec.Roster.Users }
+
+// GetRosterTeams returns the teams named in the roster.
+func (l *Lab) GetRosterTeams() []string { return l.Spec.Roster.Teams }
+
+// IsScheduled returns true if desktops should be provisioned for the roster at the
+// given time.
+func (l *Lab) IsScheduled(now time.Time) bool {
+	if len(l.Spec.Schedule) == 0 {
+		return true
+	}
+	for _, window := range l.Spec.Schedule {
+		if window.IsOpen(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNextScheduleChange returns the next time a scheduled window opens or closes after
+// the given time. A zero time is returned if the lab is not scheduled.
+func (l *Lab) GetNextScheduleChange(now time.Time) time.Time {
+	var next time.Time
+	for i := range l.Spec.Schedule {
+		start, end := l.Spec.Schedule[i].GetWindow(now)
+		if start.IsZero() {
+			continue
+		}
+		change := start
+		if !now.Before(start) {
+			change = end
+		}
+		if next.IsZero() || change.Before(next) {
+			next = change
+		}
+	}
+	return next
+}
+
+// GetSharedVolumeClaimName returns the name of the PersistentVolumeClaim backing the
+// given shared volume.
+func (l *Lab) GetSharedVolumeClaimName(vol *LabSharedVolume) string {
+	if vol.ExistingClaim != "" {
+		return vol.ExistingClaim
+	}
+	return fmt.Sprintf("%s-%s", l.GetName(), vol.Name)
+}
+
+// GetSessionSharedVolumes returns the shared volumes to mount into desktops launched
+// for this Lab.
+func (l *Lab) GetSessionSharedVolumes() []SharedVolume {
+	vols := make([]SharedVolume, 0, len(l.Spec.SharedVolumes))
+	for i := range l.Spec.SharedVolumes {
+		vol := &l.Spec.SharedVolumes[i]
+		vols = append(vols, SharedVolume{
+			Name:      fmt.Sprintf("lab-%s", vol.Name),
+			ClaimName: l.GetSharedVolumeClaimName(vol),
+			MountPath: vol.MountPath,
+			ReadOnly:  vol.ReadOnly,
+		})
+	}
+	return vols
+}
+
+// GetSessionLabels returns the labels to apply to the desktop launched for the given
+// user.
+func (l *Lab) GetSessionLabels(username string) map[string]string {
+	return map[string]string{
+		v1.UserLabel:       username,
+		v1.VDIClusterLabel: l.Spec.VDICluster,
+		v1.LabLabel:        l.GetName(),
+	}
+}
+
+// GetSessionsSelector returns a selector that can be used to find the desktops
+// provisioned for this Lab.
+func (l *Lab) GetSessionsSelector() client.MatchingLabels {
+	return client.MatchingLabels{
+		v1.VDIClusterLabel: l.Spec.VDICluster,
+		v1.LabLabel:        l.GetName(),
+	}
+}
+
+// GetSessionForUser returns the name of the session provisioned for the given user,
+// or an empty string if there is none.
+func (l *Lab) GetSessionForUser(username string) string {
+	for _, sess := range l.Status.Sessions {
+		if sess.User == username {
+			return sess.Name
+		}
+	}
+	return ""
+}
+
+// OwnerReferences returns an owner reference slice with this Lab as the owner.
+func (l *Lab) OwnerReferences() []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         l.APIVersion,
+			Kind:               l.Kind,
+			Name:               l.GetName(),
+			UID:                l.GetUID(),
+			Controller:         &v1.True,
+			BlockOwnerDeletion: &v1.False,
+		},
+	}
+}
@@ -0,0 +1,81 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DesktopPoolSpec defines the desired state of DesktopPool
+type DesktopPoolSpec struct {
+	// The VDICluster this DesktopPool belongs to.
+	VDICluster string `json:"vdiCluster"`
+	// The Template to boot desktops from.
+	Template string `json:"template"`
+	// A service account to tie to the desktops in the pool. Only launches requesting the
+	// same service account are served from the pool.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// The number of unclaimed desktops to keep booted. Claimed desktops are replaced
+	// in the background.
+	Size int32 `json:"size"`
+}
+
+// DesktopPoolStatus defines the observed state of DesktopPool
+type DesktopPoolStatus struct {
+	// The number of unclaimed desktops that are running and ready to be claimed.
+	Ready int32 `json:"ready,omitempty"`
+	// The number of unclaimed desktops that are still booting.
+	Pending int32 `json:"pending,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Template",type="string",JSONPath=".spec.template"
+//+kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".spec.size"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.ready"
+
+// DesktopPool is the Schema for the desktoppools API. A desktop pool keeps a number of
+// desktops booted from a single template, so that launches of the template can claim
+// one instead of waiting for a new desktop to start. Desktops in a pool are booted
+// before their user is known, so they run as the default desktop user and do not mount
+// user data. Pools are not served on VDIClusters that persist user data, since a claimed
+// desktop would come up without the user's volume.
+type DesktopPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DesktopPoolSpec   `json:"spec,omitempty"`
+	Status DesktopPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DesktopPoolList contains a list of DesktopPool
+type DesktopPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DesktopPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DesktopPool{}, &DesktopPoolList{})
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"context"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetTemplateName returns the name of the template desktops are booted from.
+func (p *DesktopPool) GetTemplateName() string { return p.Spec.Template }
+
+// GetTemplate retrieves the Template for this DesktopPool.
+func (p *DesktopPool) GetTemplate(c client.Client) (*Template, error) {
+	nn := types.NamespacedName{Name: p.GetTemplateName(), Namespace: metav1.NamespaceAll}
+	found := &Template{}
+	return found, c.Get(context.TODO(), nn, found)
+}
+
+// GetVDICluster retrieves the VDICluster for this DesktopPool.
+func (p *DesktopPool) GetVDICluster(c client.Client) (*appv1.VDICluster, error) {
+	nn := types.NamespacedName{Name: p.Spec.VDICluster, Namespace: metav1.NamespaceAll}
+	found := &appv1.VDICluster{}
+	return found, c.Get(context.TODO(), nn, found)
+}
+
+// GetSize returns the number of unclaimed desktops to keep booted.
+func (p *DesktopPool) GetSize() int {
+	if p.Spec.Size < 0 {
+		return 0
+	}
+	return int(p.Spec.Size)
+}
+
+// GetSessionsSelector returns a selector that can be used to find the unclaimed
+// desktops in this DesktopPool.
+func (p *DesktopPool) GetSessionsSelector() client.MatchingLabels {
+	return client.MatchingLabels{
+		v1.VDIClusterLabel:  p.Spec.VDICluster,
+		v1.DesktopPoolLabel: p.GetName(),
+	}
+}
+
+// OwnerReferences returns an owner reference slice with this DesktopPool as the owner.
+func (p *DesktopPool) OwnerReferences() []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         p.APIVersion,
+			Kind:               p.Kind,
+			Name:               p.GetName(),
+			UID:                p.GetUID(),
+			Controller:         &v1.True,
+			BlockOwnerDeletion: &v1.False,
+		},
+	}
+}
@@ -37,6 +37,41 @@ type SessionSpec struct {
 	User string `json:"user,omitempty"`
 	// A service account to tie to the pod for this instance.
 	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// The canary revision of the template this session was launched on, if any.
+	CanaryRevision string `json:"canaryRevision,omitempty"`
+	// The Lab this session was provisioned for, if any.
+	Lab string `json:"lab,omitempty"`
+	// Volumes shared with other sessions to mount into this instance.
+	SharedVolumes []SharedVolume `json:"sharedVolumes,omitempty"`
+	// The architecture to run this instance on. When unset, the instance may run on any of
+	// the architectures supported by its template.
+	Architecture Architecture `json:"architecture,omitempty"`
+	// The ticket or change ID this instance was launched against, if its template requires
+	// one.
+	Ticket string `json:"ticket,omitempty"`
+	// The maximum duration, as a Go duration string, this instance may run before it is
+	// terminated. This is set from the role rule the instance was launched under, and the
+	// shorter of it and the VDICluster's `maxSessionLength` applies.
+	MaxDuration string `json:"maxDuration,omitempty"`
+	// The DesktopPool this instance was booted for, if any. The user is empty until the
+	// instance is claimed by a launch.
+	Pool string `json:"pool,omitempty"`
+	// The DesktopSnapshot, in the namespace of this instance, to restore the user's $HOME
+	// from. The restored volume replaces the user's current userdata volume, which is
+	// retained.
+	FromSnapshot string `json:"fromSnapshot,omitempty"`
+}
+
+// SharedVolume represents a PersistentVolumeClaim that is mounted into several sessions.
+type SharedVolume struct {
+	// The name of the volume in the desktop pod.
+	Name string `json:"name"`
+	// The name of the PersistentVolumeClaim backing the volume.
+	ClaimName string `json:"claimName"`
+	// Where to mount the volume in the desktop.
+	MountPath string `json:"mountPath"`
+	// Whether to mount the volume read-only.
+	ReadOnly bool `json:"readOnly,omitempty"`
 }
 
 // SessionStatus defines the observed state of Session
@@ -45,6 +80,124 @@ type SessionStatus struct {
 	Running bool `json:"running,omitempty"`
 	// The current phase of the pod backing this instance.
 	PodPhase corev1.PodPhase `json:"podPhase,omitempty"`
+	// Set when the template's maintenance window is open but the session is still in use.
+	// The session will be recreated once it becomes idle.
+	MaintenancePending bool `json:"maintenancePending,omitempty"`
+	// Set once the connected users of a session pending maintenance have been warned.
+	MaintenanceNotified bool `json:"maintenanceNotified,omitempty"`
+	// The last time the session was recreated during a maintenance window.
+	LastMaintenanceTime metav1.Time `json:"lastMaintenanceTime,omitempty"`
+	// Set once the outcome of a canary launch has been recorded on the template.
+	RolloutRecorded bool `json:"rolloutRecorded,omitempty"`
+	// Set when the images for the session do not satisfy the cluster's image policy. The
+	// session will not be started until they do.
+	ImagePolicyError string `json:"imagePolicyError,omitempty"`
+	// Set when the spot node running the session has received a preemption notice. The
+	// session will be relaunched on on-demand capacity once the warning period ends.
+	PreemptionPending bool `json:"preemptionPending,omitempty"`
+	// The time the preemption notice for the session's node was first observed.
+	PreemptionNoticeTime metav1.Time `json:"preemptionNoticeTime,omitempty"`
+	// Set once the session has been relaunched on on-demand capacity after a preemption.
+	OnDemand bool `json:"onDemand,omitempty"`
+	// Set while the session's pod is stopped because the VDICluster is outside of business
+	// hours and nobody is connected. The pod is started again when the cluster wakes up.
+	Paused bool `json:"paused,omitempty"`
+	// Set while the static host the session connects to has been powered on and is not
+	// yet accepting connections.
+	Booting bool `json:"booting,omitempty"`
+	// The time the static host the session connects to was last powered on.
+	PowerOnTime metav1.Time `json:"powerOnTime,omitempty"`
+	// The time the ticket this instance was launched against expires, if it does.
+	TicketExpiresAt metav1.Time `json:"ticketExpiresAt,omitempty"`
+	// The time this instance will be terminated for exceeding its maximum duration, if it
+	// has one.
+	ExpiresAt metav1.Time `json:"expiresAt,omitempty"`
+	// The time this instance was claimed from its DesktopPool, if it was booted for one. Its
+	// maximum duration is counted from this time.
+	ClaimTime metav1.Time `json:"claimTime,omitempty"`
+	// The last time input was received from a client of the desktop, as reported by its
+	// kvdi-proxy.
+	LastInputTime metav1.Time `json:"lastInputTime,omitempty"`
+	// The time the user was warned that the session is idle and will be terminated.
+	IdleWarningTime metav1.Time `json:"idleWarningTime,omitempty"`
+	// The time the session will be terminated for being idle, unless input is received
+	// before then. Only set once the user has been warned.
+	IdleTerminationTime metav1.Time `json:"idleTerminationTime,omitempty"`
+	// The last message broadcast to the user of the session, such as a notice of pending
+	// maintenance. The kvdi-agent shows it inside the desktop.
+	LastBroadcast string `json:"lastBroadcast,omitempty"`
+	// The time of the last broadcast.
+	LastBroadcastTime metav1.Time `json:"lastBroadcastTime,omitempty"`
+	// The last state reported by the kvdi-agent inside the desktop, if it is enabled.
+	Agent *AgentStatus `json:"agent,omitempty"`
+	// The license seats granted to the session. They are returned to their pools when the
+	// session is deleted.
+	Licenses []LicenseGrant `json:"licenses,omitempty"`
+	// Set while the session is waiting for seats from the license pools its template
+	// requires, describing what it is waiting for.
+	LicensePending string `json:"licensePending,omitempty"`
+	// Set while the session's pod cannot be scheduled, describing why (e.g. no node has the
+	// GPUs its template requests).
+	SchedulingError string `json:"schedulingError,omitempty"`
+	// The resolutions of the monitors attached to the desktop, in order, when its template
+	// allows multiple monitors. The first is the primary monitor. The layout is reset when
+	// the desktop's pod is restarted.
+	Monitors []DisplayResolution `json:"monitors,omitempty"`
+	// The storage used by the user's $HOME directory, as last reported by the kvdi-proxy.
+	// Only set when the VDICluster enforces a userdata quota.
+	HomeUsage *HomeUsageStatus `json:"homeUsage,omitempty"`
+}
+
+// HomeUsageStatus represents the storage used by a user's $HOME directory against the
+// userdata quota.
+type HomeUsageStatus struct {
+	// The number of bytes used.
+	Used int64 `json:"used"`
+	// The quota in bytes.
+	Limit int64 `json:"limit"`
+	// Set when usage is above the quota's warning threshold.
+	Warning bool `json:"warning,omitempty"`
+	// Set when usage has reached the quota. Uploads are refused until space is freed.
+	Exceeded bool `json:"exceeded,omitempty"`
+}
+
+// LicenseGrant represents seats of a license pool held by a session.
+type LicenseGrant struct {
+	// The name of the license pool.
+	Pool string `json:"pool"`
+	// The number of seats held.
+	Seats int `json:"seats"`
+}
+
+// AgentStatus represents the state reported by the kvdi-agent inside a desktop.
+type AgentStatus struct {
+	// The last time the agent reported health.
+	LastReportTime metav1.Time `json:"lastReportTime,omitempty"`
+	// Whether every app reported by the agent is healthy.
+	Healthy bool `json:"healthy,omitempty"`
+	// The health of the individual apps reported by the agent.
+	Apps []AgentAppStatus `json:"apps,omitempty"`
+	// A display resolution requested from inside the desktop. Clients connected to the
+	// session should resize their display to match.
+	RequestedResolution *DisplayResolution `json:"requestedResolution,omitempty"`
+}
+
+// AgentAppStatus represents the health of an app inside a desktop.
+type AgentAppStatus struct {
+	// The name of the app.
+	Name string `json:"name"`
+	// Whether the app is healthy.
+	Healthy bool `json:"healthy,omitempty"`
+	// An optional message describing the state of the app.
+	Message string `json:"message,omitempty"`
+}
+
+// DisplayResolution represents the size of a desktop's display.
+type DisplayResolution struct {
+	// The width of the display in pixels.
+	Width int32 `json:"width"`
+	// The height of the display in pixels.
+	Height int32 `json:"height"`
 }
 
 //+kubebuilder:object:root=true
@@ -21,6 +21,8 @@ package v1
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
@@ -43,14 +45,112 @@ func (d *Session) GetTemplateName() string { return d.Spec.Template }
 // GetServiceAccount returns the service account for this instance.
 func (d *Session) GetServiceAccount() string { return d.Spec.ServiceAccount }
 
+// defaultSessionUser is the username used inside instances that were not launched for a user.
+const defaultSessionUser = "anonymous"
+
 // GetUser returns the username that should be used inside the instance.
 func (d *Session) GetUser() string {
 	if d.Spec.User == "" {
-		return "anonymous"
+		return defaultSessionUser
 	}
 	return d.Spec.User
 }
 
+// GetDesktopUser returns the username the desktop is running as. Instances booted for a
+// DesktopPool keep running as the default user after they are claimed.
+func (d *Session) GetDesktopUser() string {
+	if d.Spec.Pool != "" {
+		return defaultSessionUser
+	}
+	return d.GetUser()
+}
+
+// GetLab returns the name of the Lab this instance was provisioned for, if any.
+func (d *Session) GetLab() string { return d.Spec.Lab }
+
+// GetPool returns the name of the DesktopPool this instance was booted for, if any.
+func (d *Session) GetPool() string { return d.Spec.Pool }
+
+// GetFromSnapshot returns the name of the DesktopSnapshot this instance restores the user's
+// $HOME from, if any.
+func (d *Session) GetFromSnapshot() string { return d.Spec.FromSnapshot }
+
+// IsPooled returns true if this instance was booted for a DesktopPool and has not yet
+// been claimed by a user.
+func (d *Session) IsPooled() bool { return d.Spec.Pool != "" && d.Spec.User == "" }
+
+// GetStartTime returns the time this instance was started for its user. This is the time
+// it was claimed for instances booted for a DesktopPool, otherwise its creation.
+func (d *Session) GetStartTime() time.Time {
+	if d.Spec.Pool != "" && !d.Status.ClaimTime.IsZero() {
+		return d.Status.ClaimTime.Time
+	}
+	return d.GetCreationTimestamp().Time
+}
+
+// GetArchitecture returns the architecture this instance is pinned to, if any.
+func (d *Session) GetArchitecture() Architecture { return d.Spec.Architecture }
+
+// GetSharedVolumes returns the shared volumes to mount into this instance.
+func (d *Session) GetSharedVolumes() []SharedVolume { return d.Spec.SharedVolumes }
+
+// GetHomeShareSecretName returns the name of the secret holding the credentials used to
+// mount the user's home share in this instance. The secret lives in the kVDI namespace,
+// so the name includes the namespace of the instance.
+func (d *Session) GetHomeShareSecretName() string {
+	return fmt.Sprintf("%s-%s-home-share", d.GetNamespace(), d.GetName())
+}
+
+// GetDomainJoinSecretName returns the name of the secret holding the Kerberos configuration
+// and keytab for this instance.
+func (d *Session) GetDomainJoinSecretName() string { return d.GetName() + "-domain-join" }
+
+// GetStaticHostSecretName returns the name of the secret holding the credentials for the
+// static host this instance connects to.
+func (d *Session) GetStaticHostSecretName() string { return d.GetName() + "-static-host" }
+
+// GetRDPSecretName returns the name of the secret holding the credentials for the RDP
+// server of this instance.
+func (d *Session) GetRDPSecretName() string { return d.GetName() + "-rdp" }
+
+// GetPortsServiceName returns the name of the service exposing the ports declared on the
+// template of this instance.
+func (d *Session) GetPortsServiceName() string { return d.GetName() + "-ports" }
+
+// GetAgentSecretName returns the name of the secret holding the token the kvdi-agent in
+// this instance authenticates to the API with.
+func (d *Session) GetAgentSecretName() string { return d.GetName() + "-agent" }
+
+// GetMaxDuration returns the maximum duration this instance was launched with, or zero if
+// it may run indefinitely.
+func (d *Session) GetMaxDuration() time.Duration {
+	if d.Spec.MaxDuration == "" {
+		return 0
+	}
+	dur, err := time.ParseDuration(d.Spec.MaxDuration)
+	if err != nil || dur < 0 {
+		return 0
+	}
+	return dur
+}
+
+// GetExpiry returns the time this instance should be terminated, counted from its start time,
+// given the maximum session length of its VDICluster. The shorter of the two maximums applies.
+// The zero time is returned when the instance may run indefinitely.
+func (d *Session) GetExpiry(clusterMax time.Duration) time.Time {
+	dur := d.GetMaxDuration()
+	if clusterMax > 0 && (dur == 0 || clusterMax < dur) {
+		dur = clusterMax
+	}
+	if dur == 0 {
+		return time.Time{}
+	}
+	return d.GetStartTime().Add(dur)
+}
+
+// HasLicenses returns true if the session has been granted seats from license pools.
+func (d *Session) HasLicenses() bool { return len(d.Status.Licenses) > 0 }
+
 // OwnerReferences returns an owner reference slice with this Desktop
 // instance as the owner.
 func (d *Session) OwnerReferences() []metav1.OwnerReference {
@@ -72,3 +172,8 @@ func (d *Session) GetVDICluster(c client.Client) (*appv1.VDICluster, error) {
 	found := &appv1.VDICluster{}
 	return found, c.Get(context.TODO(), nn, found)
 }
+
+// String returns the resolution in `WIDTHxHEIGHT` format.
+func (d DisplayResolution) String() string {
+	return fmt.Sprintf("%dx%d", d.Width, d.Height)
+}
@@ -0,0 +1,82 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DesktopSnapshotSpec defines the desired state of DesktopSnapshot
+type DesktopSnapshotSpec struct {
+	// The VDICluster this DesktopSnapshot belongs to.
+	VDICluster string `json:"vdiCluster"`
+	// The user whose desktop session was snapshotted. Only they may launch desktops from
+	// the snapshot.
+	User string `json:"user"`
+	// The Template the session was launched from. Desktops restored from the snapshot are
+	// launched from the same template.
+	Template string `json:"template"`
+	// The name of the session that was snapshotted.
+	Session string `json:"session"`
+	// The persistent volumes of the session that were snapshotted.
+	Volumes []SnapshotVolume `json:"volumes"`
+}
+
+// SnapshotVolume represents a persistent volume of a desktop session captured in a
+// DesktopSnapshot.
+type SnapshotVolume struct {
+	// The name of the volume in the desktop pod.
+	Name string `json:"name"`
+	// The name of the PersistentVolumeClaim that was snapshotted.
+	ClaimName string `json:"claimName"`
+	// The name of the VolumeSnapshot holding the contents of the claim.
+	VolumeSnapshotName string `json:"volumeSnapshotName"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+//+kubebuilder:printcolumn:name="Template",type="string",JSONPath=".spec.template"
+//+kubebuilder:printcolumn:name="Session",type="string",JSONPath=".spec.session"
+
+// DesktopSnapshot is the Schema for the desktopsnapshots API. A desktop snapshot records
+// the CSI VolumeSnapshots taken of the persistent volumes of a desktop session, so that the
+// user can later launch a desktop that resumes from the state they were in. The
+// VolumeSnapshots are owned by the DesktopSnapshot and are removed along with it.
+type DesktopSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DesktopSnapshotSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DesktopSnapshotList contains a list of DesktopSnapshot
+type DesktopSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DesktopSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DesktopSnapshot{}, &DesktopSnapshotList{})
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeSnapshotAPIGroup is the API group of CSI VolumeSnapshots.
+const VolumeSnapshotAPIGroup = "snapshot.storage.k8s.io"
+
+// GetUser returns the user whose desktop session was snapshotted.
+func (s *DesktopSnapshot) GetUser() string { return s.Spec.User }
+
+// GetTemplateName returns the name of the template the snapshotted session was launched from.
+func (s *DesktopSnapshot) GetTemplateName() string { return s.Spec.Template }
+
+// GetVolume returns the snapshot of the volume with the given name in the desktop pod, or
+// nil if it was not snapshotted.
+func (s *DesktopSnapshot) GetVolume(name string) *SnapshotVolume {
+	for i := range s.Spec.Volumes {
+		if s.Spec.Volumes[i].Name == name {
+			return &s.Spec.Volumes[i]
+		}
+	}
+	return nil
+}
+
+// GetDataSource returns the data source for restoring the volume with the given name in the
+// desktop pod to a new claim, or nil if it was not snapshotted.
+func (s *DesktopSnapshot) GetDataSource(name string) *corev1.TypedLocalObjectReference {
+	vol := s.GetVolume(name)
+	if vol == nil {
+		return nil
+	}
+	apiGroup := VolumeSnapshotAPIGroup
+	return &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     vol.VolumeSnapshotName,
+	}
+}
+
+// OwnerReferences returns an owner reference slice with this DesktopSnapshot as the owner.
+func (s *DesktopSnapshot) OwnerReferences() []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         s.APIVersion,
+			Kind:               s.Kind,
+			Name:               s.GetName(),
+			UID:                s.GetUID(),
+			Controller:         &v1.True,
+			BlockOwnerDeletion: &v1.False,
+		},
+	}
+}
@@ -22,11 +22,24 @@
 package v1
 
 import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// Architecture represents a CPU architecture that nodes can run.
+// +kubebuilder:validation:Enum=amd64;arm64
+type Architecture string
+
+const (
+	// ArchitectureAMD64 represents x86-64 nodes.
+	ArchitectureAMD64 Architecture = "amd64"
+	// ArchitectureARM64 represents 64-bit ARM nodes.
+	ArchitectureARM64 Architecture = "arm64"
+)
+
 // DesktopInit represents the init system that the desktop container uses.
 // +kubebuilder:validation:Enum=supervisord;systemd
 type DesktopInit string
@@ -50,16 +63,125 @@ type TemplateSpec struct {
 	DesktopConfig *DesktopConfig `json:"desktop,omitempty"`
 	// Configurations for the display proxy.
 	ProxyConfig *ProxyConfig `json:"proxy,omitempty"`
+	// Configurations for the display served by desktops booted from this template.
+	DisplayConfig *DisplayConfig `json:"display,omitempty"`
+	// Configurations for streaming audio from desktops booted from this template.
+	AudioConfig *AudioConfig `json:"audio,omitempty"`
+	// Configurations for the kvdi-agent running inside desktops booted from this template.
+	// The agent reports app-level health to the API, relays resolution changes and
+	// broadcasts, and serves session metadata to tools inside the desktop.
+	AgentConfig *AgentConfig `json:"agent,omitempty"`
+	// Configurations for issuing short-lived kubeconfigs to desktops booted from this
+	// template.
+	KubeCredentialsConfig *KubeCredentialsConfig `json:"kubeCredentials,omitempty"`
 	// Docker-in-docker configurations for running a dind sidecar along with desktop instances.
 	DindConfig *DockerInDockerConfig `json:"dind,omitempty"`
 	// QEMU configurations for this template. When defined, VMs are used instead of containers
 	// for desktop sessions. This object is mututally exclusive with `desktop` and will take
 	// precedence when defined.
 	QEMUConfig *QEMUConfig `json:"qemu,omitempty"`
+	// Configurations for brokering access to an existing machine outside of Kubernetes, such
+	// as a physical workstation or an external VM. When defined, sessions connect to the machine
+	// instead of running a desktop. This object takes precedence over `desktop` and `qemu`.
+	StaticHost *StaticHostConfig `json:"staticHost,omitempty"`
+	// Configurations for exposing a browser or remote IDE endpoint from desktops booted from
+	// this template. The endpoint is reachable by the session owner through the API at
+	// `/api/sessions/{namespace}/{name}/port/{port}/`.
+	IDEConfig *IDEConfig `json:"ide,omitempty"`
+	// A recurring maintenance window during which idle sessions booted from this template
+	// are recreated, applying any pending volume resizes. New image revisions are only
+	// pulled when the desktop `imagePullPolicy` is `Always`. Sessions with connected users
+	// are flagged as pending maintenance, warned, and recreated once they become idle within
+	// the window.
+	Maintenance *MaintenanceWindow `json:"maintenance,omitempty"`
+	// A strategy for gradually rolling out a new revision of the desktop configuration to
+	// a percentage of new sessions.
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+	// Configurations for lazily pulling large desktop images so sessions can start before
+	// the full image is downloaded.
+	ImageStreaming *ImageStreamingConfig `json:"imageStreaming,omitempty"`
+	// Limits on how many sessions of this template may run at once, optionally with capacity
+	// reserved for specific users or roles.
+	Capacity *TemplateCapacity `json:"capacity,omitempty"`
+	// Seats each session requires from the license pools configured on the VDICluster.
+	// Sessions are not started until they are granted their seats.
+	Licenses []LicenseRequirement `json:"licenses,omitempty"`
+	// How risky the workloads run from this template are (e.g. browsing untrusted sites). The
+	// VDICluster sandbox policy uses this to choose the runtime class for desktop pods. Defaults
+	// to the default risk level of the cluster.
+	RiskLevel RiskLevel `json:"riskLevel,omitempty"`
+	// Configurations for mounting each user's home directory from an existing NFS or SMB
+	// file server.
+	HomeShare *HomeShareConfig `json:"homeShare,omitempty"`
+	// The CPU architectures desktops booted from this template can run on. Desktop pods are
+	// only scheduled to nodes labeled with one of these architectures. Defaults to any
+	// architecture.
+	Architectures []Architecture `json:"architectures,omitempty"`
+	// GPUs to attach to desktops booted from this template. Desktop pods request the GPUs
+	// from the vendor's device plugin and are only scheduled to nodes that advertise them.
+	GPUs *GPUConfig `json:"gpus,omitempty"`
+	// Configurations for running desktops booted from this template on spot or preemptible
+	// nodes. Sessions whose node receives a preemption notice are relaunched on on-demand
+	// capacity.
+	Spot *SpotConfig `json:"spot,omitempty"`
+	// A policy for terminating sessions booted from this template that go without input.
+	// Overrides the policy configured on the VDICluster.
+	IdlePolicy *appv1.IdlePolicy `json:"idlePolicy,omitempty"`
+	// Marks the template as deprecated. Users launching deprecated templates are warned, and
+	// once the sunset date passes new launches are blocked and remaining sessions are drained.
+	Deprecation *DeprecationConfig `json:"deprecation,omitempty"`
+	// A firewall restricting which ports inside desktops booted from this template accept
+	// connections, and whether other sessions may reach them. When set, a NetworkPolicy is
+	// generated for each session that drops connections to any other port.
+	Firewall *FirewallPolicy `json:"firewall,omitempty"`
+	// The review of a template saved from a desktop session. Until the review is approved the
+	// template is a draft, and only its author and users allowed to review templates may see
+	// and launch it.
+	Review *TemplateReview `json:"review,omitempty"`
+	// Require a ticket or change ID from the VDICluster's ticketing system to launch sessions
+	// from this template. Sessions are terminated once their ticket is closed or expires.
+	RequireTicket bool `json:"requireTicket,omitempty"`
+	// Prompts the user must answer before launching sessions from this template, such as
+	// accepting a EULA or selecting the classification level of the data they will work
+	// with. Responses are recorded in the audit log and are available to `envTemplates`
+	// as `{{ .Prompts.<name> }}`.
+	Prompts []LaunchPrompt `json:"prompts,omitempty"`
 	// Arbitrary tags for displaying in the app UI.
 	Tags map[string]string `json:"tags,omitempty"`
 }
 
+// LaunchPromptType represents the type of input a launch prompt asks for.
+// +kubebuilder:validation:Enum=text;acknowledge;choice
+type LaunchPromptType string
+
+const (
+	// LaunchPromptText asks for free-form text.
+	LaunchPromptText LaunchPromptType = "text"
+	// LaunchPromptAcknowledge asks the user to accept a statement, such as a EULA. The
+	// response must be `true`.
+	LaunchPromptAcknowledge LaunchPromptType = "acknowledge"
+	// LaunchPromptChoice asks the user to select one of a list of options.
+	LaunchPromptChoice LaunchPromptType = "choice"
+)
+
+// LaunchPrompt represents a question asked before a session is launched.
+type LaunchPrompt struct {
+	// The name responses to the prompt are submitted and recorded under.
+	Name string `json:"name"`
+	// The type of input the prompt asks for. Defaults to `text`.
+	Type LaunchPromptType `json:"type,omitempty"`
+	// The question or statement displayed to the user.
+	Label string `json:"label,omitempty"`
+	// Longer text displayed with the prompt, such as the terms of a EULA.
+	Description string `json:"description,omitempty"`
+	// The options to choose from for `choice` prompts.
+	Options []string `json:"options,omitempty"`
+	// A regular expression `text` responses must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Allow launching without a response. Acknowledgments are never optional.
+	Optional bool `json:"optional,omitempty"`
+}
+
 // DesktopConfig represents configurations for the template and desktops booted
 // from it.
 type DesktopConfig struct {
@@ -99,6 +221,184 @@ type DesktopConfig struct {
 	// downgrading to the desktop user must be done within the image's init process. `supervisord`
 	// containers are run with minimal capabilities and directly as the desktop user.
 	Init DesktopInit `json:"init,omitempty"`
+	// A policy for purging files from the user's $HOME when a session ends. The cleanup
+	// runs in the desktop's pre-stop hook, before the userdata volume is released.
+	Cleanup *CleanupPolicy `json:"cleanup,omitempty"`
+	// Sysctls to set on desktop pods booted from this template. Only sysctls in the
+	// Kubernetes "safe" set are allowed.
+	Sysctls []corev1.Sysctl `json:"sysctls,omitempty"`
+	// Resource limits to apply to processes inside the desktop container. These are applied
+	// by the image's init system, so custom images must honor the `ULIMIT_*` environment
+	// variables for them to take effect. The bundled systemd images set them as the
+	// `DefaultLimit*` of the system and user managers.
+	Ulimits *Ulimits `json:"ulimits,omitempty"`
+	// The size of the memory-backed volume to mount at /dev/shm, expressed as a Kubernetes
+	// quantity (e.g. `1Gi`). When unset, the host's /dev/shm is mounted.
+	ShmSize string `json:"shmSize,omitempty"`
+	// Ports inside the desktop container to expose to the session owner. Exposed ports are
+	// added to a `<session>-ports` service and can be reached through the API at
+	// `/api/sessions/{namespace}/{name}/port/{port}/`.
+	Ports []corev1.ContainerPort `json:"ports,omitempty"`
+	// Set to true to join desktops to the Kerberos realm configured on the VDICluster. The
+	// image must provide `sssd` and start it when the `DOMAIN_REALM` environment variable is
+	// set, as the kVDI ubuntu images do.
+	DomainJoin bool `json:"domainJoin,omitempty"`
+	// Images to use in place of `image` on specific architectures, for desktop images that are
+	// not published as multi-arch manifests. When variants are defined, sessions are pinned at
+	// launch to one of the template's `architectures`, or to one of the variants if `image` is
+	// unset.
+	ImageVariants []ImageVariant `json:"imageVariants,omitempty"`
+}
+
+// ImageVariant represents a desktop image built for a single architecture.
+type ImageVariant struct {
+	// The architecture the image is built for.
+	Architecture Architecture `json:"architecture"`
+	// The docker repository and tag of the image.
+	Image string `json:"image"`
+}
+
+// Ulimits represents process resource limits for the desktop container.
+type Ulimits struct {
+	// The maximum number of open file descriptors.
+	NoFile int64 `json:"nofile,omitempty"`
+	// The maximum number of processes available to the user.
+	NProc int64 `json:"nproc,omitempty"`
+}
+
+// CleanupPolicy describes files to purge from a user's $HOME at the end of a session.
+// Paths are relative to $HOME and may not escape it.
+type CleanupPolicy struct {
+	// Files or directories to remove entirely (e.g. `.local/share/Trash`).
+	Paths []string `json:"paths,omitempty"`
+	// Directories whose contents should be emptied, but which should themselves be
+	// preserved (e.g. `.cache`).
+	CacheDirs []string `json:"cacheDirs,omitempty"`
+}
+
+// DisplayProtocol represents the protocol spoken by the display server of a desktop.
+// +kubebuilder:validation:Enum=novnc;spice;xpra;rdp
+type DisplayProtocol string
+
+const (
+	// DisplayProtocolNoVNC is a VNC display server, served to noVNC clients.
+	DisplayProtocolNoVNC DisplayProtocol = "novnc"
+	// DisplayProtocolSPICE is a SPICE display server.
+	DisplayProtocolSPICE DisplayProtocol = "spice"
+	// DisplayProtocolXpra is an Xpra display server.
+	DisplayProtocolXpra DisplayProtocol = "xpra"
+	// DisplayProtocolRDP is an RDP server, such as a Windows desktop. The display is
+	// translated to the Guacamole protocol by a guacd sidecar.
+	DisplayProtocolRDP DisplayProtocol = "rdp"
+)
+
+// RDPSecurityMode represents the security mode used when connecting to an RDP server.
+// +kubebuilder:validation:Enum=any;nla;tls;rdp
+type RDPSecurityMode string
+
+const (
+	// RDPSecurityAny negotiates the security mode with the server.
+	RDPSecurityAny RDPSecurityMode = "any"
+	// RDPSecurityNLA uses Network Level Authentication.
+	RDPSecurityNLA RDPSecurityMode = "nla"
+	// RDPSecurityTLS uses TLS encryption.
+	RDPSecurityTLS RDPSecurityMode = "tls"
+	// RDPSecurityRDP uses standard RDP encryption.
+	RDPSecurityRDP RDPSecurityMode = "rdp"
+)
+
+// DisplayConfig represents configurations for the display of a desktop.
+type DisplayConfig struct {
+	// The protocol spoken by the display server at `proxy.socketAddr`. Clients connecting
+	// to the display may request a protocol, and are refused if it does not match. The value
+	// is set to the `DISPLAY_PROTOCOL` environment variable for init scripts to start the
+	// matching server. Screenshots and thumbnails are only available for `novnc` displays.
+	// Defaults to `spice` for `qemu` templates using SPICE, and `novnc` otherwise.
+	Protocol DisplayProtocol `json:"protocol,omitempty"`
+	// Configurations for `rdp` displays.
+	RDP *RDPConfig `json:"rdp,omitempty"`
+	// Set to true to record the display of desktops booted from this template while
+	// clients are connected to it. Recording must be configured on the VDICluster, and
+	// is only available for `novnc` displays.
+	Record bool `json:"record,omitempty"`
+	// Configurations for desktops spanning multiple monitors. Only available for `novnc`
+	// displays.
+	Monitors *MonitorsConfig `json:"monitors,omitempty"`
+	// Configurations for resizing the display at runtime. Only available for `novnc`
+	// displays.
+	Resize *DisplayResizeConfig `json:"resize,omitempty"`
+}
+
+// DisplayResizeConfig represents configurations for changing the resolution and DPI of a
+// desktop's display while it is running, so clients can match it to the size of their
+// window instead of scaling it. The proxy resizes the display with `xrandr`, which must
+// be supported by the display server (e.g. Xvnc). Displays spanning multiple monitors
+// cannot be resized.
+type DisplayResizeConfig struct {
+	// Set to true to allow clients to resize the display. The resolution is bounded by
+	// `monitors.maxResolution`.
+	Enabled bool `json:"enabled,omitempty"`
+	// The X display to resize. Defaults to `:10`.
+	XDisplay string `json:"xDisplay,omitempty"`
+}
+
+// MonitorsConfig represents configurations for desktops spanning multiple monitors. Every
+// session starts with a single monitor, and clients add and remove monitors at runtime
+// through the session API. The proxy writes the layout to `/var/run/kvdi/monitors` for
+// the init process to configure the display, and serves each additional monitor from a
+// VNC server on the display socket suffixed with its index (e.g. `display-1.sock`).
+type MonitorsConfig struct {
+	// The maximum number of monitors a session may use, including the primary one.
+	// Defaults to 1, which disables multiple monitors.
+	Max int32 `json:"max,omitempty"`
+	// The maximum resolution of each monitor. Defaults to 3840x2160.
+	MaxResolution *DisplayResolution `json:"maxResolution,omitempty"`
+}
+
+// RDPConfig represents configurations for desktops serving their display over RDP, such as
+// Windows desktops and virtual machines. A guacd sidecar connects to the RDP server inside
+// the desktop and the proxy serves the session to Guacamole clients over the display
+// websocket.
+type RDPConfig struct {
+	// The port of the RDP server inside the desktop. For `qemu` templates the virtual
+	// machine must forward this port. Defaults to 3389.
+	Port int32 `json:"port,omitempty"`
+	// The security mode to connect with. Defaults to `any`.
+	Security RDPSecurityMode `json:"security,omitempty"`
+	// Accept the certificate presented by the RDP server without verifying it. Desktops
+	// usually present self-signed certificates.
+	IgnoreCert bool `json:"ignoreCert,omitempty"`
+	// The key in the secrets backend holding the credentials to log in with. The value is a
+	// map with a `password` and optionally a `username` and `domain`. Users are never shown
+	// the credentials. When unset, users log in at the desktop's login screen.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// The image to use for the guacd sidecar. Defaults to `guacamole/guacd:1.3.0`. The
+	// sidecar must listen on port 4822.
+	GuacdImage string `json:"guacdImage,omitempty"`
+	// The pull policy to use when pulling the guacd image.
+	GuacdImagePullPolicy corev1.PullPolicy `json:"guacdImagePullPolicy,omitempty"`
+	// Resource requirements to place on the guacd sidecar.
+	GuacdResources corev1.ResourceRequirements `json:"guacdResources,omitempty"`
+}
+
+// AudioConfig represents configurations for the audio of a desktop. The kvdi-proxy
+// captures the output of the desktop's PulseAudio server, encodes it with Opus, and
+// streams it to clients over the audio websocket.
+type AudioConfig struct {
+	// Set to false to disable audio for desktops booted from this template. Defaults to
+	// true.
+	Enabled *bool `json:"enabled,omitempty"`
+	// The bitrate in bits per second to encode audio at. Must be between 6000 and 510000.
+	// Defaults to 64000.
+	Bitrate int32 `json:"bitrate,omitempty"`
+	// The sample rate to capture audio at. Must be one supported by Opus: 8000, 12000,
+	// 16000, 24000, or 48000. Defaults to 24000.
+	SampleRate int32 `json:"sampleRate,omitempty"`
+	// Set to false to keep clients from passing their microphone through to desktops
+	// booted from this template. Defaults to true when audio is enabled. Users must also
+	// hold the `use-microphone` verb on the template and have audio input allowed by the
+	// device policy of their roles.
+	Microphone *bool `json:"microphone,omitempty"`
 }
 
 // ProxyConfig represents configurations for the display/audio proxy.
@@ -114,6 +414,17 @@ type ProxyConfig struct {
 	// desktop sessions booted from this template. When using a `qemu` configuration with
 	// SPICE, file upload is enabled by default.
 	AllowFileTransfer bool `json:"allowFileTransfer,omitempty"`
+	// Restrictions to place on file transfers when they are allowed.
+	FileTransfer *FileTransferConfig `json:"fileTransfer,omitempty"`
+	// Set to false to prevent clipboard contents from being sent from clients to desktops
+	// booted from this template. Defaults to true. Users must also hold the `use-clipboard`
+	// verb on the template to sync their clipboard.
+	AllowClipboardTo *bool `json:"allowClipboardTo,omitempty"`
+	// Set to false to prevent clipboard contents from being sent from desktops booted from
+	// this template to clients. Defaults to true. For VNC displays, the restriction is also
+	// passed to the display server in the `VNC_CLIPBOARD_ARGS` environment variable, which
+	// custom images should include in the arguments to their VNC server.
+	AllowClipboardFrom *bool `json:"allowClipboardFrom,omitempty"`
 	// The address the display server listens on inside the image. This defaults to the
 	// UNIX socket `/var/run/kvdi/display.sock`. The kvdi-proxy sidecar will forward
 	// websockify requests validated by mTLS to this socket. Must be in the format of
@@ -128,6 +439,349 @@ type ProxyConfig struct {
 	PulseServer string `json:"pulseServer,omitempty"`
 	// Resource restraints to place on the proxy sidecar.
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Configurations for periodically capturing low-resolution previews of the display.
+	Thumbnails *ThumbnailConfig `json:"thumbnails,omitempty"`
+	// Set to true to have the kvdi-proxy dial out to the app over a reverse tunnel instead
+	// of waiting for the app to connect to it. Use this for desktops behind NAT or firewalls
+	// the app cannot reach. Reverse tunnels must be enabled on the VDICluster. Declared
+	// desktop ports are dialed directly and are not reachable over the tunnel.
+	ReverseTunnel bool `json:"reverseTunnel,omitempty"`
+	// Plugins that add channels to the kvdi-proxy, such as custom device redirection,
+	// telemetry, or application launchers.
+	Plugins []SidecarPlugin `json:"plugins,omitempty"`
+}
+
+// SidecarPlugin declares a plugin serving an additional channel to the clients of a
+// desktop. Plugins listen on a UNIX socket named after them in `/run/kvdi/plugins`, which
+// is shared between every container in the desktop pod. Clients open the channel with a
+// websocket to `/api/desktops/ws/{namespace}/{name}/plugins/{plugin}`, and the kvdi-proxy
+// forwards the stream to the plugin after telling it which user is connecting.
+type SidecarPlugin struct {
+	// The name of the plugin and the channel it serves. Must be a valid DNS label.
+	Name string `json:"name"`
+	// The image to run the plugin from. It is run as an additional container in desktop
+	// pods, with the path of the socket to listen on in the `KVDI_PLUGIN_SOCKET` environment
+	// variable. When omitted, the plugin is expected to be started by the desktop image.
+	Image string `json:"image,omitempty"`
+	// The pull policy to use when pulling the plugin image.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Additional environment variables to set in the plugin container.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// Resource restraints to place on the plugin container.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// FileTransferConfig represents restrictions on transferring files to and from desktops.
+// Users must also hold the `use-file-transfer` verb on the template to transfer files.
+type FileTransferConfig struct {
+	// Set to true to prevent uploading files to desktops.
+	DisableUploads bool `json:"disableUploads,omitempty"`
+	// Set to true to prevent downloading files from desktops.
+	DisableDownloads bool `json:"disableDownloads,omitempty"`
+	// The largest file that may be transferred in either direction, e.g. `100Mi`.
+	// Defaults to no limit.
+	MaxFileSize string `json:"maxFileSize,omitempty"`
+	// The content types of files that may be transferred, e.g. `application/pdf`. Types may
+	// end in a wildcard to match a whole family of types, such as `image/*`. Content types
+	// are detected from the contents of the files rather than their names. Defaults to
+	// allowing all types.
+	AllowedContentTypes []string `json:"allowedContentTypes,omitempty"`
+}
+
+// ThumbnailConfig represents configurations for capturing previews of a desktop's display.
+// Thumbnails are served by the API to users holding the `view` verb on the template, and
+// to the owner of the session.
+type ThumbnailConfig struct {
+	// Set to true to have the kvdi-proxy capture thumbnails of the display.
+	Enabled bool `json:"enabled,omitempty"`
+	// How often to capture a new thumbnail. Defaults to 30s.
+	Interval string `json:"interval,omitempty"`
+	// The maximum width in pixels of captured thumbnails. The aspect ratio of the display
+	// is preserved. Defaults to 320.
+	MaxWidth int32 `json:"maxWidth,omitempty"`
+}
+
+// AgentConfig represents configurations for the kvdi-agent inside desktops. The agent is
+// not injected by the manager, the desktop image is expected to start it. Desktops are
+// given a per-session token for authenticating to the API and the environment needed to
+// locate it.
+type AgentConfig struct {
+	// Set to true to issue desktops booted from this template a token for the kvdi-agent.
+	Enabled bool `json:"enabled,omitempty"`
+	// The port the agent serves the local metadata endpoint on. It only listens on the
+	// loopback interface. Defaults to 8989.
+	MetadataPort int32 `json:"metadataPort,omitempty"`
+	// How often the agent reports health to the API and checks for broadcasts. Defaults
+	// to 30s.
+	ReportInterval string `json:"reportInterval,omitempty"`
+}
+
+// KubeCredentialsConfig represents configurations for issuing kubeconfigs to desktop sessions.
+// Issued credentials carry the permissions of the session's service account and are bound
+// to the desktop pod, so they are revoked when the session ends.
+type KubeCredentialsConfig struct {
+	// Set to true to allow the kvdi-agent and users to request kubeconfigs for sessions
+	// booted from this template. When enabled, the service account token is no longer
+	// mounted into desktop pods.
+	Enabled bool `json:"enabled,omitempty"`
+	// How long issued kubeconfigs are valid for. Defaults to 1h. Values below 10m are
+	// raised to 10m.
+	Expiration string `json:"expiration,omitempty"`
+	// The address of the Kubernetes API server written to issued kubeconfigs. Defaults to
+	// the address the manager reaches the API server at.
+	APIServer string `json:"apiServer,omitempty"`
+}
+
+// IDEType represents the type of IDE server exposed by a template.
+// +kubebuilder:validation:Enum=code-server
+type IDEType string
+
+const (
+	// IDECodeServer signals that the template exposes a code-server instance.
+	IDECodeServer IDEType = "code-server"
+)
+
+// IDEConfig represents configurations for an IDE server exposed by desktops.
+type IDEConfig struct {
+	// The type of IDE server. Defaults to `code-server`.
+	Type IDEType `json:"type,omitempty"`
+	// The image to run the IDE server from as a sidecar sharing the user's $HOME. When
+	// omitted, the desktop image is expected to run the server itself on `127.0.0.1` and the
+	// port provided in the `IDE_PORT` environment variable. Required when using `headless` mode.
+	Image string `json:"image,omitempty"`
+	// The pull policy to use when pulling the container image.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Resource restraints to place on the IDE sidecar.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// The port the IDE server listens on. Defaults to 8080. The server only listens on the
+	// loopback interface of the pod and is reached through the kvdi-proxy.
+	Port int32 `json:"port,omitempty"`
+	// Set to true to run only the IDE sidecar and skip the graphical desktop container.
+	// This requires an `image` to be set.
+	Headless bool `json:"headless,omitempty"`
+}
+
+// MaintenanceWindow represents a recurring window during which the manager may recreate
+// idle sessions. Sessions with connected users are flagged as pending maintenance and
+// recreated once they become idle within the window. The same schedule format is used
+// for capacity reservations and lab schedules.
+type MaintenanceWindow struct {
+	// The days of the week the window opens on (e.g. `Saturday` or `sat`). Defaults to
+	// every day.
+	Days []string `json:"days,omitempty"`
+	// The time of day the window opens, in 24-hour `HH:MM` format and UTC. Defaults to
+	// `00:00`.
+	StartTime string `json:"startTime,omitempty"`
+	// How long the window stays open. Defaults to one hour.
+	Duration string `json:"duration,omitempty"`
+}
+
+// SpotConfig represents configurations for scheduling desktops onto spot or preemptible
+// nodes.
+type SpotConfig struct {
+	// Node labels selecting spot nodes (e.g. `cloud.google.com/gke-spot: "true"`).
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Tolerations for any taints placed on spot nodes.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Node taints that signal a spot node is about to be reclaimed. Defaults to the taints
+	// applied by the AWS node termination handler and on GKE.
+	PreemptionTaints []string `json:"preemptionTaints,omitempty"`
+	// How long to warn users after a preemption notice before their desktop is relaunched,
+	// giving them a chance to save their work. Defaults to one minute. The desktop is
+	// relaunched immediately if its node goes away first.
+	WarningPeriod string `json:"warningPeriod,omitempty"`
+	// Node labels selecting on-demand nodes to relaunch preempted sessions on. When unset,
+	// preempted sessions are relaunched on any node not matching the spot node selector.
+	OnDemandNodeSelector map[string]string `json:"onDemandNodeSelector,omitempty"`
+	// The VolumeSnapshotClass to snapshot the user's userdata volume with when a preemption
+	// notice is received. When unset, no snapshot is taken and the volume is reattached to
+	// the relaunched desktop as is.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// DeprecationConfig represents the retirement of a template.
+type DeprecationConfig struct {
+	// A message to show users launching the template (e.g. why it is being retired).
+	Message string `json:"message,omitempty"`
+	// The name of a template users should launch instead.
+	Replacement string `json:"replacement,omitempty"`
+	// The time after which new sessions can no longer be launched from the template. Existing
+	// sessions are drained once they are idle during the template's maintenance window, or
+	// as soon as they are idle if the template has no maintenance window. When unset, the
+	// template is deprecated but can still be launched.
+	Sunset *metav1.Time `json:"sunset,omitempty"`
+}
+
+// ImageStreamingMode represents a method for lazily pulling container images.
+// +kubebuilder:validation:Enum=estargz;soci;mirror
+type ImageStreamingMode string
+
+const (
+	// ImageStreamingEStargz signals that images are in eStargz format and pulled by the
+	// stargz containerd snapshotter.
+	ImageStreamingEStargz ImageStreamingMode = "estargz"
+	// ImageStreamingSOCI signals that images have SOCI indices and are pulled by the SOCI
+	// containerd snapshotter.
+	ImageStreamingSOCI ImageStreamingMode = "soci"
+	// ImageStreamingMirror signals that images are pulled through a read-through registry
+	// cache.
+	ImageStreamingMirror ImageStreamingMode = "mirror"
+)
+
+// HomeShareType represents the protocol used to mount a home share.
+// +kubebuilder:validation:Enum=nfs;smb
+type HomeShareType string
+
+const (
+	// HomeShareNFS mounts the home share over NFS.
+	HomeShareNFS HomeShareType = "nfs"
+	// HomeShareSMB mounts the home share over SMB/CIFS using a CSI driver.
+	HomeShareSMB HomeShareType = "smb"
+)
+
+// HomeShareConfig represents a user's home directory on an external file server that is
+// mounted inside their sessions. Credentials for SMB shares are set by each user through the
+// API and kept in the secrets backend.
+type HomeShareConfig struct {
+	// The protocol used to mount the share.
+	Type HomeShareType `json:"type"`
+	// The address of the file server.
+	Server string `json:"server"`
+	// The path of the share on the server. The string `$(USER)` is replaced with the name
+	// of the user launching the session, e.g. `/homes/$(USER)`.
+	Path string `json:"path"`
+	// Where to mount the share inside the desktop. When unset the share is mounted as the
+	// user's home directory, taking the place of any `userdataSpec` volume.
+	MountPath string `json:"mountPath,omitempty"`
+	// Set to true to mount the share read-only.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// The CSI driver used to mount the share. SMB shares default to `smb.csi.k8s.io`. NFS
+	// shares are mounted with the in-tree volume plugin unless a driver is provided, in which
+	// case `nfs.csi.k8s.io` compatible attributes are used.
+	Driver string `json:"driver,omitempty"`
+	// Extra mount options passed to the CSI driver.
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// Set to true to authenticate SMB shares with the Kerberos credential cache stored for
+	// the user instead of a password.
+	Kerberos bool `json:"kerberos,omitempty"`
+}
+
+// RiskLevel represents how risky the workloads run from a template are.
+// +kubebuilder:validation:Enum=low;medium;high
+type RiskLevel string
+
+const (
+	// RiskLevelLow is for templates running trusted workloads.
+	RiskLevelLow RiskLevel = "low"
+	// RiskLevelMedium is for templates running partially trusted workloads.
+	RiskLevelMedium RiskLevel = "medium"
+	// RiskLevelHigh is for templates running untrusted workloads, such as browsing the
+	// internet.
+	RiskLevelHigh RiskLevel = "high"
+)
+
+// GPUVendor represents a vendor of GPUs exposed to pods by a device plugin.
+// +kubebuilder:validation:Enum=nvidia;amd;intel
+type GPUVendor string
+
+const (
+	// GPUVendorNVIDIA represents GPUs exposed by the NVIDIA device plugin as `nvidia.com/gpu`.
+	GPUVendorNVIDIA GPUVendor = "nvidia"
+	// GPUVendorAMD represents GPUs exposed by the AMD device plugin as `amd.com/gpu`.
+	GPUVendorAMD GPUVendor = "amd"
+	// GPUVendorIntel represents GPUs exposed by the Intel device plugin as `gpu.intel.com/i915`.
+	GPUVendorIntel GPUVendor = "intel"
+)
+
+// GPUConfig represents the GPUs attached to desktops booted from a template.
+type GPUConfig struct {
+	// The vendor of the GPUs. Defaults to `nvidia`.
+	Vendor GPUVendor `json:"vendor,omitempty"`
+	// The number of GPUs (or MIG instances) to attach to each desktop. Defaults to 1.
+	Count int64 `json:"count,omitempty"`
+	// A MIG profile (e.g. `1g.5gb`) to request a slice of a GPU instead of whole GPUs. The
+	// device plugin must expose MIG devices with the `mixed` strategy. Only supported for
+	// NVIDIA GPUs.
+	MIGProfile string `json:"migProfile,omitempty"`
+	// A node selector matching nodes with the GPUs. Defaults to
+	// `nvidia.com/gpu.present: "true"` for NVIDIA GPUs, as labeled by GPU feature discovery.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations for any taints placed on GPU nodes.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// A runtime class exposing the GPUs to containers (e.g. `nvidia`), for nodes where the
+	// vendor runtime is not the default. The sandbox policy of the VDICluster takes precedence.
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+}
+
+// TemplateCapacity represents limits on the number of sessions that may run from a template.
+type TemplateCapacity struct {
+	// The maximum number of sessions of this template that may run at once across the cluster.
+	// Reservations are only enforced when this is set.
+	MaxSessions int `json:"maxSessions,omitempty"`
+	// Reservations holding part of the capacity for specific users or roles.
+	Reservations []CapacityReservation `json:"reservations,omitempty"`
+}
+
+// CapacityReservation holds session slots of a template for specific users or roles. While
+// a reservation is in effect, other users cannot launch sessions into its unused slots. Sessions
+// that were already running when the reservation took effect are not stopped.
+type CapacityReservation struct {
+	// A name for the reservation. Sessions launched into the reservation are annotated with it.
+	Name string `json:"name"`
+	// The users the capacity is reserved for.
+	Users []string `json:"users,omitempty"`
+	// The VDIRoles the capacity is reserved for.
+	Roles []string `json:"roles,omitempty"`
+	// The number of session slots to reserve. Defaults to 1.
+	Slots int `json:"slots,omitempty"`
+	// When the reservation is in effect. When unset the reservation is always in effect.
+	Window *MaintenanceWindow `json:"window,omitempty"`
+}
+
+// LicenseRequirement represents seats of a license pool required by each session of a
+// template.
+type LicenseRequirement struct {
+	// The name of the license pool on the VDICluster.
+	Pool string `json:"pool"`
+	// The number of seats each session requires. Defaults to 1.
+	Seats int `json:"seats,omitempty"`
+}
+
+// ImageStreamingConfig represents configurations for lazily pulling desktop images.
+type ImageStreamingConfig struct {
+	// The method used to lazily pull images. The `estargz` and `soci` modes schedule sessions
+	// onto nodes running the matching containerd snapshotter. The `mirror` mode pulls images
+	// through the registry cache at `mirror`.
+	Mode ImageStreamingMode `json:"mode"`
+	// A node selector matching nodes that support the streaming mode. Defaults to
+	// `kvdi.io/image-streaming: <mode>` for the `estargz` and `soci` modes.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// A runtime class to run sessions with, for clusters that expose the snapshotter
+	// through a dedicated runtime handler.
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// The host (and optional port) of the registry cache to pull images through when using
+	// the `mirror` mode. The registry host of each image in the pod is replaced with this value.
+	Mirror string `json:"mirror,omitempty"`
+}
+
+// RolloutStrategy represents a canary rollout of a new desktop configuration. A percentage
+// of new sessions are launched on the canary revision, and the outcome of those launches
+// determines whether the revision is promoted to the template's `desktop` configuration or
+// rolled back.
+type RolloutStrategy struct {
+	// The desktop configuration for the canary revision. Changing this value starts a new
+	// rollout.
+	Canary *DesktopConfig `json:"canary,omitempty"`
+	// The percentage of new sessions to launch on the canary revision. Defaults to 10.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int32 `json:"percentage,omitempty"`
+	// The number of canary sessions that must reach a running state before the revision is
+	// promoted. Defaults to 5.
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+	// The number of canary sessions that may fail to start before the revision is rolled
+	// back. Defaults to 2.
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
 }
 
 // DockerInDockerConfig is a configuration for mounting a DinD sidecar with desktops
@@ -178,18 +832,188 @@ type QEMUConfig struct {
 	// this sets the `SPICE_DISPLAY` environment variable to `true`. The runners provided by this
 	// repository will tell qemu to set up a SPICE server at `proxy.socketAddr`. The default is to use
 	// VNC. This value is also used by the UI to determine which protocol to expect from a display connection.
+	// Setting `display.protocol` to `spice` has the same effect.
+	//
+	// Deprecated: Set `display.protocol` to `spice` instead.
 	SPICE bool `json:"spice,omitempty"`
 }
 
+// StaticHostProtocol represents the remote desktop protocol spoken by a static host.
+// +kubebuilder:validation:Enum=vnc;rdp
+type StaticHostProtocol string
+
+const (
+	// StaticHostVNC connects the proxy directly to a VNC server on the host.
+	StaticHostVNC StaticHostProtocol = "vnc"
+	// StaticHostRDP runs a bridge next to the proxy that connects to an RDP server on the
+	// host and serves the session over VNC.
+	StaticHostRDP StaticHostProtocol = "rdp"
+)
+
+// StaticHostConfig represents an existing machine that sessions booted from a template
+// connect to. Sessions still run a pod with the kvdi-proxy, so access is authorized and
+// audited the same as any other desktop.
+type StaticHostConfig struct {
+	// The address of the machine in the format of `host:port`.
+	Address string `json:"address"`
+	// The protocol spoken by the machine. Defaults to `vnc`.
+	Protocol StaticHostProtocol `json:"protocol,omitempty"`
+	// The key in the secrets backend holding the credentials for the machine. The value is a
+	// map with a `password` and optionally a `username` and `domain`. For VNC hosts the proxy
+	// answers the VNC authentication challenge with the password, so users are never shown it.
+	// For RDP hosts the credentials are given to the bridge.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// The image to use for the RDP bridge. Defaults to `ghcr.io/kvdi/rdp-bridge:latest`.
+	// The bridge receives the address of the host and its credentials in the `RDP_ADDRESS`,
+	// `RDP_USERNAME`, `RDP_PASSWORD`, and `RDP_DOMAIN` environment variables, and must serve
+	// the session over VNC at `DISPLAY_SOCK_ADDR`.
+	BridgeImage string `json:"bridgeImage,omitempty"`
+	// The pull policy to use when pulling the bridge image.
+	BridgeImagePullPolicy corev1.PullPolicy `json:"bridgeImagePullPolicy,omitempty"`
+	// Resource requirements to place on the bridge.
+	BridgeResources corev1.ResourceRequirements `json:"bridgeResources,omitempty"`
+	// Configurations for powering on the machine when a session is started and it is
+	// not accepting connections.
+	PowerOn *StaticHostPowerOnConfig `json:"powerOn,omitempty"`
+}
+
+// StaticHostPowerOnConfig configures how a static host is powered on. When both methods
+// are configured, both are used.
+type StaticHostPowerOnConfig struct {
+	// Wake the machine by broadcasting a magic packet.
+	WakeOnLAN *WakeOnLANConfig `json:"wakeOnLAN,omitempty"`
+	// Power the machine on by calling a webhook, such as one fronting an IPMI controller
+	// or managed PDU.
+	Webhook *PowerOnWebhookConfig `json:"webhook,omitempty"`
+	// How long to wait for the machine to accept connections after powering it on.
+	// Defaults to 5m.
+	BootTimeout string `json:"bootTimeout,omitempty"`
+}
+
+// WakeOnLANConfig configures sending wake-on-LAN magic packets to a static host.
+type WakeOnLANConfig struct {
+	// The hardware address of the machine's network interface.
+	MACAddress string `json:"macAddress"`
+	// The address to send the magic packet to. This should be the broadcast address of the
+	// machine's network, and must be reachable from the manager. Defaults to
+	// `255.255.255.255:9`.
+	BroadcastAddress string `json:"broadcastAddress,omitempty"`
+}
+
+// PowerOnWebhookConfig configures a webhook that powers on a static host. The webhook
+// receives a JSON body with the `address` of the machine and the `session` being started.
+type PowerOnWebhookConfig struct {
+	// The URL to send the request to.
+	URL string `json:"url"`
+	// The HTTP method to use. Defaults to `POST`.
+	Method string `json:"method,omitempty"`
+	// A key in the secrets backend holding a token to send as a bearer token with the
+	// request.
+	TokenSecret string `json:"tokenSecret,omitempty"`
+}
+
+// FirewallPolicy represents the ports applications inside a desktop may listen on. The
+// display proxy, any exposed ports, and the IDE server are always allowed for the kvdi app.
+type FirewallPolicy struct {
+	// Additional ports applications inside the desktop may listen on. Connections to any
+	// port not listed here, and not otherwise used by kvdi, are dropped.
+	Ports []FirewallPort `json:"ports,omitempty"`
+}
+
+// FirewallPort represents a port applications inside a desktop may listen on.
+type FirewallPort struct {
+	// The port number.
+	Port int32 `json:"port"`
+	// The protocol of the port. Defaults to `TCP`.
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	// Set to true to allow desktops from other sessions in the VDICluster to connect to this
+	// port directly. Otherwise only the kvdi app may reach it.
+	AllowFromSessions bool `json:"allowFromSessions,omitempty"`
+}
+
+// ReviewState represents the state of the review of a draft template.
+// +kubebuilder:validation:Enum=Pending;Approved;Rejected
+type ReviewState string
+
+const (
+	// ReviewPending means the template is waiting for a reviewer.
+	ReviewPending ReviewState = "Pending"
+	// ReviewApproved means the template may be launched by anyone allowed to launch it.
+	ReviewApproved ReviewState = "Approved"
+	// ReviewRejected means the template was rejected and remains a draft.
+	ReviewRejected ReviewState = "Rejected"
+)
+
+// TemplateReview represents the review of a template saved from a desktop session.
+type TemplateReview struct {
+	// The user that saved the template.
+	Author string `json:"author"`
+	// The session the template was saved from, in the form `namespace/name`.
+	SourceSession string `json:"sourceSession,omitempty"`
+	// The state of the review. Defaults to `Pending`.
+	State ReviewState `json:"state,omitempty"`
+	// The user that approved or rejected the template.
+	Reviewer string `json:"reviewer,omitempty"`
+	// A comment left by the reviewer.
+	Comment string `json:"comment,omitempty"`
+	// The time the template was approved or rejected.
+	ReviewedAt *metav1.Time `json:"reviewedAt,omitempty"`
+}
+
+// TemplateStatus defines the observed state of Template
+type TemplateStatus struct {
+	// The results of the most recent lint of this template.
+	LintResults []LintResult `json:"lintResults,omitempty"`
+	// The state of the current canary rollout, if any.
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+}
+
+// RolloutPhase represents the phase of a canary rollout.
+type RolloutPhase string
+
+const (
+	// RolloutProgressing means new sessions are being launched on the canary revision.
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutPromoted means the canary revision became the template's desktop configuration.
+	RolloutPromoted RolloutPhase = "Promoted"
+	// RolloutRolledBack means the canary revision failed and new sessions use the stable
+	// configuration.
+	RolloutRolledBack RolloutPhase = "RolledBack"
+)
+
+// RolloutStatus represents the observed state of a canary rollout.
+type RolloutStatus struct {
+	// The canary revision these results apply to.
+	Revision string `json:"revision"`
+	// The current phase of the rollout.
+	Phase RolloutPhase `json:"phase"`
+	// The number of canary sessions that reached a running state.
+	Successes int32 `json:"successes,omitempty"`
+	// The number of canary sessions that failed to start.
+	Failures int32 `json:"failures,omitempty"`
+}
+
+// LintResult represents a single finding from the template linter.
+type LintResult struct {
+	// The name of the rule that produced this finding.
+	Rule string `json:"rule"`
+	// The severity of the finding.
+	Severity appv1.LintSeverity `json:"severity"`
+	// A message describing the finding.
+	Message string `json:"message"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:resource:path=templates,scope=Cluster
+//+kubebuilder:subresource:status
 
 // Template is the Schema for the templates API
 type Template struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec TemplateSpec `json:"spec,omitempty"`
+	Spec   TemplateSpec   `json:"spec,omitempty"`
+	Status TemplateStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -32,70 +32,112 @@ import (
 // environment variable secret name.
 func (t *Template) ToPodSpec(cluster *appv1.VDICluster, instance *Session, envSecret, userdataVol string) corev1.PodSpec {
 	return corev1.PodSpec{
-		Hostname:           instance.GetName(),
-		Subdomain:          instance.GetName(),
-		ServiceAccountName: instance.GetServiceAccount(),
-		SecurityContext:    t.GetPodSecurityContext(),
-		Volumes:            t.GetVolumes(cluster, instance, userdataVol),
-		ImagePullSecrets:   t.GetPullSecrets(),
-		InitContainers:     t.GetInitContainers(),
-		Containers:         t.GetContainers(cluster, instance, envSecret),
+		Hostname:                     instance.GetName(),
+		Subdomain:                    instance.GetName(),
+		ServiceAccountName:           instance.GetServiceAccount(),
+		AutomountServiceAccountToken: t.GetAutomountServiceAccountToken(),
+		SecurityContext:              t.GetPodSecurityContext(),
+		Volumes:                      t.GetVolumes(cluster, instance, userdataVol),
+		ImagePullSecrets:             t.GetPullSecrets(),
+		InitContainers:               t.applyImageMirror(cluster, t.GetInitContainers(cluster)),
+		Containers:                   t.applyImageMirror(cluster, t.GetContainers(cluster, instance, envSecret)),
+		NodeSelector:                 t.GetPodNodeSelector(instance),
+		Affinity:                     t.GetAffinity(instance),
+		Tolerations:                  t.GetTolerations(instance),
+		RuntimeClassName:             t.GetPodRuntimeClassName(cluster),
 	}
 }
 
 // GetContainers returns the containers for a given Session.
 func (t *Template) GetContainers(cluster *appv1.VDICluster, instance *Session, envSecret string) []corev1.Container {
-	containers := []corev1.Container{t.GetDesktopProxyContainer()}
+	containers := []corev1.Container{t.GetDesktopProxyContainer(cluster, instance)}
+	containers = append(containers, t.GetPluginContainers()...)
+	if t.IsStaticHostTemplate() {
+		// only the proxy, its plugins, and a bridge for protocols it does not speak, run
+		// for static hosts
+		if t.StaticHostUsesBridge() {
+			containers = append(containers, t.GetStaticHostBridgeContainer(instance))
+		}
+		return containers
+	}
 	if t.IsQEMUTemplate() {
 		containers = append(containers, t.GetQEMUContainer(cluster, instance))
-	} else {
+	} else if !t.IDEIsHeadless() {
 		containers = append(containers, t.GetDesktopContainer(cluster, instance, envSecret))
 	}
+	if t.IDEIsSidecar() {
+		containers = append(containers, t.GetIDEContainer(instance))
+	}
 	if t.DindIsEnabled() {
 		containers = append(containers, t.GetDindContainer())
 	}
+	if t.DisplayIsRDP() {
+		containers = append(containers, t.GetGuacdContainer())
+	}
 	return containers
 }
 
 // GetInitContainers returns any init containers required to run before the desktop launches.
-func (t *Template) GetInitContainers() []corev1.Container {
+func (t *Template) GetInitContainers(cluster *appv1.VDICluster) []corev1.Container {
+	containers := make([]corev1.Container, 0)
+	if cluster.GetMaxConcurrentBootsPerNode() > 0 {
+		containers = append(containers, t.GetBootGateContainer())
+	}
 	if t.IsQEMUTemplate() && !t.QEMUUseCSI() {
 		cmd := fmt.Sprintf("cp %s %s && chmod 666 %s", t.GetQEMUDiskPath(), v1.QEMUNonCSIBootImagePath, v1.QEMUNonCSIBootImagePath)
 		if cloudInit := t.GetQEMUCloudInitPath(); cloudInit != "" {
 			cmd += fmt.Sprintf(" && cp %s %s && chmod 666 %s", cloudInit, v1.QEMUNonCSICloudImagePath, v1.QEMUNonCSICloudImagePath)
 		}
-		return []corev1.Container{
-			{
-				Name:            "qemu-kvm-init",
-				Image:           t.GetQEMUDiskImage(),
-				ImagePullPolicy: t.GetQEMUDiskImagePullPolicy(),
-				Command:         []string{"/bin/sh", "-c", cmd},
-				VolumeMounts: []corev1.VolumeMount{
-					{
-						Name:      v1.RunVolume,
-						MountPath: v1.DesktopRunPath,
-					},
+		containers = append(containers, corev1.Container{
+			Name:            "qemu-kvm-init",
+			Image:           t.GetQEMUDiskImage(),
+			ImagePullPolicy: t.GetQEMUDiskImagePullPolicy(),
+			Command:         []string{"/bin/sh", "-c", cmd},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      v1.RunVolume,
+					MountPath: v1.DesktopRunPath,
 				},
 			},
-		}
+		})
+	} else if t.DindIsEnabled() {
+		containers = append(containers, corev1.Container{
+			Name:            "dind-init",
+			Image:           t.GetDindImage(),
+			ImagePullPolicy: t.GetDindPullPolicy(),
+			Command:         []string{"/bin/sh", "-c", fmt.Sprintf("cp -r /usr/local/bin/* %s", v1.DockerBinPath)},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      v1.DockerBinVolume,
+					MountPath: v1.DockerBinPath,
+				},
+			},
+		})
 	}
-	if t.DindIsEnabled() {
-		return []corev1.Container{
+	if len(containers) == 0 {
+		return nil
+	}
+	return containers
+}
+
+// GetBootGateContainer returns an init container that blocks until the manager admits the
+// pod to boot on its node. Images for the remaining containers are not pulled until it exits.
+func (t *Template) GetBootGateContainer() corev1.Container {
+	return corev1.Container{
+		Name:            "boot-gate",
+		Image:           t.GetKVDIVNCProxyImage(),
+		ImagePullPolicy: t.GetProxyPullPolicy(),
+		Command: []string{
+			"/bin/sh", "-c",
+			fmt.Sprintf("until grep -qx true %s/admitted 2>/dev/null; do sleep 1; done", v1.BootGatePath),
+		},
+		VolumeMounts: []corev1.VolumeMount{
 			{
-				Name:            "dind-init",
-				Image:           t.GetDindImage(),
-				ImagePullPolicy: t.GetDindPullPolicy(),
-				Command:         []string{"/bin/sh", "-c", fmt.Sprintf("cp -r /usr/local/bin/* %s", v1.DockerBinPath)},
-				VolumeMounts: []corev1.VolumeMount{
-					{
-						Name:      v1.DockerBinVolume,
-						MountPath: v1.DockerBinPath,
-					},
-				},
+				Name:      v1.BootGateVolume,
+				MountPath: v1.BootGatePath,
 			},
-		}
+		},
 	}
-	return nil
 }
 
 // GetPullSecrets returns the pull secrets for this instance.
@@ -110,12 +152,14 @@ func (t *Template) GetPodSecurityContext() *corev1.PodSecurityContext {
 		return &corev1.PodSecurityContext{
 			RunAsNonRoot: &v1.False,
 			FSGroup:      &v1.DefaultUser,
+			Sysctls:      t.GetSysctls(),
 		}
 	}
 	return &corev1.PodSecurityContext{
 		RunAsNonRoot: &v1.True,
 		RunAsUser:    &v1.DefaultUser,
 		FSGroup:      &v1.DefaultUser,
+		Sysctls:      t.GetSysctls(),
 	}
 }
 
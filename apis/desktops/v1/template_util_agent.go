@@ -0,0 +1,123 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AgentIsEnabled returns true if desktops booted from this template should be issued a
+// token for the kvdi-agent. Static hosts have no desktop container to run the agent in.
+func (t *Template) AgentIsEnabled() bool {
+	if t.Spec.AgentConfig != nil && !t.IsStaticHostTemplate() {
+		return t.Spec.AgentConfig.Enabled
+	}
+	return false
+}
+
+// GetAgentMetadataPort returns the port the kvdi-agent serves local metadata on.
+func (t *Template) GetAgentMetadataPort() int32 {
+	if t.Spec.AgentConfig != nil && t.Spec.AgentConfig.MetadataPort != 0 {
+		return t.Spec.AgentConfig.MetadataPort
+	}
+	return v1.DefaultAgentMetadataPort
+}
+
+// GetAgentReportInterval returns how often the kvdi-agent should report to the API.
+func (t *Template) GetAgentReportInterval() time.Duration {
+	if t.Spec.AgentConfig != nil {
+		if dur, err := time.ParseDuration(t.Spec.AgentConfig.ReportInterval); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultAgentReportInterval
+}
+
+// GetAgentVolume returns the volume containing the agent token and the CA certificate
+// for verifying the API. The desktop's own certificate and key are not included.
+func (t *Template) GetAgentVolume(desktop *Session) corev1.Volume {
+	return corev1.Volume{
+		Name: v1.AgentVolume,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: desktop.GetAgentSecretName()},
+							Items:                []corev1.KeyToPath{{Key: v1.AgentTokenKey, Path: v1.AgentTokenKey}},
+						},
+					},
+					{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: desktop.GetName()},
+							Items:                []corev1.KeyToPath{{Key: v1.CACertKey, Path: v1.CACertKey}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetAgentVolumeMount returns the mount for the agent volume in the desktop container.
+func (t *Template) GetAgentVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      v1.AgentVolume,
+		MountPath: v1.AgentPath,
+		ReadOnly:  true,
+	}
+}
+
+// GetAgentEnvVars returns the environment variables the kvdi-agent is configured from.
+func (t *Template) GetAgentEnvVars(cluster *appv1.VDICluster, desktop *Session) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  v1.AgentAPIURLEnvVar,
+			Value: cluster.GetAppInternalURL(),
+		},
+		{
+			Name:  v1.AgentSessionEnvVar,
+			Value: fmt.Sprintf("%s/%s", desktop.GetNamespace(), desktop.GetName()),
+		},
+		{
+			Name:  v1.AgentTokenFileEnvVar,
+			Value: filepath.Join(v1.AgentPath, v1.AgentTokenKey),
+		},
+		{
+			Name:  v1.AgentCAFileEnvVar,
+			Value: filepath.Join(v1.AgentPath, v1.CACertKey),
+		},
+		{
+			Name:  v1.AgentMetadataAddrEnvVar,
+			Value: fmt.Sprintf("127.0.0.1:%d", t.GetAgentMetadataPort()),
+		},
+		{
+			Name:  v1.AgentReportIntervalEnvVar,
+			Value: t.GetAgentReportInterval().String(),
+		},
+	}
+}
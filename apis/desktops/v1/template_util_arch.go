@@ -0,0 +1,133 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetArchitectures returns the architectures desktops booted from this template can run on.
+// An empty list means any architecture.
+func (t *Template) GetArchitectures() []Architecture {
+	return t.Spec.Architectures
+}
+
+// SupportsArchitecture returns true if desktops booted from this template can run on the
+// given architecture.
+func (t *Template) SupportsArchitecture(arch Architecture) bool {
+	archs := t.GetArchitectures()
+	if len(archs) == 0 {
+		return true
+	}
+	for _, a := range archs {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// HasImageVariants returns true if the template uses a different desktop image per
+// architecture. Sessions for these templates must be pinned to an architecture.
+func (t *Template) HasImageVariants() bool {
+	return t.Spec.DesktopConfig != nil && len(t.Spec.DesktopConfig.ImageVariants) > 0
+}
+
+// GetDesktopImageForArchitecture returns the desktop image to use on the given
+// architecture. The default image is returned if there is no variant for it.
+func (t *Template) GetDesktopImageForArchitecture(arch Architecture) string {
+	if t.HasImageVariants() && arch != "" {
+		for _, variant := range t.Spec.DesktopConfig.ImageVariants {
+			if variant.Architecture == arch {
+				return variant.Image
+			}
+		}
+	}
+	return t.GetDesktopImage()
+}
+
+// GetLaunchArchitectures returns the architectures a new session from this template can be
+// pinned to. When the template has image variants, only architectures with a variant (or
+// all supported ones if there is also a default image) are returned.
+func (t *Template) GetLaunchArchitectures() []Architecture {
+	archs := t.GetArchitectures()
+	if !t.HasImageVariants() || t.GetDesktopImage() != "" {
+		return archs
+	}
+	out := make([]Architecture, 0)
+	for _, variant := range t.Spec.DesktopConfig.ImageVariants {
+		if t.SupportsArchitecture(variant.Architecture) {
+			out = append(out, variant.Architecture)
+		}
+	}
+	return out
+}
+
+// GetSessionArchitecture returns the architecture the desktop for the given session should
+// run on. Sessions that were not pinned at launch fall back to the first image variant when
+// the template has no default image.
+func (t *Template) GetSessionArchitecture(instance *Session) Architecture {
+	if arch := instance.GetArchitecture(); arch != "" {
+		return arch
+	}
+	if t.HasImageVariants() && t.GetDesktopImage() == "" {
+		if archs := t.GetLaunchArchitectures(); len(archs) > 0 {
+			return archs[0]
+		}
+	}
+	return ""
+}
+
+// GetAffinity returns the scheduling affinity for the desktop pod of the given session.
+// Sessions pinned to an architecture are scheduled to nodes of that architecture, otherwise
+// pods are restricted to the architectures supported by the template. Sessions relaunched
+// after a spot preemption are also kept off of spot nodes.
+func (t *Template) GetAffinity(instance *Session) *corev1.Affinity {
+	var archs []Architecture
+	if arch := t.GetSessionArchitecture(instance); arch != "" {
+		archs = []Architecture{arch}
+	} else {
+		archs = t.GetArchitectures()
+	}
+	reqs := t.getSpotExclusions(instance)
+	if len(archs) > 0 {
+		values := make([]string, len(archs))
+		for i, arch := range archs {
+			values[i] = string(arch)
+		}
+		reqs = append(reqs, corev1.NodeSelectorRequirement{
+			Key:      corev1.LabelArchStable,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   values,
+		})
+	}
+	if len(reqs) == 0 {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: reqs},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+// AudioEnabled returns true if audio may be streamed from desktops booted from the
+// template.
+func (t *Template) AudioEnabled() bool {
+	if t.Spec.AudioConfig == nil || t.Spec.AudioConfig.Enabled == nil {
+		return true
+	}
+	return *t.Spec.AudioConfig.Enabled
+}
+
+// MicrophoneEnabled returns true if clients may pass their microphone through to desktops
+// booted from the template.
+func (t *Template) MicrophoneEnabled() bool {
+	if !t.AudioEnabled() {
+		return false
+	}
+	if t.Spec.AudioConfig == nil || t.Spec.AudioConfig.Microphone == nil {
+		return true
+	}
+	return *t.Spec.AudioConfig.Microphone
+}
+
+// GetAudioBitrate returns the bitrate in bits per second to encode audio at.
+func (t *Template) GetAudioBitrate() int32 {
+	if t.Spec.AudioConfig != nil && t.Spec.AudioConfig.Bitrate > 0 {
+		return t.Spec.AudioConfig.Bitrate
+	}
+	return v1.DefaultAudioBitrate
+}
+
+// GetAudioSampleRate returns the sample rate to capture audio at.
+func (t *Template) GetAudioSampleRate() int32 {
+	if t.Spec.AudioConfig != nil && t.Spec.AudioConfig.SampleRate > 0 {
+		return t.Spec.AudioConfig.SampleRate
+	}
+	return v1.DefaultAudioSampleRate
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+)
+
+// CapacityIsLimited returns true if this template limits the number of sessions that
+// may run at once.
+func (t *Template) CapacityIsLimited() bool {
+	return t.GetMaxSessions() > 0
+}
+
+// GetMaxSessions returns the maximum number of sessions of this template that may run
+// at once. Zero means there is no limit.
+func (t *Template) GetMaxSessions() int {
+	if t.Spec.Capacity == nil {
+		return 0
+	}
+	return t.Spec.Capacity.MaxSessions
+}
+
+// GetReservations returns the capacity reservations for this template.
+func (t *Template) GetReservations() []CapacityReservation {
+	if t.Spec.Capacity == nil {
+		return nil
+	}
+	return t.Spec.Capacity.Reservations
+}
+
+// GetActiveReservations returns the capacity reservations that are in effect at the
+// given time.
+func (t *Template) GetActiveReservations(now time.Time) []CapacityReservation {
+	active := make([]CapacityReservation, 0)
+	for _, res := range t.GetReservations() {
+		if res.IsActive(now) {
+			active = append(active, res)
+		}
+	}
+	return active
+}
+
+// GetSlots returns the number of session slots held by the reservation.
+func (r *CapacityReservation) GetSlots() int {
+	if r.Slots <= 0 {
+		return 1
+	}
+	return r.Slots
+}
+
+// IsActive returns true if the reservation is in effect at the given time.
+func (r *CapacityReservation) IsActive(now time.Time) bool {
+	if r.Window == nil {
+		return true
+	}
+	return r.Window.IsOpen(now)
+}
+
+// Matches returns true if the reservation applies to the given user or any of the
+// given roles.
+func (r *CapacityReservation) Matches(username string, roles []string) bool {
+	for _, user := range r.Users {
+		if user == username {
+			return true
+		}
+	}
+	for _, role := range r.Roles {
+		for _, userRole := range roles {
+			if role == userRole {
+				return true
+			}
+		}
+	}
+	return false
+}
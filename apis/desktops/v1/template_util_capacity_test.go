@@ -0,0 +1,61 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveReservations(t *testing.T) {
+	now := time.Date(2021, time.March, 6, 12, 0, 0, 0, time.UTC) // a saturday
+	tmpl := &Template{
+		Spec: TemplateSpec{
+			Capacity: &TemplateCapacity{
+				MaxSessions: 4,
+				Reservations: []CapacityReservation{
+					{Name: "always", Users: []string{"alice"}},
+					{Name: "weekends", Roles: []string{"students"}, Slots: 2, Window: &MaintenanceWindow{Days: []string{"sat", "sun"}, StartTime: "00:00", Duration: "24h"}},
+					{Name: "weekdays", Roles: []string{"staff"}, Window: &MaintenanceWindow{Days: []string{"mon"}, StartTime: "00:00", Duration: "24h"}},
+				},
+			},
+		},
+	}
+	if !tmpl.CapacityIsLimited() || tmpl.GetMaxSessions() != 4 {
+		t.Fatal("Expected capacity to be limited to 4 sessions")
+	}
+	active := tmpl.GetActiveReservations(now)
+	if len(active) != 2 || active[0].Name != "always" || active[1].Name != "weekends" {
+		t.Fatal("Expected the always and weekends reservations to be active, got:", active)
+	}
+	if active[0].GetSlots() != 1 || active[1].GetSlots() != 2 {
+		t.Error("Expected slots to default to 1, got:", active[0].GetSlots())
+	}
+	if !active[0].Matches("alice", nil) || active[0].Matches("bob", []string{"students"}) {
+		t.Error("Expected the always reservation to only match alice")
+	}
+	if !active[1].Matches("bob", []string{"other", "students"}) || active[1].Matches("alice", nil) {
+		t.Error("Expected the weekends reservation to only match students")
+	}
+
+	if (&Template{}).CapacityIsLimited() {
+		t.Error("Expected capacity to be unlimited without a capacity config")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import "strings"
+
+// ClipboardToDesktopAllowed returns true if clipboard contents may be sent from clients
+// to desktops booted from the template.
+func (t *Template) ClipboardToDesktopAllowed() bool {
+	if t.Spec.ProxyConfig == nil || t.Spec.ProxyConfig.AllowClipboardTo == nil {
+		return true
+	}
+	return *t.Spec.ProxyConfig.AllowClipboardTo
+}
+
+// ClipboardFromDesktopAllowed returns true if clipboard contents may be sent from
+// desktops booted from the template to clients.
+func (t *Template) ClipboardFromDesktopAllowed() bool {
+	if t.Spec.ProxyConfig == nil || t.Spec.ProxyConfig.AllowClipboardFrom == nil {
+		return true
+	}
+	return *t.Spec.ProxyConfig.AllowClipboardFrom
+}
+
+// GetVNCClipboardArgs returns the arguments to pass to the VNC server of desktops booted
+// from the template to enforce the directions the clipboard may be synced in. An empty
+// string is returned when the clipboard is unrestricted or the display is not VNC.
+func (t *Template) GetVNCClipboardArgs() string {
+	if !t.DisplayIsVNC() {
+		return ""
+	}
+	args := make([]string, 0)
+	if !t.ClipboardToDesktopAllowed() {
+		args = append(args, "-AcceptCutText=0")
+	}
+	if !t.ClipboardFromDesktopAllowed() {
+		args = append(args, "-SendCutText=0")
+	}
+	return strings.Join(args, " ")
+}
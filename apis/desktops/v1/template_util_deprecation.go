@@ -0,0 +1,64 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"time"
+)
+
+// IsDeprecated returns true if this template is marked deprecated.
+func (t *Template) IsDeprecated() bool {
+	return t.Spec.Deprecation != nil
+}
+
+// GetSunsetTime returns the time after which the template can no longer be launched, or
+// a zero time if it has none.
+func (t *Template) GetSunsetTime() time.Time {
+	if t.IsDeprecated() && t.Spec.Deprecation.Sunset != nil {
+		return t.Spec.Deprecation.Sunset.Time
+	}
+	return time.Time{}
+}
+
+// IsSunset returns true if the template's sunset date has passed at the given time.
+func (t *Template) IsSunset(now time.Time) bool {
+	sunset := t.GetSunsetTime()
+	return !sunset.IsZero() && !now.Before(sunset)
+}
+
+// GetDeprecationWarning returns a message describing the deprecation of the template for
+// users launching it, or an empty string if it is not deprecated.
+func (t *Template) GetDeprecationWarning() string {
+	if !t.IsDeprecated() {
+		return ""
+	}
+	msg := fmt.Sprintf("Template %s is deprecated", t.GetName())
+	if sunset := t.GetSunsetTime(); !sunset.IsZero() {
+		msg += fmt.Sprintf(" and will be retired on %s", sunset.UTC().Format(time.RFC3339))
+	}
+	if t.Spec.Deprecation.Replacement != "" {
+		msg += fmt.Sprintf(", use %s instead", t.Spec.Deprecation.Replacement)
+	}
+	if t.Spec.Deprecation.Message != "" {
+		msg += ": " + t.Spec.Deprecation.Message
+	}
+	return msg
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeprecation(t *testing.T) {
+	now := time.Date(2021, time.March, 6, 12, 0, 0, 0, time.UTC)
+	tmpl := &Template{}
+	tmpl.Name = "old-desktop"
+	if tmpl.IsDeprecated() || tmpl.IsSunset(now) || tmpl.GetDeprecationWarning() != "" {
+		t.Fatal("Expected a template without a deprecation config to not be deprecated")
+	}
+
+	// deprecated without a sunset date
+	tmpl.Spec.Deprecation = &DeprecationConfig{Replacement: "new-desktop", Message: "Upgrade to the new image"}
+	if !tmpl.IsDeprecated() || tmpl.IsSunset(now) || !tmpl.GetSunsetTime().IsZero() {
+		t.Error("Expected a deprecated template without a sunset to remain launchable")
+	}
+	if warning := tmpl.GetDeprecationWarning(); warning != "Template old-desktop is deprecated, use new-desktop instead: Upgrade to the new image" {
+		t.Error("Unexpected deprecation warning:", warning)
+	}
+
+	sunset := metav1.NewTime(now.Add(time.Hour))
+	tmpl.Spec.Deprecation.Sunset = &sunset
+	if tmpl.IsSunset(now) {
+		t.Error("Expected the template to not be retired before its sunset")
+	}
+	if !tmpl.IsSunset(sunset.Time) || !tmpl.IsSunset(now.Add(2*time.Hour)) {
+		t.Error("Expected the template to be retired from its sunset")
+	}
+	if warning := tmpl.GetDeprecationWarning(); !strings.Contains(warning, "will be retired on 2021-03-06T13:00:00Z") {
+		t.Error("Expected the warning to include the sunset date, got:", warning)
+	}
+}
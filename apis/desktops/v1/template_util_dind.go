@@ -28,7 +28,7 @@ import (
 
 // DindIsEnabled returns true if dind is enabled for instances from this template.
 func (t *Template) DindIsEnabled() bool {
-	return t.Spec.DindConfig != nil
+	return t.Spec.DindConfig != nil && !t.IsStaticHostTemplate()
 }
 
 // GetDindImage returns the image to use for the dind sidecar.
@@ -0,0 +1,160 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetDisplayProtocol returns the protocol spoken by the display server of desktops booted
+// from this template.
+func (t *Template) GetDisplayProtocol() DisplayProtocol {
+	if t.Spec.DisplayConfig != nil && t.Spec.DisplayConfig.Protocol != "" {
+		return t.Spec.DisplayConfig.Protocol
+	}
+	if t.Spec.QEMUConfig != nil && t.Spec.QEMUConfig.SPICE {
+		return DisplayProtocolSPICE
+	}
+	return DisplayProtocolNoVNC
+}
+
+// DisplayIsVNC returns true if the display server of desktops booted from this template
+// speaks VNC.
+func (t *Template) DisplayIsVNC() bool {
+	return t.GetDisplayProtocol() == DisplayProtocolNoVNC
+}
+
+// DisplayIsRDP returns true if desktops booted from this template serve their display over
+// RDP through a guacd sidecar.
+func (t *Template) DisplayIsRDP() bool {
+	return !t.IsStaticHostTemplate() && t.GetDisplayProtocol() == DisplayProtocolRDP
+}
+
+// RecordingEnabled returns true if the displays of desktops booted from this template
+// are recorded while clients are connected to them. Only VNC displays can be recorded.
+func (t *Template) RecordingEnabled() bool {
+	return t.Spec.DisplayConfig != nil && t.Spec.DisplayConfig.Record && t.DisplayIsVNC()
+}
+
+// GetRDPPort returns the port of the RDP server inside desktops booted from this template.
+func (t *Template) GetRDPPort() int32 {
+	if cfg := t.getRDPConfig(); cfg != nil && cfg.Port != 0 {
+		return cfg.Port
+	}
+	return v1.DefaultRDPPort
+}
+
+// GetRDPSecurity returns the security mode used when connecting to the RDP server.
+func (t *Template) GetRDPSecurity() RDPSecurityMode {
+	if cfg := t.getRDPConfig(); cfg != nil && cfg.Security != "" {
+		return cfg.Security
+	}
+	return RDPSecurityAny
+}
+
+// RDPIgnoreCert returns true if the certificate of the RDP server should not be verified.
+func (t *Template) RDPIgnoreCert() bool {
+	if cfg := t.getRDPConfig(); cfg != nil {
+		return cfg.IgnoreCert
+	}
+	return false
+}
+
+// RDPNeedsCredentials returns true if credentials for the RDP server need to be copied
+// from the secrets backend for each session.
+func (t *Template) RDPNeedsCredentials() bool {
+	return t.DisplayIsRDP() && t.GetRDPCredentialsSecret() != ""
+}
+
+// GetRDPCredentialsSecret returns the key in the secrets backend holding the credentials
+// for the RDP server.
+func (t *Template) GetRDPCredentialsSecret() string {
+	if cfg := t.getRDPConfig(); cfg != nil {
+		return cfg.CredentialsSecret
+	}
+	return ""
+}
+
+// GetGuacdImage returns the image to use for the guacd sidecar.
+func (t *Template) GetGuacdImage() string {
+	if cfg := t.getRDPConfig(); cfg != nil && cfg.GuacdImage != "" {
+		return cfg.GuacdImage
+	}
+	return "guacamole/guacd:1.3.0"
+}
+
+// GetGuacdPullPolicy returns the pull policy for the guacd image.
+func (t *Template) GetGuacdPullPolicy() corev1.PullPolicy {
+	if cfg := t.getRDPConfig(); cfg != nil && cfg.GuacdImagePullPolicy != "" {
+		return cfg.GuacdImagePullPolicy
+	}
+	return corev1.PullIfNotPresent
+}
+
+// GetGuacdResources returns the resource requirements for the guacd sidecar.
+func (t *Template) GetGuacdResources() corev1.ResourceRequirements {
+	if cfg := t.getRDPConfig(); cfg != nil {
+		return cfg.GuacdResources
+	}
+	return corev1.ResourceRequirements{}
+}
+
+// GetGuacdContainer returns the guacd sidecar translating the RDP display of a desktop
+// for the proxy.
+func (t *Template) GetGuacdContainer() corev1.Container {
+	return corev1.Container{
+		Name:            "guacd",
+		Image:           t.GetGuacdImage(),
+		ImagePullPolicy: t.GetGuacdPullPolicy(),
+		Resources:       t.GetGuacdResources(),
+	}
+}
+
+// GetRDPCredentialsEnv returns the environment variables the proxy reads the credentials
+// for the RDP server from.
+func (t *Template) GetRDPCredentialsEnv(desktop *Session) []corev1.EnvVar {
+	env := make([]corev1.EnvVar, 0)
+	for _, pair := range [][2]string{
+		{v1.RDPUsernameEnvVar, StaticHostUsernameKey},
+		{v1.RDPPasswordEnvVar, StaticHostPasswordKey},
+		{v1.RDPDomainEnvVar, StaticHostDomainKey},
+	} {
+		env = append(env, corev1.EnvVar{
+			Name: pair[0],
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: desktop.GetRDPSecretName()},
+					Key:                  pair[1],
+					Optional:             &v1.True,
+				},
+			},
+		})
+	}
+	return env
+}
+
+func (t *Template) getRDPConfig() *RDPConfig {
+	if t.Spec.DisplayConfig != nil {
+		return t.Spec.DisplayConfig.RDP
+	}
+	return nil
+}
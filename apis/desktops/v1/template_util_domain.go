@@ -0,0 +1,72 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"path/filepath"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DomainJoinIsEnabled returns true if desktops booted from this template should be joined
+// to the Kerberos realm of the given cluster.
+func (t *Template) DomainJoinIsEnabled(cluster *appv1.VDICluster) bool {
+	if t.Spec.DesktopConfig == nil || !t.Spec.DesktopConfig.DomainJoin || t.IsQEMUTemplate() {
+		return false
+	}
+	return cluster.DomainJoinIsEnabled()
+}
+
+// GetDomainJoinVolume returns the volume containing the Kerberos configuration and keytab
+// for the given desktop.
+func (t *Template) GetDomainJoinVolume(desktop *Session) corev1.Volume {
+	mode := int32(0600)
+	return corev1.Volume{
+		Name: v1.DomainJoinVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: desktop.GetDomainJoinSecretName(),
+				Items: []corev1.KeyToPath{
+					{Key: filepath.Base(v1.KRB5ConfigPath), Path: filepath.Base(v1.KRB5ConfigPath)},
+					{Key: filepath.Base(v1.KRB5KeytabPath), Path: filepath.Base(v1.KRB5KeytabPath), Mode: &mode},
+					{Key: filepath.Base(v1.SSSDConfigPath), Path: filepath.Base(v1.SSSDConfigPath), Mode: &mode},
+				},
+			},
+		},
+	}
+}
+
+// GetDomainJoinVolumeMounts returns the mounts placing the Kerberos configuration and
+// keytab in the desktop container.
+func (t *Template) GetDomainJoinVolumeMounts() []corev1.VolumeMount {
+	mounts := make([]corev1.VolumeMount, 0)
+	for _, path := range []string{v1.KRB5ConfigPath, v1.KRB5KeytabPath, v1.SSSDConfigPath} {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      v1.DomainJoinVolume,
+			MountPath: path,
+			SubPath:   filepath.Base(path),
+			ReadOnly:  true,
+		})
+	}
+	return mounts
+}
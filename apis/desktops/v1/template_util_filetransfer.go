@@ -0,0 +1,87 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// FileUploadsEnabled returns true if files may be uploaded to desktops booted from the
+// template.
+func (t *Template) FileUploadsEnabled() bool {
+	if !t.FileTransferEnabled() {
+		return false
+	}
+	return t.Spec.ProxyConfig.FileTransfer == nil || !t.Spec.ProxyConfig.FileTransfer.DisableUploads
+}
+
+// FileDownloadsEnabled returns true if files may be downloaded from desktops booted from
+// the template.
+func (t *Template) FileDownloadsEnabled() bool {
+	if !t.FileTransferEnabled() {
+		return false
+	}
+	return t.Spec.ProxyConfig.FileTransfer == nil || !t.Spec.ProxyConfig.FileTransfer.DisableDownloads
+}
+
+// GetMaxFileTransferSize returns the largest file in bytes that may be transferred to or
+// from desktops booted from the template. Zero means there is no limit.
+func (t *Template) GetMaxFileTransferSize() int64 {
+	if t.Spec.ProxyConfig == nil || t.Spec.ProxyConfig.FileTransfer == nil || t.Spec.ProxyConfig.FileTransfer.MaxFileSize == "" {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(t.Spec.ProxyConfig.FileTransfer.MaxFileSize)
+	if err != nil {
+		return 0
+	}
+	return quantity.Value()
+}
+
+// GetAllowedFileContentTypes returns the content types of files that may be transferred
+// to or from desktops booted from the template. An empty list allows all types.
+func (t *Template) GetAllowedFileContentTypes() []string {
+	if t.Spec.ProxyConfig == nil || t.Spec.ProxyConfig.FileTransfer == nil {
+		return nil
+	}
+	return t.Spec.ProxyConfig.FileTransfer.AllowedContentTypes
+}
+
+// FileContentTypeAllowed returns true if files of the given content type may be
+// transferred to or from desktops booted from the template. Parameters on the content
+// type, such as the charset, are ignored.
+func (t *Template) FileContentTypeAllowed(contentType string) bool {
+	allowed := t.GetAllowedFileContentTypes()
+	if len(allowed) == 0 {
+		return true
+	}
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*" || pattern == "*/*" || pattern == contentType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FirewallIsEnabled returns true if connections to desktops booted from this template are
+// restricted by a firewall policy.
+func (t *Template) FirewallIsEnabled() bool {
+	return t.Spec.Firewall != nil
+}
+
+// GetFirewallPorts returns the additional ports applications inside desktops booted from
+// this template may listen on.
+func (t *Template) GetFirewallPorts() []FirewallPort {
+	if t.Spec.Firewall == nil {
+		return nil
+	}
+	return t.Spec.Firewall.Ports
+}
+
+// GetFirewallSessionPorts returns the ports inside desktops booted from this template that
+// other sessions may connect to.
+func (t *Template) GetFirewallSessionPorts() []FirewallPort {
+	ports := make([]FirewallPort, 0)
+	for _, port := range t.GetFirewallPorts() {
+		if port.AllowFromSessions {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// GetProtocol returns the protocol for this port, defaulting to TCP.
+func (f *FirewallPort) GetProtocol() corev1.Protocol {
+	if f.Protocol != "" {
+		return f.Protocol
+	}
+	return corev1.ProtocolTCP
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GPUsEnabled returns true if GPUs are attached to desktops booted from this template.
+func (t *Template) GPUsEnabled() bool { return t.Spec.GPUs != nil }
+
+// GetGPUVendor returns the vendor of the GPUs attached to desktops.
+func (t *Template) GetGPUVendor() GPUVendor {
+	if !t.GPUsEnabled() || t.Spec.GPUs.Vendor == "" {
+		return GPUVendorNVIDIA
+	}
+	return t.Spec.GPUs.Vendor
+}
+
+// GetGPUCount returns the number of GPUs, or MIG instances, attached to each desktop.
+func (t *Template) GetGPUCount() int64 {
+	if !t.GPUsEnabled() {
+		return 0
+	}
+	if t.Spec.GPUs.Count <= 0 {
+		return 1
+	}
+	return t.Spec.GPUs.Count
+}
+
+// GetGPUMIGProfile returns the MIG profile requested for desktops, if any.
+func (t *Template) GetGPUMIGProfile() string {
+	if !t.GPUsEnabled() {
+		return ""
+	}
+	return t.Spec.GPUs.MIGProfile
+}
+
+// GetGPUResourceName returns the extended resource the vendor's device plugin advertises
+// the requested GPUs as.
+func (t *Template) GetGPUResourceName() corev1.ResourceName {
+	switch t.GetGPUVendor() {
+	case GPUVendorAMD:
+		return "amd.com/gpu"
+	case GPUVendorIntel:
+		return "gpu.intel.com/i915"
+	default:
+		if profile := t.GetGPUMIGProfile(); profile != "" {
+			return corev1.ResourceName(fmt.Sprintf("nvidia.com/mig-%s", profile))
+		}
+		return "nvidia.com/gpu"
+	}
+}
+
+// GetGPUNodeSelector returns the node selector matching nodes with the requested GPUs.
+func (t *Template) GetGPUNodeSelector() map[string]string {
+	if !t.GPUsEnabled() {
+		return nil
+	}
+	if len(t.Spec.GPUs.NodeSelector) > 0 {
+		return t.Spec.GPUs.NodeSelector
+	}
+	if t.GetGPUVendor() == GPUVendorNVIDIA {
+		return map[string]string{"nvidia.com/gpu.present": "true"}
+	}
+	return nil
+}
+
+// GetGPUTolerations returns the tolerations for taints placed on GPU nodes.
+func (t *Template) GetGPUTolerations() []corev1.Toleration {
+	if !t.GPUsEnabled() {
+		return nil
+	}
+	return t.Spec.GPUs.Tolerations
+}
+
+// GetGPURuntimeClassName returns the runtime class exposing GPUs to containers, if any.
+func (t *Template) GetGPURuntimeClassName() *string {
+	if !t.GPUsEnabled() || t.Spec.GPUs.RuntimeClassName == "" {
+		return nil
+	}
+	return &t.Spec.GPUs.RuntimeClassName
+}
+
+// applyGPUResources returns a copy of the given resource requirements limited to the GPUs
+// requested by this template. Extended resources default their requests to their limits.
+func (t *Template) applyGPUResources(reqs corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if !t.GPUsEnabled() {
+		return reqs
+	}
+	out := *reqs.DeepCopy()
+	if out.Limits == nil {
+		out.Limits = make(corev1.ResourceList)
+	}
+	out.Limits[t.GetGPUResourceName()] = *resource.NewQuantity(t.GetGPUCount(), resource.DecimalSI)
+	return out
+}
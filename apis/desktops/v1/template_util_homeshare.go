@@ -0,0 +1,167 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HomeShareIsEnabled returns true if this template mounts home shares from an external
+// file server.
+func (t *Template) HomeShareIsEnabled() bool {
+	return t.Spec.HomeShare != nil && t.Spec.HomeShare.Server != ""
+}
+
+// HomeShareReplacesHome returns true if the home share is mounted as the user's home
+// directory.
+func (t *Template) HomeShareReplacesHome() bool {
+	return t.HomeShareIsEnabled() && t.Spec.HomeShare.MountPath == ""
+}
+
+// HomeShareNeedsCredentials returns true if the home share is mounted with credentials
+// stored for the user.
+func (t *Template) HomeShareNeedsCredentials() bool {
+	return t.HomeShareIsEnabled() && t.Spec.HomeShare.Type == HomeShareSMB
+}
+
+// HomeShareUsesKerberos returns true if the home share authenticates with a Kerberos
+// credential cache.
+func (t *Template) HomeShareUsesKerberos() bool {
+	return t.HomeShareNeedsCredentials() && t.Spec.HomeShare.Kerberos
+}
+
+// ValidateHomeShareUser checks that the given username is safe to substitute into the
+// path of the home share. Names that could escape the share directory are rejected.
+func (t *Template) ValidateHomeShareUser(username string) error {
+	if !t.HomeShareIsEnabled() || !strings.Contains(t.Spec.HomeShare.Path, "$(USER)") {
+		return nil
+	}
+	if strings.ContainsAny(username, `/\`) || strings.Contains(username, "..") {
+		return fmt.Errorf("%q cannot be used in the path of a home share", username)
+	}
+	return nil
+}
+
+// GetHomeSharePath returns the path of the share on the server for the given user. An
+// empty string is returned if the username fails ValidateHomeShareUser.
+func (t *Template) GetHomeSharePath(username string) string {
+	if !t.HomeShareIsEnabled() || t.ValidateHomeShareUser(username) != nil {
+		return ""
+	}
+	return strings.Replace(t.Spec.HomeShare.Path, "$(USER)", username, -1)
+}
+
+// GetHomeShareMountPath returns where the home share is mounted inside the desktop.
+func (t *Template) GetHomeShareMountPath(username string) string {
+	if t.HomeShareReplacesHome() {
+		return fmt.Sprintf(v1.DesktopHomeFmt, username)
+	}
+	return t.Spec.HomeShare.MountPath
+}
+
+// GetHomeShareDriver returns the CSI driver used to mount the home share, or an empty
+// string if the in-tree NFS plugin is used.
+func (t *Template) GetHomeShareDriver() string {
+	if !t.HomeShareIsEnabled() {
+		return ""
+	}
+	if t.Spec.HomeShare.Driver != "" {
+		return t.Spec.HomeShare.Driver
+	}
+	if t.Spec.HomeShare.Type == HomeShareSMB {
+		return "smb.csi.k8s.io"
+	}
+	return ""
+}
+
+// GetHomeShareMountOptions returns the mount options passed to the CSI driver. SMB shares
+// default to being owned by the desktop user.
+func (t *Template) GetHomeShareMountOptions() []string {
+	if !t.HomeShareIsEnabled() {
+		return nil
+	}
+	opts := make([]string, 0)
+	if t.Spec.HomeShare.Type == HomeShareSMB && len(t.Spec.HomeShare.MountOptions) == 0 {
+		opts = append(opts,
+			fmt.Sprintf("uid=%d", v1.DefaultUser),
+			fmt.Sprintf("gid=%d", v1.DefaultUser),
+			"dir_mode=0700",
+			"file_mode=0600",
+		)
+	}
+	opts = append(opts, t.Spec.HomeShare.MountOptions...)
+	if t.HomeShareUsesKerberos() {
+		opts = append(opts, "sec=krb5", fmt.Sprintf("cruid=%d", v1.DefaultUser))
+	}
+	return opts
+}
+
+// GetHomeShareKerberosCacheKey returns the key in the home share secret holding the Kerberos
+// credential cache for the desktop user.
+func (t *Template) GetHomeShareKerberosCacheKey() string {
+	return fmt.Sprintf("krb5cc_%d", v1.DefaultUser)
+}
+
+// GetHomeShareVolumeSource returns the volume source for mounting the home share of the
+// given desktop. Credentials are read by the CSI driver from the kVDI namespace, so they
+// are never exposed in the namespace of the session.
+func (t *Template) GetHomeShareVolumeSource(cluster *appv1.VDICluster, desktop *Session) corev1.VolumeSource {
+	share := t.Spec.HomeShare
+	path := t.GetHomeSharePath(desktop.GetUser())
+	driver := t.GetHomeShareDriver()
+
+	if driver == "" {
+		return corev1.VolumeSource{
+			NFS: &corev1.NFSVolumeSource{
+				Server:   share.Server,
+				Path:     path,
+				ReadOnly: share.ReadOnly,
+			},
+		}
+	}
+
+	attrs := map[string]string{}
+	if share.Type == HomeShareSMB {
+		attrs["source"] = fmt.Sprintf("//%s/%s", share.Server, strings.TrimPrefix(path, "/"))
+	} else {
+		attrs["server"] = share.Server
+		attrs["share"] = path
+	}
+	if opts := t.GetHomeShareMountOptions(); len(opts) > 0 {
+		attrs["mountOptions"] = strings.Join(opts, ",")
+	}
+
+	src := &corev1.CSIVolumeSource{
+		Driver:           driver,
+		ReadOnly:         &share.ReadOnly,
+		VolumeAttributes: attrs,
+	}
+	if t.HomeShareNeedsCredentials() {
+		attrs["secretName"] = desktop.GetHomeShareSecretName()
+		attrs["secretNamespace"] = cluster.GetCoreNamespace()
+	}
+	return corev1.VolumeSource{CSI: src}
+}
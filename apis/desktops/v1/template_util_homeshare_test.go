@@ -0,0 +1,80 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+func TestHomeSharePath(t *testing.T) {
+	tmpl := &Template{
+		Spec: TemplateSpec{
+			HomeShare: &HomeShareConfig{Server: "files.example.com", Path: "/homes/$(USER)"},
+		},
+	}
+	if path := tmpl.GetHomeSharePath("alice"); path != "/homes/alice" {
+		t.Error("Expected the username to be substituted, got:", path)
+	}
+	for _, username := range []string{"../alice", "alice/../../etc", "..", `domain\alice`, "a/b"} {
+		if err := tmpl.ValidateHomeShareUser(username); err == nil {
+			t.Errorf("Expected %q to be rejected", username)
+		}
+		if path := tmpl.GetHomeSharePath(username); path != "" {
+			t.Errorf("Expected no path for %q, got %q", username, path)
+		}
+	}
+
+	// usernames are not checked when they are not part of the path
+	tmpl.Spec.HomeShare.Path = "/shared"
+	if err := tmpl.ValidateHomeShareUser("../alice"); err != nil {
+		t.Error("Expected no error for a static share path, got:", err)
+	}
+}
+
+func TestHomeShareCredentials(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &Template{
+		Spec: TemplateSpec{
+			HomeShare: &HomeShareConfig{Type: HomeShareSMB, Server: "files.example.com", Path: "homes/$(USER)"},
+		},
+	}
+	desktop := &Session{}
+	desktop.Name = "desktop"
+	desktop.Namespace = "users"
+	desktop.Spec.User = "alice"
+
+	src := tmpl.GetHomeShareVolumeSource(cluster, desktop)
+	if src.CSI == nil || src.CSI.Driver != "smb.csi.k8s.io" {
+		t.Fatal("Expected an SMB CSI volume, got:", src)
+	}
+	attrs := src.CSI.VolumeAttributes
+	if attrs["source"] != "//files.example.com/homes/alice" {
+		t.Error("Expected the user's share as the source, got:", attrs["source"])
+	}
+	if attrs["secretName"] != "users-desktop-home-share" || attrs["secretNamespace"] != cluster.GetCoreNamespace() {
+		t.Error("Expected credentials to be read from the kvdi namespace, got:", attrs)
+	}
+	if src.CSI.NodePublishSecretRef != nil {
+		t.Error("Expected no secret reference in the session namespace")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// IDEIsEnabled returns true if desktops booted from this template expose an IDE server.
+func (t *Template) IDEIsEnabled() bool {
+	return t.Spec.IDEConfig != nil
+}
+
+// GetIDEType returns the type of IDE server exposed by this template.
+func (t *Template) GetIDEType() IDEType {
+	if t.Spec.IDEConfig != nil && t.Spec.IDEConfig.Type != "" {
+		return t.Spec.IDEConfig.Type
+	}
+	return IDECodeServer
+}
+
+// GetIDEPort returns the port the IDE server listens on, or 0 if not enabled.
+func (t *Template) GetIDEPort() int32 {
+	if t.Spec.IDEConfig == nil {
+		return 0
+	}
+	if t.Spec.IDEConfig.Port != 0 {
+		return t.Spec.IDEConfig.Port
+	}
+	return 8080
+}
+
+// GetIDEAddress returns the address the kvdi-proxy reaches the IDE server at.
+func (t *Template) GetIDEAddress() string {
+	return fmt.Sprintf("127.0.0.1:%d", t.GetIDEPort())
+}
+
+// IDEIsSidecar returns true if the IDE server runs in a sidecar container instead of
+// inside the desktop image.
+func (t *Template) IDEIsSidecar() bool {
+	return t.Spec.IDEConfig != nil && t.Spec.IDEConfig.Image != ""
+}
+
+// IDEIsHeadless returns true if only the IDE server should run, without the graphical
+// desktop container.
+func (t *Template) IDEIsHeadless() bool {
+	return t.IDEIsSidecar() && t.Spec.IDEConfig.Headless
+}
+
+// GetIDEPullPolicy returns the pull policy for the IDE container.
+func (t *Template) GetIDEPullPolicy() corev1.PullPolicy {
+	if t.Spec.IDEConfig != nil && t.Spec.IDEConfig.ImagePullPolicy != "" {
+		return t.Spec.IDEConfig.ImagePullPolicy
+	}
+	return corev1.PullIfNotPresent
+}
+
+// GetIDEResources returns the resources for the IDE container.
+func (t *Template) GetIDEResources() corev1.ResourceRequirements {
+	if t.Spec.IDEConfig != nil {
+		return t.Spec.IDEConfig.Resources
+	}
+	return corev1.ResourceRequirements{}
+}
+
+// GetIDEArgs returns the arguments to pass to the IDE sidecar. Authentication is disabled
+// on the server itself since it only listens on the loopback interface of the pod, and
+// all requests are brokered through the kVDI API and the kvdi-proxy.
+func (t *Template) GetIDEArgs(home string) []string {
+	return []string{
+		"--bind-addr", t.GetIDEAddress(),
+		"--auth", "none",
+		home,
+	}
+}
+
+// GetIDEContainer returns the IDE sidecar for a desktop session.
+func (t *Template) GetIDEContainer(instance *Session) corev1.Container {
+	home := fmt.Sprintf(v1.DesktopHomeFmt, instance.GetUser())
+	return corev1.Container{
+		Name:            "ide",
+		Image:           t.Spec.IDEConfig.Image,
+		ImagePullPolicy: t.GetIDEPullPolicy(),
+		Args:            t.GetIDEArgs(home),
+		Resources:       t.GetIDEResources(),
+		Env: []corev1.EnvVar{
+			{
+				Name:  v1.HomeEnvVar,
+				Value: home,
+			},
+			{
+				Name:  v1.IDEPortEnvVar,
+				Value: strconv.Itoa(int(t.GetIDEPort())),
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      v1.HomeVolume,
+				MountPath: home,
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:    &v1.DefaultUser,
+			RunAsNonRoot: &v1.True,
+		},
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+// GetIdlePolicy returns the policy for terminating idle sessions booted from this
+// template. The template's own policy takes precedence over the cluster's.
+func (t *Template) GetIdlePolicy(cluster *appv1.VDICluster) *appv1.IdlePolicy {
+	if t.Spec.IdlePolicy != nil {
+		return t.Spec.IdlePolicy
+	}
+	return cluster.GetIdlePolicy()
+}
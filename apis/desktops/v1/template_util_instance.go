@@ -23,25 +23,29 @@ package v1
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // GetDesktopContainer returns the container for the desktop.
 func (t *Template) GetDesktopContainer(cluster *appv1.VDICluster, instance *Session, envSecret string) corev1.Container {
 	c := corev1.Container{
 		Name:            "desktop",
-		Image:           t.GetDesktopImage(),
+		Image:           t.GetDesktopImageForArchitecture(t.GetSessionArchitecture(instance)),
 		ImagePullPolicy: t.GetDesktopPullPolicy(),
 		VolumeMounts:    t.GetDesktopVolumeMounts(cluster, instance),
 		VolumeDevices:   t.GetDesktopVolumeDevices(),
 		SecurityContext: t.GetDesktopContainerSecurityContext(),
-		Env:             t.GetDesktopEnvVars(instance),
-		Lifecycle:       t.GetDesktopLifecycle(),
-		Resources:       t.GetDesktopResources(),
+		Env:             t.GetDesktopEnvVars(cluster, instance),
+		Lifecycle:       t.GetDesktopLifecycle(instance),
+		Resources:       t.applyGPUResources(t.GetDesktopResources()),
+		Ports:           t.GetExposedPorts(),
 	}
 	if envSecret != "" {
 		c.EnvFrom = []corev1.EnvFromSource{
@@ -107,6 +111,99 @@ func (t *Template) GetDesktopImage() string {
 	return ""
 }
 
+// GetSysctls returns the sysctls to set on pods booted from this template.
+func (t *Template) GetSysctls() []corev1.Sysctl {
+	if t.Spec.DesktopConfig != nil && len(t.Spec.DesktopConfig.Sysctls) > 0 {
+		return t.Spec.DesktopConfig.Sysctls
+	}
+	return nil
+}
+
+// GetUlimits returns the process resource limits for desktops booted from this template.
+func (t *Template) GetUlimits() *Ulimits {
+	if t.Spec.DesktopConfig != nil {
+		return t.Spec.DesktopConfig.Ulimits
+	}
+	return nil
+}
+
+// GetShmSize returns the size of the memory-backed /dev/shm volume for desktops booted from
+// this template. Nil is returned if the host's /dev/shm should be used. Templates with a
+// size that does not parse are rejected by ValidateProcessLimits.
+func (t *Template) GetShmSize() *resource.Quantity {
+	if t.Spec.DesktopConfig != nil && t.Spec.DesktopConfig.ShmSize != "" {
+		size, err := resource.ParseQuantity(t.Spec.DesktopConfig.ShmSize)
+		if err != nil {
+			return nil
+		}
+		return &size
+	}
+	return nil
+}
+
+// SafeSysctls are the namespaced sysctls Kubernetes allows on every kubelet. Templates
+// may only set sysctls from this list.
+var SafeSysctls = map[string]struct{}{
+	"kernel.shm_rmid_forced":       {},
+	"net.ipv4.ip_local_port_range": {},
+	"net.ipv4.tcp_syncookies":      {},
+	"net.ipv4.ping_group_range":    {},
+}
+
+// ValidateProcessLimits checks the sysctls, ulimits, and shm size on this template,
+// returning an error describing everything that is invalid.
+func (t *Template) ValidateProcessLimits() error {
+	if t.Spec.DesktopConfig == nil {
+		return nil
+	}
+	cfg := t.Spec.DesktopConfig
+	invalid := make([]string, 0)
+	for _, sysctl := range cfg.Sysctls {
+		if _, ok := SafeSysctls[sysctl.Name]; !ok {
+			invalid = append(invalid, fmt.Sprintf("sysctl %s is not in the safe set", sysctl.Name))
+		}
+	}
+	if cfg.Ulimits != nil {
+		if cfg.Ulimits.NoFile < 0 {
+			invalid = append(invalid, fmt.Sprintf("nofile %d", cfg.Ulimits.NoFile))
+		}
+		if cfg.Ulimits.NProc < 0 {
+			invalid = append(invalid, fmt.Sprintf("nproc %d", cfg.Ulimits.NProc))
+		}
+	}
+	if cfg.ShmSize != "" {
+		size, err := resource.ParseQuantity(cfg.ShmSize)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("shmSize %q: %s", cfg.ShmSize, err.Error()))
+		} else if size.Sign() <= 0 {
+			invalid = append(invalid, fmt.Sprintf("shmSize %q must be positive", cfg.ShmSize))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Template %s has invalid process limits: %s", t.GetName(), strings.Join(invalid, ", "))
+}
+
+// GetExposedPorts returns the ports in the desktop container to expose to the session owner.
+func (t *Template) GetExposedPorts() []corev1.ContainerPort {
+	if t.Spec.DesktopConfig != nil && len(t.Spec.DesktopConfig.Ports) > 0 {
+		return t.Spec.DesktopConfig.Ports
+	}
+	return nil
+}
+
+// HasExposedPort returns true if the given port is exposed by desktops booted from
+// this template.
+func (t *Template) HasExposedPort(port int32) bool {
+	for _, p := range t.GetExposedPorts() {
+		if p.ContainerPort == port {
+			return true
+		}
+	}
+	return false
+}
+
 // GetDesktopPullPolicy returns the image pull policy for this template.
 func (t *Template) GetDesktopPullPolicy() corev1.PullPolicy {
 	if t.Spec.DesktopConfig != nil && t.Spec.DesktopConfig.ImagePullPolicy != "" {
@@ -124,7 +221,7 @@ func (t *Template) GetDesktopResources() corev1.ResourceRequirements {
 }
 
 // GetDesktopEnvVars returns the environment variables for a desktop pod.
-func (t *Template) GetDesktopEnvVars(desktop *Session) []corev1.EnvVar {
+func (t *Template) GetDesktopEnvVars(cluster *appv1.VDICluster, desktop *Session) []corev1.EnvVar {
 	envVars := []corev1.EnvVar{
 		{
 			Name:  v1.UserEnvVar,
@@ -145,12 +242,83 @@ func (t *Template) GetDesktopEnvVars(desktop *Session) []corev1.EnvVar {
 			Value: t.GetDisplaySocketAddress(),
 		})
 	}
+	if !t.DisplayIsVNC() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.DisplayProtocolEnvVar,
+			Value: string(t.GetDisplayProtocol()),
+		})
+	}
+	if args := t.GetVNCClipboardArgs(); args != "" {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.VNCClipboardArgsEnvVar,
+			Value: args,
+		})
+	}
+	if t.MultiMonitorEnabled() {
+		maxRes := t.GetMaxMonitorResolution()
+		envVars = append(envVars,
+			corev1.EnvVar{
+				Name:  v1.MaxMonitorsEnvVar,
+				Value: strconv.Itoa(int(t.GetMaxMonitors())),
+			},
+			corev1.EnvVar{
+				Name:  v1.MaxMonitorResolutionEnvVar,
+				Value: maxRes.String(),
+			},
+		)
+	}
 	if t.RootEnabled() {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  v1.EnableRootEnvVar,
 			Value: "true",
 		})
 	}
+	if t.DomainJoinIsEnabled(cluster) {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.DomainRealmEnvVar,
+			Value: cluster.GetDomainRealm(),
+		})
+	}
+	if cluster.SSHGatewayEnabled() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: v1.SSHTrustedCAEnvVar,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: desktop.GetName(),
+					},
+					Key:      v1.SSHCAPublicKeyKey,
+					Optional: &v1.True,
+				},
+			},
+		})
+	}
+	if t.IDEIsEnabled() && !t.IDEIsSidecar() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.IDEPortEnvVar,
+			Value: strconv.Itoa(int(t.GetIDEPort())),
+		})
+	}
+	if ulimits := t.GetUlimits(); ulimits != nil {
+		if ulimits.NoFile > 0 {
+			envVars = append(envVars, corev1.EnvVar{
+				Name:  v1.UlimitNoFileEnvVar,
+				Value: strconv.FormatInt(ulimits.NoFile, 10),
+			})
+		}
+		if ulimits.NProc > 0 {
+			envVars = append(envVars, corev1.EnvVar{
+				Name:  v1.UlimitNProcEnvVar,
+				Value: strconv.FormatInt(ulimits.NProc, 10),
+			})
+		}
+	}
+	if t.AgentIsEnabled() {
+		envVars = append(envVars, t.GetAgentEnvVars(cluster, desktop)...)
+	}
+	if t.KubeCredentialsEnabled() {
+		envVars = append(envVars, t.GetKubeCredentialsEnvVars()...)
+	}
 	if static := t.GetStaticEnvVars(); static != nil {
 		envVars = append(envVars, static...)
 	}
@@ -186,17 +354,79 @@ func (t *Template) GetDesktopContainerSecurityContext() *corev1.SecurityContext
 	}
 }
 
+// GetCleanupPolicy returns the end-of-session cleanup policy for this template.
+func (t *Template) GetCleanupPolicy() *CleanupPolicy {
+	if t.Spec.DesktopConfig != nil {
+		return t.Spec.DesktopConfig.Cleanup
+	}
+	return nil
+}
+
+// GetCleanupScript returns a shell script that purges the paths in the cleanup policy
+// from the given user's $HOME. An empty string is returned if there is nothing to clean.
+func (t *Template) GetCleanupScript(username string) string {
+	policy := t.GetCleanupPolicy()
+	if policy == nil {
+		return ""
+	}
+	home := fmt.Sprintf(v1.DesktopHomeFmt, username)
+	cmds := make([]string, 0)
+	for _, path := range policy.Paths {
+		if full, ok := resolveHomePath(home, path); ok {
+			cmds = append(cmds, fmt.Sprintf("rm -rf %s", shellQuote(full)))
+		}
+	}
+	for _, dir := range policy.CacheDirs {
+		if full, ok := resolveHomePath(home, dir); ok {
+			cmds = append(cmds, fmt.Sprintf("find %s -mindepth 1 -delete", shellQuote(full)))
+		}
+	}
+	if len(cmds) == 0 {
+		return ""
+	}
+	return strings.Join(cmds, " 2>/dev/null ; ") + " 2>/dev/null"
+}
+
 // GetDesktopLifecycle returns the lifecycle actions for a desktop container booted from
 // this template.
-func (t *Template) GetDesktopLifecycle() *corev1.Lifecycle {
-	if t.GetInitSystem() == InitSystemd {
-		return &corev1.Lifecycle{
-			PreStop: &corev1.Handler{
-				Exec: &corev1.ExecAction{
-					Command: []string{"kill", "-s", "SIGRTMIN+3", "1"},
+func (t *Template) GetDesktopLifecycle(instance *Session) *corev1.Lifecycle {
+	cleanup := t.GetCleanupScript(instance.GetUser())
+	if cleanup == "" {
+		if t.GetInitSystem() == InitSystemd {
+			return &corev1.Lifecycle{
+				PreStop: &corev1.Handler{
+					Exec: &corev1.ExecAction{
+						Command: []string{"kill", "-s", "SIGRTMIN+3", "1"},
+					},
 				},
-			},
+			}
 		}
+		return &corev1.Lifecycle{}
+	}
+	// Purge files before signaling systemd to shut down the container
+	if t.GetInitSystem() == InitSystemd {
+		cleanup += " ; kill -s SIGRTMIN+3 1"
+	}
+	return &corev1.Lifecycle{
+		PreStop: &corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", cleanup},
+			},
+		},
 	}
-	return &corev1.Lifecycle{}
+}
+
+// resolveHomePath joins the given path to the home directory, returning false if the
+// result would fall outside of it.
+func resolveHomePath(home, path string) (string, bool) {
+	full := filepath.Join(home, filepath.Clean("/"+path))
+	if full == home || !strings.HasPrefix(full, home+"/") {
+		return "", false
+	}
+	return full, true
+}
+
+// shellQuote wraps the given string in single quotes for use in a shell command.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
 }
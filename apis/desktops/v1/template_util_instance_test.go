@@ -0,0 +1,159 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+func TestShellQuote(t *testing.T) {
+	tcs := []struct {
+		in, out string
+	}{
+		{"plain", `'plain'`},
+		{"with space", `'with space'`},
+		{"it's", `'it'"'"'s'`},
+		{"$(rm -rf /)", `'$(rm -rf /)'`},
+		{"'", `''"'"''`},
+	}
+	for _, tc := range tcs {
+		if got := shellQuote(tc.in); got != tc.out {
+			t.Errorf("shellQuote(%q): expected %s, got %s", tc.in, tc.out, got)
+		}
+	}
+}
+
+func TestResolveHomePath(t *testing.T) {
+	tcs := []struct {
+		path string
+		full string
+		ok   bool
+	}{
+		{".cache", "/home/user/.cache", true},
+		{".config/app/state", "/home/user/.config/app/state", true},
+		{"/etc/passwd", "/home/user/etc/passwd", true},
+		{"../other/.ssh", "/home/user/other/.ssh", true},
+		{"a/../../b", "/home/user/b", true},
+		{"..", "", false},
+		{"../..", "", false},
+		{"/", "", false},
+		{".", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tcs {
+		full, ok := resolveHomePath("/home/user", tc.path)
+		if ok != tc.ok || full != tc.full {
+			t.Errorf("resolveHomePath(%q): expected (%q, %v), got (%q, %v)", tc.path, tc.full, tc.ok, full, ok)
+		}
+	}
+}
+
+func TestGetCleanupScript(t *testing.T) {
+	tcs := []struct {
+		name   string
+		policy *CleanupPolicy
+		script string
+	}{
+		{
+			name:   "no policy",
+			policy: nil,
+			script: "",
+		},
+		{
+			name:   "paths and cache dirs",
+			policy: &CleanupPolicy{Paths: []string{".bash_history"}, CacheDirs: []string{".cache"}},
+			script: `rm -rf '/home/user/.bash_history' 2>/dev/null ; find '/home/user/.cache' -mindepth 1 -delete 2>/dev/null`,
+		},
+		{
+			name:   "parent traversal is contained",
+			policy: &CleanupPolicy{Paths: []string{"../../etc"}},
+			script: `rm -rf '/home/user/etc' 2>/dev/null`,
+		},
+		{
+			name:   "home itself is skipped",
+			policy: &CleanupPolicy{Paths: []string{"..", "/", "."}, CacheDirs: []string{"../"}},
+			script: "",
+		},
+		{
+			name:   "absolute paths are rooted in home",
+			policy: &CleanupPolicy{CacheDirs: []string{"/var/tmp"}},
+			script: `find '/home/user/var/tmp' -mindepth 1 -delete 2>/dev/null`,
+		},
+		{
+			name:   "embedded quotes",
+			policy: &CleanupPolicy{Paths: []string{"it's $(reboot)"}},
+			script: `rm -rf '/home/user/it'"'"'s $(reboot)' 2>/dev/null`,
+		},
+	}
+	for _, tc := range tcs {
+		tmpl := &Template{Spec: TemplateSpec{DesktopConfig: &DesktopConfig{Cleanup: tc.policy}}}
+		if got := tmpl.GetCleanupScript("user"); got != tc.script {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.script, got)
+		}
+	}
+}
+
+func TestGetShmVolume(t *testing.T) {
+	tmpl := &Template{Spec: TemplateSpec{DesktopConfig: &DesktopConfig{}}}
+	if vol := tmpl.GetShmVolume(); vol.HostPath == nil {
+		t.Error("Expected the host's /dev/shm when no size is configured, got:", vol)
+	}
+	tmpl.Spec.DesktopConfig.ShmSize = "2Gi"
+	vol := tmpl.GetShmVolume()
+	if vol.EmptyDir == nil || vol.EmptyDir.SizeLimit == nil || vol.EmptyDir.SizeLimit.String() != "2Gi" {
+		t.Error("Expected a 2Gi memory-backed volume, got:", vol)
+	}
+	if err := tmpl.ValidateProcessLimits(); err != nil {
+		t.Error("Expected a valid shm size, got:", err)
+	}
+	tmpl.Spec.DesktopConfig.ShmSize = "2 gigs"
+	if err := tmpl.ValidateProcessLimits(); err == nil {
+		t.Error("Expected an unparseable shm size to fail validation")
+	}
+}
+
+func TestIDE(t *testing.T) {
+	tmpl := &Template{Spec: TemplateSpec{IDEConfig: &IDEConfig{Image: "codercom/code-server"}}}
+	args := tmpl.GetIDEArgs("/home/user")
+	if len(args) < 2 || args[0] != "--bind-addr" || args[1] != "127.0.0.1:8080" {
+		t.Error("Expected code-server to only listen on the loopback interface, got:", args)
+	}
+	container := tmpl.GetIDEContainer(&Session{Spec: SessionSpec{User: "user"}})
+	if len(container.Ports) != 0 {
+		t.Error("Expected the IDE container to not declare any ports, got:", container.Ports)
+	}
+	if tmpl.HasExposedPort(8080) {
+		t.Error("Expected the IDE port to not be reachable through the session port proxy")
+	}
+	proxy := tmpl.GetDesktopProxyContainer(&appv1.VDICluster{}, &Session{})
+	var found bool
+	for idx, arg := range proxy.Args {
+		if arg == "--ide-addr" && idx+1 < len(proxy.Args) && proxy.Args[idx+1] == "127.0.0.1:8080" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected the kvdi-proxy to be pointed at the IDE server, got:", proxy.Args)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// KubeCredentialsEnabled returns true if kubeconfigs can be issued to desktops booted from
+// this template. Static hosts have no pod for credentials to be bound to.
+func (t *Template) KubeCredentialsEnabled() bool {
+	if t.Spec.KubeCredentialsConfig != nil && !t.IsStaticHostTemplate() {
+		return t.Spec.KubeCredentialsConfig.Enabled
+	}
+	return false
+}
+
+// GetKubeCredentialsExpiration returns how long kubeconfigs issued to desktops booted from
+// this template are valid for.
+func (t *Template) GetKubeCredentialsExpiration() time.Duration {
+	if t.Spec.KubeCredentialsConfig != nil {
+		if dur, err := time.ParseDuration(t.Spec.KubeCredentialsConfig.Expiration); err == nil && dur > 0 {
+			if dur < v1.MinKubeCredentialsExpiration {
+				return v1.MinKubeCredentialsExpiration
+			}
+			return dur
+		}
+	}
+	return v1.DefaultKubeCredentialsExpiration
+}
+
+// GetKubeCredentialsAPIServer returns the API server address to write to issued kubeconfigs.
+// An empty string means the address the manager uses should be used.
+func (t *Template) GetKubeCredentialsAPIServer() string {
+	if t.Spec.KubeCredentialsConfig != nil {
+		return t.Spec.KubeCredentialsConfig.APIServer
+	}
+	return ""
+}
+
+// GetAutomountServiceAccountToken returns whether the service account token should be
+// mounted into desktop pods. A nil value leaves the decision to the service account.
+func (t *Template) GetAutomountServiceAccountToken() *bool {
+	if t.KubeCredentialsEnabled() {
+		return &v1.False
+	}
+	return nil
+}
+
+// GetKubeconfigPath returns the path session kubeconfigs are written to inside desktops.
+func (t *Template) GetKubeconfigPath() string {
+	return fmt.Sprintf(v1.KubeconfigPathFmt, v1.DefaultUser)
+}
+
+// GetKubeCredentialsEnvVars returns the environment variables pointing Kubernetes clients
+// and the kvdi-agent at the session kubeconfig. The agent keeps the file renewed.
+func (t *Template) GetKubeCredentialsEnvVars() []corev1.EnvVar {
+	envVars := []corev1.EnvVar{
+		{
+			Name:  v1.KubeconfigEnvVar,
+			Value: t.GetKubeconfigPath(),
+		},
+	}
+	if t.AgentIsEnabled() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.AgentKubeconfigEnvVar,
+			Value: t.GetKubeconfigPath(),
+		})
+	}
+	return envVars
+}
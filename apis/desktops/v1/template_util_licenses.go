@@ -0,0 +1,40 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// RequiresLicenses returns true if sessions of this template require seats from license
+// pools.
+func (t *Template) RequiresLicenses() bool {
+	return len(t.Spec.Licenses) > 0
+}
+
+// GetLicenseRequirements returns the seats each session of this template requires from
+// license pools.
+func (t *Template) GetLicenseRequirements() []LicenseRequirement {
+	return t.Spec.Licenses
+}
+
+// GetSeats returns the number of seats required from the pool.
+func (r *LicenseRequirement) GetSeats() int {
+	if r.Seats <= 0 {
+		return 1
+	}
+	return r.Seats
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"time"
+)
+
+// MaintenanceIsEnabled returns true if this template defines a maintenance window.
+func (t *Template) MaintenanceIsEnabled() bool {
+	return t.Spec.Maintenance != nil
+}
+
+// GetMaintenanceDays returns the days of the week the maintenance window opens on.
+// Unrecognized values are ignored, and an empty result means every day.
+func (t *Template) GetMaintenanceDays() []time.Weekday {
+	return t.Spec.Maintenance.GetDays()
+}
+
+// GetMaintenanceStartTime returns the hour and minute (UTC) that the maintenance window
+// opens at. Invalid values fall back to midnight.
+func (t *Template) GetMaintenanceStartTime() (hour, minute int) {
+	return t.Spec.Maintenance.GetStartTime()
+}
+
+// GetMaintenanceDuration returns how long the maintenance window stays open.
+func (t *Template) GetMaintenanceDuration() time.Duration {
+	return t.Spec.Maintenance.GetDuration()
+}
+
+// GetMaintenanceWindow returns the start and end of the maintenance window that is
+// either open at the given time or opens next. Zero times are returned if the template
+// does not define a maintenance window.
+func (t *Template) GetMaintenanceWindow(now time.Time) (start, end time.Time) {
+	return t.Spec.Maintenance.GetWindow(now)
+}
+
+// InMaintenanceWindow returns true if the maintenance window for this template is open
+// at the given time.
+func (t *Template) InMaintenanceWindow(now time.Time) bool {
+	return t.Spec.Maintenance.IsOpen(now)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+// GetMaxMonitors returns the maximum number of monitors desktops booted from this
+// template may span, including the primary one.
+func (t *Template) GetMaxMonitors() int32 {
+	if cfg := t.getMonitorsConfig(); cfg != nil && cfg.Max > 1 {
+		return cfg.Max
+	}
+	return 1
+}
+
+// GetMaxMonitorResolution returns the maximum resolution of each monitor of desktops
+// booted from this template.
+func (t *Template) GetMaxMonitorResolution() DisplayResolution {
+	res := DisplayResolution{Width: v1.DefaultMaxMonitorWidth, Height: v1.DefaultMaxMonitorHeight}
+	if cfg := t.getMonitorsConfig(); cfg != nil && cfg.MaxResolution != nil {
+		if cfg.MaxResolution.Width > 0 {
+			res.Width = cfg.MaxResolution.Width
+		}
+		if cfg.MaxResolution.Height > 0 {
+			res.Height = cfg.MaxResolution.Height
+		}
+	}
+	return res
+}
+
+// MultiMonitorEnabled returns true if desktops booted from this template may span more
+// than one monitor. Only VNC displays served from inside the desktop's container support
+// multiple monitors.
+func (t *Template) MultiMonitorEnabled() bool {
+	return t.GetMaxMonitors() > 1 && t.DisplayIsVNC() && !t.IsStaticHostTemplate() && !t.IsQEMUTemplate()
+}
+
+func (t *Template) getMonitorsConfig() *MonitorsConfig {
+	if t.Spec.DisplayConfig != nil {
+		return t.Spec.DisplayConfig.Monitors
+	}
+	return nil
+}
+
+// DisplayResizeEnabled returns true if clients may change the resolution and DPI of the
+// displays of desktops booted from this template. Like multiple monitors, resizing is only
+// supported for VNC displays served from inside the desktop's container.
+func (t *Template) DisplayResizeEnabled() bool {
+	cfg := t.getResizeConfig()
+	return cfg != nil && cfg.Enabled && t.DisplayIsVNC() && !t.IsStaticHostTemplate() && !t.IsQEMUTemplate()
+}
+
+// GetResizeXDisplay returns the X display the proxy resizes for desktops booted from this
+// template.
+func (t *Template) GetResizeXDisplay() string {
+	if cfg := t.getResizeConfig(); cfg != nil && cfg.XDisplay != "" {
+		return cfg.XDisplay
+	}
+	return v1.DefaultResizeXDisplay
+}
+
+func (t *Template) getResizeConfig() *DisplayResizeConfig {
+	if t.Spec.DisplayConfig != nil {
+		return t.Spec.DisplayConfig.Resize
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"path/filepath"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetPlugins returns the sidecar plugins declared for desktops booted from this template.
+func (t *Template) GetPlugins() []SidecarPlugin {
+	if t.Spec.ProxyConfig != nil {
+		return t.Spec.ProxyConfig.Plugins
+	}
+	return nil
+}
+
+// GetPluginNames returns the names of the sidecar plugins declared for desktops booted from
+// this template.
+func (t *Template) GetPluginNames() []string {
+	plugins := t.GetPlugins()
+	names := make([]string, len(plugins))
+	for i, plugin := range plugins {
+		names[i] = plugin.Name
+	}
+	return names
+}
+
+// HasPlugin returns true if a sidecar plugin with the given name is declared for desktops
+// booted from this template.
+func (t *Template) HasPlugin(name string) bool {
+	for _, plugin := range t.GetPlugins() {
+		if plugin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginSocketPath returns the path of the socket the plugin with the given name listens on.
+func pluginSocketPath(name string) string {
+	return filepath.Join(v1.DesktopPluginsDir, name+".sock")
+}
+
+// GetPluginContainers returns the containers for the sidecar plugins that declare an
+// image. Each one has the run volume mounted so the kvdi-proxy can reach its socket.
+func (t *Template) GetPluginContainers() []corev1.Container {
+	containers := make([]corev1.Container, 0)
+	for _, plugin := range t.GetPlugins() {
+		if plugin.Image == "" {
+			continue
+		}
+		pullPolicy := plugin.ImagePullPolicy
+		if pullPolicy == "" {
+			pullPolicy = corev1.PullIfNotPresent
+		}
+		env := append([]corev1.EnvVar{
+			{
+				Name:  v1.PluginSocketEnvVar,
+				Value: pluginSocketPath(plugin.Name),
+			},
+		}, plugin.Env...)
+		containers = append(containers, corev1.Container{
+			Name:            "plugin-" + plugin.Name,
+			Image:           plugin.Image,
+			ImagePullPolicy: pullPolicy,
+			Env:             env,
+			Resources:       plugin.Resources,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      v1.RunVolume,
+					MountPath: v1.DesktopRunPath,
+				},
+			},
+		})
+	}
+	return containers
+}
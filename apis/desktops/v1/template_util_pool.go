@@ -0,0 +1,30 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// CanPool returns true if desktops from this template can be booted in a DesktopPool
+// ahead of a launch. Templates that need user input, a ticket, or user credentials to
+// boot a desktop cannot be pooled.
+func (t *Template) CanPool() bool {
+	return !t.HasLaunchPrompts() &&
+		!t.RequiresTicket() &&
+		!t.HasManagedEnvSecret() &&
+		!t.HomeShareIsEnabled()
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// HasLaunchPrompts returns true if users must answer prompts before launching sessions
+// from this template.
+func (t *Template) HasLaunchPrompts() bool {
+	return len(t.Spec.Prompts) > 0
+}
+
+// GetLaunchPrompts returns the prompts users must answer before launching sessions from
+// this template.
+func (t *Template) GetLaunchPrompts() []LaunchPrompt {
+	return t.Spec.Prompts
+}
+
+// GetType returns the type of input the prompt asks for, defaulting to text.
+func (p *LaunchPrompt) GetType() LaunchPromptType {
+	if p.Type != "" {
+		return p.Type
+	}
+	return LaunchPromptText
+}
+
+// GetLabel returns the question or statement displayed for the prompt, defaulting to its
+// name.
+func (p *LaunchPrompt) GetLabel() string {
+	if p.Label != "" {
+		return p.Label
+	}
+	return p.Name
+}
+
+// IsRequired returns true if a response to the prompt is required to launch.
+func (p *LaunchPrompt) IsRequired() bool {
+	return !p.Optional || p.GetType() == LaunchPromptAcknowledge
+}
@@ -27,7 +27,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/version"
 
@@ -43,6 +45,42 @@ func (t *Template) FileTransferEnabled() bool {
 	return false
 }
 
+// ThumbnailsEnabled returns true if the proxy should periodically capture thumbnails
+// of the display for desktops booted from the template.
+func (t *Template) ThumbnailsEnabled() bool {
+	if t.Spec.ProxyConfig != nil && t.Spec.ProxyConfig.Thumbnails != nil {
+		return t.Spec.ProxyConfig.Thumbnails.Enabled
+	}
+	return false
+}
+
+// ReverseTunnelEnabled returns true if the proxy should dial out to the app over a
+// reverse tunnel for desktops booted from the template.
+func (t *Template) ReverseTunnelEnabled() bool {
+	if t.Spec.ProxyConfig != nil {
+		return t.Spec.ProxyConfig.ReverseTunnel
+	}
+	return false
+}
+
+// GetThumbnailInterval returns how often thumbnails of the display should be captured.
+func (t *Template) GetThumbnailInterval() time.Duration {
+	if t.Spec.ProxyConfig != nil && t.Spec.ProxyConfig.Thumbnails != nil {
+		if dur, err := time.ParseDuration(t.Spec.ProxyConfig.Thumbnails.Interval); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultThumbnailInterval
+}
+
+// GetThumbnailMaxWidth returns the maximum width of captured thumbnails.
+func (t *Template) GetThumbnailMaxWidth() int32 {
+	if t.Spec.ProxyConfig != nil && t.Spec.ProxyConfig.Thumbnails != nil && t.Spec.ProxyConfig.Thumbnails.MaxWidth > 0 {
+		return t.Spec.ProxyConfig.Thumbnails.MaxWidth
+	}
+	return v1.DefaultThumbnailMaxWidth
+}
+
 // GetPulseServer returns the pulse server to give to the proxy for handling audio streams.
 func (t *Template) GetPulseServer() string {
 	if t.Spec.ProxyConfig != nil && t.Spec.ProxyConfig.PulseServer != "" {
@@ -76,9 +114,15 @@ func (t *Template) GetDisplaySocketAddress() string {
 
 // GetDisplaySocketURI returns the display socket URI to pass to the nonvnc-proxy.
 func (t *Template) GetDisplaySocketURI() string {
+	if t.IsStaticHostTemplate() && !t.StaticHostUsesBridge() {
+		return "tcp://" + t.GetStaticHostAddress()
+	}
 	if t.Spec.ProxyConfig != nil && t.Spec.ProxyConfig.SocketAddr != "" {
 		return t.Spec.ProxyConfig.SocketAddr
 	}
+	if t.DisplayIsRDP() {
+		return fmt.Sprintf("tcp://127.0.0.1:%d", v1.GuacdPort)
+	}
 	return v1.DefaultDisplaySocketAddr
 }
 
@@ -122,7 +166,7 @@ func (t *Template) GetProxyResources() corev1.ResourceRequirements {
 }
 
 // GetDesktopProxyContainer returns the configuration for the kvdi-proxy sidecar.
-func (t *Template) GetDesktopProxyContainer() corev1.Container {
+func (t *Template) GetDesktopProxyContainer(cluster *appv1.VDICluster, instance *Session) corev1.Container {
 	proxyVolMounts := []corev1.VolumeMount{
 		{
 			Name:      t.GetTmpVolume(),
@@ -154,29 +198,108 @@ func (t *Template) GetDesktopProxyContainer() corev1.Container {
 			MountPath: filepath.Dir(t.GetPulseServer()),
 		})
 	}
-	if t.FileTransferEnabled() {
+	if t.GetStaticHostPasswordFile() != "" {
+		proxyVolMounts = append(proxyVolMounts, corev1.VolumeMount{
+			Name:      v1.StaticHostVolume,
+			MountPath: v1.StaticHostPath,
+			ReadOnly:  true,
+		})
+	}
+	if t.FileTransferEnabled() || cluster.GetUserdataQuota() > 0 {
 		proxyVolMounts = append(proxyVolMounts, corev1.VolumeMount{
 			Name:      v1.HomeVolume,
 			MountPath: v1.DesktopHomeMntPath,
 		})
 	}
+	args := []string{
+		"--display-addr", t.GetDisplaySocketURI(),
+		"--display-protocol", string(t.GetDisplayProtocol()),
+		"--user-id", strconv.Itoa(int(v1.DefaultUser)),
+		"--pulse-server", t.GetPulseServer(),
+	}
+	if t.AudioEnabled() {
+		args = append(args,
+			"--audio-bitrate", strconv.Itoa(int(t.GetAudioBitrate())),
+			"--audio-sample-rate", strconv.Itoa(int(t.GetAudioSampleRate())),
+		)
+		if !t.MicrophoneEnabled() {
+			args = append(args, "--disable-microphone")
+		}
+	} else {
+		args = append(args, "--disable-audio")
+	}
+	if quota := cluster.GetUserdataQuota(); quota > 0 {
+		args = append(args,
+			"--home-quota", strconv.FormatInt(quota, 10),
+			"--home-quota-warning", strconv.Itoa(cluster.GetUserdataQuotaWarningThreshold()),
+		)
+	}
+	if t.ThumbnailsEnabled() && t.DisplayIsVNC() {
+		args = append(args,
+			"--thumbnail-interval", t.GetThumbnailInterval().String(),
+			"--thumbnail-width", strconv.Itoa(int(t.GetThumbnailMaxWidth())),
+		)
+	}
+	if t.MultiMonitorEnabled() {
+		args = append(args, "--max-monitors", strconv.Itoa(int(t.GetMaxMonitors())))
+	}
+	if t.DisplayResizeEnabled() {
+		args = append(args, "--resize-display", t.GetResizeXDisplay())
+	}
+	if t.MultiMonitorEnabled() || t.DisplayResizeEnabled() {
+		maxRes := t.GetMaxMonitorResolution()
+		args = append(args, "--max-monitor-resolution", maxRes.String())
+	}
+	if plugins := t.GetPluginNames(); len(plugins) > 0 {
+		args = append(args, "--plugins", strings.Join(plugins, ","))
+	}
+	if t.IDEIsEnabled() {
+		args = append(args, "--ide-addr", t.GetIDEAddress())
+	}
+	if passwordFile := t.GetStaticHostPasswordFile(); passwordFile != "" {
+		args = append(args, "--display-password-file", passwordFile)
+	}
+	var env []corev1.EnvVar
+	if t.DisplayIsRDP() {
+		args = append(args,
+			"--rdp-port", strconv.Itoa(int(t.GetRDPPort())),
+			"--rdp-security", string(t.GetRDPSecurity()),
+		)
+		if t.RDPIgnoreCert() {
+			args = append(args, "--rdp-ignore-cert")
+		}
+		if t.RDPNeedsCredentials() {
+			env = t.GetRDPCredentialsEnv(instance)
+		}
+	}
+	if t.ReverseTunnelEnabled() && cluster.TunnelsEnabled() {
+		args = append(args,
+			"--tunnel-address", cluster.GetTunnelAddress(),
+			"--tunnel-session", fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName()),
+		)
+	}
+	ports := []corev1.ContainerPort{
+		{
+			Name:          "web",
+			ContainerPort: v1.WebPort,
+		},
+	}
+	if cluster.CreateProxyServiceMonitor() {
+		args = append(args, "--metrics-addr", fmt.Sprintf(":%d", v1.ProxyMetricsPort))
+		ports = append(ports, corev1.ContainerPort{
+			Name:          "metrics",
+			ContainerPort: v1.ProxyMetricsPort,
+		})
+	}
 	c := corev1.Container{
 		Name:            "kvdi-proxy",
 		Image:           t.GetKVDIVNCProxyImage(),
 		ImagePullPolicy: t.GetProxyPullPolicy(),
-		Args: []string{
-			"--display-addr", t.GetDisplaySocketURI(),
-			"--user-id", strconv.Itoa(int(v1.DefaultUser)),
-			"--pulse-server", t.GetPulseServer(),
-		},
-		Ports: []corev1.ContainerPort{
-			{
-				Name:          "web",
-				ContainerPort: v1.WebPort,
-			},
-		},
-		VolumeMounts: proxyVolMounts,
-		Resources:    t.GetProxyResources(),
+		Args:            args,
+		Env:             env,
+		Ports:           ports,
+		VolumeMounts:    proxyVolMounts,
+		Resources:       t.GetProxyResources(),
 	}
 
 	return c
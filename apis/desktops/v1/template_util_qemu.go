@@ -31,7 +31,9 @@ import (
 )
 
 // IsQEMUTemplate returns true if this template is for a QEMU vm.
-func (t *Template) IsQEMUTemplate() bool { return t.Spec.QEMUConfig != nil }
+func (t *Template) IsQEMUTemplate() bool {
+	return t.Spec.QEMUConfig != nil && !t.IsStaticHostTemplate()
+}
 
 // QEMUUseCSI returns if the CSI driver should be used for mounting disk images.
 func (t *Template) QEMUUseCSI() bool {
@@ -43,10 +45,7 @@ func (t *Template) QEMUUseCSI() bool {
 
 // QEMUUseSPICE returns true if the template is configured to use the SPICE protocol.
 func (t *Template) QEMUUseSPICE() bool {
-	if t.Spec.QEMUConfig != nil {
-		return t.Spec.QEMUConfig.SPICE
-	}
-	return false
+	return t.Spec.QEMUConfig != nil && t.GetDisplayProtocol() == DisplayProtocolSPICE
 }
 
 // GetQEMURunnerResources returns the resources for the qemu runner.
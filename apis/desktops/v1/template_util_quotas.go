@@ -0,0 +1,53 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GetSessionResources returns the CPU and memory a session of this template is counted as
+// using against role quotas. This is the sum of the limits of each container in the session,
+// or their requests when no limit is set.
+func (t *Template) GetSessionResources() (cpu, memory resource.Quantity) {
+	for _, reqs := range []corev1.ResourceRequirements{
+		t.GetDesktopResources(),
+		t.GetProxyResources(),
+		t.GetDindResources(),
+		t.GetIDEResources(),
+		t.GetQEMURunnerResources(),
+		t.GetStaticHostBridgeResources(),
+	} {
+		cpu.Add(resourceLimitOrRequest(reqs, corev1.ResourceCPU))
+		memory.Add(resourceLimitOrRequest(reqs, corev1.ResourceMemory))
+	}
+	return
+}
+
+func resourceLimitOrRequest(reqs corev1.ResourceRequirements, name corev1.ResourceName) resource.Quantity {
+	if q, ok := reqs.Limits[name]; ok {
+		return q
+	}
+	if q, ok := reqs.Requests[name]; ok {
+		return q
+	}
+	return resource.Quantity{}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+// IsDraft returns true if this template was saved from a desktop session and has not yet
+// been approved.
+func (t *Template) IsDraft() bool {
+	return t.Spec.Review != nil && t.GetReviewState() != ReviewApproved
+}
+
+// GetReviewState returns the state of the review of this template, or an empty string if
+// the template is not subject to review.
+func (t *Template) GetReviewState() ReviewState {
+	if t.Spec.Review == nil {
+		return ""
+	}
+	if t.Spec.Review.State == "" {
+		return ReviewPending
+	}
+	return t.Spec.Review.State
+}
+
+// GetReviewAuthor returns the user that saved this template from a desktop session, if any.
+func (t *Template) GetReviewAuthor() string {
+	if t.Spec.Review == nil {
+		return ""
+	}
+	return t.Spec.Review.Author
+}
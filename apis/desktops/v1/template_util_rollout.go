@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// RolloutIsEnabled returns true if this template defines a canary revision.
+func (t *Template) RolloutIsEnabled() bool {
+	return t.Spec.Rollout != nil && t.Spec.Rollout.Canary != nil
+}
+
+// GetCanaryRevision returns a short hash identifying the current canary revision, or an
+// empty string if there is none.
+func (t *Template) GetCanaryRevision() string {
+	if !t.RolloutIsEnabled() {
+		return ""
+	}
+	out, err := json.Marshal(t.Spec.Rollout.Canary)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// GetCanaryPercentage returns the percentage of new sessions to launch on the canary
+// revision.
+func (t *Template) GetCanaryPercentage() int32 {
+	if t.Spec.Rollout == nil || t.Spec.Rollout.Percentage == 0 {
+		return 10
+	}
+	if t.Spec.Rollout.Percentage > 100 {
+		return 100
+	}
+	return t.Spec.Rollout.Percentage
+}
+
+// GetCanarySuccessThreshold returns the number of successful canary launches required
+// to promote the revision.
+func (t *Template) GetCanarySuccessThreshold() int32 {
+	if t.Spec.Rollout != nil && t.Spec.Rollout.SuccessThreshold > 0 {
+		return t.Spec.Rollout.SuccessThreshold
+	}
+	return 5
+}
+
+// GetCanaryFailureThreshold returns the number of failed canary launches that trigger
+// a rollback of the revision.
+func (t *Template) GetCanaryFailureThreshold() int32 {
+	if t.Spec.Rollout != nil && t.Spec.Rollout.FailureThreshold > 0 {
+		return t.Spec.Rollout.FailureThreshold
+	}
+	return 2
+}
+
+// GetRolloutStatus returns the status of the current canary revision. A new progressing
+// status is returned if the recorded status is for a different revision.
+func (t *Template) GetRolloutStatus() *RolloutStatus {
+	rev := t.GetCanaryRevision()
+	if t.Status.Rollout != nil && t.Status.Rollout.Revision == rev {
+		return t.Status.Rollout.DeepCopy()
+	}
+	return &RolloutStatus{Revision: rev, Phase: RolloutProgressing}
+}
+
+// CanaryIsActive returns true if new sessions may still be launched on the canary revision.
+func (t *Template) CanaryIsActive() bool {
+	return t.RolloutIsEnabled() && t.GetRolloutStatus().Phase == RolloutProgressing
+}
+
+// SelectRevision returns the canary revision to launch a new session on, or an empty
+// string for the stable revision. The roll is a uniformly distributed value in [0, 100)
+// that is compared against the canary percentage.
+func (t *Template) SelectRevision(roll int32) string {
+	if t.CanaryIsActive() && roll < t.GetCanaryPercentage() {
+		return t.GetCanaryRevision()
+	}
+	return ""
+}
+
+// ForRevision returns the template as it applies to a session launched on the given canary
+// revision. If the revision is no longer current or was rolled back, the stable template is
+// returned.
+func (t *Template) ForRevision(rev string) *Template {
+	if rev == "" || rev != t.GetCanaryRevision() || t.GetRolloutStatus().Phase == RolloutRolledBack {
+		return t
+	}
+	out := t.DeepCopy()
+	out.Spec.DesktopConfig = t.Spec.Rollout.Canary.DeepCopy()
+	return out
+}
+
+// Promote replaces the template's desktop configuration with the canary revision.
+func (t *Template) Promote() {
+	if !t.RolloutIsEnabled() {
+		return
+	}
+	t.Spec.DesktopConfig = t.Spec.Rollout.Canary.DeepCopy()
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func newRolloutTemplate(percentage int32) *Template {
+	return &Template{
+		Spec: TemplateSpec{
+			DesktopConfig: &DesktopConfig{Image: "stable"},
+			Rollout: &RolloutStrategy{
+				Canary:     &DesktopConfig{Image: "canary"},
+				Percentage: percentage,
+			},
+		},
+	}
+}
+
+func TestSelectRevision(t *testing.T) {
+	tmpl := newRolloutTemplate(25)
+	rev := tmpl.GetCanaryRevision()
+	if rev == "" {
+		t.Fatal("Expected a canary revision")
+	}
+	tcs := map[int32]string{0: rev, 24: rev, 25: "", 99: ""}
+	for roll, expected := range tcs {
+		if got := tmpl.SelectRevision(roll); got != expected {
+			t.Errorf("Expected roll %d to select %q, got %q", roll, expected, got)
+		}
+	}
+
+	// the split follows the configured percentage
+	rng := rand.New(rand.NewSource(1))
+	var canaries int
+	for i := 0; i < 10000; i++ {
+		if tmpl.SelectRevision(rng.Int31n(100)) != "" {
+			canaries++
+		}
+	}
+	if canaries < 2300 || canaries > 2700 {
+		t.Errorf("Expected roughly 25%% of launches on the canary, got %d of 10000", canaries)
+	}
+
+	// the default percentage is 10 and the maximum is 100
+	if got := newRolloutTemplate(0).GetCanaryPercentage(); got != 10 {
+		t.Error("Expected a default canary percentage of 10, got:", got)
+	}
+	if got := newRolloutTemplate(150).SelectRevision(99); got == "" {
+		t.Error("Expected every launch on the canary with a percentage over 100")
+	}
+
+	// no launches are placed on a revision that finished rolling out
+	for _, phase := range []RolloutPhase{RolloutPromoted, RolloutRolledBack} {
+		tmpl.Status.Rollout = &RolloutStatus{Revision: rev, Phase: phase}
+		if got := tmpl.SelectRevision(0); got != "" {
+			t.Errorf("Expected no canary launches once %s, got %q", phase, got)
+		}
+	}
+
+	// changing the canary starts a new rollout
+	tmpl.Spec.Rollout.Canary.Image = "canary-2"
+	if newRev := tmpl.SelectRevision(0); newRev == "" || newRev == rev {
+		t.Errorf("Expected a new canary revision after changing the canary, got %q", newRev)
+	}
+
+	if got := (&Template{}).SelectRevision(0); got != "" {
+		t.Error("Expected no canary without a rollout strategy, got:", got)
+	}
+}
+
+func TestForRevision(t *testing.T) {
+	tmpl := newRolloutTemplate(50)
+	rev := tmpl.GetCanaryRevision()
+
+	if got := tmpl.ForRevision(rev); got.GetDesktopImage() != "canary" {
+		t.Error("Expected the canary configuration for the canary revision, got:", got.GetDesktopImage())
+	}
+	if tmpl.GetDesktopImage() != "stable" {
+		t.Error("Expected the template to be left unmodified")
+	}
+	if got := tmpl.ForRevision(""); got.GetDesktopImage() != "stable" {
+		t.Error("Expected the stable configuration for stable sessions, got:", got.GetDesktopImage())
+	}
+	if got := tmpl.ForRevision("stale"); got.GetDesktopImage() != "stable" {
+		t.Error("Expected the stable configuration for a stale revision, got:", got.GetDesktopImage())
+	}
+	tmpl.Status.Rollout = &RolloutStatus{Revision: rev, Phase: RolloutRolledBack}
+	if got := tmpl.ForRevision(rev); got.GetDesktopImage() != "stable" {
+		t.Error("Expected the stable configuration once the canary is rolled back, got:", got.GetDesktopImage())
+	}
+
+	tmpl.Promote()
+	if tmpl.GetDesktopImage() != "canary" {
+		t.Error("Expected promotion to replace the desktop configuration, got:", tmpl.GetDesktopImage())
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+// GetRiskLevel returns the risk level of this template, falling back to the default risk
+// level of the given cluster.
+func (t *Template) GetRiskLevel(cluster *appv1.VDICluster) RiskLevel {
+	if t.Spec.RiskLevel != "" {
+		return t.Spec.RiskLevel
+	}
+	return RiskLevel(cluster.GetDefaultRiskLevel())
+}
+
+// GetPodRuntimeClassName returns the runtime class for pods booted from this template. The
+// sandbox policy of the cluster takes precedence over any runtime class requested by the
+// template itself, and the GPU runtime over the one for image streaming.
+func (t *Template) GetPodRuntimeClassName(cluster *appv1.VDICluster) *string {
+	if class := cluster.GetSandboxRuntimeClass(string(t.GetRiskLevel(cluster))); class != "" {
+		return &class
+	}
+	if class := t.GetGPURuntimeClassName(); class != nil {
+		return class
+	}
+	return t.GetRuntimeClassName()
+}
+
+// RequiresTicket returns true if sessions of this template must be launched against a
+// ticket from the cluster's ticketing system.
+func (t *Template) RequiresTicket() bool { return t.Spec.RequireTicket }
@@ -0,0 +1,135 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"sort"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultPreemptionTaints are the node taints applied by common cloud tooling when a spot
+// node is about to be reclaimed.
+var defaultPreemptionTaints = []string{
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/rebalance-recommendation",
+	"cloud.google.com/impending-node-termination",
+}
+
+// SpotIsEnabled returns true if desktops booted from this template may run on spot nodes.
+func (t *Template) SpotIsEnabled() bool {
+	return t.Spec.Spot != nil && len(t.Spec.Spot.NodeSelector) > 0
+}
+
+// RunsOnSpot returns true if the desktop for the given session should be scheduled onto
+// spot nodes. Sessions that were already preempted run on on-demand capacity.
+func (t *Template) RunsOnSpot(instance *Session) bool {
+	return t.SpotIsEnabled() && !instance.Status.OnDemand
+}
+
+// GetPreemptionTaints returns the node taints that signal a spot node is about to be
+// reclaimed.
+func (t *Template) GetPreemptionTaints() []string {
+	if t.SpotIsEnabled() && len(t.Spec.Spot.PreemptionTaints) > 0 {
+		return t.Spec.Spot.PreemptionTaints
+	}
+	return defaultPreemptionTaints
+}
+
+// GetSpotWarningPeriod returns how long users are warned of a preemption before their
+// desktop is relaunched.
+func (t *Template) GetSpotWarningPeriod() time.Duration {
+	if t.SpotIsEnabled() && t.Spec.Spot.WarningPeriod != "" {
+		if dur, err := time.ParseDuration(t.Spec.Spot.WarningPeriod); err == nil && dur >= 0 {
+			return dur
+		}
+	}
+	return v1.DefaultSpotWarningPeriod
+}
+
+// GetSpotVolumeSnapshotClassName returns the snapshot class to snapshot userdata volumes
+// with before a preempted session is relaunched, if any.
+func (t *Template) GetSpotVolumeSnapshotClassName() string {
+	if !t.SpotIsEnabled() {
+		return ""
+	}
+	return t.Spec.Spot.VolumeSnapshotClassName
+}
+
+// GetPodNodeSelector returns the node selector for the desktop pod of the given session,
+// combining any image streaming and GPU requirements with the spot or on-demand node selector.
+func (t *Template) GetPodNodeSelector(instance *Session) map[string]string {
+	var extra map[string]string
+	if t.RunsOnSpot(instance) {
+		extra = t.Spec.Spot.NodeSelector
+	} else if t.SpotIsEnabled() {
+		extra = t.Spec.Spot.OnDemandNodeSelector
+	}
+	selector := t.GetNodeSelector()
+	gpus := t.GetGPUNodeSelector()
+	if len(extra) == 0 && len(gpus) == 0 {
+		return selector
+	}
+	out := make(map[string]string, len(selector)+len(gpus)+len(extra))
+	for _, m := range []map[string]string{selector, gpus, extra} {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// GetTolerations returns the tolerations for the desktop pod of the given session.
+func (t *Template) GetTolerations(instance *Session) []corev1.Toleration {
+	tolerations := t.GetGPUTolerations()
+	if !t.RunsOnSpot(instance) {
+		return tolerations
+	}
+	if len(tolerations) == 0 {
+		return t.Spec.Spot.Tolerations
+	}
+	return append(append([]corev1.Toleration{}, tolerations...), t.Spec.Spot.Tolerations...)
+}
+
+// getSpotExclusions returns node selector requirements keeping a preempted session off of
+// spot nodes when no on-demand node selector is configured.
+func (t *Template) getSpotExclusions(instance *Session) []corev1.NodeSelectorRequirement {
+	if !t.SpotIsEnabled() || t.RunsOnSpot(instance) || len(t.Spec.Spot.OnDemandNodeSelector) > 0 {
+		return nil
+	}
+	// sort the keys so the pod spec is stable across reconciles
+	keys := make([]string, 0, len(t.Spec.Spot.NodeSelector))
+	for k := range t.Spec.Spot.NodeSelector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	reqs := make([]corev1.NodeSelectorRequirement, len(keys))
+	for i, k := range keys {
+		reqs[i] = corev1.NodeSelectorRequirement{
+			Key:      k,
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   []string{t.Spec.Spot.NodeSelector[k]},
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSpotScheduling(t *testing.T) {
+	tmpl := &Template{}
+	sess := &Session{}
+	if tmpl.SpotIsEnabled() || tmpl.RunsOnSpot(sess) || tmpl.GetTolerations(sess) != nil {
+		t.Fatal("Expected spot to be disabled without a spot config")
+	}
+	if tmpl.GetSpotWarningPeriod() != v1.DefaultSpotWarningPeriod || len(tmpl.GetPreemptionTaints()) != len(defaultPreemptionTaints) {
+		t.Error("Expected the default warning period and preemption taints")
+	}
+
+	spotToleration := corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpExists}
+	tmpl.Spec.Spot = &SpotConfig{
+		NodeSelector:     map[string]string{"node-lifecycle": "spot", "pool": "desktops"},
+		Tolerations:      []corev1.Toleration{spotToleration},
+		PreemptionTaints: []string{"example.com/preempting"},
+		WarningPeriod:    "2m",
+	}
+	if !tmpl.RunsOnSpot(sess) {
+		t.Fatal("Expected the session to run on spot")
+	}
+	if tmpl.GetSpotWarningPeriod() != 2*time.Minute {
+		t.Error("Expected the configured warning period, got:", tmpl.GetSpotWarningPeriod())
+	}
+	if taints := tmpl.GetPreemptionTaints(); !reflect.DeepEqual(taints, []string{"example.com/preempting"}) {
+		t.Error("Expected the configured preemption taints, got:", taints)
+	}
+	if selector := tmpl.GetPodNodeSelector(sess); !reflect.DeepEqual(selector, tmpl.Spec.Spot.NodeSelector) {
+		t.Error("Expected the spot node selector, got:", selector)
+	}
+	if tolerations := tmpl.GetTolerations(sess); !reflect.DeepEqual(tolerations, []corev1.Toleration{spotToleration}) {
+		t.Error("Expected the spot tolerations, got:", tolerations)
+	}
+	if exclusions := tmpl.getSpotExclusions(sess); exclusions != nil {
+		t.Error("Expected no exclusions while running on spot, got:", exclusions)
+	}
+
+	// preempted sessions are kept off of spot nodes
+	sess.Status.OnDemand = true
+	if tmpl.RunsOnSpot(sess) || len(tmpl.GetTolerations(sess)) != 0 || len(tmpl.GetPodNodeSelector(sess)) != 0 {
+		t.Error("Expected a preempted session to not target spot nodes")
+	}
+	exclusions := tmpl.getSpotExclusions(sess)
+	if len(exclusions) != 2 || exclusions[0].Key != "node-lifecycle" || exclusions[1].Key != "pool" {
+		t.Fatal("Expected sorted exclusions for each spot label, got:", exclusions)
+	}
+	if exclusions[0].Operator != corev1.NodeSelectorOpNotIn || exclusions[0].Values[0] != "spot" {
+		t.Error("Expected the spot label to be excluded, got:", exclusions[0])
+	}
+
+	// an on-demand selector replaces the exclusions
+	tmpl.Spec.Spot.OnDemandNodeSelector = map[string]string{"node-lifecycle": "on-demand"}
+	if selector := tmpl.GetPodNodeSelector(sess); !reflect.DeepEqual(selector, tmpl.Spec.Spot.OnDemandNodeSelector) {
+		t.Error("Expected the on-demand node selector, got:", selector)
+	}
+	if exclusions := tmpl.getSpotExclusions(sess); exclusions != nil {
+		t.Error("Expected no exclusions with an on-demand selector, got:", exclusions)
+	}
+}
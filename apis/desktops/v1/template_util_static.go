@@ -0,0 +1,238 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys in the static host credentials
+const (
+	StaticHostUsernameKey = "username"
+	StaticHostPasswordKey = "password"
+	StaticHostDomainKey   = "domain"
+)
+
+// IsStaticHostTemplate returns true if sessions booted from this template connect to an
+// existing machine instead of running a desktop.
+func (t *Template) IsStaticHostTemplate() bool { return t.Spec.StaticHost != nil }
+
+// GetStaticHostAddress returns the address of the static host.
+func (t *Template) GetStaticHostAddress() string {
+	if t.Spec.StaticHost != nil {
+		return t.Spec.StaticHost.Address
+	}
+	return ""
+}
+
+// GetStaticHostProtocol returns the protocol spoken by the static host.
+func (t *Template) GetStaticHostProtocol() StaticHostProtocol {
+	if t.Spec.StaticHost != nil && t.Spec.StaticHost.Protocol != "" {
+		return t.Spec.StaticHost.Protocol
+	}
+	return StaticHostVNC
+}
+
+// StaticHostUsesBridge returns true if an RDP bridge needs to run next to the proxy.
+func (t *Template) StaticHostUsesBridge() bool {
+	return t.IsStaticHostTemplate() && t.GetStaticHostProtocol() == StaticHostRDP
+}
+
+// StaticHostNeedsCredentials returns true if credentials for the static host need to be
+// copied from the secrets backend for each session.
+func (t *Template) StaticHostNeedsCredentials() bool {
+	return t.GetStaticHostCredentialsSecret() != ""
+}
+
+// GetStaticHostCredentialsSecret returns the key in the secrets backend holding the
+// credentials for the static host.
+func (t *Template) GetStaticHostCredentialsSecret() string {
+	if t.Spec.StaticHost != nil {
+		return t.Spec.StaticHost.CredentialsSecret
+	}
+	return ""
+}
+
+// GetStaticHostPasswordFile returns the path where the proxy reads the VNC password of the
+// static host, or an empty string if the proxy does not need to authenticate.
+func (t *Template) GetStaticHostPasswordFile() string {
+	if !t.StaticHostNeedsCredentials() || t.StaticHostUsesBridge() {
+		return ""
+	}
+	return filepath.Join(v1.StaticHostPath, StaticHostPasswordKey)
+}
+
+// GetStaticHostBridgeImage returns the image to use for the RDP bridge.
+func (t *Template) GetStaticHostBridgeImage() string {
+	if t.Spec.StaticHost != nil && t.Spec.StaticHost.BridgeImage != "" {
+		return t.Spec.StaticHost.BridgeImage
+	}
+	return "ghcr.io/kvdi/rdp-bridge:latest"
+}
+
+// GetStaticHostBridgePullPolicy returns the pull policy for the RDP bridge image.
+func (t *Template) GetStaticHostBridgePullPolicy() corev1.PullPolicy {
+	if t.Spec.StaticHost != nil && t.Spec.StaticHost.BridgeImagePullPolicy != "" {
+		return t.Spec.StaticHost.BridgeImagePullPolicy
+	}
+	return corev1.PullIfNotPresent
+}
+
+// GetStaticHostBridgeResources returns the resource requirements for the RDP bridge.
+func (t *Template) GetStaticHostBridgeResources() corev1.ResourceRequirements {
+	if t.Spec.StaticHost != nil {
+		return t.Spec.StaticHost.BridgeResources
+	}
+	return corev1.ResourceRequirements{}
+}
+
+// StaticHostPowerOnEnabled returns true if the static host should be powered on when it
+// is not accepting connections.
+func (t *Template) StaticHostPowerOnEnabled() bool {
+	if !t.IsStaticHostTemplate() || t.Spec.StaticHost.PowerOn == nil {
+		return false
+	}
+	return t.GetStaticHostWakeOnLAN() != nil || t.GetStaticHostPowerOnWebhook() != nil
+}
+
+// GetStaticHostWakeOnLAN returns the wake-on-LAN configuration for the static host, if any.
+func (t *Template) GetStaticHostWakeOnLAN() *WakeOnLANConfig {
+	if t.Spec.StaticHost != nil && t.Spec.StaticHost.PowerOn != nil {
+		if wol := t.Spec.StaticHost.PowerOn.WakeOnLAN; wol != nil && wol.MACAddress != "" {
+			return wol
+		}
+	}
+	return nil
+}
+
+// GetStaticHostPowerOnWebhook returns the webhook that powers on the static host, if any.
+func (t *Template) GetStaticHostPowerOnWebhook() *PowerOnWebhookConfig {
+	if t.Spec.StaticHost != nil && t.Spec.StaticHost.PowerOn != nil {
+		if hook := t.Spec.StaticHost.PowerOn.Webhook; hook != nil && hook.URL != "" {
+			return hook
+		}
+	}
+	return nil
+}
+
+// GetStaticHostBootTimeout returns how long to wait for the static host to accept
+// connections after powering it on.
+func (t *Template) GetStaticHostBootTimeout() time.Duration {
+	if t.StaticHostPowerOnEnabled() && t.Spec.StaticHost.PowerOn.BootTimeout != "" {
+		if dur, err := time.ParseDuration(t.Spec.StaticHost.PowerOn.BootTimeout); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return v1.DefaultStaticHostBootTimeout
+}
+
+// GetBroadcastAddress returns the address to send magic packets to.
+func (w *WakeOnLANConfig) GetBroadcastAddress() string {
+	if w.BroadcastAddress != "" {
+		return w.BroadcastAddress
+	}
+	return "255.255.255.255:9"
+}
+
+// GetMethod returns the HTTP method to use for the webhook.
+func (p *PowerOnWebhookConfig) GetMethod() string {
+	if p.Method != "" {
+		return strings.ToUpper(p.Method)
+	}
+	return "POST"
+}
+
+// GetStaticHostVolume returns the volume containing the credentials for the static host.
+func (t *Template) GetStaticHostVolume(desktop *Session) corev1.Volume {
+	return corev1.Volume{
+		Name: v1.StaticHostVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: desktop.GetStaticHostSecretName(),
+			},
+		},
+	}
+}
+
+// GetStaticHostBridgeContainer returns the container bridging an RDP static host to the
+// display socket of the proxy.
+func (t *Template) GetStaticHostBridgeContainer(desktop *Session) corev1.Container {
+	env := []corev1.EnvVar{
+		{
+			Name:  v1.RDPAddressEnvVar,
+			Value: t.GetStaticHostAddress(),
+		},
+		{
+			Name:  v1.VNCSockEnvVar,
+			Value: t.GetDisplaySocketURI(),
+		},
+	}
+	if args := t.GetVNCClipboardArgs(); args != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  v1.VNCClipboardArgsEnvVar,
+			Value: args,
+		})
+	}
+	if t.StaticHostNeedsCredentials() {
+		for _, pair := range [][2]string{
+			{v1.RDPUsernameEnvVar, StaticHostUsernameKey},
+			{v1.RDPPasswordEnvVar, StaticHostPasswordKey},
+			{v1.RDPDomainEnvVar, StaticHostDomainKey},
+		} {
+			env = append(env, corev1.EnvVar{
+				Name: pair[0],
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: desktop.GetStaticHostSecretName()},
+						Key:                  pair[1],
+						Optional:             &v1.True,
+					},
+				},
+			})
+		}
+	}
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      t.GetTmpVolume(),
+			MountPath: v1.DesktopTmpPath,
+		},
+	}
+	if t.IsUNIXDisplaySocket() && !strings.HasPrefix(path.Dir(t.GetDisplaySocketAddress()), v1.DesktopTmpPath) {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      v1.VNCSockVolume,
+			MountPath: filepath.Dir(t.GetDisplaySocketAddress()),
+		})
+	}
+	return corev1.Container{
+		Name:            "rdp-bridge",
+		Image:           t.GetStaticHostBridgeImage(),
+		ImagePullPolicy: t.GetStaticHostBridgePullPolicy(),
+		Env:             env,
+		VolumeMounts:    mounts,
+		Resources:       t.GetStaticHostBridgeResources(),
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ImageStreamingIsEnabled returns true if images for this template should be lazily pulled.
+func (t *Template) ImageStreamingIsEnabled() bool {
+	return t.Spec.ImageStreaming != nil && t.Spec.ImageStreaming.Mode != ""
+}
+
+// GetImageStreamingMode returns the method used to lazily pull images for this template.
+func (t *Template) GetImageStreamingMode() ImageStreamingMode {
+	if !t.ImageStreamingIsEnabled() {
+		return ""
+	}
+	return t.Spec.ImageStreaming.Mode
+}
+
+// GetNodeSelector returns the node selector for pods booted from this template.
+func (t *Template) GetNodeSelector() map[string]string {
+	if !t.ImageStreamingIsEnabled() {
+		return nil
+	}
+	if len(t.Spec.ImageStreaming.NodeSelector) > 0 {
+		return t.Spec.ImageStreaming.NodeSelector
+	}
+	if mode := t.GetImageStreamingMode(); mode != ImageStreamingMirror {
+		return map[string]string{v1.ImageStreamingNodeLabel: string(mode)}
+	}
+	return nil
+}
+
+// GetRuntimeClassName returns the runtime class for pods booted from this template, if any.
+func (t *Template) GetRuntimeClassName() *string {
+	if !t.ImageStreamingIsEnabled() || t.Spec.ImageStreaming.RuntimeClassName == "" {
+		return nil
+	}
+	return &t.Spec.ImageStreaming.RuntimeClassName
+}
+
+// GetImageMirror returns the registry cache to pull images through, or an empty string
+// if images are pulled from their source registries. Templates that do not provide their
+// own mirror host use the cache deployed for the VDICluster, if it is enabled.
+func (t *Template) GetImageMirror(cluster *appv1.VDICluster) string {
+	if t.GetImageStreamingMode() != ImageStreamingMirror {
+		return ""
+	}
+	if mirror := strings.TrimSuffix(t.Spec.ImageStreaming.Mirror, "/"); mirror != "" {
+		return mirror
+	}
+	if cluster != nil {
+		return cluster.GetImageCacheMirror()
+	}
+	return ""
+}
+
+// applyImageMirror rewrites the images of the given containers to be pulled through
+// the configured registry cache. When using the cache deployed for the VDICluster, only
+// images from the registry it caches are rewritten.
+func (t *Template) applyImageMirror(cluster *appv1.VDICluster, containers []corev1.Container) []corev1.Container {
+	mirror := t.GetImageMirror(cluster)
+	if mirror == "" {
+		return containers
+	}
+	var upstream string
+	if t.Spec.ImageStreaming.Mirror == "" {
+		upstream = cluster.GetImageCacheRegistry()
+	}
+	for i := range containers {
+		if upstream != "" {
+			if registry, _ := splitImageRegistry(containers[i].Image); registry != upstream {
+				continue
+			}
+		}
+		containers[i].Image = mirrorImage(mirror, containers[i].Image)
+	}
+	return containers
+}
+
+// mirrorImage replaces the registry host of the given image reference with the mirror.
+func mirrorImage(mirror, image string) string {
+	if image == "" {
+		return image
+	}
+	_, repo := splitImageRegistry(image)
+	return mirror + "/" + repo
+}
+
+// splitImageRegistry splits an image reference into its registry host and repository.
+// References without a registry host are assumed to be from Docker Hub.
+func splitImageRegistry(image string) (registry, repo string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		return "docker.io", "library/" + image
+	}
+	return "docker.io", image
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMirrorImage(t *testing.T) {
+	tcs := map[string]string{
+		"":                              "",
+		"ubuntu":                        "mirror.local/library/ubuntu",
+		"ubuntu:22.04":                  "mirror.local/library/ubuntu:22.04",
+		"tinyzimmer/kvdi:app-latest":    "mirror.local/tinyzimmer/kvdi:app-latest",
+		"ghcr.io/kvdi/desktop:latest":   "mirror.local/kvdi/desktop:latest",
+		"registry:5000/desktop":         "mirror.local/desktop",
+		"localhost/desktop@sha256:abcd": "mirror.local/desktop@sha256:abcd",
+	}
+	for image, expected := range tcs {
+		if got := mirrorImage("mirror.local", image); got != expected {
+			t.Errorf("Expected %q to be mirrored as %q, got %q", image, expected, got)
+		}
+	}
+}
+
+func TestApplyImageMirror(t *testing.T) {
+	containers := func() []corev1.Container {
+		return []corev1.Container{{Image: "ubuntu"}, {Image: "ghcr.io/kvdi/proxy:latest"}}
+	}
+	cluster := &appv1.VDICluster{}
+	tmpl := &Template{Spec: TemplateSpec{ImageStreaming: &ImageStreamingConfig{Mode: ImageStreamingMirror}}}
+
+	// without a mirror host or cluster cache images are left alone
+	got := tmpl.applyImageMirror(cluster, containers())
+	if got[0].Image != "ubuntu" || got[1].Image != "ghcr.io/kvdi/proxy:latest" {
+		t.Error("Expected images to be unchanged, got:", got)
+	}
+
+	// the cluster cache only serves images from its upstream
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{ImageCache: &appv1.ImageCacheConfig{Enabled: true}}
+	got = tmpl.applyImageMirror(cluster, containers())
+	if got[0].Image != "localhost:30500/library/ubuntu" {
+		t.Error("Expected docker hub images to be pulled through the cluster cache, got:", got[0].Image)
+	}
+	if got[1].Image != "ghcr.io/kvdi/proxy:latest" {
+		t.Error("Expected images from other registries to be unchanged, got:", got[1].Image)
+	}
+
+	// a mirror on the template takes precedence and serves every image
+	tmpl.Spec.ImageStreaming.Mirror = "mirror.local/"
+	got = tmpl.applyImageMirror(cluster, containers())
+	if got[0].Image != "mirror.local/library/ubuntu" || got[1].Image != "mirror.local/kvdi/proxy:latest" {
+		t.Error("Expected all images to be pulled through the template mirror, got:", got)
+	}
+}
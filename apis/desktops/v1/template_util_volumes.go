@@ -31,8 +31,33 @@ import (
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
+// GetShmVolume returns the volume to mount at /dev/shm in desktop pods. When a size is
+// configured on the template a memory-backed volume is used, otherwise the host's /dev/shm.
+func (t *Template) GetShmVolume() corev1.Volume {
+	if size := t.GetShmSize(); size != nil {
+		return corev1.Volume{
+			Name: v1.ShmVolume,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					Medium:    corev1.StorageMediumMemory,
+					SizeLimit: size,
+				},
+			},
+		}
+	}
+	return corev1.Volume{
+		Name: v1.ShmVolume,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: v1.HostShmPath,
+			},
+		},
+	}
+}
+
 // GetVolumes returns the volumes to mount to desktop pods.
 func (t *Template) GetVolumes(cluster *appv1.VDICluster, desktop *Session, userdataVol string) []corev1.Volume {
 	// Common volumes all containers will need.
@@ -49,14 +74,7 @@ func (t *Template) GetVolumes(cluster *appv1.VDICluster, desktop *Session, userd
 				EmptyDir: &corev1.EmptyDirVolumeSource{},
 			},
 		},
-		{
-			Name: v1.ShmVolume,
-			VolumeSource: corev1.VolumeSource{
-				HostPath: &corev1.HostPathVolumeSource{
-					Path: v1.HostShmPath,
-				},
-			},
-		},
+		t.GetShmVolume(),
 		{
 			Name: v1.TLSVolume,
 			VolumeSource: corev1.VolumeSource{
@@ -94,8 +112,14 @@ func (t *Template) GetVolumes(cluster *appv1.VDICluster, desktop *Session, userd
 		})
 	}
 
-	// A PVC claim for the user if specified, otherwise use an EmptyDir.
-	if userdataVol != "" {
+	// The user's home share if it replaces their home directory, otherwise a PVC claim
+	// for the user if specified, otherwise use an EmptyDir.
+	if t.HomeShareReplacesHome() {
+		volumes = append(volumes, corev1.Volume{
+			Name:         v1.HomeVolume,
+			VolumeSource: t.GetHomeShareVolumeSource(cluster, desktop),
+		})
+	} else if userdataVol != "" {
 		volumes = append(volumes, corev1.Volume{
 			Name: v1.HomeVolume,
 			VolumeSource: corev1.VolumeSource{
@@ -105,14 +129,38 @@ func (t *Template) GetVolumes(cluster *appv1.VDICluster, desktop *Session, userd
 			},
 		})
 	} else {
+		home := &corev1.EmptyDirVolumeSource{}
+		// bound writes made from inside the desktop by the userdata quota
+		if quota := cluster.GetUserdataQuota(); quota > 0 {
+			home.SizeLimit = resource.NewQuantity(quota, resource.BinarySI)
+		}
 		volumes = append(volumes, corev1.Volume{
 			Name: v1.HomeVolume,
 			VolumeSource: corev1.VolumeSource{
-				EmptyDir: &corev1.EmptyDirVolumeSource{},
+				EmptyDir: home,
 			},
 		})
 	}
 
+	if t.HomeShareIsEnabled() && !t.HomeShareReplacesHome() {
+		volumes = append(volumes, corev1.Volume{
+			Name:         v1.HomeShareVolume,
+			VolumeSource: t.GetHomeShareVolumeSource(cluster, desktop),
+		})
+	}
+
+	if t.DomainJoinIsEnabled(cluster) {
+		volumes = append(volumes, t.GetDomainJoinVolume(desktop))
+	}
+
+	if t.StaticHostNeedsCredentials() {
+		volumes = append(volumes, t.GetStaticHostVolume(desktop))
+	}
+
+	if t.AgentIsEnabled() {
+		volumes = append(volumes, t.GetAgentVolume(desktop))
+	}
+
 	// If systemd we need to add a few more temp filesystems and bind mount
 	// /sys/fs/cgroup.
 	if t.GetInitSystem() == InitSystemd || t.IsQEMUTemplate() {
@@ -171,6 +219,37 @@ func (t *Template) GetVolumes(cluster *appv1.VDICluster, desktop *Session, userd
 		}...)
 	}
 
+	// Expose the boot admission annotation to the boot gate init container
+	if cluster.GetMaxConcurrentBootsPerNode() > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: v1.BootGateVolume,
+			VolumeSource: corev1.VolumeSource{
+				DownwardAPI: &corev1.DownwardAPIVolumeSource{
+					Items: []corev1.DownwardAPIVolumeFile{
+						{
+							Path: "admitted",
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath: fmt.Sprintf("metadata.annotations['%s']", v1.BootAdmittedAnnotation),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	for _, shared := range desktop.GetSharedVolumes() {
+		volumes = append(volumes, corev1.Volume{
+			Name: shared.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: shared.ClaimName,
+					ReadOnly:  shared.ReadOnly,
+				},
+			},
+		})
+	}
+
 	if len(t.Spec.Volumes) > 0 {
 		volumes = append(volumes, t.Spec.Volumes...)
 	}
@@ -198,6 +277,25 @@ func (t *Template) GetDesktopVolumeMounts(cluster *appv1.VDICluster, desktop *Se
 			MountPath: fmt.Sprintf(v1.DesktopHomeFmt, desktop.GetUser()),
 		},
 	}
+	if t.HomeShareIsEnabled() && !t.HomeShareReplacesHome() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      v1.HomeShareVolume,
+			MountPath: t.GetHomeShareMountPath(desktop.GetUser()),
+		})
+	}
+	if t.DomainJoinIsEnabled(cluster) {
+		mounts = append(mounts, t.GetDomainJoinVolumeMounts()...)
+	}
+	if t.AgentIsEnabled() {
+		mounts = append(mounts, t.GetAgentVolumeMount())
+	}
+	for _, shared := range desktop.GetSharedVolumes() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      shared.Name,
+			MountPath: shared.MountPath,
+			ReadOnly:  shared.ReadOnly,
+		})
+	}
 	if t.NeedsEmptyTmpVolume() {
 		mounts = append(mounts, corev1.VolumeMount{
 			Name:      v1.TmpVolume,
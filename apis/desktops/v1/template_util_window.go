@@ -0,0 +1,116 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"strings"
+	"time"
+)
+
+// GetDays returns the days of the week the window opens on. Unrecognized values are
+// ignored, and an empty result means every day.
+func (w *MaintenanceWindow) GetDays() []time.Weekday {
+	days := make([]time.Weekday, 0)
+	if w == nil {
+		return days
+	}
+	for _, day := range w.Days {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if len(day) < 3 {
+			continue
+		}
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if strings.HasPrefix(strings.ToLower(wd.String()), day[:3]) {
+				days = append(days, wd)
+				break
+			}
+		}
+	}
+	return days
+}
+
+// GetStartTime returns the hour and minute (UTC) that the window opens at. Invalid
+// values fall back to midnight.
+func (w *MaintenanceWindow) GetStartTime() (hour, minute int) {
+	if w == nil || w.StartTime == "" {
+		return 0, 0
+	}
+	start, err := time.Parse("15:04", w.StartTime)
+	if err != nil {
+		return 0, 0
+	}
+	return start.Hour(), start.Minute()
+}
+
+// GetDuration returns how long the window stays open.
+func (w *MaintenanceWindow) GetDuration() time.Duration {
+	if w != nil && w.Duration != "" {
+		if dur, err := time.ParseDuration(w.Duration); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// GetWindow returns the start and end of the window that is either open at the given
+// time or opens next. Zero times are returned if the window is nil.
+func (w *MaintenanceWindow) GetWindow(now time.Time) (start, end time.Time) {
+	if w == nil {
+		return
+	}
+	now = now.UTC()
+	hour, minute := w.GetStartTime()
+	dur := w.GetDuration()
+	days := w.GetDays()
+	// start from the previous day in case a window spans midnight
+	for offset := -1; offset <= 7; offset++ {
+		day := now.AddDate(0, 0, offset)
+		start = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.UTC)
+		if !windowDayAllowed(days, start.Weekday()) {
+			continue
+		}
+		end = start.Add(dur)
+		if end.After(now) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// IsOpen returns true if the window is open at the given time.
+func (w *MaintenanceWindow) IsOpen(now time.Time) bool {
+	start, end := w.GetWindow(now)
+	if start.IsZero() {
+		return false
+	}
+	return !now.Before(start) && now.Before(end)
+}
+
+func windowDayAllowed(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -26,10 +26,166 @@
 package v1
 
 import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	corev1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentAppStatus) DeepCopyInto(out *AgentAppStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentAppStatus.
+func (in *AgentAppStatus) DeepCopy() *AgentAppStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentAppStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentConfig) DeepCopyInto(out *AgentConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentConfig.
+func (in *AgentConfig) DeepCopy() *AgentConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentStatus) DeepCopyInto(out *AgentStatus) {
+	*out = *in
+	in.LastReportTime.DeepCopyInto(&out.LastReportTime)
+	if in.Apps != nil {
+		in, out := &in.Apps, &out.Apps
+		*out = make([]AgentAppStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequestedResolution != nil {
+		in, out := &in.RequestedResolution, &out.RequestedResolution
+		*out = new(DisplayResolution)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentStatus.
+func (in *AgentStatus) DeepCopy() *AgentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AudioConfig) DeepCopyInto(out *AudioConfig) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Microphone != nil {
+		in, out := &in.Microphone, &out.Microphone
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AudioConfig.
+func (in *AudioConfig) DeepCopy() *AudioConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AudioConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityReservation) DeepCopyInto(out *CapacityReservation) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(MaintenanceWindow)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityReservation.
+func (in *CapacityReservation) DeepCopy() *CapacityReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CleanupPolicy) DeepCopyInto(out *CleanupPolicy) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CacheDirs != nil {
+		in, out := &in.CacheDirs, &out.CacheDirs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CleanupPolicy.
+func (in *CleanupPolicy) DeepCopy() *CleanupPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CleanupPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeprecationConfig) DeepCopyInto(out *DeprecationConfig) {
+	*out = *in
+	if in.Sunset != nil {
+		in, out := &in.Sunset, &out.Sunset
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeprecationConfig.
+func (in *DeprecationConfig) DeepCopy() *DeprecationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeprecationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DesktopConfig) DeepCopyInto(out *DesktopConfig) {
 	*out = *in
@@ -70,74 +226,886 @@ func (in *DesktopConfig) DeepCopyInto(out *DesktopConfig) {
 		*out = new(corev1.PodDNSConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Cleanup != nil {
+		in, out := &in.Cleanup, &out.Cleanup
+		*out = new(CleanupPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = make([]corev1.Sysctl, len(*in))
+		copy(*out, *in)
+	}
+	if in.Ulimits != nil {
+		in, out := &in.Ulimits, &out.Ulimits
+		*out = new(Ulimits)
+		**out = **in
+	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]corev1.ContainerPort, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageVariants != nil {
+		in, out := &in.ImageVariants, &out.ImageVariants
+		*out = make([]ImageVariant, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopConfig.
+func (in *DesktopConfig) DeepCopy() *DesktopConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopPool) DeepCopyInto(out *DesktopPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopPool.
+func (in *DesktopPool) DeepCopy() *DesktopPool {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopPoolList) DeepCopyInto(out *DesktopPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DesktopPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopPoolList.
+func (in *DesktopPoolList) DeepCopy() *DesktopPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopPoolSpec) DeepCopyInto(out *DesktopPoolSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopPoolSpec.
+func (in *DesktopPoolSpec) DeepCopy() *DesktopPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopPoolStatus) DeepCopyInto(out *DesktopPoolStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopPoolStatus.
+func (in *DesktopPoolStatus) DeepCopy() *DesktopPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopSnapshot) DeepCopyInto(out *DesktopSnapshot) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopSnapshot.
+func (in *DesktopSnapshot) DeepCopy() *DesktopSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopSnapshot) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopSnapshotList) DeepCopyInto(out *DesktopSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DesktopSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopSnapshotList.
+func (in *DesktopSnapshotList) DeepCopy() *DesktopSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopSnapshotSpec) DeepCopyInto(out *DesktopSnapshotSpec) {
+	*out = *in
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]SnapshotVolume, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopSnapshotSpec.
+func (in *DesktopSnapshotSpec) DeepCopy() *DesktopSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisplayConfig) DeepCopyInto(out *DisplayConfig) {
+	*out = *in
+	if in.RDP != nil {
+		in, out := &in.RDP, &out.RDP
+		*out = new(RDPConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitors != nil {
+		in, out := &in.Monitors, &out.Monitors
+		*out = new(MonitorsConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resize != nil {
+		in, out := &in.Resize, &out.Resize
+		*out = new(DisplayResizeConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisplayConfig.
+func (in *DisplayConfig) DeepCopy() *DisplayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DisplayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisplayResizeConfig) DeepCopyInto(out *DisplayResizeConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisplayResizeConfig.
+func (in *DisplayResizeConfig) DeepCopy() *DisplayResizeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DisplayResizeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisplayResolution) DeepCopyInto(out *DisplayResolution) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisplayResolution.
+func (in *DisplayResolution) DeepCopy() *DisplayResolution {
+	if in == nil {
+		return nil
+	}
+	out := new(DisplayResolution)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerInDockerConfig) DeepCopyInto(out *DockerInDockerConfig) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeDevices != nil {
+		in, out := &in.VolumeDevices, &out.VolumeDevices
+		*out = make([]corev1.VolumeDevice, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerInDockerConfig.
+func (in *DockerInDockerConfig) DeepCopy() *DockerInDockerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerInDockerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileTransferConfig) DeepCopyInto(out *FileTransferConfig) {
+	*out = *in
+	if in.AllowedContentTypes != nil {
+		in, out := &in.AllowedContentTypes, &out.AllowedContentTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileTransferConfig.
+func (in *FileTransferConfig) DeepCopy() *FileTransferConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FileTransferConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallPolicy) DeepCopyInto(out *FirewallPolicy) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]FirewallPort, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallPolicy.
+func (in *FirewallPolicy) DeepCopy() *FirewallPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallPort) DeepCopyInto(out *FirewallPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallPort.
+func (in *FirewallPort) DeepCopy() *FirewallPort {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUConfig) DeepCopyInto(out *GPUConfig) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUConfig.
+func (in *GPUConfig) DeepCopy() *GPUConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HomeShareConfig) DeepCopyInto(out *HomeShareConfig) {
+	*out = *in
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HomeShareConfig.
+func (in *HomeShareConfig) DeepCopy() *HomeShareConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HomeShareConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HomeUsageStatus) DeepCopyInto(out *HomeUsageStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HomeUsageStatus.
+func (in *HomeUsageStatus) DeepCopy() *HomeUsageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HomeUsageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IDEConfig) DeepCopyInto(out *IDEConfig) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IDEConfig.
+func (in *IDEConfig) DeepCopy() *IDEConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IDEConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVariant) DeepCopyInto(out *ImageVariant) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageVariant.
+func (in *ImageVariant) DeepCopy() *ImageVariant {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageVariant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeCredentialsConfig) DeepCopyInto(out *KubeCredentialsConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeCredentialsConfig.
+func (in *KubeCredentialsConfig) DeepCopy() *KubeCredentialsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeCredentialsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Lab) DeepCopyInto(out *Lab) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lab.
+func (in *Lab) DeepCopy() *Lab {
+	if in == nil {
+		return nil
+	}
+	out := new(Lab)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Lab) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabList) DeepCopyInto(out *LabList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Lab, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabList.
+func (in *LabList) DeepCopy() *LabList {
+	if in == nil {
+		return nil
+	}
+	out := new(LabList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LabList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabRoster) DeepCopyInto(out *LabRoster) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Teams != nil {
+		in, out := &in.Teams, &out.Teams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabRoster.
+func (in *LabRoster) DeepCopy() *LabRoster {
+	if in == nil {
+		return nil
+	}
+	out := new(LabRoster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabSession) DeepCopyInto(out *LabSession) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabSession.
+func (in *LabSession) DeepCopy() *LabSession {
+	if in == nil {
+		return nil
+	}
+	out := new(LabSession)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabSharedVolume) DeepCopyInto(out *LabSharedVolume) {
+	*out = *in
+	if in.ClaimSpec != nil {
+		in, out := &in.ClaimSpec, &out.ClaimSpec
+		*out = new(corev1.PersistentVolumeClaimSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabSharedVolume.
+func (in *LabSharedVolume) DeepCopy() *LabSharedVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(LabSharedVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabSpec) DeepCopyInto(out *LabSpec) {
+	*out = *in
+	if in.Instructors != nil {
+		in, out := &in.Instructors, &out.Instructors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Roster.DeepCopyInto(&out.Roster)
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SharedVolumes != nil {
+		in, out := &in.SharedVolumes, &out.SharedVolumes
+		*out = make([]LabSharedVolume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabSpec.
+func (in *LabSpec) DeepCopy() *LabSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LabSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabStatus) DeepCopyInto(out *LabStatus) {
+	*out = *in
+	if in.Sessions != nil {
+		in, out := &in.Sessions, &out.Sessions
+		*out = make([]LabSession, len(*in))
+		copy(*out, *in)
+	}
+	in.LastBroadcastTime.DeepCopyInto(&out.LastBroadcastTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabStatus.
+func (in *LabStatus) DeepCopy() *LabStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LabStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LaunchPrompt) DeepCopyInto(out *LaunchPrompt) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LaunchPrompt.
+func (in *LaunchPrompt) DeepCopy() *LaunchPrompt {
+	if in == nil {
+		return nil
+	}
+	out := new(LaunchPrompt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicenseGrant) DeepCopyInto(out *LicenseGrant) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseGrant.
+func (in *LicenseGrant) DeepCopy() *LicenseGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(LicenseGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicenseRequirement) DeepCopyInto(out *LicenseRequirement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseRequirement.
+func (in *LicenseRequirement) DeepCopy() *LicenseRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(LicenseRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintResult) DeepCopyInto(out *LintResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintResult.
+func (in *LintResult) DeepCopy() *LintResult {
+	if in == nil {
+		return nil
+	}
+	out := new(LintResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitorsConfig) DeepCopyInto(out *MonitorsConfig) {
+	*out = *in
+	if in.MaxResolution != nil {
+		in, out := &in.MaxResolution, &out.MaxResolution
+		*out = new(DisplayResolution)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitorsConfig.
+func (in *MonitorsConfig) DeepCopy() *MonitorsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitorsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageStreamingConfig) DeepCopyInto(out *ImageStreamingConfig) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageStreamingConfig.
+func (in *ImageStreamingConfig) DeepCopy() *ImageStreamingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageStreamingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerOnWebhookConfig) DeepCopyInto(out *PowerOnWebhookConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerOnWebhookConfig.
+func (in *PowerOnWebhookConfig) DeepCopy() *PowerOnWebhookConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerOnWebhookConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyConfig) DeepCopyInto(out *ProxyConfig) {
+	*out = *in
+	if in.FileTransfer != nil {
+		in, out := &in.FileTransfer, &out.FileTransfer
+		*out = new(FileTransferConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowClipboardTo != nil {
+		in, out := &in.AllowClipboardTo, &out.AllowClipboardTo
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowClipboardFrom != nil {
+		in, out := &in.AllowClipboardFrom, &out.AllowClipboardFrom
+		*out = new(bool)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Thumbnails != nil {
+		in, out := &in.Thumbnails, &out.Thumbnails
+		*out = new(ThumbnailConfig)
+		**out = **in
+	}
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]SidecarPlugin, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyConfig.
+func (in *ProxyConfig) DeepCopy() *ProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QEMUConfig) DeepCopyInto(out *QEMUConfig) {
+	*out = *in
+	in.QEMUResources.DeepCopyInto(&out.QEMUResources)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopConfig.
-func (in *DesktopConfig) DeepCopy() *DesktopConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QEMUConfig.
+func (in *QEMUConfig) DeepCopy() *QEMUConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopConfig)
+	out := new(QEMUConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DockerInDockerConfig) DeepCopyInto(out *DockerInDockerConfig) {
+func (in *RDPConfig) DeepCopyInto(out *RDPConfig) {
 	*out = *in
-	in.Resources.DeepCopyInto(&out.Resources)
-	if in.VolumeMounts != nil {
-		in, out := &in.VolumeMounts, &out.VolumeMounts
-		*out = make([]corev1.VolumeMount, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.VolumeDevices != nil {
-		in, out := &in.VolumeDevices, &out.VolumeDevices
-		*out = make([]corev1.VolumeDevice, len(*in))
-		copy(*out, *in)
-	}
+	in.GuacdResources.DeepCopyInto(&out.GuacdResources)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerInDockerConfig.
-func (in *DockerInDockerConfig) DeepCopy() *DockerInDockerConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RDPConfig.
+func (in *RDPConfig) DeepCopy() *RDPConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(DockerInDockerConfig)
+	out := new(RDPConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyConfig) DeepCopyInto(out *ProxyConfig) {
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
 	*out = *in
-	in.Resources.DeepCopyInto(&out.Resources)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyConfig.
-func (in *ProxyConfig) DeepCopy() *ProxyConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyConfig)
+	out := new(RolloutStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *QEMUConfig) DeepCopyInto(out *QEMUConfig) {
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
 	*out = *in
-	in.QEMUResources.DeepCopyInto(&out.QEMUResources)
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(DesktopConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QEMUConfig.
-func (in *QEMUConfig) DeepCopy() *QEMUConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
 	if in == nil {
 		return nil
 	}
-	out := new(QEMUConfig)
+	out := new(RolloutStrategy)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -147,8 +1115,8 @@ func (in *Session) DeepCopyInto(out *Session) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Session.
@@ -204,6 +1172,11 @@ func (in *SessionList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SessionSpec) DeepCopyInto(out *SessionSpec) {
 	*out = *in
+	if in.SharedVolumes != nil {
+		in, out := &in.SharedVolumes, &out.SharedVolumes
+		*out = make([]SharedVolume, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionSpec.
@@ -219,6 +1192,36 @@ func (in *SessionSpec) DeepCopy() *SessionSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SessionStatus) DeepCopyInto(out *SessionStatus) {
 	*out = *in
+	in.LastMaintenanceTime.DeepCopyInto(&out.LastMaintenanceTime)
+	in.PreemptionNoticeTime.DeepCopyInto(&out.PreemptionNoticeTime)
+	in.PowerOnTime.DeepCopyInto(&out.PowerOnTime)
+	in.TicketExpiresAt.DeepCopyInto(&out.TicketExpiresAt)
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+	in.ClaimTime.DeepCopyInto(&out.ClaimTime)
+	in.LastInputTime.DeepCopyInto(&out.LastInputTime)
+	in.IdleWarningTime.DeepCopyInto(&out.IdleWarningTime)
+	in.IdleTerminationTime.DeepCopyInto(&out.IdleTerminationTime)
+	in.LastBroadcastTime.DeepCopyInto(&out.LastBroadcastTime)
+	if in.Agent != nil {
+		in, out := &in.Agent, &out.Agent
+		*out = new(AgentStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Licenses != nil {
+		in, out := &in.Licenses, &out.Licenses
+		*out = make([]LicenseGrant, len(*in))
+		copy(*out, *in)
+	}
+	if in.Monitors != nil {
+		in, out := &in.Monitors, &out.Monitors
+		*out = make([]DisplayResolution, len(*in))
+		copy(*out, *in)
+	}
+	if in.HomeUsage != nil {
+		in, out := &in.HomeUsage, &out.HomeUsage
+		*out = new(HomeUsageStatus)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionStatus.
@@ -231,12 +1234,153 @@ func (in *SessionStatus) DeepCopy() *SessionStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedVolume) DeepCopyInto(out *SharedVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedVolume.
+func (in *SharedVolume) DeepCopy() *SharedVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarPlugin) DeepCopyInto(out *SidecarPlugin) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarPlugin.
+func (in *SidecarPlugin) DeepCopy() *SidecarPlugin {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarPlugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotVolume) DeepCopyInto(out *SnapshotVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotVolume.
+func (in *SnapshotVolume) DeepCopy() *SnapshotVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpotConfig) DeepCopyInto(out *SpotConfig) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreemptionTaints != nil {
+		in, out := &in.PreemptionTaints, &out.PreemptionTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OnDemandNodeSelector != nil {
+		in, out := &in.OnDemandNodeSelector, &out.OnDemandNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpotConfig.
+func (in *SpotConfig) DeepCopy() *SpotConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SpotConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticHostConfig) DeepCopyInto(out *StaticHostConfig) {
+	*out = *in
+	in.BridgeResources.DeepCopyInto(&out.BridgeResources)
+	if in.PowerOn != nil {
+		in, out := &in.PowerOn, &out.PowerOn
+		*out = new(StaticHostPowerOnConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticHostConfig.
+func (in *StaticHostConfig) DeepCopy() *StaticHostConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticHostConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticHostPowerOnConfig) DeepCopyInto(out *StaticHostPowerOnConfig) {
+	*out = *in
+	if in.WakeOnLAN != nil {
+		in, out := &in.WakeOnLAN, &out.WakeOnLAN
+		*out = new(WakeOnLANConfig)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(PowerOnWebhookConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticHostPowerOnConfig.
+func (in *StaticHostPowerOnConfig) DeepCopy() *StaticHostPowerOnConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticHostPowerOnConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Template) DeepCopyInto(out *Template) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Template.
@@ -257,6 +1401,28 @@ func (in *Template) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateCapacity) DeepCopyInto(out *TemplateCapacity) {
+	*out = *in
+	if in.Reservations != nil {
+		in, out := &in.Reservations, &out.Reservations
+		*out = make([]CapacityReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateCapacity.
+func (in *TemplateCapacity) DeepCopy() *TemplateCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateList) DeepCopyInto(out *TemplateList) {
 	*out = *in
@@ -289,6 +1455,25 @@ func (in *TemplateList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateReview) DeepCopyInto(out *TemplateReview) {
+	*out = *in
+	if in.ReviewedAt != nil {
+		in, out := &in.ReviewedAt, &out.ReviewedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateReview.
+func (in *TemplateReview) DeepCopy() *TemplateReview {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateReview)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
 	*out = *in
@@ -314,6 +1499,26 @@ func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
 		*out = new(ProxyConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DisplayConfig != nil {
+		in, out := &in.DisplayConfig, &out.DisplayConfig
+		*out = new(DisplayConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AudioConfig != nil {
+		in, out := &in.AudioConfig, &out.AudioConfig
+		*out = new(AudioConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AgentConfig != nil {
+		in, out := &in.AgentConfig, &out.AgentConfig
+		*out = new(AgentConfig)
+		**out = **in
+	}
+	if in.KubeCredentialsConfig != nil {
+		in, out := &in.KubeCredentialsConfig, &out.KubeCredentialsConfig
+		*out = new(KubeCredentialsConfig)
+		**out = **in
+	}
 	if in.DindConfig != nil {
 		in, out := &in.DindConfig, &out.DindConfig
 		*out = new(DockerInDockerConfig)
@@ -324,6 +1529,88 @@ func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
 		*out = new(QEMUConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StaticHost != nil {
+		in, out := &in.StaticHost, &out.StaticHost
+		*out = new(StaticHostConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IDEConfig != nil {
+		in, out := &in.IDEConfig, &out.IDEConfig
+		*out = new(IDEConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Maintenance != nil {
+		in, out := &in.Maintenance, &out.Maintenance
+		*out = new(MaintenanceWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageStreaming != nil {
+		in, out := &in.ImageStreaming, &out.ImageStreaming
+		*out = new(ImageStreamingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(TemplateCapacity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Licenses != nil {
+		in, out := &in.Licenses, &out.Licenses
+		*out = make([]LicenseRequirement, len(*in))
+		copy(*out, *in)
+	}
+	if in.HomeShare != nil {
+		in, out := &in.HomeShare, &out.HomeShare
+		*out = new(HomeShareConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Architectures != nil {
+		in, out := &in.Architectures, &out.Architectures
+		*out = make([]Architecture, len(*in))
+		copy(*out, *in)
+	}
+	if in.GPUs != nil {
+		in, out := &in.GPUs, &out.GPUs
+		*out = new(GPUConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Spot != nil {
+		in, out := &in.Spot, &out.Spot
+		*out = new(SpotConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdlePolicy != nil {
+		in, out := &in.IdlePolicy, &out.IdlePolicy
+		*out = new(appv1.IdlePolicy)
+		**out = **in
+	}
+	if in.Deprecation != nil {
+		in, out := &in.Deprecation, &out.Deprecation
+		*out = new(DeprecationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Firewall != nil {
+		in, out := &in.Firewall, &out.Firewall
+		*out = new(FirewallPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Review != nil {
+		in, out := &in.Review, &out.Review
+		*out = new(TemplateReview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Prompts != nil {
+		in, out := &in.Prompts, &out.Prompts
+		*out = make([]LaunchPrompt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Tags != nil {
 		in, out := &in.Tags, &out.Tags
 		*out = make(map[string]string, len(*in))
@@ -342,3 +1629,73 @@ func (in *TemplateSpec) DeepCopy() *TemplateSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateStatus) DeepCopyInto(out *TemplateStatus) {
+	*out = *in
+	if in.LintResults != nil {
+		in, out := &in.LintResults, &out.LintResults
+		*out = make([]LintResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateStatus.
+func (in *TemplateStatus) DeepCopy() *TemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThumbnailConfig) DeepCopyInto(out *ThumbnailConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThumbnailConfig.
+func (in *ThumbnailConfig) DeepCopy() *ThumbnailConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ThumbnailConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Ulimits) DeepCopyInto(out *Ulimits) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ulimits.
+func (in *Ulimits) DeepCopy() *Ulimits {
+	if in == nil {
+		return nil
+	}
+	out := new(Ulimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WakeOnLANConfig) DeepCopyInto(out *WakeOnLANConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WakeOnLANConfig.
+func (in *WakeOnLANConfig) DeepCopy() *WakeOnLANConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WakeOnLANConfig)
+	in.DeepCopyInto(out)
+	return out
+}
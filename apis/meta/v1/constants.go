@@ -27,7 +27,7 @@ import (
 )
 
 const (
-	// RoleClusterRefLabel marks for which cluster a role belongs
+	// RoleClusterRefLabel marks for which cluster a role, team, or template belongs
 	RoleClusterRefLabel = "kvdi.io/cluster-ref"
 	// CreationSpecAnnotation contains the serialized creation spec of a resource
 	// to be compared against desired state.
@@ -40,8 +40,44 @@ const (
 	// to groups provided in claims from an OIDC provider. A semicolon separated list can
 	// bind a role to multiple groups.
 	OIDCGroupRoleAnnotation = "kvdi.io/oidc-groups"
+	// BoundUsersAnnotation is applied to VDIRoles to bind them directly to users, regardless
+	// of the authentication provider. The role is granted to the listed users whenever tokens
+	// are issued to them. A semicolon separated list can bind a role to multiple users.
+	BoundUsersAnnotation = "kvdi.io/bound-users"
 	// AuthGroupSeparator is the separator used when parsing lists of groups from a string.
 	AuthGroupSeparator = ";"
+	// PrivilegedJustificationAnnotation is an annotation applied to templates that run privileged
+	// containers explaining why the privileges are required. It silences the corresponding lint rule.
+	PrivilegedJustificationAnnotation = "kvdi.io/privileged-justification"
+	// PersistentHomeAnnotation is an annotation applied to templates that expect the user's $HOME
+	// to persist between sessions.
+	PersistentHomeAnnotation = "kvdi.io/persistent-home"
+	// ImageStreamingNodeLabel is the default label used to select nodes that can lazily pull
+	// images. The value is the streaming mode the node's container runtime supports.
+	ImageStreamingNodeLabel = "kvdi.io/image-streaming"
+	// BootAdmittedAnnotation is applied to desktop pods by the manager when the node they are
+	// scheduled on has capacity for another concurrent boot.
+	BootAdmittedAnnotation = "kvdi.io/boot-admitted"
+	// BootAdmittedAtAnnotation records when a desktop pod was admitted to boot. Pods still
+	// booting long after they were admitted no longer count against their node's limit.
+	BootAdmittedAtAnnotation = "kvdi.io/boot-admitted-at"
+	// ReservationAnnotation is applied to desktop sessions that were launched into capacity
+	// reserved on their template. The value is the name of the reservation.
+	ReservationAnnotation = "kvdi.io/reservation"
+	// MarketplaceIndexAnnotation is applied to templates installed from a remote template index
+	// and contains the name of the index.
+	MarketplaceIndexAnnotation = "kvdi.io/marketplace-index"
+	// MarketplaceVersionAnnotation contains the version of an installed marketplace template.
+	MarketplaceVersionAnnotation = "kvdi.io/marketplace-version"
+	// MarketplaceDigestAnnotation contains the verified sha256 digest of the manifest an installed
+	// marketplace template was created from.
+	MarketplaceDigestAnnotation = "kvdi.io/marketplace-digest"
+	// StagedConfigAnnotation is applied to VDIClusters with a proposed revision of their spec that
+	// is waiting to be promoted.
+	StagedConfigAnnotation = "kvdi.io/staged-config"
+	// PreviousConfigAnnotation is applied to VDIClusters when a staged revision is promoted and
+	// contains the spec it replaced, so the promotion can be rolled back.
+	PreviousConfigAnnotation = "kvdi.io/previous-config"
 	// VDIClusterLabel is the label attached to resources to reference their parents VDI cluster
 	VDIClusterLabel = "vdiCluster"
 	// ComponentLabel is the label primarily used for service selectors
@@ -53,6 +89,10 @@ const (
 	DesktopNameLabel = "desktopName"
 	// ClientAddrLabel is the a label referencing the client address on a display/audio lock.
 	ClientAddrLabel = "clientAddr"
+	// LabLabel is a label referencing the lab a desktop instance was provisioned for.
+	LabLabel = "desktopLab"
+	// DesktopPoolLabel is a label referencing the pool an unclaimed desktop instance was booted for.
+	DesktopPoolLabel = "desktopPool"
 	// ServerCertificateMountPath is where server certificates get placed inside pods
 	ServerCertificateMountPath = "/etc/kvdi/tls/server"
 	// ClientCertificateMountPath is where client certificates get placed inside pods
@@ -61,25 +101,168 @@ const (
 	SecretAssetsMountPath = "/etc/kvdi/secrets"
 	// JWTSecretKey is where our JWT secret is stored in the secrets backend.
 	JWTSecretKey = "jwtSecret"
+	// LocalUsersSecretKey is where the passwd file of the local auth provider is stored in
+	// the secrets backend.
+	LocalUsersSecretKey = "passwd"
 	// OTPUsersSecretKey is where a mapping of users to their OTP secrets is held in the secrets backend.
 	OTPUsersSecretKey = "otpUsers"
 	// RefreshTokensSecretKey is where a mapping of refresh tokens to users is kept in the secrets backend.
 	RefreshTokensSecretKey = "refreshTokens"
+	// AccessRequestsSecretKey is where a mapping of template access request IDs to their details
+	// is kept in the secrets backend.
+	AccessRequestsSecretKey = "accessRequests"
+	// RevokedTokensSecretKey is where a mapping of users to the unix time before which all of their
+	// access tokens are considered revoked is kept in the secrets backend.
+	RevokedTokensSecretKey = "revokedTokens"
+	// AccessOverridesSecretKey is where a mapping of access override tokens to the users they
+	// were issued for is kept in the secrets backend.
+	AccessOverridesSecretKey = "accessOverrides"
+	// SSHCASecretKey is where the private key used for signing SSH user certificates is stored
+	// in the secrets backend.
+	SSHCASecretKey = "sshCA"
+	// HomeShareCredentialsSecretKey is where a mapping of users to the credentials used for
+	// mounting their home shares is stored in the secrets backend.
+	HomeShareCredentialsSecretKey = "homeShareCredentials"
+	// DomainKeytabsSecretKey is where a mapping of host names to the keytabs domain joined
+	// desktops authenticate to the realm with is stored in the secrets backend.
+	DomainKeytabsSecretKey = "domainKeytabs"
+	// DomainHostLeasesSecretKey is where a mapping of host names to the desktops currently
+	// using their keytabs is stored in the secrets backend.
+	DomainHostLeasesSecretKey = "domainHostLeases"
+	// UserMetadataSecretKey is where a mapping of users to their key-value metadata is held in
+	// the secrets backend.
+	UserMetadataSecretKey = "userMetadata"
+	// UserDevicesSecretKey is where a mapping of users to the devices they have signed in from
+	// is held in the secrets backend.
+	UserDevicesSecretKey = "userDevices"
+	// BreakGlassChallengesSecretKey is where a mapping of outstanding break-glass unlock
+	// challenges to the unix time they expire is kept in the secrets backend.
+	BreakGlassChallengesSecretKey = "breakGlassChallenges"
+	// DeletedObjectsSecretKey is where a mapping of deleted users and roles to the data needed
+	// to restore them is held in the secrets backend.
+	DeletedObjectsSecretKey = "deletedObjects"
+	// SchemaVersionSecretKey is where the version of the data formats used in the secrets
+	// backend is recorded. It is advanced by the manager as it migrates stored data.
+	SchemaVersionSecretKey = "schemaVersion"
 	// WebPort is the port that web services will listen on internally
 	WebPort = 8443
+	// GuacdPort is the port guacd listens on inside desktops serving RDP displays
+	GuacdPort = 4822
+	// DefaultRDPPort is the default port of the RDP server inside desktops
+	DefaultRDPPort = 3389
 	// PublicWebPort is the port for the app service
 	PublicWebPort = 443
+	// TunnelPort is the port the app listens on for reverse tunnels from desktop proxies
+	TunnelPort = 8444
+	// ProxyMetricsPort is the port the kvdi-proxy serves metrics on, when enabled
+	ProxyMetricsPort = 8445
+	// TunnelRelayPort is the port app replicas listen on for reverse tunnel connections
+	// relayed from other replicas
+	TunnelRelayPort = 8446
 	// DesktopRunDir is the dir mounted for internal runtime files
 	DesktopRunDir = "/var/run/kvdi"
 	// DefaultDisplaySocketAddr is the default path used for the display unix socket
 	DefaultDisplaySocketAddr = "unix:///var/run/kvdi/display.sock"
+	// DefaultDisplayProtocol is the default protocol spoken by the display server
+	DefaultDisplayProtocol = "novnc"
+	// DesktopMonitorsFile is where the proxy writes the monitor layout of a desktop
+	// spanning multiple monitors, one `WIDTHxHEIGHT` per line.
+	DesktopMonitorsFile = "/var/run/kvdi/monitors"
+	// DesktopPluginsDir is where sidecar plugins place the sockets they listen on, each
+	// named after its plugin. It lives in the run volume shared by every container.
+	DesktopPluginsDir = "/run/kvdi/plugins"
+	// MaxDesktopMonitors is the most monitors a desktop may span.
+	MaxDesktopMonitors = 16
+	// DefaultMaxMonitorWidth is the maximum width of each monitor of a desktop when not
+	// configured on the template.
+	DefaultMaxMonitorWidth = 3840
+	// DefaultMaxMonitorHeight is the maximum height of each monitor of a desktop when not
+	// configured on the template.
+	DefaultMaxMonitorHeight = 2160
+	// DefaultResizeXDisplay is the X display the proxy resizes when not configured on
+	// the template.
+	DefaultResizeXDisplay = ":10"
+	// MinDisplayDPI is the lowest DPI clients may set on a resizable display.
+	MinDisplayDPI = 48
+	// MaxDisplayDPI is the highest DPI clients may set on a resizable display.
+	MaxDisplayDPI = 480
 	// DefaultNamespace is the default namespace to provision resources in
 	DefaultNamespace = "default"
 	// DefaultSessionLength is the session length used for setting expiry
 	// times on new user sessions.
 	DefaultSessionLength = time.Duration(15) * time.Minute
+	// DefaultThumbnailInterval is how often desktop thumbnails are captured when not
+	// configured on the template.
+	DefaultThumbnailInterval = time.Duration(30) * time.Second
+	// DefaultSpotWarningPeriod is how long users are warned of a spot preemption before
+	// their desktop is relaunched when not configured on the template.
+	DefaultSpotWarningPeriod = time.Duration(1) * time.Minute
+	// DefaultIdleWarnBefore is how long before an idle session is terminated to warn its
+	// user when not configured on the idle policy.
+	DefaultIdleWarnBefore = time.Duration(5) * time.Minute
+	// DefaultStaticHostBootTimeout is how long to wait for a static host to accept
+	// connections after powering it on when not configured on the template.
+	DefaultStaticHostBootTimeout = time.Duration(5) * time.Minute
+	// DefaultTicketRevalidateInterval is how often the tickets of running sessions are
+	// revalidated when not configured on the VDICluster.
+	DefaultTicketRevalidateInterval = time.Duration(5) * time.Minute
+	// DefaultMiddlewareWebhookTimeout is how long to wait for an API webhook filter to
+	// respond when not configured on the VDICluster.
+	DefaultMiddlewareWebhookTimeout = time.Duration(5) * time.Second
+	// DefaultCapacityScheduleLeadTime is how long before a window of a capacity schedule
+	// opens the schedule becomes active when not configured on the VDICluster.
+	DefaultCapacityScheduleLeadTime = time.Duration(15) * time.Minute
+	// DefaultDeletionRetention is how long deleted users and roles are kept so they can be
+	// restored when not configured on the VDICluster.
+	DefaultDeletionRetention = time.Duration(72) * time.Hour
+	// DefaultAuditFilePath is where auditing events are appended when using the file sink
+	// without a path configured on the VDICluster.
+	DefaultAuditFilePath = "/var/log/kvdi/audit.log"
+	// DefaultAuditRetention is the number of recent auditing events each app instance keeps
+	// in memory when not configured on the VDICluster.
+	DefaultAuditRetention = 1000
+	// DefaultRecordingRegion is the region recordings are signed for when not configured on
+	// the VDICluster.
+	DefaultRecordingRegion = "us-east-1"
+	// DefaultRecordingPrefix is the prefix of the keys recordings are written under when not
+	// configured on the VDICluster.
+	DefaultRecordingPrefix = "recordings/"
+	// DefaultRecordingFrameInterval is how often frames of recorded sessions are captured
+	// when not configured on the VDICluster.
+	DefaultRecordingFrameInterval = time.Duration(5) * time.Second
+	// DefaultRecordingFrameMaxWidth is the maximum width frames of recorded sessions are
+	// captured at when not configured on the VDICluster.
+	DefaultRecordingFrameMaxWidth = 1280
+	// DefaultAgentReportInterval is how often the kvdi-agent reports health to the API when
+	// not configured on the template.
+	DefaultAgentReportInterval = time.Duration(30) * time.Second
+	// DefaultAgentMetadataPort is the port the kvdi-agent serves the local metadata endpoint
+	// on when not configured on the template.
+	DefaultAgentMetadataPort = 8989
+	// DefaultKubeCredentialsExpiration is how long kubeconfigs issued to desktop sessions
+	// are valid for when not configured on the template.
+	DefaultKubeCredentialsExpiration = time.Duration(1) * time.Hour
+	// MinKubeCredentialsExpiration is the shortest lifetime the Kubernetes API accepts for
+	// service account tokens.
+	MinKubeCredentialsExpiration = time.Duration(10) * time.Minute
+	// DefaultThumbnailMaxWidth is the maximum width of desktop thumbnails when not
+	// configured on the template.
+	DefaultThumbnailMaxWidth = 320
+	// DefaultAudioBitrate is the bitrate in bits per second desktop audio is encoded at
+	// when not configured on the template.
+	DefaultAudioBitrate = 64000
+	// DefaultAudioSampleRate is the sample rate desktop audio is captured at when not
+	// configured on the template.
+	DefaultAudioSampleRate = 24000
 	// CACertKey is the key where the CA certificate is placed in TLS secrets.
 	CACertKey = "ca.crt"
+	// AgentTokenKey is the key where the token for the kvdi-agent is placed in agent secrets.
+	AgentTokenKey = "token"
+	// AgentTokenHeader is the header the kvdi-agent presents its token to the API in.
+	AgentTokenHeader = "X-Kvdi-Agent-Token"
+	// SSHCAPublicKeyKey is the key where the SSH user CA public key is placed in desktop TLS
+	// secrets.
+	SSHCAPublicKeyKey = "ssh_ca.pub"
 	// UserEnvVar is the environment variable used to set the username during a desktop's init
 	// process
 	UserEnvVar = "USER"
@@ -104,6 +287,56 @@ const (
 	QEMUMemoryEnvVar = "MEMORY"
 	// SPICEDisplayEnvVar is used to signal that the template wishes to use a SPICE display.
 	SPICEDisplayEnvVar = "SPICE_DISPLAY"
+	// DisplayProtocolEnvVar contains the protocol the display server should speak (e.g. `xpra`).
+	DisplayProtocolEnvVar = "DISPLAY_PROTOCOL"
+	// VNCClipboardArgsEnvVar contains arguments for the VNC server restricting the
+	// directions the clipboard may be synced in.
+	VNCClipboardArgsEnvVar = "VNC_CLIPBOARD_ARGS"
+	// MaxMonitorsEnvVar contains the maximum number of monitors the display may span.
+	MaxMonitorsEnvVar = "MAX_MONITORS"
+	// MaxMonitorResolutionEnvVar contains the maximum resolution of each monitor, in
+	// `WIDTHxHEIGHT` format.
+	MaxMonitorResolutionEnvVar = "MAX_MONITOR_RESOLUTION"
+	// PluginSocketEnvVar contains the path of the socket a sidecar plugin should listen on.
+	PluginSocketEnvVar = "KVDI_PLUGIN_SOCKET"
+	// UlimitNoFileEnvVar is used to signal the init process to raise the open file limit.
+	UlimitNoFileEnvVar = "ULIMIT_NOFILE"
+	// UlimitNProcEnvVar is used to signal the init process to raise the process limit.
+	UlimitNProcEnvVar = "ULIMIT_NPROC"
+	// SSHTrustedCAEnvVar contains the public key of the CA that signs SSH user certificates.
+	SSHTrustedCAEnvVar = "SSH_TRUSTED_CA_KEYS"
+	// IDEPortEnvVar contains the port an IDE server inside the desktop should listen on. The
+	// server should only listen on 127.0.0.1.
+	IDEPortEnvVar = "IDE_PORT"
+	// RDPAddressEnvVar contains the address of the RDP server an RDP bridge connects to.
+	RDPAddressEnvVar = "RDP_ADDRESS"
+	// RDPPortEnvVar contains the port of the RDP server inside the desktop.
+	RDPPortEnvVar = "RDP_PORT"
+	// RDPUsernameEnvVar contains the username an RDP bridge logs in with.
+	RDPUsernameEnvVar = "RDP_USERNAME"
+	// RDPPasswordEnvVar contains the password an RDP bridge logs in with.
+	RDPPasswordEnvVar = "RDP_PASSWORD"
+	// RDPDomainEnvVar contains the domain an RDP bridge logs in to.
+	RDPDomainEnvVar = "RDP_DOMAIN"
+	// DomainRealmEnvVar contains the Kerberos realm a domain joined desktop belongs to.
+	DomainRealmEnvVar = "DOMAIN_REALM"
+	// AgentAPIURLEnvVar contains the URL of the API the kvdi-agent reports to.
+	AgentAPIURLEnvVar = "KVDI_AGENT_API_URL"
+	// AgentSessionEnvVar contains the namespace/name of the session the kvdi-agent runs in.
+	AgentSessionEnvVar = "KVDI_AGENT_SESSION"
+	// AgentTokenFileEnvVar contains the path to the token the kvdi-agent authenticates with.
+	AgentTokenFileEnvVar = "KVDI_AGENT_TOKEN_FILE"
+	// AgentCAFileEnvVar contains the path to the CA certificate for verifying the API.
+	AgentCAFileEnvVar = "KVDI_AGENT_CA_FILE"
+	// AgentMetadataAddrEnvVar contains the address the kvdi-agent serves local metadata on.
+	AgentMetadataAddrEnvVar = "KVDI_AGENT_METADATA_ADDR"
+	// AgentReportIntervalEnvVar contains how often the kvdi-agent reports to the API.
+	AgentReportIntervalEnvVar = "KVDI_AGENT_REPORT_INTERVAL"
+	// AgentKubeconfigEnvVar contains the path the kvdi-agent writes session kubeconfigs to.
+	AgentKubeconfigEnvVar = "KVDI_AGENT_KUBECONFIG"
+	// KubeconfigEnvVar is the environment variable Kubernetes clients locate their
+	// kubeconfig with.
+	KubeconfigEnvVar = "KUBECONFIG"
 )
 
 // Desktop runtime volume names
@@ -121,6 +354,11 @@ var (
 	DockerBinVolume  = "docker-bin"
 	KVMVolume        = "qemu-kvm"
 	QEMUDiskVolume   = "qemu-disk-image"
+	BootGateVolume   = "boot-gate"
+	HomeShareVolume  = "home-share"
+	DomainJoinVolume = "domain-join"
+	StaticHostVolume = "static-host"
+	AgentVolume      = "agent"
 )
 
 // Desktop runtime mount paths
@@ -138,6 +376,13 @@ const (
 	DesktopKVMPath     = "/dev/kvm"
 	DockerDataPath     = "/var/lib/docker"
 	DockerBinPath      = "/usr/local/docker/bin"
+	BootGatePath       = "/etc/kvdi/boot"
+	KRB5ConfigPath     = "/etc/krb5.conf"
+	KRB5KeytabPath     = "/etc/krb5.keytab"
+	SSSDConfigPath     = "/etc/sssd/sssd.conf"
+	StaticHostPath     = "/etc/kvdi/static-host"
+	AgentPath          = "/etc/kvdi/agent"
+	KubeconfigPathFmt  = "/run/user/%d/kubeconfig"
 )
 
 // Qemu variables
@@ -155,8 +400,9 @@ var (
 	False                 = false
 )
 
-// Websocket configurations
+// Websocket configurations. The write buffer size determines the size of frames
+// sent to clients, so it is large enough to carry a full display update per frame.
 const (
-	WebsocketWriteBufferSize = 512
-	WebsocketReadBufferSize  = 512
+	WebsocketWriteBufferSize = 32 * 1024
+	WebsocketReadBufferSize  = 4 * 1024
 )
@@ -0,0 +1,122 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessHours restricts when members of a role may log in and connect to their desktop
+// sessions. Unlike rules, which grant access to resources, access hours apply to the user
+// as a whole. When a user holds multiple roles, access is allowed if any role has no access
+// hours or has a window open at the time. Administrators may issue override tokens to let
+// a user in outside of their access hours.
+type AccessHours struct {
+	// The windows during which access is allowed. Access is denied outside of all of them.
+	Windows []AccessWindow `json:"windows,omitempty"`
+}
+
+// AccessWindow represents a daily window of time during which access is allowed.
+type AccessWindow struct {
+	// The days of the week the window applies to (e.g. `Monday` or `mon`). Defaults to
+	// every day.
+	Days []string `json:"days,omitempty"`
+	// The time of day the window opens, in 24-hour `HH:MM` format. Times are in UTC, or in
+	// the time zone of the schedule the window belongs to. Defaults to `00:00`.
+	StartTime string `json:"startTime,omitempty"`
+	// The time of day the window closes, in 24-hour `HH:MM` format. Windows closing
+	// earlier than they open span midnight. Defaults to the start time, meaning the window
+	// is open all day.
+	EndTime string `json:"endTime,omitempty"`
+}
+
+// IsOpen returns true if access is allowed at the given time. Nil access hours are always
+// open.
+func (a *AccessHours) IsOpen(now time.Time) bool {
+	if a == nil {
+		return true
+	}
+	for _, window := range a.Windows {
+		if window.IsOpen(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen returns true if the window is open at the given time in UTC.
+func (w *AccessWindow) IsOpen(now time.Time) bool {
+	return w.isOpenAt(now.UTC())
+}
+
+// isOpenAt returns true if the window is open at the wall clock time of now, in whatever
+// location now is in.
+func (w *AccessWindow) isOpenAt(now time.Time) bool {
+	start := clockMinutes(w.StartTime)
+	end := start
+	if w.EndTime != "" {
+		end = clockMinutes(w.EndTime)
+	}
+	minute := now.Hour()*60 + now.Minute()
+	switch {
+	case start == end:
+		return w.dayAllowed(now.Weekday())
+	case start < end:
+		return minute >= start && minute < end && w.dayAllowed(now.Weekday())
+	default:
+		// the window spans midnight, the early hours belong to the previous day's window
+		if minute >= start {
+			return w.dayAllowed(now.Weekday())
+		}
+		if minute < end {
+			return w.dayAllowed(now.AddDate(0, 0, -1).Weekday())
+		}
+		return false
+	}
+}
+
+func (w *AccessWindow) dayAllowed(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if len(d) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), d[:3]) {
+			return true
+		}
+	}
+	return false
+}
+
+// clockMinutes returns the minutes since midnight for a `HH:MM` time of day. Invalid
+// values fall back to midnight.
+func clockMinutes(val string) int {
+	if val == "" {
+		return 0
+	}
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
@@ -0,0 +1,112 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// AttributeMFAVerified is the user attribute set by kvdi to `true` when the user completed
+// multi-factor authentication for their session, and `false` otherwise. It cannot be set
+// by auth providers.
+const AttributeMFAVerified = "mfaVerified"
+
+// ConditionOperator represents how a condition compares a user attribute to its values.
+// +kubebuilder:validation:Enum=In;NotIn;Exists;DoesNotExist
+type ConditionOperator string
+
+// Condition operators
+const (
+	// The attribute is set to one of the values.
+	ConditionOpIn ConditionOperator = "In"
+	// The attribute is not set, or set to none of the values.
+	ConditionOpNotIn ConditionOperator = "NotIn"
+	// The attribute is set to any value.
+	ConditionOpExists ConditionOperator = "Exists"
+	// The attribute is not set.
+	ConditionOpDoesNotExist ConditionOperator = "DoesNotExist"
+)
+
+// Condition matches an attribute of the user, as populated by the auth provider. For
+// example, `department == "engineering"` is expressed as the attribute `department`,
+// the operator `In`, and the values `["engineering"]`.
+type Condition struct {
+	// The name of the user attribute to match (e.g. `department` or `mfaVerified`).
+	Attribute string `json:"attribute"`
+	// How the attribute is compared to the values. Defaults to `In`.
+	Operator ConditionOperator `json:"operator,omitempty"`
+	// The values to compare the attribute to. Required for `In` and `NotIn`.
+	Values []string `json:"values,omitempty"`
+}
+
+// GetOperator returns the operator for this condition.
+func (c *Condition) GetOperator() ConditionOperator {
+	if c.Operator == "" {
+		return ConditionOpIn
+	}
+	return c.Operator
+}
+
+// Matches returns true if the given user attributes satisfy this condition.
+func (c *Condition) Matches(attributes map[string]string) bool {
+	val, ok := attributes[c.Attribute]
+	switch c.GetOperator() {
+	case ConditionOpExists:
+		return ok
+	case ConditionOpDoesNotExist:
+		return !ok
+	case ConditionOpNotIn:
+		return !ok || !c.hasValue(val)
+	default:
+		return ok && c.hasValue(val)
+	}
+}
+
+func (c *Condition) hasValue(val string) bool {
+	for _, v := range c.Values {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error for a condition without an attribute, with an unknown operator,
+// or without values for an operator that needs them.
+func (c *Condition) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if c.Attribute == "" {
+		errs = append(errs, field.Required(fldPath.Child("attribute"), "a condition must name an attribute"))
+	}
+	switch c.GetOperator() {
+	case ConditionOpIn, ConditionOpNotIn:
+		if len(c.Values) == 0 {
+			errs = append(errs, field.Required(fldPath.Child("values"), "values are required for the In and NotIn operators"))
+		}
+	case ConditionOpExists, ConditionOpDoesNotExist:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("operator"), c.Operator, []string{
+			string(ConditionOpIn), string(ConditionOpNotIn), string(ConditionOpExists), string(ConditionOpDoesNotExist),
+		}))
+	}
+	return errs
+}
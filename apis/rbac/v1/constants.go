@@ -25,7 +25,7 @@ package v1
 const NamespaceAll = "*"
 
 // Resource represents the target of an API action
-// +kubebuilder:validation:Enum=users;roles;templates;serviceaccounts;*
+// +kubebuilder:validation:Enum=users;roles;templates;serviceaccounts;auditlogs;recordings;*
 type Resource string
 
 // Resource options
@@ -38,14 +38,21 @@ const (
 	ResourceRoles Resource = "roles"
 	// ResourceTeemplates represents desktop templates in kVDI. Mainly the ability
 	// to launch seessions from them and connect to them. The "launch" verb can used
-	// in this context when referring to launching templates, and the "use" verb for
-	// connecting to them via the UI.
+	// in this context when referring to launching templates, the "use" verb for
+	// connecting to them via the UI, and the "view" verb for watching thumbnails of
+	// their sessions.
 	ResourceTemplates Resource = "templates"
 	// ResourceServiceAccounts represents kubernetes service accounts. Specifically,
 	// the ability to launch desktops that assume them. The API does not expose any
 	// CRUD operations on these, but the "use" verb can be used to signal that a user
 	// is allowed to assume the given service accounts.
 	ResourceServiceAccounts Resource = "serviceaccounts"
+	// ResourceAuditLogs represents the audit log of API requests. Only the "read" verb
+	// is evaluated, allowing a user to query recent audit events.
+	ResourceAuditLogs Resource = "auditlogs"
+	// ResourceRecordings represents recordings of desktop sessions. The "read" verb allows
+	// a user to list and play them back, and the "delete" verb to remove them.
+	ResourceRecordings Resource = "recordings"
 	// ResourceAll matches all resources
 	ResourceAll Resource = "*"
 )
@@ -59,7 +66,7 @@ func resourcesToStrings(r []Resource) []string {
 }
 
 // Verb represents an API action
-// +kubebuilder:validation:Enum=create;read;update;delete;use;launch;*
+// +kubebuilder:validation:Enum=create;read;update;delete;use;launch;view;review;use-file-transfer;use-clipboard;use-microphone;*
 type Verb string
 
 // Verb options
@@ -76,6 +83,19 @@ const (
 	VerbUse Verb = "use"
 	// Launch operations
 	VerbLaunch Verb = "launch"
+	// View operations, such as watching previews of desktop sessions
+	VerbView Verb = "view"
+	// Review operations, such as approving templates saved from desktop sessions
+	VerbReview Verb = "review"
+	// File transfer operations, such as uploading and downloading files to and from
+	// desktop sessions
+	VerbUseFileTransfer Verb = "use-file-transfer"
+	// Clipboard operations, such as syncing the clipboard between the client and
+	// desktop sessions
+	VerbUseClipboard Verb = "use-clipboard"
+	// Microphone operations, such as passing the client's microphone through to desktop
+	// sessions
+	VerbUseMicrophone Verb = "use-microphone"
 	// VerbAll matches all actions
 	VerbAll Verb = "*"
 )
@@ -87,3 +107,33 @@ func verbsToStrings(r []Verb) []string {
 	}
 	return out
 }
+
+// Effect represents whether a rule allows or denies the actions it matches
+// +kubebuilder:validation:Enum=Allow;Deny
+type Effect string
+
+// Effect options
+const (
+	// EffectAllow allows the actions matched by a rule. This is the default.
+	EffectAllow Effect = "Allow"
+	// EffectDeny denies the actions matched by a rule. Deny rules take precedence over
+	// allow rules in all of a user's roles.
+	EffectDeny Effect = "Deny"
+)
+
+// PatternType represents how the resource patterns in a rule are matched against
+// resource names.
+// +kubebuilder:validation:Enum=regex;glob;exact
+type PatternType string
+
+// PatternType options
+const (
+	// PatternTypeRegex matches resource names against regular expressions. This is the
+	// default. Patterns are not anchored, so `dev-` matches any name containing it.
+	PatternTypeRegex PatternType = "regex"
+	// PatternTypeGlob matches entire resource names against shell-style globs, where `*`
+	// matches any sequence of characters, `?` a single character, and `[a-z]` a range.
+	PatternTypeGlob PatternType = "glob"
+	// PatternTypeExact matches resource names that are exactly equal to a pattern.
+	PatternTypeExact PatternType = "exact"
+)
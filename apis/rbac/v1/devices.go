@@ -0,0 +1,34 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+// DevicePolicy controls which local devices a user may attach to their desktop
+// sessions. When a user holds multiple roles, a device is allowed if any role
+// allows it, and denied if any role denies it and none allow it. Devices that no
+// role mentions are allowed. Audio is currently the only device channel the proxy
+// serves.
+type DevicePolicy struct {
+	// Whether desktop audio may be played back on the client.
+	AudioOut *bool `json:"audioOut,omitempty"`
+	// Whether the client's microphone may be passed through to the desktop.
+	AudioIn *bool `json:"audioIn,omitempty"`
+}
@@ -0,0 +1,69 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// SessionQuotas limits the desktop sessions members of a role may run and the devices they
+// may be signed in from. When a user holds
+// multiple roles, the most permissive value for each limit applies, and a role that does
+// not set a limit lifts it entirely.
+type SessionQuotas struct {
+	// The maximum number of sessions a member may run at once. Zero means no limit.
+	MaxSessions int `json:"maxSessions,omitempty"`
+	// The maximum number of sessions a member may run at once in any single namespace.
+	// Zero means no limit.
+	MaxSessionsPerNamespace int `json:"maxSessionsPerNamespace,omitempty"`
+	// The maximum CPU a member's sessions may use in total, as the sum of the limits (or
+	// requests when no limit is set) of the containers in each session.
+	MaxCPU *resource.Quantity `json:"maxCPU,omitempty"`
+	// The maximum memory a member's sessions may use in total, counted the same way as
+	// `maxCPU`.
+	MaxMemory *resource.Quantity `json:"maxMemory,omitempty"`
+	// The maximum number of devices or browsers a member may be signed in from at once.
+	// Zero means no limit.
+	MaxDevices int `json:"maxDevices,omitempty"`
+}
+
+// Validate returns an error for each limit that is negative.
+func (q *SessionQuotas) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if q.MaxSessions < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxSessions"), q.MaxSessions, "must not be negative"))
+	}
+	if q.MaxSessionsPerNamespace < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxSessionsPerNamespace"), q.MaxSessionsPerNamespace, "must not be negative"))
+	}
+	if q.MaxDevices < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxDevices"), q.MaxDevices, "must not be negative"))
+	}
+	if q.MaxCPU != nil && q.MaxCPU.Sign() < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxCPU"), q.MaxCPU.String(), "must not be negative"))
+	}
+	if q.MaxMemory != nil && q.MaxMemory.Sign() < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxMemory"), q.MaxMemory.String(), "must not be negative"))
+	}
+	return errs
+}
@@ -22,19 +22,30 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package v1
 
 import (
+	"path"
+	"reflect"
 	"regexp"
 	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // Rule represents a set of permissions applied to a VDIRole. It mostly resembles
 // an rbacv1.PolicyRule, with resources being a regex and the addition of a
 // namespace selector.
 type Rule struct {
+	// Whether this rule allows or denies the actions it matches. Defaults to `Allow`.
+	// Deny rules take precedence over allow rules in all of a user's roles, so broad
+	// allow rules can be written with exceptions carved out of them.
+	Effect Effect `json:"effect,omitempty"`
 	// The actions this rule applies for. VerbAll matches all actions.
-	// Recognized options are: `["create", "read", "update", "delete", "use", "launch", "*"]`
+	// Recognized options are: `["create", "read", "update", "delete", "use", "launch", "view", "review", "use-file-transfer", "use-clipboard", "use-microphone", "*"]`
 	Verbs []Verb `json:"verbs,omitempty"`
 	// Resources this rule applies to. ResourceAll matches all resources.
-	// Recognized options are: `["users", "roles", "templates", "serviceaccounts", "*"]`
+	// Recognized options are: `["users", "roles", "templates", "serviceaccounts", "auditlogs", "recordings", "*"]`
 	Resources []Resource `json:"resources,omitempty"`
 	// Resource regexes that match this rule. This can be template patterns, role
 	// names or user names. There is no All representation because * will have
@@ -49,9 +60,34 @@ type Rule struct {
 	// cluster admin, both of which come with inherent risks. In the end, you can decide the best
 	// approach for your use case with regards to exposing access to the Kubernetes APIs via kvdi sessions.
 	ResourcePatterns []string `json:"resourcePatterns,omitempty"`
+	// How resource patterns are matched against resource names. Defaults to `regex`.
+	// With `glob`, `dev-*` matches names starting with `dev-`, while with `exact` a
+	// pattern only matches the name it spells out.
+	PatternType PatternType `json:"patternType,omitempty"`
 	// Namespaces this rule applies to. Only evaluated for template launching
 	// permissions. Including "*" as an option matches all namespaces.
 	Namespaces []string `json:"namespaces,omitempty"`
+	// A label selector for namespaces this rule applies to, in addition to those listed in
+	// `namespaces`. Like `namespaces`, it is only evaluated for template launching
+	// permissions. Namespace labels are resolved when the rule is evaluated, so the rule
+	// follows namespaces as they are labeled and unlabeled.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// VDITeams this rule applies to. When set, the rule only matches users that are members of,
+	// and roles that are bound to, one of these teams or any of their descendants. Teams may be
+	// used together with or instead of `resourcePatterns`.
+	Teams []string `json:"teams,omitempty"`
+	// A schedule restricting when this rule applies (e.g. to business hours). Outside of
+	// the schedule an allow rule grants nothing and a deny rule denies nothing. Rules without
+	// a schedule always apply.
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Conditions on the attributes of the user that must all be met for this rule to apply.
+	// When they are not, an allow rule grants nothing and a deny rule denies nothing.
+	Conditions []Condition `json:"conditions,omitempty"`
+	// The maximum duration, as a Go duration string (e.g. `8h`), that sessions launched under
+	// this rule may run before they are terminated. Only evaluated for template launching
+	// permissions. When several rules allow a launch the most permissive one applies, and a
+	// rule without a maximum lifts it entirely.
+	MaxSessionDuration string `json:"maxSessionDuration,omitempty"`
 }
 
 // IsEmpty returns true if this rule is empty.
@@ -59,7 +95,9 @@ func (r *Rule) IsEmpty() bool {
 	return len(r.Verbs) == 0 &&
 		len(r.Resources) == 0 &&
 		len(r.ResourcePatterns) == 0 &&
-		len(r.Namespaces) == 0
+		len(r.Namespaces) == 0 &&
+		r.NamespaceSelector == nil &&
+		len(r.Teams) == 0
 }
 
 // DeepEqual returns true if the provided rule matches this one exactly. All values in both rules
@@ -82,11 +120,20 @@ func (r *Rule) DeepEqual(rule Rule) bool {
 	sort.Strings(this.Namespaces)
 	sort.Strings(that.ResourcePatterns)
 	sort.Strings(that.Namespaces)
+	sort.Strings(this.Teams)
+	sort.Strings(that.Teams)
 
-	return strSliceEqual(thisResourceStrings, thatResourceStrings) &&
+	return this.IsDeny() == that.IsDeny() &&
+		this.GetPatternType() == that.GetPatternType() &&
+		this.GetMaxSessionDuration() == that.GetMaxSessionDuration() &&
+		reflect.DeepEqual(this.NamespaceSelector, that.NamespaceSelector) &&
+		reflect.DeepEqual(this.Schedule, that.Schedule) &&
+		reflect.DeepEqual(this.Conditions, that.Conditions) &&
+		strSliceEqual(thisResourceStrings, thatResourceStrings) &&
 		strSliceEqual(thisVerbStrings, thatVerbStrings) &&
 		strSliceEqual(this.ResourcePatterns, that.ResourcePatterns) &&
-		strSliceEqual(this.Namespaces, that.Namespaces)
+		strSliceEqual(this.Namespaces, that.Namespaces) &&
+		strSliceEqual(this.Teams, that.Teams)
 }
 
 func strSliceEqual(ss, xx []string) bool {
@@ -116,6 +163,57 @@ func strSliceEqual(ss, xx []string) bool {
 	return true
 }
 
+// GetPatternType returns how the resource patterns in this rule are matched, defaulting
+// to regular expressions.
+func (r *Rule) GetPatternType() PatternType {
+	if r.PatternType == "" {
+		return PatternTypeRegex
+	}
+	return r.PatternType
+}
+
+// GetMaxSessionDuration returns the maximum duration of sessions launched under this rule.
+// Zero is returned when there is no maximum. Invalid durations are rejected by Validate and
+// treated as no maximum.
+func (r *Rule) GetMaxSessionDuration() time.Duration {
+	if r.MaxSessionDuration == "" {
+		return 0
+	}
+	dur, err := time.ParseDuration(r.MaxSessionDuration)
+	if err != nil || dur < 0 {
+		return 0
+	}
+	return dur
+}
+
+// IsDeny returns true if this rule denies the actions it matches.
+func (r *Rule) IsDeny() bool { return r.Effect == EffectDeny }
+
+// IsActive returns true if this rule applies at the given time. When the time zone of the
+// schedule cannot be loaded, allow rules never apply and deny rules always do, so that a
+// misconfigured schedule never grants more access.
+func (r *Rule) IsActive(now time.Time) bool {
+	if r.Schedule == nil {
+		return true
+	}
+	active, err := r.Schedule.IsActive(now)
+	if err != nil {
+		return r.IsDeny()
+	}
+	return active
+}
+
+// ConditionsMet returns true if the given user attributes satisfy all of the conditions of
+// this rule.
+func (r *Rule) ConditionsMet(attributes map[string]string) bool {
+	for i := range r.Conditions {
+		if !r.Conditions[i].Matches(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
 // HasVerb returns true if this rule contains the given verb.
 func (r *Rule) HasVerb(verb Verb) bool {
 	for _, item := range r.Verbs {
@@ -143,22 +241,94 @@ func (r *Rule) HasResourceType(resource Resource) bool {
 }
 
 // MatchesResourceName returns true if any of the resource patterns in this rule
-// match the given name.
+// match the given name according to the rule's pattern type. Compiled regexes are
+// cached across calls.
 func (r *Rule) MatchesResourceName(name string) bool {
+	patternType := r.GetPatternType()
 	for _, pattern := range r.ResourcePatterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			// Should have an external validator to let the user know
-			// there is a bad regex.
-			continue
-		}
-		if re.MatchString(name) {
+		if matchPattern(patternType, pattern, name) {
 			return true
 		}
 	}
 	return false
 }
 
+func matchPattern(patternType PatternType, pattern, name string) bool {
+	switch patternType {
+	case PatternTypeExact:
+		return pattern == name
+	case PatternTypeGlob:
+		// Malformed globs never match and are rejected by Validate.
+		matched, err := path.Match(pattern, name)
+		return err == nil && matched
+	case PatternTypeRegex:
+		re := compilePattern(pattern)
+		if re == nil {
+			// Invalid patterns are rejected by Validate when the role is
+			// admitted or saved through the API.
+			return false
+		}
+		return re.MatchString(name)
+	default:
+		return false
+	}
+}
+
+// Validate returns an error for an unknown effect or pattern type, for each resource pattern in
+// this rule that is not valid for its pattern type, for an invalid namespace selector or maximum
+// session duration, and for each problem with its schedule or conditions.
+// fldPath is the path to the rule in the object being validated.
+func (r *Rule) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if r.Effect != "" && r.Effect != EffectAllow && r.Effect != EffectDeny {
+		errs = append(errs, field.NotSupported(fldPath.Child("effect"), r.Effect, []string{string(EffectAllow), string(EffectDeny)}))
+	}
+	switch r.GetPatternType() {
+	case PatternTypeRegex, PatternTypeGlob, PatternTypeExact:
+		for i, pattern := range r.ResourcePatterns {
+			if err := ValidatePattern(r.GetPatternType(), pattern); err != nil {
+				errs = append(errs, field.Invalid(fldPath.Child("resourcePatterns").Index(i), pattern, err.Error()))
+			}
+		}
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("patternType"), r.PatternType, []string{
+			string(PatternTypeRegex), string(PatternTypeGlob), string(PatternTypeExact),
+		}))
+	}
+	if _, err := r.GetNamespaceSelector(); err != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("namespaceSelector"), r.NamespaceSelector, err.Error()))
+	}
+	if r.MaxSessionDuration != "" {
+		if dur, err := time.ParseDuration(r.MaxSessionDuration); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("maxSessionDuration"), r.MaxSessionDuration, err.Error()))
+		} else if dur <= 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("maxSessionDuration"), r.MaxSessionDuration, "must be positive"))
+		}
+	}
+	if r.Schedule != nil {
+		errs = append(errs, r.Schedule.Validate(fldPath.Child("schedule"))...)
+	}
+	for i := range r.Conditions {
+		errs = append(errs, r.Conditions[i].Validate(fldPath.Child("conditions").Index(i))...)
+	}
+	return errs
+}
+
+// ValidatePattern returns an error if the given resource pattern is not valid for the
+// pattern type. Exact patterns are always valid.
+func ValidatePattern(patternType PatternType, pattern string) error {
+	switch patternType {
+	case PatternTypeRegex:
+		_, err := regexp.Compile(pattern)
+		return err
+	case PatternTypeGlob:
+		// matching against an empty name still checks the syntax of the whole pattern
+		_, err := path.Match(pattern, "")
+		return err
+	}
+	return nil
+}
+
 // HasNamespace returns true if this rule includes the given namespace.
 func (r *Rule) HasNamespace(ns string) bool {
 	for _, item := range r.Namespaces {
@@ -171,3 +341,71 @@ func (r *Rule) HasNamespace(ns string) bool {
 	}
 	return false
 }
+
+// HasNamespaceRestriction returns true if this rule lists namespaces or selects them by
+// their labels.
+func (r *Rule) HasNamespaceRestriction() bool {
+	return len(r.Namespaces) > 0 || r.NamespaceSelector != nil
+}
+
+// GetNamespaceSelector converts the namespace selector of this rule to a labels.Selector.
+// A nil selector is returned when the rule does not select namespaces by their labels.
+func (r *Rule) GetNamespaceSelector() (labels.Selector, error) {
+	if r.NamespaceSelector == nil {
+		return nil, nil
+	}
+	return metav1.LabelSelectorAsSelector(r.NamespaceSelector)
+}
+
+// MatchesNamespaceLabels returns true if this rule has a namespace selector matching the
+// given labels. Invalid selectors never match and are rejected by Validate.
+func (r *Rule) MatchesNamespaceLabels(nsLabels map[string]string) bool {
+	selector, err := r.GetNamespaceSelector()
+	if err != nil || selector == nil {
+		return false
+	}
+	return selector.Matches(labels.Set(nsLabels))
+}
+
+// MatchesNamespace returns true if the given namespace is listed in this rule, or carries
+// labels matched by its namespace selector.
+func (r *Rule) MatchesNamespace(ns string, nsLabels map[string]string) bool {
+	return r.HasNamespace(ns) || r.MatchesNamespaceLabels(nsLabels)
+}
+
+// HasTeam returns true if this rule includes the given team.
+func (r *Rule) HasTeam(team string) bool {
+	for _, item := range r.Teams {
+		if item == team {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesResourceTeams returns true if any of the given teams are included in this rule.
+// The teams provided should include the ancestors of the teams the resource belongs to,
+// so that the rule matches a team and all of its descendants.
+func (r *Rule) MatchesResourceTeams(teams []string) bool {
+	for _, team := range teams {
+		if r.HasTeam(team) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesResource returns true if the given resource is matched by this rule. When the
+// rule contains both resource patterns and teams, the resource must match both.
+func (r *Rule) MatchesResource(name string, teams []string) bool {
+	if len(r.ResourcePatterns) == 0 && len(r.Teams) == 0 {
+		return false
+	}
+	if len(r.ResourcePatterns) > 0 && !r.MatchesResourceName(name) {
+		return false
+	}
+	if len(r.Teams) > 0 && !r.MatchesResourceTeams(teams) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"regexp"
+	"sync"
+)
+
+// maxCachedPatterns bounds the number of compiled resource patterns held in memory. The
+// cache is dropped when it is exceeded, which only happens if roles churn faster than
+// ResetPatternCache is called.
+const maxCachedPatterns = 4096
+
+var (
+	patternCache    = make(map[string]*regexp.Regexp)
+	patternCacheMux sync.RWMutex
+)
+
+// compilePattern returns the compiled form of the given resource pattern, compiling and
+// caching it on first use. Invalid patterns are cached as nil so they are not recompiled
+// on every check either.
+func compilePattern(pattern string) *regexp.Regexp {
+	patternCacheMux.RLock()
+	re, ok := patternCache[pattern]
+	patternCacheMux.RUnlock()
+	if ok {
+		return re
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+
+	patternCacheMux.Lock()
+	defer patternCacheMux.Unlock()
+	if len(patternCache) >= maxCachedPatterns {
+		patternCache = make(map[string]*regexp.Regexp)
+	}
+	patternCache[pattern] = re
+	return re
+}
+
+// ResetPatternCache drops all compiled resource patterns. It should be called when VDIRoles
+// change so that patterns no longer in use are not held in memory.
+func ResetPatternCache() {
+	patternCacheMux.Lock()
+	defer patternCacheMux.Unlock()
+	patternCache = make(map[string]*regexp.Regexp)
+}
@@ -0,0 +1,121 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func cachedPatterns() int {
+	patternCacheMux.RLock()
+	defer patternCacheMux.RUnlock()
+	return len(patternCache)
+}
+
+func TestCompilePattern(t *testing.T) {
+	ResetPatternCache()
+	defer ResetPatternCache()
+
+	for _, tc := range []struct {
+		pattern string
+		valid   bool
+		name    string
+		matches bool
+	}{
+		{pattern: "^team-a-.*$", valid: true, name: "team-a-desktop", matches: true},
+		{pattern: "^team-a-.*$", valid: true, name: "team-b-desktop", matches: false},
+		{pattern: "ubuntu|arch", valid: true, name: "arch", matches: true},
+		{pattern: "team-(a", valid: false, name: "team-(a", matches: false},
+		{pattern: "[", valid: false, name: "[", matches: false},
+	} {
+		re := compilePattern(tc.pattern)
+		if tc.valid && re == nil {
+			t.Errorf("Expected %q to compile", tc.pattern)
+		} else if !tc.valid && re != nil {
+			t.Errorf("Expected %q to be cached as nil, got: %s", tc.pattern, re)
+		}
+		if cached, ok := patternCache[tc.pattern]; !ok || cached != re {
+			t.Errorf("Expected %q to be cached", tc.pattern)
+		}
+		if again := compilePattern(tc.pattern); again != re {
+			t.Errorf("Expected a cache hit for %q to return the same regexp", tc.pattern)
+		}
+		if matched := matchPattern(PatternTypeRegex, tc.pattern, tc.name); matched != tc.matches {
+			t.Errorf("Expected %q matching %q to be %v, got: %v", tc.pattern, tc.name, tc.matches, matched)
+		}
+	}
+	if count := cachedPatterns(); count != 4 {
+		t.Error("Expected each distinct pattern to be cached once, got:", count)
+	}
+}
+
+func TestPatternCacheLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fill     int
+		reset    bool
+		expected int
+	}{
+		{name: "below limit", fill: maxCachedPatterns - 1, expected: maxCachedPatterns},
+		{name: "at limit", fill: maxCachedPatterns, expected: 1},
+		{name: "reset", fill: 10, reset: true, expected: 1},
+	} {
+		ResetPatternCache()
+		for i := 0; i < tc.fill; i++ {
+			compilePattern(fmt.Sprintf("^pattern-%d$", i))
+		}
+		if tc.reset {
+			ResetPatternCache()
+			if count := cachedPatterns(); count != 0 {
+				t.Errorf("%s: expected an empty cache after reset, got: %d", tc.name, count)
+			}
+		}
+		re := compilePattern("^last$")
+		if re == nil || !re.MatchString("last") {
+			t.Errorf("%s: expected the pattern to compile after filling the cache", tc.name)
+		}
+		if count := cachedPatterns(); count != tc.expected {
+			t.Errorf("%s: expected %d cached patterns, got: %d", tc.name, tc.expected, count)
+		}
+	}
+	ResetPatternCache()
+}
+
+func BenchmarkCompilePattern(b *testing.B) {
+	ResetPatternCache()
+	defer ResetPatternCache()
+	pattern := "^team-[a-z]+-(ubuntu|arch)-[0-9]+$"
+	for i := 0; i < b.N; i++ {
+		compilePattern(pattern)
+	}
+}
+
+func BenchmarkCompilePatternUncached(b *testing.B) {
+	pattern := "^team-[a-z]+-(ubuntu|arch)-[0-9]+$"
+	for i := 0; i < b.N; i++ {
+		if _, err := regexp.Compile(pattern); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
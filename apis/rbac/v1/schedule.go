@@ -0,0 +1,94 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Schedule restricts the times at which a rule applies, for example to limit launching
+// templates to business hours.
+type Schedule struct {
+	// The IANA time zone the windows are expressed in (e.g. `America/New_York`). Defaults
+	// to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// The windows during which the rule applies. The rule does not apply outside of all of
+	// them.
+	Windows []AccessWindow `json:"windows"`
+}
+
+// Location returns the time zone of the schedule.
+func (s *Schedule) Location() (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.TimeZone)
+}
+
+// IsActive returns true if any window in the schedule is open at the given time. Nil
+// schedules are always active.
+func (s *Schedule) IsActive(now time.Time) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	loc, err := s.Location()
+	if err != nil {
+		return false, err
+	}
+	now = now.In(loc)
+	for _, window := range s.Windows {
+		if window.isOpenAt(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Validate returns an error for an unknown time zone, a schedule without windows, and each
+// window time that is not in `HH:MM` format.
+func (s *Schedule) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if _, err := s.Location(); err != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("timeZone"), s.TimeZone, err.Error()))
+	}
+	if len(s.Windows) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("windows"), "a schedule must have at least one window"))
+	}
+	for i, window := range s.Windows {
+		windowPath := fldPath.Child("windows").Index(i)
+		errs = append(errs, validateClockTime(windowPath.Child("startTime"), window.StartTime)...)
+		errs = append(errs, validateClockTime(windowPath.Child("endTime"), window.EndTime)...)
+	}
+	return errs
+}
+
+func validateClockTime(fldPath *field.Path, val string) field.ErrorList {
+	if val == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", val); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, val, "must be in HH:MM format")}
+	}
+	return nil
+}
@@ -37,11 +37,65 @@ type VDIRole struct {
 
 	// A list of rules granting access to resources in the VDICluster.
 	Rules []Rule `json:"rules,omitempty"`
+	// The local devices members of this role may attach to their sessions.
+	Devices *DevicePolicy `json:"devices,omitempty"`
+	// The hours during which members of this role may log in and connect to desktop
+	// sessions.
+	AccessHours *AccessHours `json:"accessHours,omitempty"`
+	// Limits on the desktop sessions members of this role may run.
+	Quotas *SessionQuotas `json:"quotas,omitempty"`
+	// The share of display bandwidth members of this role receive relative to other users
+	// when an app instance is at its display bandwidth limit. When a user holds multiple
+	// roles, the highest weight applies. Defaults to 1.
+	BandwidthWeight int32 `json:"bandwidthWeight,omitempty"`
+	// The names of other VDIRoles whose rules this role inherits, similar to ClusterRole
+	// aggregation. Inheritance is transitive. The inherited rules are resolved by the
+	// manager and recorded in the status of the role.
+	InheritsFrom []string `json:"inheritsFrom,omitempty"`
+
+	// The observed state of the role.
+	Status VDIRoleStatus `json:"status,omitempty"`
+}
+
+// VDIRoleStatus defines the observed state of a VDIRole.
+type VDIRoleStatus struct {
+	// The rules inherited from the roles in `inheritsFrom` and the roles they inherit from.
+	InheritedRules []Rule `json:"inheritedRules,omitempty"`
+	// The names of the roles rules were inherited from.
+	InheritedRoles []string `json:"inheritedRoles,omitempty"`
+	// The names of roles in the inheritance chain that do not exist.
+	MissingRoles []string `json:"missingRoles,omitempty"`
 }
 
 // GetRules returns the rules for this VDIRole.
 func (v *VDIRole) GetRules() []Rule { return v.Rules }
 
+// GetDevicePolicy returns the device policy for this VDIRole.
+func (v *VDIRole) GetDevicePolicy() *DevicePolicy { return v.Devices }
+
+// GetAccessHours returns the access hours for this VDIRole.
+func (v *VDIRole) GetAccessHours() *AccessHours { return v.AccessHours }
+
+// GetQuotas returns the session quotas for this VDIRole.
+func (v *VDIRole) GetQuotas() *SessionQuotas { return v.Quotas }
+
+// GetBandwidthWeight returns the display bandwidth weight for this VDIRole.
+func (v *VDIRole) GetBandwidthWeight() int32 { return v.BandwidthWeight }
+
+// GetInheritsFrom returns the names of the roles this VDIRole inherits rules from.
+func (v *VDIRole) GetInheritsFrom() []string { return v.InheritsFrom }
+
+// GetEffectiveRules returns the rules of this VDIRole followed by the rules it inherits, as
+// last resolved by the manager.
+func (v *VDIRole) GetEffectiveRules() []Rule {
+	if len(v.InheritsFrom) == 0 || len(v.Status.InheritedRules) == 0 {
+		return v.Rules
+	}
+	rules := make([]Rule, 0, len(v.Rules)+len(v.Status.InheritedRules))
+	rules = append(rules, v.Rules...)
+	return append(rules, v.Status.InheritedRules...)
+}
+
 //+kubebuilder:object:root=true
 
 // VDIRoleList contains a list of VDIRole
@@ -59,22 +113,27 @@ func (v *VDIRoleList) Trim() []*VDIRole {
 		return nil
 	}
 	out := make([]*VDIRole, len(v.Items))
-	for i, role := range v.Items {
-		r := role.DeepCopy()
-		r.SetManagedFields(nil)
-		r.SetOwnerReferences(nil)
-		r.SetGeneration(0)
-		r.SetResourceVersion("")
-		r.SetUID(types.UID(""))
-		if annotations := r.GetAnnotations(); annotations != nil {
-			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
-			r.SetAnnotations(annotations)
-		}
-		out[i] = r
+	for i := range v.Items {
+		out[i] = v.Items[i].Trim()
 	}
 	return out
 }
 
+// Trim returns a copy of this role with unnecessary metadata stripped.
+func (v *VDIRole) Trim() *VDIRole {
+	r := v.DeepCopy()
+	r.SetManagedFields(nil)
+	r.SetOwnerReferences(nil)
+	r.SetGeneration(0)
+	r.SetResourceVersion("")
+	r.SetUID(types.UID(""))
+	if annotations := r.GetAnnotations(); annotations != nil {
+		delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		r.SetAnnotations(annotations)
+	}
+	return r
+}
+
 func init() {
 	SchemeBuilder.Register(&VDIRole{}, &VDIRoleList{})
 }
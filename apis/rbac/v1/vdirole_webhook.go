@@ -0,0 +1,79 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the VDIRole validating webhook with the manager.
+func (v *VDIRole) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(v).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-rbac-kvdi-io-v1-vdirole,mutating=false,failurePolicy=fail,sideEffects=None,groups=rbac.kvdi.io,resources=vdiroles,verbs=create;update,versions=v1,name=vvdirole.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VDIRole{}
+
+// ValidateCreate implements webhook.Validator.
+func (v *VDIRole) ValidateCreate() error { return v.Validate() }
+
+// ValidateUpdate implements webhook.Validator.
+func (v *VDIRole) ValidateUpdate(old runtime.Object) error { return v.Validate() }
+
+// ValidateDelete implements webhook.Validator. Deletes are always allowed.
+func (v *VDIRole) ValidateDelete() error { return nil }
+
+// Validate checks the rules, quotas, and inherited roles of this VDIRole and returns an Invalid
+// error listing every field that is misconfigured, or nil if the role is valid.
+func (v *VDIRole) Validate() error {
+	errs := field.ErrorList{}
+	rulesPath := field.NewPath("rules")
+	for i := range v.Rules {
+		errs = append(errs, v.Rules[i].Validate(rulesPath.Index(i))...)
+	}
+	inheritsPath := field.NewPath("inheritsFrom")
+	for i, name := range v.InheritsFrom {
+		switch name {
+		case "":
+			errs = append(errs, field.Required(inheritsPath.Index(i), "the name of a role is required"))
+		case v.GetName():
+			errs = append(errs, field.Invalid(inheritsPath.Index(i), name, "a role cannot inherit from itself"))
+		}
+	}
+	if v.Quotas != nil {
+		errs = append(errs, v.Quotas.Validate(field.NewPath("quotas"))...)
+	}
+	if v.BandwidthWeight < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("bandwidthWeight"), v.BandwidthWeight, "must not be negative"))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("VDIRole").GroupKind(), v.GetName(), errs)
+}
@@ -0,0 +1,94 @@
+/*
+
+	Copyright 2020,2021 Avi Zimmerman
+
+	This file is part of kvdi.
+
+	kvdi is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	kvdi is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=vditeams,scope=Cluster
+//+kubebuilder:printcolumn:name="Parent",type="string",JSONPath=".spec.parent"
+
+// VDITeam is the Schema for the vditeams API. Teams form a hierarchy through
+// their parent references. Members of a team are granted the roles bound to it
+// and to all of its ancestors.
+type VDITeam struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VDITeamSpec `json:"spec,omitempty"`
+}
+
+// VDITeamSpec defines the desired state of a VDITeam.
+type VDITeamSpec struct {
+	// The name of the parent team, if any.
+	Parent string `json:"parent,omitempty"`
+	// The names of the users that are members of this team.
+	Members []string `json:"members,omitempty"`
+	// The names of the VDIRoles bound to this team. Members of this team and of all
+	// of its descendants are granted these roles.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// GetParent returns the name of the parent team, or an empty string if this is
+// a top-level team.
+func (t *VDITeam) GetParent() string { return t.Spec.Parent }
+
+// GetMembers returns the names of the users that are members of this team.
+func (t *VDITeam) GetMembers() []string { return t.Spec.Members }
+
+// GetRoles returns the names of the roles bound to this team.
+func (t *VDITeam) GetRoles() []string { return t.Spec.Roles }
+
+// HasMember returns true if the given user is a member of this team.
+func (t *VDITeam) HasMember(username string) bool {
+	for _, member := range t.Spec.Members {
+		if member == username {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole returns true if the given role is bound to this team.
+func (t *VDITeam) HasRole(role string) bool {
+	for _, name := range t.Spec.Roles {
+		if name == role {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// VDITeamList contains a list of VDITeam
+type VDITeamList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VDITeam `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VDITeam{}, &VDITeamList{})
+}
@@ -0,0 +1,39 @@
+#!/bin/bash
+
+DEFAULT_SOCK_ADDR="unix:///var/run/kvdi/display.sock"
+DEFAULT_GEOMETRY="1920x1080"
+
+export DISPLAY_SOCK_ADDR=${DISPLAY_SOCK_ADDR:-$DEFAULT_SOCK_ADDR}
+export GEOMETRY=${GEOMETRY:-$DEFAULT_GEOMETRY}
+
+if [[ -z "${RDP_ADDRESS}" ]] ; then
+  echo "RDP_ADDRESS is not set"
+  exit 1
+fi
+
+if [[ "${DISPLAY_SOCK_ADDR}" =~ ^unix://* ]] ; then
+  socket_address=${DISPLAY_SOCK_ADDR#"unix://"}
+  rm -f "${socket_address}"
+  VNC_ARGS="-rfbunixpath ${socket_address} -rfbport -1"
+else
+  address=${DISPLAY_SOCK_ADDR#"tcp://"}
+  VNC_ARGS="-rfbport $(echo ${address} | cut -d ":" -f2)"
+fi
+
+# The proxy is the only client of the VNC server and is already authenticated
+Xvnc :0 ${VNC_ARGS} ${VNC_CLIPBOARD_ARGS} -SecurityTypes None -geometry ${GEOMETRY} -depth 24 &
+export DISPLAY=:0
+
+RDP_ARGS="/v:${RDP_ADDRESS} /f /cert:ignore /dynamic-resolution +clipboard"
+[[ -n "${RDP_USERNAME}" ]] && RDP_ARGS="${RDP_ARGS} /u:${RDP_USERNAME}"
+[[ -n "${RDP_DOMAIN}" ]] && RDP_ARGS="${RDP_ARGS} /d:${RDP_DOMAIN}"
+
+# Reconnect whenever the RDP session ends so the display stays available
+while true ; do
+  if [[ -n "${RDP_PASSWORD}" ]] ; then
+    echo "${RDP_PASSWORD}" | xfreerdp ${RDP_ARGS} /from-stdin:force
+  else
+    xfreerdp ${RDP_ARGS}
+  fi
+  sleep 2
+done
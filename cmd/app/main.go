@@ -23,6 +23,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
@@ -36,10 +37,14 @@ import (
 var applogger = logf.Log.WithName("app")
 
 func main() {
-	var vdiCluster string
-	var enableCORS bool
+	var vdiCluster, pathPrefix string
+	var enableCORS, requestClientCerts, wakeListener, validate bool
 	flag.StringVar(&vdiCluster, "vdi-cluster", "", "The VDICluster this application is serving")
+	flag.StringVar(&pathPrefix, "path-prefix", "", "A path prefix to serve the app under in addition to the root")
 	flag.BoolVar(&enableCORS, "enable-cors", false, "Add CORS headers to requests")
+	flag.BoolVar(&requestClientCerts, "request-client-certs", false, "Request TLS client certificates for device trust checks")
+	flag.BoolVar(&wakeListener, "wake-listener", false, "Serve a wake-up page instead of the app while the cluster is scaled down")
+	flag.BoolVar(&validate, "validate-config", false, "Check the VDICluster configuration end-to-end, print a readiness report, and exit")
 	common.ParseFlagsAndSetupLogging()
 
 	common.PrintVersion(applogger)
@@ -51,8 +56,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	// validate the configuration and exit if requested
+	if validate {
+		ready, err := validateConfig(cfg, vdiCluster)
+		if err != nil {
+			applogger.Error(err, "Failed to validate the configuration")
+			os.Exit(1)
+		}
+		if !ready {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// build the server
-	srvr, err := newServer(cfg, vdiCluster, enableCORS)
+	var srvr *http.Server
+	if wakeListener {
+		srvr, err = newWakeServer(cfg, vdiCluster, pathPrefix)
+	} else {
+		srvr, err = newServer(cfg, vdiCluster, pathPrefix, enableCORS, requestClientCerts)
+	}
 	if err != nil {
 		applogger.Error(err, "Failed to build the server router")
 		os.Exit(1)
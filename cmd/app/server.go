@@ -20,19 +20,23 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/api"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/rest"
 )
 
@@ -65,19 +69,13 @@ func formatLog(writer io.Writer, params handlers.LogFormatterParams) {
 	}
 }
 
-func newServer(cfg *rest.Config, vdiCluster string, enableCORS bool) (*http.Server, error) {
+func newServer(cfg *rest.Config, vdiCluster, pathPrefix string, enableCORS, requestClientCerts bool) (*http.Server, error) {
 	// build the api router with our kubeconfig
 	apiRouter, err := api.NewFromConfig(cfg, vdiCluster)
 	if err != nil {
 		return nil, err
 	}
-
-	r := mux.NewRouter()
-
-	// api routes
-	r.PathPrefix("/api").Handler(apiRouter)
-	// vue frontend
-	r.PathPrefix("/").Handler(http.FileServer(http.Dir("/static")))
+	r := newRouter(apiRouter, http.FileServer(http.Dir("/static")), pathPrefix)
 
 	wrappedRouter := handlers.ProxyHeaders(
 		handlers.CompressHandler(
@@ -89,11 +87,42 @@ func newServer(cfg *rest.Config, vdiCluster string, enableCORS bool) (*http.Serv
 		wrappedRouter = handlers.CORS()(wrappedRouter)
 	}
 
-	return &http.Server{
-		Handler: wrappedRouter,
-		Addr:    fmt.Sprintf(":%d", v1.WebPort),
+	srvr := &http.Server{
+		Handler:     wrappedRouter,
+		Addr:        fmt.Sprintf(":%d", v1.WebPort),
+		ConnContext: apiutil.ConnContext,
 		// TODO: make these configurable (currently high for large dir transfers)
 		WriteTimeout: 300 * time.Second,
 		ReadTimeout:  300 * time.Second,
-	}, nil
+	}
+
+	if requestClientCerts {
+		// Certificates are verified by the API against the configured device trust issuers,
+		// clients without one are still allowed to connect.
+		srvr.TLSConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+	}
+
+	return srvr, nil
+}
+
+// newRouter returns a router serving the api and static handlers at the root, and also
+// under pathPrefix when one is configured.
+func newRouter(apiRouter, staticHandler http.Handler, pathPrefix string) *mux.Router {
+	r := mux.NewRouter()
+
+	// everything is also served under the path prefix, if configured
+	if pathPrefix = strings.TrimSuffix(pathPrefix, "/"); pathPrefix != "" {
+		r.Path(pathPrefix).Handler(http.RedirectHandler(pathPrefix+"/", http.StatusMovedPermanently))
+		r.PathPrefix(pathPrefix + "/api").Handler(http.StripPrefix(pathPrefix, apiRouter))
+		r.PathPrefix(pathPrefix + "/").Handler(http.StripPrefix(pathPrefix, staticHandler))
+	}
+
+	// api routes
+	r.PathPrefix("/api").Handler(apiRouter)
+	// metrics at the conventional path, they are also served by the api at /api/metrics
+	r.Path("/metrics").Handler(promhttp.Handler())
+	// vue frontend
+	r.PathPrefix("/").Handler(staticHandler)
+
+	return r
 }
@@ -0,0 +1,78 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouterPathPrefix(t *testing.T) {
+	staticDir, err := ioutil.TempDir("", "kvdi-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(staticDir)
+	if err := ioutil.WriteFile(filepath.Join(staticDir, "app.js"), []byte("console.log('kvdi')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	apiRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Api-Path", r.URL.Path)
+	})
+	staticHandler := http.FileServer(http.Dir(staticDir))
+
+	for _, tc := range []struct {
+		prefix, path     string
+		code             int
+		location         string
+		apiPath, content string
+	}{
+		// the empty default serves everything at the root
+		{prefix: "", path: "/api/whoami", code: http.StatusOK, apiPath: "/api/whoami"},
+		{prefix: "", path: "/app.js", code: http.StatusOK, content: "console.log('kvdi')"},
+		{prefix: "", path: "/vdi/app.js", code: http.StatusNotFound},
+		// a prefix serves everything under it, and still at the root
+		{prefix: "/vdi/", path: "/vdi", code: http.StatusMovedPermanently, location: "/vdi/"},
+		{prefix: "/vdi/", path: "/vdi/api/whoami", code: http.StatusOK, apiPath: "/api/whoami"},
+		{prefix: "/vdi/", path: "/vdi/app.js", code: http.StatusOK, content: "console.log('kvdi')"},
+		{prefix: "/vdi/", path: "/api/whoami", code: http.StatusOK, apiPath: "/api/whoami"},
+		{prefix: "/vdi/", path: "/app.js", code: http.StatusOK, content: "console.log('kvdi')"},
+	} {
+		rr := httptest.NewRecorder()
+		newRouter(apiRouter, staticHandler, tc.prefix).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rr.Code != tc.code {
+			t.Errorf("%q %s: expected status %d, got: %d", tc.prefix, tc.path, tc.code, rr.Code)
+			continue
+		}
+		if location := rr.Header().Get("Location"); location != tc.location {
+			t.Errorf("%q %s: expected redirect to %q, got: %q", tc.prefix, tc.path, tc.location, location)
+		}
+		if apiPath := rr.Header().Get("X-Api-Path"); apiPath != tc.apiPath {
+			t.Errorf("%q %s: expected the api to see %q, got: %q", tc.prefix, tc.path, tc.apiPath, apiPath)
+		}
+		if tc.content != "" && rr.Body.String() != tc.content {
+			t.Errorf("%q %s: expected the static file, got: %q", tc.prefix, tc.path, rr.Body.String())
+		}
+	}
+}
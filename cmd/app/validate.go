@@ -0,0 +1,63 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/preflight"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateConfig checks the configuration of the given VDICluster end-to-end and writes the
+// report to stdout. It returns whether the configuration is ready to serve traffic.
+func validateConfig(cfg *rest.Config, vdiCluster string) (bool, error) {
+	scheme := runtime.NewScheme()
+	if err := appv1.AddToScheme(scheme); err != nil {
+		return false, err
+	}
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		return false, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return false, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return false, err
+	}
+	cluster := &appv1.VDICluster{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: vdiCluster}, cluster); err != nil {
+		return false, err
+	}
+	report := preflight.Validate(context.TODO(), c, cluster, nil)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return report.Ready, enc.Encode(report)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/gorilla/handlers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wakeRecordInterval limits how often wake-up requests are written to the cluster.
+const wakeRecordInterval = 30 * time.Second
+
+// businessHoursInterval is how often the wake-up listener checks if business hours
+// started, so a manager that was scaled down can be brought back up.
+const businessHoursInterval = time.Minute
+
+// wakePage is served to browsers while the app is scaled down. It refreshes until the
+// app server is serving requests again.
+const wakePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>kVDI</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 20%%;">
+<h2>kVDI is waking up</h2>
+<p>The desktop environment was scaled down outside of business hours. This page will refresh automatically once it is ready.</p>
+</body>
+</html>
+`
+
+// wakeListener serves requests while the app deployment is scaled down for energy saving.
+// Every request, other than health checks and metric scrapes, is recorded as a wake-up
+// request for the manager to act on.
+type wakeListener struct {
+	client     client.Client
+	vdiCluster string
+	pathPrefix string
+	lastRecord time.Time
+	mux        sync.Mutex
+}
+
+func newWakeServer(cfg *rest.Config, vdiCluster, pathPrefix string) (*http.Server, error) {
+	scheme := runtime.NewScheme()
+	if err := appv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	listener := &wakeListener{client: c, vdiCluster: vdiCluster, pathPrefix: strings.TrimSuffix(pathPrefix, "/")}
+	go listener.watchBusinessHours()
+	return &http.Server{
+		Handler:      handlers.CustomLoggingHandler(os.Stdout, listener, formatLog),
+		Addr:         fmt.Sprintf(":%d", v1.WebPort),
+		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  10 * time.Second,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (l *wakeListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if l.pathPrefix != "" && strings.HasPrefix(path, l.pathPrefix+"/") {
+		path = strings.TrimPrefix(path, l.pathPrefix)
+	}
+	switch path {
+	case "/api/readyz":
+		apiutil.WriteOK(w)
+		return
+	case "/api/metrics":
+		// scrapes must not keep the stack awake
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err := l.recordWakeRequest(r.Context()); err != nil {
+		applogger.Error(err, "Failed to record wake-up request")
+	}
+	w.Header().Set("Retry-After", "10")
+	if strings.HasPrefix(path, "/api") {
+		apiutil.WriteOrLogError(
+			errors.ToAPIError(errors.New("kVDI is waking up, try again shortly"), errors.ServerError).JSON(),
+			w, http.StatusServiceUnavailable,
+		)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, wakePage)
+}
+
+// recordWakeRequest writes the current time to the wake ConfigMap of the cluster.
+func (l *wakeListener) recordWakeRequest(ctx context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if time.Since(l.lastRecord) < wakeRecordInterval {
+		return nil
+	}
+	cluster := &appv1.VDICluster{}
+	if err := l.client.Get(ctx, types.NamespacedName{Name: l.vdiCluster}, cluster); err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	if err := l.client.Get(ctx, types.NamespacedName{Name: cluster.GetWakeConfigMapName(), Namespace: cluster.GetCoreNamespace()}, cm); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(cm.DeepCopy())
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[appv1.WakeRequestedAtKey] = time.Now().UTC().Format(time.RFC3339)
+	if err := l.client.Patch(ctx, cm, patch); err != nil {
+		return err
+	}
+	applogger.Info("Recorded wake-up request")
+	l.lastRecord = time.Now()
+	if cluster.ScaleManager() {
+		return l.restoreManager(ctx, cluster)
+	}
+	return nil
+}
+
+// watchBusinessHours brings the manager back up when business hours start, if it was
+// scaled down for energy saving.
+func (l *wakeListener) watchBusinessHours() {
+	ticker := time.NewTicker(businessHoursInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := l.checkBusinessHours(context.Background()); err != nil {
+			applogger.Error(err, "Failed to check business hours")
+		}
+	}
+}
+
+func (l *wakeListener) checkBusinessHours(ctx context.Context) error {
+	cluster := &appv1.VDICluster{}
+	if err := l.client.Get(ctx, types.NamespacedName{Name: l.vdiCluster}, cluster); err != nil {
+		return err
+	}
+	if !cluster.ScaleManager() || !cluster.InBusinessHours(time.Now()) {
+		return nil
+	}
+	return l.restoreManager(ctx, cluster)
+}
+
+// restoreManager scales the manager deployment back to the number of replicas it ran
+// with before it was scaled down.
+func (l *wakeListener) restoreManager(ctx context.Context, cluster *appv1.VDICluster) error {
+	name, namespace := cluster.GetManagerDeployment()
+	deployment := &appsv1.Deployment{}
+	if err := l.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, deployment); err != nil {
+		return err
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0 {
+		return nil
+	}
+	replicas := int32(1)
+	if val, ok := deployment.GetAnnotations()[appv1.AsleepReplicasAnnotation]; ok {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			replicas = int32(n)
+		}
+	}
+	patch := client.MergeFrom(deployment.DeepCopy())
+	delete(deployment.Annotations, appv1.AsleepReplicasAnnotation)
+	deployment.Spec.Replicas = &replicas
+	if err := l.client.Patch(ctx, deployment, patch); err != nil {
+		return err
+	}
+	applogger.Info("Scaled the manager back up", "Replicas", replicas)
+	return nil
+}
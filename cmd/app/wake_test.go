@@ -0,0 +1,112 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestWakeListener(t *testing.T) (*wakeListener, *appv1.VDICluster) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	appsv1.AddToScheme(scheme)
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.EnergySaving = &appv1.EnergySavingConfig{
+		Enabled:           true,
+		ScaleManager:      true,
+		ManagerDeployment: "manager",
+		ManagerNamespace:  "kvdi",
+	}
+	cm := &corev1.ConfigMap{}
+	cm.Name = cluster.GetWakeConfigMapName()
+	cm.Namespace = cluster.GetCoreNamespace()
+	manager := &appsv1.Deployment{}
+	manager.Name = "manager"
+	manager.Namespace = "kvdi"
+	manager.Annotations = map[string]string{appv1.AsleepReplicasAnnotation: "2"}
+	manager.Spec.Replicas = common.Int32Ptr(0)
+	c := fake.NewFakeClientWithScheme(scheme, cluster, cm, manager)
+	return &wakeListener{client: c, vdiCluster: cluster.GetName(), pathPrefix: "/kvdi"}, cluster
+}
+
+func wakeRequestedAt(t *testing.T, l *wakeListener, cluster *appv1.VDICluster) string {
+	t.Helper()
+	cm := &corev1.ConfigMap{}
+	if err := l.client.Get(context.TODO(), types.NamespacedName{Name: cluster.GetWakeConfigMapName(), Namespace: cluster.GetCoreNamespace()}, cm); err != nil {
+		t.Fatal(err)
+	}
+	return cm.Data[appv1.WakeRequestedAtKey]
+}
+
+func managerReplicas(t *testing.T, l *wakeListener) int32 {
+	t.Helper()
+	manager := &appsv1.Deployment{}
+	if err := l.client.Get(context.TODO(), types.NamespacedName{Name: "manager", Namespace: "kvdi"}, manager); err != nil {
+		t.Fatal(err)
+	}
+	return *manager.Spec.Replicas
+}
+
+// TestWakeListener tests that requests wake the stack, and that health checks and metric
+// scrapes do not.
+func TestWakeListener(t *testing.T) {
+	l, cluster := newTestWakeListener(t)
+
+	for _, path := range []string{"/kvdi/api/readyz", "/kvdi/api/metrics", "/api/metrics"} {
+		rr := httptest.NewRecorder()
+		l.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if path != "/kvdi/api/readyz" && rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected %s to be unavailable, got: %d", path, rr.Code)
+		}
+		if val := wakeRequestedAt(t, l, cluster); val != "" {
+			t.Errorf("Expected no wake-up request for %s, got: %s", path, val)
+		}
+		if replicas := managerReplicas(t, l); replicas != 0 {
+			t.Errorf("Expected the manager to stay down for %s, got: %d", path, replicas)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	l.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/kvdi/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Error("Expected the wake-up page, got:", rr.Code)
+	}
+	if val := wakeRequestedAt(t, l, cluster); val == "" {
+		t.Error("Expected a wake-up request to be recorded")
+	}
+	if replicas := managerReplicas(t, l); replicas != 2 {
+		t.Error("Expected the manager to be scaled back up, got:", replicas)
+	}
+}
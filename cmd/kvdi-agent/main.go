@@ -0,0 +1,112 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// The main entrypoint for the kvdi-agent which runs inside desktops. It reports app health
+// and resolution requests to the API, picks up broadcasts, keeps a session kubeconfig
+// renewed when enabled, and serves session metadata to tools inside the desktop. Its
+// configuration is read from the environment of the desktop by default.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/agent"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+)
+
+var log = logf.Log.WithName("kvdi_agent")
+
+func main() {
+	var apiURL, session, tokenFile, caFile, metadataAddr, broadcastCommand, kubeconfigPath string
+	var reportInterval time.Duration
+
+	flag.StringVar(&apiURL, "api-url", os.Getenv(v1.AgentAPIURLEnvVar), "The URL of the kvdi API")
+	flag.StringVar(&session, "session", os.Getenv(v1.AgentSessionEnvVar), "The namespace/name of the session the agent runs in")
+	flag.StringVar(&tokenFile, "token-file", os.Getenv(v1.AgentTokenFileEnvVar), "The file containing the token to authenticate to the API with")
+	flag.StringVar(&caFile, "ca-file", os.Getenv(v1.AgentCAFileEnvVar), "The CA certificate to verify the API with, defaults to the system roots")
+	flag.StringVar(&metadataAddr, "metadata-addr", envOrDefault(v1.AgentMetadataAddrEnvVar, "127.0.0.1:8989"), "The address to serve the local metadata endpoint on")
+	flag.DurationVar(&reportInterval, "report-interval", envDurationOrDefault(v1.AgentReportIntervalEnvVar, v1.DefaultAgentReportInterval), "How often to report to the API")
+	flag.StringVar(&broadcastCommand, "broadcast-command", "", "A command to run with each new broadcast message as its last argument (e.g. notify-send kvdi)")
+	flag.StringVar(&kubeconfigPath, "kubeconfig", os.Getenv(v1.AgentKubeconfigEnvVar), "A path to keep a short-lived kubeconfig for the session renewed at")
+	common.ParseFlagsAndSetupLogging()
+	common.PrintVersion(log)
+
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		log.Error(err, "Failed to read agent token")
+		os.Exit(1)
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			log.Error(err, "Failed to read CA certificate")
+			os.Exit(1)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	}
+
+	client, err := agent.NewClient(apiURL, session, strings.TrimSpace(string(token)), tlsConfig)
+	if err != nil {
+		log.Error(err, "Failed to create API client")
+		os.Exit(1)
+	}
+
+	a := agent.New(log, client, &agent.Opts{
+		ReportInterval:   reportInterval,
+		BroadcastCommand: strings.Fields(broadcastCommand),
+		KubeconfigPath:   kubeconfigPath,
+	})
+	go a.Run(context.Background())
+
+	log.Info("Serving local metadata endpoint", "Address", metadataAddr)
+	if err := http.ListenAndServe(metadataAddr, a.Handler()); err != nil {
+		log.Error(err, "Error running metadata endpoint")
+		os.Exit(1)
+	}
+}
+
+func envOrDefault(name, def string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return def
+}
+
+func envDurationOrDefault(name string, def time.Duration) time.Duration {
+	if dur, err := time.ParseDuration(os.Getenv(name)); err == nil && dur > 0 {
+		return dur
+	}
+	return def
+}
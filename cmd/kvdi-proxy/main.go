@@ -26,15 +26,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/util/validation"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	proxyserver "github.com/tinyzimmer/kvdi/pkg/proxyproto/server"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto/tunnel"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/guac"
 )
 
 // TODO: clean this all up
@@ -45,9 +51,29 @@ var (
 	listenHost string
 
 	userID                                  int
+	homeQuota                               int64
+	homeQuotaWarning                        int
+	sshAddr                                 string
+	ideAddr                                 string
 	pulseServer                             string
 	displayAddr                             string
+	displayPasswordFile                     string
+	displayProtocol                         string
+	rdpPort                                 int
+	rdpSecurity                             string
+	rdpIgnoreCert                           bool
 	displayConnectProto, displayConnectAddr string
+	disableAudio, disableMicrophone         bool
+	audioBitrate, audioSampleRate           int
+	thumbnailInterval                       time.Duration
+	thumbnailWidth                          int
+	tunnelAddr, tunnelSession               string
+	tunnelPoolSize                          int
+	maxMonitors                             int
+	maxMonitorResolution                    string
+	plugins                                 string
+	resizeDisplay                           string
+	metricsAddr                             string
 
 	monitorDeviceName    = "kvdi"
 	monitorDescription   = "kvdi-playback"
@@ -65,8 +91,31 @@ func main() {
 	// parse flags and setup logging
 	flag.StringVar(&listenHost, "listen", "0.0.0.0", "The address to listen for connections on")
 	flag.StringVar(&displayAddr, "display-addr", "unix:///var/run/kvdi/display.sock", "The tcp or unix-socket address of the display server")
+	flag.StringVar(&displayPasswordFile, "display-password-file", "", "A file containing the password for the display server, if it requires one")
+	flag.StringVar(&displayProtocol, "display-protocol", v1.DefaultDisplayProtocol, "The protocol spoken by the display server (novnc, spice, xpra, or rdp)")
+	flag.IntVar(&rdpPort, "rdp-port", v1.DefaultRDPPort, "The port of the RDP server inside the desktop, used with the rdp display protocol")
+	flag.StringVar(&rdpSecurity, "rdp-security", "any", "The security mode to connect to the RDP server with (any, nla, tls, or rdp)")
+	flag.BoolVar(&rdpIgnoreCert, "rdp-ignore-cert", false, "Accept the certificate of the RDP server without verifying it")
 	flag.IntVar(&userID, "user-id", 9000, "The ID of the main user in the desktop container, used for chown operations")
+	flag.Int64Var(&homeQuota, "home-quota", 0, "The maximum size in bytes of the user's home directory, zero for no limit")
+	flag.IntVar(&homeQuotaWarning, "home-quota-warning", 90, "The percentage of the home quota at which to warn about usage")
+	flag.StringVar(&sshAddr, "ssh-addr", "127.0.0.1:22", "The address of the SSH server inside the desktop")
+	flag.StringVar(&ideAddr, "ide-addr", "", "The address of the IDE server inside the desktop, leave empty to refuse IDE requests")
 	flag.StringVar(&pulseServer, "pulse-server", "", "The socket where pulseaudio is accepting connections. Defaults to /run/user/<userID>/pulse/native")
+	flag.BoolVar(&disableAudio, "disable-audio", false, "Refuse requests for audio streams")
+	flag.BoolVar(&disableMicrophone, "disable-microphone", false, "Discard microphone audio sent by clients")
+	flag.IntVar(&audioBitrate, "audio-bitrate", v1.DefaultAudioBitrate, "The bitrate in bits per second to encode audio playback at")
+	flag.IntVar(&audioSampleRate, "audio-sample-rate", v1.DefaultAudioSampleRate, "The sample rate to capture audio playback at")
+	flag.DurationVar(&thumbnailInterval, "thumbnail-interval", 0, "How often to capture thumbnails of the display, zero to disable thumbnails")
+	flag.IntVar(&thumbnailWidth, "thumbnail-width", v1.DefaultThumbnailMaxWidth, "The maximum width in pixels of captured thumbnails")
+	flag.StringVar(&tunnelAddr, "tunnel-address", "", "The address of the app to open reverse tunnels to, leave empty to only accept direct connections")
+	flag.StringVar(&tunnelSession, "tunnel-session", "", "The namespace/name of the desktop session to open reverse tunnels for")
+	flag.IntVar(&tunnelPoolSize, "tunnel-pool-size", 4, "The number of idle reverse tunnels to keep open to the app")
+	flag.IntVar(&maxMonitors, "max-monitors", 1, "The maximum number of monitors the desktop may span")
+	flag.StringVar(&maxMonitorResolution, "max-monitor-resolution", fmt.Sprintf("%dx%d", v1.DefaultMaxMonitorWidth, v1.DefaultMaxMonitorHeight), "The maximum resolution of each monitor, in WIDTHxHEIGHT format")
+	flag.StringVar(&plugins, "plugins", "", "A comma-separated list of the sidecar plugins declared for the desktop")
+	flag.StringVar(&resizeDisplay, "resize-display", "", "The X display to resize with xrandr when clients request it, leave empty to disable resizing")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "The address to serve prometheus metrics on over plain HTTP, leave empty to disable metrics")
 	common.ParseFlagsAndSetupLogging()
 	common.PrintVersion(log)
 
@@ -83,6 +132,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Parse the maximum resolution of additional monitors
+	maxMonitorWidth, maxMonitorHeight, err := parseResolution(maxMonitorResolution)
+	if err != nil {
+		log.Error(err, "Invalid maximum monitor resolution")
+		os.Exit(1)
+	}
+
+	// Plugin names become the names of their sockets, so only accept DNS labels
+	pluginNames := splitPlugins(plugins)
+	for _, name := range pluginNames {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			log.Info(fmt.Sprintf("%s is an invalid plugin name: %s", name, strings.Join(errs, ", ")))
+			os.Exit(1)
+		}
+	}
+
+	// Read the display password if the display server requires one
+	var displayPassword string
+	if displayPasswordFile != "" {
+		pw, err := ioutil.ReadFile(displayPasswordFile)
+		if err != nil {
+			log.Error(err, "Failed to read display password file")
+			os.Exit(1)
+		}
+		displayPassword = strings.TrimRight(string(pw), "\r\n")
+	}
+
+	// RDP displays are served by guacd, which is told how to reach the RDP server
+	// during the handshake. Credentials are read from the environment.
+	var rdpOpts *guac.ConnectOptions
+	if displayProtocol == "rdp" {
+		rdpOpts = &guac.ConnectOptions{
+			Protocol: "rdp",
+			Params: map[string]string{
+				"hostname":      "127.0.0.1",
+				"port":          strconv.Itoa(rdpPort),
+				"security":      rdpSecurity,
+				"ignore-cert":   strconv.FormatBool(rdpIgnoreCert),
+				"username":      os.Getenv(v1.RDPUsernameEnvVar),
+				"password":      os.Getenv(v1.RDPPasswordEnvVar),
+				"domain":        os.Getenv(v1.RDPDomainEnvVar),
+				"resize-method": "display-update",
+			},
+			Width:          1024,
+			Height:         768,
+			DPI:            96,
+			AudioMimetypes: []string{"audio/L8", "audio/L16"},
+			ImageMimetypes: []string{"image/jpeg", "image/png", "image/webp"},
+		}
+	}
+
 	// Populate the default pulseserver path if not set on the command line
 	if pulseServer == "" {
 		pulseServer = fmt.Sprintf("/run/user/%d/pulse/native", userID)
@@ -94,9 +194,15 @@ func main() {
 		FSUserID:                   userID,
 		DisplayAddress:             displayConnectAddr,
 		DisplayProto:               displayConnectProto,
+		DisplayPassword:            displayPassword,
+		DisplayProtocol:            displayProtocol,
+		RDP:                        rdpOpts,
 		PulseServer:                pulseServer,
+		AudioDisabled:              disableAudio,
+		MicrophoneDisabled:         disableMicrophone,
 		PlaybackDeviceName:         monitorDeviceName,
-		PlaybackSampleRate:         24000, // TODO
+		PlaybackSampleRate:         audioSampleRate,
+		PlaybackBitrate:            audioBitrate,
 		PlaybackDeviceDescription:  monitorDescription,
 		RecordingDeviceName:        micDeviceName,
 		RecordingDeviceDescription: micDeviceDescription,
@@ -104,10 +210,68 @@ func main() {
 		RecordingDeviceFormat:      micDeviceFormat,
 		RecordingDeviceSampleRate:  micDeviceSampleRate,
 		RecordingDeviceChannels:    micDeviceChannels,
+		HomeQuota:                  homeQuota,
+		HomeQuotaWarningThreshold:  homeQuotaWarning,
+		SSHAddress:                 sshAddr,
+		IDEAddress:                 ideAddr,
+		ThumbnailInterval:          thumbnailInterval,
+		ThumbnailMaxWidth:          thumbnailWidth,
+		MaxMonitors:                maxMonitors,
+		MaxMonitorWidth:            maxMonitorWidth,
+		MaxMonitorHeight:           maxMonitorHeight,
+		Plugins:                    pluginNames,
+		ResizeDisplay:              resizeDisplay,
 	})
 
+	if tunnelAddr != "" {
+		session, err := tunnel.ParseSession(tunnelSession)
+		if err != nil {
+			log.Error(err, "Invalid tunnel session")
+			os.Exit(1)
+		}
+		go func() {
+			if err := server.ServeTunnel(tunnelAddr, session, tunnelPoolSize); err != nil {
+				log.Error(err, "Error running reverse tunnels")
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if metricsAddr != "" {
+		go func() {
+			if err := server.ServeMetrics(metricsAddr); err != nil {
+				log.Error(err, "Error serving metrics")
+				os.Exit(1)
+			}
+		}()
+	}
+
 	if err := server.ListenAndServe(); err != nil {
 		log.Error(err, "Error running proxy server")
 		os.Exit(1)
 	}
 }
+
+// parseResolution parses a resolution in WIDTHxHEIGHT format.
+func parseResolution(res string) (width, height int64, err error) {
+	parts := strings.Split(res, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%s is not in WIDTHxHEIGHT format", res)
+	}
+	if width, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return
+	}
+	height, err = strconv.ParseInt(parts[1], 10, 64)
+	return
+}
+
+// splitPlugins returns the names in a comma-separated list of plugins.
+func splitPlugins(list string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/backup"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// backupPassphraseEnvVar is the environment variable holding the passphrase used to
+// encrypt and decrypt archives.
+const backupPassphraseEnvVar = "KVDI_BACKUP_PASSPHRASE"
+
+// exportBackup writes an encrypted archive of the named VDICluster to path.
+func exportBackup(cfg *rest.Config, clusterName, path string) error {
+	passphrase := os.Getenv(backupPassphraseEnvVar)
+	if passphrase == "" {
+		return fmt.Errorf("%s must be set to create a backup", backupPassphraseEnvVar)
+	}
+	c, cluster, engine, err := setupBackupClients(cfg, clusterName)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	archive, err := backup.Export(context.TODO(), c, cluster, engine)
+	if err != nil {
+		return err
+	}
+	data, err := archive.Encrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	setupLog.Info("Wrote backup", "Cluster", cluster.GetName(), "Path", path)
+	return nil
+}
+
+// restoreBackup restores the encrypted archive at path to the named VDICluster.
+func restoreBackup(cfg *rest.Config, clusterName, path string, skipClusterConfig bool) error {
+	passphrase := os.Getenv(backupPassphraseEnvVar)
+	if passphrase == "" {
+		return fmt.Errorf("%s must be set to restore a backup", backupPassphraseEnvVar)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	archive, err := backup.Decrypt(data, passphrase)
+	if err != nil {
+		return err
+	}
+	c, cluster, engine, err := setupBackupClients(cfg, clusterName)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	res, err := backup.Restore(context.TODO(), c, cluster, engine, archive, &backup.RestoreOptions{
+		SkipClusterConfig: skipClusterConfig,
+	})
+	if err != nil {
+		return err
+	}
+	setupLog.Info("Restored backup",
+		"Cluster", res.Cluster,
+		"Source", archive.Cluster,
+		"Roles", res.Roles,
+		"Teams", res.Teams,
+		"Templates", res.Templates,
+		"Secrets", res.Secrets,
+		"ClusterConfig", res.ClusterConfig,
+	)
+	return nil
+}
+
+// setupBackupClients returns a client, the named VDICluster, and a secrets engine set up
+// for it. If no name is given and only one VDICluster exists, that one is used.
+func setupBackupClients(cfg *rest.Config, clusterName string) (client.Client, *appv1.VDICluster, *secrets.SecretEngine, error) {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var cluster *appv1.VDICluster
+	if clusterName != "" {
+		cluster, err = k8sutil.LookupClusterByName(c, clusterName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		clusters := &appv1.VDIClusterList{}
+		if err := c.List(context.TODO(), clusters); err != nil {
+			return nil, nil, nil, err
+		}
+		if len(clusters.Items) != 1 {
+			return nil, nil, nil, errors.New("--vdi-cluster must be set when there is not exactly one VDICluster")
+		}
+		cluster = &clusters.Items[0]
+	}
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(c, cluster); err != nil {
+		return nil, nil, nil, err
+	}
+	return c, cluster, engine, nil
+}
@@ -0,0 +1,134 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/install"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// installUsage is printed above the flags of the install and uninstall subcommands.
+const installUsage = `Usage: manager install [flags]
+       manager uninstall [flags]
+
+Installs kVDI into the current cluster without Helm, by applying the CRDs, RBAC, and manager
+deployment, followed by a default VDICluster. Running install again upgrades an existing
+installation. Uninstall removes them again, keeping the CRDs (and with them any templates,
+roles, and sessions) unless --delete-crds is given.
+
+Flags:
+`
+
+// runInstaller runs the install or uninstall subcommand with the given arguments and
+// returns the exit code.
+func runInstaller(command string, args []string) int {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), installUsage)
+		fs.PrintDefaults()
+	}
+
+	opts := &install.Options{}
+	var appReplicas int
+	var appImage, appServiceType string
+	var render, skipCluster, deleteCRDs bool
+	fs.StringVar(&opts.Namespace, "namespace", install.DefaultNamespace,
+		"The namespace to install the manager to.")
+	fs.StringVar(&opts.ManagerImage, "manager-image", "",
+		"The image to run the manager with. Defaults to the release matching this binary.")
+	fs.StringVar(&opts.ClusterName, "cluster-name", install.DefaultClusterName,
+		"The name of the default VDICluster.")
+	fs.BoolVar(&skipCluster, "skip-cluster", false,
+		"Do not create a default VDICluster, or when uninstalling, do not remove it.")
+	fs.StringVar(&opts.ClusterSpec.AppNamespace, "app-namespace", "",
+		"The namespace to run the app in for the default VDICluster. Defaults to default.")
+	fs.StringVar(&appImage, "app-image", "",
+		"The image to run the app with for the default VDICluster. Defaults to the release matching the manager.")
+	fs.IntVar(&appReplicas, "app-replicas", 0,
+		"The number of app replicas to run for the default VDICluster. Defaults to 1.")
+	fs.StringVar(&appServiceType, "app-service-type", "",
+		"The type of service to create in front of the app for the default VDICluster. Defaults to LoadBalancer.")
+	fs.BoolVar(&render, "render", false,
+		"Print the manifests to stdout instead of applying them.")
+	fs.BoolVar(&deleteCRDs, "delete-crds", false,
+		"When uninstalling, also remove the CRDs. This deletes every kVDI resource in the cluster.")
+	zapOpts := zap.Options{Development: true}
+	zapOpts.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	logger := ctrl.Log.WithName(command)
+
+	if skipCluster {
+		opts.ClusterName = ""
+	}
+	if appImage != "" || appReplicas > 0 || appServiceType != "" {
+		opts.ClusterSpec.App = &appv1.AppConfig{
+			Image:       appImage,
+			Replicas:    int32(appReplicas),
+			ServiceType: corev1.ServiceType(appServiceType),
+		}
+	}
+
+	objs, err := install.Render(opts)
+	if err != nil {
+		logger.Error(err, "unable to render manifests")
+		return 1
+	}
+	if render {
+		if err := install.WriteYAML(os.Stdout, objs); err != nil {
+			logger.Error(err, "unable to write manifests")
+			return 1
+		}
+		return 0
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "unable to create client")
+		return 1
+	}
+
+	if command == "uninstall" {
+		err = install.Uninstall(context.TODO(), logger, c, objs, deleteCRDs)
+	} else {
+		err = install.Apply(context.TODO(), logger, c, objs)
+	}
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("unable to %s kVDI", command))
+		return 1
+	}
+	logger.Info(fmt.Sprintf("Finished %s", command), "Namespace", opts.GetNamespace())
+	return 0
+}
@@ -42,6 +42,7 @@ import (
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	appcontrollers "github.com/tinyzimmer/kvdi/controllers/app"
 	desktopscontrollers "github.com/tinyzimmer/kvdi/controllers/desktops"
+	rbaccontrollers "github.com/tinyzimmer/kvdi/controllers/rbac"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	//+kubebuilder:scaffold:imports
 )
@@ -62,14 +63,37 @@ func init() {
 }
 
 func main() {
+	// the installer subcommands take their own flags
+	if len(os.Args) > 1 && (os.Args[1] == "install" || os.Args[1] == "uninstall") {
+		os.Exit(runInstaller(os.Args[1], os.Args[2:]))
+	}
+
 	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
+	var enableLeaderElection, validate bool
+	var probeAddr, backupPath, restorePath, vdiCluster string
+	var skipClusterConfig, migrate, skipUpgradeChecks bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&validate, "validate-config", false,
+		"Check the configuration of every VDICluster end-to-end, print readiness reports, and exit.")
+	flag.StringVar(&backupPath, "backup", "",
+		"Write an encrypted backup of a VDICluster to the given path and exit. "+
+			"The passphrase is read from "+backupPassphraseEnvVar+".")
+	flag.StringVar(&restorePath, "restore", "",
+		"Restore the encrypted backup at the given path to a VDICluster and exit. "+
+			"The passphrase is read from "+backupPassphraseEnvVar+".")
+	flag.StringVar(&vdiCluster, "vdi-cluster", "",
+		"The VDICluster to back up or restore to. May be omitted when only one exists.")
+	flag.BoolVar(&skipClusterConfig, "skip-cluster-config", false,
+		"When restoring, do not replace the VDICluster configuration with the one in the backup.")
+	flag.BoolVar(&migrate, "migrate", false,
+		"Run the upgrade checks and migrate stored data for every VDICluster, then exit. "+
+			"The manager also does this every time it starts.")
+	flag.BoolVar(&skipUpgradeChecks, "skip-upgrade-checks", false,
+		"Start the manager without running the upgrade checks and migrations.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -80,7 +104,47 @@ func main() {
 
 	common.PrintVersion(setupLog)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cfg := ctrl.GetConfigOrDie()
+
+	if validate {
+		ready, err := validateConfig(cfg)
+		if err != nil {
+			setupLog.Error(err, "unable to validate configuration")
+			os.Exit(1)
+		}
+		if !ready {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if backupPath != "" {
+		if err := exportBackup(cfg, vdiCluster, backupPath); err != nil {
+			setupLog.Error(err, "unable to create backup")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if restorePath != "" {
+		if err := restoreBackup(cfg, vdiCluster, restorePath, skipClusterConfig); err != nil {
+			setupLog.Error(err, "unable to restore backup")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if migrate || !skipUpgradeChecks {
+		if err := upgradeClusters(cfg); err != nil {
+			setupLog.Error(err, "unable to upgrade to this version")
+			os.Exit(1)
+		}
+		if migrate {
+			os.Exit(0)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
@@ -109,6 +173,38 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Session")
 		os.Exit(1)
 	}
+	if err = (&desktopscontrollers.LabReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("desktops").WithName("Lab"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Lab")
+		os.Exit(1)
+	}
+	if err = (&desktopscontrollers.DesktopPoolReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("desktops").WithName("DesktopPool"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DesktopPool")
+		os.Exit(1)
+	}
+	if err = (&rbaccontrollers.VDIRoleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("rbac").WithName("VDIRole"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VDIRole")
+		os.Exit(1)
+	}
+	// Webhooks need a serving certificate, so they are only set up when the deployment
+	// provides one.
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&rbacv1.VDIRole{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VDIRole")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
@@ -0,0 +1,88 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/preflight"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// upgradeClusters runs the upgrade checks for every VDICluster and migrates the data in
+// their secrets backends to the format used by this version. It returns an error if any
+// cluster cannot safely be managed by this version. Clusters whose secrets backend cannot
+// be reached yet are migrated the next time the manager starts.
+func upgradeClusters(cfg *rest.Config) error {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	clusters := &appv1.VDIClusterList{}
+	if err := c.List(context.TODO(), clusters); err != nil {
+		return err
+	}
+	for i := range clusters.Items {
+		if err := upgradeCluster(c, &clusters.Items[i]); err != nil {
+			return fmt.Errorf("VDICluster %s: %s", clusters.Items[i].GetName(), err.Error())
+		}
+	}
+	return nil
+}
+
+// upgradeCluster runs the upgrade checks and pending migrations for a single cluster.
+func upgradeCluster(c client.Client, cluster *appv1.VDICluster) error {
+	reqLogger := setupLog.WithValues("vdicluster", cluster.GetName())
+
+	engine := secrets.GetSecretEngine(cluster)
+	defer engine.Close()
+	if err := engine.Setup(c, cluster); err != nil {
+		reqLogger.Info("Secrets backend is not ready, skipping migrations", "error", err.Error())
+		engine = nil
+	}
+
+	report := preflight.CheckUpgrade(context.TODO(), c, cluster, engine)
+	for _, check := range report.Checks {
+		switch {
+		case check.Skipped:
+			reqLogger.Info("Skipped upgrade check", "check", check.Name, "reason", check.Message)
+		case !check.Ready:
+			return fmt.Errorf("%s check failed: %s", check.Name, check.Error)
+		default:
+			reqLogger.Info(check.Message, "check", check.Name)
+		}
+	}
+	if engine == nil {
+		return nil
+	}
+
+	applied, err := preflight.Migrate(engine)
+	for _, desc := range applied {
+		reqLogger.Info("Applied migration", "migration", desc)
+	}
+	return err
+}
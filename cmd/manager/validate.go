@@ -0,0 +1,58 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/preflight"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateConfig checks the configuration of every VDICluster end-to-end and writes the
+// reports to stdout. It returns whether all of them are ready to serve traffic.
+func validateConfig(cfg *rest.Config) (bool, error) {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return false, err
+	}
+	clusters := &appv1.VDIClusterList{}
+	if err := c.List(context.TODO(), clusters); err != nil {
+		return false, err
+	}
+	ready := true
+	reports := make([]*types.ConfigValidationReport, 0, len(clusters.Items))
+	for i := range clusters.Items {
+		report := preflight.Validate(context.TODO(), c, &clusters.Items[i], nil)
+		ready = ready && report.Ready
+		reports = append(reports, report)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return ready, enc.Encode(reports)
+}
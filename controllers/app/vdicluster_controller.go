@@ -50,16 +50,22 @@ type VDIClusterReconciler struct {
 	Scheme *runtime.Scheme
 }
 
-//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces;nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=endpoints;pods/log;configmaps;serviceaccounts;secrets;services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims;persistentvolumes,verbs=get;list;watch;update;delete
 //+kubebuilder:rbac:groups=apps,resources=deployments;replicasets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.kvdi.io,resources=vdiroles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.kvdi.io,resources=vditeams,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
 //+kubebuilder:rbac:groups=app.kvdi.io,resources=vdiclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.kvdi.io,resources=vdiclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.kvdi.io,resources=vdiclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheuses;servicemonitors,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cert-manager.io,resources=certificates;issuers;clusterissuers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -115,6 +121,7 @@ func (r *VDIClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&kappsv1.Deployment{}).
 		Owns(&corev1.Secret{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
 		Owns(&krbacv1.ClusterRole{}).
 		Owns(&krbacv1.ClusterRoleBinding{}).
 		Complete(r)
@@ -28,13 +28,22 @@ import (
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/resources"
 	"github.com/tinyzimmer/kvdi/pkg/resources/desktop"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -51,6 +60,10 @@ type SessionReconciler struct {
 //+kubebuilder:rbac:groups=desktops.kvdi.io,resources=sessions;templates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=desktops.kvdi.io,resources=sessions/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=desktops.kvdi.io,resources=sessions/finalizers,verbs=update
+//+kubebuilder:rbac:groups=desktops.kvdi.io,resources=desktopsnapshots,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -102,5 +115,50 @@ func (r *SessionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.Service{}).
 		Owns(&corev1.Secret{}).
 		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(r.sessionsForNode),
+			builder.WithPredicates(nodeTaintsChanged),
+		).
 		Complete(r)
 }
+
+// nodeTaintsChanged filters node events down to those that may signal a spot preemption.
+var nodeTaintsChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return !equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) ||
+			oldNode.GetDeletionTimestamp() != newNode.GetDeletionTimestamp()
+	},
+}
+
+// sessionsForNode returns reconcile requests for every desktop session running on the
+// given node.
+func (r *SessionReconciler) sessionsForNode(obj client.Object) []reconcile.Request {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(context.TODO(), pods, client.MatchingLabels{v1.ComponentLabel: "desktop"}); err != nil {
+		r.Log.Error(err, "Failed to list desktop pods for node", "Node", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != obj.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: pod.GetName(), Namespace: pod.GetNamespace()},
+		})
+	}
+	return requests
+}
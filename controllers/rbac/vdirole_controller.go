@@ -0,0 +1,133 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	rbacutil "github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// VDIRoleReconciler reconciles a VDIRole object
+type VDIRoleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=rbac.kvdi.io,resources=vdiroles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.kvdi.io,resources=vdiroles/status,verbs=get;update;patch
+
+// Reconcile resolves the roles a VDIRole inherits from and records the inherited rules in
+// its status.
+func (r *VDIRoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := r.Log.WithValues("vdirole", req.Name)
+
+	instance := &rbacv1.VDIRole{}
+	if err := r.Client.Get(ctx, req.NamespacedName, instance); err != nil {
+		// Request object not found, could have been deleted after reconcile request.
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var status rbacv1.VDIRoleStatus
+	if len(instance.GetInheritsFrom()) > 0 {
+		roles, err := r.clusterRoles(ctx, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		inheritance := rbacutil.ResolveInheritance(instance, roles)
+		status = rbacv1.VDIRoleStatus{
+			InheritedRules: inheritance.Rules,
+			InheritedRoles: inheritance.Roles,
+			MissingRoles:   inheritance.Missing,
+		}
+		if len(inheritance.Missing) > 0 {
+			reqLogger.Info("Role inherits from roles that do not exist", "Missing", inheritance.Missing)
+		}
+	}
+
+	if equality.Semantic.DeepEqual(instance.Status, status) {
+		return ctrl.Result{}, nil
+	}
+	reqLogger.Info("Updating inherited rules", "InheritedRoles", status.InheritedRoles)
+	instance.Status = status
+	return ctrl.Result{}, r.Client.Status().Update(ctx, instance)
+}
+
+// clusterRoles returns the roles belonging to the same VDICluster as the given role. Roles
+// may only inherit from each other within a cluster.
+func (r *VDIRoleReconciler) clusterRoles(ctx context.Context, role *rbacv1.VDIRole) ([]*rbacv1.VDIRole, error) {
+	roleList := &rbacv1.VDIRoleList{}
+	if err := r.Client.List(ctx, roleList); err != nil {
+		return nil, err
+	}
+	cluster := role.GetLabels()[v1.RoleClusterRefLabel]
+	roles := make([]*rbacv1.VDIRole, 0, len(roleList.Items))
+	for i := range roleList.Items {
+		if roleList.Items[i].GetLabels()[v1.RoleClusterRefLabel] == cluster {
+			roles = append(roles, &roleList.Items[i])
+		}
+	}
+	return roles, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VDIRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&rbacv1.VDIRole{}).
+		Watches(
+			&source.Kind{Type: &rbacv1.VDIRole{}},
+			handler.EnqueueRequestsFromMapFunc(r.inheritorsForRole),
+		).
+		Complete(r)
+}
+
+// inheritorsForRole returns reconcile requests for every role that inherits from the given
+// role, so changes to its rules propagate down the inheritance chain.
+func (r *VDIRoleReconciler) inheritorsForRole(obj client.Object) []reconcile.Request {
+	role, ok := obj.(*rbacv1.VDIRole)
+	if !ok {
+		return nil
+	}
+	roles, err := r.clusterRoles(context.TODO(), role)
+	if err != nil {
+		r.Log.Error(err, "Failed to list roles inheriting from role", "Role", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, name := range rbacutil.Inheritors(role.GetName(), roles) {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	return requests
+}
@@ -0,0 +1,118 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func newTestRole(name, cluster string, inheritsFrom []string, resources ...rbacv1.Resource) *rbacv1.VDIRole {
+	role := &rbacv1.VDIRole{
+		Rules:        []rbacv1.Rule{{Verbs: []rbacv1.Verb{rbacv1.VerbRead}, Resources: resources}},
+		InheritsFrom: inheritsFrom,
+	}
+	role.Name = name
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster}
+	return role
+}
+
+func TestReconcileInheritedRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	rbacv1.AddToScheme(scheme)
+	r := &VDIRoleReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme,
+			newTestRole("base", "kvdi", nil, rbacv1.ResourceTemplates),
+			newTestRole("auditor", "kvdi", []string{"base"}, rbacv1.ResourceAuditLogs),
+			newTestRole("admin", "kvdi", []string{"auditor", "reports"}, rbacv1.ResourceUsers),
+			newTestRole("standalone", "kvdi", nil, rbacv1.ResourceRoles),
+			// roles in other clusters are treated as missing
+			newTestRole("reports", "other", nil, rbacv1.ResourceRecordings),
+		),
+		Log:    zap.New(zap.UseDevMode(true)),
+		Scheme: scheme,
+	}
+
+	for _, tc := range []struct {
+		name      string
+		resources []rbacv1.Resource
+		inherited []string
+		missing   []string
+	}{
+		{name: "standalone"},
+		{name: "auditor", resources: []rbacv1.Resource{rbacv1.ResourceTemplates}, inherited: []string{"base"}},
+		{
+			name:      "admin",
+			resources: []rbacv1.Resource{rbacv1.ResourceAuditLogs, rbacv1.ResourceTemplates},
+			inherited: []string{"auditor", "base"},
+			missing:   []string{"reports"},
+		},
+	} {
+		nn := types.NamespacedName{Name: tc.name}
+		if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: nn}); err != nil {
+			t.Fatal(err)
+		}
+		role := &rbacv1.VDIRole{}
+		if err := r.Client.Get(context.TODO(), nn, role); err != nil {
+			t.Fatal(err)
+		}
+		resources := make([]rbacv1.Resource, 0)
+		for _, rule := range role.Status.InheritedRules {
+			resources = append(resources, rule.Resources...)
+		}
+		if len(resources) != len(tc.resources) || (len(resources) > 0 && !reflect.DeepEqual(resources, tc.resources)) {
+			t.Errorf("%s: expected inherited resources %v, got: %v", tc.name, tc.resources, resources)
+		}
+		if !reflect.DeepEqual(role.Status.InheritedRoles, tc.inherited) {
+			t.Errorf("%s: expected inherited roles %v, got: %v", tc.name, tc.inherited, role.Status.InheritedRoles)
+		}
+		if !reflect.DeepEqual(role.Status.MissingRoles, tc.missing) {
+			t.Errorf("%s: expected missing roles %v, got: %v", tc.name, tc.missing, role.Status.MissingRoles)
+		}
+		if effective := role.GetEffectiveRules(); len(effective) != len(role.Rules)+len(tc.resources) {
+			t.Errorf("%s: expected the role's own rules followed by the inherited ones, got: %v", tc.name, effective)
+		}
+	}
+
+	// inheritors are requeued when a role they inherit from changes
+	base := &rbacv1.VDIRole{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "base"}, base); err != nil {
+		t.Fatal(err)
+	}
+	requests := make([]string, 0)
+	for _, req := range r.inheritorsForRole(base) {
+		requests = append(requests, req.Name)
+	}
+	if !reflect.DeepEqual(requests, []string{"admin", "auditor"}) {
+		t.Error("Expected the roles inheriting from base to be requeued, got:", requests)
+	}
+}
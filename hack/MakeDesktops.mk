@@ -3,6 +3,7 @@
 build-ubuntu-base:
 	cd build/desktops/ubuntu && docker build . \
 		-f Dockerfile.base \
+		--build-arg AGENT_IMAGE=${KVDI_AGENT_IMAGE} \
 		-t ${UBUNTU_BASE_IMAGE}
 
 build-app-base:
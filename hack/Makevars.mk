@@ -11,6 +11,7 @@ BASE_IMAGE              ?= ${REPO}/build-base:${VERSION}
 MANAGER_IMAGE           ?= ${REPO}/manager:${VERSION}
 APP_IMAGE               ?= ${REPO}/app:${VERSION}
 KVDI_PROXY_IMAGE        ?= ${REPO}/proxy:${VERSION}
+KVDI_AGENT_IMAGE        ?= ${REPO}/agent:${VERSION}
 UBUNTU_BASE_IMAGE       ?= ${REPO}/ubuntu-base:latest
 APP_PROFILE_BASE_IMAGE  ?= ${REPO}/app-base:latest
 DOSBOX_IMAGE            ?= ${REPO}/dosbox:latest
@@ -57,7 +58,8 @@ define build_docker
 		-f build/Dockerfile.$(1) \
 		-t $(2) \
 		--build-arg BASE_IMAGE=$(BASE_IMAGE) \
-		--build-arg LDFLAGS="$(LDFLAGS)"
+		--build-arg LDFLAGS="$(LDFLAGS)" \
+		--build-arg GO_TAGS="$(GO_TAGS)"
 endef
 
 define load_image
@@ -0,0 +1,390 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// staleReportIntervals is how many report intervals may pass without an app reporting its
+// health before it is considered unhealthy.
+const staleReportIntervals = 3
+
+// kubeconfigRenewFraction is how far into the lifetime of a kubeconfig it is renewed.
+const kubeconfigRenewFraction = 0.8
+
+// Opts are options for configuring the agent.
+type Opts struct {
+	// How often to report health to the API and check for broadcasts.
+	ReportInterval time.Duration
+	// A command to run when a new message is broadcast to the desktop. The message is
+	// passed as the last argument.
+	BroadcastCommand []string
+	// A path to keep a short-lived kubeconfig for the session renewed at. Leave empty
+	// when Kubernetes credentials are not enabled for the session.
+	KubeconfigPath string
+}
+
+// Agent relays between tools inside a desktop and the API.
+type Agent struct {
+	log     logr.Logger
+	client  *Client
+	opts    *Opts
+	started time.Time
+
+	mu        sync.Mutex
+	apps      map[string]*appReport
+	metadata  *types.AgentMetadata
+	broadcast *types.AgentBroadcast
+
+	// only touched from the sync loop
+	kubeconfigRenewAt time.Time
+}
+
+// appReport is the last health reported by a tool for an app.
+type appReport struct {
+	health     types.AgentAppHealth
+	reportedAt time.Time
+}
+
+// New returns a new agent reporting to the API with the given client.
+func New(logger logr.Logger, client *Client, opts *Opts) *Agent {
+	return &Agent{
+		log:     logger,
+		client:  client,
+		opts:    opts,
+		started: time.Now(),
+		apps:    make(map[string]*appReport),
+	}
+}
+
+// Run reports to the API every report interval until the context is cancelled.
+func (a *Agent) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.ReportInterval)
+	defer ticker.Stop()
+	for {
+		a.sync()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync reports health, refreshes the cached metadata, and checks for new broadcasts.
+func (a *Agent) sync() {
+	if err := a.client.ReportHealth(a.healthReport(time.Now())); err != nil {
+		a.log.Error(err, "Failed to report health")
+	}
+	if _, err := a.refreshMetadata(); err != nil {
+		a.log.Error(err, "Failed to refresh metadata")
+	}
+	if err := a.renewKubeconfig(time.Now()); err != nil {
+		a.log.Error(err, "Failed to renew kubeconfig")
+	}
+	broadcast, err := a.client.GetBroadcast()
+	if err != nil {
+		a.log.Error(err, "Failed to check for broadcasts")
+		return
+	}
+	if a.recordBroadcast(broadcast) {
+		a.notify(broadcast.Message)
+	}
+}
+
+// recordBroadcast records the given broadcast and returns true if it is new. Broadcasts
+// sent before the agent started are recorded but not considered new.
+func (a *Agent) recordBroadcast(broadcast *types.AgentBroadcast) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if broadcast.Time == 0 {
+		return false
+	}
+	if a.broadcast != nil && broadcast.Time <= a.broadcast.Time {
+		return false
+	}
+	a.broadcast = broadcast
+	return broadcast.Time >= a.started.Unix()
+}
+
+// notify runs the broadcast command, if configured, with the given message.
+func (a *Agent) notify(message string) {
+	a.log.Info("Received broadcast", "Message", message)
+	if len(a.opts.BroadcastCommand) == 0 {
+		return
+	}
+	args := append(append([]string{}, a.opts.BroadcastCommand[1:]...), message)
+	go func() {
+		if out, err := exec.Command(a.opts.BroadcastCommand[0], args...).CombinedOutput(); err != nil {
+			a.log.Error(err, "Broadcast command failed", "Output", string(out))
+		}
+	}()
+}
+
+// renewKubeconfig writes a new kubeconfig for the session if one is configured and the
+// current one is due for renewal. Failures are retried on the next sync.
+func (a *Agent) renewKubeconfig(now time.Time) error {
+	if a.opts.KubeconfigPath == "" || now.Before(a.kubeconfigRenewAt) {
+		return nil
+	}
+	res, err := a.client.GetKubeconfig()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(a.opts.KubeconfigPath, []byte(res.Kubeconfig), 0600); err != nil {
+		return err
+	}
+	lifetime := time.Unix(res.ExpiresAt, 0).Sub(now)
+	a.kubeconfigRenewAt = now.Add(time.Duration(float64(lifetime) * kubeconfigRenewFraction))
+	a.log.Info("Renewed kubeconfig", "Path", a.opts.KubeconfigPath, "RenewAt", a.kubeconfigRenewAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// recordHealth records the health of an app reported by a tool inside the desktop.
+func (a *Agent) recordHealth(health types.AgentAppHealth, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.apps[health.Name] = &appReport{health: health, reportedAt: now}
+}
+
+// healthReport builds a report from the health recorded for each app. Apps that have not
+// reported in a while are marked unhealthy.
+func (a *Agent) healthReport(now time.Time) *types.AgentHealthReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	report := &types.AgentHealthReport{Apps: make([]types.AgentAppHealth, 0, len(a.apps))}
+	for _, app := range a.apps {
+		health := app.health
+		if now.Sub(app.reportedAt) > a.opts.ReportInterval*staleReportIntervals {
+			health.Healthy = false
+			health.Message = fmt.Sprintf("No report received since %s", app.reportedAt.UTC().Format(time.RFC3339))
+		}
+		report.Apps = append(report.Apps, health)
+	}
+	sort.Slice(report.Apps, func(i, j int) bool { return report.Apps[i].Name < report.Apps[j].Name })
+	return report
+}
+
+// refreshMetadata retrieves the metadata from the API and caches it.
+func (a *Agent) refreshMetadata() (*types.AgentMetadata, error) {
+	md, err := a.client.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.metadata = md
+	return md, nil
+}
+
+// getMetadata returns the cached metadata, retrieving it from the API if it has not been
+// yet.
+func (a *Agent) getMetadata() (*types.AgentMetadata, error) {
+	a.mu.Lock()
+	md := a.metadata
+	a.mu.Unlock()
+	if md != nil {
+		return md, nil
+	}
+	return a.refreshMetadata()
+}
+
+// Handler returns the handler for the local endpoint served to tools inside the desktop.
+//
+//	GET  /                          The session the desktop belongs to and its user's metadata
+//	GET  /metadata[/{ns}[/{key}]]   The user's metadata, a namespace of it, or a single value
+//	GET  /broadcast                 The latest message broadcast to the desktop
+//	GET  /health                    The health report that will next be sent to the API
+//	POST /health                    Record the health of an app
+//	POST /resolution                Request a new display resolution
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", a.handleSession)
+	mux.HandleFunc("/metadata", a.handleMetadata)
+	mux.HandleFunc("/metadata/", a.handleMetadata)
+	mux.HandleFunc("/broadcast", a.handleBroadcast)
+	mux.HandleFunc("/health", a.handleHealth)
+	mux.HandleFunc("/resolution", a.handleResolution)
+	return mux
+}
+
+func (a *Agent) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	md, err := a.getMetadata()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, md)
+}
+
+func (a *Agent) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	md, err := a.getMetadata()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/metadata"), "/")
+	if path == "" {
+		writeJSON(w, md.Metadata)
+		return
+	}
+	parts := strings.SplitN(path, "/", 2)
+	values, ok := md.Metadata[parts[0]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		writeJSON(w, values)
+		return
+	}
+	value, ok := values[parts[1]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(value))
+}
+
+func (a *Agent) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	a.mu.Lock()
+	broadcast := a.broadcast
+	a.mu.Unlock()
+	if broadcast == nil {
+		broadcast = &types.AgentBroadcast{}
+	}
+	writeJSON(w, broadcast)
+}
+
+func (a *Agent) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, a.healthReport(time.Now()))
+		return
+	}
+	health := types.AgentAppHealth{}
+	if err := json.NewDecoder(r.Body).Decode(&health); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if health.Name == "" {
+		http.Error(w, "An app name is required", http.StatusBadRequest)
+		return
+	}
+	a.recordHealth(health, time.Now())
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *Agent) handleResolution(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	req := &types.AgentResolutionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.client.RequestResolution(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// allowMethod writes a method not allowed response and returns false if the request does
+// not use one of the given methods.
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, i interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(i); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
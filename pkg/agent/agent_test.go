@@ -0,0 +1,295 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+const testToken = "test-token"
+
+// fakeAPI records the requests made by the agent and answers them like the API would.
+type fakeAPI struct {
+	mu         sync.Mutex
+	health     *types.AgentHealthReport
+	resolution *types.AgentResolutionRequest
+	broadcast  types.AgentBroadcast
+	kubeconfig int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(v1.AgentTokenHeader) != testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid agent token", "status": "Unauthorized"}`))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.TrimPrefix(r.URL.Path, "/api/sessions/default/test-session/agent/") {
+	case "health":
+		f.health = &types.AgentHealthReport{}
+		json.NewDecoder(r.Body).Decode(f.health)
+	case "resolution":
+		f.resolution = &types.AgentResolutionRequest{}
+		json.NewDecoder(r.Body).Decode(f.resolution)
+	case "broadcast":
+		json.NewEncoder(w).Encode(f.broadcast)
+	case "kubeconfig":
+		f.kubeconfig++
+		json.NewEncoder(w).Encode(&types.SessionKubeconfigResponse{
+			Kubeconfig: fmt.Sprintf("kubeconfig-%d", f.kubeconfig),
+			ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+		})
+	case "metadata":
+		json.NewEncoder(w).Encode(&types.AgentMetadata{
+			Name:      "test-session",
+			Namespace: "default",
+			Template:  "test-template",
+			User:      "admin",
+			Metadata: map[string]map[string]string{
+				"app": {"theme": "dark"},
+			},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "not found", "status": "NotFound"}`))
+	}
+}
+
+func newTestAgent(t *testing.T, token string) (*Agent, *fakeAPI) {
+	t.Helper()
+	api := &fakeAPI{}
+	srvr := httptest.NewServer(api)
+	t.Cleanup(srvr.Close)
+	client, err := NewClient(srvr.URL, "default/test-session", token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(testLogger, client, &Opts{ReportInterval: time.Minute}), api
+}
+
+func TestNewClient(t *testing.T) {
+	for _, session := range []string{"", "default", "default/", "/name", "a/b/c"} {
+		if _, err := NewClient("https://kvdi", session, testToken, nil); err == nil {
+			t.Errorf("Expected error for session %q", session)
+		}
+	}
+	if _, err := NewClient("https://kvdi", "default/test-session", "", nil); err == nil {
+		t.Error("Expected error for missing token")
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	agent, _ := newTestAgent(t, "wrong-token")
+	if _, err := agent.client.GetMetadata(); err == nil || err.Error() != "Invalid agent token" {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+	agent, api := newTestAgent(t, testToken)
+	now := time.Now()
+	agent.recordHealth(types.AgentAppHealth{Name: "editor", Healthy: true}, now)
+	agent.recordHealth(types.AgentAppHealth{Name: "browser", Healthy: true}, now.Add(-time.Hour))
+
+	report := agent.healthReport(now)
+	if len(report.Apps) != 2 {
+		t.Fatalf("Expected 2 apps, got %d", len(report.Apps))
+	}
+	if report.Apps[0].Name != "browser" || report.Apps[0].Healthy {
+		t.Errorf("Expected stale browser to be unhealthy, got %+v", report.Apps[0])
+	}
+	if report.Apps[1].Name != "editor" || !report.Apps[1].Healthy {
+		t.Errorf("Expected editor to be healthy, got %+v", report.Apps[1])
+	}
+	if report.Healthy() {
+		t.Error("Expected report to be unhealthy")
+	}
+
+	agent.sync()
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.health == nil || len(api.health.Apps) != 2 {
+		t.Errorf("Expected health report to be sent to the API, got %+v", api.health)
+	}
+}
+
+func TestRecordBroadcast(t *testing.T) {
+	agent, _ := newTestAgent(t, testToken)
+	started := agent.started.Unix()
+
+	if agent.recordBroadcast(&types.AgentBroadcast{}) {
+		t.Error("Expected empty broadcast to not be new")
+	}
+	if agent.recordBroadcast(&types.AgentBroadcast{Message: "old", Time: started - 60}) {
+		t.Error("Expected broadcast from before the agent started to not be new")
+	}
+	if !agent.recordBroadcast(&types.AgentBroadcast{Message: "hello", Time: started + 1}) {
+		t.Error("Expected broadcast to be new")
+	}
+	if agent.recordBroadcast(&types.AgentBroadcast{Message: "hello", Time: started + 1}) {
+		t.Error("Expected repeated broadcast to not be new")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	agent, api := newTestAgent(t, testToken)
+	srvr := httptest.NewServer(agent.Handler())
+	defer srvr.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := http.Get(srvr.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, strings.TrimSpace(string(body))
+	}
+	post := func(path, body string) int {
+		t.Helper()
+		res, err := http.Post(srvr.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	tc := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/metadata/app/theme", http.StatusOK, "dark"},
+		{"/metadata/app", http.StatusOK, `{"theme":"dark"}`},
+		{"/metadata", http.StatusOK, `{"app":{"theme":"dark"}}`},
+		{"/metadata/app/missing", http.StatusNotFound, ""},
+		{"/metadata/missing", http.StatusNotFound, ""},
+		{"/unknown", http.StatusNotFound, ""},
+	}
+	for _, c := range tc {
+		status, body := get(c.path)
+		if status != c.status {
+			t.Errorf("GET %s: expected status %d, got %d", c.path, c.status, status)
+		}
+		if c.body != "" && body != c.body {
+			t.Errorf("GET %s: expected body %q, got %q", c.path, c.body, body)
+		}
+	}
+
+	if status, body := get("/"); status != http.StatusOK || !strings.Contains(body, `"user":"admin"`) {
+		t.Errorf("Expected session metadata, got %d %s", status, body)
+	}
+
+	if status := post("/health", `{"name": "editor", "healthy": true}`); status != http.StatusOK {
+		t.Errorf("Expected health report to be accepted, got %d", status)
+	}
+	if status := post("/health", `{"healthy": true}`); status != http.StatusBadRequest {
+		t.Errorf("Expected health report without a name to be rejected, got %d", status)
+	}
+	if report := agent.healthReport(time.Now()); len(report.Apps) != 1 || !report.Healthy() {
+		t.Errorf("Expected one healthy app, got %+v", report)
+	}
+
+	if status := post("/resolution", `{"width": 0, "height": 1080}`); status != http.StatusBadRequest {
+		t.Errorf("Expected invalid resolution to be rejected, got %d", status)
+	}
+	if status := post("/resolution", `{"width": 1920, "height": 1080}`); status != http.StatusOK {
+		t.Errorf("Expected resolution request to be accepted, got %d", status)
+	}
+	api.mu.Lock()
+	if api.resolution == nil || api.resolution.Width != 1920 || api.resolution.Height != 1080 {
+		t.Errorf("Expected resolution request to be sent to the API, got %+v", api.resolution)
+	}
+	api.mu.Unlock()
+
+	if status := post("/metadata", `{}`); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST to metadata to not be allowed, got %d", status)
+	}
+}
+
+func TestRenewKubeconfig(t *testing.T) {
+	agent, api := newTestAgent(t, testToken)
+	now := time.Now()
+
+	// nothing is requested without a path configured
+	if err := agent.renewKubeconfig(now); err != nil {
+		t.Fatal(err)
+	}
+	if api.kubeconfig != 0 {
+		t.Fatal("Expected no kubeconfig to be requested without a path")
+	}
+
+	path := filepath.Join(t.TempDir(), "run", "kubeconfig")
+	agent.opts.KubeconfigPath = path
+	if err := agent.renewKubeconfig(now); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "kubeconfig-1" {
+		t.Errorf("Expected first kubeconfig to be written, got %q", string(body))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected kubeconfig to only be readable by the owner, got %v", info.Mode().Perm())
+	}
+
+	// not renewed until most of the lifetime has passed
+	if err := agent.renewKubeconfig(now.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if api.kubeconfig != 1 {
+		t.Errorf("Expected kubeconfig not to be renewed early, got %d requests", api.kubeconfig)
+	}
+	if err := agent.renewKubeconfig(now.Add(50 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if api.kubeconfig != 2 {
+		t.Errorf("Expected kubeconfig to be renewed, got %d requests", api.kubeconfig)
+	}
+	if body, _ = ioutil.ReadFile(path); string(body) != "kubeconfig-2" {
+		t.Errorf("Expected renewed kubeconfig to be written, got %q", string(body))
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package agent
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Client talks to the API over the back-channel of a single session.
+type Client struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the given session, in the format of namespace/name, on
+// the API at apiURL. The token is presented on every request.
+func NewClient(apiURL, session, token string, tlsConfig *tls.Config) (*Client, error) {
+	parts := strings.Split(session, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("%q is not a valid session, expected namespace/name", session)
+	}
+	if token == "" {
+		return nil, errors.New("An agent token is required")
+	}
+	return &Client{
+		endpoint: fmt.Sprintf("%s/api/sessions/%s/%s/agent", strings.TrimSuffix(apiURL, "/"), parts[0], parts[1]),
+		token:    token,
+		httpClient: &http.Client{
+			Timeout:   time.Second * 10,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// ReportHealth sends the health of the apps in the desktop to the API.
+func (c *Client) ReportHealth(report *types.AgentHealthReport) error {
+	return c.do(http.MethodPost, "health", report, nil)
+}
+
+// RequestResolution asks the API to change the resolution of the desktop's display.
+func (c *Client) RequestResolution(req *types.AgentResolutionRequest) error {
+	return c.do(http.MethodPost, "resolution", req, nil)
+}
+
+// GetBroadcast retrieves the latest message broadcast to the desktop.
+func (c *Client) GetBroadcast() (*types.AgentBroadcast, error) {
+	out := &types.AgentBroadcast{}
+	return out, c.do(http.MethodGet, "broadcast", nil, out)
+}
+
+// GetMetadata retrieves the metadata served to tools inside the desktop.
+func (c *Client) GetMetadata() (*types.AgentMetadata, error) {
+	out := &types.AgentMetadata{}
+	return out, c.do(http.MethodGet, "metadata", nil, out)
+}
+
+// GetKubeconfig retrieves a short-lived kubeconfig for the session.
+func (c *Client) GetKubeconfig() (*types.SessionKubeconfigResponse, error) {
+	out := &types.SessionKubeconfigResponse{}
+	return out, c.do(http.MethodGet, "kubeconfig", nil, out)
+}
+
+func (c *Client) do(method, path string, req, resp interface{}) error {
+	var body []byte
+	if req != nil {
+		var err error
+		body, err = json.Marshal(req)
+		if err != nil {
+			return err
+		}
+	}
+	r, err := http.NewRequest(method, fmt.Sprintf("%s/%s", c.endpoint, path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set(v1.AgentTokenHeader, c.token)
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := errors.CheckAPIError(res); err != nil {
+		return err
+	}
+	if resp != nil {
+		return json.NewDecoder(res.Body).Decode(resp)
+	}
+	return nil
+}
@@ -32,13 +32,28 @@ import (
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/audit"
 	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/auth/breakglass"
 	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/auth/device"
+	"github.com/tinyzimmer/kvdi/pkg/auth/domain"
+	"github.com/tinyzimmer/kvdi/pkg/auth/homeshare"
 	"github.com/tinyzimmer/kvdi/pkg/auth/mfa"
+	"github.com/tinyzimmer/kvdi/pkg/devices"
+	"github.com/tinyzimmer/kvdi/pkg/marketplace"
+	"github.com/tinyzimmer/kvdi/pkg/metadata"
+	"github.com/tinyzimmer/kvdi/pkg/middleware"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto/tunnel"
+	"github.com/tinyzimmer/kvdi/pkg/recordings"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/trash"
 	util "github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/gateway"
+	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
 
 	"github.com/gorilla/mux"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
@@ -75,6 +90,42 @@ type desktopAPI struct {
 	secrets *secrets.SecretEngine
 	// the mfa backend for setting and retrieving OTP secrets
 	mfa *mfa.Manager
+	// the backend for setting and retrieving home share credentials
+	homeShares *homeshare.Manager
+	// the backend for the host keytabs of domain joined desktops
+	domainHosts *domain.Manager
+	// the backend for users' key-value metadata
+	metadata *metadata.Manager
+	// the backend for the devices users sign in from
+	userDevices *devices.Manager
+	// the manager for break-glass unlock challenges
+	breakGlass *breakglass.Manager
+	// the backend for deleted users and roles that can still be restored
+	trash *trash.Manager
+	// the in-memory cache of VDIRoles and VDITeams
+	rbacCache *rbacCache
+	// the device trust manager for verifying device assertions
+	devices *device.Manager
+	// the client for retrieving templates from remote template indexes
+	marketplace *marketplace.Client
+	// the listener for reverse tunnels from desktop proxies, nil when tunnels are disabled
+	tunnels *tunnel.Listener
+	// the display connections held open for clients to resume
+	displays *displayResumer
+	// the statistics of the websocket connections served by this instance
+	connections *connectionTracker
+	// the gateways selected for users from the latencies reported by their clients
+	gateways *gateway.Selector
+	// the informer-backed reader for resolving namespace labels in role rules
+	namespaces client.Reader
+	// the audit log of API requests
+	audit *audit.Logger
+	// the manager for recording desktop sessions to object storage
+	recordings *recordings.Manager
+	// the recordings in progress for sessions with display connections through this instance
+	recorders *sessionRecorders
+	// deployment-specific middleware run on API requests
+	middleware *middleware.Chain
 }
 
 func (d *desktopAPI) handleClusterUpdate(req reconcile.Request) error {
@@ -98,12 +149,21 @@ func (d *desktopAPI) handleClusterUpdate(req reconcile.Request) error {
 		return err
 	}
 	d.vdiCluster = changed
+	d.connections.setDisplayBandwidth(d.vdiCluster.GetDisplayBandwidth())
+	// roles are matched to the cluster by name, so drop any cached for the previous state
+	d.rbacCache.invalidate()
 
 	if d.secrets == nil {
 		// we have not set up secrets yet
 		d.secrets = secrets.GetSecretEngine(d.vdiCluster)
-		// this means mfa also still need to be setup
+		// this means mfa, home shares, domain hosts, metadata, devices, break-glass, and the trash also still need to be setup
 		d.mfa = mfa.NewManager(d.secrets)
+		d.homeShares = homeshare.NewManager(d.secrets)
+		d.domainHosts = domain.NewManager(d.secrets)
+		d.metadata = metadata.NewManager(d.secrets)
+		d.userDevices = devices.NewManager(d.secrets)
+		d.breakGlass = breakglass.NewManager(d.secrets)
+		d.trash = trash.NewManager(d.secrets)
 	}
 	// call Setup on the secrets backend, should be idempotent
 	if err = d.secrets.Setup(d.client, d.vdiCluster); err != nil {
@@ -119,6 +179,63 @@ func (d *desktopAPI) handleClusterUpdate(req reconcile.Request) error {
 		return err
 	}
 
+	if d.devices == nil {
+		// device trust has not been setup yet
+		d.devices = device.NewManager()
+	}
+	// rebuild the device trust verifiers from the current configuration
+	if err = d.devices.Setup(d.vdiCluster); err != nil {
+		return err
+	}
+
+	if d.marketplace == nil {
+		d.marketplace = marketplace.New(nil)
+	}
+
+	if d.audit == nil {
+		d.audit = audit.NewLogger()
+	}
+	// rebuild the audit sink from the current configuration
+	if err = d.audit.Setup(d.client, d.vdiCluster, d.secrets); err != nil {
+		return err
+	}
+
+	// rebuild the webhook filters from the current configuration
+	if err = d.middleware.Setup(d.vdiCluster, d.secrets); err != nil {
+		return err
+	}
+
+	if d.recordings == nil {
+		d.recordings = recordings.NewManager()
+	}
+	// rebuild the recordings store from the current configuration
+	if err = d.recordings.Setup(d.vdiCluster, d.secrets); err != nil {
+		return err
+	}
+
+	if d.tunnels == nil && d.vdiCluster.TunnelsEnabled() {
+		// tunnels have not been setup yet, the listener runs for the life of the process
+		tlsConfig, err := tlsutil.NewTunnelServerTLSConfig()
+		if err != nil {
+			return err
+		}
+		relayTLSConfig, err := tlsutil.NewTunnelRelayTLSConfig()
+		if err != nil {
+			return err
+		}
+		d.tunnels = tunnel.NewListener(apiLogger.WithName("tunnels"), &tunnelRegistry{api: d}, relayTLSConfig)
+		go func() {
+			if err := d.tunnels.ListenAndServe(fmt.Sprintf(":%d", v1.TunnelPort), tlsConfig); err != nil {
+				apiLogger.Error(err, "Reverse tunnel listener died")
+			}
+		}()
+		go func() {
+			if err := d.tunnels.ServeRelay(fmt.Sprintf(":%d", v1.TunnelRelayPort)); err != nil {
+				apiLogger.Error(err, "Reverse tunnel relay died")
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -136,6 +253,9 @@ func buildScheme() (*runtime.Scheme, error) {
 	if err := corev1.AddToScheme(scheme); err != nil {
 		return nil, err
 	}
+	if err := coordinationv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
 	return scheme, nil
 }
 
@@ -147,7 +267,7 @@ func getClientFromConfigAndScheme(cfg *rest.Config, scheme *runtime.Scheme) (cli
 // and vdi cluster name.
 func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 	// create an api object
-	api := &desktopAPI{clusterName: vdiCluster}
+	api := &desktopAPI{clusterName: vdiCluster, rbacCache: newRBACCache(), displays: newDisplayResumer(), connections: newConnectionTracker(), gateways: newGatewaySelector(), recorders: newSessionRecorders(), middleware: middleware.NewChain()}
 
 	// build our scheme
 	scheme, err := buildScheme()
@@ -170,6 +290,10 @@ func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 		return nil, err
 	}
 
+	// namespace labels are read from the manager's cache, which keeps an informer on
+	// namespaces once the first one is looked up
+	api.namespaces = mgr.GetCache()
+
 	// watch the vdicluster for updates, this also handles initial setup
 	// of auth and secrets.
 	var c controller.Controller
@@ -186,6 +310,22 @@ func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 		return nil, err
 	}
 
+	// invalidate the role cache whenever a VDIRole or VDITeam changes
+	if c, err = controller.New("rbac-watcher", mgr, controller.Options{
+		Reconciler: reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			api.rbacCache.invalidate()
+			return reconcile.Result{}, nil
+		}),
+	}); err != nil {
+		return nil, err
+	}
+	if err = c.Watch(&source.Kind{Type: &rbacv1.VDIRole{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return nil, err
+	}
+	if err = c.Watch(&source.Kind{Type: &rbacv1.VDITeam{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return nil, err
+	}
+
 	// start the mgr
 	go func() {
 		// Start the manager. This will block until the stop channel is
@@ -195,6 +335,21 @@ func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 		}
 	}()
 
+	// keep the autoscaling metrics current for node autoscalers
+	go api.refreshAutoscalingMetrics()
+
+	// keep the session counts current for scrapers
+	go api.refreshSessionMetrics()
+
+	// delete recordings once they are past their retention
+	go api.pruneRecordings()
+
+	// record when sessions last received input so idle ones can be terminated
+	go api.refreshIdleStatus()
+
+	// permanently delete users and roles once they are past their retention
+	go api.purgeDeletedObjects()
+
 	// return the api and build the router
 	return api, api.buildRouter()
 }
@@ -204,7 +359,7 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 	adminPass = "testing"
 
 	// create an api object
-	api := &desktopAPI{clusterName: "test-cluster"}
+	api := &desktopAPI{clusterName: "test-cluster", rbacCache: newRBACCache(), displays: newDisplayResumer(), connections: newConnectionTracker(), gateways: newGatewaySelector(), recorders: newSessionRecorders(), middleware: middleware.NewChain()}
 
 	// build our scheme
 	var scheme *runtime.Scheme
@@ -215,6 +370,7 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 
 	// build a client for routes to use
 	api.client = fake.NewFakeClientWithScheme(scheme)
+	api.namespaces = api.client
 
 	// create a cluster object
 	api.vdiCluster = &appv1.VDICluster{}
@@ -256,6 +412,12 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 	// set up auth and secrets
 	api.secrets = secrets.GetSecretEngine(api.vdiCluster)
 	api.mfa = mfa.NewManager(api.secrets)
+	api.homeShares = homeshare.NewManager(api.secrets)
+	api.domainHosts = domain.NewManager(api.secrets)
+	api.metadata = metadata.NewManager(api.secrets)
+	api.userDevices = devices.NewManager(api.secrets)
+	api.breakGlass = breakglass.NewManager(api.secrets)
+	api.trash = trash.NewManager(api.secrets)
 	api.auth = auth.GetAuthProvider(api.vdiCluster, api.secrets)
 	if err = api.secrets.Setup(api.client, api.vdiCluster); err != nil {
 		return
@@ -263,6 +425,19 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 	if err = api.auth.Setup(api.client, api.vdiCluster); err != nil {
 		return
 	}
+	api.devices = device.NewManager()
+	if err = api.devices.Setup(api.vdiCluster); err != nil {
+		return
+	}
+	api.marketplace = marketplace.New(nil)
+	api.audit = audit.NewLogger()
+	if err = api.audit.Setup(api.client, api.vdiCluster, api.secrets); err != nil {
+		return
+	}
+	api.recordings = recordings.NewManager()
+	if err = api.recordings.Setup(api.vdiCluster, api.secrets); err != nil {
+		return
+	}
 
 	// set a dummy jwt key
 	if err = api.secrets.WriteSecret(v1.JWTSecretKey, []byte("supersecret")); err != nil {
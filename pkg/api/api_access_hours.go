@@ -0,0 +1,139 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	"github.com/google/uuid"
+)
+
+// accessOverrideRecord is the value stored for each access override token in the secrets
+// backend.
+type accessOverrideRecord struct {
+	// The user the override was issued for
+	User string `json:"user"`
+	// The user that issued the override
+	IssuedBy string `json:"issuedBy"`
+	// The reason the override was issued
+	Reason string `json:"reason,omitempty"`
+	// The unix time the override expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// issueAccessOverride creates a new override token allowing the given user in outside of
+// the access hours of their roles. Expired overrides are pruned at the same time.
+func (d *desktopAPI) issueAccessOverride(username, issuedBy string, req *types.AccessOverrideRequest) (*types.AccessOverrideResponse, error) {
+	if err := d.secrets.Lock(10); err != nil {
+		return nil, err
+	}
+	defer d.secrets.Release()
+
+	overrides, err := d.secrets.ReadSecretMap(v1.AccessOverridesSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return nil, err
+		}
+		overrides = make(map[string][]byte)
+	}
+	now := time.Now()
+	for token, value := range overrides {
+		record := &accessOverrideRecord{}
+		if err := json.Unmarshal(value, record); err != nil || now.Unix() >= record.ExpiresAt {
+			delete(overrides, token)
+		}
+	}
+
+	token := uuid.New().String()
+	expiresAt := now.Add(req.GetDuration()).Unix()
+	record, err := json.Marshal(&accessOverrideRecord{
+		User:      username,
+		IssuedBy:  issuedBy,
+		Reason:    req.Reason,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	overrides[token] = record
+	if err := d.secrets.WriteSecretMap(v1.AccessOverridesSecretKey, overrides); err != nil {
+		return nil, err
+	}
+	return &types.AccessOverrideResponse{
+		User:      username,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// lookupAccessOverride returns the time the given override token expires if it was issued
+// for the given user and has not expired yet.
+func (d *desktopAPI) lookupAccessOverride(username, token string) (time.Time, error) {
+	overrides, err := d.secrets.ReadSecretMap(v1.AccessOverridesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return time.Time{}, errors.New("The access override token does not exist")
+		}
+		return time.Time{}, err
+	}
+	value, ok := overrides[token]
+	if !ok {
+		return time.Time{}, errors.New("The access override token does not exist")
+	}
+	record := &accessOverrideRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return time.Time{}, err
+	}
+	if record.User != username {
+		return time.Time{}, errors.New("The access override token was issued for a different user")
+	}
+	expiresAt := time.Unix(record.ExpiresAt, 0)
+	if !time.Now().Before(expiresAt) {
+		return time.Time{}, errors.New("The access override token has expired")
+	}
+	return expiresAt, nil
+}
+
+// accessAllowed returns true if the user is within the access hours of their roles, or
+// holds an access override that has not expired.
+func accessAllowed(user *types.VDIUser, overrideExpiresAt time.Time) bool {
+	now := time.Now()
+	return rbac.AccessAllowed(user.Roles, now) || now.Before(overrideExpiresAt)
+}
+
+// checkAccessHours makes sure the user making the request is allowed to connect to
+// desktops at the current time. If they are not, a forbidden response is written and
+// false is returned.
+func (d *desktopAPI) checkAccessHours(w http.ResponseWriter, r *http.Request) bool {
+	session := apiutil.GetRequestUserSession(r)
+	if !accessAllowed(session.User, session.GetAccessOverrideExpiresAt()) {
+		apiutil.ReturnAPIForbidden(nil, "Desktops may not be accessed outside of the access hours of your roles", w)
+		return false
+	}
+	return true
+}
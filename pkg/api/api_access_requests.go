@@ -0,0 +1,204 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// invalidRoleNameChars matches characters that cannot be used in the name of a VDIRole.
+var invalidRoleNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// listAccessRequests returns all template access requests sorted by creation time.
+func (d *desktopAPI) listAccessRequests() ([]*types.AccessRequest, error) {
+	stored, err := d.secrets.ReadSecretMap(v1.AccessRequestsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return []*types.AccessRequest{}, nil
+		}
+		return nil, err
+	}
+	out := make([]*types.AccessRequest, 0, len(stored))
+	for _, value := range stored {
+		req := &types.AccessRequest{}
+		if err := json.Unmarshal(value, req); err != nil {
+			return nil, err
+		}
+		out = append(out, req)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out, nil
+}
+
+// updateAccessRequests applies the given function to the stored access requests while
+// holding the secrets lock, and then writes the result back to the backend.
+func (d *desktopAPI) updateAccessRequests(f func(map[string]*types.AccessRequest) error) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	stored, err := d.secrets.ReadSecretMap(v1.AccessRequestsSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		stored = make(map[string][]byte)
+	}
+	requests := make(map[string]*types.AccessRequest, len(stored))
+	for id, value := range stored {
+		req := &types.AccessRequest{}
+		if err := json.Unmarshal(value, req); err != nil {
+			return err
+		}
+		requests[id] = req
+	}
+	if err := f(requests); err != nil {
+		return err
+	}
+	updated := make(map[string][]byte, len(requests))
+	for id, req := range requests {
+		value, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		updated[id] = value
+	}
+	return d.secrets.WriteSecretMap(v1.AccessRequestsSecretKey, updated)
+}
+
+// getAccessRoleName returns the name of the VDIRole holding the template access granted
+// to the given user through access requests.
+func (d *desktopAPI) getAccessRoleName(username string) string {
+	name := invalidRoleNameChars.ReplaceAllString(strings.ToLower(username), "-")
+	return fmt.Sprintf("%s-access-%s", d.vdiCluster.GetName(), strings.Trim(name, "-."))
+}
+
+// grantTemplateAccess ensures the user from the given access request is bound to a role
+// allowing them to launch and use the requested template. The role is bound with an
+// annotation rather than through the authentication provider, so that access can be granted
+// to users of providers that cannot be updated, such as OIDC and LDAP. The user receives the
+// role the next time a token is issued to them.
+func (d *desktopAPI) grantTemplateAccess(req *types.AccessRequest) error {
+	rule := rbacv1.Rule{
+		Verbs:            []rbacv1.Verb{rbacv1.VerbRead, rbacv1.VerbLaunch, rbacv1.VerbUse},
+		Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+		ResourcePatterns: []string{fmt.Sprintf("^%s$", regexp.QuoteMeta(req.Template))},
+		Namespaces:       []string{req.Namespace},
+	}
+
+	defer d.rbacCache.invalidate()
+	roleName := d.getAccessRoleName(req.User)
+	role := &rbacv1.VDIRole{}
+	nn := ktypes.NamespacedName{Name: roleName, Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, role); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		role = &rbacv1.VDIRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: roleName,
+				Labels: map[string]string{
+					v1.RoleClusterRefLabel: d.vdiCluster.GetName(),
+				},
+				Annotations: map[string]string{
+					v1.BoundUsersAnnotation: req.User,
+				},
+			},
+			Rules: []rbacv1.Rule{rule},
+		}
+		return d.client.Create(context.TODO(), role)
+	}
+
+	changed := false
+	if !roleHasRule(role, rule) {
+		role.Rules = append(role.Rules, rule)
+		changed = true
+	}
+	if !roleBoundToUser(role, req.User) {
+		annotations := role.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		if bound := annotations[v1.BoundUsersAnnotation]; bound != "" {
+			annotations[v1.BoundUsersAnnotation] = bound + v1.AuthGroupSeparator + req.User
+		} else {
+			annotations[v1.BoundUsersAnnotation] = req.User
+		}
+		role.SetAnnotations(annotations)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return d.client.Update(context.TODO(), role)
+}
+
+// applyBoundRoles adds the roles bound directly to the given user to their list of roles.
+func (d *desktopAPI) applyBoundRoles(user *types.VDIUser) error {
+	roles, err := d.getRoles()
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if roleBoundToUser(role, user.GetName()) && !userHasRole(user, role.GetName()) {
+			user.Roles = append(user.Roles, rbac.VDIRoleToUserRole(role))
+		}
+	}
+	return nil
+}
+
+// roleBoundToUser returns true if the given role is bound directly to the given user.
+func roleBoundToUser(role *rbacv1.VDIRole, username string) bool {
+	bound, ok := role.GetAnnotations()[v1.BoundUsersAnnotation]
+	if !ok {
+		return false
+	}
+	for _, name := range strings.Split(bound, v1.AuthGroupSeparator) {
+		if strings.TrimSpace(name) == username {
+			return true
+		}
+	}
+	return false
+}
+
+func roleHasRule(role *rbacv1.VDIRole, rule rbacv1.Rule) bool {
+	for _, existing := range role.GetRules() {
+		if reflect.DeepEqual(existing, rule) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateAgentToken is a middleware for the routes used by the kvdi-agent inside desktops.
+// The token presented by the agent must match the one issued to the session in the request
+// path. User sessions are not accepted on these routes.
+func (d *desktopAPI) ValidateAgentToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(v1.AgentTokenHeader)
+		if token == "" {
+			apiutil.ReturnAPIUnauthorized(nil, "No agent token provided in request", w)
+			return
+		}
+
+		sess, err := d.getAgentSession(r)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPIUnauthorized(nil, "Invalid agent token", w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+
+		secret := &corev1.Secret{}
+		if err := d.client.Get(context.TODO(), ktypes.NamespacedName{
+			Name:      sess.GetAgentSecretName(),
+			Namespace: sess.GetNamespace(),
+		}, secret); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPIUnauthorized(nil, "Invalid agent token", w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+
+		expected := secret.Data[v1.AgentTokenKey]
+		if len(expected) == 0 || subtle.ConstantTimeCompare(expected, []byte(token)) != 1 {
+			apiutil.ReturnAPIUnauthorized(nil, "Invalid agent token", w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getAgentSession retrieves the session referenced by the namespace and name in an agent
+// request path.
+func (d *desktopAPI) getAgentSession(r *http.Request) (*desktopsv1.Session, error) {
+	return d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+}
+
+// updateAgentStatus applies the given function to the agent status of the session in the
+// request and saves it.
+func (d *desktopAPI) updateAgentStatus(r *http.Request, fn func(*desktopsv1.AgentStatus)) error {
+	sess, err := d.getAgentSession(r)
+	if err != nil {
+		return err
+	}
+	if sess.Status.Agent == nil {
+		sess.Status.Agent = &desktopsv1.AgentStatus{}
+	}
+	fn(sess.Status.Agent)
+	return d.client.Status().Update(context.TODO(), sess)
+}
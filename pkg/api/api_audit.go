@@ -23,15 +23,12 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/types"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 )
 
-// auditLogger handles the audit events. This could be overridden with audit
-// "providers" that just implement the logr interface.
-var auditLogger = logf.Log.WithName("api_audit")
-
 // AuditResult contains information about an audit event from the API router.
 type AuditResult struct {
 	Allowed     bool
@@ -71,19 +68,28 @@ func buildAuditMsg(result *AuditResult) string {
 	return msg
 }
 
-// auditLog logs the event with parseable metadata.
+// newAuditEvent returns an audit event for the given request.
+func newAuditEvent(r *http.Request, user string, allowed bool, msg string) *types.AuditEvent {
+	sourceIP := apiutil.GetClientAddr(r)
+	return &types.AuditEvent{
+		Timestamp:    time.Now().UTC(),
+		User:         user,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		Allowed:      allowed,
+		SourceIP:     sourceIP,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Message:      msg,
+	}
+}
+
+// auditLog records the event in the audit log, if it is enabled.
 func (d *desktopAPI) auditLog(result *AuditResult) {
 	if !d.vdiCluster.AuditLogEnabled() {
 		return
 	}
-	msg := buildAuditMsg(result)
-	auditLogger.Info(
-		msg,
-		"Allowed", result.Allowed,
-		"Username", result.UserSession.User.Name,
-		"RequestPath", result.Request.URL.Path,
-		"RequestOrigin", result.Request.RemoteAddr,
-		"RequestForwardedFor", result.Request.Header.Get("X-Forwarded-For"),
-		"APIActions", result.Actions,
-	)
+	ev := newAuditEvent(result.Request, result.UserSession.User.GetName(), result.Allowed, buildAuditMsg(result))
+	ev.Actions = result.Actions
+	ev.FromOwner = result.FromOwner
+	d.audit.Record(ev)
 }
@@ -0,0 +1,146 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// autoscalingRefreshInterval is how often the autoscaling metrics are refreshed.
+const autoscalingRefreshInterval = 30 * time.Second
+
+var (
+	// autoscalingDesiredDesktops tracks the number of desktops the cluster should have capacity for
+	autoscalingDesiredDesktops = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "autoscaling_desired_desktops",
+		Help:      "The number of desktops the cluster should have capacity for.",
+	})
+
+	// autoscalingDesiredNodes tracks the number of nodes needed for the desired desktops
+	autoscalingDesiredNodes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "autoscaling_desired_nodes",
+		Help:      "The number of nodes needed for the desired desktops, zero when desktops per node is not configured.",
+	})
+
+	// autoscalingQueuedLaunches tracks the number of desktops waiting for a node
+	autoscalingQueuedLaunches = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "autoscaling_queued_launches",
+		Help:      "The number of desktop sessions waiting for a node to run on.",
+	})
+
+	// autoscalingRunningDesktops tracks the number of desktops running
+	autoscalingRunningDesktops = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "autoscaling_running_desktops",
+		Help:      "The number of desktop sessions running.",
+	})
+)
+
+// getAutoscalingHints computes the autoscaling hints from the desktop pods of the cluster
+// and publishes them to the autoscaling metrics.
+func (d *desktopAPI) getAutoscalingHints() (*types.AutoscalingHints, error) {
+	pods := &corev1.PodList{}
+	if err := d.client.List(context.TODO(), pods, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+	var running, queued int
+	for _, pod := range pods.Items {
+		if pod.GetDeletionTimestamp() != nil {
+			continue
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			running++
+		case corev1.PodPending:
+			// pods that are scheduled are pulling images or booting and already have a node
+			if pod.Spec.NodeName == "" {
+				queued++
+			} else {
+				running++
+			}
+		}
+	}
+
+	hints := computeAutoscalingHints(d.vdiCluster.GetAutoscaling(), running, queued, time.Now())
+	autoscalingDesiredDesktops.Set(float64(hints.DesiredDesktops))
+	autoscalingDesiredNodes.Set(float64(hints.DesiredNodes))
+	autoscalingQueuedLaunches.Set(float64(hints.QueuedLaunches))
+	autoscalingRunningDesktops.Set(float64(hints.RunningDesktops))
+	return hints, nil
+}
+
+// computeAutoscalingHints returns the autoscaling hints for the given running and queued
+// desktops at the given time. A nil configuration has no warm pool or schedules.
+func computeAutoscalingHints(cfg *appv1.AutoscalingConfig, running, queued int, now time.Time) *types.AutoscalingHints {
+	hints := &types.AutoscalingHints{
+		RunningDesktops: running,
+		QueuedLaunches:  queued,
+		ActiveSchedules: make([]string, 0),
+	}
+	if cfg != nil {
+		hints.WarmPool = cfg.WarmPool
+		for _, schedule := range cfg.Schedules {
+			if !schedule.IsActive(now) {
+				continue
+			}
+			hints.ActiveSchedules = append(hints.ActiveSchedules, schedule.Name)
+			if schedule.MinDesktops > hints.ScheduledMinimum {
+				hints.ScheduledMinimum = schedule.MinDesktops
+			}
+		}
+	}
+	hints.DesiredDesktops = running + queued + hints.WarmPool
+	if hints.ScheduledMinimum > hints.DesiredDesktops {
+		hints.DesiredDesktops = hints.ScheduledMinimum
+	}
+	if cfg != nil && cfg.DesktopsPerNode > 0 {
+		hints.DesiredNodes = (hints.DesiredDesktops + cfg.DesktopsPerNode - 1) / cfg.DesktopsPerNode
+	}
+	return hints
+}
+
+// refreshAutoscalingMetrics periodically recomputes the autoscaling hints so the metrics
+// stay current between requests to the autoscaling endpoint. It runs for the life of the
+// process.
+func (d *desktopAPI) refreshAutoscalingMetrics() {
+	ticker := time.NewTicker(autoscalingRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if d.vdiCluster == nil || d.vdiCluster.GetAutoscaling() == nil {
+			continue
+		}
+		if _, err := d.getAutoscalingHints(); err != nil {
+			apiLogger.Error(err, "Failed to refresh autoscaling hints")
+		}
+	}
+}
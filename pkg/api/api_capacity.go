@@ -0,0 +1,91 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reserveTemplateCapacity checks that the template has capacity for another session for
+// the given user. If the user is covered by an active reservation with a free slot, the
+// name of the reservation is returned so the new session can be annotated with it. An
+// error is returned if there is no capacity left that the user is allowed to use.
+//
+// Sessions that were already running when a reservation came into effect are not stopped,
+// so a reservation may only free up once other sessions end.
+func (d *desktopAPI) reserveTemplateCapacity(tmpl *desktopsv1.Template, user *types.VDIUser) (string, error) {
+	if !tmpl.CapacityIsLimited() {
+		return "", nil
+	}
+
+	desktops := &desktopsv1.SessionList{}
+	if err := d.client.List(context.TODO(), desktops, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return "", err
+	}
+
+	// count running sessions of this template and the slots each reservation holds
+	var running int
+	held := make(map[string]int)
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.Template != tmpl.GetName() || desktop.GetDeletionTimestamp() != nil {
+			continue
+		}
+		running++
+		if res, ok := desktop.GetAnnotations()[v1.ReservationAnnotation]; ok {
+			held[res]++
+		}
+	}
+
+	// reservations only hold part of the capacity, so the limit applies to them as well
+	if running >= tmpl.GetMaxSessions() {
+		return "", fmt.Errorf("Template %s has no capacity available for %s", tmpl.GetName(), user.GetName())
+	}
+
+	roles := make([]string, len(user.Roles))
+	for i, role := range user.Roles {
+		roles[i] = role.GetName()
+	}
+
+	var unused int
+	for _, res := range tmpl.GetActiveReservations(time.Now()) {
+		free := res.GetSlots() - held[res.Name]
+		if free <= 0 {
+			continue
+		}
+		if res.Matches(user.GetName(), roles) {
+			return res.Name, nil
+		}
+		unused += free
+	}
+
+	if running+unused >= tmpl.GetMaxSessions() {
+		return "", fmt.Errorf("Template %s has no capacity available for %s", tmpl.GetName(), user.GetName())
+	}
+	return "", nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// getClipboardRequest returns the directions the user making the request may sync the
+// clipboard of the requested desktop session in. Each direction must be allowed by the
+// template, and the user must hold the `use-clipboard` verb on it.
+func (d *desktopAPI) getClipboardRequest(r *http.Request) (*proxyproto.ClipboardRequest, error) {
+	sess, err := d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: sess.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		return nil, err
+	}
+
+	action := &types.APIAction{
+		Verb:              rbacv1.VerbUseClipboard,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      tmpl.GetName(),
+		ResourceNamespace: sess.GetNamespace(),
+	}
+	if err := d.populateAction(action); err != nil {
+		return nil, err
+	}
+	return clipboardRequest(tmpl, rbac.EvaluateUser(apiutil.GetRequestUserSession(r).User, action)), nil
+}
+
+// clipboardRequest returns the clipboard directions the template allows for a user that
+// does or does not hold the `use-clipboard` verb on it.
+func clipboardRequest(tmpl *desktopsv1.Template, canUseClipboard bool) *proxyproto.ClipboardRequest {
+	return &proxyproto.ClipboardRequest{
+		ToDesktop:   canUseClipboard && tmpl.ClipboardToDesktopAllowed(),
+		FromDesktop: canUseClipboard && tmpl.ClipboardFromDesktopAllowed(),
+	}
+}
@@ -21,17 +21,24 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
 
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	proxyclient "github.com/tinyzimmer/kvdi/pkg/proxyproto/client"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 )
 
 // TokenHeader is the HTTP header containing the user's access token
@@ -49,8 +56,70 @@ func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *types.AuthResul
 		return
 	}
 
+	if result.SessionStart.IsZero() {
+		result.SessionStart = time.Now()
+	}
+
+	// grant the roles bound to the user's teams
+	if err := d.applyTeamRoles(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// and the roles bound directly to the user, such as those granting approved access requests
+	if err := d.applyBoundRoles(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// record whether the user completed MFA, overriding anything set by the auth provider
+	d.setMFAVerifiedAttribute(result.User, authorized)
+
+	// make sure the user is within the access hours of their roles
+	if !accessAllowed(result.User, result.AccessOverrideExpiresAt) {
+		recordAuthFailure(authFailureAccessHours)
+		apiutil.ReturnAPIForbidden(nil, "Access is not allowed outside of the access hours of your roles", w)
+		return
+	}
+
+	// cap the lifetime of the token at the maximum age of the session
+	tokenDuration := d.vdiCluster.GetTokenDuration()
+	if !result.RefreshNotSupported {
+		tokenDuration = d.vdiCluster.GetAccessTokenTTL()
+	}
+	var sessionExpiresAt int64
+	if maxAge := d.vdiCluster.GetMaxSessionAge(); maxAge > 0 {
+		expiresAt := result.SessionStart.Add(maxAge)
+		remaining := time.Until(expiresAt)
+		if remaining <= 0 {
+			recordAuthFailure(authFailureSessionExpired)
+			apiutil.ReturnAPIForbidden(nil, "The session has reached its maximum age, please log in again", w)
+			return
+		}
+		if remaining < tokenDuration {
+			tokenDuration = remaining
+		}
+		sessionExpiresAt = expiresAt.Unix()
+	}
+
+	// record the device the user is signing in from, within the device limit of their roles
+	if authorized && result.Device != nil {
+		var limit int
+		if quotas := rbac.EffectiveQuotas(result.User.Roles); quotas != nil {
+			limit = quotas.MaxDevices
+		}
+		if err := d.userDevices.Track(result.User.Name, result.Device, time.Now().Add(tokenDuration), limit); err != nil {
+			if errors.IsQuotaExceededError(err) {
+				apiutil.ReturnAPIQuotaExceeded(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
 	// create a new token
-	claims, newToken, err := apiutil.GenerateJWT(secret, result, authorized, d.vdiCluster.GetTokenDuration())
+	claims, newToken, err := apiutil.GenerateJWT(secret, result, authorized, tokenDuration)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -58,7 +127,7 @@ func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *types.AuthResul
 
 	if authorized && !result.RefreshNotSupported {
 		// Generate a refresh token
-		refreshToken, err := d.generateRefreshToken(result.User)
+		refreshToken, err := d.generateRefreshToken(result.User, result.Device, result.SessionStart, result.AccessOverrideExpiresAt)
 		if err != nil {
 			apiutil.ReturnAPIError(err, w)
 			return
@@ -71,20 +140,84 @@ func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *types.AuthResul
 			HttpOnly: true,
 			Secure:   true,
 		})
+		recordTokenIssued(tokenTypeRefresh)
+	}
+
+	if authorized {
+		recordTokenIssued(tokenTypeAccess)
+	} else {
+		recordTokenIssued(tokenTypeMFA)
 	}
 
 	// return the token to the user
 	apiutil.WriteJSON(&types.SessionResponse{
-		Token:      newToken,
-		ExpiresAt:  claims.ExpiresAt,
-		Renewable:  !result.RefreshNotSupported,
-		User:       result.User,
-		Authorized: authorized,
-		State:      state,
+		Token:            newToken,
+		ExpiresAt:        claims.ExpiresAt,
+		Renewable:        !result.RefreshNotSupported,
+		User:             result.User,
+		Authorized:       authorized,
+		State:            state,
+		SessionExpiresAt: sessionExpiresAt,
 	}, w)
 }
 
-func (d *desktopAPI) generateRefreshToken(user *types.VDIUser) (string, error) {
+// setMFAVerifiedAttribute sets the mfaVerified attribute on the given user. A fully authorized
+// user with a verified MFA secret must have completed MFA to get here.
+func (d *desktopAPI) setMFAVerifiedAttribute(user *types.VDIUser, authorized bool) {
+	var verified bool
+	if authorized {
+		if _, ok, err := d.mfa.GetUserMFAStatus(user.Name); err == nil {
+			verified = ok
+		}
+	}
+	if user.Attributes == nil {
+		user.Attributes = make(map[string]string)
+	}
+	user.Attributes[rbacv1.AttributeMFAVerified] = strconv.FormatBool(verified)
+}
+
+// refreshTokenRecord is the value stored for each refresh token in the secrets backend.
+type refreshTokenRecord struct {
+	// The user the token was issued to
+	User string `json:"user"`
+	// The unix time the user's session originally started
+	SessionStart int64 `json:"sessionStart"`
+	// The unix time the token was issued
+	IssuedAt int64 `json:"issuedAt"`
+	// The unix time the user's access override expires, if they logged in with one
+	AccessOverrideExpiresAt int64 `json:"accessOverrideExpiresAt,omitempty"`
+	// The ID of the device the token was issued to, if known
+	Device string `json:"device,omitempty"`
+}
+
+// IdleFor returns how long it has been since the token was issued. Records created before
+// issue times were tracked always return zero.
+func (r *refreshTokenRecord) IdleFor() time.Duration {
+	if r.IssuedAt == 0 {
+		return time.Duration(0)
+	}
+	return time.Since(time.Unix(r.IssuedAt, 0))
+}
+
+// GetSessionStart returns the time the user's session originally started, or the zero
+// time if it is unknown.
+func (r *refreshTokenRecord) GetSessionStart() time.Time {
+	if r.SessionStart == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.SessionStart, 0)
+}
+
+// GetAccessOverrideExpiresAt returns the time the user's access override expires, or the
+// zero time if they did not log in with one.
+func (r *refreshTokenRecord) GetAccessOverrideExpiresAt() time.Time {
+	if r.AccessOverrideExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.AccessOverrideExpiresAt, 0)
+}
+
+func (d *desktopAPI) generateRefreshToken(user *types.VDIUser, device *types.UserDevice, sessionStart, accessOverrideExpiresAt time.Time) (string, error) {
 	refreshToken := uuid.New().String()
 	if err := d.secrets.Lock(10); err != nil {
 		return "", err
@@ -97,45 +230,70 @@ func (d *desktopAPI) generateRefreshToken(user *types.VDIUser) (string, error) {
 		}
 		tokens = make(map[string][]byte)
 	}
-	tokens[refreshToken] = []byte(user.Name)
+	rec := &refreshTokenRecord{
+		User:         user.Name,
+		SessionStart: sessionStart.Unix(),
+		IssuedAt:     time.Now().Unix(),
+	}
+	if !accessOverrideExpiresAt.IsZero() {
+		rec.AccessOverrideExpiresAt = accessOverrideExpiresAt.Unix()
+	}
+	if device != nil {
+		rec.Device = device.ID
+	}
+	record, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	tokens[refreshToken] = record
 	return refreshToken, d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
 }
 
-func (d *desktopAPI) lookupRefreshToken(refreshToken string) (string, error) {
+func (d *desktopAPI) lookupRefreshToken(refreshToken string) (*refreshTokenRecord, error) {
 	if err := d.secrets.Lock(10); err != nil {
-		return "", err
+		return nil, err
 	}
 	defer d.secrets.Release()
 	tokens, err := d.secrets.ReadSecretMap(v1.RefreshTokensSecretKey, false)
 	if err != nil {
 		if errors.IsSecretNotFoundError(err) {
-			return "", errors.New("The refresh token does not exist in the secret storage")
+			return nil, errors.New("The refresh token does not exist in the secret storage")
 		}
-		return "", err
+		return nil, err
 	}
-	user, ok := tokens[refreshToken]
+	value, ok := tokens[refreshToken]
 	if !ok {
-		return "", errors.New("The refresh token does not exist in the secret storage")
+		return nil, errors.New("The refresh token does not exist in the secret storage")
 	}
 	delete(tokens, refreshToken)
-	return string(user), d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
-}
-
-func (d *desktopAPI) getDesktopProxyHost(r *http.Request) (string, error) {
-	nn := apiutil.GetNamespacedNameFromRequest(r)
-	found := &corev1.Service{}
-	if err := d.client.Get(context.TODO(), nn, found); err != nil {
-		return "", err
+	record := &refreshTokenRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		// tokens issued by previous versions only contain the username
+		record = &refreshTokenRecord{User: string(value)}
 	}
-	return fmt.Sprintf("%s:%d", found.Spec.ClusterIP, v1.WebPort), nil
+	return record, d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
 }
 
 func (d *desktopAPI) getProxyClientForRequest(r *http.Request) (*proxyclient.Client, error) {
-	endpointURL, err := d.getDesktopProxyHost(r)
-	if err != nil {
+	return d.getProxyClient(apiutil.GetNamespacedNameFromRequest(r))
+}
+
+// getProxyClient returns a proxy client for the given desktop session. Sessions with a
+// reverse tunnel connected to this instance are reached over the tunnel, all others are
+// dialed at their service. Sessions belonging to another VDICluster are reported as
+// not found.
+func (d *desktopAPI) getProxyClient(nn ktypes.NamespacedName) (*proxyclient.Client, error) {
+	if _, err := d.getClusterDesktop(nn); err != nil {
+		return nil, err
+	}
+	if d.tunnels != nil && d.tunnels.Connected(nn) {
+		return proxyclient.NewWithDialer(apiLogger, func() (net.Conn, error) { return d.tunnels.Dial(nn) }), nil
+	}
+	found := &corev1.Service{}
+	if err := d.client.Get(context.TODO(), nn, found); err != nil {
 		return nil, err
 	}
-	return proxyclient.New(apiLogger, endpointURL), nil
+	return proxyclient.New(apiLogger, fmt.Sprintf("%s:%d", found.Spec.ClusterIP, v1.WebPort)), nil
 }
 
 // Session response
@@ -0,0 +1,118 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Updates to users, roles, and templates may be made against the version of the object the
+// client last read, given in the If-Match header of the request. The version is returned in
+// the ETag header of the GET and PUT responses for each. When the object has changed since,
+// a Conflict is returned with the current state of the object and its version, so the client
+// can merge its changes and try again.
+
+// lookupRole retrieves the role with the given name. Nil is returned if it does not exist
+// or belongs to another VDICluster.
+func (d *desktopAPI) lookupRole(name string) (*rbacv1.VDIRole, error) {
+	nn := ktypes.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+	role := &rbacv1.VDIRole{}
+	if err := d.client.Get(context.TODO(), nn, role); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !d.vdiCluster.OwnsObject(role) {
+		return nil, nil
+	}
+	return role, nil
+}
+
+// returnRoleConflict responds to an update made against a stale version of the given role.
+func (d *desktopAPI) returnRoleConflict(w http.ResponseWriter, name string) {
+	role, err := d.lookupRole(name)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if role == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The role '%s' doesn't exist", name), w)
+		return
+	}
+	apiutil.SetETag(w, role.GetResourceVersion())
+	apiutil.ReturnAPIConflict(errors.NewConflictError("role", name), role.Trim(), w)
+}
+
+// returnTemplateConflict responds to an update made against a stale version of the given
+// template.
+func (d *desktopAPI) returnTemplateConflict(w http.ResponseWriter, name string) {
+	nn := ktypes.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), nn, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.SetETag(w, tmpl.GetResourceVersion())
+	apiutil.ReturnAPIConflict(errors.NewConflictError("template", name), tmpl.Trim(), w)
+}
+
+// getUserVersion returns the version of the given user, or an empty string if the auth
+// provider does not version users.
+func (d *desktopAPI) getUserVersion(username string) (string, error) {
+	versioned, ok := d.auth.(common.VersionedProvider)
+	if !ok {
+		return "", nil
+	}
+	return versioned.GetUserVersion(username)
+}
+
+// returnUserConflict responds to an update made against a stale version of the given user.
+func (d *desktopAPI) returnUserConflict(w http.ResponseWriter, username string) {
+	user, err := d.auth.GetUser(username)
+	if err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	version, err := d.getUserVersion(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.SetETag(w, version)
+	apiutil.ReturnAPIConflict(errors.NewConflictError("user", username), user, w)
+}
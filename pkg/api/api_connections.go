@@ -0,0 +1,280 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/fairqueue"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectionSampleInterval is how often open websocket connections are pinged and their
+// throughput is sampled.
+var connectionSampleInterval = time.Second * 5
+
+// slowFrameThreshold is how long a message may take to hand to the network before it is
+// counted as slow.
+const slowFrameThreshold = 100 * time.Millisecond
+
+// connectionTypes are the names of the websocket connection types in statistics.
+var connectionTypes = map[proxyproto.RequestType]string{
+	proxyproto.RequestTypeDisplay:   "display",
+	proxyproto.RequestTypeAudio:     "audio",
+	proxyproto.RequestTypeSSH:       "ssh",
+	proxyproto.RequestTypeClipboard: "clipboard",
+	proxyproto.RequestTypePlugin:    "plugin",
+}
+
+// connectionTracker holds the websocket connections to desktop sessions served by this
+// instance, keyed by the session and then by the ID of the connection. Writes to display
+// connections share the display bandwidth of the instance through a fair queue.
+type connectionTracker struct {
+	mux     sync.RWMutex
+	conns   map[string]map[string]*trackedConnection
+	display *fairqueue.Queue
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		conns:   make(map[string]map[string]*trackedConnection),
+		display: fairqueue.New(0),
+	}
+}
+
+// setDisplayBandwidth sets the bytes per second shared between display connections. Zero
+// means there is no limit.
+func (c *connectionTracker) setDisplayBandwidth(rate int64) { c.display.SetRate(rate) }
+
+// track starts tracking a websocket connection to the given desktop session. The returned
+// connection should be used to wrap the reads and writes of the websocket, and untracked
+// once it is closed. Statistics are sampled until the context is cancelled. Display
+// connections receive display bandwidth in proportion to the given weight.
+func (c *connectionTracker) track(ctx context.Context, nn ktypes.NamespacedName, rt proxyproto.RequestType, weight int, user, clientAddr string, ws *websocket.Conn, raw net.Conn) *trackedConnection {
+	conn := &trackedConnection{
+		stats: types.ConnectionStats{
+			ID:          uuid.New().String(),
+			Type:        connectionTypes[rt],
+			User:        user,
+			ClientAddr:  clientAddr,
+			ConnectedAt: time.Now(),
+		},
+		ws:         ws,
+		raw:        raw,
+		lastSample: time.Now(),
+	}
+	if rt == proxyproto.RequestTypeDisplay {
+		conn.flow = c.display.NewFlow(weight)
+		conn.queue = c.display
+		conn.stats.BandwidthWeight = conn.flow.Weight()
+		conn.lastFlowSent, conn.lastQueueSent = conn.flow.Sent(), c.display.Sent()
+	}
+	ws.SetPongHandler(conn.handlePong)
+
+	c.mux.Lock()
+	if _, ok := c.conns[nn.String()]; !ok {
+		c.conns[nn.String()] = make(map[string]*trackedConnection)
+	}
+	c.conns[nn.String()][conn.stats.ID] = conn
+	c.mux.Unlock()
+
+	go conn.run(ctx)
+	return conn
+}
+
+// untrack stops tracking the given connection to the desktop session.
+func (c *connectionTracker) untrack(nn ktypes.NamespacedName, conn *trackedConnection) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.conns[nn.String()], conn.stats.ID)
+	if len(c.conns[nn.String()]) == 0 {
+		delete(c.conns, nn.String())
+	}
+}
+
+// list returns the statistics of all the connections to the given desktop session, oldest
+// first.
+func (c *connectionTracker) list(nn ktypes.NamespacedName) []*types.ConnectionStats {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	out := make([]*types.ConnectionStats, 0, len(c.conns[nn.String()]))
+	for _, conn := range c.conns[nn.String()] {
+		out = append(out, conn.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ConnectedAt.Before(out[j].ConnectedAt) })
+	return out
+}
+
+// trackedConnection records statistics for a single websocket connection.
+type trackedConnection struct {
+	// counters updated on every read and write
+	bytesSent, bytesReceived, framesSent, droppedFrames, slowFrames int64
+
+	ws  *websocket.Conn
+	raw net.Conn
+
+	// the flow paced writes go through, nil for connections that are not paced
+	flow  *fairqueue.Flow
+	queue *fairqueue.Queue
+
+	mux           sync.Mutex
+	stats         types.ConnectionStats
+	lastSample    time.Time
+	lastSent      int64
+	lastRecvd     int64
+	lastFlowSent  int64
+	lastQueueSent int64
+}
+
+// Reader returns a reader counting the bytes read from r as received from the client.
+func (t *trackedConnection) Reader(r io.Reader) io.Reader {
+	return &trackedReader{Reader: r, conn: t}
+}
+
+// Writer returns a writer counting the messages written to w as sent to the client. Writes
+// to display connections wait for their turn in the display bandwidth queue.
+func (t *trackedConnection) Writer(w io.Writer) io.Writer {
+	return &trackedWriter{Writer: w, conn: t}
+}
+
+// Stats returns a snapshot of the statistics for the connection.
+func (t *trackedConnection) Stats() *types.ConnectionStats {
+	t.mux.Lock()
+	stats := t.stats
+	t.mux.Unlock()
+	stats.BytesSent = atomic.LoadInt64(&t.bytesSent)
+	stats.BytesReceived = atomic.LoadInt64(&t.bytesReceived)
+	stats.FramesSent = atomic.LoadInt64(&t.framesSent)
+	stats.DroppedFrames = atomic.LoadInt64(&t.droppedFrames)
+	stats.SlowFrames = atomic.LoadInt64(&t.slowFrames)
+	return &stats
+}
+
+// run pings the client and samples throughput until the context is cancelled.
+func (t *trackedConnection) run(ctx context.Context) {
+	ticker := time.NewTicker(connectionSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.sample()
+			// the payload is echoed back in the pong
+			payload := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+			if err := t.ws.WriteControl(websocket.PingMessage, payload, time.Now().Add(connectionSampleInterval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sample updates the throughput rates and TCP statistics of the connection.
+func (t *trackedConnection) sample() {
+	tcp := getTCPStats(t.raw)
+	sent, recvd := atomic.LoadInt64(&t.bytesSent), atomic.LoadInt64(&t.bytesReceived)
+	now := time.Now()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if elapsed := now.Sub(t.lastSample).Seconds(); elapsed > 0 {
+		t.stats.SendBytesPerSecond = float64(sent-t.lastSent) / elapsed
+		t.stats.ReceiveBytesPerSecond = float64(recvd-t.lastRecvd) / elapsed
+	}
+	t.stats.TCP = tcp
+	t.lastSample, t.lastSent, t.lastRecvd = now, sent, recvd
+
+	if t.flow != nil {
+		flowSent, queueSent := t.flow.Sent(), t.queue.Sent()
+		if total := queueSent - t.lastQueueSent; total > 0 {
+			t.stats.BandwidthShare = float64(flowSent-t.lastFlowSent) / float64(total)
+		} else {
+			t.stats.BandwidthShare = 0
+		}
+		t.lastFlowSent, t.lastQueueSent = flowSent, queueSent
+	}
+}
+
+// handlePong updates the round trip estimate from the ping the pong is answering. The
+// estimate is smoothed the same way TCP smooths its own.
+func (t *trackedConnection) handlePong(payload string) error {
+	sentAt, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return nil
+	}
+	rtt := float64(time.Since(time.Unix(0, sentAt))) / float64(time.Millisecond)
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.stats.RTTMillis == 0 {
+		t.stats.RTTMillis = rtt
+	} else {
+		t.stats.RTTMillis = 0.875*t.stats.RTTMillis + 0.125*rtt
+	}
+	return nil
+}
+
+type trackedReader struct {
+	io.Reader
+	conn *trackedConnection
+}
+
+// Read implements a Reader.
+func (r *trackedReader) Read(b []byte) (int, error) {
+	size, err := r.Reader.Read(b)
+	atomic.AddInt64(&r.conn.bytesReceived, int64(size))
+	return size, err
+}
+
+type trackedWriter struct {
+	io.Writer
+	conn *trackedConnection
+}
+
+// Write implements a Writer.
+func (w *trackedWriter) Write(b []byte) (int, error) {
+	if w.conn.flow != nil {
+		w.conn.flow.Wait(len(b))
+	}
+	start := time.Now()
+	size, err := w.Writer.Write(b)
+	atomic.AddInt64(&w.conn.bytesSent, int64(size))
+	if err != nil {
+		atomic.AddInt64(&w.conn.droppedFrames, 1)
+		return size, err
+	}
+	atomic.AddInt64(&w.conn.framesSent, 1)
+	if time.Since(start) > slowFrameThreshold {
+		atomic.AddInt64(&w.conn.slowFrames, 1)
+	}
+	return size, nil
+}
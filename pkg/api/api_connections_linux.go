@@ -0,0 +1,55 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"golang.org/x/sys/unix"
+)
+
+// getTCPStats returns the kernel's statistics for the given connection, or nil if it is
+// not a TCP socket.
+func getTCPStats(conn net.Conn) *types.TCPStats {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || infoErr != nil {
+		return nil
+	}
+	return &types.TCPStats{
+		RTTMillis:    float64(time.Duration(info.Rtt)*time.Microsecond) / float64(time.Millisecond),
+		RTTVarMillis: float64(time.Duration(info.Rttvar)*time.Microsecond) / float64(time.Millisecond),
+		Retransmits:  info.Total_retrans,
+		Lost:         info.Lost,
+	}
+}
@@ -37,6 +37,24 @@ var Decoders = map[string]map[string]interface{}{
 	"/api/sessions": {
 		"POST": types.CreateSessionRequest{},
 	},
+	"/api/sessions/bulk": {
+		"POST": types.BulkCreateSessionRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/ssh": {
+		"POST": types.SSHCertificateRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/agent/health": {
+		"POST": types.AgentHealthReport{},
+	},
+	"/api/sessions/{namespace}/{name}/agent/resolution": {
+		"POST": types.AgentResolutionRequest{},
+	},
+	"/api/labs/{namespace}/{name}/broadcast": {
+		"POST": types.LabBroadcastRequest{},
+	},
+	"/api/labs/{namespace}/{name}/lock": {
+		"POST": types.LabLockRequest{},
+	},
 	"/api/users": {
 		"POST": types.CreateUserRequest{},
 	},
@@ -49,18 +67,87 @@ var Decoders = map[string]map[string]interface{}{
 	"/api/users/{user}/mfa/verify": {
 		"PUT": types.AuthorizeRequest{},
 	},
+	"/api/users/{user}/homeshare": {
+		"PUT": types.HomeShareCredentials{},
+	},
+	"/api/users/{user}/volume": {
+		"PUT": types.ExpandUserVolumeRequest{},
+	},
+	"/api/users/{user}/access_override": {
+		"POST": types.AccessOverrideRequest{},
+	},
+	"/api/users/{user}/metadata/{namespace}/{key}": {
+		"PUT": types.UserMetadataValue{},
+	},
 	"/api/roles": {
 		"POST": types.CreateRoleRequest{},
 	},
+	"/api/domain/hosts/{host}": {
+		"PUT": types.DomainKeytabRequest{},
+	},
 	"/api/templates": {
 		"POST": desktopsv1.Template{},
 	},
+	"/api/templates/validate": {
+		"POST": desktopsv1.Template{},
+	},
+	"/api/templates/{template}/approve": {
+		"POST": types.ReviewTemplateRequest{},
+	},
+	"/api/templates/{template}/reject": {
+		"POST": types.ReviewTemplateRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/template": {
+		"POST": types.SaveSessionTemplateRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/snapshot": {
+		"POST": types.CreateSnapshotRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/monitors": {
+		"POST": types.AddMonitorRequest{},
+	},
+	"/api/desktops/{namespace}/{name}/display": {
+		"POST": types.DisplayResizeRequest{},
+	},
 	"/api/roles/{role}": {
 		"PUT": types.UpdateRoleRequest{},
 	},
+	"/api/gateways/latency": {
+		"PUT": types.GatewayLatencyReport{},
+	},
+	"/api/authz/check": {
+		"POST": types.AuthzCheckRequest{},
+	},
 	"/api/login": {
 		"POST": types.LoginRequest{},
 	},
+	"/api/break-glass/unlock": {
+		"POST": types.BreakGlassUnlockRequest{},
+	},
+	"/api/config/staged": {
+		"PUT": types.StageConfigRequest{},
+	},
+	"/api/faults": {
+		"PUT": types.FaultInjectionRequest{},
+	},
+	"/api/config/staged/promote": {
+		"POST": types.PromoteConfigRequest{},
+	},
+	"/api/backup": {
+		"POST": types.BackupRequest{},
+	},
+	"/api/restore": {
+		"POST": types.RestoreRequest{},
+	},
+	"/api/access_requests": {
+		"POST": types.CreateAccessRequest{},
+	},
+	"/api/access_requests/{request}/approve": {
+		"POST": types.ReviewAccessRequest{},
+	},
+	"/api/access_requests/{request}/deny": {
+		"POST": types.ReviewAccessRequest{},
+	},
 }
 
 // DecodeRequest will inspect the request object for the type of object
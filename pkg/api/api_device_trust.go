@@ -0,0 +1,63 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// verifyDevice returns the name of the issuer that verified the device making the
+// request, or an empty string if no trusted device assertion was presented.
+func (d *desktopAPI) verifyDevice(r *http.Request) string {
+	if !d.vdiCluster.DeviceTrustIsEnabled() {
+		return ""
+	}
+	assertion, err := d.devices.Verify(r)
+	if err != nil {
+		apiLogger.Info("Request did not present a trusted device", "reason", err.Error())
+		return ""
+	}
+	apiLogger.Info("Verified device assertion", "issuer", assertion.Issuer, "device", assertion.Device)
+	return assertion.Issuer
+}
+
+// checkDeviceTrust makes sure the user making the request is on a trusted device if
+// their roles require one. The device may have been verified at login, or it may present
+// an assertion with the request itself. If neither is the case, a forbidden response is
+// written and false is returned.
+func (d *desktopAPI) checkDeviceTrust(w http.ResponseWriter, r *http.Request) bool {
+	if !d.deviceTrusted(apiutil.GetRequestUserSession(r), r) {
+		apiutil.ReturnAPIForbidden(nil, "A trusted device is required to access desktop sessions", w)
+		return false
+	}
+	return true
+}
+
+// deviceTrusted returns true if the given session does not require a trusted device, or
+// was verified on one at login or by an assertion presented with the request.
+func (d *desktopAPI) deviceTrusted(session *types.JWTClaims, r *http.Request) bool {
+	if !d.devices.RequiredFor(session.User) || session.TrustedDevice != "" {
+		return true
+	}
+	return d.verifyDevice(r) != ""
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// disconnectCheckInterval is how often open websocket connections check whether they
+// should be closed.
+var disconnectCheckInterval = time.Second * 15
+
+// Pod status reasons set when a pod is evicted or its node stops responding.
+const (
+	podEvictedReason  = "Evicted"
+	podNodeLostReason = "NodeLost"
+)
+
+// activityReader wraps a reader and records the last time data was read from it.
+type activityReader struct {
+	io.Reader
+	last int64
+}
+
+func newActivityReader(r io.Reader) *activityReader {
+	return &activityReader{Reader: r, last: time.Now().UnixNano()}
+}
+
+// Read implements a Reader.
+func (a *activityReader) Read(b []byte) (int, error) {
+	size, err := a.Reader.Read(b)
+	if size > 0 {
+		atomic.StoreInt64(&a.last, time.Now().UnixNano())
+	}
+	return size, err
+}
+
+// IdleFor returns how long it has been since data was last read.
+func (a *activityReader) IdleFor() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.last)))
+}
+
+// watchConnection periodically checks whether a websocket connection to the given desktop
+// session should be closed, until the context is cancelled. The reason for closing the
+// connection is returned, or an empty string if the context finished first. The connection
+// is closed once the access token it was opened with expires, and when idleTimeout is
+// non-zero, once the client has sent nothing for that long.
+func (d *desktopAPI) watchConnection(ctx context.Context, claims *types.JWTClaims, nn ktypes.NamespacedName, client *activityReader, idleTimeout time.Duration) types.DisconnectReason {
+	ticker := time.NewTicker(disconnectCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-ticker.C:
+			if !claims.VerifyExpiresAt(time.Now().Unix(), false) {
+				return types.DisconnectAuthExpired
+			}
+			if idleTimeout > 0 && client.IdleFor() > idleTimeout {
+				return types.DisconnectIdleTimeout
+			}
+			if reason := d.disconnectReason(ctx, claims, nn); reason != "" {
+				return reason
+			}
+		}
+	}
+}
+
+// disconnectReason returns the reason a connection to the given desktop session can no
+// longer be served, or an empty string if nothing is wrong. Errors looking up the session are
+// logged and otherwise ignored, so a flaky API server does not drop connections.
+func (d *desktopAPI) disconnectReason(ctx context.Context, claims *types.JWTClaims, nn ktypes.NamespacedName) types.DisconnectReason {
+	if revoked, err := d.tokenIsRevoked(claims); err != nil {
+		apiLogger.Error(err, "Failed to check token revocations for open connection")
+	} else if revoked {
+		return types.DisconnectAuthExpired
+	}
+	if maxAge := d.vdiCluster.GetMaxSessionAge(); maxAge > 0 && time.Since(claims.GetSessionStart()) > maxAge {
+		return types.DisconnectAuthExpired
+	}
+
+	session := &desktopsv1.Session{}
+	if err := d.client.Get(ctx, nn, session); err != nil {
+		if apierrors.IsNotFound(err) {
+			return types.DisconnectAdminTerminated
+		}
+		apiLogger.Error(err, "Failed to lookup desktop session for open connection")
+		return ""
+	}
+	if session.GetDeletionTimestamp() != nil {
+		return types.DisconnectAdminTerminated
+	}
+	if session.Status.Paused {
+		// the pod of a paused session is expected to go away
+		return ""
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.client.Get(ctx, nn, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return types.DisconnectPodEvicted
+		}
+		apiLogger.Error(err, "Failed to lookup desktop pod for open connection")
+		return ""
+	}
+	switch pod.Status.Reason {
+	case podEvictedReason:
+		return types.DisconnectPodEvicted
+	case podNodeLostReason:
+		return types.DisconnectNodeLost
+	}
+	if pod.GetDeletionTimestamp() != nil {
+		return types.DisconnectPodEvicted
+	}
+	if pod.Spec.NodeName != "" {
+		node := &corev1.Node{}
+		if err := d.client.Get(ctx, ktypes.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+			if apierrors.IsNotFound(err) {
+				return types.DisconnectNodeLost
+			}
+			apiLogger.Error(err, "Failed to lookup node for open connection")
+			return ""
+		}
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeReady && cond.Status == corev1.ConditionUnknown {
+				return types.DisconnectNodeLost
+			}
+		}
+	}
+	return ""
+}
+
+// closeWithReason sends a close frame with the given reason to the websocket client.
+func closeWithReason(conn *websocket.Conn, reason types.DisconnectReason) {
+	msg := websocket.FormatCloseMessage(reason.CloseCode(), string(reason))
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+		apiLogger.Error(err, "Failed to send close frame to websocket client", "Reason", reason)
+	}
+}
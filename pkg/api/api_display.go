@@ -0,0 +1,167 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/gorilla/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// displayProtocols are the display protocols clients may request.
+var displayProtocols = []desktopsv1.DisplayProtocol{
+	desktopsv1.DisplayProtocolNoVNC,
+	desktopsv1.DisplayProtocolSPICE,
+	desktopsv1.DisplayProtocolXpra,
+	desktopsv1.DisplayProtocolRDP,
+}
+
+// guacamoleSubprotocol is the websocket subprotocol requested by Guacamole clients, which
+// are served RDP displays.
+const guacamoleSubprotocol = "guacamole"
+
+// requestedDisplayProtocol returns the display protocol requested by the client, either in
+// the `protocol` query parameter or as a websocket subprotocol. An empty value means the
+// client accepts whatever protocol the desktop serves.
+func requestedDisplayProtocol(r *http.Request) desktopsv1.DisplayProtocol {
+	if proto := r.URL.Query().Get("protocol"); proto != "" {
+		return desktopsv1.DisplayProtocol(strings.ToLower(proto))
+	}
+	for _, proto := range websocket.Subprotocols(r) {
+		if strings.ToLower(proto) == guacamoleSubprotocol {
+			return desktopsv1.DisplayProtocolRDP
+		}
+		for _, known := range displayProtocols {
+			if strings.ToLower(proto) == string(known) {
+				return known
+			}
+		}
+	}
+	return ""
+}
+
+// checkDisplayProtocol refuses display connections requesting a protocol other than the
+// one served by the desktop. If it returns false, an error has already been written to
+// the client.
+func (d *desktopAPI) checkDisplayProtocol(w http.ResponseWriter, r *http.Request) bool {
+	requested := requestedDisplayProtocol(r)
+	if requested == "" {
+		return true
+	}
+	sess, err := d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+	if err != nil {
+		// let the proxy handler return the appropriate error
+		return true
+	}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: sess.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return false
+	}
+	if served := tmpl.GetDisplayProtocol(); served != requested {
+		apiutil.ReturnAPIError(fmt.Errorf("The desktop serves a %s display, not %s", served, requested), w)
+		return false
+	}
+	return true
+}
+
+// checkResize returns an error if the display of a desktop booted from the given template
+// and spanning the given monitors may not be resized as requested.
+func checkResize(tmpl *desktopsv1.Template, monitors []desktopsv1.DisplayResolution, req *types.DisplayResizeRequest) error {
+	if !tmpl.DisplayResizeEnabled() {
+		return fmt.Errorf("Template %s does not allow resizing the display", tmpl.GetName())
+	}
+	if maxRes := tmpl.GetMaxMonitorResolution(); req.Width > maxRes.Width || req.Height > maxRes.Height {
+		return fmt.Errorf("The display may not exceed a resolution of %s", maxRes.String())
+	}
+	if len(monitors) > 1 {
+		return errors.New("The display cannot be resized while it spans multiple monitors")
+	}
+	return nil
+}
+
+// resizeDisplay changes the resolution and DPI of the display of the given desktop session.
+func (d *desktopAPI) resizeDisplay(sess *desktopsv1.Session, tmpl *desktopsv1.Template, req *types.DisplayResizeRequest) error {
+	if err := checkResize(tmpl, sess.Status.Monitors, req); err != nil {
+		return err
+	}
+	if !sess.Status.Running {
+		return errors.New("The desktop session is not running")
+	}
+	proxy, err := d.getProxyClient(ktypes.NamespacedName{Name: sess.GetName(), Namespace: sess.GetNamespace()})
+	if err != nil {
+		return err
+	}
+	return proxy.Resize(&proxyproto.ResizeRequest{
+		Width:  int64(req.Width),
+		Height: int64(req.Height),
+		DPI:    int64(req.DPI),
+	})
+}
+
+// displayControlHandler returns a handler for the control messages sent by clients over the
+// display websocket in the request. Nil is returned when the display cannot be resized, in
+// which case text messages are streamed to the desktop like any other.
+func (d *desktopAPI) displayControlHandler(r *http.Request) func([]byte) {
+	sess, err := d.getDesktopForRequest(r)
+	if err != nil {
+		return nil
+	}
+	tmpl, err := sess.GetTemplate(d.client)
+	if err != nil || !tmpl.DisplayResizeEnabled() {
+		return nil
+	}
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	return func(data []byte) {
+		var msg types.DisplayControlMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			apiLogger.Info("Ignoring malformed display control message", "Session", nn.String())
+			return
+		}
+		if msg.Type != types.DisplayControlResize {
+			apiLogger.Info("Ignoring unknown display control message", "Session", nn.String(), "Type", msg.Type)
+			return
+		}
+		if err := msg.DisplayResizeRequest.Validate(); err != nil {
+			apiLogger.Info("Ignoring invalid display resize request", "Session", nn.String(), "Error", err.Error())
+			return
+		}
+		// Refresh the session in case monitors were attached since the client connected
+		if err := d.client.Get(context.TODO(), nn, sess); err != nil {
+			apiLogger.Error(err, "Could not retrieve desktop session for display resize", "Session", nn.String())
+			return
+		}
+		if err := d.resizeDisplay(sess, tmpl, &msg.DisplayResizeRequest); err != nil {
+			apiLogger.Error(err, "Failed to resize display", "Session", nn.String())
+		}
+	}
+}
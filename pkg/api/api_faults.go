@@ -0,0 +1,41 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"io"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
+)
+
+// faultWriter wraps the writer relaying frames from a desktop to a client so that
+// faults injected for the request type can delay or drop them.
+func faultWriter(rt proxyproto.RequestType, w io.Writer) io.Writer {
+	switch rt {
+	case proxyproto.RequestTypeDisplay:
+		return faults.Writer(types.FaultPointDisplayFrames, w)
+	case proxyproto.RequestTypeAudio:
+		return faults.Writer(types.FaultPointAudioFrames, w)
+	default:
+		return w
+	}
+}
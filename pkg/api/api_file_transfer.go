@@ -0,0 +1,134 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxMultipartOverhead is the room left in the body of upload requests for the multipart
+// encoding around the file.
+const maxMultipartOverhead = 1 << 20
+
+// fileTransferMode is the kind of file transfer a request makes.
+type fileTransferMode string
+
+const (
+	// fileTransferBrowse is a request for file info or a directory listing.
+	fileTransferBrowse fileTransferMode = "browse"
+	// fileTransferUpload is a request to upload a file to a desktop.
+	fileTransferUpload fileTransferMode = "upload"
+	// fileTransferDownload is a request to download a file from a desktop.
+	fileTransferDownload fileTransferMode = "download"
+)
+
+// checkFileTransfer ensures the template of the desktop session in the request allows
+// file transfers of the given mode, and that the user holds the `use-file-transfer` verb
+// on it. The template is returned so size and content type restrictions can be checked
+// against it. If it returns nil, an error has already been written to the client.
+func (d *desktopAPI) checkFileTransfer(w http.ResponseWriter, r *http.Request, mode fileTransferMode) *desktopsv1.Template {
+	sess, err := d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return nil
+		}
+		apiutil.ReturnAPIError(err, w)
+		return nil
+	}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: sess.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return nil
+	}
+
+	if !fileTransferAllowed(tmpl, mode) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("File %s is disabled for desktops booted from %s", mode, tmpl.GetName()), w)
+		return nil
+	}
+
+	user := apiutil.GetRequestUserSession(r).User
+	action := &types.APIAction{
+		Verb:              rbacv1.VerbUseFileTransfer,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      tmpl.GetName(),
+		ResourceNamespace: sess.GetNamespace(),
+	}
+	if err := d.populateAction(action); err != nil {
+		apiutil.ReturnAPIForbidden(err, "An error ocurred resolving the teams and namespace for the requested resource", w)
+		return nil
+	}
+	if !rbac.EvaluateUser(user, action) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("%s does not have the ability to %s", user.GetName(), action.String()), w)
+		return nil
+	}
+	return tmpl
+}
+
+// fileTransferAllowed returns true if the template allows file transfers of the given mode.
+func fileTransferAllowed(tmpl *desktopsv1.Template, mode fileTransferMode) bool {
+	switch mode {
+	case fileTransferUpload:
+		return tmpl.FileUploadsEnabled()
+	case fileTransferDownload:
+		return tmpl.FileDownloadsEnabled()
+	default:
+		return tmpl.FileTransferEnabled()
+	}
+}
+
+// checkFileTransferLimits returns an error if a file of the given size and content type
+// may not be transferred to or from desktops booted from the template.
+func checkFileTransferLimits(tmpl *desktopsv1.Template, name string, size int64, contentType string) error {
+	if max := tmpl.GetMaxFileTransferSize(); max > 0 && size > max {
+		return fmt.Errorf("%s is %d bytes, larger than the %d bytes allowed by %s", name, size, max, tmpl.GetName())
+	}
+	if !tmpl.FileContentTypeAllowed(contentType) {
+		return fmt.Errorf("%s is of type %s, which is not allowed by %s", name, contentType, tmpl.GetName())
+	}
+	return nil
+}
+
+// detectContentType detects the content type of a file from its first bytes, then seeks
+// back to the start of the file.
+func detectContentType(f io.ReadSeeker) (string, error) {
+	hdr := make([]byte, 512)
+	n, err := io.ReadFull(f, hdr)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(hdr[:n]), nil
+}
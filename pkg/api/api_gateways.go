@@ -0,0 +1,79 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/gateway"
+)
+
+const (
+	// gatewayReportMaxAge is how long the latencies reported by a client are trusted. Clients
+	// are expected to probe the gateways again before then.
+	gatewayReportMaxAge = 15 * time.Minute
+	// gatewaySwitchThreshold is how much faster, as a fraction of the current latency,
+	// another gateway has to be before a user is moved to it.
+	gatewaySwitchThreshold = 0.2
+	// gatewayProbePath is the path on each gateway clients request to measure latency.
+	gatewayProbePath = "/api/gateways/probe"
+)
+
+func newGatewaySelector() *gateway.Selector {
+	return gateway.NewSelector(gatewayReportMaxAge, gatewaySwitchThreshold)
+}
+
+// getGateways returns the gateways available to clients, starting with the default one.
+func (d *desktopAPI) getGateways() []*types.Gateway {
+	gateways := []*types.Gateway{{Name: gateway.Default, ProbePath: gatewayProbePath}}
+	for _, gw := range d.vdiCluster.GetGateways() {
+		if gw.Name == "" || gw.Name == gateway.Default || gw.URL == "" {
+			continue
+		}
+		gateways = append(gateways, &types.Gateway{
+			Name:      gw.Name,
+			URL:       strings.TrimSuffix(gw.URL, "/"),
+			Zone:      gw.Zone,
+			ProbePath: gatewayProbePath,
+		})
+	}
+	return gateways
+}
+
+// toGatewaySelection converts a selection to its API representation.
+func toGatewaySelection(gateways []*types.Gateway, sel gateway.Selection) *types.GatewaySelection {
+	out := &types.GatewaySelection{Gateway: sel.Gateway, LatencyMillis: sel.LatencyMillis}
+	for _, gw := range gateways {
+		if gw.Name == sel.Gateway {
+			out.URL = gw.URL
+		}
+	}
+	return out
+}
+
+func gatewayNames(gateways []*types.Gateway) []string {
+	names := make([]string, len(gateways))
+	for i, gw := range gateways {
+		names[i] = gw.Name
+	}
+	return names
+}
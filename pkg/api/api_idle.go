@@ -0,0 +1,149 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	proxyclient "github.com/tinyzimmer/kvdi/pkg/proxyproto/client"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// idleRefreshInterval is how often the last input time of running sessions is refreshed
+// from their proxies.
+const idleRefreshInterval = 30 * time.Second
+
+// maintenanceNotice is the message broadcast to the users of sessions pending maintenance.
+const maintenanceNotice = "This desktop is scheduled for maintenance and will be restarted once you disconnect. Please save your work."
+
+// refreshIdleStatus periodically records the last time input was received by each running
+// desktop session on its status, where the controller uses it to terminate idle sessions.
+// It runs for the life of the process.
+func (d *desktopAPI) refreshIdleStatus() {
+	ticker := time.NewTicker(idleRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if d.vdiCluster == nil {
+			continue
+		}
+		if err := d.updateIdleStatus(); err != nil {
+			apiLogger.Error(err, "Failed to refresh the idle status of desktop sessions")
+		}
+	}
+}
+
+// updateIdleStatus retrieves the last input time from the proxy of every running session
+// and records it on the session status when it has changed. When the cluster enforces a
+// userdata quota, the usage of the session's $HOME directory is recorded alongside it so
+// the user can be warned before they run out of space. Users of sessions pending
+// maintenance are warned once that their desktop will be restarted, and the lock state of
+// each lab is applied to the proxies of its sessions.
+func (d *desktopAPI) updateIdleStatus() error {
+	sessions := &desktopsv1.SessionList{}
+	if err := d.client.List(context.TODO(), sessions, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return err
+	}
+	labs := make(map[string]*desktopsv1.Lab)
+	for i := range sessions.Items {
+		sess := &sessions.Items[i]
+		if !sess.Status.Running || sess.GetDeletionTimestamp() != nil {
+			continue
+		}
+		nn := ktypes.NamespacedName{Name: sess.GetName(), Namespace: sess.GetNamespace()}
+		proxy, err := d.getProxyClient(nn)
+		if err != nil {
+			apiLogger.Error(err, "Failed to get proxy client for session", "Session", nn.String())
+			continue
+		}
+		res, err := proxy.Idle()
+		if err != nil {
+			apiLogger.Error(err, "Failed to retrieve idle status from desktop proxy", "Session", nn.String())
+			continue
+		}
+		var changed bool
+		if lastInput := time.Unix(res.LastInput, 0); lastInput.After(sess.Status.LastInputTime.Time) {
+			sess.Status.LastInputTime = metav1.NewTime(lastInput)
+			changed = true
+		}
+		if d.vdiCluster.GetUserdataQuota() > 0 {
+			usage, err := getHomeUsage(proxy)
+			if err != nil {
+				apiLogger.Error(err, "Failed to retrieve home directory usage from desktop proxy", "Session", nn.String())
+			} else if !reflect.DeepEqual(usage, sess.Status.HomeUsage) {
+				sess.Status.HomeUsage = usage
+				changed = true
+			}
+		}
+		if sess.Status.MaintenancePending && !sess.Status.MaintenanceNotified {
+			// The kvdi-agent shows the notice from the session status, and the proxy keeps
+			// a copy in the user's home directory for desktops without the agent.
+			if err := proxy.Control(&proxyproto.ControlRequest{
+				Action:  proxyproto.ControlBroadcast,
+				Message: maintenanceNotice,
+			}); err != nil {
+				apiLogger.Error(err, "Failed to write the maintenance notice to the desktop", "Session", nn.String())
+			}
+			sess.Status.LastBroadcast = maintenanceNotice
+			sess.Status.LastBroadcastTime = metav1.Now()
+			sess.Status.MaintenanceNotified = true
+			changed = true
+		}
+		if sess.GetLab() != "" {
+			if err := d.syncLabLock(proxy, sess, labs); err != nil {
+				apiLogger.Error(err, "Failed to apply the lock state of the lab to the session", "Session", nn.String())
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := d.client.Status().Update(context.TODO(), sess); err != nil {
+			apiLogger.Error(err, "Failed to record idle status for session", "Session", nn.String())
+		}
+	}
+	return nil
+}
+
+// getHomeUsage retrieves the usage of the $HOME directory from the given proxy.
+func getHomeUsage(proxy *proxyclient.Client) (*desktopsv1.HomeUsageStatus, error) {
+	rdr, err := proxy.HomeUsage()
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+	res := &types.HomeUsageResponse{}
+	if err := json.NewDecoder(rdr).Decode(res); err != nil {
+		return nil, err
+	}
+	return &desktopsv1.HomeUsageStatus{
+		Used:     res.Used,
+		Limit:    res.Limit,
+		Warning:  res.Warning,
+		Exceeded: res.Exceeded,
+	}, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// writeSessionKubeconfig issues a kubeconfig for the session in the request path and writes
+// it to the response. The token in the kubeconfig is bound to the desktop pod, so it stops
+// working when the session ends even if it has not expired yet.
+func (d *desktopAPI) writeSessionKubeconfig(w http.ResponseWriter, r *http.Request) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	session, err := d.getClusterDesktop(nn)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	tmpl, err := session.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if !tmpl.KubeCredentialsEnabled() {
+		apiutil.ReturnAPIError(errors.New("Kubernetes credentials are not enabled for this session"), w)
+		return
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.client.Get(context.TODO(), nn, pod); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if pod.GetDeletionTimestamp() != nil {
+		apiutil.ReturnAPIError(errors.New("The desktop session is shutting down"), w)
+		return
+	}
+
+	status, err := k8sutil.RequestPodBoundToken(context.TODO(), pod, tmpl.GetKubeCredentialsExpiration())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	server, caData, err := k8sutil.GetInClusterAPIServer()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if override := tmpl.GetKubeCredentialsAPIServer(); override != "" {
+		server = override
+	}
+
+	kubeconfig, err := k8sutil.NewKubeconfig(server, caData, session.GetNamespace(), k8sutil.GetPodServiceAccount(pod), status.Token)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(&types.SessionKubeconfigResponse{
+		Kubeconfig: string(kubeconfig),
+		ExpiresAt:  status.ExpirationTimestamp.Unix(),
+	}, w)
+}
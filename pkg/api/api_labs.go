@@ -0,0 +1,131 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	proxyclient "github.com/tinyzimmer/kvdi/pkg/proxyproto/client"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getLabForRequest retrieves the lab referenced by the namespace and name in the
+// request path.
+func (d *desktopAPI) getLabForRequest(r *http.Request) (*desktopsv1.Lab, error) {
+	lab := &desktopsv1.Lab{}
+	return lab, d.client.Get(context.TODO(), apiutil.GetNamespacedNameFromRequest(r), lab)
+}
+
+// canManageLabs returns true if the given user can manage every lab, regardless of
+// whether they are an instructor.
+func canManageLabs(user *types.VDIUser) bool {
+	return rbac.EvaluateUser(user, &types.APIAction{
+		Verb:         rbacv1.VerbUpdate,
+		ResourceType: rbacv1.ResourceAll,
+	})
+}
+
+// getLabSessionProxy returns a proxy client for the given session in the lab.
+func (d *desktopAPI) getLabSessionProxy(lab *desktopsv1.Lab, name string) (*proxyclient.Client, error) {
+	return d.getProxyClient(ktypes.NamespacedName{Name: name, Namespace: lab.GetNamespace()})
+}
+
+// forEachLabSession calls the given function with a proxy client for every session
+// in the lab and collects the results.
+func (d *desktopAPI) forEachLabSession(lab *desktopsv1.Lab, fn func(*proxyclient.Client) error) []*types.LabSessionResult {
+	results := make([]*types.LabSessionResult, 0, len(lab.Status.Sessions))
+	for _, sess := range lab.Status.Sessions {
+		result := &types.LabSessionResult{User: sess.User, Name: sess.Name}
+		results = append(results, result)
+		proxy, err := d.getLabSessionProxy(lab, sess.Name)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		if err := fn(proxy); err != nil {
+			result.Error = err.Error()
+		}
+	}
+	return results
+}
+
+// syncLabLock applies the lock state recorded on the status of the session's lab to the
+// session's proxy. Locking and unlocking are idempotent, so this restores the lock after
+// the proxy restarts and extends it to sessions provisioned after the lab was locked.
+// Labs are cached in the given map for the duration of a refresh.
+func (d *desktopAPI) syncLabLock(proxy *proxyclient.Client, sess *desktopsv1.Session, labs map[string]*desktopsv1.Lab) error {
+	key := ktypes.NamespacedName{Name: sess.GetLab(), Namespace: sess.GetNamespace()}
+	lab, ok := labs[key.String()]
+	if !ok {
+		lab = &desktopsv1.Lab{}
+		if err := d.client.Get(context.TODO(), key, lab); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			lab = nil
+		}
+		labs[key.String()] = lab
+	}
+	if lab == nil {
+		return nil
+	}
+	if lab.Status.Locked {
+		return proxy.Control(&proxyproto.ControlRequest{Action: proxyproto.ControlLock, Message: lab.Status.LockMessage})
+	}
+	return proxy.Control(&proxyproto.ControlRequest{Action: proxyproto.ControlUnlock})
+}
+
+// checkLabLock writes a forbidden response and returns false if the session in the
+// request belongs to a lab whose screens are locked. Instructors are not affected
+// by the lock.
+func (d *desktopAPI) checkLabLock(w http.ResponseWriter, r *http.Request) bool {
+	sess, err := d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+	if err != nil {
+		// let the proxy handler return the appropriate error
+		return true
+	}
+	if sess.GetLab() == "" {
+		return true
+	}
+	lab := &desktopsv1.Lab{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: sess.GetLab(), Namespace: sess.GetNamespace()}, lab); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return false
+	}
+	if !lab.Status.Locked || lab.IsInstructor(apiutil.GetRequestUserSession(r).User.GetName()) {
+		return true
+	}
+	msg := "The screens for this lab are locked by an instructor"
+	if lab.Status.LockMessage != "" {
+		msg = fmt.Sprintf("%s: %s", msg, lab.Status.LockMessage)
+	}
+	apiutil.ReturnAPIForbidden(nil, msg, w)
+	return false
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+)
+
+// launchLockTimeout is how long a launch waits for other launches holding the same
+// locks before giving up.
+const launchLockTimeout = 10 * time.Second
+
+// launchLeaseDuration is how long a launch lock is held before another replica may
+// take it over, in case the replica holding it crashed.
+const launchLeaseDuration = 30 * time.Second
+
+// getLaunchLockNames returns the names of the leases guarding the checks made when the
+// given user launches the given template. The per-user quotas are always guarded, while
+// template capacity and license pools are only guarded when the template uses them.
+// The names are returned sorted so that concurrent launches always acquire them in the
+// same order.
+func (d *desktopAPI) getLaunchLockNames(user *types.VDIUser, tmpl *desktopsv1.Template) []string {
+	prefix := d.vdiCluster.GetAppName()
+	names := []string{lock.LeaseName(fmt.Sprintf("%s-user", prefix), user.GetName())}
+	if tmpl.CapacityIsLimited() {
+		names = append(names, lock.LeaseName(fmt.Sprintf("%s-template", prefix), tmpl.GetName()))
+	}
+	if tmpl.RequiresLicenses() {
+		for _, req := range tmpl.GetLicenseRequirements() {
+			name := lock.LeaseName(fmt.Sprintf("%s-license", prefix), req.Pool)
+			if !common.StringSliceContains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// acquireLaunchLocks acquires the leases guarding the launch of the given template by the
+// given user. This serializes quota, capacity, and license checks for the same user,
+// template, and license pools across all replicas of the API, so concurrent launches
+// can't both pass a check that only one of them should. The returned function releases
+// the locks and must be called once the session has been created.
+func (d *desktopAPI) acquireLaunchLocks(user *types.VDIUser, tmpl *desktopsv1.Template) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), launchLockTimeout)
+	defer cancel()
+
+	held := make([]*lock.LeaseLock, 0)
+	release := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			if err := held[i].Release(context.Background()); err != nil {
+				apiLogger.Error(err, "Failed to release launch lock", "Lease.Name", held[i].GetName())
+			}
+		}
+	}
+
+	for _, name := range d.getLaunchLockNames(user, tmpl) {
+		l := lock.NewLeaseLock(d.client, d.vdiCluster.GetCoreNamespace(), name, launchLeaseDuration).
+			WithLabels(d.vdiCluster.GetComponentLabels("launch-lock"))
+		if err := l.Acquire(ctx); err != nil {
+			release()
+			return nil, fmt.Errorf("Could not acquire launch lock %s: %s", name, err.Error())
+		}
+		held = append(held, l)
+	}
+
+	return release, nil
+}
@@ -90,8 +90,67 @@ var (
 		Name:      "active_audio_streams",
 		Help:      "The current number of active audio streams.",
 	})
+
+	// streamBytesSentTotal tracks bytes sent over the other websocket streams of a desktop
+	streamBytesSentTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "ws_stream_bytes_sent_total",
+		Help:      "Total bytes sent over other websocket connections (e.g. ssh, clipboard, plugins) by desktop, client, and stream.",
+	}, []string{"desktop", "client", "stream"})
+
+	// streamBytesReceivedTotal tracks bytes received over the other websocket streams of a desktop
+	streamBytesReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "ws_stream_bytes_rcvd_total",
+		Help:      "Total bytes received over other websocket connections (e.g. ssh, clipboard, plugins) by desktop, client, and stream.",
+	}, []string{"desktop", "client", "stream"})
+
+	// authFailuresTotal tracks failed attempts to authenticate with the API
+	authFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "auth_failures_total",
+		Help:      "Total number of failed authentication attempts by reason.",
+	}, []string{"reason"})
+
+	// tokensIssuedTotal tracks the tokens handed out by the API
+	tokensIssuedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "tokens_issued_total",
+		Help:      "Total number of tokens issued by type.",
+	}, []string{"type"})
+)
+
+// Reasons recorded for failed authentication attempts
+const (
+	authFailureInvalidCredentials  = "invalid_credentials"
+	authFailureInvalidOverride     = "invalid_access_override"
+	authFailureInvalidMFA          = "invalid_mfa"
+	authFailureInvalidBreakGlass   = "invalid_break_glass"
+	authFailureMissingToken        = "missing_token"
+	authFailureInvalidToken        = "invalid_token"
+	authFailureRevokedToken        = "revoked_token"
+	authFailureInvalidRefreshToken = "invalid_refresh_token"
+	authFailureSessionExpired      = "session_expired"
+	authFailureAccessHours         = "access_hours"
+)
+
+// Types recorded for issued tokens
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeMFA     = "mfa"
+	tokenTypeRefresh = "refresh"
 )
 
+// recordAuthFailure records a failed authentication attempt for the given reason.
+func recordAuthFailure(reason string) {
+	authFailuresTotal.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// recordTokenIssued records a token of the given type being issued.
+func recordTokenIssued(tokenType string) {
+	tokensIssuedTotal.With(prometheus.Labels{"type": tokenType}).Inc()
+}
+
 // apiResponseWriter extends the regular http.ResponseWriter and stores the
 // status code internally to be referenced by the metrics collector.
 // When a Hijack is requested for a websocket connection, the net.Conn interface
@@ -100,8 +159,8 @@ type apiResponseWriter struct {
 	http.ResponseWriter
 	status int
 
-	isAudio, isDisplay      bool
-	clientAddr, desktopName string
+	isAudio, isDisplay              bool
+	clientAddr, desktopName, stream string
 }
 
 func (a *apiResponseWriter) WriteHeader(s int) {
@@ -112,6 +171,7 @@ func (a *apiResponseWriter) WriteHeader(s int) {
 func (a *apiResponseWriter) Status() int { return a.status }
 
 func (a *apiResponseWriter) getBytesSentCounter() (counter *prometheus.CounterVec) {
+	counter = streamBytesSentTotal
 	if a.isAudio {
 		counter = audioBytesSentTotal
 	}
@@ -121,6 +181,7 @@ func (a *apiResponseWriter) getBytesSentCounter() (counter *prometheus.CounterVe
 	return
 }
 func (a *apiResponseWriter) getBytesRcvdCounter() (counter *prometheus.CounterVec) {
+	counter = streamBytesReceivedTotal
 	if a.isAudio {
 		counter = audioBytesReceivedTotal
 	}
@@ -130,9 +191,17 @@ func (a *apiResponseWriter) getBytesRcvdCounter() (counter *prometheus.CounterVe
 	return
 }
 
+func (a *apiResponseWriter) getLabels() map[string]string {
+	labels := map[string]string{"desktop": a.desktopName, "client": a.clientAddr}
+	if !a.isAudio && !a.isDisplay {
+		labels["stream"] = a.stream
+	}
+	return labels
+}
+
 func (a *apiResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	conn, rw, err := apiutil.NewWebsocketWatcher(nil).
-		WithLabels(map[string]string{"desktop": a.desktopName, "client": a.clientAddr}).
+		WithLabels(a.getLabels()).
 		WithMetrics(a.getBytesSentCounter(), a.getBytesRcvdCounter()).
 		Hijack(a.ResponseWriter)
 
@@ -182,8 +251,9 @@ func doRequestMetrics(next http.Handler, w *apiResponseWriter, r *http.Request)
 
 func doWebsocketMetrics(next http.Handler, w *apiResponseWriter, r *http.Request) {
 	path := apiutil.GetGorillaPath(r)
-	w.clientAddr = strings.Split(r.RemoteAddr, ":")[0]
+	w.clientAddr = apiutil.GetClientAddr(r)
 	w.desktopName = apiutil.GetNamespacedNameFromRequest(r).String()
+	w.stream = websocketStream(path)
 	if isDisplayWebsocket(path) {
 		// this is a display connection
 		activeDisplayStreams.Inc()
@@ -215,3 +285,15 @@ func isAudioWebsocket(path string) bool {
 }
 
 func isWebsocket(path string) bool { return strings.Contains(path, "/ws/") }
+
+// websocketStream returns the name of the stream served by a websocket route, e.g. `ssh`
+// for `/api/desktops/ws/{namespace}/{name}/ssh`.
+func websocketStream(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part == "ws" && i+3 < len(parts) {
+			return parts[i+3]
+		}
+	}
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// requestedMonitor returns the monitor requested in the `monitor` query parameter of a
+// display connection. The primary monitor is returned when it is not set.
+func requestedMonitor(r *http.Request) (int64, error) {
+	val := r.URL.Query().Get("monitor")
+	if val == "" {
+		return 0, nil
+	}
+	monitor, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || monitor < 0 {
+		return 0, fmt.Errorf("Invalid monitor: %s", val)
+	}
+	return monitor, nil
+}
+
+// getDisplayRequest returns the display request for a client connecting to the desktop
+// in the request.
+func (d *desktopAPI) getDisplayRequest(r *http.Request) (*proxyproto.DisplayRequest, error) {
+	monitor, err := requestedMonitor(r)
+	if err != nil {
+		return nil, err
+	}
+	clipboard, err := d.getClipboardRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyproto.DisplayRequest{
+		ClipboardToDesktop:   clipboard.ToDesktop,
+		ClipboardFromDesktop: clipboard.FromDesktop,
+		Monitor:              monitor,
+	}, nil
+}
+
+// addMonitor returns the given monitor layout with a monitor of the requested resolution
+// attached. When the layout is empty the desktop spans a single monitor, which is assumed
+// to have the same resolution.
+func addMonitor(tmpl *desktopsv1.Template, monitors []desktopsv1.DisplayResolution, req *types.AddMonitorRequest) ([]desktopsv1.DisplayResolution, error) {
+	if !tmpl.MultiMonitorEnabled() {
+		return nil, fmt.Errorf("Template %s does not allow multiple monitors", tmpl.GetName())
+	}
+	res := desktopsv1.DisplayResolution{Width: req.Width, Height: req.Height}
+	if maxRes := tmpl.GetMaxMonitorResolution(); res.Width > maxRes.Width || res.Height > maxRes.Height {
+		return nil, fmt.Errorf("Monitors may not exceed a resolution of %s", maxRes.String())
+	}
+	layout := append([]desktopsv1.DisplayResolution{}, monitors...)
+	if len(layout) == 0 {
+		layout = append(layout, res)
+	}
+	if int32(len(layout)) >= tmpl.GetMaxMonitors() {
+		return nil, fmt.Errorf("The desktop may span at most %d monitors", tmpl.GetMaxMonitors())
+	}
+	return append(layout, res), nil
+}
+
+// removeMonitor returns the given monitor layout with the monitor at the given index
+// detached. Monitors after it move down an index. The primary monitor cannot be removed.
+func removeMonitor(monitors []desktopsv1.DisplayResolution, index int) ([]desktopsv1.DisplayResolution, error) {
+	if index < 1 || index >= len(monitors) {
+		return nil, fmt.Errorf("Monitor %d is not an additional monitor attached to the desktop", index)
+	}
+	layout := append([]desktopsv1.DisplayResolution{}, monitors[:index]...)
+	return append(layout, monitors[index+1:]...), nil
+}
+
+// setSessionMonitors sends the given monitor layout to the proxy of the desktop session and
+// records it on the session's status.
+func (d *desktopAPI) setSessionMonitors(sess *desktopsv1.Session, monitors []desktopsv1.DisplayResolution) error {
+	if !sess.Status.Running {
+		return errors.New("The desktop session is not running")
+	}
+	proxy, err := d.getProxyClient(ktypes.NamespacedName{Name: sess.GetName(), Namespace: sess.GetNamespace()})
+	if err != nil {
+		return err
+	}
+	req := &proxyproto.MonitorsRequest{Monitors: make([]proxyproto.Monitor, len(monitors))}
+	for idx, monitor := range monitors {
+		req.Monitors[idx] = proxyproto.Monitor{Width: int64(monitor.Width), Height: int64(monitor.Height)}
+	}
+	if err := proxy.SetMonitors(req); err != nil {
+		return err
+	}
+	sess.Status.Monitors = monitors
+	return d.client.Status().Update(context.TODO(), sess)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// claimPooledSession claims a ready desktop for the user from a pool of the template in the
+// requested namespace. The claimed session leaves its pool, which boots a replacement in the
+// background. Nil is returned when no pool has a desktop that can serve the launch.
+func (d *desktopAPI) claimPooledSession(username string, tmpl *desktopsv1.Template, req *types.CreateSessionRequest, arch desktopsv1.Architecture, maxDuration time.Duration, reservation string) (*desktopsv1.Session, error) {
+	// sessions launched on a canary revision are never served from a pool, and pooled
+	// desktops are booted without the user data of the user claiming them
+	if !tmpl.CanPool() || tmpl.CanaryIsActive() || d.vdiCluster.UserdataIsEnabled() {
+		return nil, nil
+	}
+
+	pools := &desktopsv1.DesktopPoolList{}
+	if err := d.client.List(context.TODO(), pools, client.InNamespace(req.GetNamespace())); err != nil {
+		return nil, err
+	}
+
+	for _, pool := range pools.Items {
+		if pool.Spec.VDICluster != d.vdiCluster.GetName() || pool.GetTemplateName() != tmpl.GetName() || pool.Spec.ServiceAccount != req.GetServiceAccount() {
+			continue
+		}
+		sessions := &desktopsv1.SessionList{}
+		if err := d.client.List(context.TODO(), sessions, client.InNamespace(pool.GetNamespace()), pool.GetSessionsSelector()); err != nil {
+			return nil, err
+		}
+		for i := range sessions.Items {
+			desktop := &sessions.Items[i]
+			if !desktop.IsPooled() || !desktop.Status.Running || desktop.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if arch != "" && tmpl.GetSessionArchitecture(desktop) != arch {
+				continue
+			}
+			desktop.Spec.User = username
+			if maxDuration > 0 {
+				desktop.Spec.MaxDuration = maxDuration.String()
+			}
+			desktop.SetLabels(d.vdiCluster.GetUserDesktopSelector(username))
+			if reservation != "" {
+				annotations := desktop.GetAnnotations()
+				if annotations == nil {
+					annotations = make(map[string]string)
+				}
+				annotations[v1.ReservationAnnotation] = reservation
+				desktop.SetAnnotations(annotations)
+			}
+			// the session is no longer managed by the pool
+			desktop.SetOwnerReferences(nil)
+			if err := d.client.Update(context.TODO(), desktop); err != nil {
+				if apierrors.IsConflict(err) {
+					// another launch claimed the desktop first
+					continue
+				}
+				return nil, err
+			}
+			apiLogger.Info("Claimed pooled desktop session", "Pool", pool.GetName(), "Session", desktop.GetName(), "User", username)
+			return desktop, nil
+		}
+	}
+
+	return nil, nil
+}
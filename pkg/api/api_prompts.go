@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+)
+
+// validateLaunchPrompts checks the responses given to the prompts of a template before a
+// session is launched from it. The responses to the template's prompts are returned, with
+// acknowledgments normalized to `true`. Denied launches are recorded in the audit log.
+func (d *desktopAPI) validateLaunchPrompts(sess *types.JWTClaims, tmpl *desktopsv1.Template, responses map[string]string) (map[string]string, error) {
+	checked, err := checkLaunchPrompts(tmpl, responses)
+	if err != nil {
+		d.auditPrompts(sess, tmpl, responses, "", err)
+		return nil, err
+	}
+	return checked, nil
+}
+
+// checkLaunchPrompts checks the given responses against the prompts of the template.
+func checkLaunchPrompts(tmpl *desktopsv1.Template, responses map[string]string) (map[string]string, error) {
+	prompts := tmpl.GetLaunchPrompts()
+	known := make(map[string]struct{}, len(prompts))
+	checked := make(map[string]string, len(prompts))
+	for _, prompt := range prompts {
+		known[prompt.Name] = struct{}{}
+		val := strings.TrimSpace(responses[prompt.Name])
+		if val == "" {
+			if prompt.IsRequired() {
+				return nil, fmt.Errorf("Template %s requires a response to the %q prompt", tmpl.GetName(), prompt.Name)
+			}
+			continue
+		}
+		switch prompt.GetType() {
+		case desktopsv1.LaunchPromptAcknowledge:
+			if accepted, err := strconv.ParseBool(val); err != nil || !accepted {
+				return nil, fmt.Errorf("The %q prompt must be acknowledged to launch %s", prompt.Name, tmpl.GetName())
+			}
+			val = "true"
+		case desktopsv1.LaunchPromptChoice:
+			if !common.StringSliceContains(prompt.Options, val) {
+				return nil, fmt.Errorf("%q is not an option for the %q prompt, must be one of: %s", val, prompt.Name, strings.Join(prompt.Options, ", "))
+			}
+		default:
+			if prompt.Pattern != "" {
+				re, err := regexp.Compile(prompt.Pattern)
+				if err != nil {
+					return nil, fmt.Errorf("The %q prompt of template %s has an invalid pattern: %s", prompt.Name, tmpl.GetName(), err.Error())
+				}
+				if !re.MatchString(val) {
+					return nil, fmt.Errorf("The response to the %q prompt does not match the pattern %s", prompt.Name, prompt.Pattern)
+				}
+			}
+		}
+		checked[prompt.Name] = val
+	}
+	for name := range responses {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("Template %s has no prompt named %q", tmpl.GetName(), name)
+		}
+	}
+	return checked, nil
+}
+
+// auditPrompts records the responses given to the prompts of a template in the audit log.
+// If err is not nil, the launch was denied.
+func (d *desktopAPI) auditPrompts(sess *types.JWTClaims, tmpl *desktopsv1.Template, responses map[string]string, session string, err error) {
+	if !d.vdiCluster.AuditLogEnabled() {
+		return
+	}
+	allowed := err == nil
+	ev := &types.AuditEvent{
+		Timestamp: time.Now().UTC(),
+		User:      sess.User.GetName(),
+		Allowed:   allowed,
+		Details: map[string]string{
+			"Template": tmpl.GetName(),
+		},
+	}
+	names := make([]string, 0, len(responses))
+	for name, val := range responses {
+		ev.Details["Prompt."+name] = val
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ev.Message = fmt.Sprintf("%s %s => launch %s => prompts %s", actions[allowed], sess.User.GetName(), tmpl.GetName(), strings.Join(names, ","))
+	if allowed {
+		ev.Details["Session"] = session
+	} else {
+		ev.Details["Reason"] = err.Error()
+	}
+	d.audit.Record(ev)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	rbacutil "github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkSessionQuotas checks that launching a session of the given template in the given
+// namespace would not exceed any of the quotas applied to the user by their roles. A
+// QuotaExceededError describing the first quota that would be exceeded is returned if so.
+func (d *desktopAPI) checkSessionQuotas(user *types.VDIUser, tmpl *desktopsv1.Template, namespace string) error {
+	quotas := rbacutil.EffectiveQuotas(user.Roles)
+	if quotas == nil {
+		return nil
+	}
+
+	desktops := &desktopsv1.SessionList{}
+	if err := d.client.List(context.TODO(), desktops, client.InNamespace(metav1.NamespaceAll), client.MatchingLabels(d.vdiCluster.GetUserDesktopSelector(user.GetName()))); err != nil {
+		return err
+	}
+
+	var running, inNamespace int
+	cpu, memory := tmpl.GetSessionResources()
+	templates := map[string]*desktopsv1.Template{tmpl.GetName(): tmpl}
+	for _, desktop := range desktops.Items {
+		if desktop.GetDeletionTimestamp() != nil {
+			continue
+		}
+		running++
+		if desktop.GetNamespace() == namespace {
+			inNamespace++
+		}
+		if quotas.MaxCPU == nil && quotas.MaxMemory == nil {
+			continue
+		}
+		desktopTmpl, ok := templates[desktop.Spec.Template]
+		if !ok {
+			desktopTmpl = &desktopsv1.Template{}
+			nn := ktypes.NamespacedName{Name: desktop.Spec.Template, Namespace: metav1.NamespaceAll}
+			if err := d.client.Get(context.TODO(), nn, desktopTmpl); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return err
+				}
+				// the template was removed, there is nothing left to count the session against
+				desktopTmpl = &desktopsv1.Template{}
+			}
+			templates[desktop.Spec.Template] = desktopTmpl
+		}
+		desktopCPU, desktopMemory := desktopTmpl.GetSessionResources()
+		cpu.Add(desktopCPU)
+		memory.Add(desktopMemory)
+	}
+
+	if quotas.MaxSessions > 0 && running >= quotas.MaxSessions {
+		return errors.NewQuotaExceededError(fmt.Sprintf("%s has reached the maximum allowed (%d) sessions for their roles", user.GetName(), quotas.MaxSessions))
+	}
+	if quotas.MaxSessionsPerNamespace > 0 && inNamespace >= quotas.MaxSessionsPerNamespace {
+		return errors.NewQuotaExceededError(fmt.Sprintf("%s has reached the maximum allowed (%d) sessions in namespace %s for their roles", user.GetName(), quotas.MaxSessionsPerNamespace, namespace))
+	}
+	if quotas.MaxCPU != nil && cpu.Cmp(*quotas.MaxCPU) > 0 {
+		return errors.NewQuotaExceededError(fmt.Sprintf("Launching %s would bring the CPU used by %s's sessions to %s, exceeding the maximum allowed (%s) for their roles", tmpl.GetName(), user.GetName(), cpu.String(), quotas.MaxCPU.String()))
+	}
+	if quotas.MaxMemory != nil && memory.Cmp(*quotas.MaxMemory) > 0 {
+		return errors.NewQuotaExceededError(fmt.Sprintf("Launching %s would bring the memory used by %s's sessions to %s, exceeding the maximum allowed (%s) for their roles", tmpl.GetName(), user.GetName(), memory.String(), quotas.MaxMemory.String()))
+	}
+	return nil
+}
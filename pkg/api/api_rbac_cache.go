@@ -0,0 +1,125 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"sync"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// rbacCacheMaxAge is how long cached roles and teams are trusted before they are fetched
+// again, in case an invalidating watch event is missed.
+const rbacCacheMaxAge = 5 * time.Minute
+
+// rbacCache holds the VDIRoles and VDITeam hierarchy for the cluster in memory, so
+// requests don't have to list them from the API server every time. The cache is
+// invalidated by watches on both types and whenever the API changes a role itself.
+type rbacCache struct {
+	mux sync.RWMutex
+	// incremented on every invalidation, so lookups that started before one don't
+	// populate the cache with stale results
+	generation  uint64
+	roles       []*rbacv1.VDIRole
+	rolesSynced time.Time
+	tree        *rbac.TeamTree
+	treeSynced  time.Time
+}
+
+func newRBACCache() *rbacCache { return &rbacCache{} }
+
+// invalidate drops everything in the cache, along with the compiled resource patterns
+// of the roles.
+func (c *rbacCache) invalidate() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.generation++
+	c.roles, c.tree = nil, nil
+	rbacv1.ResetPatternCache()
+}
+
+// getGeneration returns the current generation of the cache to pass to the setters
+// after a lookup.
+func (c *rbacCache) getGeneration() uint64 {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.generation
+}
+
+func (c *rbacCache) getRoles() ([]*rbacv1.VDIRole, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if c.roles == nil || time.Since(c.rolesSynced) > rbacCacheMaxAge {
+		return nil, false
+	}
+	out := make([]*rbacv1.VDIRole, len(c.roles))
+	for i, role := range c.roles {
+		out[i] = role.DeepCopy()
+	}
+	return out, true
+}
+
+func (c *rbacCache) setRoles(roles []*rbacv1.VDIRole, generation uint64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.roles = make([]*rbacv1.VDIRole, len(roles))
+	for i, role := range roles {
+		c.roles[i] = role.DeepCopy()
+	}
+	c.rolesSynced = time.Now()
+}
+
+func (c *rbacCache) getTeamTree() (*rbac.TeamTree, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if c.tree == nil || time.Since(c.treeSynced) > rbacCacheMaxAge {
+		return nil, false
+	}
+	return c.tree, true
+}
+
+func (c *rbacCache) setTeamTree(tree *rbac.TeamTree, generation uint64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.tree = tree
+	c.treeSynced = time.Now()
+}
+
+// getRoles returns the VDIRoles for this cluster, from the cache when possible.
+func (d *desktopAPI) getRoles() ([]*rbacv1.VDIRole, error) {
+	if roles, ok := d.rbacCache.getRoles(); ok {
+		return roles, nil
+	}
+	generation := d.rbacCache.getGeneration()
+	roles, err := d.vdiCluster.GetRoles(d.client)
+	if err != nil {
+		return nil, err
+	}
+	d.rbacCache.setRoles(roles, generation)
+	return roles, nil
+}
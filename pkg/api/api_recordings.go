@@ -0,0 +1,244 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/recordings"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// recordingPruneInterval is how often recordings past their retention are deleted. Every
+// app instance prunes, which is harmless since deleting is idempotent.
+var recordingPruneInterval = time.Hour
+
+// recordingLeaseDuration is how long the lease on the recording of a session is held
+// without being renewed before another app instance may take it over. It is renewed at a
+// third of this interval.
+var recordingLeaseDuration = 30 * time.Second
+
+// sessionRecorders tracks the recordings in progress for desktop sessions with display
+// connections through this instance. A session is recorded from when the first client
+// connects to its display until the last one disconnects. When clients are connected
+// through more than one instance, only the instance holding the session's recording
+// lease records it, and the others take over if it disconnects.
+type sessionRecorders struct {
+	mu     sync.Mutex
+	active map[ktypes.NamespacedName]*activeRecording
+}
+
+// activeRecording is the recording of a session and the clients of its display connected
+// through this instance.
+type activeRecording struct {
+	// the lease allowing a single instance to record the session
+	lease *lock.LeaseLock
+	// the recording in progress, nil while another instance holds the lease
+	recorder *recordings.Recorder
+	// the clients connected to the display through this instance
+	clients map[*recordingClient]struct{}
+	// closed when the last client disconnects
+	done chan struct{}
+}
+
+// recordingClient is a client attached to the display of a recorded session.
+type recordingClient struct {
+	user, addr string
+	idle       func() time.Duration
+	// detaches the client from the recorder, nil while it is not attached to one
+	detach func()
+}
+
+func newSessionRecorders() *sessionRecorders {
+	return &sessionRecorders{active: make(map[ktypes.NamespacedName]*activeRecording)}
+}
+
+// recordDisplay attaches a client of the display of the given desktop session to its
+// recording, starting one if the session's template has recording enabled and none is in
+// progress. The returned function must be called when the client disconnects. Failures
+// to record are logged and do not keep the client from connecting.
+func (d *desktopAPI) recordDisplay(nn ktypes.NamespacedName, user, clientAddr string, client *activityReader) (detach func()) {
+	detach = func() {}
+	if d.recordings == nil || !d.recordings.Enabled() {
+		return
+	}
+	session := &desktopsv1.Session{}
+	if err := d.client.Get(context.TODO(), nn, session); err != nil {
+		apiLogger.Error(err, "Failed to look up desktop session for recording", "Session", nn.String())
+		return
+	}
+	tmpl, err := session.GetTemplate(d.client)
+	if err != nil {
+		apiLogger.Error(err, "Failed to look up template for recording", "Session", nn.String())
+		return
+	}
+	if !tmpl.RecordingEnabled() {
+		return
+	}
+
+	d.recorders.mu.Lock()
+	defer d.recorders.mu.Unlock()
+	active, ok := d.recorders.active[nn]
+	if !ok {
+		active = &activeRecording{
+			lease: lock.NewLeaseLock(d.client, d.vdiCluster.GetCoreNamespace(), lock.LeaseName(fmt.Sprintf("%s-recording", d.vdiCluster.GetAppName()), nn.String()), recordingLeaseDuration).
+				WithLabels(d.vdiCluster.GetComponentLabels("recording-lock")),
+			clients: make(map[*recordingClient]struct{}),
+			done:    make(chan struct{}),
+		}
+		d.recorders.active[nn] = active
+		go d.holdRecording(nn, session, active)
+	}
+	c := &recordingClient{user: user, addr: clientAddr, idle: client.IdleFor}
+	if active.recorder != nil {
+		c.detach = active.recorder.Attach(c.user, c.addr, c.idle)
+	}
+	active.clients[c] = struct{}{}
+
+	return func() {
+		d.recorders.mu.Lock()
+		defer d.recorders.mu.Unlock()
+		if c.detach != nil {
+			c.detach()
+		}
+		delete(active.clients, c)
+		if len(active.clients) > 0 {
+			return
+		}
+		delete(d.recorders.active, nn)
+		close(active.done)
+	}
+}
+
+// holdRecording tries to acquire the recording lease of the given session until its last
+// client disconnects, recording the session while the lease is held. When the last client
+// disconnects the recording is finished and the lease released.
+func (d *desktopAPI) holdRecording(nn ktypes.NamespacedName, session *desktopsv1.Session, active *activeRecording) {
+	ticker := time.NewTicker(recordingLeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		d.takeRecording(nn, session, active)
+		select {
+		case <-active.done:
+			d.recorders.mu.Lock()
+			recorder := active.recorder
+			active.recorder = nil
+			d.recorders.mu.Unlock()
+			if recorder != nil {
+				if err := recorder.Stop(context.Background()); err != nil {
+					apiLogger.Error(err, "Failed to finish recording desktop session", "Session", nn.String())
+				}
+			}
+			if err := active.lease.Release(context.Background()); err != nil {
+				apiLogger.Error(err, "Failed to release recording lease", "Session", nn.String())
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// takeRecording acquires or renews the recording lease of the given session, and starts
+// recording it if this instance is not already. If another instance took over the lease,
+// the recording made by this instance is finished.
+func (d *desktopAPI) takeRecording(nn ktypes.NamespacedName, session *desktopsv1.Session, active *activeRecording) {
+	err := active.lease.TryAcquire(context.TODO())
+
+	d.recorders.mu.Lock()
+	defer d.recorders.mu.Unlock()
+	if err != nil {
+		if !errors.Is(err, lock.ErrLeaseHeld) {
+			apiLogger.Error(err, "Failed to acquire recording lease", "Session", nn.String())
+			return
+		}
+		if active.recorder == nil {
+			return
+		}
+		apiLogger.Info("Recording of desktop session was taken over by another instance", "Session", nn.String())
+		for c := range active.clients {
+			if c.detach != nil {
+				c.detach()
+				c.detach = nil
+			}
+		}
+		recorder := active.recorder
+		active.recorder = nil
+		go func() {
+			if err := recorder.Stop(context.Background()); err != nil {
+				apiLogger.Error(err, "Failed to finish recording desktop session", "Session", nn.String())
+			}
+		}()
+		return
+	}
+	if active.recorder != nil {
+		return
+	}
+	recorder, err := d.recordings.Start(context.TODO(), session, d.captureDisplay(nn))
+	if err != nil {
+		apiLogger.Error(err, "Failed to start recording desktop session", "Session", nn.String())
+		return
+	}
+	apiLogger.Info("Recording desktop session", "Session", nn.String(), "Recording", recorder.Recording().ID)
+	active.recorder = recorder
+	for c := range active.clients {
+		c.detach = recorder.Attach(c.user, c.addr, c.idle)
+	}
+}
+
+// captureDisplay returns a function that captures a frame of the display of the given
+// desktop session through its proxy.
+func (d *desktopAPI) captureDisplay(nn ktypes.NamespacedName) func(maxWidth int) ([]byte, error) {
+	return func(maxWidth int) ([]byte, error) {
+		proxy, err := d.getProxyClient(nn)
+		if err != nil {
+			return nil, err
+		}
+		img, err := proxy.Screenshot(&proxyproto.ScreenshotRequest{MaxWidth: int64(maxWidth)})
+		if err != nil {
+			return nil, err
+		}
+		defer img.Close()
+		return ioutil.ReadAll(img)
+	}
+}
+
+// pruneRecordings periodically deletes recordings past the retention configured on the
+// VDICluster. It runs for the life of the process.
+func (d *desktopAPI) pruneRecordings() {
+	ticker := time.NewTicker(recordingPruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if d.recordings == nil || !d.recordings.Enabled() {
+			continue
+		}
+		if err := d.recordings.Prune(context.Background(), time.Now()); err != nil {
+			apiLogger.Error(err, "Failed to prune expired recordings")
+		}
+	}
+}
@@ -0,0 +1,401 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/bufpool"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+	"github.com/tinyzimmer/kvdi/pkg/util/replay"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxResumeIDLength is the longest resume ID a client may connect with.
+const maxResumeIDLength = 64
+
+// displayResumer holds the display connections that may be resumed, keyed by the desktop
+// session and the user they belong to. There is at most one per user and session.
+type displayResumer struct {
+	mux      sync.Mutex
+	displays map[string]*resumableDisplay
+}
+
+func newDisplayResumer() *displayResumer {
+	return &displayResumer{displays: make(map[string]*resumableDisplay)}
+}
+
+func resumeKey(nn ktypes.NamespacedName, user string) string {
+	return nn.String() + "/" + user
+}
+
+// get returns the display for the given key if it was opened with the given resume ID.
+func (d *displayResumer) get(key, id string) *resumableDisplay {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if display, ok := d.displays[key]; ok && display.id == id {
+		return display
+	}
+	return nil
+}
+
+// put stores the display under the given key, closing any display it replaces.
+func (d *displayResumer) put(key string, display *resumableDisplay) {
+	d.mux.Lock()
+	old := d.displays[key]
+	d.displays[key] = display
+	d.mux.Unlock()
+	display.onClose = func() { d.remove(key, display) }
+	if old != nil {
+		old.close()
+	}
+}
+
+// remove deletes the display from the given key if it is still the one stored there.
+func (d *displayResumer) remove(key string, display *resumableDisplay) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.displays[key] == display {
+		delete(d.displays, key)
+	}
+}
+
+// resumableDisplay is a display connection to a desktop that outlives the websocket
+// connections of its clients. Output from the desktop is always read, so the desktop never
+// blocks on a dropped client, and the most recent of it is kept for clients that resume the
+// connection.
+type resumableDisplay struct {
+	id      string
+	conn    *proxyproto.Conn
+	buf     *replay.Buffer
+	window  time.Duration
+	onClose func()
+
+	mux sync.Mutex
+	// the client currently receiving output, nil while detached
+	client *displayClient
+	// closes the connection once the client has been gone for the resume window
+	expiry *time.Timer
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// displayClient is a websocket connection attached to a resumable display.
+type displayClient struct {
+	io.Writer
+	ws       *websocket.Conn
+	detached chan struct{}
+}
+
+func newResumableDisplay(id string, conn *proxyproto.Conn, bufferSize int, window time.Duration) *resumableDisplay {
+	return &resumableDisplay{
+		id:     id,
+		conn:   conn,
+		buf:    replay.New(bufferSize),
+		window: window,
+		done:   make(chan struct{}),
+	}
+}
+
+// run copies output from the desktop to the replay buffer and the attached client until
+// the desktop connection ends.
+func (r *resumableDisplay) run(name string) {
+	defer r.close()
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	for {
+		size, err := r.conn.Read(*buf)
+		if size > 0 {
+			r.send((*buf)[:size])
+		}
+		if err != nil {
+			if err != io.EOF {
+				apiLogger.Error(err, "Error while reading resumable display stream from proxy", "Desktop", name)
+			}
+			return
+		}
+	}
+}
+
+func (r *resumableDisplay) send(p []byte) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, err := r.buf.Write(p); err != nil {
+		return
+	}
+	if r.client == nil {
+		return
+	}
+	if _, err := r.client.Write(p); err != nil {
+		// the client will be told nothing, it resumes from what it received
+		r.detachLocked(r.client)
+	}
+}
+
+// attach makes the given client the receiver of display output, after replaying everything
+// written since the given offset in the stream. False is returned if the output since the
+// offset is no longer available.
+func (r *resumableDisplay) attach(client *displayClient, offset int64) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	missed, ok := r.buf.Since(offset)
+	if !ok {
+		return false
+	}
+	if r.client != nil {
+		r.detachLocked(r.client)
+	}
+	if r.expiry != nil {
+		r.expiry.Stop()
+		r.expiry = nil
+	}
+	if len(missed) > 0 {
+		if _, err := client.Write(missed); err != nil {
+			apiLogger.Error(err, "Failed to replay display output to resumed client")
+			r.startExpiryLocked()
+			return true
+		}
+	}
+	r.client = client
+	return true
+}
+
+// detach removes the given client from the display if it is still attached, and closes the
+// display if no client resumes it within the window.
+func (r *resumableDisplay) detach(client *displayClient) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.detachLocked(client)
+}
+
+// detachCurrent removes whichever client is attached to the display. It is used when a
+// client resumes the connection before the server noticed the previous one was gone.
+func (r *resumableDisplay) detachCurrent() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.client != nil {
+		r.detachLocked(r.client)
+	}
+}
+
+func (r *resumableDisplay) detachLocked(client *displayClient) {
+	if r.client != client {
+		return
+	}
+	r.client = nil
+	close(client.detached)
+	if err := client.ws.Close(); err != nil {
+		apiLogger.Error(err, "Failed to close detached display client")
+	}
+	r.startExpiryLocked()
+}
+
+func (r *resumableDisplay) startExpiryLocked() {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	if r.expiry != nil {
+		return
+	}
+	r.expiry = time.AfterFunc(r.window, r.close)
+}
+
+// close ends the connection to the desktop and forgets the display.
+func (r *resumableDisplay) close() {
+	r.closeOnce.Do(func() {
+		if err := r.conn.Close(); err != nil {
+			apiLogger.Error(err, "Failed to close resumable display connection to proxy")
+		}
+		r.mux.Lock()
+		if r.expiry != nil {
+			r.expiry.Stop()
+		}
+		r.mux.Unlock()
+		close(r.done)
+		if r.onClose != nil {
+			r.onClose()
+		}
+	})
+}
+
+// getResumeID returns the resume ID the client connected with, if any.
+func getResumeID(r *http.Request) string {
+	return r.URL.Query().Get("resume")
+}
+
+// detachResumingClient removes the client attached to the display the request is resuming,
+// if there is one. A client dropped by the network may look connected until its socket times
+// out, and would hold the display lock until then.
+func (d *desktopAPI) detachResumingClient(r *http.Request) {
+	id := getResumeID(r)
+	if id == "" || !d.vdiCluster.DisplayResumeEnabled() {
+		return
+	}
+	key := resumeKey(apiutil.GetNamespacedNameFromRequest(r), apiutil.GetRequestUserSession(r).User.Name)
+	if display := d.displays.get(key, id); display != nil {
+		display.detachCurrent()
+	}
+}
+
+// serveResumableDisplay serves a display connection that may be resumed with the given ID.
+// When a display for the user and session was opened with the same ID, the client is
+// attached to it and receives the output it missed since the `offset` in the request.
+// Otherwise a new connection to the desktop is opened, replacing any other resumable
+// display the user had for the session.
+func (d *desktopAPI) serveResumableDisplay(w http.ResponseWriter, r *http.Request, id string) {
+	if len(id) > maxResumeIDLength {
+		apiutil.ReturnAPIError(errors.New("The resume ID is too long"), w)
+		return
+	}
+	var offset int64
+	if val := r.URL.Query().Get("offset"); val != "" {
+		var err error
+		if offset, err = strconv.ParseInt(val, 10, 64); err != nil || offset < 0 {
+			apiutil.ReturnAPIError(errors.New("The resume offset must be a positive integer"), w)
+			return
+		}
+	}
+
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	claims := apiutil.GetRequestUserSession(r)
+	key := resumeKey(nn, claims.User.Name)
+
+	display := d.displays.get(key, id)
+	if display == nil && offset == 0 {
+		proxy, err := d.getProxyClientForRequest(r)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		apiLogger.Info("Connecting to desktop proxy", "Path", r.URL.Path, "Resumable", true)
+		req, err := d.getDisplayRequest(r)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		conn, err := proxy.DisplayProxy(req)
+		if err != nil {
+			apiLogger.Error(err, "Error creating connection to proxy server")
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		display = newResumableDisplay(id, conn, d.vdiCluster.GetDisplayResumeBufferSize(), d.vdiCluster.GetDisplayResumeWindow())
+		d.displays.put(key, display)
+		go display.run(nn.String())
+	}
+
+	wsconn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		apiLogger.Error(err, "Failed to upgrade the websocket connection")
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer wsconn.Close()
+
+	if display == nil {
+		apiLogger.Info("Client tried to resume an unknown display connection", "Path", r.URL.Path)
+		closeWithReason(wsconn, types.DisconnectResumeExpired)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rw := apiutil.NewGorillaReadWriter(wsconn).WithTextHandler(d.displayControlHandler(r))
+	tracked := d.connections.track(ctx, nn, proxyproto.RequestTypeDisplay, rbac.EffectiveBandwidthWeight(claims.User.Roles), claims.User.Name, apiutil.GetClientAddr(r), wsconn, apiutil.GetRequestConn(r))
+	defer d.connections.untrack(nn, tracked)
+
+	client := &displayClient{Writer: faults.Writer(types.FaultPointDisplayFrames, tracked.Writer(rw)), ws: wsconn, detached: make(chan struct{})}
+	if !display.attach(client, offset) {
+		apiLogger.Info("Client missed too much display output to resume", "Path", r.URL.Path, "Offset", offset)
+		display.close()
+		closeWithReason(wsconn, types.DisconnectResumeExpired)
+		return
+	}
+	if offset > 0 {
+		apiLogger.Info("Resumed display connection", "Path", r.URL.Path, "Offset", offset)
+	}
+
+	clientReader := newActivityReader(tracked.Reader(rw))
+	// Only the primary monitor is recorded
+	if monitor, _ := requestedMonitor(r); monitor == 0 {
+		defer d.recordDisplay(nn, claims.User.Name, apiutil.GetClientAddr(r), clientReader)()
+	}
+
+	// Copy client connection to server. When the client drops, the display is held open
+	// for it to resume.
+	go func() {
+		defer cancel()
+		if _, err := bufpool.CopyFor(nn.String(), display.conn, clientReader); err != nil {
+			apiLogger.Info("Display client disconnected, holding connection open for resume", "Path", r.URL.Path, "Error", err.Error())
+		}
+		display.detach(client)
+	}()
+
+	// Watch for reasons to end the connection
+	reasonCh := make(chan types.DisconnectReason, 1)
+	go func() {
+		reason := d.watchConnection(ctx, claims, nn, clientReader, d.vdiCluster.GetDisplayIdleTimeout())
+		if reason != "" {
+			cancel()
+		}
+		reasonCh <- reason
+	}()
+
+	var desktopClosed bool
+	select {
+	case <-ctx.Done():
+	case <-client.detached:
+	case <-display.done:
+		desktopClosed = true
+	}
+	cancel()
+
+	reason := <-reasonCh
+	if reason == "" && desktopClosed {
+		reason = d.disconnectReason(context.Background(), claims, nn)
+	}
+	if reason != "" {
+		// the connection cannot be resumed after the server ended it
+		display.close()
+		apiLogger.Info("Closing websocket connection", "Path", r.URL.Path, "Reason", reason)
+		closeWithReason(wsconn, reason)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// revokeUserTokens invalidates every access and refresh token currently issued to the
+// given user. Access tokens issued before the current time are rejected by the session
+// middleware until they would have expired anyway.
+func (d *desktopAPI) revokeUserTokens(username string) error {
+	// the user is no longer signed in on any of their devices
+	if err := d.userDevices.SignOutAll(username); err != nil {
+		return err
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+
+	if err := d.writeRevocation(username, time.Now()); err != nil {
+		return err
+	}
+
+	// drop any refresh tokens belonging to the user
+	tokens, err := d.secrets.ReadSecretMap(v1.RefreshTokensSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	for token, value := range tokens {
+		record := &refreshTokenRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			// tokens issued by previous versions only contain the username
+			record = &refreshTokenRecord{User: string(value)}
+		}
+		if record.User == username {
+			delete(tokens, token)
+		}
+	}
+	return d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
+}
+
+// revokeDeviceTokens invalidates every access and refresh token issued to the given user
+// on the given device.
+func (d *desktopAPI) revokeDeviceTokens(username, device string) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+
+	if err := d.writeRevocation(deviceRevocationKey(username, device), time.Now()); err != nil {
+		return err
+	}
+
+	tokens, err := d.secrets.ReadSecretMap(v1.RefreshTokensSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	for token, value := range tokens {
+		record := &refreshTokenRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			continue
+		}
+		if record.User == username && record.Device == device {
+			delete(tokens, token)
+		}
+	}
+	return d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
+}
+
+// writeRevocation records that tokens matching the given key were revoked at the given
+// time, and drops revocations that no token issued before them could still be affected by.
+// The secrets lock must be held by the caller.
+func (d *desktopAPI) writeRevocation(key string, now time.Time) error {
+	revoked, err := d.secrets.ReadSecretMap(v1.RevokedTokensSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		revoked = make(map[string][]byte)
+	}
+	pruneRevocations(revoked, now, d.maxTokenLifetime())
+	revoked[key] = []byte(strconv.FormatInt(now.UnixNano(), 10))
+	return d.secrets.WriteSecretMap(v1.RevokedTokensSecretKey, revoked)
+}
+
+// maxTokenLifetime returns the longest any access token may be valid for.
+func (d *desktopAPI) maxTokenLifetime() time.Duration {
+	lifetime := d.vdiCluster.GetTokenDuration()
+	if ttl := d.vdiCluster.GetAccessTokenTTL(); ttl > lifetime {
+		lifetime = ttl
+	}
+	return lifetime
+}
+
+// pruneRevocations removes revocations older than the given token lifetime from the map.
+// Every token issued before them has expired. Values that cannot be parsed are removed too.
+func pruneRevocations(revoked map[string][]byte, now time.Time, lifetime time.Duration) {
+	for key, value := range revoked {
+		revokedAt, err := parseRevocationTime(value)
+		if err != nil || now.Sub(revokedAt) > lifetime {
+			delete(revoked, key)
+		}
+	}
+}
+
+// parseRevocationTime parses the time tokens were revoked. Revocations written by previous
+// versions are in unix seconds, current ones are in unix nanoseconds.
+func parseRevocationTime(value []byte) (time.Time, error) {
+	ts, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts < 1e12 {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Unix(0, ts), nil
+}
+
+// deviceRevocationKey returns the key in the revoked tokens map holding the time tokens
+// issued to the given user on the given device were revoked.
+func deviceRevocationKey(username, device string) string {
+	return username + "/devices/" + device
+}
+
+// tokenIsRevoked returns true if the given claims were issued before the user's tokens
+// were last revoked, or before the tokens of the device they were issued to were. When
+// there are multiple app replicas the revocation list is always read from the backend,
+// since a peer may have updated it.
+func (d *desktopAPI) tokenIsRevoked(claims *types.JWTClaims) (bool, error) {
+	if claims.User == nil {
+		return false, nil
+	}
+	useCache := *d.vdiCluster.GetAppReplicas() <= 1
+	revoked, err := d.secrets.ReadSecretMap(v1.RevokedTokensSecretKey, useCache)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	keys := []string{claims.User.GetName()}
+	if claims.Device != "" {
+		keys = append(keys, deviceRevocationKey(claims.User.GetName(), claims.Device))
+	}
+	for _, key := range keys {
+		value, ok := revoked[key]
+		if !ok {
+			continue
+		}
+		revokedAt, err := parseRevocationTime(value)
+		if err != nil {
+			return false, err
+		}
+		if issuedBefore(claims, revokedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// issuedBefore returns true if the given claims were issued before the given time. Tokens
+// issued by previous versions only carry the second they were issued, and are considered
+// issued before any time within or after that second.
+func issuedBefore(claims *types.JWTClaims, t time.Time) bool {
+	if claims.IssuedAtNanos == 0 {
+		return claims.IssuedAt <= t.Unix()
+	}
+	return time.Unix(0, claims.IssuedAtNanos).Before(t)
+}
@@ -27,7 +27,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
 	"github.com/tinyzimmer/kvdi/pkg/version"
 	"golang.org/x/net/websocket"
 )
@@ -48,6 +50,9 @@ func (d *desktopAPI) buildRouter() error {
 	// Setup the decoder
 	r.Use(DecodeRequest)
 
+	// Run deployment-specific middleware before authentication
+	r.Use(d.middleware.Handler(appv1.MiddlewareStagePreAuth))
+
 	// simple version handler - TODO: should be cleaned up and documented
 	r.PathPrefix("/api/version").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		apiutil.WriteJSON(map[string]string{
@@ -63,6 +68,10 @@ func (d *desktopAPI) buildRouter() error {
 	r.PathPrefix("/api/healthz").HandlerFunc(d.Healthz).Methods("GET")
 	r.PathPrefix("/api/readyz").HandlerFunc(d.Readyz).Methods("GET")
 
+	// Latency probe for clients choosing between gateways. It is requested cross-origin
+	// from the other gateways, so it does not require authentication.
+	r.PathPrefix("/api/gateways/probe").HandlerFunc(d.GetGatewayProbe).Methods("GET")
+
 	// Grafana proxy - This is unprotected for now, but should figure out what
 	// permission model would work well for it. It doesn't fit well into the existing
 	// paradigms.
@@ -70,6 +79,12 @@ func (d *desktopAPI) buildRouter() error {
 		Scheme: "http",
 		Host:   "127.0.0.1:3000",
 	})
+	// grafana serves from the sub path under the app path prefix
+	director := grafanaProxy.Director
+	grafanaProxy.Director = func(req *http.Request) {
+		director(req)
+		req.URL.Path = d.vdiCluster.GetAppPathPrefix() + req.URL.Path
+	}
 	grafanaProxy.ModifyResponse = func(res *http.Response) error {
 		res.Header.Del("X-Frame-Options")
 		return nil
@@ -84,6 +99,26 @@ func (d *desktopAPI) buildRouter() error {
 
 	r.PathPrefix("/api/refresh_token").HandlerFunc(d.GetRefreshToken).Methods("GET") // Refresh a user's access token
 
+	// Break-glass routes are not protected since they are meant for when the auth provider
+	// cannot issue tokens. They are disabled unless configured on the VDICluster.
+	r.PathPrefix("/api/break-glass/challenge").HandlerFunc(d.PostBreakGlassChallenge).Methods("POST") // Retrieve a challenge to sign with the break-glass key
+	r.PathPrefix("/api/break-glass/unlock").HandlerFunc(d.PostBreakGlassUnlock).Methods("POST")       // Present a signed challenge for a break-glass token
+
+	// Forward-auth route for reverse proxies fronting other applications. It validates
+	// the session itself so it can answer with a 401 instead of going through the
+	// protected middleware.
+	r.PathPrefix("/api/forward-auth").HandlerFunc(d.GetForwardAuth)
+
+	// Back-channel for the kvdi-agent inside desktops. These routes authenticate with the
+	// token issued to the session instead of a user session.
+	agent := r.PathPrefix("/api/sessions/{namespace}/{name}/agent").Subrouter()
+	agent.Use(d.ValidateAgentToken)
+	agent.HandleFunc("/health", d.PostAgentHealth).Methods("POST")         // Report the health of apps inside the desktop
+	agent.HandleFunc("/resolution", d.PostAgentResolution).Methods("POST") // Request a new display resolution
+	agent.HandleFunc("/broadcast", d.GetAgentBroadcast).Methods("GET")     // Retrieve the latest broadcast to the desktop
+	agent.HandleFunc("/metadata", d.GetAgentMetadata).Methods("GET")       // Retrieve metadata for tools inside the desktop
+	agent.HandleFunc("/kubeconfig", d.GetAgentKubeconfig).Methods("GET")   // Retrieve a short-lived kubeconfig for the session
+
 	// Main HTTP routes
 
 	protected := r.PathPrefix("/api").Subrouter()
@@ -92,51 +127,158 @@ func (d *desktopAPI) buildRouter() error {
 	protected.Use(d.ValidateUserSession)
 	// check the grants for the request user
 	protected.Use(d.ValidateUserGrants)
+	// Run deployment-specific middleware on authenticated requests
+	protected.Use(d.middleware.Handler(appv1.MiddlewareStagePostAuth))
 
 	// SUBROUTER ASSUMES /api PREFIX ON ALL ROUTES
 
 	protected.HandleFunc("/authorize", d.PostAuthorize).Methods("POST") // Verify a user's MFA token
 
+	// Fault injection routes, only served by builds with fault injection enabled
+	if faults.Enabled {
+		protected.HandleFunc("/faults", d.GetFaults).Methods("GET")       // Retrieve the faults injected into the server
+		protected.HandleFunc("/faults", d.PutFaults).Methods("PUT")       // Replace the faults injected into the server
+		protected.HandleFunc("/faults", d.DeleteFaults).Methods("DELETE") // Remove all faults injected into the server
+	}
+
 	// Misc routes
 	protected.HandleFunc("/logout", d.PostLogout).Methods("POST")                             // Cleans up user's desktops
+	protected.HandleFunc("/logout/all", d.PostLogoutAll).Methods("POST")                      // Revokes all of the user's tokens
+	protected.HandleFunc("/renew_token", d.PostRenewToken).Methods("POST")                    // Renew the current access token
 	protected.HandleFunc("/whoami", d.GetWhoAmI).Methods("GET")                               // Convenience route for decoding JWTs
 	protected.HandleFunc("/config", d.GetConfig).Methods("GET")                               // Retrieve server configuration
+	protected.HandleFunc("/config/validate", d.GetConfigValidation).Methods("GET")            // Check the server configuration end-to-end
+	protected.HandleFunc("/config/staged", d.GetStagedConfig).Methods("GET")                  // Preview the staged server configuration
+	protected.HandleFunc("/config/staged", d.PutStagedConfig).Methods("PUT")                  // Stage a proposed server configuration
+	protected.HandleFunc("/config/staged", d.DeleteStagedConfig).Methods("DELETE")            // Discard the staged server configuration
+	protected.HandleFunc("/config/staged/promote", d.PostConfigPromote).Methods("POST")       // Promote the staged server configuration
+	protected.HandleFunc("/config/rollback", d.PostConfigRollback).Methods("POST")            // Roll back the last promoted server configuration
+	protected.HandleFunc("/backup", d.PostBackup).Methods("POST")                             // Export the cluster state to an encrypted archive
+	protected.HandleFunc("/restore", d.PostRestore).Methods("POST")                           // Restore the cluster state from an encrypted archive
 	protected.HandleFunc("/namespaces", d.GetNamespaces).Methods("GET")                       // Retrieve a list of available namespaces for the requesting user
 	protected.HandleFunc("/serviceaccounts/{namespace}", d.GetServiceAccounts).Methods("GET") // Retrieve a list of available service accounts for the requesting user
 
 	// User operations
-	protected.HandleFunc("/users", d.GetUsers).Methods("GET")                           // Retrieve a list of all users
-	protected.HandleFunc("/users", d.PostUsers).Methods("POST")                         // Create a new user
-	protected.HandleFunc("/users/{user}", d.GetUser).Methods("GET")                     // Retrieve information for a single user
-	protected.HandleFunc("/users/{user}", d.PutUser).Methods("PUT")                     // Update a user
-	protected.HandleFunc("/users/{user}/mfa", d.GetUserMFA).Methods("GET")              // Retrieve MFA status for a user
-	protected.HandleFunc("/users/{user}/mfa", d.PutUserMFA).Methods("PUT")              // Update MFA status for a user
-	protected.HandleFunc("/users/{user}/mfa/verify", d.PutUserMFAVerify).Methods("PUT") // Verify that a user has succesfully configured MFA
-	protected.HandleFunc("/users/{user}", d.DeleteUser).Methods("DELETE")               // Delete a user
+	protected.HandleFunc("/users", d.GetUsers).Methods("GET")                                       // Retrieve a list of all users
+	protected.HandleFunc("/users", d.PostUsers).Methods("POST")                                     // Create a new user
+	protected.HandleFunc("/users/{user}", d.GetUser).Methods("GET")                                 // Retrieve information for a single user
+	protected.HandleFunc("/users/{user}", d.PutUser).Methods("PUT")                                 // Update a user
+	protected.HandleFunc("/users/{user}/mfa", d.GetUserMFA).Methods("GET")                          // Retrieve MFA status for a user
+	protected.HandleFunc("/users/{user}/mfa", d.PutUserMFA).Methods("PUT")                          // Update MFA status for a user
+	protected.HandleFunc("/users/{user}/mfa/verify", d.PutUserMFAVerify).Methods("PUT")             // Verify that a user has succesfully configured MFA
+	protected.HandleFunc("/users/{user}/revoke", d.PostUserRevoke).Methods("POST")                  // Revoke all tokens issued to a user
+	protected.HandleFunc("/users/{user}/access_override", d.PostUserAccessOverride).Methods("POST") // Issue a token letting a user in outside of their access hours
+	protected.HandleFunc("/users/{user}/homeshare", d.PutUserHomeShare).Methods("PUT")              // Set the credentials used to mount a user's home share
+	protected.HandleFunc("/users/{user}/homeshare", d.DeleteUserHomeShare).Methods("DELETE")        // Remove the credentials used to mount a user's home share
+	protected.HandleFunc("/users/{user}/volume", d.GetUserVolume).Methods("GET")                    // Retrieve the volume holding a user's $HOME directory
+	protected.HandleFunc("/users/{user}/volume", d.PutUserVolume).Methods("PUT")                    // Expand the volume holding a user's $HOME directory
+	protected.HandleFunc("/users/{user}", d.DeleteUser).Methods("DELETE")                           // Delete a user
+	protected.HandleFunc("/users/{user}/restore", d.PostUserRestore).Methods("POST")                // Restore a deleted user
+	protected.HandleFunc("/deleted", d.GetDeleted).Methods("GET")                                   // Retrieve the deleted users and roles that can still be restored
+	protected.HandleFunc("/directory/search", d.GetDirectorySearch).Methods("GET")                  // Search the user directory for users and groups
+
+	// User metadata operations
+	protected.HandleFunc("/users/{user}/metadata", d.GetUserMetadata).Methods("GET")                              // Retrieve all of a user's metadata
+	protected.HandleFunc("/users/{user}/metadata/{namespace}", d.GetUserMetadataNamespace).Methods("GET")         // Retrieve the keys and values in a namespace of a user's metadata
+	protected.HandleFunc("/users/{user}/metadata/{namespace}", d.DeleteUserMetadataNamespace).Methods("DELETE")   // Remove a namespace from a user's metadata
+	protected.HandleFunc("/users/{user}/metadata/{namespace}/{key}", d.GetUserMetadataValue).Methods("GET")       // Retrieve a value from a user's metadata
+	protected.HandleFunc("/users/{user}/metadata/{namespace}/{key}", d.PutUserMetadataValue).Methods("PUT")       // Set a value in a user's metadata
+	protected.HandleFunc("/users/{user}/metadata/{namespace}/{key}", d.DeleteUserMetadataValue).Methods("DELETE") // Remove a value from a user's metadata
+
+	// User device operations
+	protected.HandleFunc("/users/{user}/devices", d.GetUserDevices).Methods("GET")               // Retrieve the devices a user has signed in from
+	protected.HandleFunc("/users/{user}/devices/{device}", d.DeleteUserDevice).Methods("DELETE") // Sign a device out and forget it
+
+	// Domain join operations
+	protected.HandleFunc("/domain/hosts", d.GetDomainHosts).Methods("GET")             // Retrieve the hosts domain joined desktops may lease
+	protected.HandleFunc("/domain/hosts/{host}", d.PutDomainHost).Methods("PUT")       // Upload the keytab of a host for domain joined desktops
+	protected.HandleFunc("/domain/hosts/{host}", d.DeleteDomainHost).Methods("DELETE") // Remove the keytab of a host
 
 	// Role operations
-	protected.HandleFunc("/roles", d.GetRoles).Methods("GET")             // Retrieve a list of all VDIRoles
-	protected.HandleFunc("/roles", d.CreateRole).Methods("POST")          // Create a new VDIRole
-	protected.HandleFunc("/roles/{role}", d.GetRole).Methods("GET")       // Retrieve information for a single VDIRole
-	protected.HandleFunc("/roles/{role}", d.UpdateRole).Methods("PUT")    // Update a VDIRole
-	protected.HandleFunc("/roles/{role}", d.DeleteRole).Methods("DELETE") // Delete a VDIRole
+	protected.HandleFunc("/roles", d.GetRoles).Methods("GET")                               // Retrieve a list of all VDIRoles
+	protected.HandleFunc("/roles", d.CreateRole).Methods("POST")                            // Create a new VDIRole
+	protected.HandleFunc("/roles/{role}", d.GetRole).Methods("GET")                         // Retrieve information for a single VDIRole
+	protected.HandleFunc("/roles/{role}", d.UpdateRole).Methods("PUT")                      // Update a VDIRole
+	protected.HandleFunc("/roles/{role}", d.DeleteRole).Methods("DELETE")                   // Delete a VDIRole
+	protected.HandleFunc("/roles/{role}/restore", d.PostRoleRestore).Methods("POST")        // Restore a deleted VDIRole
+	protected.HandleFunc("/roles/{role}/effective", d.GetRoleEffectiveRules).Methods("GET") // Retrieve the rules a VDIRole grants, including inherited ones
+
+	// Gateway operations
+	protected.HandleFunc("/gateways", d.GetGateways).Methods("GET")               // Retrieve the available gateways and the one selected for the user
+	protected.HandleFunc("/gateways/latency", d.PutGatewayLatency).Methods("PUT") // Report the latencies measured to each gateway
+
+	// Authorization debugging operations
+	protected.HandleFunc("/authz/check", d.PostAuthzCheck).Methods("POST")  // Check whether a user may perform an action
+	protected.HandleFunc("/authz/who-can", d.GetAuthzWhoCan).Methods("GET") // List the users and roles allowed to perform an action
+	protected.HandleFunc("/authz/matrix", d.GetAuthzMatrix).Methods("GET")  // Generate a matrix of the permissions held by every user
+
+	// Audit log operations
+	protected.HandleFunc("/audit", d.GetAudit).Methods("GET") // Query recent audit events
+
+	// Session recording operations
+	protected.HandleFunc("/recordings", d.GetRecordings).Methods("GET")                             // Retrieve session recordings
+	protected.HandleFunc("/recordings/{recording}", d.GetRecording).Methods("GET")                  // Retrieve a single session recording
+	protected.HandleFunc("/recordings/{recording}", d.DeleteRecording).Methods("DELETE")            // Delete a session recording
+	protected.HandleFunc("/recordings/{recording}/playback", d.GetRecordingPlayback).Methods("GET") // Stream the events of a session recording
+
+	// Template access request operations
+	protected.HandleFunc("/access_requests", d.GetAccessRequests).Methods("GET")                           // Retrieve template access requests
+	protected.HandleFunc("/access_requests", d.PostAccessRequest).Methods("POST")                          // Request access to a template
+	protected.HandleFunc("/access_requests/{request}/approve", d.PostAccessRequestApprove).Methods("POST") // Approve an access request
+	protected.HandleFunc("/access_requests/{request}/deny", d.PostAccessRequestDeny).Methods("POST")       // Deny an access request
 
 	// Template operations
-	protected.HandleFunc("/templates", d.GetDesktopTemplates).Methods("GET")                 // Retrieve a list of all available DesktopTemplates
-	protected.HandleFunc("/templates", d.PostDesktopTemplates).Methods("POST")               // Create a new DesktopTemplate
-	protected.HandleFunc("/templates/{template}", d.GetDesktopTemplate).Methods("GET")       // Retrieve information for a single DesktopTemplate
-	protected.HandleFunc("/templates/{template}", d.PutDesktopTemplate).Methods("PUT")       // Update a DesktopTemplate
-	protected.HandleFunc("/templates/{template}", d.DeleteDesktopTemplate).Methods("DELETE") // Delete a DesktopTemplate
+	protected.HandleFunc("/templates", d.GetDesktopTemplates).Methods("GET")                     // Retrieve a list of all available DesktopTemplates
+	protected.HandleFunc("/templates", d.PostDesktopTemplates).Methods("POST")                   // Create a new DesktopTemplate
+	protected.HandleFunc("/templates/validate", d.PostDesktopTemplateValidate).Methods("POST")   // Lint a DesktopTemplate without creating it
+	protected.HandleFunc("/templates/{template}", d.GetDesktopTemplate).Methods("GET")           // Retrieve information for a single DesktopTemplate
+	protected.HandleFunc("/templates/{template}", d.PutDesktopTemplate).Methods("PUT")           // Update a DesktopTemplate
+	protected.HandleFunc("/templates/{template}", d.DeleteDesktopTemplate).Methods("DELETE")     // Delete a DesktopTemplate
+	protected.HandleFunc("/templates/{template}/approve", d.PostTemplateApprove).Methods("POST") // Approve a draft template
+	protected.HandleFunc("/templates/{template}/reject", d.PostTemplateReject).Methods("POST")   // Reject a draft template
+
+	// Cluster capacity operations
+	protected.HandleFunc("/capacity", d.GetCapacity).Methods("GET")       // Retrieve desktop capacity by node architecture
+	protected.HandleFunc("/autoscaling", d.GetAutoscaling).Methods("GET") // Retrieve the desired desktop capacity for node autoscalers
+
+	// License pool operations
+	protected.HandleFunc("/licenses", d.GetLicenseUsage).Methods("GET") // Retrieve the usage of the license pools
+
+	// Template marketplace operations
+	protected.HandleFunc("/marketplace", d.GetMarketplace).Methods("GET")                             // List templates available from remote template indexes
+	protected.HandleFunc("/marketplace/{index}/{template}", d.PostMarketplaceInstall).Methods("POST") // Install or update a template from a remote template index
 
 	// Desktop session operations
-	protected.HandleFunc("/sessions", d.GetDesktopSessions).Methods("GET")                         // Retrieve status information for all desktop sessions
-	protected.HandleFunc("/sessions", d.StartDesktopSession).Methods("POST")                       // Start a new desktop session
-	protected.HandleFunc("/sessions/{namespace}/{name}", d.GetDesktopSessionStatus).Methods("GET") // Get the status of a desktop session
-	protected.HandleFunc("/sessions/{namespace}/{name}", d.DeleteDesktopSession).Methods("DELETE") // Stop a desktop session
+	protected.HandleFunc("/sessions", d.GetDesktopSessions).Methods("GET")                                            // Retrieve status information for all desktop sessions
+	protected.HandleFunc("/sessions", d.StartDesktopSession).Methods("POST")                                          // Start a new desktop session
+	protected.HandleFunc("/sessions/bulk", d.StartDesktopSessions).Methods("POST")                                    // Launch a template in several namespaces or for several users
+	protected.HandleFunc("/sessions/{namespace}/{name}", d.GetDesktopSessionStatus).Methods("GET")                    // Get the status of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}", d.DeleteDesktopSession).Methods("DELETE")                    // Stop a desktop session
+	protected.PathPrefix("/sessions/{namespace}/{name}/port/{port}/").HandlerFunc(d.ProxySessionPort)                 // Proxy HTTP requests to a port exposed by a desktop session
+	protected.PathPrefix("/sessions/{namespace}/{name}/ide/").HandlerFunc(d.ProxySessionIDE)                          // Proxy HTTP requests to the IDE server of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/ssh", d.PostSessionSSHCertificate).Methods("POST")             // Sign an SSH certificate for access to a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/kubeconfig", d.PostSessionKubeconfig).Methods("POST")          // Issue a short-lived kubeconfig for a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/template", d.PostSessionTemplate).Methods("POST")              // Save a desktop session as a draft template
+	protected.HandleFunc("/sessions/{namespace}/{name}/snapshot", d.PostSessionSnapshot).Methods("POST")              // Snapshot the persistent volumes of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/monitors", d.PostSessionMonitor).Methods("POST")               // Attach an additional monitor to a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/monitors/{monitor}", d.DeleteSessionMonitor).Methods("DELETE") // Detach a monitor from a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/devices", d.GetDesktopSessionDevices).Methods("GET")           // Get the effective device policy for a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/thumbnail", d.GetDesktopSessionThumbnail).Methods("GET")       // Get the most recent thumbnail of a desktop session's display
+	protected.HandleFunc("/sessions/{namespace}/{name}/connections", d.GetDesktopSessionConnections).Methods("GET")   // Get live statistics for the connections to a desktop session
+
+	// Lab operations
+	protected.HandleFunc("/labs", d.GetLabs).Methods("GET")                                              // Retrieve the labs the user can instruct
+	protected.HandleFunc("/labs/{namespace}/{name}", d.GetLab).Methods("GET")                            // Retrieve a single lab and the status of its sessions
+	protected.HandleFunc("/labs/{namespace}/{name}/broadcast", d.PostLabBroadcast).Methods("POST")       // Deliver a message to every session in a lab
+	protected.HandleFunc("/labs/{namespace}/{name}/lock", d.PostLabLock).Methods("POST")                 // Lock the screens of every session in a lab
+	protected.HandleFunc("/labs/{namespace}/{name}/unlock", d.PostLabUnlock).Methods("POST")             // Unlock the screens of every session in a lab
+	protected.HandleFunc("/labs/{namespace}/{name}/thumbnails/{user}", d.GetLabThumbnail).Methods("GET") // Retrieve a thumbnail of a user's display in a lab
+	protected.PathPrefix("/labs/{namespace}/{name}/collect/").HandlerFunc(d.GetLabFiles).Methods("GET")  // Collect a file from every session in a lab
 
 	// Methods for interacting with the kvdi-proxy
 	// // Plain HTTP routes
 	protected.HandleFunc("/desktops/{namespace}/{name}/logs/{container}", d.GetDesktopLogs).Methods("GET") // Retrieve the logs a container in the desktop
+	protected.HandleFunc("/desktops/{namespace}/{name}/display", d.PostDesktopDisplay).Methods("POST")     // Change the resolution and DPI of the display of a desktop
 	// // Websocket routes
 	protected.Path("/desktops/ws/{namespace}/{name}/status").Handler(&websocket.Server{ // Do a follow the session status for a desktop. Used to query connect readiness.
 		Handshake: func(*websocket.Config, *http.Request) error { return nil },
@@ -146,13 +288,17 @@ func (d *desktopAPI) buildRouter() error {
 		Handshake: func(*websocket.Config, *http.Request) error { return nil },
 		Handler:   d.GetDesktopLogsWebsocket,
 	})
-	protected.HandleFunc("/desktops/ws/{namespace}/{name}/display", d.GetWebsockify)    // Connect to the VNC socket on a desktop over websockets
-	protected.HandleFunc("/desktops/ws/{namespace}/{name}/audio", d.GetWebsockifyAudio) // Connect to the audio stream of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/display", d.GetWebsockify)                // Connect to the VNC socket on a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/audio", d.GetWebsockifyAudio)             // Connect to the audio stream of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/clipboard", d.GetWebsockifyClipboard)     // Sync the clipboard of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/ssh", d.GetWebsockifySSH)                 // Connect to the SSH server of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/plugins/{plugin}", d.GetWebsockifyPlugin) // Connect to a sidecar plugin of a desktop over websockets
 
 	// // Filesystem access
 	protected.PathPrefix("/desktops/fs/{namespace}/{name}/stat/").HandlerFunc(d.GetStatDesktopFile).Methods("GET")    // Retrieve file info or a directory listing from a desktop
 	protected.PathPrefix("/desktops/fs/{namespace}/{name}/get/").HandlerFunc(d.GetDownloadDesktopFile).Methods("GET") // Retrieve the contents of a file from a desktop
 	protected.HandleFunc("/desktops/fs/{namespace}/{name}/put", d.PutDesktopFile).Methods("PUT")                      // Uploads a file to a desktop
+	protected.HandleFunc("/desktops/fs/{namespace}/{name}/usage", d.GetDesktopHomeUsage).Methods("GET")               // Retrieve the storage usage of the user's home directory
 
 	d.router = r
 	return nil
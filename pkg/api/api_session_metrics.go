@@ -0,0 +1,101 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sessionMetricsRefreshInterval is how often the session metrics are refreshed.
+const sessionMetricsRefreshInterval = 15 * time.Second
+
+// sessionsTotal tracks the number of desktop sessions by namespace, template, and phase
+var sessionsTotal = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "kvdi",
+	Name:      "sessions",
+	Help:      "The current number of desktop sessions by namespace, template, and phase.",
+}, []string{"namespace", "template", "phase"})
+
+// sessionKey is the set of labels desktop sessions are counted by.
+type sessionKey struct {
+	namespace, template, phase string
+}
+
+// countSessions returns the number of sessions for each namespace, template, and phase.
+// Sessions being deleted are not counted.
+func countSessions(sessions []desktopsv1.Session) map[sessionKey]int {
+	counts := make(map[sessionKey]int)
+	for _, sess := range sessions {
+		if sess.GetDeletionTimestamp() != nil {
+			continue
+		}
+		phase := "pending"
+		switch {
+		case sess.Status.Paused:
+			phase = "paused"
+		case sess.Status.Running:
+			phase = "running"
+		}
+		counts[sessionKey{namespace: sess.GetNamespace(), template: sess.Spec.Template, phase: phase}]++
+	}
+	return counts
+}
+
+// updateSessionMetrics counts the desktop sessions of the cluster and publishes them to
+// the session metrics.
+func (d *desktopAPI) updateSessionMetrics() error {
+	sessions := &desktopsv1.SessionList{}
+	if err := d.client.List(context.TODO(), sessions, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return err
+	}
+	// start from scratch so templates without sessions drop off
+	sessionsTotal.Reset()
+	for key, count := range countSessions(sessions.Items) {
+		sessionsTotal.With(prometheus.Labels{
+			"namespace": key.namespace,
+			"template":  key.template,
+			"phase":     key.phase,
+		}).Set(float64(count))
+	}
+	return nil
+}
+
+// refreshSessionMetrics periodically recounts the desktop sessions of the cluster. It
+// runs for the life of the process.
+func (d *desktopAPI) refreshSessionMetrics() {
+	ticker := time.NewTicker(sessionMetricsRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if d.vdiCluster == nil {
+			continue
+		}
+		if err := d.updateSessionMetrics(); err != nil {
+			apiLogger.Error(err, "Failed to refresh session metrics")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateLaunchSnapshot checks that a session for the given user can be launched from the
+// snapshot in the request. The snapshot restores the user's $HOME to a new volume, so it
+// is only possible on clusters provisioning volumes from a userdataSpec, and when the user
+// does not already have a volume claimed in the namespace.
+func (d *desktopAPI) validateLaunchSnapshot(username string, tmpl *desktopsv1.Template, req *types.CreateSessionRequest) error {
+	if d.vdiCluster.GetUserdataVolumeSpec() == nil {
+		return errNoUserdataSpec
+	}
+	if selector := d.vdiCluster.GetUserdataSelector(); selector != nil && selector.IsValid() {
+		return errors.New("Snapshots cannot be restored to volumes located with a userdataSelector")
+	}
+	if tmpl.HomeShareReplacesHome() {
+		return fmt.Errorf("Template %s mounts a home share as the user's home directory", tmpl.GetName())
+	}
+
+	snapshot := &desktopsv1.DesktopSnapshot{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: req.FromSnapshot, Namespace: req.GetNamespace()}, snapshot); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return fmt.Errorf("No snapshot %s found in namespace %s", req.FromSnapshot, req.GetNamespace())
+		}
+		return err
+	}
+	if snapshot.GetUser() != username || snapshot.Spec.VDICluster != d.vdiCluster.GetName() {
+		return fmt.Errorf("Snapshot %s does not belong to %s", snapshot.GetName(), username)
+	}
+	if snapshot.GetTemplateName() != tmpl.GetName() {
+		return fmt.Errorf("Snapshot %s was taken of a %s desktop and cannot be restored to %s", snapshot.GetName(), snapshot.GetTemplateName(), tmpl.GetName())
+	}
+	if snapshot.GetVolume(v1.HomeVolume) == nil {
+		return fmt.Errorf("Snapshot %s does not contain the user's $HOME", snapshot.GetName())
+	}
+
+	// the user's existing claim would be mounted instead of the restored volume
+	pvc := &corev1.PersistentVolumeClaim{}
+	nn := ktypes.NamespacedName{Name: d.vdiCluster.GetUserdataVolumeName(username), Namespace: req.GetNamespace()}
+	if err := d.client.Get(context.TODO(), nn, pvc); err == nil {
+		return fmt.Errorf("%s already has a volume in use in namespace %s, stop their desktops before restoring a snapshot", username, req.GetNamespace())
+	} else if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// getTeamTree returns the team hierarchy for this cluster, from the cache when possible.
+func (d *desktopAPI) getTeamTree() (*rbac.TeamTree, error) {
+	if tree, ok := d.rbacCache.getTeamTree(); ok {
+		return tree, nil
+	}
+	generation := d.rbacCache.getGeneration()
+	teams, err := d.vdiCluster.GetTeams(d.client)
+	if err != nil {
+		return nil, err
+	}
+	tree := rbac.NewTeamTree(teams)
+	d.rbacCache.setTeamTree(tree, generation)
+	return tree, nil
+}
+
+// applyTeamRoles adds the roles granted to the user through their teams to the user's
+// existing roles.
+func (d *desktopAPI) applyTeamRoles(user *types.VDIUser) error {
+	tree, err := d.getTeamTree()
+	if err != nil {
+		return err
+	}
+	teamRoles := tree.UserRoles(user.GetName())
+	if len(teamRoles) == 0 {
+		return nil
+	}
+	roles, err := d.getRoles()
+	if err != nil {
+		return err
+	}
+	for _, name := range teamRoles {
+		if userHasRole(user, name) {
+			continue
+		}
+		for _, role := range roles {
+			if role.GetName() == name {
+				user.Roles = append(user.Roles, rbac.VDIRoleToUserRole(role))
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// populateResourceTeams sets the teams, including their ancestors, that the resource
+// targeted by the given action belongs to. Only users and roles can belong to teams.
+func (d *desktopAPI) populateResourceTeams(action *types.APIAction) error {
+	if action.ResourceName == "" {
+		return nil
+	}
+	if action.ResourceType != rbacv1.ResourceUsers && action.ResourceType != rbacv1.ResourceRoles {
+		return nil
+	}
+	tree, err := d.getTeamTree()
+	if err != nil {
+		return err
+	}
+	if action.ResourceType == rbacv1.ResourceUsers {
+		action.ResourceTeams = tree.UserTeams(action.ResourceName)
+	} else {
+		action.ResourceTeams = tree.RoleTeams(action.ResourceName)
+	}
+	return nil
+}
+
+func userHasRole(user *types.VDIUser, name string) bool {
+	for _, role := range user.Roles {
+		if role.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -20,12 +20,35 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/api/client"
+	"github.com/tinyzimmer/kvdi/pkg/recordings"
 	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+
+	"github.com/gorilla/mux"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 // mustNewTestAPI creates and starts a new HTTP server connected to the
@@ -183,4 +206,1979 @@ func TestUsers(t *testing.T) {
 		t.Error("Expected user not found error, got:", err)
 	}
 
+	// the deleted user should be kept for restoring
+	deleted, err := cl.GetDeletedObjects(rbacv1.ResourceUsers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Name != "test-user" || deleted[0].DeletedBy != "admin" {
+		t.Fatal("Expected the deleted user to be kept, got:", deleted)
+	}
+	if len(deleted[0].Roles) != 1 || deleted[0].Roles[0] != "test-cluster-launch-templates" {
+		t.Error("Expected the roles of the deleted user to be kept, got:", deleted[0].Roles)
+	}
+	if deleted, err = cl.GetDeletedObjects(rbacv1.ResourceRoles); err != nil {
+		t.Fatal(err)
+	} else if len(deleted) != 0 {
+		t.Error("Expected no deleted roles, got:", deleted)
+	}
+
+	// restore the user with their roles
+	if err := cl.RestoreVDIUser("test-user"); err != nil {
+		t.Fatal(err)
+	}
+	newUser, err = cl.GetVDIUser("test-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newUser.Roles) != 1 || newUser.Roles[0].GetName() != "test-cluster-launch-templates" {
+		t.Error("Expected the restored user to have their roles back, got:", newUser.Roles)
+	}
+	if err := cl.RestoreVDIUser("test-user"); err == nil {
+		t.Error("Expected error for restoring a user that was already restored, got nil")
+	} else if !strings.Contains(err.Error(), "No deleted users") {
+		t.Error("Expected deleted object not found error, got:", err)
+	}
+}
+
+// TestRevokeUserTokens tests that revoked tokens can no longer be used.
+func TestRevokeUserTokens(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if _, err := cl.WhoAmI(); err != nil {
+		t.Fatal("Expected to be able to query whoami, got:", err)
+	}
+
+	if err := cl.RevokeVDIUserTokens("admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the access token and the refresh token should both be rejected now
+	if _, err := cl.WhoAmI(); err == nil {
+		t.Error("Expected error using a revoked token, got nil")
+	}
+
+	// logging in again right away, within the same second, should work
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	admin, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if err := admin.RevokeVDIUserTokens("admin"); err != nil {
+		t.Fatal(err)
+	}
+	relogin, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relogin.Close()
+	if _, err := relogin.WhoAmI(); err != nil {
+		t.Error("Expected a token issued after the revocation to be accepted, got:", err)
+	}
+}
+
+func TestIssuedBefore(t *testing.T) {
+	revokedAt := time.Unix(1000, 500)
+	newClaims := func(secs, nanos int64) *types.JWTClaims {
+		claims := &types.JWTClaims{IssuedAtNanos: nanos}
+		claims.IssuedAt = secs
+		return claims
+	}
+	if !issuedBefore(newClaims(1000, revokedAt.UnixNano()-1), revokedAt) {
+		t.Error("Expected a token issued just before the revocation to be revoked")
+	}
+	if issuedBefore(newClaims(1000, revokedAt.UnixNano()+1), revokedAt) {
+		t.Error("Expected a token issued later in the same second to be accepted")
+	}
+	if !issuedBefore(newClaims(1000, 0), revokedAt) {
+		t.Error("Expected a legacy token issued in the same second to be revoked")
+	}
+	if issuedBefore(newClaims(1001, 0), revokedAt) {
+		t.Error("Expected a legacy token issued in a later second to be accepted")
+	}
+}
+
+func TestPruneRevocations(t *testing.T) {
+	now := time.Now()
+	revoked := map[string][]byte{
+		"recent":  []byte(strconv.FormatInt(now.Add(-time.Minute).UnixNano(), 10)),
+		"expired": []byte(strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10)),
+		"legacy":  []byte(strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)),
+		"invalid": []byte("not-a-time"),
+	}
+	pruneRevocations(revoked, now, 15*time.Minute)
+	if len(revoked) != 2 {
+		t.Error("Expected only the recent revocations to be kept, got:", revoked)
+	}
+	for _, key := range []string{"recent", "legacy"} {
+		if _, ok := revoked[key]; !ok {
+			t.Errorf("Expected the %s revocation to be kept", key)
+		}
+	}
+}
+
+// TestUserHomeShare tests setting and removing home share credentials.
+func TestUserHomeShare(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if err := cl.SetVDIUserHomeShare("admin", &types.HomeShareCredentials{
+		Username: "admin",
+	}); err == nil {
+		t.Error("Expected error setting home share credentials without a password, got nil")
+	}
+
+	if err := cl.SetVDIUserHomeShare("admin", &types.HomeShareCredentials{
+		Username: "admin",
+		Password: "filer-password",
+		Domain:   "CORP",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cl.SetVDIUserHomeShare("no-user", &types.HomeShareCredentials{
+		Username: "no-user",
+		Password: "filer-password",
+	}); err == nil {
+		t.Error("Expected error setting home share credentials for missing user, got nil")
+	} else if !strings.Contains(err.Error(), "not found") {
+		t.Error("Expected user not found error, got:", err)
+	}
+
+	if err := cl.DeleteVDIUserHomeShare("admin"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUserMetadata tests storing and retrieving user metadata.
+func TestUserMetadata(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if _, err := cl.GetVDIUserMetadataValue("admin", "app", "theme"); err == nil {
+		t.Error("Expected error retrieving missing metadata, got nil")
+	}
+
+	if err := cl.SetVDIUserMetadataValue("admin", "app", "theme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.SetVDIUserMetadataValue("admin", "app", "font-size", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.SetVDIUserMetadataValue("admin", "not/valid", "theme", "dark"); err == nil {
+		t.Error("Expected error setting metadata in an invalid namespace, got nil")
+	}
+
+	if val, err := cl.GetVDIUserMetadataValue("admin", "app", "theme"); err != nil {
+		t.Fatal(err)
+	} else if val != "dark" {
+		t.Error("Expected theme to be dark, got:", val)
+	}
+
+	store, err := cl.GetVDIUserMetadata("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store["app"]) != 2 {
+		t.Error("Expected two keys in the app namespace, got:", store)
+	}
+
+	if err := cl.DeleteVDIUserMetadataValue("admin", "app", "theme"); err != nil {
+		t.Fatal(err)
+	}
+	if values, err := cl.GetVDIUserMetadataNamespace("admin", "app"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := values["theme"]; ok || len(values) != 1 {
+		t.Error("Expected only font-size to remain, got:", values)
+	}
+
+	if err := cl.DeleteVDIUserMetadataNamespace("admin", "app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetVDIUserMetadataNamespace("admin", "app"); err == nil {
+		t.Error("Expected error retrieving deleted namespace, got nil")
+	}
+}
+
+// TestUserDevices tests listing and revoking the devices a user signed in from.
+func TestUserDevices(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	list, err := cl.GetVDIUserDevices("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Current || !list[0].Active {
+		t.Fatal("Expected the client to be the only, current, and active device, got:", list)
+	}
+
+	if err := cl.RevokeVDIUserDevice("admin", "not-a-device"); err == nil {
+		t.Error("Expected error revoking a missing device, got nil")
+	} else if !strings.Contains(err.Error(), "could not be found") {
+		t.Error("Expected device not found error, got:", err)
+	}
+
+	if err := cl.RevokeVDIUserDevice("admin", list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tokens issued to the device should be rejected now
+	if _, err := cl.WhoAmI(); err == nil {
+		t.Error("Expected error using a token issued to a revoked device, got nil")
+	}
+}
+
+func TestRecordingsDisabled(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	// the test cluster has no object storage configured for recordings
+	if _, err := cl.GetRecordings("", "", ""); err == nil {
+		t.Error("Expected error listing recordings while disabled, got nil")
+	} else if !strings.Contains(err.Error(), "not enabled") {
+		t.Error("Expected recording not enabled error, got:", err)
+	}
+	if _, err := cl.GetRecordingPlayback("f47ac10b-58cc-4372-a567-0e02b2c3d479"); err == nil {
+		t.Error("Expected error playing back a recording while disabled, got nil")
+	}
+}
+
+func TestRecordingLease(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme), vdiCluster: cluster, recordings: recordings.NewManager(), recorders: newSessionRecorders()}
+
+	nn := ktypes.NamespacedName{Name: "test-session", Namespace: "default"}
+	leaseName := lock.LeaseName(cluster.GetAppName()+"-recording", nn.String())
+	other := lock.NewLeaseLock(d.client, cluster.GetCoreNamespace(), leaseName, time.Minute)
+	if err := other.TryAcquire(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	active := &activeRecording{
+		lease:   lock.NewLeaseLock(d.client, cluster.GetCoreNamespace(), leaseName, time.Minute),
+		clients: make(map[*recordingClient]struct{}),
+		done:    make(chan struct{}),
+	}
+	// another instance is recording the session
+	d.takeRecording(nn, &desktopsv1.Session{}, active)
+	if active.recorder != nil {
+		t.Error("Expected the session to not be recorded while another instance holds the lease")
+	}
+	// the lease is taken over once the other instance releases it
+	if err := other.Release(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	d.takeRecording(nn, &desktopsv1.Session{}, active)
+	if err := other.TryAcquire(context.TODO()); err != lock.ErrLeaseHeld {
+		t.Error("Expected the recording lease to be held by this instance, got:", err)
+	}
+}
+
+// TestUserAccessOverride tests issuing and redeeming access override tokens.
+func TestUserAccessOverride(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if _, err := cl.IssueVDIUserAccessOverride("admin", &types.AccessOverrideRequest{
+		Duration: "48h",
+	}); err == nil {
+		t.Error("Expected error issuing an override longer than a day, got nil")
+	}
+
+	if _, err := cl.IssueVDIUserAccessOverride("no-user", &types.AccessOverrideRequest{}); err == nil {
+		t.Error("Expected error issuing an override for missing user, got nil")
+	} else if !strings.Contains(err.Error(), "not found") {
+		t.Error("Expected user not found error, got:", err)
+	}
+
+	resp, err := cl.IssueVDIUserAccessOverride("admin", &types.AccessOverrideRequest{
+		Duration: "2h",
+		Reason:   "maintenance window",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" {
+		t.Fatal("Expected an access override token in the response")
+	}
+
+	// logging in with the override token should succeed
+	overrideOpts := *opts
+	overrideOpts.AccessOverrideToken = resp.Token
+	overrideCl, err := client.New(&overrideOpts)
+	if err != nil {
+		t.Fatal("Expected to be able to log in with the override token, got:", err)
+	}
+	overrideCl.Close()
+
+	// logging in with a bogus token should not
+	overrideOpts.AccessOverrideToken = "not-a-token"
+	if badCl, err := client.New(&overrideOpts); err == nil {
+		badCl.Close()
+		t.Error("Expected error logging in with an invalid override token, got nil")
+	}
+}
+
+// TestForwardAuth tests validating sessions for reverse proxies.
+func TestForwardAuth(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+
+	body, err := json.Marshal(&types.LoginRequest{Username: opts.Username, Password: opts.Password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Post(opts.URL+"/api/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	session := &types.SessionResponse{}
+	if err := json.NewDecoder(res.Body).Decode(session); err != nil {
+		t.Fatal(err)
+	}
+
+	forwardAuth := func(path string, headers map[string]string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, opts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	if res := forwardAuth("/api/forward-auth", nil); res.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected unauthorized without a token, got:", res.StatusCode)
+	}
+
+	if res := forwardAuth("/api/forward-auth", map[string]string{"Authorization": "Bearer not-a-token"}); res.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected unauthorized with an invalid token, got:", res.StatusCode)
+	}
+
+	for _, headers := range []map[string]string{
+		{TokenHeader: session.Token},
+		{"Authorization": "Bearer " + session.Token},
+	} {
+		res := forwardAuth("/api/forward-auth", headers)
+		if res.StatusCode != http.StatusOK {
+			t.Error("Expected ok with a valid token, got:", res.StatusCode)
+			continue
+		}
+		if user := res.Header.Get(ForwardAuthUserHeader); user != "admin" {
+			t.Error("Expected admin user header, got:", user)
+		}
+		if roles := res.Header.Get(ForwardAuthRolesHeader); !strings.Contains(roles, "test-cluster-admin") {
+			t.Error("Expected admin role header, got:", roles)
+		}
+	}
+
+	headers := map[string]string{TokenHeader: session.Token}
+	if res := forwardAuth("/api/forward-auth?role=test-cluster-admin", headers); res.StatusCode != http.StatusOK {
+		t.Error("Expected ok with a bound role, got:", res.StatusCode)
+	}
+	if res := forwardAuth("/api/forward-auth?role=other-role", headers); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected forbidden without the required role, got:", res.StatusCode)
+	}
+
+	// tokens in the original URI end up in access logs and are not accepted
+	for _, header := range []string{"X-Forwarded-Uri", "X-Original-URI"} {
+		if res := forwardAuth("/api/forward-auth", map[string]string{header: "/grafana/?token=" + session.Token}); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected unauthorized with a token in %s, got: %d", header, res.StatusCode)
+		}
+	}
+
+	// users outside of their access hours are not let through
+	closed := time.Now().UTC().Add(2 * time.Hour)
+	_, token, err := apiutil.GenerateJWT([]byte("supersecret"), &types.AuthResult{
+		User: &types.VDIUser{
+			Name: "admin",
+			Roles: []*types.VDIUserRole{{
+				Name: "test-cluster-admin",
+				AccessHours: &rbacv1.AccessHours{Windows: []rbacv1.AccessWindow{{
+					StartTime: closed.Format("15:04"),
+					EndTime:   closed.Add(time.Hour).Format("15:04"),
+				}}},
+			}},
+		},
+	}, true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := forwardAuth("/api/forward-auth", map[string]string{TokenHeader: token}); res.StatusCode != http.StatusForbidden {
+		t.Error("Expected forbidden outside of access hours, got:", res.StatusCode)
+	}
+}
+
+// TestRoleCache tests that roles changed through the API are not served stale from
+// the role cache.
+func TestRoleCache(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	hasRole := func(name string) bool {
+		t.Helper()
+		roles, err := cl.GetVDIRoles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, role := range roles {
+			if role.GetName() == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// populate the cache
+	if hasRole("cached-role") {
+		t.Fatal("Expected cached-role to not exist yet")
+	}
+
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{Name: "cached-role"}); err != nil {
+		t.Fatal(err)
+	}
+	if !hasRole("cached-role") {
+		t.Error("Expected created role to be returned after creation")
+	}
+
+	if err := cl.DeleteVDIRole("cached-role"); err != nil {
+		t.Fatal(err)
+	}
+	if hasRole("cached-role") {
+		t.Error("Expected deleted role to not be returned after deletion")
+	}
+
+	if err := cl.RestoreVDIRole("cached-role"); err != nil {
+		t.Fatal(err)
+	}
+	if !hasRole("cached-role") {
+		t.Error("Expected restored role to be returned after restoring")
+	}
+}
+
+// TestUpdateConflicts tests that updates made against a stale version of a role or user are
+// rejected with the current state of the object.
+func TestUpdateConflicts(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{Name: "edited-role"}); err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := cl.GetVDIRoleVersion("edited-role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version == "" {
+		t.Fatal("Expected a version for the role")
+	}
+
+	// the first edit is made against the current version
+	newVersion, err := cl.UpdateVDIRoleVersion("edited-role", version, &types.UpdateRoleRequest{
+		Annotations: map[string]string{"editor": "first"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newVersion == "" || newVersion == version {
+		t.Error("Expected a new version after the update, got:", newVersion)
+	}
+
+	// the second is made against the version the first replaced
+	_, err = cl.UpdateVDIRoleVersion("edited-role", version, &types.UpdateRoleRequest{
+		Annotations: map[string]string{"editor": "second"},
+	})
+	if !errors.IsAPIConflict(err) {
+		t.Fatal("Expected a conflict updating a stale role, got:", err)
+	}
+	current := &rbacv1.VDIRole{}
+	if err := err.(*errors.APIError).DecodeCurrent(current); err != nil {
+		t.Fatal(err)
+	} else if current.GetAnnotations()["editor"] != "first" {
+		t.Error("Expected the conflict to contain the first edit, got:", current.GetAnnotations())
+	}
+
+	// updates without a version are always applied
+	if err := cl.UpdateVDIRole("edited-role", &types.UpdateRoleRequest{
+		Annotations: map[string]string{"editor": "third"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cl.CreateVDIUser(&types.CreateUserRequest{
+		Username: "edited-user",
+		Password: "password",
+		Roles:    []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, version, err = cl.GetVDIUserVersion("edited-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.UpdateVDIUserVersion("edited-user", version, &types.UpdateUserRequest{
+		Roles: []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.UpdateVDIUserVersion("edited-user", version, &types.UpdateUserRequest{
+		Roles: []string{"edited-role"},
+	})
+	if !errors.IsAPIConflict(err) {
+		t.Fatal("Expected a conflict updating a stale user, got:", err)
+	}
+	user := &types.VDIUser{}
+	if err := err.(*errors.APIError).DecodeCurrent(user); err != nil {
+		t.Fatal(err)
+	} else if len(user.Roles) != 1 || user.Roles[0].GetName() != "test-cluster-launch-templates" {
+		t.Error("Expected the conflict to contain the first edit, got:", user.Roles)
+	}
+}
+
+// TestUserVolume tests that user volumes can't be queried when the cluster does not
+// provision them.
+func TestUserVolume(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if _, err := cl.GetVDIUserVolume("admin"); err == nil {
+		t.Error("Expected error getting volume without a userdataSpec, got nil")
+	}
+	if _, err := cl.ExpandVDIUserVolume("admin", "20Gi"); err == nil {
+		t.Error("Expected error expanding volume without a userdataSpec, got nil")
+	}
+	if _, err := cl.ExpandVDIUserVolume("admin", "not-a-size"); err == nil {
+		t.Error("Expected error expanding volume to an invalid size, got nil")
+	}
+}
+
+func TestSessionSnapshot(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if _, err := cl.SnapshotDesktopSession(client.NamespacedName{Name: "missing", Namespace: "default"}, &types.CreateSnapshotRequest{}); err == nil {
+		t.Error("Expected error snapshotting a missing session, got nil")
+	}
+
+	desktop := &desktopsv1.Session{
+		Spec: desktopsv1.SessionSpec{VDICluster: "test-cluster", Template: "ubuntu", User: "admin"},
+	}
+	desktop.Name = "admin-desktop"
+	desktop.Namespace = "default"
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: v1.HomeVolume, VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "test-cluster-admin-userdata"},
+				}},
+			},
+		},
+	}
+	snapshot := newSnapshotForSession(nil, desktop, pod, &types.CreateSnapshotRequest{Name: "before-upgrade"})
+	if len(snapshot.Spec.Volumes) != 1 {
+		t.Fatal("Expected only the persistent volume to be snapshotted, got:", snapshot.Spec.Volumes)
+	}
+	source := snapshot.GetDataSource(v1.HomeVolume)
+	if source == nil || source.Name != "before-upgrade-home" || snapshot.GetTemplateName() != "ubuntu" {
+		t.Errorf("Expected the home volume to be restorable from the snapshot, got: %+v", snapshot.Spec)
+	}
+
+	if _, err := cl.CreateDesktopSession(&types.CreateSessionRequest{Template: "ubuntu", FromSnapshot: "before-upgrade"}); err == nil {
+		t.Error("Expected error launching from a snapshot without a userdataSpec, got nil")
+	}
+}
+
+func TestSessionMonitors(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	nn := client.NamespacedName{Name: "missing", Namespace: "default"}
+	if _, err := cl.AddDesktopSessionMonitor(nn, &types.AddMonitorRequest{Width: 1920, Height: 1080}); err == nil {
+		t.Error("Expected error adding a monitor to a missing session, got nil")
+	}
+	if _, err := cl.AddDesktopSessionMonitor(nn, &types.AddMonitorRequest{}); err == nil {
+		t.Error("Expected error adding a monitor without a resolution, got nil")
+	}
+	if _, err := cl.RemoveDesktopSessionMonitor(nn, 1); err == nil {
+		t.Error("Expected error removing a monitor from a missing session, got nil")
+	}
+
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DisplayConfig: &desktopsv1.DisplayConfig{
+				Monitors: &desktopsv1.MonitorsConfig{Max: 3},
+			},
+		},
+	}
+	req := &types.AddMonitorRequest{Width: 1920, Height: 1080}
+	monitors, err := addMonitor(tmpl, nil, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monitors) != 2 || monitors[0] != monitors[1] {
+		t.Error("Expected the primary monitor to match the first additional one, got:", monitors)
+	}
+	monitors, err = addMonitor(tmpl, monitors, &types.AddMonitorRequest{Width: 1280, Height: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addMonitor(tmpl, monitors, req); err == nil {
+		t.Error("Expected error exceeding the maximum number of monitors, got nil")
+	}
+	if _, err := addMonitor(tmpl, nil, &types.AddMonitorRequest{Width: 7680, Height: 4320}); err == nil {
+		t.Error("Expected error exceeding the maximum monitor resolution, got nil")
+	}
+
+	if _, err := removeMonitor(monitors, 0); err == nil {
+		t.Error("Expected error removing the primary monitor, got nil")
+	}
+	monitors, err = removeMonitor(monitors, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monitors) != 2 || monitors[1].Width != 1280 {
+		t.Error("Expected later monitors to move down an index, got:", monitors)
+	}
+
+	tmpl.Spec.DisplayConfig.Monitors.Max = 1
+	if _, err := addMonitor(tmpl, nil, req); err == nil {
+		t.Error("Expected error adding a monitor to a template without multiple monitors, got nil")
+	}
+}
+
+func TestDesktopDisplayResize(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	nn := client.NamespacedName{Name: "missing", Namespace: "default"}
+	if err := cl.ResizeDesktopDisplay(nn, &types.DisplayResizeRequest{Width: 1920, Height: 1080}); err == nil {
+		t.Error("Expected error resizing the display of a missing session, got nil")
+	}
+	if err := cl.ResizeDesktopDisplay(nn, &types.DisplayResizeRequest{Width: 1920, Height: 1080, DPI: 4}); err == nil {
+		t.Error("Expected error resizing the display with an invalid DPI, got nil")
+	}
+
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DisplayConfig: &desktopsv1.DisplayConfig{
+				Resize: &desktopsv1.DisplayResizeConfig{Enabled: true},
+			},
+		},
+	}
+	req := &types.DisplayResizeRequest{Width: 1920, Height: 1080, DPI: 144}
+	if err := checkResize(tmpl, nil, req); err != nil {
+		t.Error("Expected resize to be allowed, got:", err)
+	}
+	if err := checkResize(tmpl, nil, &types.DisplayResizeRequest{Width: 7680, Height: 4320}); err == nil {
+		t.Error("Expected error exceeding the maximum resolution, got nil")
+	}
+	monitors := []desktopsv1.DisplayResolution{{Width: 1920, Height: 1080}, {Width: 1920, Height: 1080}}
+	if err := checkResize(tmpl, monitors, req); err == nil {
+		t.Error("Expected error resizing a display spanning multiple monitors, got nil")
+	}
+
+	tmpl.Spec.DisplayConfig.Protocol = desktopsv1.DisplayProtocolSPICE
+	if err := checkResize(tmpl, nil, req); err == nil {
+		t.Error("Expected error resizing a non-VNC display, got nil")
+	}
+}
+
+// TestRoleEffectiveRules tests that the effective rules of a role include the rules of the
+// roles it inherits from.
+func TestRoleEffectiveRules(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	baseRule := rbacv1.Rule{
+		Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+		Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+		ResourcePatterns: []string{"base-.*"},
+	}
+	childRule := rbacv1.Rule{
+		Verbs:            []rbacv1.Verb{rbacv1.VerbLaunch},
+		Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+		ResourcePatterns: []string{"child-.*"},
+	}
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{Name: "base-role", Rules: []rbacv1.Rule{baseRule}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name:         "child-role",
+		Rules:        []rbacv1.Rule{childRule},
+		InheritsFrom: []string{"base-role", "missing-role"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	effective, err := cl.GetVDIRoleEffectiveRules("child-role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(effective.Rules) != 2 {
+		t.Fatal("Expected own and inherited rule, got:", effective.Rules)
+	}
+	if !effective.Rules[0].DeepEqual(childRule) || !effective.Rules[1].DeepEqual(baseRule) {
+		t.Error("Expected own rule followed by inherited rule, got:", effective.Rules)
+	}
+	if len(effective.InheritedRoles) != 1 || effective.InheritedRoles[0] != "base-role" {
+		t.Error("Expected rules inherited from base-role, got:", effective.InheritedRoles)
+	}
+	if len(effective.MissingRoles) != 1 || effective.MissingRoles[0] != "missing-role" {
+		t.Error("Expected missing-role to be reported missing, got:", effective.MissingRoles)
+	}
+
+	if _, err := cl.GetVDIRoleEffectiveRules("no-such-role"); err == nil {
+		t.Error("Expected error for role that does not exist")
+	}
+}
+
+// TestAuthzCheck tests dry-run authorization checks and who-can queries.
+func TestAuthzCheck(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name: "ubuntu-launchers",
+		Rules: []rbacv1.Rule{
+			{
+				Verbs:            []rbacv1.Verb{rbacv1.VerbLaunch},
+				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+				ResourcePatterns: []string{"^ubuntu-.*"},
+				Namespaces:       []string{rbacv1.NamespaceAll},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIUser(&types.CreateUserRequest{
+		Username: "authz-user",
+		Password: "test-password",
+		Roles:    []string{"ubuntu-launchers"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the permission matrix is evaluated against the templates that exist
+	for _, name := range []string{"ubuntu-desktop", "centos-desktop"} {
+		tmpl := &desktopsv1.Template{}
+		tmpl.Name = name
+		tmpl.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/" + name + ":latest"}
+		if err := cl.CreateDesktopTemplate(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the requesting user is checked when no user is given
+	res, err := cl.CheckAuthz(&types.AuthzCheckRequest{
+		Verb:         rbacv1.VerbLaunch,
+		Resource:     rbacv1.ResourceTemplates,
+		ResourceName: "centos-desktop",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User != "admin" || !res.Allowed || res.Role != "test-cluster-admin" || res.Rule == nil {
+		t.Error("Expected admin to be allowed by the cluster admin role, got:", res)
+	}
+
+	res, err = cl.CheckAuthz(&types.AuthzCheckRequest{
+		User:         "authz-user",
+		Verb:         rbacv1.VerbLaunch,
+		Resource:     rbacv1.ResourceTemplates,
+		ResourceName: "ubuntu-desktop",
+		Namespace:    "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Role != "ubuntu-launchers" {
+		t.Error("Expected authz-user to be allowed by ubuntu-launchers, got:", res)
+	}
+
+	res, err = cl.CheckAuthz(&types.AuthzCheckRequest{
+		User:         "authz-user",
+		Verb:         rbacv1.VerbLaunch,
+		Resource:     rbacv1.ResourceTemplates,
+		ResourceName: "centos-desktop",
+		Namespace:    "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Rule != nil {
+		t.Error("Expected authz-user to not be allowed to launch centos-desktop, got:", res)
+	}
+
+	if _, err := cl.CheckAuthz(&types.AuthzCheckRequest{User: "authz-user", Resource: rbacv1.ResourceTemplates}); err == nil {
+		t.Error("Expected check without a verb to be rejected")
+	}
+	if _, err := cl.CheckAuthz(&types.AuthzCheckRequest{
+		User:     "no-such-user",
+		Verb:     rbacv1.VerbRead,
+		Resource: rbacv1.ResourceTemplates,
+	}); err == nil {
+		t.Error("Expected check for a user that does not exist to fail")
+	}
+
+	whoCan, err := cl.WhoCan(&types.APIAction{
+		Verb:              rbacv1.VerbLaunch,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      "ubuntu-desktop",
+		ResourceNamespace: "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(whoCan.Users) != 2 {
+		t.Error("Expected admin and authz-user to be able to launch ubuntu-desktop, got:", whoCan.Users)
+	}
+	whoCan, err = cl.WhoCan(&types.APIAction{
+		Verb:         rbacv1.VerbLaunch,
+		ResourceType: rbacv1.ResourceTemplates,
+		ResourceName: "centos-desktop",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(whoCan.Users) != 1 || whoCan.Users[0] != "admin" {
+		t.Error("Expected only admin to be able to launch centos-desktop, got:", whoCan.Users)
+	}
+	for _, role := range whoCan.Roles {
+		if role == "ubuntu-launchers" {
+			t.Error("Expected ubuntu-launchers to not allow launching centos-desktop")
+		}
+	}
+
+	matrix, err := cl.GetPermissionMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, entry := range matrix.Entries {
+		if entry.User == "authz-user" && entry.Verb == rbacv1.VerbLaunch && entry.Template == "ubuntu-desktop" {
+			found = true
+			if entry.Role != "ubuntu-launchers" {
+				t.Error("Expected ubuntu-launchers to grant launching ubuntu-desktop, got:", entry)
+			}
+		}
+		if entry.User == "authz-user" && entry.Template == "centos-desktop" {
+			t.Error("Expected authz-user to hold no permissions on centos-desktop, got:", entry)
+		}
+	}
+	if !found {
+		t.Error("Expected authz-user to be able to launch ubuntu-desktop in the matrix")
+	}
+
+	rdr, err := cl.GetPermissionMatrixCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Close()
+	records, err := csv.NewReader(rdr).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(matrix.Entries)+1 {
+		t.Errorf("Expected %d csv records, got %d", len(matrix.Entries)+1, len(records))
+	}
+}
+
+// TestGateways tests listing gateways and reporting latencies to them.
+func TestGateways(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	gateways, err := cl.GetGateways()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gateways.Gateways) != 1 || gateways.Gateways[0].Name != "default" || gateways.Gateways[0].URL != "" {
+		t.Error("Expected only the default gateway, got:", gateways.Gateways)
+	}
+	if gateways.Selected == nil || gateways.Selected.Gateway != "default" {
+		t.Error("Expected the default gateway to be selected, got:", gateways.Selected)
+	}
+
+	sel, err := cl.ReportGatewayLatency(&types.GatewayLatencyReport{
+		Latencies: map[string]float64{"default": 25, "unknown": 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.Gateway != "default" || sel.LatencyMillis != 25 {
+		t.Error("Expected unknown gateways to be ignored, got:", sel)
+	}
+
+	if _, err := cl.ReportGatewayLatency(&types.GatewayLatencyReport{}); err == nil {
+		t.Error("Expected an empty latency report to be rejected")
+	}
+
+	// the probe does not require authentication
+	res, err := http.Get(opts.URL + "/api/gateways/probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("Expected the probe to be reachable from any origin, got:", res.StatusCode, res.Header)
+	}
+}
+
+// TestRoleValidation tests that roles with invalid resource patterns are rejected.
+func TestRoleValidation(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name: "invalid-role",
+		Rules: []rbacv1.Rule{
+			{
+				Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+				ResourcePatterns: []string{".*", "[unclosed"},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected role with an invalid pattern to be rejected")
+	}
+	if !strings.Contains(err.Error(), "rules[0].resourcePatterns[1]") {
+		t.Error("Expected error to name the invalid field, got:", err)
+	}
+
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{Name: "valid-role"}); err != nil {
+		t.Fatal(err)
+	}
+	err = cl.UpdateVDIRole("valid-role", &types.UpdateRoleRequest{
+		Rules: []rbacv1.Rule{{ResourcePatterns: []string{"(bad"}}},
+	})
+	if err == nil {
+		t.Error("Expected update with an invalid pattern to be rejected")
+	}
+}
+
+// TestDirectorySearch tests searching for users and groups with a provider that can't
+// search its directory directly.
+func TestDirectorySearch(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name:        "directory-role",
+		Annotations: map[string]string{v1.LDAPGroupRoleAnnotation: "cn=Engineers,ou=groups;cn=Admins,ou=groups"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"engineer-one", "engineer-two", "designer"} {
+		if err := cl.CreateVDIUser(&types.CreateUserRequest{
+			Username: name,
+			Password: "test-password",
+			Roles:    []string{"directory-role"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := cl.SearchDirectory(&types.DirectorySearchRequest{Query: "ENGINEER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 3 || res.Truncated {
+		t.Fatal("Expected two users and one group to match, got:", res.Results)
+	}
+	if res.Results[0].Name != "engineer-one" || res.Results[0].Kind != types.DirectoryEntryUser {
+		t.Error("Expected users to be sorted first, got:", res.Results[0])
+	}
+	if res.Results[2].Name != "cn=Engineers,ou=groups" || res.Results[2].Kind != types.DirectoryEntryGroup {
+		t.Error("Expected the group from the role annotation, got:", res.Results[2])
+	}
+
+	res, err = cl.SearchDirectory(&types.DirectorySearchRequest{Query: "engineer", Kind: types.DirectoryEntryUser, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || !res.Truncated {
+		t.Error("Expected the results to be truncated to the limit, got:", res)
+	}
+
+	if _, err := cl.SearchDirectory(&types.DirectorySearchRequest{}); err == nil {
+		t.Error("Expected a search without a query to be rejected")
+	}
+	if _, err := cl.SearchDirectory(&types.DirectorySearchRequest{Query: "engineer", Kind: "robot"}); err == nil {
+		t.Error("Expected a search for an unknown kind to be rejected")
+	}
+}
+
+// TestAuditLog tests querying the audit log.
+func TestAuditLog(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	if _, err := cl.GetAuditEvents(&types.AuditQuery{User: "admin", Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := cl.GetAuditEvents(&types.AuditQuery{Since: now, Until: now.Add(-time.Hour)}); err == nil {
+		t.Error("Expected a query with an inverted time range to be rejected")
+	}
+}
+
+func TestAutoscalingHints(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	hints, err := cl.GetAutoscalingHints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hints.DesiredDesktops != hints.RunningDesktops+hints.QueuedLaunches {
+		t.Error("Expected the desired desktops to match the sessions without autoscaling configured, got:", hints.DesiredDesktops)
+	}
+
+	now := time.Date(2021, time.March, 1, 8, 50, 0, 0, time.UTC) // a Monday
+	cfg := &appv1.AutoscalingConfig{
+		WarmPool:        2,
+		DesktopsPerNode: 4,
+		Schedules: []appv1.CapacitySchedule{
+			{
+				Name:        "workday",
+				MinDesktops: 20,
+				Schedule: rbacv1.Schedule{
+					Windows: []rbacv1.AccessWindow{{Days: []string{"Monday"}, StartTime: "09:00", EndTime: "17:00"}},
+				},
+			},
+			{
+				Name:        "weekend",
+				MinDesktops: 50,
+				Schedule: rbacv1.Schedule{
+					Windows: []rbacv1.AccessWindow{{Days: []string{"Saturday", "Sunday"}}},
+				},
+			},
+		},
+	}
+
+	// the workday schedule opens within the default lead time
+	hints = computeAutoscalingHints(cfg, 3, 1, now)
+	if hints.ScheduledMinimum != 20 || hints.DesiredDesktops != 20 || hints.DesiredNodes != 5 {
+		t.Errorf("Expected the workday schedule to set the desired capacity, got: %+v", hints)
+	}
+	if len(hints.ActiveSchedules) != 1 || hints.ActiveSchedules[0] != "workday" {
+		t.Error("Expected only the workday schedule to be active, got:", hints.ActiveSchedules)
+	}
+
+	// outside of all schedules the warm pool sits on top of the sessions
+	hints = computeAutoscalingHints(cfg, 3, 1, now.Add(-2*time.Hour))
+	if hints.ScheduledMinimum != 0 || hints.DesiredDesktops != 6 || hints.DesiredNodes != 2 {
+		t.Errorf("Expected the warm pool to set the desired capacity, got: %+v", hints)
+	}
+}
+
+func TestLaunchPrompts(t *testing.T) {
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			Prompts: []desktopsv1.LaunchPrompt{
+				{Name: "eula", Type: desktopsv1.LaunchPromptAcknowledge},
+				{Name: "classification", Type: desktopsv1.LaunchPromptChoice, Options: []string{"public", "internal"}},
+				{Name: "project", Pattern: "^[a-z]+$", Optional: true},
+			},
+		},
+	}
+
+	if _, err := checkLaunchPrompts(tmpl, map[string]string{"eula": "yes", "classification": "internal"}); err == nil {
+		t.Error("Expected a non-boolean acknowledgment to be rejected")
+	}
+	responses, err := checkLaunchPrompts(tmpl, map[string]string{"eula": "1", "classification": "internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responses["eula"] != "true" || responses["classification"] != "internal" {
+		t.Error("Expected the responses to be normalized, got:", responses)
+	}
+	if _, ok := responses["project"]; ok {
+		t.Error("Expected no response for the optional prompt, got:", responses["project"])
+	}
+
+	for _, invalid := range []map[string]string{
+		{"classification": "internal"},
+		{"eula": "false", "classification": "internal"},
+		{"eula": "true", "classification": "secret"},
+		{"eula": "true", "classification": "public", "project": "Not Valid"},
+		{"eula": "true", "classification": "public", "unknown": "value"},
+	} {
+		if _, err := checkLaunchPrompts(tmpl, invalid); err == nil {
+			t.Error("Expected responses to be rejected:", invalid)
+		}
+	}
+}
+
+func TestRequestedDisplayProtocol(t *testing.T) {
+	tcases := []struct {
+		query       string
+		subprotocol string
+		expected    desktopsv1.DisplayProtocol
+	}{
+		{"", "", ""},
+		{"", "binary", ""},
+		{"?protocol=SPICE", "", desktopsv1.DisplayProtocolSPICE},
+		{"", "binary, xpra", desktopsv1.DisplayProtocolXpra},
+		{"?protocol=novnc", "spice", desktopsv1.DisplayProtocolNoVNC},
+		{"", "guacamole", desktopsv1.DisplayProtocolRDP},
+	}
+	for _, tc := range tcases {
+		r := httptest.NewRequest(http.MethodGet, "/api/desktops/ws/default/test/display"+tc.query, nil)
+		if tc.subprotocol != "" {
+			r.Header.Set("Sec-Websocket-Protocol", tc.subprotocol)
+		}
+		if got := requestedDisplayProtocol(r); got != tc.expected {
+			t.Errorf("Expected %q for %q %q, got: %q", tc.expected, tc.query, tc.subprotocol, got)
+		}
+	}
+}
+
+func TestFileTransferLimits(t *testing.T) {
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			ProxyConfig: &desktopsv1.ProxyConfig{
+				AllowFileTransfer: true,
+				FileTransfer: &desktopsv1.FileTransferConfig{
+					DisableDownloads:    true,
+					MaxFileSize:         "1Ki",
+					AllowedContentTypes: []string{"text/*", "application/pdf"},
+				},
+			},
+		},
+	}
+	tmpl.Name = "restricted"
+
+	if !fileTransferAllowed(tmpl, fileTransferUpload) || fileTransferAllowed(tmpl, fileTransferDownload) {
+		t.Error("Expected uploads to be allowed and downloads to be denied")
+	}
+	if !fileTransferAllowed(tmpl, fileTransferBrowse) {
+		t.Error("Expected browsing to be allowed")
+	}
+
+	contentType, err := detectContentType(bytes.NewReader([]byte("just some notes")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFileTransferLimits(tmpl, "notes.txt", 15, contentType); err != nil {
+		t.Error("Expected text file to be allowed, got:", err)
+	}
+	if err := checkFileTransferLimits(tmpl, "large.txt", 2048, contentType); err == nil {
+		t.Error("Expected file over the size limit to be denied")
+	}
+	contentType, err = detectContentType(bytes.NewReader([]byte("\x89PNG\x0D\x0A\x1A\x0A")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFileTransferLimits(tmpl, "image.png", 8, contentType); err == nil {
+		t.Error("Expected image to be denied by the allowed content types")
+	}
+
+	tmpl.Spec.ProxyConfig.AllowFileTransfer = false
+	if fileTransferAllowed(tmpl, fileTransferUpload) || fileTransferAllowed(tmpl, fileTransferBrowse) {
+		t.Error("Expected file transfer to be denied when disabled on the template")
+	}
+}
+
+func TestClipboardRequest(t *testing.T) {
+	tmpl := &desktopsv1.Template{}
+	if req := clipboardRequest(tmpl, true); !req.ToDesktop || !req.FromDesktop {
+		t.Error("Expected clipboard to be allowed in both directions by default, got:", req.String())
+	}
+	if req := clipboardRequest(tmpl, false); req.ToDesktop || req.FromDesktop {
+		t.Error("Expected clipboard to be denied without the use-clipboard verb, got:", req.String())
+	}
+
+	disallowed := false
+	tmpl.Spec.ProxyConfig = &desktopsv1.ProxyConfig{AllowClipboardFrom: &disallowed}
+	if req := clipboardRequest(tmpl, true); !req.ToDesktop || req.FromDesktop {
+		t.Error("Expected clipboard to only be allowed to the desktop, got:", req.String())
+	}
+	if args := tmpl.GetVNCClipboardArgs(); args != "-SendCutText=0" {
+		t.Error("Unexpected VNC clipboard args:", args)
+	}
+}
+
+func TestSessionDevicePolicy(t *testing.T) {
+	tmpl := &desktopsv1.Template{}
+	if policy := sessionDevicePolicy(tmpl, &types.SessionDevicePolicy{AudioOut: true, AudioIn: true}, true); !policy.AudioOut || !policy.AudioIn {
+		t.Error("Expected audio to be allowed in both directions by default, got:", policy)
+	}
+	if policy := sessionDevicePolicy(tmpl, &types.SessionDevicePolicy{AudioOut: true, AudioIn: true}, false); !policy.AudioOut || policy.AudioIn {
+		t.Error("Expected the microphone to be denied without the use-microphone verb, got:", policy)
+	}
+
+	disabled := false
+	tmpl.Spec.AudioConfig = &desktopsv1.AudioConfig{Microphone: &disabled}
+	if policy := sessionDevicePolicy(tmpl, &types.SessionDevicePolicy{AudioOut: true, AudioIn: true}, true); !policy.AudioOut || policy.AudioIn {
+		t.Error("Expected the microphone to be denied by the template, got:", policy)
+	}
+	tmpl.Spec.AudioConfig = &desktopsv1.AudioConfig{Enabled: &disabled}
+	if policy := sessionDevicePolicy(tmpl, &types.SessionDevicePolicy{AudioOut: true, AudioIn: true}, true); policy.AudioOut || policy.AudioIn {
+		t.Error("Expected audio to be denied when disabled on the template, got:", policy)
+	}
+}
+
+func TestCountSessions(t *testing.T) {
+	newSession := func(namespace, template string, status desktopsv1.SessionStatus) desktopsv1.Session {
+		sess := desktopsv1.Session{Status: status}
+		sess.Namespace = namespace
+		sess.Spec.Template = template
+		return sess
+	}
+	counts := countSessions([]desktopsv1.Session{
+		newSession("default", "ubuntu", desktopsv1.SessionStatus{Running: true}),
+		newSession("default", "ubuntu", desktopsv1.SessionStatus{Running: true}),
+		newSession("default", "ubuntu", desktopsv1.SessionStatus{}),
+		newSession("team-a", "ubuntu", desktopsv1.SessionStatus{Paused: true}),
+		newSession("team-a", "arch", desktopsv1.SessionStatus{Running: true}),
+	})
+	for key, expected := range map[sessionKey]int{
+		{namespace: "default", template: "ubuntu", phase: "running"}: 2,
+		{namespace: "default", template: "ubuntu", phase: "pending"}: 1,
+		{namespace: "team-a", template: "ubuntu", phase: "paused"}:   1,
+		{namespace: "team-a", template: "arch", phase: "running"}:    1,
+	} {
+		if counts[key] != expected {
+			t.Errorf("Expected %d sessions for %+v, got: %d", expected, key, counts[key])
+		}
+	}
+	if len(counts) != 4 {
+		t.Error("Expected 4 session counts, got:", counts)
+	}
+}
+
+func TestWebsocketStream(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/api/desktops/ws/{namespace}/{name}/ssh":                 "ssh",
+		"/api/desktops/ws/{namespace}/{name}/logs/{container}":    "logs",
+		"/api/desktops/ws/{namespace}/{name}/plugins/{plugin}":    "plugins",
+		"/api/desktops/ws/{namespace}/{name}/clipboard/":          "clipboard",
+		"/api/sessions/{namespace}/{name}/ports/{port}/websocket": "websocket",
+	} {
+		if stream := websocketStream(path); stream != expected {
+			t.Errorf("Expected stream %q for %s, got: %q", expected, path, stream)
+		}
+	}
+}
+
+func TestDisconnectReason(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nn := ktypes.NamespacedName{Name: "desktop", Namespace: "default"}
+	newSession := func() *desktopsv1.Session {
+		return &desktopsv1.Session{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	}
+	newPod := func(nodeName, reason string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+		pod.Spec.NodeName = nodeName
+		pod.Status.Reason = reason
+		return pod
+	}
+	newNode := func(status corev1.ConditionStatus) *corev1.Node {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+		node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}
+		return node
+	}
+	claims := &types.JWTClaims{}
+	claims.IssuedAt = time.Now().Unix()
+
+	for _, tc := range []struct {
+		name     string
+		objs     []runtime.Object
+		expected types.DisconnectReason
+	}{
+		{"healthy", []runtime.Object{newSession(), newPod("node-a", ""), newNode(corev1.ConditionTrue)}, ""},
+		{"session deleted", nil, types.DisconnectAdminTerminated},
+		{"pod evicted", []runtime.Object{newSession(), newPod("node-a", podEvictedReason)}, types.DisconnectPodEvicted},
+		{"pod deleted", []runtime.Object{newSession()}, types.DisconnectPodEvicted},
+		{"pod node lost", []runtime.Object{newSession(), newPod("node-a", podNodeLostReason)}, types.DisconnectNodeLost},
+		{"node missing", []runtime.Object{newSession(), newPod("node-a", "")}, types.DisconnectNodeLost},
+		{"node not responding", []runtime.Object{newSession(), newPod("node-a", ""), newNode(corev1.ConditionUnknown)}, types.DisconnectNodeLost},
+	} {
+		d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme, tc.objs...), vdiCluster: &appv1.VDICluster{}}
+		if reason := d.disconnectReason(context.TODO(), claims, nn); reason != tc.expected {
+			t.Errorf("%s: expected reason %q, got: %q", tc.name, tc.expected, reason)
+		}
+	}
+
+	// sessions older than the maximum session age are disconnected
+	d := &desktopAPI{
+		client:     fake.NewFakeClientWithScheme(scheme, newSession(), newPod("", "")),
+		vdiCluster: &appv1.VDICluster{Spec: appv1.VDIClusterSpec{Auth: &appv1.AuthConfig{MaxSessionAge: "1h"}}},
+	}
+	oldClaims := &types.JWTClaims{SessionStart: time.Now().Add(-2 * time.Hour).Unix()}
+	if reason := d.disconnectReason(context.TODO(), oldClaims, nn); reason != types.DisconnectAuthExpired {
+		t.Errorf("Expected reason %q for an aged out session, got: %q", types.DisconnectAuthExpired, reason)
+	}
+}
+
+func TestWatchConnection(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(interval time.Duration) { disconnectCheckInterval = interval }(disconnectCheckInterval)
+	disconnectCheckInterval = time.Millisecond * 10
+
+	nn := ktypes.NamespacedName{Name: "desktop", Namespace: "default"}
+	session := &desktopsv1.Session{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme, session, pod), vdiCluster: &appv1.VDICluster{}}
+
+	// an expired access token closes the connection
+	claims := &types.JWTClaims{}
+	claims.IssuedAt = time.Now().Add(-time.Hour).Unix()
+	claims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if reason := d.watchConnection(ctx, claims, nn, newActivityReader(strings.NewReader("")), 0); reason != types.DisconnectAuthExpired {
+		t.Errorf("Expected reason %q for an expired token, got: %q", types.DisconnectAuthExpired, reason)
+	}
+
+	// a valid token keeps the connection open until the context is done
+	claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if reason := d.watchConnection(ctx, claims, nn, newActivityReader(strings.NewReader("")), 0); reason != "" {
+		t.Errorf("Expected no reason for a valid token, got: %q", reason)
+	}
+
+	// removing the session while connected closes the connection
+	if err := d.client.Delete(context.TODO(), session); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if reason := d.watchConnection(ctx, claims, nn, newActivityReader(strings.NewReader("")), 0); reason != types.DisconnectAdminTerminated {
+		t.Errorf("Expected reason %q for a deleted session, got: %q", types.DisconnectAdminTerminated, reason)
+	}
+}
+
+func TestStripSessionPortCredentials(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions/default/test/port/3000/app?token=secret&page=2", nil)
+	req.Header.Set(TokenHeader, "secret")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.AddCookie(&http.Cookie{Name: RefreshTokenCookie, Value: "secret"})
+	req.AddCookie(&http.Cookie{Name: "other", Value: "value"})
+	req.Header.Set("Accept", "text/html")
+
+	stripSessionPortCredentials(req)
+
+	for _, header := range []string{TokenHeader, "Authorization", "Cookie"} {
+		if val := req.Header.Get(header); val != "" {
+			t.Errorf("Expected %s header to be stripped, got: %s", header, val)
+		}
+	}
+	if _, err := req.Cookie(RefreshTokenCookie); err == nil {
+		t.Error("Expected the refresh token cookie to be stripped")
+	}
+	if q := req.URL.Query(); q.Get("token") != "" || q.Get("page") != "2" {
+		t.Error("Expected only the token to be stripped from the query, got:", req.URL.RawQuery)
+	}
+	if req.Header.Get("Accept") != "text/html" {
+		t.Error("Expected other headers to be preserved")
+	}
+}
+
+// TestRenewToken tests that renewing a token reloads the user from the auth provider.
+func TestRenewToken(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	admin, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	if err := admin.CreateVDIUser(&types.CreateUserRequest{
+		Username: "renew-user",
+		Password: "renew-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cl, err := client.New(&client.Opts{URL: opts.URL, Username: "renew-user", Password: "renew-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := admin.UpdateVDIUser("renew-user", &types.UpdateUserRequest{
+		Roles: []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.RenewToken(); err != nil {
+		t.Fatal(err)
+	}
+	session, err := cl.WhoAmI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Roles) != 1 || session.Roles[0].GetName() != "test-cluster-admin" {
+		t.Error("Expected the renewed token to carry the updated roles, got:", session.Roles)
+	}
+}
+
+func TestRenewalDenied(t *testing.T) {
+	now := time.Now()
+	newClaims := func(authorized, renewable bool, issuedAt time.Time) *types.JWTClaims {
+		claims := &types.JWTClaims{Authorized: authorized, Renewable: renewable}
+		claims.IssuedAt = issuedAt.Unix()
+		return claims
+	}
+	tcs := []struct {
+		name        string
+		claims      *types.JWTClaims
+		idleTimeout time.Duration
+		denied      bool
+	}{
+		{"renewable", newClaims(true, true, now.Add(-time.Hour)), 0, false},
+		{"not authorized", newClaims(false, true, now), 0, true},
+		{"not renewable", newClaims(true, false, now), 0, true},
+		{"active", newClaims(true, true, now.Add(-time.Minute)), 10 * time.Minute, false},
+		{"idle", newClaims(true, true, now.Add(-time.Hour)), 10 * time.Minute, true},
+	}
+	for _, tc := range tcs {
+		if denied := renewalDenied(tc.claims, tc.idleTimeout, now) != ""; denied != tc.denied {
+			t.Errorf("%s: expected denied to be %v, got %v", tc.name, tc.denied, denied)
+		}
+	}
+}
+
+func TestAccessRequestApproval(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	admin, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "restricted-desktop"
+	tmpl.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/ubuntu-xfce4:latest"}
+	if err := admin.CreateDesktopTemplate(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.CreateVDIRole(&types.CreateRoleRequest{
+		Name: "template-viewers",
+		Rules: []rbacv1.Rule{
+			{
+				Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+				ResourcePatterns: []string{".*"},
+				Namespaces:       []string{rbacv1.NamespaceAll},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.CreateVDIUser(&types.CreateUserRequest{
+		Username: "request-user",
+		Password: "request-password",
+		Roles:    []string{"template-viewers"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cl, err := client.New(&client.Opts{URL: opts.URL, Username: "request-user", Password: "request-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	req, err := cl.CreateAccessRequest(&types.CreateAccessRequest{Template: "restricted-desktop", Reason: "testing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.ApproveAccessRequest("missing", nil); !errors.IsAPINotFound(err) {
+		t.Error("Expected not found approving a missing request, got:", err)
+	}
+	approved, err := admin.ApproveAccessRequest(req.ID, &types.ReviewAccessRequest{Comment: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.State != types.AccessRequestApproved || approved.ReviewedBy != "admin" {
+		t.Error("Expected the request to be approved by admin, got:", approved)
+	}
+	if _, err := admin.DenyAccessRequest(req.ID, nil); err == nil {
+		t.Error("Expected error reviewing a request twice, got nil")
+	}
+
+	// the user's own roles are left untouched and the grant is bound on the role
+	user, err := admin.GetVDIUser("request-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 1 {
+		t.Error("Expected the user's stored roles to be unchanged, got:", user.Roles)
+	}
+	role, err := admin.GetVDIRole("test-cluster-access-request-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !roleBoundToUser(role, "request-user") {
+		t.Error("Expected the access role to be bound to request-user, got:", role.GetAnnotations())
+	}
+
+	if err := cl.RenewToken(); err != nil {
+		t.Fatal(err)
+	}
+	whoami, err := cl.WhoAmI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !userHasRole(whoami, role.GetName()) {
+		t.Error("Expected the renewed token to carry the access role, got:", whoami.Roles)
+	}
+}
+
+func TestRoleBoundToUser(t *testing.T) {
+	role := &rbacv1.VDIRole{}
+	if roleBoundToUser(role, "alice") {
+		t.Error("Expected a role without bindings to not be bound")
+	}
+	role.SetAnnotations(map[string]string{v1.BoundUsersAnnotation: "alice;bob"})
+	if !roleBoundToUser(role, "alice") || !roleBoundToUser(role, "bob") {
+		t.Error("Expected role to be bound to alice and bob")
+	}
+	if roleBoundToUser(role, "ali") {
+		t.Error("Expected partial names to not match")
+	}
+}
+
+func TestReserveTemplateCapacity(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme), vdiCluster: cluster}
+
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "reserved-desktop"
+	tmpl.Spec.Capacity = &desktopsv1.TemplateCapacity{
+		MaxSessions:  2,
+		Reservations: []desktopsv1.CapacityReservation{{Name: "staff", Roles: []string{"staff"}}},
+	}
+	staff := &types.VDIUser{Name: "alice", Roles: []*types.VDIUserRole{{Name: "staff"}}}
+	other := &types.VDIUser{Name: "bob"}
+
+	var launched int
+	launch := func(user *types.VDIUser) string {
+		t.Helper()
+		res, err := d.reserveTemplateCapacity(tmpl, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desktop := &desktopsv1.Session{}
+		launched++
+		desktop.Name = "session-" + strconv.Itoa(launched)
+		desktop.Namespace = "default"
+		desktop.Labels = map[string]string{v1.VDIClusterLabel: cluster.GetName()}
+		desktop.Spec.Template = tmpl.GetName()
+		if res != "" {
+			desktop.Annotations = map[string]string{v1.ReservationAnnotation: res}
+		}
+		if err := d.client.Create(context.TODO(), desktop); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// the unreserved slot is available to everyone
+	if res := launch(other); res != "" {
+		t.Error("Expected the first session to not use the reservation, got:", res)
+	}
+	// the reserved slot is held for staff
+	if _, err := d.reserveTemplateCapacity(tmpl, other); err == nil {
+		t.Error("Expected the reserved slot to be unavailable to other users")
+	}
+	if res := launch(staff); res != "staff" {
+		t.Error("Expected staff to launch into the reservation, got:", res)
+	}
+	// a matching reservation does not exceed the template limit
+	tmpl.Spec.Capacity.Reservations[0].Slots = 3
+	if _, err := d.reserveTemplateCapacity(tmpl, staff); err == nil {
+		t.Error("Expected the reservation to not exceed the maximum sessions")
+	}
+}
+
+func TestCrossTenantSessions(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme), vdiCluster: cluster}
+
+	for name, vdiCluster := range map[string]string{"own-session": "test-cluster", "other-session": "other-cluster"} {
+		desktop := &desktopsv1.Session{}
+		desktop.Name = name
+		desktop.Namespace = "default"
+		desktop.Spec.VDICluster = vdiCluster
+		if err := d.client.Create(context.TODO(), desktop); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := func(name string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/sessions/default/"+name, nil)
+		return mux.SetURLVars(r, map[string]string{"namespace": "default", "name": name})
+	}
+
+	rec := httptest.NewRecorder()
+	d.GetDesktopSessionStatus(rec, request("own-session"))
+	if rec.Code != http.StatusOK {
+		t.Error("Expected the status of a session in this cluster to be returned, got:", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	d.GetDesktopSessionStatus(rec, request("other-session"))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected the status of another cluster's session to not be found, got:", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	d.DeleteDesktopSession(rec, request("other-session"))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected deleting another cluster's session to not be found, got:", rec.Code)
+	}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: "other-session", Namespace: "default"}, &desktopsv1.Session{}); err != nil {
+		t.Error("Expected another cluster's session to not be deleted, got:", err)
+	}
+	if _, err := d.getProxyClientForRequest(request("other-session")); !apierrors.IsNotFound(err) {
+		t.Error("Expected no proxy to another cluster's session, got:", err)
+	}
+}
+
+func TestClaimPooledSession(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme), vdiCluster: cluster}
+
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "ubuntu-desktop"
+	pool := &desktopsv1.DesktopPool{}
+	pool.Name = "ubuntu-pool"
+	pool.Namespace = "default"
+	pool.Spec.VDICluster = cluster.GetName()
+	pool.Spec.Template = tmpl.GetName()
+	if err := d.client.Create(context.TODO(), pool); err != nil {
+		t.Fatal(err)
+	}
+	desktop := &desktopsv1.Session{}
+	desktop.Name = "ubuntu-pool-abcde"
+	desktop.Namespace = "default"
+	desktop.Labels = pool.GetSessionsSelector()
+	desktop.Annotations = map[string]string{"example.com/note": "keep"}
+	desktop.Spec.VDICluster = cluster.GetName()
+	desktop.Spec.Template = tmpl.GetName()
+	desktop.Spec.Pool = pool.GetName()
+	desktop.Status.Running = true
+	if err := d.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	req := &types.CreateSessionRequest{Template: tmpl.GetName()}
+
+	// pooled desktops can't mount the user data of the user claiming them
+	cluster.Spec.UserdataSpec = &corev1.PersistentVolumeClaimSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}}
+	if claimed, err := d.claimPooledSession("alice", tmpl, req, "", 0, ""); err != nil {
+		t.Fatal(err)
+	} else if claimed != nil {
+		t.Error("Expected no pooled desktop to be claimed while user data is enabled, got:", claimed.GetName())
+	}
+	cluster.Spec.UserdataSpec = nil
+
+	claimed, err := d.claimPooledSession("alice", tmpl, req, "", 0, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil {
+		t.Fatal("Expected the pooled desktop to be claimed")
+	}
+	found := &desktopsv1.Session{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.GetUser() != "alice" || found.IsPooled() {
+		t.Error("Expected the desktop to be claimed by alice, got user:", found.GetUser())
+	}
+	if found.GetAnnotations()[v1.ReservationAnnotation] != "staff" {
+		t.Error("Expected the reservation to be recorded on the claimed desktop, got:", found.GetAnnotations())
+	}
+	if found.GetAnnotations()["example.com/note"] != "keep" {
+		t.Error("Expected existing annotations to be kept on the claimed desktop, got:", found.GetAnnotations())
+	}
+	if len(found.GetOwnerReferences()) != 0 {
+		t.Error("Expected the claimed desktop to leave its pool")
+	}
+
+	// the desktop can only be claimed once
+	if claimed, err := d.claimPooledSession("bob", tmpl, req, "", 0, ""); err != nil {
+		t.Fatal(err)
+	} else if claimed != nil {
+		t.Error("Expected no pooled desktop to be left to claim, got:", claimed.GetName())
+	}
+}
+
+func TestDomainHosts(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	hosts, err := cl.GetDomainHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 0 {
+		t.Error("Expected no domain hosts, got:", hosts)
+	}
+	if err := cl.SetDomainHostKeytab("vdi-01", []byte("keytab")); err == nil {
+		t.Error("Expected error uploading a keytab without domain join configured")
+	}
+	if err := cl.DeleteDomainHost("vdi-01"); err == nil {
+		t.Error("Expected error deleting a host without a keytab")
+	}
+}
+
+func TestBulkSessionGrants(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "lab-desktop"
+	tmpl.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/ubuntu-xfce4:latest"}
+	if err := cl.CreateDesktopTemplate(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name: "lab-launchers",
+		Rules: []rbacv1.Rule{
+			{
+				Verbs:            []rbacv1.Verb{rbacv1.VerbLaunch},
+				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+				ResourcePatterns: []string{"^lab-.*"},
+				Namespaces:       []string{"default"},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIRole(&types.CreateRoleRequest{
+		Name: "template-readers",
+		Rules: []rbacv1.Rule{
+			{
+				Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+				Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+				ResourcePatterns: []string{".*"},
+				Namespaces:       []string{rbacv1.NamespaceAll},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for user, role := range map[string]string{"student": "lab-launchers", "visitor": "template-readers"} {
+		if err := cl.CreateVDIUser(&types.CreateUserRequest{
+			Username: user,
+			Password: "test-password",
+			Roles:    []string{role},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the admin can launch anything, but not on behalf of a user without the grant
+	if _, err := cl.CreateDesktopSessions(&types.BulkCreateSessionRequest{
+		Template: "lab-desktop",
+		Users:    []string{"student", "visitor"},
+	}); err == nil {
+		t.Error("Expected launching for a user without the launch grant to be forbidden")
+	} else if !strings.Contains(err.Error(), "visitor") {
+		t.Error("Expected the error to name the user without the grant, got:", err)
+	}
+
+	// the grant of the target user is scoped to its namespaces
+	if _, err := cl.CreateDesktopSessions(&types.BulkCreateSessionRequest{
+		Template:   "lab-desktop",
+		Namespaces: []string{"default", "other"},
+		Users:      []string{"student"},
+	}); err == nil {
+		t.Error("Expected launching outside the namespaces of the target user to be forbidden")
+	}
+
+	resp, err := cl.CreateDesktopSessions(&types.BulkCreateSessionRequest{
+		Template: "lab-desktop",
+		Users:    []string{"student"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 1 || resp.Failed != 0 {
+		t.Error("Expected a single session to be launched for student, got:", resp.Results)
+	}
+}
+
+func TestSessionThumbnailPermissions(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	admin, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	for role, verb := range map[string]rbacv1.Verb{"thumbnail-viewers": rbacv1.VerbView, "template-users": rbacv1.VerbUse} {
+		if err := admin.CreateVDIRole(&types.CreateRoleRequest{
+			Name: role,
+			Rules: []rbacv1.Rule{
+				{
+					Verbs:            []rbacv1.Verb{verb},
+					Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+					ResourcePatterns: []string{"^ubuntu-.*"},
+					Namespaces:       []string{rbacv1.NamespaceAll},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users := map[string]string{"viewer": "thumbnail-viewers", "user": "template-users"}
+	clients := make(map[string]*client.Client)
+	for user, role := range users {
+		if err := admin.CreateVDIUser(&types.CreateUserRequest{
+			Username: user,
+			Password: "test-password",
+			Roles:    []string{role},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		cl, err := client.New(&client.Opts{URL: opts.URL, Username: user, Password: "test-password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+		clients[user] = cl
+	}
+
+	sessions := make(map[string]client.NamespacedName)
+	for _, name := range []string{"ubuntu-desktop", "centos-desktop"} {
+		tmpl := &desktopsv1.Template{}
+		tmpl.Name = name
+		tmpl.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/" + name + ":latest"}
+		if err := admin.CreateDesktopTemplate(tmpl); err != nil {
+			t.Fatal(err)
+		}
+		sess, err := admin.CreateDesktopSession(&types.CreateSessionRequest{Template: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions[name] = client.NamespacedName{Namespace: sess.Namespace, Name: sess.Name}
+	}
+
+	// the view grant passes the permission check, and the request fails at the proxy
+	if _, err := clients["viewer"].GetDesktopSessionThumbnail(sessions["ubuntu-desktop"]); err == nil || errors.IsAPIForbidden(err) {
+		t.Error("Expected the viewer to be allowed through to the proxy, got:", err)
+
+	}
+	// the view grant is limited to its patterns
+	if _, err := clients["viewer"].GetDesktopSessionThumbnail(sessions["centos-desktop"]); !errors.IsAPIForbidden(err) {
+		t.Error("Expected the viewer to be forbidden outside of its patterns, got:", err)
+	}
+	// using a template does not grant viewing the sessions of others
+	if _, err := clients["user"].GetDesktopSessionThumbnail(sessions["ubuntu-desktop"]); !errors.IsAPIForbidden(err) {
+		t.Error("Expected a user without the view grant to be forbidden, got:", err)
+	}
+}
+
+func TestTemplateDeprecation(t *testing.T) {
+	cl, closeFunc := mustNewClientWithClose(t)
+	defer closeFunc()
+
+	deprecated := &desktopsv1.Template{}
+	deprecated.Name = "aaa-old-desktop"
+	deprecated.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/old:latest"}
+	deprecated.Spec.Deprecation = &desktopsv1.DeprecationConfig{Replacement: "zzz-new-desktop"}
+	current := &desktopsv1.Template{}
+	current.Name = "zzz-new-desktop"
+	current.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/new:latest"}
+	sunset := metav1.NewTime(time.Now().Add(-time.Hour))
+	retired := &desktopsv1.Template{}
+	retired.Name = "retired-desktop"
+	retired.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "kvdi/retired:latest"}
+	retired.Spec.Deprecation = &desktopsv1.DeprecationConfig{Sunset: &sunset}
+	for _, tmpl := range []*desktopsv1.Template{deprecated, current, retired} {
+		if err := cl.CreateDesktopTemplate(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// deprecated templates are listed after the rest of the catalog
+	tmpls, err := cl.GetDesktopTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seenDeprecated bool
+	for _, tmpl := range tmpls {
+		if tmpl.IsDeprecated() {
+			seenDeprecated = true
+		} else if seenDeprecated {
+			t.Error("Expected deprecated templates to be listed last, got:", tmpl.GetName())
+		}
+	}
+
+	// launching a deprecated template warns the user
+	resp, err := cl.CreateDesktopSession(&types.CreateSessionRequest{Template: deprecated.GetName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Warning, "use zzz-new-desktop instead") {
+		t.Error("Expected a deprecation warning, got:", resp.Warning)
+	}
+	if resp, err := cl.CreateDesktopSession(&types.CreateSessionRequest{Template: current.GetName()}); err != nil {
+		t.Fatal(err)
+	} else if resp.Warning != "" {
+		t.Error("Expected no warning for a current template, got:", resp.Warning)
+	}
+
+	// retired templates can no longer be launched
+	if _, err := cl.CreateDesktopSession(&types.CreateSessionRequest{Template: retired.GetName()}); err == nil {
+		t.Error("Expected launching a retired template to fail")
+	} else if !strings.Contains(err.Error(), "can no longer be launched") {
+		t.Error("Expected a retirement error, got:", err)
+	}
 }
@@ -0,0 +1,82 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/ticketing"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// validateLaunchTicket checks the given ticket against the cluster's ticketing system
+// before a session is launched from a template that requires one.
+func (d *desktopAPI) validateLaunchTicket(sess *types.JWTClaims, tmpl *desktopsv1.Template, ticket string) error {
+	if !d.vdiCluster.TicketingIsEnabled() {
+		return fmt.Errorf("Template %s requires a ticket but no ticketing system is configured", tmpl.GetName())
+	}
+	if ticket == "" {
+		return fmt.Errorf("Template %s requires a ticket or change ID to launch", tmpl.GetName())
+	}
+	validator, err := ticketing.New(d.vdiCluster, d.secrets)
+	if err != nil {
+		return err
+	}
+	resp, err := validator.Validate(&ticketing.Request{
+		Ticket:   ticket,
+		User:     sess.User.GetName(),
+		Template: tmpl.GetName(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(ticket, time.Now()); err != nil {
+		d.auditTicket(sess, tmpl, ticket, "", err)
+		return err
+	}
+	return nil
+}
+
+// auditTicket records a session launch against a ticket in the audit log. If err is not
+// nil, the launch was denied.
+func (d *desktopAPI) auditTicket(sess *types.JWTClaims, tmpl *desktopsv1.Template, ticket, session string, err error) {
+	if !d.vdiCluster.AuditLogEnabled() {
+		return
+	}
+	allowed := err == nil
+	ev := &types.AuditEvent{
+		Timestamp: time.Now().UTC(),
+		User:      sess.User.GetName(),
+		Allowed:   allowed,
+		Details: map[string]string{
+			"Template": tmpl.GetName(),
+			"Ticket":   ticket,
+		},
+		Message: fmt.Sprintf("%s %s => launch %s => ticket %s", actions[allowed], sess.User.GetName(), tmpl.GetName(), ticket),
+	}
+	if allowed {
+		ev.Details["Session"] = session
+	} else {
+		ev.Details["Reason"] = err.Error()
+	}
+	d.audit.Record(ev)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// trashPurgeInterval is how often deleted users and roles past their retention are purged.
+// Every app instance purges, which is harmless since the trash is updated under a lock.
+var trashPurgeInterval = 10 * time.Minute
+
+// newDeletedObject returns a record of the given object being deleted by the user making
+// the request, expiring after the retention configured on the VDICluster.
+func (d *desktopAPI) newDeletedObject(r *http.Request, kind rbacv1.Resource, name string) *types.DeletedObject {
+	now := time.Now()
+	obj := &types.DeletedObject{
+		Kind:      kind,
+		Name:      name,
+		DeletedAt: now,
+		ExpiresAt: now.Add(d.vdiCluster.GetDeletionRetention()),
+	}
+	if sess := apiutil.GetRequestUserSession(r); sess != nil && sess.User != nil {
+		obj.DeletedBy = sess.User.GetName()
+	}
+	return obj
+}
+
+// trashUser deletes the given user, keeping them in the trash when a retention is
+// configured and the auth provider can restore users. It returns whether the user was
+// kept, if not they are deleted permanently.
+func (d *desktopAPI) trashUser(r *http.Request, username string) (kept bool, err error) {
+	restorable, ok := d.auth.(common.RestorableProvider)
+	if !ok || d.vdiCluster.GetDeletionRetention() == 0 {
+		return false, d.auth.DeleteUser(username)
+	}
+	user, err := d.auth.GetUser(username)
+	if err != nil {
+		return false, err
+	}
+	data, err := restorable.ExportUser(username)
+	if err != nil {
+		return false, err
+	}
+	obj := d.newDeletedObject(r, rbacv1.ResourceUsers, username)
+	for _, role := range user.Roles {
+		obj.Roles = append(obj.Roles, role.GetName())
+	}
+	// keep the user first, so they are never lost if the trash can't be written to
+	if err := d.trash.Add(obj, data); err != nil {
+		return false, err
+	}
+	if err := d.auth.DeleteUser(username); err != nil {
+		if _, terr := d.trash.Take(rbacv1.ResourceUsers, username); terr != nil {
+			apiLogger.Error(terr, "Failed to remove user from the trash after failing to delete them", "User", username)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// trashRole deletes the given role, keeping it in the trash when a retention is
+// configured.
+func (d *desktopAPI) trashRole(r *http.Request, role *rbacv1.VDIRole) error {
+	if d.vdiCluster.GetDeletionRetention() == 0 {
+		return d.client.Delete(context.TODO(), role)
+	}
+	kept := role.DeepCopy()
+	kept.ObjectMeta = metav1.ObjectMeta{
+		Name:        role.GetName(),
+		Labels:      role.GetLabels(),
+		Annotations: role.GetAnnotations(),
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	if err := d.trash.Add(d.newDeletedObject(r, rbacv1.ResourceRoles, role.GetName()), data); err != nil {
+		return err
+	}
+	if err := d.client.Delete(context.TODO(), role); err != nil {
+		if _, terr := d.trash.Take(rbacv1.ResourceRoles, role.GetName()); terr != nil {
+			apiLogger.Error(terr, "Failed to remove role from the trash after failing to delete it", "Role", role.GetName())
+		}
+		return err
+	}
+	return nil
+}
+
+// decodeTrashedRole decodes a role kept in the trash.
+func decodeTrashedRole(data []byte) (*rbacv1.VDIRole, error) {
+	role := &rbacv1.VDIRole{}
+	return role, json.Unmarshal(data, role)
+}
+
+// removeUserData removes the data kept for a user that was permanently deleted.
+func (d *desktopAPI) removeUserData(username string) {
+	if err := d.metadata.DeleteUser(username); err != nil {
+		apiLogger.Error(err, "Failed to remove metadata for deleted user", "User", username)
+	}
+	if err := d.userDevices.DeleteUser(username); err != nil {
+		apiLogger.Error(err, "Failed to remove devices for deleted user", "User", username)
+	}
+	if d.vdiCluster.GetUserdataVolumeSpec() != nil && d.vdiCluster.GetUserdataReclaimPolicy() == corev1.PersistentVolumeReclaimDelete {
+		if err := d.deleteUserVolume(username); err != nil {
+			apiLogger.Error(err, "Failed to remove $HOME volume for deleted user", "User", username)
+		}
+	}
+}
+
+// getTrashedRules returns the rules that restoring the user or role targeted by the request
+// would grant. Nil is returned if the object is not in the trash.
+func (d *desktopAPI) getTrashedRules(r *http.Request) ([]rbacv1.Rule, error) {
+	kind, name := rbacv1.ResourceUsers, apiutil.GetUserFromRequest(r)
+	if name == "" {
+		kind, name = rbacv1.ResourceRoles, apiutil.GetRoleFromRequest(r)
+	}
+	entry, err := d.trash.Get(kind, name)
+	if err != nil {
+		if errors.IsDeletedObjectNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if kind == rbacv1.ResourceRoles {
+		role, err := decodeTrashedRole(entry.Data)
+		if err != nil {
+			return nil, err
+		}
+		return d.getRequestedRoleRules(role.GetName(), role.GetRules(), role.GetInheritsFrom())
+	}
+	vdiRoles, err := d.getRoles()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]rbacv1.Rule, 0)
+	for _, name := range entry.Roles {
+		if roleObj := getRoleByName(vdiRoles, name); roleObj != nil {
+			rules = append(rules, rbac.EffectiveRules(roleObj, vdiRoles)...)
+		}
+	}
+	return rules, nil
+}
+
+// purgeDeletedObjects periodically removes users and roles past their retention from the
+// trash, along with any data kept for the users. It runs for the life of the process.
+func (d *desktopAPI) purgeDeletedObjects() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if d.trash == nil {
+			continue
+		}
+		expired, err := d.trash.Purge()
+		if err != nil {
+			apiLogger.Error(err, "Failed to purge expired users and roles")
+			continue
+		}
+		for _, entry := range expired {
+			apiLogger.Info("Purged deleted object past its retention", "Kind", entry.Kind, "Name", entry.Name)
+			if entry.Kind != rbacv1.ResourceUsers {
+				continue
+			}
+			// a new user may have been created with the same name since
+			if _, err := d.auth.GetUser(entry.Name); errors.IsUserNotFoundError(err) {
+				d.removeUserData(entry.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto/tunnel"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// tunnelLeaseDuration is how long a replica is considered to hold tunnel connections for a
+// session after it last registered them.
+const tunnelLeaseDuration = 3 * tunnel.HeartbeatInterval
+
+// tunnelRegistry records the replica holding the reverse tunnels of each session in a
+// lease, so the other replicas of the app can relay through it.
+type tunnelRegistry struct {
+	api *desktopAPI
+}
+
+// getTunnelLeaseName returns the name of the lease recording the replica holding the
+// tunnels of the given session.
+func (t *tunnelRegistry) getTunnelLeaseName(session ktypes.NamespacedName) ktypes.NamespacedName {
+	return ktypes.NamespacedName{
+		Name:      lock.LeaseName(fmt.Sprintf("%s-tunnel", t.api.vdiCluster.GetAppName()), session.String()),
+		Namespace: t.api.vdiCluster.GetCoreNamespace(),
+	}
+}
+
+// Register implements tunnel.Registry. The last replica to register a session holds it,
+// any replica with connections parked for the session can serve it.
+func (t *tunnelRegistry) Register(session ktypes.NamespacedName) error {
+	podName, err := k8sutil.GetThisPodName()
+	if err != nil {
+		return err
+	}
+	nn := t.getTunnelLeaseName(session)
+	now := metav1.NowMicro()
+	lease := &coordinationv1.Lease{}
+	if err := t.api.client.Get(context.TODO(), nn, lease); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nn.Name,
+				Namespace: nn.Namespace,
+				Labels:    t.api.vdiCluster.GetComponentLabels("tunnel"),
+			},
+		}
+		lease.Spec.HolderIdentity = &podName
+		lease.Spec.LeaseDurationSeconds = common.Int32Ptr(int32(tunnelLeaseDuration.Seconds()))
+		lease.Spec.RenewTime = &now
+		return t.api.client.Create(context.TODO(), lease)
+	}
+	lease.Spec.HolderIdentity = &podName
+	lease.Spec.LeaseDurationSeconds = common.Int32Ptr(int32(tunnelLeaseDuration.Seconds()))
+	lease.Spec.RenewTime = &now
+	return t.api.client.Update(context.TODO(), lease)
+}
+
+// Lookup implements tunnel.Registry. It returns the relay address of the pod holding the
+// tunnels of the session, if it is not this one and its registration has not expired.
+func (t *tunnelRegistry) Lookup(session ktypes.NamespacedName) (string, error) {
+	lease := &coordinationv1.Lease{}
+	if err := t.api.client.Get(context.TODO(), t.getTunnelLeaseName(session), lease); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", tunnel.ErrNoTunnel
+		}
+		return "", err
+	}
+	if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil {
+		return "", tunnel.ErrNoTunnel
+	}
+	if time.Since(lease.Spec.RenewTime.Time) > tunnelLeaseDuration {
+		return "", tunnel.ErrNoTunnel
+	}
+	if podName, _ := k8sutil.GetThisPodName(); podName == *lease.Spec.HolderIdentity {
+		return "", tunnel.ErrNoTunnel
+	}
+	pod := &corev1.Pod{}
+	if err := t.api.client.Get(context.TODO(), ktypes.NamespacedName{Name: *lease.Spec.HolderIdentity, Namespace: lease.GetNamespace()}, pod); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", tunnel.ErrNoTunnel
+		}
+		return "", err
+	}
+	if pod.Status.PodIP == "" {
+		return "", tunnel.ErrNoTunnel
+	}
+	return fmt.Sprintf("%s:%d", pod.Status.PodIP, v1.TunnelRelayPort), nil
+}
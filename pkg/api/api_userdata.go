@@ -0,0 +1,114 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errNoUserdataSpec is returned for requests about user $HOME volumes when the cluster
+// does not provision them.
+var errNoUserdataSpec = errors.New("The cluster is not configured with a userdataSpec")
+
+// getUserVolume returns the $HOME volume provisioned for the given user along with the
+// claim mounting it in one of their desktops. Nil is returned for the volume if the user
+// has not been provisioned one yet, and for the claim if none of their desktops are running.
+func (d *desktopAPI) getUserVolume(username string) (*corev1.PersistentVolume, *corev1.PersistentVolumeClaim, error) {
+	volMapCM := &corev1.ConfigMap{}
+	if err := d.client.Get(context.TODO(), d.vdiCluster.GetUserdataVolumeMapName(), volMapCM); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+	pvName, ok := volMapCM.Data[username]
+	if !ok {
+		return nil, nil, nil
+	}
+	pv := &corev1.PersistentVolume{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: pvName}, pv); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := d.client.List(context.TODO(), pvcs, client.MatchingLabels{
+		v1.VDIClusterLabel: d.vdiCluster.GetName(),
+		v1.UserLabel:       username,
+	}); err != nil {
+		return nil, nil, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.GetName() == d.vdiCluster.GetUserdataVolumeName(username) && pvc.Spec.VolumeName == pvName && pvc.GetDeletionTimestamp() == nil {
+			return pv, pvc, nil
+		}
+	}
+	return pv, nil, nil
+}
+
+// deleteUserVolume removes the $HOME volume provisioned for the given user, if any. The
+// volume is handed to its storage provisioner to delete once it is no longer mounted.
+func (d *desktopAPI) deleteUserVolume(username string) error {
+	pv, _, err := d.getUserVolume(username)
+	if err != nil || pv == nil {
+		return err
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+		if err := d.client.Update(context.TODO(), pv); err != nil {
+			return err
+		}
+	}
+	if err := d.client.Delete(context.TODO(), pv); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	volMapCM := &corev1.ConfigMap{}
+	if err := d.client.Get(context.TODO(), d.vdiCluster.GetUserdataVolumeMapName(), volMapCM); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	delete(volMapCM.Data, username)
+	return d.client.Update(context.TODO(), volMapCM)
+}
+
+// newUserVolume converts a user's $HOME volume and the claim mounting it to their API
+// representation.
+func (d *desktopAPI) newUserVolume(pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) *types.UserVolume {
+	vol := &types.UserVolume{
+		Name:          pv.GetName(),
+		StorageClass:  pv.Spec.StorageClassName,
+		Phase:         string(pv.Status.Phase),
+		ReclaimPolicy: string(d.vdiCluster.GetUserdataReclaimPolicy()),
+	}
+	if capacity, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+		vol.Capacity = capacity.String()
+	}
+	if pvc != nil {
+		vol.ClaimName = pvc.GetName()
+		vol.ClaimNamespace = pvc.GetNamespace()
+		if requested, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			vol.Requested = requested.String()
+		}
+	}
+	return vol
+}
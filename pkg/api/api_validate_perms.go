@@ -76,11 +76,145 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowAll,
 		},
 	},
+	"/api/logout/all": {
+		"POST": {
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/renew_token": {
+		"POST": {
+			OverrideFunc: allowAll,
+		},
+	},
 	"/api/config": {
 		"GET": {
 			OverrideFunc: allowAll,
 		},
 	},
+	"/api/config/validate": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/backup": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbAll,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/restore": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbAll,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/faults": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbAll,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbAll,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/config/staged": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/config/staged/promote": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/config/rollback": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
 	"/api/config/reload": {
 		"POST": {
 			OverrideFunc: allowAll,
@@ -96,6 +230,18 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowAll,
 		},
 	},
+	"/api/directory/search": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+				},
+			},
+		},
+	},
 	"/api/users": {
 		"GET": {
 			Actions: []ActionTemplate{
@@ -142,178 +288,951 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 					ResourceNameFunc: apiutil.GetUserFromRequest,
 				},
 			},
-			OverrideFunc:   allowSameUser,
-			ExtraCheckFunc: denyUserElevatePerms,
+			OverrideFunc:   allowSameUser,
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbDelete,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+		},
+	},
+	"/api/users/{user}/revoke": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/access_override": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+		},
+	},
+	"/api/users/{user}/mfa": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/domain/hosts": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/domain/hosts/{host}": {
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceAll,
+					},
+				},
+			},
+		},
+	},
+	"/api/users/{user}/homeshare": {
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/volume": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+		},
+	},
+	"/api/users/{user}/restore": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/users/{user}/devices": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/devices/{device}": {
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/metadata": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/metadata/{namespace}": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/metadata/{namespace}/{key}": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/users/{user}/mfa/verify": {
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: apiutil.GetUserFromRequest,
+				},
+			},
+			OverrideFunc: allowSameUser,
+		},
+	},
+	"/api/access_requests": {
+		"GET": {
+			OverrideFunc: allowAll,
+		},
+		"POST": {
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/access_requests/{request}/approve": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/access_requests/{request}/deny": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/roles": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/gateways": {
+		"GET": {
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/gateways/latency": {
+		"PUT": {
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/authz/check": {
+		"POST": {
+			OverrideFunc: allowAuthzSelfCheck,
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+					ResourceNameFunc: getAuthzCheckUserFromRequest,
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/authz/who-can": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/deleted": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/authz/matrix": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceUsers,
+					},
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+				},
+			},
+		},
+	},
+	"/api/audit": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceAuditLogs,
+					},
+				},
+			},
+		},
+	},
+	"/api/recordings": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRecordings,
+					},
+				},
+			},
+		},
+	},
+	"/api/recordings/{recording}": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRecordings,
+					},
+					ResourceNameFunc: apiutil.GetRecordingFromRequest,
+				},
+			},
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbDelete,
+						ResourceType: rbacv1.ResourceRecordings,
+					},
+					ResourceNameFunc: apiutil.GetRecordingFromRequest,
+				},
+			},
+		},
+	},
+	"/api/recordings/{recording}/playback": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRecordings,
+					},
+					ResourceNameFunc: apiutil.GetRecordingFromRequest,
+				},
+			},
+		},
+	},
+	"/api/roles/{role}/effective": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+					ResourceNameFunc: apiutil.GetRoleFromRequest,
+				},
+			},
+		},
+	},
+	"/api/roles/{role}/restore": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+					ResourceNameFunc: apiutil.GetRoleFromRequest,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/roles/{role}": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+					ResourceNameFunc: apiutil.GetRoleFromRequest,
+				},
+			},
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+					ResourceNameFunc: apiutil.GetRoleFromRequest,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+		"DELETE": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbDelete,
+						ResourceType: rbacv1.ResourceRoles,
+					},
+					ResourceNameFunc: apiutil.GetRoleFromRequest,
+				},
+			},
+		},
+	},
+	"/api/templates": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/templates/validate": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/templates/{template}/approve": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbReview,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+			},
+			ExtraCheckFunc: denySelfReview,
+		},
+	},
+	"/api/templates/{template}/reject": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbReview,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+			},
+			ExtraCheckFunc: denySelfReview,
+		},
+	},
+	"/api/capacity": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/autoscaling": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/licenses": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/marketplace": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+				},
+			},
+		},
+	},
+	"/api/marketplace/{index}/{template}": {
+		"POST": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbCreate,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+			},
+		},
+	},
+	"/api/templates/{template}": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbRead,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+			},
+		},
+		"PUT": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUpdate,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+				},
+			},
 		},
 		"DELETE": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
 						Verb:         rbacv1.VerbDelete,
-						ResourceType: rbacv1.ResourceUsers,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetUserFromRequest,
+					ResourceNameFunc: apiutil.GetTemplateFromRequest,
 				},
 			},
 		},
 	},
-	"/api/users/{user}/mfa": {
+	"/api/sessions": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
 						Verb:         rbacv1.VerbRead,
-						ResourceType: rbacv1.ResourceUsers,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetUserFromRequest,
 				},
-			},
-			OverrideFunc: allowSameUser,
-		},
-		"PUT": {
-			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbUpdate,
+						Verb:         rbacv1.VerbRead,
 						ResourceType: rbacv1.ResourceUsers,
 					},
-					ResourceNameFunc: apiutil.GetUserFromRequest,
 				},
 			},
-			OverrideFunc: allowSameUser,
 		},
-	},
-	"/api/users/{user}/mfa/verify": {
-		"PUT": {
+		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbUpdate,
-						ResourceType: rbacv1.ResourceUsers,
+						Verb:         rbacv1.VerbLaunch,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc: func(r *http.Request) string {
+						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
+						return req.GetTemplate()
+					},
+					ResourceNamespaceFunc: func(r *http.Request) string {
+						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
+						return req.GetNamespace()
+					},
+				},
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUse,
+						ResourceType: rbacv1.ResourceServiceAccounts,
+					},
+					ResourceNameFunc: func(r *http.Request) string {
+						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
+						return req.GetServiceAccount()
+					},
+					ResourceNamespaceFunc: func(r *http.Request) string {
+						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
+						return req.GetNamespace()
 					},
-					ResourceNameFunc: apiutil.GetUserFromRequest,
 				},
 			},
-			OverrideFunc: allowSameUser,
+			OverrideFunc: allowDraftTemplateLaunch,
 		},
 	},
-	"/api/roles": {
+	"/api/sessions/bulk": {
+		"POST": {
+			// grants are evaluated for each requested session by the handler
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/labs": {
+		"GET": {
+			// labs are filtered to the ones the user instructs by the handler
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/labs/{namespace}/{name}": {
+		"GET": labInstructorPermissions,
+	},
+	"/api/labs/{namespace}/{name}/broadcast": {
+		"POST": labInstructorPermissions,
+	},
+	"/api/labs/{namespace}/{name}/lock": {
+		"POST": labInstructorPermissions,
+	},
+	"/api/labs/{namespace}/{name}/unlock": {
+		"POST": labInstructorPermissions,
+	},
+	"/api/labs/{namespace}/{name}/thumbnails/{user}": {
+		"GET": labInstructorPermissions,
+	},
+	"/api/labs/{namespace}/{name}/collect/": {
+		"GET": labInstructorPermissions,
+	},
+	"/api/sessions/{namespace}/{name}": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
 						Verb:         rbacv1.VerbRead,
-						ResourceType: rbacv1.ResourceRoles,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
-		"POST": {
+		"DELETE": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbCreate,
-						ResourceType: rbacv1.ResourceRoles,
+						Verb:         rbacv1.VerbDelete,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
-			ExtraCheckFunc: denyUserElevatePerms,
+			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/roles/{role}": {
+	"/api/desktops/{namespace}/{name}/logs/{container}": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
-						ResourceType: rbacv1.ResourceRoles,
+						Verb:         rbacv1.VerbUse,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetRoleFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
-		"PUT": {
+	},
+	"/api/desktops/{namespace}/{name}/display": {
+		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbUpdate,
-						ResourceType: rbacv1.ResourceRoles,
+						Verb:         rbacv1.VerbUse,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetRoleFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
-			ExtraCheckFunc: denyUserElevatePerms,
+			OverrideFunc: allowSessionOwner,
 		},
-		"DELETE": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/logs/{container}": {
+		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbDelete,
-						ResourceType: rbacv1.ResourceRoles,
+						Verb:         rbacv1.VerbUse,
+						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetRoleFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/templates": {
+	"/api/desktops/ws/{namespace}/{name}/display": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
-		"POST": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/audio": {
+		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbCreate,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/templates/{template}": {
+	"/api/desktops/ws/{namespace}/{name}/clipboard": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
-		"PUT": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/ssh": {
+		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbUpdate,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
-		"DELETE": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/plugins/{plugin}": {
+		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbDelete,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: apiutil.GetTemplateFromRequest,
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/sessions": {
+	"/api/desktops/ws/{namespace}/{name}/status": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
@@ -321,54 +1240,49 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 						Verb:         rbacv1.VerbRead,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
-				},
-				{
-					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
-						ResourceType: rbacv1.ResourceUsers,
-					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
+	},
+	"/api/sessions/{namespace}/{name}/ssh": {
 		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbLaunch,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
-					ResourceNameFunc: func(r *http.Request) string {
-						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
-						return req.GetTemplate()
-					},
-					ResourceNamespaceFunc: func(r *http.Request) string {
-						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
-						return req.GetNamespace()
-					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
+			},
+			OverrideFunc: allowSessionOwner,
+		},
+	},
+	"/api/sessions/{namespace}/{name}/template": {
+		"POST": {
+			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
 						Verb:         rbacv1.VerbUse,
-						ResourceType: rbacv1.ResourceServiceAccounts,
-					},
-					ResourceNameFunc: func(r *http.Request) string {
-						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
-						return req.GetServiceAccount()
-					},
-					ResourceNamespaceFunc: func(r *http.Request) string {
-						req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
-						return req.GetNamespace()
+						ResourceType: rbacv1.ResourceTemplates,
 					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 				},
 			},
+			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/sessions/{namespace}/{name}": {
-		"GET": {
+	"/api/sessions/{namespace}/{name}/snapshot": {
+		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
 					ResourceNameFunc:      apiutil.GetNameFromRequest,
@@ -377,11 +1291,13 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			},
 			OverrideFunc: allowSessionOwner,
 		},
-		"DELETE": {
+	},
+	"/api/sessions/{namespace}/{name}/monitors": {
+		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbDelete,
+						Verb:         rbacv1.VerbUse,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
 					ResourceNameFunc:      apiutil.GetNameFromRequest,
@@ -391,8 +1307,8 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/desktops/{namespace}/{name}/logs/{container}": {
-		"GET": {
+	"/api/sessions/{namespace}/{name}/monitors/{monitor}": {
+		"DELETE": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
@@ -406,8 +1322,8 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/logs/{container}": {
-		"GET": {
+	"/api/sessions/{namespace}/{name}/kubeconfig": {
+		"POST": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
@@ -421,7 +1337,7 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/display": {
+	"/api/sessions/{namespace}/{name}/devices": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
@@ -436,12 +1352,12 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/audio": {
+	"/api/sessions/{namespace}/{name}/thumbnail": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbUse,
+						Verb:         rbacv1.VerbView,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
 					ResourceNameFunc:      apiutil.GetNameFromRequest,
@@ -451,12 +1367,12 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/status": {
+	"/api/sessions/{namespace}/{name}/connections": {
 		"GET": {
 			Actions: []ActionTemplate{
 				{
 					APIAction: types.APIAction{
-						Verb:         rbacv1.VerbRead,
+						Verb:         rbacv1.VerbView,
 						ResourceType: rbacv1.ResourceTemplates,
 					},
 					ResourceNameFunc:      apiutil.GetNameFromRequest,
@@ -466,6 +1382,24 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
+	"/api/sessions/{namespace}/{name}/port/{port}/": {
+		"GET":     sessionPortProxyPermissions,
+		"HEAD":    sessionPortProxyPermissions,
+		"POST":    sessionPortProxyPermissions,
+		"PUT":     sessionPortProxyPermissions,
+		"PATCH":   sessionPortProxyPermissions,
+		"DELETE":  sessionPortProxyPermissions,
+		"OPTIONS": sessionPortProxyPermissions,
+	},
+	"/api/sessions/{namespace}/{name}/ide/": {
+		"GET":     sessionPortProxyPermissions,
+		"HEAD":    sessionPortProxyPermissions,
+		"POST":    sessionPortProxyPermissions,
+		"PUT":     sessionPortProxyPermissions,
+		"PATCH":   sessionPortProxyPermissions,
+		"DELETE":  sessionPortProxyPermissions,
+		"OPTIONS": sessionPortProxyPermissions,
+	},
 	"/api/desktops/fs/{namespace}/{name}/stat/": {
 		"GET": {
 			Actions: []ActionTemplate{
@@ -511,6 +1445,51 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowSessionOwner,
 		},
 	},
+	"/api/desktops/fs/{namespace}/{name}/usage": {
+		"GET": {
+			Actions: []ActionTemplate{
+				{
+					APIAction: types.APIAction{
+						Verb:         rbacv1.VerbUse,
+						ResourceType: rbacv1.ResourceTemplates,
+					},
+					ResourceNameFunc:      apiutil.GetNameFromRequest,
+					ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+				},
+			},
+			OverrideFunc: allowSessionOwner,
+		},
+	},
+}
+
+// sessionPortProxyPermissions are the permissions required for every method proxied to
+// a port or the IDE server of a desktop session.
+var sessionPortProxyPermissions = MethodPermissions{
+	Actions: []ActionTemplate{
+		{
+			APIAction: types.APIAction{
+				Verb:         rbacv1.VerbUse,
+				ResourceType: rbacv1.ResourceTemplates,
+			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+		},
+	},
+	OverrideFunc: allowSessionOwner,
+}
+
+// labInstructorPermissions are the permissions required for viewing and controlling
+// a lab. Instructors of the lab are always allowed.
+var labInstructorPermissions = MethodPermissions{
+	Actions: []ActionTemplate{
+		{
+			APIAction: types.APIAction{
+				Verb:         rbacv1.VerbUpdate,
+				ResourceType: rbacv1.ResourceAll,
+			},
+		},
+	},
+	OverrideFunc: allowLabInstructor,
 }
 
 func (d *desktopAPI) ValidateUserGrants(next http.Handler) http.Handler {
@@ -558,6 +1537,12 @@ func (d *desktopAPI) ValidateUserGrants(next http.Handler) http.Handler {
 
 		for _, action := range methodGrant.Actions {
 			apiAction := buildActionFromTemplate(action, r)
+			if err := d.populateAction(apiAction); err != nil {
+				apiutil.ReturnAPIForbidden(err, "An error ocurred resolving the teams and namespace for the requested resource", w)
+				result.Allowed = false
+				d.auditLog(result)
+				return
+			}
 			result.Actions = append(result.Actions, apiAction)
 			if !rbac.EvaluateUser(userSession.User, apiAction) {
 				msg := fmt.Sprintf("%s does not have the ability to %s", userSession.User.Name, apiAction.String())
@@ -612,3 +1597,12 @@ func buildActionFromTemplate(tmpl ActionTemplate, r *http.Request) *types.APIAct
 
 	return tmplAction
 }
+
+// populateAction resolves the teams of the resource targeted by the given action and the
+// labels of its namespace, so that rules restricted to either can be evaluated.
+func (d *desktopAPI) populateAction(action *types.APIAction) error {
+	if err := d.populateResourceTeams(action); err != nil {
+		return err
+	}
+	return d.populateNamespaceLabels(action)
+}
@@ -24,8 +24,13 @@ import (
 	"net/http"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func allowSameUser(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
@@ -40,8 +45,8 @@ func allowSameUser(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allo
 
 func allowSessionOwner(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
 	nn := apiutil.GetNamespacedNameFromRequest(r)
-	found := &desktopsv1.Session{}
-	if err := d.client.Get(context.TODO(), nn, found); err != nil {
+	found, err := d.getClusterDesktop(nn)
+	if err != nil {
 		return false, false, err
 	}
 	userDesktopLabels := d.vdiCluster.GetUserDesktopSelector(reqUser.Name)
@@ -59,6 +64,81 @@ func allowSessionOwner(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (
 	return true, true, nil
 }
 
+func allowLabInstructor(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	found := &desktopsv1.Lab{}
+	if err := d.client.Get(context.TODO(), nn, found); err != nil {
+		return false, false, err
+	}
+	if found.IsInstructor(reqUser.Name) {
+		return true, true, nil
+	}
+	return false, false, nil
+}
+
+// allowAuthzSelfCheck lets users check what they are allowed to do themselves.
+func allowAuthzSelfCheck(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
+	user := getAuthzCheckUserFromRequest(r)
+	if user != "" && user != reqUser.Name {
+		return false, false, nil
+	}
+	return true, true, nil
+}
+
+// getAuthzCheckUserFromRequest returns the user an authorization check was requested for.
+func getAuthzCheckUserFromRequest(r *http.Request) string {
+	if req, ok := apiutil.GetRequestObject(r).(*types.AuthzCheckRequest); ok {
+		return req.User
+	}
+	return ""
+}
+
+// allowDraftTemplateLaunch lets the author of a draft template, and users allowed to review
+// it, launch it in any namespace they may launch templates in.
+func allowDraftTemplateLaunch(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
+	req, ok := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
+	if !ok || req == nil {
+		return false, false, nil
+	}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: req.GetTemplate()}, tmpl); err != nil {
+		return false, false, client.IgnoreNotFound(err)
+	}
+	if !tmpl.IsDraft() || !rbac.CanAccessDraft(reqUser, tmpl) {
+		return false, false, nil
+	}
+	if allowed, err = d.evaluateUserAction(reqUser, &types.APIAction{
+		Verb:              rbacv1.VerbLaunch,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceNamespace: req.GetNamespace(),
+	}); err != nil || !allowed {
+		return false, false, err
+	}
+	if sa := req.GetServiceAccount(); sa != "" {
+		allowed, err = d.evaluateUserAction(reqUser, &types.APIAction{
+			Verb:              rbacv1.VerbUse,
+			ResourceType:      rbacv1.ResourceServiceAccounts,
+			ResourceName:      sa,
+			ResourceNamespace: req.GetNamespace(),
+		})
+		return allowed, false, err
+	}
+	return true, false, nil
+}
+
+// denySelfReview keeps users from approving or rejecting templates they saved themselves.
+func denySelfReview(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: apiutil.GetTemplateFromRequest(r)}, tmpl); err != nil {
+		// let the handler report missing templates
+		return client.IgnoreNotFound(err) == nil, "", client.IgnoreNotFound(err)
+	}
+	if tmpl.GetReviewAuthor() == reqUser.GetName() {
+		return false, "Templates cannot be reviewed by their author", nil
+	}
+	return true, "", nil
+}
+
 func allowAll(d *desktopAPI, reqUser *types.VDIUser, r *http.Request) (allowed, owner bool, err error) {
 	return true, false, nil
 }
@@ -38,9 +38,25 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 		return true, "", nil
 	}
 
+	// Check that restoring a deleted user or role will not grant permissions the user does
+	// not have.
+	switch apiutil.GetGorillaPath(r) {
+	case "/api/users/{user}/restore", "/api/roles/{role}/restore":
+		rules, err := d.getTrashedRules(r)
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
+			if !rbac.UserIncludesRule(reqUser, rule, NewResourceGetter(d)) {
+				return false, elevateDenyReason, nil
+			}
+		}
+		return true, "", nil
+	}
+
 	// Check that a POST /users will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*types.CreateUserRequest); ok {
-		vdiRoles, err := d.vdiCluster.GetRoles(d.client)
+		vdiRoles, err := d.getRoles()
 		if err != nil {
 			return false, "", err
 		}
@@ -49,7 +65,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 			if roleObj == nil {
 				continue
 			}
-			for _, rule := range roleObj.GetRules() {
+			for _, rule := range rbac.EffectiveRules(roleObj, vdiRoles) {
 				if !rbac.UserIncludesRule(reqUser, rule, NewResourceGetter(d)) {
 					return false, elevateDenyReason, nil
 				}
@@ -60,7 +76,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 
 	// Check that a PUT /users/{user} will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*types.UpdateUserRequest); ok {
-		vdiRoles, err := d.vdiCluster.GetRoles(d.client)
+		vdiRoles, err := d.getRoles()
 		if err != nil {
 			return false, "", err
 		}
@@ -69,7 +85,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 			if roleObj == nil {
 				continue
 			}
-			for _, rule := range roleObj.GetRules() {
+			for _, rule := range rbac.EffectiveRules(roleObj, vdiRoles) {
 				if !rbac.UserIncludesRule(reqUser, rule, NewResourceGetter(d)) {
 					return false, elevateDenyReason, nil
 				}
@@ -80,7 +96,11 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 
 	// Check that a POST /roles will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*types.CreateRoleRequest); ok {
-		for _, rule := range reqObj.GetRules() {
+		rules, err := d.getRequestedRoleRules(reqObj.GetName(), reqObj.GetRules(), reqObj.InheritsFrom)
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
 			if !rbac.UserIncludesRule(reqUser, rule, NewResourceGetter(d)) {
 				return false, elevateDenyReason, nil
 			}
@@ -90,7 +110,11 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 
 	// Check that a PUT /roles/{role} will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*types.UpdateRoleRequest); ok {
-		for _, rule := range reqObj.GetRules() {
+		rules, err := d.getRequestedRoleRules(apiutil.GetRoleFromRequest(r), reqObj.GetRules(), reqObj.InheritsFrom)
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
 			if !rbac.UserIncludesRule(reqUser, rule, NewResourceGetter(d)) {
 				return false, elevateDenyReason, nil
 			}
@@ -102,6 +126,21 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *types.VDIUser, r *http.Request
 	return false, elevateDenyReason, nil
 }
 
+// getRequestedRoleRules returns the rules a role would grant with the given rules and
+// inheritance, so roles can't be used to inherit privileges the user does not have.
+func (d *desktopAPI) getRequestedRoleRules(name string, rules []rbacv1.Rule, inheritsFrom []string) ([]rbacv1.Rule, error) {
+	if len(inheritsFrom) == 0 {
+		return rules, nil
+	}
+	vdiRoles, err := d.getRoles()
+	if err != nil {
+		return nil, err
+	}
+	role := &rbacv1.VDIRole{Rules: rules, InheritsFrom: inheritsFrom}
+	role.SetName(name)
+	return rbac.EffectiveRules(role, vdiRoles), nil
+}
+
 func getRoleByName(roles []*rbacv1.VDIRole, name string) *rbacv1.VDIRole {
 	for _, role := range roles {
 		if role.GetName() == name {
@@ -43,6 +43,7 @@ func (d *desktopAPI) ValidateUserSession(next http.Handler) http.Handler {
 
 		// if we don't have a token we can't proceed
 		if authToken == "" {
+			recordAuthFailure(authFailureMissingToken)
 			apiutil.ReturnAPIForbidden(nil, "No token provided in request", w)
 			return
 		}
@@ -57,10 +58,21 @@ func (d *desktopAPI) ValidateUserSession(next http.Handler) http.Handler {
 		// verify the token and retrieve the claims
 		session, err := apiutil.DecodeAndVerifyJWT(jwtSecret, authToken)
 		if err != nil {
+			recordAuthFailure(authFailureInvalidToken)
 			apiutil.ReturnAPIUnauthorized(nil, err.Error(), w)
 			return
 		}
 
+		// make sure the token has not been revoked
+		if revoked, err := d.tokenIsRevoked(session); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		} else if revoked {
+			recordAuthFailure(authFailureRevokedToken)
+			apiutil.ReturnAPIUnauthorized(nil, "The token has been revoked", w)
+			return
+		}
+
 		// let requests to authorize a token with mfa to go through
 		if !session.Authorized && apiutil.GetGorillaPath(r) != "/api/authorize" && r.Method != http.MethodPost {
 			apiutil.ReturnAPIForbidden(nil, "User session is not authorized", w)
@@ -21,10 +21,13 @@ package client
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
+	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/tinyzimmer/kvdi/pkg/auth/breakglass"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
@@ -32,10 +35,14 @@ import (
 // authenticate retrieves an access token for the API and starts a goroutine
 // to refresh the token as needed.
 func (c *Client) authenticate() error {
+	if len(c.opts.BreakGlassKey) != 0 {
+		return c.unlockBreakGlass()
+	}
 	loginRequest := &types.LoginRequest{
-		Username: c.opts.Username,
-		Password: c.opts.Password,
-		State:    uuid.New().String(),
+		Username:            c.opts.Username,
+		Password:            c.opts.Password,
+		State:               uuid.New().String(),
+		AccessOverrideToken: c.opts.AccessOverrideToken,
 	}
 	payload, err := json.Marshal(loginRequest)
 	if err != nil {
@@ -71,6 +78,55 @@ func (c *Client) authenticate() error {
 	return nil
 }
 
+// unlockBreakGlass signs a break-glass challenge with the configured key and uses the
+// signature to retrieve an access token for the break-glass account.
+func (c *Client) unlockBreakGlass() error {
+	key, err := breakglass.ParsePrivateKey(c.opts.BreakGlassKey)
+	if err != nil {
+		return err
+	}
+	challenge := &types.BreakGlassChallengeResponse{}
+	if err := c.do(http.MethodPost, "break-glass/challenge", nil, challenge, false); err != nil {
+		return err
+	}
+	sig, err := breakglass.Sign(key, challenge.Challenge)
+	if err != nil {
+		return err
+	}
+	req := &types.BreakGlassUnlockRequest{
+		Challenge: challenge.Challenge,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+		Reason:    c.opts.BreakGlassReason,
+		State:     uuid.New().String(),
+	}
+	sessionResponse := &types.SessionResponse{}
+	if err := c.do(http.MethodPost, "break-glass/unlock", req, sessionResponse, false); err != nil {
+		return err
+	}
+	if sessionResponse.State != req.State {
+		return errors.New("State was malformed during authentication flow, your request might have been intercepted")
+	}
+	c.setAccessToken(sessionResponse.Token)
+	return nil
+}
+
+// RenewToken renews the client's access token, extending the session without
+// authenticating again.
+func (c *Client) RenewToken() error {
+	sessionResponse := &types.SessionResponse{}
+	if err := c.do(http.MethodPost, "renew_token", nil, sessionResponse); err != nil {
+		return err
+	}
+	c.setAccessToken(sessionResponse.Token)
+	return nil
+}
+
+// LogoutAll revokes every token issued to the authenticated user, including the one
+// in use by this client. The client must authenticate again before making further requests.
+func (c *Client) LogoutAll() error {
+	return c.do(http.MethodPost, "logout/all", nil, nil, false)
+}
+
 // refreshToken performs a refresh_token request and returns the response or any error.
 func (c *Client) refreshToken() (*types.SessionResponse, error) {
 	res, err := c.httpClient.Get(c.getEndpoint("refresh_token"))
@@ -46,7 +46,8 @@ type Client struct {
 
 // Opts are options to pass to New when creating a new client interface.
 type Opts struct {
-	// The full URL to the kVDI app server (e.g. https://kvdi.local)
+	// The full URL to the kVDI app server (e.g. https://kvdi.local), including the path
+	// prefix when the app is served under one (e.g. https://apps.local/vdi)
 	URL string
 	// The username to use to authenticate.
 	Username string
@@ -61,6 +62,14 @@ type Opts struct {
 	TLSCACert []byte
 	// Set to true to skip TLS verification.
 	TLSInsecureSkipVerify bool
+	// An access override token to supply when logging in outside of the access
+	// hours of the user's roles.
+	AccessOverrideToken string
+	// A PEM encoded break-glass private key. When set, the client unlocks the break-glass
+	// account with it instead of authenticating with a username and password.
+	BreakGlassKey []byte
+	// The reason for using the break-glass account, recorded in the audit log.
+	BreakGlassReason string
 }
 
 // New creates a new kVDI client.
@@ -25,6 +25,9 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
@@ -57,6 +60,72 @@ func (c *Client) GetServerConfig() (*appv1.VDIClusterSpec, error) {
 	return spec, c.do(http.MethodGet, "config", nil, spec)
 }
 
+// ValidateServerConfig checks the VDICluster configuration of the server end-to-end and
+// returns the report.
+func (c *Client) ValidateServerConfig() (*types.ConfigValidationReport, error) {
+	report := &types.ConfigValidationReport{}
+	return report, c.do(http.MethodGet, "config/validate", nil, report)
+}
+
+// StageServerConfig stages a proposed revision of the server's VDICluster configuration.
+// It is not applied until it is promoted.
+func (c *Client) StageServerConfig(spec *appv1.VDIClusterSpec) error {
+	return c.do(http.MethodPut, "config/staged", &types.StageConfigRequest{Spec: *spec}, nil)
+}
+
+// GetStagedServerConfig previews the staged revision of the server's VDICluster configuration.
+func (c *Client) GetStagedServerConfig() (*types.StagedConfigPreview, error) {
+	preview := &types.StagedConfigPreview{}
+	return preview, c.do(http.MethodGet, "config/staged", nil, preview)
+}
+
+// DiscardStagedServerConfig discards the staged revision of the server's VDICluster configuration.
+func (c *Client) DiscardStagedServerConfig() error {
+	return c.do(http.MethodDelete, "config/staged", nil, nil)
+}
+
+// PromoteServerConfig promotes the staged revision of the server's VDICluster configuration.
+func (c *Client) PromoteServerConfig(force bool) (*types.StagedConfigPreview, error) {
+	preview := &types.StagedConfigPreview{}
+	return preview, c.do(http.MethodPost, "config/staged/promote", &types.PromoteConfigRequest{Force: force}, preview)
+}
+
+// RollbackServerConfig restores the server's VDICluster configuration that was replaced by
+// the last promotion.
+func (c *Client) RollbackServerConfig() error {
+	return c.do(http.MethodPost, "config/rollback", nil, nil)
+}
+
+// CreateBackup exports the state of the server's VDICluster to an archive encrypted
+// with the given passphrase.
+func (c *Client) CreateBackup(passphrase string) (*types.BackupResponse, error) {
+	resp := &types.BackupResponse{}
+	return resp, c.do(http.MethodPost, "backup", &types.BackupRequest{Passphrase: passphrase}, resp)
+}
+
+// RestoreBackup restores the state of the server's VDICluster from the given archive.
+func (c *Client) RestoreBackup(req *types.RestoreRequest) (*types.RestoreResponse, error) {
+	resp := &types.RestoreResponse{}
+	return resp, c.do(http.MethodPost, "restore", req, resp)
+}
+
+// GetFaults retrieves the faults injected into the server. Only servers built with
+// fault injection serve this request.
+func (c *Client) GetFaults() (*types.FaultInjectionStatus, error) {
+	status := &types.FaultInjectionStatus{}
+	return status, c.do(http.MethodGet, "faults", nil, status)
+}
+
+// SetFaults replaces the faults injected into the server.
+func (c *Client) SetFaults(faults []*types.Fault) error {
+	return c.do(http.MethodPut, "faults", &types.FaultInjectionRequest{Faults: faults}, nil)
+}
+
+// ClearFaults removes all faults injected into the server.
+func (c *Client) ClearFaults() error {
+	return c.do(http.MethodDelete, "faults", nil, nil)
+}
+
 // GetNamespaces retrieves a list of namespaces the current user has access to.
 func (c *Client) GetNamespaces() ([]string, error) {
 	var nss []string
@@ -91,11 +160,24 @@ func (c *Client) CreateDesktopSession(opts *types.CreateSessionRequest) (*types.
 	return resp, c.do(http.MethodPost, "sessions", opts, resp)
 }
 
+// CreateDesktopSessions launches a template in several namespaces or for several users.
+func (c *Client) CreateDesktopSessions(opts *types.BulkCreateSessionRequest) (*types.BulkCreateSessionResponse, error) {
+	resp := &types.BulkCreateSessionResponse{}
+	return resp, c.do(http.MethodPost, "sessions/bulk", opts, resp)
+}
+
 // DeleteDesktopSession terminates the given desktop session.
 func (c *Client) DeleteDesktopSession(nn NamespacedName) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("sessions/%s/%s", nn.Namespace, nn.Name), nil, nil)
 }
 
+// GetDesktopSessionDevices retrieves the effective device policy for the current user on
+// the given session.
+func (c *Client) GetDesktopSessionDevices(nn NamespacedName) (*types.SessionDevicePolicy, error) {
+	resp := &types.SessionDevicePolicy{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("sessions/%s/%s/devices", nn.Namespace, nn.Name), nil, resp)
+}
+
 // GetDesktopDisplayProxy returns a ReadWriteCloser proxying the display of the given session.
 func (c *Client) GetDesktopDisplayProxy(nn NamespacedName) (io.ReadWriteCloser, error) {
 	return c.doWebsocket(fmt.Sprintf("desktops/ws/%s/%s/display", nn.Namespace, nn.Name))
@@ -106,12 +188,74 @@ func (c *Client) GetDesktopAudioProxy(nn NamespacedName) (io.ReadWriteCloser, er
 	return c.doWebsocket(fmt.Sprintf("desktops/ws/%s/%s/audio", nn.Namespace, nn.Name))
 }
 
+// GetDesktopClipboardProxy returns a ReadWriteCloser syncing the clipboard of the given
+// session. Updates are exchanged as a 4-byte big-endian length followed by UTF-8 text.
+func (c *Client) GetDesktopClipboardProxy(nn NamespacedName) (io.ReadWriteCloser, error) {
+	return c.doWebsocket(fmt.Sprintf("desktops/ws/%s/%s/clipboard", nn.Namespace, nn.Name))
+}
+
+// GetDesktopSSHProxy returns a ReadWriteCloser proxying the SSH server of the given session.
+func (c *Client) GetDesktopSSHProxy(nn NamespacedName) (io.ReadWriteCloser, error) {
+	return c.doWebsocket(fmt.Sprintf("desktops/ws/%s/%s/ssh", nn.Namespace, nn.Name))
+}
+
+// SignDesktopSSHKey signs the given public key for SSH access to the given session.
+func (c *Client) SignDesktopSSHKey(nn NamespacedName, req *types.SSHCertificateRequest) (*types.SSHCertificateResponse, error) {
+	resp := &types.SSHCertificateResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/ssh", nn.Namespace, nn.Name), req, resp)
+}
+
+// GetDesktopKubeconfig issues a short-lived kubeconfig with the permissions of the given
+// session's service account.
+func (c *Client) GetDesktopKubeconfig(nn NamespacedName) (*types.SessionKubeconfigResponse, error) {
+	resp := &types.SessionKubeconfigResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/kubeconfig", nn.Namespace, nn.Name), nil, resp)
+}
+
+// SaveDesktopTemplate saves the given desktop session as a new draft template, pending review.
+func (c *Client) SaveDesktopTemplate(nn NamespacedName, req *types.SaveSessionTemplateRequest) (*desktopsv1.Template, error) {
+	resp := &desktopsv1.Template{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/template", nn.Namespace, nn.Name), req, resp)
+}
+
+// SnapshotDesktopSession snapshots the persistent volumes of the given desktop session.
+func (c *Client) SnapshotDesktopSession(nn NamespacedName, req *types.CreateSnapshotRequest) (*desktopsv1.DesktopSnapshot, error) {
+	resp := &desktopsv1.DesktopSnapshot{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/snapshot", nn.Namespace, nn.Name), req, resp)
+}
+
+// AddDesktopSessionMonitor attaches an additional monitor to the given desktop session and
+// returns the new layout of its monitors.
+func (c *Client) AddDesktopSessionMonitor(nn NamespacedName, req *types.AddMonitorRequest) ([]desktopsv1.DisplayResolution, error) {
+	resp := make([]desktopsv1.DisplayResolution, 0)
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/monitors", nn.Namespace, nn.Name), req, &resp)
+}
+
+// RemoveDesktopSessionMonitor detaches the monitor at the given index from the given
+// desktop session and returns the new layout of its monitors.
+func (c *Client) RemoveDesktopSessionMonitor(nn NamespacedName, monitor int) ([]desktopsv1.DisplayResolution, error) {
+	resp := make([]desktopsv1.DisplayResolution, 0)
+	return resp, c.do(http.MethodDelete, fmt.Sprintf("sessions/%s/%s/monitors/%d", nn.Namespace, nn.Name, monitor), nil, &resp)
+}
+
+// ResizeDesktopDisplay changes the resolution and DPI of the display of the given desktop
+// session.
+func (c *Client) ResizeDesktopDisplay(nn NamespacedName, req *types.DisplayResizeRequest) error {
+	return c.do(http.MethodPost, fmt.Sprintf("desktops/%s/%s/display", nn.Namespace, nn.Name), req, nil)
+}
+
 // StatDesktopFile retrieves stat information for the given path on the desktop.
 func (c *Client) StatDesktopFile(nn NamespacedName, path string) (*types.StatDesktopFileResponse, error) {
 	resp := &types.StatDesktopFileResponse{}
 	return resp, c.do(http.MethodGet, fmt.Sprintf("desktops/fs/%s/%s/stat/%s", nn.Namespace, nn.Name, path), nil, resp)
 }
 
+// GetDesktopHomeUsage retrieves the storage usage of the user's home directory in the given desktop.
+func (c *Client) GetDesktopHomeUsage(nn NamespacedName) (*types.HomeUsageResponse, error) {
+	resp := &types.HomeUsageResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("desktops/fs/%s/%s/usage", nn.Namespace, nn.Name), nil, resp)
+}
+
 // GetDesktopFile retrieves a ReadCloser containing the contents of the requested file
 func (c *Client) GetDesktopFile(nn NamespacedName, path string) (io.ReadCloser, error) {
 	resp, err := c.doRaw(http.MethodGet, fmt.Sprintf("desktops/fs/%s/%s/get/%s", nn.Namespace, nn.Name, path), nil)
@@ -124,6 +268,19 @@ func (c *Client) GetDesktopFile(nn NamespacedName, path string) (io.ReadCloser,
 	return resp.Body, nil
 }
 
+// GetDesktopSessionThumbnail retrieves a ReadCloser containing the most recent PNG
+// thumbnail captured of the session's display.
+func (c *Client) GetDesktopSessionThumbnail(nn NamespacedName) (io.ReadCloser, error) {
+	resp, err := c.doRaw(http.MethodGet, fmt.Sprintf("sessions/%s/%s/thumbnail", nn.Namespace, nn.Name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.CheckAPIError(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
 // PutDesktopFile uploads a file to the given desktop session.
 func (c *Client) PutDesktopFile(nn NamespacedName, name string, contents io.Reader) error {
 	var b bytes.Buffer
@@ -172,17 +329,166 @@ func (c *Client) GetVDIRole(name string) (*rbacv1.VDIRole, error) {
 	return role, c.do(http.MethodGet, fmt.Sprintf("roles/%s", name), nil, role)
 }
 
+// GetVDIRoleEffectiveRules retrieves the rules a VDIRole grants, including those it
+// inherits from other roles.
+func (c *Client) GetVDIRoleEffectiveRules(name string) (*types.EffectiveRulesResponse, error) {
+	resp := &types.EffectiveRulesResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("roles/%s/effective", name), nil, resp)
+}
+
 // UpdateVDIRole will update a VDIRole. All existing properties are overwritten by those
 // in the request, even if nil or unset.
 func (c *Client) UpdateVDIRole(name string, req *types.UpdateRoleRequest) error {
 	return c.do(http.MethodPut, fmt.Sprintf("roles/%s", name), req, nil)
 }
 
+// GetVDIRoleVersion retrieves the given VDIRole along with its current version, for use
+// with UpdateVDIRoleVersion.
+func (c *Client) GetVDIRoleVersion(name string) (*rbacv1.VDIRole, string, error) {
+	role := &rbacv1.VDIRole{}
+	version, err := c.doVersioned(http.MethodGet, fmt.Sprintf("roles/%s", name), "", nil, role)
+	return role, version, err
+}
+
+// UpdateVDIRoleVersion will update a VDIRole only if it is still at the given version. The
+// new version of the role is returned. If the role has changed since, an API Conflict error
+// is returned containing its current state.
+func (c *Client) UpdateVDIRoleVersion(name, version string, req *types.UpdateRoleRequest) (string, error) {
+	return c.doVersioned(http.MethodPut, fmt.Sprintf("roles/%s", name), version, req, nil)
+}
+
 // DeleteVDIRole will delete the given VDIRole.
 func (c *Client) DeleteVDIRole(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("roles/%s", name), nil, nil)
 }
 
+// RestoreVDIRole will restore a deleted VDIRole.
+func (c *Client) RestoreVDIRole(name string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("roles/%s/restore", name), nil, nil)
+}
+
+// Authorization functions
+
+// CheckAuthz checks whether a user would be allowed to perform an action, and which rule
+// decides it. The action is not performed.
+func (c *Client) CheckAuthz(req *types.AuthzCheckRequest) (*types.AuthzCheckResponse, error) {
+	resp := &types.AuthzCheckResponse{}
+	return resp, c.do(http.MethodPost, "authz/check", req, resp)
+}
+
+// WhoCan lists the users and roles allowed to perform the given action.
+func (c *Client) WhoCan(action *types.APIAction) (*types.WhoCanResponse, error) {
+	query := url.Values{}
+	query.Set("verb", string(action.Verb))
+	query.Set("resource", string(action.ResourceType))
+	if action.ResourceName != "" {
+		query.Set("name", action.ResourceName)
+	}
+	if action.ResourceNamespace != "" {
+		query.Set("namespace", action.ResourceNamespace)
+	}
+	resp := &types.WhoCanResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("authz/who-can?%s", query.Encode()), nil, resp)
+}
+
+// GetPermissionMatrix generates a matrix of the permissions held by every user.
+func (c *Client) GetPermissionMatrix() (*types.PermissionMatrixResponse, error) {
+	resp := &types.PermissionMatrixResponse{}
+	return resp, c.do(http.MethodGet, "authz/matrix", nil, resp)
+}
+
+// GetPermissionMatrixCSV retrieves a ReadCloser containing the matrix of the permissions
+// held by every user as CSV.
+func (c *Client) GetPermissionMatrixCSV() (io.ReadCloser, error) {
+	resp, err := c.doRaw(http.MethodGet, "authz/matrix?format=csv", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.CheckAPIError(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetAuditEvents returns the recent audit events matching the given query from the app
+// instance serving the request.
+func (c *Client) GetAuditEvents(q *types.AuditQuery) (*types.AuditLogResponse, error) {
+	query := url.Values{}
+	if q.User != "" {
+		query.Set("user", q.User)
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	resp := &types.AuditLogResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("audit?%s", query.Encode()), nil, resp)
+}
+
+// Session recording functions
+
+// GetRecordings retrieves the recordings of desktop sessions, most recent first. Filters
+// left empty match every recording.
+func (c *Client) GetRecordings(user, namespace, session string) (*types.RecordingsResponse, error) {
+	query := url.Values{}
+	if user != "" {
+		query.Set("user", user)
+	}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	if session != "" {
+		query.Set("session", session)
+	}
+	resp := &types.RecordingsResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("recordings?%s", query.Encode()), nil, resp)
+}
+
+// GetRecording retrieves the recording of a desktop session with the given ID.
+func (c *Client) GetRecording(id string) (*types.Recording, error) {
+	resp := &types.Recording{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("recordings/%s", id), nil, resp)
+}
+
+// GetRecordingPlayback retrieves a ReadCloser streaming the events of the given recording
+// as JSON lines.
+func (c *Client) GetRecordingPlayback(id string) (io.ReadCloser, error) {
+	resp, err := c.doRaw(http.MethodGet, fmt.Sprintf("recordings/%s/playback", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.CheckAPIError(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteRecording deletes the recording with the given ID.
+func (c *Client) DeleteRecording(id string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("recordings/%s", id), nil, nil)
+}
+
+// Gateway functions
+
+// GetGateways retrieves the gateways available for connecting to desktops, along with
+// the one currently selected for the user.
+func (c *Client) GetGateways() (*types.GatewaysResponse, error) {
+	resp := &types.GatewaysResponse{}
+	return resp, c.do(http.MethodGet, "gateways", nil, resp)
+}
+
+// ReportGatewayLatency reports the latencies measured to each gateway and returns the
+// gateway display connections should be routed through.
+func (c *Client) ReportGatewayLatency(req *types.GatewayLatencyReport) (*types.GatewaySelection, error) {
+	resp := &types.GatewaySelection{}
+	return resp, c.do(http.MethodPut, "gateways/latency", req, resp)
+}
+
 // DesktopTemplate functions
 
 // GetDesktopTemplates returns a list of available DesktopTemplates. This is the same as doing
@@ -210,11 +516,76 @@ func (c *Client) UpdateDesktopTemplate(name string, req *desktopsv1.Template) er
 	return c.do(http.MethodPut, fmt.Sprintf("templates/%s", name), req, nil)
 }
 
+// GetDesktopTemplateVersion retrieves the given DesktopTemplate along with its current
+// version, for use with UpdateDesktopTemplateVersion.
+func (c *Client) GetDesktopTemplateVersion(name string) (*desktopsv1.Template, string, error) {
+	tmpl := &desktopsv1.Template{}
+	version, err := c.doVersioned(http.MethodGet, fmt.Sprintf("templates/%s", name), "", nil, tmpl)
+	return tmpl, version, err
+}
+
+// UpdateDesktopTemplateVersion will update a DesktopTemplate only if it is still at the
+// given version. The new version of the template is returned. If the template has changed
+// since, an API Conflict error is returned containing its current state.
+func (c *Client) UpdateDesktopTemplateVersion(name, version string, req *desktopsv1.Template) (string, error) {
+	return c.doVersioned(http.MethodPut, fmt.Sprintf("templates/%s", name), version, req, nil)
+}
+
+// ValidateDesktopTemplate lints the given DesktopTemplate without creating it.
+func (c *Client) ValidateDesktopTemplate(req *desktopsv1.Template) (*types.TemplateLintResponse, error) {
+	resp := &types.TemplateLintResponse{}
+	return resp, c.do(http.MethodPost, "templates/validate", req, resp)
+}
+
 // DeleteDesktopTemplate will delete the given DesktopTemplate.
 func (c *Client) DeleteDesktopTemplate(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("templates/%s", name), nil, nil)
 }
 
+// ApproveDesktopTemplate approves the given draft template, allowing others to launch it.
+func (c *Client) ApproveDesktopTemplate(name string, req *types.ReviewTemplateRequest) (*desktopsv1.Template, error) {
+	resp := &desktopsv1.Template{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("templates/%s/approve", name), req, resp)
+}
+
+// RejectDesktopTemplate rejects the given draft template.
+func (c *Client) RejectDesktopTemplate(name string, req *types.ReviewTemplateRequest) (*desktopsv1.Template, error) {
+	resp := &desktopsv1.Template{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("templates/%s/reject", name), req, resp)
+}
+
+// GetCapacity retrieves the desktop capacity of the cluster by node architecture.
+func (c *Client) GetCapacity() (*types.CapacityResponse, error) {
+	resp := &types.CapacityResponse{}
+	return resp, c.do(http.MethodGet, "capacity", nil, resp)
+}
+
+// GetAutoscalingHints retrieves the desktop capacity the cluster should have for node
+// autoscalers.
+func (c *Client) GetAutoscalingHints() (*types.AutoscalingHints, error) {
+	resp := &types.AutoscalingHints{}
+	return resp, c.do(http.MethodGet, "autoscaling", nil, resp)
+}
+
+// GetLicenseUsage retrieves the usage of the license pools configured on the cluster.
+func (c *Client) GetLicenseUsage() (*types.LicenseUsageResponse, error) {
+	resp := &types.LicenseUsageResponse{}
+	return resp, c.do(http.MethodGet, "licenses", nil, resp)
+}
+
+// GetMarketplaceTemplates lists the templates available from the template indexes the
+// cluster subscribes to.
+func (c *Client) GetMarketplaceTemplates() (*types.MarketplaceResponse, error) {
+	resp := &types.MarketplaceResponse{}
+	return resp, c.do(http.MethodGet, "marketplace", nil, resp)
+}
+
+// InstallMarketplaceTemplate installs or updates a template from the given template index.
+func (c *Client) InstallMarketplaceTemplate(index, name string) (*desktopsv1.Template, error) {
+	tmpl := &desktopsv1.Template{}
+	return tmpl, c.do(http.MethodPost, fmt.Sprintf("marketplace/%s/%s", index, name), nil, tmpl)
+}
+
 // VDIUser functions
 
 // GetVDIUsers returns a list of available VDIUsers, if possible. VDIUsers are not
@@ -245,9 +616,243 @@ func (c *Client) UpdateVDIUser(name string, req *types.UpdateUserRequest) error
 	return c.do(http.MethodPut, fmt.Sprintf("users/%s", name), req, nil)
 }
 
+// GetVDIUserVersion retrieves the given VDIUser along with its current version, for use
+// with UpdateVDIUserVersion. The version is empty if the auth provider does not version
+// users.
+func (c *Client) GetVDIUserVersion(name string) (*types.VDIUser, string, error) {
+	user := &types.VDIUser{}
+	version, err := c.doVersioned(http.MethodGet, fmt.Sprintf("users/%s", name), "", nil, user)
+	return user, version, err
+}
+
+// UpdateVDIUserVersion will update a VDIUser only if it is still at the given version. The
+// new version of the user is returned. If the user has changed since, an API Conflict error
+// is returned containing its current state.
+func (c *Client) UpdateVDIUserVersion(name, version string, req *types.UpdateUserRequest) (string, error) {
+	return c.doVersioned(http.MethodPut, fmt.Sprintf("users/%s", name), version, req, nil)
+}
+
+// RevokeVDIUserTokens will revoke all access and refresh tokens issued to the given VDIUser.
+func (c *Client) RevokeVDIUserTokens(name string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("users/%s/revoke", name), nil, nil)
+}
+
+// IssueVDIUserAccessOverride will issue a token letting the given VDIUser log in outside of
+// the access hours of their roles.
+func (c *Client) IssueVDIUserAccessOverride(name string, req *types.AccessOverrideRequest) (*types.AccessOverrideResponse, error) {
+	resp := &types.AccessOverrideResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("users/%s/access_override", name), req, resp)
+}
+
+// SetVDIUserHomeShare will set the credentials used to mount the home share of the given VDIUser.
+func (c *Client) SetVDIUserHomeShare(name string, creds *types.HomeShareCredentials) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/homeshare", name), creds, nil)
+}
+
+// DeleteVDIUserHomeShare will remove the credentials used to mount the home share of the given VDIUser.
+func (c *Client) DeleteVDIUserHomeShare(name string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/homeshare", name), nil, nil)
+}
+
+// GetVDIUserVolume retrieves the volume holding the $HOME directory of the given VDIUser.
+func (c *Client) GetVDIUserVolume(name string) (*types.UserVolume, error) {
+	resp := &types.UserVolume{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/volume", name), nil, resp)
+}
+
+// ExpandVDIUserVolume expands the volume holding the $HOME directory of the given VDIUser
+// to the given size.
+func (c *Client) ExpandVDIUserVolume(name, size string) (*types.UserVolume, error) {
+	resp := &types.UserVolume{}
+	return resp, c.do(http.MethodPut, fmt.Sprintf("users/%s/volume", name), &types.ExpandUserVolumeRequest{Size: size}, resp)
+}
+
+// SearchDirectory searches the user directory backing the server's authentication
+// provider for users and groups, including users that have never logged in.
+func (c *Client) SearchDirectory(req *types.DirectorySearchRequest) (*types.DirectorySearchResponse, error) {
+	query := url.Values{}
+	query.Set("q", req.Query)
+	if req.Kind != "" {
+		query.Set("kind", string(req.Kind))
+	}
+	if req.Limit != 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	resp := &types.DirectorySearchResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("directory/search?%s", query.Encode()), nil, resp)
+}
+
+// GetVDIUserMetadata returns all of the metadata stored for the given VDIUser, keyed by
+// namespace.
+func (c *Client) GetVDIUserMetadata(name string) (map[string]map[string]string, error) {
+	out := make(map[string]map[string]string)
+	return out, c.do(http.MethodGet, fmt.Sprintf("users/%s/metadata", name), nil, &out)
+}
+
+// GetVDIUserMetadataNamespace returns the keys and values in a namespace of the given
+// VDIUser's metadata.
+func (c *Client) GetVDIUserMetadataNamespace(name, namespace string) (map[string]string, error) {
+	out := make(map[string]string)
+	return out, c.do(http.MethodGet, fmt.Sprintf("users/%s/metadata/%s", name, namespace), nil, &out)
+}
+
+// GetVDIUserMetadataValue returns a single value from the given VDIUser's metadata.
+func (c *Client) GetVDIUserMetadataValue(name, namespace, key string) (string, error) {
+	out := &types.UserMetadataValue{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("users/%s/metadata/%s/%s", name, namespace, key), nil, out); err != nil {
+		return "", err
+	}
+	return out.Value, nil
+}
+
+// SetVDIUserMetadataValue will set a value in the given VDIUser's metadata.
+func (c *Client) SetVDIUserMetadataValue(name, namespace, key, value string) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/metadata/%s/%s", name, namespace, key), &types.UserMetadataValue{Value: value}, nil)
+}
+
+// DeleteVDIUserMetadataNamespace will remove a namespace and all of its keys from the given
+// VDIUser's metadata.
+func (c *Client) DeleteVDIUserMetadataNamespace(name, namespace string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/metadata/%s", name, namespace), nil, nil)
+}
+
+// DeleteVDIUserMetadataValue will remove a value from the given VDIUser's metadata.
+func (c *Client) DeleteVDIUserMetadataValue(name, namespace, key string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/metadata/%s/%s", name, namespace, key), nil, nil)
+}
+
+// GetVDIUserDevices returns the devices the given VDIUser has signed in from.
+func (c *Client) GetVDIUserDevices(name string) ([]*types.UserDevice, error) {
+	out := make([]*types.UserDevice, 0)
+	return out, c.do(http.MethodGet, fmt.Sprintf("users/%s/devices", name), nil, &out)
+}
+
+// RevokeVDIUserDevice will sign the given VDIUser out on a device and forget it.
+func (c *Client) RevokeVDIUserDevice(name, device string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/devices/%s", name, device), nil, nil)
+}
+
 // DeleteVDIUser will delete the given VDIUser.
 func (c *Client) DeleteVDIUser(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("users/%s", name), nil, nil)
 }
 
+// RestoreVDIUser will restore a deleted VDIUser along with the roles bound to them.
+func (c *Client) RestoreVDIUser(name string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("users/%s/restore", name), nil, nil)
+}
+
+// GetDeletedObjects returns the deleted users and roles that can still be restored. If
+// kind is not empty, only objects of that kind are returned.
+func (c *Client) GetDeletedObjects(kind rbacv1.Resource) ([]*types.DeletedObject, error) {
+	path := "deleted"
+	if kind != "" {
+		path = fmt.Sprintf("deleted?kind=%s", kind)
+	}
+	resp := make([]*types.DeletedObject, 0)
+	return resp, c.do(http.MethodGet, path, nil, &resp)
+}
+
 // TODO: Should MFA management functions be implemented?
+
+// Template access request functions
+
+// GetAccessRequests retrieves template access requests. Users allowed to review requests
+// receive all of them, otherwise only the requesting user's are returned.
+func (c *Client) GetAccessRequests() ([]*types.AccessRequest, error) {
+	resp := make([]*types.AccessRequest, 0)
+	return resp, c.do(http.MethodGet, "access_requests", nil, &resp)
+}
+
+// CreateAccessRequest requests access to launch a template.
+func (c *Client) CreateAccessRequest(req *types.CreateAccessRequest) (*types.AccessRequest, error) {
+	resp := &types.AccessRequest{}
+	return resp, c.do(http.MethodPost, "access_requests", req, resp)
+}
+
+// ApproveAccessRequest approves the access request with the given ID.
+func (c *Client) ApproveAccessRequest(id string, req *types.ReviewAccessRequest) (*types.AccessRequest, error) {
+	resp := &types.AccessRequest{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("access_requests/%s/approve", id), req, resp)
+}
+
+// DenyAccessRequest denies the access request with the given ID.
+func (c *Client) DenyAccessRequest(id string, req *types.ReviewAccessRequest) (*types.AccessRequest, error) {
+	resp := &types.AccessRequest{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("access_requests/%s/deny", id), req, resp)
+}
+
+// GetDomainHosts retrieves the hosts domain joined desktops may lease.
+func (c *Client) GetDomainHosts() ([]*types.DomainHost, error) {
+	resp := make([]*types.DomainHost, 0)
+	return resp, c.do(http.MethodGet, "domain/hosts", nil, &resp)
+}
+
+// SetDomainHostKeytab will upload the keytab of a host domain joined desktops may lease.
+func (c *Client) SetDomainHostKeytab(host string, keytab []byte) error {
+	return c.do(http.MethodPut, fmt.Sprintf("domain/hosts/%s", host), &types.DomainKeytabRequest{Keytab: keytab}, nil)
+}
+
+// DeleteDomainHost will remove the keytab of a host that is not leased to a desktop.
+func (c *Client) DeleteDomainHost(host string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("domain/hosts/%s", host), nil, nil)
+}
+
+// Lab functions
+
+// GetLabs retrieves the labs the current user is an instructor for. Users with
+// administrative access receive every lab for the cluster.
+func (c *Client) GetLabs() ([]*desktopsv1.Lab, error) {
+	resp := make([]*desktopsv1.Lab, 0)
+	return resp, c.do(http.MethodGet, "labs", nil, &resp)
+}
+
+// GetLab retrieves a single lab by its namespaced name.
+func (c *Client) GetLab(nn NamespacedName) (*desktopsv1.Lab, error) {
+	lab := &desktopsv1.Lab{}
+	return lab, c.do(http.MethodGet, fmt.Sprintf("labs/%s/%s", nn.Namespace, nn.Name), nil, lab)
+}
+
+// BroadcastLab delivers a message to every session in the given lab.
+func (c *Client) BroadcastLab(nn NamespacedName, req *types.LabBroadcastRequest) (*types.LabControlResponse, error) {
+	resp := &types.LabControlResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("labs/%s/%s/broadcast", nn.Namespace, nn.Name), req, resp)
+}
+
+// LockLab locks the screens of every session in the given lab.
+func (c *Client) LockLab(nn NamespacedName, req *types.LabLockRequest) (*types.LabControlResponse, error) {
+	resp := &types.LabControlResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("labs/%s/%s/lock", nn.Namespace, nn.Name), req, resp)
+}
+
+// UnlockLab unlocks the screens of every session in the given lab.
+func (c *Client) UnlockLab(nn NamespacedName) (*types.LabControlResponse, error) {
+	resp := &types.LabControlResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("labs/%s/%s/unlock", nn.Namespace, nn.Name), nil, resp)
+}
+
+// GetLabThumbnail retrieves a ReadCloser containing a PNG image of the given user's
+// display in the lab.
+func (c *Client) GetLabThumbnail(nn NamespacedName, user string) (io.ReadCloser, error) {
+	resp, err := c.doRaw(http.MethodGet, fmt.Sprintf("labs/%s/%s/thumbnails/%s", nn.Namespace, nn.Name, user), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.CheckAPIError(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CollectLabFiles retrieves a ReadCloser containing a tar archive of the given path
+// from the home directory of every session in the lab.
+func (c *Client) CollectLabFiles(nn NamespacedName, path string) (io.ReadCloser, error) {
+	resp, err := c.doRaw(http.MethodGet, fmt.Sprintf("labs/%s/%s/collect/%s", nn.Namespace, nn.Name, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.CheckAPIError(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
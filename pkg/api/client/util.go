@@ -46,7 +46,7 @@ func (c *Client) getEndpoint(ep string) string {
 
 // getWebsocketEndpoint returns the full URL (token included) for a given websocket endpoint.
 func (c *Client) getWebsocketEndpoint(ep string) string {
-	u := strings.Replace(c.opts.URL, "http", "ws", 1)
+	u := strings.Replace(strings.TrimSuffix(c.opts.URL, "/"), "http", "ws", 1)
 	return fmt.Sprintf("%s/api/%s?token=%s", u, ep, c.getAccessToken())
 }
 
@@ -64,6 +64,12 @@ func (c *Client) doWebsocket(endpoint string) (io.ReadWriteCloser, error) {
 
 // doRaw retrieves the raw response for the given endpoint and method.
 func (c *Client) doRaw(method, endpoint string, req interface{}) (*http.Response, error) {
+	return c.doRawVersioned(method, endpoint, "", req)
+}
+
+// doRawVersioned retrieves the raw response for the given endpoint and method. When a version
+// is given, the request is made against that version of the object at the endpoint.
+func (c *Client) doRawVersioned(method, endpoint, version string, req interface{}) (*http.Response, error) {
 	var reqBody []byte
 	var err error
 
@@ -81,10 +87,40 @@ func (c *Client) doRaw(method, endpoint string, req interface{}) (*http.Response
 
 	r.Header.Add("X-Session-Token", c.getAccessToken())
 	r.Header.Add("Content-Type", "application/json")
+	if version != "" {
+		r.Header.Add("If-Match", fmt.Sprintf("%q", version))
+	}
 
 	return c.httpClient.Do(r)
 }
 
+// doVersioned is a helper function for requests made against a version of an object. The
+// version of the object returned by the API is returned. Requests made against a stale
+// version return an API Conflict error containing the current state of the object.
+func (c *Client) doVersioned(method, endpoint, version string, req, resp interface{}) (string, error) {
+	rawRes, err := c.doRawVersioned(method, endpoint, version, req)
+	if err != nil {
+		return "", err
+	}
+	defer rawRes.Body.Close()
+
+	if err := errors.CheckAPIError(rawRes); err != nil {
+		return "", err
+	}
+
+	if resp != nil {
+		body, err := ioutil.ReadAll(rawRes.Body)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(body, resp); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Trim(rawRes.Header.Get("ETag"), `"`), nil
+}
+
 // do is a helper function for a generic request flow with the API.
 func (c *Client) do(method, endpoint string, req, resp interface{}, retry ...bool) error {
 	rawRes, err := c.doRaw(method, endpoint, req)
@@ -0,0 +1,46 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/staging"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route DELETE /api/config/staged Miscellaneous deleteStagedConfig
+// Discards the staged revision of the VDICluster configuration.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) DeleteStagedConfig(w http.ResponseWriter, r *http.Request) {
+	if err := staging.Discard(r.Context(), d.client, d.vdiCluster.GetName()); err != nil {
+		if err == staging.ErrNoStagedConfig {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
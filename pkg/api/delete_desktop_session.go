@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"net/http"
 
-	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -55,8 +54,8 @@ import (
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) DeleteDesktopSession(w http.ResponseWriter, r *http.Request) {
 	nn := apiutil.GetNamespacedNameFromRequest(r)
-	found := &desktopsv1.Session{}
-	if err := d.client.Get(context.TODO(), nn, found); err != nil {
+	found, err := d.getClusterDesktop(nn)
+	if err != nil {
 		if client.IgnoreNotFound(err) == nil {
 			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
 			return
@@ -0,0 +1,40 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
+)
+
+// swagger:route DELETE /api/faults Miscellaneous deleteFaults
+// Removes all faults injected into the server. This route is only served by servers
+// built with fault injection enabled.
+// responses:
+//   200: boolResponse
+//   403: error
+//   404: error
+func (d *desktopAPI) DeleteFaults(w http.ResponseWriter, r *http.Request) {
+	faults.Clear()
+	apiLogger.Info("Cleared injected faults", "User", apiutil.GetRequestUserSession(r).User.GetName())
+	apiutil.WriteOK(w)
+}
@@ -35,6 +35,10 @@ import (
 // swagger:operation DELETE /api/roles/{role} Roles deleteRoleRequest
 // ---
 // summary: Delete the specified role.
+// description: |
+//   When a deletion retention is configured, the role is kept until the retention passes
+//   and can be restored. Users and teams bound to the role keep referencing it by name, so
+//   restoring the role restores their bindings as well.
 // parameters:
 // - name: role
 //   in: path
@@ -62,9 +66,14 @@ func (d *desktopAPI) DeleteRole(w http.ResponseWriter, r *http.Request) {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	if err := d.client.Delete(context.TODO(), vdiRole); err != nil {
+	if !d.vdiCluster.OwnsObject(vdiRole) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The role '%s' doesn't exist", role), w)
+		return
+	}
+	if err := d.trashRole(r, vdiRole); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	d.rbacCache.invalidate()
 	apiutil.WriteOK(w)
 }
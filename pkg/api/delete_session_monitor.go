@@ -0,0 +1,89 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation DELETE /api/sessions/{namespace}/{name}/monitors/{monitor} Sessions deleteSessionMonitor
+// ---
+// summary: Detach a monitor from a desktop session.
+// description: The primary monitor cannot be detached. Monitors after the detached one move down an index, and their display streams are closed for clients to reconnect. The new layout of the desktop's monitors is returned.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: monitor
+//   in: path
+//   description: The index of the monitor to detach
+//   type: integer
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/monitorsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteSessionMonitor(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(apiutil.GetMonitorFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(fmt.Errorf("Invalid monitor: %s", apiutil.GetMonitorFromRequest(r)), w)
+		return
+	}
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	monitors, err := removeMonitor(desktop.Status.Monitors, index)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.setSessionMonitors(desktop, monitors); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(monitors, w)
+}
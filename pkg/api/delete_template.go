@@ -24,6 +24,7 @@ import (
 	"net/http"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -61,6 +62,10 @@ func (d *desktopAPI) DeleteDesktopTemplate(w http.ResponseWriter, r *http.Reques
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if err := tenancy.CheckTemplate(d.vdiCluster, tmpl); err != nil {
+		apiutil.ReturnAPINotFound(err, w)
+		return
+	}
 	if err := d.client.Delete(context.TODO(), tmpl); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -29,6 +29,10 @@ import (
 // swagger:operation DELETE /api/users/{user} Users deleteUserRequest
 // ---
 // summary: Delete the specified user.
+// description: |
+//   When a deletion retention is configured and the auth provider supports it, the user is
+//   kept until the retention passes and can be restored along with the roles bound to them.
+//   Their metadata and devices are removed once they are purged.
 // parameters:
 // - name: user
 //   in: path
@@ -46,7 +50,8 @@ import (
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	username := apiutil.GetUserFromRequest(r)
-	if err := d.auth.DeleteUser(username); err != nil {
+	kept, err := d.trashUser(r, username)
+	if err != nil {
 		if errors.IsUserNotFoundError(err) {
 			apiutil.ReturnAPINotFound(err, w)
 			return
@@ -54,5 +59,8 @@ func (d *desktopAPI) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if !kept {
+		d.removeUserData(username)
+	}
 	apiutil.WriteOK(w)
 }
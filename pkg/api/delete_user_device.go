@@ -0,0 +1,68 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation DELETE /api/users/{user}/devices/{device} Users deleteUserDeviceRequest
+// ---
+// summary: Signs the given user out on a device and forgets it.
+// description: All access and refresh tokens issued to the user on the device are revoked. The user may sign in from the device again afterwards, within the device limit of their roles.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - name: device
+//   in: path
+//   description: The ID of the device to revoke
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserDevice(w http.ResponseWriter, r *http.Request) {
+	username, device := apiutil.GetUserFromRequest(r), apiutil.GetDeviceFromRequest(r)
+	if err := d.userDevices.Remove(username, device); err != nil {
+		if errors.IsDeviceNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.revokeDeviceTokens(username, device); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation DELETE /api/users/{user}/metadata/{namespace} Users deleteUserMetadataNamespaceRequest
+// ---
+// summary: Removes a namespace and all of its keys from the given user's metadata.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The metadata namespace to remove
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserMetadataNamespace(w http.ResponseWriter, r *http.Request) {
+	if err := d.metadata.DeleteNamespace(apiutil.GetUserFromRequest(r), apiutil.GetNamespaceFromRequest(r)); err != nil {
+		if errors.IsMetadataNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// swagger:operation DELETE /api/users/{user}/metadata/{namespace}/{key} Users deleteUserMetadataValueRequest
+// ---
+// summary: Removes a value from the given user's metadata.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The metadata namespace of the key
+//   type: string
+//   required: true
+// - name: key
+//   in: path
+//   description: The key to remove
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserMetadataValue(w http.ResponseWriter, r *http.Request) {
+	if err := d.metadata.DeleteValue(apiutil.GetUserFromRequest(r), apiutil.GetNamespaceFromRequest(r), apiutil.GetKeyFromRequest(r)); err != nil {
+		if errors.IsMetadataNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
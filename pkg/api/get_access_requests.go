@@ -0,0 +1,71 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package api
+
+import (
+	"net/http"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// swagger:route GET /api/access_requests AccessRequests getAccessRequests
+// Retrieves template access requests. Users allowed to review requests receive the full queue,
+// while all other users only receive their own requests.
+// responses:
+//   200: accessRequestsResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetAccessRequests(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+	requests, err := d.listAccessRequests()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if canReviewAccessRequests(sess.User) {
+		apiutil.WriteJSON(requests, w)
+		return
+	}
+	filtered := make([]*types.AccessRequest, 0)
+	for _, req := range requests {
+		if req.User == sess.User.GetName() {
+			filtered = append(filtered, req)
+		}
+	}
+	apiutil.WriteJSON(filtered, w)
+}
+
+// canReviewAccessRequests returns true if the given user is allowed to approve
+// and deny access requests.
+func canReviewAccessRequests(user *types.VDIUser) bool {
+	return rbac.EvaluateUser(user, &types.APIAction{
+		Verb:         rbacv1.VerbUpdate,
+		ResourceType: rbacv1.ResourceRoles,
+	})
+}
+
+// Access requests response
+// swagger:response accessRequestsResponse
+type swaggerAccessRequestsResponse struct {
+	// in:body
+	Body []types.AccessRequest
+}
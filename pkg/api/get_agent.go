@@ -0,0 +1,175 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/agent/broadcast Agent getAgentBroadcast
+// ---
+// summary: Retrieves the latest message broadcast to a desktop session.
+// description: |
+//   Only accepts the token issued to the kvdi-agent in the session, passed in the X-Kvdi-Agent-Token header.
+//   Messages are broadcast to the lab the session belongs to, warn the user of pending maintenance, or warn the user that the session is idle and will be terminated.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getAgentBroadcastResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "401":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAgentBroadcast(w http.ResponseWriter, r *http.Request) {
+	sess, err := d.getAgentSession(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	out := &types.AgentBroadcast{}
+	if sess.GetLab() != "" {
+		lab := &desktopsv1.Lab{}
+		if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: sess.GetLab(), Namespace: sess.GetNamespace()}, lab); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			}
+		} else if lab.Status.LastBroadcast != "" {
+			out.Message = lab.Status.LastBroadcast
+			out.Time = lab.Status.LastBroadcastTime.Unix()
+		}
+	}
+	if sent := sess.Status.LastBroadcastTime; sess.Status.LastBroadcast != "" && sent.Unix() > out.Time {
+		out.Message = sess.Status.LastBroadcast
+		out.Time = sent.Unix()
+	}
+	if warned := sess.Status.IdleWarningTime; !warned.IsZero() && warned.Unix() > out.Time {
+		out.Message = fmt.Sprintf(
+			"This desktop has been idle and will be terminated at %s unless there is activity",
+			sess.Status.IdleTerminationTime.UTC().Format(time.RFC3339),
+		)
+		out.Time = warned.Unix()
+	}
+	apiutil.WriteJSON(out, w)
+}
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/agent/metadata Agent getAgentMetadata
+// ---
+// summary: Retrieves the metadata the kvdi-agent serves to tools inside a desktop session.
+// description: Only accepts the token issued to the kvdi-agent in the session, passed in the X-Kvdi-Agent-Token header.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getAgentMetadataResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "401":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAgentMetadata(w http.ResponseWriter, r *http.Request) {
+	sess, err := d.getAgentSession(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	store, err := d.metadata.Get(sess.GetUser())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&types.AgentMetadata{
+		Name:      sess.GetName(),
+		Namespace: sess.GetNamespace(),
+		Template:  sess.GetTemplateName(),
+		User:      sess.GetUser(),
+		Metadata:  store,
+	}, w)
+}
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/agent/kubeconfig Agent getAgentKubeconfig
+// ---
+// summary: Issues a short-lived kubeconfig with the permissions of a desktop session's service account.
+// description: Only accepts the token issued to the kvdi-agent in the session, passed in the X-Kvdi-Agent-Token header.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/sessionKubeconfigResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "401":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAgentKubeconfig(w http.ResponseWriter, r *http.Request) {
+	d.writeSessionKubeconfig(w, r)
+}
+
+// The latest message broadcast to a session
+// swagger:response getAgentBroadcastResponse
+type swaggerGetAgentBroadcastResponse struct {
+	// in:body
+	Body types.AgentBroadcast
+}
+
+// Metadata for tools inside a session
+// swagger:response getAgentMetadataResponse
+type swaggerGetAgentMetadataResponse struct {
+	// in:body
+	Body types.AgentMetadata
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/audit Miscellaneous getAudit
+// ---
+// summary: Query recent audit events.
+// description: |
+//   Returns the audit events kept in memory by the app instance serving the request, most
+//   recent first. The number of events kept is set by the audit retention on the VDICluster.
+//   Older events, and events recorded by other replicas, are only available from the
+//   configured audit sink.
+// parameters:
+// - name: user
+//   in: query
+//   description: Only return events for this user
+//   type: string
+// - name: since
+//   in: query
+//   description: Only return events at or after this time, in RFC3339 format
+//   type: string
+// - name: until
+//   in: query
+//   description: Only return events before this time, in RFC3339 format
+//   type: string
+// - name: limit
+//   in: query
+//   description: The maximum number of events to return
+//   type: integer
+// responses:
+//   "200":
+//     "$ref": "#/responses/auditLogResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAudit(w http.ResponseWriter, r *http.Request) {
+	query, err := getAuditQueryFromRequest(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&types.AuditLogResponse{Events: d.audit.Query(query)}, w)
+}
+
+// getAuditQueryFromRequest builds an audit query from the query of a request.
+func getAuditQueryFromRequest(r *http.Request) (*types.AuditQuery, error) {
+	params := r.URL.Query()
+	query := &types.AuditQuery{User: params.Get("user")}
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, errors.New("The start of the time range must be in RFC3339 format")
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, errors.New("The end of the time range must be in RFC3339 format")
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.New("The limit must be a number")
+		}
+	}
+	return query, query.Validate()
+}
+
+// The audit events matching a query
+// swagger:response auditLogResponse
+type swaggerAuditLogResponse struct {
+	// in:body
+	Body types.AuditLogResponse
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// swagger:operation GET /api/authz/matrix Authorization getAuthzMatrix
+// ---
+// summary: Generate a matrix of the permissions held by every user.
+// description: |
+//   The matrix is computed from the roles bound to each user, including through their teams,
+//   and is meant for periodic access reviews. It contains one entry for every permission a
+//   user holds, with the role and rule that grants it. Management verbs are listed for each
+//   type of resource, and session verbs (such as `launch` and `use`) for every template in
+//   every namespace. Listing users is not supported with OIDC authentication.
+// parameters:
+// - name: format
+//   in: query
+//   description: The format of the report, `json` (the default) or `csv`
+//   type: string
+// responses:
+//   "200":
+//     "$ref": "#/responses/permissionMatrixResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAuthzMatrix(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		apiutil.ReturnAPIError(errors.New("The format must be one of json or csv"), w)
+		return
+	}
+
+	res, err := d.getPermissionMatrix()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="permission-matrix.csv"`)
+		if err := writePermissionMatrixCSV(w, res); err != nil {
+			apiLogger.Error(err, "Failed to write permission matrix")
+		}
+		return
+	}
+	apiutil.WriteJSON(res, w)
+}
+
+// getPermissionMatrix evaluates the permissions of every user against every type of
+// resource, and every template in every namespace available to the cluster.
+func (d *desktopAPI) getPermissionMatrix() (*types.PermissionMatrixResponse, error) {
+	users, err := d.auth.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if err := d.applyTeamRoles(user); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].GetName() < users[j].GetName() })
+
+	templates, err := NewResourceGetter(d).GetTemplates()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(templates)
+
+	namespaces, err := d.ListKubernetesNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(namespaces)
+	nsLabels := make(map[string]map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		if nsLabels[ns], err = d.getNamespaceLabels(ns); err != nil {
+			return nil, err
+		}
+	}
+
+	userNames := make([]string, len(users))
+	for i, user := range users {
+		userNames[i] = user.GetName()
+	}
+
+	return &types.PermissionMatrixResponse{
+		GeneratedAt: time.Now().Unix(),
+		Users:       userNames,
+		Templates:   templates,
+		Namespaces:  namespaces,
+		Entries:     rbac.PermissionMatrix(users, rbac.PermissionMatrixActions(templates, namespaces, nsLabels)),
+	}, nil
+}
+
+// writePermissionMatrixCSV writes the entries of the permission matrix as CSV, with a
+// header row.
+func writePermissionMatrixCSV(w io.Writer, res *types.PermissionMatrixResponse) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"user", "resource", "verb", "template", "namespace", "role", "rule"}); err != nil {
+		return err
+	}
+	for _, entry := range res.Entries {
+		if err := out.Write([]string{
+			entry.User,
+			string(entry.Resource),
+			string(entry.Verb),
+			entry.Template,
+			entry.Namespace,
+			entry.Role,
+			strconv.Itoa(entry.Rule),
+		}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// A matrix of the permissions held by every user
+// swagger:response permissionMatrixResponse
+type swaggerPermissionMatrixResponse struct {
+	// in:body
+	Body types.PermissionMatrixResponse
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// swagger:operation GET /api/authz/who-can Authorization getAuthzWhoCan
+// ---
+// summary: List the users and roles allowed to perform an action.
+// description: |
+//   The targeted resource can be given with `resource` and `name`, or with one of the
+//   `template`, `user`, or `role` shorthands. For example, `?verb=launch&template=foo`
+//   lists who may launch the template `foo`. Listing users is not supported with OIDC
+//   authentication.
+// parameters:
+// - name: verb
+//   in: query
+//   description: The action to check (e.g. `launch`)
+//   type: string
+//   required: true
+// - name: resource
+//   in: query
+//   description: The type of resource targeted by the action (e.g. `templates`)
+//   type: string
+// - name: name
+//   in: query
+//   description: The name of the targeted resource
+//   type: string
+// - name: namespace
+//   in: query
+//   description: The namespace of the targeted resource
+//   type: string
+// - name: template
+//   in: query
+//   description: Shorthand for `resource=templates&name=<template>`
+//   type: string
+// - name: user
+//   in: query
+//   description: Shorthand for `resource=users&name=<user>`
+//   type: string
+// - name: role
+//   in: query
+//   description: Shorthand for `resource=roles&name=<role>`
+//   type: string
+// responses:
+//   "200":
+//     "$ref": "#/responses/whoCanResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAuthzWhoCan(w http.ResponseWriter, r *http.Request) {
+	action, err := getWhoCanActionFromRequest(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.populateAction(action); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	users, err := d.auth.GetUsers()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	for _, user := range users {
+		if err := d.applyTeamRoles(user); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	vdiRoles, err := d.getRoles()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	roles := make([]*types.VDIUserRole, len(vdiRoles))
+	for i, role := range vdiRoles {
+		roles[i] = rbac.VDIRoleToUserRole(role)
+	}
+
+	apiutil.WriteJSON(&types.WhoCanResponse{
+		Action: action,
+		Users:  rbac.UsersAllowed(users, action),
+		Roles:  rbac.RolesAllowed(roles, action),
+	}, w)
+}
+
+// getWhoCanActionFromRequest builds the action to check from the query of a who-can request.
+func getWhoCanActionFromRequest(r *http.Request) (*types.APIAction, error) {
+	query := r.URL.Query()
+	action := &types.APIAction{
+		Verb:              rbacv1.Verb(query.Get("verb")),
+		ResourceType:      rbacv1.Resource(query.Get("resource")),
+		ResourceName:      query.Get("name"),
+		ResourceNamespace: query.Get("namespace"),
+	}
+	for param, resource := range map[string]rbacv1.Resource{
+		"template": rbacv1.ResourceTemplates,
+		"user":     rbacv1.ResourceUsers,
+		"role":     rbacv1.ResourceRoles,
+	} {
+		if name := query.Get(param); name != "" {
+			if action.ResourceType != "" && action.ResourceType != resource {
+				return nil, errors.New("Only one of resource, template, user, or role may be given")
+			}
+			action.ResourceType = resource
+			action.ResourceName = name
+		}
+	}
+	if action.Verb == "" {
+		return nil, errors.New("A verb is required to check authorization")
+	}
+	if action.ResourceType == "" {
+		return nil, errors.New("A resource is required to check authorization")
+	}
+	return action, nil
+}
+
+// The users and roles allowed to perform an action
+// swagger:response whoCanResponse
+type swaggerWhoCanResponse struct {
+	// in:body
+	Body types.WhoCanResponse
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/autoscaling Templates getAutoscaling
+// Retrieve the desktop capacity the cluster should have, computed from the running sessions,
+// the launches waiting for a node, the warm pool, and the active capacity schedules. The same
+// values are published as Prometheus metrics for node autoscalers to consume.
+// responses:
+//   200: autoscalingResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetAutoscaling(w http.ResponseWriter, r *http.Request) {
+	hints, err := d.getAutoscalingHints()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(hints, w)
+}
+
+// Autoscaling hints response
+// swagger:response autoscalingResponse
+type swaggerAutoscalingResponse struct {
+	// in:body
+	Body types.AutoscalingHints
+}
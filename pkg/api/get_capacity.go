@@ -0,0 +1,196 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route GET /api/capacity Templates getCapacity
+// Retrieve the desktop capacity of the cluster by node architecture, along with the templates
+// that can be launched on each architecture.
+// responses:
+//   200: capacityResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+
+	capacity, err := d.getArchitectureCapacity()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tmpls, err := d.getAllDesktopTemplates()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	visible := rbac.FilterTemplates(sess.User, tmpls.Trim())
+
+	resp := &types.CapacityResponse{Architectures: make([]*types.ArchitectureCapacity, 0, len(capacity))}
+	for arch, archCapacity := range capacity {
+		for _, tmpl := range visible {
+			if tmpl.SupportsArchitecture(arch) {
+				archCapacity.Templates = append(archCapacity.Templates, tmpl.GetName())
+			}
+		}
+		resp.Architectures = append(resp.Architectures, archCapacity)
+	}
+	sort.Slice(resp.Architectures, func(i, j int) bool {
+		return resp.Architectures[i].Architecture < resp.Architectures[j].Architecture
+	})
+
+	apiutil.WriteJSON(resp, w)
+}
+
+// getArchitectureCapacity tallies the nodes, allocatable resources, and running desktops of
+// each architecture in the cluster.
+func (d *desktopAPI) getArchitectureCapacity() (map[desktopsv1.Architecture]*types.ArchitectureCapacity, error) {
+	nodes := &corev1.NodeList{}
+	if err := d.client.List(context.TODO(), nodes); err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := d.client.List(context.TODO(), pods, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+
+	capacity := make(map[desktopsv1.Architecture]*types.ArchitectureCapacity)
+	nodeArchs := make(map[string]desktopsv1.Architecture)
+	cpu := make(map[desktopsv1.Architecture]*resource.Quantity)
+	memory := make(map[desktopsv1.Architecture]*resource.Quantity)
+
+	for _, node := range nodes.Items {
+		arch := desktopsv1.Architecture(node.GetLabels()[corev1.LabelArchStable])
+		if arch == "" {
+			continue
+		}
+		nodeArchs[node.GetName()] = arch
+		if _, ok := capacity[arch]; !ok {
+			capacity[arch] = &types.ArchitectureCapacity{Architecture: string(arch), Templates: make([]string, 0)}
+			cpu[arch] = resource.NewQuantity(0, resource.DecimalSI)
+			memory[arch] = resource.NewQuantity(0, resource.BinarySI)
+		}
+		capacity[arch].Nodes++
+		if !nodeIsAvailable(&node) {
+			continue
+		}
+		capacity[arch].AvailableNodes++
+		cpu[arch].Add(*node.Status.Allocatable.Cpu())
+		memory[arch].Add(*node.Status.Allocatable.Memory())
+	}
+
+	for arch, archCapacity := range capacity {
+		archCapacity.AllocatableCPU = cpu[arch].String()
+		archCapacity.AllocatableMemory = memory[arch].String()
+	}
+
+	for _, pod := range pods.Items {
+		if arch, ok := nodeArchs[pod.Spec.NodeName]; ok && pod.Status.Phase == corev1.PodRunning {
+			capacity[arch].Sessions++
+		}
+	}
+
+	return capacity, nil
+}
+
+// nodeIsAvailable returns true if the node is ready and accepting new pods.
+func nodeIsAvailable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// selectSessionArchitecture returns the architecture to pin a new session of the template
+// to. Templates with per-architecture images are pinned to the requested architecture, or
+// the one with the most available nodes. Other templates are only pinned when requested.
+func (d *desktopAPI) selectSessionArchitecture(tmpl *desktopsv1.Template, requested string) (desktopsv1.Architecture, error) {
+	candidates := tmpl.GetLaunchArchitectures()
+
+	if requested != "" {
+		arch := desktopsv1.Architecture(requested)
+		if !tmpl.SupportsArchitecture(arch) {
+			return "", fmt.Errorf("Template %s does not support the %s architecture", tmpl.GetName(), requested)
+		}
+		if tmpl.HasImageVariants() && tmpl.GetDesktopImage() == "" {
+			for _, candidate := range candidates {
+				if candidate == arch {
+					return arch, nil
+				}
+			}
+			return "", fmt.Errorf("Template %s has no image for the %s architecture", tmpl.GetName(), requested)
+		}
+		return arch, nil
+	}
+
+	if !tmpl.HasImageVariants() {
+		return "", nil
+	}
+	if len(candidates) == 0 {
+		// the template has a default image and may run anywhere
+		return "", nil
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	capacity, err := d.getArchitectureCapacity()
+	if err != nil {
+		return "", err
+	}
+	var selected desktopsv1.Architecture
+	var most int
+	for _, arch := range candidates {
+		if archCapacity, ok := capacity[arch]; ok && archCapacity.AvailableNodes > most {
+			selected, most = arch, archCapacity.AvailableNodes
+		}
+	}
+	if selected == "" {
+		return "", fmt.Errorf("There are no nodes available for the architectures supported by %s", tmpl.GetName())
+	}
+	return selected, nil
+}
+
+// Capacity response
+// swagger:response capacityResponse
+type swaggerCapacityResponse struct {
+	// in:body
+	Body types.CapacityResponse
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/staging"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/config/staged Miscellaneous getStagedConfig
+// Previews the staged revision of the VDICluster configuration. The response contains the
+// changes to the spec, the changes the reconciler would make to the core resources of the
+// cluster, and the result of validating the revision end-to-end.
+// responses:
+//   200: stagedConfigResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) GetStagedConfig(w http.ResponseWriter, r *http.Request) {
+	preview, err := staging.Preview(r.Context(), d.client, d.vdiCluster.GetName())
+	if err != nil {
+		if err == staging.ErrNoStagedConfig {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(preview, w)
+}
+
+// Staged config response
+// swagger:response stagedConfigResponse
+type swaggerStagedConfigResponse struct {
+	// in:body
+	Body types.StagedConfigPreview
+}
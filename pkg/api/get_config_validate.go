@@ -0,0 +1,48 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/preflight"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/config/validate Miscellaneous getConfigValidation
+// Checks the VDICluster configuration end-to-end. This includes authenticating to the secrets
+// backend, setting up the auth provider (connecting to LDAP or performing OpenID discovery),
+// and verifying the app TLS certificates. Failed checks are reported in the body rather than
+// in the status code.
+// responses:
+//   200: configValidationResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetConfigValidation(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(preflight.Validate(r.Context(), d.client, d.vdiCluster, d.secrets), w)
+}
+
+// Config validation response
+// swagger:response configValidationResponse
+type swaggerConfigValidationResponse struct {
+	// in:body
+	Body types.ConfigValidationReport
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/deleted Users getDeletedRequest
+// ---
+// summary: Retrieves the users and roles that were deleted and can still be restored.
+// description: Deleted objects are kept for the deletion retention configured on the VDICluster, after which they are purged permanently. The most recently deleted objects are listed first.
+// parameters:
+// - name: kind
+//   in: query
+//   description: Only list objects of the given kind, either `users` or `roles`
+//   type: string
+// responses:
+//   "200":
+//     "$ref": "#/responses/getDeletedResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetDeleted(w http.ResponseWriter, r *http.Request) {
+	kind := rbacv1.Resource(r.URL.Query().Get("kind"))
+	if kind != "" && kind != rbacv1.ResourceUsers && kind != rbacv1.ResourceRoles {
+		apiutil.ReturnAPIError(errors.New("The kind must be one of users or roles"), w)
+		return
+	}
+	list, err := d.trash.List()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	out := make([]*types.DeletedObject, 0, len(list))
+	for _, obj := range list {
+		if kind == "" || obj.Kind == kind {
+			out = append(out, obj)
+		}
+	}
+	apiutil.WriteJSON(out, w)
+}
+
+// Deleted objects response
+// swagger:response getDeletedResponse
+type swaggerGetDeletedResponse struct {
+	// in:body
+	Body []types.DeletedObject
+}
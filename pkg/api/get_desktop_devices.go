@@ -0,0 +1,119 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/devices Sessions getSessionDevices
+// ---
+// summary: Retrieve the effective device policy for the requesting user on the given desktop session.
+// description: The policy combines the device policies of all of the user's roles and is enforced by the desktop proxy. Audio is denied in both directions when it is disabled on the desktop's template, and the microphone when the template disables it or the user does not hold the use-microphone verb on the template.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getSessionDevicesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetDesktopSessionDevices(w http.ResponseWriter, r *http.Request) {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	policy, err := d.getSessionDevicePolicy(r, desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(policy, w)
+}
+
+// getSessionDevicePolicy returns the effective device policy for the user making the
+// request on the given desktop session. The user must hold the `use-microphone` verb on
+// the template of the session to pass their microphone through.
+func (d *desktopAPI) getSessionDevicePolicy(r *http.Request, desktop *desktopsv1.Session) (*types.SessionDevicePolicy, error) {
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: desktop.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		return nil, err
+	}
+	user := apiutil.GetRequestUserSession(r).User
+	action := &types.APIAction{
+		Verb:              rbacv1.VerbUseMicrophone,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      tmpl.GetName(),
+		ResourceNamespace: desktop.GetNamespace(),
+	}
+	if err := d.populateAction(action); err != nil {
+		return nil, err
+	}
+	return sessionDevicePolicy(tmpl, rbac.EffectiveDevicePolicy(user.Roles), rbac.EvaluateUser(user, action)), nil
+}
+
+// sessionDevicePolicy restricts the device policy of a user to what the template allows.
+// Audio is denied in both directions when the template disables it, and audio input when
+// the template disables the microphone or the user cannot use it.
+func sessionDevicePolicy(tmpl *desktopsv1.Template, policy *types.SessionDevicePolicy, canUseMicrophone bool) *types.SessionDevicePolicy {
+	if !tmpl.AudioEnabled() {
+		policy.AudioOut = false
+	}
+	if !canUseMicrophone || !tmpl.MicrophoneEnabled() {
+		policy.AudioIn = false
+	}
+	return policy
+}
+
+// Session device policy response
+// swagger:response getSessionDevicesResponse
+type swaggerGetSessionDevicesResponse struct {
+	// in:body
+	Body types.SessionDevicePolicy
+}
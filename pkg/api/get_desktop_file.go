@@ -61,6 +61,9 @@ import (
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetStatDesktopFile(w http.ResponseWriter, r *http.Request) {
+	if d.checkFileTransfer(w, r, fileTransferBrowse) == nil {
+		return
+	}
 	proxy, err := d.getProxyClientForRequest(r)
 	if err != nil {
 		if client.IgnoreNotFound(err) == nil {
@@ -94,6 +97,7 @@ type swaggerStatDesktopFileResponse struct {
 // swagger:operation GET /api/desktops/fs/{namespace}/{name}/get/{fpath} Desktops downloadDesktopFile
 // ---
 // summary: Download the given file from a desktop session.
+// description: The template of the session must allow file downloads and the user must hold the use-file-transfer verb on it. The size and content type of the file are checked against the limits of the template.
 // parameters:
 // - name: namespace
 //   in: path
@@ -123,6 +127,10 @@ type swaggerStatDesktopFileResponse struct {
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetDownloadDesktopFile(w http.ResponseWriter, r *http.Request) {
+	tmpl := d.checkFileTransfer(w, r, fileTransferDownload)
+	if tmpl == nil {
+		return
+	}
 	proxy, err := d.getProxyClientForRequest(r)
 	if err != nil {
 		if client.IgnoreNotFound(err) == nil {
@@ -142,6 +150,11 @@ func (d *desktopAPI) GetDownloadDesktopFile(w http.ResponseWriter, r *http.Reque
 	}
 	defer res.Body.Close()
 
+	if err := checkFileTransferLimits(tmpl, res.Name, res.Size, res.Type); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
 	fileSizeStr := strconv.FormatInt(res.Size, 10)
 
 	w.Header().Set("Content-Length", fileSizeStr)
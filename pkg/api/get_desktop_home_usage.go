@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route GET /api/desktops/fs/{namespace}/{name}/usage Desktops getDesktopHomeUsage
+// Retrieve the storage usage of the user's home directory inside a desktop session.
+// Usage is only tracked when the VDICluster enforces a userdata quota, and is refreshed
+// by the desktop every 30 seconds.
+// responses:
+//   200: homeUsageResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) GetDesktopHomeUsage(w http.ResponseWriter, r *http.Request) {
+	proxy, err := d.getProxyClientForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	res, err := proxy.HomeUsage()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer res.Close()
+	if _, err := io.Copy(w, res); err != nil {
+		apiLogger.Error(err, "Error copying proxy response to client")
+	}
+}
+
+// Home directory usage response
+// swagger:response homeUsageResponse
+type swaggerHomeUsageResponse struct {
+	// in:body
+	Body types.HomeUsageResponse
+}
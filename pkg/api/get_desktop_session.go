@@ -32,6 +32,8 @@ import (
 
 	"golang.org/x/net/websocket"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -141,20 +143,42 @@ func (d *desktopAPI) GetDesktopSessionStatusWebsocket(conn *websocket.Conn) {
 }
 
 func (d *desktopAPI) getDesktopForRequest(r *http.Request) (*desktopsv1.Session, error) {
-	nn := apiutil.GetNamespacedNameFromRequest(r)
+	return d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+}
+
+// getClusterDesktop retrieves the given desktop session. Sessions belonging to a
+// different VDICluster are reported as not found, so one tenant cannot see or reach
+// another tenant's desktops by guessing their names.
+func (d *desktopAPI) getClusterDesktop(nn ktypes.NamespacedName) (*desktopsv1.Session, error) {
 	found := &desktopsv1.Session{}
-	return found, d.client.Get(context.TODO(), nn, found)
+	if err := d.client.Get(context.TODO(), nn, found); err != nil {
+		return found, err
+	}
+	if found.Spec.VDICluster != d.vdiCluster.GetName() {
+		return found, apierrors.NewNotFound(desktopsv1.GroupVersion.WithResource("sessions").GroupResource(), nn.Name)
+	}
+	return found, nil
 }
 
 type desktopStatus struct {
-	Running  bool            `json:"running"`
-	PodPhase corev1.PodPhase `json:"podPhase"`
+	Running         bool                           `json:"running"`
+	PodPhase        corev1.PodPhase                `json:"podPhase"`
+	Booting         bool                           `json:"booting,omitempty"`
+	LicensePending  string                         `json:"licensePending,omitempty"`
+	SchedulingError string                         `json:"schedulingError,omitempty"`
+	Agent           *desktopsv1.AgentStatus        `json:"agent,omitempty"`
+	Monitors        []desktopsv1.DisplayResolution `json:"monitors,omitempty"`
 }
 
 func toReturnStatus(desktop *desktopsv1.Session) *desktopStatus {
 	return &desktopStatus{
-		Running:  desktop.Status.Running,
-		PodPhase: desktop.Status.PodPhase,
+		Running:         desktop.Status.Running,
+		PodPhase:        desktop.Status.PodPhase,
+		Booting:         desktop.Status.Booting,
+		LicensePending:  desktop.Status.LicensePending,
+		SchedulingError: desktop.Status.SchedulingError,
+		Agent:           desktop.Status.Agent,
+		Monitors:        desktop.Status.Monitors,
 	}
 }
 
@@ -23,6 +23,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
@@ -87,6 +88,7 @@ func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request)
 			User:           desktop.GetUser(),
 			ServiceAccount: desktop.GetServiceAccount(),
 			Template:       desktop.GetTemplateName(),
+			Ticket:         desktop.Spec.Ticket,
 			Status:         getSessionStatus(d.vdiCluster, desktop, displayLocks.Items, audioLocks.Items),
 		}
 		res.Sessions = append(res.Sessions, sess)
@@ -101,8 +103,24 @@ func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request)
 // could also be optimized to pop found locks off for future iterations.
 func getSessionStatus(cluster *appv1.VDICluster, desktop desktopsv1.Session, displayLocks, audioLocks []corev1.ConfigMap) *types.DesktopSessionStatus {
 	status := &types.DesktopSessionStatus{
-		Display: &types.ConnectionStatus{Connected: false},
-		Audio:   &types.ConnectionStatus{Connected: false},
+		Display:            &types.ConnectionStatus{Connected: false},
+		Audio:              &types.ConnectionStatus{Connected: false},
+		MaintenancePending: desktop.Status.MaintenancePending,
+		Booting:            desktop.Status.Booting,
+	}
+	if expiresAt := desktop.Status.ExpiresAt; !expiresAt.IsZero() {
+		status.ExpiresAt = expiresAt.Unix()
+		if remaining := time.Until(expiresAt.Time); remaining > 0 {
+			status.RemainingSeconds = int64(remaining.Seconds())
+		}
+	}
+	if lastInput := desktop.Status.LastInputTime; !lastInput.IsZero() {
+		if idle := time.Since(lastInput.Time); idle > 0 {
+			status.IdleSeconds = int64(idle.Seconds())
+		}
+	}
+	if terminatesAt := desktop.Status.IdleTerminationTime; !terminatesAt.IsZero() {
+		status.IdleTerminatesAt = terminatesAt.Unix()
 	}
 	displayLockName := fmt.Sprintf("display-%s-%s", desktop.GetNamespace(), desktop.GetName())
 	audioLockName := fmt.Sprintf("audio-%s-%s", desktop.GetNamespace(), desktop.GetName())
@@ -0,0 +1,168 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/directory/search Users getDirectorySearch
+// ---
+// summary: Search the user directory for users and groups.
+// description: |
+//   Searches the directory backing the authentication provider, so principals can be
+//   found before they have ever logged in to kVDI. LDAP servers are searched directly.
+//   Other providers are searched through the users they know about and the groups
+//   bound to roles.
+// parameters:
+// - name: q
+//   in: query
+//   description: The text to search for in the names of users and groups
+//   type: string
+//   required: true
+// - name: kind
+//   in: query
+//   description: Restrict the search to `user` or `group` entries
+//   type: string
+// - name: limit
+//   in: query
+//   description: The maximum number of results to return (default 25, max 100)
+//   type: integer
+// responses:
+//   "200":
+//     "$ref": "#/responses/directorySearchResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetDirectorySearch(w http.ResponseWriter, r *http.Request) {
+	req, err := getDirectorySearchRequest(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	var entries []*types.DirectoryEntry
+	if directory, ok := d.auth.(common.DirectoryProvider); ok {
+		entries, err = directory.SearchDirectory(req)
+	} else {
+		entries, err = d.searchKnownPrincipals(req)
+	}
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind == types.DirectoryEntryUser
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	resp := &types.DirectorySearchResponse{Results: entries}
+	if limit := req.GetLimit(); len(entries) > limit {
+		resp.Results = entries[:limit]
+		resp.Truncated = true
+	}
+	apiutil.WriteJSON(resp, w)
+}
+
+// getDirectorySearchRequest builds a directory search from the query of a request.
+func getDirectorySearchRequest(r *http.Request) (*types.DirectorySearchRequest, error) {
+	query := r.URL.Query()
+	req := &types.DirectorySearchRequest{
+		Query: query.Get("q"),
+		Kind:  types.DirectoryEntryKind(query.Get("kind")),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.New("The limit must be a number")
+		}
+	}
+	return req, req.Validate()
+}
+
+// searchKnownPrincipals searches the users returned by the auth provider and the groups
+// bound to roles, for providers that can't search their directory directly. Providers
+// that can't list users only have their groups searched, unless users were explicitly
+// requested.
+func (d *desktopAPI) searchKnownPrincipals(req *types.DirectorySearchRequest) ([]*types.DirectoryEntry, error) {
+	entries := make([]*types.DirectoryEntry, 0)
+
+	if req.IncludesUsers() {
+		users, err := d.auth.GetUsers()
+		if err != nil && req.Kind == types.DirectoryEntryUser {
+			return nil, err
+		}
+		for _, user := range users {
+			if req.Matches(user.Name) {
+				entries = append(entries, &types.DirectoryEntry{
+					Kind: types.DirectoryEntryUser,
+					Name: user.Name,
+				})
+			}
+		}
+	}
+
+	if req.IncludesGroups() {
+		roles, err := d.getRoles()
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]struct{})
+		for _, role := range roles {
+			annotations := role.GetAnnotations()
+			for _, annotation := range []string{v1.LDAPGroupRoleAnnotation, v1.OIDCGroupRoleAnnotation} {
+				for _, group := range strings.Split(annotations[annotation], v1.AuthGroupSeparator) {
+					if group == "" || !req.Matches(group) {
+						continue
+					}
+					if _, ok := seen[group]; ok {
+						continue
+					}
+					seen[group] = struct{}{}
+					entries = append(entries, &types.DirectoryEntry{
+						Kind: types.DirectoryEntryGroup,
+						Name: group,
+					})
+				}
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// The users and groups matching a directory search
+// swagger:response directorySearchResponse
+type swaggerDirectorySearchResponse struct {
+	// in:body
+	Body types.DirectorySearchResponse
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
+)
+
+// swagger:route GET /api/faults Miscellaneous getFaults
+// Retrieves the faults currently injected into the server. This route is only served
+// by servers built with fault injection enabled.
+// responses:
+//   200: faultsResponse
+//   403: error
+//   404: error
+func (d *desktopAPI) GetFaults(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(faults.Status(), w)
+}
+
+// Injected faults response
+// swagger:response faultsResponse
+type swaggerFaultsResponse struct {
+	// in:body
+	Body types.FaultInjectionStatus
+}
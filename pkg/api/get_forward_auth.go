@@ -0,0 +1,138 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	util "github.com/tinyzimmer/kvdi/pkg/util/common"
+)
+
+// Headers returned by the forward-auth endpoint to identify the user to the
+// upstream application.
+const (
+	// ForwardAuthUserHeader contains the name of the authenticated user.
+	ForwardAuthUserHeader = "X-Auth-Request-User"
+	// ForwardAuthRolesHeader contains a comma-separated list of the user's roles.
+	ForwardAuthRolesHeader = "X-Auth-Request-Groups"
+)
+
+// swagger:operation GET /api/forward-auth Miscellaneous forwardAuth
+// ---
+// summary: Validates a kVDI session for a reverse proxy.
+// description: |
+//   Compatible with traefik's ForwardAuth middleware and nginx's auth_request directive.
+//   The access token is read from the X-Session-Token header or a Bearer Authorization header.
+//   Tokens in the query of the original request are not accepted, since they would end up in
+//   the access logs of the proxy and the upstream application. Users outside of the access
+//   hours of their roles are not let through. When the session is valid, the user's identity
+//   is returned in the X-Auth-Request-User and X-Auth-Request-Groups headers.
+// parameters:
+// - name: role
+//   in: query
+//   description: When set, the user must be bound to this role to be allowed through.
+//   type: string
+//   required: false
+// responses:
+//   "200":
+//     description: The session is valid.
+//   "401":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "500":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetForwardAuth(w http.ResponseWriter, r *http.Request) {
+	authToken := getForwardAuthToken(r)
+	if authToken == "" {
+		apiutil.ReturnAPIUnauthorized(nil, "No token provided in request", w)
+		return
+	}
+
+	// retrieve the jwt secret
+	jwtSecret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// verify the token and retrieve the claims
+	session, err := apiutil.DecodeAndVerifyJWT(jwtSecret, authToken)
+	if err != nil {
+		apiutil.ReturnAPIUnauthorized(nil, err.Error(), w)
+		return
+	}
+
+	// make sure the token has not been revoked
+	if revoked, err := d.tokenIsRevoked(session); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	} else if revoked {
+		apiutil.ReturnAPIUnauthorized(nil, "The token has been revoked", w)
+		return
+	}
+
+	// sessions still waiting on MFA are not let through
+	if !session.Authorized {
+		apiutil.ReturnAPIUnauthorized(nil, "User session is not authorized", w)
+		return
+	}
+
+	// users required to be on a trusted device must be on one for proxied apps as well
+	if !d.deviceTrusted(session, r) {
+		apiutil.ReturnAPIForbidden(nil, "A trusted device is required to access this application", w)
+		return
+	}
+
+	// proxied apps are subject to the same access hours as desktops
+	if !accessAllowed(session.User, session.GetAccessOverrideExpiresAt()) {
+		apiutil.ReturnAPIForbidden(nil, "Applications may not be accessed outside of the access hours of your roles", w)
+		return
+	}
+
+	roles := make([]string, len(session.User.Roles))
+	for idx, role := range session.User.Roles {
+		roles[idx] = role.Name
+	}
+
+	if required := r.URL.Query().Get("role"); required != "" && !util.StringSliceContains(roles, required) {
+		apiutil.ReturnAPIForbidden(nil, "User is not bound to the required role", w)
+		return
+	}
+
+	w.Header().Set(ForwardAuthUserHeader, session.User.Name)
+	w.Header().Set(ForwardAuthRolesHeader, strings.Join(roles, ","))
+	w.WriteHeader(http.StatusOK)
+}
+
+// getForwardAuthToken retrieves the access token from the headers of a forward-auth
+// request. Reverse proxies pass the headers of the original request.
+func getForwardAuthToken(r *http.Request) string {
+	if token := r.Header.Get(TokenHeader); token != "" {
+		return token
+	}
+	if authz := r.Header.Get("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimPrefix(authz, "Bearer ")
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/gateways Gateways getGateways
+// Retrieves the gateways clients may connect to desktops through, and the one currently selected for the user.
+// responses:
+//   200: gatewaysResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetGateways(w http.ResponseWriter, r *http.Request) {
+	user := apiutil.GetRequestUserSession(r).User
+	gateways := d.getGateways()
+	apiutil.WriteJSON(&types.GatewaysResponse{
+		Gateways: gateways,
+		Selected: toGatewaySelection(gateways, d.gateways.Selected(user.GetName(), gatewayNames(gateways))),
+	}, w)
+}
+
+// swagger:route GET /api/gateways/probe Gateways getGatewayProbe
+// An empty response for clients to measure their latency to a gateway. It does not require authentication and may be requested from any origin.
+// responses:
+//   200: boolResponse
+func (d *desktopAPI) GetGatewayProbe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "no-store")
+	apiutil.WriteOK(w)
+}
+
+// Available gateways response
+// swagger:response gatewaysResponse
+type swaggerGatewaysResponse struct {
+	// in:body
+	Body types.GatewaysResponse
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// labThumbnailWidth is the width of the thumbnails returned for lab sessions.
+const labThumbnailWidth = 320
+
+// swagger:operation GET /api/labs/{namespace}/{name}/thumbnails/{user} Labs getLabThumbnail
+// ---
+// summary: Retrieve a low-resolution image of the display of a user's session in the lab.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// - name: user
+//   in: path
+//   description: The user whose session to capture
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     content:
+//       "image/png":
+//         type: string
+//         format: binary
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetLabThumbnail(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	lab, err := d.getLabForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	user := apiutil.GetUserFromRequest(r)
+	name := lab.GetSessionForUser(user)
+	if name == "" {
+		apiutil.ReturnAPINotFound(fmt.Errorf("%s does not have a session in this lab", user), w)
+		return
+	}
+	proxy, err := d.getLabSessionProxy(lab, name)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	img, err := proxy.Screenshot(&proxyproto.ScreenshotRequest{MaxWidth: labThumbnailWidth})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer img.Close()
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, img); err != nil {
+		apiLogger.Error(err, "Failed to copy thumbnail to response buffer")
+	}
+}
+
+// swagger:operation GET /api/labs/{namespace}/{name}/collect/{fpath} Labs collectLabFiles
+// ---
+// summary: Collect the given path from the home directory of every session in the lab.
+// description: The response is a tar archive containing a directory for each user. Directories are collected as gzipped tarballs.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// - name: fpath
+//   in: path
+//   description: The path to collect, relative to each user's home directory
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     content:
+//       "application/x-tar":
+//         type: string
+//         format: binary
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetLabFiles(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	lab, err := d.getLabForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	path := getPathFromRequest(r)
+	if strings.Trim(path, "/") == "" {
+		apiutil.ReturnAPIError(errors.New("A path to collect is required"), w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.tar", lab.GetName(), time.Now().UTC().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	tw := tar.NewWriter(w)
+	defer func() {
+		if err := tw.Close(); err != nil {
+			apiLogger.Error(err, "Failed to finish lab file archive")
+		}
+	}()
+
+	failures := make([]string, 0)
+	for _, sess := range lab.Status.Sessions {
+		if err := d.collectLabSessionFile(tw, lab, sess, path); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", sess.User, err.Error()))
+		}
+	}
+
+	// record any sessions that could not be collected from in the archive itself,
+	// since the response status has already been written
+	if len(failures) > 0 {
+		body := strings.Join(failures, "\n") + "\n"
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    "errors.txt",
+			Mode:    0644,
+			Size:    int64(len(body)),
+			ModTime: time.Now(),
+		}); err != nil {
+			apiLogger.Error(err, "Failed to write errors to lab file archive")
+			return
+		}
+		if _, err := io.WriteString(tw, body); err != nil {
+			apiLogger.Error(err, "Failed to write errors to lab file archive")
+		}
+	}
+}
+
+// collectLabSessionFile retrieves the given path from a session in the lab and writes
+// it to the archive under a directory for the user.
+func (d *desktopAPI) collectLabSessionFile(tw *tar.Writer, lab *desktopsv1.Lab, sess desktopsv1.LabSession, path string) error {
+	proxy, err := d.getLabSessionProxy(lab, sess.Name)
+	if err != nil {
+		return err
+	}
+	res, err := proxy.GetFile(&proxyproto.FGetRequest{Path: path})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    fmt.Sprintf("%s/%s", sess.User, res.Name),
+		Mode:    0644,
+		Size:    res.Size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, res.Body)
+	return err
+}
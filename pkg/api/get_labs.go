@@ -0,0 +1,110 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route GET /api/labs Labs getLabs
+// Retrieves the labs the requesting user is an instructor for.
+// responses:
+//   200: labsResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetLabs(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+	labList := &desktopsv1.LabList{}
+	if err := d.client.List(context.TODO(), labList, client.InNamespace(metav1.NamespaceAll)); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	manageAll := canManageLabs(sess.User)
+	labs := make([]desktopsv1.Lab, 0)
+	for _, lab := range labList.Items {
+		if lab.Spec.VDICluster != d.vdiCluster.GetName() {
+			continue
+		}
+		if manageAll || lab.IsInstructor(sess.User.GetName()) {
+			labs = append(labs, lab)
+		}
+	}
+	apiutil.WriteJSON(labs, w)
+}
+
+// Labs response
+// swagger:response labsResponse
+type swaggerLabsResponse struct {
+	// in:body
+	Body []desktopsv1.Lab
+}
+
+// swagger:operation GET /api/labs/{namespace}/{name} Labs getLab
+// ---
+// summary: Retrieve the specified lab and the sessions provisioned for its roster.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/labResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetLab(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	lab, err := d.getLabForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(lab, w)
+}
+
+// Lab response
+// swagger:response labResponse
+type swaggerLabResponse struct {
+	// in:body
+	Body desktopsv1.Lab
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/licenses"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/licenses Templates getLicenseUsage
+// Retrieve the seats held, queued for, and available in each license pool.
+// responses:
+//   200: licenseUsageResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetLicenseUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := licenses.New(d.client, d.vdiCluster, d.secrets).Usage(context.TODO())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&types.LicenseUsageResponse{Pools: usage}, w)
+}
+
+// License usage response
+// swagger:response licenseUsageResponse
+type swaggerLicenseUsageResponse struct {
+	// in:body
+	Body types.LicenseUsageResponse
+}
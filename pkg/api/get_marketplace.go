@@ -0,0 +1,94 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/marketplace Templates getMarketplace
+// Retrieve the templates available from the template indexes the cluster subscribes to.
+// Indexes that cannot be retrieved or fail signature verification are reported in the errors
+// of the response.
+// responses:
+//   200: marketplaceResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetMarketplace(w http.ResponseWriter, r *http.Request) {
+	installed := &desktopsv1.TemplateList{}
+	if err := d.client.List(context.TODO(), installed); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	resp := &types.MarketplaceResponse{
+		Templates: make([]*types.MarketplaceTemplate, 0),
+		Errors:    make(map[string]string),
+	}
+	for _, idx := range d.vdiCluster.GetTemplateIndexes() {
+		index, err := d.marketplace.FetchIndex(idx)
+		if err != nil {
+			apiLogger.Error(err, "Failed to retrieve template index", "Index", idx.Name)
+			resp.Errors[idx.Name] = err.Error()
+			continue
+		}
+		for _, entry := range index.Templates {
+			tmpl := &types.MarketplaceTemplate{
+				Index:       idx.Name,
+				Name:        entry.Name,
+				Version:     entry.Version,
+				Description: entry.Description,
+				Tags:        entry.Tags,
+			}
+			if existing := findMarketplaceTemplate(installed, idx.Name, entry.Name); existing != nil {
+				tmpl.InstalledVersion = existing.GetAnnotations()[v1.MarketplaceVersionAnnotation]
+				tmpl.UpdateAvailable = tmpl.InstalledVersion != entry.Version
+			}
+			resp.Templates = append(resp.Templates, tmpl)
+		}
+	}
+
+	apiutil.WriteJSON(resp, w)
+}
+
+// findMarketplaceTemplate returns the template in the list with the given name if it was
+// installed from the given index.
+func findMarketplaceTemplate(list *desktopsv1.TemplateList, index, name string) *desktopsv1.Template {
+	for _, tmpl := range list.Items {
+		if tmpl.GetName() == name && tmpl.GetAnnotations()[v1.MarketplaceIndexAnnotation] == index {
+			out := tmpl
+			return &out
+		}
+	}
+	return nil
+}
+
+// Marketplace response
+// swagger:response marketplaceResponse
+type swaggerMarketplaceResponse struct {
+	// in:body
+	Body types.MarketplaceResponse
+}
@@ -23,10 +23,14 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
+	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 
 	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // swagger:route GET /api/namespaces Miscellaneous getNamespaces
@@ -42,12 +46,18 @@ func (d *desktopAPI) GetNamespaces(w http.ResponseWriter, r *http.Request) {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	rbac.FilterUserNamespaces(sess.User, namespaces)
-	apiutil.WriteJSON(rbac.FilterUserNamespaces(sess.User, namespaces), w)
+	nsLabels := make(map[string]map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		if nsLabels[ns], err = d.getNamespaceLabels(ns); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+	apiutil.WriteJSON(rbac.FilterUserNamespaces(sess.User, namespaces, nsLabels), w)
 }
 
 // ListKubernetesNamespaces returns a string slice of all the namespaces
-// in kubernetes.
+// in kubernetes that sessions of this cluster may run in.
 func (d *desktopAPI) ListKubernetesNamespaces() ([]string, error) {
 	nsList := &corev1.NamespaceList{}
 	if err := d.client.List(context.TODO(), nsList); err != nil {
@@ -57,7 +67,31 @@ func (d *desktopAPI) ListKubernetesNamespaces() ([]string, error) {
 	for _, ns := range nsList.Items {
 		nsNames = append(nsNames, ns.GetName())
 	}
-	return nsNames, nil
+	return tenancy.FilterNamespaces(context.TODO(), d.client, d.vdiCluster, nsNames)
+}
+
+// getNamespaceLabels returns the labels of the given namespace from the namespace cache.
+// Namespaces that don't exist have no labels.
+func (d *desktopAPI) getNamespaceLabels(name string) (map[string]string, error) {
+	reader := d.namespaces
+	if reader == nil {
+		reader = d.client
+	}
+	ns := &corev1.Namespace{}
+	if err := reader.Get(context.TODO(), ktypes.NamespacedName{Name: name}, ns); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return ns.GetLabels(), nil
+}
+
+// populateNamespaceLabels sets the labels of the namespace targeted by the given action,
+// so rules with namespace selectors can be evaluated against it.
+func (d *desktopAPI) populateNamespaceLabels(action *types.APIAction) (err error) {
+	if action.ResourceNamespace == "" {
+		return nil
+	}
+	action.ResourceNamespaceLabels, err = d.getNamespaceLabels(action.ResourceNamespace)
+	return err
 }
 
 // Namespaces response
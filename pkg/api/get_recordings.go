@@ -0,0 +1,162 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/recordings Recordings getRecordings
+// ---
+// summary: Retrieve the recordings of desktop sessions.
+// description: Recordings are read from the object storage configured on the VDICluster, most recent first. Sessions are only recorded when booted from templates with recording enabled.
+// parameters:
+// - name: user
+//   in: query
+//   description: Only return recordings of sessions owned by this user
+//   type: string
+// - name: namespace
+//   in: query
+//   description: Only return recordings of sessions in this namespace
+//   type: string
+// - name: session
+//   in: query
+//   description: Only return recordings of sessions with this name
+//   type: string
+// responses:
+//   "200":
+//     "$ref": "#/responses/recordingsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRecordings(w http.ResponseWriter, r *http.Request) {
+	recs, err := d.recordings.List(r.Context())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	params := r.URL.Query()
+	out := make([]*types.Recording, 0, len(recs))
+	for _, rec := range recs {
+		if user := params.Get("user"); user != "" && rec.User != user {
+			continue
+		}
+		if namespace := params.Get("namespace"); namespace != "" && rec.Namespace != namespace {
+			continue
+		}
+		if session := params.Get("session"); session != "" && rec.Session != session {
+			continue
+		}
+		out = append(out, rec)
+	}
+	apiutil.WriteJSON(&types.RecordingsResponse{Recordings: out}, w)
+}
+
+// swagger:operation GET /api/recordings/{recording} Recordings getRecording
+// ---
+// summary: Retrieve a single recording of a desktop session.
+// parameters:
+// - name: recording
+//   in: path
+//   description: The ID of the recording
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/recordingResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRecording(w http.ResponseWriter, r *http.Request) {
+	rec, err := d.recordings.Get(r.Context(), apiutil.GetRecordingFromRequest(r))
+	if err != nil {
+		returnRecordingError(err, w)
+		return
+	}
+	apiutil.WriteJSON(rec, w)
+}
+
+// swagger:operation GET /api/recordings/{recording}/playback Recordings getRecordingPlayback
+// ---
+// summary: Stream the events of a recording of a desktop session.
+// description: Events are streamed in order as JSON lines, each with its offset in milliseconds from the start of the recording. Frame events carry a base64 encoded PNG image of the display, and connect, disconnect, and input events the user and client address they are for. Recordings in progress can be played back up to the most recently written segment.
+// parameters:
+// - name: recording
+//   in: path
+//   description: The ID of the recording
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     content:
+//       "application/x-ndjson":
+//         type: string
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRecordingPlayback(w http.ResponseWriter, r *http.Request) {
+	id := apiutil.GetRecordingFromRequest(r)
+	if _, err := d.recordings.Get(r.Context(), id); err != nil {
+		returnRecordingError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := d.recordings.Playback(r.Context(), id, w); err != nil && r.Context().Err() != context.Canceled {
+		apiLogger.Error(err, "Failed to stream recording", "Recording", id)
+	}
+}
+
+// returnRecordingError writes the given error from the recordings manager, as a 404 if
+// the recording does not exist.
+func returnRecordingError(err error, w http.ResponseWriter) {
+	if errors.IsRecordingNotFoundError(err) {
+		apiutil.ReturnAPINotFound(err, w)
+		return
+	}
+	apiutil.ReturnAPIError(err, w)
+}
+
+// Session recordings response
+// swagger:response recordingsResponse
+type swaggerRecordingsResponse struct {
+	// in:body
+	Body types.RecordingsResponse
+}
+
+// Session recording response
+// swagger:response recordingResponse
+type swaggerRecordingResponse struct {
+	// in:body
+	Body types.Recording
+}
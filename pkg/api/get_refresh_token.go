@@ -22,6 +22,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/devices"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -43,22 +44,30 @@ func (d *desktopAPI) GetRefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	refreshToken, err := r.Cookie(RefreshTokenCookie)
 	if err != nil {
+		recordAuthFailure(authFailureInvalidRefreshToken)
 		apiutil.ReturnAPIForbidden(err, "Could not retrieve a refresh token from the request", w)
 		return
 	}
 	if refreshToken == nil || refreshToken.Value == "" {
+		recordAuthFailure(authFailureInvalidRefreshToken)
 		apiutil.ReturnAPIForbidden(nil, "No refresh token was provided in the request", w)
 		return
 	}
 
-	username, err := d.lookupRefreshToken(refreshToken.Value)
+	record, err := d.lookupRefreshToken(refreshToken.Value)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
+	if idleTimeout := d.vdiCluster.GetIdleTimeout(); idleTimeout > 0 && record.IdleFor() > idleTimeout {
+		recordAuthFailure(authFailureSessionExpired)
+		apiutil.ReturnAPIForbidden(nil, "The session has expired due to inactivity, please log in again", w)
+		return
+	}
+
 	// retrieve the user from the auth provider
-	user, err := d.auth.GetUser(username)
+	user, err := d.auth.GetUser(record.User)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -66,5 +75,11 @@ func (d *desktopAPI) GetRefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	// return a new access and refresh token for the user
 	// TODO: Use state during a refresh?
-	d.returnNewJWT(w, &types.AuthResult{User: user}, true, "")
+	d.returnNewJWT(w, &types.AuthResult{
+		User:                    user,
+		SessionStart:            record.GetSessionStart(),
+		TrustedDevice:           d.verifyDevice(r),
+		Device:                  devices.FromRequest(r),
+		AccessOverrideExpiresAt: record.GetAccessOverrideExpiresAt(),
+	}, true, "")
 }
@@ -24,7 +24,9 @@ import (
 	"net/http"
 
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 )
 
 // swagger:route GET /api/roles Roles getRoles
@@ -34,7 +36,7 @@ import (
 //   400: error
 //   403: error
 func (d *desktopAPI) GetRoles(w http.ResponseWriter, r *http.Request) {
-	roles, err := d.vdiCluster.GetRoles(d.client)
+	roles, err := d.getRoles()
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -62,21 +64,77 @@ func (d *desktopAPI) GetRoles(w http.ResponseWriter, r *http.Request) {
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetRole(w http.ResponseWriter, r *http.Request) {
-	roles, err := d.vdiCluster.GetRoles(d.client)
+	roles, err := d.getRoles()
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 	roleName := apiutil.GetRoleFromRequest(r)
 	for _, role := range roles {
-		if role.GetName() == roleName {
-			apiutil.WriteJSON(role, w)
+		if role.GetName() != roleName {
+			continue
+		}
+		// serve the latest version of the role, so it can be updated against
+		current, err := d.lookupRole(roleName)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
 			return
 		}
+		if current == nil {
+			break
+		}
+		apiutil.SetETag(w, current.GetResourceVersion())
+		apiutil.WriteJSON(current.Trim(), w)
+		return
 	}
 	apiutil.ReturnAPINotFound(fmt.Errorf("No role with the name '%s' found", roleName), w)
 }
 
+// swagger:operation GET /api/roles/{role}/effective Roles getRoleEffectiveRules
+// ---
+// summary: Retrieve the effective rules of the specified role.
+// description: |
+//   The effective rules are the rules defined on the role followed by the rules it inherits,
+//   directly or transitively, from the roles in its `inheritsFrom`. They are resolved at the
+//   time of the request.
+// parameters:
+// - name: role
+//   in: path
+//   description: The role to retrieve the effective rules for
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/effectiveRulesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRoleEffectiveRules(w http.ResponseWriter, r *http.Request) {
+	roles, err := d.getRoles()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	roleName := apiutil.GetRoleFromRequest(r)
+	role := getRoleByName(roles, roleName)
+	if role == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("No role with the name '%s' found", roleName), w)
+		return
+	}
+	inheritance := rbac.ResolveInheritance(role, roles)
+	rules := make([]rbacv1.Rule, 0, len(role.GetRules())+len(inheritance.Rules))
+	rules = append(rules, role.GetRules()...)
+	apiutil.WriteJSON(&types.EffectiveRulesResponse{
+		Name:           role.GetName(),
+		Rules:          append(rules, inheritance.Rules...),
+		InheritedRoles: inheritance.Roles,
+		MissingRoles:   inheritance.Missing,
+	}, w)
+}
+
 // A list of roles
 // swagger:response rolesResponse
 type swaggerRolesResponse struct {
@@ -90,3 +148,10 @@ type swaggerRoleResponse struct {
 	// in:body
 	Body rbacv1.VDIRole
 }
+
+// The effective rules of a role
+// swagger:response effectiveRulesResponse
+type swaggerEffectiveRulesResponse struct {
+	// in:body
+	Body types.EffectiveRulesResponse
+}
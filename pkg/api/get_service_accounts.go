@@ -54,7 +54,12 @@ func (d *desktopAPI) GetServiceAccounts(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(rbac.FilterUserServiceAccounts(sess.User, serviceAccounts, namespace), w)
+	nsLabels, err := d.getNamespaceLabels(namespace)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(rbac.FilterUserServiceAccounts(sess.User, serviceAccounts, namespace, nsLabels), w)
 }
 
 // ListServiceAccounts returns a string slice of all the service accounts
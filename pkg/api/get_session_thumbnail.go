@@ -0,0 +1,88 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/thumbnail Sessions getSessionThumbnail
+// ---
+// summary: Retrieve the most recent thumbnail captured of a desktop session's display.
+// description: Thumbnails are only captured for sessions booted from templates that enable them.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     content:
+//       "image/png":
+//         type: string
+//         format: binary
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetDesktopSessionThumbnail(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	proxy, err := d.getProxyClientForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	res, err := proxy.Thumbnail()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer res.Body.Close()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.FormatInt(res.Size, 10))
+	w.Header().Set("Last-Modified", time.Unix(res.CapturedAt, 0).UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, res.Body); err != nil {
+		apiLogger.Error(err, "Failed to copy thumbnail to response buffer")
+	}
+}
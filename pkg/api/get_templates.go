@@ -21,9 +21,12 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 
@@ -33,7 +36,7 @@ import (
 )
 
 // swagger:route GET /api/templates Templates getTemplates
-// Retrieves available templates to boot desktops from.
+// Retrieves available templates to boot desktops from. Deprecated templates are listed last.
 // responses:
 //   200: templatesResponse
 //   400: error
@@ -45,7 +48,12 @@ func (d *desktopAPI) GetDesktopTemplates(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(rbac.FilterTemplates(sess.User, tmpls.Trim()), w)
+	filtered := rbac.FilterTemplates(sess.User, tenancy.FilterTemplates(d.vdiCluster, tmpls.Trim()))
+	// list deprecated templates after the rest of the catalog
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return !filtered[i].IsDeprecated() && filtered[j].IsDeprecated()
+	})
+	apiutil.WriteJSON(filtered, w)
 }
 
 // getAllDesktopTemplates lists the DesktopTemplates registered in the api servers.
@@ -84,6 +92,16 @@ func (d *desktopAPI) GetDesktopTemplate(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if err := tenancy.CheckTemplate(d.vdiCluster, tmpl); err != nil {
+		apiutil.ReturnAPINotFound(err, w)
+		return
+	}
+	// drafts are hidden from everyone but their author and reviewers
+	if tmpl.IsDraft() && !rbac.CanAccessDraft(apiutil.GetRequestUserSession(r).User, tmpl) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The template '%s' doesn't exist", tmplName), w)
+		return
+	}
+	apiutil.SetETag(w, tmpl.GetResourceVersion())
 	apiutil.WriteJSON(tmpl.Trim(), w)
 }
 
@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/users/{user}/devices Users getUserDevicesRequest
+// ---
+// summary: Retrieves the devices and browsers the given user has signed in from.
+// description: Devices are identified by the X-Device-Fingerprint header presented at login, or by their user agent when none is presented, and named by the X-Device-Name header. Devices that are signed in count towards the device limit in the quotas of the user's roles. The device making the request is marked as current.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserDevicesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserDevices(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	list, err := d.userDevices.List(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if session := apiutil.GetRequestUserSession(r); session.User.GetName() == username && session.Device != "" {
+		if device := list.Get(session.Device); device != nil {
+			device.Current = true
+		}
+	}
+	apiutil.WriteJSON(list, w)
+}
+
+// User devices response
+// swagger:response getUserDevicesResponse
+type swaggerGetUserDevicesResponse struct {
+	// in:body
+	Body []types.UserDevice
+}
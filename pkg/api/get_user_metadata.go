@@ -0,0 +1,152 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/metadata Users getUserMetadataRequest
+// ---
+// summary: Retrieves all of the metadata stored for the given user.
+// description: The response maps each namespace to its keys and values.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserMetadataResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserMetadata(w http.ResponseWriter, r *http.Request) {
+	store, err := d.metadata.Get(apiutil.GetUserFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(store, w)
+}
+
+// swagger:operation GET /api/users/{user}/metadata/{namespace} Users getUserMetadataNamespaceRequest
+// ---
+// summary: Retrieves the keys and values in a namespace of the given user's metadata.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The metadata namespace to retrieve
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserMetadataNamespaceResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserMetadataNamespace(w http.ResponseWriter, r *http.Request) {
+	values, err := d.metadata.GetNamespace(apiutil.GetUserFromRequest(r), apiutil.GetNamespaceFromRequest(r))
+	if err != nil {
+		if errors.IsMetadataNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(values, w)
+}
+
+// swagger:operation GET /api/users/{user}/metadata/{namespace}/{key} Users getUserMetadataValueRequest
+// ---
+// summary: Retrieves a value from the given user's metadata.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The metadata namespace of the key
+//   type: string
+//   required: true
+// - name: key
+//   in: path
+//   description: The key to retrieve
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserMetadataValueResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserMetadataValue(w http.ResponseWriter, r *http.Request) {
+	value, err := d.metadata.GetValue(apiutil.GetUserFromRequest(r), apiutil.GetNamespaceFromRequest(r), apiutil.GetKeyFromRequest(r))
+	if err != nil {
+		if errors.IsMetadataNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&types.UserMetadataValue{Value: value}, w)
+}
+
+// All of a user's metadata
+// swagger:response getUserMetadataResponse
+type swaggerGetUserMetadataResponse struct {
+	// in:body
+	Body map[string]map[string]string
+}
+
+// The keys and values in a namespace of a user's metadata
+// swagger:response getUserMetadataNamespaceResponse
+type swaggerGetUserMetadataNamespaceResponse struct {
+	// in:body
+	Body map[string]string
+}
+
+// A value from a user's metadata
+// swagger:response getUserMetadataValueResponse
+type swaggerGetUserMetadataValueResponse struct {
+	// in:body
+	Body types.UserMetadataValue
+}
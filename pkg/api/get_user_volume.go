@@ -0,0 +1,75 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/users/{user}/volume Users getUserVolumeRequest
+// ---
+// summary: Retrieves the volume holding the $HOME directory of the specified user.
+// description: |
+//   Volumes are provisioned from the `userdataSpec` of the VDICluster the first time a user
+//   launches a desktop, and mounted in every desktop they launch after. The claim is only
+//   present while one of the user's desktops is running.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserVolumeResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserVolume(w http.ResponseWriter, r *http.Request) {
+	if d.vdiCluster.GetUserdataVolumeSpec() == nil {
+		apiutil.ReturnAPIError(errNoUserdataSpec, w)
+		return
+	}
+	username := apiutil.GetUserFromRequest(r)
+	pv, pvc, err := d.getUserVolume(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if pv == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("No volume has been provisioned for user '%s'", username), w)
+		return
+	}
+	apiutil.WriteJSON(d.newUserVolume(pv, pvc), w)
+}
+
+// User volume response
+// swagger:response getUserVolumeResponse
+type swaggerGetUserVolumeResponse struct {
+	// in:body
+	Body types.UserVolume
+}
@@ -103,6 +103,12 @@ func (d *desktopAPI) GetUser(w http.ResponseWriter, r *http.Request) {
 			Verified: verified,
 		}
 	}
+	version, err := d.getUserVersion(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.SetETag(w, version)
 	apiutil.WriteJSON(user, w)
 }
 
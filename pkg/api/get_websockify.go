@@ -22,14 +22,22 @@ package api
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/bufpool"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/gorilla/websocket"
@@ -37,8 +45,8 @@ import (
 
 // swagger:operation GET /api/desktops/ws/{namespace}/{name}/display Desktops doWebsocket
 // ---
-// summary: Start an mTLS noVNC connection with the provided Desktop.
-// description: Assumes the requesting client is a noVNC RFB object.
+// summary: Start an mTLS display connection with the provided Desktop.
+// description: Assumes the requesting client speaks the display protocol of the desktop's template, which defaults to noVNC. When the server ends the connection it sends a close frame with one of the reasons idle-timeout (4000), admin-terminated (4001), pod-evicted (4002), auth-expired (4003), node-lost (4004), or resume-expired (4005). When display resume is enabled on the VDICluster, clients may connect with a random resume ID. If the client drops, the connection to the desktop is held open and a client reconnecting with the same ID and the number of bytes it received as the offset receives the output it missed. Clients should generate a new ID for every new connection. When the desktop's template allows resizing the display, clients may send JSON text messages alongside the binary display stream to control it, e.g. {"type": "resize", "width": 1920, "height": 1080, "dpi": 96}.
 // parameters:
 // - name: namespace
 //   in: path
@@ -55,6 +63,26 @@ import (
 //   description: The X-Session-Token of the requesting client
 //   type: string
 //   required: true
+// - name: protocol
+//   in: query
+//   description: The display protocol the client speaks (novnc, spice, xpra, or rdp). RDP displays are served to Guacamole clients, which may request the guacamole websocket subprotocol instead. The connection is refused if the desktop serves a different protocol. May also be requested as a websocket subprotocol.
+//   type: string
+//   required: false
+// - name: resume
+//   in: query
+//   description: An ID of up to 64 characters for resuming the connection if the client drops
+//   type: string
+//   required: false
+// - name: offset
+//   in: query
+//   description: When resuming, the number of bytes of display output received before the client dropped
+//   type: integer
+//   required: false
+// - name: monitor
+//   in: query
+//   description: The index of the monitor to stream when the desktop spans multiple monitors. Defaults to the primary monitor, zero. Only the primary monitor is recorded.
+//   type: integer
+//   required: false
 // responses:
 //   "UPGRADE": {}
 //   "400":
@@ -64,12 +92,34 @@ import (
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetWebsockify(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+	if !d.checkLabLock(w, r) {
+		return
+	}
+	if !d.checkDisplayProtocol(w, r) {
+		return
+	}
+	monitor, err := requestedMonitor(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.detachResumingClient(r)
 	lockName := fmt.Sprintf(
 		"display-%s",
 		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
 	)
+	// Each monitor is streamed over its own connection
+	if monitor > 0 {
+		lockName = fmt.Sprintf("%s-%d", lockName, monitor)
+	}
 	labels := d.vdiCluster.GetComponentLabels("display-lock")
-	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
+	setClientAddrLabel(labels, apiutil.GetClientAddr(r))
 	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels)
 
 	if err := sessionLock.Acquire(); err != nil {
@@ -89,6 +139,7 @@ func (d *desktopAPI) GetWebsockify(w http.ResponseWriter, r *http.Request) {
 // swagger:operation GET /api/desktops/ws/{namespace}/{name}/audio Desktops doAudio
 // ---
 // summary: Retrieve the audio stream from the given desktop session.
+// description: Desktop audio is sent to the client as an Opus/WebM stream. Clients may send microphone audio, also as an Opus/WebM stream, which is fed into a virtual PulseAudio source in the desktop. Microphone audio is discarded unless the desktop's template enables the microphone, the user holds the use-microphone verb on the template, and the device policy of the user's roles allows audio input.
 // parameters:
 // - name: namespace
 //   in: path
@@ -114,12 +165,18 @@ func (d *desktopAPI) GetWebsockify(w http.ResponseWriter, r *http.Request) {
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetWebsockifyAudio(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
 	lockName := fmt.Sprintf(
 		"audio-%s",
 		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
 	)
 	labels := d.vdiCluster.GetComponentLabels("audio-lock")
-	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
+	setClientAddrLabel(labels, apiutil.GetClientAddr(r))
 	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels)
 
 	if err := sessionLock.Acquire(); err != nil {
@@ -136,15 +193,152 @@ func (d *desktopAPI) GetWebsockifyAudio(w http.ResponseWriter, r *http.Request)
 	d.ServeWebsocketProxy(w, r, proxyproto.RequestTypeAudio)
 }
 
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/clipboard Desktops doClipboard
+// ---
+// summary: Sync the clipboard of the given desktop session.
+// description: Clipboard contents are exchanged in both directions as binary messages containing a 4-byte big-endian length followed by that many bytes of UTF-8 text. Each direction must be allowed by the desktop's template, and the user must hold the use-clipboard verb on it. Updates in a direction that is not allowed are discarded.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyClipboard(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+	if !d.checkLabLock(w, r) {
+		return
+	}
+	d.ServeWebsocketProxy(w, r, proxyproto.RequestTypeClipboard)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/ssh Desktops doSSH
+// ---
+// summary: Start a bidirectional stream with the SSH server of the given desktop session.
+// description: The SSH gateway must be enabled on the VDICluster. Clients authenticate with a certificate from /api/sessions/{namespace}/{name}/ssh.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifySSH(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.SSHGatewayEnabled() {
+		apiutil.ReturnAPIError(errors.New("The SSH gateway is not enabled"), w)
+		return
+	}
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+	d.ServeWebsocketProxy(w, r, proxyproto.RequestTypeSSH)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/plugins/{plugin} Desktops doPlugin
+// ---
+// summary: Start a bidirectional stream with a sidecar plugin of the given desktop session.
+// description: The plugin must be declared in the proxy configuration of the session's template. The format of the stream is defined by the plugin.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: plugin
+//   in: path
+//   description: The name of the plugin
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyPlugin(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+	if !d.checkLabLock(w, r) {
+		return
+	}
+	d.ServeWebsocketProxy(w, r, proxyproto.RequestTypePlugin)
+}
+
 var upgrader = &websocket.Upgrader{
 	CheckOrigin:       func(r *http.Request) bool { return true },
 	EnableCompression: true,
-	Subprotocols:      []string{"binary"},
+	Subprotocols:      []string{"binary", "novnc", "spice", "xpra", "rdp", guacamoleSubprotocol},
 	ReadBufferSize:    v1.WebsocketReadBufferSize,
 	WriteBufferSize:   v1.WebsocketWriteBufferSize,
+	WriteBufferPool:   &sync.Pool{},
 }
 
 func (d *desktopAPI) ServeWebsocketProxy(w http.ResponseWriter, r *http.Request, rt proxyproto.RequestType) {
+	if rt == proxyproto.RequestTypeDisplay && d.vdiCluster.DisplayResumeEnabled() {
+		if id := getResumeID(r); id != "" {
+			d.serveResumableDisplay(w, r, id)
+			return
+		}
+	}
+
 	proxy, err := d.getProxyClientForRequest(r)
 	if err != nil {
 		if client.IgnoreNotFound(err) == nil {
@@ -160,9 +354,33 @@ func (d *desktopAPI) ServeWebsocketProxy(w http.ResponseWriter, r *http.Request,
 	var conn *proxyproto.Conn
 	switch rt {
 	case proxyproto.RequestTypeDisplay:
-		conn, err = proxy.DisplayProxy()
+		var display *proxyproto.DisplayRequest
+		if display, err = d.getDisplayRequest(r); err == nil {
+			conn, err = proxy.DisplayProxy(display)
+		}
 	case proxyproto.RequestTypeAudio:
-		conn, err = proxy.AudioProxy()
+		var desktop *desktopsv1.Session
+		var policy *types.SessionDevicePolicy
+		if desktop, err = d.getDesktopForRequest(r); err == nil {
+			if policy, err = d.getSessionDevicePolicy(r, desktop); err == nil {
+				conn, err = proxy.AudioProxy(&proxyproto.AudioRequest{
+					Playback: policy.AudioOut,
+					Capture:  policy.AudioIn,
+				})
+			}
+		}
+	case proxyproto.RequestTypeClipboard:
+		var clipboard *proxyproto.ClipboardRequest
+		if clipboard, err = d.getClipboardRequest(r); err == nil {
+			conn, err = proxy.ClipboardProxy(clipboard)
+		}
+	case proxyproto.RequestTypeSSH:
+		conn, err = proxy.SSHProxy()
+	case proxyproto.RequestTypePlugin:
+		conn, err = proxy.PluginProxy(&proxyproto.PluginRequest{
+			Name: apiutil.GetPluginFromRequest(r),
+			User: apiutil.GetRequestUserSession(r).User.Name,
+		})
 	}
 	if err != nil {
 		apiLogger.Error(err, "Error creating connection to proxy server")
@@ -180,25 +398,72 @@ func (d *desktopAPI) ServeWebsocketProxy(w http.ResponseWriter, r *http.Request,
 	defer wsconn.Close()
 
 	client := apiutil.NewGorillaReadWriter(wsconn)
+	if rt == proxyproto.RequestTypeDisplay {
+		client.WithTextHandler(d.displayControlHandler(r))
+	}
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	claims := apiutil.GetRequestUserSession(r)
 	ctx, cancel := context.WithCancel(context.Background())
 
+	tracked := d.connections.track(ctx, nn, rt, rbac.EffectiveBandwidthWeight(claims.User.Roles), claims.User.Name, apiutil.GetClientAddr(r), wsconn, apiutil.GetRequestConn(r))
+	defer d.connections.untrack(nn, tracked)
+	clientReader := newActivityReader(tracked.Reader(client))
+	// Only the primary monitor is recorded
+	if monitor, _ := requestedMonitor(r); rt == proxyproto.RequestTypeDisplay && monitor == 0 {
+		defer d.recordDisplay(nn, claims.User.Name, apiutil.GetClientAddr(r), clientReader)()
+	}
+
 	// Copy client connection to server
 	go func() {
 		defer cancel()
-		if _, err := io.Copy(conn, client); err != nil {
+		if _, err := bufpool.CopyFor(nn.String(), conn, clientReader); err != nil {
 			apiLogger.Error(err, "Error while copying stream from websocket connection to proxy")
 		}
 	}()
 
 	// Copy server connection to the client
+	var serverClosed int32
 	go func() {
 		defer cancel()
-		if _, err := io.Copy(client, conn); err != nil {
+		if _, err := bufpool.CopyFor(nn.String(), faultWriter(rt, tracked.Writer(client)), conn); err != nil {
 			apiLogger.Error(err, "Error while copying stream from proxy to websocket connection")
 		}
+		atomic.StoreInt32(&serverClosed, 1)
+	}()
+
+	// Watch for reasons to end the connection
+	var idleTimeout time.Duration
+	if rt == proxyproto.RequestTypeDisplay {
+		idleTimeout = d.vdiCluster.GetDisplayIdleTimeout()
+	}
+	reasonCh := make(chan types.DisconnectReason, 1)
+	go func() {
+		reason := d.watchConnection(ctx, claims, nn, clientReader, idleTimeout)
+		if reason != "" {
+			cancel()
+		}
+		reasonCh <- reason
 	}()
 
 	// block until the context is finished
 	for range ctx.Done() {
 	}
+
+	// When the desktop side dropped the connection, let the client know why if possible
+	reason := <-reasonCh
+	if reason == "" && atomic.LoadInt32(&serverClosed) == 1 {
+		reason = d.disconnectReason(context.Background(), claims, nn)
+	}
+	if reason != "" {
+		apiLogger.Info("Closing websocket connection", "Path", r.URL.Path, "Reason", reason)
+		closeWithReason(wsconn, reason)
+	}
+}
+
+// setClientAddrLabel records the given client address on the labels of a display or audio
+// lock. IPv6 addresses are not valid label values, and are left off.
+func setClientAddrLabel(labels map[string]string, clientAddr string) {
+	if len(validation.IsValidLabelValue(clientAddr)) == 0 {
+		labels[v1.ClientAddrLabel] = clientAddr
+	}
 }
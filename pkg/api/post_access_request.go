@@ -0,0 +1,134 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request containing a template access request
+// swagger:parameters postAccessRequest
+type swaggerCreateAccessRequest struct {
+	// in:body
+	Body types.CreateAccessRequest
+}
+
+// swagger:route POST /api/access_requests AccessRequests postAccessRequest
+// Requests access to launch a template the user can see but not launch. The request is
+// placed in a queue for an administrator to approve or deny.
+// responses:
+//   200: accessRequestResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostAccessRequest(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+	req := apiutil.GetRequestObject(r).(*types.CreateAccessRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	// users can only request access to templates they are allowed to see
+	if !rbac.EvaluateUser(sess.User, &types.APIAction{
+		Verb:         rbacv1.VerbRead,
+		ResourceType: rbacv1.ResourceTemplates,
+		ResourceName: req.GetTemplate(),
+	}) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("You are not allowed to view the template '%s'", req.GetTemplate()), w)
+		return
+	}
+
+	nn := ktypes.NamespacedName{Name: req.GetTemplate(), Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, &desktopsv1.Template{}); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The template '%s' doesn't exist", req.GetTemplate()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	allowed, err := d.evaluateUserAction(sess.User, &types.APIAction{
+		Verb:              rbacv1.VerbLaunch,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      req.GetTemplate(),
+		ResourceNamespace: req.GetNamespace(),
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if allowed {
+		apiutil.ReturnAPIError(fmt.Errorf("You are already allowed to launch '%s' in '%s'", req.GetTemplate(), req.GetNamespace()), w)
+		return
+	}
+
+	accessRequest := &types.AccessRequest{
+		ID:        uuid.New().String(),
+		User:      sess.User.GetName(),
+		Template:  req.GetTemplate(),
+		Namespace: req.GetNamespace(),
+		Reason:    req.Reason,
+		State:     types.AccessRequestPending,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	if err := d.updateAccessRequests(func(requests map[string]*types.AccessRequest) error {
+		// don't queue duplicate requests
+		for _, existing := range requests {
+			if existing.State == types.AccessRequestPending &&
+				existing.User == accessRequest.User &&
+				existing.Template == accessRequest.Template &&
+				existing.Namespace == accessRequest.Namespace {
+				accessRequest = existing
+				return nil
+			}
+		}
+		requests[accessRequest.ID] = accessRequest
+		return nil
+	}); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(accessRequest, w)
+}
+
+// Access request response
+// swagger:response accessRequestResponse
+type swaggerAccessRequestResponse struct {
+	// in:body
+	Body types.AccessRequest
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation POST /api/access_requests/{request}/approve AccessRequests approveAccessRequest
+// ---
+// summary: Approves a template access request and grants the user access to the template.
+// description: The user is bound to a role allowing them to launch and use the requested template.
+// parameters:
+// - name: request
+//   in: path
+//   description: The ID of the access request
+//   type: string
+//   required: true
+// - in: body
+//   name: reviewAccessRequest
+//   description: An optional comment to record with the decision.
+//   schema:
+//     "$ref": "#/definitions/ReviewAccessRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/accessRequestResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostAccessRequestApprove(w http.ResponseWriter, r *http.Request) {
+	d.reviewAccessRequest(w, r, types.AccessRequestApproved)
+}
+
+// swagger:operation POST /api/access_requests/{request}/deny AccessRequests denyAccessRequest
+// ---
+// summary: Denies a template access request.
+// parameters:
+// - name: request
+//   in: path
+//   description: The ID of the access request
+//   type: string
+//   required: true
+// - in: body
+//   name: reviewAccessRequest
+//   description: An optional comment to record with the decision.
+//   schema:
+//     "$ref": "#/definitions/ReviewAccessRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/accessRequestResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostAccessRequestDeny(w http.ResponseWriter, r *http.Request) {
+	d.reviewAccessRequest(w, r, types.AccessRequestDenied)
+}
+
+func (d *desktopAPI) reviewAccessRequest(w http.ResponseWriter, r *http.Request, state types.AccessRequestState) {
+	sess := apiutil.GetRequestUserSession(r)
+	id := apiutil.GetAccessRequestFromRequest(r)
+	review, ok := apiutil.GetRequestObject(r).(*types.ReviewAccessRequest)
+	if !ok || review == nil {
+		review = &types.ReviewAccessRequest{}
+	}
+
+	// The request is checked, granted, and recorded while holding the lock on the stored
+	// requests, so that concurrent reviews cannot both act on it. The grant is idempotent, so
+	// if recording fails the request can safely be approved again.
+	var reviewed *types.AccessRequest
+	var notFound bool
+	if err := d.updateAccessRequests(func(requests map[string]*types.AccessRequest) error {
+		stored, ok := requests[id]
+		if !ok {
+			notFound = true
+			return fmt.Errorf("The access request '%s' doesn't exist", id)
+		}
+		if stored.State != types.AccessRequestPending {
+			return fmt.Errorf("The access request '%s' has already been %s", id, strings.ToLower(string(stored.State)))
+		}
+		if state == types.AccessRequestApproved {
+			if err := d.grantTemplateAccess(stored); err != nil {
+				return err
+			}
+		}
+		stored.State = state
+		stored.ReviewedBy = sess.User.GetName()
+		stored.ReviewedAt = time.Now().Unix()
+		stored.Comment = review.Comment
+		reviewed = stored
+		return nil
+	}); err != nil {
+		if notFound {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(reviewed, w)
+}
+
+// Request containing a review comment
+// swagger:parameters approveAccessRequest denyAccessRequest
+type swaggerReviewAccessRequest struct {
+	// in:body
+	Body types.ReviewAccessRequest
+}
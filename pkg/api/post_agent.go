@@ -0,0 +1,144 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/agent/health Agent postAgentHealth
+// ---
+// summary: Reports the health of the apps inside a desktop session.
+// description: Only accepts the token issued to the kvdi-agent in the session, passed in the X-Kvdi-Agent-Token header.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - in: body
+//   name: postAgentHealthRequest
+//   description: The health of the apps in the session.
+//   schema:
+//     "$ref": "#/definitions/AgentHealthReport"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "401":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostAgentHealth(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.AgentHealthReport)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	apps := make([]desktopsv1.AgentAppStatus, len(req.Apps))
+	for idx, app := range req.Apps {
+		apps[idx] = desktopsv1.AgentAppStatus{
+			Name:    app.Name,
+			Healthy: app.Healthy,
+			Message: app.Message,
+		}
+	}
+	if err := d.updateAgentStatus(r, func(status *desktopsv1.AgentStatus) {
+		status.LastReportTime = metav1.Now()
+		status.Healthy = req.Healthy()
+		status.Apps = apps
+	}); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/agent/resolution Agent postAgentResolution
+// ---
+// summary: Requests a new display resolution for a desktop session.
+// description: |
+//   Only accepts the token issued to the kvdi-agent in the session, passed in the X-Kvdi-Agent-Token header.
+//   The request is recorded on the session status, and clients connected to the session resize their display to match.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - in: body
+//   name: postAgentResolutionRequest
+//   description: The requested resolution.
+//   schema:
+//     "$ref": "#/definitions/AgentResolutionRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "401":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostAgentResolution(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.AgentResolutionRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if err := d.updateAgentStatus(r, func(status *desktopsv1.AgentStatus) {
+		status.RequestedResolution = &desktopsv1.DisplayResolution{
+			Width:  req.Width,
+			Height: req.Height,
+		}
+	}); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// Request containing a health report from an agent
+// swagger:parameters postAgentHealthRequest
+type swaggerPostAgentHealthRequest struct {
+	// in:body
+	Body types.AgentHealthReport
+}
+
+// Request containing a resolution change from an agent
+// swagger:parameters postAgentResolutionRequest
+type swaggerPostAgentResolutionRequest struct {
+	// in:body
+	Body types.AgentResolutionRequest
+}
@@ -22,6 +22,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/devices"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -54,8 +55,11 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 		// The user does not require MFA - this shouldn't happen but go ahead
 		// and send back an authorized token
 		d.returnNewJWT(w, &types.AuthResult{
-			User:                userSession.User,
-			RefreshNotSupported: !userSession.Renewable,
+			User:                    userSession.User,
+			RefreshNotSupported:     !userSession.Renewable,
+			TrustedDevice:           userSession.TrustedDevice,
+			Device:                  devices.FromRequest(r),
+			AccessOverrideExpiresAt: userSession.GetAccessOverrideExpiresAt(),
 		}, true, req.GetState())
 		return
 	}
@@ -63,6 +67,7 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 	if !verified {
 		// The user has not verified their MFA secret yet.
 		// The login attempt should not have required MFA.
+		recordAuthFailure(authFailureInvalidMFA)
 		apiutil.ReturnAPIForbidden(nil, "MFA token has not been verified", w)
 		return
 	}
@@ -70,13 +75,17 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 	totp := gotp.NewDefaultTOTP(secret)
 
 	if totp.Now() != req.GetOTP() {
+		recordAuthFailure(authFailureInvalidMFA)
 		apiutil.ReturnAPIForbidden(nil, "Invalid MFA Code", w)
 		return
 	}
 
 	d.returnNewJWT(w, &types.AuthResult{
-		User:                userSession.User,
-		RefreshNotSupported: !userSession.Renewable,
+		User:                    userSession.User,
+		RefreshNotSupported:     !userSession.Renewable,
+		TrustedDevice:           userSession.TrustedDevice,
+		Device:                  devices.FromRequest(r),
+		AccessOverrideExpiresAt: userSession.GetAccessOverrideExpiresAt(),
 	}, true, req.GetState())
 }
 
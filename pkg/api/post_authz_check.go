@@ -0,0 +1,98 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// Request containing an action to check
+// swagger:parameters postAuthzCheckRequest
+type swaggerAuthzCheckRequest struct {
+	// in:body
+	Body types.AuthzCheckRequest
+}
+
+// swagger:route POST /api/authz/check Authorization postAuthzCheckRequest
+// Checks whether a user would be allowed to perform an action, and which rule decides it. Nothing is performed.
+// responses:
+//   200: authzCheckResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostAuthzCheck(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.AuthzCheckRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user, err := d.getAuthzCheckUser(r, req)
+	if err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	action := req.GetAction()
+	if err := d.populateAction(action); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	decision := rbac.ExplainUser(user, action)
+	apiutil.WriteJSON(&types.AuthzCheckResponse{
+		User:    user.GetName(),
+		Allowed: decision.Allowed,
+		Reason:  decision.Reason(),
+		Role:    decision.Role,
+		Rule:    decision.Rule,
+	}, w)
+}
+
+// getAuthzCheckUser returns the user to check an action for. The user making the request
+// is checked with the roles in their session, other users with their current roles.
+func (d *desktopAPI) getAuthzCheckUser(r *http.Request, req *types.AuthzCheckRequest) (*types.VDIUser, error) {
+	reqUser := apiutil.GetRequestUserSession(r).User
+	if req.User == "" || req.User == reqUser.GetName() {
+		return reqUser, nil
+	}
+	user, err := d.auth.GetUser(req.User)
+	if err != nil {
+		return nil, err
+	}
+	user.Attributes = req.Attributes
+	if err := d.applyTeamRoles(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// The result of an authorization check
+// swagger:response authzCheckResponse
+type swaggerAuthzCheckResponse struct {
+	// in:body
+	Body types.AuthzCheckResponse
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/backup"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Request containing the passphrase for a new backup
+// swagger:parameters postBackupRequest
+type swaggerBackupRequest struct {
+	// in:body
+	Body types.BackupRequest
+}
+
+// swagger:route POST /api/backup Miscellaneous postBackupRequest
+// Exports the state of the cluster to an encrypted archive. This includes the VDICluster
+// configuration, roles, teams, templates, and the long-lived data in the secrets backend.
+// responses:
+//   200: backupResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostBackup(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.BackupRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	archive, err := backup.Export(r.Context(), d.client, d.vdiCluster, d.secrets)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	data, err := archive.Encrypt(req.Passphrase)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Exported cluster backup", "User", apiutil.GetRequestUserSession(r).User.GetName())
+	apiutil.WriteJSON(&types.BackupResponse{
+		Archive:   data,
+		CreatedAt: archive.CreatedAt.Unix(),
+	}, w)
+}
+
+// Backup response
+// swagger:response backupResponse
+type swaggerBackupResponse struct {
+	// in:body
+	Body types.BackupResponse
+}
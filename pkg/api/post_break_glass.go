@@ -0,0 +1,155 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/auth/breakglass"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+)
+
+// swagger:route POST /api/break-glass/challenge Auth postBreakGlassChallenge
+// Retrieves a one-time challenge to sign with the break-glass key. The challenge must be
+// presented to /api/break-glass/unlock within five minutes. Each client may request five
+// challenges a minute.
+// responses:
+//   200: breakGlassChallengeResponse
+//   403: error
+//   429: error
+//   500: error
+func (d *desktopAPI) PostBreakGlassChallenge(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.BreakGlassIsEnabled() {
+		apiutil.ReturnAPIForbidden(nil, "The break-glass account is not enabled", w)
+		return
+	}
+	challenge, expiresAt, err := d.breakGlass.IssueChallenge(apiutil.GetClientAddr(r))
+	if err != nil {
+		if err == breakglass.ErrRateLimited || err == breakglass.ErrTooManyChallenges {
+			apiutil.ReturnAPIQuotaExceeded(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.auditBreakGlass(r, "challenge", "", nil)
+	apiutil.WriteJSON(&types.BreakGlassChallengeResponse{
+		Challenge: challenge,
+		ExpiresAt: expiresAt.Unix(),
+	}, w)
+}
+
+// swagger:route POST /api/break-glass/unlock Auth postBreakGlassUnlock
+// Presents a challenge signed with the break-glass key and retrieves a token for the
+// break-glass account. The token carries the cluster admin role and cannot be refreshed.
+// Every attempt is recorded in the audit log, whether or not auditing is enabled.
+// responses:
+//   200: sessionResponse
+//   400: error
+//   403: error
+//   500: error
+func (d *desktopAPI) PostBreakGlassUnlock(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.BreakGlassIsEnabled() {
+		apiutil.ReturnAPIForbidden(nil, "The break-glass account is not enabled", w)
+		return
+	}
+
+	req := apiutil.GetRequestObject(r).(*types.BreakGlassUnlockRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if req.Reason == "" {
+		apiutil.ReturnAPIError(errors.New("A reason is required to use the break-glass account"), w)
+		return
+	}
+
+	if err := d.verifyBreakGlass(req); err != nil {
+		d.auditBreakGlass(r, "unlock", req.Reason, err)
+		// The underlying error is in the audit log, don't tell the client which check failed.
+		recordAuthFailure(authFailureInvalidBreakGlass)
+		apiutil.ReturnAPIForbidden(err, "Invalid break-glass challenge or signature", w)
+		return
+	}
+	d.auditBreakGlass(r, "unlock", req.Reason, nil)
+
+	result := &types.AuthResult{
+		User: &types.VDIUser{
+			Name:  d.vdiCluster.GetBreakGlassUsername(),
+			Roles: []*types.VDIUserRole{rbac.VDIRoleToUserRole(d.vdiCluster.GetAdminRole())},
+		},
+		RefreshNotSupported: true,
+	}
+	d.returnNewJWT(w, result, true, req.State)
+}
+
+// verifyBreakGlass consumes the challenge in the request and checks its signature against
+// the configured public key.
+func (d *desktopAPI) verifyBreakGlass(req *types.BreakGlassUnlockRequest) error {
+	// Consume the challenge first so it can't be retried with another signature
+	if err := d.breakGlass.ConsumeChallenge(req.Challenge); err != nil {
+		return err
+	}
+	key, err := breakglass.ParsePublicKey(d.vdiCluster.GetBreakGlassPublicKey())
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil {
+		return err
+	}
+	return breakglass.Verify(key, req.Challenge, sig)
+}
+
+// auditBreakGlass records a break-glass action in the audit log. Unlike other audit events
+// these are always recorded, to stdout when the audit log is disabled. If err is not nil,
+// the action was denied.
+func (d *desktopAPI) auditBreakGlass(r *http.Request, action, reason string, err error) {
+	allowed := err == nil
+	user := d.vdiCluster.GetBreakGlassUsername()
+	ev := newAuditEvent(r, user, allowed, fmt.Sprintf("%s %s => break-glass %s", actions[allowed], user, action))
+	ev.Details = make(map[string]string)
+	if reason != "" {
+		ev.Details["BreakGlassReason"] = reason
+	}
+	if !allowed {
+		ev.Details["Reason"] = err.Error()
+	}
+	d.audit.Record(ev)
+}
+
+// Break-glass challenge response
+// swagger:response breakGlassChallengeResponse
+type swaggerBreakGlassChallengeResponse struct {
+	// in:body
+	Body types.BreakGlassChallengeResponse
+}
+
+// Break-glass unlock request
+// swagger:parameters postBreakGlassUnlock
+type swaggerBreakGlassUnlockRequest struct {
+	// in:body
+	Body types.BreakGlassUnlockRequest
+}
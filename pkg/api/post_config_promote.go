@@ -0,0 +1,68 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/staging"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Request to promote the staged VDICluster configuration
+// swagger:parameters postConfigPromoteRequest
+type swaggerPromoteConfigRequest struct {
+	// in:body
+	Body types.PromoteConfigRequest
+}
+
+// swagger:route POST /api/config/staged/promote Miscellaneous postConfigPromoteRequest
+// Promotes the staged revision of the VDICluster configuration. Unless forced, the revision
+// is refused if it fails validation or the configuration has changed since it was staged.
+// The replaced configuration is kept so the promotion can be rolled back.
+// responses:
+//   200: stagedConfigResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostConfigPromote(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.PromoteConfigRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	preview, err := staging.Promote(r.Context(), d.client, d.vdiCluster.GetName(), req.Force)
+	if err != nil {
+		if err == staging.ErrNoStagedConfig {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Promoted staged VDICluster configuration",
+		"User", apiutil.GetRequestUserSession(r).User.GetName(),
+		"StagedBy", preview.Staged.StagedBy,
+		"Forced", req.Force,
+	)
+	apiutil.WriteJSON(preview, w)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/staging"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route POST /api/config/rollback Miscellaneous postConfigRollback
+// Restores the VDICluster configuration that was replaced by the last promotion.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if err := staging.Rollback(r.Context(), d.client, d.vdiCluster.GetName()); err != nil {
+		if err == staging.ErrNoPreviousConfig {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Rolled back VDICluster configuration", "User", apiutil.GetRequestUserSession(r).User.GetName())
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	proxyclient "github.com/tinyzimmer/kvdi/pkg/proxyproto/client"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Lab control response
+// swagger:response labControlResponse
+type swaggerLabControlResponse struct {
+	// in:body
+	Body types.LabControlResponse
+}
+
+// swagger:operation POST /api/labs/{namespace}/{name}/broadcast Labs postLabBroadcast
+// ---
+// summary: Delivers a message to every session in the lab.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// - in: body
+//   name: postLabBroadcastRequest
+//   description: The message to broadcast.
+//   schema:
+//     "$ref": "#/definitions/LabBroadcastRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/labControlResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostLabBroadcast(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.LabBroadcastRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	d.controlLab(w, r, &proxyproto.ControlRequest{
+		Action:  proxyproto.ControlBroadcast,
+		Message: req.Message,
+	}, func(lab *desktopsv1.Lab) {
+		lab.Status.LastBroadcast = req.Message
+		lab.Status.LastBroadcastTime = metav1.Now()
+	})
+}
+
+// swagger:operation POST /api/labs/{namespace}/{name}/lock Labs postLabLock
+// ---
+// summary: Locks the screens of every session in the lab.
+// description: Active display streams are closed and new ones are refused until the lab is unlocked.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// - in: body
+//   name: postLabLockRequest
+//   description: An optional message to show on locked screens.
+//   schema:
+//     "$ref": "#/definitions/LabLockRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/labControlResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostLabLock(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.LabLockRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	d.controlLab(w, r, &proxyproto.ControlRequest{
+		Action:  proxyproto.ControlLock,
+		Message: req.Message,
+	}, func(lab *desktopsv1.Lab) {
+		lab.Status.Locked = true
+		lab.Status.LockMessage = req.Message
+	})
+}
+
+// swagger:operation POST /api/labs/{namespace}/{name}/unlock Labs postLabUnlock
+// ---
+// summary: Unlocks the screens of every session in the lab.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the lab
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the lab
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/labControlResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostLabUnlock(w http.ResponseWriter, r *http.Request) {
+	d.controlLab(w, r, &proxyproto.ControlRequest{
+		Action: proxyproto.ControlUnlock,
+	}, func(lab *desktopsv1.Lab) {
+		lab.Status.Locked = false
+		lab.Status.LockMessage = ""
+	})
+}
+
+// controlLab records the change to the lab's status and then sends the control request
+// to every session in the lab.
+func (d *desktopAPI) controlLab(w http.ResponseWriter, r *http.Request, req *proxyproto.ControlRequest, updateStatus func(*desktopsv1.Lab)) {
+	lab, err := d.getLabForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	updateStatus(lab)
+	if err := d.client.Status().Update(context.TODO(), lab); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	results := d.forEachLabSession(lab, func(proxy *proxyclient.Client) error {
+		return proxy.Control(req)
+	})
+	apiutil.WriteJSON(&types.LabControlResponse{Results: results}, w)
+}
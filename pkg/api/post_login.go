@@ -22,6 +22,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/devices"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -59,7 +60,7 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 
 		// redirect back to home page. the ui knows to use it's existing state token
 		// and attempt anonymous login. The next POST should return the proper claims.
-		http.Redirect(w, r, "/#/login", http.StatusFound)
+		http.Redirect(w, r, d.vdiCluster.GetAppPathPrefix()+"/#/login", http.StatusFound)
 		return
 	}
 
@@ -85,12 +86,15 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 					Name:  userAnonymous,
 					Roles: []*types.VDIUserRole{rbac.VDIRoleToUserRole(d.vdiCluster.GetLaunchTemplatesRole())},
 				},
+				TrustedDevice: d.verifyDevice(r),
+				Device:        devices.FromRequest(r),
 			}
 			d.returnNewJWT(w, result, true, req.GetState())
 			return
 		}
 		// If it's not an actual credential error, it will still be logged server side,
 		// but always tell the user 'Invalid credentials'.
+		recordAuthFailure(authFailureInvalidCredentials)
 		apiutil.ReturnAPIForbidden(err, "Invalid credentials", w)
 		return
 	}
@@ -105,6 +109,21 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record whether the user is on a trusted device, and which device it is
+	result.TrustedDevice = d.verifyDevice(r)
+	result.Device = devices.FromRequest(r)
+
+	// Let the user in outside of their access hours if they present an override
+	if token := req.GetAccessOverrideToken(); token != "" {
+		expiresAt, err := d.lookupAccessOverride(result.User.Name, token)
+		if err != nil {
+			recordAuthFailure(authFailureInvalidOverride)
+			apiutil.ReturnAPIForbidden(err, "Invalid access override token", w)
+			return
+		}
+		result.AccessOverrideExpiresAt = expiresAt
+	}
+
 	d.checkMFAAndReturnJWT(w, result, req.GetState())
 }
 
@@ -57,6 +57,12 @@ func (d *desktopAPI) PostLogout(w http.ResponseWriter, r *http.Request) {
 			Secure:   true,
 		})
 	}
+	// the user is no longer signed in on the device
+	if session := apiutil.GetRequestUserSession(r); session.Device != "" {
+		if err := d.userDevices.SignOut(session.User.GetName(), session.Device); err != nil {
+			apiLogger.Error(err, "Error while signing out device", "Device", session.Device)
+		}
+	}
 	apiutil.WriteOK(w)
 }
 
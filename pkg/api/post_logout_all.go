@@ -0,0 +1,49 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route POST /api/logout/all Auth logoutAll
+// Ends all sessions for the current user. Every access and refresh token issued to the user
+// up to this point is revoked.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostLogoutAll(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+	if err := d.revokeUserTokens(userSession.User.GetName()); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	// Set the cookie to an empty value
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshTokenCookie,
+		Value:    "",
+		HttpOnly: true,
+		Secure:   true,
+	})
+	apiutil.WriteOK(w)
+}
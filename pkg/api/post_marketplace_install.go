@@ -0,0 +1,130 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/marketplace/{index}/{template} Templates postMarketplaceInstall
+// ---
+// summary: Install or update a template from a remote template index.
+// description: |
+//   The index signature and the digest of the template manifest are verified before the
+//   template is applied. Existing templates are only updated if they were installed from
+//   the same index.
+// parameters:
+// - name: index
+//   in: path
+//   description: The name of the template index
+//   type: string
+//   required: true
+// - name: template
+//   in: path
+//   description: The name of the template in the index
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/templateResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostMarketplaceInstall(w http.ResponseWriter, r *http.Request) {
+	indexName := apiutil.GetIndexFromRequest(r)
+	tmplName := apiutil.GetTemplateFromRequest(r)
+
+	idx := d.vdiCluster.GetTemplateIndex(indexName)
+	if idx == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("Template index %q is not configured", indexName), w)
+		return
+	}
+	index, err := d.marketplace.FetchIndex(*idx)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	entry := index.Get(tmplName)
+	if entry == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("Template %q is not listed in index %q", tmplName, indexName), w)
+		return
+	}
+	tmpl, err := d.marketplace.FetchTemplate(*idx, entry, d.vdiCluster.GetTemplateSigningKeys())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.lintTemplate(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	results := tmpl.Status.LintResults
+	d.vdiCluster.ClaimObject(tmpl)
+
+	existing := &desktopsv1.Template{}
+	err = d.client.Get(context.TODO(), ktypes.NamespacedName{Name: tmplName}, existing)
+	switch {
+	case client.IgnoreNotFound(err) != nil:
+		apiutil.ReturnAPIError(err, w)
+		return
+	case err != nil:
+		// the template is not installed yet
+		if err := d.client.Create(context.TODO(), tmpl); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	default:
+		if err := tenancy.CheckTemplate(d.vdiCluster, existing); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if existing.GetAnnotations()[v1.MarketplaceIndexAnnotation] != indexName {
+			apiutil.ReturnAPIError(fmt.Errorf("Template %q already exists and was not installed from index %q", tmplName, indexName), w)
+			return
+		}
+		existing.Spec = tmpl.Spec
+		existing.SetLabels(tmpl.GetLabels())
+		existing.SetAnnotations(tmpl.GetAnnotations())
+		if err := d.client.Update(context.TODO(), existing); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		tmpl = existing
+	}
+
+	if err := d.writeTemplateLintStatus(tmpl, results); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(tmpl, w)
+}
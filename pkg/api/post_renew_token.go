@@ -0,0 +1,89 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/devices"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route POST /api/renew_token Auth renewTokenRequest
+// Renews the access token used in the request. The user is reloaded from the authentication
+// provider so that changes to their roles take effect, and the new token is never issued past
+// the maximum age of the session. Sessions that cannot be refreshed, such as those from OIDC
+// or break-glass logins, cannot be renewed either.
+// responses:
+//   200: sessionResponse
+//   400: error
+//   403: error
+//   500: error
+func (d *desktopAPI) PostRenewToken(w http.ResponseWriter, r *http.Request) {
+	session := apiutil.GetRequestUserSession(r)
+	if reason := renewalDenied(session, d.vdiCluster.GetIdleTimeout(), time.Now()); reason != "" {
+		if session.Authorized && session.Renewable {
+			recordAuthFailure(authFailureSessionExpired)
+		}
+		apiutil.ReturnAPIForbidden(nil, reason, w)
+		return
+	}
+
+	// pick up any changes made to the user since the token was issued
+	user, err := d.auth.GetUser(session.User.Name)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// revoke the current refresh token, a new one is issued with the renewed access token
+	if refreshToken, err := r.Cookie(RefreshTokenCookie); err == nil && refreshToken.Value != "" {
+		if _, err := d.lookupRefreshToken(refreshToken.Value); err != nil {
+			apiLogger.Error(err, "Error while revoking refresh token, garbage may be left in the db")
+		}
+	}
+
+	d.returnNewJWT(w, &types.AuthResult{
+		User:                    user,
+		Data:                    session.Data,
+		SessionStart:            session.GetSessionStart(),
+		TrustedDevice:           session.TrustedDevice,
+		Device:                  devices.FromRequest(r),
+		AccessOverrideExpiresAt: session.GetAccessOverrideExpiresAt(),
+	}, true, "")
+}
+
+// renewalDenied returns the reason the given session may not be renewed, or an empty
+// string if it may be. Each renewal counts as activity, so a session is idle for as long
+// as its current token has been issued.
+func renewalDenied(session *types.JWTClaims, idleTimeout time.Duration, now time.Time) string {
+	if !session.Authorized {
+		return "Only fully authorized sessions can be renewed"
+	}
+	if !session.Renewable {
+		return "This session cannot be renewed, please log in again"
+	}
+	if idleTimeout > 0 && now.Sub(time.Unix(session.IssuedAt, 0)) > idleTimeout {
+		return "The session has expired due to inactivity, please log in again"
+	}
+	return ""
+}
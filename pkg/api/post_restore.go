@@ -0,0 +1,78 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/backup"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Request containing an archive to restore
+// swagger:parameters postRestoreRequest
+type swaggerRestoreRequest struct {
+	// in:body
+	Body types.RestoreRequest
+}
+
+// swagger:route POST /api/restore Miscellaneous postRestoreRequest
+// Restores the state of the cluster from an encrypted archive. Roles, teams, and templates
+// in the archive are created or replaced, and the data in the archive is written to the
+// secrets backend. Unless skipped, the VDICluster configuration is replaced last.
+// responses:
+//   200: restoreResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostRestore(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.RestoreRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	archive, err := backup.Decrypt(req.Archive, req.Passphrase)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	res, err := backup.Restore(r.Context(), d.client, d.vdiCluster, d.secrets, archive, &backup.RestoreOptions{
+		SkipClusterConfig: req.SkipClusterConfig,
+	})
+	d.rbacCache.invalidate()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Restored cluster backup",
+		"User", apiutil.GetRequestUserSession(r).User.GetName(),
+		"Source", archive.Cluster,
+		"CreatedAt", archive.CreatedAt,
+	)
+	apiutil.WriteJSON(res, w)
+}
+
+// Restore response
+// swagger:response restoreResponse
+type swaggerRestoreResponse struct {
+	// in:body
+	Body types.RestoreResponse
+}
@@ -27,6 +27,7 @@ import (
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
@@ -53,10 +54,19 @@ func (d *desktopAPI) CreateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	role := d.newRoleFromRequest(req)
+	if err := role.Validate(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := tenancy.CheckRole(d.vdiCluster, role); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if err := d.client.Create(context.TODO(), role); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	d.rbacCache.invalidate()
 	apiutil.WriteOK(w)
 }
 
@@ -69,6 +79,11 @@ func (d *desktopAPI) newRoleFromRequest(req *types.CreateRoleRequest) *rbacv1.VD
 				v1.RoleClusterRefLabel: d.vdiCluster.GetName(),
 			},
 		},
-		Rules: req.GetRules(),
+		Rules:           req.GetRules(),
+		Devices:         req.Devices,
+		AccessHours:     req.AccessHours,
+		Quotas:          req.Quotas,
+		BandwidthWeight: req.BandwidthWeight,
+		InheritsFrom:    req.InheritsFrom,
 	}
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/roles/{role}/restore Roles postRoleRestoreRequest
+// ---
+// summary: Restores a deleted role.
+// description: The role must have been deleted within the deletion retention configured on the VDICluster, and no role with the same name may exist. Users and teams still bound to the role by name regain its permissions.
+// parameters:
+// - name: role
+//   in: path
+//   description: The role to restore
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostRoleRestore(w http.ResponseWriter, r *http.Request) {
+	name := apiutil.GetRoleFromRequest(r)
+	entry, err := d.trash.Take(rbacv1.ResourceRoles, name)
+	if err != nil {
+		if errors.IsDeletedObjectNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	role, err := decodeTrashedRole(entry.Data)
+	if err == nil {
+		err = d.client.Create(context.TODO(), role)
+	}
+	if err != nil {
+		if aerr := d.trash.Add(entry.DeletedObject, entry.Data); aerr != nil {
+			apiLogger.Error(aerr, "Failed to return role to the trash after failing to restore it", "Role", name)
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.rbacCache.invalidate()
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// Short-lived kubeconfig response
+// swagger:response sessionKubeconfigResponse
+type swaggerSessionKubeconfigResponse struct {
+	// in:body
+	Body types.SessionKubeconfigResponse
+}
+
+// swagger:route POST /api/sessions/{namespace}/{name}/kubeconfig Sessions postSessionKubeconfigRequest
+// Issues a short-lived kubeconfig with the permissions of the given desktop session's service account.
+// The credentials are revoked when the session ends.
+// responses:
+//   200: sessionKubeconfigResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostSessionKubeconfig(w http.ResponseWriter, r *http.Request) {
+	d.writeSessionKubeconfig(w, r)
+}
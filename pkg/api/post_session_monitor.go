@@ -0,0 +1,110 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/monitors Sessions postSessionMonitor
+// ---
+// summary: Attach an additional monitor to a desktop session.
+// description: The desktop's template must allow multiple monitors. The monitor is placed to the right of the existing ones and is streamed over the display websocket with its index in the monitor query parameter. The new layout of the desktop's monitors is returned, the first being the primary monitor.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - in: body
+//   name: addMonitorRequest
+//   description: The resolution of the monitor.
+//   schema:
+//     "$ref": "#/definitions/AddMonitorRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/monitorsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionMonitor(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.AddMonitorRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tmpl, err := desktop.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	monitors, err := addMonitor(tmpl, desktop.Status.Monitors, req)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.setSessionMonitors(desktop, monitors); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(monitors, w)
+}
+
+// Request containing the resolution of a monitor to attach to a desktop session
+// swagger:parameters postSessionMonitor
+type swaggerAddMonitorRequest struct {
+	// in:body
+	Body types.AddMonitorRequest
+}
+
+// The monitors a desktop session spans
+// swagger:response monitorsResponse
+type swaggerMonitorsResponse struct {
+	// in:body
+	Body []desktopsv1.DisplayResolution
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/snapshot Sessions postSessionSnapshot
+// ---
+// summary: Snapshot the persistent volumes of a desktop session.
+// description: A CSI VolumeSnapshot is taken of every persistent volume mounted in the desktop, and recorded in a DesktopSnapshot alongside the template the session was launched from. New sessions of the same template may be launched from the snapshot to resume where it was taken.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - in: body
+//   name: createSnapshotRequest
+//   description: The name of the snapshot and the class to take it with.
+//   schema:
+//     "$ref": "#/definitions/CreateSnapshotRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/snapshotResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionSnapshot(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.CreateSnapshotRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.client.Get(context.TODO(), apiutil.GetNamespacedNameFromRequest(r), pod); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	snapshot := newSnapshotForSession(d.vdiCluster.GetUserDesktopSelector(desktop.GetUser()), desktop, pod, req)
+	if len(snapshot.Spec.Volumes) == 0 {
+		apiutil.ReturnAPIError(errors.New("The desktop session does not mount any persistent volumes"), w)
+		return
+	}
+	if err := d.client.Create(context.TODO(), snapshot); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	for _, vol := range snapshot.Spec.Volumes {
+		if err := d.createVolumeSnapshot(snapshot, vol, req.VolumeSnapshotClassName); err != nil {
+			// the VolumeSnapshots already taken are garbage collected with their owner
+			if derr := d.client.Delete(context.TODO(), snapshot); derr != nil {
+				apiLogger.Error(derr, "Failed to clean up DesktopSnapshot", "Snapshot", snapshot.GetName())
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	apiutil.WriteJSON(snapshot, w)
+}
+
+// newSnapshotForSession returns a DesktopSnapshot recording the persistent volumes mounted
+// in the pod of the given desktop session.
+func newSnapshotForSession(labels map[string]string, desktop *desktopsv1.Session, pod *corev1.Pod, req *types.CreateSnapshotRequest) *desktopsv1.DesktopSnapshot {
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-%d", desktop.GetName(), time.Now().Unix())
+	}
+	volumes := make([]desktopsv1.SnapshotVolume, 0)
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+		volumes = append(volumes, desktopsv1.SnapshotVolume{
+			Name:               vol.Name,
+			ClaimName:          vol.PersistentVolumeClaim.ClaimName,
+			VolumeSnapshotName: fmt.Sprintf("%s-%s", name, vol.Name),
+		})
+	}
+	return &desktopsv1.DesktopSnapshot{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: desktopsv1.GroupVersion.String(),
+			Kind:       "DesktopSnapshot",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: desktop.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: desktopsv1.DesktopSnapshotSpec{
+			VDICluster: desktop.Spec.VDICluster,
+			User:       desktop.GetUser(),
+			Template:   desktop.GetTemplateName(),
+			Session:    desktop.GetName(),
+			Volumes:    volumes,
+		},
+	}
+}
+
+// createVolumeSnapshot takes a CSI VolumeSnapshot of a volume recorded in the given
+// DesktopSnapshot. An empty class name uses the default class of the storage driver.
+func (d *desktopAPI) createVolumeSnapshot(snapshot *desktopsv1.DesktopSnapshot, vol desktopsv1.SnapshotVolume, className string) error {
+	volSnapshot := &unstructured.Unstructured{}
+	volSnapshot.SetAPIVersion(desktopsv1.VolumeSnapshotAPIGroup + "/v1")
+	volSnapshot.SetKind("VolumeSnapshot")
+	volSnapshot.SetName(vol.VolumeSnapshotName)
+	volSnapshot.SetNamespace(snapshot.GetNamespace())
+	volSnapshot.SetLabels(snapshot.GetLabels())
+	volSnapshot.SetOwnerReferences(snapshot.OwnerReferences())
+	if className != "" {
+		if err := unstructured.SetNestedField(volSnapshot.Object, className, "spec", "volumeSnapshotClassName"); err != nil {
+			return err
+		}
+	}
+	if err := unstructured.SetNestedField(volSnapshot.Object, vol.ClaimName, "spec", "source", "persistentVolumeClaimName"); err != nil {
+		return err
+	}
+	return d.client.Create(context.TODO(), volSnapshot)
+}
+
+// Request containing the name and class of a snapshot
+// swagger:parameters postSessionSnapshot
+type swaggerCreateSnapshotRequest struct {
+	// in:body
+	Body types.CreateSnapshotRequest
+}
+
+// A DesktopSnapshot
+// swagger:response snapshotResponse
+type swaggerSnapshotResponse struct {
+	// in:body
+	Body desktopsv1.DesktopSnapshot
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/pki"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"golang.org/x/crypto/ssh"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request to sign an SSH public key
+// swagger:parameters postSessionSSHCertificateRequest
+type swaggerSSHCertificateRequest struct {
+	// in:body
+	Body types.SSHCertificateRequest
+}
+
+// Signed SSH certificate response
+// swagger:response sshCertificateResponse
+type swaggerSSHCertificateResponse struct {
+	// in:body
+	Body types.SSHCertificateResponse
+}
+
+// swagger:route POST /api/sessions/{namespace}/{name}/ssh Sessions postSessionSSHCertificateRequest
+// Signs a short-lived SSH certificate for access to the given desktop session.
+// responses:
+//   200: sshCertificateResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostSessionSSHCertificate(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.SSHGatewayEnabled() {
+		apiutil.ReturnAPIError(errors.New("The SSH gateway is not enabled"), w)
+		return
+	}
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+
+	req := apiutil.GetRequestObject(r).(*types.SSHCertificateRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	session, err := d.getClusterDesktop(apiutil.GetNamespacedNameFromRequest(r))
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// The certificate is issued for the user the desktop is running as
+	principal := session.GetDesktopUser()
+	cert, err := pki.New(d.client, d.vdiCluster, d.secrets).SignSSHUserKey(apiLogger, pubKey, principal)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(&types.SSHCertificateResponse{
+		Certificate: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		Principal:   principal,
+		ValidBefore: int64(cert.ValidBefore),
+	}, w)
+}
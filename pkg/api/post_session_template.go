@@ -0,0 +1,149 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/template Sessions postSessionTemplate
+// ---
+// summary: Save a desktop session as a new draft template.
+// description: The draft copies the configuration the session was launched with, optionally with a different desktop image. Until it is approved, only the author and users allowed to review templates may see and launch it.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - in: body
+//   name: saveSessionTemplateRequest
+//   description: The name and image of the new template.
+//   schema:
+//     "$ref": "#/definitions/SaveSessionTemplateRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/templateResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionTemplate(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+	req := apiutil.GetRequestObject(r).(*types.SaveSessionTemplateRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	source, err := desktop.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	tmpl, err := newDraftTemplate(source.ForRevision(desktop.Spec.CanaryRevision), desktop, sess.User.GetName(), req)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.lintTemplate(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	results := tmpl.Status.LintResults
+	d.vdiCluster.ClaimObject(tmpl)
+	if err := d.client.Create(context.TODO(), tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.writeTemplateLintStatus(tmpl, results); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(tmpl, w)
+}
+
+// newDraftTemplate returns a draft copy of the template the given session was launched from,
+// pending review. Rollouts and deprecations are not carried over to the draft.
+func newDraftTemplate(source *desktopsv1.Template, desktop *desktopsv1.Session, author string, req *types.SaveSessionTemplateRequest) (*desktopsv1.Template, error) {
+	spec := source.Spec.DeepCopy()
+	spec.Rollout = nil
+	spec.Deprecation = nil
+	spec.Review = &desktopsv1.TemplateReview{
+		Author:        author,
+		SourceSession: ktypes.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}.String(),
+		State:         desktopsv1.ReviewPending,
+	}
+	if req.Tags != nil {
+		spec.Tags = req.Tags
+	}
+	if req.Image != "" {
+		if source.IsQEMUTemplate() || source.IsStaticHostTemplate() || spec.DesktopConfig == nil {
+			return nil, fmt.Errorf("Template %s does not run a desktop image", source.GetName())
+		}
+		spec.DesktopConfig.Image = req.Image
+		// the image was committed from a desktop running on a single architecture
+		spec.DesktopConfig.ImageVariants = nil
+		if desktop.Spec.Architecture != "" {
+			spec.Architectures = []desktopsv1.Architecture{desktop.Spec.Architecture}
+		}
+	}
+	return &desktopsv1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: req.Name,
+		},
+		Spec: *spec,
+	}, nil
+}
+
+// Request containing the name of a template to save a session as
+// swagger:parameters postSessionTemplate
+type swaggerSaveSessionTemplateRequest struct {
+	// in:body
+	Body types.SaveSessionTemplateRequest
+}
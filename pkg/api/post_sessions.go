@@ -22,15 +22,21 @@ package api
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"text/template"
+	"time"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/licenses"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -58,6 +64,7 @@ type swaggerCreateSessionResponse struct {
 //   200: postSessionResponse
 //   400: error
 //   403: error
+//   429: error
 func (d *desktopAPI) StartDesktopSession(w http.ResponseWriter, r *http.Request) {
 	sess := apiutil.GetRequestUserSession(r)
 	req := apiutil.GetRequestObject(r).(*types.CreateSessionRequest)
@@ -66,30 +73,158 @@ func (d *desktopAPI) StartDesktopSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	tmplnn := ktypes.NamespacedName{Name: req.GetTemplate(), Namespace: metav1.NamespaceAll}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), tmplnn, tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	desktop, err := d.launchDesktopSession(sess, tmpl, req)
+	if err != nil {
+		if errors.IsQuotaExceededError(err) {
+			apiutil.ReturnAPIQuotaExceeded(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(&types.CreateSessionResponse{
+		Name:      desktop.GetName(),
+		Namespace: desktop.GetNamespace(),
+		Warning:   tmpl.GetDeprecationWarning(),
+	}, w)
+}
+
+// launchDesktopSession creates a new desktop session from the given template for the user
+// in the provided claims. Any secrets required by the template are created alongside it.
+func (d *desktopAPI) launchDesktopSession(sess *types.JWTClaims, tmpl *desktopsv1.Template, req *types.CreateSessionRequest) (*desktopsv1.Session, error) {
+	if err := tenancy.CheckTemplate(d.vdiCluster, tmpl); err != nil {
+		return nil, err
+	}
+	if err := tenancy.CheckNamespace(context.TODO(), d.client, d.vdiCluster, req.GetNamespace()); err != nil {
+		return nil, err
+	}
+
+	if tmpl.IsDraft() && !rbac.CanAccessDraft(sess.User, tmpl) {
+		return nil, fmt.Errorf("Template %s is pending review and can only be launched by its author and reviewers", tmpl.GetName())
+	}
+
+	if tmpl.IsSunset(time.Now()) {
+		return nil, fmt.Errorf("Template %s was retired on %s and can no longer be launched", tmpl.GetName(), tmpl.GetSunsetTime().UTC().Format(time.RFC3339))
+	}
+
+	if tmpl.RequiresTicket() {
+		if err := d.validateLaunchTicket(sess, tmpl, req.Ticket); err != nil {
+			return nil, err
+		}
+	}
+
+	var promptResponses map[string]string
+	if tmpl.HasLaunchPrompts() || len(req.Prompts) > 0 {
+		responses, err := d.validateLaunchPrompts(sess, tmpl, req.Prompts)
+		if err != nil {
+			return nil, err
+		}
+		promptResponses = responses
+	}
+
+	if req.FromSnapshot != "" {
+		if err := d.validateLaunchSnapshot(sess.User.GetName(), tmpl, req); err != nil {
+			return nil, err
+		}
+	}
+
+	// hold the launch locks until the session exists, so it is counted by the checks
+	// of any other launches
+	release, err := d.acquireLaunchLocks(sess.User, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	if max := d.vdiCluster.GetMaxSessionsPerUser(); max > 0 {
 		desktops := &desktopsv1.SessionList{}
 		if err := d.client.List(context.TODO(), desktops, client.InNamespace(metav1.NamespaceAll), client.MatchingLabels(d.vdiCluster.GetUserDesktopSelector(sess.User.Name))); err != nil {
-			apiutil.ReturnAPIError(err, w)
-			return
+			return nil, err
 		}
 		if len(desktops.Items) >= max {
-			apiutil.ReturnAPIError(fmt.Errorf("%s has reached the maximum allowed (%d) running desktops", sess.User.Name, max), w)
-			return
+			return nil, fmt.Errorf("%s has reached the maximum allowed (%d) running desktops", sess.User.Name, max)
 		}
 	}
 
-	tmplnn := ktypes.NamespacedName{Name: req.GetTemplate(), Namespace: metav1.NamespaceAll}
-	tmpl := &desktopsv1.Template{}
-	if err := d.client.Get(context.TODO(), tmplnn, tmpl); err != nil {
-		apiutil.ReturnAPIError(err, w)
-		return
+	if err := d.checkSessionQuotas(sess.User, tmpl, req.GetNamespace()); err != nil {
+		return nil, err
+	}
+
+	arch, err := d.selectSessionArchitecture(tmpl, req.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := d.reserveTemplateCapacity(tmpl, sess.User)
+	if err != nil {
+		return nil, err
+	}
+
+	if tmpl.RequiresLicenses() {
+		if err := licenses.New(d.client, d.vdiCluster, d.secrets).CheckLaunch(context.TODO(), tmpl); err != nil {
+			return nil, err
+		}
+	}
+
+	// limit how long the session may run by the rules it is launched under
+	launchAction := &types.APIAction{
+		Verb:              rbacv1.VerbLaunch,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      tmpl.GetName(),
+		ResourceNamespace: req.GetNamespace(),
+	}
+	if err := d.populateAction(launchAction); err != nil {
+		return nil, err
+	}
+
+	maxDuration := rbac.MaxSessionDuration(sess.User, launchAction)
+
+	// serve the launch from a desktop pool if one has a desktop ready, pooled desktops
+	// don't mount user data so they can't restore snapshots
+	if req.FromSnapshot == "" {
+		if desktop, err := d.claimPooledSession(sess.User.GetName(), tmpl, req, arch, maxDuration, reservation); err != nil || desktop != nil {
+			return desktop, err
+		}
 	}
 
 	desktop := d.newDesktopForRequest(req, sess.User.GetName())
+	desktop.Spec.Architecture = arch
+	if maxDuration > 0 {
+		desktop.Spec.MaxDuration = maxDuration.String()
+	}
+	if tmpl.RequiresTicket() {
+		desktop.Spec.Ticket = req.Ticket
+	}
+	if reservation != "" {
+		annotations := desktop.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[v1.ReservationAnnotation] = reservation
+		desktop.SetAnnotations(annotations)
+	}
+
+	// launch a percentage of new sessions on the canary revision during a rollout
+	desktop.Spec.CanaryRevision = tmpl.SelectRevision(rand.Int31n(100))
 
 	if err := d.client.Create(context.TODO(), desktop); err != nil {
-		apiutil.ReturnAPIError(err, w)
-		return
+		return nil, err
+	}
+
+	if desktop.Spec.Ticket != "" {
+		d.auditTicket(sess, tmpl, desktop.Spec.Ticket, fmt.Sprintf("%s/%s", desktop.GetNamespace(), desktop.GetName()), nil)
+	}
+
+	if tmpl.HasLaunchPrompts() {
+		d.auditPrompts(sess, tmpl, promptResponses, fmt.Sprintf("%s/%s", desktop.GetNamespace(), desktop.GetName()), nil)
 	}
 
 	if envTemplates := tmpl.GetEnvTemplates(); len(envTemplates) > 0 {
@@ -102,22 +237,17 @@ func (d *desktopAPI) StartDesktopSession(w http.ResponseWriter, r *http.Request)
 			}
 		}()
 		var data map[string][]byte
-		data, secretErr = executeEnvTemplates(sess, envTemplates)
+		data, secretErr = executeEnvTemplates(sess, promptResponses, envTemplates)
 		if secretErr != nil {
-			apiutil.ReturnAPIError(secretErr, w)
-			return
+			return nil, secretErr
 		}
 		secret := d.newEnvSecretForRequest(req, desktop, sess.User.GetName(), data)
 		if secretErr = d.client.Create(context.TODO(), secret); secretErr != nil {
-			apiutil.ReturnAPIError(secretErr, w)
-			return
+			return nil, secretErr
 		}
 	}
 
-	apiutil.WriteJSON(&types.CreateSessionResponse{
-		Name:      desktop.GetName(),
-		Namespace: desktop.GetNamespace(),
-	}, w)
+	return desktop, nil
 }
 
 func (d *desktopAPI) newDesktopForRequest(req *types.CreateSessionRequest, username string) *desktopsv1.Session {
@@ -132,6 +262,7 @@ func (d *desktopAPI) newDesktopForRequest(req *types.CreateSessionRequest, usern
 			Template:       req.GetTemplate(),
 			User:           username,
 			ServiceAccount: req.GetServiceAccount(),
+			FromSnapshot:   req.FromSnapshot,
 		},
 	}
 }
@@ -149,7 +280,7 @@ func (d *desktopAPI) newEnvSecretForRequest(req *types.CreateSessionRequest, des
 	}
 }
 
-func executeEnvTemplates(sess *types.JWTClaims, envTemplates map[string]string) (map[string][]byte, error) {
+func executeEnvTemplates(sess *types.JWTClaims, prompts map[string]string, envTemplates map[string]string) (map[string][]byte, error) {
 	data := make(map[string][]byte)
 	for envVar, envVarTmpl := range envTemplates {
 		t, err := template.New("").Parse(envVarTmpl)
@@ -159,6 +290,7 @@ func executeEnvTemplates(sess *types.JWTClaims, envTemplates map[string]string)
 		var buf bytes.Buffer
 		if err := t.Execute(&buf, map[string]interface{}{
 			"Session": sess,
+			"Prompts": prompts,
 		}); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,218 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request to launch a template in several namespaces or for several users
+// swagger:parameters postSessionsBulkRequest
+type swaggerBulkCreateSessionRequest struct {
+	// in:body
+	Body types.BulkCreateSessionRequest
+}
+
+// Bulk session launch response
+// swagger:response postSessionsBulkResponse
+type swaggerBulkCreateSessionResponse struct {
+	// in:body
+	Body types.BulkCreateSessionResponse
+}
+
+// swagger:route POST /api/sessions/bulk Sessions postSessionsBulkRequest
+// Launches the same template in each of the given namespaces for each of the given users.
+// Unless partial results are allowed, any failure removes all sessions created by the request.
+// responses:
+//   200: postSessionsBulkResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) StartDesktopSessions(w http.ResponseWriter, r *http.Request) {
+	sess := apiutil.GetRequestUserSession(r)
+	req := apiutil.GetRequestObject(r).(*types.BulkCreateSessionRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	tmplnn := ktypes.NamespacedName{Name: req.Template, Namespace: metav1.NamespaceAll}
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), tmplnn, tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	users := req.Users
+	if len(users) == 0 {
+		users = []string{sess.User.GetName()}
+	}
+
+	// resolve all the target users up front so a missing user fails the request
+	// before anything is launched
+	claims := make(map[string]*types.JWTClaims, len(users))
+	targets := []*types.VDIUser{sess.User}
+	for _, username := range users {
+		if _, ok := claims[username]; ok {
+			continue
+		}
+		if username == sess.User.GetName() {
+			claims[username] = sess
+			continue
+		}
+		allowed, err := d.evaluateUserAction(sess.User, &types.APIAction{
+			Verb:         rbacv1.VerbUpdate,
+			ResourceType: rbacv1.ResourceUsers,
+			ResourceName: username,
+		})
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if !allowed {
+			apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("%s does not have the ability to launch sessions for %s", sess.User.GetName(), username), w)
+			return
+		}
+		user, err := d.auth.GetUser(username)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if err := d.applyTeamRoles(user); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if err := d.applyBoundRoles(user); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		claims[username] = &types.JWTClaims{User: user, Authorized: true}
+		targets = append(targets, user)
+	}
+
+	// the requesting user, and each user the sessions are launched for, must be
+	// able to launch the template in every namespace
+	for _, ns := range req.GetNamespaces() {
+		for _, user := range targets {
+			allowed, err := d.evaluateUserAction(user, &types.APIAction{
+				Verb:              rbacv1.VerbLaunch,
+				ResourceType:      rbacv1.ResourceTemplates,
+				ResourceName:      req.Template,
+				ResourceNamespace: ns,
+			})
+			if err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			}
+			if !allowed {
+				apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("%s does not have the ability to launch %s in %s", user.GetName(), req.Template, ns), w)
+				return
+			}
+		}
+	}
+	if req.ServiceAccount != "" {
+		if !rbac.EvaluateUser(sess.User, &types.APIAction{
+			Verb:         rbacv1.VerbUse,
+			ResourceType: rbacv1.ResourceServiceAccounts,
+			ResourceName: req.ServiceAccount,
+		}) {
+			apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("%s does not have the ability to use the service account %s", sess.User.GetName(), req.ServiceAccount), w)
+			return
+		}
+	}
+
+	resp := &types.BulkCreateSessionResponse{
+		Results: make([]*types.BulkCreateSessionResult, 0),
+		Warning: tmpl.GetDeprecationWarning(),
+	}
+	created := make([]*desktopsv1.Session, 0)
+
+	for _, username := range users {
+		for _, ns := range req.GetNamespaces() {
+			result := &types.BulkCreateSessionResult{User: username, Namespace: ns}
+			resp.Results = append(resp.Results, result)
+			desktop, err := d.launchDesktopSession(claims[username], tmpl, &types.CreateSessionRequest{
+				Template:       req.Template,
+				Namespace:      ns,
+				ServiceAccount: req.ServiceAccount,
+				Ticket:         req.Ticket,
+				Prompts:        req.Prompts,
+			})
+			if err != nil {
+				result.Error = err.Error()
+				resp.Failed++
+				continue
+			}
+			result.Name = desktop.GetName()
+			resp.Succeeded++
+			created = append(created, desktop)
+		}
+	}
+
+	if resp.Failed > 0 && !req.AllowPartial && len(created) > 0 {
+		for _, desktop := range created {
+			if err := d.cleanupDesktopSession(desktop); err != nil {
+				apiLogger.Error(err, "Couldn't rollback desktop from failed bulk launch", "Desktop", desktop.GetName(), "Namespace", desktop.GetNamespace())
+			}
+		}
+		for _, result := range resp.Results {
+			result.Name = ""
+		}
+		resp.Succeeded = 0
+		resp.RolledBack = true
+	}
+
+	apiutil.WriteJSON(resp, w)
+}
+
+// evaluateUserAction resolves the teams and namespace labels for the given action and
+// evaluates it against the user's roles.
+func (d *desktopAPI) evaluateUserAction(user *types.VDIUser, action *types.APIAction) (bool, error) {
+	if err := d.populateAction(action); err != nil {
+		return false, err
+	}
+	return rbac.EvaluateUser(user, action), nil
+}
+
+// cleanupDesktopSession removes a desktop session along with any environment secrets
+// that were created for it.
+func (d *desktopAPI) cleanupDesktopSession(desktop *desktopsv1.Session) error {
+	if err := d.client.Delete(context.TODO(), desktop); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	return d.client.DeleteAllOf(context.TODO(), &corev1.Secret{},
+		client.InNamespace(desktop.GetNamespace()),
+		client.MatchingLabels{v1.DesktopNameLabel: desktop.GetName()},
+	)
+}
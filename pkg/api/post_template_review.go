@@ -0,0 +1,137 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/templates/{template}/approve Templates approveTemplate
+// ---
+// summary: Approves a draft template saved from a desktop session.
+// description: Once approved, the template may be launched by anyone allowed to launch it.
+// parameters:
+// - name: template
+//   in: path
+//   description: The draft template to approve
+//   type: string
+//   required: true
+// - in: body
+//   name: reviewTemplateRequest
+//   description: An optional comment to record with the decision.
+//   schema:
+//     "$ref": "#/definitions/ReviewTemplateRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/templateResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostTemplateApprove(w http.ResponseWriter, r *http.Request) {
+	d.reviewTemplate(w, r, desktopsv1.ReviewApproved)
+}
+
+// swagger:operation POST /api/templates/{template}/reject Templates rejectTemplate
+// ---
+// summary: Rejects a draft template saved from a desktop session.
+// description: Rejected templates remain drafts visible only to their author and reviewers.
+// parameters:
+// - name: template
+//   in: path
+//   description: The draft template to reject
+//   type: string
+//   required: true
+// - in: body
+//   name: reviewTemplateRequest
+//   description: An optional comment to record with the decision.
+//   schema:
+//     "$ref": "#/definitions/ReviewTemplateRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/templateResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostTemplateReject(w http.ResponseWriter, r *http.Request) {
+	d.reviewTemplate(w, r, desktopsv1.ReviewRejected)
+}
+
+func (d *desktopAPI) reviewTemplate(w http.ResponseWriter, r *http.Request, state desktopsv1.ReviewState) {
+	sess := apiutil.GetRequestUserSession(r)
+	review, ok := apiutil.GetRequestObject(r).(*types.ReviewTemplateRequest)
+	if !ok || review == nil {
+		review = &types.ReviewTemplateRequest{}
+	}
+
+	tmplName := apiutil.GetTemplateFromRequest(r)
+	tmpl := &desktopsv1.Template{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: tmplName, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if tmpl.GetReviewState() != desktopsv1.ReviewPending {
+		if tmpl.Spec.Review == nil {
+			apiutil.ReturnAPIError(fmt.Errorf("The template '%s' is not subject to review", tmplName), w)
+			return
+		}
+		apiutil.ReturnAPIError(fmt.Errorf("The template '%s' has already been %s", tmplName, strings.ToLower(string(tmpl.GetReviewState()))), w)
+		return
+	}
+
+	now := metav1.Now()
+	tmpl.Spec.Review.State = state
+	tmpl.Spec.Review.Reviewer = sess.User.GetName()
+	tmpl.Spec.Review.Comment = review.Comment
+	tmpl.Spec.Review.ReviewedAt = &now
+	if err := d.client.Update(context.TODO(), tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(tmpl, w)
+}
+
+// Request containing a review comment
+// swagger:parameters approveTemplate rejectTemplate
+type swaggerReviewTemplateRequest struct {
+	// in:body
+	Body types.ReviewTemplateRequest
+}
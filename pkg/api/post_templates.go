@@ -40,10 +40,20 @@ func (d *desktopAPI) PostDesktopTemplates(w http.ResponseWriter, r *http.Request
 		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
 		return
 	}
+	if err := d.lintTemplate(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	results := tmpl.Status.LintResults
+	d.vdiCluster.ClaimObject(tmpl)
 	if err := d.client.Create(context.TODO(), tmpl); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if err := d.writeTemplateLintStatus(tmpl, results); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	apiutil.WriteOK(w)
 }
 
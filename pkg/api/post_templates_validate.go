@@ -0,0 +1,80 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/lint"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route POST /api/templates/validate Templates postTemplateValidateRequest
+// Lint a DesktopTemplate without creating it.
+// responses:
+//   200: templateLintResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostDesktopTemplateValidate(w http.ResponseWriter, r *http.Request) {
+	tmpl := apiutil.GetRequestObject(r).(*desktopsv1.Template)
+	if tmpl == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	apiutil.WriteJSON(&types.TemplateLintResponse{
+		Results: lint.Run(d.vdiCluster, tmpl),
+	}, w)
+}
+
+// lintTemplate runs the linter against the given template and returns an error if
+// any of the findings have an error severity.
+func (d *desktopAPI) lintTemplate(tmpl *desktopsv1.Template) error {
+	tmpl.Status.LintResults = lint.Run(d.vdiCluster, tmpl)
+	if lint.HasErrors(tmpl.Status.LintResults) {
+		return fmt.Errorf("Template %q failed linting: %+v", tmpl.GetName(), tmpl.Status.LintResults)
+	}
+	return nil
+}
+
+// writeTemplateLintStatus writes the lint results computed by lintTemplate to the
+// status of the given template.
+func (d *desktopAPI) writeTemplateLintStatus(tmpl *desktopsv1.Template, results []desktopsv1.LintResult) error {
+	tmpl.Status.LintResults = results
+	return d.client.Status().Update(context.TODO(), tmpl)
+}
+
+// Request containing a template to validate
+// swagger:parameters postTemplateValidateRequest
+type swaggerValidateTemplateRequest struct {
+	// in:body
+	Body desktopsv1.Template
+}
+
+// Template lint response
+// swagger:response templateLintResponse
+type swaggerTemplateLintResponse struct {
+	// in:body
+	Body types.TemplateLintResponse
+}
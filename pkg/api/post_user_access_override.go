@@ -0,0 +1,98 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/users/{user}/access_override Users postUserAccessOverrideRequest
+// ---
+// summary: Issues a token letting the specified user log in and connect to their desktops outside of the access hours of their roles.
+// description: The token is only returned once and should be handed to the user, who supplies it when logging in.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to issue the override for
+//   type: string
+//   required: true
+// - in: body
+//   name: postUserAccessOverrideRequest
+//   description: The duration and reason for the override.
+//   schema:
+//     "$ref": "#/definitions/AccessOverrideRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/postUserAccessOverrideResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostUserAccessOverride(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	// We can only verify the user exists when not using OIDC.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*types.AccessOverrideRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	issuer := apiutil.GetRequestUserSession(r).User.GetName()
+	resp, err := d.issueAccessOverride(username, issuer, req)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Issued access override", "user", username, "issuedBy", issuer, "reason", req.Reason)
+
+	apiutil.WriteJSON(resp, w)
+}
+
+// Request containing the duration and reason for an access override
+// swagger:parameters postUserAccessOverrideRequest
+type swaggerPostUserAccessOverrideRequest struct {
+	// in:body
+	Body types.AccessOverrideRequest
+}
+
+// Response containing an issued access override token
+// swagger:response postUserAccessOverrideResponse
+type swaggerPostUserAccessOverrideResponse struct {
+	// in:body
+	Body types.AccessOverrideResponse
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/users/{user}/restore Users postUserRestoreRequest
+// ---
+// summary: Restores a deleted user along with the roles bound to them.
+// description: The user must have been deleted within the deletion retention configured on the VDICluster, and no user with the same name may exist.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to restore
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostUserRestore(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	restorable, ok := d.auth.(common.RestorableProvider)
+	if !ok {
+		apiutil.ReturnAPIError(errors.New("Restoring users is not supported by the configured auth provider"), w)
+		return
+	}
+	entry, err := d.trash.Take(rbacv1.ResourceUsers, username)
+	if err != nil {
+		if errors.IsDeletedObjectNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := restorable.ImportUser(entry.Data); err != nil {
+		if aerr := d.trash.Add(entry.DeletedObject, entry.Data); aerr != nil {
+			apiLogger.Error(aerr, "Failed to return user to the trash after failing to restore them", "User", username)
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/ide/{path} Sessions proxySessionIDE
+// ---
+// summary: Proxy an HTTP request to the IDE server of the desktop session.
+// description: |
+//   The IDE server only listens on the loopback interface of the desktop pod, so requests
+//   are tunneled through the kvdi-proxy. All HTTP methods and websocket upgrades are proxied,
+//   and kvdi credentials are stripped from proxied requests.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: path
+//   in: path
+//   description: The path to request from the IDE server
+//   type: string
+//   required: false
+// responses:
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) ProxySessionIDE(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	session, err := d.getClusterDesktop(nn)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tmpl, err := session.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if !tmpl.IDEIsEnabled() {
+		apiutil.ReturnAPINotFound(fmt.Errorf("Template %s does not run an IDE server", tmpl.GetName()), w)
+		return
+	}
+
+	proxy, err := d.getProxyClient(nn)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	prefix := getSessionPortPrefix(r)
+	rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "ide"})
+	director := rp.Director
+	rp.Director = func(req *http.Request) {
+		req.URL.Path = "/" + strings.TrimPrefix(req.URL.Path, prefix)
+		req.URL.RawPath = ""
+		director(req)
+		req.Header.Set("X-Forwarded-Prefix", strings.TrimSuffix(prefix, "/"))
+		stripSessionPortCredentials(req)
+	}
+	rp.Transport = &http.Transport{
+		// Every connection to the IDE server is a new stream through the kvdi-proxy
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return proxy.IDEProxy()
+		},
+		DisableKeepAlives: true,
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		apiLogger.Error(err, "Error proxying request to desktop IDE server")
+		apiutil.ReturnAPIError(err, w)
+	}
+	rp.ServeHTTP(w, r)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/port/{port}/{path} Sessions proxySessionPort
+// ---
+// summary: Proxy an HTTP request to a port exposed by the desktop session.
+// description: |
+//   The port must be declared in the `ports` of the session's template. All HTTP methods are proxied.
+//   kvdi credentials are stripped from proxied requests, and responses are sandboxed with a
+//   content security policy so they cannot act on behalf of the user against the API.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: port
+//   in: path
+//   description: The port inside the desktop to proxy to
+//   type: integer
+//   required: true
+// - name: path
+//   in: path
+//   description: The path to request from the service inside the desktop
+//   type: string
+//   required: false
+// responses:
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) ProxySessionPort(w http.ResponseWriter, r *http.Request) {
+	if !d.checkDeviceTrust(w, r) {
+		return
+	}
+	if !d.checkAccessHours(w, r) {
+		return
+	}
+	port, err := strconv.ParseInt(apiutil.GetPortFromRequest(r), 10, 32)
+	if err != nil {
+		apiutil.ReturnAPIError(fmt.Errorf("Invalid port: %s", err.Error()), w)
+		return
+	}
+
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	session, err := d.getClusterDesktop(nn)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	tmpl, err := session.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if !tmpl.HasExposedPort(int32(port)) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("Port %d is not exposed by template %s", port, tmpl.GetName()), w)
+		return
+	}
+
+	svc := &corev1.Service{}
+	if err := d.client.Get(context.TODO(), ktypes.NamespacedName{Name: session.GetPortsServiceName(), Namespace: session.GetNamespace()}, svc); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == "None" {
+		apiutil.ReturnAPIError(errors.New("The desktop service has not been assigned an IP yet"), w)
+		return
+	}
+
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, port)}
+	prefix := getSessionPortPrefix(r)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		req.URL.Path = "/" + strings.TrimPrefix(req.URL.Path, prefix)
+		req.URL.RawPath = ""
+		director(req)
+		req.Header.Set("X-Forwarded-Prefix", strings.TrimSuffix(prefix, "/"))
+		stripSessionPortCredentials(req)
+	}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		// The service is served from the kvdi origin, so sandbox it away from the
+		// API and the credentials stored by the UI.
+		res.Header.Set("Content-Security-Policy", sessionPortCSP)
+		res.Header.Del("Set-Cookie")
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		apiLogger.Error(err, "Error proxying request to desktop session port")
+		apiutil.ReturnAPIError(err, w)
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// sessionPortCSP is the content security policy applied to responses from session ports.
+// Sandboxing without allow-same-origin gives the page an opaque origin, so it cannot
+// read the UI's storage or make credentialed requests to the API.
+const sessionPortCSP = "sandbox allow-scripts allow-forms allow-popups allow-modals allow-downloads"
+
+// stripSessionPortCredentials removes the user's kvdi credentials from a request before it
+// is proxied to a service inside the desktop.
+func stripSessionPortCredentials(req *http.Request) {
+	req.Header.Del(TokenHeader)
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+	if q := req.URL.Query(); q.Get("token") != "" {
+		q.Del("token")
+		req.URL.RawQuery = q.Encode()
+	}
+}
+
+// getSessionPortPrefix returns the URL prefix of a session port or IDE proxy request with all
+// variables substituted.
+func getSessionPortPrefix(r *http.Request) string {
+	prefix := apiutil.GetGorillaPath(r)
+	for _, v := range []string{"namespace", "name", "port"} {
+		prefix = strings.Replace(prefix, fmt.Sprintf("{%s}", v), mux.Vars(r)[v], 1)
+	}
+	return prefix
+}
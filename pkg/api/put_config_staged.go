@@ -0,0 +1,58 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/staging"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Request containing a proposed VDICluster configuration
+// swagger:parameters putStagedConfigRequest
+type swaggerStageConfigRequest struct {
+	// in:body
+	Body types.StageConfigRequest
+}
+
+// swagger:route PUT /api/config/staged Miscellaneous putStagedConfigRequest
+// Stages a proposed revision of the VDICluster configuration. The revision is not applied
+// until it is promoted, and replaces any revision that was already staged.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PutStagedConfig(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.StageConfigRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user := apiutil.GetRequestUserSession(r).User.GetName()
+	if _, err := staging.Stage(r.Context(), d.client, d.vdiCluster.GetName(), req.Spec, user); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Staged VDICluster configuration", "User", user)
+	apiutil.WriteOK(w)
+}
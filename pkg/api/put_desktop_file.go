@@ -31,6 +31,7 @@ import (
 // swagger:operation PUT /api/desktops/fs/{namespace}/{name}/put Desktops putDesktopFile
 // ---
 // summary: Uploads a file to a desktop session.
+// description: The template of the session must allow file uploads and the user must hold the use-file-transfer verb on it. The size and content type of the file are checked against the limits of the template.
 // consumes:
 // - multipart/form-data
 // parameters:
@@ -58,11 +59,31 @@ import (
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) PutDesktopFile(w http.ResponseWriter, r *http.Request) {
+	tmpl := d.checkFileTransfer(w, r, fileTransferUpload)
+	if tmpl == nil {
+		return
+	}
+
+	if max := tmpl.GetMaxFileTransferSize(); max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, max+maxMultipartOverhead)
+	}
 	file, handler, err := r.FormFile("file")
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	defer file.Close()
+
+	contentType, err := detectContentType(file)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	name := sanitize.BaseName(handler.Filename)
+	if err := checkFileTransferLimits(tmpl, name, handler.Size, contentType); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 
 	proxy, err := d.getProxyClientForRequest(r)
 	if err != nil {
@@ -75,7 +96,7 @@ func (d *desktopAPI) PutDesktopFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := proxy.PutFile(&proxyproto.FPutRequest{
-		Name: sanitize.BaseName(handler.Filename),
+		Name: name,
 		Size: handler.Size,
 		Body: file,
 	}); err != nil {
@@ -0,0 +1,67 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/domain/hosts/{host} Domain putDomainHostRequest
+// ---
+// summary: Uploads the keytab of a host that domain joined desktops may lease.
+// description: Every domain joined desktop leases a host of its own for as long as it runs, so enough hosts must be stored for the desktops that may run at once.
+// parameters:
+// - name: host
+//   in: path
+//   description: The name of the host on the realm
+//   type: string
+//   required: true
+// - in: body
+//   name: domainKeytabRequest
+//   description: The keytab of the host.
+//   schema:
+//     "$ref": "#/definitions/DomainKeytabRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutDomainHost(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.DomainJoinIsEnabled() {
+		apiutil.ReturnAPIError(errors.New("Domain join is not configured on this cluster"), w)
+		return
+	}
+	req, ok := apiutil.GetRequestObject(r).(*types.DomainKeytabRequest)
+	if !ok || req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if err := d.domainHosts.SetKeytab(apiutil.GetHostFromRequest(r), req.Keytab); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
+)
+
+// Request containing the faults to inject
+// swagger:parameters putFaultsRequest
+type swaggerFaultInjectionRequest struct {
+	// in:body
+	Body types.FaultInjectionRequest
+}
+
+// swagger:route PUT /api/faults Miscellaneous putFaultsRequest
+// Replaces the faults injected into the server. This route is only served by servers
+// built with fault injection enabled.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PutFaults(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.FaultInjectionRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if err := faults.Set(req.Faults); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info("Updated injected faults", "User", apiutil.GetRequestUserSession(r).User.GetName(), "Faults", len(req.Faults))
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Request containing the latencies measured to each gateway
+// swagger:parameters putGatewayLatencyRequest
+type swaggerGatewayLatencyRequest struct {
+	// in:body
+	Body types.GatewayLatencyReport
+}
+
+// swagger:route PUT /api/gateways/latency Gateways putGatewayLatencyRequest
+// Reports the latencies the client measured to each gateway, and returns the gateway display connections should be routed through.
+// responses:
+//   200: gatewaySelectionResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PutGatewayLatency(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.GatewayLatencyReport)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user := apiutil.GetRequestUserSession(r).User
+	gateways := d.getGateways()
+	sel := d.gateways.Report(user.GetName(), gatewayNames(gateways), req.Latencies)
+	apiutil.WriteJSON(toGatewaySelection(gateways, sel), w)
+}
+
+// Selected gateway response
+// swagger:response gatewaySelectionResponse
+type swaggerGatewaySelectionResponse struct {
+	// in:body
+	Body types.GatewaySelection
+}
@@ -27,9 +27,11 @@ import (
 
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ktypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,12 +41,17 @@ import (
 // ---
 // summary: Update the specified role.
 // description: All properties will be overwritten with those provided in the payload, even if undefined.
+//   When an If-Match header is provided, the role is only updated if it is still at the given version.
 // parameters:
 // - name: role
 //   in: path
 //   description: The role to update
 //   type: string
 //   required: true
+// - name: If-Match
+//   in: header
+//   description: The version of the role the update is made against, from the ETag of a previous response.
+//   type: string
 // - in: body
 //   name: roleDetails
 //   description: The role details to update.
@@ -59,6 +66,8 @@ import (
 //     "$ref": "#/responses/error"
 //   "404":
 //     "$ref": "#/responses/error"
+//   "409":
+//     "$ref": "#/responses/error"
 func (d *desktopAPI) UpdateRole(w http.ResponseWriter, r *http.Request) {
 	role := apiutil.GetRoleFromRequest(r)
 	nn := ktypes.NamespacedName{Name: role, Namespace: metav1.NamespaceAll}
@@ -71,6 +80,14 @@ func (d *desktopAPI) UpdateRole(w http.ResponseWriter, r *http.Request) {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if !d.vdiCluster.OwnsObject(vdiRole) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The role '%s' doesn't exist", role), w)
+		return
+	}
+	if version := apiutil.GetIfMatch(r); version != "" && version != vdiRole.GetResourceVersion() {
+		d.returnRoleConflict(w, role)
+		return
+	}
 	params := apiutil.GetRequestObject(r).(*types.UpdateRoleRequest)
 	if params == nil {
 		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
@@ -78,10 +95,29 @@ func (d *desktopAPI) UpdateRole(w http.ResponseWriter, r *http.Request) {
 	}
 	vdiRole.Annotations = params.GetAnnotations()
 	vdiRole.Rules = params.GetRules()
+	vdiRole.Devices = params.Devices
+	vdiRole.AccessHours = params.AccessHours
+	vdiRole.Quotas = params.Quotas
+	vdiRole.BandwidthWeight = params.BandwidthWeight
+	vdiRole.InheritsFrom = params.InheritsFrom
+	if err := vdiRole.Validate(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := tenancy.CheckRole(d.vdiCluster, vdiRole); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if err := d.client.Update(context.TODO(), vdiRole); err != nil {
+		if apierrors.IsConflict(err) {
+			d.returnRoleConflict(w, role)
+			return
+		}
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	d.rbacCache.invalidate()
+	apiutil.SetETag(w, vdiRole.GetResourceVersion())
 	apiutil.WriteOK(w)
 }
 
@@ -24,8 +24,10 @@ import (
 	"net/http"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -35,12 +37,17 @@ import (
 // ---
 // summary: Update the specified DesktopTemplate.
 // description: Only attributes defined in the payload will be applied.
+//   When an If-Match header is provided, the template is only updated if it is still at the given version.
 // parameters:
 // - name: template
 //   in: path
 //   description: The DesktopTemplate to update
 //   type: string
 //   required: true
+// - name: If-Match
+//   in: header
+//   description: The version of the template the update is made against, from the ETag of a previous response.
+//   type: string
 // - in: body
 //   name: templateDetails
 //   description: The manifest to merge with the existing template.
@@ -55,6 +62,8 @@ import (
 //     "$ref": "#/responses/error"
 //   "404":
 //     "$ref": "#/responses/error"
+//   "409":
+//     "$ref": "#/responses/error"
 func (d *desktopAPI) PutDesktopTemplate(w http.ResponseWriter, r *http.Request) {
 	tmplName := apiutil.GetTemplateFromRequest(r)
 	nn := types.NamespacedName{Name: tmplName, Namespace: metav1.NamespaceAll}
@@ -67,6 +76,14 @@ func (d *desktopAPI) PutDesktopTemplate(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if err := tenancy.CheckTemplate(d.vdiCluster, tmpl); err != nil {
+		apiutil.ReturnAPINotFound(err, w)
+		return
+	}
+	if version := apiutil.GetIfMatch(r); version != "" && version != tmpl.GetResourceVersion() {
+		d.returnTemplateConflict(w, tmplName)
+		return
+	}
 	// This will replace fields in the existing object with any provided in the
 	// payload
 	if err := apiutil.UnmarshalRequest(r, tmpl); err != nil {
@@ -74,11 +91,27 @@ func (d *desktopAPI) PutDesktopTemplate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := d.lintTemplate(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	results := tmpl.Status.LintResults
+
 	if err := d.client.Update(context.TODO(), tmpl); err != nil {
+		if apierrors.IsConflict(err) {
+			d.returnTemplateConflict(w, tmplName)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if err := d.writeTemplateLintStatus(tmpl, results); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
+	apiutil.SetETag(w, tmpl.GetResourceVersion())
 	apiutil.WriteOK(w)
 }
 
@@ -22,6 +22,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -31,12 +32,17 @@ import (
 // ---
 // summary: Update the specified user.
 // description: Only the provided attributes will be updated.
+//   When an If-Match header is provided, the user is only updated if it is still at the given version.
 // parameters:
 // - name: user
 //   in: path
 //   description: The user to update
 //   type: string
 //   required: true
+// - name: If-Match
+//   in: header
+//   description: The version of the user the update is made against, from the ETag of a previous response.
+//   type: string
 // - in: body
 //   name: userDetails
 //   description: The user details to update.
@@ -51,6 +57,8 @@ import (
 //     "$ref": "#/responses/error"
 //   "404":
 //     "$ref": "#/responses/error"
+//   "409":
+//     "$ref": "#/responses/error"
 func (d *desktopAPI) PutUser(w http.ResponseWriter, r *http.Request) {
 	username := apiutil.GetUserFromRequest(r)
 	req := apiutil.GetRequestObject(r).(*types.UpdateUserRequest)
@@ -59,15 +67,31 @@ func (d *desktopAPI) PutUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := d.auth.UpdateUser(username, req); err != nil {
+	var err error
+	if versioned, ok := d.auth.(common.VersionedProvider); ok {
+		err = versioned.UpdateUserIfVersion(username, apiutil.GetIfMatch(r), req)
+	} else {
+		err = d.auth.UpdateUser(username, req)
+	}
+	if err != nil {
 		if errors.IsUserNotFoundError(err) {
 			apiutil.ReturnAPINotFound(err, w)
 			return
 		}
+		if errors.IsConflictError(err) {
+			d.returnUserConflict(w, username)
+			return
+		}
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
+	version, err := d.getUserVersion(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.SetETag(w, version)
 	apiutil.WriteOK(w)
 }
 
@@ -0,0 +1,80 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/metadata/{namespace}/{key} Users putUserMetadataValueRequest
+// ---
+// summary: Sets a value in the given user's metadata.
+// description: Namespaces and keys may contain letters, numbers, '.', '_', and '-'. Each user may store up to 16KiB of metadata.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The metadata namespace of the key
+//   type: string
+//   required: true
+// - name: key
+//   in: path
+//   description: The key to set
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserMetadataValueRequest
+//   description: The value to store.
+//   schema:
+//     "$ref": "#/definitions/UserMetadataValue"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserMetadataValue(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*types.UserMetadataValue)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if err := d.metadata.SetValue(apiutil.GetUserFromRequest(r), apiutil.GetNamespaceFromRequest(r), apiutil.GetKeyFromRequest(r), req.Value); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// Request containing a value for a user's metadata
+// swagger:parameters putUserMetadataValueRequest
+type swaggerPutUserMetadataValueRequest struct {
+	// in:body
+	Body types.UserMetadataValue
+}
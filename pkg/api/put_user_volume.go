@@ -0,0 +1,114 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// swagger:operation PUT /api/users/{user}/volume Users putUserVolumeRequest
+// ---
+// summary: Expands the volume holding the $HOME directory of the specified user.
+// description: |
+//   The volume is expanded through the claim mounting it, so one of the user's desktops must
+//   be running, and the storage class of the volume must allow expansion. Depending on the
+//   storage provisioner, the new capacity may only be visible after the desktop is restarted.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user whose volume to expand
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserVolumeRequest
+//   description: The new size of the volume.
+//   schema:
+//     "$ref": "#/definitions/ExpandUserVolumeRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserVolumeResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserVolume(w http.ResponseWriter, r *http.Request) {
+	if d.vdiCluster.GetUserdataVolumeSpec() == nil {
+		apiutil.ReturnAPIError(errNoUserdataSpec, w)
+		return
+	}
+
+	req := apiutil.GetRequestObject(r).(*types.ExpandUserVolumeRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	size, err := req.GetSize()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	username := apiutil.GetUserFromRequest(r)
+	pv, pvc, err := d.getUserVolume(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if pv == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("No volume has been provisioned for user '%s'", username), w)
+		return
+	}
+	if pvc == nil {
+		apiutil.ReturnAPIError(errors.New("The volume can only be expanded while one of the user's desktops is running"), w)
+		return
+	}
+
+	if current, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok && size.Cmp(current) <= 0 {
+		apiutil.ReturnAPIError(fmt.Errorf("The new size must be larger than the current size of %s", current.String()), w)
+		return
+	}
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = make(corev1.ResourceList)
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	if err := d.client.Update(context.TODO(), pvc); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(d.newUserVolume(pv, pvc), w)
+}
+
+// Request containing the new size of a user's volume
+// swagger:parameters putUserVolumeRequest
+type swaggerPutUserVolumeRequest struct {
+	// in:body
+	Body types.ExpandUserVolumeRequest
+}
@@ -23,6 +23,7 @@ import (
 	"context"
 
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
 
@@ -51,7 +52,7 @@ func (r *ResourceGetter) GetUsers() ([]types.VDIUser, error) {
 
 // GetRoles returns a list of all the VDIRolse for this cluster.
 func (r *ResourceGetter) GetRoles() ([]types.VDIUserRole, error) {
-	roles, err := r.api.vdiCluster.GetRoles(r.api.client)
+	roles, err := r.api.getRoles()
 	if err != nil {
 		apiLogger.Error(err, "Failed to list VDI roles")
 		return nil, err
@@ -71,7 +72,7 @@ func (r *ResourceGetter) GetTemplates() ([]string, error) {
 		return nil, err
 	}
 	tmplNames := make([]string, 0)
-	for _, tmpl := range tmplList.Items {
+	for _, tmpl := range tenancy.FilterTemplates(r.api.vdiCluster, tmplList.Trim()) {
 		tmplNames = append(tmplNames, tmpl.GetName())
 	}
 	return tmplNames, nil
@@ -43,7 +43,7 @@ type Buffer struct {
 	pbkReader                                                      io.ReadCloser
 	recWriter                                                      io.WriteCloser
 	micSinkPipeline                                                *gst.Pipeline
-	channels, sampleRate, micChannels, micSampleRate               int
+	channels, sampleRate, bitrate, micChannels, micSampleRate      int
 	pulseServer, pulseFormat, pulseMonitor, pulseMic, pulseMicPath string
 	closed                                                         bool
 	wmux                                                           sync.Mutex
@@ -66,6 +66,7 @@ func NewBuffer(opts *BufferOpts) *Buffer {
 		micChannels:   opts.getPulseMicChannels(),
 		micSampleRate: opts.getPulseMicRate(),
 		sampleRate:    opts.getPulsePlaybackRate(),
+		bitrate:       opts.getPulsePlaybackBitrate(),
 		pulseMonitor:  opts.getPulseMonitorName(),
 		pulseMic:      opts.getMicName(),
 		pulseMicPath:  opts.getMicPath(),
@@ -110,6 +111,7 @@ func (a *Buffer) newPlaybackPipeline() (io.ReadCloser, error) {
 			SourceFormat:   a.pulseFormat,
 			SourceRate:     a.sampleRate,
 			SourceChannels: a.channels,
+			Bitrate:        a.bitrate,
 		},
 	)
 }
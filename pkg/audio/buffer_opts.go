@@ -45,6 +45,8 @@ type BufferOpts struct {
 	PulseMonitorName string
 	// The sample rate to use on the playback monitor. Defaults to 24000.
 	PulseMonitorSampleRate int
+	// The bitrate in bits per second to encode playback at. Defaults to 64000.
+	PulseMonitorBitrate int
 	// The number of channels to record on the playback monitor. Defaults to 2.
 	PulseMonitorChannels int
 	// The name of the PulseSource to write to when recording on the write-buffer.
@@ -95,6 +97,13 @@ func (o *BufferOpts) getPulsePlaybackRate() int {
 	return o.PulseMonitorSampleRate
 }
 
+func (o *BufferOpts) getPulsePlaybackBitrate() int {
+	if o.PulseMonitorBitrate == 0 {
+		return 64000
+	}
+	return o.PulseMonitorBitrate
+}
+
 func (o *BufferOpts) getPulseMonitorChannels() int {
 	if o.PulseMonitorChannels == 0 {
 		return 2
@@ -34,6 +34,8 @@ import (
 type playbackPipelineOpts struct {
 	PulseServer, DeviceName, SourceFormat string
 	SourceRate, SourceChannels            int
+	// The bitrate to encode at, the encoder's default is used when zero.
+	Bitrate int
 }
 
 type pipelineReader struct {
@@ -67,6 +69,12 @@ func newPlaybackPipelineReader(log logr.Logger, errors chan error, opts *playbac
 		return
 	}
 
+	if opts.Bitrate > 0 {
+		if err = opusenc.SetProperty("bitrate", opts.Bitrate); err != nil {
+			return
+		}
+	}
+
 	pulsecaps := newRawCaps(opts.SourceFormat, opts.SourceRate, opts.SourceChannels)
 
 	r, w := io.Pipe()
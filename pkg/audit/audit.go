@@ -0,0 +1,164 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"sync"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// auditLogger is where events are logged when using the stdout sink, and where errors
+// writing to other sinks are reported.
+var auditLogger = logf.Log.WithName("api_audit")
+
+// Sink is a destination for audit events.
+type Sink interface {
+	// Write records the given event.
+	Write(*types.AuditEvent) error
+	// Close flushes any pending events and releases the resources held by the sink.
+	Close() error
+}
+
+// Logger records audit events to the sink configured on the VDICluster and keeps the
+// most recent of them in memory.
+type Logger struct {
+	mu sync.RWMutex
+	// the sink events are written to
+	sink Sink
+	// the configuration the sink was built from
+	config appv1.AuditConfig
+	// whether the audit log was enabled when the sink was built
+	enabled bool
+	// a ring buffer of the most recent events
+	events []*types.AuditEvent
+	// the index in the ring buffer the next event is written to
+	next int
+}
+
+// NewLogger returns a new Logger. Until Setup is called events are logged to stdout.
+func NewLogger() *Logger {
+	return &Logger{sink: newStdoutSink()}
+}
+
+// Setup builds the sink and sizes the in-memory buffer from the configuration on the given
+// VDICluster. It is safe to call again when the configuration changes. Events are always
+// logged to stdout when the audit log is disabled, for the ones recorded regardless of
+// the configuration.
+func (l *Logger) Setup(c client.Client, cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	enabled := cluster.AuditLogEnabled()
+	config := *cluster.GetAuditConfig()
+	if l.sink == nil || enabled != l.enabled || config != l.config {
+		sink := newStdoutSink()
+		if enabled {
+			var err error
+			if sink, err = newSink(c, cluster, secretsEngine); err != nil {
+				return err
+			}
+		}
+		if l.sink != nil {
+			if err := l.sink.Close(); err != nil {
+				auditLogger.Error(err, "Failed to close the previous audit sink")
+			}
+		}
+		l.sink = sink
+		l.config = config
+		l.enabled = enabled
+	}
+
+	l.resize(cluster.GetAuditRetention())
+	return nil
+}
+
+// Record writes the event to the sink and adds it to the in-memory buffer.
+func (l *Logger) Record(ev *types.AuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.sink.Write(ev); err != nil {
+		auditLogger.Error(err, "Failed to write audit event", "Message", ev.Message)
+	}
+	if len(l.events) == 0 {
+		return
+	}
+	l.events[l.next] = ev
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// Query returns the events in the in-memory buffer matching the given query, most
+// recent first.
+func (l *Logger) Query(q *types.AuditQuery) []*types.AuditEvent {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]*types.AuditEvent, 0)
+	for i := 1; i <= len(l.events); i++ {
+		ev := l.events[(l.next-i+len(l.events))%len(l.events)]
+		if ev == nil {
+			// the buffer has not wrapped around yet
+			break
+		}
+		if !q.Matches(ev) {
+			continue
+		}
+		out = append(out, ev)
+		if q.Limit > 0 && len(out) == q.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Close closes the sink. Events recorded afterwards are logged to stdout.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.sink.Close()
+	l.sink = newStdoutSink()
+	l.enabled = false
+	return err
+}
+
+// resize changes the size of the in-memory buffer, keeping the most recent events that
+// fit. It must be called with the lock held.
+func (l *Logger) resize(size int) {
+	if size == len(l.events) {
+		return
+	}
+	events := make([]*types.AuditEvent, size)
+	// walk the current buffer from oldest to newest so the newest are kept
+	n := 0
+	for i := 0; i < len(l.events); i++ {
+		ev := l.events[(l.next+i)%len(l.events)]
+		if ev == nil {
+			continue
+		}
+		events[n%size] = ev
+		n++
+	}
+	l.events = events
+	l.next = n % size
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func newTestCluster(cfg *appv1.AuditConfig) *appv1.VDICluster {
+	cluster := &appv1.VDICluster{}
+	cluster.Spec.App = &appv1.AppConfig{Audit: cfg}
+	return cluster
+}
+
+func newTestEvent(user string, ts time.Time) *types.AuditEvent {
+	return &types.AuditEvent{Timestamp: ts, User: user, Allowed: true, Message: "ALLOWED " + user}
+}
+
+func TestLoggerQuery(t *testing.T) {
+	l := NewLogger()
+	if err := l.Setup(nil, newTestCluster(&appv1.AuditConfig{Retention: 3}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().UTC()
+	for i, user := range []string{"alice", "bob", "alice", "bob"} {
+		l.Record(newTestEvent(user, start.Add(time.Duration(i)*time.Minute)))
+	}
+
+	// the oldest event no longer fits in the buffer
+	events := l.Query(&types.AuditQuery{})
+	if len(events) != 3 {
+		t.Fatal("Expected the three most recent events, got:", len(events))
+	}
+	if !events[0].Timestamp.Equal(start.Add(3*time.Minute)) || !events[2].Timestamp.Equal(start.Add(time.Minute)) {
+		t.Error("Expected the most recent events first, got:", events[0].Timestamp, events[2].Timestamp)
+	}
+
+	if events := l.Query(&types.AuditQuery{User: "alice"}); len(events) != 1 || events[0].User != "alice" {
+		t.Error("Expected one event for alice, got:", events)
+	}
+	if events := l.Query(&types.AuditQuery{Since: start.Add(2 * time.Minute)}); len(events) != 2 {
+		t.Error("Expected two events since the start of the range, got:", len(events))
+	}
+	if events := l.Query(&types.AuditQuery{Until: start.Add(2 * time.Minute)}); len(events) != 1 {
+		t.Error("Expected one event before the end of the range, got:", len(events))
+	}
+	if events := l.Query(&types.AuditQuery{Limit: 1}); len(events) != 1 || events[0].User != "bob" {
+		t.Error("Expected only the most recent event, got:", events)
+	}
+
+	// shrinking the buffer keeps the most recent events
+	if err := l.Setup(nil, newTestCluster(&appv1.AuditConfig{Retention: 2}), nil); err != nil {
+		t.Fatal(err)
+	}
+	events = l.Query(&types.AuditQuery{})
+	if len(events) != 2 || !events[0].Timestamp.Equal(start.Add(3*time.Minute)) {
+		t.Error("Expected the two most recent events after resizing, got:", events)
+	}
+	l.Record(newTestEvent("carol", start.Add(4*time.Minute)))
+	if events := l.Query(&types.AuditQuery{}); len(events) != 2 || events[0].User != "carol" {
+		t.Error("Expected new events to be recorded after resizing, got:", events)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := os.MkdirTemp("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "audit.log")
+
+	l := NewLogger()
+	if err := l.Setup(nil, newTestCluster(&appv1.AuditConfig{Sink: appv1.AuditSinkFile, FilePath: path}), nil); err != nil {
+		t.Fatal(err)
+	}
+	l.Record(newTestEvent("alice", time.Now()))
+	l.Record(newTestEvent("bob", time.Now()))
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	users := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ev := &types.AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), ev); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, ev.User)
+	}
+	if len(users) != 2 || users[0] != "alice" || users[1] != "bob" {
+		t.Error("Expected an event per line in the file, got:", users)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan *types.AuditEvent, 1)
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev := &types.AuditEvent{}
+		if err := json.NewDecoder(r.Body).Decode(ev); err != nil {
+			t.Error(err)
+		}
+		received <- ev
+	}))
+	defer srvr.Close()
+
+	l := NewLogger()
+	if err := l.Setup(nil, newTestCluster(&appv1.AuditConfig{Sink: appv1.AuditSinkWebhook}), nil); err == nil {
+		t.Error("Expected error for webhook sink without a URL")
+	}
+	if err := l.Setup(nil, newTestCluster(&appv1.AuditConfig{Sink: appv1.AuditSinkWebhook, WebhookURL: srvr.URL}), nil); err != nil {
+		t.Fatal(err)
+	}
+	l.Record(newTestEvent("alice", time.Now()))
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-received:
+		if ev.User != "alice" {
+			t.Error("Expected the event for alice, got:", ev)
+		}
+	default:
+		t.Error("Expected the event to be sent before the sink was closed")
+	}
+}
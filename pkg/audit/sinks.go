@@ -0,0 +1,228 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// queueSize is the number of events sinks that send them over the network buffer before
+// dropping new ones.
+const queueSize = 256
+
+// newSink returns the sink configured on the given VDICluster.
+func newSink(c client.Client, cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) (Sink, error) {
+	cfg := cluster.GetAuditConfig()
+	switch cluster.GetAuditSink() {
+	case appv1.AuditSinkStdout:
+		return newStdoutSink(), nil
+	case appv1.AuditSinkFile:
+		return newFileSink(cluster.GetAuditFilePath())
+	case appv1.AuditSinkWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, errors.New("A webhook URL is required for the webhook audit sink")
+		}
+		var token string
+		if cfg.WebhookTokenSecret != "" {
+			contents, err := secretsEngine.ReadSecret(cfg.WebhookTokenSecret, true)
+			if err != nil {
+				return nil, err
+			}
+			token = strings.TrimSpace(string(contents))
+		}
+		return newQueuedSink(newWebhookSender(cfg.WebhookURL, token)), nil
+	case appv1.AuditSinkEvent:
+		return newQueuedSink(newEventSender(c, cluster)), nil
+	default:
+		return nil, fmt.Errorf("Unknown audit sink: %s", cluster.GetAuditSink())
+	}
+}
+
+// stdoutSink logs events to stdout with the rest of the app logs.
+type stdoutSink struct{ log logr.Logger }
+
+func newStdoutSink() Sink { return &stdoutSink{log: auditLogger} }
+
+func (s *stdoutSink) Write(ev *types.AuditEvent) error {
+	keysAndValues := []interface{}{
+		"Allowed", ev.Allowed,
+		"Username", ev.User,
+		"RequestMethod", ev.Method,
+		"RequestPath", ev.Path,
+		"RequestOrigin", ev.SourceIP,
+		"RequestForwardedFor", ev.ForwardedFor,
+		"APIActions", ev.Actions,
+	}
+	for key, val := range ev.Details {
+		keysAndValues = append(keysAndValues, key, val)
+	}
+	s.log.Info(ev.Message, keysAndValues...)
+	return nil
+}
+
+func (s *stdoutSink) Close() error { return nil }
+
+// fileSink appends events to a file as JSON lines.
+type fileSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func newFileSink(path string) (Sink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *fileSink) Write(ev *types.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// queuedSink hands events to a sender in the background, so requests are not held up by
+// a slow remote. Events are dropped when the queue is full.
+type queuedSink struct {
+	queue chan *types.AuditEvent
+	done  chan struct{}
+}
+
+func newQueuedSink(send func(*types.AuditEvent) error) Sink {
+	s := &queuedSink{
+		queue: make(chan *types.AuditEvent, queueSize),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		for ev := range s.queue {
+			if err := send(ev); err != nil {
+				auditLogger.Error(err, "Failed to send audit event", "Message", ev.Message)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *queuedSink) Write(ev *types.AuditEvent) error {
+	select {
+	case s.queue <- ev:
+		return nil
+	default:
+		return errors.New("The audit queue is full, dropping event")
+	}
+}
+
+func (s *queuedSink) Close() error {
+	close(s.queue)
+	<-s.done
+	return nil
+}
+
+// newWebhookSender returns a function that POSTs events to the given URL.
+func newWebhookSender(url, token string) func(*types.AuditEvent) error {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	return func(ev *types.AuditEvent) error {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("Audit webhook returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// newEventSender returns a function that records events as Kubernetes Events on the
+// given VDICluster.
+func newEventSender(c client.Client, cluster *appv1.VDICluster) func(*types.AuditEvent) error {
+	ref := corev1.ObjectReference{
+		APIVersion: appv1.GroupVersion.String(),
+		Kind:       "VDICluster",
+		Name:       cluster.GetName(),
+		UID:        cluster.GetUID(),
+	}
+	return func(ev *types.AuditEvent) error {
+		eventType, reason := corev1.EventTypeNormal, "RequestAllowed"
+		if !ev.Allowed {
+			eventType, reason = corev1.EventTypeWarning, "RequestDenied"
+		}
+		ts := metav1.NewTime(ev.Timestamp)
+		return c.Create(context.TODO(), &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s.%x", cluster.GetName(), ev.Timestamp.UnixNano()),
+				Namespace: cluster.GetCoreNamespace(),
+				Labels:    cluster.GetComponentLabels("audit"),
+			},
+			InvolvedObject: ref,
+			Reason:         reason,
+			Message:        ev.Message,
+			Type:           eventType,
+			Source:         corev1.EventSource{Component: cluster.GetAppName()},
+			FirstTimestamp: ts,
+			LastTimestamp:  ts,
+			Count:          1,
+		})
+	}
+}
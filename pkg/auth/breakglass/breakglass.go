@@ -0,0 +1,264 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package breakglass
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// ChallengeTTL is how long an unlock challenge may be signed and presented for.
+const ChallengeTTL = 5 * time.Minute
+
+// MaxChallenges is the maximum number of challenges that may be outstanding at once.
+const MaxChallenges = 100
+
+// ChallengeRateLimit is the number of challenges a single source may request from an
+// API replica within ChallengeRateWindow.
+const ChallengeRateLimit = 5
+
+// ChallengeRateWindow is the window over which ChallengeRateLimit applies.
+const ChallengeRateWindow = time.Minute
+
+// ErrRateLimited is returned when a source requests challenges too quickly.
+var ErrRateLimited = errors.New("Too many break-glass challenges were requested, try again later")
+
+// ErrTooManyChallenges is returned when the maximum number of challenges are outstanding.
+var ErrTooManyChallenges = errors.New("Too many break-glass challenges are outstanding, try again later")
+
+// challengePrefix is prepended to challenges so a signature over one cannot be mistaken
+// for a signature over anything else.
+const challengePrefix = "kvdi-break-glass:"
+
+// Manager issues and consumes unlock challenges. Outstanding challenges are kept in the
+// secrets backend so any API replica can consume them.
+type Manager struct {
+	secrets *secrets.SecretEngine
+	// the times challenges were requested by each source, for rate limiting
+	requests map[string][]time.Time
+	mux      sync.Mutex
+}
+
+// NewManager returns a new break-glass manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets, requests: make(map[string][]time.Time)}
+}
+
+// IssueChallenge creates a new challenge for the given source, usually the address of the
+// client, that is valid until the returned time. Expired challenges are pruned along the
+// way. ErrRateLimited is returned if the source requested too many challenges recently,
+// and ErrTooManyChallenges if too many are outstanding.
+func (m *Manager) IssueChallenge(source string) (string, time.Time, error) {
+	if !m.allow(source, time.Now()) {
+		return "", time.Time{}, ErrRateLimited
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	challenge := challengePrefix + base64.RawURLEncoding.EncodeToString(buf)
+	expiresAt := time.Now().Add(ChallengeTTL)
+
+	if err := m.secrets.Lock(10); err != nil {
+		return "", time.Time{}, err
+	}
+	defer m.secrets.Release()
+	challenges, err := m.readChallenges()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	pruneChallenges(challenges, time.Now())
+	if len(challenges) >= MaxChallenges {
+		return "", time.Time{}, ErrTooManyChallenges
+	}
+	challenges[challenge] = []byte(strconv.FormatInt(expiresAt.Unix(), 10))
+	if err := m.secrets.WriteSecretMap(v1.BreakGlassChallengesSecretKey, challenges); err != nil {
+		return "", time.Time{}, err
+	}
+	return challenge, expiresAt, nil
+}
+
+// ConsumeChallenge removes the given challenge so it cannot be used again, and returns an
+// error if it was never issued or has expired. Expired challenges are pruned along the way.
+func (m *Manager) ConsumeChallenge(challenge string) error {
+	if err := m.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	challenges, err := m.readChallenges()
+	if err != nil {
+		return err
+	}
+	expiry, ok := challenges[challenge]
+	delete(challenges, challenge)
+	now := time.Now()
+	pruneChallenges(challenges, now)
+	if err := m.secrets.WriteSecretMap(v1.BreakGlassChallengesSecretKey, challenges); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("The challenge was not issued or has already been used")
+	}
+	if challengeExpired(expiry, now) {
+		return errors.New("The challenge has expired")
+	}
+	return nil
+}
+
+func (m *Manager) readChallenges() (map[string][]byte, error) {
+	challenges, err := m.secrets.ReadSecretMap(v1.BreakGlassChallengesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return make(map[string][]byte), nil
+		}
+		return nil, err
+	}
+	return challenges, nil
+}
+
+// allow records a challenge request from the given source, and returns false if the
+// source has exceeded the rate limit.
+func (m *Manager) allow(source string, now time.Time) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for key, times := range m.requests {
+		recent := times[:0]
+		for _, t := range times {
+			if now.Sub(t) < ChallengeRateWindow {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(m.requests, key)
+			continue
+		}
+		m.requests[key] = recent
+	}
+	if len(m.requests[source]) >= ChallengeRateLimit {
+		return false
+	}
+	m.requests[source] = append(m.requests[source], now)
+	return true
+}
+
+// pruneChallenges removes the expired challenges from the given map.
+func pruneChallenges(challenges map[string][]byte, now time.Time) {
+	for key, val := range challenges {
+		if challengeExpired(val, now) {
+			delete(challenges, key)
+		}
+	}
+}
+
+func challengeExpired(expiry []byte, now time.Time) bool {
+	unix, err := strconv.ParseInt(string(expiry), 10, 64)
+	return err != nil || !now.Before(time.Unix(unix, 0))
+}
+
+// ParsePublicKey parses a base64 encoded PEM public key. RSA, ECDSA, and Ed25519 keys are
+// supported.
+func ParsePublicKey(encoded string) (crypto.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("No PEM data found in the break-glass public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, errors.New("The break-glass public key is not an RSA, ECDSA, or Ed25519 key")
+}
+
+// Verify checks the given signature over a challenge against the public key. RSA
+// signatures are expected in PKCS #1 v1.5 form and ECDSA signatures in ASN.1 form, both
+// over the SHA-256 digest of the challenge. Ed25519 signatures are over the challenge
+// itself.
+func Verify(key crypto.PublicKey, challenge string, signature []byte) error {
+	digest := sha256.Sum256([]byte(challenge))
+	var valid bool
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(pub, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pub, []byte(challenge), signature)
+	default:
+		return errors.New("Unsupported break-glass public key")
+	}
+	if !valid {
+		return errors.New("The signature does not match the break-glass public key")
+	}
+	return nil
+}
+
+// Sign signs a challenge with the given private key in the form expected by Verify.
+func Sign(key crypto.Signer, challenge string) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, []byte(challenge), crypto.Hash(0))
+	}
+	digest := sha256.Sum256([]byte(challenge))
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// ParsePrivateKey parses a PEM private key for signing challenges. PKCS #8, PKCS #1, and
+// SEC 1 encoded keys are supported.
+func ParsePrivateKey(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("No PEM data found in the private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("The private key cannot be used for signing")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("The private key is not a PKCS #8, PKCS #1, or SEC 1 encoded key")
+}
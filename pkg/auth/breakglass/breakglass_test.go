@@ -0,0 +1,169 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package breakglass
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func encodePrivateKey(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := challengePrefix + "test"
+	for name, key := range map[string]crypto.Signer{"rsa": rsaKey, "ecdsa": ecKey, "ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			signer, err := ParsePrivateKey(encodePrivateKey(t, key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := ParsePublicKey(encodePublicKey(t, key.Public()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := Sign(signer, challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(pub, challenge, sig); err != nil {
+				t.Error("Expected signature to verify, got:", err)
+			}
+			if err := Verify(pub, challenge+"x", sig); err == nil {
+				t.Error("Expected signature over a different challenge to fail")
+			}
+		})
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Sign(key, "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(&other.PublicKey, "challenge", sig); err == nil {
+		t.Error("Expected signature from another key to fail")
+	}
+}
+
+func TestParsePublicKeyInvalid(t *testing.T) {
+	if _, err := ParsePublicKey("not base64!"); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+	if _, err := ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("no pem here"))); err == nil {
+		t.Error("Expected error for missing PEM data")
+	}
+}
+
+func TestChallengeExpired(t *testing.T) {
+	now := time.Now()
+	if challengeExpired([]byte(strconv.FormatInt(now.Add(time.Minute).Unix(), 10)), now) {
+		t.Error("Expected future expiry to be valid")
+	}
+	if !challengeExpired([]byte(strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)), now) {
+		t.Error("Expected past expiry to be expired")
+	}
+	if !challengeExpired([]byte("garbage"), now) {
+		t.Error("Expected unparseable expiry to be expired")
+	}
+}
+
+func TestChallengeRateLimit(t *testing.T) {
+	m := NewManager(nil)
+	now := time.Now()
+	for i := 0; i < ChallengeRateLimit; i++ {
+		if !m.allow("10.0.0.1", now) {
+			t.Fatal("Expected request to be allowed within the rate limit")
+		}
+	}
+	if m.allow("10.0.0.1", now) {
+		t.Error("Expected request beyond the rate limit to be denied")
+	}
+	if !m.allow("10.0.0.2", now) {
+		t.Error("Expected requests from other sources to be allowed")
+	}
+	if !m.allow("10.0.0.1", now.Add(ChallengeRateWindow)) {
+		t.Error("Expected request to be allowed after the window passed")
+	}
+	if _, ok := m.requests["10.0.0.2"]; ok {
+		t.Error("Expected sources without recent requests to be forgotten")
+	}
+}
+
+func TestPruneChallenges(t *testing.T) {
+	now := time.Now()
+	challenges := map[string][]byte{
+		"valid":   []byte(strconv.FormatInt(now.Add(time.Minute).Unix(), 10)),
+		"expired": []byte(strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)),
+		"garbage": []byte("garbage"),
+	}
+	pruneChallenges(challenges, now)
+	if len(challenges) != 1 {
+		t.Error("Expected only the valid challenge to remain, got:", challenges)
+	}
+	if _, ok := challenges["valid"]; !ok {
+		t.Error("Expected the valid challenge to remain")
+	}
+}
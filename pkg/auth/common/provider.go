@@ -61,3 +61,37 @@ type AuthProvider interface {
 	// DeleteUser should remove a VDIUser
 	DeleteUser(string) error
 }
+
+// DirectoryProvider is an optional interface an AuthProvider can implement to search
+// the directory backing it for users and groups, including users that have not logged
+// in to kVDI yet. Providers that don't implement it are searched through the users
+// returned by GetUsers and the groups bound to roles.
+type DirectoryProvider interface {
+	// SearchDirectory should return the users and groups matching the request. It may
+	// return more entries than the request's limit, they are truncated by the API.
+	SearchDirectory(*types.DirectorySearchRequest) ([]*types.DirectoryEntry, error)
+}
+
+// RestorableProvider is an optional interface an AuthProvider can implement to let users
+// deleted through the API be restored for a time. Users of providers that don't implement
+// it are deleted permanently right away.
+type RestorableProvider interface {
+	// ExportUser should return the user, including any credentials, in a form ImportUser
+	// can recreate them from.
+	ExportUser(string) ([]byte, error)
+	// ImportUser should recreate a user from the output of ExportUser. It should fail if
+	// the user already exists.
+	ImportUser([]byte) error
+}
+
+// VersionedProvider is an optional interface an AuthProvider can implement to detect
+// concurrent updates to users. Users of providers that don't implement it are always
+// updated with the latest request.
+type VersionedProvider interface {
+	// GetUserVersion should return an opaque version of the user that changes whenever
+	// the user is updated.
+	GetUserVersion(string) (string, error)
+	// UpdateUserIfVersion should update the user the same as UpdateUser, but only if the
+	// user is still at the given version. A ConflictError should be returned otherwise.
+	UpdateUserIfVersion(string, string, *types.UpdateUserRequest) error
+}
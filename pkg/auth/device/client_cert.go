@@ -0,0 +1,70 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package device
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+// clientCertVerifier verifies TLS client certificates presented to the app server.
+type clientCertVerifier struct {
+	name  string
+	roots *x509.CertPool
+}
+
+func newClientCertVerifier(issuer appv1.DeviceTrustIssuer) (Verifier, error) {
+	caCert, err := base64.StdEncoding.DecodeString(issuer.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if ok := roots.AppendCertsFromPEM(caCert); !ok {
+		return nil, fmt.Errorf("No valid certificates found for device trust issuer %q", issuer.Name)
+	}
+	return &clientCertVerifier{name: issuer.Name, roots: roots}, nil
+}
+
+// Verify implements Verifier.
+func (c *clientCertVerifier) Verify(r *http.Request) (*Assertion, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, errNoAssertion
+	}
+	leaf := r.TLS.PeerCertificates[0]
+	// a CA certificate does not identify a device, even if it is one of the trusted roots
+	if leaf.IsCA {
+		return nil, fmt.Errorf("The client certificate %q is a CA certificate", leaf.Subject.CommonName)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, err
+	}
+	return &Assertion{Issuer: c.name, Device: leaf.Subject.CommonName}, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Assertion represents a verified device assertion.
+type Assertion struct {
+	// The name of the issuer that verified the device
+	Issuer string
+	// The identity of the device as presented by the issuer
+	Device string
+}
+
+// Verifier is an interface for verifying device assertions presented with a request.
+// Additional assertion methods can be supported by implementing this interface.
+type Verifier interface {
+	// Verify returns the verified assertion presented with the request, or an error
+	// if none was presented or it could not be verified.
+	Verify(r *http.Request) (*Assertion, error)
+}
+
+// errNoAssertion is returned when a request does not carry an assertion for a verifier.
+var errNoAssertion = errors.New("No device assertion was presented in the request")
+
+// NewVerifier returns a Verifier for the given issuer configuration.
+func NewVerifier(issuer appv1.DeviceTrustIssuer) (Verifier, error) {
+	switch issuer.Method {
+	case appv1.DeviceTrustClientCert:
+		return newClientCertVerifier(issuer)
+	case appv1.DeviceTrustHeader:
+		return newHeaderVerifier(issuer)
+	default:
+		return nil, fmt.Errorf("Unknown device trust method %q for issuer %q", issuer.Method, issuer.Name)
+	}
+}
+
+// Manager is an object for verifying devices against the issuers configured for a
+// VDICluster.
+type Manager struct {
+	verifiers []Verifier
+	roles     []string
+	mux       sync.RWMutex
+}
+
+// NewManager returns a new device trust manager. Setup must be called before it is used.
+func NewManager() *Manager {
+	return &Manager{verifiers: make([]Verifier, 0)}
+}
+
+// Setup builds verifiers for the issuers configured on the given cluster. It can be called
+// again to refresh the configuration.
+func (m *Manager) Setup(cluster *appv1.VDICluster) error {
+	verifiers := make([]Verifier, 0)
+	for _, issuer := range cluster.GetDeviceTrustIssuers() {
+		verifier, err := NewVerifier(issuer)
+		if err != nil {
+			return err
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.verifiers = verifiers
+	m.roles = cluster.GetDeviceTrustRoles()
+	return nil
+}
+
+// RequiredFor returns true if the given user must present a trusted device before
+// accessing a desktop session.
+func (m *Manager) RequiredFor(user *types.VDIUser) bool {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if len(m.verifiers) == 0 {
+		return false
+	}
+	if len(m.roles) == 0 {
+		return true
+	}
+	for _, role := range user.Roles {
+		for _, name := range m.roles {
+			if role.GetName() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verify checks the request against every configured issuer and returns the first
+// assertion that is successfully verified.
+func (m *Manager) Verify(r *http.Request) (*Assertion, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	err := errNoAssertion
+	for _, verifier := range m.verifiers {
+		var assertion *Assertion
+		assertion, err = verifier.Verify(r)
+		if err == nil {
+			return assertion, nil
+		}
+	}
+	return nil, err
+}
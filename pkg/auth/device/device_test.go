@@ -0,0 +1,239 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package device
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func mustGenerateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustSignAssertion(t *testing.T, key *rsa.PrivateKey, claims jwt.StandardClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestHeaderVerifier(t *testing.T) {
+	key := mustGenerateKey(t)
+	verifier, err := NewVerifier(appv1.DeviceTrustIssuer{
+		Name:        "mdm",
+		Method:      appv1.DeviceTrustHeader,
+		Certificate: encodePublicKey(t, key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/login", nil)
+	if _, err := verifier.Verify(req); err != errNoAssertion {
+		t.Error("Expected no assertion error, got:", err)
+	}
+
+	req.Header.Set("X-Device-Assertion", mustSignAssertion(t, key, jwt.StandardClaims{
+		Subject:   "laptop-1",
+		Audience:  "kvdi",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}))
+	assertion, err := verifier.Verify(req)
+	if err != nil {
+		t.Fatal("Expected valid assertion, got:", err)
+	}
+	if assertion.Issuer != "mdm" || assertion.Device != "laptop-1" {
+		t.Error("Unexpected assertion contents:", assertion)
+	}
+
+	req.Header.Set("X-Device-Assertion", mustSignAssertion(t, key, jwt.StandardClaims{Subject: "laptop-1", Audience: "kvdi"}))
+	if _, err := verifier.Verify(req); err == nil {
+		t.Error("Expected error for assertion without expiry, got nil")
+	}
+
+	for _, audience := range []string{"", "another-service"} {
+		req.Header.Set("X-Device-Assertion", mustSignAssertion(t, key, jwt.StandardClaims{
+			Subject:   "laptop-1",
+			Audience:  audience,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}))
+		if _, err := verifier.Verify(req); err == nil {
+			t.Errorf("Expected error for assertion with audience %q, got nil", audience)
+		}
+	}
+
+	req.Header.Set("X-Device-Assertion", mustSignAssertion(t, mustGenerateKey(t), jwt.StandardClaims{
+		Subject:   "laptop-1",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}))
+	if _, err := verifier.Verify(req); err == nil {
+		t.Error("Expected error for assertion signed by another key, got nil")
+	}
+}
+
+func TestClientCertVerifier(t *testing.T) {
+	caKey := mustGenerateKey(t)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "device-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey := mustGenerateKey(t)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "laptop-1"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewVerifier(appv1.DeviceTrustIssuer{
+		Name:        "corp-ca",
+		Method:      appv1.DeviceTrustClientCert,
+		Certificate: base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/login", nil)
+	if _, err := verifier.Verify(req); err != errNoAssertion {
+		t.Error("Expected no assertion error, got:", err)
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+	assertion, err := verifier.Verify(req)
+	if err != nil {
+		t.Fatal("Expected valid client certificate, got:", err)
+	}
+	if assertion.Device != "laptop-1" {
+		t.Error("Expected device to be the certificate common name, got:", assertion.Device)
+	}
+
+	// a certificate from another CA should not be trusted
+	otherKey := mustGenerateKey(t)
+	otherDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "laptop-1"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caTmpl, &otherKey.PublicKey, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := x509.ParseCertificate(otherDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}
+	if _, err := verifier.Verify(req); err == nil {
+		t.Error("Expected error for untrusted certificate, got nil")
+	}
+
+	// the CA certificate itself does not identify a device
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{caCert}}
+	if _, err := verifier.Verify(req); err == nil {
+		t.Error("Expected error for a CA certificate, got nil")
+	}
+}
+
+func TestRequiredFor(t *testing.T) {
+	key := mustGenerateKey(t)
+	cluster := &appv1.VDICluster{
+		Spec: appv1.VDIClusterSpec{
+			Auth: &appv1.AuthConfig{
+				DeviceTrust: &appv1.DeviceTrustConfig{
+					Roles: []string{"admins"},
+					Issuers: []appv1.DeviceTrustIssuer{
+						{Name: "mdm", Method: appv1.DeviceTrustHeader, Certificate: encodePublicKey(t, key)},
+					},
+				},
+			},
+		},
+	}
+
+	manager := NewManager()
+	if manager.RequiredFor(&types.VDIUser{Name: "admin"}) {
+		t.Error("Expected device trust to not be required before setup")
+	}
+	if err := manager.Setup(cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &types.VDIUser{Name: "admin", Roles: []*types.VDIUserRole{{Name: "admins"}}}
+	if !manager.RequiredFor(admin) {
+		t.Error("Expected device trust to be required for admins")
+	}
+	user := &types.VDIUser{Name: "user", Roles: []*types.VDIUserRole{{Name: "users"}}}
+	if manager.RequiredFor(user) {
+		t.Error("Expected device trust to not be required for users")
+	}
+}
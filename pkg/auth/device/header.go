@@ -0,0 +1,91 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package device
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// headerVerifier verifies signed JWT assertions presented in a request header.
+type headerVerifier struct {
+	name     string
+	header   string
+	audience string
+	key      interface{}
+}
+
+func newHeaderVerifier(issuer appv1.DeviceTrustIssuer) (Verifier, error) {
+	keyPEM, err := base64.StdEncoding.DecodeString(issuer.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	if key, err = jwt.ParseRSAPublicKeyFromPEM(keyPEM); err != nil {
+		if key, err = jwt.ParseECPublicKeyFromPEM(keyPEM); err != nil {
+			return nil, fmt.Errorf("No valid public key found for device trust issuer %q", issuer.Name)
+		}
+	}
+	return &headerVerifier{name: issuer.Name, header: issuer.GetHeader(), audience: issuer.GetAudience(), key: key}, nil
+}
+
+// Verify implements Verifier.
+func (h *headerVerifier) Verify(r *http.Request) (*Assertion, error) {
+	raw := r.Header.Get(h.header)
+	if raw == "" {
+		return nil, errNoAssertion
+	}
+	claims := &jwt.StandardClaims{}
+	if _, err := jwt.ParseWithClaims(raw, claims, h.keyFunc); err != nil {
+		return nil, err
+	}
+	// assertions that never expire could be replayed indefinitely
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("The device assertion does not have an expiry")
+	}
+	// assertions issued for other services must not be accepted
+	if !claims.VerifyAudience(h.audience, true) {
+		return nil, fmt.Errorf("The device assertion was not issued for audience %q", h.audience)
+	}
+	return &Assertion{Issuer: h.name, Device: claims.Subject}, nil
+}
+
+// keyFunc returns the issuer key after making sure it matches the signing method
+// of the token.
+func (h *headerVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := h.key.(*rsa.PublicKey); ok {
+			return h.key, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if _, ok := h.key.(*ecdsa.PublicKey); ok {
+			return h.key, nil
+		}
+	}
+	return nil, fmt.Errorf("Unexpected signing algorithm %v on device assertion", token.Header["alg"])
+}
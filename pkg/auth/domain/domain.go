@@ -0,0 +1,169 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Manager is an object for tracking the host keytabs of domain joined desktops. Every
+// desktop is leased the keytab of its own host, so that no two desktops share credentials
+// on the realm. It uses the configured secrets backend for storage.
+type Manager struct {
+	secrets *secrets.SecretEngine
+}
+
+// NewManager returns a new domain host manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets}
+}
+
+// ListHosts returns the hosts that have keytabs stored and the desktops leasing them.
+func (m *Manager) ListHosts() ([]*types.DomainHost, error) {
+	keytabs, err := m.readMap(v1.DomainKeytabsSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := m.readMap(v1.DomainHostLeasesSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]*types.DomainHost, 0, len(keytabs))
+	for _, host := range sortedHosts(keytabs) {
+		hosts = append(hosts, &types.DomainHost{Host: host, Session: string(leases[host])})
+	}
+	return hosts, nil
+}
+
+// SetKeytab stores the keytab for the given host.
+func (m *Manager) SetKeytab(host string, keytab []byte) error {
+	return m.update(func(keytabs, leases map[string][]byte) error {
+		keytabs[host] = keytab
+		return nil
+	})
+}
+
+// DeleteKeytab removes the keytab for the given host. Keytabs leased to a desktop cannot
+// be removed until the desktop is deleted.
+func (m *Manager) DeleteKeytab(host string) error {
+	return m.update(func(keytabs, leases map[string][]byte) error {
+		if _, ok := keytabs[host]; !ok {
+			return fmt.Errorf("There is no keytab stored for the host %s", host)
+		}
+		if owner, ok := leases[host]; ok {
+			return fmt.Errorf("The host %s is in use by %s", host, string(owner))
+		}
+		delete(keytabs, host)
+		return nil
+	})
+}
+
+// Lease returns the host and keytab leased to the given owner, leasing a free host to
+// it if it does not hold one already.
+func (m *Manager) Lease(owner string) (host string, keytab []byte, err error) {
+	err = m.update(func(keytabs, leases map[string][]byte) error {
+		var ok bool
+		host, ok = selectHost(keytabs, leases, owner)
+		if !ok {
+			return errors.New("No host keytabs are free for joining desktops to the domain")
+		}
+		leases[host] = []byte(owner)
+		keytab = keytabs[host]
+		return nil
+	})
+	return
+}
+
+// Release frees any host leased to the given owner.
+func (m *Manager) Release(owner string) error {
+	return m.update(func(keytabs, leases map[string][]byte) error {
+		for host, leasedTo := range leases {
+			if string(leasedTo) == owner {
+				delete(leases, host)
+			}
+		}
+		return nil
+	})
+}
+
+// selectHost returns the host already leased to the owner, or the first host with a keytab
+// that is not leased to anyone.
+func selectHost(keytabs, leases map[string][]byte, owner string) (string, bool) {
+	hosts := sortedHosts(keytabs)
+	for _, host := range hosts {
+		if string(leases[host]) == owner {
+			return host, true
+		}
+	}
+	for _, host := range hosts {
+		if _, ok := leases[host]; !ok {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+func sortedHosts(keytabs map[string][]byte) []string {
+	hosts := make([]string, 0, len(keytabs))
+	for host := range keytabs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (m *Manager) readMap(key string) (map[string][]byte, error) {
+	data, err := m.secrets.ReadSecretMap(key, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return nil, err
+		}
+		data = make(map[string][]byte)
+	}
+	return data, nil
+}
+
+func (m *Manager) update(f func(keytabs, leases map[string][]byte) error) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	keytabs, err := m.readMap(v1.DomainKeytabsSecretKey)
+	if err != nil {
+		return err
+	}
+	leases, err := m.readMap(v1.DomainHostLeasesSecretKey)
+	if err != nil {
+		return err
+	}
+	if err := f(keytabs, leases); err != nil {
+		return err
+	}
+	if err := m.secrets.WriteSecretMap(v1.DomainKeytabsSecretKey, keytabs); err != nil {
+		return err
+	}
+	return m.secrets.WriteSecretMap(v1.DomainHostLeasesSecretKey, leases)
+}
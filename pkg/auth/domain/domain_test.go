@@ -0,0 +1,118 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func mustNewManager(t *testing.T) *Manager {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	os.Setenv("POD_NAME", "test-pod")
+	os.Setenv("POD_NAMESPACE", "test-namespace")
+	c := fake.NewFakeClientWithScheme(scheme)
+	pod := &corev1.Pod{}
+	pod.Name = "test-pod"
+	pod.Namespace = "test-namespace"
+	if err := c.Create(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(engine)
+}
+
+func TestLeaseHosts(t *testing.T) {
+	m := mustNewManager(t)
+
+	if _, _, err := m.Lease("default/desktop-a"); err == nil {
+		t.Error("Expected error leasing a host before any keytabs are stored")
+	}
+	for _, host := range []string{"vdi-02", "vdi-01"} {
+		if err := m.SetKeytab(host, []byte("keytab-"+host)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	host, keytab, err := m.Lease("default/desktop-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "vdi-01" || string(keytab) != "keytab-vdi-01" {
+		t.Error("Expected desktop-a to lease vdi-01, got:", host, string(keytab))
+	}
+	// leasing again returns the same host
+	if again, _, err := m.Lease("default/desktop-a"); err != nil || again != host {
+		t.Error("Expected desktop-a to keep its lease, got:", again, err)
+	}
+	other, _, err := m.Lease("default/desktop-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != "vdi-02" {
+		t.Error("Expected desktop-b to lease a different host, got:", other)
+	}
+	if _, _, err := m.Lease("default/desktop-c"); err == nil {
+		t.Error("Expected error when every host is leased")
+	}
+	if err := m.DeleteKeytab("vdi-01"); err == nil {
+		t.Error("Expected error deleting a leased host")
+	}
+
+	hosts, err := m.ListHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0].Session != "default/desktop-a" || hosts[1].Session != "default/desktop-b" {
+		t.Error("Expected both hosts to be leased, got:", hosts)
+	}
+
+	if err := m.Release("default/desktop-a"); err != nil {
+		t.Fatal(err)
+	}
+	if host, _, err := m.Lease("default/desktop-c"); err != nil || host != "vdi-01" {
+		t.Error("Expected the released host to be leased again, got:", host, err)
+	}
+	if err := m.Release("default/desktop-b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteKeytab("vdi-02"); err != nil {
+		t.Error("Expected to delete a free host, got:", err)
+	}
+	if err := m.DeleteKeytab("vdi-02"); err == nil {
+		t.Error("Expected error deleting a missing host")
+	}
+}
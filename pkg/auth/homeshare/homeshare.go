@@ -0,0 +1,92 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package homeshare
+
+import (
+	"encoding/json"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Manager is an object for tracking users and their home share credentials. It uses
+// the configured secrets backend for storage.
+type Manager struct {
+	secrets *secrets.SecretEngine
+}
+
+// NewManager returns a new home share manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets}
+}
+
+// GetCredentials returns the home share credentials for the given user. If there are
+// no credentials stored for the user, a UserNotFound error is returned.
+func (m *Manager) GetCredentials(name string) (*types.HomeShareCredentials, error) {
+	creds, err := m.secrets.ReadSecretMap(v1.HomeShareCredentialsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil, errors.NewUserNotFoundError(name)
+		}
+		return nil, err
+	}
+	data, ok := creds[name]
+	if !ok {
+		return nil, errors.NewUserNotFoundError(name)
+	}
+	out := &types.HomeShareCredentials{}
+	return out, json.Unmarshal(data, out)
+}
+
+// SetCredentials stores the home share credentials for the given user.
+func (m *Manager) SetCredentials(name string, creds *types.HomeShareCredentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return m.updateCredentials(func(all map[string][]byte) {
+		all[name] = data
+	})
+}
+
+// DeleteCredentials removes the home share credentials for the given user.
+func (m *Manager) DeleteCredentials(name string) error {
+	return m.updateCredentials(func(all map[string][]byte) {
+		delete(all, name)
+	})
+}
+
+func (m *Manager) updateCredentials(f func(map[string][]byte)) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	creds, err := m.secrets.ReadSecretMap(v1.HomeShareCredentialsSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		creds = make(map[string][]byte)
+	}
+	f(creds)
+	return m.secrets.WriteSecretMap(v1.HomeShareCredentialsSecretKey, creds)
+}
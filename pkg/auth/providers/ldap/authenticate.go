@@ -84,8 +84,9 @@ func (a *AuthProvider) Authenticate(req *types.LoginRequest) (*types.AuthResult,
 
 	// make a new user object
 	vdiUser := &types.VDIUser{
-		Name:  req.Username,
-		Roles: make([]*types.VDIUserRole, 0),
+		Name:       req.Username,
+		Roles:      make([]*types.VDIUserRole, 0),
+		Attributes: a.userAttributes(user),
 	}
 
 	// we'll have to iterate our available roles and check if any have an annotation
@@ -0,0 +1,114 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ldap
+
+import (
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Blank assignment to make sure AuthProvider satisfies the directory interface.
+var _ common.DirectoryProvider = &AuthProvider{}
+
+// groupObjectClassFilter matches the object classes commonly used for groups.
+const groupObjectClassFilter = "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(objectClass=posixGroup))"
+
+// SearchDirectory searches the LDAP server for users and groups matching the request.
+// Groups are returned by their DN, since that is what is used in role annotations.
+func (a *AuthProvider) SearchDirectory(req *types.DirectorySearchRequest) ([]*types.DirectoryEntry, error) {
+	conn, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := a.bind(conn); err != nil {
+		return nil, err
+	}
+
+	query := ldapv3.EscapeFilter(req.Query)
+	// ask for one more than the limit so the API can tell the results were truncated
+	sizeLimit := req.GetLimit() + 1
+	entries := make([]*types.DirectoryEntry, 0)
+
+	if req.IncludesUsers() {
+		userIDAttr := a.cluster.GetLDAPUserIDAttribute()
+		sr, err := searchWithLimit(conn, ldapv3.NewSearchRequest(
+			a.getUserBase(),
+			ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, sizeLimit, 0, false,
+			fmt.Sprintf("(|(%s=*%s*)(cn=*%s*))", userIDAttr, query, query),
+			[]string{"cn", userIDAttr},
+			nil,
+		))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range sr.Entries {
+			name := entry.GetAttributeValue(userIDAttr)
+			if name == "" {
+				continue
+			}
+			entries = append(entries, &types.DirectoryEntry{
+				Kind:        types.DirectoryEntryUser,
+				Name:        name,
+				DisplayName: entry.GetAttributeValue("cn"),
+			})
+		}
+	}
+
+	if req.IncludesGroups() {
+		sr, err := searchWithLimit(conn, ldapv3.NewSearchRequest(
+			a.getGroupBase(),
+			ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, sizeLimit, 0, false,
+			fmt.Sprintf("(&%s(cn=*%s*))", groupObjectClassFilter, query),
+			[]string{"cn"},
+			nil,
+		))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range sr.Entries {
+			entries = append(entries, &types.DirectoryEntry{
+				Kind:        types.DirectoryEntryGroup,
+				Name:        entry.DN,
+				DisplayName: entry.GetAttributeValue("cn"),
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// searchWithLimit performs the given search, returning the partial results when the
+// server stops at the size limit of the request.
+func searchWithLimit(conn *ldapv3.Conn, req *ldapv3.SearchRequest) (*ldapv3.SearchResult, error) {
+	sr, err := conn.Search(req)
+	if err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultSizeLimitExceeded) && sr != nil {
+			return sr, nil
+		}
+		return nil, err
+	}
+	return sr, nil
+}
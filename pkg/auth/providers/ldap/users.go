@@ -122,8 +122,9 @@ func (a *AuthProvider) GetUser(username string) (*types.VDIUser, error) {
 	user := sr.Entries[0]
 
 	vdiUser := &types.VDIUser{
-		Name:  username,
-		Roles: make([]*types.VDIUserRole, 0),
+		Name:       username,
+		Roles:      make([]*types.VDIUserRole, 0),
+		Attributes: a.userAttributes(user),
 	}
 
 RoleLoop:
@@ -21,6 +21,8 @@ package ldap
 
 import (
 	"fmt"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
 )
 
 func (a *AuthProvider) getUserBase() string {
@@ -35,7 +37,23 @@ func (a *AuthProvider) userAttrs() []string {
 	if a.cluster.GetLDAPDoUserStatusCheck() {
 		attrs = append(attrs, a.cluster.GetLDAPUserStatusAttribute())
 	}
-	return attrs
+	return append(attrs, a.cluster.GetLDAPUserAttributes()...)
+}
+
+// userAttributes returns the configured attributes of the given LDAP entry to copy into
+// the attributes of the user.
+func (a *AuthProvider) userAttributes(entry *ldapv3.Entry) map[string]string {
+	names := a.cluster.GetLDAPUserAttributes()
+	if len(names) == 0 {
+		return nil
+	}
+	attributes := make(map[string]string, len(names))
+	for _, name := range names {
+		if val := entry.GetAttributeValue(name); val != "" {
+			attributes[name] = val
+		}
+	}
+	return attributes
 }
 
 func (a *AuthProvider) userFilter() string {
@@ -45,3 +63,12 @@ func (a *AuthProvider) userFilter() string {
 func (a *AuthProvider) groupUsersFilter() string {
 	return fmt.Sprintf("(%s=%%s)", a.cluster.GetLDAPUserGroupsAttribute())
 }
+
+// getGroupBase returns the base DN to search for groups from. Groups are frequently kept
+// outside of the user search base, so the whole directory is searched when possible.
+func (a *AuthProvider) getGroupBase() string {
+	if a.baseDN != "" {
+		return a.baseDN
+	}
+	return a.getUserBase()
+}
@@ -20,6 +20,8 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package local
 
 import (
+	"strings"
+
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
@@ -80,6 +82,23 @@ func (a *AuthProvider) GetUser(username string) (*types.VDIUser, error) {
 
 // UpdateUser implements AuthProvider and serves a PUT /api/users/{user} request
 func (a *AuthProvider) UpdateUser(username string, req *types.UpdateUserRequest) error {
+	return a.UpdateUserIfVersion(username, "", req)
+}
+
+// GetUserVersion implements VersionedProvider and returns the version of the user's entry
+// in the passwd file.
+func (a *AuthProvider) GetUserVersion(username string) (string, error) {
+	user, err := a.getUser(username)
+	if err != nil {
+		return "", err
+	}
+	return user.Version(), nil
+}
+
+// UpdateUserIfVersion implements VersionedProvider and serves a PUT /api/users/{user}
+// request made against the given version of the user. An empty version updates the user
+// regardless.
+func (a *AuthProvider) UpdateUserIfVersion(username, version string, req *types.UpdateUserRequest) error {
 	user := &User{Username: username}
 	if len(req.Roles) != 0 {
 		user.Groups = req.Roles
@@ -91,10 +110,30 @@ func (a *AuthProvider) UpdateUser(username string, req *types.UpdateUserRequest)
 		}
 		user.PasswordHash = passwdHash
 	}
-	return a.updateUser(user)
+	return a.updateUser(user, version)
 }
 
 // DeleteUser implements AuthProvider and serves a DELETE /api/users/{user} request
 func (a *AuthProvider) DeleteUser(username string) error {
 	return a.deleteUser(username)
 }
+
+// ExportUser implements RestorableProvider and returns the user's entry in the passwd
+// file.
+func (a *AuthProvider) ExportUser(username string) ([]byte, error) {
+	user, err := a.getUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return user.Encode(), nil
+}
+
+// ImportUser implements RestorableProvider and adds a user exported by ExportUser back to
+// the passwd file.
+func (a *AuthProvider) ImportUser(data []byte) error {
+	user, err := ParseUser(strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	return a.createUser(user)
+}
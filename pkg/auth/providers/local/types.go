@@ -20,6 +20,7 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package local
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"strings"
@@ -45,6 +46,11 @@ func (u *User) Encode() []byte {
 	return []byte(fmt.Sprintf("%s:%s:%s\n", u.Username, strings.Join(u.Groups, ","), u.PasswordHash))
 }
 
+// Version returns a version of this user that changes whenever any of its fields do.
+func (u *User) Version() string {
+	return fmt.Sprintf("%x", sha256.Sum256(u.Encode()))[:16]
+}
+
 // ParseUser will parse a string representation of a user into a User object.
 func ParseUser(text string) (*User, error) {
 	fields := strings.Split(text, ":")
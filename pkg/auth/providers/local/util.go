@@ -19,6 +19,8 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 
 package local
 
+import "github.com/tinyzimmer/kvdi/pkg/util/errors"
+
 // listUsers builds a map of users to their "groups".
 func (a *AuthProvider) listUsers() ([]*User, error) {
 	file, err := a.getPasswdFile()
@@ -59,11 +61,22 @@ func (a *AuthProvider) createUser(user *User) error {
 	return a.updatePasswdFile(newFile)
 }
 
-func (a *AuthProvider) updateUser(user *User) error {
+// updateUser updates a user in the passwd file. When a version is given, the update is only
+// applied if the existing user is still at that version.
+func (a *AuthProvider) updateUser(user *User, version string) error {
 	if err := a.secrets.Lock(15); err != nil {
 		return err
 	}
 	defer a.secrets.Release()
+	if version != "" {
+		existing, err := a.getUser(user.Username)
+		if err != nil {
+			return err
+		}
+		if existing.Version() != version {
+			return errors.NewConflictError("user", user.Username)
+		}
+	}
 	file, err := a.getPasswdFile()
 	if err != nil {
 		return err
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -120,8 +121,9 @@ func (a *AuthProvider) Authenticate(req *types.LoginRequest) (*types.AuthResult,
 
 	result := &types.AuthResult{
 		User: &types.VDIUser{
-			Name:  username,
-			Roles: make([]*types.VDIUserRole, 0),
+			Name:       username,
+			Roles:      make([]*types.VDIUserRole, 0),
+			Attributes: getAttributesFromClaims(claims, a.cluster.GetOIDCAttributeClaims()),
 		},
 		RefreshNotSupported: true,
 	}
@@ -218,6 +220,26 @@ func getUsernameFromClaims(claims map[string]interface{}) (string, error) {
 	return "", fmt.Errorf("Could not parse username from claims: %+v", claims)
 }
 
+// getAttributesFromClaims returns the given claims as user attributes. Claims that are
+// missing or not a string, number, or boolean are skipped.
+func getAttributesFromClaims(claims map[string]interface{}, names []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	attributes := make(map[string]string, len(names))
+	for _, name := range names {
+		switch val := claims[name].(type) {
+		case string:
+			attributes[name] = val
+		case bool:
+			attributes[name] = strconv.FormatBool(val)
+		case float64:
+			attributes[name] = strconv.FormatFloat(val, 'f', -1, 64)
+		}
+	}
+	return attributes
+}
+
 func appendRoleIfBound(boundRoles, userGroups []string, role *rbacv1.VDIRole) []string {
 	if annotations := role.GetAnnotations(); annotations != nil {
 		if oidcGroupStr, ok := annotations[v1.OIDCGroupRoleAnnotation]; ok {
@@ -0,0 +1,162 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ArchiveVersion is the version of the archive format written by this package.
+const ArchiveVersion = 1
+
+// archiveMagic prefixes every encrypted archive.
+var archiveMagic = []byte("KVDIBAK1")
+
+const (
+	saltSize  = 16
+	nonceSize = 12
+	keySize   = 32
+)
+
+// ErrDecrypt is returned when an archive cannot be decrypted. This is usually
+// because the wrong passphrase was given.
+var ErrDecrypt = errors.New("could not decrypt the archive, the passphrase may be incorrect")
+
+// Archive is the decrypted contents of a backup.
+type Archive struct {
+	// The version of the archive format.
+	Version int `json:"version"`
+	// When the archive was created.
+	CreatedAt time.Time `json:"createdAt"`
+	// The name of the VDICluster the archive was taken from.
+	Cluster string `json:"cluster"`
+	// The configuration of the VDICluster.
+	Spec appv1.VDIClusterSpec `json:"spec"`
+	// The VDIRoles bound to the cluster.
+	Roles []*rbacv1.VDIRole `json:"roles,omitempty"`
+	// The VDITeams bound to the cluster.
+	Teams []*rbacv1.VDITeam `json:"teams,omitempty"`
+	// All desktop templates.
+	Templates []*desktopsv1.Template `json:"templates,omitempty"`
+	// Raw values from the secrets backend.
+	Secrets map[string][]byte `json:"secrets,omitempty"`
+	// Map values from the secrets backend.
+	SecretMaps map[string]map[string][]byte `json:"secretMaps,omitempty"`
+}
+
+// Encrypt serializes and compresses the archive, and then encrypts it with a key
+// derived from the given passphrase.
+func (a *Archive) Encrypt(passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required to encrypt the archive")
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gw).Encode(a); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(archiveMagic)+saltSize+nonceSize+buf.Len()+gcm.Overhead())
+	out = append(out, archiveMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, buf.Bytes(), archiveMagic), nil
+}
+
+// Decrypt decrypts and decodes an archive produced by Encrypt.
+func Decrypt(data []byte, passphrase string) (*Archive, error) {
+	headerSize := len(archiveMagic) + saltSize + nonceSize
+	if len(data) < headerSize || !bytes.Equal(data[:len(archiveMagic)], archiveMagic) {
+		return nil, errors.New("the data is not a kvdi backup archive")
+	}
+	salt := data[len(archiveMagic) : len(archiveMagic)+saltSize]
+	nonce := data[len(archiveMagic)+saltSize : headerSize]
+
+	gcm, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, data[headerSize:], archiveMagic)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	body, err := ioutil.ReadAll(gr)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{}
+	if err := json.Unmarshal(body, archive); err != nil {
+		return nil, err
+	}
+	if archive.Version > ArchiveVersion {
+		return nil, errors.New("the archive was written by a newer version of kvdi")
+	}
+	return archive, nil
+}
+
+// newCipher derives a key from the passphrase and salt and returns an AES-GCM cipher using it.
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// lockTimeout is how long, in seconds, the secrets engine lock is held while restoring.
+const lockTimeout = 30
+
+// secretKeys returns the raw keys in the secrets backend that are included in a backup
+// of the given cluster. Short-lived data, such as refresh tokens and pending challenges,
+// and the CA, which is reissued by the manager, are left out.
+func secretKeys(cluster *appv1.VDICluster) []string {
+	keys := []string{
+		v1.JWTSecretKey,
+		v1.LocalUsersSecretKey,
+		v1.OTPUsersSecretKey,
+		v1.SSHCASecretKey,
+	}
+	if cluster.AuthIsUsingSecretEngine() {
+		if cluster.IsUsingLDAPAuth() {
+			keys = append(keys, cluster.GetLDAPUserDNKey(), cluster.GetLDAPPasswordKey())
+		}
+		if cluster.IsUsingOIDCAuth() {
+			keys = append(keys, cluster.GetOIDCClientIDKey(), cluster.GetOIDCClientSecretKey())
+		}
+	}
+	for _, pool := range cluster.GetLicensePools() {
+		if pool.QueryTokenSecret != "" {
+			keys = append(keys, pool.QueryTokenSecret)
+		}
+	}
+	return keys
+}
+
+// secretMapKeys returns the map keys in the secrets backend that are included in a backup.
+func secretMapKeys() []string {
+	return []string{
+		v1.AccessRequestsSecretKey,
+		v1.AccessOverridesSecretKey,
+		v1.HomeShareCredentialsSecretKey,
+		v1.DomainKeytabsSecretKey,
+		v1.UserMetadataSecretKey,
+	}
+}
+
+// Export collects the state of the given cluster into an archive. The secrets engine is
+// expected to already be set up for the cluster.
+func Export(ctx context.Context, c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine) (*Archive, error) {
+	archive := &Archive{
+		Version:    ArchiveVersion,
+		CreatedAt:  time.Now().UTC(),
+		Cluster:    cluster.GetName(),
+		Spec:       *cluster.Spec.DeepCopy(),
+		Secrets:    make(map[string][]byte),
+		SecretMaps: make(map[string]map[string][]byte),
+	}
+
+	roles, err := cluster.GetRoles(c)
+	if err != nil {
+		return nil, fmt.Errorf("could not list roles: %s", err.Error())
+	}
+	archive.Roles = roles
+
+	teams, err := cluster.GetTeams(c)
+	if err != nil {
+		return nil, fmt.Errorf("could not list teams: %s", err.Error())
+	}
+	for i := range teams {
+		team := teams[i].DeepCopy()
+		trimMeta(&team.ObjectMeta)
+		archive.Teams = append(archive.Teams, team)
+	}
+
+	tmplList := &desktopsv1.TemplateList{}
+	if err := c.List(ctx, tmplList, client.InNamespace(metav1.NamespaceAll)); err != nil {
+		return nil, fmt.Errorf("could not list templates: %s", err.Error())
+	}
+	for i := range tmplList.Items {
+		if !cluster.OwnsObject(&tmplList.Items[i]) {
+			continue
+		}
+		tmpl := tmplList.Items[i].DeepCopy()
+		trimMeta(&tmpl.ObjectMeta)
+		tmpl.Status = desktopsv1.TemplateStatus{}
+		archive.Templates = append(archive.Templates, tmpl)
+	}
+
+	for _, key := range secretKeys(cluster) {
+		val, err := engine.ReadSecret(key, false)
+		if err != nil {
+			if errors.IsSecretNotFoundError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("could not read secret %s: %s", key, err.Error())
+		}
+		archive.Secrets[key] = val
+	}
+	for _, key := range secretMapKeys() {
+		val, err := engine.ReadSecretMap(key, false)
+		if err != nil {
+			if errors.IsSecretNotFoundError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("could not read secret %s: %s", key, err.Error())
+		}
+		archive.SecretMaps[key] = val
+	}
+
+	return archive, nil
+}
+
+// RestoreOptions controls what is restored from an archive.
+type RestoreOptions struct {
+	// Do not replace the configuration of the target cluster with the one in the archive.
+	SkipClusterConfig bool
+}
+
+// Restore applies the contents of an archive to the given cluster. Roles, teams, and
+// templates in the archive are created or replaced, and objects that are not in the
+// archive are left alone. Roles and teams are relabeled for the target cluster, so an archive
+// taken from one cluster can be used to clone it into another. Secrets are written to the
+// secrets backend currently configured on the target cluster, and the cluster configuration
+// is applied last.
+func Restore(ctx context.Context, c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine, archive *Archive, opts *RestoreOptions) (*types.RestoreResponse, error) {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	res := &types.RestoreResponse{Cluster: cluster.GetName()}
+
+	for _, role := range archive.Roles {
+		restored := role.DeepCopy()
+		prepareMeta(&restored.ObjectMeta, cluster, true)
+		if err := createOrReplace(ctx, c, restored, &rbacv1.VDIRole{}); err != nil {
+			return res, fmt.Errorf("could not restore role %s: %s", restored.GetName(), err.Error())
+		}
+		res.Roles++
+	}
+
+	for _, team := range archive.Teams {
+		restored := team.DeepCopy()
+		prepareMeta(&restored.ObjectMeta, cluster, true)
+		if err := createOrReplace(ctx, c, restored, &rbacv1.VDITeam{}); err != nil {
+			return res, fmt.Errorf("could not restore team %s: %s", restored.GetName(), err.Error())
+		}
+		res.Teams++
+	}
+
+	for _, tmpl := range archive.Templates {
+		restored := tmpl.DeepCopy()
+		prepareMeta(&restored.ObjectMeta, cluster, false)
+		cluster.ClaimObject(restored)
+		if err := createOrReplace(ctx, c, restored, &desktopsv1.Template{}); err != nil {
+			return res, fmt.Errorf("could not restore template %s: %s", restored.GetName(), err.Error())
+		}
+		res.Templates++
+	}
+
+	if err := engine.Lock(lockTimeout); err != nil {
+		return res, err
+	}
+	defer engine.Release()
+	for key, val := range archive.Secrets {
+		if err := engine.WriteSecret(key, val); err != nil {
+			return res, fmt.Errorf("could not write secret %s: %s", key, err.Error())
+		}
+		res.Secrets++
+	}
+	for key, val := range archive.SecretMaps {
+		if err := engine.WriteSecretMap(key, val); err != nil {
+			return res, fmt.Errorf("could not write secret %s: %s", key, err.Error())
+		}
+		res.Secrets++
+	}
+
+	if !opts.SkipClusterConfig {
+		found := &appv1.VDICluster{}
+		if err := c.Get(ctx, ktypes.NamespacedName{Name: cluster.GetName()}, found); err != nil {
+			return res, err
+		}
+		found.Spec = archive.Spec
+		if err := c.Update(ctx, found); err != nil {
+			return res, fmt.Errorf("could not update the cluster configuration: %s", err.Error())
+		}
+		res.ClusterConfig = true
+	}
+
+	return res, nil
+}
+
+// createOrReplace creates the given object, or replaces the one already in the cluster
+// with it. found is an empty object of the same type used to look up the existing one.
+// The owners of an existing object are kept.
+func createOrReplace(ctx context.Context, c client.Client, obj, found client.Object) error {
+	if err := c.Get(ctx, ktypes.NamespacedName{Name: obj.GetName()}, found); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		return c.Create(ctx, obj)
+	}
+	obj.SetResourceVersion(found.GetResourceVersion())
+	obj.SetOwnerReferences(found.GetOwnerReferences())
+	return c.Update(ctx, obj)
+}
+
+// trimMeta strips the server-populated fields from an object's metadata before it is
+// written to an archive.
+func trimMeta(meta *metav1.ObjectMeta) {
+	meta.SetManagedFields(nil)
+	meta.SetOwnerReferences(nil)
+	meta.SetGeneration(0)
+	meta.SetResourceVersion("")
+	meta.SetUID(ktypes.UID(""))
+	meta.SetCreationTimestamp(metav1.Time{})
+	meta.SetSelfLink("")
+	if annotations := meta.GetAnnotations(); annotations != nil {
+		delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		meta.SetAnnotations(annotations)
+	}
+}
+
+// prepareMeta readies archived metadata for writing to the target cluster. When bind is
+// true the object is labeled for the target cluster.
+func prepareMeta(meta *metav1.ObjectMeta, cluster *appv1.VDICluster, bind bool) {
+	trimMeta(meta)
+	if !bind {
+		return
+	}
+	labels := meta.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[v1.RoleClusterRefLabel] = cluster.GetName()
+	meta.SetLabels(labels)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	rbacv1.AddToScheme(scheme)
+	desktopsv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+func mustSetupCluster(t *testing.T, c client.Client, name string) (*appv1.VDICluster, *secrets.SecretEngine) {
+	t.Helper()
+	cluster := &appv1.VDICluster{}
+	cluster.Name = name
+	if err := c.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	return cluster, engine
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	archive := &Archive{
+		Version: ArchiveVersion,
+		Cluster: "test-cluster",
+		Secrets: map[string][]byte{v1.JWTSecretKey: []byte("secret")},
+	}
+	data, err := archive.Encrypt("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("test-cluster")) {
+		t.Error("Expected the archive contents to be encrypted")
+	}
+
+	decrypted, err := Decrypt(data, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Cluster != "test-cluster" {
+		t.Error("Expected cluster name to survive the round trip, got:", decrypted.Cluster)
+	}
+	if string(decrypted.Secrets[v1.JWTSecretKey]) != "secret" {
+		t.Error("Expected secrets to survive the round trip, got:", decrypted.Secrets)
+	}
+
+	if _, err := Decrypt(data, "wrong"); err != ErrDecrypt {
+		t.Error("Expected decrypt error with the wrong passphrase, got:", err)
+	}
+	if _, err := Decrypt([]byte("not an archive"), "passphrase"); err == nil {
+		t.Error("Expected error decrypting garbage")
+	}
+	if _, err := archive.Encrypt(""); err == nil {
+		t.Error("Expected error encrypting without a passphrase")
+	}
+}
+
+func TestExportRestore(t *testing.T) {
+	srcClient := getFakeClient(t)
+	src, srcEngine := mustSetupCluster(t, srcClient, "source")
+	src.Spec.AppNamespace = "kvdi-source"
+
+	role := &rbacv1.VDIRole{}
+	role.Name = "developers"
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: "source"}
+	role.Rules = []rbacv1.Rule{{Verbs: []rbacv1.Verb{rbacv1.VerbLaunch}, Resources: []rbacv1.Resource{rbacv1.ResourceTemplates}}}
+	team := &rbacv1.VDITeam{}
+	team.Name = "engineering"
+	team.Labels = map[string]string{v1.RoleClusterRefLabel: "source"}
+	team.Spec.Roles = []string{"developers"}
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "ubuntu"
+	for _, obj := range []client.Object{role, team, tmpl} {
+		if err := srcClient.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := srcEngine.WriteSecret(v1.JWTSecretKey, []byte("jwt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcEngine.WriteSecretMap(v1.UserMetadataSecretKey, map[string][]byte{"admin": []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcEngine.WriteSecret(v1.RefreshTokensSecretKey, []byte("transient")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := Export(context.TODO(), srcClient, src, srcEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Roles) != 1 || len(archive.Teams) != 1 || len(archive.Templates) != 1 {
+		t.Fatalf("Expected one role, team, and template, got: %+v", archive)
+	}
+	if _, ok := archive.Secrets[v1.RefreshTokensSecretKey]; ok {
+		t.Error("Expected refresh tokens to be left out of the archive")
+	}
+	if archive.Roles[0].GetResourceVersion() != "" {
+		t.Error("Expected resource version to be stripped from archived roles")
+	}
+
+	dstClient := getFakeClient(t)
+	dst, dstEngine := mustSetupCluster(t, dstClient, "target")
+	res, err := Restore(context.TODO(), dstClient, dst, dstEngine, archive, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Roles != 1 || res.Teams != 1 || res.Templates != 1 || res.Secrets != 2 || !res.ClusterConfig {
+		t.Errorf("Unexpected restore result: %+v", res)
+	}
+
+	restoredRole := &rbacv1.VDIRole{}
+	if err := dstClient.Get(context.TODO(), types.NamespacedName{Name: "developers"}, restoredRole); err != nil {
+		t.Fatal(err)
+	}
+	if restoredRole.GetLabels()[v1.RoleClusterRefLabel] != "target" {
+		t.Error("Expected restored role to be bound to the target cluster, got:", restoredRole.GetLabels())
+	}
+	restoredTeam := &rbacv1.VDITeam{}
+	if err := dstClient.Get(context.TODO(), types.NamespacedName{Name: "engineering"}, restoredTeam); err != nil {
+		t.Fatal(err)
+	}
+	if restoredTeam.GetLabels()[v1.RoleClusterRefLabel] != "target" {
+		t.Error("Expected restored team to be bound to the target cluster, got:", restoredTeam.GetLabels())
+	}
+	if err := dstClient.Get(context.TODO(), types.NamespacedName{Name: "ubuntu"}, &desktopsv1.Template{}); err != nil {
+		t.Error("Expected template to be restored, got:", err)
+	}
+
+	if jwt, err := dstEngine.ReadSecret(v1.JWTSecretKey, false); err != nil || string(jwt) != "jwt" {
+		t.Error("Expected JWT secret to be restored, got:", string(jwt), err)
+	}
+	if md, err := dstEngine.ReadSecretMap(v1.UserMetadataSecretKey, false); err != nil || len(md) != 1 {
+		t.Error("Expected user metadata to be restored, got:", md, err)
+	}
+
+	restoredCluster := &appv1.VDICluster{}
+	if err := dstClient.Get(context.TODO(), types.NamespacedName{Name: "target"}, restoredCluster); err != nil {
+		t.Fatal(err)
+	}
+	if restoredCluster.Spec.AppNamespace != "kvdi-source" {
+		t.Error("Expected cluster configuration to be restored, got:", restoredCluster.Spec.AppNamespace)
+	}
+
+	// Restoring again replaces the existing objects
+	res, err = Restore(context.TODO(), dstClient, dst, dstEngine, archive, &RestoreOptions{SkipClusterConfig: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Roles != 1 || res.ClusterConfig {
+		t.Errorf("Unexpected restore result: %+v", res)
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package backup exports and restores the state of a VDICluster as a single encrypted archive.
+// An archive holds the cluster configuration, its roles and teams, all desktop templates, and
+// the long-lived data kept in the secrets backend, so that a cluster can be recovered after a
+// disaster or cloned into a new one.
+package backup
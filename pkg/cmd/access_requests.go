@@ -0,0 +1,120 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+var (
+	accessRequestOpts types.CreateAccessRequest
+	accessReviewOpts  types.ReviewAccessRequest
+)
+
+func init() {
+	createFlags := accessRequestsCreateCmd.Flags()
+	createFlags.StringVar(&accessRequestOpts.Template, "template", "", "the template to request access to")
+	createFlags.StringVarP(&accessRequestOpts.Namespace, "namespace", "n", "", "the namespace to request access to launch the template in")
+	createFlags.StringVar(&accessRequestOpts.Reason, "reason", "", "the reason access is needed")
+	accessRequestsCreateCmd.MarkFlagRequired("template")
+	accessRequestsCreateCmd.RegisterFlagCompletionFunc("template", completeTemplates)
+
+	accessRequestsApproveCmd.Flags().StringVar(&accessReviewOpts.Comment, "comment", "", "a comment to record with the decision")
+	accessRequestsDenyCmd.Flags().StringVar(&accessReviewOpts.Comment, "comment", "", "a comment to record with the decision")
+
+	accessRequestsCmd.AddCommand(accessRequestsGetCmd)
+	accessRequestsCmd.AddCommand(accessRequestsCreateCmd)
+	accessRequestsCmd.AddCommand(accessRequestsApproveCmd)
+	accessRequestsCmd.AddCommand(accessRequestsDenyCmd)
+
+	rootCmd.AddCommand(accessRequestsCmd)
+}
+
+var accessRequestsCmd = &cobra.Command{
+	Use:     "access-requests",
+	Aliases: []string{"access-request", "ar"},
+	Short:   "Template access request commands",
+}
+
+var accessRequestsGetCmd = &cobra.Command{
+	Use:     "get",
+	Short:   "Retrieve template access requests",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetAccessRequests()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var accessRequestsCreateCmd = &cobra.Command{
+	Use:     "create",
+	Aliases: []string{"new"},
+	Short:   "Request access to launch a template",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.CreateAccessRequest(&accessRequestOpts)
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var accessRequestsApproveCmd = &cobra.Command{
+	Use:     "approve [IDS...]",
+	Short:   "Approve template access requests",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if _, err := kvdiClient.ApproveAccessRequest(arg, &accessReviewOpts); err != nil {
+				return err
+			}
+			fmt.Printf("Access request %q approved\n", arg)
+		}
+		return nil
+	},
+}
+
+var accessRequestsDenyCmd = &cobra.Command{
+	Use:     "deny [IDS...]",
+	Short:   "Deny template access requests",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if _, err := kvdiClient.DenyAccessRequest(arg, &accessReviewOpts); err != nil {
+				return err
+			}
+			fmt.Printf("Access request %q denied\n", arg)
+		}
+		return nil
+	},
+}
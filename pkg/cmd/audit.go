@@ -0,0 +1,91 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditUser  string
+	auditSince string
+	auditUntil string
+	auditLimit int
+)
+
+func init() {
+	flags := auditCmd.Flags()
+	flags.StringVarP(&auditUser, "user", "u", "", "only show events for this user")
+	flags.StringVar(&auditSince, "since", "", "only show events after this time, as a duration ago (e.g. 1h) or in RFC3339 format")
+	flags.StringVar(&auditUntil, "until", "", "only show events before this time, as a duration ago (e.g. 10m) or in RFC3339 format")
+	flags.IntVar(&auditLimit, "limit", 100, "the maximum number of events to show")
+	auditCmd.RegisterFlagCompletionFunc("user", completeUsers)
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query recent audit events",
+	Long: `Query recent audit events
+
+Events are read from the memory of the app instance serving the request. Older events,
+and events recorded by other replicas, are only available from the configured audit sink.`,
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := &types.AuditQuery{User: auditUser, Limit: auditLimit}
+		var err error
+		if query.Since, err = parseAuditTime(auditSince); err != nil {
+			return err
+		}
+		if query.Until, err = parseAuditTime(auditUntil); err != nil {
+			return err
+		}
+		resp, err := kvdiClient.GetAuditEvents(query)
+		if err != nil {
+			return err
+		}
+		return writeObject(resp.Events)
+	},
+}
+
+// parseAuditTime parses a time given as either a duration before now or in RFC3339
+// format. An empty value returns the zero time.
+func parseAuditTime(val string) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if dur, err := time.ParseDuration(val); err == nil {
+		return time.Now().Add(-dur), nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Could not parse %q as a duration or RFC3339 time", val)
+	}
+	return t, nil
+}
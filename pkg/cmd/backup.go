@@ -0,0 +1,150 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// backupPassphraseEnv is the environment variable the archive passphrase is read from.
+const backupPassphraseEnv = "KVDI_BACKUP_PASSPHRASE"
+
+var (
+	backupOutput            string
+	backupInput             string
+	backupSkipClusterConfig bool
+)
+
+func init() {
+	backupCreateCmd.Flags().StringVarP(&backupOutput, "output", "o", "kvdi-backup.enc", "the file to write the encrypted archive to")
+
+	backupRestoreCmd.Flags().StringVarP(&backupInput, "file", "f", "", "the encrypted archive to restore")
+	backupRestoreCmd.Flags().BoolVar(&backupSkipClusterConfig, "skip-cluster-config", false, "do not replace the VDICluster configuration with the one in the archive")
+	backupRestoreCmd.MarkFlagRequired("file")
+
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+
+	rootCmd.AddCommand(backupCmd)
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backup and restore commands",
+	Long: `Backup and restore commands
+
+Archives contain the VDICluster configuration, roles, teams, templates, and the long-lived
+data in the secrets backend. They are encrypted with a passphrase read from the
+KVDI_BACKUP_PASSPHRASE environment variable, or prompted for if it is unset.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:     "create",
+	Short:   "Export the state of the cluster to an encrypted archive",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := getBackupPassphrase(true)
+		if err != nil {
+			return err
+		}
+		resp, err := kvdiClient.CreateBackup(passphrase)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(backupOutput, resp.Archive, 0600); err != nil {
+			return err
+		}
+		fmt.Printf("Backup created at %s and written to %s\n", time.Unix(resp.CreatedAt, 0).Format(time.RFC3339), backupOutput)
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:     "restore",
+	Short:   "Restore the state of the cluster from an encrypted archive",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archive, err := ioutil.ReadFile(backupInput)
+		if err != nil {
+			return err
+		}
+		passphrase, err := getBackupPassphrase(false)
+		if err != nil {
+			return err
+		}
+		resp, err := kvdiClient.RestoreBackup(&types.RestoreRequest{
+			Archive:           archive,
+			Passphrase:        passphrase,
+			SkipClusterConfig: backupSkipClusterConfig,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored to %s:\n", resp.Cluster)
+		fmt.Println("  Roles:", resp.Roles)
+		fmt.Println("  Teams:", resp.Teams)
+		fmt.Println("  Templates:", resp.Templates)
+		fmt.Println("  Secrets:", resp.Secrets)
+		fmt.Println("  Cluster Config:", resp.ClusterConfig)
+		return nil
+	},
+}
+
+// getBackupPassphrase reads the archive passphrase from the environment, or prompts for it.
+// When confirm is true the passphrase must be entered twice.
+func getBackupPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(backupPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	fmt.Print("Enter backup passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("a passphrase is required")
+	}
+	if confirm {
+		fmt.Print("Confirm backup passphrase: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
@@ -22,16 +22,40 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"strings"
 
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var (
+	stagedConfigFile string
+	promoteForce     bool
 )
 
 func init() {
 	clientConfigCmd.AddCommand(setClientConfigCmd)
 	clientConfigCmd.AddCommand(getClientConfigCmd)
 
+	stageServerConfigCmd.Flags().StringVarP(&stagedConfigFile, "file", "f", "", "a JSON or YAML file containing the proposed VDICluster spec")
+	stageServerConfigCmd.MarkFlagRequired("file")
+	promoteServerConfigCmd.Flags().BoolVar(&promoteForce, "force", false, "promote even if the revision fails validation or the configuration changed since it was staged")
+
+	stagedServerConfigCmd.AddCommand(stageServerConfigCmd)
+	stagedServerConfigCmd.AddCommand(discardServerConfigCmd)
+	stagedServerConfigCmd.AddCommand(promoteServerConfigCmd)
+
+	serverConfigCmd.AddCommand(validateServerConfigCmd)
+	serverConfigCmd.AddCommand(stagedServerConfigCmd)
+	serverConfigCmd.AddCommand(rollbackServerConfigCmd)
+
 	configCmd.AddCommand(serverConfigCmd)
 	configCmd.AddCommand(clientConfigCmd)
 
@@ -57,7 +81,110 @@ var serverConfigCmd = &cobra.Command{
 	},
 }
 
-// TODO: Allow configuring server?
+var validateServerConfigCmd = &cobra.Command{
+	Use:     "validate",
+	Short:   "Check the server configuration end-to-end",
+	Long:    "Checks the secrets backend, auth provider, and TLS material of the server and reports whether it is ready to serve traffic.",
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := kvdiClient.ValidateServerConfig()
+		if err != nil {
+			return err
+		}
+		if err := writeObject(report); err != nil {
+			return err
+		}
+		if !report.Ready {
+			return errors.New("The server configuration is not ready")
+		}
+		return nil
+	},
+}
+
+var stagedServerConfigCmd = &cobra.Command{
+	Use:   "staged",
+	Short: "Preview the staged server configuration",
+	Long: `Preview the staged server configuration
+
+Prints the changes to the VDICluster spec, the changes the manager would make to the core
+resources of the cluster, and the result of validating the staged revision end-to-end.`,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		preview, err := kvdiClient.GetStagedServerConfig()
+		if err != nil {
+			return err
+		}
+		return writeObject(preview)
+	},
+}
+
+var stageServerConfigCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Stage a proposed server configuration",
+	Long: `Stage a proposed server configuration
+
+The file should contain a VDICluster spec, such as the output of "kvdictl config server -o yaml".
+The revision is not applied until it is promoted.`,
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := ioutil.ReadFile(stagedConfigFile)
+		if err != nil {
+			return err
+		}
+		spec := &appv1.VDIClusterSpec{}
+		if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096).Decode(spec); err != nil {
+			return err
+		}
+		if err := kvdiClient.StageServerConfig(spec); err != nil {
+			return err
+		}
+		fmt.Println("Configuration staged, run \"kvdictl config server staged\" to preview it")
+		return nil
+	},
+}
+
+var discardServerConfigCmd = &cobra.Command{
+	Use:     "discard",
+	Short:   "Discard the staged server configuration",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := kvdiClient.DiscardStagedServerConfig(); err != nil {
+			return err
+		}
+		fmt.Println("Staged configuration discarded")
+		return nil
+	},
+}
+
+var promoteServerConfigCmd = &cobra.Command{
+	Use:     "promote",
+	Short:   "Promote the staged server configuration",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := kvdiClient.PromoteServerConfig(promoteForce); err != nil {
+			return err
+		}
+		fmt.Println("Staged configuration promoted")
+		return nil
+	},
+}
+
+var rollbackServerConfigCmd = &cobra.Command{
+	Use:     "rollback",
+	Short:   "Restore the server configuration replaced by the last promotion",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := kvdiClient.RollbackServerConfig(); err != nil {
+			return err
+		}
+		fmt.Println("Configuration rolled back")
+		return nil
+	},
+}
 
 var clientConfigCmd = &cobra.Command{
 	Use:   "client",
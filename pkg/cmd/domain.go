@@ -0,0 +1,90 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	domainCmd.AddCommand(domainGetHostsCmd)
+	domainCmd.AddCommand(domainSetKeytabCmd)
+	domainCmd.AddCommand(domainDeleteHostCmd)
+
+	rootCmd.AddCommand(domainCmd)
+}
+
+var domainCmd = &cobra.Command{
+	Use:   "domain",
+	Short: "Domain join commands",
+}
+
+var domainGetHostsCmd = &cobra.Command{
+	Use:     "get-hosts",
+	Short:   "Retrieve the hosts domain joined desktops may lease",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetDomainHosts()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var domainSetKeytabCmd = &cobra.Command{
+	Use:     "set-keytab [HOST] [FILE]",
+	Short:   "Upload the keytab of a host domain joined desktops may lease",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keytab, err := ioutil.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if err := kvdiClient.SetDomainHostKeytab(args[0], keytab); err != nil {
+			return err
+		}
+		fmt.Printf("Keytab for %q uploaded successfully\n", args[0])
+		return nil
+	},
+}
+
+var domainDeleteHostCmd = &cobra.Command{
+	Use:     "delete-host [HOSTS...]",
+	Short:   "Remove the keytabs of hosts that are not leased to desktops",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if err := kvdiClient.DeleteDomainHost(arg); err != nil {
+				return err
+			}
+			fmt.Printf("Host %q deleted\n", arg)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,110 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var faultsFile string
+
+func init() {
+	setFaultsCmd.Flags().StringVarP(&faultsFile, "file", "f", "", "a JSON or YAML file containing the faults to inject")
+	setFaultsCmd.MarkFlagRequired("file")
+
+	faultsCmd.AddCommand(setFaultsCmd)
+	faultsCmd.AddCommand(clearFaultsCmd)
+
+	rootCmd.AddCommand(faultsCmd)
+}
+
+var faultsCmd = &cobra.Command{
+	Use:   "faults",
+	Short: "Fault injection commands",
+	Long: `Fault injection commands
+
+Without a subcommand, the faults currently injected into the server are retrieved.
+Faults are only injected by servers built with the "faults" build tag, other servers
+do not serve these requests.`,
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, err := kvdiClient.GetFaults()
+		if err != nil {
+			return err
+		}
+		return writeObject(status)
+	},
+}
+
+var setFaultsCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Replace the faults injected into the server",
+	Long: `Replace the faults injected into the server
+
+The file should contain a list of faults under the "faults" key. For example:
+
+  faults:
+    - point: display-frames
+      failEvery: 10
+      limit: 5
+    - point: secrets-read
+      delay: 2s`,
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := ioutil.ReadFile(faultsFile)
+		if err != nil {
+			return err
+		}
+		req := &types.FaultInjectionRequest{}
+		if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096).Decode(req); err != nil {
+			return err
+		}
+		if err := kvdiClient.SetFaults(req.Faults); err != nil {
+			return err
+		}
+		fmt.Printf("Injecting %d faults\n", len(req.Faults))
+		return nil
+	},
+}
+
+var clearFaultsCmd = &cobra.Command{
+	Use:     "clear",
+	Short:   "Remove all faults injected into the server",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := kvdiClient.ClearFaults(); err != nil {
+			return err
+		}
+		fmt.Println("Faults cleared")
+		return nil
+	},
+}
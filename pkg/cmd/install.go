@@ -1,19 +1,15 @@
 package cmd
 
 import (
-	// embeds bundle manifest
-	_ "embed"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tinyzimmer/kvdi/pkg/install"
 	"github.com/tinyzimmer/kvdi/pkg/version"
 )
 
-//go:embed bundle.yaml
-var bundleManifest string
-
 var (
 	managerVersion   string
 	installNamespace string
@@ -24,7 +20,7 @@ var (
 func init() {
 	installFlags := installCmd.Flags()
 
-	installFlags.StringVar(&installNamespace, "namespace", "kvdi-system", "the namespace to use for the manifests")
+	installFlags.StringVar(&installNamespace, "namespace", install.DefaultNamespace, "the namespace to use for the manifests")
 	installFlags.StringVar(&managerVersion, "manager-version", version.Version, "the version of the kvdi-manager to install")
 
 	rootCmd.AddCommand(installCmd)
@@ -45,7 +41,7 @@ Example Usage:
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		bundleManifest = strings.Replace(bundleManifest, "kvdi-system", installNamespace, -1)
+		bundleManifest := install.Bundle(installNamespace)
 		bundleManifest = strings.Replace(bundleManifest, version.Version, managerVersion, -1)
 
 		fmt.Println(bundleManifest)
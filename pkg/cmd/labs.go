@@ -0,0 +1,198 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+var (
+	labLockMessage string
+	labOutputPath  string
+)
+
+func init() {
+	labsLockCmd.Flags().StringVarP(&labLockMessage, "message", "m", "", "a message to show on the locked screens")
+	labsThumbnailCmd.Flags().StringVarP(&labOutputPath, "output", "o", "", "the file to write the image to (defaults to <user>.png)")
+	labsCollectCmd.Flags().StringVarP(&labOutputPath, "output", "o", "", "the file to write the archive to (defaults to <lab>-files.tar)")
+
+	labsCmd.AddCommand(labsGetCmd)
+	labsCmd.AddCommand(labsBroadcastCmd)
+	labsCmd.AddCommand(labsLockCmd)
+	labsCmd.AddCommand(labsUnlockCmd)
+	labsCmd.AddCommand(labsThumbnailCmd)
+	labsCmd.AddCommand(labsCollectCmd)
+
+	rootCmd.AddCommand(labsCmd)
+}
+
+var labsCmd = &cobra.Command{
+	Use:     "labs",
+	Aliases: []string{"lab"},
+	Short:   "Lab commands",
+}
+
+var labsGetCmd = &cobra.Command{
+	Use:     "get [LAB]",
+	Short:   "Retrieve labs",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var out interface{}
+		if len(args) == 1 {
+			nn, err := argToNamespacedName(args[0])
+			if err != nil {
+				return err
+			}
+			out, err = kvdiClient.GetLab(nn)
+			if err != nil {
+				return err
+			}
+		} else {
+			labs, err := kvdiClient.GetLabs()
+			if err != nil {
+				return err
+			}
+			out = labs
+		}
+		return writeObject(out)
+	},
+}
+
+var labsBroadcastCmd = &cobra.Command{
+	Use:     "broadcast LAB MESSAGE",
+	Short:   "Deliver a message to every session in a lab",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		out, err := kvdiClient.BroadcastLab(nn, &types.LabBroadcastRequest{Message: strings.Join(args[1:], " ")})
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var labsLockCmd = &cobra.Command{
+	Use:     "lock LAB",
+	Short:   "Lock the screens of every session in a lab",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		out, err := kvdiClient.LockLab(nn, &types.LabLockRequest{Message: labLockMessage})
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var labsUnlockCmd = &cobra.Command{
+	Use:     "unlock LAB",
+	Short:   "Unlock the screens of every session in a lab",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		out, err := kvdiClient.UnlockLab(nn)
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var labsThumbnailCmd = &cobra.Command{
+	Use:     "thumbnail LAB USER",
+	Short:   "Download an image of a user's display in a lab",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		img, err := kvdiClient.GetLabThumbnail(nn, args[1])
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		out := labOutputPath
+		if out == "" {
+			out = fmt.Sprintf("%s.png", args[1])
+		}
+		return writeLabDownload(out, img)
+	},
+}
+
+var labsCollectCmd = &cobra.Command{
+	Use:     "collect LAB PATH",
+	Short:   "Collect a file from the home directory of every session in a lab",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		archive, err := kvdiClient.CollectLabFiles(nn, strings.TrimPrefix(args[1], "/"))
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		out := labOutputPath
+		if out == "" {
+			out = fmt.Sprintf("%s-files.tar", nn.Name)
+		}
+		return writeLabDownload(out, archive)
+	},
+}
+
+func writeLabDownload(path string, body io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, body); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordingsUser      string
+	recordingsNamespace string
+	recordingsSession   string
+	recordingsOutput    string
+)
+
+func init() {
+	flags := recordingsGetCmd.Flags()
+	flags.StringVarP(&recordingsUser, "user", "u", "", "only show recordings of sessions owned by this user")
+	flags.StringVarP(&recordingsNamespace, "namespace", "n", "", "only show recordings of sessions in this namespace")
+	flags.StringVarP(&recordingsSession, "session", "s", "", "only show recordings of sessions with this name")
+	recordingsGetCmd.RegisterFlagCompletionFunc("user", completeUsers)
+
+	recordingsPlayCmd.Flags().StringVarP(&recordingsOutput, "output", "o", "", "the file to write the events to (defaults to stdout)")
+
+	recordingsCmd.AddCommand(recordingsGetCmd)
+	recordingsCmd.AddCommand(recordingsPlayCmd)
+	recordingsCmd.AddCommand(recordingsDeleteCmd)
+
+	rootCmd.AddCommand(recordingsCmd)
+}
+
+var recordingsCmd = &cobra.Command{
+	Use:     "recordings",
+	Aliases: []string{"recording", "rec"},
+	Short:   "Session recording commands",
+}
+
+var recordingsGetCmd = &cobra.Command{
+	Use:     "get [RECORDING]",
+	Short:   "Retrieve recordings of desktop sessions",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			rec, err := kvdiClient.GetRecording(args[0])
+			if err != nil {
+				return err
+			}
+			return writeObject(rec)
+		}
+		resp, err := kvdiClient.GetRecordings(recordingsUser, recordingsNamespace, recordingsSession)
+		if err != nil {
+			return err
+		}
+		return writeObject(resp.Recordings)
+	},
+}
+
+var recordingsPlayCmd = &cobra.Command{
+	Use:   "play RECORDING",
+	Short: "Stream the events of a recording",
+	Long: `Stream the events of a recording
+
+Events are written in order as JSON lines. Frames carry a base64 encoded PNG image of
+the display.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := kvdiClient.GetRecordingPlayback(args[0])
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		var out io.Writer = os.Stdout
+		if recordingsOutput != "" {
+			f, err := os.Create(recordingsOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		_, err = io.Copy(out, body)
+		return err
+	},
+}
+
+var recordingsDeleteCmd = &cobra.Command{
+	Use:     "delete [RECORDINGS...]",
+	Short:   "Delete recordings of desktop sessions",
+	Aliases: []string{"del", "rem", "rm", "remove"},
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if err := kvdiClient.DeleteRecording(arg); err != nil {
+				return err
+			}
+			fmt.Printf("Recording %q deleted\n", arg)
+		}
+		return nil
+	},
+}
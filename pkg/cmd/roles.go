@@ -26,9 +26,10 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-	metav1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var (
@@ -37,14 +38,24 @@ var (
 	ruleVerbs            []string
 	ruleResources        []string
 	ruleResourcePatterns []string
+	rulePatternType      string
 	ruleNamespaces       []string
+	ruleNsSelector       string
+	ruleMaxDuration      string
+	ruleDeny             bool
+	ruleScheduleDays     []string
+	ruleScheduleStart    string
+	ruleScheduleEnd      string
+	ruleScheduleTimeZone string
 )
 
 func init() {
 	createFlags := roleCreateCmd.Flags()
 	createFlags.StringVar(&createRoleOpts.Name, "name", "", "the name to assign the new role")
 	createFlags.StringToStringVar(&createRoleOpts.Annotations, "annotations", map[string]string{}, "annotations to apply to the role")
+	createFlags.StringSliceVar(&createRoleOpts.InheritsFrom, "inherits-from", []string{}, "other roles to inherit rules from")
 	roleCreateCmd.MarkFlagRequired("name")
+	roleCreateCmd.RegisterFlagCompletionFunc("inherits-from", completeRoles)
 
 	addUpdateRoleFlags(roleRulesAddCmd)
 	addUpdateRoleFlags(roleRulesRemoveCmd)
@@ -62,6 +73,8 @@ func init() {
 	rolesCmd.AddCommand(rolesGetCmd)
 	rolesCmd.AddCommand(roleCreateCmd)
 	rolesCmd.AddCommand(rolesDeleteCmd)
+	rolesCmd.AddCommand(rolesDeletedCmd)
+	rolesCmd.AddCommand(rolesRestoreCmd)
 	rolesCmd.AddCommand(roleRulesCmd)
 	rolesCmd.AddCommand(roleAnnotationsCmd)
 
@@ -82,16 +95,36 @@ func addRuleFlags(cmd *cobra.Command) {
 	flagSet.StringSliceVar(&ruleVerbs, "verbs", []string{}, "verbs for the rule")
 	flagSet.StringSliceVar(&ruleResources, "resources", []string{}, "resources for a rule")
 	flagSet.StringSliceVar(&ruleResourcePatterns, "resource-patterns", []string{}, "resource patterns for the rule")
+	flagSet.StringVar(&rulePatternType, "pattern-type", "", "how resource patterns are matched (regex, glob, or exact), defaults to regex")
 	flagSet.StringSliceVar(&ruleNamespaces, "namespaces", []string{}, "namespaces for the rule")
+	flagSet.StringVar(&ruleNsSelector, "namespace-selector", "", "a label selector for namespaces the rule applies to (e.g. team=data-science)")
+	flagSet.StringVar(&ruleMaxDuration, "max-session-duration", "", "the maximum duration of sessions launched under the rule (e.g. 8h)")
+	flagSet.BoolVar(&ruleDeny, "deny", false, "make the rule deny the actions it matches instead of allowing them")
+	flagSet.StringSliceVar(&ruleScheduleDays, "schedule-days", []string{}, "only apply the rule on these days of the week")
+	flagSet.StringVar(&ruleScheduleStart, "schedule-start", "", "only apply the rule from this time of day (HH:MM)")
+	flagSet.StringVar(&ruleScheduleEnd, "schedule-end", "", "only apply the rule until this time of day (HH:MM)")
+	flagSet.StringVar(&ruleScheduleTimeZone, "schedule-timezone", "", "the time zone for the schedule flags, defaults to UTC")
 
 	cmd.RegisterFlagCompletionFunc("verbs", completeVerbs)
 	cmd.RegisterFlagCompletionFunc("resources", completeResources)
 }
 
-func ruleFlagsToRule() rbacv1.Rule {
+func ruleFlagsToRule() (rbacv1.Rule, error) {
 	r := rbacv1.Rule{
-		ResourcePatterns: ruleResourcePatterns,
-		Namespaces:       ruleNamespaces,
+		ResourcePatterns:   ruleResourcePatterns,
+		PatternType:        rbacv1.PatternType(rulePatternType),
+		Namespaces:         ruleNamespaces,
+		MaxSessionDuration: ruleMaxDuration,
+	}
+	if ruleDeny {
+		r.Effect = rbacv1.EffectDeny
+	}
+	if ruleNsSelector != "" {
+		selector, err := metav1.ParseToLabelSelector(ruleNsSelector)
+		if err != nil {
+			return r, err
+		}
+		r.NamespaceSelector = selector
 	}
 	if len(ruleVerbs) > 0 {
 		verbs := make([]rbacv1.Verb, len(ruleVerbs))
@@ -107,7 +140,17 @@ func ruleFlagsToRule() rbacv1.Rule {
 		}
 		r.Resources = resources
 	}
-	return r
+	if len(ruleScheduleDays) > 0 || ruleScheduleStart != "" || ruleScheduleEnd != "" {
+		r.Schedule = &rbacv1.Schedule{
+			TimeZone: ruleScheduleTimeZone,
+			Windows: []rbacv1.AccessWindow{{
+				Days:      ruleScheduleDays,
+				StartTime: ruleScheduleStart,
+				EndTime:   ruleScheduleEnd,
+			}},
+		}
+	}
+	return r, nil
 }
 
 var rolesCmd = &cobra.Command{
@@ -144,7 +187,11 @@ var roleCreateCmd = &cobra.Command{
 	Args:    cobra.NoArgs,
 	PreRunE: checkClientInitErr,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		createRoleOpts.Rules = []rbacv1.Rule{ruleFlagsToRule()}
+		rule, err := ruleFlagsToRule()
+		if err != nil {
+			return err
+		}
+		createRoleOpts.Rules = []rbacv1.Rule{rule}
 		if err := kvdiClient.CreateVDIRole(&createRoleOpts); err != nil {
 			return err
 		}
@@ -170,6 +217,36 @@ var rolesDeleteCmd = &cobra.Command{
 	},
 }
 
+var rolesDeletedCmd = &cobra.Command{
+	Use:     "deleted",
+	Short:   "Retrieve deleted VDI roles that can still be restored",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deleted, err := kvdiClient.GetDeletedObjects(rbacv1.ResourceRoles)
+		if err != nil {
+			return err
+		}
+		return writeObject(deleted)
+	},
+}
+
+var rolesRestoreCmd = &cobra.Command{
+	Use:     "restore [ROLES...]",
+	Short:   "Restore deleted VDI roles",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if err := kvdiClient.RestoreVDIRole(arg); err != nil {
+				return err
+			}
+			fmt.Printf("Role %q restored successfully\n", arg)
+		}
+		return nil
+	},
+}
+
 var roleRulesCmd = &cobra.Command{
 	Use:     "rules",
 	Aliases: []string{"rule"},
@@ -192,7 +269,10 @@ var roleRulesAddCmd = &cobra.Command{
 		if updateRoleName == "" {
 			return errors.New("You must provide a role name")
 		}
-		newRule := ruleFlagsToRule()
+		newRule, err := ruleFlagsToRule()
+		if err != nil {
+			return err
+		}
 		if newRule.IsEmpty() {
 			return errors.New("You must specify fields for the rule")
 		}
@@ -202,8 +282,9 @@ var roleRulesAddCmd = &cobra.Command{
 		}
 		role.Rules = append(role.Rules, newRule)
 		if err := kvdiClient.UpdateVDIRole(updateRoleName, &types.UpdateRoleRequest{
-			Annotations: role.GetAnnotations(),
-			Rules:       role.Rules,
+			Annotations:  role.GetAnnotations(),
+			Rules:        role.Rules,
+			InheritsFrom: role.GetInheritsFrom(),
 		}); err != nil {
 			return err
 		}
@@ -230,10 +311,14 @@ If multiple matches are found, they will all be removed.`,
 		if err != nil {
 			return err
 		}
-		toDelete := ruleFlagsToRule()
+		toDelete, err := ruleFlagsToRule()
+		if err != nil {
+			return err
+		}
 		opts := &types.UpdateRoleRequest{
-			Annotations: role.GetAnnotations(),
-			Rules:       make([]rbacv1.Rule, 0),
+			Annotations:  role.GetAnnotations(),
+			Rules:        make([]rbacv1.Rule, 0),
+			InheritsFrom: role.GetInheritsFrom(),
 		}
 		var ruleRemoved bool
 		for _, rule := range role.Rules {
@@ -260,7 +345,7 @@ var roleAnnotationsSetCmd = &cobra.Command{
 	Short:   "Set annotations on a VDI role",
 	Args:    cobra.ExactArgs(2),
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		avail := []string{metav1.LDAPGroupRoleAnnotation, metav1.OIDCGroupRoleAnnotation}
+		avail := []string{v1.LDAPGroupRoleAnnotation, v1.OIDCGroupRoleAnnotation}
 		role, err := kvdiClient.GetVDIRole(updateRoleName)
 		if err != nil {
 			return []string{}, cobra.ShellCompDirectiveError
@@ -291,8 +376,9 @@ var roleAnnotationsSetCmd = &cobra.Command{
 		}
 		annotations[args[0]] = args[1]
 		opts := &types.UpdateRoleRequest{
-			Rules:       role.Rules,
-			Annotations: annotations,
+			Rules:        role.Rules,
+			Annotations:  annotations,
+			InheritsFrom: role.GetInheritsFrom(),
 		}
 		if err := kvdiClient.UpdateVDIRole(updateRoleName, opts); err != nil {
 			return err
@@ -340,8 +426,9 @@ var roleAnnotationsRemoveCmd = &cobra.Command{
 		}
 		delete(annotations, args[0])
 		opts := &types.UpdateRoleRequest{
-			Rules:       role.Rules,
-			Annotations: annotations,
+			Rules:        role.Rules,
+			Annotations:  annotations,
+			InheritsFrom: role.GetInheritsFrom(),
 		}
 		if err := kvdiClient.UpdateVDIRole(updateRoleName, opts); err != nil {
 			return err
@@ -98,6 +98,10 @@ will potentially be the ability to create API tokens specifically for API and CL
 
 Using the CLI with a user that requires MFA is currently not supported.
 
+If the cluster has a break-glass account configured, you can use it when the external identity
+provider is unavailable by setting KVDI_BREAK_GLASS_KEY to the path of the PEM encoded private
+key and KVDI_BREAK_GLASS_REASON to why it is being used. The reason is recorded in the audit log.
+
 An example for a configuration file might look similar to this:
    
     server:
@@ -173,6 +177,12 @@ func initClient() {
 		cobra.CheckErr(err)
 	}
 
+	var breakGlassKey []byte
+	if keyFile := os.Getenv("KVDI_BREAK_GLASS_KEY"); keyFile != "" {
+		breakGlassKey, err = ioutil.ReadFile(keyFile)
+		cobra.CheckErr(err)
+	}
+
 	kvdiUser := viper.GetString("server.user")
 	kvdiPassword := viper.GetString("server.password")
 
@@ -180,7 +190,7 @@ func initClient() {
 		kvdiPassword = os.Getenv("KVDI_PASSWORD")
 	}
 
-	if kvdiPassword == "" && notVersionCmd() {
+	if kvdiPassword == "" && len(breakGlassKey) == 0 && notVersionCmd() {
 		fmt.Printf("Enter Password for %q: ", kvdiUser)
 		password, err = term.ReadPassword(int(os.Stdin.Fd()))
 		cobra.CheckErr(err)
@@ -193,6 +203,9 @@ func initClient() {
 		Password:              kvdiPassword,
 		TLSCACert:             tlsCA,
 		TLSInsecureSkipVerify: viper.GetBool("server.insecureSkipVerify"),
+		AccessOverrideToken:   os.Getenv("KVDI_ACCESS_OVERRIDE_TOKEN"),
+		BreakGlassKey:         breakGlassKey,
+		BreakGlassReason:      os.Getenv("KVDI_BREAK_GLASS_REASON"),
 	})
 
 	// This would only happen during a bizarre memory allocation issue during cookiejar.New().
@@ -24,9 +24,12 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tinyzimmer/kvdi/pkg/api/client"
@@ -35,8 +38,12 @@ import (
 
 var (
 	createSessionOpts types.CreateSessionRequest
+	bulkSessionOpts   types.BulkCreateSessionRequest
 	proxyHost         string
 	proxyPort         int
+	sshPublicKeyPath  string
+	thumbnailOutput   string
+	kubeconfigOutput  string
 )
 
 func init() {
@@ -44,6 +51,9 @@ func init() {
 	createFlags.StringVar(&createSessionOpts.Template, "template", "", "the template to launch")
 	createFlags.StringVar(&createSessionOpts.Namespace, "namespace", "", "the namespace to launch the template in")
 	createFlags.StringVar(&createSessionOpts.ServiceAccount, "service-account", "", "a service account to attach to the session")
+	createFlags.StringVar(&createSessionOpts.Architecture, "arch", "", "the architecture to run the session on")
+	createFlags.StringVar(&createSessionOpts.Ticket, "ticket", "", "the ticket or change ID to launch the session against")
+	createFlags.StringToStringVar(&createSessionOpts.Prompts, "prompt", nil, "responses to the launch prompts of the template (e.g. classification=internal)")
 
 	sessionCreateCommand.MarkFlagRequired("template")
 	sessionCreateCommand.RegisterFlagCompletionFunc("template", completeTemplates)
@@ -66,19 +76,43 @@ func init() {
 		return sas, cobra.ShellCompDirectiveDefault
 	})
 
+	bulkFlags := sessionBulkCreateCommand.Flags()
+	bulkFlags.StringVar(&bulkSessionOpts.Template, "template", "", "the template to launch")
+	bulkFlags.StringSliceVar(&bulkSessionOpts.Namespaces, "namespaces", nil, "the namespaces to launch the template in")
+	bulkFlags.StringSliceVar(&bulkSessionOpts.Users, "users", nil, "the users to launch the template for")
+	bulkFlags.StringVar(&bulkSessionOpts.ServiceAccount, "service-account", "", "a service account to attach to the sessions")
+	bulkFlags.StringVar(&bulkSessionOpts.Ticket, "ticket", "", "the ticket or change ID to launch the sessions against")
+	bulkFlags.StringToStringVar(&bulkSessionOpts.Prompts, "prompt", nil, "responses to the launch prompts of the template (e.g. classification=internal)")
+	bulkFlags.BoolVar(&bulkSessionOpts.AllowPartial, "allow-partial", false, "keep the sessions that launched even if others fail")
+
+	sessionBulkCreateCommand.MarkFlagRequired("template")
+	sessionBulkCreateCommand.RegisterFlagCompletionFunc("template", completeTemplates)
+
 	proxyFlags := sessionsProxyCmd.PersistentFlags()
 	proxyFlags.StringVar(&proxyHost, "host", "127.0.0.1", "the host to bind the listener to")
 	proxyFlags.IntVar(&proxyPort, "port", 5900, "the port to bind the listener to")
 
 	sessionsProxyCmd.AddCommand(sessionDisplayProxyCmd)
 	sessionsProxyCmd.AddCommand(sessionAudioProxyCmd)
+	sessionsProxyCmd.AddCommand(sessionSSHProxyCmd)
+
+	sessionSSHCertCmd.Flags().StringVarP(&sshPublicKeyPath, "public-key", "i", "", "the SSH public key to sign")
+	sessionSSHCertCmd.MarkFlagRequired("public-key")
+
+	sessionKubeconfigCmd.Flags().StringVarP(&kubeconfigOutput, "output", "o", "", "the file to write the kubeconfig to (defaults to stdout)")
+
+	sessionThumbnailCmd.Flags().StringVarP(&thumbnailOutput, "output", "o", "", "the file to write the image to (defaults to <name>.png)")
 
 	sessionsCmd.AddCommand(sessionsGetCmd)
 	sessionsCmd.AddCommand(sessionCreateCommand)
+	sessionsCmd.AddCommand(sessionBulkCreateCommand)
 	sessionsCmd.AddCommand(sessionsDeleteCmd)
 	sessionsCmd.AddCommand(sessionsProxyCmd)
 	sessionsCmd.AddCommand(sessionCopyCmd)
 	sessionsCmd.AddCommand(sessionStatCmd)
+	sessionsCmd.AddCommand(sessionSSHCertCmd)
+	sessionsCmd.AddCommand(sessionKubeconfigCmd)
+	sessionsCmd.AddCommand(sessionThumbnailCmd)
 
 	rootCmd.AddCommand(sessionsCmd)
 }
@@ -147,6 +181,19 @@ var sessionCreateCommand = &cobra.Command{
 	},
 }
 
+var sessionBulkCreateCommand = &cobra.Command{
+	Use:     "create-bulk",
+	Short:   "Launch a VDI session in several namespaces or for several users",
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := kvdiClient.CreateDesktopSessions(&bulkSessionOpts)
+		if err != nil {
+			return err
+		}
+		return writeObject(resp)
+	},
+}
+
 var sessionsProxyCmd = &cobra.Command{
 	Use:     "proxy",
 	Aliases: []string{"serve"},
@@ -195,6 +242,116 @@ var sessionAudioProxyCmd = &cobra.Command{
 	},
 }
 
+var sessionSSHProxyCmd = &cobra.Command{
+	Use:               "ssh",
+	Short:             "Proxy a session's SSH server",
+	PreRunE:           checkClientInitErr,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Retrieving SSH connection to", nn.String())
+		conn, err := kvdiClient.GetDesktopSSHProxy(nn)
+		if err != nil {
+			return err
+		}
+		return proxyConn(conn)
+	},
+}
+
+var sessionSSHCertCmd = &cobra.Command{
+	Use:               "ssh-cert",
+	Short:             "Sign an SSH public key for access to a VDI session",
+	Long:              "Sign an SSH public key for access to a VDI session. The certificate is written next to the public key with a -cert.pub suffix.",
+	PreRunE:           checkClientInitErr,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		pubKey, err := ioutil.ReadFile(sshPublicKeyPath)
+		if err != nil {
+			return err
+		}
+		resp, err := kvdiClient.SignDesktopSSHKey(nn, &types.SSHCertificateRequest{PublicKey: string(pubKey)})
+		if err != nil {
+			return err
+		}
+		certPath := strings.TrimSuffix(sshPublicKeyPath, ".pub") + "-cert.pub"
+		if err := ioutil.WriteFile(certPath, []byte(resp.Certificate+"\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote certificate for %q to %s (expires %s)\n", resp.Principal, certPath, time.Unix(resp.ValidBefore, 0).String())
+		return nil
+	},
+}
+
+var sessionKubeconfigCmd = &cobra.Command{
+	Use:               "kubeconfig",
+	Short:             "Retrieve a short-lived kubeconfig for a VDI session",
+	Long:              "Retrieve a short-lived kubeconfig with the permissions of a VDI session's service account. The credentials are revoked when the session ends.",
+	PreRunE:           checkClientInitErr,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		resp, err := kvdiClient.GetDesktopKubeconfig(nn)
+		if err != nil {
+			return err
+		}
+		if kubeconfigOutput == "" {
+			fmt.Print(resp.Kubeconfig)
+			return nil
+		}
+		if err := ioutil.WriteFile(kubeconfigOutput, []byte(resp.Kubeconfig), 0600); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote kubeconfig to %s (expires %s)\n", kubeconfigOutput, time.Unix(resp.ExpiresAt, 0).String())
+		return nil
+	},
+}
+
+var sessionThumbnailCmd = &cobra.Command{
+	Use:               "thumbnail",
+	Short:             "Download the most recent thumbnail of a VDI session's display",
+	PreRunE:           checkClientInitErr,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSessions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nn, err := argToNamespacedName(args[0])
+		if err != nil {
+			return err
+		}
+		img, err := kvdiClient.GetDesktopSessionThumbnail(nn)
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		out := thumbnailOutput
+		if out == "" {
+			out = fmt.Sprintf("%s.png", nn.Name)
+		}
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, img); err != nil {
+			return err
+		}
+		fmt.Println("Wrote thumbnail to", out)
+		return nil
+	},
+}
+
 func proxyConn(conn io.ReadWriteCloser) error {
 	defer conn.Close()
 	addr := net.JoinHostPort(proxyHost, strconv.Itoa(proxyPort))
@@ -22,11 +22,27 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
+	"github.com/tinyzimmer/kvdi/pkg/types"
 )
 
+var templateReviewOpts types.ReviewTemplateRequest
+
 func init() {
+	templatesApproveCmd.Flags().StringVar(&templateReviewOpts.Comment, "comment", "", "a comment to record with the decision")
+	templatesRejectCmd.Flags().StringVar(&templateReviewOpts.Comment, "comment", "", "a comment to record with the decision")
+
 	templatesCmd.AddCommand(templatesGetCmd)
+	templatesCmd.AddCommand(templatesMarketplaceCmd)
+	templatesCmd.AddCommand(templatesInstallCmd)
+	templatesCmd.AddCommand(templatesCapacityCmd)
+	templatesCmd.AddCommand(templatesAutoscalingCmd)
+	templatesCmd.AddCommand(templatesLicensesCmd)
+	templatesCmd.AddCommand(templatesApproveCmd)
+	templatesCmd.AddCommand(templatesRejectCmd)
 
 	rootCmd.AddCommand(templatesCmd)
 }
@@ -57,3 +73,112 @@ var templatesGetCmd = &cobra.Command{
 		return writeObject(out)
 	},
 }
+
+var templatesMarketplaceCmd = &cobra.Command{
+	Use:     "marketplace",
+	Aliases: []string{"available"},
+	Short:   "List templates available from remote template indexes",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetMarketplaceTemplates()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var templatesInstallCmd = &cobra.Command{
+	Use:     "install INDEX/TEMPLATE",
+	Short:   "Install or update a template from a remote template index",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spl := strings.Split(args[0], "/")
+		if len(spl) != 2 {
+			return fmt.Errorf("%q is not in the format INDEX/TEMPLATE", args[0])
+		}
+		out, err := kvdiClient.InstallMarketplaceTemplate(spl[0], spl[1])
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var templatesCapacityCmd = &cobra.Command{
+	Use:     "capacity",
+	Short:   "Show desktop capacity and launchable templates by node architecture",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetCapacity()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var templatesAutoscalingCmd = &cobra.Command{
+	Use:     "autoscaling",
+	Short:   "Show the desktop capacity the cluster should have for node autoscalers",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetAutoscalingHints()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var templatesLicensesCmd = &cobra.Command{
+	Use:     "licenses",
+	Short:   "Show the seats held, queued for, and available in each license pool",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := kvdiClient.GetLicenseUsage()
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var templatesApproveCmd = &cobra.Command{
+	Use:               "approve [TEMPLATES...]",
+	Short:             "Approve draft templates saved from desktop sessions",
+	Args:              cobra.MinimumNArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeTemplates,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if _, err := kvdiClient.ApproveDesktopTemplate(arg, &templateReviewOpts); err != nil {
+				return err
+			}
+			fmt.Printf("Template %q approved\n", arg)
+		}
+		return nil
+	},
+}
+
+var templatesRejectCmd = &cobra.Command{
+	Use:               "reject [TEMPLATES...]",
+	Short:             "Reject draft templates saved from desktop sessions",
+	Args:              cobra.MinimumNArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeTemplates,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if _, err := kvdiClient.RejectDesktopTemplate(arg, &templateReviewOpts); err != nil {
+				return err
+			}
+			fmt.Printf("Template %q rejected\n", arg)
+		}
+		return nil
+	},
+}
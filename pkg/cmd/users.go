@@ -24,8 +24,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/spf13/cobra"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 )
@@ -35,6 +37,11 @@ var (
 	userUpdateOpts     types.UpdateUserRequest
 	userUpdateGenPassw bool
 	userPasswLength    int
+	userHomeShareOpts  types.HomeShareCredentials
+	userHomeShareKrb5  string
+	userHomeShareClear bool
+	userOverrideOpts   types.AccessOverrideRequest
+	userSearchOpts     types.DirectorySearchRequest
 )
 
 func init() {
@@ -54,10 +61,40 @@ func init() {
 	updateFlags.IntVar(&userPasswLength, "password-length", 16, "the length to use when generating passwords")
 	userUpdateCmd.RegisterFlagCompletionFunc("roles", completeRoles)
 
+	homeShareFlags := usersHomeShareCmd.Flags()
+	homeShareFlags.StringVar(&userHomeShareOpts.Username, "username", "", "the username to authenticate to the file server with")
+	homeShareFlags.StringVar(&userHomeShareOpts.Password, "password", "", "the password to authenticate to the file server with")
+	homeShareFlags.StringVar(&userHomeShareOpts.Domain, "domain", "", "the domain of the user on the file server")
+	homeShareFlags.StringVar(&userHomeShareKrb5, "kerberos-cache", "", "the path to a kerberos credential cache to authenticate with")
+	homeShareFlags.BoolVar(&userHomeShareClear, "clear", false, "remove the stored home share credentials instead")
+
+	overrideFlags := usersAccessOverrideCmd.Flags()
+	overrideFlags.StringVar(&userOverrideOpts.Duration, "duration", "1h", "how long the override is valid for")
+	overrideFlags.StringVar(&userOverrideOpts.Reason, "reason", "", "the reason the override is being issued")
+
+	searchFlags := usersSearchCmd.Flags()
+	searchFlags.StringVar((*string)(&userSearchOpts.Kind), "kind", "", "restrict the search to 'user' or 'group' entries")
+	searchFlags.IntVar(&userSearchOpts.Limit, "limit", 0, "the maximum number of results to return")
+
 	usersCmd.AddCommand(usersGetCmd)
+	usersCmd.AddCommand(usersSearchCmd)
 	usersCmd.AddCommand(userCreateCmd)
 	usersCmd.AddCommand(usersDeleteCmd)
+	usersCmd.AddCommand(usersDeletedCmd)
+	usersCmd.AddCommand(usersRestoreCmd)
 	usersCmd.AddCommand(userUpdateCmd)
+	usersCmd.AddCommand(usersRevokeCmd)
+	usersCmd.AddCommand(usersHomeShareCmd)
+	usersCmd.AddCommand(usersAccessOverrideCmd)
+	usersCmd.AddCommand(usersMetadataCmd)
+	usersCmd.AddCommand(usersDevicesCmd)
+
+	usersMetadataCmd.AddCommand(usersMetadataGetCmd)
+	usersMetadataCmd.AddCommand(usersMetadataSetCmd)
+	usersMetadataCmd.AddCommand(usersMetadataDeleteCmd)
+
+	usersDevicesCmd.AddCommand(usersDevicesGetCmd)
+	usersDevicesCmd.AddCommand(usersDevicesRevokeCmd)
 
 	rootCmd.AddCommand(usersCmd)
 }
@@ -89,6 +126,22 @@ var usersGetCmd = &cobra.Command{
 	},
 }
 
+var usersSearchCmd = &cobra.Command{
+	Use:     "search QUERY",
+	Aliases: []string{"find"},
+	Short:   "Search the user directory for users and groups",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userSearchOpts.Query = args[0]
+		resp, err := kvdiClient.SearchDirectory(&userSearchOpts)
+		if err != nil {
+			return err
+		}
+		return writeObject(resp)
+	},
+}
+
 var userCreateCmd = &cobra.Command{
 	Use:     "create",
 	Aliases: []string{"new"},
@@ -143,6 +196,36 @@ var usersDeleteCmd = &cobra.Command{
 	},
 }
 
+var usersDeletedCmd = &cobra.Command{
+	Use:     "deleted",
+	Short:   "Retrieve deleted VDI users that can still be restored",
+	Args:    cobra.NoArgs,
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deleted, err := kvdiClient.GetDeletedObjects(rbacv1.ResourceUsers)
+		if err != nil {
+			return err
+		}
+		return writeObject(deleted)
+	},
+}
+
+var usersRestoreCmd = &cobra.Command{
+	Use:     "restore [USERS...]",
+	Short:   "Restore deleted VDI users along with their roles",
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: checkClientInitErr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if err := kvdiClient.RestoreVDIUser(arg); err != nil {
+				return err
+			}
+			fmt.Printf("User %q restored successfully\n", arg)
+		}
+		return nil
+	},
+}
+
 var userUpdateCmd = &cobra.Command{
 	Use:               "update [USER]",
 	Short:             "Update VDI users",
@@ -183,3 +266,188 @@ var userUpdateCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var usersRevokeCmd = &cobra.Command{
+	Use:               "revoke [USERS...]",
+	Short:             "Revoke all tokens issued to VDI users",
+	Args:              cobra.MinimumNArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeUsers,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			if err := kvdiClient.RevokeVDIUserTokens(arg); err != nil {
+				return err
+			}
+			fmt.Printf("Tokens for user %q revoked successfully\n", arg)
+		}
+		return nil
+	},
+}
+
+var usersHomeShareCmd = &cobra.Command{
+	Use:               "homeshare [USER]",
+	Short:             "Set the credentials used to mount a VDI user's home share",
+	Args:              cobra.ExactArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeUsers,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if userHomeShareClear {
+			if err := kvdiClient.DeleteVDIUserHomeShare(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Home share credentials for user %q removed successfully\n", args[0])
+			return nil
+		}
+		if userHomeShareKrb5 != "" {
+			cache, err := ioutil.ReadFile(userHomeShareKrb5)
+			if err != nil {
+				return err
+			}
+			userHomeShareOpts.KerberosCache = cache
+		}
+		if err := userHomeShareOpts.Validate(); err != nil {
+			return err
+		}
+		if err := kvdiClient.SetVDIUserHomeShare(args[0], &userHomeShareOpts); err != nil {
+			return err
+		}
+		fmt.Printf("Home share credentials for user %q set successfully\n", args[0])
+		return nil
+	},
+}
+
+var usersAccessOverrideCmd = &cobra.Command{
+	Use:               "access-override [USER]",
+	Short:             "Issue a token letting a VDI user in outside of the access hours of their roles",
+	Args:              cobra.ExactArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeUsers,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := userOverrideOpts.Validate(); err != nil {
+			return err
+		}
+		out, err := kvdiClient.IssueVDIUserAccessOverride(args[0], &userOverrideOpts)
+		if err != nil {
+			return err
+		}
+		return writeObject(out)
+	},
+}
+
+var usersMetadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "VDI user metadata commands",
+}
+
+var usersMetadataGetCmd = &cobra.Command{
+	Use:               "get USER [NAMESPACE] [KEY]",
+	Short:             "Retrieve metadata stored for a VDI user",
+	Args:              cobra.RangeArgs(1, 3),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeMetadataUser,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch len(args) {
+		case 3:
+			value, err := kvdiClient.GetVDIUserMetadataValue(args[0], args[1], args[2])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		case 2:
+			values, err := kvdiClient.GetVDIUserMetadataNamespace(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return writeObject(values)
+		default:
+			store, err := kvdiClient.GetVDIUserMetadata(args[0])
+			if err != nil {
+				return err
+			}
+			return writeObject(store)
+		}
+	},
+}
+
+var usersMetadataSetCmd = &cobra.Command{
+	Use:               "set USER NAMESPACE KEY VALUE",
+	Short:             "Set a value in a VDI user's metadata",
+	Args:              cobra.ExactArgs(4),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeMetadataUser,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := kvdiClient.SetVDIUserMetadataValue(args[0], args[1], args[2], args[3]); err != nil {
+			return err
+		}
+		fmt.Printf("Metadata key %s/%s for user %q set successfully\n", args[1], args[2], args[0])
+		return nil
+	},
+}
+
+var usersMetadataDeleteCmd = &cobra.Command{
+	Use:               "delete USER NAMESPACE [KEY]",
+	Short:             "Remove a key or an entire namespace from a VDI user's metadata",
+	Args:              cobra.RangeArgs(2, 3),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeMetadataUser,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 3 {
+			if err := kvdiClient.DeleteVDIUserMetadataValue(args[0], args[1], args[2]); err != nil {
+				return err
+			}
+			fmt.Printf("Metadata key %s/%s for user %q removed successfully\n", args[1], args[2], args[0])
+			return nil
+		}
+		if err := kvdiClient.DeleteVDIUserMetadataNamespace(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Metadata namespace %q for user %q removed successfully\n", args[1], args[0])
+		return nil
+	},
+}
+
+var usersDevicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "VDI user device commands",
+}
+
+var usersDevicesGetCmd = &cobra.Command{
+	Use:               "get USER",
+	Short:             "Retrieve the devices a VDI user has signed in from",
+	Args:              cobra.ExactArgs(1),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeMetadataUser,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := kvdiClient.GetVDIUserDevices(args[0])
+		if err != nil {
+			return err
+		}
+		return writeObject(list)
+	},
+}
+
+var usersDevicesRevokeCmd = &cobra.Command{
+	Use:               "revoke USER [DEVICES...]",
+	Short:             "Sign a VDI user out on devices and forget them",
+	Args:              cobra.MinimumNArgs(2),
+	PreRunE:           checkClientInitErr,
+	ValidArgsFunction: completeMetadataUser,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, device := range args[1:] {
+			if err := kvdiClient.RevokeVDIUserDevice(args[0], device); err != nil {
+				return err
+			}
+			fmt.Printf("Device %q for user %q revoked successfully\n", device, args[0])
+		}
+		return nil
+	},
+}
+
+// completeMetadataUser only completes the user argument of the metadata and device commands.
+func completeMetadataUser(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeUsers(cmd, args, toComplete)
+}
@@ -59,6 +59,11 @@ func completeVerbs(cmd *cobra.Command, args []string, toComplete string) ([]stri
 		string(rbacv1.VerbDelete),
 		string(rbacv1.VerbUse),
 		string(rbacv1.VerbLaunch),
+		string(rbacv1.VerbView),
+		string(rbacv1.VerbReview),
+		string(rbacv1.VerbUseFileTransfer),
+		string(rbacv1.VerbUseClipboard),
+		string(rbacv1.VerbUseMicrophone),
 		string(rbacv1.VerbAll),
 	}, cobra.ShellCompDirectiveDefault
 }
@@ -69,6 +74,8 @@ func completeResources(cmd *cobra.Command, args []string, toComplete string) ([]
 		string(rbacv1.ResourceRoles),
 		string(rbacv1.ResourceTemplates),
 		string(rbacv1.ResourceServiceAccounts),
+		string(rbacv1.ResourceAuditLogs),
+		string(rbacv1.ResourceRecordings),
 		string(rbacv1.ResourceAll),
 	}, cobra.ShellCompDirectiveDefault
 }
@@ -0,0 +1,272 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package devices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+const (
+	// FingerprintHeader is the HTTP header clients present a fingerprint of their device
+	// in. Clients that do not send one are identified by their user agent.
+	FingerprintHeader = "X-Device-Fingerprint"
+	// NameHeader is the HTTP header clients may present a name for their device in.
+	NameHeader = "X-Device-Name"
+)
+
+// Limits on the devices stored for each user.
+const (
+	// MaxDevicesPerUser is the most devices remembered for a single user. When a user
+	// signs in from more, the devices they are not signed in on are forgotten, least
+	// recently seen first.
+	MaxDevicesPerUser = 32
+	// MaxNameLength is the longest device name stored. Longer names are truncated.
+	MaxNameLength = 128
+)
+
+// FromRequest returns the device making the given request. Nil is returned if the request
+// presents neither a fingerprint nor a user agent to identify the device by.
+func FromRequest(r *http.Request) *types.UserDevice {
+	fingerprint := r.Header.Get(FingerprintHeader)
+	if fingerprint == "" {
+		fingerprint = r.UserAgent()
+	}
+	if fingerprint == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(fingerprint))
+	name := strings.TrimSpace(r.Header.Get(NameHeader))
+	if name == "" {
+		name = r.UserAgent()
+	}
+	if len(name) > MaxNameLength {
+		name = name[:MaxNameLength]
+	}
+	return &types.UserDevice{
+		ID:         hex.EncodeToString(sum[:8]),
+		Name:       name,
+		ClientAddr: strings.Split(r.RemoteAddr, ":")[0],
+	}
+}
+
+// List is the devices of a single user.
+type List []*types.UserDevice
+
+// Get returns the device with the given ID, or nil if it is not in the list.
+func (l List) Get(id string) *types.UserDevice {
+	for _, device := range l {
+		if device.ID == id {
+			return device
+		}
+	}
+	return nil
+}
+
+// Active returns the number of devices in the list that are signed in at the given time.
+func (l List) Active(now time.Time) int {
+	var active int
+	for _, device := range l {
+		if device.ActiveUntil.After(now) {
+			active++
+		}
+	}
+	return active
+}
+
+// Track records that a token active until the given time was issued to the device. If the
+// device is not already signed in and the user is signed in on limit devices or more, a
+// QuotaExceededError is returned. A limit of zero means there is no limit.
+func (l List) Track(user string, device *types.UserDevice, now, activeUntil time.Time, limit int) (List, error) {
+	existing := l.Get(device.ID)
+	if limit > 0 && (existing == nil || !existing.ActiveUntil.After(now)) && l.Active(now) >= limit {
+		return l, errors.NewQuotaExceededError(fmt.Sprintf("%s is signed in on the maximum allowed (%d) devices for their roles, sign out or revoke another device first", user, limit))
+	}
+	if existing == nil {
+		existing = &types.UserDevice{ID: device.ID, FirstSeen: now}
+		l = append(l, existing)
+	}
+	existing.Name = device.Name
+	existing.ClientAddr = device.ClientAddr
+	existing.LastSeen = now
+	existing.ActiveUntil = activeUntil
+	return l.prune(now), nil
+}
+
+// prune forgets the least recently seen devices that are not signed in until at most
+// MaxDevicesPerUser remain.
+func (l List) prune(now time.Time) List {
+	if len(l) <= MaxDevicesPerUser {
+		return l
+	}
+	sort.SliceStable(l, func(i, j int) bool { return l[i].LastSeen.After(l[j].LastSeen) })
+	out := make(List, 0, MaxDevicesPerUser)
+	excess := len(l) - MaxDevicesPerUser
+	for i := len(l) - 1; i >= 0; i-- {
+		if excess > 0 && !l[i].ActiveUntil.After(now) {
+			excess--
+			continue
+		}
+		out = append(out, l[i])
+	}
+	// restore most recently seen first
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Manager is an object for tracking the devices of users. It uses the configured secrets
+// backend for storage.
+type Manager struct {
+	secrets *secrets.SecretEngine
+}
+
+// NewManager returns a new device manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets}
+}
+
+// List returns the devices the given user has signed in from, most recently seen first.
+func (m *Manager) List(user string) (List, error) {
+	all, err := m.secrets.ReadSecretMap(v1.UserDevicesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return List{}, nil
+		}
+		return nil, err
+	}
+	list, err := decodeList(all[user])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, device := range list {
+		device.Active = device.ActiveUntil.After(now)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+	return list, nil
+}
+
+// Track records that a token active until the given time was issued to the device for the
+// user, enforcing the given limit on the devices the user is signed in on at once.
+func (m *Manager) Track(user string, device *types.UserDevice, activeUntil time.Time, limit int) error {
+	return m.update(user, func(list List) (List, error) {
+		return list.Track(user, device, time.Now(), activeUntil, limit)
+	})
+}
+
+// SignOut marks the given device as no longer signed in for the user.
+func (m *Manager) SignOut(user, id string) error {
+	return m.update(user, func(list List) (List, error) {
+		if device := list.Get(id); device != nil {
+			device.ActiveUntil = time.Now()
+		}
+		return list, nil
+	})
+}
+
+// SignOutAll marks all of the user's devices as no longer signed in.
+func (m *Manager) SignOutAll(user string) error {
+	return m.update(user, func(list List) (List, error) {
+		now := time.Now()
+		for _, device := range list {
+			if device.ActiveUntil.After(now) {
+				device.ActiveUntil = now
+			}
+		}
+		return list, nil
+	})
+}
+
+// Remove forgets the given device for the user.
+func (m *Manager) Remove(user, id string) error {
+	return m.update(user, func(list List) (List, error) {
+		out := make(List, 0, len(list))
+		for _, device := range list {
+			if device.ID != id {
+				out = append(out, device)
+			}
+		}
+		if len(out) == len(list) {
+			return nil, errors.NewDeviceNotFoundError(user, id)
+		}
+		return out, nil
+	})
+}
+
+// DeleteUser removes all of the devices for the user.
+func (m *Manager) DeleteUser(user string) error {
+	return m.update(user, func(List) (List, error) { return List{}, nil })
+}
+
+func (m *Manager) update(user string, f func(List) (List, error)) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	all, err := m.secrets.ReadSecretMap(v1.UserDevicesSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		all = make(map[string][]byte)
+	}
+	list, err := decodeList(all[user])
+	if err != nil {
+		return err
+	}
+	if list, err = f(list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		delete(all, user)
+	} else {
+		for _, device := range list {
+			// computed when listing
+			device.Active, device.Current = false, false
+		}
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		all[user] = data
+	}
+	return m.secrets.WriteSecretMap(v1.UserDevicesSecretKey, all)
+}
+
+func decodeList(data []byte) (List, error) {
+	list := List{}
+	if len(data) == 0 {
+		return list, nil
+	}
+	return list, json.Unmarshal(data, &list)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package devices
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	r.Header.Del("User-Agent")
+	if device := FromRequest(r); device != nil {
+		t.Error("Expected no device without a fingerprint or user agent, got:", device)
+	}
+
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	byAgent := FromRequest(r)
+	if byAgent == nil || byAgent.Name != "Mozilla/5.0" || len(byAgent.ID) != 16 {
+		t.Fatal("Expected the device to be identified by its user agent, got:", byAgent)
+	}
+
+	r.Header.Set(FingerprintHeader, "abc123")
+	r.Header.Set(NameHeader, "Work laptop")
+	device := FromRequest(r)
+	if device.ID == byAgent.ID || device.Name != "Work laptop" {
+		t.Error("Expected the device to be identified by its fingerprint, got:", device)
+	}
+	if again := FromRequest(r); again.ID != device.ID {
+		t.Error("Expected the same fingerprint to give the same ID")
+	}
+}
+
+func TestTrack(t *testing.T) {
+	now := time.Now()
+	laptop := &types.UserDevice{ID: "laptop", Name: "Laptop"}
+	phone := &types.UserDevice{ID: "phone", Name: "Phone"}
+
+	list, err := List{}.Track("alice", laptop, now, now.Add(time.Hour), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Active(now) != 1 || list.Get("laptop").FirstSeen != now {
+		t.Error("Expected the device to be recorded and active, got:", list)
+	}
+
+	// renewing on a device that is already signed in stays within the limit
+	if list, err = list.Track("alice", laptop, now.Add(time.Minute), now.Add(2*time.Hour), 1); err != nil {
+		t.Error("Expected the same device to be allowed at the limit, got:", err)
+	}
+	if _, err = list.Track("alice", phone, now, now.Add(time.Hour), 1); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected a quota error signing in on another device at the limit, got:", err)
+	}
+	if _, err = list.Track("alice", phone, now, now.Add(time.Hour), 0); err != nil {
+		t.Error("Expected no limit with a zero limit, got:", err)
+	}
+
+	// once the laptop is no longer signed in the phone may be used
+	later := now.Add(3 * time.Hour)
+	if list, err = list.Track("alice", phone, later, later.Add(time.Hour), 1); err != nil {
+		t.Error("Expected a new device to be allowed once the others are signed out, got:", err)
+	}
+	if len(list) != 2 || list.Active(later) != 1 {
+		t.Error("Expected both devices to be remembered with one active, got:", list)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	list := List{}
+	for i := 0; i < MaxDevicesPerUser+2; i++ {
+		seen := now.Add(time.Duration(i) * time.Minute)
+		list = append(list, &types.UserDevice{ID: fmt.Sprintf("device-%d", i), LastSeen: seen, ActiveUntil: seen})
+	}
+	// the oldest device is still signed in and should be kept
+	list[0].ActiveUntil = now.Add(24 * time.Hour)
+
+	list = list.prune(now.Add(time.Hour))
+	if len(list) != MaxDevicesPerUser {
+		t.Fatal("Expected the list to be pruned to the maximum, got:", len(list))
+	}
+	if list.Get("device-0") == nil {
+		t.Error("Expected the signed in device to be kept")
+	}
+	if list.Get("device-1") != nil || list.Get("device-2") != nil {
+		t.Error("Expected the least recently seen devices to be forgotten")
+	}
+}
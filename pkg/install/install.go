@@ -0,0 +1,347 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package install
+
+import (
+	"context"
+	// embeds bundle manifest
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/version"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:embed bundle.yaml
+var bundleManifest string
+
+const (
+	// DefaultNamespace is the namespace the manager is installed to in the bundle.
+	DefaultNamespace = "kvdi-system"
+	// DefaultClusterName is the name of the VDICluster created by default.
+	DefaultClusterName = "kvdi"
+	// fieldOwner is the field manager recorded for objects applied by the installer.
+	fieldOwner = "kvdi-installer"
+	// managerLabel is the label the bundle places on the resources for the manager,
+	// including its namespace.
+	managerLabel = "control-plane"
+)
+
+// waitTimeout is how long to wait for CRDs to be established, or for the VDICluster to
+// be removed on uninstall.
+var waitTimeout = 2 * time.Minute
+
+// Options are the customizations for rendering the manifests.
+type Options struct {
+	// The namespace to install the manager to. Defaults to `kvdi-system`.
+	Namespace string
+	// The image to run the manager with. Defaults to the release matching this binary.
+	ManagerImage string
+	// The name of a default VDICluster to create. No VDICluster is created when empty.
+	ClusterName string
+	// The spec of the default VDICluster.
+	ClusterSpec appv1.VDIClusterSpec
+}
+
+// GetNamespace returns the namespace to install the manager to.
+func (o *Options) GetNamespace() string {
+	if o.Namespace != "" {
+		return o.Namespace
+	}
+	return DefaultNamespace
+}
+
+// GetManagerImage returns the image to run the manager with.
+func (o *Options) GetManagerImage() string {
+	if o.ManagerImage != "" {
+		return o.ManagerImage
+	}
+	if version.Version == "" {
+		return "ghcr.io/kvdi/manager:latest"
+	}
+	return fmt.Sprintf("ghcr.io/kvdi/manager:%s", version.Version)
+}
+
+// Bundle returns the raw release bundle with the manager namespace replaced.
+func Bundle(namespace string) string {
+	return strings.Replace(bundleManifest, DefaultNamespace, namespace, -1)
+}
+
+// Render returns the objects to install in the order they should be applied. CRDs come
+// first and the default VDICluster, if any, last.
+func Render(opts *Options) ([]*unstructured.Unstructured, error) {
+	decoder := kyaml.NewYAMLOrJSONDecoder(strings.NewReader(Bundle(opts.GetNamespace())), 4096)
+	objs := make([]*unstructured.Unstructured, 0)
+	seen := make(map[string]int)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "Deployment" {
+			if err := setManagerImage(obj, opts.GetManagerImage()); err != nil {
+				return nil, err
+			}
+		}
+		// the bundle may declare an object more than once, the last declaration wins
+		key := objectKey(obj)
+		if idx, ok := seen[key]; ok {
+			objs[idx] = obj
+			continue
+		}
+		seen[key] = len(objs)
+		objs = append(objs, obj)
+	}
+
+	if opts.ClusterName != "" {
+		cluster, err := newCluster(opts)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, cluster)
+	}
+	return objs, nil
+}
+
+// CRDs returns the CustomResourceDefinitions in the release bundle.
+func CRDs() ([]*unstructured.Unstructured, error) {
+	objs, err := Render(&Options{})
+	if err != nil {
+		return nil, err
+	}
+	crds := make([]*unstructured.Unstructured, 0)
+	for _, obj := range objs {
+		if obj.GetKind() == "CustomResourceDefinition" {
+			crds = append(crds, obj)
+		}
+	}
+	return crds, nil
+}
+
+// WriteYAML writes the given objects to w as a multi-document YAML manifest.
+func WriteYAML(w io.Writer, objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply applies the given objects to the cluster in order. Objects are applied server-side,
+// so running the installer again upgrades an existing installation. The VDICluster is
+// applied once the CRDs are established.
+func Apply(ctx context.Context, reqLogger logr.Logger, c client.Client, objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		if obj.GroupVersionKind() == appv1.GroupVersion.WithKind("VDICluster") {
+			if err := waitForCRDs(ctx, c, objs); err != nil {
+				return err
+			}
+		}
+		reqLogger.Info("Applying object", "Kind", obj.GetKind(), "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %s", obj.GetKind(), obj.GetName(), err.Error())
+		}
+	}
+	return nil
+}
+
+// Uninstall removes the given objects from the cluster in reverse order. The VDICluster is
+// removed first, while the manager is still running to clean up after it. CRDs are only
+// removed when deleteCRDs is true, since doing so deletes every kVDI resource in the
+// cluster, and the manager namespace is only removed if it was created by the installer.
+func Uninstall(ctx context.Context, reqLogger logr.Logger, c client.Client, objs []*unstructured.Unstructured, deleteCRDs bool) error {
+	for i := len(objs) - 1; i >= 0; i-- {
+		obj := objs[i]
+		switch obj.GetKind() {
+		case "CustomResourceDefinition":
+			if !deleteCRDs {
+				reqLogger.Info("Keeping CRD", "Name", obj.GetName())
+				continue
+			}
+		case "Namespace":
+			owned, err := isInstalledNamespace(ctx, c, obj)
+			if err != nil {
+				return err
+			}
+			if !owned {
+				reqLogger.Info("Keeping namespace not created by the installer", "Name", obj.GetName())
+				continue
+			}
+		}
+		reqLogger.Info("Deleting object", "Kind", obj.GetKind(), "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+		if err := c.Delete(ctx, obj); client.IgnoreNotFound(err) != nil && !meta.IsNoMatchError(err) {
+			return fmt.Errorf("failed to delete %s %s: %s", obj.GetKind(), obj.GetName(), err.Error())
+		}
+		if obj.GetKind() == "VDICluster" {
+			if err := waitForDeletion(ctx, c, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newCluster returns the default VDICluster for the given options.
+func newCluster(opts *Options) (*unstructured.Unstructured, error) {
+	cluster := &appv1.VDICluster{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1.GroupVersion.String(),
+			Kind:       "VDICluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: opts.ClusterName,
+		},
+		Spec: opts.ClusterSpec,
+	}
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cluster)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{Object: raw}
+	// the status is managed by the manager
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	return obj, nil
+}
+
+// setManagerImage sets the image of the manager container if the given object is the
+// manager deployment.
+func setManagerImage(obj *unstructured.Unstructured, image string) error {
+	if obj.GetLabels()[managerLabel] != "controller-manager" {
+		return nil
+	}
+	containers, found, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+	if err != nil || !found {
+		return err
+	}
+	for i, container := range containers {
+		c, ok := container.(map[string]interface{})
+		if !ok || c["name"] != "manager" {
+			continue
+		}
+		c["image"] = image
+		containers[i] = c
+	}
+	return unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")
+}
+
+// waitForCRDs waits for every CRD in the given objects to be established.
+func waitForCRDs(ctx context.Context, c client.Client, objs []*unstructured.Unstructured) error {
+	deadline := time.Now().Add(waitTimeout)
+	for _, obj := range objs {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		for {
+			established, err := crdEstablished(ctx, c, obj)
+			if err != nil {
+				return err
+			}
+			if established {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out waiting for CRD %s to be established", obj.GetName())
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+// crdEstablished returns true if the given CRD has been established by the API server.
+func crdEstablished(ctx context.Context, c client.Client, obj *unstructured.Unstructured) (bool, error) {
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := c.Get(ctx, types.NamespacedName{Name: obj.GetName()}, crd); err != nil {
+		return false, err
+	}
+	conditions, _, err := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+	for _, cond := range conditions {
+		if c, ok := cond.(map[string]interface{}); ok && c["type"] == "Established" && c["status"] == "True" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// waitForDeletion waits for the given object to be removed from the cluster.
+func waitForDeletion(ctx context.Context, c client.Client, obj *unstructured.Unstructured) error {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		found := &unstructured.Unstructured{}
+		found.SetGroupVersionKind(obj.GroupVersionKind())
+		err := c.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, found)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s %s to be deleted", obj.GetKind(), obj.GetName())
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// isInstalledNamespace returns true if the given namespace exists and carries the labels
+// placed on it by the installer.
+func isInstalledNamespace(ctx context.Context, c client.Client, obj *unstructured.Unstructured) (bool, error) {
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := c.Get(ctx, types.NamespacedName{Name: obj.GetName()}, found); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return found.GetLabels()[managerLabel] == obj.GetLabels()[managerLabel], nil
+}
+
+// objectKey returns a key identifying the given object in a manifest.
+func objectKey(obj *unstructured.Unstructured) string {
+	return strings.Join([]string{obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()}, "/")
+}
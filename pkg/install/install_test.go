@@ -0,0 +1,112 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package install
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestRender(t *testing.T) {
+	objs, err := Render(&Options{
+		Namespace:    "vdi-system",
+		ManagerImage: "registry.example.com/kvdi/manager:test",
+		ClusterName:  "test-cluster",
+		ClusterSpec:  appv1.VDIClusterSpec{AppNamespace: "vdi-apps"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) == 0 || objs[0].GetKind() != "CustomResourceDefinition" {
+		t.Fatal("Expected the CRDs to be applied first")
+	}
+
+	seen := make(map[string]struct{})
+	var foundImage bool
+	for _, obj := range objs {
+		key := objectKey(obj)
+		if _, ok := seen[key]; ok {
+			t.Error("Expected objects to only be rendered once, got duplicate:", key)
+		}
+		seen[key] = struct{}{}
+		if obj.GetNamespace() == DefaultNamespace || (obj.GetKind() == "Namespace" && obj.GetName() == DefaultNamespace) {
+			t.Error("Expected the manager namespace to be replaced, got:", key)
+		}
+		if obj.GetKind() == "Deployment" {
+			containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+			for _, c := range containers {
+				if c.(map[string]interface{})["image"] == "registry.example.com/kvdi/manager:test" {
+					foundImage = true
+				}
+			}
+		}
+	}
+	if !foundImage {
+		t.Error("Expected the manager deployment to run the requested image")
+	}
+
+	cluster := objs[len(objs)-1]
+	if cluster.GroupVersionKind() != appv1.GroupVersion.WithKind("VDICluster") || cluster.GetName() != "test-cluster" {
+		t.Fatal("Expected the VDICluster to be applied last, got:", objectKey(cluster))
+	}
+	if ns, _, _ := unstructured.NestedString(cluster.Object, "spec", "appNamespace"); ns != "vdi-apps" {
+		t.Error("Expected the VDICluster spec to be rendered, got:", cluster.Object)
+	}
+	if _, ok := cluster.Object["status"]; ok {
+		t.Error("Expected no status on the rendered VDICluster")
+	}
+
+	var out bytes.Buffer
+	if err := WriteYAML(&out, objs); err != nil {
+		t.Fatal(err)
+	}
+	decoder := kyaml.NewYAMLOrJSONDecoder(&out, 4096)
+	var docs int
+	for {
+		doc := make(map[string]interface{})
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		if len(doc) > 0 {
+			docs++
+		}
+	}
+	if docs != len(objs) {
+		t.Errorf("Expected %d documents in the manifest, got %d", len(objs), docs)
+	}
+
+	// no VDICluster without a name
+	objs, err = Render(&Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := objs[len(objs)-1]; last.GetKind() == "VDICluster" {
+		t.Error("Expected no VDICluster to be rendered")
+	}
+}
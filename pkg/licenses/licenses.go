@@ -0,0 +1,284 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package licenses
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// QueryResponse is the body returned by the query hook of a license pool.
+type QueryResponse struct {
+	// The number of seats left on the license server.
+	Available int `json:"available"`
+}
+
+// Manager accounts for the seats of the license pools configured on a VDICluster.
+type Manager struct {
+	client     client.Client
+	cluster    *appv1.VDICluster
+	secrets    *secrets.SecretEngine
+	httpClient *http.Client
+}
+
+// New returns a manager for the license pools of the given cluster. Tokens for query hooks
+// are read from the given secrets engine.
+func New(c client.Client, cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) *Manager {
+	return &Manager{
+		client:     c,
+		cluster:    cluster,
+		secrets:    secretsEngine,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ledger is a snapshot of the seats held by sessions and the sessions waiting for seats.
+type ledger struct {
+	// seats held in each pool
+	held map[string]int
+	// sessions without seats, in the order they were launched
+	waiting []*waiter
+}
+
+// waiter is a session waiting for seats.
+type waiter struct {
+	session      *desktopsv1.Session
+	requirements []desktopsv1.LicenseRequirement
+}
+
+// queued returns the number of seats waiting sessions require from the given pool. When a
+// session is given, only the sessions launched before it are counted.
+func (l *ledger) queued(pool string, before *desktopsv1.Session) (seats, sessions int) {
+	for _, w := range l.waiting {
+		if before != nil && w.session.GetUID() == before.GetUID() {
+			break
+		}
+		for _, req := range w.requirements {
+			if req.Pool == pool {
+				seats += req.GetSeats()
+				sessions++
+			}
+		}
+	}
+	return
+}
+
+// getLedger tallies the seats held by, and required by, the sessions of the cluster.
+func (m *Manager) getLedger(ctx context.Context) (*ledger, error) {
+	sessions := &desktopsv1.SessionList{}
+	if err := m.client.List(ctx, sessions, client.InNamespace(metav1.NamespaceAll), m.cluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+	tmpls := &desktopsv1.TemplateList{}
+	if err := m.client.List(ctx, tmpls); err != nil {
+		return nil, err
+	}
+	requirements := make(map[string][]desktopsv1.LicenseRequirement)
+	for _, tmpl := range tmpls.Items {
+		if tmpl.RequiresLicenses() {
+			requirements[tmpl.GetName()] = tmpl.GetLicenseRequirements()
+		}
+	}
+
+	l := &ledger{held: make(map[string]int), waiting: make([]*waiter, 0)}
+	for i, sess := range sessions.Items {
+		if sess.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if sess.HasLicenses() {
+			for _, grant := range sess.Status.Licenses {
+				l.held[grant.Pool] += grant.Seats
+			}
+			continue
+		}
+		if reqs, ok := requirements[sess.GetTemplateName()]; ok {
+			l.waiting = append(l.waiting, &waiter{session: &sessions.Items[i], requirements: reqs})
+		}
+	}
+	sort.SliceStable(l.waiting, func(i, j int) bool {
+		a, b := l.waiting[i].session, l.waiting[j].session
+		if ta, tb := a.GetCreationTimestamp(), b.GetCreationTimestamp(); !ta.Equal(&tb) {
+			return ta.Before(&tb)
+		}
+		return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+	})
+	return l, nil
+}
+
+// free returns the number of seats in the given pool that are not held by sessions.
+func (m *Manager) free(pool *appv1.LicensePool, held int) (int, error) {
+	free := pool.Seats - held
+	if pool.QueryURL != "" {
+		available, err := m.query(pool)
+		if err != nil {
+			return 0, err
+		}
+		if pool.Seats <= 0 || available < free {
+			free = available
+		}
+	}
+	if free < 0 {
+		return 0, nil
+	}
+	return free, nil
+}
+
+// query asks the query hook of the given pool for the seats left on the license server.
+func (m *Manager) query(pool *appv1.LicensePool) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, pool.QueryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if pool.QueryTokenSecret != "" {
+		token, err := m.secrets.ReadSecret(pool.QueryTokenSecret, true)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to query license pool %s: %s", pool.Name, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Query hook for license pool %s returned status %d", pool.Name, resp.StatusCode)
+	}
+	out := &QueryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("Could not decode response from the query hook for license pool %s: %s", pool.Name, err.Error())
+	}
+	return out.Available, nil
+}
+
+// getPool returns the pool with the given name from the cluster.
+func (m *Manager) getPool(name string) (*appv1.LicensePool, error) {
+	pool := m.cluster.GetLicensePool(name)
+	if pool == nil {
+		return nil, fmt.Errorf("License pool %s is not defined on the VDICluster", name)
+	}
+	return pool, nil
+}
+
+// CheckLaunch returns an error if a new session of the given template would not be granted
+// its seats right away from a pool that blocks launches when it is exhausted. Pools that
+// queue launches are not checked.
+func (m *Manager) CheckLaunch(ctx context.Context, tmpl *desktopsv1.Template) error {
+	if !tmpl.RequiresLicenses() {
+		return nil
+	}
+	l, err := m.getLedger(ctx)
+	if err != nil {
+		return err
+	}
+	for _, req := range tmpl.GetLicenseRequirements() {
+		pool, err := m.getPool(req.Pool)
+		if err != nil {
+			return err
+		}
+		if pool.QueuesLaunches() {
+			continue
+		}
+		free, err := m.free(pool, l.held[pool.Name])
+		if err != nil {
+			return err
+		}
+		queued, _ := l.queued(pool.Name, nil)
+		if free-queued < req.GetSeats() {
+			return fmt.Errorf("License pool %s does not have %d seat(s) available for template %s", pool.Name, req.GetSeats(), tmpl.GetName())
+		}
+	}
+	return nil
+}
+
+// Acquire returns the seats to grant the given session from the pools its template
+// requires. Sessions are granted seats in the order they were launched. If the session
+// must keep waiting, no grants are returned along with a description of what it is
+// waiting for.
+func (m *Manager) Acquire(ctx context.Context, sess *desktopsv1.Session, tmpl *desktopsv1.Template) ([]desktopsv1.LicenseGrant, string, error) {
+	if sess.HasLicenses() {
+		return sess.Status.Licenses, "", nil
+	}
+	if !tmpl.RequiresLicenses() {
+		return nil, "", nil
+	}
+	l, err := m.getLedger(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	grants := make([]desktopsv1.LicenseGrant, 0)
+	for _, req := range tmpl.GetLicenseRequirements() {
+		pool, err := m.getPool(req.Pool)
+		if err != nil {
+			return nil, "", err
+		}
+		free, err := m.free(pool, l.held[pool.Name])
+		if err != nil {
+			return nil, "", err
+		}
+		queued, ahead := l.queued(pool.Name, sess)
+		if free-queued < req.GetSeats() {
+			return nil, fmt.Sprintf("Waiting for %d seat(s) from license pool %s (%d free, %d session(s) queued ahead)", req.GetSeats(), pool.Name, free, ahead), nil
+		}
+		grants = append(grants, desktopsv1.LicenseGrant{Pool: pool.Name, Seats: req.GetSeats()})
+	}
+	return grants, "", nil
+}
+
+// Usage returns the usage of every license pool on the cluster. Errors querying a pool's
+// license server are reported with the pool.
+func (m *Manager) Usage(ctx context.Context) ([]*types.LicensePoolUsage, error) {
+	l, err := m.getLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pools := m.cluster.GetLicensePools()
+	usage := make([]*types.LicensePoolUsage, len(pools))
+	for i, pool := range pools {
+		_, queued := l.queued(pool.Name, nil)
+		usage[i] = &types.LicensePoolUsage{
+			Name:        pool.Name,
+			Seats:       pool.Seats,
+			InUse:       l.held[pool.Name],
+			Queued:      queued,
+			OnExhausted: string(pool.GetOnExhausted()),
+		}
+		free, err := m.free(&pool, l.held[pool.Name])
+		if err != nil {
+			usage[i].Error = err.Error()
+			continue
+		}
+		usage[i].Available = free
+	}
+	return usage, nil
+}
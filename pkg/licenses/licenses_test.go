@@ -0,0 +1,195 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package licenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	desktopsv1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+func newTestCluster(queryURL string) *appv1.VDICluster {
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		LicensePools: []appv1.LicensePool{
+			{Name: "matlab", Seats: 2},
+			{Name: "ansys", QueryURL: queryURL, OnExhausted: appv1.LicenseExhaustedQueue},
+		},
+	}
+	return cluster
+}
+
+func newTestTemplate(t *testing.T, c client.Client, name, pool string) *desktopsv1.Template {
+	t.Helper()
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = name
+	tmpl.Spec.Licenses = []desktopsv1.LicenseRequirement{{Pool: pool}}
+	if err := c.Create(context.TODO(), tmpl); err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+func newTestSession(t *testing.T, c client.Client, cluster *appv1.VDICluster, name, tmpl string, created time.Time, grants ...desktopsv1.LicenseGrant) *desktopsv1.Session {
+	t.Helper()
+	sess := &desktopsv1.Session{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			Labels:            cluster.GetClusterDesktopsSelector(),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: desktopsv1.SessionSpec{Template: tmpl},
+	}
+	sess.Status.Licenses = grants
+	if err := c.Create(context.TODO(), sess); err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func TestBlockingPool(t *testing.T) {
+	c := getFakeClient(t)
+	cluster := newTestCluster("")
+	m := New(c, cluster, nil)
+	tmpl := newTestTemplate(t, c, "matlab-desktop", "matlab")
+	now := time.Now()
+
+	if err := m.CheckLaunch(context.TODO(), tmpl); err != nil {
+		t.Fatal("Expected launch to be allowed on an empty pool, got:", err)
+	}
+
+	s1 := newTestSession(t, c, cluster, "s1", tmpl.Name, now)
+	s2 := newTestSession(t, c, cluster, "s2", tmpl.Name, now.Add(time.Second))
+	s3 := newTestSession(t, c, cluster, "s3", tmpl.Name, now.Add(2*time.Second))
+
+	// two seats are enough for the first two sessions in line
+	grants, reason, err := m.Acquire(context.TODO(), s2, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "" || len(grants) != 1 || grants[0].Pool != "matlab" || grants[0].Seats != 1 {
+		t.Error("Expected second session to be granted a seat, got:", grants, reason)
+	}
+	grants, reason, err = m.Acquire(context.TODO(), s3, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason == "" || grants != nil {
+		t.Error("Expected third session to wait behind the first two, got:", grants)
+	}
+	if err := m.CheckLaunch(context.TODO(), tmpl); err == nil {
+		t.Error("Expected launch to be blocked with every seat spoken for")
+	}
+
+	// the first session finishing frees a seat for the third
+	if err := c.Delete(context.TODO(), s1); err != nil {
+		t.Fatal(err)
+	}
+	s2.Status.Licenses = []desktopsv1.LicenseGrant{{Pool: "matlab", Seats: 1}}
+	if err := c.Update(context.TODO(), s2); err != nil {
+		t.Fatal(err)
+	}
+	grants, reason, err = m.Acquire(context.TODO(), s3, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "" || len(grants) != 1 {
+		t.Error("Expected third session to be granted a seat, got:", reason)
+	}
+
+	usage, err := m.Usage(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 {
+		t.Fatal("Expected usage for both pools, got:", usage)
+	}
+	if usage[0].Name != "matlab" || usage[0].InUse != 1 || usage[0].Queued != 1 || usage[0].Available != 1 {
+		t.Errorf("Unexpected usage for matlab pool: %+v", usage[0])
+	}
+}
+
+func TestQueuedPool(t *testing.T) {
+	available := 1
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&QueryResponse{Available: available})
+	}))
+	defer srvr.Close()
+
+	c := getFakeClient(t)
+	cluster := newTestCluster(srvr.URL)
+	m := New(c, cluster, nil)
+	tmpl := newTestTemplate(t, c, "ansys-desktop", "ansys")
+	now := time.Now()
+
+	s1 := newTestSession(t, c, cluster, "s1", tmpl.Name, now)
+	grants, reason, err := m.Acquire(context.TODO(), s1, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "" || len(grants) != 1 {
+		t.Error("Expected session to be granted the seat reported by the hook, got:", reason)
+	}
+
+	// the license server running out queues launches instead of blocking them
+	available = 0
+	if err := m.CheckLaunch(context.TODO(), tmpl); err != nil {
+		t.Error("Expected launch to be queued instead of blocked, got:", err)
+	}
+	s2 := newTestSession(t, c, cluster, "s2", tmpl.Name, now.Add(time.Second))
+	if _, reason, err = m.Acquire(context.TODO(), s2, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if reason == "" {
+		t.Error("Expected session to wait for the license server")
+	}
+}
+
+func TestUndefinedPool(t *testing.T) {
+	c := getFakeClient(t)
+	m := New(c, newTestCluster(""), nil)
+	tmpl := newTestTemplate(t, c, "unknown-desktop", "unknown")
+	if err := m.CheckLaunch(context.TODO(), tmpl); err == nil {
+		t.Error("Expected error for undefined pool")
+	}
+}
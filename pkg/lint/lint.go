@@ -0,0 +1,77 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lint
+
+import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+)
+
+// Rule represents a single named lint rule.
+type Rule struct {
+	// The name of the rule, used for configuring its severity.
+	Name string
+	// A short description of what the rule checks for.
+	Description string
+	// The severity used when the VDICluster does not override it.
+	DefaultSeverity appv1.LintSeverity
+	// Check inspects the template and returns a message describing any finding.
+	// An empty message means the template passed the rule.
+	Check func(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string
+}
+
+// rules is the registry of lint rules in the order they are evaluated.
+var rules = make([]*Rule, 0)
+
+// Register adds a rule to the registry.
+func Register(rule *Rule) { rules = append(rules, rule) }
+
+// Rules returns all registered lint rules.
+func Rules() []*Rule { return rules }
+
+// Run evaluates all registered rules against the given template and returns the findings.
+// Rules with a configured severity of `off` are skipped.
+func Run(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) []desktopsv1.LintResult {
+	results := make([]desktopsv1.LintResult, 0)
+	for _, rule := range rules {
+		severity := cluster.GetLintSeverity(rule.Name, rule.DefaultSeverity)
+		if severity == appv1.LintSeverityOff {
+			continue
+		}
+		if msg := rule.Check(cluster, tmpl); msg != "" {
+			results = append(results, desktopsv1.LintResult{
+				Rule:     rule.Name,
+				Severity: severity,
+				Message:  msg,
+			})
+		}
+	}
+	return results
+}
+
+// HasErrors returns true if any of the given results have an error severity.
+func HasErrors(results []desktopsv1.LintResult) bool {
+	for _, result := range results {
+		if result.Severity == appv1.LintSeverityError {
+			return true
+		}
+	}
+	return false
+}
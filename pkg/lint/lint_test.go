@@ -0,0 +1,505 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func findResult(results []desktopsv1.LintResult, rule string) *desktopsv1.LintResult {
+	for _, result := range results {
+		if result.Rule == rule {
+			return &result
+		}
+	}
+	return nil
+}
+
+func TestIsLatestImage(t *testing.T) {
+	tt := map[string]bool{
+		"ubuntu":                        true,
+		"ubuntu:latest":                 true,
+		"ghcr.io/kvdi/ubuntu-xfce4":     true,
+		"ghcr.io/kvdi/ubuntu-xfce4:v1":  false,
+		"localhost:5000/ubuntu":         true,
+		"localhost:5000/ubuntu:v1":      false,
+		"ubuntu@sha256:abcdef012345678": false,
+	}
+	for image, expected := range tt {
+		if got := isLatestImage(image); got != expected {
+			t.Errorf("Expected %v for %s, got %v", expected, image, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DesktopConfig: &desktopsv1.DesktopConfig{
+				Image: "ghcr.io/kvdi/ubuntu-xfce4:latest",
+			},
+		},
+	}
+	tmpl.SetAnnotations(map[string]string{v1.PersistentHomeAnnotation: "true"})
+
+	results := Run(cluster, tmpl)
+	if len(results) != 4 {
+		t.Fatal("Expected all rules to fail, got:", results)
+	}
+	if !HasErrors(results) {
+		t.Error("Expected error results for template without userdata")
+	}
+
+	// Override severities
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		Lint: &appv1.LintConfig{
+			Rules: map[string]appv1.LintSeverity{
+				RuleNoUserdataWhenPersistent: appv1.LintSeverityOff,
+				RuleLatestTag:                appv1.LintSeverityError,
+			},
+		},
+	}
+	results = Run(cluster, tmpl)
+	if findResult(results, RuleNoUserdataWhenPersistent) != nil {
+		t.Error("Expected disabled rule to be skipped")
+	}
+	if res := findResult(results, RuleLatestTag); res == nil || res.Severity != appv1.LintSeverityError {
+		t.Error("Expected latest-tag finding with error severity, got:", res)
+	}
+
+	// Fix the remaining findings
+	tmpl.Spec.DesktopConfig.Image = "ghcr.io/kvdi/ubuntu-xfce4:v0.3.3"
+	tmpl.Spec.DesktopConfig.Init = desktopsv1.InitSupervisord
+	tmpl.Spec.DesktopConfig.Resources = corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1"),
+		},
+	}
+	if results = Run(cluster, tmpl); len(results) != 0 {
+		t.Error("Expected no findings, got:", results)
+	}
+}
+
+func TestHighRiskWithoutSandbox(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			RiskLevel: desktopsv1.RiskLevelHigh,
+		},
+	}
+	if msg := checkHighRiskWithoutSandbox(cluster, tmpl); msg == "" {
+		t.Error("Expected finding for high risk template without a sandbox policy")
+	}
+
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		Sandbox: &appv1.SandboxConfig{
+			RuntimeClasses: map[string]string{"high": "gvisor"},
+		},
+	}
+	if msg := checkHighRiskWithoutSandbox(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for sandboxed template, got:", msg)
+	}
+	if class := tmpl.GetPodRuntimeClassName(cluster); class == nil || *class != "gvisor" {
+		t.Error("Expected gvisor runtime class, got:", class)
+	}
+
+	// templates falling back to the default risk level are covered too
+	tmpl.Spec.RiskLevel = ""
+	cluster.Spec.Desktops.Sandbox.DefaultRiskLevel = "high"
+	if class := tmpl.GetPodRuntimeClassName(cluster); class == nil || *class != "gvisor" {
+		t.Error("Expected gvisor runtime class for default risk level, got:", class)
+	}
+}
+
+func TestUnsupportedImageVariant(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			Architectures: []desktopsv1.Architecture{desktopsv1.ArchitectureAMD64},
+			DesktopConfig: &desktopsv1.DesktopConfig{
+				ImageVariants: []desktopsv1.ImageVariant{
+					{Architecture: desktopsv1.ArchitectureAMD64, Image: "ghcr.io/kvdi/desktop:v1-amd64"},
+					{Architecture: desktopsv1.ArchitectureARM64, Image: "ghcr.io/kvdi/desktop:v1-arm64"},
+				},
+			},
+		},
+	}
+	if msg := checkUnsupportedImageVariant(cluster, tmpl); msg == "" {
+		t.Error("Expected finding for image variant of an unsupported architecture")
+	}
+
+	tmpl.Spec.Architectures = append(tmpl.Spec.Architectures, desktopsv1.ArchitectureARM64)
+	if msg := checkUnsupportedImageVariant(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for supported image variants, got:", msg)
+	}
+
+	// sessions pinned to an architecture use the matching variant
+	sess := &desktopsv1.Session{Spec: desktopsv1.SessionSpec{Architecture: desktopsv1.ArchitectureARM64}}
+	if image := tmpl.GetDesktopImageForArchitecture(tmpl.GetSessionArchitecture(sess)); image != "ghcr.io/kvdi/desktop:v1-arm64" {
+		t.Error("Expected arm64 image variant, got:", image)
+	}
+}
+
+func TestStaticHost(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			StaticHost: &desktopsv1.StaticHostConfig{
+				Protocol: desktopsv1.StaticHostRDP,
+			},
+		},
+	}
+	if msg := checkStaticHostWithoutAddress(cluster, tmpl); msg == "" {
+		t.Error("Expected finding for static host without an address")
+	}
+	if msg := checkLatestTag(cluster, tmpl); msg == "" {
+		t.Error("Expected finding for unpinned RDP bridge image")
+	}
+	if msg := checkMissingResourceLimits(cluster, tmpl); msg == "" {
+		t.Error("Expected finding for RDP bridge without resource limits")
+	}
+
+	// VNC hosts are dialed directly by the proxy
+	tmpl.Spec.StaticHost.Address = "workstation.example.com:5900"
+	tmpl.Spec.StaticHost.Protocol = desktopsv1.StaticHostVNC
+	for _, check := range []func(*appv1.VDICluster, *desktopsv1.Template) string{
+		checkStaticHostWithoutAddress, checkLatestTag, checkMissingResourceLimits, checkPrivilegedWithoutJustification,
+	} {
+		if msg := check(cluster, tmpl); msg != "" {
+			t.Error("Expected no finding for VNC static host, got:", msg)
+		}
+	}
+	if uri := tmpl.GetDisplaySocketURI(); uri != "tcp://workstation.example.com:5900" {
+		t.Error("Expected display to point at the static host, got:", uri)
+	}
+}
+
+func TestFirewall(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DesktopConfig: &desktopsv1.DesktopConfig{
+				Ports: []corev1.ContainerPort{{ContainerPort: 3000}},
+			},
+			Firewall: &desktopsv1.FirewallPolicy{
+				Ports: []desktopsv1.FirewallPort{
+					{Port: 3000},
+					{Port: 5353, Protocol: corev1.ProtocolUDP, AllowFromSessions: true},
+				},
+			},
+		},
+	}
+	if msg := checkInvalidFirewallPort(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid firewall ports, got:", msg)
+	}
+	if msg := checkUnreachableFirewallPort(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for reachable firewall ports, got:", msg)
+	}
+
+	tmpl.Spec.Firewall.Ports = append(tmpl.Spec.Firewall.Ports,
+		desktopsv1.FirewallPort{Port: 70000},
+		desktopsv1.FirewallPort{Port: 3000, Protocol: corev1.ProtocolTCP},
+		desktopsv1.FirewallPort{Port: 8000, Protocol: "ICMP"},
+		desktopsv1.FirewallPort{Port: 9000},
+	)
+	msg := checkInvalidFirewallPort(cluster, tmpl)
+	for _, expected := range []string{"70000/TCP", "3000/TCP (duplicate)", "8000/ICMP"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+	if msg := checkUnreachableFirewallPort(cluster, tmpl); !strings.Contains(msg, "9000/TCP") {
+		t.Error("Expected finding for port 9000, got:", msg)
+	}
+}
+
+func TestLaunchPrompts(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			Prompts: []desktopsv1.LaunchPrompt{
+				{Name: "eula", Type: desktopsv1.LaunchPromptAcknowledge},
+				{Name: "classification", Type: desktopsv1.LaunchPromptChoice, Options: []string{"public", "internal"}},
+				{Name: "project", Pattern: "^[a-z]+$"},
+			},
+		},
+	}
+	if msg := checkInvalidLaunchPrompt(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid launch prompts, got:", msg)
+	}
+
+	tmpl.Spec.Prompts = append(tmpl.Spec.Prompts,
+		desktopsv1.LaunchPrompt{Name: "eula"},
+		desktopsv1.LaunchPrompt{Name: "dataset", Type: desktopsv1.LaunchPromptChoice},
+		desktopsv1.LaunchPrompt{Name: "ticket", Pattern: "[a-z"},
+		desktopsv1.LaunchPrompt{Label: "Cost center"},
+	)
+	msg := checkInvalidLaunchPrompt(cluster, tmpl)
+	for _, expected := range []string{"eula (duplicate)", "dataset (no options)", "ticket (invalid pattern)", `"Cost center" (no name)`} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestFileTransfer(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			ProxyConfig: &desktopsv1.ProxyConfig{
+				AllowFileTransfer: true,
+				FileTransfer: &desktopsv1.FileTransferConfig{
+					MaxFileSize:         "100Mi",
+					AllowedContentTypes: []string{"application/pdf", "image/*"},
+				},
+			},
+		},
+	}
+	if msg := checkInvalidFileTransfer(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid file transfer limits, got:", msg)
+	}
+
+	tmpl.Spec.ProxyConfig.FileTransfer.MaxFileSize = "lots"
+	tmpl.Spec.ProxyConfig.FileTransfer.AllowedContentTypes = []string{"pdf", "*/plain"}
+	msg := checkInvalidFileTransfer(cluster, tmpl)
+	for _, expected := range []string{"maxFileSize lots", "content type pdf", "content type */plain"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestAudio(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			AudioConfig: &desktopsv1.AudioConfig{
+				Bitrate:    96000,
+				SampleRate: 48000,
+			},
+		},
+	}
+	if msg := checkInvalidAudio(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid audio settings, got:", msg)
+	}
+
+	tmpl.Spec.AudioConfig.Bitrate = 1000
+	tmpl.Spec.AudioConfig.SampleRate = 44100
+	msg := checkInvalidAudio(cluster, tmpl)
+	for _, expected := range []string{"bitrate 1000", "sampleRate 44100"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestGPU(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			GPUs: &desktopsv1.GPUConfig{MIGProfile: "1g.5gb"},
+		},
+	}
+	if msg := checkInvalidGPU(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for a valid MIG profile, got:", msg)
+	}
+
+	tmpl.Spec.GPUs.MIGProfile = "half"
+	if msg := checkInvalidGPU(cluster, tmpl); !strings.Contains(msg, "migProfile half") {
+		t.Error("Expected an invalid MIG profile to be reported, got:", msg)
+	}
+	tmpl.Spec.GPUs.Vendor = desktopsv1.GPUVendorAMD
+	tmpl.Spec.GPUs.MIGProfile = "1g.5gb"
+	tmpl.Spec.StaticHost = &desktopsv1.StaticHostConfig{Address: "10.0.0.1"}
+	msg := checkInvalidGPU(cluster, tmpl)
+	for _, expected := range []string{"migProfile for amd GPUs", "does not run a desktop container"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DisplayConfig: &desktopsv1.DisplayConfig{
+				Monitors: &desktopsv1.MonitorsConfig{Max: 4},
+			},
+		},
+	}
+	if msg := checkInvalidMonitors(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for a valid monitor config, got:", msg)
+	}
+
+	tmpl.Spec.DisplayConfig.Protocol = desktopsv1.DisplayProtocolRDP
+	tmpl.Spec.DisplayConfig.Monitors.Max = 32
+	msg := checkInvalidMonitors(cluster, tmpl)
+	for _, expected := range []string{"max 32", "rdp displays"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestDisplayResize(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DisplayConfig: &desktopsv1.DisplayConfig{
+				Resize: &desktopsv1.DisplayResizeConfig{Enabled: true},
+			},
+		},
+	}
+	if msg := checkInvalidDisplayResize(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for a valid resize config, got:", msg)
+	}
+
+	tmpl.Spec.DisplayConfig.Protocol = desktopsv1.DisplayProtocolRDP
+	tmpl.Spec.DisplayConfig.Resize.XDisplay = "10"
+	msg := checkInvalidDisplayResize(cluster, tmpl)
+	for _, expected := range []string{"rdp displays", `xDisplay "10"`} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			ProxyConfig: &desktopsv1.ProxyConfig{
+				Plugins: []desktopsv1.SidecarPlugin{{Name: "usb"}, {Name: "telemetry"}},
+			},
+		},
+	}
+	if msg := checkInvalidPlugins(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid plugins, got:", msg)
+	}
+
+	tmpl.Spec.ProxyConfig.Plugins = append(tmpl.Spec.ProxyConfig.Plugins,
+		desktopsv1.SidecarPlugin{Name: "usb"},
+		desktopsv1.SidecarPlugin{Name: "../display"},
+	)
+	msg := checkInvalidPlugins(cluster, tmpl)
+	for _, expected := range []string{"usb is declared more than once", `"../display" is not a valid name`} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DesktopConfig: &desktopsv1.DesktopConfig{
+				Sysctls: []corev1.Sysctl{{Name: "net.ipv4.ip_local_port_range", Value: "1024 65535"}},
+				Ulimits: &desktopsv1.Ulimits{NoFile: 65536, NProc: 4096},
+				ShmSize: "1Gi",
+			},
+		},
+	}
+	if msg := checkInvalidProcessLimits(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for valid process limits, got:", msg)
+	}
+
+	tmpl.Spec.DesktopConfig.Sysctls = append(tmpl.Spec.DesktopConfig.Sysctls, corev1.Sysctl{Name: "kernel.msgmax", Value: "65536"})
+	tmpl.Spec.DesktopConfig.Ulimits.NProc = -1
+	tmpl.Spec.DesktopConfig.ShmSize = "lots"
+	msg := checkInvalidProcessLimits(cluster, tmpl)
+	for _, expected := range []string{"sysctl kernel.msgmax is not in the safe set", "nproc -1", `shmSize "lots"`} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+
+	tmpl.Spec.DesktopConfig.Sysctls = nil
+	tmpl.Spec.DesktopConfig.Ulimits = nil
+	tmpl.Spec.DesktopConfig.ShmSize = "0"
+	if msg := checkInvalidProcessLimits(cluster, tmpl); !strings.Contains(msg, "must be positive") {
+		t.Error("Expected a zero shm size to be rejected, got:", msg)
+	}
+}
+
+func TestPorts(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DesktopConfig: &desktopsv1.DesktopConfig{
+				Ports: []corev1.ContainerPort{{ContainerPort: 3000}, {Name: "docs", ContainerPort: 8000}},
+			},
+			IDEConfig: &desktopsv1.IDEConfig{},
+		},
+	}
+	if msg := checkInvalidPorts(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding for unique ports, got:", msg)
+	}
+
+	tmpl.Spec.DesktopConfig.Ports = append(tmpl.Spec.DesktopConfig.Ports,
+		corev1.ContainerPort{ContainerPort: 3000},
+		corev1.ContainerPort{Name: "web", ContainerPort: v1.WebPort},
+		corev1.ContainerPort{ContainerPort: 8080},
+	)
+	tmpl.Spec.IDEConfig.Port = 8000
+	msg := checkInvalidPorts(cluster, tmpl)
+	for _, expected := range []string{
+		"port 3000 collides with port 3000",
+		"web collides with the kvdi-proxy",
+		"ide collides with docs on 8000",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in finding, got: %s", expected, msg)
+		}
+	}
+	if strings.Contains(msg, "port 8080") {
+		t.Error("Expected the default IDE port to be free once the IDE port is changed, got:", msg)
+	}
+}
+
+func TestMaintenanceWithoutPull(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	tmpl := &desktopsv1.Template{
+		Spec: desktopsv1.TemplateSpec{
+			DesktopConfig: &desktopsv1.DesktopConfig{},
+		},
+	}
+	if msg := checkMaintenanceWithoutPull(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding without a maintenance window, got:", msg)
+	}
+	tmpl.Spec.Maintenance = &desktopsv1.MaintenanceWindow{}
+	if msg := checkMaintenanceWithoutPull(cluster, tmpl); !strings.Contains(msg, "IfNotPresent") {
+		t.Error("Expected a finding for the default pull policy, got:", msg)
+	}
+	tmpl.Spec.DesktopConfig.ImagePullPolicy = corev1.PullAlways
+	if msg := checkMaintenanceWithoutPull(cluster, tmpl); msg != "" {
+		t.Error("Expected no finding when images are always pulled, got:", msg)
+	}
+}
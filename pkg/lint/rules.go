@@ -0,0 +1,568 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Names of the built-in lint rules
+const (
+	RulePrivilegedWithoutJustification = "privileged-without-justification"
+	RuleMissingResourceLimits          = "missing-resource-limits"
+	RuleLatestTag                      = "latest-tag"
+	RuleNoUserdataWhenPersistent       = "no-userdata-when-persistent"
+	RuleHeadlessIDEWithoutImage        = "headless-ide-without-image"
+	RuleImageMirrorWithoutHost         = "image-mirror-without-host"
+	RuleHighRiskWithoutSandbox         = "high-risk-without-sandbox"
+	RuleUnsupportedImageVariant        = "unsupported-image-variant"
+	RuleStaticHostWithoutAddress       = "static-host-without-address"
+	RuleInvalidFirewallPort            = "invalid-firewall-port"
+	RuleUnreachableFirewallPort        = "unreachable-firewall-port"
+	RuleInvalidLaunchPrompt            = "invalid-launch-prompt"
+	RuleInvalidFileTransfer            = "invalid-file-transfer"
+	RuleInvalidAudio                   = "invalid-audio"
+	RuleInvalidGPU                     = "invalid-gpu"
+	RuleInvalidMonitors                = "invalid-monitors"
+	RuleInvalidPlugins                 = "invalid-plugins"
+	RuleInvalidDisplayResize           = "invalid-display-resize"
+	RuleInvalidProcessLimits           = "invalid-process-limits"
+	RuleInvalidPorts                   = "invalid-ports"
+	RuleMaintenanceWithoutPull         = "maintenance-without-pull"
+)
+
+// migProfileRegex matches NVIDIA MIG profile names, such as `1g.5gb` or `1g.10gb+me`.
+var migProfileRegex = regexp.MustCompile(`^[0-9]+g\.[0-9]+gb(\+me)?$`)
+
+func init() {
+	Register(&Rule{
+		Name:            RulePrivilegedWithoutJustification,
+		Description:     "Templates running privileged containers should explain why in an annotation",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkPrivilegedWithoutJustification,
+	})
+	Register(&Rule{
+		Name:            RuleMissingResourceLimits,
+		Description:     "Templates should declare resource limits for the desktop container",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkMissingResourceLimits,
+	})
+	Register(&Rule{
+		Name:            RuleLatestTag,
+		Description:     "Templates should pin their images to a tag other than latest",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkLatestTag,
+	})
+	Register(&Rule{
+		Name:            RuleNoUserdataWhenPersistent,
+		Description:     "Templates expecting a persistent $HOME require userdata to be configured on the cluster",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkNoUserdataWhenPersistent,
+	})
+	Register(&Rule{
+		Name:            RuleHeadlessIDEWithoutImage,
+		Description:     "Templates running a headless IDE must provide an image for the IDE server",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkHeadlessIDEWithoutImage,
+	})
+	Register(&Rule{
+		Name:            RuleImageMirrorWithoutHost,
+		Description:     "Templates streaming images through a registry mirror must provide the mirror host or use the cluster image cache",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkImageMirrorWithoutHost,
+	})
+	Register(&Rule{
+		Name:            RuleHighRiskWithoutSandbox,
+		Description:     "High risk templates should be covered by the sandbox policy of the cluster",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkHighRiskWithoutSandbox,
+	})
+	Register(&Rule{
+		Name:            RuleUnsupportedImageVariant,
+		Description:     "Image variants must be for architectures the template supports",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkUnsupportedImageVariant,
+	})
+	Register(&Rule{
+		Name:            RuleStaticHostWithoutAddress,
+		Description:     "Templates connecting to a static host must provide its address",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkStaticHostWithoutAddress,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidFirewallPort,
+		Description:     "Ports allowed by a template firewall must be valid port numbers and protocols",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidFirewallPort,
+	})
+	Register(&Rule{
+		Name:            RuleUnreachableFirewallPort,
+		Description:     "Ports allowed by a template firewall should be exposed or reachable from other sessions",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkUnreachableFirewallPort,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidLaunchPrompt,
+		Description:     "Launch prompts must have unique names, choices must have options, and patterns must compile",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidLaunchPrompt,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidFileTransfer,
+		Description:     "File transfer limits must have a valid size and content types",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidFileTransfer,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidAudio,
+		Description:     "Audio must use a bitrate and sample rate supported by Opus",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidAudio,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidGPU,
+		Description:     "GPUs must be attached to a desktop container, and MIG profiles must be valid NVIDIA profiles",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidGPU,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidMonitors,
+		Description:     "Multiple monitors require a VNC display served from a desktop container, and a supported number of monitors",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidMonitors,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidPlugins,
+		Description:     "Sidecar plugins must have unique names that are valid DNS labels",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidPlugins,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidDisplayResize,
+		Description:     "Resizing the display requires a VNC display served from a desktop container, and an X display to resize",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidDisplayResize,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidProcessLimits,
+		Description:     "Sysctls must be in the Kubernetes safe set, and ulimits and the shm size must be valid",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidProcessLimits,
+	})
+	Register(&Rule{
+		Name:            RuleInvalidPorts,
+		Description:     "Exposed ports and the IDE port must be unique and not collide with the ports used by the kvdi-proxy",
+		DefaultSeverity: appv1.LintSeverityError,
+		Check:           checkInvalidPorts,
+	})
+	Register(&Rule{
+		Name:            RuleMaintenanceWithoutPull,
+		Description:     "Templates with a maintenance window only pick up new image revisions with an imagePullPolicy of Always",
+		DefaultSeverity: appv1.LintSeverityWarning,
+		Check:           checkMaintenanceWithoutPull,
+	})
+}
+
+func checkPrivilegedWithoutJustification(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.IsQEMUTemplate() || tmpl.IsStaticHostTemplate() {
+		return ""
+	}
+	if tmpl.GetInitSystem() != desktopsv1.InitSystemd && !tmpl.DindIsEnabled() {
+		return ""
+	}
+	if annotations := tmpl.GetAnnotations(); annotations != nil && annotations[v1.PrivilegedJustificationAnnotation] != "" {
+		return ""
+	}
+	return fmt.Sprintf("Template runs privileged containers but has no %s annotation", v1.PrivilegedJustificationAnnotation)
+}
+
+func checkMissingResourceLimits(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.IsQEMUTemplate() {
+		if len(tmpl.GetQEMURunnerResources().Limits) == 0 {
+			return "No resource limits are defined for the qemu runner"
+		}
+		return ""
+	}
+	if tmpl.IsStaticHostTemplate() {
+		if tmpl.StaticHostUsesBridge() && len(tmpl.GetStaticHostBridgeResources().Limits) == 0 {
+			return "No resource limits are defined for the RDP bridge"
+		}
+		return ""
+	}
+	if len(tmpl.GetDesktopResources().Limits) == 0 {
+		return "No resource limits are defined for the desktop container"
+	}
+	return ""
+}
+
+func checkLatestTag(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	image := tmpl.GetDesktopImage()
+	if tmpl.IsQEMUTemplate() {
+		image = tmpl.GetQEMUDiskImage()
+	} else if tmpl.IsStaticHostTemplate() {
+		image = ""
+		if tmpl.StaticHostUsesBridge() {
+			image = tmpl.GetStaticHostBridgeImage()
+		}
+	}
+	if image == "" || !isLatestImage(image) {
+		return ""
+	}
+	return fmt.Sprintf("Image %q is not pinned to a specific tag", image)
+}
+
+func checkNoUserdataWhenPersistent(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	annotations := tmpl.GetAnnotations()
+	if annotations == nil || annotations[v1.PersistentHomeAnnotation] != "true" {
+		return ""
+	}
+	if selector := cluster.GetUserdataSelector(); selector != nil && selector.IsValid() {
+		return ""
+	}
+	if cluster.GetUserdataVolumeSpec() != nil {
+		return ""
+	}
+	return "Template expects a persistent $HOME but the cluster has no userdata configuration"
+}
+
+func checkHeadlessIDEWithoutImage(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.Spec.IDEConfig == nil || !tmpl.Spec.IDEConfig.Headless || tmpl.IDEIsSidecar() {
+		return ""
+	}
+	return "IDE is set to headless but no image is configured, the graphical desktop will be used instead"
+}
+
+func checkImageMirrorWithoutHost(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.GetImageStreamingMode() != desktopsv1.ImageStreamingMirror || tmpl.GetImageMirror(cluster) != "" {
+		return ""
+	}
+	return "Image streaming is set to mirror but no mirror host is configured and the cluster image cache is not enabled, images will be pulled from their source registries"
+}
+
+func checkHighRiskWithoutSandbox(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.GetRiskLevel(cluster) != desktopsv1.RiskLevelHigh {
+		return ""
+	}
+	if cluster.GetSandboxRuntimeClass(string(desktopsv1.RiskLevelHigh)) != "" {
+		return ""
+	}
+	return "Template is high risk but the cluster has no sandbox runtime class configured for high risk templates"
+}
+
+func checkUnsupportedImageVariant(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if !tmpl.HasImageVariants() {
+		return ""
+	}
+	unsupported := make([]string, 0)
+	for _, variant := range tmpl.Spec.DesktopConfig.ImageVariants {
+		if !tmpl.SupportsArchitecture(variant.Architecture) {
+			unsupported = append(unsupported, string(variant.Architecture))
+		}
+	}
+	if len(unsupported) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has image variants for architectures it does not support: %s", strings.Join(unsupported, ", "))
+}
+
+func checkStaticHostWithoutAddress(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if !tmpl.IsStaticHostTemplate() || tmpl.GetStaticHostAddress() != "" {
+		return ""
+	}
+	return "Template connects to a static host but no address is configured"
+}
+
+func checkInvalidFirewallPort(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	invalid := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, port := range tmpl.GetFirewallPorts() {
+		key := fmt.Sprintf("%d/%s", port.Port, port.GetProtocol())
+		switch port.GetProtocol() {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			invalid = append(invalid, key)
+			continue
+		}
+		if port.Port < 1 || port.Port > 65535 {
+			invalid = append(invalid, key)
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			invalid = append(invalid, key+" (duplicate)")
+		}
+		seen[key] = struct{}{}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template firewall allows invalid ports: %s", strings.Join(invalid, ", "))
+}
+
+func checkUnreachableFirewallPort(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	unreachable := make([]string, 0)
+	for _, port := range tmpl.GetFirewallPorts() {
+		if port.AllowFromSessions || tmpl.HasExposedPort(port.Port) {
+			continue
+		}
+		unreachable = append(unreachable, fmt.Sprintf("%d/%s", port.Port, port.GetProtocol()))
+	}
+	if len(unreachable) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template firewall allows ports that are neither exposed to the session owner nor open to other sessions: %s", strings.Join(unreachable, ", "))
+}
+
+func checkInvalidLaunchPrompt(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	invalid := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, prompt := range tmpl.GetLaunchPrompts() {
+		if prompt.Name == "" {
+			invalid = append(invalid, fmt.Sprintf("%q (no name)", prompt.GetLabel()))
+			continue
+		}
+		if _, ok := seen[prompt.Name]; ok {
+			invalid = append(invalid, prompt.Name+" (duplicate)")
+		}
+		seen[prompt.Name] = struct{}{}
+		switch prompt.GetType() {
+		case desktopsv1.LaunchPromptText:
+			if prompt.Pattern != "" {
+				if _, err := regexp.Compile(prompt.Pattern); err != nil {
+					invalid = append(invalid, prompt.Name+" (invalid pattern)")
+				}
+			}
+		case desktopsv1.LaunchPromptChoice:
+			if len(prompt.Options) == 0 {
+				invalid = append(invalid, prompt.Name+" (no options)")
+			}
+		case desktopsv1.LaunchPromptAcknowledge:
+		default:
+			invalid = append(invalid, fmt.Sprintf("%s (unknown type %s)", prompt.Name, prompt.Type))
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid launch prompts: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidFileTransfer(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.Spec.ProxyConfig == nil || tmpl.Spec.ProxyConfig.FileTransfer == nil {
+		return ""
+	}
+	invalid := make([]string, 0)
+	if size := tmpl.Spec.ProxyConfig.FileTransfer.MaxFileSize; size != "" {
+		if quantity, err := resource.ParseQuantity(size); err != nil || quantity.Sign() <= 0 {
+			invalid = append(invalid, fmt.Sprintf("maxFileSize %s", size))
+		}
+	}
+	for _, contentType := range tmpl.GetAllowedFileContentTypes() {
+		if contentType == "*" {
+			continue
+		}
+		parts := strings.Split(contentType, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || (parts[0] == "*" && parts[1] != "*") {
+			invalid = append(invalid, fmt.Sprintf("content type %s", contentType))
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid file transfer limits: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidAudio(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.Spec.AudioConfig == nil {
+		return ""
+	}
+	invalid := make([]string, 0)
+	if bitrate := tmpl.Spec.AudioConfig.Bitrate; bitrate != 0 && (bitrate < 6000 || bitrate > 510000) {
+		invalid = append(invalid, fmt.Sprintf("bitrate %d", bitrate))
+	}
+	switch rate := tmpl.Spec.AudioConfig.SampleRate; rate {
+	case 0, 8000, 12000, 16000, 24000, 48000:
+	default:
+		invalid = append(invalid, fmt.Sprintf("sampleRate %d", rate))
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid audio settings: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidGPU(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if !tmpl.GPUsEnabled() {
+		return ""
+	}
+	invalid := make([]string, 0)
+	if tmpl.IsStaticHostTemplate() || tmpl.IsQEMUTemplate() || tmpl.IDEIsHeadless() {
+		invalid = append(invalid, "template does not run a desktop container")
+	}
+	if tmpl.Spec.GPUs.Count < 0 {
+		invalid = append(invalid, fmt.Sprintf("count %d", tmpl.Spec.GPUs.Count))
+	}
+	if profile := tmpl.GetGPUMIGProfile(); profile != "" {
+		if tmpl.GetGPUVendor() != desktopsv1.GPUVendorNVIDIA {
+			invalid = append(invalid, fmt.Sprintf("migProfile for %s GPUs", tmpl.GetGPUVendor()))
+		} else if !migProfileRegex.MatchString(profile) {
+			invalid = append(invalid, fmt.Sprintf("migProfile %s", profile))
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid GPU settings: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidMonitors(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.Spec.DisplayConfig == nil || tmpl.Spec.DisplayConfig.Monitors == nil {
+		return ""
+	}
+	cfg := tmpl.Spec.DisplayConfig.Monitors
+	invalid := make([]string, 0)
+	if cfg.Max < 0 || cfg.Max > v1.MaxDesktopMonitors {
+		invalid = append(invalid, fmt.Sprintf("max %d, must be at most %d", cfg.Max, v1.MaxDesktopMonitors))
+	}
+	if res := cfg.MaxResolution; res != nil && (res.Width < 0 || res.Height < 0) {
+		invalid = append(invalid, fmt.Sprintf("maxResolution %s", res.String()))
+	}
+	if cfg.Max > 1 {
+		if !tmpl.DisplayIsVNC() {
+			invalid = append(invalid, fmt.Sprintf("%s displays", tmpl.GetDisplayProtocol()))
+		}
+		if tmpl.IsStaticHostTemplate() || tmpl.IsQEMUTemplate() {
+			invalid = append(invalid, "template does not run a desktop container")
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid monitor settings: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidDisplayResize(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if tmpl.Spec.DisplayConfig == nil || tmpl.Spec.DisplayConfig.Resize == nil || !tmpl.Spec.DisplayConfig.Resize.Enabled {
+		return ""
+	}
+	invalid := make([]string, 0)
+	if !tmpl.DisplayIsVNC() {
+		invalid = append(invalid, fmt.Sprintf("%s displays", tmpl.GetDisplayProtocol()))
+	}
+	if tmpl.IsStaticHostTemplate() || tmpl.IsQEMUTemplate() {
+		invalid = append(invalid, "template does not run a desktop container")
+	}
+	if xdisplay := tmpl.GetResizeXDisplay(); !strings.Contains(xdisplay, ":") {
+		invalid = append(invalid, fmt.Sprintf("xDisplay %q is not an X display", xdisplay))
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid display resize settings: %s", strings.Join(invalid, ", "))
+}
+
+func checkInvalidProcessLimits(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if err := tmpl.ValidateProcessLimits(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func checkInvalidPorts(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	invalid := make([]string, 0)
+	seen := map[int32]string{
+		v1.WebPort:          "the kvdi-proxy",
+		v1.ProxyMetricsPort: "kvdi-proxy metrics",
+	}
+	ports := append([]corev1.ContainerPort{}, tmpl.GetExposedPorts()...)
+	if tmpl.IDEIsEnabled() {
+		ports = append(ports, corev1.ContainerPort{Name: "ide", ContainerPort: tmpl.GetIDEPort()})
+	}
+	for _, port := range ports {
+		name := port.Name
+		if name == "" {
+			name = fmt.Sprintf("port %d", port.ContainerPort)
+		}
+		if port.ContainerPort <= 0 || port.ContainerPort > 65535 {
+			invalid = append(invalid, fmt.Sprintf("%s is not a valid port", name))
+			continue
+		}
+		if other, ok := seen[port.ContainerPort]; ok {
+			invalid = append(invalid, fmt.Sprintf("%s collides with %s on %d", name, other, port.ContainerPort))
+			continue
+		}
+		seen[port.ContainerPort] = name
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid ports: %s", strings.Join(invalid, ", "))
+}
+
+func checkMaintenanceWithoutPull(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	if !tmpl.MaintenanceIsEnabled() || tmpl.GetDesktopPullPolicy() == corev1.PullAlways {
+		return ""
+	}
+	return fmt.Sprintf("Maintenance will not pull new revisions of the desktop image with an imagePullPolicy of %s", tmpl.GetDesktopPullPolicy())
+}
+
+func checkInvalidPlugins(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) string {
+	invalid := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, name := range tmpl.GetPluginNames() {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			invalid = append(invalid, fmt.Sprintf("%q is not a valid name", name))
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			invalid = append(invalid, fmt.Sprintf("%s is declared more than once", name))
+		}
+		seen[name] = struct{}{}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Template has invalid plugins: %s", strings.Join(invalid, ", "))
+}
+
+// isLatestImage returns true if the given image reference has no tag or digest, or
+// uses the latest tag.
+func isLatestImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image
+	if idx := strings.LastIndex(image, "/"); idx != -1 {
+		name = image[idx+1:]
+	}
+	if !strings.Contains(name, ":") {
+		return true
+	}
+	return strings.HasSuffix(name, ":latest")
+}
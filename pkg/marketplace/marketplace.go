@@ -0,0 +1,183 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package marketplace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/provenance"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// maxDocumentSize is the largest index or template manifest that will be downloaded.
+const maxDocumentSize = 10 << 20
+
+// Index is a listing of desktop templates served by a remote index.
+type Index struct {
+	// The templates available from the index.
+	Templates []*Entry `json:"templates"`
+}
+
+// Entry represents a single template listed in an index.
+type Entry struct {
+	// The name of the template. This must match the name in the manifest.
+	Name string `json:"name"`
+	// The version of the template.
+	Version string `json:"version"`
+	// A short description of the template.
+	Description string `json:"description,omitempty"`
+	// Tags for searching templates.
+	Tags []string `json:"tags,omitempty"`
+	// The URL of the template manifest. Relative URLs are resolved against the URL of
+	// the index.
+	URL string `json:"url"`
+	// The sha256 digest of the template manifest in the format `sha256:<hex>`.
+	Digest string `json:"digest"`
+	// An optional base64 encoded signature of the template manifest, in the format produced
+	// by `cosign sign-blob`. Required when the cluster's image policy verifies templates.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Get returns the entry for the template with the given name, or nil if the index
+// does not list it.
+func (i *Index) Get(name string) *Entry {
+	for _, entry := range i.Templates {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Client retrieves and verifies documents from remote template indexes.
+type Client struct {
+	httpClient *http.Client
+}
+
+// New returns a new marketplace client. If httpClient is nil, a client with a default
+// timeout is used.
+func New(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{httpClient: httpClient}
+}
+
+// FetchIndex retrieves the given index and verifies its signature.
+func (c *Client) FetchIndex(idx appv1.TemplateIndex) (*Index, error) {
+	body, err := c.get(idx.URL)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := c.get(idx.URL + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("Could not retrieve signature for index %q: %s", idx.Name, err.Error())
+	}
+	if err := provenance.VerifyBlob(idx.PublicKey, body, sig); err != nil {
+		return nil, fmt.Errorf("Index %q failed verification: %s", idx.Name, err.Error())
+	}
+	index := &Index{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096).Decode(index); err != nil {
+		return nil, fmt.Errorf("Could not decode index %q: %s", idx.Name, err.Error())
+	}
+	return index, nil
+}
+
+// FetchTemplate retrieves the manifest for the given entry in the index and verifies it
+// against the digest recorded in the index. If any trusted keys are given, the signature
+// listed for the entry must also verify against one of them. The returned template is
+// annotated with its provenance.
+func (c *Client) FetchTemplate(idx appv1.TemplateIndex, entry *Entry, trustedKeys []appv1.SigningKey) (*desktopsv1.Template, error) {
+	base, err := url.Parse(idx.URL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.get(base.ResolveReference(ref).String())
+	if err != nil {
+		return nil, err
+	}
+	if err := provenance.VerifyDigest(entry.Digest, body); err != nil {
+		return nil, fmt.Errorf("Template %q from index %q failed verification: %s", entry.Name, idx.Name, err.Error())
+	}
+	if len(trustedKeys) > 0 {
+		if entry.Signature == "" {
+			return nil, fmt.Errorf("Template %q from index %q is not signed", entry.Name, idx.Name)
+		}
+		if _, err := provenance.VerifyBlobWithKeys(trustedKeys, body, []byte(entry.Signature)); err != nil {
+			return nil, fmt.Errorf("Template %q from index %q failed verification: %s", entry.Name, idx.Name, err.Error())
+		}
+	}
+	tmpl := &desktopsv1.Template{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(body), 4096).Decode(tmpl); err != nil {
+		return nil, fmt.Errorf("Could not decode template %q: %s", entry.Name, err.Error())
+	}
+	if tmpl.GetName() != entry.Name {
+		return nil, fmt.Errorf("Index %q lists template %q but the manifest is for %q", idx.Name, entry.Name, tmpl.GetName())
+	}
+
+	// Strip any server-side state that may have been exported with the manifest
+	tmpl.SetResourceVersion("")
+	tmpl.SetUID("")
+	tmpl.SetNamespace("")
+	tmpl.Status = desktopsv1.TemplateStatus{}
+
+	annotations := tmpl.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1.MarketplaceIndexAnnotation] = idx.Name
+	annotations[v1.MarketplaceVersionAnnotation] = entry.Version
+	annotations[v1.MarketplaceDigestAnnotation] = entry.Digest
+	tmpl.SetAnnotations(annotations)
+	return tmpl, nil
+}
+
+func (c *Client) get(u string) ([]byte, error) {
+	resp, err := c.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", u, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxDocumentSize {
+		return nil, fmt.Errorf("GET %s returned more than %d bytes", u, maxDocumentSize)
+	}
+	return body, nil
+}
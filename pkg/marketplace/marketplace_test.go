@@ -0,0 +1,164 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package marketplace
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+)
+
+var testTemplate = []byte(`apiVersion: desktops.kvdi.io/v1
+kind: Template
+metadata:
+  name: ubuntu-xfce
+spec:
+  desktop:
+    image: ghcr.io/kvdi/ubuntu-xfce4:latest
+`)
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodePublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustSign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig))
+}
+
+// newTestIndex serves an index listing testTemplate with the given digest, signed by key.
+func newTestIndex(t *testing.T, key *ecdsa.PrivateKey, digest string) *httptest.Server {
+	t.Helper()
+	index := []byte(fmt.Sprintf(`templates:
+- name: ubuntu-xfce
+  version: 1.0.0
+  url: templates/ubuntu-xfce.yaml
+  digest: %s
+`, digest))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, r *http.Request) { w.Write(index) })
+	mux.HandleFunc("/index.yaml.sig", func(w http.ResponseWriter, r *http.Request) { w.Write(mustSign(t, key, index)) })
+	mux.HandleFunc("/templates/ubuntu-xfce.yaml", func(w http.ResponseWriter, r *http.Request) { w.Write(testTemplate) })
+	return httptest.NewServer(mux)
+}
+
+func testDigest() string {
+	sum := sha256.Sum256(testTemplate)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestFetchTemplate(t *testing.T) {
+	key := mustGenerateKey(t)
+	srvr := newTestIndex(t, key, testDigest())
+	defer srvr.Close()
+
+	idx := appv1.TemplateIndex{Name: "community", URL: srvr.URL + "/index.yaml", PublicKey: encodePublicKey(t, key)}
+	cl := New(nil)
+
+	index, err := cl.FetchIndex(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := index.Get("ubuntu-xfce")
+	if entry == nil {
+		t.Fatal("Expected index to list ubuntu-xfce, got:", index.Templates)
+	}
+	if index.Get("arch-xfce") != nil {
+		t.Error("Expected nil entry for template not in index")
+	}
+
+	tmpl, err := cl.FetchTemplate(idx, entry, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.GetName() != "ubuntu-xfce" {
+		t.Error("Expected ubuntu-xfce template, got:", tmpl.GetName())
+	}
+	annotations := tmpl.GetAnnotations()
+	if annotations[v1.MarketplaceIndexAnnotation] != "community" {
+		t.Error("Expected index annotation, got:", annotations)
+	}
+	if annotations[v1.MarketplaceVersionAnnotation] != "1.0.0" {
+		t.Error("Expected version annotation, got:", annotations)
+	}
+	if annotations[v1.MarketplaceDigestAnnotation] != testDigest() {
+		t.Error("Expected digest annotation, got:", annotations)
+	}
+}
+
+func TestFetchIndexBadSignature(t *testing.T) {
+	key := mustGenerateKey(t)
+	srvr := newTestIndex(t, key, testDigest())
+	defer srvr.Close()
+
+	// verify against a different key
+	idx := appv1.TemplateIndex{Name: "community", URL: srvr.URL + "/index.yaml", PublicKey: encodePublicKey(t, mustGenerateKey(t))}
+	if _, err := New(nil).FetchIndex(idx); err == nil {
+		t.Error("Expected error verifying index signed by another key, got nil")
+	}
+}
+
+func TestFetchTemplateBadDigest(t *testing.T) {
+	key := mustGenerateKey(t)
+	sum := sha256.Sum256([]byte("not the template"))
+	srvr := newTestIndex(t, key, "sha256:"+hex.EncodeToString(sum[:]))
+	defer srvr.Close()
+
+	idx := appv1.TemplateIndex{Name: "community", URL: srvr.URL + "/index.yaml", PublicKey: encodePublicKey(t, key)}
+	cl := New(nil)
+	index, err := cl.FetchIndex(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.FetchTemplate(idx, index.Get("ubuntu-xfce"), nil); err == nil {
+		t.Error("Expected error fetching template with mismatched digest, got nil")
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Limits on the metadata stored for each user.
+const (
+	// MaxUserSize is the maximum combined size in bytes of the namespaces, keys, and values
+	// stored for a single user.
+	MaxUserSize = 16 * 1024
+	// MaxNameLength is the maximum length of a namespace or key.
+	MaxNameLength = 128
+)
+
+// nameRegex is the pattern namespaces and keys must match.
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ValidateName returns an error if the given namespace or key is not valid.
+func ValidateName(name string) error {
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("%q is longer than %d characters", name, MaxNameLength)
+	}
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("%q must start with a letter or number and contain only letters, numbers, '.', '_', or '-'", name)
+	}
+	return nil
+}
+
+// Store is the metadata for a single user, mapping namespaces to their keys and values.
+type Store map[string]map[string]string
+
+// Size returns the combined size in bytes of the namespaces, keys, and values in the store.
+func (s Store) Size() int {
+	var size int
+	for ns, values := range s {
+		size += len(ns)
+		for key, value := range values {
+			size += len(key) + len(value)
+		}
+	}
+	return size
+}
+
+// Set sets the value of the given key, returning an error if the names are invalid or
+// the store would grow beyond MaxUserSize.
+func (s Store) Set(namespace, key, value string) error {
+	if err := ValidateName(namespace); err != nil {
+		return err
+	}
+	if err := ValidateName(key); err != nil {
+		return err
+	}
+	size := s.Size() + len(key) + len(value)
+	if values, ok := s[namespace]; ok {
+		if old, ok := values[key]; ok {
+			size -= len(key) + len(old)
+		}
+	} else {
+		size += len(namespace)
+	}
+	if size > MaxUserSize {
+		return fmt.Errorf("Setting %s/%s would exceed the limit of %d bytes of metadata per user", namespace, key, MaxUserSize)
+	}
+	if _, ok := s[namespace]; !ok {
+		s[namespace] = make(map[string]string)
+	}
+	s[namespace][key] = value
+	return nil
+}
+
+// Delete removes the given key, and its namespace if it is left empty. It returns false if
+// the key did not exist.
+func (s Store) Delete(namespace, key string) bool {
+	values, ok := s[namespace]
+	if !ok {
+		return false
+	}
+	if _, ok := values[key]; !ok {
+		return false
+	}
+	delete(values, key)
+	if len(values) == 0 {
+		delete(s, namespace)
+	}
+	return true
+}
+
+// Manager is an object for storing the metadata of users. It uses the configured secrets
+// backend for storage.
+type Manager struct {
+	secrets *secrets.SecretEngine
+}
+
+// NewManager returns a new metadata manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets}
+}
+
+// Get returns all of the metadata for the given user.
+func (m *Manager) Get(user string) (Store, error) {
+	all, err := m.secrets.ReadSecretMap(v1.UserMetadataSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return Store{}, nil
+		}
+		return nil, err
+	}
+	return decodeStore(all[user])
+}
+
+// GetNamespace returns the keys and values in the given namespace for the user.
+func (m *Manager) GetNamespace(user, namespace string) (map[string]string, error) {
+	store, err := m.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := store[namespace]
+	if !ok {
+		return nil, errors.NewMetadataNotFoundError(user, namespace)
+	}
+	return values, nil
+}
+
+// GetValue returns the value of the given key for the user.
+func (m *Manager) GetValue(user, namespace, key string) (string, error) {
+	values, err := m.GetNamespace(user, namespace)
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", errors.NewMetadataNotFoundError(user, fmt.Sprintf("%s/%s", namespace, key))
+	}
+	return value, nil
+}
+
+// SetValue sets the value of the given key for the user.
+func (m *Manager) SetValue(user, namespace, key, value string) error {
+	return m.update(user, func(store Store) error {
+		return store.Set(namespace, key, value)
+	})
+}
+
+// DeleteValue removes the given key for the user.
+func (m *Manager) DeleteValue(user, namespace, key string) error {
+	return m.update(user, func(store Store) error {
+		if !store.Delete(namespace, key) {
+			return errors.NewMetadataNotFoundError(user, fmt.Sprintf("%s/%s", namespace, key))
+		}
+		return nil
+	})
+}
+
+// DeleteNamespace removes the given namespace and all of its keys for the user.
+func (m *Manager) DeleteNamespace(user, namespace string) error {
+	return m.update(user, func(store Store) error {
+		if _, ok := store[namespace]; !ok {
+			return errors.NewMetadataNotFoundError(user, namespace)
+		}
+		delete(store, namespace)
+		return nil
+	})
+}
+
+// DeleteUser removes all of the metadata for the user.
+func (m *Manager) DeleteUser(user string) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	all, err := m.secrets.ReadSecretMap(v1.UserMetadataSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	if _, ok := all[user]; !ok {
+		return nil
+	}
+	delete(all, user)
+	return m.secrets.WriteSecretMap(v1.UserMetadataSecretKey, all)
+}
+
+func (m *Manager) update(user string, f func(Store) error) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	all, err := m.secrets.ReadSecretMap(v1.UserMetadataSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		all = make(map[string][]byte)
+	}
+	store, err := decodeStore(all[user])
+	if err != nil {
+		return err
+	}
+	if err := f(store); err != nil {
+		return err
+	}
+	if len(store) == 0 {
+		delete(all, user)
+	} else {
+		data, err := json.Marshal(store)
+		if err != nil {
+			return err
+		}
+		all[user] = data
+	}
+	return m.secrets.WriteSecretMap(v1.UserMetadataSecretKey, all)
+}
+
+func decodeStore(data []byte) (Store, error) {
+	store := Store{}
+	if len(data) == 0 {
+		return store, nil
+	}
+	return store, json.Unmarshal(data, &store)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, valid := range []string{"ui", "agent.v1", "theme_color", "0-prefs"} {
+		if err := ValidateName(valid); err != nil {
+			t.Errorf("Expected %q to be valid, got: %s", valid, err)
+		}
+	}
+	for _, invalid := range []string{"", ".hidden", "a/b", "with space", strings.Repeat("a", MaxNameLength+1)} {
+		if err := ValidateName(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := Store{}
+	if err := store.Set("ui", "theme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+	if size := store.Size(); size != len("ui")+len("theme")+len("dark") {
+		t.Error("Unexpected store size:", size)
+	}
+	if err := store.Set("ui", "theme", "light"); err != nil {
+		t.Fatal(err)
+	}
+	if store["ui"]["theme"] != "light" {
+		t.Error("Expected value to be replaced, got:", store["ui"]["theme"])
+	}
+	if err := store.Set("ui", "bad/key", "value"); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+
+	// fill the store up to the limit
+	remaining := MaxUserSize - store.Size() - len("agent") - len("state")
+	if err := store.Set("agent", "state", strings.Repeat("a", remaining)); err != nil {
+		t.Fatal("Expected value up to the limit to be stored, got:", err)
+	}
+	if err := store.Set("agent", "more", "a"); err == nil {
+		t.Error("Expected error when exceeding the size limit")
+	}
+	// replacing a value with a smaller one is allowed at the limit
+	if err := store.Set("agent", "state", "small"); err != nil {
+		t.Error("Expected smaller value to replace larger one, got:", err)
+	}
+
+	if store.Delete("ui", "missing") {
+		t.Error("Expected false deleting missing key")
+	}
+	if !store.Delete("ui", "theme") {
+		t.Error("Expected true deleting existing key")
+	}
+	if _, ok := store["ui"]; ok {
+		t.Error("Expected empty namespace to be removed")
+	}
+}
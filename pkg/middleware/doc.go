@@ -0,0 +1,30 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package middleware lets deployments run their own middleware on API requests without
+// changing how the router is built. Middleware is either compiled into the app, by a plugin
+// package calling Register from its init function and being blank-imported into
+// cmd/app/plugins.go, or run by an external webhook filter configured on the VDICluster.
+//
+// Each middleware runs at a stage. PreAuth middleware runs on every API request before
+// the user session is validated, and may add or rewrite the headers read by the auth
+// provider. PostAuth middleware runs on authenticated requests once the user session and
+// grants are validated, and can read the user from apiutil.GetRequestUserSession.
+// Compiled-in middleware runs before webhook filters of the same stage.
+package middleware
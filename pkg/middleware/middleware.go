@@ -0,0 +1,137 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"github.com/gorilla/mux"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// middlewareLogger is where webhook filter failures are reported.
+var middlewareLogger = logf.Log.WithName("api_middleware")
+
+// Middleware wraps the handling of API requests. The handler is given the next handler in
+// the chain, and may inspect or modify the request before passing it on, wrap the response
+// writer to inspect or modify the response, or write a response of its own instead.
+type Middleware struct {
+	// A unique name for the middleware.
+	Name string
+	// The stage at which the middleware runs.
+	Stage appv1.MiddlewareStage
+	// The function wrapping the next handler.
+	Handler mux.MiddlewareFunc
+}
+
+var (
+	// registered is the middleware compiled into the binary, in the order it was registered
+	registered    []*Middleware
+	registeredMux sync.RWMutex
+)
+
+// Register adds a middleware to the API servers built by this binary. It is meant to be
+// called from the init function of a plugin package. Registering a middleware with the
+// name of an existing one replaces it.
+func Register(m *Middleware) {
+	registeredMux.Lock()
+	defer registeredMux.Unlock()
+	for idx, existing := range registered {
+		if existing.Name == m.Name {
+			registered[idx] = m
+			return
+		}
+	}
+	registered = append(registered, m)
+}
+
+// Registered returns the middleware registered for the given stage, in the order it was
+// registered.
+func Registered(stage appv1.MiddlewareStage) []*Middleware {
+	registeredMux.RLock()
+	defer registeredMux.RUnlock()
+	out := make([]*Middleware, 0)
+	for _, m := range registered {
+		if m.Stage == stage {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Chain runs the middleware registered for a stage followed by the webhook filters
+// configured on the VDICluster for it.
+type Chain struct {
+	mu sync.RWMutex
+	// the webhook filters built from the VDICluster configuration
+	webhooks []*webhookFilter
+}
+
+// NewChain returns a new Chain. Until Setup is called only registered middleware runs.
+func NewChain() *Chain { return &Chain{} }
+
+// Setup builds the webhook filters from the configuration on the given VDICluster. It is
+// safe to call again when the configuration changes.
+func (c *Chain) Setup(cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) error {
+	webhooks := make([]*webhookFilter, 0)
+	for _, cfg := range cluster.GetMiddlewareWebhooks() {
+		filter, err := newWebhookFilter(cfg, secretsEngine)
+		if err != nil {
+			return err
+		}
+		webhooks = append(webhooks, filter)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.webhooks = webhooks
+	return nil
+}
+
+// Handler returns a mux.MiddlewareFunc running the middleware and webhook filters for the
+// given stage.
+func (c *Chain) Handler(stage appv1.MiddlewareStage) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.filtered(stage, next).ServeHTTP(w, r)
+		})
+		mws := Registered(stage)
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i].Handler(h)
+		}
+		return h
+	}
+}
+
+// filtered returns the given handler wrapped by the current webhook filters for the given
+// stage.
+func (c *Chain) filtered(stage appv1.MiddlewareStage, next http.Handler) http.Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.webhooks) - 1; i >= 0; i-- {
+		if c.webhooks[i].stage == stage {
+			next = c.webhooks[i].wrap(next)
+		}
+	}
+	return next
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+func TestRegister(t *testing.T) {
+	defer func() { registered = nil }()
+
+	order := make([]string, 0)
+	mw := func(name string) *Middleware {
+		return &Middleware{
+			Name:  name,
+			Stage: appv1.MiddlewareStagePreAuth,
+			Handler: func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(w, r)
+				})
+			},
+		}
+	}
+	Register(mw("first"))
+	Register(mw("second"))
+	Register(&Middleware{Name: "post", Stage: appv1.MiddlewareStagePostAuth})
+	Register(mw("first"))
+
+	if mws := Registered(appv1.MiddlewareStagePreAuth); len(mws) != 2 {
+		t.Fatal("Expected two PreAuth middlewares, got:", len(mws))
+	}
+
+	handler := NewChain().Handler(appv1.MiddlewareStagePreAuth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/whoami", nil))
+	if len(order) != 3 || order[0] != "first" || order[1] != "second" || order[2] != "handler" {
+		t.Error("Expected middlewares to run in the order they were registered, got:", order)
+	}
+}
+
+func TestWebhookFilter(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &Review{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			t.Error(err)
+		}
+		if review.Headers.Get("X-Session-Token") != "" {
+			t.Error("Expected session tokens to be stripped from the review")
+		}
+		if query, err := url.ParseQuery(review.Query); err != nil {
+			t.Error(err)
+		} else if query.Get("token") != "" || query.Get("code") != "" {
+			t.Error("Expected credentials to be stripped from the review query, got:", review.Query)
+		} else if review.Path == "/api/secrets" && review.Response == nil && query.Get("page") != "2" {
+			t.Error("Expected other query parameters to be kept in the review, got:", review.Query)
+		}
+		switch {
+		case review.Response != nil:
+			json.NewEncoder(w).Encode(&Verdict{Allowed: true, Body: json.RawMessage(`{"secret":"[redacted]"}`)})
+		case review.Path == "/api/denied":
+			json.NewEncoder(w).Encode(&Verdict{Allowed: false, Reason: "not today"})
+		default:
+			json.NewEncoder(w).Encode(&Verdict{Allowed: true, SetHeaders: map[string]string{"X-Forwarded-User": "admin"}})
+		}
+	}))
+	defer srvr.Close()
+
+	chain := NewChain()
+	cluster := &appv1.VDICluster{
+		Spec: appv1.VDIClusterSpec{
+			App: &appv1.AppConfig{
+				Middleware: &appv1.MiddlewareConfig{
+					Webhooks: []appv1.MiddlewareWebhook{
+						{Name: "test", URL: srvr.URL, Stage: appv1.MiddlewareStagePreAuth, Paths: []string{"^/api/(denied|secrets)"}, Responses: true},
+					},
+				},
+			},
+		},
+	}
+	if err := chain.Setup(cluster, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := chain.Handler(appv1.MiddlewareStagePreAuth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/secrets" && r.Header.Get("X-Forwarded-User") != "admin" {
+			t.Error("Expected the webhook to set a request header")
+		}
+		apiutil.WriteJSON(map[string]string{"secret": "hunter2"}, w)
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/secrets?token=secret&code=secret&page=2", nil)
+	req.Header.Set("X-Session-Token", "token")
+	handler.ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != `{"secret":"[redacted]"}` {
+		t.Error("Expected the response to be redacted, got:", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/denied", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Error("Expected the request to be denied, got:", rec.Code)
+	}
+
+	// paths that do not match are not sent to the webhook
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/whoami", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() == `{"secret":"[redacted]"}` {
+		t.Error("Expected the response to be unfiltered, got:", rec.Body.String())
+	}
+
+	// requests fail when the webhook cannot be reached, unless failures are ignored
+	cluster.Spec.App.Middleware.Webhooks[0].URL = "http://127.0.0.1:1"
+	if err := chain.Setup(cluster, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets", nil))
+	if rec.Code == http.StatusOK {
+		t.Error("Expected the request to fail when the webhook is unreachable")
+	}
+	cluster.Spec.App.Middleware.Webhooks[0].FailurePolicy = appv1.MiddlewareFailureIgnore
+	cluster.Spec.App.Middleware.Webhooks[0].Responses = false
+	if err := chain.Setup(cluster, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/secrets", nil)
+	req.Header.Set("X-Forwarded-User", "admin")
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Error("Expected the request to pass when webhook failures are ignored, got:", rec.Code)
+	}
+
+	cluster.Spec.App.Middleware.Webhooks[0].Paths = []string{"("}
+	if err := chain.Setup(cluster, nil); err == nil {
+		t.Error("Expected error for an invalid path expression, got nil")
+	}
+}
+
+func TestResponseBufferFlush(t *testing.T) {
+	// responses written through are flushed to the client
+	rec := httptest.NewRecorder()
+	buf := &responseBuffer{ResponseWriter: rec}
+	buf.Header().Set("Content-Type", "text/event-stream")
+	if _, err := buf.Write([]byte("data: test\n\n")); err != nil {
+		t.Fatal(err)
+	}
+	var w http.ResponseWriter = buf
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("Expected the response buffer to implement http.Flusher")
+	}
+	flusher.Flush()
+	if !rec.Flushed {
+		t.Error("Expected a passed through response to be flushed")
+	}
+
+	// buffered responses are held until they are filtered
+	rec = httptest.NewRecorder()
+	buf = &responseBuffer{ResponseWriter: rec}
+	buf.Header().Set("Content-Type", "application/json")
+	if _, err := buf.Write([]byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	buf.Flush()
+	if rec.Flushed || rec.Body.Len() != 0 {
+		t.Error("Expected a buffered response to not be flushed")
+	}
+}
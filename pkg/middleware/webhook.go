@@ -0,0 +1,367 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// sensitiveHeaders are never sent to webhook filters.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Session-Token"}
+
+// sensitiveQueryParams are never sent to webhook filters. Websocket clients pass their
+// session token in the query, and OIDC callbacks carry the authorization code and state.
+var sensitiveQueryParams = []string{"token", "access_token", "code", "state"}
+
+// Review is the body sent to webhook filters.
+type Review struct {
+	// The stage at which the webhook runs.
+	Stage appv1.MiddlewareStage `json:"stage"`
+	// The method of the request.
+	Method string `json:"method"`
+	// The path of the request.
+	Path string `json:"path"`
+	// The raw query of the request, without credentials.
+	Query string `json:"query,omitempty"`
+	// The headers of the request, without credentials.
+	Headers http.Header `json:"headers,omitempty"`
+	// The user making the request, for PostAuth webhooks.
+	User string `json:"user,omitempty"`
+	// The response to the request, when filtering a response.
+	Response *ReviewResponse `json:"response,omitempty"`
+}
+
+// ReviewResponse is a response sent to a webhook filter.
+type ReviewResponse struct {
+	// The status code of the response.
+	Status int `json:"status"`
+	// The headers of the response, without cookies.
+	Headers http.Header `json:"headers,omitempty"`
+	// The JSON body of the response.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Verdict is the body returned by webhook filters.
+type Verdict struct {
+	// Whether the request, or response, is allowed.
+	Allowed bool `json:"allowed"`
+	// Why the request is denied.
+	Reason string `json:"reason,omitempty"`
+	// The status to deny the request with, 401 or 403. Defaults to 403.
+	Status int `json:"status,omitempty"`
+	// Headers to set on the request, or the response.
+	SetHeaders map[string]string `json:"setHeaders,omitempty"`
+	// Headers to remove from the request, or the response.
+	RemoveHeaders []string `json:"removeHeaders,omitempty"`
+	// A JSON body to replace the response with.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// webhookFilter sends API requests, and optionally their responses, to an external
+// webhook to be allowed, denied, or modified.
+type webhookFilter struct {
+	name          string
+	url           string
+	token         string
+	stage         appv1.MiddlewareStage
+	paths         []*regexp.Regexp
+	responses     bool
+	failurePolicy appv1.MiddlewareFailurePolicy
+	httpClient    *http.Client
+}
+
+// newWebhookFilter returns a filter for the given webhook. The token for the webhook, if
+// any, is read from the given secrets engine.
+func newWebhookFilter(cfg appv1.MiddlewareWebhook, secretsEngine *secrets.SecretEngine) (*webhookFilter, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("Middleware webhook %s has no URL", cfg.Name)
+	}
+	f := &webhookFilter{
+		name:          cfg.Name,
+		url:           cfg.URL,
+		stage:         cfg.GetStage(),
+		responses:     cfg.Responses,
+		failurePolicy: cfg.GetFailurePolicy(),
+		httpClient:    &http.Client{Timeout: cfg.GetTimeout()},
+	}
+	for _, expr := range cfg.Paths {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Middleware webhook %s has an invalid path %q: %s", cfg.Name, expr, err.Error())
+		}
+		f.paths = append(f.paths, re)
+	}
+	if cfg.TokenSecret != "" {
+		token, err := secretsEngine.ReadSecret(cfg.TokenSecret, true)
+		if err != nil {
+			return nil, err
+		}
+		f.token = strings.TrimSpace(string(token))
+	}
+	return f, nil
+}
+
+// matches returns true if the filter applies to the given path.
+func (f *webhookFilter) matches(path string) bool {
+	if len(f.paths) == 0 {
+		return true
+	}
+	for _, re := range f.paths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap returns the given handler wrapped by the filter.
+func (f *webhookFilter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.matches(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		verdict, err := f.review(f.newReview(r, nil))
+		if err != nil {
+			if f.failurePolicy == appv1.MiddlewareFailureIgnore {
+				middlewareLogger.Error(err, "Ignoring failed request filter", "Webhook", f.name, "Path", r.URL.Path)
+				next.ServeHTTP(w, r)
+				return
+			}
+			apiutil.ReturnAPIError(fmt.Errorf("Request filter %s failed: %s", f.name, err.Error()), w)
+			return
+		}
+		if !verdict.Allowed {
+			deny(verdict, w)
+			return
+		}
+		applyHeaders(r.Header, verdict)
+
+		// websocket connections are hijacked and cannot be buffered
+		if !f.responses || websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buf := &responseBuffer{ResponseWriter: w}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 || buf.passthrough {
+			return
+		}
+		f.filterResponse(r, buf)
+	})
+}
+
+// filterResponse sends a buffered response to the webhook and writes what it allows.
+func (f *webhookFilter) filterResponse(r *http.Request, buf *responseBuffer) {
+	w := buf.ResponseWriter
+	body := buf.body.Bytes()
+	if !json.Valid(body) {
+		// nothing the webhook could parse, write it as is
+		w.WriteHeader(buf.status)
+		if _, err := w.Write(body); err != nil {
+			middlewareLogger.Error(err, "Failed to write response", "Webhook", f.name, "Path", r.URL.Path)
+		}
+		return
+	}
+	verdict, err := f.review(f.newReview(r, &ReviewResponse{
+		Status:  buf.status,
+		Headers: sanitizeHeaders(w.Header()),
+		Body:    json.RawMessage(body),
+	}))
+	if err != nil {
+		if f.failurePolicy != appv1.MiddlewareFailureIgnore {
+			w.Header().Del("Content-Length")
+			apiutil.ReturnAPIError(fmt.Errorf("Response filter %s failed: %s", f.name, err.Error()), w)
+			return
+		}
+		middlewareLogger.Error(err, "Ignoring failed response filter", "Webhook", f.name, "Path", r.URL.Path)
+		verdict = &Verdict{Allowed: true}
+	}
+	w.Header().Del("Content-Length")
+	if !verdict.Allowed {
+		deny(verdict, w)
+		return
+	}
+	applyHeaders(w.Header(), verdict)
+	if verdict.Body != nil {
+		body = verdict.Body
+	}
+	w.WriteHeader(buf.status)
+	if _, err := w.Write(body); err != nil {
+		middlewareLogger.Error(err, "Failed to write filtered response", "Webhook", f.name, "Path", r.URL.Path)
+	}
+}
+
+// newReview returns the review of the given request, and response if not nil.
+func (f *webhookFilter) newReview(r *http.Request, resp *ReviewResponse) *Review {
+	review := &Review{
+		Stage:    f.stage,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    sanitizeQuery(r.URL),
+		Headers:  sanitizeHeaders(r.Header),
+		Response: resp,
+	}
+	if f.stage == appv1.MiddlewareStagePostAuth {
+		if sess := apiutil.GetRequestUserSession(r); sess != nil && sess.User != nil {
+			review.User = sess.User.GetName()
+		}
+	}
+	return review
+}
+
+// review sends the given review to the webhook and returns its verdict.
+func (f *webhookFilter) review(review *Review) (*Verdict, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to reach the webhook: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Webhook returned status %d", resp.StatusCode)
+	}
+	verdict := &Verdict{}
+	if err := json.NewDecoder(resp.Body).Decode(verdict); err != nil {
+		return nil, fmt.Errorf("Could not decode response from the webhook: %s", err.Error())
+	}
+	if verdict.Body != nil && !json.Valid(verdict.Body) {
+		return nil, errors.New("Webhook returned a response body that is not valid JSON")
+	}
+	return verdict, nil
+}
+
+// deny writes the error for a request denied by a webhook.
+func deny(verdict *Verdict, w http.ResponseWriter) {
+	reason := verdict.Reason
+	if reason == "" {
+		reason = "The request was denied by a filter"
+	}
+	if verdict.Status == http.StatusUnauthorized {
+		apiutil.ReturnAPIUnauthorized(nil, reason, w)
+		return
+	}
+	apiutil.ReturnAPIForbidden(nil, reason, w)
+}
+
+// applyHeaders applies the header changes in the given verdict.
+func applyHeaders(header http.Header, verdict *Verdict) {
+	for _, name := range verdict.RemoveHeaders {
+		header.Del(name)
+	}
+	for name, value := range verdict.SetHeaders {
+		header.Set(name, value)
+	}
+}
+
+// sanitizeHeaders returns a copy of the given headers without credentials.
+func sanitizeHeaders(header http.Header) http.Header {
+	out := header.Clone()
+	for _, name := range sensitiveHeaders {
+		out.Del(name)
+	}
+	return out
+}
+
+// sanitizeQuery returns the raw query of the given URL without credentials.
+func sanitizeQuery(u *url.URL) string {
+	q := u.Query()
+	var stripped bool
+	for _, name := range sensitiveQueryParams {
+		if _, ok := q[name]; ok {
+			q.Del(name)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return u.RawQuery
+	}
+	return q.Encode()
+}
+
+// responseBuffer holds back JSON responses so they can be filtered before being written.
+// Other responses are written through as they are produced.
+type responseBuffer struct {
+	http.ResponseWriter
+	status      int
+	passthrough bool
+	body        bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status != 0 {
+		return
+	}
+	b.status = status
+	if !strings.HasPrefix(b.Header().Get("Content-Type"), "application/json") {
+		b.passthrough = true
+		b.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.passthrough {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Flush implements http.Flusher. Responses written through are flushed to the client,
+// while buffered responses are held until they have been filtered.
+func (b *responseBuffer) Flush() {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if !b.passthrough {
+		return
+	}
+	if flusher, ok := b.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
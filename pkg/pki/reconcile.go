@@ -29,6 +29,7 @@ import (
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
 
@@ -48,6 +49,11 @@ func (m *Manager) Reconcile(reqLogger logr.Logger) error {
 	if err := m.reconcileAppCertificates(reqLogger, caCert, caKey); err != nil {
 		return err
 	}
+	if m.cluster.SSHGatewayEnabled() {
+		if _, err := m.reconcileSSHCA(reqLogger); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -82,6 +88,11 @@ func (m *Manager) ReconcileDesktop(reqLogger logr.Logger, desktop *desktopsv1.Se
 		if err != nil {
 			return err
 		}
+		if m.cluster.SSHGatewayEnabled() {
+			if certData[v1.SSHCAPublicKeyKey], err = m.GetSSHCAPublicKey(reqLogger); err != nil {
+				return err
+			}
+		}
 		newSecret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:            nn.Name,
@@ -98,6 +109,19 @@ func (m *Manager) ReconcileDesktop(reqLogger logr.Logger, desktop *desktopsv1.Se
 	// TODO: since these are shortlived I can postpone doing verification
 	// but it should be done
 
+	// Make sure the SSH CA is present if the gateway was enabled after the session started
+	if _, ok := secret.Data[v1.SSHCAPublicKeyKey]; m.cluster.SSHGatewayEnabled() && !ok {
+		pubKey, err := m.GetSSHCAPublicKey(reqLogger)
+		if err != nil {
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[v1.SSHCAPublicKeyKey] = pubKey
+		return m.client.Update(context.TODO(), secret)
+	}
+
 	return nil
 }
 
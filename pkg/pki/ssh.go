@@ -0,0 +1,142 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pki
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCertificateExtensions are the permissions granted to issued user certificates.
+var sshCertificateExtensions = map[string]string{
+	"permit-pty":              "",
+	"permit-port-forwarding":  "",
+	"permit-agent-forwarding": "",
+	"permit-X11-forwarding":   "",
+}
+
+// reconcileSSHCA will ensure the presence of the key used to sign SSH user certificates
+// and return a signer for it. The key is only generated by the manager, while holding the
+// secrets lock, so concurrent reconciles and app replicas cannot overwrite each other's key.
+func (m *Manager) reconcileSSHCA(reqLogger logr.Logger) (ssh.Signer, error) {
+	signer, err := m.loadSSHCA(true)
+	if err == nil || !errors.IsSecretNotFoundError(err) {
+		return signer, err
+	}
+
+	if err := m.secrets.Lock(15); err != nil {
+		return nil, err
+	}
+	defer m.secrets.Release()
+
+	// check again now that we hold the lock, in case a peer generated it first
+	signer, err = m.loadSSHCA(false)
+	if err == nil || !errors.IsSecretNotFoundError(err) {
+		return signer, err
+	}
+
+	reqLogger.Info("Generating new SSH CA for the kVDI cluster")
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := m.secrets.WriteSecret(v1.SSHCASecretKey, keyPEM); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(privKey)
+}
+
+// loadSSHCA returns a signer for the SSH user CA. A SecretNotFoundError is returned if the
+// CA has not been generated yet.
+func (m *Manager) loadSSHCA(cache bool) (ssh.Signer, error) {
+	keyPEM, err := m.secrets.ReadSecret(v1.SSHCASecretKey, cache)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("Could not decode SSH CA private key")
+	}
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(privKey)
+}
+
+// GetSSHCAPublicKey returns the public key of the SSH user CA in authorized_keys format,
+// generating the CA if it does not exist.
+func (m *Manager) GetSSHCAPublicKey(reqLogger logr.Logger) ([]byte, error) {
+	signer, err := m.reconcileSSHCA(reqLogger)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.MarshalAuthorizedKey(signer.PublicKey()), nil
+}
+
+// SignSSHUserKey signs the given public key with the SSH user CA, returning a certificate
+// valid for the given principal for the configured duration. The CA is not generated
+// here, an error is returned if the manager has not reconciled it yet.
+func (m *Manager) SignSSHUserKey(reqLogger logr.Logger, pubKey ssh.PublicKey, principal string) (*ssh.Certificate, error) {
+	signer, err := m.loadSSHCA(true)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil, errors.New("The SSH CA has not been generated yet")
+		}
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          serial.Uint64(),
+		CertType:        ssh.UserCert,
+		KeyId:           principal,
+		ValidPrincipals: []string{principal},
+		// allow for a small amount of clock skew
+		ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(m.cluster.GetSSHCertificateTTL()).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: sshCertificateExtensions,
+		},
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+func mustNewTestManager(t *testing.T) *Manager {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme)
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		SSH: &appv1.SSHConfig{Enabled: true, CertificateTTL: "30m"},
+	}
+	if err := c.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	return New(c, cluster, engine)
+}
+
+func mustNewSSHKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPub
+}
+
+func TestSignSSHUserKey(t *testing.T) {
+	m := mustNewTestManager(t)
+	userKey := mustNewSSHKey(t)
+
+	if _, err := m.SignSSHUserKey(testLogger, userKey, "test-user"); err == nil {
+		t.Error("Expected signing to fail before the CA is reconciled")
+	}
+
+	caPub, err := m.GetSSHCAPublicKey(testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authority, _, _, _, err := ssh.ParseAuthorizedKey(caPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := m.SignSSHUserKey(testLogger, userKey, "test-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.CertType != ssh.UserCert || !bytes.Equal(cert.Key.Marshal(), userKey.Marshal()) {
+		t.Error("Expected a user certificate for the given key, got:", cert)
+	}
+	if ttl := time.Until(time.Unix(int64(cert.ValidBefore), 0)); ttl > 30*time.Minute || ttl < 29*time.Minute {
+		t.Error("Expected the certificate to be valid for the configured TTL, got:", ttl)
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), authority.Marshal())
+		},
+	}
+	if err := checker.CheckCert("test-user", cert); err != nil {
+		t.Error("Expected the certificate to be valid for its principal, got:", err)
+	}
+	if err := checker.CheckCert("other-user", cert); err == nil {
+		t.Error("Expected the certificate to be rejected for another principal")
+	}
+	if checker.IsUserAuthority(cert.SignatureKey) == false {
+		t.Error("Expected the certificate to be signed by the CA")
+	}
+}
+
+func TestReconcileSSHCAConcurrently(t *testing.T) {
+	m := mustNewTestManager(t)
+	keys := make([][]byte, 5)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, err := m.GetSSHCAPublicKey(testLogger)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			keys[i] = key
+		}(i)
+	}
+	wg.Wait()
+	for _, key := range keys[1:] {
+		if !bytes.Equal(key, keys[0]) {
+			t.Fatal("Expected every reconcile to return the same CA")
+		}
+	}
+
+	// the manager reconcile generates the CA when the gateway is enabled
+	m = mustNewTestManager(t)
+	if err := m.Reconcile(testLogger); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SignSSHUserKey(testLogger, mustNewSSHKey(t), "test-user"); err != nil {
+		t.Error("Expected signing to succeed after the manager reconciled the CA, got:", err)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package preflight validates the configuration of a VDICluster end-to-end before anything
+// serves traffic. It checks that the secrets backend can be authenticated to, that the auth
+// provider can be reached (including OpenID discovery), and that the app TLS material is
+// present and valid.
+//
+// It also holds the checks the manager runs at startup to make upgrades safe. They verify
+// the installed CRDs match the ones this version was built against, call out deprecated
+// fields still in use, and check the version of the data formats in the secrets backend.
+// Migrations bring data written by previous versions up to the current format.
+package preflight
@@ -0,0 +1,148 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// SchemaVersion is the version of the data formats in the secrets backend used by this
+// version of kVDI. It must match the version of the last migration.
+const SchemaVersion = 1
+
+// migrationLockTimeout is how long, in seconds, the secrets engine lock is held while
+// migrating.
+const migrationLockTimeout = 30
+
+// migration rewrites data in the secrets backend from the format of the schema version
+// before it to the format of its own version. Migrations must be safe to run again on data
+// that was already migrated.
+type migration struct {
+	// The schema version the data is at after the migration
+	Version int
+	// A description of what the migration does
+	Description string
+	// Rewrites the data, the secrets engine is locked while it runs
+	Migrate func(*secrets.SecretEngine) error
+}
+
+// migrations are every migration in the order they are applied.
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "Store refresh tokens as records instead of bare usernames",
+		Migrate:     migrateRefreshTokenRecords,
+	},
+}
+
+// Migrate runs every migration newer than the schema version recorded in the secrets
+// backend, recording the new version after each one. It returns the migrations that were
+// applied. Data written by a newer version of kVDI is left untouched and an error is
+// returned.
+func Migrate(engine *secrets.SecretEngine) ([]string, error) {
+	if err := engine.Lock(migrationLockTimeout); err != nil {
+		return nil, err
+	}
+	defer engine.Release()
+	current, err := readSchemaVersion(engine)
+	if err != nil {
+		return nil, err
+	}
+	if current > SchemaVersion {
+		return nil, newerSchemaError(current)
+	}
+	applied := make([]string, 0)
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := m.Migrate(engine); err != nil {
+			return applied, fmt.Errorf("Migration to schema version %d failed: %s", m.Version, err.Error())
+		}
+		if err := engine.WriteSecret(v1.SchemaVersionSecretKey, []byte(strconv.Itoa(m.Version))); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.Description)
+	}
+	return applied, nil
+}
+
+// readSchemaVersion returns the schema version recorded in the secrets backend. Data
+// written before versions were recorded is at version 0.
+func readSchemaVersion(engine *secrets.SecretEngine) (int, error) {
+	data, err := engine.ReadSecret(v1.SchemaVersionSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid schema version in the secrets backend: %s", err.Error())
+	}
+	return version, nil
+}
+
+// newerSchemaError returns the error for data written by a newer version of kVDI.
+func newerSchemaError(version int) error {
+	return fmt.Errorf("The secrets backend is at schema version %d, but this version of kVDI only supports up to %d. Upgrade the manager, or restore a backup taken before the newer version was installed.", version, SchemaVersion)
+}
+
+// refreshTokenRecord holds the fields of the refresh token records written by the API
+// that can be recovered from a bare username.
+type refreshTokenRecord struct {
+	User string `json:"user"`
+}
+
+// migrateRefreshTokenRecords rewrites refresh tokens issued by versions that only stored
+// the username into records.
+func migrateRefreshTokenRecords(engine *secrets.SecretEngine) error {
+	tokens, err := engine.ReadSecretMap(v1.RefreshTokensSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	var changed bool
+	for token, value := range tokens {
+		if err := json.Unmarshal(value, &refreshTokenRecord{}); err == nil {
+			continue
+		}
+		record, err := json.Marshal(&refreshTokenRecord{User: string(value)})
+		if err != nil {
+			return err
+		}
+		tokens[token] = record
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return engine.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package preflight
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Check names used in reports.
+const (
+	CheckSecrets   = "secrets"
+	CheckAuth      = "auth"
+	CheckTLSServer = "tls-server"
+	CheckTLSClient = "tls-client"
+	CheckTenancy   = "tenancy"
+)
+
+// expiryWarning is how close to expiring a certificate can be before it is called out
+// in its check message.
+const expiryWarning = 7 * 24 * time.Hour
+
+// Validate runs every check against the given cluster and returns the report. If engine
+// is nil, a new secrets engine is set up for the checks and closed afterwards. Otherwise the
+// given engine is assumed to be set up already and is left open.
+func Validate(ctx context.Context, c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine) *types.ConfigValidationReport {
+	report := &types.ConfigValidationReport{
+		Cluster: cluster.GetName(),
+		Checks:  make([]*types.ConfigCheck, 0),
+	}
+
+	setup := engine == nil
+	if setup {
+		engine = secrets.GetSecretEngine(cluster)
+		defer engine.Close()
+	}
+	secretsCheck := newCheck(CheckSecrets)(checkSecrets(c, cluster, engine, setup))
+	report.Checks = append(report.Checks, secretsCheck)
+
+	if secretsCheck.Ready {
+		report.Checks = append(report.Checks, newCheck(CheckAuth)(checkAuth(c, cluster, engine)))
+	} else {
+		report.Checks = append(report.Checks, &types.ConfigCheck{
+			Name:    CheckAuth,
+			Skipped: true,
+			Message: "The auth provider depends on the secrets backend",
+		})
+	}
+
+	report.Checks = append(report.Checks,
+		newCheck(CheckTLSServer)(checkTLSSecret(ctx, c, cluster.GetAppServerTLSNamespacedName())),
+		newCheck(CheckTLSClient)(checkTLSSecret(ctx, c, cluster.GetAppClientTLSNamespacedName())),
+	)
+
+	if cluster.IsIsolated() {
+		report.Checks = append(report.Checks, newCheck(CheckTenancy)(checkTenancy(ctx, c, cluster)))
+	}
+
+	report.Ready = allReady(report.Checks)
+	return report
+}
+
+// allReady returns true if every one of the given checks passed.
+func allReady(checks []*types.ConfigCheck) bool {
+	for _, check := range checks {
+		if !check.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// newCheck returns a function that builds a check with the given name from the result
+// of running it.
+func newCheck(name string) func(string, error) *types.ConfigCheck {
+	return func(msg string, err error) *types.ConfigCheck {
+		check := &types.ConfigCheck{Name: name, Ready: err == nil, Message: msg}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		return check
+	}
+}
+
+// checkSecrets verifies the secrets backend can be authenticated to and read from.
+func checkSecrets(c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine, setup bool) (string, error) {
+	if setup {
+		if err := engine.Setup(c, cluster); err != nil {
+			return "", err
+		}
+	}
+	// The JWT secret won't exist until the app has started once, so only other errors count
+	if _, err := engine.ReadSecret(v1.JWTSecretKey, false); err != nil && !errors.IsSecretNotFoundError(err) {
+		return "", err
+	}
+	return fmt.Sprintf("Authenticated to and read from the %s secrets backend", cluster.GetSecretsBackend()), nil
+}
+
+// checkAuth sets up a fresh instance of the configured auth provider. Setting up the LDAP
+// provider connects and binds to the server, and setting up the OpenID provider performs
+// discovery against the issuer. Local users are read from the secrets backend.
+func checkAuth(c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine) (string, error) {
+	provider := auth.GetAuthProvider(cluster, engine)
+	defer provider.Close()
+	if err := provider.Setup(c, cluster); err != nil {
+		return "", err
+	}
+	switch {
+	case cluster.IsUsingLDAPAuth():
+		return "Connected and bound to the LDAP server", nil
+	case cluster.IsUsingOIDCAuth():
+		return fmt.Sprintf("Discovered the OpenID provider at %s", cluster.GetOIDCIssuerURL()), nil
+	}
+	if _, err := provider.GetUsers(); err != nil {
+		return "", err
+	}
+	return "Read local users from the secrets backend", nil
+}
+
+// checkTenancy verifies an isolated cluster does not overlap with another tenant.
+func checkTenancy(ctx context.Context, c client.Client, cluster *appv1.VDICluster) (string, error) {
+	if err := tenancy.Validate(ctx, c, cluster); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Isolated in namespaces %s", strings.Join(cluster.GetTenantNamespaces(), ", ")), nil
+}
+
+// checkTLSSecret verifies the TLS secret with the given name contains a certificate and
+// key that match, are currently valid, and chain to the included CA if there is one.
+func checkTLSSecret(ctx context.Context, c client.Client, nn ktypes.NamespacedName) (string, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, nn, secret); err != nil {
+		return "", err
+	}
+	keypair, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return "", fmt.Errorf("Invalid keypair in %s: %s", nn.String(), err.Error())
+	}
+	cert, err := x509.ParseCertificate(keypair.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		return "", fmt.Errorf("The certificate in %s is not valid until %s", nn.String(), cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return "", fmt.Errorf("The certificate in %s expired at %s", nn.String(), cert.NotAfter.Format(time.RFC3339))
+	}
+	if ca, ok := secret.Data[v1.CACertKey]; ok && len(ca) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return "", fmt.Errorf("Invalid CA certificate in %s", nn.String())
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+			return "", fmt.Errorf("The certificate in %s does not chain to its CA: %s", nn.String(), err.Error())
+		}
+	}
+	msg := fmt.Sprintf("The certificate in %s is valid until %s", nn.String(), cert.NotAfter.Format(time.RFC3339))
+	if cert.NotAfter.Sub(now) < expiryWarning {
+		msg += " and expires soon"
+	}
+	return msg, nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package preflight
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func mustNewCert(t *testing.T, notAfter time.Time, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func mustCreateTLSSecret(t *testing.T, c client.Client, name string, cert, ca *testCert) ktypes.NamespacedName {
+	t.Helper()
+	secret := &corev1.Secret{}
+	secret.Name = name
+	secret.Namespace = "default"
+	secret.Data = map[string][]byte{
+		corev1.TLSCertKey:       cert.certPEM,
+		corev1.TLSPrivateKeyKey: cert.keyPEM,
+	}
+	if ca != nil {
+		secret.Data[v1.CACertKey] = ca.certPEM
+	}
+	if err := c.Create(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	return ktypes.NamespacedName{Name: name, Namespace: "default"}
+}
+
+func TestCheckTLSSecret(t *testing.T) {
+	c := getFakeClient(t)
+	ca := mustNewCert(t, time.Now().Add(365*24*time.Hour), nil)
+	otherCA := mustNewCert(t, time.Now().Add(365*24*time.Hour), nil)
+
+	valid := mustCreateTLSSecret(t, c, "valid", mustNewCert(t, time.Now().Add(90*24*time.Hour), ca), ca)
+	if _, err := checkTLSSecret(context.TODO(), c, valid); err != nil {
+		t.Error("Expected valid certificate to pass, got:", err)
+	}
+
+	noCA := mustCreateTLSSecret(t, c, "no-ca", mustNewCert(t, time.Now().Add(90*24*time.Hour), ca), nil)
+	if _, err := checkTLSSecret(context.TODO(), c, noCA); err != nil {
+		t.Error("Expected certificate without a CA to pass, got:", err)
+	}
+
+	expiring := mustCreateTLSSecret(t, c, "expiring", mustNewCert(t, time.Now().Add(24*time.Hour), ca), ca)
+	if msg, err := checkTLSSecret(context.TODO(), c, expiring); err != nil {
+		t.Error("Expected expiring certificate to pass, got:", err)
+	} else if msg == "" {
+		t.Error("Expected a message for the expiring certificate")
+	}
+
+	expired := mustCreateTLSSecret(t, c, "expired", mustNewCert(t, time.Now().Add(-time.Minute), ca), ca)
+	if _, err := checkTLSSecret(context.TODO(), c, expired); err == nil {
+		t.Error("Expected expired certificate to fail")
+	}
+
+	wrongCA := mustCreateTLSSecret(t, c, "wrong-ca", mustNewCert(t, time.Now().Add(90*24*time.Hour), ca), otherCA)
+	if _, err := checkTLSSecret(context.TODO(), c, wrongCA); err == nil {
+		t.Error("Expected certificate not chaining to its CA to fail")
+	}
+
+	if _, err := checkTLSSecret(context.TODO(), c, ktypes.NamespacedName{Name: "missing", Namespace: "default"}); err == nil {
+		t.Error("Expected missing secret to fail")
+	}
+}
+
+func TestValidateMissingTLS(t *testing.T) {
+	c := getFakeClient(t)
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	if err := c.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Validate(context.TODO(), c, cluster, nil)
+	if report.Ready {
+		t.Error("Expected report to not be ready without TLS secrets")
+	}
+	if report.Cluster != "test-cluster" {
+		t.Error("Expected report for test-cluster, got:", report.Cluster)
+	}
+	names := []string{CheckSecrets, CheckAuth, CheckTLSServer, CheckTLSClient}
+	if len(report.Checks) != len(names) {
+		t.Fatal("Expected a result for every check, got:", len(report.Checks))
+	}
+	for i, name := range names {
+		if report.Checks[i].Name != name {
+			t.Errorf("Expected check %d to be %s, got: %s", i, name, report.Checks[i].Name)
+		}
+	}
+	for _, check := range report.Checks[2:] {
+		if check.Ready || check.Error == "" {
+			t.Errorf("Expected %s to fail with an error, got: %+v", check.Name, check)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/install"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Upgrade check names used in reports.
+const (
+	CheckCRDVersions      = "crd-versions"
+	CheckDeprecatedFields = "deprecated-fields"
+	CheckSchemaVersion    = "schema-version"
+)
+
+// deprecatedField is a field that still works, but has been superseded and will be removed
+// in a future version.
+type deprecatedField struct {
+	// The path to the field in the object
+	Path string
+	// What to use instead
+	Replacement string
+	// Returns true if the field is set on the given template
+	InUse func(*desktopsv1.Template) bool
+}
+
+// deprecatedTemplateFields are the deprecated fields of templates.
+var deprecatedTemplateFields = []deprecatedField{
+	{
+		Path:        "spec.qemu.spice",
+		Replacement: "spec.display.protocol",
+		InUse: func(t *desktopsv1.Template) bool {
+			return t.Spec.QEMUConfig != nil && t.Spec.QEMUConfig.SPICE
+		},
+	},
+}
+
+// CheckUpgrade runs the checks that make it safe for this version of kVDI to manage the
+// given cluster and returns the report. The engine follows the same rules as in Validate.
+// Deprecated fields being in use is reported, but does not fail the check.
+func CheckUpgrade(ctx context.Context, c client.Client, cluster *appv1.VDICluster, engine *secrets.SecretEngine) *types.ConfigValidationReport {
+	report := &types.ConfigValidationReport{
+		Cluster: cluster.GetName(),
+		Checks: []*types.ConfigCheck{
+			newCheck(CheckCRDVersions)(checkCRDVersions(ctx, c)),
+			newCheck(CheckDeprecatedFields)(checkDeprecatedFields(ctx, c)),
+		},
+	}
+
+	setup := engine == nil
+	if setup {
+		engine = secrets.GetSecretEngine(cluster)
+		defer engine.Close()
+		if err := engine.Setup(c, cluster); err != nil {
+			report.Checks = append(report.Checks, &types.ConfigCheck{
+				Name:    CheckSchemaVersion,
+				Skipped: true,
+				Message: fmt.Sprintf("The secrets backend could not be set up: %s", err.Error()),
+			})
+			report.Ready = allReady(report.Checks)
+			return report
+		}
+	}
+	report.Checks = append(report.Checks, newCheck(CheckSchemaVersion)(checkSchemaVersion(engine)))
+
+	report.Ready = allReady(report.Checks)
+	return report
+}
+
+// checkCRDVersions verifies the CRDs installed in the cluster serve every version this
+// manager was built against, and that no objects are stored at versions it does not know.
+func checkCRDVersions(ctx context.Context, c client.Client) (string, error) {
+	crds, err := install.CRDs()
+	if err != nil {
+		return "", err
+	}
+	problems := make([]string, 0)
+	for _, crd := range crds {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(crd.GroupVersionKind())
+		if err := c.Get(ctx, ktypes.NamespacedName{Name: crd.GetName()}, live); err != nil {
+			if apierrors.IsNotFound(err) {
+				problems = append(problems, fmt.Sprintf("%s is not installed", crd.GetName()))
+				continue
+			}
+			return "", err
+		}
+		problems = append(problems, compareCRD(crd, live)...)
+	}
+	if len(problems) > 0 {
+		return "", fmt.Errorf("The installed CRDs do not match this version of kVDI, reinstall them from the release bundle: %s", strings.Join(problems, "; "))
+	}
+	return fmt.Sprintf("All %d CRDs serve the versions this manager was built against", len(crds)), nil
+}
+
+// compareCRD returns the ways in which the live CRD is incompatible with the one bundled
+// with this version.
+func compareCRD(bundled, live *unstructured.Unstructured) []string {
+	problems := make([]string, 0)
+	liveServed := crdVersions(live, true)
+	for _, version := range crdVersions(bundled, true) {
+		if !containsString(liveServed, version) {
+			problems = append(problems, fmt.Sprintf("%s does not serve version %s", bundled.GetName(), version))
+		}
+	}
+	known := crdVersions(bundled, false)
+	stored, _, _ := unstructured.NestedStringSlice(live.Object, "status", "storedVersions")
+	for _, version := range stored {
+		if !containsString(known, version) {
+			problems = append(problems, fmt.Sprintf("%s has objects stored at unknown version %s", bundled.GetName(), version))
+		}
+	}
+	return problems
+}
+
+// crdVersions returns the names of the versions declared by the given CRD. When served
+// is true, only the versions it serves are returned.
+func crdVersions(crd *unstructured.Unstructured, served bool) []string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	names := make([]string, 0, len(versions))
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served && version["served"] != true {
+			continue
+		}
+		if name, ok := version["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeprecatedFields looks for deprecated fields in use by templates. The fields still
+// work, so they are only reported.
+func checkDeprecatedFields(ctx context.Context, c client.Client) (string, error) {
+	tmpls := &desktopsv1.TemplateList{}
+	if err := c.List(ctx, tmpls); err != nil {
+		return "", err
+	}
+	usages := deprecatedFieldUsages(tmpls.Items)
+	if len(usages) == 0 {
+		return "No deprecated fields are in use", nil
+	}
+	return fmt.Sprintf("Deprecated fields are in use and will stop working in a future version: %s", strings.Join(usages, "; ")), nil
+}
+
+// deprecatedFieldUsages returns a description of every deprecated field set on the given
+// templates.
+func deprecatedFieldUsages(tmpls []desktopsv1.Template) []string {
+	usages := make([]string, 0)
+	for i := range tmpls {
+		for _, field := range deprecatedTemplateFields {
+			if field.InUse(&tmpls[i]) {
+				usages = append(usages, fmt.Sprintf("template %s sets %s, use %s instead", tmpls[i].GetName(), field.Path, field.Replacement))
+			}
+		}
+	}
+	return usages
+}
+
+// checkSchemaVersion verifies the data in the secrets backend was not written by a newer
+// version of kVDI, and reports any migrations that are pending.
+func checkSchemaVersion(engine *secrets.SecretEngine) (string, error) {
+	version, err := readSchemaVersion(engine)
+	if err != nil {
+		return "", err
+	}
+	if version > SchemaVersion {
+		return "", newerSchemaError(version)
+	}
+	if version < SchemaVersion {
+		return fmt.Sprintf("The secrets backend is at schema version %d, %d migration(s) to version %d are pending", version, SchemaVersion-version, SchemaVersion), nil
+	}
+	return fmt.Sprintf("The secrets backend is at schema version %d", version), nil
+}
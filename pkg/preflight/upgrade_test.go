@@ -0,0 +1,161 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package preflight
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/install"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func mustSetupSecretEngine(t *testing.T) *secrets.SecretEngine {
+	t.Helper()
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(getFakeClient(t), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to be for version %d, got: %d", i, i+1, m.Version)
+		}
+	}
+	if last := migrations[len(migrations)-1].Version; last != SchemaVersion {
+		t.Error("Expected the last migration to be for the current schema version, got:", last)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	engine := mustSetupSecretEngine(t)
+	defer engine.Close()
+
+	if err := engine.WriteSecretMap(v1.RefreshTokensSecretKey, map[string][]byte{
+		"legacy":  []byte("admin"),
+		"current": []byte(`{"user":"jdoe","issuedAt":1}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkSchemaVersion(engine); err != nil {
+		t.Fatal("Expected unversioned data to be migratable, got:", err)
+	}
+
+	applied, err := Migrate(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != SchemaVersion {
+		t.Error("Expected every migration to be applied, got:", applied)
+	}
+	tokens, err := engine.ReadSecretMap(v1.RefreshTokensSecretKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &refreshTokenRecord{}
+	if err := json.Unmarshal(tokens["legacy"], record); err != nil || record.User != "admin" {
+		t.Error("Expected the legacy token to be rewritten as a record, got:", string(tokens["legacy"]))
+	}
+	if string(tokens["current"]) != `{"user":"jdoe","issuedAt":1}` {
+		t.Error("Expected existing records to be left alone, got:", string(tokens["current"]))
+	}
+	if version, err := readSchemaVersion(engine); err != nil || version != SchemaVersion {
+		t.Error("Expected the schema version to be recorded, got:", version, err)
+	}
+
+	// running again is a no-op
+	if applied, err := Migrate(engine); err != nil || len(applied) != 0 {
+		t.Error("Expected no migrations to be pending, got:", applied, err)
+	}
+
+	// data from a newer version is refused
+	if err := engine.WriteSecret(v1.SchemaVersionSecretKey, []byte("99")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkSchemaVersion(engine); err == nil {
+		t.Error("Expected a newer schema version to fail the check")
+	}
+	if _, err := Migrate(engine); err == nil {
+		t.Error("Expected migrating from a newer schema version to fail")
+	}
+}
+
+func TestCompareCRD(t *testing.T) {
+	crds, err := install.CRDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crds) == 0 {
+		t.Fatal("Expected CRDs in the release bundle")
+	}
+	bundled := crds[0]
+
+	if problems := compareCRD(bundled, bundled.DeepCopy()); len(problems) != 0 {
+		t.Error("Expected the bundled CRD to be compatible with itself, got:", problems)
+	}
+
+	live := bundled.DeepCopy()
+	if err := unstructured.SetNestedSlice(live.Object, []interface{}{
+		map[string]interface{}{"name": "v1alpha1", "served": true, "storage": true},
+	}, "spec", "versions"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedStringSlice(live.Object, []string{"v1alpha1"}, "status", "storedVersions"); err != nil {
+		t.Fatal(err)
+	}
+	problems := compareCRD(bundled, live)
+	if len(problems) != 2 {
+		t.Fatal("Expected a missing version and an unknown stored version, got:", problems)
+	}
+	if !strings.Contains(problems[0], "does not serve version v1") {
+		t.Error("Expected the missing version to be reported, got:", problems[0])
+	}
+	if !strings.Contains(problems[1], "unknown version v1alpha1") {
+		t.Error("Expected the unknown stored version to be reported, got:", problems[1])
+	}
+}
+
+func TestDeprecatedFieldUsages(t *testing.T) {
+	current := desktopsv1.Template{}
+	current.Name = "current"
+	current.Spec.QEMUConfig = &desktopsv1.QEMUConfig{}
+	legacy := desktopsv1.Template{}
+	legacy.Name = "legacy"
+	legacy.Spec.QEMUConfig = &desktopsv1.QEMUConfig{SPICE: true}
+
+	usages := deprecatedFieldUsages([]desktopsv1.Template{current, legacy})
+	if len(usages) != 1 {
+		t.Fatal("Expected one deprecated field in use, got:", usages)
+	}
+	if !strings.Contains(usages[0], "legacy") || !strings.Contains(usages[0], "spec.qemu.spice") {
+		t.Error("Expected the legacy template's use of spec.qemu.spice to be reported, got:", usages[0])
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// cosignSignatureAnnotation is the layer annotation holding the base64 signature of
+	// the layer contents.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// inTotoPayloadType is the DSSE payload type of in-toto statements.
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// cosignPayload is the simple signing payload signed by `cosign sign`.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// dsseEnvelope is the envelope pushed by `cosign attest`.
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// inTotoStatement is the subset of an in-toto statement checked against the policy.
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// trustedKey is a parsed signing key.
+type trustedKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+// cosignTag returns the tag that cosign stores an artifact for the given digest under.
+func cosignTag(digest, suffix string) string {
+	return strings.Replace(digest, ":", "-", 1) + "." + suffix
+}
+
+// layerPayloads retrieves the signed layers stored alongside the image under the given
+// cosign tag suffix. A missing tag returns no layers.
+func (r *registry) layerPayloads(ref *Reference, digest, suffix string) ([]Descriptor, [][]byte, error) {
+	body, _, err := r.getManifest(ref, cosignTag(digest, suffix))
+	if err == errManifestNotFound {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, nil, err
+	}
+	payloads := make([][]byte, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		if payloads[i], err = r.getBlob(ref, layer.Digest); err != nil {
+			return nil, nil, err
+		}
+	}
+	return manifest.Layers, payloads, nil
+}
+
+// verifySignatures returns the name of the first trusted key with a valid signature over
+// the image digest.
+func (r *registry) verifySignatures(ref *Reference, digest string, keys []trustedKey) (string, error) {
+	layers, payloads, err := r.layerPayloads(ref, digest, "sig")
+	if err != nil {
+		return "", err
+	}
+	if len(layers) == 0 {
+		return "", fmt.Errorf("No signatures found for %s", ref.String())
+	}
+	for i, layer := range layers {
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil || len(sig) == 0 {
+			continue
+		}
+		var payload cosignPayload
+		if err := json.Unmarshal(payloads[i], &payload); err != nil {
+			continue
+		}
+		if payload.Critical.Image.DockerManifestDigest != digest {
+			continue
+		}
+		for _, key := range keys {
+			if verifyRaw(key.key, payloads[i], sig) == nil {
+				return key.name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("No signatures for %s verified against the trusted keys", ref.String())
+}
+
+// verifyAttestations checks that each of the required predicate types is attested for the
+// image digest by one of the trusted keys.
+func (r *registry) verifyAttestations(ref *Reference, digest string, keys []trustedKey, predicateTypes []string) error {
+	if len(predicateTypes) == 0 {
+		return nil
+	}
+	_, payloads, err := r.layerPayloads(ref, digest, "att")
+	if err != nil {
+		return err
+	}
+	attested := make(map[string]struct{})
+	for _, payload := range payloads {
+		if predicateType, ok := verifyAttestation(payload, digest, keys); ok {
+			attested[predicateType] = struct{}{}
+		}
+	}
+	missing := make([]string, 0)
+	for _, predicateType := range predicateTypes {
+		if _, ok := attested[predicateType]; !ok {
+			missing = append(missing, predicateType)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s is missing verified attestations for: %s", ref.String(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// verifyAttestation checks a single DSSE envelope and returns the predicate type of its
+// statement if it is signed by a trusted key and its subject is the image digest.
+func verifyAttestation(data []byte, digest string, keys []trustedKey) (string, bool) {
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.PayloadType != inTotoPayloadType {
+		return "", false
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return "", false
+	}
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(envelope.PayloadType), envelope.PayloadType, len(payload), payload))
+	var signed bool
+	for _, s := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if verifyRaw(key.key, pae, sig) == nil {
+				signed = true
+				break
+			}
+		}
+	}
+	if !signed {
+		return "", false
+	}
+	var statement inTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return "", false
+	}
+	for _, subject := range statement.Subject {
+		if "sha256:"+subject.Digest["sha256"] == digest {
+			return statement.PredicateType, true
+		}
+	}
+	return "", false
+}
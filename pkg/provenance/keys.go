@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+// ErrSignatureInvalid is returned when a signature does not verify against any of the
+// trusted keys.
+var ErrSignatureInvalid = errors.New("Signature verification failed")
+
+// ParsePublicKey decodes a base64 encoded PEM public key.
+func ParsePublicKey(publicKey string) (crypto.PublicKey, error) {
+	keyPEM, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("No PEM data found in public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// VerifyBlob checks the base64 encoded signature over data against the given base64
+// encoded PEM public key. This is the format produced by `cosign sign-blob`.
+func VerifyBlob(publicKey string, data, signature []byte) error {
+	pub, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("Could not decode signature: %s", err.Error())
+	}
+	return verifyRaw(pub, data, sig)
+}
+
+// VerifyBlobWithKeys checks the base64 encoded signature over data against each of the
+// given keys and returns the name of the first key that verifies it.
+func VerifyBlobWithKeys(keys []appv1.SigningKey, data, signature []byte) (string, error) {
+	for _, key := range keys {
+		if err := VerifyBlob(key.PublicKey, data, signature); err == nil {
+			return key.Name, nil
+		}
+	}
+	return "", ErrSignatureInvalid
+}
+
+// verifyRaw checks a raw signature over data. ECDSA and RSA signatures are computed over
+// the sha256 digest of the data.
+func verifyRaw(pub crypto.PublicKey, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return ErrSignatureInvalid
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrSignatureInvalid
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return ErrSignatureInvalid
+		}
+	default:
+		return fmt.Errorf("Unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// VerifyDigest checks that data matches a digest in the format `sha256:<hex>`.
+func VerifyDigest(digest string, data []byte) error {
+	spl := strings.SplitN(digest, ":", 2)
+	if len(spl) != 2 || spl[0] != "sha256" {
+		return fmt.Errorf("Unsupported digest %q", digest)
+	}
+	if actual := Digest(data); actual != "sha256:"+strings.ToLower(spl[1]) {
+		return fmt.Errorf("Digest mismatch: expected %s, got %s", digest, actual)
+	}
+	return nil
+}
+
+// Digest returns the sha256 digest of data in the format `sha256:<hex>`.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultRegistry     = "docker.io"
+	defaultRegistryHost = "registry-1.docker.io"
+	defaultTag          = "latest"
+)
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// The registry hosting the image (e.g. `ghcr.io`).
+	Registry string
+	// The repository of the image within the registry.
+	Repository string
+	// The tag of the image, if referenced by tag.
+	Tag string
+	// The digest of the image, if referenced by digest.
+	Digest string
+}
+
+// ParseReference parses an image reference in the format accepted by the kubelet. Images
+// without a registry are resolved against Docker Hub.
+func ParseReference(image string) (*Reference, error) {
+	if image == "" {
+		return nil, fmt.Errorf("Image reference is empty")
+	}
+	ref := &Reference{}
+	name := image
+	if spl := strings.SplitN(name, "@", 2); len(spl) == 2 {
+		name, ref.Digest = spl[0], spl[1]
+		if !strings.HasPrefix(ref.Digest, "sha256:") {
+			return nil, fmt.Errorf("Unsupported digest in image reference %q", image)
+		}
+	}
+	// a tag follows the last colon, unless that colon is part of a registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+	spl := strings.SplitN(name, "/", 2)
+	if len(spl) == 2 && (strings.ContainsAny(spl[0], ".:") || spl[0] == "localhost") {
+		ref.Registry, ref.Repository = spl[0], spl[1]
+	} else {
+		ref.Registry, ref.Repository = defaultRegistry, name
+	}
+	if ref.Registry == defaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" || strings.ToLower(ref.Repository) != ref.Repository {
+		return nil, fmt.Errorf("Invalid repository in image reference %q", image)
+	}
+	return ref, nil
+}
+
+// Host returns the host serving the registry API for the reference.
+func (r *Reference) Host() string {
+	if r.Registry == defaultRegistry {
+		return defaultRegistryHost
+	}
+	return r.Registry
+}
+
+// Identifier returns the digest of the reference if set, otherwise its tag.
+func (r *Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String returns the full name of the reference.
+func (r *Reference) String() string {
+	name := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		name += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		name += "@" + r.Digest
+	}
+	return name
+}
+
+// MatchImage returns true if the given image matches any of the glob patterns. A `*`
+// matches any sequence of characters, including path separators.
+func MatchImage(patterns []string, image string) bool {
+	for _, pattern := range patterns {
+		expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+		if ok, _ := regexp.MatchString(expr, image); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tc := []struct {
+		image                             string
+		registry, repository, tag, digest string
+	}{
+		{"ubuntu", "docker.io", "library/ubuntu", "latest", ""},
+		{"tinyzimmer/kvdi:app-latest", "docker.io", "tinyzimmer/kvdi", "app-latest", ""},
+		{"ghcr.io/kvdi/ubuntu-xfce4:latest", "ghcr.io", "kvdi/ubuntu-xfce4", "latest", ""},
+		{"localhost:5000/desktop", "localhost:5000", "desktop", "latest", ""},
+		{"registry.local:5000/org/desktop:v1@sha256:abcd", "registry.local:5000", "org/desktop", "v1", "sha256:abcd"},
+	}
+	for _, c := range tc {
+		ref, err := ParseReference(c.image)
+		if err != nil {
+			t.Errorf("Expected no error parsing %q, got: %s", c.image, err)
+			continue
+		}
+		if ref.Registry != c.registry || ref.Repository != c.repository || ref.Tag != c.tag || ref.Digest != c.digest {
+			t.Errorf("Unexpected parse result for %q: %+v", c.image, ref)
+		}
+	}
+	if ref, _ := ParseReference("ubuntu"); ref.Host() != "registry-1.docker.io" {
+		t.Error("Expected docker hub images to resolve to registry-1.docker.io, got:", ref.Host())
+	}
+	for _, image := range []string{"", "Ubuntu:latest", "ubuntu@md5:abcd"} {
+		if _, err := ParseReference(image); err == nil {
+			t.Errorf("Expected error parsing %q, got nil", image)
+		}
+	}
+}
+
+func TestMatchImage(t *testing.T) {
+	patterns := []string{"ghcr.io/kvdi/*", "docker.io/library/ubuntu:20.04"}
+	for image, expected := range map[string]bool{
+		"ghcr.io/kvdi/ubuntu-xfce4:latest":  true,
+		"ghcr.io/kvdi/nested/image:v1":      true,
+		"ghcr.io/other/ubuntu-xfce4:latest": false,
+		"docker.io/library/ubuntu:20.04":    true,
+		"docker.io/library/ubuntu:22.04":    false,
+	} {
+		if actual := MatchImage(patterns, image); actual != expected {
+			t.Errorf("Expected match of %q to be %v, got %v", image, expected, actual)
+		}
+	}
+}
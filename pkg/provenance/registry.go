@@ -0,0 +1,282 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxRegistryResponseSize is the largest manifest or blob that will be read from a
+// registry. Signature and attestation layers are small JSON documents.
+const maxRegistryResponseSize = 4 * 1024 * 1024
+
+// manifestAcceptTypes are the manifest media types requested from registries.
+var manifestAcceptTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// errManifestNotFound is returned when a registry does not have the requested manifest.
+var errManifestNotFound = fmt.Errorf("Manifest not found")
+
+// Manifest is the subset of an OCI image manifest read when looking up signatures.
+type Manifest struct {
+	Layers []Descriptor `json:"layers"`
+}
+
+// Descriptor is an OCI content descriptor.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Keychain holds basic credentials for registries, keyed by registry host.
+type Keychain map[string]basicAuth
+
+type basicAuth struct {
+	username, password string
+}
+
+// dockerConfig is the format of a `kubernetes.io/dockerconfigjson` secret.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	} `json:"auths"`
+}
+
+// AddDockerConfig adds the credentials from the contents of a `.dockerconfigjson` file to
+// the keychain.
+func (k Keychain) AddDockerConfig(data []byte) error {
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	for server, auth := range cfg.Auths {
+		creds := basicAuth{username: auth.Username, password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return err
+			}
+			spl := strings.SplitN(string(decoded), ":", 2)
+			if len(spl) == 2 {
+				creds = basicAuth{username: spl[0], password: spl[1]}
+			}
+		}
+		k[registryHost(server)] = creds
+	}
+	return nil
+}
+
+// registryHost normalizes the server keys used in docker configurations.
+func registryHost(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		server = u.Host
+	}
+	server = strings.TrimSuffix(server, "/")
+	if server == "index.docker.io" || server == defaultRegistry {
+		return defaultRegistryHost
+	}
+	return server
+}
+
+// registry is a minimal client for the OCI distribution API.
+type registry struct {
+	httpClient *http.Client
+	keychain   Keychain
+	// the scheme used to reach registries, overridden in tests
+	scheme string
+	// bearer tokens by registry host and repository
+	tokens   map[string]string
+	tokensMu sync.Mutex
+}
+
+func newRegistry(httpClient *http.Client, keychain Keychain) *registry {
+	if keychain == nil {
+		keychain = make(Keychain)
+	}
+	return &registry{
+		httpClient: httpClient,
+		keychain:   keychain,
+		scheme:     "https",
+		tokens:     make(map[string]string),
+	}
+}
+
+// getManifest retrieves the manifest for the given reference and returns it along with
+// its digest.
+func (r *registry) getManifest(ref *Reference, identifier string) ([]byte, string, error) {
+	body, header, err := r.get(ref, "manifests/"+identifier, strings.Join(manifestAcceptTypes, ","))
+	if err != nil {
+		return nil, "", err
+	}
+	digest := Digest(body)
+	if d := header.Get("Docker-Content-Digest"); d != "" && d != digest {
+		return nil, "", fmt.Errorf("Registry returned digest %s for %s, but the manifest hashes to %s", d, ref.String(), digest)
+	}
+	return body, digest, nil
+}
+
+// getBlob retrieves the blob with the given digest and verifies its contents.
+func (r *registry) getBlob(ref *Reference, digest string) ([]byte, error) {
+	body, _, err := r.get(ref, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	return body, VerifyDigest(digest, body)
+}
+
+func (r *registry) get(ref *Reference, path, accept string) ([]byte, http.Header, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", r.scheme, ref.Host(), ref.Repository, path)
+	resp, err := r.do(ref, u, accept)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil, errManifestNotFound
+	default:
+		return nil, nil, fmt.Errorf("Unexpected status %d from %s", resp.StatusCode, u)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRegistryResponseSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > maxRegistryResponseSize {
+		return nil, nil, fmt.Errorf("Response from %s exceeds %d bytes", u, maxRegistryResponseSize)
+	}
+	return body, resp.Header, nil
+}
+
+// do performs a GET request against the registry, completing a bearer token challenge if
+// the registry requires one.
+func (r *registry) do(ref *Reference, u, accept string) (*http.Response, error) {
+	tokenKey := ref.Host() + "/" + ref.Repository
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		r.tokensMu.Lock()
+		token := r.tokens[tokenKey]
+		r.tokensMu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if creds, ok := r.keychain[ref.Host()]; ok {
+			req.SetBasicAuth(creds.username, creds.password)
+		}
+		return r.httpClient.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, fmt.Errorf("Registry %s denied access to %s", ref.Host(), ref.Repository)
+	}
+	token, err := r.fetchToken(ref, parseChallenge(challenge[len("bearer "):]))
+	if err != nil {
+		return nil, err
+	}
+	r.tokensMu.Lock()
+	r.tokens[tokenKey] = token
+	r.tokensMu.Unlock()
+	return send()
+}
+
+// fetchToken retrieves a pull token for the reference's repository from the realm
+// advertised by the registry.
+func (r *registry) fetchToken(ref *Reference, params map[string]string) (string, error) {
+	realm, ok := params["realm"]
+	if !ok {
+		return "", fmt.Errorf("Registry %s returned a bearer challenge without a realm", ref.Host())
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds, ok := r.keychain[ref.Host()]; ok {
+		req.SetBasicAuth(creds.username, creds.password)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected status %d retrieving a token from %s", resp.StatusCode, realm)
+	}
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRegistryResponseSize)).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// parseChallenge parses the parameters of a WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		spl := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(spl) == 2 {
+			params[strings.ToLower(spl[0])] = strings.Trim(spl[1], `"`)
+		}
+	}
+	return params
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+// verificationCacheTTL is how long a successful verification of an image is trusted
+// before the registry is consulted again.
+const verificationCacheTTL = 10 * time.Minute
+
+// Result is the outcome of a successful image verification.
+type Result struct {
+	// The image as it was referenced.
+	Image string
+	// The digest the image resolved to.
+	Digest string
+	// The name of the key that signed the image.
+	Key string
+}
+
+// Verifier verifies images against an image policy. Successful verifications are cached
+// for a short period so repeated launches of the same template do not query the registry
+// each time.
+type Verifier struct {
+	httpClient *http.Client
+	cache      map[string]cachedResult
+	mu         sync.Mutex
+}
+
+type cachedResult struct {
+	result  *Result
+	expires time.Time
+}
+
+// NewVerifier returns a new verifier using the given HTTP client. If httpClient is nil,
+// the default client is used.
+func NewVerifier(httpClient *http.Client) *Verifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Verifier{
+		httpClient: httpClient,
+		cache:      make(map[string]cachedResult),
+	}
+}
+
+// Verify checks that the image is signed by one of the policy's keys and carries all of
+// the attestations it requires. The keychain supplies credentials for private registries
+// and may be nil. Images that do not match the policy's patterns return a nil result and
+// no error.
+func (v *Verifier) Verify(image string, policy *appv1.ImagePolicyConfig, keychain Keychain) (*Result, error) {
+	return v.verify(newRegistry(v.httpClient, keychain), image, policy)
+}
+
+func (v *Verifier) verify(reg *registry, image string, policy *appv1.ImagePolicyConfig) (*Result, error) {
+	if policy == nil || !MatchImage(policy.GetImages(), image) {
+		return nil, nil
+	}
+
+	cacheKey, err := cacheKeyFor(image, policy)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	cached, ok := v.cache[cacheKey]
+	v.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.result, nil
+	}
+
+	keys := make([]trustedKey, 0, len(policy.Keys))
+	for _, key := range policy.Keys {
+		pub, err := ParsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse signing key %q: %s", key.Name, err.Error())
+		}
+		keys = append(keys, trustedKey{name: key.Name, key: pub})
+	}
+
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	_, digest, err := reg.getManifest(ref, ref.Identifier())
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve %s: %s", image, err.Error())
+	}
+	if ref.Digest != "" && ref.Digest != digest {
+		return nil, fmt.Errorf("%s resolved to unexpected digest %s", image, digest)
+	}
+
+	keyName, err := reg.verifySignatures(ref, digest, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.verifyAttestations(ref, digest, keys, policy.RequireAttestations); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Image: image, Digest: digest, Key: keyName}
+	v.mu.Lock()
+	v.cache[cacheKey] = cachedResult{result: result, expires: time.Now().Add(verificationCacheTTL)}
+	v.mu.Unlock()
+	return result, nil
+}
+
+// cacheKeyFor returns a key identifying the verification of an image under a policy, so
+// changes to the trusted keys or required attestations invalidate earlier results.
+func cacheKeyFor(image string, policy *appv1.ImagePolicyConfig) (string, error) {
+	fingerprint, err := json.Marshal([]interface{}{policy.Keys, policy.RequireAttestations})
+	if err != nil {
+		return "", err
+	}
+	return image + "|" + Digest(fingerprint), nil
+}
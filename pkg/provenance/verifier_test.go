@@ -0,0 +1,228 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package provenance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+const testPredicateType = "https://slsa.dev/provenance/v0.2"
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodePublicKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustSign(t *testing.T, key *ecdsa.PrivateKey, data []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// testRegistry serves a single image repository along with its cosign signature and
+// attestation manifests.
+type testRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{manifests: make(map[string][]byte), blobs: make(map[string][]byte)}
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/kvdi/desktop/")
+	var body []byte
+	var ok bool
+	if strings.HasPrefix(path, "manifests/") {
+		body, ok = r.manifests[strings.TrimPrefix(path, "manifests/")]
+	} else if strings.HasPrefix(path, "blobs/") {
+		body, ok = r.blobs[strings.TrimPrefix(path, "blobs/")]
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(body)
+}
+
+// pushLayers stores a manifest under the cosign tag for digest with the given layers.
+func (r *testRegistry) pushLayers(t *testing.T, digest, suffix string, payloads [][]byte, annotations []map[string]string) {
+	t.Helper()
+	manifest := Manifest{}
+	for i, payload := range payloads {
+		d := Digest(payload)
+		r.blobs[d] = payload
+		manifest.Layers = append(manifest.Layers, Descriptor{Digest: d, Size: int64(len(payload)), Annotations: annotations[i]})
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.manifests[cosignTag(digest, suffix)] = body
+}
+
+func (r *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, digest string) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"kvdi/desktop"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"}}`, digest))
+	r.pushLayers(t, digest, "sig", [][]byte{payload}, []map[string]string{{cosignSignatureAnnotation: mustSign(t, key, payload)}})
+}
+
+func (r *testRegistry) attest(t *testing.T, key *ecdsa.PrivateKey, digest, predicateType string) {
+	t.Helper()
+	statement := []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":%q,"subject":[{"name":"kvdi/desktop","digest":{"sha256":%q}}],"predicate":{}}`, predicateType, strings.TrimPrefix(digest, "sha256:")))
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(inTotoPayloadType), inTotoPayloadType, len(statement), statement))
+	envelope, err := json.Marshal(map[string]interface{}{
+		"payloadType": inTotoPayloadType,
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []map[string]string{{"sig": mustSign(t, key, pae)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.pushLayers(t, digest, "att", [][]byte{envelope}, []map[string]string{nil})
+}
+
+func setupTestRegistry(t *testing.T) (*testRegistry, *httptest.Server, string, string) {
+	t.Helper()
+	reg := newTestRegistry()
+	image := []byte(`{"schemaVersion":2,"layers":[]}`)
+	reg.manifests["latest"] = image
+	digest := Digest(image)
+	reg.manifests[digest] = image
+	srvr := httptest.NewServer(reg)
+	return reg, srvr, strings.TrimPrefix(srvr.URL, "http://") + "/kvdi/desktop:latest", digest
+}
+
+func testVerify(v *Verifier, image string, policy *appv1.ImagePolicyConfig) (*Result, error) {
+	reg := newRegistry(http.DefaultClient, nil)
+	reg.scheme = "http"
+	return v.verify(reg, image, policy)
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := mustGenerateKey(t)
+	reg, srvr, image, digest := setupTestRegistry(t)
+	defer srvr.Close()
+
+	policy := &appv1.ImagePolicyConfig{
+		Keys: []appv1.SigningKey{{Name: "release", PublicKey: encodePublicKey(t, key)}},
+	}
+
+	if _, err := testVerify(NewVerifier(nil), image, policy); err == nil {
+		t.Error("Expected error verifying unsigned image, got nil")
+	}
+
+	reg.sign(t, key, digest)
+	result, err := testVerify(NewVerifier(nil), image, policy)
+	if err != nil {
+		t.Fatal("Expected signed image to verify, got:", err)
+	}
+	if result.Digest != digest || result.Key != "release" {
+		t.Error("Unexpected verification result:", result)
+	}
+
+	otherKey := mustGenerateKey(t)
+	policy.Keys = []appv1.SigningKey{{Name: "other", PublicKey: encodePublicKey(t, otherKey)}}
+	if _, err := testVerify(NewVerifier(nil), image, policy); err == nil {
+		t.Error("Expected error verifying image signed by an untrusted key, got nil")
+	}
+
+	policy.Images = []string{"ghcr.io/*"}
+	if result, err := testVerify(NewVerifier(nil), image, policy); err != nil || result != nil {
+		t.Error("Expected images outside the policy to be skipped, got:", result, err)
+	}
+}
+
+func TestVerifyAttestations(t *testing.T) {
+	key := mustGenerateKey(t)
+	reg, srvr, image, digest := setupTestRegistry(t)
+	defer srvr.Close()
+	reg.sign(t, key, digest)
+
+	policy := &appv1.ImagePolicyConfig{
+		Keys:                []appv1.SigningKey{{Name: "release", PublicKey: encodePublicKey(t, key)}},
+		RequireAttestations: []string{testPredicateType},
+	}
+
+	if _, err := testVerify(NewVerifier(nil), image, policy); err == nil {
+		t.Error("Expected error verifying image without attestations, got nil")
+	}
+
+	reg.attest(t, mustGenerateKey(t), digest, testPredicateType)
+	if _, err := testVerify(NewVerifier(nil), image, policy); err == nil {
+		t.Error("Expected error verifying attestation signed by an untrusted key, got nil")
+	}
+
+	reg.attest(t, key, digest, testPredicateType)
+	if _, err := testVerify(NewVerifier(nil), image, policy); err != nil {
+		t.Error("Expected attested image to verify, got:", err)
+	}
+}
+
+func TestVerifyBlobWithKeys(t *testing.T) {
+	key := mustGenerateKey(t)
+	keys := []appv1.SigningKey{
+		{Name: "other", PublicKey: encodePublicKey(t, mustGenerateKey(t))},
+		{Name: "release", PublicKey: encodePublicKey(t, key)},
+	}
+	data := []byte("template manifest")
+	name, err := VerifyBlobWithKeys(keys, data, []byte(mustSign(t, key, data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "release" {
+		t.Error("Expected blob to be verified by the release key, got:", name)
+	}
+	if _, err := VerifyBlobWithKeys(keys, []byte("tampered"), []byte(mustSign(t, key, data))); err == nil {
+		t.Error("Expected error verifying tampered blob, got nil")
+	}
+}
@@ -25,6 +25,7 @@ package client
 
 import (
 	"io"
+	"net"
 
 	"github.com/go-logr/logr"
 	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
@@ -33,13 +34,33 @@ import (
 // Client is a structure used by the kvdi-app for sending traffic to and from
 // the kvdi-proxy instances.
 type Client struct {
-	proxyAddr string
-	log       logr.Logger
+	dial func(proxyproto.RequestType) (*proxyproto.Conn, error)
+	log  logr.Logger
 }
 
 // New returns a new proxy client to send requests to the given address.
 func New(logger logr.Logger, addr string) *Client {
-	return &Client{proxyAddr: addr, log: logger}
+	return &Client{
+		dial: func(rtype proxyproto.RequestType) (*proxyproto.Conn, error) {
+			return proxyproto.Dial(logger, addr, rtype)
+		},
+		log: logger,
+	}
+}
+
+// NewWithDialer returns a new proxy client that retrieves connections to the proxy from
+// the given function. This is used for reaching proxies over reverse tunnels.
+func NewWithDialer(logger logr.Logger, dialer func() (net.Conn, error)) *Client {
+	return &Client{
+		dial: func(rtype proxyproto.RequestType) (*proxyproto.Conn, error) {
+			c, err := dialer()
+			if err != nil {
+				return nil, err
+			}
+			return proxyproto.NewClientConn(logger, c, rtype)
+		},
+		log: logger,
+	}
 }
 
 func (p *Client) tryCloseError(c *proxyproto.Conn) {
@@ -49,8 +70,81 @@ func (p *Client) tryCloseError(c *proxyproto.Conn) {
 }
 
 // DisplayProxy returns a new connection for proxying a display stream.
-func (p *Client) DisplayProxy() (*proxyproto.Conn, error) {
-	c, err := proxyproto.Dial(p.log, p.proxyAddr, proxyproto.RequestTypeDisplay)
+func (p *Client) DisplayProxy(req *proxyproto.DisplayRequest) (*proxyproto.Conn, error) {
+	c, err := p.dial(proxyproto.RequestTypeDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AudioProxy returns a new connection for proxying an audio stream in the directions
+// allowed by the request. When both directions are allowed a plain audio request is made,
+// which every proxy version understands.
+func (p *Client) AudioProxy(req *proxyproto.AudioRequest) (*proxyproto.Conn, error) {
+	if req == nil || (req.Playback && req.Capture) {
+		c, err := p.dial(proxyproto.RequestTypeAudio)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ReadStatus(); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	c, err := p.dial(proxyproto.RequestTypeRestrictedAudio)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ClipboardProxy returns a new connection for syncing the clipboard in the directions
+// allowed by the request.
+func (p *Client) ClipboardProxy(req *proxyproto.ClipboardRequest) (*proxyproto.Conn, error) {
+	c, err := p.dial(proxyproto.RequestTypeClipboard)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SSHProxy returns a new connection for proxying an SSH stream.
+func (p *Client) SSHProxy() (*proxyproto.Conn, error) {
+	c, err := p.dial(proxyproto.RequestTypeSSH)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IDEProxy returns a new connection for proxying a stream to the desktop's IDE server.
+func (p *Client) IDEProxy() (*proxyproto.Conn, error) {
+	c, err := p.dial(proxyproto.RequestTypeIDE)
 	if err != nil {
 		return nil, err
 	}
@@ -60,12 +154,17 @@ func (p *Client) DisplayProxy() (*proxyproto.Conn, error) {
 	return c, nil
 }
 
-// AudioProxy returns a new connection for proxying a display stream.
-func (p *Client) AudioProxy() (*proxyproto.Conn, error) {
-	c, err := proxyproto.Dial(p.log, p.proxyAddr, proxyproto.RequestTypeAudio)
+// PluginProxy returns a new connection for proxying a stream to the sidecar plugin named
+// in the request.
+func (p *Client) PluginProxy(req *proxyproto.PluginRequest) (*proxyproto.Conn, error) {
+	c, err := p.dial(proxyproto.RequestTypePlugin)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
 	if err := c.ReadStatus(); err != nil {
 		return nil, err
 	}
@@ -75,7 +174,7 @@ func (p *Client) AudioProxy() (*proxyproto.Conn, error) {
 // StatFile will stat a path on the desktop's filesystem. The returned reader contains
 // json to be presented to the requestor.
 func (p *Client) StatFile(req *proxyproto.FStatRequest) (io.ReadCloser, error) {
-	c, err := proxyproto.Dial(p.log, p.proxyAddr, proxyproto.RequestTypeFStat)
+	c, err := p.dial(proxyproto.RequestTypeFStat)
 	if err != nil {
 		return nil, err
 	}
@@ -89,9 +188,22 @@ func (p *Client) StatFile(req *proxyproto.FStatRequest) (io.ReadCloser, error) {
 	return c, nil
 }
 
+// HomeUsage will retrieve the storage usage of the user's home directory. The returned
+// reader contains json to be presented to the requestor.
+func (p *Client) HomeUsage() (io.ReadCloser, error) {
+	c, err := p.dial(proxyproto.RequestTypeFUsage)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // GetFile will retrieve a file on the desktop's filesystem.
 func (p *Client) GetFile(req *proxyproto.FGetRequest) (*proxyproto.FGetResponse, error) {
-	c, err := proxyproto.Dial(p.log, p.proxyAddr, proxyproto.RequestTypeFGet)
+	c, err := p.dial(proxyproto.RequestTypeFGet)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +224,7 @@ func (p *Client) GetFile(req *proxyproto.FGetRequest) (*proxyproto.FGetResponse,
 
 // PutFile will send a file to the desktop's filesystem.
 func (p *Client) PutFile(req *proxyproto.FPutRequest) error {
-	c, err := proxyproto.Dial(p.log, p.proxyAddr, proxyproto.RequestTypeFPut)
+	c, err := p.dial(proxyproto.RequestTypeFPut)
 	if err != nil {
 		return err
 	}
@@ -131,3 +243,103 @@ func (p *Client) PutFile(req *proxyproto.FPutRequest) error {
 	}
 	return c.Close()
 }
+
+// Control will lock, unlock, or broadcast a message to the desktop.
+func (p *Client) Control(req *proxyproto.ControlRequest) error {
+	c, err := p.dial(proxyproto.RequestTypeControl)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// SetMonitors will change the layout of monitors the desktop spans.
+func (p *Client) SetMonitors(req *proxyproto.MonitorsRequest) error {
+	c, err := p.dial(proxyproto.RequestTypeMonitors)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// Resize will change the resolution and DPI of the desktop's display.
+func (p *Client) Resize(req *proxyproto.ResizeRequest) error {
+	c, err := p.dial(proxyproto.RequestTypeResize)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// Screenshot will capture an image of the desktop's display. The returned reader
+// contains a PNG encoded image.
+func (p *Client) Screenshot(req *proxyproto.ScreenshotRequest) (io.ReadCloser, error) {
+	c, err := p.dial(proxyproto.RequestTypeScreenshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteStructure(req); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Thumbnail will retrieve the most recent thumbnail captured of the desktop's display.
+// The proxy returns an error if thumbnails are not enabled for the desktop.
+func (p *Client) Thumbnail() (*proxyproto.ThumbnailResponse, error) {
+	c, err := p.dial(proxyproto.RequestTypeThumbnail)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	res := &proxyproto.ThumbnailResponse{}
+	if err := c.ReadStructure(res); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	return res, nil
+}
+
+// Idle will retrieve the last time input was received from a client of the desktop.
+func (p *Client) Idle() (*proxyproto.IdleResponse, error) {
+	c, err := p.dial(proxyproto.RequestTypeIdle)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ReadStatus(); err != nil {
+		return nil, err
+	}
+	res := &proxyproto.IdleResponse{}
+	if err := c.ReadStructure(res); err != nil {
+		p.tryCloseError(c)
+		return nil, err
+	}
+	return res, c.Close()
+}
@@ -22,12 +22,13 @@
 package proxyproto
 
 import (
-	"bufio"
+	"bytes"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"io"
 	"net"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
@@ -55,6 +56,13 @@ func Dial(logger logr.Logger, addr string, rtype RequestType) (*Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewClientConn(logger, c, rtype)
+}
+
+// NewClientConn initializes a new client connection for the given request type over an
+// already established connection to a proxy. This is used when the proxy dialed the app
+// over a reverse tunnel.
+func NewClientConn(logger logr.Logger, c net.Conn, rtype RequestType) (*Conn, error) {
 	pc := &Conn{
 		Conn:  c,
 		rtype: rtype,
@@ -154,18 +162,26 @@ func (c *Conn) readByte() (byte, error) {
 }
 
 // ReadString reads until the next newline sent over the connection. Request arguments
-// are newline delimited strings sent immediately after the RequestType. This
-// implementation satisfies the request types currently being used, but may need to be
-// adapted further in the future.
+// are newline delimited strings sent immediately after the RequestType. The string is read
+// a byte at a time, so that nothing sent after it is consumed along with it. This matters
+// for unbuffered transports, such as the UNIX sockets of sidecar plugins, where several
+// arguments may arrive in a single read.
 func (c *Conn) readString() (string, error) {
-	scanner := bufio.NewScanner(c.Conn)
-	scanner.Scan()
-	if err := scanner.Err(); err != nil {
-		return "", err
+	var buf bytes.Buffer
+	for {
+		b, err := c.readByte()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+		if b == '\n' {
+			break
+		}
+		buf.WriteByte(b)
 	}
-	s := scanner.Text()
-	c.rsize += int64(len([]byte(s)))
-	return s, nil
+	return strings.TrimSuffix(buf.String(), "\r"), nil
 }
 
 // ReadInt64 is used similarly to ReadString, except it reads a signed 64-bit integer argument
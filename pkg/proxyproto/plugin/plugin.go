@@ -0,0 +1,143 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package plugin implements the serving side of desktop sidecar plugins. Plugins add
+// channels to the kvdi-proxy without changes to the proxy itself, such as custom device
+// redirection, telemetry, or application launchers. They are declared in the `proxy.plugins`
+// of a template and listen on a UNIX socket shared with the proxy.
+//
+// When a client opens a plugin channel, the proxy dials the plugin's socket and forwards
+// the request, including the name of the user connecting. The plugin accepts or refuses the
+// stream, after which bytes are copied in both directions until either side closes it.
+// Plugins written in Go implement the Plugin interface and call ListenAndServe. Plugins in
+// other languages speak the same protocol described in the proxyproto package.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+)
+
+// Client describes the user on the other end of a plugin stream.
+type Client struct {
+	// The name of the user that opened the stream
+	User string
+}
+
+// Plugin is implemented by sidecar plugins to serve their channel.
+type Plugin interface {
+	// Name returns the name of the plugin. It must match the name declared in the template.
+	Name() string
+	// Accept is called before a stream is established. Returning an error refuses the
+	// stream and the error is sent to the client.
+	Accept(client *Client) error
+	// Serve handles the stream of a single client. The stream is closed when it returns.
+	Serve(ctx context.Context, client *Client, stream net.Conn) error
+}
+
+// SocketPath returns the path of the socket the plugin with the given name listens on.
+func SocketPath(name string) string {
+	return filepath.Join(v1.DesktopPluginsDir, name+".sock")
+}
+
+// ListenAndServe listens on the socket for the given plugin and serves streams until the
+// context is cancelled. The path of the socket is read from the `KVDI_PLUGIN_SOCKET`
+// environment variable when set, otherwise it is derived from the name of the plugin.
+func ListenAndServe(ctx context.Context, logger logr.Logger, p Plugin) error {
+	path := os.Getenv(v1.PluginSocketEnvVar)
+	if path == "" {
+		path = SocketPath(p.Name())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// clean up a socket left behind by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	logger.Info("Listening for plugin streams", "Plugin", p.Name(), "Socket", path)
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveConn(ctx, logger, p, c)
+	}
+}
+
+// serveConn reads the request forwarded by the proxy off the given connection, and hands
+// the stream to the plugin if it is accepted.
+func serveConn(ctx context.Context, logger logr.Logger, p Plugin, c net.Conn) {
+	conn, err := proxyproto.NewConn(logger, c)
+	if err != nil {
+		logger.Error(err, "Error initiating new plugin stream")
+		return
+	}
+	defer conn.Close()
+
+	if conn.RequestType() != proxyproto.RequestTypePlugin {
+		conn.WriteError(fmt.Errorf("Plugins only serve %s requests, got: %s", proxyproto.RequestTypePlugin, conn.RequestType()))
+		return
+	}
+	req := &proxyproto.PluginRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		logger.Error(err, "Error reading plugin request")
+		return
+	}
+	if req.Name != p.Name() {
+		conn.WriteError(fmt.Errorf("This socket serves the %s plugin, not %s", p.Name(), req.Name))
+		return
+	}
+	client := &Client{User: req.User}
+	if err := p.Accept(client); err != nil {
+		conn.WriteError(err)
+		return
+	}
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		logger.Error(err, "Failed to write response header")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := p.Serve(ctx, client, conn); err != nil {
+		logger.Error(err, "Error serving plugin stream", "User", client.User)
+	}
+}
@@ -36,6 +36,8 @@ package proxyproto
 import (
 	"fmt"
 	"io"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 )
 
 // RequestType represents the type of request being made from a client to a proxy.
@@ -53,6 +55,35 @@ const (
 	RequestTypeFGet
 	// RequestTypeFPut is a request to put a file on the system.
 	RequestTypeFPut
+	// RequestTypeFUsage is a request for the storage usage of the user's home directory.
+	RequestTypeFUsage
+	// RequestTypeSSH is a request for a bidirectional stream to the desktop's SSH server.
+	RequestTypeSSH
+	// RequestTypeControl is a request to lock, unlock, or broadcast a message to the desktop.
+	RequestTypeControl
+	// RequestTypeScreenshot is a request for a PNG image of the desktop's display.
+	RequestTypeScreenshot
+	// RequestTypeThumbnail is a request for the most recent thumbnail captured of the
+	// desktop's display.
+	RequestTypeThumbnail
+	// RequestTypeClipboard is a request for a bidirectional stream of clipboard updates.
+	RequestTypeClipboard
+	// RequestTypeIdle is a request for the last time input was received from a client of
+	// the desktop.
+	RequestTypeIdle
+	// RequestTypeMonitors is a request to change the layout of monitors the desktop spans.
+	RequestTypeMonitors
+	// RequestTypePlugin is a request for a bidirectional stream to a sidecar plugin. The
+	// proxy forwards the same request to the plugin over its socket.
+	RequestTypePlugin
+	// RequestTypeResize is a request to change the resolution and DPI of the display.
+	RequestTypeResize
+	// RequestTypeIDE is a request for a bidirectional stream to the desktop's IDE server.
+	RequestTypeIDE
+	// RequestTypeRestrictedAudio is a request for an audio feed limited to the directions in
+	// an AudioRequest. Proxies that predate it close the connection without a status, so a
+	// restricted request to them fails instead of falling back to a bidirectional feed.
+	RequestTypeRestrictedAudio
 )
 
 // RequestStatus represents the non-wire related status of a request.
@@ -78,11 +109,145 @@ func (r RequestType) String() string {
 		return "get-file"
 	case RequestTypeFPut:
 		return "put-file"
+	case RequestTypeFUsage:
+		return "home-usage"
+	case RequestTypeSSH:
+		return "ssh"
+	case RequestTypeControl:
+		return "control"
+	case RequestTypeScreenshot:
+		return "screenshot"
+	case RequestTypeThumbnail:
+		return "thumbnail"
+	case RequestTypeClipboard:
+		return "clipboard"
+	case RequestTypeIdle:
+		return "idle"
+	case RequestTypeMonitors:
+		return "monitors"
+	case RequestTypePlugin:
+		return "plugin"
+	case RequestTypeResize:
+		return "resize"
+	case RequestTypeIDE:
+		return "ide"
+	case RequestTypeRestrictedAudio:
+		return "restricted-audio"
 	default:
 		return "unknown"
 	}
 }
 
+// DisplayRequest contains the parameters for requesting a display stream.
+type DisplayRequest struct {
+	// Whether the client may send its clipboard contents to the desktop over the display
+	// stream. When false, clipboard updates from the client are dropped.
+	ClipboardToDesktop bool
+	// Whether the client may receive the clipboard contents of the desktop over the
+	// display stream. When false, clipboard updates from the desktop are dropped.
+	ClipboardFromDesktop bool
+	// The index of the monitor to stream, zero being the primary monitor. The proxy
+	// refuses the request if the desktop does not currently span the monitor.
+	Monitor int64
+}
+
+func (d *DisplayRequest) String() string {
+	return fmt.Sprintf("Display { ClipboardToDesktop: %t, ClipboardFromDesktop: %t, Monitor: %d }", d.ClipboardToDesktop, d.ClipboardFromDesktop, d.Monitor)
+}
+
+func (d *DisplayRequest) send(c *Conn) (err error) {
+	var flags byte
+	if d.ClipboardToDesktop {
+		flags |= 1
+	}
+	if d.ClipboardFromDesktop {
+		flags |= 2
+	}
+	if err = c.writeByte(flags); err != nil {
+		return
+	}
+	return c.writeInt64(d.Monitor)
+}
+
+func (d *DisplayRequest) recv(c *Conn) (err error) {
+	flags, err := c.readByte()
+	if err != nil {
+		return err
+	}
+	d.ClipboardToDesktop = flags&1 != 0
+	d.ClipboardFromDesktop = flags&2 != 0
+	d.Monitor, err = c.readInt64()
+	return err
+}
+
+// AudioRequest contains the directions of audio the client is allowed to use. It is only
+// sent with a RequestTypeRestrictedAudio, and the proxy refuses the request if neither
+// direction is allowed.
+type AudioRequest struct {
+	Playback bool
+	Capture  bool
+}
+
+func (a *AudioRequest) String() string {
+	return fmt.Sprintf("Audio { Playback: %t, Capture: %t }", a.Playback, a.Capture)
+}
+
+func (a *AudioRequest) send(c *Conn) (err error) {
+	var flags byte
+	if a.Playback {
+		flags |= 1
+	}
+	if a.Capture {
+		flags |= 1 << 1
+	}
+	return c.writeByte(flags)
+}
+
+func (a *AudioRequest) recv(c *Conn) (err error) {
+	flags, err := c.readByte()
+	if err != nil {
+		return err
+	}
+	a.Playback = flags&1 != 0
+	a.Capture = flags&(1<<1) != 0
+	return nil
+}
+
+// ClipboardRequest contains the directions of clipboard sync the client is allowed to
+// use. The proxy refuses the request if neither direction is allowed.
+//
+// Once established, clipboard contents are exchanged in both directions as a 4-byte
+// big-endian length followed by that many bytes of UTF-8 text.
+type ClipboardRequest struct {
+	ToDesktop   bool
+	FromDesktop bool
+}
+
+func (c *ClipboardRequest) String() string {
+	return fmt.Sprintf("Clipboard { ToDesktop: %t, FromDesktop: %t }", c.ToDesktop, c.FromDesktop)
+}
+
+func (c *ClipboardRequest) send(conn *Conn) (err error) {
+	var flags byte
+	if c.ToDesktop {
+		flags |= 1
+	}
+	if c.FromDesktop {
+		flags |= 1 << 1
+	}
+	return conn.writeByte(flags)
+}
+
+func (c *ClipboardRequest) recv(conn *Conn) (err error) {
+	flags, err := conn.readByte()
+	if err != nil {
+		return err
+	}
+	c.ToDesktop = flags&1 != 0
+	c.FromDesktop = flags&(1<<1) != 0
+	return nil
+}
+
 // FStatRequest contains the parameters for sending a stat request to a proxy.
 type FStatRequest struct {
 	Path string
@@ -189,3 +354,238 @@ func (f *FPutRequest) recv(c *Conn) (err error) {
 	f.Body = c
 	return
 }
+
+// ControlAction represents an action to perform on a desktop through a control request.
+type ControlAction byte
+
+const (
+	_ ControlAction = iota
+	// ControlLock locks the desktop's display. Active display streams are closed and new
+	// ones are refused until the desktop is unlocked.
+	ControlLock
+	// ControlUnlock unlocks the desktop's display.
+	ControlUnlock
+	// ControlBroadcast delivers a message to the desktop's user.
+	ControlBroadcast
+)
+
+func (c ControlAction) String() string {
+	switch c {
+	case ControlLock:
+		return "lock"
+	case ControlUnlock:
+		return "unlock"
+	case ControlBroadcast:
+		return "broadcast"
+	default:
+		return "unknown"
+	}
+}
+
+// ControlRequest contains the parameters for sending a control request to a proxy.
+type ControlRequest struct {
+	Action  ControlAction
+	Message string
+}
+
+func (c *ControlRequest) String() string {
+	return fmt.Sprintf("Control { Action: %s }", c.Action.String())
+}
+
+func (c *ControlRequest) send(conn *Conn) (err error) {
+	if err = conn.writeByte(byte(c.Action)); err != nil {
+		return
+	}
+	return conn.writeString(c.Message)
+}
+
+func (c *ControlRequest) recv(conn *Conn) (err error) {
+	action, err := conn.readByte()
+	if err != nil {
+		return err
+	}
+	c.Action = ControlAction(action)
+	c.Message, err = conn.readString()
+	return err
+}
+
+// ScreenshotRequest contains the parameters for requesting an image of the desktop's
+// display.
+type ScreenshotRequest struct {
+	// The maximum width of the returned image. The image is scaled down to fit while
+	// preserving its aspect ratio. Zero means the full resolution of the display.
+	MaxWidth int64
+}
+
+func (s *ScreenshotRequest) String() string {
+	return fmt.Sprintf("Screenshot { MaxWidth: %d }", s.MaxWidth)
+}
+
+func (s *ScreenshotRequest) send(c *Conn) (err error) {
+	return c.writeInt64(s.MaxWidth)
+}
+
+func (s *ScreenshotRequest) recv(c *Conn) (err error) {
+	s.MaxWidth, err = c.readInt64()
+	return err
+}
+
+// ThumbnailResponse contains the most recent thumbnail captured by the proxy.
+type ThumbnailResponse struct {
+	// The unix timestamp the thumbnail was captured at.
+	CapturedAt int64
+	// The size of the PNG encoded image.
+	Size int64
+	// The PNG encoded image.
+	Body io.ReadCloser
+}
+
+func (t *ThumbnailResponse) send(c *Conn) (err error) {
+	defer t.Body.Close()
+	if err = c.writeInt64(t.CapturedAt); err != nil {
+		return
+	}
+	if err = c.writeInt64(t.Size); err != nil {
+		return
+	}
+	_, err = io.Copy(c, t.Body)
+	return
+}
+
+func (t *ThumbnailResponse) recv(c *Conn) (err error) {
+	if t.CapturedAt, err = c.readInt64(); err != nil {
+		return
+	}
+	if t.Size, err = c.readInt64(); err != nil {
+		return
+	}
+	t.Body = c
+	return
+}
+
+// IdleResponse contains the last time input was received from a client of the desktop.
+type IdleResponse struct {
+	// The unix timestamp of the last input event received over a display or SSH stream.
+	// Until a client sends input, this is the time the proxy started.
+	LastInput int64
+}
+
+func (i *IdleResponse) send(c *Conn) (err error) {
+	return c.writeInt64(i.LastInput)
+}
+
+func (i *IdleResponse) recv(c *Conn) (err error) {
+	i.LastInput, err = c.readInt64()
+	return err
+}
+
+// Monitor represents the resolution of a single monitor spanned by a desktop.
+type Monitor struct {
+	Width, Height int64
+}
+
+// MonitorsRequest contains the layout of monitors a desktop should span. The first
+// monitor is the primary one. Display streams for monitors no longer in the layout are
+// closed.
+type MonitorsRequest struct {
+	Monitors []Monitor
+}
+
+func (m *MonitorsRequest) String() string {
+	return fmt.Sprintf("Monitors { Count: %d }", len(m.Monitors))
+}
+
+func (m *MonitorsRequest) send(c *Conn) (err error) {
+	if err = c.writeInt64(int64(len(m.Monitors))); err != nil {
+		return
+	}
+	for _, monitor := range m.Monitors {
+		if err = c.writeInt64(monitor.Width); err != nil {
+			return
+		}
+		if err = c.writeInt64(monitor.Height); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (m *MonitorsRequest) recv(c *Conn) (err error) {
+	count, err := c.readInt64()
+	if err != nil {
+		return err
+	}
+	if count < 0 || count > v1.MaxDesktopMonitors {
+		return fmt.Errorf("Invalid number of monitors: %d", count)
+	}
+	m.Monitors = make([]Monitor, count)
+	for i := range m.Monitors {
+		if m.Monitors[i].Width, err = c.readInt64(); err != nil {
+			return
+		}
+		if m.Monitors[i].Height, err = c.readInt64(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// PluginRequest contains the parameters for requesting a stream to a sidecar plugin. It
+// is sent by the app to the proxy, and forwarded by the proxy to the plugin.
+type PluginRequest struct {
+	// The name of the plugin to connect to
+	Name string
+	// The name of the user the stream is for
+	User string
+}
+
+func (p *PluginRequest) String() string {
+	return fmt.Sprintf("Plugin { Name: %s, User: %s }", p.Name, p.User)
+}
+
+func (p *PluginRequest) send(c *Conn) (err error) {
+	if err = c.writeString(p.Name); err != nil {
+		return
+	}
+	return c.writeString(p.User)
+}
+
+func (p *PluginRequest) recv(c *Conn) (err error) {
+	if p.Name, err = c.readString(); err != nil {
+		return
+	}
+	p.User, err = c.readString()
+	return
+}
+
+// ResizeRequest contains the resolution and DPI a desktop's display should be changed to.
+type ResizeRequest struct {
+	Width, Height int64
+	// The DPI of the display, zero to leave it unchanged
+	DPI int64
+}
+
+func (r *ResizeRequest) String() string {
+	return fmt.Sprintf("Resize { Width: %d, Height: %d, DPI: %d }", r.Width, r.Height, r.DPI)
+}
+
+func (r *ResizeRequest) send(c *Conn) (err error) {
+	if err = c.writeInt64(r.Width); err != nil {
+		return
+	}
+	if err = c.writeInt64(r.Height); err != nil {
+		return
+	}
+	return c.writeInt64(r.DPI)
+}
+
+func (r *ResizeRequest) recv(c *Conn) (err error) {
+	if r.Width, err = c.readInt64(); err != nil {
+		return
+	}
+	if r.Height, err = c.readInt64(); err != nil {
+		return
+	}
+	r.DPI, err = c.readInt64()
+	return
+}
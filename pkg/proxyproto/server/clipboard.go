@@ -0,0 +1,183 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+)
+
+// maxClipboardLength is the largest clipboard update relayed in either direction.
+const maxClipboardLength = 1 << 20
+
+func (p *Server) handleClipboard(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.ClipboardRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read clipboard request from client")
+		conn.WriteError(err)
+		return
+	}
+	if !req.ToDesktop && !req.FromDesktop {
+		conn.WriteError(errors.New("Clipboard sync is disabled for this user"))
+		return
+	}
+	if !p.displayIsVNC() {
+		conn.WriteError(fmt.Errorf("Clipboard sync is not supported for %s displays", p.opts.DisplayProtocol))
+		return
+	}
+	p.log.Info("Clipboard directions allowed by policy", "Request", req.String())
+
+	// Clipboard streams belong to the primary monitor, so they survive layout changes
+	if err := p.control.track(conn, 0); err != nil {
+		p.log.Info("Refusing clipboard request", "Reason", err.Error())
+		conn.WriteError(err)
+		return
+	}
+	defer p.control.untrack(conn)
+
+	displayConn, err := net.Dial(p.opts.DisplayProto, p.opts.DisplayAddress)
+	if err != nil {
+		p.log.Error(err, "Failed to connect to display server")
+		conn.WriteError(err)
+		return
+	}
+	defer displayConn.Close()
+
+	// The connection is shared with the display streams, and since no framebuffer
+	// updates are ever requested, the server only sends clipboard and bell messages.
+	rw := bufio.NewReadWriter(bufio.NewReader(displayConn), bufio.NewWriter(displayConn))
+	if _, err := rfbHandshake(rw, p.opts.DisplayPassword); err != nil {
+		p.log.Error(err, "Failed to complete handshake with display server")
+		conn.WriteError(err)
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Failed to write response header")
+		return
+	}
+
+	stChan := p.logConnectionMetrics("clipboard", conn)
+	defer func() { stChan <- struct{}{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer cancel()
+		if err := relayServerCutText(conn, rw.Reader, req.FromDesktop); err != nil && err != io.EOF && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, "Error while relaying clipboard from display server to client")
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		if err := relayClientClipboard(rw.Writer, conn, req.ToDesktop); err != nil && err != io.EOF && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, "Error while relaying clipboard from client to display server")
+		}
+	}()
+
+	<-ctx.Done()
+	p.log.Info("Clipboard sync ended")
+}
+
+// relayServerCutText reads messages from the display server and writes any clipboard
+// updates to the client. Updates are discarded when forward is false.
+func relayServerCutText(dst io.Writer, r io.Reader, forward bool) error {
+	server := rfb.NewServerReader(r)
+	if forward {
+		server.MaxCutText = maxClipboardLength
+		server.OnCutText = func(text string) error { return writeClipboardUpdate(dst, text) }
+	}
+	return server.Run()
+}
+
+// relayClientClipboard reads clipboard updates from the client and sends them to the
+// display server. Updates are discarded when forward is false.
+func relayClientClipboard(w io.Writer, r io.Reader, forward bool) error {
+	for {
+		text, err := readClipboardUpdate(r)
+		if err != nil {
+			return err
+		}
+		if !forward {
+			continue
+		}
+		if err := rfb.WriteClientCutText(w, text); err != nil {
+			return err
+		}
+	}
+}
+
+// readClipboardUpdate reads a length-prefixed clipboard update from the client.
+func readClipboardUpdate(r io.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if length > maxClipboardLength {
+		return "", fmt.Errorf("Clipboard update of %d bytes exceeds the limit of %d bytes", length, maxClipboardLength)
+	}
+	text := make([]byte, length)
+	if _, err := io.ReadFull(r, text); err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// writeClipboardUpdate writes a length-prefixed clipboard update to the client.
+func writeClipboardUpdate(w io.Writer, text string) error {
+	msg := make([]byte, 4, 4+len(text))
+	binary.BigEndian.PutUint32(msg, uint32(len(text)))
+	_, err := w.Write(append(msg, text...))
+	return err
+}
+
+// copyClientMessages copies the client side of a brokered display stream to the display
+// server, dropping clipboard updates sent by the client unless allowCutText is true. When
+// a ServerCutTextFilter is given, the client's messages are copied through it.
+func copyClientMessages(dst io.Writer, src io.Reader, allowCutText bool, serverCutText *rfb.ServerCutTextFilter) error {
+	// ClientInit
+	init := make([]byte, 1)
+	if _, err := io.ReadFull(src, init); err != nil {
+		return err
+	}
+	if _, err := dst.Write(init); err != nil {
+		return err
+	}
+	var err error
+	if serverCutText != nil {
+		_, err = serverCutText.FilterClient(dst, src, allowCutText)
+	} else {
+		_, err = rfb.FilterClientMessages(dst, src, allowCutText)
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+)
+
+// broadcastDir is the directory in the user's home where broadcast messages are written.
+var broadcastDir = filepath.Join(v1.DesktopHomeMntPath, "Broadcasts")
+
+// displayControl tracks whether the display is locked and the display streams that
+// are currently being served.
+type displayControl struct {
+	log      logr.Logger
+	locked   bool
+	message  string
+	displays map[*proxyproto.Conn]int64
+	mux      sync.Mutex
+}
+
+func newDisplayControl(logger logr.Logger) *displayControl {
+	return &displayControl{
+		log:      logger.WithName("control"),
+		displays: make(map[*proxyproto.Conn]int64),
+	}
+}
+
+// track registers a display stream for the given monitor. An error is returned if the
+// display is locked.
+func (d *displayControl) track(conn *proxyproto.Conn, monitor int64) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.locked {
+		if d.message != "" {
+			return fmt.Errorf("The display is locked: %s", d.message)
+		}
+		return fmt.Errorf("The display is locked")
+	}
+	d.displays[conn] = monitor
+	return nil
+}
+
+// untrack removes a display stream once it has finished.
+func (d *displayControl) untrack(conn *proxyproto.Conn) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	delete(d.displays, conn)
+}
+
+// lock locks the display and closes any active display streams. It returns false if
+// the display was already locked with the same message. The lock is applied again
+// periodically from the state recorded on the lab, so it must be idempotent.
+func (d *displayControl) lock(message string) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.locked && d.message == message {
+		return false
+	}
+	d.locked = true
+	d.message = message
+	for conn := range d.displays {
+		if err := conn.Close(); err != nil {
+			d.log.Error(err, "Error closing display stream")
+		}
+		delete(d.displays, conn)
+	}
+	return true
+}
+
+// detachMonitors closes any active display streams for monitors at or beyond the given
+// index.
+func (d *displayControl) detachMonitors(from int64) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for conn, monitor := range d.displays {
+		if monitor < from {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			d.log.Error(err, "Error closing display stream")
+		}
+		delete(d.displays, conn)
+	}
+}
+
+// unlock allows display streams to be served again. It returns false if the display
+// was not locked.
+func (d *displayControl) unlock() bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if !d.locked {
+		return false
+	}
+	d.locked = false
+	d.message = ""
+	return true
+}
+
+// broadcast writes the given message to a file in the user's Broadcasts directory. The
+// kvdi-agent shows broadcasts as notifications from the session and lab status, so this
+// keeps a record of them for users of desktops without the agent.
+func (d *displayControl) broadcast(message string, uid int) error {
+	if err := os.MkdirAll(broadcastDir, 0755); err != nil {
+		return err
+	}
+	if err := os.Chown(broadcastDir, uid, uid); err != nil {
+		return err
+	}
+	dst := filepath.Join(broadcastDir, fmt.Sprintf("%s.txt", time.Now().UTC().Format("20060102-150405")))
+	if err := ioutil.WriteFile(dst, []byte(message+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Chown(dst, uid, uid)
+}
@@ -22,6 +22,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -31,6 +32,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kennygrant/sanitize"
@@ -39,8 +41,11 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/audio/pa"
 	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
 	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/bufpool"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/guac"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
 )
 
 func (p *Server) setupPulseAudio(manager *pa.DeviceManager) error {
@@ -69,11 +74,37 @@ func (p *Server) setupPulseAudio(manager *pa.DeviceManager) error {
 }
 
 func (p *Server) handleDisplay(conn *proxyproto.Conn) {
-	addr := fmt.Sprintf("%s://%s", p.opts.DisplayProto, p.opts.DisplayAddress)
-	p.log.Info(fmt.Sprintf("Received display proxy request, connecting to %s", addr))
 	defer conn.Close()
 
-	displayConn, err := net.Dial(p.opts.DisplayProto, p.opts.DisplayAddress)
+	req := &proxyproto.DisplayRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read display request from client")
+		conn.WriteError(err)
+		return
+	}
+
+	if req.Monitor < 0 || req.Monitor >= p.monitors.count() {
+		err := fmt.Errorf("Monitor %d is not attached to the desktop", req.Monitor)
+		p.log.Info("Refusing display proxy request", "Reason", err.Error())
+		conn.WriteError(err)
+		return
+	}
+	displayAddr, err := monitorAddress(p.opts.DisplayProto, p.opts.DisplayAddress, req.Monitor)
+	if err != nil {
+		p.log.Error(err, "Failed to determine display server address")
+		conn.WriteError(err)
+		return
+	}
+	p.log.Info(fmt.Sprintf("Received display proxy request, connecting to %s://%s", p.opts.DisplayProto, displayAddr), "Monitor", req.Monitor)
+
+	if err := p.control.track(conn, req.Monitor); err != nil {
+		p.log.Info("Refusing display proxy request", "Reason", err.Error())
+		conn.WriteError(err)
+		return
+	}
+	defer p.control.untrack(conn)
+
+	displayConn, err := net.Dial(p.opts.DisplayProto, displayAddr)
 	if err != nil {
 		p.log.Error(err, "Failed to connect to display server")
 		conn.WriteError(err)
@@ -81,20 +112,83 @@ func (p *Server) handleDisplay(conn *proxyproto.Conn) {
 	}
 	p.log.Info("Connection to display server established")
 
+	// Clipboard updates can only be picked out of the display stream when the handshake
+	// is brokered by the proxy. Following the server's messages also requires following
+	// the pixel format and encodings chosen by the client.
+	var serverCutText *rfb.ServerCutTextFilter
+	if !req.ClipboardFromDesktop && p.displayIsVNC() {
+		serverCutText = rfb.NewServerCutTextFilter()
+	}
+	filterClient := (!req.ClipboardToDesktop || serverCutText != nil) && p.displayIsVNC()
+
+	// For VNC displays, authenticate on behalf of the client and present it with a server
+	// that needs no authentication. This keeps the display password from the client and
+	// lets its messages be followed, to drop clipboard updates and pick out input events
+	// from the framebuffer update requests it sends continuously.
+	authenticate := p.displayIsVNC()
+	if authenticate {
+		if err := rfb.Authenticate(displayConn, p.opts.DisplayPassword); err != nil {
+			displayConn.Close()
+			p.log.Error(err, "Failed to authenticate with display server")
+			conn.WriteError(err)
+			return
+		}
+	}
+
+	// For RDP displays, have guacd connect to the RDP server before handing the
+	// connection to the client.
+	var tunnelID string
+	if p.opts.RDP != nil {
+		if tunnelID, err = guac.Handshake(displayConn, p.opts.RDP); err != nil {
+			displayConn.Close()
+			p.log.Error(err, "Failed to connect to RDP server through guacd")
+			conn.WriteError(err)
+			return
+		}
+	}
+
 	p.log.Info("Starting display proxy")
 	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
 		p.log.Error(err, "Failed to write response header")
 		return
 	}
 
+	if authenticate {
+		if err := rfb.ServeNoAuth(conn); err != nil {
+			displayConn.Close()
+			p.log.Error(err, "Failed to complete handshake with client")
+			return
+		}
+	}
+
+	if tunnelID != "" {
+		if err := guac.WriteInstruction(conn, guac.TunnelInstruction(tunnelID)); err != nil {
+			displayConn.Close()
+			p.log.Error(err, "Failed to send tunnel ID to client")
+			return
+		}
+	}
+
 	stChan := p.logConnectionMetrics("display", conn)
 	defer func() { stChan <- struct{}{} }()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Record input from the client for idle tracking
+	var clientStream io.Reader = &inputReader{Reader: conn, idle: p.idle}
+	if authenticate {
+		clientStream = io.TeeReader(conn, rfb.NewInputWatcher(p.idle.touch))
+	}
+
 	go func() {
 		defer cancel()
-		if _, err := io.Copy(displayConn, conn); err != nil {
+		var err error
+		if filterClient {
+			err = copyClientMessages(displayConn, clientStream, req.ClipboardToDesktop, serverCutText)
+		} else {
+			_, err = bufpool.Copy(displayConn, clientStream)
+		}
+		if err != nil {
 			p.log.Error(err, "Error while copying stream from client connection to display socket")
 		}
 	}()
@@ -102,17 +196,27 @@ func (p *Server) handleDisplay(conn *proxyproto.Conn) {
 	// Copy server connection to the client
 	go func() {
 		defer cancel()
-		if _, err := io.Copy(conn, displayConn); err != nil {
+		var err error
+		if serverCutText != nil {
+			_, err = serverCutText.FilterServer(conn, displayConn)
+		} else {
+			_, err = bufpool.Copy(conn, displayConn)
+		}
+		if err != nil {
 			p.log.Error(err, "Error while copying stream from display socket to client connection")
 		}
 	}()
 
-	p.log.Info(fmt.Sprintf("Connecting to pulse server: %s", p.opts.PulseServer))
-	paDevices, err := pa.NewDeviceManager(&pa.DeviceManagerOpts{
-		PulseServer: p.opts.PulseServer,
-	})
-	if err != nil {
-		p.log.Error(err, "Failed to setup pulseaudio, playback will not function")
+	// Audio devices are only set up for the primary monitor
+	var paDevices *pa.DeviceManager
+	if !p.opts.AudioDisabled && req.Monitor == 0 {
+		p.log.Info(fmt.Sprintf("Connecting to pulse server: %s", p.opts.PulseServer))
+		paDevices, err = pa.NewDeviceManager(&pa.DeviceManagerOpts{
+			PulseServer: p.opts.PulseServer,
+		})
+		if err != nil {
+			p.log.Error(err, "Failed to setup pulseaudio, playback will not function")
+		}
 	}
 
 	if paDevices != nil {
@@ -135,9 +239,171 @@ func (p *Server) handleDisplay(conn *proxyproto.Conn) {
 	}
 }
 
+func (p *Server) handleControl(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.ControlRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read control request from client")
+		conn.WriteError(err)
+		return
+	}
+	switch req.Action {
+	case proxyproto.ControlLock:
+		if p.control.lock(req.Message) {
+			p.log.Info(req.String())
+		}
+	case proxyproto.ControlUnlock:
+		if p.control.unlock() {
+			p.log.Info(req.String())
+		}
+	case proxyproto.ControlBroadcast:
+		p.log.Info(req.String())
+		if err := p.control.broadcast(req.Message, p.opts.FSUserID); err != nil {
+			p.log.Error(err, "Failed to write broadcast message")
+			conn.WriteError(err)
+			return
+		}
+	default:
+		conn.WriteError(fmt.Errorf("Unknown control action: %d", req.Action))
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Error writing OK to connection")
+	}
+}
+
+func (p *Server) handleScreenshot(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.ScreenshotRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read screenshot request from client")
+		conn.WriteError(err)
+		return
+	}
+	p.log.Info(req.String())
+
+	img, err := p.captureDisplay(int(req.MaxWidth))
+	if err != nil {
+		p.log.Error(err, "Failed to capture display")
+		conn.WriteError(err)
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Failed to write response status header")
+		return
+	}
+	if _, err := conn.Write(img); err != nil {
+		p.log.Error(err, "Failed to copy screenshot to client")
+	}
+}
+
+func (p *Server) handleThumbnail(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	img, capturedAt, err := p.thumbnails.latest()
+	if err != nil {
+		conn.WriteError(err)
+		return
+	}
+
+	conn.WriteResponse(&proxyproto.ThumbnailResponse{
+		CapturedAt: capturedAt.Unix(),
+		Size:       int64(len(img)),
+		Body:       ioutil.NopCloser(bytes.NewReader(img)),
+	})
+}
+
+func (p *Server) handleSSH(conn *proxyproto.Conn) {
+	p.proxyTCP(conn, "SSH", p.opts.SSHAddress)
+}
+
+func (p *Server) handleIDE(conn *proxyproto.Conn) {
+	if p.opts.IDEAddress == "" {
+		defer conn.Close()
+		conn.WriteError(errors.New("This desktop does not run an IDE server"))
+		return
+	}
+	p.proxyTCP(conn, "IDE", p.opts.IDEAddress)
+}
+
+// proxyTCP pipes the given connection to a TCP server inside the desktop until either
+// side closes.
+func (p *Server) proxyTCP(conn *proxyproto.Conn, name, addr string) {
+	p.log.Info(fmt.Sprintf("Received %s proxy request, connecting to %s", name, addr))
+	defer conn.Close()
+
+	srvConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		p.log.Error(err, fmt.Sprintf("Failed to connect to %s server", name))
+		conn.WriteError(err)
+		return
+	}
+	defer srvConn.Close()
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Failed to write response header")
+		return
+	}
+
+	stChan := p.logConnectionMetrics(strings.ToLower(name), conn)
+	defer func() { stChan <- struct{}{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer cancel()
+		if _, err := bufpool.Copy(srvConn, &inputReader{Reader: conn, idle: p.idle}); err != nil && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, fmt.Sprintf("Error while copying stream from client connection to %s server", name))
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		if _, err := bufpool.Copy(conn, srvConn); err != nil && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, fmt.Sprintf("Error while copying stream from %s server to client connection", name))
+		}
+	}()
+
+	<-ctx.Done()
+	p.log.Info(fmt.Sprintf("%s stream proxy ended", name))
+}
+
 func (p *Server) handleAudio(conn *proxyproto.Conn) {
 	p.log.Info("Received audio proxy request, setting up pulseaudio/g-streamer")
 	defer conn.Close()
+	p.serveAudio(conn, &proxyproto.AudioRequest{Playback: true, Capture: true})
+}
+
+func (p *Server) handleRestrictedAudio(conn *proxyproto.Conn) {
+	p.log.Info("Received restricted audio proxy request, setting up pulseaudio/g-streamer")
+	defer conn.Close()
+
+	req := &proxyproto.AudioRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read audio request from client")
+		conn.WriteError(err)
+		return
+	}
+	p.serveAudio(conn, req)
+}
+
+func (p *Server) serveAudio(conn *proxyproto.Conn, req *proxyproto.AudioRequest) {
+	if p.opts.AudioDisabled {
+		conn.WriteError(errors.New("Audio is disabled for this desktop"))
+		return
+	}
+	if p.opts.MicrophoneDisabled {
+		req.Capture = false
+	}
+	if !req.Playback && !req.Capture {
+		conn.WriteError(errors.New("Audio is disabled by the device policy for this user"))
+		return
+	}
+	p.log.Info("Audio directions allowed by device policy", "Request", req.String())
 
 	p.log.Info("Starting audio buffer")
 	// Create a new audio buffer
@@ -145,6 +411,7 @@ func (p *Server) handleAudio(conn *proxyproto.Conn) {
 		Logger:                 p.log,
 		PulseServer:            p.opts.PulseServer,
 		PulseMonitorSampleRate: p.opts.PlaybackSampleRate,
+		PulseMonitorBitrate:    p.opts.PlaybackBitrate,
 		PulseMonitorName:       p.opts.PlaybackDeviceName,
 		PulseMicName:           p.opts.RecordingDeviceName,
 		PulseMicPath:           p.opts.RecordingDevicePath,
@@ -165,20 +432,28 @@ func (p *Server) handleAudio(conn *proxyproto.Conn) {
 	stChan := p.logConnectionMetrics("audio", conn)
 	defer func() { stChan <- struct{}{} }()
 
-	// Copy audio playback data to the connection
+	// Copy audio playback data to the connection, or discard it if playback is not allowed
+	var playbackDst io.Writer = conn
+	if !req.Playback {
+		playbackDst = ioutil.Discard
+	}
 	go func() {
 		defer audioBuffer.Close()
-		if _, err := io.Copy(conn, audioBuffer); err != nil {
+		if _, err := bufpool.Copy(playbackDst, audioBuffer); err != nil {
 			if !errors.IsBrokenPipeError(err) {
 				p.log.Error(err, "Error while copying from audio stream to websocket connection")
 			}
 		}
 	}()
 
-	// Copy any received recording data to the buffer
+	// Copy any received recording data to the buffer, or discard it if capture is not allowed
+	var recordingDst io.Writer = audioBuffer
+	if !req.Capture {
+		recordingDst = ioutil.Discard
+	}
 	go func() {
 		defer audioBuffer.Close()
-		if _, err := io.Copy(audioBuffer, conn); err != nil {
+		if _, err := bufpool.Copy(recordingDst, conn); err != nil {
 			if !errors.IsBrokenPipeError(err) {
 				p.log.Error(err, "Error while copying from websocket connection to audio buffer")
 			}
@@ -297,6 +572,12 @@ func (p *Server) handlePut(conn *proxyproto.Conn) {
 	}
 	p.log.Info(req.String())
 
+	if err := p.quota.reserve(req.Size); err != nil {
+		p.log.Error(err, "Refusing upload")
+		conn.WriteError(err)
+		return
+	}
+
 	uploadDir := filepath.Join(v1.DesktopHomeMntPath, "Uploads")
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		conn.WriteError(err)
@@ -332,6 +613,28 @@ func (p *Server) handlePut(conn *proxyproto.Conn) {
 	}
 }
 
+func (p *Server) handleUsage(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	// The usage is kept current by the quota watcher, so there is no need to walk the
+	// home directory again here. Without a quota nothing is tracked.
+	out, err := json.MarshalIndent(p.quota.usage(), "", "  ")
+	if err != nil {
+		p.log.Error(err, "Failed to marshal response")
+		conn.WriteError(err)
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Failed to write response status header")
+		return
+	}
+
+	if _, err := conn.Write(out); err != nil {
+		p.log.Error(err, "Failed to copy response to client")
+	}
+}
+
 func serveDir(conn *proxyproto.Conn, path string) {
 	tarball, err := common.TarDirectoryToTempFile(path)
 	if err != nil {
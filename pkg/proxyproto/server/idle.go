@@ -0,0 +1,69 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+)
+
+// idleTracker records the last time input was received from a client of the desktop.
+// Until a client sends input, the time the proxy started is used.
+type idleTracker struct {
+	last int64
+}
+
+// newIdleTracker returns a new idle tracker starting at the current time.
+func newIdleTracker() *idleTracker {
+	return &idleTracker{last: time.Now().UnixNano()}
+}
+
+// touch records input at the current time.
+func (i *idleTracker) touch() { atomic.StoreInt64(&i.last, time.Now().UnixNano()) }
+
+// lastInput returns the last time input was received.
+func (i *idleTracker) lastInput() time.Time { return time.Unix(0, atomic.LoadInt64(&i.last)) }
+
+// inputReader wraps a client stream and records input whenever data is read from it.
+// It is used for streams where input cannot be told apart from other client traffic.
+type inputReader struct {
+	io.Reader
+	idle *idleTracker
+}
+
+func (r *inputReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.idle.touch()
+	}
+	return n, err
+}
+
+func (p *Server) handleIdle(conn *proxyproto.Conn) {
+	defer conn.Close()
+	conn.WriteResponse(&proxyproto.IdleResponse{
+		LastInput: p.idle.lastInput().Unix(),
+	})
+}
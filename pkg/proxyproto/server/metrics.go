@@ -0,0 +1,83 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus gatherers
+
+var (
+	// streamBytesSentTotal tracks bytes sent to clients over proxied streams
+	streamBytesSentTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "proxy_stream_bytes_sent_total",
+		Help:      "Total bytes sent to clients over proxied streams by stream type.",
+	}, []string{"stream"})
+
+	// streamBytesReceivedTotal tracks bytes received from clients over proxied streams
+	streamBytesReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "proxy_stream_bytes_rcvd_total",
+		Help:      "Total bytes received from clients over proxied streams by stream type.",
+	}, []string{"stream"})
+
+	// activeStreams tracks the number of proxied streams currently open
+	activeStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "proxy_active_streams",
+		Help:      "The current number of proxied streams by stream type.",
+	}, []string{"stream"})
+)
+
+// streamCounter publishes the bytes transferred over a connection to the stream metrics.
+type streamCounter struct {
+	labels     prometheus.Labels
+	conn       *proxyproto.Conn
+	sent, rcvd int64
+}
+
+func newStreamCounter(streamType string, conn *proxyproto.Conn) *streamCounter {
+	return &streamCounter{labels: prometheus.Labels{"stream": streamType}, conn: conn}
+}
+
+// flush adds the bytes transferred since the last flush to the stream metrics.
+func (s *streamCounter) flush() {
+	sent, rcvd := s.conn.BytesSentCount(), s.conn.BytesRecvdCount()
+	streamBytesSentTotal.With(s.labels).Add(float64(sent - s.sent))
+	streamBytesReceivedTotal.With(s.labels).Add(float64(rcvd - s.rcvd))
+	s.sent, s.rcvd = sent, rcvd
+}
+
+// ServeMetrics serves the prometheus metrics of the proxy over plain HTTP at the given
+// address. It blocks forever and is meant to be run alongside ListenAndServe.
+func (p *Server) ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	p.log.Info("Serving metrics", "Address", addr)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,162 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+)
+
+// monitorLayout tracks the monitors the desktop spans. The layout is written to a file
+// in the desktop's runtime directory, where the init process picks it up to resize the
+// display and start a VNC server for each additional monitor.
+type monitorLayout struct {
+	max                 int
+	maxWidth, maxHeight int64
+	path                string
+	monitors            []proxyproto.Monitor
+	mux                 sync.Mutex
+}
+
+// newMonitorLayout returns a layout allowing up to max monitors of at most the given
+// resolution. Desktops start out spanning a single monitor.
+func newMonitorLayout(max int, maxWidth, maxHeight int64) *monitorLayout {
+	if max < 1 {
+		max = 1
+	}
+	return &monitorLayout{
+		max:       max,
+		maxWidth:  maxWidth,
+		maxHeight: maxHeight,
+		path:      v1.DesktopMonitorsFile,
+	}
+}
+
+// enabled returns true if the desktop may span more than one monitor.
+func (m *monitorLayout) enabled() bool { return m.max > 1 }
+
+// count returns the number of monitors the desktop currently spans.
+func (m *monitorLayout) count() int64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.monitors) == 0 {
+		return 1
+	}
+	return int64(len(m.monitors))
+}
+
+// set validates the given monitors and writes them as the desktop's new layout. It
+// returns the index of the first additional monitor that changed, from which display
+// streams are no longer showing what they were.
+func (m *monitorLayout) set(monitors []proxyproto.Monitor) (int64, error) {
+	if !m.enabled() {
+		return 0, fmt.Errorf("Multiple monitors are not enabled for this desktop")
+	}
+	if len(monitors) == 0 {
+		return 0, fmt.Errorf("The desktop must span at least one monitor")
+	}
+	if len(monitors) > m.max {
+		return 0, fmt.Errorf("The desktop may span at most %d monitors", m.max)
+	}
+	var b strings.Builder
+	for idx, monitor := range monitors {
+		if monitor.Width <= 0 || monitor.Height <= 0 {
+			return 0, fmt.Errorf("Monitor %d must have a width and height greater than zero", idx)
+		}
+		if monitor.Width > m.maxWidth || monitor.Height > m.maxHeight {
+			return 0, fmt.Errorf("Monitor %d exceeds the maximum resolution of %dx%d", idx, m.maxWidth, m.maxHeight)
+		}
+		fmt.Fprintf(&b, "%dx%d\n", monitor.Width, monitor.Height)
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	// Write to a temporary file first so the init process never reads a partial layout
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return 0, err
+	}
+	// The primary monitor is always served by the same display server
+	changed := int64(1)
+	for changed < int64(len(monitors)) && changed < int64(len(m.monitors)) && monitors[changed] == m.monitors[changed] {
+		changed++
+	}
+	m.monitors = monitors
+	return changed, nil
+}
+
+// monitorAddress returns the address of the display server for the given monitor. The
+// primary monitor is served at the display address, and each additional one on the
+// display socket suffixed with its index, or the display port offset by its index.
+func monitorAddress(proto, addr string, monitor int64) (string, error) {
+	if monitor == 0 {
+		return addr, nil
+	}
+	if proto == "unix" {
+		ext := filepath.Ext(addr)
+		return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(addr, ext), monitor, ext), nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(portNum+int(monitor))), nil
+}
+
+func (p *Server) handleMonitors(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.MonitorsRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read monitors request from client")
+		conn.WriteError(err)
+		return
+	}
+	p.log.Info(req.String())
+
+	changed, err := p.monitors.set(req.Monitors)
+	if err != nil {
+		p.log.Error(err, "Failed to set monitor layout")
+		conn.WriteError(err)
+		return
+	}
+	p.control.detachMonitors(changed)
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Error writing OK to connection")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto/plugin"
+	"github.com/tinyzimmer/kvdi/pkg/util/bufpool"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// pluginEnabled returns true if the plugin with the given name was declared for the
+// desktop.
+func (p *Server) pluginEnabled(name string) bool {
+	for _, plugin := range p.opts.Plugins {
+		if plugin == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Server) handlePlugin(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.PluginRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Failed to read plugin request")
+		conn.WriteError(err)
+		return
+	}
+	if !p.pluginEnabled(req.Name) {
+		conn.WriteError(fmt.Errorf("The %s plugin is not enabled for this desktop", req.Name))
+		return
+	}
+	p.log.Info(fmt.Sprintf("Received plugin request, connecting to %s", plugin.SocketPath(req.Name)), "User", req.User)
+
+	c, err := net.Dial("unix", plugin.SocketPath(req.Name))
+	if err != nil {
+		p.log.Error(err, "Failed to connect to plugin")
+		conn.WriteError(fmt.Errorf("The %s plugin is not running", req.Name))
+		return
+	}
+	pluginConn, err := proxyproto.NewClientConn(p.log, c, proxyproto.RequestTypePlugin)
+	if err != nil {
+		p.log.Error(err, "Failed to initiate plugin stream")
+		conn.WriteError(err)
+		return
+	}
+	defer pluginConn.Close()
+	if err := pluginConn.WriteStructure(req); err != nil {
+		conn.WriteError(err)
+		return
+	}
+	// pass a refusal from the plugin on to the client
+	if err := pluginConn.ReadStatus(); err != nil {
+		conn.WriteError(err)
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Failed to write response header")
+		return
+	}
+
+	stChan := p.logConnectionMetrics("plugin-"+req.Name, conn)
+	defer func() { stChan <- struct{}{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer cancel()
+		if _, err := bufpool.Copy(pluginConn, conn); err != nil && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, "Error while copying stream from client connection to plugin")
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		if _, err := bufpool.Copy(conn, pluginConn); err != nil && !errors.IsBrokenPipeError(err) {
+			p.log.Error(err, "Error while copying stream from plugin to client connection")
+		}
+	}()
+
+	<-ctx.Done()
+	p.log.Info("Plugin stream proxy ended", "Plugin", req.Name)
+}
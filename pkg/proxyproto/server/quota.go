@@ -0,0 +1,142 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// quotaPollInterval is how often the home directory is walked to recompute usage.
+var quotaPollInterval = time.Second * 30
+
+// quotaWatcher tracks the storage usage of the user's home directory and enforces
+// the configured quota on writes made through the proxy. Writes made from inside the
+// desktop are bounded by the size limit of the home volume instead, and are caught up
+// with by the periodic scan, which reports usage to the app for the user to see.
+type quotaWatcher struct {
+	log              logr.Logger
+	path             string
+	limit            int64
+	warningThreshold int
+	used             int64
+	mux              sync.RWMutex
+}
+
+func newQuotaWatcher(logger logr.Logger, limit int64, warningThreshold int) *quotaWatcher {
+	return &quotaWatcher{
+		log:              logger.WithName("quota"),
+		path:             v1.DesktopHomeMntPath,
+		limit:            limit,
+		warningThreshold: warningThreshold,
+	}
+}
+
+// enabled returns true if a quota is being enforced.
+func (q *quotaWatcher) enabled() bool { return q.limit > 0 }
+
+// run recomputes usage on an interval. Usage over the warning threshold is logged here,
+// and reaches the user through the session status the app records from the usage
+// requests it makes.
+func (q *quotaWatcher) run() {
+	ticker := time.NewTicker(quotaPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := q.refresh(); err != nil {
+			q.log.Error(err, "Failed to compute home directory usage")
+		} else if usage := q.usage(); usage.Exceeded {
+			q.log.Info("Home directory usage has reached the quota, refusing uploads", "Used", usage.Used, "Limit", usage.Limit)
+		} else if usage.Warning {
+			q.log.Info("Home directory usage is above the warning threshold", "Used", usage.Used, "Limit", usage.Limit)
+		}
+		<-ticker.C
+	}
+}
+
+// refresh walks the home directory and updates the recorded usage.
+func (q *quotaWatcher) refresh() error {
+	used, err := dirSize(q.path)
+	if err != nil {
+		return err
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.used = used
+	return nil
+}
+
+// usage returns the last recorded usage of the home directory.
+func (q *quotaWatcher) usage() *types.HomeUsageResponse {
+	q.mux.RLock()
+	defer q.mux.RUnlock()
+	res := &types.HomeUsageResponse{Used: q.used, Limit: q.limit}
+	if q.enabled() {
+		res.Warning = q.used*100 >= q.limit*int64(q.warningThreshold)
+		res.Exceeded = q.used >= q.limit
+	}
+	return res
+}
+
+// reserve checks that writing the given number of bytes would not exceed the quota.
+// Usage is recomputed first so that uploads are checked against an accurate value.
+func (q *quotaWatcher) reserve(size int64) error {
+	if !q.enabled() {
+		return nil
+	}
+	if err := q.refresh(); err != nil {
+		return err
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.used+size > q.limit {
+		return fmt.Errorf("writing %d bytes would exceed the home directory quota (%d of %d bytes used)", size, q.used, q.limit)
+	}
+	q.used += size
+	return nil
+}
+
+// dirSize returns the total size of all regular files beneath the given path.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files may disappear while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
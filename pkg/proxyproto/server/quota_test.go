@@ -0,0 +1,112 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+func newTestQuotaWatcher(t *testing.T, limit int64) *quotaWatcher {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	q := newQuotaWatcher(testLogger, limit, 50)
+	q.path = dir
+	return q
+}
+
+func writeTestFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaUsage(t *testing.T) {
+	q := newTestQuotaWatcher(t, 100)
+	// files written from inside the desktop are picked up by the scan
+	writeTestFile(t, filepath.Join(q.path, "a"), 30)
+	writeTestFile(t, filepath.Join(q.path, "nested", "b"), 30)
+	if err := q.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	usage := q.usage()
+	if usage.Used != 60 || usage.Limit != 100 {
+		t.Errorf("Expected 60 of 100 bytes used, got: %+v", usage)
+	}
+	if !usage.Warning || usage.Exceeded {
+		t.Errorf("Expected usage to be above the warning threshold only, got: %+v", usage)
+	}
+
+	writeTestFile(t, filepath.Join(q.path, "c"), 40)
+	if err := q.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if usage := q.usage(); !usage.Exceeded {
+		t.Errorf("Expected the quota to be exceeded, got: %+v", usage)
+	}
+}
+
+func TestQuotaReserve(t *testing.T) {
+	q := newTestQuotaWatcher(t, 100)
+	writeTestFile(t, filepath.Join(q.path, "a"), 60)
+	if err := q.reserve(50); err == nil {
+		t.Error("Expected an upload past the quota to be refused")
+	}
+	if err := q.reserve(40); err != nil {
+		t.Error("Expected an upload within the quota to be allowed, got:", err)
+	}
+	// reservations are replaced by the actual usage on the next scan
+	if err := q.reserve(0); err != nil {
+		t.Fatal(err)
+	}
+	if usage := q.usage(); usage.Used != 60 {
+		t.Errorf("Expected the next reservation to rescan usage, got: %+v", usage)
+	}
+}
+
+func TestQuotaDisabled(t *testing.T) {
+	q := newTestQuotaWatcher(t, 0)
+	writeTestFile(t, filepath.Join(q.path, "a"), 60)
+	if q.enabled() {
+		t.Error("Expected a zero limit to disable the quota")
+	}
+	if err := q.reserve(1 << 30); err != nil {
+		t.Error("Expected any upload to be allowed without a quota, got:", err)
+	}
+	if usage := q.usage(); usage.Used != 0 || usage.Warning || usage.Exceeded {
+		t.Errorf("Expected no usage to be tracked without a quota, got: %+v", usage)
+	}
+}
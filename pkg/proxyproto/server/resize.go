@@ -0,0 +1,114 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+)
+
+// resizeTimeout is the maximum amount of time to wait for xrandr to resize the display.
+var resizeTimeout = time.Second * 10
+
+// resizeMux serializes resize requests so concurrent clients can't interleave xrandr
+// invocations.
+var resizeMux sync.Mutex
+
+// validateResize returns an error if the given resize request is not allowed for the
+// display served by the proxy.
+func (p *Server) validateResize(req *proxyproto.ResizeRequest) error {
+	if p.opts.ResizeDisplay == "" {
+		return fmt.Errorf("Resizing the display is not enabled for this desktop")
+	}
+	if p.monitors.count() > 1 {
+		return fmt.Errorf("The display cannot be resized while it spans multiple monitors")
+	}
+	if req.Width <= 0 || req.Height <= 0 {
+		return fmt.Errorf("The display must have a width and height greater than zero")
+	}
+	if req.Width > p.opts.MaxMonitorWidth || req.Height > p.opts.MaxMonitorHeight {
+		return fmt.Errorf("The display may not exceed a resolution of %dx%d", p.opts.MaxMonitorWidth, p.opts.MaxMonitorHeight)
+	}
+	if req.DPI != 0 && (req.DPI < v1.MinDisplayDPI || req.DPI > v1.MaxDisplayDPI) {
+		return fmt.Errorf("The DPI must be between %d and %d", v1.MinDisplayDPI, v1.MaxDisplayDPI)
+	}
+	return nil
+}
+
+// resizeArgs returns the arguments to xrandr for the given resize request.
+func resizeArgs(req *proxyproto.ResizeRequest) []string {
+	args := []string{"--fb", fmt.Sprintf("%dx%d", req.Width, req.Height)}
+	if req.DPI != 0 {
+		args = append(args, "--dpi", strconv.FormatInt(req.DPI, 10))
+	}
+	return args
+}
+
+// resizeDisplay changes the resolution and DPI of the X display with xrandr. The display
+// server notifies connected VNC clients of the new size.
+func (p *Server) resizeDisplay(req *proxyproto.ResizeRequest) error {
+	resizeMux.Lock()
+	defer resizeMux.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), resizeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "xrandr", resizeArgs(req)...)
+	cmd.Env = append(os.Environ(), "DISPLAY="+p.opts.ResizeDisplay)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("xrandr failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (p *Server) handleResize(conn *proxyproto.Conn) {
+	defer conn.Close()
+
+	req := &proxyproto.ResizeRequest{}
+	if err := conn.ReadStructure(req); err != nil {
+		p.log.Error(err, "Could not read resize request from client")
+		conn.WriteError(err)
+		return
+	}
+	p.log.Info(req.String())
+
+	if err := p.validateResize(req); err != nil {
+		conn.WriteError(err)
+		return
+	}
+	if err := p.resizeDisplay(req); err != nil {
+		p.log.Error(err, "Failed to resize display")
+		conn.WriteError(err)
+		return
+	}
+
+	if err := conn.WriteStatus(proxyproto.RequestOK); err != nil {
+		p.log.Error(err, "Error writing OK to connection")
+	}
+}
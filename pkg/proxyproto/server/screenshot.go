@@ -0,0 +1,107 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+)
+
+// screenshotTimeout is the maximum amount of time to spend capturing a single frame.
+var screenshotTimeout = time.Second * 10
+
+// captureDisplay opens a shared connection to the VNC display server, requests a single
+// full frame using raw encoding, and returns it as a PNG scaled down to the given maximum
+// width.
+func (p *Server) captureDisplay(maxWidth int) ([]byte, error) {
+	if !p.displayIsVNC() {
+		return nil, fmt.Errorf("Screenshots are not supported for %s displays", p.opts.DisplayProtocol)
+	}
+	displayConn, err := net.DialTimeout(p.opts.DisplayProto, p.opts.DisplayAddress, screenshotTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer displayConn.Close()
+	if err := displayConn.SetDeadline(time.Now().Add(screenshotTimeout)); err != nil {
+		return nil, err
+	}
+
+	rw := bufio.NewReadWriter(bufio.NewReader(displayConn), bufio.NewWriter(displayConn))
+
+	init, err := rfbHandshake(rw, p.opts.DisplayPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := rfb.RequestRawFrame(rw, init.Width, init.Height); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, int(init.Width), int(init.Height)))
+	if err := rfb.NewServerReader(rw).ReadFrame(img); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, scaleImage(img, maxWidth)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// rfbHandshake negotiates the protocol version and security with the display server
+// and returns its ServerInit. The display password is used if the server requires
+// authentication.
+func rfbHandshake(rw *bufio.ReadWriter, password string) (*rfb.ServerInit, error) {
+	if err := rfb.Authenticate(rw, password); err != nil {
+		return nil, err
+	}
+	return rfb.ClientInit(rw)
+}
+
+// scaleImage scales the given image down to the given width using nearest neighbor
+// sampling. The image is returned as is if it is already small enough.
+func scaleImage(img *image.RGBA, maxWidth int) image.Image {
+	bounds := img.Bounds()
+	if maxWidth <= 0 || bounds.Dx() <= maxWidth {
+		return img
+	}
+	height := bounds.Dy() * maxWidth / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, maxWidth, height))
+	for y := 0; y < height; y++ {
+		srcY := y * bounds.Dy() / height
+		for x := 0; x < maxWidth; x++ {
+			srcX := x * bounds.Dx() / maxWidth
+			copy(scaled.Pix[scaled.PixOffset(x, y):scaled.PixOffset(x, y)+4], img.Pix[img.PixOffset(srcX, srcY):img.PixOffset(srcX, srcY)+4])
+		}
+	}
+	return scaled
+}
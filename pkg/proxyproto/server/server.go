@@ -27,43 +27,80 @@ import (
 	"crypto/tls"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/go-logr/logr"
 
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/proxyproto"
+	"github.com/tinyzimmer/kvdi/pkg/proxyproto/tunnel"
+	"github.com/tinyzimmer/kvdi/pkg/util/guac"
 	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
+
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // Server is a structure used by the kvdi-proxy for accepting connections from
 // the kvdi-app instances.
 type Server struct {
-	host string
-	port int32
-	opts *ProxyOpts
-	log  logr.Logger
+	host       string
+	port       int32
+	opts       *ProxyOpts
+	log        logr.Logger
+	quota      *quotaWatcher
+	control    *displayControl
+	thumbnails *thumbnailer
+	idle       *idleTracker
+	monitors   *monitorLayout
 }
 
 // ProxyOpts are additional options for configuring the proxy server.
 type ProxyOpts struct {
 	FSUserID                                           int
 	DisplayAddress, DisplayProto                       string
+	DisplayPassword                                    string
+	DisplayProtocol                                    string
+	RDP                                                *guac.ConnectOptions
 	PulseServer                                        string
-	PlaybackSampleRate                                 int
+	AudioDisabled, MicrophoneDisabled                  bool
+	PlaybackSampleRate, PlaybackBitrate                int
 	PlaybackDeviceName, PlaybackDeviceDescription      string
 	RecordingDeviceName, RecordingDeviceDescription    string
 	RecordingDevicePath, RecordingDeviceFormat         string
 	RecordingDeviceSampleRate, RecordingDeviceChannels int
+	HomeQuota                                          int64
+	HomeQuotaWarningThreshold                          int
+	SSHAddress                                         string
+	IDEAddress                                         string
+	ThumbnailInterval                                  time.Duration
+	ThumbnailMaxWidth                                  int
+	MaxMonitors                                        int
+	MaxMonitorWidth, MaxMonitorHeight                  int64
+	Plugins                                            []string
+	ResizeDisplay                                      string
 }
 
 // New returns a new proxy server configured to listen on the given host and
 // port.
 func New(logger logr.Logger, host string, port int32, opts *ProxyOpts) *Server {
-	return &Server{
-		host: host,
-		port: port,
-		opts: opts,
-		log:  logger,
+	p := &Server{
+		host:     host,
+		port:     port,
+		opts:     opts,
+		log:      logger,
+		quota:    newQuotaWatcher(logger, opts.HomeQuota, opts.HomeQuotaWarningThreshold),
+		control:  newDisplayControl(logger),
+		idle:     newIdleTracker(),
+		monitors: newMonitorLayout(opts.MaxMonitors, opts.MaxMonitorWidth, opts.MaxMonitorHeight),
 	}
+	p.thumbnails = newThumbnailer(logger, opts.ThumbnailInterval, opts.ThumbnailMaxWidth, p.captureDisplay)
+	return p
+}
+
+// displayIsVNC returns true if the display server speaks VNC. Authenticating on behalf
+// of clients and capturing screenshots are only supported for VNC displays.
+func (p *Server) displayIsVNC() bool {
+	return p.opts.DisplayProtocol == "" || p.opts.DisplayProtocol == v1.DefaultDisplayProtocol
 }
 
 // ListenAndServe listens and accepts incoming client connections and feeds them to
@@ -73,6 +110,12 @@ func (p *Server) ListenAndServe() error {
 	if err != nil {
 		return err
 	}
+	if p.quota.enabled() {
+		go p.quota.run()
+	}
+	if p.thumbnails.enabled() {
+		go p.thumbnails.run()
+	}
 	addr := net.JoinHostPort(p.host, strconv.Itoa(int(p.port)))
 	p.log.Info("Listening for new mTLS TCP connections", "Address", addr)
 	l, err := tls.Listen("tcp", addr, tlsConfig)
@@ -89,6 +132,18 @@ func (p *Server) ListenAndServe() error {
 	}
 }
 
+// ServeTunnel keeps a pool of reverse tunnels open to the app at the given address on
+// behalf of the given session, and serves requests received over them. It blocks forever
+// and is meant to be run alongside ListenAndServe.
+func (p *Server) ServeTunnel(addr string, session types.NamespacedName, poolSize int) error {
+	tlsConfig, err := tlsutil.NewTunnelClientTLSConfig()
+	if err != nil {
+		return err
+	}
+	tunnel.NewAgent(p.log.WithName("tunnel"), addr, session, tlsConfig, poolSize, p.handleConn).Run()
+	return nil
+}
+
 // Handler is a function for handling a connection server side.
 type Handler func(*proxyproto.Conn)
 
@@ -105,6 +160,30 @@ func (p *Server) handler(rt proxyproto.RequestType) Handler {
 		return p.handleGet
 	case proxyproto.RequestTypeFPut:
 		return p.handlePut
+	case proxyproto.RequestTypeFUsage:
+		return p.handleUsage
+	case proxyproto.RequestTypeSSH:
+		return p.handleSSH
+	case proxyproto.RequestTypeControl:
+		return p.handleControl
+	case proxyproto.RequestTypeScreenshot:
+		return p.handleScreenshot
+	case proxyproto.RequestTypeThumbnail:
+		return p.handleThumbnail
+	case proxyproto.RequestTypeClipboard:
+		return p.handleClipboard
+	case proxyproto.RequestTypeIdle:
+		return p.handleIdle
+	case proxyproto.RequestTypeMonitors:
+		return p.handleMonitors
+	case proxyproto.RequestTypePlugin:
+		return p.handlePlugin
+	case proxyproto.RequestTypeResize:
+		return p.handleResize
+	case proxyproto.RequestTypeIDE:
+		return p.handleIDE
+	case proxyproto.RequestTypeRestrictedAudio:
+		return p.handleRestrictedAudio
 	}
 	return nil
 }
@@ -0,0 +1,91 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// thumbnailer periodically captures low-resolution images of the display and keeps
+// the most recent one for serving to clients.
+type thumbnailer struct {
+	log        logr.Logger
+	interval   time.Duration
+	maxWidth   int
+	capture    func(maxWidth int) ([]byte, error)
+	img        []byte
+	capturedAt time.Time
+	mux        sync.RWMutex
+}
+
+func newThumbnailer(logger logr.Logger, interval time.Duration, maxWidth int, capture func(int) ([]byte, error)) *thumbnailer {
+	return &thumbnailer{
+		log:      logger.WithName("thumbnails"),
+		interval: interval,
+		maxWidth: maxWidth,
+		capture:  capture,
+	}
+}
+
+// enabled returns true if thumbnails are being captured.
+func (t *thumbnailer) enabled() bool { return t.interval > 0 }
+
+// run captures a new thumbnail on every interval.
+func (t *thumbnailer) run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		t.refresh()
+		<-ticker.C
+	}
+}
+
+// refresh captures a new thumbnail. Failures are logged and the previous thumbnail is
+// kept, since the display server may not be up yet.
+func (t *thumbnailer) refresh() {
+	img, err := t.capture(t.maxWidth)
+	if err != nil {
+		t.log.Error(err, "Failed to capture thumbnail")
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.img = img
+	t.capturedAt = time.Now()
+}
+
+// latest returns the most recent thumbnail and the time it was captured.
+func (t *thumbnailer) latest() ([]byte, time.Time, error) {
+	if !t.enabled() {
+		return nil, time.Time{}, errors.New("Thumbnails are not enabled for this desktop")
+	}
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.img == nil {
+		return nil, time.Time{}, errors.New("No thumbnail has been captured yet")
+	}
+	return t.img, t.capturedAt, nil
+}
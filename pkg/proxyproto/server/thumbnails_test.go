@@ -0,0 +1,74 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThumbnailer(t *testing.T) {
+	if _, _, err := newThumbnailer(testLogger, 0, 320, nil).latest(); err == nil {
+		t.Error("Expected an error when thumbnails are disabled")
+	}
+
+	var captureErr error
+	var widths []int
+	thumbs := newThumbnailer(testLogger, time.Minute, 320, func(maxWidth int) ([]byte, error) {
+		widths = append(widths, maxWidth)
+		if captureErr != nil {
+			return nil, captureErr
+		}
+		return []byte("frame"), nil
+	})
+	if !thumbs.enabled() {
+		t.Fatal("Expected thumbnails to be enabled")
+	}
+	if _, _, err := thumbs.latest(); err == nil {
+		t.Error("Expected an error before the first capture")
+	}
+
+	thumbs.refresh()
+	img, capturedAt, err := thumbs.latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img, []byte("frame")) || capturedAt.IsZero() {
+		t.Error("Expected the captured frame and its time, got:", string(img), capturedAt)
+	}
+	if len(widths) != 1 || widths[0] != 320 {
+		t.Error("Expected a capture at the configured width, got:", widths)
+	}
+
+	// a failed capture keeps the previous thumbnail
+	captureErr = errors.New("display not ready")
+	thumbs.refresh()
+	img, kept, err := thumbs.latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img, []byte("frame")) || !kept.Equal(capturedAt) {
+		t.Error("Expected the previous thumbnail to be kept, got:", string(img), kept)
+	}
+}
@@ -51,15 +51,21 @@ func getLocalPathFromRequest(path string) (string, error) {
 func (p *Server) logConnectionMetrics(proxyType string, conn *proxyproto.Conn) chan struct{} {
 	st := make(chan struct{})
 	logger := p.log.WithValues("Connection", proxyType)
+	counter := newStreamCounter(proxyType, conn)
+	activeStreams.With(counter.labels).Inc()
 	go func() {
 		ticker := time.NewTicker(time.Second * 10)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-st:
 				logger.Info("Connection is closing")
+				counter.flush()
+				activeStreams.With(counter.labels).Dec()
 				return
 			case <-ticker.C:
 				logger.Info("Connection is alive", "BytesSent", conn.BytesSentCount(), "BytesReceived", conn.BytesRecvdCount())
+				counter.flush()
 			}
 		}
 	}()
@@ -0,0 +1,156 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tunnel
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxBackoff is the longest the agent waits between failed attempts to reach the app.
+const maxBackoff = time.Minute
+
+// Agent is used by the kvdi-proxy to keep a pool of reverse tunnel connections open to
+// the app.
+type Agent struct {
+	log       logr.Logger
+	addr      string
+	session   types.NamespacedName
+	tlsConfig *tls.Config
+	poolSize  int
+	handler   func(net.Conn)
+}
+
+// NewAgent returns a new agent that dials the app at the given address on behalf of the
+// given session. Activated connections are passed to the handler.
+func NewAgent(logger logr.Logger, addr string, session types.NamespacedName, tlsConfig *tls.Config, poolSize int, handler func(net.Conn)) *Agent {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	return &Agent{
+		log:       logger,
+		addr:      addr,
+		session:   session,
+		tlsConfig: tlsConfig,
+		poolSize:  poolSize,
+		handler:   handler,
+	}
+}
+
+// Run keeps the pool of tunnel connections full. It blocks forever.
+func (a *Agent) Run() {
+	a.log.Info("Opening reverse tunnels", "Address", a.addr, "Session", a.session.String(), "PoolSize", a.poolSize)
+	var wg sync.WaitGroup
+	for i := 0; i < a.poolSize; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.worker()
+		}()
+	}
+	wg.Wait()
+}
+
+// worker keeps a single idle connection open, replacing it every time it is taken into
+// use or lost.
+func (a *Agent) worker() {
+	backoff := time.Second
+	for {
+		c, err := a.connect()
+		if err != nil {
+			a.log.Error(err, "Failed to open reverse tunnel", "Retry", backoff.String())
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+		if err := waitForActivation(c); err != nil {
+			a.log.Info("Reverse tunnel closed", "Reason", err.Error())
+			c.Close()
+			continue
+		}
+		go a.handler(c)
+	}
+}
+
+// connect dials the app and completes the hello for the session.
+func (a *Agent) connect() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: handshakeTimeout, KeepAlive: HeartbeatInterval}
+	c, err := tls.DialWithDialer(dialer, "tcp", a.addr, a.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := writeHello(c, a.session); err != nil {
+		c.Close()
+		return nil, err
+	}
+	status := make([]byte, 1)
+	if _, err := io.ReadFull(c, status); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if status[0] != helloOK {
+		c.Close()
+		return nil, errors.New("The app rejected the tunnel for this session")
+	}
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// waitForActivation reads heartbeats off a parked connection until the app activates it.
+// The connection is considered lost if heartbeats stop arriving.
+func waitForActivation(c net.Conn) error {
+	frame := make([]byte, 1)
+	for {
+		if err := c.SetReadDeadline(time.Now().Add(3 * HeartbeatInterval)); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c, frame); err != nil {
+			return err
+		}
+		switch frame[0] {
+		case frameHeartbeat:
+			continue
+		case frameActivate:
+			return c.SetReadDeadline(time.Time{})
+		default:
+			return fmt.Errorf("Unexpected frame %d on parked tunnel", frame[0])
+		}
+	}
+}
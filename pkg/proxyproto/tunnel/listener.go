@@ -0,0 +1,294 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tunnel
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Listener is used by the kvdi-app to accept reverse tunnels from desktop proxies and
+// hand out connections to them.
+type Listener struct {
+	log      logr.Logger
+	registry Registry
+	relayTLS *tls.Config
+	mu       sync.Mutex
+	pools    map[types.NamespacedName][]*parkedConn
+}
+
+// parkedConn is an idle tunnel connection waiting to be taken into use.
+type parkedConn struct {
+	net.Conn
+	mu    sync.Mutex
+	taken bool
+}
+
+// NewListener returns a new tunnel listener. When a registry is given, the sessions with
+// tunnels parked on this listener are registered with it, and connections for sessions
+// parked on other replicas of the app are relayed through them using the relay TLS
+// configuration.
+func NewListener(logger logr.Logger, registry Registry, relayTLSConfig *tls.Config) *Listener {
+	return &Listener{
+		log:      logger,
+		registry: registry,
+		relayTLS: relayTLSConfig,
+		pools:    make(map[types.NamespacedName][]*parkedConn),
+	}
+}
+
+// ListenAndServe listens on the given address and accepts tunnels from desktop proxies.
+// The TLS configuration must require and verify client certificates.
+func (l *Listener) ListenAndServe(addr string, tlsConfig *tls.Config) error {
+	ln, err := tls.Listen("tcp", addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	l.log.Info("Listening for reverse tunnels", "Address", addr)
+	if l.registry != nil {
+		go l.renewRegistrations()
+	}
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			l.log.Error(err, "Error accepting new tunnel connection")
+			continue
+		}
+		go l.handleConn(c)
+	}
+}
+
+// Connected returns true if there are idle tunnel connections for the given session,
+// either on this listener or on another replica of the app.
+func (l *Listener) Connected(session types.NamespacedName) bool {
+	if l.connectedLocal(session) {
+		return true
+	}
+	if l.registry == nil {
+		return false
+	}
+	addr, err := l.registry.Lookup(session)
+	return err == nil && addr != ""
+}
+
+// Dial takes an idle tunnel connection for the given session into use. The returned
+// connection is already secured and can be used as if it were dialed directly. If the
+// tunnels of the session are parked on another replica of the app, the connection is
+// relayed through it.
+func (l *Listener) Dial(session types.NamespacedName) (net.Conn, error) {
+	c, err := l.dialLocal(session)
+	if err == ErrNoTunnel && l.registry != nil {
+		return l.dialRelay(session)
+	}
+	return c, err
+}
+
+func (l *Listener) connectedLocal(session types.NamespacedName) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pools[session]) > 0
+}
+
+// dialLocal takes an idle tunnel connection parked on this listener into use.
+func (l *Listener) dialLocal(session types.NamespacedName) (net.Conn, error) {
+	for {
+		pc := l.pop(session)
+		if pc == nil {
+			return nil, ErrNoTunnel
+		}
+		if err := pc.activate(); err != nil {
+			l.log.Error(err, "Discarding dead tunnel connection", "Session", session.String())
+			pc.Close()
+			continue
+		}
+		return pc.Conn, nil
+	}
+}
+
+func (l *Listener) handleConn(c net.Conn) {
+	session, err := l.accept(c)
+	if err != nil {
+		l.log.Error(err, "Rejecting tunnel connection", "Client", c.RemoteAddr().String())
+		c.Close()
+		return
+	}
+	pc := &parkedConn{Conn: c}
+	// hold the connection until the hello is answered, so it can't be activated first
+	pc.mu.Lock()
+	if !l.push(session, pc) {
+		pc.mu.Unlock()
+		l.log.Info("Rejecting tunnel connection, too many are parked for the session", "Session", session.String(), "Client", c.RemoteAddr().String())
+		c.Write([]byte{helloRejected})
+		c.Close()
+		return
+	}
+	_, err = c.Write([]byte{helloOK})
+	if err == nil {
+		err = c.SetDeadline(time.Time{})
+	}
+	pc.mu.Unlock()
+	if err != nil {
+		l.log.Error(err, "Failed to accept tunnel connection", "Session", session.String(), "Client", c.RemoteAddr().String())
+		l.remove(session, pc)
+		c.Close()
+		return
+	}
+	l.log.Info("Accepted tunnel connection", "Session", session.String(), "Client", c.RemoteAddr().String())
+	l.heartbeat(session, pc)
+}
+
+// accept completes the handshake on a new tunnel connection and verifies the proxy's
+// certificate was issued for the session it claims to belong to. The hello is answered
+// once the connection is parked.
+func (l *Listener) accept(c net.Conn) (types.NamespacedName, error) {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return types.NamespacedName{}, errors.New("Tunnel connections must use TLS")
+	}
+	if err := tc.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return types.NamespacedName{}, err
+	}
+	if err := tc.Handshake(); err != nil {
+		return types.NamespacedName{}, err
+	}
+	session, err := readHello(tc)
+	if err != nil {
+		return session, err
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return session, errors.New("No client certificate presented")
+	}
+	if err := certs[0].VerifyHostname(fmt.Sprintf("%s.%s", session.Name, session.Namespace)); err != nil {
+		tc.Write([]byte{helloRejected})
+		return session, err
+	}
+	return session, nil
+}
+
+// heartbeat periodically writes to a parked connection until it is taken into use or
+// found to be dead.
+func (l *Listener) heartbeat(session types.NamespacedName, pc *parkedConn) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		alive, err := pc.beat()
+		if alive {
+			continue
+		}
+		if err != nil {
+			l.log.Info("Tunnel connection closed", "Session", session.String(), "Reason", err.Error())
+			l.remove(session, pc)
+			pc.Close()
+		}
+		return
+	}
+}
+
+// push parks a connection for the session. It returns false if the session already has
+// the maximum number of connections parked.
+func (l *Listener) push(session types.NamespacedName, pc *parkedConn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pool := l.pools[session]
+	if len(pool) >= MaxParkedConns {
+		return false
+	}
+	if len(pool) == 0 && l.registry != nil {
+		go l.register(session)
+	}
+	l.pools[session] = append(pool, pc)
+	return true
+}
+
+// pop removes and returns the most recently parked connection for the session.
+func (l *Listener) pop(session types.NamespacedName) *parkedConn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pool := l.pools[session]
+	if len(pool) == 0 {
+		return nil
+	}
+	pc := pool[len(pool)-1]
+	l.setPool(session, pool[:len(pool)-1])
+	return pc
+}
+
+func (l *Listener) remove(session types.NamespacedName, pc *parkedConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pool := l.pools[session]
+	for i, c := range pool {
+		if c == pc {
+			l.setPool(session, append(pool[:i:i], pool[i+1:]...))
+			return
+		}
+	}
+}
+
+// setPool must be called while holding the lock.
+func (l *Listener) setPool(session types.NamespacedName, pool []*parkedConn) {
+	if len(pool) == 0 {
+		delete(l.pools, session)
+		return
+	}
+	l.pools[session] = pool
+}
+
+// beat writes a heartbeat to the connection. It returns false if the connection has
+// been taken into use or the write failed.
+func (pc *parkedConn) beat() (bool, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.taken {
+		return false, nil
+	}
+	if err := pc.writeFrame(frameHeartbeat); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// activate marks the connection as taken and tells the proxy to start serving it.
+func (pc *parkedConn) activate() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.taken = true
+	return pc.writeFrame(frameActivate)
+}
+
+func (pc *parkedConn) writeFrame(frame byte) error {
+	if err := pc.SetWriteDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+	if _, err := pc.Write([]byte{frame}); err != nil {
+		return err
+	}
+	return pc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,170 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tunnel
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Registry records which replica of the app holds tunnel connections for each session.
+type Registry interface {
+	// Register records this replica as holding tunnel connections for the session. It is
+	// called again every HeartbeatInterval while connections remain parked.
+	Register(session types.NamespacedName) error
+	// Lookup returns the relay address of another replica holding tunnel connections for
+	// the session. ErrNoTunnel is returned if there is none.
+	Lookup(session types.NamespacedName) (string, error)
+}
+
+// ServeRelay listens on the given address and relays connections from other replicas of
+// the app to the tunnels parked on this listener. It uses the relay TLS configuration the
+// listener was created with.
+func (l *Listener) ServeRelay(addr string) error {
+	if l.relayTLS == nil {
+		return errors.New("No TLS configuration for relaying tunnels")
+	}
+	ln, err := tls.Listen("tcp", addr, l.relayTLS)
+	if err != nil {
+		return err
+	}
+	l.log.Info("Listening for relayed tunnel connections", "Address", addr)
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			l.log.Error(err, "Error accepting relayed tunnel connection")
+			continue
+		}
+		go l.handleRelay(c)
+	}
+}
+
+// handleRelay reads the session a relayed connection is for and splices it with one of
+// the connections parked for the session.
+func (l *Listener) handleRelay(c net.Conn) {
+	defer c.Close()
+	if err := c.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return
+	}
+	session, err := readHello(c)
+	if err != nil {
+		l.log.Error(err, "Rejecting relayed tunnel connection", "Client", c.RemoteAddr().String())
+		return
+	}
+	tc, err := l.dialLocal(session)
+	if err != nil {
+		c.Write([]byte{helloRejected})
+		return
+	}
+	defer tc.Close()
+	if _, err := c.Write([]byte{helloOK}); err != nil {
+		return
+	}
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+	l.log.Info("Relaying tunnel connection", "Session", session.String(), "Client", c.RemoteAddr().String())
+	splice(c, tc)
+}
+
+// dialRelay dials the replica holding tunnel connections for the session, and returns a
+// connection relayed through it.
+func (l *Listener) dialRelay(session types.NamespacedName) (net.Conn, error) {
+	if l.relayTLS == nil {
+		return nil, ErrNoTunnel
+	}
+	addr, err := l.registry.Lookup(session)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: handshakeTimeout}
+	c, err := tls.DialWithDialer(dialer, "tcp", addr, l.relayTLS)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := writeHello(c, session); err != nil {
+		c.Close()
+		return nil, err
+	}
+	status := make([]byte, 1)
+	if _, err := io.ReadFull(c, status); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if status[0] != helloOK {
+		c.Close()
+		return nil, ErrNoTunnel
+	}
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (l *Listener) register(session types.NamespacedName) {
+	if err := l.registry.Register(session); err != nil {
+		l.log.Error(err, "Failed to register tunnel connections", "Session", session.String())
+	}
+}
+
+// renewRegistrations periodically registers all sessions with parked connections.
+func (l *Listener) renewRegistrations() {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mu.Lock()
+		sessions := make([]types.NamespacedName, 0, len(l.pools))
+		for session := range l.pools {
+			sessions = append(sessions, session)
+		}
+		l.mu.Unlock()
+		for _, session := range sessions {
+			l.register(session)
+		}
+	}
+}
+
+// splice copies data between two connections until either side is done.
+func splice(a, b net.Conn) {
+	var once sync.Once
+	done := make(chan struct{})
+	cp := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		once.Do(func() { close(done) })
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	a.Close()
+	b.Close()
+}
@@ -0,0 +1,111 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package tunnel contains a reverse tunnel implementation for reaching desktop proxies
+// that the app cannot dial directly.
+//
+// Instead of multiplexing streams over a single connection, the proxy keeps a small pool
+// of idle mTLS connections open to the app. Each connection starts with a hello naming the
+// desktop session it belongs to, after which the app parks it and sends periodic heartbeats.
+// When the app needs to reach the desktop it takes a parked connection and sends an
+// activation byte. From that point on the connection is treated by the proxy exactly like
+// one it accepted itself, and the proxy dials a replacement to keep the pool full.
+//
+// When the app runs more than one replica, the connections of a session may be parked on
+// any of them. Each replica records the sessions it holds connections for in a Registry,
+// and replicas without a parked connection relay through one that has.
+package tunnel
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// frameHeartbeat is sent by the app on parked connections to keep them alive.
+	frameHeartbeat byte = iota
+	// frameActivate is sent by the app when it takes a parked connection into use.
+	frameActivate
+)
+
+const (
+	// helloOK is sent by the app when it accepts a tunnel.
+	helloOK byte = iota + 1
+	// helloRejected is sent by the app when it refuses a tunnel.
+	helloRejected
+)
+
+const (
+	// HeartbeatInterval is how often the app sends heartbeats on parked connections.
+	HeartbeatInterval = 15 * time.Second
+	// handshakeTimeout is how long either side waits on the TLS handshake and hello.
+	handshakeTimeout = 10 * time.Second
+	// maxHelloSize is the maximum size of a hello accepted by the app.
+	maxHelloSize = 512
+	// MaxParkedConns is the maximum number of idle connections a replica of the app parks
+	// for a single session. Connections beyond it are rejected.
+	MaxParkedConns = 16
+)
+
+// ErrNoTunnel is returned when there are no idle tunnel connections for a desktop session.
+var ErrNoTunnel = errors.New("No reverse tunnel is connected for the desktop session")
+
+// writeHello writes the name of the session a tunnel connection belongs to.
+func writeHello(w io.Writer, session types.NamespacedName) error {
+	hello := []byte(session.String())
+	buf := make([]byte, 2+len(hello))
+	binary.BigEndian.PutUint16(buf, uint16(len(hello)))
+	copy(buf[2:], hello)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readHello reads the name of the session a tunnel connection belongs to.
+func readHello(r io.Reader) (types.NamespacedName, error) {
+	size := make([]byte, 2)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return types.NamespacedName{}, err
+	}
+	length := binary.BigEndian.Uint16(size)
+	if length == 0 || length > maxHelloSize {
+		return types.NamespacedName{}, fmt.Errorf("Invalid hello size %d", length)
+	}
+	hello := make([]byte, length)
+	if _, err := io.ReadFull(r, hello); err != nil {
+		return types.NamespacedName{}, err
+	}
+	return ParseSession(string(hello))
+}
+
+// ParseSession parses a session name in the format of `namespace/name`.
+func ParseSession(s string) (types.NamespacedName, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("%q is not in the format of namespace/name", s)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
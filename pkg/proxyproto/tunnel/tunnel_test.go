@@ -0,0 +1,329 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tunnel
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+var testSession = types.NamespacedName{Name: "test-session", Namespace: "default"}
+
+// testPKI is a CA along with the certificates used by the app and a desktop proxy.
+type testPKI struct {
+	pool  *x509.CertPool
+	app   tls.Certificate
+	proxy tls.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(serial int64, dnsNames []string, ips []net.IP) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: dnsNames[0]},
+			DNSNames:     dnsNames,
+			IPAddresses:  ips,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return &testPKI{
+		pool:  pool,
+		app:   issue(2, []string{"kvdi-app"}, []net.IP{net.IPv4(127, 0, 0, 1)}),
+		proxy: issue(3, []string{"test-session.default"}, nil),
+	}
+}
+
+func (p *testPKI) serverConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{p.app},
+		ClientCAs:    p.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}
+}
+
+func (p *testPKI) agentConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{p.proxy},
+		RootCAs:      p.pool,
+		MinVersion:   tls.VersionTLS13,
+	}
+}
+
+func (p *testPKI) relayConfig() *tls.Config {
+	return &tls.Config{
+		Certificates:       []tls.Certificate{p.app},
+		ClientAuth:         tls.RequireAnyClientCert,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+	}
+}
+
+// freeAddr returns a local address that is free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// waitFor polls the condition until it is true or a timeout is reached.
+func waitFor(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startListener starts a listener accepting tunnels, and relayed connections when it has
+// a registry, on free local addresses.
+func startListener(t *testing.T, pki *testPKI, registry Registry) (l *Listener, addr, relayAddr string) {
+	t.Helper()
+	l = NewListener(testLogger, registry, pki.relayConfig())
+	addr = freeAddr(t)
+	go l.ListenAndServe(addr, pki.serverConfig())
+	if registry != nil {
+		relayAddr = freeAddr(t)
+		go l.ServeRelay(relayAddr)
+	}
+	for _, a := range []string{addr, relayAddr} {
+		if a == "" {
+			continue
+		}
+		waitFor(t, "the listener to start", func() bool {
+			c, err := net.Dial("tcp", a)
+			if err != nil {
+				return false
+			}
+			c.Close()
+			return true
+		})
+	}
+	return l, addr, relayAddr
+}
+
+// echo serves activated tunnel connections by writing back everything read from them.
+func echo(c net.Conn) {
+	defer c.Close()
+	io.Copy(c, c)
+}
+
+func parked(l *Listener, session types.NamespacedName) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pools[session])
+}
+
+func assertEcho(t *testing.T, c net.Conn) {
+	t.Helper()
+	defer c.Close()
+	msg := []byte("hello desktop")
+	if _, err := c.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, msg) {
+		t.Errorf("Expected %q back over the tunnel, got: %q", msg, buf)
+	}
+}
+
+// TestTunnel tests that parked connections are handed out to the app and served by the
+// proxy.
+func TestTunnel(t *testing.T) {
+	pki := newTestPKI(t)
+	l, addr, _ := startListener(t, pki, nil)
+
+	if l.Connected(testSession) {
+		t.Error("Expected no tunnels before the agent connects")
+	}
+	if _, err := l.Dial(testSession); err != ErrNoTunnel {
+		t.Error("Expected ErrNoTunnel, got:", err)
+	}
+
+	agent := NewAgent(testLogger, addr, testSession, pki.agentConfig(), 2, echo)
+	go agent.Run()
+	waitFor(t, "the pool to fill", func() bool { return parked(l, testSession) == 2 })
+
+	c, err := l.Dial(testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEcho(t, c)
+
+	// the agent replaces the connection that was taken into use
+	waitFor(t, "the pool to refill", func() bool { return parked(l, testSession) == 2 })
+
+	// proxies may only open tunnels for the session their certificate was issued for
+	other := NewAgent(testLogger, addr, types.NamespacedName{Name: "other-session", Namespace: "default"}, pki.agentConfig(), 1, echo)
+	if c, err := other.connect(); err == nil {
+		c.Close()
+		t.Error("Expected the tunnel for another session to be rejected")
+	}
+}
+
+// TestMaxParkedConns tests that the number of connections parked for a session is
+// bounded.
+func TestMaxParkedConns(t *testing.T) {
+	pki := newTestPKI(t)
+	l, addr, _ := startListener(t, pki, nil)
+
+	agent := NewAgent(testLogger, addr, testSession, pki.agentConfig(), 1, echo)
+	for i := 0; i < MaxParkedConns; i++ {
+		c, err := agent.connect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	if c, err := agent.connect(); err == nil {
+		c.Close()
+		t.Error("Expected connections beyond the maximum to be rejected")
+	}
+	if n := parked(l, testSession); n != MaxParkedConns {
+		t.Errorf("Expected %d parked connections, got: %d", MaxParkedConns, n)
+	}
+}
+
+// testOwners records the relay address of the listener holding each session.
+type testOwners struct {
+	mu     sync.Mutex
+	owners map[types.NamespacedName]string
+}
+
+// testRegistry is an in-memory Registry for one of the listeners of a test.
+type testRegistry struct {
+	self   string
+	owners *testOwners
+}
+
+func (r *testRegistry) Register(session types.NamespacedName) error {
+	r.owners.mu.Lock()
+	defer r.owners.mu.Unlock()
+	r.owners.owners[session] = r.self
+	return nil
+}
+
+func (r *testRegistry) Lookup(session types.NamespacedName) (string, error) {
+	r.owners.mu.Lock()
+	defer r.owners.mu.Unlock()
+	addr, ok := r.owners.owners[session]
+	if !ok || addr == r.self {
+		return "", ErrNoTunnel
+	}
+	return addr, nil
+}
+
+// TestRelay tests that a replica without parked connections for a session relays through
+// the replica that has them.
+func TestRelay(t *testing.T) {
+	pki := newTestPKI(t)
+	owners := &testOwners{owners: make(map[types.NamespacedName]string)}
+	registryA := &testRegistry{owners: owners}
+	registryB := &testRegistry{owners: owners}
+
+	a, _, relayA := startListener(t, pki, registryA)
+	b, addrB, relayB := startListener(t, pki, registryB)
+	registryA.self, registryB.self = relayA, relayB
+
+	agent := NewAgent(testLogger, addrB, testSession, pki.agentConfig(), 1, echo)
+	go agent.Run()
+	waitFor(t, "the tunnel to be registered", func() bool { return a.Connected(testSession) })
+	if parked(a, testSession) != 0 {
+		t.Fatal("Expected no connections parked on the first replica")
+	}
+
+	c, err := a.Dial(testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEcho(t, c)
+
+	// the replica holding the tunnel does not relay to itself
+	if _, err := b.dialRelay(types.NamespacedName{Name: "other-session", Namespace: "default"}); err != ErrNoTunnel {
+		t.Error("Expected ErrNoTunnel for a session without tunnels, got:", err)
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package recordings records the displays of desktop sessions to object storage. Any
+// store speaking the S3 API may be used, such as Amazon S3, MinIO, or Google Cloud
+// Storage with HMAC keys. Recordings are made of frames of the display captured at a
+// fixed interval along with the connection and input activity of clients, and are written
+// in gzipped segments of JSON lines so they can be streamed back for playback.
+package recordings
@@ -0,0 +1,247 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package recordings
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// segmentDuration is roughly how much of a recording is buffered in memory before it is
+// written to object storage as a segment.
+const segmentDuration = time.Minute
+
+// Recorder records the display of a single desktop session. Frames are captured on an
+// interval until Stop is called.
+type Recorder struct {
+	mu sync.Mutex
+	// the store and key prefix the recording is written under
+	store  *store
+	prefix string
+	// the recording's metadata
+	rec *types.Recording
+	// captures a frame of the display no wider than maxWidth
+	capture  func(maxWidth int) ([]byte, error)
+	interval time.Duration
+	maxWidth int
+	// the number of frames written to each segment
+	segmentFrames int
+	// the events of the current segment
+	buf *bytes.Buffer
+	gz  *gzip.Writer
+	enc *json.Encoder
+	// the frames in the current segment
+	pending int
+	// the clients connected to the display
+	clients map[int]*recordedClient
+	nextID  int
+	stop    chan struct{}
+	done    chan struct{}
+	now     func() time.Time
+}
+
+// recordedClient is a client connected to the display of a recorded session.
+type recordedClient struct {
+	user, clientAddr string
+	// returns how long it has been since the client sent input
+	idle func() time.Duration
+}
+
+func newRecorder(s *store, prefix string, rec *types.Recording, capture func(int) ([]byte, error), interval time.Duration, maxWidth int) *Recorder {
+	segmentFrames := int(segmentDuration / interval)
+	if segmentFrames < 1 {
+		segmentFrames = 1
+	}
+	r := &Recorder{
+		store:         s,
+		prefix:        prefix,
+		rec:           rec,
+		capture:       capture,
+		interval:      interval,
+		maxWidth:      maxWidth,
+		segmentFrames: segmentFrames,
+		clients:       make(map[int]*recordedClient),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		now:           time.Now,
+	}
+	r.resetSegment()
+	return r
+}
+
+// Recording returns a copy of the metadata of the recording.
+func (r *Recorder) Recording() types.Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.rec
+}
+
+// Attach records a client connecting to the display. On every frame the client is
+// recorded as having sent input if idle reports less than the frame interval. The returned
+// function records the client disconnecting.
+func (r *Recorder) Attach(user, clientAddr string, idle func() time.Duration) (detach func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.clients[id] = &recordedClient{user: user, clientAddr: clientAddr, idle: idle}
+	r.writeLocked(&types.RecordingEvent{Type: types.RecordingEventConnect, User: user, ClientAddr: clientAddr})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			delete(r.clients, id)
+			r.writeLocked(&types.RecordingEvent{Type: types.RecordingEventDisconnect, User: user, ClientAddr: clientAddr})
+		})
+	}
+}
+
+// start writes the metadata of the recording and begins capturing frames.
+func (r *Recorder) start(ctx context.Context) error {
+	if err := r.writeMetadata(ctx); err != nil {
+		return err
+	}
+	go r.run()
+	return nil
+}
+
+// Stop stops capturing frames and writes the rest of the recording to object storage.
+func (r *Recorder) Stop(ctx context.Context) error {
+	close(r.stop)
+	<-r.done
+	r.mu.Lock()
+	ended := r.now().UTC()
+	r.rec.EndedAt = &ended
+	r.mu.Unlock()
+	return r.flush(ctx)
+}
+
+// run captures a frame on every interval until the recorder is stopped, writing a segment
+// each time enough frames are buffered.
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.captureFrame()
+		if r.segmentFull() {
+			if err := r.flush(context.Background()); err != nil {
+				recordingsLogger.Error(err, "Failed to write recording segment", "Recording", r.rec.ID)
+			}
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// captureFrame captures a frame of the display and records the clients that sent input
+// since the previous one. Failures are logged and the frame is skipped, since the display
+// server may not be up yet.
+func (r *Recorder) captureFrame() {
+	img, err := r.capture(r.maxWidth)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		recordingsLogger.Error(err, "Failed to capture recording frame", "Recording", r.rec.ID)
+	} else {
+		r.writeLocked(&types.RecordingEvent{Type: types.RecordingEventFrame, Image: img})
+		r.rec.Frames++
+		r.pending++
+	}
+	for _, client := range r.clients {
+		if client.idle() < r.interval {
+			r.writeLocked(&types.RecordingEvent{Type: types.RecordingEventInput, User: client.user, ClientAddr: client.clientAddr})
+		}
+	}
+}
+
+func (r *Recorder) segmentFull() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pending >= r.segmentFrames
+}
+
+// writeLocked adds an event to the current segment. The lock must be held.
+func (r *Recorder) writeLocked(ev *types.RecordingEvent) {
+	ev.Offset = r.now().Sub(r.rec.StartedAt).Milliseconds()
+	if err := r.enc.Encode(ev); err != nil {
+		recordingsLogger.Error(err, "Failed to buffer recording event", "Recording", r.rec.ID)
+	}
+}
+
+func (r *Recorder) resetSegment() {
+	r.buf = new(bytes.Buffer)
+	r.gz = gzip.NewWriter(r.buf)
+	r.enc = json.NewEncoder(r.gz)
+	r.pending = 0
+}
+
+// flush writes the events buffered since the last segment as a new segment, followed by
+// the updated metadata. Segments are only written by run and, once it has returned, Stop.
+func (r *Recorder) flush(ctx context.Context) error {
+	r.mu.Lock()
+	if err := r.gz.Close(); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	segment := r.buf.Bytes()
+	seq := r.rec.Segments
+	r.rec.Segments++
+	r.rec.Size += int64(len(segment))
+	r.resetSegment()
+	r.mu.Unlock()
+
+	if err := r.store.put(ctx, segmentKey(r.prefix, r.rec.ID, seq), "application/gzip", segment); err != nil {
+		return err
+	}
+	return r.writeMetadata(ctx)
+}
+
+// writeMetadata writes the current metadata of the recording.
+func (r *Recorder) writeMetadata(ctx context.Context) error {
+	rec := r.Recording()
+	body, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	return r.store.put(ctx, metadataKey(r.prefix, rec.ID), "application/json", body)
+}
+
+// recordingPrefix returns the prefix of the keys of the objects of a recording.
+func recordingPrefix(prefix, id string) string { return prefix + id + "/" }
+
+// metadataKey returns the key of the metadata of a recording.
+func metadataKey(prefix, id string) string { return recordingPrefix(prefix, id) + metadataObject }
+
+// segmentKey returns the key of a segment of a recording.
+func segmentKey(prefix, id string, seq int) string {
+	return recordingPrefix(prefix, id) + fmt.Sprintf("%06d%s", seq, segmentSuffix)
+}
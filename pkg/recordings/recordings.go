@@ -0,0 +1,328 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package recordings
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	kerrors "github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/google/uuid"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var recordingsLogger = logf.Log.WithName("recordings")
+
+const (
+	// metadataObject is the name of the object holding the metadata of a recording.
+	metadataObject = "recording.json"
+	// segmentSuffix is the suffix of the objects holding the segments of a recording.
+	segmentSuffix = ".jsonl.gz"
+)
+
+// ErrNotEnabled is returned when starting a recording while no object storage is
+// configured for recordings.
+var ErrNotEnabled = errors.New("Session recording is not enabled on the VDICluster")
+
+// Manager records desktop sessions to the object storage configured on the VDICluster and
+// serves the recordings back.
+type Manager struct {
+	mu sync.RWMutex
+	// the store recordings are written to, nil when recording is disabled
+	store *store
+	// the prefix of the keys of recordings in the bucket
+	prefix string
+	// how often frames are captured and the maximum width they are captured at
+	interval time.Duration
+	maxWidth int
+	// how long recordings are kept, or zero to keep them forever
+	retention time.Duration
+}
+
+// NewManager returns a new Manager. Recording is disabled until Setup is called.
+func NewManager() *Manager { return &Manager{} }
+
+// Setup builds the client for object storage from the configuration on the given
+// VDICluster. It is safe to call again when the configuration changes. Recordings already
+// in progress keep writing to the previous store.
+func (m *Manager) Setup(cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !cluster.RecordingEnabled() {
+		m.store = nil
+		return nil
+	}
+	cfg := cluster.GetRecordingConfig()
+	creds, err := secretsEngine.ReadSecretMap(cfg.CredentialsSecret, true)
+	if err != nil {
+		if kerrors.IsSecretNotFoundError(err) {
+			return fmt.Errorf("No credentials for recording sessions have been stored at %q", cfg.CredentialsSecret)
+		}
+		return err
+	}
+	accessKey, secretKey := string(creds[appv1.RecordingAccessKeyIDKey]), string(creds[appv1.RecordingSecretAccessKeyKey])
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("The credentials for recording sessions stored at %q must contain an %s and %s", cfg.CredentialsSecret, appv1.RecordingAccessKeyIDKey, appv1.RecordingSecretAccessKeyKey)
+	}
+	s, err := newStore(cfg.Endpoint, cluster.GetRecordingRegion(), cfg.Bucket, accessKey, secretKey)
+	if err != nil {
+		return err
+	}
+	m.store = s
+	m.prefix = cluster.GetRecordingPrefix()
+	m.interval = cluster.GetRecordingFrameInterval()
+	m.maxWidth = cluster.GetRecordingFrameMaxWidth()
+	m.retention = cluster.GetRecordingRetention()
+	return nil
+}
+
+// Enabled returns true if object storage is configured for recordings.
+func (m *Manager) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.store != nil
+}
+
+// Start begins recording the display of the given desktop session, using capture to grab
+// frames of it. The caller must Stop the returned Recorder when the session is no longer
+// being watched.
+func (m *Manager) Start(ctx context.Context, session *desktopsv1.Session, capture func(maxWidth int) ([]byte, error)) (*Recorder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.store == nil {
+		return nil, ErrNotEnabled
+	}
+	rec := &types.Recording{
+		ID:        uuid.New().String(),
+		Namespace: session.GetNamespace(),
+		Session:   session.GetName(),
+		Template:  session.GetTemplateName(),
+		User:      session.GetUser(),
+		StartedAt: time.Now().UTC(),
+	}
+	r := newRecorder(m.store, m.prefix, rec, capture, m.interval, m.maxWidth)
+	if err := r.start(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getStore returns the store and key prefix recordings are read from.
+func (m *Manager) getStore() (*store, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.store == nil {
+		return nil, "", ErrNotEnabled
+	}
+	return m.store, m.prefix, nil
+}
+
+// List returns the metadata of all recordings, most recent first.
+func (m *Manager) List(ctx context.Context) ([]*types.Recording, error) {
+	s, prefix, err := m.getStore()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.Recording, 0)
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "/"+metadataObject) {
+			continue
+		}
+		rec, err := readMetadata(ctx, s, obj.Key)
+		if err != nil {
+			if err == errObjectNotFound {
+				// deleted since the listing
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out, nil
+}
+
+// Get returns the metadata of the recording with the given ID.
+func (m *Manager) Get(ctx context.Context, id string) (*types.Recording, error) {
+	s, prefix, err := m.getStore()
+	if err != nil {
+		return nil, err
+	}
+	if !validID(id) {
+		return nil, kerrors.NewRecordingNotFoundError(id)
+	}
+	rec, err := readMetadata(ctx, s, metadataKey(prefix, id))
+	if err == errObjectNotFound {
+		return nil, kerrors.NewRecordingNotFoundError(id)
+	}
+	return rec, err
+}
+
+// Playback writes the events of the recording with the given ID to w in order, as JSON
+// lines. Segments are streamed from object storage as they are read, so recordings in
+// progress can be played back up to their most recent segment.
+func (m *Manager) Playback(ctx context.Context, id string, w io.Writer) error {
+	s, prefix, err := m.getStore()
+	if err != nil {
+		return err
+	}
+	segments, err := listSegments(ctx, s, prefix, id)
+	if err != nil {
+		return err
+	}
+	for _, key := range segments {
+		if err := copySegment(ctx, s, key, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the recording with the given ID from object storage.
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	s, prefix, err := m.getStore()
+	if err != nil {
+		return err
+	}
+	if _, err := m.Get(ctx, id); err != nil {
+		return err
+	}
+	return deleteRecording(ctx, s, prefix, id)
+}
+
+// Prune deletes the recordings that have not been written to for longer than the
+// retention configured on the VDICluster. It does nothing when recordings are kept
+// forever.
+func (m *Manager) Prune(ctx context.Context, now time.Time) error {
+	s, prefix, err := m.getStore()
+	if err != nil {
+		return err
+	}
+	m.mu.RLock()
+	retention := m.retention
+	m.mu.RUnlock()
+	if retention <= 0 {
+		return nil
+	}
+	objects, err := s.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		// the metadata is rewritten with every segment, so it was last modified when the
+		// recording was last written to
+		if !strings.HasSuffix(obj.Key, "/"+metadataObject) || now.Sub(obj.LastModified) < retention {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), "/"+metadataObject)
+		recordingsLogger.Info("Deleting expired recording", "Recording", id)
+		if err := deleteRecording(ctx, s, prefix, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validID returns true if the given ID names a single recording, so it cannot be used to
+// reach other objects in the bucket.
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\") && id != "." && id != ".."
+}
+
+// readMetadata reads the metadata of a recording at the given key.
+func readMetadata(ctx context.Context, s *store, key string) (*types.Recording, error) {
+	body, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	rec := &types.Recording{}
+	return rec, json.NewDecoder(body).Decode(rec)
+}
+
+// listSegments returns the keys of the segments of a recording in order.
+func listSegments(ctx context.Context, s *store, prefix, id string) ([]string, error) {
+	if !validID(id) {
+		return nil, kerrors.NewRecordingNotFoundError(id)
+	}
+	objects, err := s.list(ctx, recordingPrefix(prefix, id))
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, kerrors.NewRecordingNotFoundError(id)
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Key, segmentSuffix) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	// segment names are zero-padded, so they sort in the order they were written
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// copySegment decompresses the segment at the given key to w.
+func copySegment(ctx context.Context, s *store, key string, w io.Writer) error {
+	body, err := s.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	_, err = io.Copy(w, gz)
+	return err
+}
+
+// deleteRecording removes every object of a recording.
+func deleteRecording(ctx context.Context, s *store, prefix, id string) error {
+	objects, err := s.list(ctx, recordingPrefix(prefix, id))
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.delete(ctx, obj.Key); err != nil && err != errObjectNotFound {
+			return err
+		}
+	}
+	return nil
+}
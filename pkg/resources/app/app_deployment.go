@@ -103,6 +103,30 @@ func newAppContainerForCR(instance *appv1.VDICluster) corev1.Container {
 	if instance.EnableCORS() {
 		args = append(args, "--enable-cors")
 	}
+	if instance.DeviceTrustUsesClientCerts() {
+		args = append(args, "--request-client-certs")
+	}
+	if prefix := instance.GetAppPathPrefix(); prefix != "" {
+		args = append(args, "--path-prefix", prefix)
+	}
+	ports := []corev1.ContainerPort{
+		{
+			Name:          "web",
+			ContainerPort: v1.WebPort,
+		},
+	}
+	if instance.TunnelsEnabled() {
+		ports = append(ports,
+			corev1.ContainerPort{
+				Name:          "tunnel",
+				ContainerPort: v1.TunnelPort,
+			},
+			corev1.ContainerPort{
+				Name:          "tunnel-relay",
+				ContainerPort: v1.TunnelRelayPort,
+			},
+		)
+	}
 	return corev1.Container{
 		Name:            "app",
 		Image:           instance.GetAppImage(),
@@ -127,12 +151,7 @@ func newAppContainerForCR(instance *appv1.VDICluster) corev1.Container {
 				},
 			},
 		},
-		Ports: []corev1.ContainerPort{
-			{
-				Name:          "web",
-				ContainerPort: v1.WebPort,
-			},
-		},
+		Ports: ports,
 		ReadinessProbe: &corev1.Probe{
 			Handler: corev1.Handler{
 				HTTPGet: &corev1.HTTPGetAction{
@@ -179,7 +198,7 @@ func newGrafanaContainerForCR(instance *appv1.VDICluster) corev1.Container {
 			},
 			{
 				Name:  "GF_SERVER_ROOT_URL",
-				Value: "http://localhost:3000/api/grafana",
+				Value: fmt.Sprintf("http://localhost:3000%s/api/grafana", instance.GetAppPathPrefix()),
 			},
 			{
 				Name:  "GF_SERVER_SERVE_FROM_SUB_PATH",
@@ -98,6 +98,40 @@ func newAppServiceMonitorForCR(instance *appv1.VDICluster) *promv1.ServiceMonito
 	}
 }
 
+// newProxyServiceMonitorForCR returns a ServiceMonitor scraping the metrics served by the
+// kvdi-proxy of every desktop session in the cluster.
+func newProxyServiceMonitorForCR(instance *appv1.VDICluster) *promv1.ServiceMonitor {
+	return &promv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-proxies", instance.GetAppName()),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetServiceMonitorLabels(),
+			Annotations:     instance.GetAnnotations(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: promv1.ServiceMonitorSpec{
+			// desktops can run in any namespace
+			NamespaceSelector: promv1.NamespaceSelector{
+				Any: true,
+			},
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					v1.ComponentLabel:  "desktop",
+					v1.VDIClusterLabel: instance.GetName(),
+				},
+			},
+			Endpoints: []promv1.Endpoint{
+				{
+					Port:     "metrics",
+					Path:     "/metrics",
+					Interval: "10s",
+					Scheme:   "http",
+				},
+			},
+		},
+	}
+}
+
 func newPrometheusForCR(instance *appv1.VDICluster) *promv1.Prometheus {
 	return &promv1.Prometheus{
 		ObjectMeta: metav1.ObjectMeta{
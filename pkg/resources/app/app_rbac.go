@@ -41,21 +41,71 @@ var appRules = []rbacv1.PolicyRule{
 		Resources: []string{"vdiroles"},
 		Verbs:     verbsAll,
 	},
+	{
+		APIGroups: []string{"rbac.kvdi.io"},
+		Resources: []string{"vditeams"},
+		Verbs:     verbsReadOnly,
+	},
 	{
 		APIGroups: []string{"desktops.kvdi.io"},
 		Resources: []string{"sessions", "templates"},
 		Verbs:     verbsAll,
 	},
+	{
+		APIGroups: []string{"desktops.kvdi.io"},
+		Resources: []string{"labs", "labs/status"},
+		Verbs:     verbsAll,
+	},
+	{
+		APIGroups: []string{"desktops.kvdi.io"},
+		Resources: []string{"desktoppools", "desktoppools/status"},
+		Verbs:     verbsAll,
+	},
+	{
+		APIGroups: []string{"desktops.kvdi.io"},
+		Resources: []string{"desktopsnapshots"},
+		Verbs:     verbsAll,
+	},
+	{
+		APIGroups: []string{"snapshot.storage.k8s.io"},
+		Resources: []string{"volumesnapshots"},
+		Verbs:     []string{"create", "get", "list", "watch"},
+	},
 	{
 		APIGroups: []string{""},
 		Resources: []string{"pods", "pods/log", "services", "namespaces", "endpoints", "serviceaccounts"},
 		Verbs:     verbsReadOnly,
 	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"serviceaccounts/token"},
+		Verbs:     []string{"create"},
+	},
 	{
 		APIGroups: []string{""},
 		Resources: []string{"configmaps", "secrets"},
 		Verbs:     verbsAll,
 	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"persistentvolumeclaims", "persistentvolumes"},
+		Verbs:     []string{"get", "list", "watch", "update", "delete"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+		Verbs:     []string{"create", "patch"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"nodes"},
+		Verbs:     verbsReadOnly,
+	},
+	{
+		APIGroups: []string{"coordination.k8s.io"},
+		Resources: []string{"leases"},
+		Verbs:     verbsAll,
+	},
 }
 
 func newAppClusterRoleForCR(instance *appv1.VDICluster) *rbacv1.ClusterRole {
@@ -67,8 +117,26 @@ func newAppClusterRoleForCR(instance *appv1.VDICluster) *rbacv1.ClusterRole {
 			Annotations:     instance.GetAnnotations(),
 			OwnerReferences: instance.OwnerReferences(),
 		},
-		Rules: appRules,
+		Rules: appRulesForCR(instance),
+	}
+}
+
+// appRulesForCR returns the rules of the app cluster role. The wake-up listener runs with
+// the app service account, and needs to scale the manager back up if it is scaled down
+// for energy saving.
+func appRulesForCR(instance *appv1.VDICluster) []rbacv1.PolicyRule {
+	if !instance.ScaleManager() {
+		return appRules
 	}
+	name, _ := instance.GetManagerDeployment()
+	rules := make([]rbacv1.PolicyRule, len(appRules), len(appRules)+1)
+	copy(rules, appRules)
+	return append(rules, rbacv1.PolicyRule{
+		APIGroups:     []string{"apps"},
+		Resources:     []string{"deployments"},
+		ResourceNames: []string{name},
+		Verbs:         []string{"get", "patch"},
+	})
 }
 
 func newAppServiceAccountForCR(instance *appv1.VDICluster) *corev1.ServiceAccount {
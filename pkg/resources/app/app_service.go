@@ -29,6 +29,20 @@ import (
 )
 
 func newAppServiceForCR(instance *appv1.VDICluster) *corev1.Service {
+	ports := []corev1.ServicePort{
+		{
+			Name:       "web",
+			Port:       v1.PublicWebPort,
+			TargetPort: intstr.FromInt(v1.WebPort),
+		},
+	}
+	if instance.TunnelsEnabled() {
+		ports = append(ports, corev1.ServicePort{
+			Name:       "tunnel",
+			Port:       v1.TunnelPort,
+			TargetPort: intstr.FromInt(v1.TunnelPort),
+		})
+	}
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            instance.GetAppName(),
@@ -40,13 +54,7 @@ func newAppServiceForCR(instance *appv1.VDICluster) *corev1.Service {
 		Spec: corev1.ServiceSpec{
 			Type:     instance.GetAppServiceType(),
 			Selector: instance.GetComponentLabels("app"),
-			Ports: []corev1.ServicePort{
-				{
-					Name:       "web",
-					Port:       v1.PublicWebPort,
-					TargetPort: intstr.FromInt(v1.WebPort),
-				},
-			},
+			Ports:    ports,
 		},
 	}
 }
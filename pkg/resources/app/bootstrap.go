@@ -0,0 +1,222 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileBootstrap creates the roles, templates, and users declared in the bootstrap
+// configuration of the cluster. Each object is only created once, and objects that already
+// exist are left alone. The objects handled are recorded in the status of the cluster, even
+// when a later one fails, so they are not recreated after an administrator removes them.
+func (f *Reconciler) reconcileBootstrap(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster, authProvider common.AuthProvider) (err error) {
+	bootstrapped := make([]string, 0)
+	defer func() {
+		if len(bootstrapped) == 0 {
+			return
+		}
+		instance.Status.Bootstrapped = append(instance.Status.Bootstrapped, bootstrapped...)
+		if uerr := f.client.Status().Update(ctx, instance); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	objs, err := f.getBootstrapObjects(ctx, instance)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		key := bootstrapKey(obj)
+		if instance.IsBootstrapped(key) {
+			continue
+		}
+		if err := f.client.Create(ctx, obj); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			reqLogger.Info("Object declared in the bootstrap configuration already exists, leaving it alone", "Object", key)
+		} else {
+			reqLogger.Info("Created object from the bootstrap configuration", "Object", key)
+		}
+		bootstrapped = append(bootstrapped, key)
+	}
+
+	users := instance.GetBootstrapUsers()
+	if len(users) == 0 {
+		return nil
+	}
+	if !instance.IsUsingLocalAuth() {
+		reqLogger.Info("Users can only be bootstrapped when using local authentication, skipping them")
+		return nil
+	}
+	if err := authProvider.Setup(f.client, instance); err != nil {
+		return err
+	}
+	for _, user := range users {
+		key := fmt.Sprintf("%s/%s", rbacv1.ResourceUsers, user.Name)
+		if instance.IsBootstrapped(key) {
+			continue
+		}
+		if _, err := authProvider.GetUser(user.Name); err == nil {
+			reqLogger.Info("User declared in the bootstrap configuration already exists, leaving it alone", "User", user.Name)
+			bootstrapped = append(bootstrapped, key)
+			continue
+		} else if !errors.IsUserNotFoundError(err) {
+			return err
+		}
+		password, err := f.getBootstrapPassword(ctx, instance, user)
+		if err != nil {
+			return err
+		}
+		req := &types.CreateUserRequest{Username: user.Name, Password: password, Roles: user.Roles}
+		if err := req.Validate(); err != nil {
+			return fmt.Errorf("Bootstrap user %q is invalid: %s", user.Name, err.Error())
+		}
+		if err := authProvider.CreateUser(req); err != nil {
+			return err
+		}
+		reqLogger.Info("Created user from the bootstrap configuration", "User", user.Name)
+		bootstrapped = append(bootstrapped, key)
+	}
+	return nil
+}
+
+// getBootstrapObjects decodes the roles and templates in the bootstrap ConfigMaps of the
+// cluster. The keys of each ConfigMap are read in order.
+func (f *Reconciler) getBootstrapObjects(ctx context.Context, instance *appv1.VDICluster) ([]client.Object, error) {
+	objs := make([]client.Object, 0)
+	for _, name := range instance.GetBootstrapConfigMaps() {
+		cm := &corev1.ConfigMap{}
+		if err := f.client.Get(ctx, ktypes.NamespacedName{Name: name, Namespace: instance.GetCoreNamespace()}, cm); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(cm.Data[key]), 4096)
+			for {
+				var raw json.RawMessage
+				if err := decoder.Decode(&raw); err != nil {
+					if err == io.EOF {
+						break
+					}
+					return nil, fmt.Errorf("Could not decode %q in bootstrap ConfigMap %q: %s", key, name, err.Error())
+				}
+				if len(raw) == 0 || string(raw) == "null" {
+					continue
+				}
+				obj, err := decodeBootstrapObject(instance, raw)
+				if err != nil {
+					return nil, fmt.Errorf("Could not decode %q in bootstrap ConfigMap %q: %s", key, name, err.Error())
+				}
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, nil
+}
+
+// decodeBootstrapObject decodes a role or template manifest and readies it for creation
+// in the cluster.
+func decodeBootstrapObject(instance *appv1.VDICluster, raw []byte) (client.Object, error) {
+	typeMeta := &metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, typeMeta); err != nil {
+		return nil, err
+	}
+	var obj client.Object
+	switch typeMeta.Kind {
+	case "VDIRole":
+		obj = &rbacv1.VDIRole{}
+	case "Template":
+		obj = &desktopsv1.Template{}
+	default:
+		return nil, fmt.Errorf("Only VDIRoles and Templates can be bootstrapped, got %q", typeMeta.Kind)
+	}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return nil, err
+	}
+	if obj.GetName() == "" {
+		return nil, fmt.Errorf("%s is missing a name", typeMeta.Kind)
+	}
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetNamespace("")
+	if _, ok := obj.(*rbacv1.VDIRole); ok {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[v1.RoleClusterRefLabel] = instance.GetName()
+		obj.SetLabels(labels)
+	} else {
+		instance.ClaimObject(obj)
+	}
+	return obj, nil
+}
+
+// bootstrapKey returns the key the given role or template is recorded under in the status
+// of the cluster once it is bootstrapped.
+func bootstrapKey(obj client.Object) string {
+	if _, ok := obj.(*rbacv1.VDIRole); ok {
+		return fmt.Sprintf("%s/%s", rbacv1.ResourceRoles, obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s", rbacv1.ResourceTemplates, obj.GetName())
+}
+
+// getBootstrapPassword retrieves the initial password for a bootstrap user from its secret.
+func (f *Reconciler) getBootstrapPassword(ctx context.Context, instance *appv1.VDICluster, user appv1.BootstrapUser) (string, error) {
+	if user.PasswordSecret == "" {
+		return "", fmt.Errorf("Bootstrap user %q does not reference a password secret", user.Name)
+	}
+	secret := &corev1.Secret{}
+	if err := f.client.Get(ctx, ktypes.NamespacedName{Name: user.PasswordSecret, Namespace: instance.GetCoreNamespace()}, secret); err != nil {
+		return "", err
+	}
+	password, ok := secret.Data[appv1.BootstrapPasswordKey]
+	if !ok || len(password) == 0 {
+		return "", fmt.Errorf("Secret %q does not contain a %q key", user.PasswordSecret, appv1.BootstrapPasswordKey)
+	}
+	return string(password), nil
+}
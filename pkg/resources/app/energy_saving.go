@@ -0,0 +1,368 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// energySavingPollInterval is how often the schedule is checked while the stack is kept
+// awake outside of business hours by connected sessions.
+const energySavingPollInterval = 5 * time.Minute
+
+// reconcileEnergySaving applies the energy saving schedule of the cluster. It returns
+// whether the app should be scaled down, and how long until the schedule should be checked
+// again. Outside of business hours the stack goes to sleep once nobody is connected to a
+// session and no wake-up request was made within the wake duration.
+func (f *Reconciler) reconcileEnergySaving(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) (asleep bool, recheck time.Duration, err error) {
+	if !instance.EnergySavingIsEnabled() {
+		if err := f.removeWakeListener(ctx, reqLogger, instance); err != nil {
+			return false, 0, err
+		}
+		if err := f.resumeSessions(ctx, reqLogger, instance); err != nil {
+			return false, 0, err
+		}
+		return false, 0, f.setAsleep(ctx, instance, false)
+	}
+
+	// The wake-up listener runs at all times so the service can be pointed at it
+	// without waiting for it to start.
+	if err := reconcile.ConfigMap(ctx, reqLogger, f.client, newWakeConfigMapForCR(instance)); err != nil {
+		return false, 0, err
+	}
+	if err := reconcile.Deployment(ctx, reqLogger, f.client, newWakeListenerDeploymentForCR(instance), false); err != nil {
+		return false, 0, err
+	}
+
+	now := time.Now().UTC()
+	start, end := instance.GetBusinessHours(now)
+	wakeRequested, err := f.getLastWakeRequest(ctx, instance)
+	if err != nil {
+		return false, 0, err
+	}
+	wakeUntil := wakeRequested.Add(instance.GetWakeDuration())
+
+	if instance.InBusinessHours(now) || now.Before(wakeUntil) {
+		if err := f.resumeSessions(ctx, reqLogger, instance); err != nil {
+			return false, 0, err
+		}
+		if err := f.setAsleep(ctx, instance, false); err != nil {
+			return false, 0, err
+		}
+		if instance.InBusinessHours(now) {
+			return false, end.Sub(now), nil
+		}
+		return false, wakeUntil.Sub(now), nil
+	}
+
+	connected, err := f.pauseIdleSessions(ctx, reqLogger, instance)
+	if err != nil {
+		return false, 0, err
+	}
+	if connected > 0 {
+		reqLogger.Info(fmt.Sprintf("Outside of business hours but %d session(s) are connected, staying awake", connected))
+		return false, energySavingPollInterval, f.setAsleep(ctx, instance, false)
+	}
+
+	if !instance.Status.Asleep {
+		// make sure there is something to serve requests before scaling the app down
+		if err := reconcile.Deployment(ctx, reqLogger, f.client, newWakeListenerDeploymentForCR(instance), true); err != nil {
+			return false, 0, err
+		}
+		reqLogger.Info("Outside of business hours and no sessions are connected, scaling the stack down")
+	}
+	if err := f.setAsleep(ctx, instance, true); err != nil {
+		return false, 0, err
+	}
+	if start.IsZero() {
+		return true, energySavingPollInterval, nil
+	}
+	return true, start.Sub(now), nil
+}
+
+// reconcileAppService ensures the app service and points it at either the app pods or,
+// while the stack is asleep, the wake-up listener.
+func (f *Reconciler) reconcileAppService(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster, asleep bool) error {
+	if err := reconcile.Service(ctx, reqLogger, f.client, newAppServiceForCR(instance)); err != nil {
+		return err
+	}
+	selector := instance.GetComponentLabels("app")
+	if asleep {
+		selector = instance.GetComponentLabels("wake-listener")
+	}
+	svc := &corev1.Service{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: instance.GetAppName(), Namespace: instance.GetCoreNamespace()}, svc); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(svc.Spec.Selector, selector) {
+		return nil
+	}
+	reqLogger.Info("Updating app service selector", "Asleep", asleep)
+	svc.Spec.Selector = selector
+	return f.client.Update(ctx, svc)
+}
+
+// getLastWakeRequest returns the time of the last request made to the wake-up listener.
+// A zero time is returned if none was recorded.
+func (f *Reconciler) getLastWakeRequest(ctx context.Context, instance *appv1.VDICluster) (time.Time, error) {
+	cm := &corev1.ConfigMap{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: instance.GetWakeConfigMapName(), Namespace: instance.GetCoreNamespace()}, cm); err != nil {
+		return time.Time{}, client.IgnoreNotFound(err)
+	}
+	val, ok := cm.Data[appv1.WakeRequestedAtKey]
+	if !ok {
+		return time.Time{}, nil
+	}
+	requestedAt, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return requestedAt, nil
+}
+
+// pauseIdleSessions pauses the sessions of the cluster that have no connected display,
+// if the cluster is configured to do so. The number of connected sessions is returned.
+func (f *Reconciler) pauseIdleSessions(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) (int, error) {
+	sessions, err := f.listSessions(ctx, instance)
+	if err != nil {
+		return 0, err
+	}
+	displayLocks := &corev1.ConfigMapList{}
+	if err := f.client.List(
+		ctx,
+		displayLocks,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels(instance.GetComponentLabels("display-lock")),
+	); err != nil {
+		return 0, err
+	}
+	locked := make(map[string]struct{}, len(displayLocks.Items))
+	for _, lock := range displayLocks.Items {
+		locked[lock.GetName()] = struct{}{}
+	}
+
+	var connected int
+	for i := range sessions {
+		session := &sessions[i]
+		if _, ok := locked[fmt.Sprintf("display-%s-%s", session.GetNamespace(), session.GetName())]; ok {
+			connected++
+			continue
+		}
+		if !instance.PauseIdleSessions() || session.Status.Paused || session.GetDeletionTimestamp() != nil {
+			continue
+		}
+		reqLogger.Info("Pausing idle session outside of business hours", "Session.Name", session.GetName(), "Session.Namespace", session.GetNamespace())
+		session.Status.Paused = true
+		if err := f.client.Status().Update(ctx, session); err != nil {
+			return 0, err
+		}
+	}
+	return connected, nil
+}
+
+// resumeSessions clears the paused flag on all sessions of the cluster.
+func (f *Reconciler) resumeSessions(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	sessions, err := f.listSessions(ctx, instance)
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		session := &sessions[i]
+		if !session.Status.Paused {
+			continue
+		}
+		reqLogger.Info("Resuming paused session", "Session.Name", session.GetName(), "Session.Namespace", session.GetNamespace())
+		session.Status.Paused = false
+		if err := f.client.Status().Update(ctx, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Reconciler) listSessions(ctx context.Context, instance *appv1.VDICluster) ([]desktopsv1.Session, error) {
+	sessionList := &desktopsv1.SessionList{}
+	if err := f.client.List(ctx, sessionList, client.InNamespace(metav1.NamespaceAll)); err != nil {
+		return nil, err
+	}
+	sessions := make([]desktopsv1.Session, 0)
+	for _, session := range sessionList.Items {
+		if session.Spec.VDICluster == instance.GetName() {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (f *Reconciler) setAsleep(ctx context.Context, instance *appv1.VDICluster, asleep bool) error {
+	if instance.Status.Asleep == asleep {
+		return nil
+	}
+	instance.Status.Asleep = asleep
+	return f.client.Status().Update(ctx, instance)
+}
+
+// scaleManagerDown scales the manager deployment to zero, keeping the number of replicas
+// it ran with in an annotation for the wake-up listener to restore.
+func (f *Reconciler) scaleManagerDown(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	name, namespace := instance.GetManagerDeployment()
+	deployment := &appsv1.Deployment{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, deployment); err != nil {
+		return err
+	}
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		return nil
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	reqLogger.Info("Scaling the manager down", "Deployment.Name", name, "Deployment.Namespace", namespace)
+	patch := client.MergeFrom(deployment.DeepCopy())
+	annotations := deployment.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[appv1.AsleepReplicasAnnotation] = strconv.Itoa(int(replicas))
+	deployment.SetAnnotations(annotations)
+	deployment.Spec.Replicas = common.Int32Ptr(0)
+	return f.client.Patch(ctx, deployment, patch)
+}
+
+func (f *Reconciler) removeWakeListener(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	deployment := &appsv1.Deployment{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: instance.GetWakeListenerName(), Namespace: instance.GetCoreNamespace()}, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	reqLogger.Info("Energy saving is disabled, removing the wake-up listener")
+	return client.IgnoreNotFound(f.client.Delete(ctx, deployment))
+}
+
+func newWakeConfigMapForCR(instance *appv1.VDICluster) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetWakeConfigMapName(),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetComponentLabels("wake-listener"),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+	}
+}
+
+func newWakeListenerDeploymentForCR(instance *appv1.VDICluster) *appsv1.Deployment {
+	args := []string{"--vdi-cluster", instance.GetName(), "--wake-listener"}
+	if prefix := instance.GetAppPathPrefix(); prefix != "" {
+		args = append(args, "--path-prefix", prefix)
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetWakeListenerName(),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetComponentLabels("wake-listener"),
+			Annotations:     instance.GetAnnotations(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: common.Int32Ptr(1),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: instance.GetComponentLabels("wake-listener"),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: instance.GetComponentLabels("wake-listener"),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: instance.GetAppName(),
+					SecurityContext:    instance.GetAppSecurityContext(),
+					ImagePullSecrets:   instance.GetPullSecrets(),
+					Volumes: []corev1.Volume{
+						{
+							Name: "tls-server",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: instance.GetAppServerTLSSecretName(),
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "wake-listener",
+							Image:           instance.GetAppImage(),
+							ImagePullPolicy: instance.GetAppPullPolicy(),
+							Args:            args,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("32Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("100m"),
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "web",
+									ContainerPort: v1.WebPort,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path:   "/api/readyz",
+										Port:   intstr.FromInt(v1.WebPort),
+										Scheme: "HTTPS",
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "tls-server",
+									MountPath: v1.ServerCertificateMountPath,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
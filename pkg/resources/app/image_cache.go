@@ -0,0 +1,194 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package app
+
+import (
+	"context"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// imageCachePort is the port the registry cache listens on inside its pod.
+const imageCachePort = 5000
+
+// reconcileImageCache deploys the read-through registry cache for desktop images, or
+// removes it if it is no longer enabled.
+func (f *Reconciler) reconcileImageCache(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	if !instance.ImageCacheIsEnabled() {
+		return f.removeImageCache(ctx, reqLogger, instance)
+	}
+	if pvc := newImageCachePVCForCR(instance); pvc != nil {
+		if err := reconcile.PersistentVolumeClaim(ctx, reqLogger, f.client, pvc); err != nil {
+			return err
+		}
+	}
+	if err := reconcile.Deployment(ctx, reqLogger, f.client, newImageCacheDeploymentForCR(instance), false); err != nil {
+		return err
+	}
+	return reconcile.Service(ctx, reqLogger, f.client, newImageCacheServiceForCR(instance))
+}
+
+// removeImageCache deletes the registry cache deployment and service. The volume holding
+// the cached layers is kept so it can be reused if the cache is enabled again.
+func (f *Reconciler) removeImageCache(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	nn := types.NamespacedName{Name: instance.GetImageCacheName(), Namespace: instance.GetCoreNamespace()}
+	deployment := &appsv1.Deployment{}
+	if err := f.client.Get(ctx, nn, deployment); err == nil {
+		reqLogger.Info("Image cache is disabled, removing the registry cache")
+		if err := client.IgnoreNotFound(f.client.Delete(ctx, deployment)); err != nil {
+			return err
+		}
+	} else if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	svc := &corev1.Service{}
+	if err := f.client.Get(ctx, nn, svc); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return client.IgnoreNotFound(f.client.Delete(ctx, svc))
+}
+
+func newImageCachePVCForCR(instance *appv1.VDICluster) *corev1.PersistentVolumeClaim {
+	spec := instance.Spec.Desktops.ImageCache.VolumeSpec
+	if spec == nil {
+		return nil
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetImageCacheName(),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetComponentLabels("image-cache"),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: *spec.DeepCopy(),
+	}
+}
+
+func newImageCacheDeploymentForCR(instance *appv1.VDICluster) *appsv1.Deployment {
+	storage := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	if instance.Spec.Desktops.ImageCache.VolumeSpec != nil {
+		storage = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: instance.GetImageCacheName(),
+			},
+		}
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetImageCacheName(),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetComponentLabels("image-cache"),
+			Annotations:     instance.GetAnnotations(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: common.Int32Ptr(1),
+			// a persistent volume can only be attached to one cache at a time
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: instance.GetComponentLabels("image-cache"),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: instance.GetComponentLabels("image-cache"),
+				},
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: instance.GetPullSecrets(),
+					Volumes: []corev1.Volume{
+						{
+							Name:         "registry",
+							VolumeSource: storage,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:      "registry",
+							Image:     instance.GetImageCacheImage(),
+							Resources: instance.Spec.Desktops.ImageCache.Resources,
+							Env: []corev1.EnvVar{
+								{
+									Name:  "REGISTRY_PROXY_REMOTEURL",
+									Value: instance.GetImageCacheUpstream(),
+								},
+								{
+									Name:  "REGISTRY_STORAGE_FILESYSTEM_ROOTDIRECTORY",
+									Value: "/var/lib/registry",
+								},
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "registry",
+									ContainerPort: imageCachePort,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/v2/",
+										Port: intstr.FromInt(imageCachePort),
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "registry",
+									MountPath: "/var/lib/registry",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newImageCacheServiceForCR(instance *appv1.VDICluster) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetImageCacheName(),
+			Namespace:       instance.GetCoreNamespace(),
+			Labels:          instance.GetComponentLabels("image-cache"),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeNodePort,
+			Selector: instance.GetComponentLabels("image-cache"),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "registry",
+					Port:       imageCachePort,
+					TargetPort: intstr.FromInt(imageCachePort),
+					NodePort:   instance.GetImageCacheNodePort(),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package app
+
+import (
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DesiredResources returns the core resources the reconciler would apply for the given
+// cluster, in the order they are reconciled. It is used to preview the effect of changes
+// to a VDICluster spec before they are made. Resources that depend on the state of the
+// cluster at the time of reconciling, such as the admin secret and the PKI, are not included.
+func DesiredResources(instance *appv1.VDICluster) []client.Object {
+	objs := []client.Object{
+		instance.GetAdminRole(),
+		instance.GetLaunchTemplatesRole(),
+		newAppServiceAccountForCR(instance),
+		newAppClusterRoleForCR(instance),
+		newRoleBindingsForCR(instance),
+	}
+	if instance.RunAppGrafanaSidecar() {
+		objs = append(objs, newGrafanaConfigForCR(instance))
+	}
+	if instance.ImageCacheIsEnabled() {
+		if pvc := newImageCachePVCForCR(instance); pvc != nil {
+			objs = append(objs, pvc)
+		}
+		objs = append(objs, newImageCacheDeploymentForCR(instance), newImageCacheServiceForCR(instance))
+	}
+	objs = append(objs, newAppDeploymentForCR(instance), newAppServiceForCR(instance))
+	if instance.CreatePrometheusCR() {
+		objs = append(objs, newPrometheusForCR(instance), newPrometheusServiceForCR(instance))
+	}
+	if instance.CreateAppServiceMonitor() {
+		objs = append(objs, newAppServiceMonitorForCR(instance))
+	}
+	if instance.CreateProxyServiceMonitor() {
+		objs = append(objs, newProxyServiceMonitorForCR(instance))
+	}
+	return objs
+}
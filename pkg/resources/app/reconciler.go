@@ -21,7 +21,9 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
@@ -30,6 +32,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/pki"
 	"github.com/tinyzimmer/kvdi/pkg/resources"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
@@ -56,6 +59,14 @@ func New(c client.Client, s *runtime.Scheme) *Reconciler {
 
 // Reconcile reconciles all the core-components of a kVDI cluster.
 func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	// Make sure an isolated cluster does not overlap with another tenant
+	if instance.IsIsolated() {
+		reqLogger.Info("Validating tenancy configuration")
+		if err := tenancy.Validate(ctx, f.client, instance); err != nil {
+			return err
+		}
+	}
+
 	// Generate the admin secret
 	reqLogger.Info("Reconciling admin password secret")
 	adminPass, err := f.reconcileAdminSecret(reqLogger, instance)
@@ -106,6 +117,14 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 	if err := authProvider.Reconcile(ctx, reqLogger, f.client, instance, adminPass); err != nil {
 		return err
 	}
+
+	// create the users, roles, and templates declared for the first install
+	if instance.Spec.Bootstrap != nil {
+		reqLogger.Info("Reconciling bootstrap configuration")
+		if err := f.reconcileBootstrap(ctx, reqLogger, instance, authProvider); err != nil {
+			return err
+		}
+	}
 	if err := authProvider.Close(); err != nil {
 		reqLogger.Error(err, "Failed to close auth provider cleanly")
 	}
@@ -137,14 +156,47 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 		}
 	}
 
+	// Registry cache for templates pulling images through a mirror
+	reqLogger.Info("Reconciling image cache")
+	if err := f.reconcileImageCache(ctx, reqLogger, instance); err != nil {
+		return err
+	}
+
+	// Clean up user $HOME volumes left behind by desktop sessions
+	if instance.GetUserdataVolumeSpec() != nil {
+		reqLogger.Info("Reconciling userdata volumes")
+		if err := f.reconcileUserdataVolumes(ctx, reqLogger, instance); err != nil {
+			return err
+		}
+	}
+
+	// Scale the stack down outside of business hours if configured
+	reqLogger.Info("Reconciling energy saving schedule")
+	asleep, recheck, err := f.reconcileEnergySaving(ctx, reqLogger, instance)
+	if err != nil {
+		return err
+	}
+
 	// App deployment and service
 	reqLogger.Info("Reconciling app deployment and services")
-	if err := reconcile.Deployment(ctx, reqLogger, f.client, newAppDeploymentForCR(instance), true); err != nil {
-		return err
+	if asleep {
+		// point the service at the wake-up listener before scaling the app down
+		if err := f.reconcileAppService(ctx, reqLogger, instance, true); err != nil {
+			return err
+		}
+	}
+	appDeployment := newAppDeploymentForCR(instance)
+	if asleep {
+		appDeployment.Spec.Replicas = common.Int32Ptr(0)
 	}
-	if err := reconcile.Service(ctx, reqLogger, f.client, newAppServiceForCR(instance)); err != nil {
+	if err := reconcile.Deployment(ctx, reqLogger, f.client, appDeployment, !asleep); err != nil {
 		return err
 	}
+	if !asleep {
+		if err := f.reconcileAppService(ctx, reqLogger, instance, false); err != nil {
+			return err
+		}
+	}
 
 	// Prometheus instance for aggregating metrics
 	if instance.CreatePrometheusCR() {
@@ -165,7 +217,36 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 	if instance.CreateAppServiceMonitor() {
 		reqLogger.Info("Reconciling ServiceMonitor for app metrics")
 		err = reconcile.ServiceMonitor(ctx, reqLogger, f.client, newAppServiceMonitorForCR(instance))
-		return ignoreNoPromOperator(reqLogger, err)
+		if err := ignoreNoPromOperator(reqLogger, err); err != nil {
+			return err
+		}
+	}
+	if instance.CreateProxyServiceMonitor() {
+		reqLogger.Info("Reconciling ServiceMonitor for desktop proxy metrics")
+		err = reconcile.ServiceMonitor(ctx, reqLogger, f.client, newProxyServiceMonitorForCR(instance))
+		if err := ignoreNoPromOperator(reqLogger, err); err != nil {
+			return err
+		}
+	}
+
+	// The manager goes to sleep last, once everything else is scaled down
+	if asleep && instance.ScaleManager() {
+		if err := f.scaleManagerDown(ctx, reqLogger, instance); err != nil {
+			return err
+		}
+	}
+
+	// Check back in when the energy saving schedule changes
+	if recheck > 0 && (instance.GetUserdataVolumeSpec() == nil || recheck < userdataJanitorInterval) {
+		return errors.NewRequeueError(
+			fmt.Sprintf("Waiting for the next energy saving schedule change at %s", time.Now().Add(recheck).UTC().Format(time.RFC3339)),
+			int(recheck.Seconds())+1,
+		)
+	}
+
+	// Or when it's time to clean up userdata volumes again
+	if instance.GetUserdataVolumeSpec() != nil {
+		return errors.NewRequeueError("Waiting to clean up userdata volumes", int(userdataJanitorInterval.Seconds()))
 	}
 
 	return nil
@@ -23,17 +23,25 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	krbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -44,6 +52,7 @@ func newReconciler(t *testing.T) *Reconciler {
 	t.Helper()
 	scheme := runtime.NewScheme()
 	appv1.AddToScheme(scheme)
+	desktopsv1.AddToScheme(scheme)
 	rbacv1.AddToScheme(scheme)
 	corev1.AddToScheme(scheme)
 	appsv1.AddToScheme(scheme)
@@ -58,7 +67,7 @@ func newCluster(t *testing.T) *appv1.VDICluster {
 	cluster.Name = "test-cluster"
 	cluster.Spec = appv1.VDIClusterSpec{
 		Metrics: &appv1.MetricsConfig{
-			ServiceMonitor: &appv1.ServiceMonitorConfig{Create: true},
+			ServiceMonitor: &appv1.ServiceMonitorConfig{Create: true, Proxies: true},
 			Prometheus:     &appv1.PrometheusConfig{Create: true},
 			Grafana:        &appv1.GrafanaConfig{Enabled: true},
 		},
@@ -107,4 +116,379 @@ func TestReconcile(t *testing.T) {
 	if err := r.Reconcile(context.TODO(), testLogger, cluster); err != nil {
 		t.Error("Expected reconcile to complete successfully")
 	}
+
+	// the desktop proxies should be scraped in every namespace
+	sm := &promv1.ServiceMonitor{}
+	nn = types.NamespacedName{Name: cluster.GetAppName() + "-proxies", Namespace: cluster.GetCoreNamespace()}
+	if err := r.client.Get(context.TODO(), nn, sm); err != nil {
+		t.Fatal(err)
+	}
+	if !sm.Spec.NamespaceSelector.Any || sm.Spec.Endpoints[0].Port != "metrics" {
+		t.Errorf("Expected the proxy ServiceMonitor to select metrics ports in all namespaces, got: %+v", sm.Spec)
+	}
+
+	// the app mints pod-bound tokens for session kubeconfigs
+	role := &krbacv1.ClusterRole{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cluster.GetAppName()}, role); err != nil {
+		t.Fatal(err)
+	}
+	var canCreateTokens bool
+	for _, rule := range role.Rules {
+		if common.StringSliceContains(rule.Resources, "serviceaccounts/token") && common.StringSliceContains(rule.Verbs, "create") {
+			canCreateTokens = true
+		}
+	}
+	if !canCreateTokens {
+		t.Error("Expected the app role to allow creating service account tokens")
+	}
+}
+
+// TestEnergySaving tests that the stack goes to sleep outside of business hours and
+// wakes up on request.
+func TestEnergySaving(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	// only tomorrow is a business day
+	cluster.Spec.EnergySaving = &appv1.EnergySavingConfig{
+		Enabled:           true,
+		Days:              []string{time.Now().UTC().AddDate(0, 0, 1).Weekday().String()},
+		PauseIdleSessions: true,
+	}
+	if err := r.client.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	session := &desktopsv1.Session{}
+	session.Name = "test-session"
+	session.Namespace = "default"
+	session.Spec.VDICluster = cluster.GetName()
+	if err := r.client.Create(context.TODO(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	// should wait for the wake-up listener before going to sleep
+	if _, _, err := r.reconcileEnergySaving(context.TODO(), testLogger, cluster); err == nil {
+		t.Fatal("Expected error got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Error("Expected requeue error, got:", err)
+	}
+
+	deployment := &appsv1.Deployment{}
+	nn := types.NamespacedName{Name: cluster.GetWakeListenerName(), Namespace: cluster.GetCoreNamespace()}
+	if err := r.client.Get(context.TODO(), nn, deployment); err != nil {
+		t.Fatal(err)
+	}
+	deployment.Status = appsv1.DeploymentStatus{ReadyReplicas: 1}
+	if err := r.client.Status().Update(context.TODO(), deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	asleep, recheck, err := r.reconcileEnergySaving(context.TODO(), testLogger, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !asleep || !cluster.Status.Asleep {
+		t.Error("Expected the cluster to be asleep")
+	}
+	if recheck <= 0 {
+		t.Error("Expected a recheck at the start of business hours, got:", recheck)
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: session.Name, Namespace: session.Namespace}, session); err != nil {
+		t.Fatal(err)
+	}
+	if !session.Status.Paused {
+		t.Error("Expected idle session to be paused")
+	}
+
+	// record a wake-up request
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: cluster.GetWakeConfigMapName(), Namespace: cluster.GetCoreNamespace()}, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Data = map[string]string{appv1.WakeRequestedAtKey: time.Now().UTC().Format(time.RFC3339)}
+	if err := r.client.Update(context.TODO(), cm); err != nil {
+		t.Fatal(err)
+	}
+
+	asleep, _, err = r.reconcileEnergySaving(context.TODO(), testLogger, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asleep || cluster.Status.Asleep {
+		t.Error("Expected the cluster to be awake after a wake-up request")
+	}
+	// the fake client does not clear omitted fields on an existing object
+	session = &desktopsv1.Session{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "test-session", Namespace: "default"}, session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Status.Paused {
+		t.Error("Expected session to be resumed")
+	}
+}
+
+// TestScaleManagerDown tests that the manager deployment is scaled down while the stack
+// is asleep, and that the replicas it ran with are kept for the wake-up listener.
+func TestScaleManagerDown(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.EnergySaving = &appv1.EnergySavingConfig{
+		Enabled:           true,
+		ScaleManager:      true,
+		ManagerDeployment: "manager",
+		ManagerNamespace:  "kvdi",
+	}
+	manager := &appsv1.Deployment{}
+	manager.Name = "manager"
+	manager.Namespace = "kvdi"
+	manager.Spec.Replicas = common.Int32Ptr(2)
+	if err := r.client.Create(context.TODO(), manager); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.scaleManagerDown(context.TODO(), testLogger, cluster); err != nil {
+		t.Fatal(err)
+	}
+	manager = &appsv1.Deployment{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "manager", Namespace: "kvdi"}, manager); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Spec.Replicas == nil || *manager.Spec.Replicas != 0 {
+		t.Error("Expected the manager to be scaled down, got:", manager.Spec.Replicas)
+	}
+	if val := manager.GetAnnotations()[appv1.AsleepReplicasAnnotation]; val != "2" {
+		t.Error("Expected the previous replicas to be recorded, got:", val)
+	}
+
+	// scaling down again keeps the recorded replicas
+	if err := r.scaleManagerDown(context.TODO(), testLogger, cluster); err != nil {
+		t.Fatal(err)
+	}
+	manager = &appsv1.Deployment{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "manager", Namespace: "kvdi"}, manager); err != nil {
+		t.Fatal(err)
+	}
+	if val := manager.GetAnnotations()[appv1.AsleepReplicasAnnotation]; val != "2" {
+		t.Error("Expected the previous replicas to be kept, got:", val)
+	}
+
+	// the app may patch the manager deployment
+	var found bool
+	for _, rule := range newAppClusterRoleForCR(cluster).Rules {
+		if len(rule.ResourceNames) == 1 && rule.ResourceNames[0] == "manager" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected the app role to allow scaling the manager")
+	}
+}
+
+// TestUserdataJanitor tests that userdata claims and volumes left behind by deleted
+// sessions are cleaned up.
+func TestUserdataJanitor(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.UserdataSpec = &corev1.PersistentVolumeClaimSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}}
+
+	orphan := &corev1.PersistentVolumeClaim{}
+	orphan.Name = cluster.GetUserdataVolumeName("test-user")
+	orphan.Namespace = "default"
+	orphan.Labels = map[string]string{
+		v1.VDIClusterLabel:  cluster.GetName(),
+		v1.ComponentLabel:   "desktop",
+		v1.UserLabel:        "test-user",
+		v1.DesktopNameLabel: "deleted-session",
+	}
+	released := &corev1.PersistentVolume{}
+	released.Name = "released-volume"
+	released.Spec.ClaimRef = &corev1.ObjectReference{Name: orphan.Name, Namespace: orphan.Namespace}
+	released.Status.Phase = corev1.VolumeReleased
+	volMap := &corev1.ConfigMap{}
+	volMap.Name = cluster.GetUserdataVolumeMapName().Name
+	volMap.Namespace = cluster.GetUserdataVolumeMapName().Namespace
+	volMap.Data = map[string]string{
+		"test-user":    released.Name,
+		"deleted-user": "deleted-volume",
+	}
+	for _, obj := range []client.Object{orphan, released, volMap} {
+		if err := r.client.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.reconcileUserdataVolumes(context.TODO(), testLogger, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: orphan.Name, Namespace: orphan.Namespace}, orphan); err == nil {
+		t.Error("Expected the claim of the deleted session to be removed")
+	}
+	// get into fresh objects so fields cleared by the reconciler are not left over
+	freed := &corev1.PersistentVolume{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: released.Name}, freed); err != nil {
+		t.Fatal(err)
+	}
+	if freed.Spec.ClaimRef != nil {
+		t.Error("Expected the released volume to be freed")
+	}
+	if freed.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		t.Error("Expected the released volume to be retained, got:", freed.Spec.PersistentVolumeReclaimPolicy)
+	}
+	foundMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), cluster.GetUserdataVolumeMapName(), foundMap); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := foundMap.Data["deleted-user"]; ok {
+		t.Error("Expected the deleted volume to be removed from the volume map")
+	}
+	if foundMap.Data["test-user"] != released.Name {
+		t.Error("Expected the released volume to stay in the volume map, got:", foundMap.Data)
+	}
+}
+
+// TestBootstrap tests that the users, roles, and templates declared in the bootstrap
+// configuration are created once.
+func TestBootstrap(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Bootstrap = &appv1.BootstrapConfig{
+		Users: []appv1.BootstrapUser{
+			{Name: "bootstrap-user", Roles: []string{"bootstrap-role"}, PasswordSecret: "bootstrap-user-password"},
+		},
+		ConfigMaps: []string{"bootstrap-manifests"},
+	}
+	if err := r.client.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	manifests := &corev1.ConfigMap{}
+	manifests.Name = "bootstrap-manifests"
+	manifests.Namespace = cluster.GetCoreNamespace()
+	manifests.Data = map[string]string{
+		"manifests.yaml": strings.Join([]string{
+			"apiVersion: rbac.kvdi.io/v1",
+			"kind: VDIRole",
+			"metadata:",
+			"  name: bootstrap-role",
+			"rules:",
+			"  - verbs: [\"launch\"]",
+			"    resources: [\"templates\"]",
+			"---",
+			"apiVersion: desktops.kvdi.io/v1",
+			"kind: Template",
+			"metadata:",
+			"  name: bootstrap-template",
+		}, "\n"),
+	}
+	password := &corev1.Secret{}
+	password.Name = "bootstrap-user-password"
+	password.Namespace = cluster.GetCoreNamespace()
+	password.Data = map[string][]byte{appv1.BootstrapPasswordKey: []byte("password")}
+	for _, obj := range []client.Object{manifests, password} {
+		if err := r.client.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	provider := auth.GetAuthProvider(cluster, engine)
+	if err := provider.Reconcile(context.TODO(), testLogger, r.client, cluster, "admin-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.reconcileBootstrap(context.TODO(), testLogger, cluster, provider); err != nil {
+		t.Fatal(err)
+	}
+
+	role := &rbacv1.VDIRole{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "bootstrap-role"}, role); err != nil {
+		t.Fatal(err)
+	}
+	if role.GetLabels()[v1.RoleClusterRefLabel] != cluster.GetName() {
+		t.Error("Expected the role to be bound to the cluster, got:", role.GetLabels())
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "bootstrap-template"}, &desktopsv1.Template{}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := provider.GetUser("bootstrap-user"); err != nil {
+		t.Fatal(err)
+	} else if len(user.Roles) != 1 || user.Roles[0].GetName() != "bootstrap-role" {
+		t.Error("Expected the user to be bound to the bootstrap role, got:", user.Roles)
+	}
+	for _, key := range []string{"roles/bootstrap-role", "templates/bootstrap-template", "users/bootstrap-user"} {
+		if !cluster.IsBootstrapped(key) {
+			t.Error("Expected the cluster status to record", key)
+		}
+	}
+
+	// objects removed after they are bootstrapped are not recreated
+	if err := r.client.Delete(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileBootstrap(context.TODO(), testLogger, cluster, provider); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "bootstrap-role"}, role); err == nil {
+		t.Error("Expected the deleted role not to be recreated")
+	}
+}
+
+// TestImageCache tests that the registry cache is deployed when enabled and removed
+// when disabled.
+func TestImageCache(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		ImageCache: &appv1.ImageCacheConfig{
+			Enabled:    true,
+			Upstream:   "quay.io",
+			VolumeSpec: &corev1.PersistentVolumeClaimSpec{},
+		},
+	}
+	if err := r.reconcileImageCache(context.TODO(), testLogger, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	nn := types.NamespacedName{Name: cluster.GetImageCacheName(), Namespace: cluster.GetCoreNamespace()}
+	deployment := &appsv1.Deployment{}
+	if err := r.client.Get(context.TODO(), nn, deployment); err != nil {
+		t.Fatal(err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "registry:2" {
+		t.Error("Expected the default registry image, got:", container.Image)
+	}
+	if container.Env[0].Value != "https://quay.io" {
+		t.Error("Expected the cache to proxy the upstream registry, got:", container.Env[0].Value)
+	}
+	if deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim == nil {
+		t.Error("Expected the cache to store layers on the claimed volume")
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Error("Expected the cache volume to be created, got:", err)
+	}
+	svc := &corev1.Service{}
+	if err := r.client.Get(context.TODO(), nn, svc); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Type != corev1.ServiceTypeNodePort || svc.Spec.Ports[0].NodePort != 30500 {
+		t.Errorf("Expected the cache to be exposed on node port 30500, got: %+v", svc.Spec)
+	}
+
+	cluster.Spec.Desktops.ImageCache.Enabled = false
+	if err := r.reconcileImageCache(context.TODO(), testLogger, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Error("Expected the cache deployment to be removed, got:", err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.Service{}); !apierrors.IsNotFound(err) {
+		t.Error("Expected the cache service to be removed, got:", err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Error("Expected the cache volume to be kept, got:", err)
+	}
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package app
+
+import (
+	"context"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// userdataJanitorInterval is how often volumes left behind by desktop sessions are
+// cleaned up.
+const userdataJanitorInterval = 10 * time.Minute
+
+// reconcileUserdataVolumes cleans up after the user $HOME volumes provisioned from the
+// userdataSpec. Claims normally go away with the session that owns them, but ones that
+// outlive their session are removed, and volumes released by their claims are freed so
+// the user's next desktop can claim them again. Volumes that no longer exist are removed
+// from the userdata volume map.
+func (f *Reconciler) reconcileUserdataVolumes(ctx context.Context, reqLogger logr.Logger, instance *appv1.VDICluster) error {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := f.client.List(ctx, pvcs, client.MatchingLabels{
+		v1.VDIClusterLabel: instance.GetName(),
+		v1.ComponentLabel:  "desktop",
+	}); err != nil {
+		return err
+	}
+	for _, pvc := range pvcs.Items {
+		labels := pvc.GetLabels()
+		if pvc.GetName() != instance.GetUserdataVolumeName(labels[v1.UserLabel]) || pvc.GetDeletionTimestamp() != nil {
+			continue
+		}
+		session := &desktopsv1.Session{}
+		nn := types.NamespacedName{Name: labels[v1.DesktopNameLabel], Namespace: pvc.GetNamespace()}
+		if err := f.client.Get(ctx, nn, session); err == nil {
+			continue
+		} else if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		reqLogger.Info("Removing userdata claim left behind by a deleted session", "PVC.Name", pvc.GetName(), "PVC.Namespace", pvc.GetNamespace())
+		if err := f.client.Delete(ctx, &pvc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	volMapCM := &corev1.ConfigMap{}
+	if err := f.client.Get(ctx, instance.GetUserdataVolumeMapName(), volMapCM); err != nil {
+		// no user has launched a desktop yet
+		return client.IgnoreNotFound(err)
+	}
+	var changed bool
+	for user, pvName := range volMapCM.Data {
+		pv := &corev1.PersistentVolume{}
+		if err := f.client.Get(ctx, types.NamespacedName{Name: pvName}, pv); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			reqLogger.Info("Removing userdata volume that no longer exists from the volume map", "User", user, "PV.Name", pvName)
+			delete(volMapCM.Data, user)
+			changed = true
+			continue
+		}
+		if pv.GetDeletionTimestamp() != nil || pv.Status.Phase != corev1.VolumeReleased {
+			continue
+		}
+		reqLogger.Info("Freeing userdata volume released by its claim", "User", user, "PV.Name", pvName)
+		pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+		pv.Spec.ClaimRef = nil
+		if err := f.client.Update(ctx, pv); err != nil {
+			return err
+		}
+	}
+	if changed {
+		return f.client.Update(ctx, volMapCM)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// agentTokenLength is the length of the tokens issued to in-desktop agents.
+var agentTokenLength = 48
+
+// reconcileAgentSecret ensures a secret exists holding the token the agent inside the
+// session authenticates to the API with. The token is generated once and lives as long
+// as the session.
+func (f *Reconciler) reconcileAgentSecret(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session) error {
+	nn := types.NamespacedName{Name: instance.GetAgentSecretName(), Namespace: instance.GetNamespace()}
+	found := &corev1.Secret{}
+	exists := true
+	if err := f.client.Get(ctx, nn, found); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		exists = false
+	}
+	if exists && len(found.Data[v1.AgentTokenKey]) > 0 {
+		return nil
+	}
+
+	token, err := common.GeneratePassword(agentTokenLength)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		reqLogger.Info("Agent secret is missing a token, generating a new one", "Secret.Name", nn.Name)
+		if found.Data == nil {
+			found.Data = make(map[string][]byte)
+		}
+		found.Data[v1.AgentTokenKey] = []byte(token)
+		return f.client.Update(ctx, found)
+	}
+
+	reqLogger.Info("Creating agent token secret", "Secret.Name", nn.Name, "Secret.Namespace", nn.Namespace)
+	return f.client.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            nn.Name,
+			Namespace:       nn.Namespace,
+			Labels:          instance.GetLabels(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: map[string][]byte{
+			v1.AgentTokenKey: []byte(token),
+		},
+	})
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// bootSlotTimeout is how long an admitted pod may take to boot before it stops holding
+// one of its node's boot slots.
+const bootSlotTimeout = 10 * time.Minute
+
+// bootFailureReasons are the reasons a container waits for that will not resolve without
+// intervention. Pods with containers in these states do not hold a boot slot.
+var bootFailureReasons = map[string]struct{}{
+	"CrashLoopBackOff":           {},
+	"ImagePullBackOff":           {},
+	"ErrImagePull":               {},
+	"ErrImageNeverPull":          {},
+	"InvalidImageName":           {},
+	"CreateContainerConfigError": {},
+	"CreateContainerError":       {},
+	"RunContainerError":          {},
+}
+
+// admitBoot releases the boot gate on the given desktop pod once the node it is scheduled
+// on has fewer admitted pods still booting than the cluster allows.
+func (f *Reconciler) admitBoot(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, pod *corev1.Pod) error {
+	limit := cluster.GetMaxConcurrentBootsPerNode()
+	if limit <= 0 || bootAdmitted(pod) {
+		return nil
+	}
+
+	if pod.Spec.NodeName == "" {
+		return errors.NewRequeueError("Desktop pod has not been scheduled to a node yet", 2)
+	}
+
+	desktopPods := &corev1.PodList{}
+	if err := f.client.List(
+		ctx,
+		desktopPods,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels{v1.VDIClusterLabel: cluster.GetName(), v1.ComponentLabel: "desktop"},
+	); err != nil {
+		return err
+	}
+
+	var booting int
+	now := time.Now()
+	for _, p := range desktopPods.Items {
+		if p.Spec.NodeName == pod.Spec.NodeName && holdsBootSlot(&p, now) {
+			booting++
+		}
+	}
+	if booting >= limit {
+		return errors.NewRequeueError(fmt.Sprintf("Node %s has %d desktops booting, waiting for capacity", pod.Spec.NodeName, booting), 5)
+	}
+
+	reqLogger.Info("Admitting desktop pod to boot", "Node", pod.Spec.NodeName)
+	annotations := pod.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1.BootAdmittedAnnotation] = "true"
+	annotations[v1.BootAdmittedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	pod.SetAnnotations(annotations)
+	return f.client.Update(ctx, pod)
+}
+
+// bootAdmitted returns true if the given pod has been released from its boot gate.
+func bootAdmitted(pod *corev1.Pod) bool {
+	annotations := pod.GetAnnotations()
+	return annotations != nil && annotations[v1.BootAdmittedAnnotation] == "true"
+}
+
+// holdsBootSlot returns true if the given pod was admitted to boot and is still booting.
+// Pods that are being deleted, are stuck on an error, or were admitted longer than the
+// boot timeout ago release their slot.
+func holdsBootSlot(pod *corev1.Pod, now time.Time) bool {
+	if !bootAdmitted(pod) || podBooted(pod) || pod.GetDeletionTimestamp() != nil || bootFailed(pod) {
+		return false
+	}
+	return now.Sub(bootAdmittedAt(pod)) < bootSlotTimeout
+}
+
+// bootAdmittedAt returns when the given pod was admitted to boot. Pods admitted before
+// the time was recorded are assumed to have been admitted when they were created.
+func bootAdmittedAt(pod *corev1.Pod) time.Time {
+	if at, err := time.Parse(time.RFC3339, pod.GetAnnotations()[v1.BootAdmittedAtAnnotation]); err == nil {
+		return at
+	}
+	return pod.GetCreationTimestamp().Time
+}
+
+// bootFailed returns true if any of the containers in the given pod are waiting on an
+// error that will not resolve on its own.
+func bootFailed(pod *corev1.Pod) bool {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if _, ok := bootFailureReasons[status.State.Waiting.Reason]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// podBooted returns true if the given pod has finished pulling images and started all of
+// its containers, or has stopped.
+func podBooted(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodPending && pod.Status.Phase != corev1.PodRunning {
+		return true
+	}
+	if pod.Status.Phase == corev1.PodPending || len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileSunset drains a session whose template has passed its sunset date. Sessions are
+// removed once they are idle during the template's maintenance window, or whenever they are
+// idle if the template has no maintenance window. Connected sessions are flagged as pending
+// maintenance so clients can notify their users.
+func (f *Reconciler) reconcileSunset(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	now := time.Now().UTC()
+
+	if template.MaintenanceIsEnabled() && !template.InMaintenanceWindow(now) {
+		start, _ := template.GetMaintenanceWindow(now)
+		return errors.NewRequeueError(
+			fmt.Sprintf("Template is retired, waiting for the next maintenance window at %s to drain the session", start.Format(time.RFC3339)),
+			int(start.Sub(now).Seconds())+1,
+		)
+	}
+
+	connected, err := f.sessionIsConnected(ctx, cluster, instance)
+	if err != nil {
+		return err
+	}
+	if connected {
+		if !instance.Status.MaintenancePending {
+			reqLogger.Info("Template is retired but the session is still connected, flagging as pending")
+			instance.Status.MaintenancePending = true
+			if err := f.client.Status().Update(ctx, instance); err != nil {
+				return err
+			}
+		}
+		return errors.NewRequeueError("Waiting for the session to become idle to drain it", maintenanceIdlePollSeconds)
+	}
+
+	reqLogger.Info(fmt.Sprintf("Template %s was retired on %s, removing idle session", template.GetName(), template.GetSunsetTime().UTC().Format(time.RFC3339)))
+	return client.IgnoreNotFound(f.client.Delete(ctx, instance))
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/domain"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileDomainJoinSecret leases a host on the realm to the desktop and renders the
+// Kerberos and sssd configuration for it into a secret alongside the host's keytab. The
+// lease is released by a finalizer when the session is deleted.
+func (f *Reconciler) reconcileDomainJoinSecret(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *appv1.VDICluster, instance *desktopsv1.Session) error {
+	if err := f.ensureFinalizer(ctx, instance, domainHostReleaseFinalizer); err != nil {
+		return err
+	}
+
+	host, keytab, err := domain.NewManager(secretsEngine).Lease(domainHostOwner(instance))
+	if err != nil {
+		return err
+	}
+	reqLogger.Info("Leased domain host to desktop", "Host", host)
+
+	return reconcile.Secret(ctx, reqLogger, f.client, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetDomainJoinSecretName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          instance.GetLabels(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: map[string][]byte{
+			filepath.Base(v1.KRB5ConfigPath): []byte(cluster.GetKRB5Config()),
+			filepath.Base(v1.KRB5KeytabPath): keytab,
+			filepath.Base(v1.SSSDConfigPath): []byte(cluster.GetSSSDConfig(host)),
+		},
+	})
+}
+
+// releaseDomainHost frees the host leased to a deleted session.
+func (f *Reconciler) releaseDomainHost(instance *desktopsv1.Session) error {
+	cluster, err := instance.GetVDICluster(f.client)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(f.client, cluster); err != nil {
+		return err
+	}
+	defer secretsEngine.Close()
+	return domain.NewManager(secretsEngine).Release(domainHostOwner(instance))
+}
+
+// domainHostOwner returns the name a session holds its lease on a domain host under.
+func domainHostOwner(instance *desktopsv1.Session) string {
+	return fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName())
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Global map of expiry routines. The UID of the desktop is placed as a key to
+// avoid duplicate goroutines spawning.
+var expiryRoutines = make(map[types.UID]struct{})
+
+// reconcileExpiry records when the session expires, from the maximum duration it was launched
+// with and the cluster's maximum session length, and makes sure it is destroyed at that time.
+// Sessions that have already expired are destroyed right away, and true is returned. Unclaimed
+// sessions in a desktop pool do not expire until they are claimed.
+func (f *Reconciler) reconcileExpiry(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, instance *desktopsv1.Session) (expired bool, err error) {
+	if instance.IsPooled() {
+		return false, nil
+	}
+	if err := f.recordPoolClaim(ctx, reqLogger, instance); err != nil {
+		return false, err
+	}
+	expiresAt := instance.GetExpiry(cluster.GetMaxSessionLength())
+	if expiresAt.IsZero() {
+		return false, nil
+	}
+
+	if !instance.Status.ExpiresAt.Time.Equal(expiresAt) {
+		instance.Status.ExpiresAt = metav1.NewTime(expiresAt)
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return false, err
+		}
+	}
+
+	if !time.Now().Before(expiresAt) {
+		reqLogger.Info("Desktop session has expired, destroying instance", "ExpiresAt", expiresAt)
+		return true, client.IgnoreNotFound(f.client.Delete(ctx, instance))
+	}
+
+	// skip if we already have a goroutine running
+	if _, ok := expiryRoutines[instance.GetUID()]; !ok {
+		expiryRoutines[instance.GetUID()] = struct{}{}
+		go f.killOnExpiry(reqLogger, instance, expiresAt)
+	}
+	return false, nil
+}
+
+// killOnExpiry waits until the given time and destroys the session, unless it has been
+// deleted in the meantime.
+func (f *Reconciler) killOnExpiry(reqLogger logr.Logger, instance *desktopsv1.Session, expiresAt time.Time) {
+	ctx := context.Background()
+
+	reqLogger.Info("Starting session timer for desktop instance.", "ExpiresAt", expiresAt)
+
+	// make sure to clean the global map on return
+	defer func() { delete(expiryRoutines, instance.GetUID()) }()
+
+	// define the namespaced name and setup timers
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+	sessTimer := time.NewTimer(time.Until(expiresAt))
+	defer sessTimer.Stop()
+	pollTicker := time.NewTicker(time.Duration(10) * time.Second)
+	defer pollTicker.Stop()
+
+	// listen on the timer channels
+	for {
+		select {
+
+		case <-sessTimer.C:
+			// the desktop session has expired
+			reqLogger.Info("Desktop session has expired, destroying instance")
+			if err := f.client.Delete(ctx, instance); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					reqLogger.Error(err, fmt.Sprintf("Error destroying desktop instance: %s", err.Error()))
+				}
+			}
+			return
+
+		case <-pollTicker.C:
+			// return if desktop has been deleted
+			if err := f.client.Get(ctx, nn, &desktopsv1.Session{}); err != nil {
+				if client.IgnoreNotFound(err) == nil {
+					reqLogger.Info("Desktop instance has been deleted, stopping session poll")
+					return
+				}
+				reqLogger.Error(err, fmt.Sprintf("Error polling desktop instance: %s", err.Error()))
+				// retry on next loop
+			}
+
+		}
+	}
+}
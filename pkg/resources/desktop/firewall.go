@@ -0,0 +1,132 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileFirewall ensures the network policy restricting connections to the desktop
+// when its template has a firewall, and removes it when the template no longer does.
+func (f *Reconciler) reconcileFirewall(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	if template.FirewallIsEnabled() {
+		reqLogger.Info("Template has a firewall, reconciling network policy for the desktop session")
+		return reconcile.NetworkPolicy(ctx, reqLogger, f.client, newNetworkPolicyForCR(cluster, template, instance))
+	}
+	policy := &networkingv1.NetworkPolicy{}
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+	if err := f.client.Get(ctx, nn, policy); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	reqLogger.Info("Template no longer has a firewall, removing network policy for the desktop session")
+	return client.IgnoreNotFound(f.client.Delete(ctx, policy))
+}
+
+// newNetworkPolicyForCR returns a network policy only admitting connections to the desktop
+// on the ports used by kvdi and those allowed by the template's firewall. The kvdi app may
+// reach all of them, while other sessions may only reach the ports opened to them.
+func newNetworkPolicyForCR(cluster *appv1.VDICluster, tmpl *desktopsv1.Template, instance *desktopsv1.Session) *networkingv1.NetworkPolicy {
+	appPorts := []networkingv1.NetworkPolicyPort{newNetworkPolicyPort(v1.WebPort, corev1.ProtocolTCP)}
+	for _, port := range tmpl.GetExposedPorts() {
+		appPorts = append(appPorts, newNetworkPolicyPort(port.ContainerPort, port.Protocol))
+	}
+	for _, port := range tmpl.GetFirewallPorts() {
+		appPorts = append(appPorts, newNetworkPolicyPort(port.Port, port.GetProtocol()))
+	}
+
+	ingress := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{
+				newNetworkPolicyPeer(map[string]string{
+					v1.VDIClusterLabel: cluster.GetName(),
+					v1.ComponentLabel:  "app",
+				}),
+			},
+			Ports: appPorts,
+		},
+	}
+
+	if sessionPorts := tmpl.GetFirewallSessionPorts(); len(sessionPorts) > 0 {
+		ports := make([]networkingv1.NetworkPolicyPort, len(sessionPorts))
+		for i, port := range sessionPorts {
+			ports[i] = newNetworkPolicyPort(port.Port, port.GetProtocol())
+		}
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				newNetworkPolicyPeer(map[string]string{
+					v1.VDIClusterLabel: cluster.GetName(),
+					v1.ComponentLabel:  "desktop",
+				}),
+			},
+			Ports: ports,
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          k8sutil.GetDesktopLabels(cluster, instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					v1.VDIClusterLabel:  cluster.GetName(),
+					v1.ComponentLabel:   "desktop",
+					v1.DesktopNameLabel: instance.GetName(),
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     ingress,
+		},
+	}
+}
+
+func newNetworkPolicyPort(port int32, protocol corev1.Protocol) networkingv1.NetworkPolicyPort {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	portNum := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &portNum}
+}
+
+// newNetworkPolicyPeer returns a peer matching pods with the given labels in any namespace,
+// since sessions and the kvdi app may run in different namespaces.
+func newNetworkPolicyPeer(labels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector:       &metav1.LabelSelector{MatchLabels: labels},
+		NamespaceSelector: &metav1.LabelSelector{},
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gpuRetrySeconds is how long to wait before checking the nodes for the GPUs a waiting
+// session requires again.
+const gpuRetrySeconds = 30
+
+// checkGPUAvailability holds sessions whose template requests more GPUs than any node in the
+// cluster advertises, recording why on their status. Once the pod for a session exists it is
+// left to the scheduler.
+func (f *Reconciler) checkGPUAvailability(ctx context.Context, reqLogger logr.Logger, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+	if err := f.client.Get(ctx, nn, &corev1.Pod{}); err == nil || client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := f.client.List(ctx, nodes, client.MatchingLabels(template.GetGPUNodeSelector())); err != nil {
+		return err
+	}
+	reason := gpuShortage(template, nodes.Items)
+	if instance.Status.SchedulingError != reason {
+		instance.Status.SchedulingError = reason
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+	if reason != "" {
+		return errors.NewRequeueError(reason, gpuRetrySeconds)
+	}
+
+	reqLogger.Info("Found nodes with the GPUs requested by the template", "Resource", template.GetGPUResourceName())
+	return nil
+}
+
+// gpuShortage returns why none of the given nodes can run a desktop with the GPUs the
+// template requests, or an empty string if one of them can.
+func gpuShortage(template *desktopsv1.Template, nodes []corev1.Node) string {
+	name := template.GetGPUResourceName()
+	count := template.GetGPUCount()
+	var most int64
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		quantity, ok := node.Status.Allocatable[name]
+		if !ok {
+			continue
+		}
+		if quantity.Value() >= count {
+			return ""
+		}
+		if quantity.Value() > most {
+			most = quantity.Value()
+		}
+	}
+	if most == 0 {
+		return fmt.Sprintf("No schedulable node matching the template's GPU node selector advertises %s", name)
+	}
+	return fmt.Sprintf("The template requests %d %s but no schedulable node has more than %d", count, name, most)
+}
+
+// podSchedulingError returns the reason the scheduler gave for being unable to place the
+// given pod, or an empty string if it has been scheduled or not yet attempted.
+func podSchedulingError(pod *corev1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+			return cond.Message
+		}
+	}
+	return ""
+}
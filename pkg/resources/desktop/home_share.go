@@ -0,0 +1,93 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/homeshare"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileHomeShareSecret copies the user's home share credentials from the secrets
+// backend into a secret the CSI driver can read when mounting the share. The secret is kept
+// in the kVDI namespace so the credentials are not readable from the session namespace, and
+// it is removed by a finalizer when the session is deleted.
+func (f *Reconciler) reconcileHomeShareSecret(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	if err := f.ensureFinalizer(ctx, instance, homeShareCleanupFinalizer); err != nil {
+		return err
+	}
+
+	creds, err := homeshare.NewManager(secretsEngine).GetCredentials(instance.GetUser())
+	if err != nil {
+		if errors.IsUserNotFoundError(err) {
+			return fmt.Errorf("%s has not stored credentials for their home share", instance.GetUser())
+		}
+		return err
+	}
+
+	data := map[string][]byte{
+		"username": []byte(creds.Username),
+	}
+	if creds.Password != "" {
+		data["password"] = []byte(creds.Password)
+	}
+	if creds.Domain != "" {
+		data["domain"] = []byte(creds.Domain)
+	}
+	if template.HomeShareUsesKerberos() {
+		if len(creds.KerberosCache) == 0 {
+			return fmt.Errorf("%s has not stored a kerberos credential cache for their home share", instance.GetUser())
+		}
+		data[template.GetHomeShareKerberosCacheKey()] = creds.KerberosCache
+	}
+
+	return reconcile.Secret(ctx, reqLogger, f.client, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.GetHomeShareSecretName(),
+			Namespace: cluster.GetCoreNamespace(),
+			Labels:    instance.GetLabels(),
+		},
+		Data: data,
+	})
+}
+
+// removeHomeShareSecret removes the home share credentials of a deleted session from the
+// kVDI namespace.
+func (f *Reconciler) removeHomeShareSecret(ctx context.Context, instance *desktopsv1.Session) error {
+	cluster, err := instance.GetVDICluster(f.client)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	secret := &corev1.Secret{}
+	secret.Name = instance.GetHomeShareSecretName()
+	secret.Namespace = cluster.GetCoreNamespace()
+	return client.IgnoreNotFound(f.client.Delete(ctx, secret))
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// idlePollInterval is the longest to wait between checks of a session for idleness. The
+// API refreshes the last input time of sessions about as often.
+const idlePollInterval = 30 * time.Second
+
+// idleRoutines tracks the sessions that have a goroutine watching them for idleness.
+var idleRoutines = make(map[types.UID]struct{})
+
+// watchIdle terminates the session once it goes without input for longer than its idle
+// policy allows. The user is warned through the session status before the session is
+// destroyed, and the kvdi-agent relays the warning inside the desktop. Input received
+// after the warning cancels it. The goroutine exits once the session is deleted or no
+// longer has an idle policy.
+func (f *Reconciler) watchIdle(reqLogger logr.Logger, instance *desktopsv1.Session) {
+	ctx := context.Background()
+
+	reqLogger.Info("Starting idle watch for desktop instance")
+
+	// make sure to clean the global map on return
+	defer func() { delete(idleRoutines, instance.GetUID()) }()
+
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+
+	for {
+		session := &desktopsv1.Session{}
+		if err := f.client.Get(ctx, nn, session); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				reqLogger.Info("Desktop instance has been deleted, stopping idle watch")
+				return
+			}
+			reqLogger.Error(err, fmt.Sprintf("Error polling desktop instance: %s", err.Error()))
+			time.Sleep(idlePollInterval)
+			continue
+		}
+
+		done, wait, err := f.checkIdle(ctx, reqLogger, session)
+		if err != nil {
+			reqLogger.Error(err, "Failed to check desktop instance for idleness, will retry")
+		} else if done {
+			return
+		}
+		if wait <= 0 || wait > idlePollInterval {
+			wait = idlePollInterval
+		}
+		time.Sleep(wait)
+	}
+}
+
+// checkIdle warns the user of an idle session, or destroys it once the warning period
+// has passed. It returns true when the session no longer needs to be watched, otherwise
+// how long to wait before checking again.
+func (f *Reconciler) checkIdle(ctx context.Context, reqLogger logr.Logger, session *desktopsv1.Session) (done bool, wait time.Duration, err error) {
+	template, err := session.GetTemplate(f.client)
+	if err != nil {
+		return false, 0, err
+	}
+	cluster, err := session.GetVDICluster(f.client)
+	if err != nil {
+		return false, 0, err
+	}
+	policy := template.GetIdlePolicy(cluster)
+	timeout := policy.GetIdleTimeout()
+	if timeout == 0 {
+		reqLogger.Info("Desktop instance no longer has an idle policy, stopping idle watch")
+		return true, 0, f.clearIdleWarning(ctx, session)
+	}
+
+	// the API has not reported on the session yet
+	if session.Status.LastInputTime.IsZero() {
+		return false, idlePollInterval, nil
+	}
+
+	now := time.Now()
+	lastInput := session.Status.LastInputTime.Time
+	warned := session.Status.IdleWarningTime
+
+	if !warned.IsZero() && lastInput.After(warned.Time) {
+		reqLogger.Info("Input was received after the idle warning, keeping the session")
+		if err := f.clearIdleWarning(ctx, session); err != nil {
+			return false, 0, err
+		}
+		warned = metav1.Time{}
+	}
+
+	if warned.IsZero() {
+		warnBefore := policy.GetWarnBefore()
+		warnAt := lastInput.Add(timeout - warnBefore)
+		if now.Before(warnAt) {
+			return false, warnAt.Sub(now), nil
+		}
+		// always give the user the full warning period
+		terminateAt := lastInput.Add(timeout)
+		if earliest := now.Add(warnBefore); terminateAt.Before(earliest) {
+			terminateAt = earliest
+		}
+		reqLogger.Info("Desktop session is idle, warning the user", "LastInput", lastInput, "TerminatesAt", terminateAt)
+		session.Status.IdleWarningTime = metav1.NewTime(now)
+		session.Status.IdleTerminationTime = metav1.NewTime(terminateAt)
+		if err := f.client.Status().Update(ctx, session); err != nil {
+			return false, 0, err
+		}
+		return false, terminateAt.Sub(now), nil
+	}
+
+	terminateAt := session.Status.IdleTerminationTime.Time
+	if now.Before(terminateAt) {
+		return false, terminateAt.Sub(now), nil
+	}
+
+	reqLogger.Info("Desktop session has been idle for too long, destroying instance", "LastInput", lastInput)
+	return true, 0, client.IgnoreNotFound(f.client.Delete(ctx, session))
+}
+
+// clearIdleWarning removes any idle warning from the session status.
+func (f *Reconciler) clearIdleWarning(ctx context.Context, session *desktopsv1.Session) error {
+	if session.Status.IdleWarningTime.IsZero() && session.Status.IdleTerminationTime.IsZero() {
+		return nil
+	}
+	session.Status.IdleWarningTime = metav1.Time{}
+	session.Status.IdleTerminationTime = metav1.Time{}
+	return f.client.Status().Update(ctx, session)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/provenance"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// imagePolicyRetrySeconds is how long to wait before checking the images of a blocked
+// session again.
+const imagePolicyRetrySeconds = 60
+
+// imageVerifier is shared across reconciles so successful verifications are cached.
+var imageVerifier = provenance.NewVerifier(nil)
+
+// enforceImagePolicy verifies the images of the desktop pod against the cluster's image
+// policy. Sessions with unverified images are blocked and the reason is recorded on their
+// status, unless the policy is in audit mode.
+func (f *Reconciler) enforceImagePolicy(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, instance *desktopsv1.Session, pod *corev1.Pod) error {
+	policy := cluster.GetImagePolicy()
+	if policy == nil {
+		return nil
+	}
+
+	keychain, err := f.getPullSecretKeychain(ctx, pod)
+	if err != nil {
+		return err
+	}
+
+	violations := make([]string, 0)
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		result, err := imageVerifier.Verify(container.Image, policy, keychain)
+		if err != nil {
+			violations = append(violations, err.Error())
+			continue
+		}
+		if result != nil {
+			reqLogger.Info(fmt.Sprintf("Image %s (%s) verified by key %q", result.Image, result.Digest, result.Key))
+		}
+	}
+
+	var msg string
+	if len(violations) > 0 {
+		msg = fmt.Sprintf("Images do not satisfy the image policy: %s", strings.Join(violations, "; "))
+		if policy.AuditOnly {
+			reqLogger.Info(fmt.Sprintf("Image policy is in audit mode, allowing session: %s", msg))
+			msg = ""
+		}
+	}
+
+	if instance.Status.ImagePolicyError != msg {
+		instance.Status.ImagePolicyError = msg
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+	if msg != "" {
+		return errors.NewRequeueError(msg, imagePolicyRetrySeconds)
+	}
+	return nil
+}
+
+// getPullSecretKeychain builds registry credentials from the pull secrets of the desktop
+// pod, so signatures can be read from the same private registries as the images.
+func (f *Reconciler) getPullSecretKeychain(ctx context.Context, pod *corev1.Pod) (provenance.Keychain, error) {
+	keychain := make(provenance.Keychain)
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		secret := &corev1.Secret{}
+		if err := f.client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: pod.GetNamespace()}, secret); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			continue
+		}
+		if err := keychain.AddDockerConfig(data); err != nil {
+			return nil, fmt.Errorf("Could not parse pull secret %s: %s", ref.Name, err.Error())
+		}
+	}
+	return keychain, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/licenses"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+)
+
+// licenseRetrySeconds is how long to wait before checking the license pools for a waiting
+// session again.
+const licenseRetrySeconds = 15
+
+// acquireLicenses grants the session the seats its template requires from the cluster's
+// license pools. Sessions that cannot be granted their seats yet are held, and what they
+// are waiting for is recorded on their status.
+func (f *Reconciler) acquireLicenses(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	if instance.HasLicenses() {
+		return nil
+	}
+
+	grants, reason, err := licenses.New(f.client, cluster, secretsEngine).Acquire(ctx, instance, template)
+	if err != nil {
+		return err
+	}
+
+	if len(grants) > 0 || instance.Status.LicensePending != reason {
+		instance.Status.Licenses = grants
+		instance.Status.LicensePending = reason
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+	if reason != "" {
+		return errors.NewRequeueError(reason, licenseRetrySeconds)
+	}
+
+	for _, grant := range grants {
+		reqLogger.Info("Granted license seats to session", "Pool", grant.Pool, "Seats", grant.Seats)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maintenanceIdlePollSeconds is how often a connected session is checked for idleness
+// while its maintenance window is open.
+const maintenanceIdlePollSeconds = 30
+
+// reconcileMaintenance recreates the pod for an idle session once per maintenance window
+// of its template. A fresh pod remounts the user's volumes, which applies any pending
+// filesystem resizes, and pulls a new revision of the template image if its pull policy is
+// Always. Sessions that still have a connected display are flagged as pending, and the app
+// warns their users through the desktop proxy.
+func (f *Reconciler) reconcileMaintenance(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session, pod *corev1.Pod) error {
+	if !template.MaintenanceIsEnabled() {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	start, end := template.GetMaintenanceWindow(now)
+	if start.IsZero() {
+		return nil
+	}
+
+	// The window is not open yet, or the session's pod was already (re)created during it
+	if now.Before(start) || !instance.Status.LastMaintenanceTime.Time.Before(start) || !pod.GetCreationTimestamp().Time.Before(start) {
+		if instance.Status.MaintenancePending || instance.Status.MaintenanceNotified {
+			instance.Status.MaintenancePending = false
+			instance.Status.MaintenanceNotified = false
+			if err := f.client.Status().Update(ctx, instance); err != nil {
+				return err
+			}
+		}
+		if !now.Before(start) {
+			start, _ = template.GetMaintenanceWindow(end)
+		}
+		return errors.NewRequeueError(
+			fmt.Sprintf("Waiting for the next maintenance window at %s", start.Format(time.RFC3339)),
+			int(start.Sub(now).Seconds())+1,
+		)
+	}
+
+	connected, err := f.sessionIsConnected(ctx, cluster, instance)
+	if err != nil {
+		return err
+	}
+	if connected {
+		if !instance.Status.MaintenancePending {
+			reqLogger.Info("Maintenance window is open but the session is still connected, flagging as pending")
+			instance.Status.MaintenancePending = true
+			if err := f.client.Status().Update(ctx, instance); err != nil {
+				return err
+			}
+		}
+		return errors.NewRequeueError("Waiting for the session to become idle for maintenance", maintenanceIdlePollSeconds)
+	}
+
+	reqLogger.Info("Session is idle during its maintenance window, recreating the desktop pod")
+	instance.Status.MaintenancePending = false
+	instance.Status.MaintenanceNotified = false
+	instance.Status.LastMaintenanceTime = metav1.NewTime(now)
+	instance.Status.Running = false
+	if err := f.client.Status().Update(ctx, instance); err != nil {
+		return err
+	}
+	if err := f.client.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	return errors.NewRequeueError("Desktop pod is being recreated for maintenance", 3)
+}
+
+// sessionIsConnected returns true if a client currently holds the display lock for the
+// given session.
+func (f *Reconciler) sessionIsConnected(ctx context.Context, cluster *appv1.VDICluster, instance *desktopsv1.Session) (bool, error) {
+	displayLocks := &corev1.ConfigMapList{}
+	if err := f.client.List(
+		ctx,
+		displayLocks,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels(cluster.GetComponentLabels("display-lock")),
+	); err != nil {
+		return false, err
+	}
+	lockName := fmt.Sprintf("display-%s-%s", instance.GetNamespace(), instance.GetName())
+	for _, lock := range displayLocks.Items {
+		if lock.GetName() == lockName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// sessionLaunchDuration tracks how long desktop pods take to become ready.
+var sessionLaunchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "kvdi",
+	Name:      "session_launch_duration_seconds",
+	Help:      "The time from a desktop pod being created to its session running, by namespace and template.",
+	Buckets:   []float64{5, 10, 15, 30, 45, 60, 90, 120, 180, 300, 600},
+}, []string{"namespace", "template"})
+
+func init() {
+	metrics.Registry.MustRegister(sessionLaunchDuration)
+}
+
+// observeLaunchDuration records the time it took the given pod to start the session. Pods
+// started again after maintenance, preemption, or a pause are counted as new launches.
+func observeLaunchDuration(instance *desktopsv1.Session, pod *corev1.Pod) {
+	created := pod.GetCreationTimestamp()
+	if created.IsZero() {
+		return
+	}
+	sessionLaunchDuration.With(prometheus.Labels{
+		"namespace": instance.GetNamespace(),
+		"template":  instance.Spec.Template,
+	}).Observe(time.Since(created.Time).Seconds())
+}
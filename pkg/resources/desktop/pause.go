@@ -0,0 +1,56 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcilePaused stops the pod for a session that was paused by the VDICluster's energy
+// saving schedule. The session itself, along with its service and volumes, is kept so the
+// pod can be started again when the cluster wakes up and clears the flag.
+func (f *Reconciler) reconcilePaused(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session) error {
+	pod := &corev1.Pod{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, pod); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	} else if pod.GetDeletionTimestamp() == nil {
+		reqLogger.Info("Session is paused for energy saving, stopping the desktop pod")
+		if err := f.client.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	if instance.Status.Running {
+		instance.Status.Running = false
+		instance.Status.PodPhase = ""
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -20,6 +20,8 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package desktop
 
 import (
+	"fmt"
+
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
@@ -43,7 +45,21 @@ func newDesktopPodForCR(cluster *appv1.VDICluster, tmpl *desktopsv1.Template, in
 	}
 }
 
-func newServiceForCR(cluster *appv1.VDICluster, instance *desktopsv1.Session) *corev1.Service {
+func newServiceForCR(cluster *appv1.VDICluster, tmpl *desktopsv1.Template, instance *desktopsv1.Session) *corev1.Service {
+	ports := []corev1.ServicePort{
+		{
+			Name:       "kvdi-proxy",
+			Port:       v1.WebPort,
+			TargetPort: intstr.FromInt(v1.WebPort),
+		},
+	}
+	if cluster.CreateProxyServiceMonitor() {
+		ports = append(ports, corev1.ServicePort{
+			Name:       "metrics",
+			Port:       v1.ProxyMetricsPort,
+			TargetPort: intstr.FromInt(v1.ProxyMetricsPort),
+		})
+	}
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            instance.GetName(),
@@ -55,13 +71,42 @@ func newServiceForCR(cluster *appv1.VDICluster, instance *desktopsv1.Session) *c
 		Spec: corev1.ServiceSpec{
 			Type:     corev1.ServiceTypeClusterIP,
 			Selector: k8sutil.GetDesktopLabels(cluster, instance),
-			Ports: []corev1.ServicePort{
-				{
-					Name:       "kvdi-proxy",
-					Port:       v1.WebPort,
-					TargetPort: intstr.FromInt(v1.WebPort),
-				},
-			},
+			Ports:    ports,
+		},
+	}
+}
+
+// newPortsServiceForCR returns a service exposing the ports declared on the template, or
+// nil if the template does not declare any.
+func newPortsServiceForCR(cluster *appv1.VDICluster, tmpl *desktopsv1.Template, instance *desktopsv1.Session) *corev1.Service {
+	exposed := tmpl.GetExposedPorts()
+	if len(exposed) == 0 {
+		return nil
+	}
+	ports := make([]corev1.ServicePort, len(exposed))
+	for idx, port := range exposed {
+		name := port.Name
+		if name == "" {
+			name = fmt.Sprintf("port-%d", port.ContainerPort)
+		}
+		ports[idx] = corev1.ServicePort{
+			Name:       name,
+			Port:       port.ContainerPort,
+			TargetPort: intstr.FromInt(int(port.ContainerPort)),
+			Protocol:   port.Protocol,
+		}
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetPortsServiceName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          k8sutil.GetDesktopLabels(cluster, instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: k8sutil.GetDesktopLabels(cluster, instance),
+			Ports:    ports,
 		},
 	}
 }
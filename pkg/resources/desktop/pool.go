@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podSessionFor returns the view of the session its pod and service are built from. Sessions
+// booted for a desktop pool are always built as they were before being claimed, so that
+// claiming one does not recreate its desktop.
+func podSessionFor(instance *desktopsv1.Session) *desktopsv1.Session {
+	if instance.GetPool() == "" {
+		return instance
+	}
+	view := instance.DeepCopy()
+	view.Spec.User = ""
+	view.SetLabels(map[string]string{
+		v1.VDIClusterLabel:  instance.Spec.VDICluster,
+		v1.DesktopPoolLabel: instance.GetPool(),
+	})
+	view.SetAnnotations(nil)
+	return view
+}
+
+// recordPoolClaim records the time a session booted for a desktop pool was claimed by a
+// user, the first time it is reconciled after the claim.
+func (f *Reconciler) recordPoolClaim(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session) error {
+	if instance.GetPool() == "" || instance.IsPooled() || !instance.Status.ClaimTime.IsZero() {
+		return nil
+	}
+	reqLogger.Info("Desktop session was claimed from its pool", "Pool", instance.GetPool(), "User", instance.GetUser())
+	instance.Status.ClaimTime = metav1.NewTime(time.Now())
+	return f.client.Status().Update(ctx, instance)
+}
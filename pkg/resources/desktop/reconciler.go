@@ -32,6 +32,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/pki"
 	"github.com/tinyzimmer/kvdi/pkg/resources"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
@@ -55,9 +56,9 @@ var _ resources.DesktopReconciler = &Reconciler{}
 
 var userdataReclaimFinalizer = "kvdi.io/userdata-reclaim"
 
-// Global map of ticker routines. The UID of the desktop is placed as a key to
-// avoid duplicate goroutines spawning.
-var tickerRoutines = make(map[types.UID]struct{})
+var homeShareCleanupFinalizer = "kvdi.io/home-share-cleanup"
+
+var domainHostReleaseFinalizer = "kvdi.io/domain-host-release"
 
 // New returns a new Desktop reconciler
 func New(c client.Client, s *runtime.Scheme) *Reconciler {
@@ -76,16 +77,44 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 	if err != nil {
 		return err
 	}
+	if err := template.ValidateProcessLimits(); err != nil {
+		return err
+	}
+	if err := template.ValidateHomeShareUser(instance.GetUser()); err != nil {
+		return err
+	}
 	cluster, err := instance.GetVDICluster(f.client)
 	if err != nil {
 		return err
 	}
 
+	// refuse to run sessions across tenants
+	if err := tenancy.CheckSession(ctx, f.client, cluster, template, instance); err != nil {
+		return err
+	}
+
+	// sessions launched on a canary revision use its desktop configuration
+	template = template.ForRevision(instance.Spec.CanaryRevision)
+
+	// destroy the session once it exceeds its maximum duration
+	if expired, err := f.reconcileExpiry(ctx, reqLogger, cluster, instance); err != nil || expired {
+		return err
+	}
+
+	// keep the pod stopped while the cluster has the session paused for energy saving
+	if instance.Status.Paused {
+		return f.reconcilePaused(ctx, reqLogger, instance)
+	}
+
 	resourceNamespacedName := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
 
 	var userdataVol string
 	// create a PV for the user if we need to
-	if selector := cluster.GetUserdataSelector(); selector != nil && selector.IsValid() {
+	if instance.GetPool() != "" {
+		reqLogger.Info("Session was booted for a desktop pool, skipping userdata")
+	} else if template.HomeShareReplacesHome() {
+		reqLogger.Info("Template mounts a home share as the user's home directory, skipping userdata")
+	} else if selector := cluster.GetUserdataSelector(); selector != nil && selector.IsValid() {
 		reqLogger.Info("Cluster has userdataSelector, searching for user PVC")
 		userdataVol, err = f.locateUserdataPVC(ctx, reqLogger, instance, selector)
 		if err != nil {
@@ -101,7 +130,18 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 
 	// create a service in front of the desktop (so we can pre-allocate an IP that resolves to the pod)
 	reqLogger.Info("Reconciling service for the desktop session")
-	if err := reconcile.Service(ctx, reqLogger, f.client, newServiceForCR(cluster, instance)); err != nil {
+	if err := reconcile.Service(ctx, reqLogger, f.client, newServiceForCR(cluster, template, podSessionFor(instance))); err != nil {
+		return err
+	}
+	if svc := newPortsServiceForCR(cluster, template, podSessionFor(instance)); svc != nil {
+		reqLogger.Info("Reconciling service for the ports exposed by the desktop session")
+		if err := reconcile.Service(ctx, reqLogger, f.client, svc); err != nil {
+			return err
+		}
+	}
+
+	// restrict the ports inside the desktop that accept connections
+	if err := f.reconcileFirewall(ctx, reqLogger, cluster, template, instance); err != nil {
 		return err
 	}
 
@@ -132,6 +172,45 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 		return err
 	}
 
+	// copy the user's home share credentials into the kvdi namespace
+	if template.HomeShareNeedsCredentials() {
+		reqLogger.Info("Template mounts a home share with user credentials, reconciling secret")
+		if err := f.reconcileHomeShareSecret(ctx, reqLogger, secretsEngine, cluster, template, instance); err != nil {
+			return err
+		}
+	}
+
+	// render the kerberos configuration and keytab for domain joined desktops
+	if template.DomainJoinIsEnabled(cluster) {
+		reqLogger.Info("Template joins desktops to the domain, reconciling secret")
+		if err := f.reconcileDomainJoinSecret(ctx, reqLogger, secretsEngine, cluster, instance); err != nil {
+			return err
+		}
+	}
+
+	// copy the credentials for the static host the session connects to
+	if template.StaticHostNeedsCredentials() {
+		reqLogger.Info("Template connects to a static host with credentials, reconciling secret")
+		if err := f.reconcileStaticHostSecret(ctx, reqLogger, secretsEngine, template, instance); err != nil {
+			return err
+		}
+	}
+
+	// copy the credentials for the RDP server of the desktop
+	if template.RDPNeedsCredentials() {
+		reqLogger.Info("Template serves an RDP display with credentials, reconciling secret")
+		if err := f.reconcileRDPSecret(ctx, reqLogger, secretsEngine, template, instance); err != nil {
+			return err
+		}
+	}
+
+	// issue the in-desktop agent a token for the API
+	if template.AgentIsEnabled() {
+		if err := f.reconcileAgentSecret(ctx, reqLogger, instance); err != nil {
+			return err
+		}
+	}
+
 	// If a secret was pre-created by the API for extra environment variables, fetch its name
 	var secretName string
 	if template.HasManagedEnvSecret() {
@@ -162,9 +241,35 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 		secretName = secret.GetName()
 	}
 
+	desiredPod := newDesktopPodForCR(cluster, template, podSessionFor(instance), secretName, userdataVol)
+
+	// verify the images in the pod against the cluster's signature policy
+	if cluster.ImagePolicyIsEnabled() {
+		reqLogger.Info("Cluster has an image policy, verifying desktop images")
+		if err := f.enforceImagePolicy(ctx, reqLogger, cluster, instance, desiredPod); err != nil {
+			return err
+		}
+	}
+
+	// hold the session until a node can satisfy the GPUs its template requests
+	if template.GPUsEnabled() {
+		reqLogger.Info("Template requests GPUs, checking node availability")
+		if err := f.checkGPUAvailability(ctx, reqLogger, template, instance); err != nil {
+			return err
+		}
+	}
+
+	// hold the session until it is granted the license seats its template requires
+	if template.RequiresLicenses() {
+		reqLogger.Info("Template requires license seats, acquiring from license pools")
+		if err := f.acquireLicenses(ctx, reqLogger, cluster, secretsEngine, template, instance); err != nil {
+			return err
+		}
+	}
+
 	// ensure the pod
 	reqLogger.Info("Reconciling pod for session")
-	if _, err := reconcile.Pod(ctx, reqLogger, f.client, newDesktopPodForCR(cluster, template, instance, secretName, userdataVol)); err != nil {
+	if _, err := reconcile.Pod(ctx, reqLogger, f.client, desiredPod); err != nil {
 		return err
 	}
 
@@ -175,6 +280,29 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 		return err
 	}
 
+	// power on the static host the session connects to and wait for it to boot
+	if template.StaticHostPowerOnEnabled() {
+		if err := f.reconcileStaticHostPower(ctx, reqLogger, secretsEngine, template, instance); err != nil {
+			return err
+		}
+	}
+
+	// relaunch the session on on-demand capacity if its spot node is being reclaimed
+	if err := f.reconcileSpotPreemption(ctx, reqLogger, cluster, template, instance, desktopPod); err != nil {
+		return err
+	}
+
+	// hold the pod at its boot gate until its node has capacity for another launch
+	if err := f.admitBoot(ctx, reqLogger, cluster, desktopPod); err != nil {
+		return err
+	}
+
+	if launchFailed(desktopPod) {
+		if err := f.recordRolloutOutcome(ctx, reqLogger, instance, false); err != nil {
+			return err
+		}
+	}
+
 	if desktopPod.Status.Phase != corev1.PodRunning {
 		return f.updateNonRunningStatusAndRequeue(ctx, instance, desktopPod, "Desktop pod is not in running phase")
 	}
@@ -184,7 +312,7 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 		}
 	}
 
-	if (cluster.GetUserdataSelector() == nil || !cluster.GetUserdataSelector().IsValid()) && cluster.GetUserdataVolumeSpec() != nil {
+	if instance.GetPool() == "" && (cluster.GetUserdataSelector() == nil || !cluster.GetUserdataSelector().IsValid()) && cluster.GetUserdataVolumeSpec() != nil {
 		if err := f.reconcileUserdataMapping(ctx, reqLogger, cluster, instance); err != nil {
 			return err
 		}
@@ -196,22 +324,40 @@ func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, insta
 	if !instance.Status.Running {
 		instance.Status.PodPhase = desktopPod.Status.Phase
 		instance.Status.Running = true
+		instance.Status.SchedulingError = ""
 		if err := f.client.Status().Update(ctx, instance); err != nil {
 			return err
 		}
+		observeLaunchDuration(instance, desktopPod)
 	}
 
-	// start a timer to kill the desktop if max session length is set
-	if dur := cluster.GetMaxSessionLength(); dur != 0 {
-		if _, ok := tickerRoutines[instance.GetUID()]; ok {
-			// we already have a goroutine running, we are done here
-			return nil
+	if err := f.recordRolloutOutcome(ctx, reqLogger, instance, true); err != nil {
+		return err
+	}
+
+	// terminate the session along with the ticket it was launched against
+	if instance.Spec.Ticket != "" && cluster.TicketingIsEnabled() {
+		if _, ok := ticketRoutines[instance.GetUID()]; !ok {
+			ticketRoutines[instance.GetUID()] = struct{}{}
+			go f.watchTicket(reqLogger, cluster, instance)
 		}
-		tickerRoutines[instance.GetUID()] = struct{}{}
-		go f.killOnSessionTimeout(reqLogger, instance, dur)
 	}
 
-	return nil
+	// terminate the session once it goes without input for longer than its idle policy allows
+	if template.GetIdlePolicy(cluster).GetIdleTimeout() > 0 {
+		if _, ok := idleRoutines[instance.GetUID()]; !ok {
+			idleRoutines[instance.GetUID()] = struct{}{}
+			go f.watchIdle(reqLogger, instance)
+		}
+	}
+
+	// drain the session if its template has been retired
+	if template.IsSunset(time.Now()) {
+		return f.reconcileSunset(ctx, reqLogger, cluster, template, instance)
+	}
+
+	// recreate the session if the template's maintenance window is open and it is idle
+	return f.reconcileMaintenance(ctx, reqLogger, cluster, template, instance, desktopPod)
 }
 
 func (f *Reconciler) locateUserdataPVC(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session, selector *appv1.UserdataSelector) (string, error) {
@@ -251,51 +397,12 @@ func (f *Reconciler) locateUserdataPVC(ctx context.Context, reqLogger logr.Logge
 	return "", errors.New("Cannot use empty userdata selector")
 }
 
-func (f *Reconciler) killOnSessionTimeout(reqLogger logr.Logger, instance *desktopsv1.Session, dur time.Duration) {
-	ctx := context.Background()
-
-	reqLogger.Info("Starting session timer for desktop instance.")
-
-	// make sure to clean the global map on return
-	defer func() { delete(tickerRoutines, instance.GetUID()) }()
-
-	// define the namespaced name and setup tickers
-	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
-	sessTicker := time.NewTicker(dur)
-	pollTicker := time.NewTicker(time.Duration(10) * time.Second)
-
-	// listen on the ticker channels
-	for {
-		select {
-
-		case <-sessTicker.C:
-			// the desktop session has expired
-			reqLogger.Info("Desktop session has expired, destroying instance")
-			if err := f.client.Delete(ctx, instance); err != nil {
-				if client.IgnoreNotFound(err) != nil {
-					reqLogger.Error(err, fmt.Sprintf("Error destroying desktop instance: %s", err.Error()))
-				}
-			}
-			return
-
-		case <-pollTicker.C:
-			// return if desktop has been deleted
-			if err := f.client.Get(ctx, nn, &desktopsv1.Session{}); err != nil {
-				if client.IgnoreNotFound(err) == nil {
-					reqLogger.Info("Desktop instance has been deleted, stopping session poll")
-					return
-				}
-				reqLogger.Error(err, fmt.Sprintf("Error polling desktop instance: %s", err.Error()))
-				// retry on next loop
-			}
-
-		}
-	}
-}
-
 func (f *Reconciler) updateNonRunningStatusAndRequeue(ctx context.Context, instance *desktopsv1.Session, pod *corev1.Pod, msg string) error {
 	instance.Status.Running = false
 	instance.Status.PodPhase = pod.Status.Phase
+	instance.Status.SchedulingError = podSchedulingError(pod)
+	// A restarted desktop comes back spanning a single monitor
+	instance.Status.Monitors = nil
 	if err := f.client.Status().Update(ctx, instance); err != nil {
 		return err
 	}
@@ -303,8 +410,12 @@ func (f *Reconciler) updateNonRunningStatusAndRequeue(ctx context.Context, insta
 }
 
 func (f *Reconciler) ensureFinalizers(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session) error {
-	if !common.StringSliceContains(instance.GetFinalizers(), userdataReclaimFinalizer) {
-		instance.SetFinalizers(append(instance.GetFinalizers(), userdataReclaimFinalizer))
+	return f.ensureFinalizer(ctx, instance, userdataReclaimFinalizer)
+}
+
+func (f *Reconciler) ensureFinalizer(ctx context.Context, instance *desktopsv1.Session, finalizer string) error {
+	if !common.StringSliceContains(instance.GetFinalizers(), finalizer) {
+		instance.SetFinalizers(append(instance.GetFinalizers(), finalizer))
 		if err := f.client.Update(ctx, instance); err != nil {
 			return err
 		}
@@ -324,6 +435,20 @@ func (f *Reconciler) runFinalizers(ctx context.Context, reqLogger logr.Logger, i
 		instance.SetFinalizers(common.StringSliceRemove(instance.GetFinalizers(), userdataReclaimFinalizer))
 		updated = true
 	}
+	if common.StringSliceContains(instance.GetFinalizers(), homeShareCleanupFinalizer) {
+		if err := f.removeHomeShareSecret(ctx, instance); err != nil {
+			return err
+		}
+		instance.SetFinalizers(common.StringSliceRemove(instance.GetFinalizers(), homeShareCleanupFinalizer))
+		updated = true
+	}
+	if common.StringSliceContains(instance.GetFinalizers(), domainHostReleaseFinalizer) {
+		if err := f.releaseDomainHost(instance); err != nil {
+			return err
+		}
+		instance.SetFinalizers(common.StringSliceRemove(instance.GetFinalizers(), domainHostReleaseFinalizer))
+		updated = true
+	}
 	if updated {
 		return f.client.Update(ctx, instance)
 	}
@@ -21,16 +21,27 @@ package desktop
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 
+	"github.com/tinyzimmer/kvdi/pkg/auth/domain"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -51,6 +62,7 @@ func newReconciler(t *testing.T) *Reconciler {
 	corev1.AddToScheme(scheme)
 	appsv1.AddToScheme(scheme)
 	rbacv1.AddToScheme(scheme)
+	networkingv1.AddToScheme(scheme)
 	return New(fake.NewFakeClientWithScheme(scheme), scheme)
 }
 
@@ -279,3 +291,811 @@ func TestReconcile(t *testing.T) {
 		t.Error("Expected reconcile to finish completely, got:", err)
 	}
 }
+
+func TestReconcileStaticHostPower(t *testing.T) {
+	r := newReconciler(t)
+	desktop := newDesktop(t)
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+	}))
+	defer hook.Close()
+
+	// reserve an address for the host that is not yet accepting connections
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	tmpl := newTemplate(t)
+	tmpl.Spec.StaticHost = &desktopsv1.StaticHostConfig{
+		Address: addr,
+		PowerOn: &desktopsv1.StaticHostPowerOnConfig{
+			Webhook: &desktopsv1.PowerOnWebhookConfig{URL: hook.URL},
+		},
+	}
+	if !tmpl.StaticHostPowerOnEnabled() {
+		t.Fatal("Expected power on to be enabled")
+	}
+
+	// host should be powered on and the session marked as booting
+	for i := 0; i < 2; i++ {
+		if err := r.reconcileStaticHostPower(context.TODO(), testLogger, nil, tmpl, desktop); err != nil {
+			if _, ok := errors.IsRequeueError(err); !ok {
+				t.Fatal("Expected requeue error, got:", err)
+			}
+		} else {
+			t.Fatal("Expected requeue while host is booting")
+		}
+	}
+	if calls != 1 {
+		t.Error("Expected webhook to be called once, got:", calls)
+	}
+	if !desktop.Status.Booting {
+		t.Error("Expected session to be marked as booting")
+	}
+
+	// host comes up
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := r.reconcileStaticHostPower(context.TODO(), testLogger, nil, tmpl, desktop); err != nil {
+		t.Fatal("Expected host to be up, got:", err)
+	}
+	if desktop.Status.Booting {
+		t.Error("Expected session to no longer be booting")
+	}
+}
+
+func TestReconcileAgentSecret(t *testing.T) {
+	r := newReconciler(t)
+	desktop := newDesktop(t)
+	nn := types.NamespacedName{Name: desktop.GetAgentSecretName(), Namespace: desktop.GetNamespace()}
+
+	if err := r.reconcileAgentSecret(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+		t.Fatal(err)
+	}
+	token := string(secret.Data["token"])
+	if len(token) != agentTokenLength {
+		t.Fatalf("Expected a %d character token, got %q", agentTokenLength, token)
+	}
+
+	// the token should not change on subsequent reconciles
+	if err := r.reconcileAgentSecret(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["token"]) != token {
+		t.Error("Expected the agent token to be preserved")
+	}
+
+	// a missing token should be regenerated
+	secret.Data = nil
+	if err := r.client.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileAgentSecret(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.Data["token"]) != agentTokenLength {
+		t.Error("Expected a new agent token to be generated")
+	}
+}
+
+func TestReconcileFirewall(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.Firewall = &desktopsv1.FirewallPolicy{
+		Ports: []desktopsv1.FirewallPort{
+			{Port: 3000},
+			{Port: 5353, Protocol: corev1.ProtocolUDP, AllowFromSessions: true},
+		},
+	}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+
+	if err := r.reconcileFirewall(context.TODO(), testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	policy := &networkingv1.NetworkPolicy{}
+	if err := r.client.Get(context.TODO(), nn, policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Spec.Ingress) != 2 {
+		t.Fatal("Expected ingress rules for the app and other sessions, got:", policy.Spec.Ingress)
+	}
+	// the display proxy and both firewall ports
+	if ports := policy.Spec.Ingress[0].Ports; len(ports) != 3 {
+		t.Error("Expected the app to reach the proxy and firewall ports, got:", ports)
+	}
+	if ports := policy.Spec.Ingress[1].Ports; len(ports) != 1 || ports[0].Port.IntValue() != 5353 || *ports[0].Protocol != corev1.ProtocolUDP {
+		t.Error("Expected other sessions to only reach the port opened to them, got:", ports)
+	}
+
+	// removing the firewall should remove the policy
+	tmpl.Spec.Firewall = nil
+	if err := r.reconcileFirewall(context.TODO(), testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, policy); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	} else if err == nil {
+		t.Error("Expected the network policy to be removed")
+	}
+}
+
+func TestReconcileExpiry(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+
+	// sessions without a maximum duration never expire
+	desktop := newDesktop(t)
+	desktop.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	if expired, err := r.reconcileExpiry(context.TODO(), testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	} else if expired || !desktop.Status.ExpiresAt.IsZero() {
+		t.Error("Expected session without a maximum duration to not expire")
+	}
+
+	// the shorter of the cluster and session maximums applies
+	desktop.Spec.MaxDuration = "4h"
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{MaxSessionLength: "2h"}
+	if expired, err := r.reconcileExpiry(context.TODO(), testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	} else if expired {
+		t.Error("Expected session to not be expired yet")
+	}
+	if expected := desktop.CreationTimestamp.Add(2 * time.Hour); !desktop.Status.ExpiresAt.Time.Equal(expected) {
+		t.Errorf("Expected session to expire at %s, got %s", expected, desktop.Status.ExpiresAt.Time)
+	}
+
+	// expired sessions are destroyed
+	desktop.Spec.MaxDuration = "30m"
+	if expired, err := r.reconcileExpiry(context.TODO(), testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	} else if !expired {
+		t.Error("Expected session to be expired")
+	}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, &desktopsv1.Session{}); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	} else if err == nil {
+		t.Error("Expected expired session to be deleted")
+	}
+}
+
+func TestReconcilePooledSession(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{MaxSessionLength: "2h"}
+	tmpl := newTemplate(t)
+
+	// unclaimed sessions in a pool do not expire
+	desktop := newDesktop(t)
+	desktop.Spec.Pool = "test-pool"
+	desktop.CreationTimestamp = metav1.NewTime(time.Now().Add(-3 * time.Hour))
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	if expired, err := r.reconcileExpiry(context.TODO(), testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	} else if expired || !desktop.Status.ExpiresAt.IsZero() {
+		t.Error("Expected unclaimed pool session to not expire")
+	}
+	unclaimedPod := newDesktopPodForCR(cluster, tmpl, podSessionFor(desktop), "", "")
+
+	// claiming the session does not change its pod, and its duration counts from the claim
+	desktop.Spec.User = "test-user"
+	desktop.Spec.MaxDuration = "4h"
+	desktop.SetLabels(cluster.GetUserDesktopSelector("test-user"))
+	if claimedPod := newDesktopPodForCR(cluster, tmpl, podSessionFor(desktop), "", ""); !reflect.DeepEqual(unclaimedPod, claimedPod) {
+		t.Error("Expected the pod of a pool session to not change when it is claimed")
+	}
+	if expired, err := r.reconcileExpiry(context.TODO(), testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	} else if expired {
+		t.Error("Expected claimed pool session to not be expired")
+	}
+	if desktop.Status.ClaimTime.IsZero() {
+		t.Fatal("Expected the claim time to be recorded")
+	}
+	if expected := desktop.Status.ClaimTime.Add(2 * time.Hour); !desktop.Status.ExpiresAt.Time.Equal(expected) {
+		t.Errorf("Expected session to expire at %s, got %s", expected, desktop.Status.ExpiresAt.Time)
+	}
+	if desktop.GetDesktopUser() != "anonymous" {
+		t.Error("Expected a claimed pool session to keep running as the default user, got:", desktop.GetDesktopUser())
+	}
+}
+
+func TestCheckIdle(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		IdlePolicy: &appv1.IdlePolicy{IdleTimeout: "1h", WarnBefore: "10m"},
+	}
+	template := newTemplate(t)
+	desktop := newDesktop(t)
+	for _, obj := range []client.Object{cluster, template, desktop} {
+		if err := r.client.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sessions are left alone until their last input is reported
+	if done, wait, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if done || wait != idlePollInterval {
+		t.Error("Expected session without a last input time to keep being polled, got", done, wait)
+	}
+
+	// sessions are not warned until the warning period starts
+	desktop.Status.LastInputTime = metav1.NewTime(time.Now().Add(-45 * time.Minute))
+	if done, wait, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if done || wait <= 0 || wait > 5*time.Minute {
+		t.Error("Expected session to be checked again when the warning period starts, got", done, wait)
+	}
+	if !desktop.Status.IdleWarningTime.IsZero() {
+		t.Error("Expected session to not be warned yet")
+	}
+
+	// idle sessions are warned before they are terminated, and always get the full warning
+	// period even if it starts late
+	desktop.Status.LastInputTime = metav1.NewTime(time.Now().Add(-65 * time.Minute))
+	if done, _, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if done {
+		t.Error("Expected warned session to keep being watched")
+	}
+	if desktop.Status.IdleWarningTime.IsZero() {
+		t.Fatal("Expected idle session to be warned")
+	}
+	if period := desktop.Status.IdleTerminationTime.Sub(desktop.Status.IdleWarningTime.Time); period < 9*time.Minute || period > 11*time.Minute {
+		t.Error("Expected a ten minute warning period, got", period)
+	}
+
+	// input after the warning cancels it
+	desktop.Status.LastInputTime = metav1.NewTime(desktop.Status.IdleWarningTime.Add(time.Second))
+	if done, wait, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if done || wait <= 0 {
+		t.Error("Expected active session to keep being watched, got", done, wait)
+	}
+	if !desktop.Status.IdleWarningTime.IsZero() || !desktop.Status.IdleTerminationTime.IsZero() {
+		t.Error("Expected idle warning to be cleared")
+	}
+
+	// the template's policy overrides the cluster's
+	template.Spec.IdlePolicy = &appv1.IdlePolicy{}
+	if err := r.client.Update(context.TODO(), template); err != nil {
+		t.Fatal(err)
+	}
+	if done, _, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if !done {
+		t.Error("Expected session without an idle timeout to stop being watched")
+	}
+
+	// warned sessions are destroyed once the warning period passes
+	template.Spec.IdlePolicy = nil
+	if err := r.client.Update(context.TODO(), template); err != nil {
+		t.Fatal(err)
+	}
+	desktop.Status.LastInputTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	desktop.Status.IdleWarningTime = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+	desktop.Status.IdleTerminationTime = metav1.NewTime(time.Now().Add(-time.Minute))
+	if done, _, err := r.checkIdle(context.TODO(), testLogger, desktop); err != nil {
+		t.Fatal(err)
+	} else if !done {
+		t.Error("Expected destroyed session to stop being watched")
+	}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, &desktopsv1.Session{}); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	} else if err == nil {
+		t.Error("Expected idle session to be deleted")
+	}
+}
+
+func TestCheckGPUAvailability(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.GPUs = &desktopsv1.GPUConfig{Count: 2}
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	// the desktop container requests the GPUs and is scheduled to GPU nodes
+	spec := tmpl.ToPodSpec(cluster, desktop, "", "")
+	for _, c := range spec.Containers {
+		if c.Name != "desktop" {
+			continue
+		}
+		if q := c.Resources.Limits["nvidia.com/gpu"]; q.Value() != 2 {
+			t.Error("Expected the desktop container to be limited to 2 GPUs, got:", c.Resources.Limits)
+		}
+	}
+	if spec.NodeSelector["nvidia.com/gpu.present"] != "true" {
+		t.Error("Expected desktop pod to select GPU nodes, got:", spec.NodeSelector)
+	}
+
+	node := &corev1.Node{}
+	node.Name = "gpu-node"
+	node.SetLabels(map[string]string{"nvidia.com/gpu.present": "true"})
+	node.Status.Allocatable = corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}
+	if err := r.client.Create(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	err := r.checkGPUAvailability(context.TODO(), testLogger, tmpl, desktop)
+	if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected the session to be held for GPUs, got:", err)
+	}
+	if !strings.Contains(desktop.Status.SchedulingError, "no schedulable node has more than 1") {
+		t.Error("Expected the GPU shortage to be recorded, got:", desktop.Status.SchedulingError)
+	}
+
+	node.Status.Allocatable = corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")}
+	if err := r.client.Update(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.checkGPUAvailability(context.TODO(), testLogger, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Status.SchedulingError != "" {
+		t.Error("Expected the scheduling error to be cleared, got:", desktop.Status.SchedulingError)
+	}
+
+	// once the pod exists, the reason the scheduler gives is surfaced instead
+	pod := &corev1.Pod{}
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type:    corev1.PodScheduled,
+		Status:  corev1.ConditionFalse,
+		Reason:  corev1.PodReasonUnschedulable,
+		Message: "0/1 nodes are available: 1 Insufficient nvidia.com/gpu.",
+	}}
+	if msg := podSchedulingError(pod); msg != pod.Status.Conditions[0].Message {
+		t.Error("Expected the scheduler's message, got:", msg)
+	}
+}
+
+func TestNewPortsServiceForCR(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	session := &desktopsv1.Session{}
+	session.Name = "test-session"
+	session.Namespace = "default"
+	tmpl := &desktopsv1.Template{Spec: desktopsv1.TemplateSpec{DesktopConfig: &desktopsv1.DesktopConfig{}}}
+
+	if svc := newPortsServiceForCR(cluster, tmpl, session); svc != nil {
+		t.Error("Expected no ports service when the template does not declare ports, got:", svc)
+	}
+
+	tmpl.Spec.DesktopConfig.Ports = []corev1.ContainerPort{{ContainerPort: 3000}, {Name: "docs", ContainerPort: 8000}}
+	svc := newPortsServiceForCR(cluster, tmpl, session)
+	if svc == nil || svc.GetName() != "test-session-ports" {
+		t.Fatal("Expected a test-session-ports service, got:", svc)
+	}
+	if len(svc.Spec.Ports) != 2 || svc.Spec.Ports[0].Name != "port-3000" || svc.Spec.Ports[1].Name != "docs" {
+		t.Error("Expected the declared ports on the service, got:", svc.Spec.Ports)
+	}
+	for _, port := range newServiceForCR(cluster, tmpl, session).Spec.Ports {
+		if port.Port == 3000 || port.Port == 8000 {
+			t.Error("Expected declared ports to be kept off the desktop service, got:", port)
+		}
+	}
+}
+
+func TestNewServiceForCRExcludesIDE(t *testing.T) {
+	cluster := &appv1.VDICluster{}
+	session := &desktopsv1.Session{}
+	session.Name = "test-session"
+	tmpl := &desktopsv1.Template{Spec: desktopsv1.TemplateSpec{IDEConfig: &desktopsv1.IDEConfig{}}}
+	for _, port := range newServiceForCR(cluster, tmpl, session).Spec.Ports {
+		if port.Port == tmpl.GetIDEPort() {
+			t.Error("Expected the IDE port to be kept off the desktop service, got:", port)
+		}
+	}
+	for _, port := range newNetworkPolicyForCR(cluster, tmpl, session).Spec.Ingress[0].Ports {
+		if port.Port.IntValue() == int(tmpl.GetIDEPort()) {
+			t.Error("Expected the IDE port to not be admitted by the network policy, got:", port)
+		}
+	}
+}
+
+func TestReconcileMaintenance(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	// a window that is open all day
+	tmpl.Spec.Maintenance = &desktopsv1.MaintenanceWindow{StartTime: "00:00", Duration: "24h"}
+
+	desktop := newDesktop(t)
+	desktop.Status.Running = true
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	pod.Name = desktop.GetName()
+	pod.Namespace = desktop.GetNamespace()
+	pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	if err := r.client.Create(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// connected sessions are flagged as pending and left running
+	lock := &corev1.ConfigMap{}
+	lock.Name = "display-test-namespace-test-desktop"
+	lock.Namespace = "default"
+	lock.Labels = cluster.GetComponentLabels("display-lock")
+	if err := r.client.Create(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileMaintenance(context.TODO(), testLogger, cluster, tmpl, desktop, pod); !isRequeue(err) {
+		t.Fatal("Expected a requeue while the session is connected, got:", err)
+	}
+	if !desktop.Status.MaintenancePending {
+		t.Error("Expected the connected session to be flagged as pending maintenance")
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{}); err != nil {
+		t.Error("Expected the pod of a connected session to be kept, got:", err)
+	}
+
+	// once the user disconnects the pod is recreated
+	desktop.Status.MaintenanceNotified = true
+	if err := r.client.Delete(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileMaintenance(context.TODO(), testLogger, cluster, tmpl, desktop, pod); !isRequeue(err) {
+		t.Fatal("Expected a requeue while the pod is recreated, got:", err)
+	}
+	if desktop.Status.MaintenancePending || desktop.Status.MaintenanceNotified || desktop.Status.LastMaintenanceTime.IsZero() {
+		t.Error("Expected the maintenance to be recorded on the session, got:", desktop.Status)
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected the pod to be deleted for maintenance, got:", err)
+	}
+
+	// the session is not recreated again in the same window
+	pod.CreationTimestamp = metav1.Now()
+	if err := r.reconcileMaintenance(context.TODO(), testLogger, cluster, tmpl, desktop, pod); !isRequeue(err) {
+		t.Fatal("Expected a requeue for the next window, got:", err)
+	} else if !strings.Contains(err.Error(), "next maintenance window") {
+		t.Error("Expected to wait for the next window, got:", err)
+	}
+}
+
+func TestReconcileSunset(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	sunset := metav1.NewTime(time.Now().Add(-time.Hour))
+	tmpl.Spec.Deprecation = &desktopsv1.DeprecationConfig{Sunset: &sunset}
+
+	desktop := newDesktop(t)
+	desktop.Status.Running = true
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	// connected sessions are flagged and left running
+	lock := &corev1.ConfigMap{}
+	lock.Name = "display-test-namespace-test-desktop"
+	lock.Namespace = "default"
+	lock.Labels = cluster.GetComponentLabels("display-lock")
+	if err := r.client.Create(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileSunset(context.TODO(), testLogger, cluster, tmpl, desktop); !isRequeue(err) {
+		t.Fatal("Expected a requeue while the session is connected, got:", err)
+	}
+	if !desktop.Status.MaintenancePending {
+		t.Error("Expected the connected session to be flagged as pending")
+	}
+
+	// with a maintenance window, idle sessions wait for it to open
+	if err := r.client.Delete(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	closed := time.Now().UTC().Add(-24 * time.Hour)
+	tmpl.Spec.Maintenance = &desktopsv1.MaintenanceWindow{Days: []string{closed.Weekday().String()}, StartTime: "00:00", Duration: "1h"}
+	if err := r.reconcileSunset(context.TODO(), testLogger, cluster, tmpl, desktop); !isRequeue(err) {
+		t.Fatal("Expected a requeue until the maintenance window opens, got:", err)
+	} else if !strings.Contains(err.Error(), "next maintenance window") {
+		t.Error("Expected to wait for the maintenance window, got:", err)
+	}
+
+	// without one, idle sessions are drained immediately
+	tmpl.Spec.Maintenance = nil
+	if err := r.reconcileSunset(context.TODO(), testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desktop.Name, Namespace: desktop.Namespace}, &desktopsv1.Session{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected the idle session to be removed, got:", err)
+	}
+}
+
+func TestReconcileSpotPreemption(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.Spot = &desktopsv1.SpotConfig{
+		NodeSelector:  map[string]string{"node-lifecycle": "spot"},
+		WarningPeriod: "1h",
+	}
+
+	desktop := newDesktop(t)
+	desktop.Status.Running = true
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	node := &corev1.Node{}
+	node.Name = "spot-node"
+	if err := r.client.Create(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	pod.Name = desktop.GetName()
+	pod.Namespace = desktop.GetNamespace()
+	pod.Spec.NodeName = node.Name
+
+	// nothing happens without a preemption notice
+	if err := r.reconcileSpotPreemption(context.TODO(), testLogger, cluster, tmpl, desktop, pod); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Status.PreemptionPending {
+		t.Fatal("Expected no preemption without a notice")
+	}
+
+	// a preemption taint warns the user and waits out the warning period
+	node.Spec.Taints = []corev1.Taint{{Key: "cloud.google.com/impending-node-termination", Effect: corev1.TaintEffectNoSchedule}}
+	if err := r.client.Update(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileSpotPreemption(context.TODO(), testLogger, cluster, tmpl, desktop, pod); !isRequeue(err) {
+		t.Fatal("Expected a requeue during the warning period, got:", err)
+	}
+	if !desktop.Status.PreemptionPending || desktop.Status.PreemptionNoticeTime.IsZero() || desktop.Status.OnDemand {
+		t.Error("Expected the session to be flagged as preempted, got:", desktop.Status)
+	}
+
+	// the session is relaunched on demand as soon as the node goes away
+	if err := r.client.Delete(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileSpotPreemption(context.TODO(), testLogger, cluster, tmpl, desktop, pod); !isRequeue(err) {
+		t.Fatal("Expected a requeue while the pod is relaunched, got:", err)
+	}
+	if desktop.Status.PreemptionPending || !desktop.Status.OnDemand || desktop.Status.Running {
+		t.Error("Expected the session to be relaunched on demand, got:", desktop.Status)
+	}
+	if tmpl.RunsOnSpot(desktop) {
+		t.Error("Expected the relaunched session to no longer run on spot")
+	}
+
+	// sessions already on demand are left alone
+	if err := r.reconcileSpotPreemption(context.TODO(), testLogger, cluster, tmpl, desktop, pod); err != nil {
+		t.Error("Expected no action for an on-demand session, got:", err)
+	}
+}
+
+func isRequeue(err error) bool {
+	_, ok := errors.IsRequeueError(err)
+	return ok
+}
+
+func TestRecordRolloutOutcome(t *testing.T) {
+	r := newReconciler(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.DesktopConfig = &desktopsv1.DesktopConfig{Image: "stable"}
+	tmpl.Spec.Rollout = &desktopsv1.RolloutStrategy{
+		Canary:           &desktopsv1.DesktopConfig{Image: "canary"},
+		SuccessThreshold: 2,
+		FailureThreshold: 2,
+	}
+	if err := r.client.Create(context.TODO(), tmpl); err != nil {
+		t.Fatal(err)
+	}
+	rev := tmpl.GetCanaryRevision()
+
+	launch := func(name string, succeeded bool) {
+		t.Helper()
+		desktop := newDesktop(t)
+		desktop.Name = name
+		desktop.Spec.CanaryRevision = rev
+		if err := r.client.Create(context.TODO(), desktop); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.recordRolloutOutcome(context.TODO(), testLogger, desktop, succeeded); err != nil {
+			t.Fatal(err)
+		}
+		if !desktop.Status.RolloutRecorded {
+			t.Error("Expected the outcome to be recorded on the session")
+		}
+		// outcomes are only counted once per session
+		if err := r.recordRolloutOutcome(context.TODO(), testLogger, desktop, succeeded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getTemplate := func() *desktopsv1.Template {
+		t.Helper()
+		found := &desktopsv1.Template{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: tmpl.GetName()}, found); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	launch("canary-1", true)
+	launch("canary-2", false)
+	if status := getTemplate().GetRolloutStatus(); status.Phase != desktopsv1.RolloutProgressing || status.Successes != 1 || status.Failures != 1 {
+		t.Fatal("Expected one success and one failure while progressing, got:", status)
+	}
+
+	launch("canary-3", true)
+	found := getTemplate()
+	if found.GetDesktopImage() != "canary" {
+		t.Error("Expected the canary to be promoted after reaching the success threshold, got:", found.GetDesktopImage())
+	}
+
+	// a new canary that fails is rolled back without touching the stable configuration
+	found.Spec.Rollout.Canary = &desktopsv1.DesktopConfig{Image: "broken"}
+	if err := r.client.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	rev = found.GetCanaryRevision()
+	launch("broken-1", false)
+	launch("broken-2", false)
+	found = getTemplate()
+	if status := found.GetRolloutStatus(); status.Phase != desktopsv1.RolloutRolledBack {
+		t.Error("Expected the canary to be rolled back after reaching the failure threshold, got:", status)
+	}
+	if found.GetDesktopImage() != "canary" || found.SelectRevision(0) != "" {
+		t.Error("Expected new launches to use the stable configuration after a rollback")
+	}
+}
+
+func TestAdmitBoot(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{MaxConcurrentBootsPerNode: 1}
+
+	newPod := func(name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Name = name
+		pod.Namespace = "test-namespace"
+		pod.Labels = map[string]string{v1.VDIClusterLabel: cluster.GetName(), v1.ComponentLabel: "desktop"}
+		pod.Spec.NodeName = "node-1"
+		pod.Status.Phase = corev1.PodPending
+		if err := r.client.Create(context.TODO(), pod); err != nil {
+			t.Fatal(err)
+		}
+		return pod
+	}
+
+	booting := newPod("booting")
+	if err := r.admitBoot(context.TODO(), testLogger, cluster, booting); err != nil {
+		t.Fatal(err)
+	}
+	if !bootAdmitted(booting) || booting.Annotations[v1.BootAdmittedAtAnnotation] == "" {
+		t.Fatal("Expected the first pod to be admitted with its admission time, got:", booting.Annotations)
+	}
+
+	// the node is full while the first pod boots
+	waiting := newPod("waiting")
+	if err := r.admitBoot(context.TODO(), testLogger, cluster, waiting); !isRequeue(err) {
+		t.Fatal("Expected the second pod to wait for a boot slot, got:", err)
+	}
+
+	// a pod stuck pulling its image releases its slot
+	booting.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+	}
+	if err := r.client.Status().Update(context.TODO(), booting); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.admitBoot(context.TODO(), testLogger, cluster, waiting); err != nil {
+		t.Fatal("Expected the second pod to be admitted once the first failed, got:", err)
+	}
+
+	// a pod that has been booting longer than the timeout releases its slot
+	late := newPod("late")
+	if err := r.admitBoot(context.TODO(), testLogger, cluster, late); !isRequeue(err) {
+		t.Fatal("Expected the third pod to wait for a boot slot, got:", err)
+	}
+	waiting.Annotations[v1.BootAdmittedAtAnnotation] = time.Now().Add(-bootSlotTimeout).UTC().Format(time.RFC3339)
+	if err := r.client.Update(context.TODO(), waiting); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.admitBoot(context.TODO(), testLogger, cluster, late); err != nil {
+		t.Fatal("Expected the third pod to be admitted once the second timed out, got:", err)
+	}
+}
+
+func TestReconcileDomainJoin(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{
+		DomainJoin: &appv1.DomainJoinConfig{Realm: "CORP.EXAMPLE.COM", KDCs: []string{"dc1.corp.example.com"}},
+	}
+	if err := r.client.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	hosts := domain.NewManager(secretsEngine)
+	for _, host := range []string{"vdi-01", "vdi-02"} {
+		if err := hosts.SetKeytab(host, []byte("keytab-"+host)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	desktops := make([]*desktopsv1.Session, 2)
+	for i := range desktops {
+		desktops[i] = newDesktop(t)
+		desktops[i].Name = fmt.Sprintf("test-desktop-%d", i)
+		if err := r.client.Create(context.TODO(), desktops[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.reconcileDomainJoinSecret(context.TODO(), testLogger, secretsEngine, cluster, desktops[i]); err != nil {
+			t.Fatal(err)
+		}
+		if !common.StringSliceContains(desktops[i].GetFinalizers(), domainHostReleaseFinalizer) {
+			t.Error("Expected the domain host finalizer on the session")
+		}
+	}
+
+	// every desktop gets the keytab of its own host
+	for i, host := range []string{"vdi-01", "vdi-02"} {
+		secret := &corev1.Secret{}
+		nn := types.NamespacedName{Name: desktops[i].GetDomainJoinSecretName(), Namespace: desktops[i].GetNamespace()}
+		if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+			t.Fatal(err)
+		}
+		if string(secret.Data["krb5.keytab"]) != "keytab-"+host {
+			t.Errorf("Expected %s to hold the keytab of %s, got %q", desktops[i].GetName(), host, string(secret.Data["krb5.keytab"]))
+		}
+		if !strings.Contains(string(secret.Data["sssd.conf"]), fmt.Sprintf("ad_hostname = %s.corp.example.com", host)) {
+			t.Error("Expected sssd to use the leased host, got:", string(secret.Data["sssd.conf"]))
+		}
+	}
+
+	// a third desktop cannot join until a host is released
+	extra := newDesktop(t)
+	extra.Name = "test-desktop-extra"
+	if err := r.client.Create(context.TODO(), extra); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileDomainJoinSecret(context.TODO(), testLogger, secretsEngine, cluster, extra); err == nil {
+		t.Error("Expected error when every host is leased")
+	}
+	if err := r.releaseDomainHost(desktops[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileDomainJoinSecret(context.TODO(), testLogger, secretsEngine, cluster, extra); err != nil {
+		t.Error("Expected the released host to be leased, got:", err)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// canaryLaunchesTotal tracks the outcome of sessions launched on canary template revisions.
+var canaryLaunchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kvdi",
+	Name:      "template_canary_launches_total",
+	Help:      "Total number of sessions launched on canary template revisions by template, revision, and outcome.",
+}, []string{"template", "revision", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(canaryLaunchesTotal)
+}
+
+// canaryFailureReasons are the container waiting reasons that count as a failed launch.
+var canaryFailureReasons = map[string]struct{}{
+	"ErrImagePull":               {},
+	"ImagePullBackOff":           {},
+	"InvalidImageName":           {},
+	"CrashLoopBackOff":           {},
+	"CreateContainerConfigError": {},
+	"CreateContainerError":       {},
+}
+
+// launchFailed returns true if the given desktop pod failed to start.
+func launchFailed(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if _, ok := canaryFailureReasons[status.State.Waiting.Reason]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRolloutOutcome records whether a session launched on a canary revision started
+// successfully. The results are tallied on the template's rollout status, and the revision
+// is promoted or rolled back once the configured thresholds are reached.
+func (f *Reconciler) recordRolloutOutcome(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Session, succeeded bool) error {
+	if instance.Spec.CanaryRevision == "" || instance.Status.RolloutRecorded {
+		return nil
+	}
+
+	// fetch a fresh copy of the template, the one used for reconciling may have its
+	// desktop configuration swapped for the canary revision
+	template, err := instance.GetTemplate(f.client)
+	if err != nil {
+		return err
+	}
+
+	outcome := "succeeded"
+	if !succeeded {
+		outcome = "failed"
+	}
+	canaryLaunchesTotal.WithLabelValues(template.GetName(), instance.Spec.CanaryRevision, outcome).Inc()
+
+	if template.GetCanaryRevision() == instance.Spec.CanaryRevision {
+		status := template.GetRolloutStatus()
+		if status.Phase == desktopsv1.RolloutProgressing {
+			if succeeded {
+				status.Successes++
+			} else {
+				status.Failures++
+			}
+			switch {
+			case status.Failures >= template.GetCanaryFailureThreshold():
+				reqLogger.Info(fmt.Sprintf("Canary revision %s failed %d launches, rolling back", status.Revision, status.Failures))
+				status.Phase = desktopsv1.RolloutRolledBack
+			case status.Successes >= template.GetCanarySuccessThreshold():
+				reqLogger.Info(fmt.Sprintf("Canary revision %s succeeded %d launches, promoting", status.Revision, status.Successes))
+				template.Promote()
+				if err := f.client.Update(ctx, template); err != nil {
+					return err
+				}
+				status.Phase = desktopsv1.RolloutPromoted
+			}
+			template.Status.Rollout = status
+			if err := f.client.Status().Update(ctx, template); err != nil {
+				return err
+			}
+		}
+	}
+
+	instance.Status.RolloutRecorded = true
+	return f.client.Status().Update(ctx, instance)
+}
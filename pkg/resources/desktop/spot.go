@@ -0,0 +1,160 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// preemptedPodReasons are the pod status reasons set when a node shuts down underneath
+// its pods.
+var preemptedPodReasons = map[string]struct{}{
+	"Shutdown":     {},
+	"NodeShutdown": {},
+	"Terminated":   {},
+	"NodeLost":     {},
+}
+
+// reconcileSpotPreemption watches for preemption notices on the spot node running the
+// session. When one is received the user is warned through the session status, their
+// userdata volume is optionally snapshotted, and once the warning period ends the session
+// is marked to be relaunched on on-demand capacity.
+func (f *Reconciler) reconcileSpotPreemption(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session, pod *corev1.Pod) error {
+	if !template.RunsOnSpot(instance) || pod.Spec.NodeName == "" {
+		return nil
+	}
+
+	notice, nodeGone, err := f.getPreemptionNotice(ctx, template, pod)
+	if err != nil {
+		return err
+	}
+	if notice == "" {
+		return nil
+	}
+
+	now := time.Now()
+	if !instance.Status.PreemptionPending {
+		reqLogger.Info(fmt.Sprintf("Spot node %s is being preempted (%s), warning the user", pod.Spec.NodeName, notice))
+		instance.Status.PreemptionPending = true
+		instance.Status.PreemptionNoticeTime = metav1.NewTime(now)
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+		if err := f.snapshotUserdata(ctx, reqLogger, cluster, template, instance, pod); err != nil {
+			reqLogger.Error(err, "Failed to snapshot userdata volume for preempted session")
+		}
+	}
+
+	relaunchAt := instance.Status.PreemptionNoticeTime.Add(template.GetSpotWarningPeriod())
+	if !nodeGone && now.Before(relaunchAt) {
+		return errors.NewRequeueError("Waiting for the preemption warning period to end", int(relaunchAt.Sub(now).Seconds())+1)
+	}
+
+	reqLogger.Info("Relaunching preempted session on on-demand capacity")
+	instance.Status.PreemptionPending = false
+	instance.Status.OnDemand = true
+	instance.Status.Running = false
+	if err := f.client.Status().Update(ctx, instance); err != nil {
+		return err
+	}
+	// the desired pod spec no longer targets spot nodes, so the next reconcile recreates it
+	return errors.NewRequeueError("Desktop pod is being relaunched on on-demand capacity", 1)
+}
+
+// getPreemptionNotice returns a description of the preemption notice for the node running
+// the pod, if any, and whether the node has already gone away.
+func (f *Reconciler) getPreemptionNotice(ctx context.Context, template *desktopsv1.Template, pod *corev1.Pod) (notice string, nodeGone bool, err error) {
+	if pod.Status.Phase == corev1.PodFailed {
+		if _, ok := preemptedPodReasons[pod.Status.Reason]; ok {
+			return fmt.Sprintf("pod %s", pod.Status.Reason), true, nil
+		}
+	}
+	node := &corev1.Node{}
+	if err := f.client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return "node removed", true, nil
+		}
+		return "", false, err
+	}
+	if node.GetDeletionTimestamp() != nil {
+		return "node deleted", false, nil
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range template.GetPreemptionTaints() {
+			if taint.Key == key {
+				return fmt.Sprintf("taint %s", taint.Key), false, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// snapshotUserdata takes a VolumeSnapshot of the userdata volume mounted in the pod, if the
+// template configures a snapshot class. Snapshots are owned by the session.
+func (f *Reconciler) snapshotUserdata(ctx context.Context, reqLogger logr.Logger, cluster *appv1.VDICluster, template *desktopsv1.Template, instance *desktopsv1.Session, pod *corev1.Pod) error {
+	className := template.GetSpotVolumeSnapshotClassName()
+	if className == "" {
+		return nil
+	}
+	var claimName string
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Name == v1.HomeVolume && vol.PersistentVolumeClaim != nil {
+			claimName = vol.PersistentVolumeClaim.ClaimName
+		}
+	}
+	if claimName == "" {
+		return nil
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetAPIVersion("snapshot.storage.k8s.io/v1")
+	snapshot.SetKind("VolumeSnapshot")
+	snapshot.SetName(fmt.Sprintf("%s-preempted-%d", instance.GetName(), instance.Status.PreemptionNoticeTime.Unix()))
+	snapshot.SetNamespace(instance.GetNamespace())
+	snapshot.SetLabels(cluster.GetUserDesktopSelector(instance.GetUser()))
+	snapshot.SetOwnerReferences(instance.OwnerReferences())
+	if err := unstructured.SetNestedField(snapshot.Object, className, "spec", "volumeSnapshotClassName"); err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(snapshot.Object, claimName, "spec", "source", "persistentVolumeClaimName"); err != nil {
+		return err
+	}
+
+	reqLogger.Info("Snapshotting userdata volume for preempted session", "PVC", claimName, "Snapshot", snapshot.GetName())
+	if err := f.client.Create(ctx, snapshot); err != nil && !kerrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+	"github.com/tinyzimmer/kvdi/pkg/util/wol"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileStaticHostSecret copies the credentials for the template's static host from the
+// secrets backend into a secret for the proxy or RDP bridge.
+func (f *Reconciler) reconcileStaticHostSecret(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	return f.reconcileCredentialsSecret(ctx, reqLogger, secretsEngine, "static host", template.GetStaticHostCredentialsSecret(), instance.GetStaticHostSecretName(), instance)
+}
+
+// reconcileRDPSecret copies the credentials for the RDP server of the template's desktops
+// from the secrets backend into a secret for the proxy.
+func (f *Reconciler) reconcileRDPSecret(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	return f.reconcileCredentialsSecret(ctx, reqLogger, secretsEngine, "RDP server", template.GetRDPCredentialsSecret(), instance.GetRDPSecretName(), instance)
+}
+
+// reconcileCredentialsSecret copies the credentials stored at the given key in the secrets
+// backend into the secret with the given name. The credentials must contain a password.
+func (f *Reconciler) reconcileCredentialsSecret(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, target, key, name string, instance *desktopsv1.Session) error {
+	creds, err := secretsEngine.ReadSecretMap(key, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return fmt.Errorf("No credentials for the %s have been stored at %q", target, key)
+		}
+		return err
+	}
+	if len(creds[desktopsv1.StaticHostPasswordKey]) == 0 {
+		return fmt.Errorf("The credentials for the %s stored at %q do not contain a password", target, key)
+	}
+
+	data := make(map[string][]byte)
+	for _, k := range []string{desktopsv1.StaticHostUsernameKey, desktopsv1.StaticHostPasswordKey, desktopsv1.StaticHostDomainKey} {
+		if v, ok := creds[k]; ok {
+			data[k] = v
+		}
+	}
+
+	return reconcile.Secret(ctx, reqLogger, f.client, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       instance.GetNamespace(),
+			Labels:          instance.GetLabels(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: data,
+	})
+}
+
+// staticHostDialTimeout is how long to wait when checking if a static host is accepting
+// connections.
+var staticHostDialTimeout = time.Second * 3
+
+// staticHostPollInterval is how often to check if a booting static host is accepting
+// connections.
+var staticHostPollInterval = 5
+
+// reconcileStaticHostPower powers on the template's static host if it is not accepting
+// connections and requeues until it is. The session is marked as booting in the meantime.
+func (f *Reconciler) reconcileStaticHostPower(ctx context.Context, reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	addr := template.GetStaticHostAddress()
+
+	if staticHostIsUp(addr) {
+		if instance.Status.Booting {
+			reqLogger.Info("Static host is accepting connections", "Address", addr)
+			instance.Status.Booting = false
+			return f.client.Status().Update(ctx, instance)
+		}
+		return nil
+	}
+
+	if instance.Status.Booting {
+		timeout := template.GetStaticHostBootTimeout()
+		if time.Since(instance.Status.PowerOnTime.Time) < timeout {
+			return errors.NewRequeueError("Static host is booting", staticHostPollInterval)
+		}
+		reqLogger.Info(fmt.Sprintf("Static host did not accept connections within %s, powering it on again", timeout), "Address", addr)
+	} else {
+		reqLogger.Info("Static host is not accepting connections, powering it on", "Address", addr)
+	}
+
+	if err := powerOnStaticHost(ctx, secretsEngine, template, instance); err != nil {
+		return err
+	}
+
+	instance.Status.Booting = true
+	instance.Status.PowerOnTime = metav1.Now()
+	if err := f.client.Status().Update(ctx, instance); err != nil {
+		return err
+	}
+	return errors.NewRequeueError("Static host is booting", staticHostPollInterval)
+}
+
+// staticHostIsUp returns true if the given address is accepting connections.
+func staticHostIsUp(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, staticHostDialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// powerOnStaticHost sends a magic packet and/or calls the webhook configured to power on
+// the template's static host.
+func powerOnStaticHost(ctx context.Context, secretsEngine *secrets.SecretEngine, template *desktopsv1.Template, instance *desktopsv1.Session) error {
+	if cfg := template.GetStaticHostWakeOnLAN(); cfg != nil {
+		if err := wol.Wake(cfg.MACAddress, cfg.GetBroadcastAddress()); err != nil {
+			return fmt.Errorf("Failed to send wake-on-LAN packet: %s", err.Error())
+		}
+	}
+
+	hook := template.GetStaticHostPowerOnWebhook()
+	if hook == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"address": template.GetStaticHostAddress(),
+		"session": fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName()),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, hook.GetMethod(), hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.TokenSecret != "" {
+		token, err := secretsEngine.ReadSecret(hook.TokenSecret, false)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	client := &http.Client{Timeout: time.Second * 30}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to call power-on webhook: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Power-on webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/ticketing"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ticketRoutines tracks the sessions that have a goroutine revalidating their ticket.
+var ticketRoutines = make(map[types.UID]struct{})
+
+// watchTicket periodically revalidates the ticket the session was launched against and
+// destroys the session once the ticket is closed or expires. Failures reaching the
+// ticketing system do not terminate the session.
+func (f *Reconciler) watchTicket(reqLogger logr.Logger, cluster *appv1.VDICluster, instance *desktopsv1.Session) {
+	ctx := context.Background()
+
+	reqLogger.Info("Starting ticket revalidation for desktop instance", "Ticket", instance.Spec.Ticket)
+
+	// make sure to clean the global map on return
+	defer func() { delete(ticketRoutines, instance.GetUID()) }()
+
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+	interval := cluster.GetTicketRevalidateInterval()
+
+	for {
+		wait := interval
+
+		session := &desktopsv1.Session{}
+		if err := f.client.Get(ctx, nn, session); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				reqLogger.Info("Desktop instance has been deleted, stopping ticket revalidation")
+				return
+			}
+			reqLogger.Error(err, fmt.Sprintf("Error polling desktop instance: %s", err.Error()))
+			time.Sleep(wait)
+			continue
+		}
+
+		resp, err := f.validateTicket(cluster, session)
+		if err != nil {
+			reqLogger.Error(err, "Failed to revalidate ticket, will retry")
+		} else if terr := resp.Err(session.Spec.Ticket, time.Now()); terr != nil {
+			reqLogger.Info("Ticket no longer allows the session, destroying instance", "Ticket", session.Spec.Ticket, "Reason", terr.Error())
+			if err := f.client.Delete(ctx, session); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					reqLogger.Error(err, fmt.Sprintf("Error destroying desktop instance: %s", err.Error()))
+					time.Sleep(wait)
+					continue
+				}
+			}
+			return
+		} else if resp.ExpiresAt != nil {
+			if !session.Status.TicketExpiresAt.Time.Equal(*resp.ExpiresAt) {
+				session.Status.TicketExpiresAt = metav1.NewTime(*resp.ExpiresAt)
+				if err := f.client.Status().Update(ctx, session); err != nil {
+					reqLogger.Error(err, "Failed to record ticket expiry")
+				}
+			}
+			// wake up in time to terminate the session when the ticket expires
+			if until := time.Until(*resp.ExpiresAt); until < wait {
+				wait = until
+			}
+		}
+
+		time.Sleep(wait)
+	}
+}
+
+// validateTicket sends the session's ticket to the cluster's ticketing system.
+func (f *Reconciler) validateTicket(cluster *appv1.VDICluster, instance *desktopsv1.Session) (*ticketing.Response, error) {
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(f.client, cluster); err != nil {
+		return nil, err
+	}
+	defer secretsEngine.Close()
+	validator, err := ticketing.New(cluster, secretsEngine)
+	if err != nil {
+		return nil, err
+	}
+	return validator.Validate(&ticketing.Request{
+		Ticket:   instance.Spec.Ticket,
+		User:     instance.GetUser(),
+		Template: instance.GetTemplateName(),
+		Session:  fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName()),
+	})
+}
@@ -21,15 +21,18 @@ package desktop
 
 import (
 	"context"
+	"fmt"
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -52,7 +55,23 @@ func (f *Reconciler) reconcileVolumes(ctx context.Context, reqLogger logr.Logger
 			existingVol = ""
 		}
 	}
+	var dataSource *corev1.TypedLocalObjectReference
+	if name := instance.GetFromSnapshot(); name != "" {
+		snapshot := &desktopsv1.DesktopSnapshot{}
+		if err := f.client.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.GetNamespace()}, snapshot); err != nil {
+			return err
+		}
+		if dataSource = snapshot.GetDataSource(v1.HomeVolume); dataSource == nil {
+			return fmt.Errorf("DesktopSnapshot %s does not contain the user's $HOME", name)
+		}
+		// the restored volume takes the place of the user's current one
+		reqLogger.Info("Restoring userdata volume from snapshot", "Snapshot", name)
+		existingVol = ""
+	}
 	pvc := newPVCForUser(cluster, instance, existingVol)
+	if dataSource != nil {
+		pvc.Spec.DataSource = dataSource
+	}
 	return reconcile.PersistentVolumeClaim(ctx, reqLogger, f.client, pvc)
 }
 
@@ -113,10 +132,21 @@ func newConfigMapForCluster(cluster *appv1.VDICluster) *corev1.ConfigMap {
 }
 
 func newPVCForUser(cluster *appv1.VDICluster, instance *desktopsv1.Session, existingPVName string) *corev1.PersistentVolumeClaim {
-	spec := cluster.GetUserdataVolumeSpec()
+	spec := cluster.GetUserdataVolumeSpec().DeepCopy()
 	if existingPVName != "" {
 		spec.VolumeName = existingPVName
 	}
+	// size new volumes no larger than the userdata quota, so that writes made from
+	// inside the desktop are bounded by the filesystem
+	if quota := cluster.GetUserdataQuota(); quota > 0 {
+		limit := resource.NewQuantity(quota, resource.BinarySI)
+		if requested, ok := spec.Resources.Requests[corev1.ResourceStorage]; !ok || requested.Cmp(*limit) > 0 {
+			if spec.Resources.Requests == nil {
+				spec.Resources.Requests = corev1.ResourceList{}
+			}
+			spec.Resources.Requests[corev1.ResourceStorage] = *limit
+		}
+	}
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            cluster.GetUserdataVolumeName(instance.GetUser()),
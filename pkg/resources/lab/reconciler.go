@@ -0,0 +1,222 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lab
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/resources"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rbac"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reconciler implements a reconciler for Lab related resources.
+type Reconciler struct {
+	resources.LabReconciler
+
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+var _ resources.LabReconciler = &Reconciler{}
+
+// New returns a new Lab reconciler
+func New(c client.Client, s *runtime.Scheme) *Reconciler {
+	return &Reconciler{client: c, scheme: s}
+}
+
+// Reconcile ensures the shared volumes for a lab, and that a desktop session exists
+// for every member of the roster while the lab is scheduled.
+func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.Lab) error {
+	if instance.GetDeletionTimestamp() != nil {
+		// sessions and volumes are garbage collected through their owner references
+		return nil
+	}
+
+	cluster, err := instance.GetVDICluster(f.client)
+	if err != nil {
+		return err
+	}
+	template, err := instance.GetTemplate(f.client)
+	if err != nil {
+		return err
+	}
+
+	// refuse to run the lab across tenants
+	if err := tenancy.CheckTemplate(cluster, template); err != nil {
+		return err
+	}
+	if err := tenancy.CheckNamespace(ctx, f.client, cluster, instance.GetNamespace()); err != nil {
+		return err
+	}
+
+	reqLogger.Info("Reconciling shared volumes for lab")
+	for i := range instance.Spec.SharedVolumes {
+		vol := &instance.Spec.SharedVolumes[i]
+		if vol.ExistingClaim != "" {
+			continue
+		}
+		if vol.ClaimSpec == nil {
+			return fmt.Errorf("Shared volume %s must provide either an existing claim or a claim spec", vol.Name)
+		}
+		if err := reconcile.PersistentVolumeClaim(ctx, reqLogger, f.client, newPVCForSharedVolume(instance, vol)); err != nil {
+			return err
+		}
+	}
+
+	roster, err := f.getRoster(cluster, instance)
+	if err != nil {
+		return err
+	}
+
+	sessions := &desktopsv1.SessionList{}
+	if err := f.client.List(ctx, sessions, client.InNamespace(instance.GetNamespace()), instance.GetSessionsSelector()); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	active := instance.IsScheduled(now)
+
+	// remove sessions for users no longer in the roster, or all of them if the lab is
+	// outside of its schedule
+	existing := make(map[string]*desktopsv1.Session)
+	for i := range sessions.Items {
+		sess := &sessions.Items[i]
+		if _, ok := roster[sess.GetUser()]; ok && active {
+			existing[sess.GetUser()] = sess
+			continue
+		}
+		if sess.GetDeletionTimestamp() != nil {
+			continue
+		}
+		reqLogger.Info("Removing lab session", "Session", sess.GetName(), "User", sess.GetUser())
+		if err := f.client.Delete(ctx, sess); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	if active {
+		for user := range roster {
+			if _, ok := existing[user]; ok {
+				continue
+			}
+			reqLogger.Info("Creating lab session", "User", user)
+			sess := newSessionForUser(instance, user)
+			if err := f.client.Create(ctx, sess); err != nil {
+				return err
+			}
+			existing[user] = sess
+		}
+	}
+
+	status := desktopsv1.LabStatus{
+		Active:            active,
+		Sessions:          make([]desktopsv1.LabSession, 0, len(existing)),
+		Locked:            instance.Status.Locked,
+		LockMessage:       instance.Status.LockMessage,
+		LastBroadcast:     instance.Status.LastBroadcast,
+		LastBroadcastTime: instance.Status.LastBroadcastTime,
+	}
+	for user, sess := range existing {
+		status.Sessions = append(status.Sessions, desktopsv1.LabSession{User: user, Name: sess.GetName()})
+	}
+	sort.Slice(status.Sessions, func(i, j int) bool { return status.Sessions[i].User < status.Sessions[j].User })
+	if !reflect.DeepEqual(status, instance.Status) {
+		instance.Status = status
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+
+	// come back when the schedule next changes
+	if next := instance.GetNextScheduleChange(now); !next.IsZero() {
+		return errors.NewRequeueError("Waiting for the next lab schedule change", int(next.Sub(now).Seconds())+1)
+	}
+	return nil
+}
+
+// getRoster returns the set of users in the lab's roster, including the members of
+// the roster's teams.
+func (f *Reconciler) getRoster(cluster *appv1.VDICluster, instance *desktopsv1.Lab) (map[string]struct{}, error) {
+	roster := make(map[string]struct{})
+	for _, user := range instance.GetRosterUsers() {
+		roster[user] = struct{}{}
+	}
+	if len(instance.GetRosterTeams()) == 0 {
+		return roster, nil
+	}
+	teams, err := cluster.GetTeams(f.client)
+	if err != nil {
+		return nil, err
+	}
+	tree := rbac.NewTeamTree(teams)
+	for _, team := range instance.GetRosterTeams() {
+		for _, user := range tree.TeamMembers(team) {
+			roster[user] = struct{}{}
+		}
+	}
+	return roster, nil
+}
+
+func newSessionForUser(instance *desktopsv1.Lab, user string) *desktopsv1.Session {
+	return &desktopsv1.Session{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName:    fmt.Sprintf("%s-", instance.GetTemplateName()),
+			Namespace:       instance.GetNamespace(),
+			Labels:          instance.GetSessionLabels(user),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: desktopsv1.SessionSpec{
+			VDICluster:     instance.Spec.VDICluster,
+			Template:       instance.GetTemplateName(),
+			User:           user,
+			ServiceAccount: instance.Spec.ServiceAccount,
+			Lab:            instance.GetName(),
+			SharedVolumes:  instance.GetSessionSharedVolumes(),
+		},
+	}
+}
+
+func newPVCForSharedVolume(instance *desktopsv1.Lab, vol *desktopsv1.LabSharedVolume) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetSharedVolumeClaimName(vol),
+			Namespace:       instance.GetNamespace(),
+			Labels:          instance.GetSessionsSelector(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: *vol.ClaimSpec,
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lab
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+func newReconciler(t *testing.T) *Reconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	desktopsv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	return New(fake.NewFakeClientWithScheme(scheme), scheme)
+}
+
+func newLab(t *testing.T) *desktopsv1.Lab {
+	t.Helper()
+	lab := &desktopsv1.Lab{}
+	lab.Name = "test-lab"
+	lab.Namespace = "test-namespace"
+	lab.Spec = desktopsv1.LabSpec{
+		VDICluster: "test-cluster",
+		Template:   "test-template",
+		Roster:     desktopsv1.LabRoster{Users: []string{"bob", "alice"}},
+		SharedVolumes: []desktopsv1.LabSharedVolume{
+			{
+				Name:      "handouts",
+				MountPath: "/mnt/handouts",
+				ReadOnly:  true,
+				ClaimSpec: &corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{"storage": resource.MustParse("1Gi")},
+					},
+				},
+			},
+		},
+	}
+	return lab
+}
+
+// mustSetup creates the cluster, template, and lab and returns the reconciler.
+func mustSetup(t *testing.T, lab *desktopsv1.Lab) *Reconciler {
+	t.Helper()
+	r := newReconciler(t)
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &desktopsv1.Template{}
+	tmpl.Name = "test-template"
+	for _, obj := range []client.Object{cluster, tmpl, lab} {
+		if err := r.client.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// listSessions returns the sessions of the lab keyed by user.
+func listSessions(t *testing.T, r *Reconciler, lab *desktopsv1.Lab) map[string]*desktopsv1.Session {
+	t.Helper()
+	sessions := &desktopsv1.SessionList{}
+	if err := r.client.List(context.TODO(), sessions, client.InNamespace(lab.GetNamespace()), lab.GetSessionsSelector()); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]*desktopsv1.Session)
+	for i := range sessions.Items {
+		out[sessions.Items[i].GetUser()] = &sessions.Items[i]
+	}
+	return out
+}
+
+func TestReconcile(t *testing.T) {
+	lab := newLab(t)
+	lab.Status.Locked = true
+	lab.Status.LockMessage = "Eyes on the instructor"
+	r := mustSetup(t, lab)
+
+	if err := r.Reconcile(context.TODO(), testLogger, lab); err != nil {
+		t.Fatal(err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	nn := types.NamespacedName{Name: lab.GetSharedVolumeClaimName(&lab.Spec.SharedVolumes[0]), Namespace: lab.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, pvc); err != nil {
+		t.Fatal("Expected the shared volume claim to be created, got:", err)
+	}
+
+	sessions := listSessions(t, r, lab)
+	if len(sessions) != 2 {
+		t.Fatal("Expected a session for each member of the roster, got:", sessions)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		sess, ok := sessions[user]
+		if !ok {
+			t.Fatal("Expected a session for", user)
+		}
+		if sess.GetLab() != lab.GetName() || sess.GetTemplateName() != "test-template" {
+			t.Error("Expected the session to belong to the lab, got:", sess.Spec)
+		}
+		if vols := sess.GetSharedVolumes(); len(vols) != 1 || vols[0].ClaimName != pvc.GetName() || !vols[0].ReadOnly {
+			t.Error("Expected the shared volume to be mounted read-only, got:", vols)
+		}
+	}
+
+	if !lab.Status.Active || len(lab.Status.Sessions) != 2 || lab.Status.Sessions[0].User != "alice" {
+		t.Error("Expected the active sessions sorted by user, got:", lab.Status)
+	}
+	if !lab.Status.Locked || lab.Status.LockMessage != "Eyes on the instructor" {
+		t.Error("Expected the lock state to be preserved, got:", lab.Status)
+	}
+
+	// members removed from the roster lose their sessions
+	lab.Spec.Roster.Users = []string{"alice"}
+	if err := r.Reconcile(context.TODO(), testLogger, lab); err != nil {
+		t.Fatal(err)
+	}
+	sessions = listSessions(t, r, lab)
+	if _, ok := sessions["bob"]; ok || len(sessions) != 1 {
+		t.Error("Expected only the session for alice to remain, got:", sessions)
+	}
+	if len(lab.Status.Sessions) != 1 {
+		t.Error("Expected the status to list only alice, got:", lab.Status.Sessions)
+	}
+}
+
+func TestReconcileSchedule(t *testing.T) {
+	lab := newLab(t)
+	// a window that opened yesterday and is already closed
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	lab.Spec.Schedule = []desktopsv1.MaintenanceWindow{
+		{Days: []string{yesterday.Weekday().String()}, StartTime: "00:00", Duration: "1h"},
+	}
+	r := mustSetup(t, lab)
+
+	err := r.Reconcile(context.TODO(), testLogger, lab)
+	if err == nil {
+		t.Fatal("Expected a requeue for the next schedule change")
+	}
+	if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected a requeue error, got:", err)
+	}
+	if lab.Status.Active {
+		t.Error("Expected the lab to be inactive outside of its schedule")
+	}
+	if sessions := listSessions(t, r, lab); len(sessions) != 0 {
+		t.Error("Expected no sessions outside of the schedule, got:", sessions)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/resources"
+	"github.com/tinyzimmer/kvdi/pkg/tenancy"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reconciler implements a reconciler for DesktopPool related resources.
+type Reconciler struct {
+	resources.DesktopPoolReconciler
+
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+var _ resources.DesktopPoolReconciler = &Reconciler{}
+
+// New returns a new DesktopPool reconciler
+func New(c client.Client, s *runtime.Scheme) *Reconciler {
+	return &Reconciler{client: c, scheme: s}
+}
+
+// Reconcile ensures the pool has the desired number of unclaimed desktops. Desktops claimed
+// by a launch leave the pool and are replaced here.
+func (f *Reconciler) Reconcile(ctx context.Context, reqLogger logr.Logger, instance *desktopsv1.DesktopPool) error {
+	if instance.GetDeletionTimestamp() != nil {
+		// unclaimed sessions are garbage collected through their owner references
+		return nil
+	}
+
+	cluster, err := instance.GetVDICluster(f.client)
+	if err != nil {
+		return err
+	}
+	template, err := instance.GetTemplate(f.client)
+	if err != nil {
+		return err
+	}
+
+	// refuse to run the pool across tenants
+	if err := tenancy.CheckTemplate(cluster, template); err != nil {
+		return err
+	}
+	if err := tenancy.CheckNamespace(ctx, f.client, cluster, instance.GetNamespace()); err != nil {
+		return err
+	}
+
+	if !template.CanPool() {
+		return fmt.Errorf("Template %s requires user input or credentials to boot a desktop and cannot be pooled", template.GetName())
+	}
+	if cluster.UserdataIsEnabled() {
+		return fmt.Errorf("VDICluster %s persists user data, which pooled desktops cannot mount", cluster.GetName())
+	}
+
+	sessions := &desktopsv1.SessionList{}
+	if err := f.client.List(ctx, sessions, client.InNamespace(instance.GetNamespace()), instance.GetSessionsSelector()); err != nil {
+		return err
+	}
+
+	unclaimed := make([]*desktopsv1.Session, 0, len(sessions.Items))
+	for i := range sessions.Items {
+		sess := &sessions.Items[i]
+		if !sess.IsPooled() || sess.GetDeletionTimestamp() != nil {
+			continue
+		}
+		unclaimed = append(unclaimed, sess)
+	}
+
+	// remove the newest sessions first when the pool has shrunk, they are the least
+	// likely to be ready
+	sort.Slice(unclaimed, func(i, j int) bool {
+		ti, tj := unclaimed[i].GetCreationTimestamp(), unclaimed[j].GetCreationTimestamp()
+		return ti.Before(&tj)
+	})
+	for len(unclaimed) > instance.GetSize() {
+		sess := unclaimed[len(unclaimed)-1]
+		reqLogger.Info("Removing unclaimed pool session", "Session", sess.GetName())
+		if err := f.client.Delete(ctx, sess); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		unclaimed = unclaimed[:len(unclaimed)-1]
+	}
+
+	for len(unclaimed) < instance.GetSize() {
+		reqLogger.Info("Creating pool session", "Template", instance.GetTemplateName())
+		sess := newPoolSession(instance)
+		if err := f.client.Create(ctx, sess); err != nil {
+			return err
+		}
+		unclaimed = append(unclaimed, sess)
+	}
+
+	status := desktopsv1.DesktopPoolStatus{}
+	for _, sess := range unclaimed {
+		if sess.Status.Running {
+			status.Ready++
+		} else {
+			status.Pending++
+		}
+	}
+	if status != instance.Status {
+		instance.Status = status
+		if err := f.client.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+	}
+
+	// the session controller updates the running state of the desktops, check back on
+	// the pool until they are all ready
+	if status.Pending > 0 {
+		return errors.NewRequeueError("Waiting for pool sessions to become ready", 10)
+	}
+	return nil
+}
+
+func newPoolSession(instance *desktopsv1.DesktopPool) *desktopsv1.Session {
+	return &desktopsv1.Session{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName:    fmt.Sprintf("%s-pool-", instance.GetTemplateName()),
+			Namespace:       instance.GetNamespace(),
+			Labels:          instance.GetSessionsSelector(),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: desktopsv1.SessionSpec{
+			VDICluster:     instance.Spec.VDICluster,
+			Template:       instance.GetTemplateName(),
+			ServiceAccount: instance.Spec.ServiceAccount,
+			Pool:           instance.GetName(),
+		},
+	}
+}
@@ -39,3 +39,13 @@ type VDIReconciler interface {
 type DesktopReconciler interface {
 	Reconcile(context.Context, logr.Logger, *desktopsv1.Session) error
 }
+
+// LabReconciler represents an interface for ensuring resources for a lab.
+type LabReconciler interface {
+	Reconcile(context.Context, logr.Logger, *desktopsv1.Lab) error
+}
+
+// DesktopPoolReconciler represents an interface for ensuring resources for a desktop pool.
+type DesktopPoolReconciler interface {
+	Reconcile(context.Context, logr.Logger, *desktopsv1.DesktopPool) error
+}
@@ -38,6 +38,7 @@ type Provider struct {
 	common.SecretsProvider
 
 	crConfig    *appv1.VaultConfig
+	secretsPath string
 	vaultConfig *api.Config
 	client      *api.Client
 	stopCh      chan struct{}
@@ -61,6 +62,7 @@ func New() *Provider {
 func (p *Provider) Setup(client client.Client, cluster *appv1.VDICluster) error {
 	var err error
 	p.crConfig = cluster.Spec.Secrets.Vault
+	p.secretsPath = cluster.GetVaultSecretsPath()
 	p.vaultConfig, err = buildConfig(p.crConfig)
 	if err != nil {
 		return err
@@ -98,5 +98,5 @@ func (p *Provider) WriteSecretMap(name string, content map[string][]byte) error
 
 // getSecretPath returns the path to a given secret name in vault.
 func (p *Provider) getSecretPath(name string) string {
-	return fmt.Sprintf("%s/%s", p.crConfig.GetSecretsPath(), name)
+	return fmt.Sprintf("%s/%s", p.secretsPath, name)
 }
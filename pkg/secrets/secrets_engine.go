@@ -27,7 +27,9 @@ import (
 
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 
+	"github.com/tinyzimmer/kvdi/pkg/types"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/faults"
 	"github.com/tinyzimmer/kvdi/pkg/util/lock"
 
 	"github.com/tinyzimmer/kvdi/pkg/secrets/common"
@@ -156,6 +158,9 @@ func (s *SecretEngine) writeCacheMap(name string, contents map[string][]byte) {
 // the cache will be checked first, and if not found then the backend will be queried.
 // The secret is unconditionally written to the cache after retrieval.
 func (s *SecretEngine) ReadSecret(name string, cache bool) ([]byte, error) {
+	if err := faults.Inject(types.FaultPointSecretsRead); err != nil {
+		return nil, err
+	}
 	if cache {
 		if val := s.readCache(name); val != nil {
 			return val, nil
@@ -173,6 +178,9 @@ func (s *SecretEngine) ReadSecret(name string, cache bool) ([]byte, error) {
 // the cache will be checked first, and if not found the backend will be queried. The result
 // is then unconditionally written to the cache.
 func (s *SecretEngine) ReadSecretMap(name string, cache bool) (map[string][]byte, error) {
+	if err := faults.Inject(types.FaultPointSecretsRead); err != nil {
+		return nil, err
+	}
 	if cache {
 		if val := s.readCacheMap(name); val != nil {
 			return val, nil
@@ -0,0 +1,168 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package staging
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// diffObjects returns the fields that differ between the JSON representations of a and b.
+// Paths are prefixed with the given root.
+func diffObjects(root string, a, b interface{}) ([]*types.FieldChange, error) {
+	av, err := toJSONValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := toJSONValue(b)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*types.FieldChange, 0)
+	diffValues(root, av, bv, &changes)
+	return changes, nil
+}
+
+// diffValues walks a and b together and appends a change for every leaf that differs.
+// Lists of different lengths are reported as a single change.
+func diffValues(path string, a, b interface{}, out *[]*types.FieldChange) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffValues(joinPath(path, k), am[k], bm[k], out)
+		}
+		return
+	}
+	as, aIsList := a.([]interface{})
+	bs, bIsList := b.([]interface{})
+	if aIsList && bIsList && len(as) == len(bs) {
+		for i := range as {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), as[i], bs[i], out)
+		}
+		return
+	}
+	*out = append(*out, &types.FieldChange{Path: path, From: encodeValue(a), To: encodeValue(b)})
+}
+
+// diffResources compares the resources desired for the current and proposed specs.
+func diffResources(current, proposed []client.Object) ([]*types.ResourceDiff, error) {
+	currentByKey := make(map[string]client.Object, len(current))
+	for _, obj := range current {
+		currentByKey[resourceKey(obj)] = obj
+	}
+	seen := make(map[string]struct{}, len(proposed))
+	diffs := make([]*types.ResourceDiff, 0)
+
+	for _, obj := range proposed {
+		key := resourceKey(obj)
+		seen[key] = struct{}{}
+		existing, ok := currentByKey[key]
+		if !ok {
+			diffs = append(diffs, newResourceDiff(obj, types.ResourceCreate))
+			continue
+		}
+		changes, err := diffObjects("", existing, obj)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		diff := newResourceDiff(obj, types.ResourceUpdate)
+		diff.Changes = changes
+		diffs = append(diffs, diff)
+	}
+
+	for _, obj := range current {
+		if _, ok := seen[resourceKey(obj)]; !ok {
+			diffs = append(diffs, newResourceDiff(obj, types.ResourceDelete))
+		}
+	}
+	return diffs, nil
+}
+
+func newResourceDiff(obj client.Object, action types.ResourceDiffAction) *types.ResourceDiff {
+	return &types.ResourceDiff{
+		Kind:      resourceKind(obj),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Action:    action,
+	}
+}
+
+// resourceKind returns the kind of an object. The objects built by the reconciler do not
+// have their TypeMeta populated, so the kind is taken from the Go type.
+func resourceKind(obj client.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
+
+func resourceKey(obj client.Object) string {
+	return fmt.Sprintf("%s/%s/%s", resourceKind(obj), obj.GetNamespace(), obj.GetName())
+}
+
+func toJSONValue(obj interface{}) (interface{}, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	return out, json.Unmarshal(body, &out)
+}
+
+func encodeValue(val interface{}) string {
+	if val == nil {
+		return ""
+	}
+	body, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(body)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
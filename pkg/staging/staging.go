@@ -0,0 +1,206 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package staging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/preflight"
+	"github.com/tinyzimmer/kvdi/pkg/resources/app"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrNoStagedConfig is returned when a cluster has no staged revision.
+var ErrNoStagedConfig = errors.New("there is no staged configuration for the cluster")
+
+// ErrNoPreviousConfig is returned when a cluster has no promoted revision to roll back.
+var ErrNoPreviousConfig = errors.New("there is no previous configuration to roll back to")
+
+// GetStaged returns the revision staged on the given cluster.
+func GetStaged(cluster *appv1.VDICluster) (*types.StagedConfig, error) {
+	raw, ok := cluster.GetAnnotations()[v1.StagedConfigAnnotation]
+	if !ok || raw == "" {
+		return nil, ErrNoStagedConfig
+	}
+	staged := &types.StagedConfig{}
+	if err := json.Unmarshal([]byte(raw), staged); err != nil {
+		return nil, fmt.Errorf("could not decode the staged configuration: %s", err.Error())
+	}
+	return staged, nil
+}
+
+// Stage stores a proposed revision of the spec on the named cluster, replacing any
+// revision that was already staged.
+func Stage(ctx context.Context, c client.Client, clusterName string, spec appv1.VDIClusterSpec, user string) (*types.StagedConfig, error) {
+	cluster, err := k8sutil.LookupClusterByName(c, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	staged := &types.StagedConfig{
+		Spec:           spec,
+		StagedBy:       user,
+		StagedAt:       time.Now().Unix(),
+		BaseGeneration: cluster.GetGeneration(),
+	}
+	body, err := json.Marshal(staged)
+	if err != nil {
+		return nil, err
+	}
+	setAnnotation(cluster, v1.StagedConfigAnnotation, string(body))
+	return staged, c.Update(ctx, cluster)
+}
+
+// Discard removes the revision staged on the named cluster.
+func Discard(ctx context.Context, c client.Client, clusterName string) error {
+	cluster, err := k8sutil.LookupClusterByName(c, clusterName)
+	if err != nil {
+		return err
+	}
+	if _, err := GetStaged(cluster); err != nil {
+		return err
+	}
+	setAnnotation(cluster, v1.StagedConfigAnnotation, "")
+	return c.Update(ctx, cluster)
+}
+
+// Preview describes what promoting the revision staged on the named cluster would change,
+// and validates the revision end-to-end.
+func Preview(ctx context.Context, c client.Client, clusterName string) (*types.StagedConfigPreview, error) {
+	cluster, err := k8sutil.LookupClusterByName(c, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	staged, err := GetStaged(cluster)
+	if err != nil {
+		return nil, err
+	}
+	proposed := cluster.DeepCopy()
+	proposed.Spec = staged.Spec
+
+	changes, err := diffObjects("spec", cluster.Spec, proposed.Spec)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := diffResources(app.DesiredResources(cluster), app.DesiredResources(proposed))
+	if err != nil {
+		return nil, err
+	}
+	return &types.StagedConfigPreview{
+		Staged:     staged,
+		Stale:      staged.BaseGeneration != cluster.GetGeneration(),
+		Changes:    changes,
+		Resources:  resources,
+		Validation: preflight.Validate(ctx, c, proposed, nil),
+	}, nil
+}
+
+// Promote applies the revision staged on the named cluster. Unless forced, the revision
+// is refused if the cluster has changed since it was staged, or if it fails validation.
+// The spec that was replaced is kept for Rollback.
+func Promote(ctx context.Context, c client.Client, clusterName string, force bool) (*types.StagedConfigPreview, error) {
+	preview, err := Preview(ctx, c, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		if preview.Stale {
+			return preview, errors.New("the cluster configuration has changed since the revision was staged, preview it again or force the promotion")
+		}
+		if !preview.Validation.Ready {
+			return preview, fmt.Errorf("the staged configuration failed validation: %s", failedChecks(preview.Validation))
+		}
+	}
+
+	cluster, err := k8sutil.LookupClusterByName(c, clusterName)
+	if err != nil {
+		return preview, err
+	}
+	if err := swapSpec(cluster, preview.Staged.Spec); err != nil {
+		return preview, err
+	}
+	setAnnotation(cluster, v1.StagedConfigAnnotation, "")
+	return preview, c.Update(ctx, cluster)
+}
+
+// Rollback restores the spec that was replaced by the last promotion. The spec being
+// rolled back is kept in its place, so a rollback can itself be undone.
+func Rollback(ctx context.Context, c client.Client, clusterName string) error {
+	cluster, err := k8sutil.LookupClusterByName(c, clusterName)
+	if err != nil {
+		return err
+	}
+	raw, ok := cluster.GetAnnotations()[v1.PreviousConfigAnnotation]
+	if !ok || raw == "" {
+		return ErrNoPreviousConfig
+	}
+	previous := appv1.VDIClusterSpec{}
+	if err := json.Unmarshal([]byte(raw), &previous); err != nil {
+		return fmt.Errorf("could not decode the previous configuration: %s", err.Error())
+	}
+	if err := swapSpec(cluster, previous); err != nil {
+		return err
+	}
+	return c.Update(ctx, cluster)
+}
+
+// swapSpec replaces the spec of the cluster and records the spec it replaced.
+func swapSpec(cluster *appv1.VDICluster, spec appv1.VDIClusterSpec) error {
+	body, err := json.Marshal(cluster.Spec)
+	if err != nil {
+		return err
+	}
+	setAnnotation(cluster, v1.PreviousConfigAnnotation, string(body))
+	cluster.Spec = spec
+	return nil
+}
+
+// setAnnotation sets an annotation on the cluster, or removes it if val is empty.
+func setAnnotation(cluster *appv1.VDICluster, key, val string) {
+	annotations := cluster.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if val == "" {
+		delete(annotations, key)
+	} else {
+		annotations[key] = val
+	}
+	cluster.SetAnnotations(annotations)
+}
+
+func failedChecks(report *types.ConfigValidationReport) string {
+	failed := make([]string, 0)
+	for _, check := range report.Checks {
+		if !check.Ready && !check.Skipped {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package staging
+
+import (
+	"context"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+func mustCreateCluster(t *testing.T, c client.Client) *appv1.VDICluster {
+	t.Helper()
+	cluster := &appv1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.App = &appv1.AppConfig{Replicas: 1}
+	if err := c.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return cluster
+}
+
+func TestDiffValues(t *testing.T) {
+	a := map[string]interface{}{
+		"keep":    "same",
+		"change":  "old",
+		"removed": "gone",
+		"nested":  map[string]interface{}{"list": []interface{}{"a", "b"}},
+		"grown":   []interface{}{"a"},
+	}
+	b := map[string]interface{}{
+		"keep":   "same",
+		"change": "new",
+		"added":  "here",
+		"nested": map[string]interface{}{"list": []interface{}{"a", "c"}},
+		"grown":  []interface{}{"a", "b"},
+	}
+	changes := make([]*types.FieldChange, 0)
+	diffValues("spec", a, b, &changes)
+
+	expected := map[string][2]string{
+		"spec.added":          {"", `"here"`},
+		"spec.change":         {`"old"`, `"new"`},
+		"spec.grown":          {`["a"]`, `["a","b"]`},
+		"spec.nested.list[1]": {`"b"`, `"c"`},
+		"spec.removed":        {`"gone"`, ""},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got: %d", len(expected), len(changes))
+	}
+	for _, change := range changes {
+		exp, ok := expected[change.Path]
+		if !ok {
+			t.Error("Unexpected change at", change.Path)
+			continue
+		}
+		if change.From != exp[0] || change.To != exp[1] {
+			t.Errorf("Unexpected change at %s: %q -> %q", change.Path, change.From, change.To)
+		}
+	}
+	// Keys are walked in order
+	if changes[0].Path != "spec.added" {
+		t.Error("Expected changes to be sorted by path, got first:", changes[0].Path)
+	}
+}
+
+func TestStagePreviewPromoteRollback(t *testing.T) {
+	c := getFakeClient(t)
+	cluster := mustCreateCluster(t, c)
+
+	if _, err := Preview(context.TODO(), c, cluster.GetName()); err != ErrNoStagedConfig {
+		t.Error("Expected no staged config error, got:", err)
+	}
+
+	proposed := cluster.Spec.DeepCopy()
+	proposed.App.Replicas = 3
+	if _, err := Stage(context.TODO(), c, cluster.GetName(), *proposed, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := k8sutil.LookupClusterByName(c, cluster.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Spec.App.Replicas != 1 {
+		t.Error("Expected staging to leave the live spec alone, got replicas:", live.Spec.App.Replicas)
+	}
+
+	preview, err := Preview(context.TODO(), c, cluster.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Staged.StagedBy != "admin" {
+		t.Error("Expected revision to be staged by admin, got:", preview.Staged.StagedBy)
+	}
+	if len(preview.Changes) != 1 || preview.Changes[0].Path != "spec.app.replicas" {
+		t.Errorf("Expected a single change to the replicas, got: %+v", preview.Changes)
+	}
+	var deploymentUpdated bool
+	for _, res := range preview.Resources {
+		if res.Kind == "Deployment" && res.Action == types.ResourceUpdate {
+			deploymentUpdated = true
+		}
+	}
+	if !deploymentUpdated {
+		t.Errorf("Expected the app deployment to be updated, got: %+v", preview.Resources)
+	}
+	if preview.Validation == nil || preview.Validation.Ready {
+		t.Error("Expected validation to fail without TLS secrets")
+	}
+
+	// Validation fails without TLS material, so promotion must be forced
+	if _, err := Promote(context.TODO(), c, cluster.GetName(), false); err == nil {
+		t.Error("Expected promotion to be refused when validation fails")
+	}
+	if _, err := Promote(context.TODO(), c, cluster.GetName(), true); err != nil {
+		t.Fatal(err)
+	}
+	live, err = k8sutil.LookupClusterByName(c, cluster.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Spec.App.Replicas != 3 {
+		t.Error("Expected promoted spec to be applied, got replicas:", live.Spec.App.Replicas)
+	}
+	if _, ok := live.GetAnnotations()[v1.StagedConfigAnnotation]; ok {
+		t.Error("Expected staged config to be cleared after promotion")
+	}
+	if _, err := GetStaged(live); err != ErrNoStagedConfig {
+		t.Error("Expected no staged config after promotion, got:", err)
+	}
+
+	if err := Rollback(context.TODO(), c, cluster.GetName()); err != nil {
+		t.Fatal(err)
+	}
+	live, err = k8sutil.LookupClusterByName(c, cluster.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Spec.App.Replicas != 1 {
+		t.Error("Expected rollback to restore the previous spec, got replicas:", live.Spec.App.Replicas)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	c := getFakeClient(t)
+	cluster := mustCreateCluster(t, c)
+	if err := Discard(context.TODO(), c, cluster.GetName()); err != ErrNoStagedConfig {
+		t.Error("Expected no staged config error, got:", err)
+	}
+	if err := Rollback(context.TODO(), c, cluster.GetName()); err != ErrNoPreviousConfig {
+		t.Error("Expected no previous config error, got:", err)
+	}
+	if _, err := Stage(context.TODO(), c, cluster.GetName(), cluster.Spec, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Discard(context.TODO(), c, cluster.GetName()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Preview(context.TODO(), c, cluster.GetName()); err != ErrNoStagedConfig {
+		t.Error("Expected staged config to be discarded, got:", err)
+	}
+}
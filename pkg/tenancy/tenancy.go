@@ -0,0 +1,224 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Validate checks an isolated VDICluster against the rest of the management cluster. It returns
+// an error listing every namespace the cluster claims that is already claimed by another
+// VDICluster, every role labeled for the cluster that grants access to namespaces outside of its
+// own, and every reference the cluster or its teams make to a role of another VDICluster. Clusters
+// that are not isolated are always valid.
+func Validate(ctx context.Context, c client.Client, cluster *appv1.VDICluster) error {
+	if !cluster.IsIsolated() {
+		return nil
+	}
+	problems := make([]string, 0)
+
+	clusters := &appv1.VDIClusterList{}
+	if err := c.List(ctx, clusters); err != nil {
+		return err
+	}
+	for i := range clusters.Items {
+		other := &clusters.Items[i]
+		if other.GetName() == cluster.GetName() {
+			continue
+		}
+		for _, ns := range cluster.GetTenantNamespaces() {
+			if other.OwnsNamespace(ns) {
+				problems = append(problems, fmt.Sprintf("namespace %q is already claimed by VDICluster %q", ns, other.GetName()))
+			}
+		}
+	}
+
+	roles, err := cluster.GetRoles(c)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if err := CheckRole(cluster, role); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	refs := make(map[string]string)
+	for _, name := range cluster.GetDeviceTrustRoles() {
+		refs[name] = "the device trust configuration"
+	}
+	teams, err := cluster.GetTeams(c)
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		for _, name := range team.GetRoles() {
+			refs[name] = fmt.Sprintf("team %q", team.GetName())
+		}
+	}
+	for name, referrer := range refs {
+		if err := checkRoleRef(ctx, c, cluster, name); err != nil {
+			problems = append(problems, fmt.Sprintf("%s references %s", referrer, err.Error()))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("VDICluster %s is isolated but %s", cluster.GetName(), strings.Join(problems, "; "))
+}
+
+// CheckNamespace returns an error if the sessions of the given VDICluster may not run in the
+// namespace. Isolated clusters may only run sessions in the namespaces they claim, and no other
+// cluster may run sessions in them.
+func CheckNamespace(ctx context.Context, c client.Client, cluster *appv1.VDICluster, ns string) error {
+	if cluster.IsIsolated() {
+		if !cluster.OwnsNamespace(ns) {
+			return fmt.Errorf("Namespace %s is not one of the namespaces of VDICluster %s", ns, cluster.GetName())
+		}
+		return nil
+	}
+	owner, err := isolatedOwner(ctx, c, cluster, ns)
+	if err != nil {
+		return err
+	}
+	if owner != "" {
+		return fmt.Errorf("Namespace %s belongs to the isolated VDICluster %s", ns, owner)
+	}
+	return nil
+}
+
+// FilterNamespaces returns the given namespaces the sessions of the VDICluster may run in.
+func FilterNamespaces(ctx context.Context, c client.Client, cluster *appv1.VDICluster, namespaces []string) ([]string, error) {
+	if cluster.IsIsolated() {
+		out := make([]string, 0)
+		for _, ns := range namespaces {
+			if cluster.OwnsNamespace(ns) {
+				out = append(out, ns)
+			}
+		}
+		return out, nil
+	}
+	clusters := &appv1.VDIClusterList{}
+	if err := c.List(ctx, clusters); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0)
+	for _, ns := range namespaces {
+		if claimedByIsolated(clusters, cluster, ns) == "" {
+			out = append(out, ns)
+		}
+	}
+	return out, nil
+}
+
+// CheckTemplate returns an error if the template belongs to another VDICluster, or if the
+// VDICluster is isolated and the template is not labeled for it.
+func CheckTemplate(cluster *appv1.VDICluster, tmpl *desktopsv1.Template) error {
+	if !cluster.OwnsObject(tmpl) {
+		return fmt.Errorf("Template %s does not belong to VDICluster %s", tmpl.GetName(), cluster.GetName())
+	}
+	return nil
+}
+
+// FilterTemplates returns the given templates that are usable by the VDICluster.
+func FilterTemplates(cluster *appv1.VDICluster, tmpls []*desktopsv1.Template) []*desktopsv1.Template {
+	out := make([]*desktopsv1.Template, 0)
+	for _, tmpl := range tmpls {
+		if cluster.OwnsObject(tmpl) {
+			out = append(out, tmpl)
+		}
+	}
+	return out
+}
+
+// CheckRole returns an error if the role belongs to another VDICluster, or if the VDICluster
+// is isolated and the role grants access to namespaces outside of the ones it claims.
+func CheckRole(cluster *appv1.VDICluster, role *rbacv1.VDIRole) error {
+	if !cluster.OwnsObject(role) {
+		return fmt.Errorf("VDIRole %s does not belong to VDICluster %s", role.GetName(), cluster.GetName())
+	}
+	if !cluster.IsIsolated() {
+		return nil
+	}
+	for _, rule := range role.GetRules() {
+		for _, ns := range rule.Namespaces {
+			if ns != rbacv1.NamespaceAll && !cluster.OwnsNamespace(ns) {
+				return fmt.Errorf("VDIRole %s grants access to namespace %s outside of VDICluster %s", role.GetName(), ns, cluster.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// CheckSession returns an error if the session runs in a namespace, or from a template, that
+// the VDICluster it belongs to may not use.
+func CheckSession(ctx context.Context, c client.Client, cluster *appv1.VDICluster, tmpl *desktopsv1.Template, sess *desktopsv1.Session) error {
+	if err := CheckTemplate(cluster, tmpl); err != nil {
+		return err
+	}
+	return CheckNamespace(ctx, c, cluster, sess.GetNamespace())
+}
+
+// checkRoleRef returns an error if the role with the given name exists and belongs to another
+// VDICluster.
+func checkRoleRef(ctx context.Context, c client.Client, cluster *appv1.VDICluster, name string) error {
+	role := &rbacv1.VDIRole{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, role); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !cluster.OwnsObject(role) {
+		return fmt.Errorf("VDIRole %s of another VDICluster", name)
+	}
+	return nil
+}
+
+// isolatedOwner returns the name of the isolated VDICluster, other than the given one, that
+// claims the namespace, or an empty string if there is none.
+func isolatedOwner(ctx context.Context, c client.Client, cluster *appv1.VDICluster, ns string) (string, error) {
+	clusters := &appv1.VDIClusterList{}
+	if err := c.List(ctx, clusters); err != nil {
+		return "", err
+	}
+	return claimedByIsolated(clusters, cluster, ns), nil
+}
+
+func claimedByIsolated(clusters *appv1.VDIClusterList, cluster *appv1.VDICluster, ns string) string {
+	for i := range clusters.Items {
+		other := &clusters.Items[i]
+		if other.GetName() != cluster.GetName() && other.IsIsolated() && other.OwnsNamespace(ns) {
+			return other.GetName()
+		}
+	}
+	return ""
+}
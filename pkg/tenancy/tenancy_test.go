@@ -0,0 +1,200 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package tenancy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	appv1.AddToScheme(scheme)
+	desktopsv1.AddToScheme(scheme)
+	rbacv1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+func mustCreateCluster(t *testing.T, c client.Client, name, appNamespace string, isolated bool, namespaces ...string) *appv1.VDICluster {
+	t.Helper()
+	cluster := &appv1.VDICluster{}
+	cluster.Name = name
+	cluster.Spec.AppNamespace = appNamespace
+	if isolated {
+		cluster.Spec.Tenancy = &appv1.TenancyConfig{Isolated: true, Namespaces: namespaces}
+	}
+	if err := c.Create(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return cluster
+}
+
+func labeledFor(cluster string) map[string]string {
+	return map[string]string{v1.RoleClusterRefLabel: cluster}
+}
+
+func TestCheckNamespace(t *testing.T) {
+	c := getFakeClient(t)
+	shared := mustCreateCluster(t, c, "shared", "kvdi", false)
+	tenant := mustCreateCluster(t, c, "tenant", "tenant-app", true, "tenant-desktops")
+
+	for _, ns := range []string{"tenant-app", "tenant-desktops"} {
+		if err := CheckNamespace(context.TODO(), c, tenant, ns); err != nil {
+			t.Errorf("Expected tenant to run sessions in %s, got: %s", ns, err)
+		}
+		if err := CheckNamespace(context.TODO(), c, shared, ns); err == nil {
+			t.Errorf("Expected shared cluster to be kept out of %s", ns)
+		}
+	}
+	if err := CheckNamespace(context.TODO(), c, tenant, "kvdi"); err == nil {
+		t.Error("Expected tenant to be kept out of namespaces it does not claim")
+	}
+	if err := CheckNamespace(context.TODO(), c, shared, "default"); err != nil {
+		t.Error("Expected shared cluster to run sessions in unclaimed namespaces, got:", err)
+	}
+
+	filtered, err := FilterNamespaces(context.TODO(), c, shared, []string{"default", "kvdi", "tenant-app", "tenant-desktops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filtered, ",") != "default,kvdi" {
+		t.Error("Expected tenant namespaces to be filtered for the shared cluster, got:", filtered)
+	}
+	filtered, err = FilterNamespaces(context.TODO(), c, tenant, []string{"default", "kvdi", "tenant-app", "tenant-desktops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filtered, ",") != "tenant-app,tenant-desktops" {
+		t.Error("Expected only the tenant namespaces for the tenant, got:", filtered)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	c := getFakeClient(t)
+	shared := mustCreateCluster(t, c, "shared", "kvdi", false)
+	tenant := mustCreateCluster(t, c, "tenant", "tenant-app", true)
+
+	unlabeled := &desktopsv1.Template{}
+	unlabeled.Name = "unlabeled"
+	tenantTmpl := &desktopsv1.Template{}
+	tenantTmpl.Name = "tenant-template"
+	tenantTmpl.Labels = labeledFor("tenant")
+
+	if err := CheckTemplate(shared, unlabeled); err != nil {
+		t.Error("Expected shared cluster to use unlabeled templates, got:", err)
+	}
+	if err := CheckTemplate(shared, tenantTmpl); err == nil {
+		t.Error("Expected shared cluster to be refused the tenant's template")
+	}
+	if err := CheckTemplate(tenant, unlabeled); err == nil {
+		t.Error("Expected tenant to be refused unlabeled templates")
+	}
+	if err := CheckTemplate(tenant, tenantTmpl); err != nil {
+		t.Error("Expected tenant to use its own template, got:", err)
+	}
+
+	filtered := FilterTemplates(tenant, []*desktopsv1.Template{unlabeled, tenantTmpl})
+	if len(filtered) != 1 || filtered[0].GetName() != "tenant-template" {
+		t.Error("Expected only the tenant template for the tenant, got:", filtered)
+	}
+
+	claimed := &desktopsv1.Template{}
+	tenant.ClaimObject(claimed)
+	if err := CheckTemplate(tenant, claimed); err != nil {
+		t.Error("Expected claimed template to belong to the tenant, got:", err)
+	}
+	shared.ClaimObject(unlabeled)
+	if len(unlabeled.GetLabels()) != 0 {
+		t.Error("Expected shared cluster to leave templates unlabeled, got:", unlabeled.GetLabels())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := getFakeClient(t)
+	mustCreateCluster(t, c, "shared", "kvdi", false)
+	mustCreateCluster(t, c, "other", "other-app", true, "other-desktops")
+	tenant := mustCreateCluster(t, c, "tenant", "tenant-app", true, "tenant-desktops")
+
+	if err := Validate(context.TODO(), c, tenant); err != nil {
+		t.Fatal("Expected tenant without conflicts to be valid, got:", err)
+	}
+
+	tenant.Spec.Tenancy.Namespaces = []string{"tenant-desktops", "kvdi", "other-desktops"}
+	err := Validate(context.TODO(), c, tenant)
+	if err == nil {
+		t.Fatal("Expected tenant claiming namespaces of other clusters to be invalid")
+	}
+	for _, expected := range []string{`"kvdi" is already claimed by VDICluster "shared"`, `"other-desktops" is already claimed by VDICluster "other"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in error, got: %s", expected, err)
+		}
+	}
+	tenant.Spec.Tenancy.Namespaces = []string{"tenant-desktops"}
+
+	role := &rbacv1.VDIRole{}
+	role.Name = "tenant-role"
+	role.Labels = labeledFor("tenant")
+	role.Rules = []rbacv1.Rule{{Namespaces: []string{"tenant-desktops", "default"}}}
+	if err := c.Create(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(context.TODO(), c, tenant); err == nil || !strings.Contains(err.Error(), "namespace default") {
+		t.Error("Expected role granting access outside of the tenant to be invalid, got:", err)
+	}
+	role.Rules = []rbacv1.Rule{{Namespaces: []string{"tenant-desktops", rbacv1.NamespaceAll}}}
+	if err := c.Update(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+
+	otherRole := &rbacv1.VDIRole{}
+	otherRole.Name = "other-role"
+	otherRole.Labels = labeledFor("other")
+	if err := c.Create(context.TODO(), otherRole); err != nil {
+		t.Fatal(err)
+	}
+	team := &rbacv1.VDITeam{}
+	team.Name = "tenant-team"
+	team.Labels = labeledFor("tenant")
+	team.Spec.Roles = []string{"tenant-role", "other-role", "missing-role"}
+	if err := c.Create(context.TODO(), team); err != nil {
+		t.Fatal(err)
+	}
+	err = Validate(context.TODO(), c, tenant)
+	if err == nil {
+		t.Fatal("Expected team referencing a role of another cluster to be invalid")
+	}
+	if !strings.Contains(err.Error(), `team "tenant-team" references VDIRole other-role`) {
+		t.Error("Expected the cross-tenant role reference in the error, got:", err)
+	}
+	if strings.Contains(err.Error(), "tenant-role") || strings.Contains(err.Error(), "missing-role") {
+		t.Error("Expected only the cross-tenant role reference in the error, got:", err)
+	}
+}
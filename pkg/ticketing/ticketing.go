@@ -0,0 +1,127 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ticketing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+)
+
+// Request is the body sent to the ticketing webhook.
+type Request struct {
+	// The ticket or change ID being validated.
+	Ticket string `json:"ticket"`
+	// The user launching or running the session.
+	User string `json:"user"`
+	// The template the session is launched from.
+	Template string `json:"template"`
+	// The namespaced name of the session, when revalidating a running session.
+	Session string `json:"session,omitempty"`
+}
+
+// Response is the body returned by the ticketing webhook.
+type Response struct {
+	// Whether the ticket is open and allows the session.
+	Valid bool `json:"valid"`
+	// Why the ticket does not allow the session.
+	Reason string `json:"reason,omitempty"`
+	// When the ticket expires, if it does.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Err returns an error describing why the ticket does not allow the session at the given
+// time, or nil if it does.
+func (r *Response) Err(ticket string, now time.Time) error {
+	if !r.Valid {
+		if r.Reason != "" {
+			return fmt.Errorf("Ticket %s is not valid: %s", ticket, r.Reason)
+		}
+		return fmt.Errorf("Ticket %s is not valid", ticket)
+	}
+	if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+		return fmt.Errorf("Ticket %s expired at %s", ticket, r.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Validator validates tickets against the webhook of a ticketing system.
+type Validator struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// New returns a validator for the ticketing system configured on the given cluster. The
+// token for the webhook, if any, is read from the given secrets engine.
+func New(cluster *appv1.VDICluster, secretsEngine *secrets.SecretEngine) (*Validator, error) {
+	cfg := cluster.GetTicketing()
+	if cfg == nil {
+		return nil, errors.New("No ticketing system is configured")
+	}
+	v := &Validator{
+		url:        cfg.URL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	if cfg.TokenSecret != "" {
+		token, err := secretsEngine.ReadSecret(cfg.TokenSecret, true)
+		if err != nil {
+			return nil, err
+		}
+		v.token = strings.TrimSpace(string(token))
+	}
+	return v, nil
+}
+
+// Validate sends the given request to the webhook and returns its response.
+func (v *Validator) Validate(req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if v.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+v.token)
+	}
+	resp, err := v.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to reach the ticketing system: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Ticketing system returned status %d", resp.StatusCode)
+	}
+	out := &Response{}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("Could not decode response from the ticketing system: %s", err.Error())
+	}
+	return out, nil
+}
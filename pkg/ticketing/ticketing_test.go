@@ -0,0 +1,89 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ticketing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+)
+
+func TestValidate(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &Request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			t.Error(err)
+		}
+		if req.User != "admin" || req.Template != "paw" {
+			t.Error("Unexpected request:", req)
+		}
+		switch req.Ticket {
+		case "CHG001":
+			json.NewEncoder(w).Encode(&Response{Valid: true, ExpiresAt: &expires})
+		case "CHG002":
+			json.NewEncoder(w).Encode(&Response{Valid: false, Reason: "change is closed"})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srvr.Close()
+
+	if _, err := New(&appv1.VDICluster{}, nil); err == nil {
+		t.Error("Expected error for cluster without ticketing")
+	}
+
+	cluster := &appv1.VDICluster{}
+	cluster.Spec.Desktops = &appv1.DesktopsConfig{Ticketing: &appv1.TicketingConfig{URL: srvr.URL}}
+	v, err := New(cluster, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := v.Validate(&Request{Ticket: "CHG001", User: "admin", Template: "paw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err("CHG001", time.Now()); err != nil {
+		t.Error("Expected valid ticket, got:", err)
+	}
+	if resp.ExpiresAt == nil || !resp.ExpiresAt.Equal(expires) {
+		t.Error("Expected expiry to be returned, got:", resp.ExpiresAt)
+	}
+	if err := resp.Err("CHG001", expires.Add(time.Second)); err == nil {
+		t.Error("Expected error for expired ticket")
+	}
+
+	resp, err = v.Validate(&Request{Ticket: "CHG002", User: "admin", Template: "paw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Err("CHG002", time.Now()); err == nil {
+		t.Error("Expected error for closed ticket")
+	}
+
+	if _, err := v.Validate(&Request{Ticket: "CHG003", User: "admin", Template: "paw"}); err == nil {
+		t.Error("Expected error for failed webhook")
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package trash
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Entry is a deleted object along with the data needed to restore it.
+type Entry struct {
+	*types.DeletedObject
+	// The serialized object, in a format understood by whatever restores it.
+	Data []byte `json:"data"`
+}
+
+// Bin is the deleted objects being kept, keyed by their kind and name.
+type Bin map[string]*Entry
+
+func key(kind rbacv1.Resource, name string) string { return string(kind) + "/" + name }
+
+// Add puts the entry in the bin, replacing any earlier deletion of an object of the same
+// kind and name.
+func (b Bin) Add(entry *Entry) {
+	b[key(entry.Kind, entry.Name)] = entry
+}
+
+// Get returns the entry for the given object, or nil if it is not in the bin or has
+// expired.
+func (b Bin) Get(kind rbacv1.Resource, name string, now time.Time) *Entry {
+	entry, ok := b[key(kind, name)]
+	if !ok || !entry.ExpiresAt.After(now) {
+		return nil
+	}
+	return entry
+}
+
+// Take removes the entry for the given object from the bin and returns it. Nil is returned
+// if it is not in the bin or has expired.
+func (b Bin) Take(kind rbacv1.Resource, name string, now time.Time) *Entry {
+	entry := b.Get(kind, name, now)
+	if entry != nil {
+		delete(b, key(kind, name))
+	}
+	return entry
+}
+
+// Expired removes the entries past their retention from the bin and returns them.
+func (b Bin) Expired(now time.Time) []*Entry {
+	expired := make([]*Entry, 0)
+	for k, entry := range b {
+		if !entry.ExpiresAt.After(now) {
+			expired = append(expired, entry)
+			delete(b, k)
+		}
+	}
+	return expired
+}
+
+// List returns the objects in the bin that have not expired, most recently deleted first.
+func (b Bin) List(now time.Time) []*types.DeletedObject {
+	out := make([]*types.DeletedObject, 0, len(b))
+	for _, entry := range b {
+		if entry.ExpiresAt.After(now) {
+			out = append(out, entry.DeletedObject)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].DeletedAt.Equal(out[j].DeletedAt) {
+			return key(out[i].Kind, out[i].Name) < key(out[j].Kind, out[j].Name)
+		}
+		return out[i].DeletedAt.After(out[j].DeletedAt)
+	})
+	return out
+}
+
+// Manager is an object for keeping deleted users and roles. It uses the configured secrets
+// backend for storage.
+type Manager struct {
+	secrets *secrets.SecretEngine
+}
+
+// NewManager returns a new trash manager with the given secrets engine.
+func NewManager(secrets *secrets.SecretEngine) *Manager {
+	return &Manager{secrets: secrets}
+}
+
+// Add keeps the given deleted object and the data needed to restore it.
+func (m *Manager) Add(obj *types.DeletedObject, data []byte) error {
+	return m.update(func(bin Bin) error {
+		bin.Add(&Entry{DeletedObject: obj, Data: data})
+		return nil
+	})
+}
+
+// List returns the deleted objects that can still be restored, most recently deleted
+// first.
+func (m *Manager) List() ([]*types.DeletedObject, error) {
+	bin, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+	return bin.List(time.Now()), nil
+}
+
+// Get returns the entry for the given deleted object without removing it. If it cannot be
+// restored a DeletedObjectNotFoundError is returned.
+func (m *Manager) Get(kind rbacv1.Resource, name string) (*Entry, error) {
+	bin, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+	entry := bin.Get(kind, name, time.Now())
+	if entry == nil {
+		return nil, errors.NewDeletedObjectNotFoundError(string(kind), name)
+	}
+	return entry, nil
+}
+
+// Take removes the entry for the given deleted object and returns it, so it can be
+// restored. If it cannot be restored a DeletedObjectNotFoundError is returned. Callers
+// that fail to restore the object should Add it back.
+func (m *Manager) Take(kind rbacv1.Resource, name string) (*Entry, error) {
+	var entry *Entry
+	err := m.update(func(bin Bin) error {
+		if entry = bin.Take(kind, name, time.Now()); entry == nil {
+			return errors.NewDeletedObjectNotFoundError(string(kind), name)
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// Purge removes the deleted objects past their retention and returns them, so any data
+// that was kept around for restoring them can be cleaned up.
+func (m *Manager) Purge() ([]*Entry, error) {
+	var expired []*Entry
+	err := m.update(func(bin Bin) error {
+		expired = bin.Expired(time.Now())
+		return nil
+	})
+	return expired, err
+}
+
+func (m *Manager) read() (Bin, error) {
+	all, err := m.secrets.ReadSecretMap(v1.DeletedObjectsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return Bin{}, nil
+		}
+		return nil, err
+	}
+	return decodeBin(all)
+}
+
+func (m *Manager) update(f func(Bin) error) error {
+	if err := m.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer m.secrets.Release()
+	bin, err := m.read()
+	if err != nil {
+		return err
+	}
+	if err := f(bin); err != nil {
+		return err
+	}
+	all := make(map[string][]byte, len(bin))
+	for k, entry := range bin {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		all[k] = data
+	}
+	return m.secrets.WriteSecretMap(v1.DeletedObjectsSecretKey, all)
+}
+
+func decodeBin(all map[string][]byte) (Bin, error) {
+	bin := make(Bin, len(all))
+	for k, data := range all {
+		entry := &Entry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, err
+		}
+		if entry.DeletedObject == nil {
+			continue
+		}
+		bin[k] = entry
+	}
+	return bin, nil
+}
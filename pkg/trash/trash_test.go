@@ -0,0 +1,105 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package trash
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func newEntry(kind rbacv1.Resource, name string, deletedAt time.Time, retention time.Duration) *Entry {
+	return &Entry{
+		DeletedObject: &types.DeletedObject{
+			Kind:      kind,
+			Name:      name,
+			DeletedAt: deletedAt,
+			ExpiresAt: deletedAt.Add(retention),
+		},
+		Data: []byte(name),
+	}
+}
+
+func TestBin(t *testing.T) {
+	now := time.Now()
+	bin := Bin{}
+	bin.Add(newEntry(rbacv1.ResourceUsers, "alice", now.Add(-2*time.Hour), time.Hour))
+	bin.Add(newEntry(rbacv1.ResourceUsers, "bob", now.Add(-time.Hour), 2*time.Hour))
+	bin.Add(newEntry(rbacv1.ResourceRoles, "bob", now.Add(-time.Minute), 2*time.Hour))
+
+	list := bin.List(now)
+	if len(list) != 2 {
+		t.Fatal("Expected the expired user to not be listed, got:", list)
+	}
+	if list[0].Kind != rbacv1.ResourceRoles || list[1].Kind != rbacv1.ResourceUsers {
+		t.Error("Expected the most recently deleted object first, got:", list)
+	}
+
+	if entry := bin.Get(rbacv1.ResourceUsers, "alice", now); entry != nil {
+		t.Error("Expected an expired object to not be returned, got:", entry)
+	}
+	if entry := bin.Get(rbacv1.ResourceRoles, "alice", now); entry != nil {
+		t.Error("Expected an object that was never deleted to not be returned, got:", entry)
+	}
+	if entry := bin.Get(rbacv1.ResourceUsers, "bob", now); entry == nil || string(entry.Data) != "bob" {
+		t.Error("Expected the deleted user to be returned, got:", entry)
+	}
+
+	if entry := bin.Take(rbacv1.ResourceRoles, "bob", now); entry == nil || entry.Kind != rbacv1.ResourceRoles {
+		t.Error("Expected the deleted role to be taken, got:", entry)
+	}
+	if entry := bin.Take(rbacv1.ResourceRoles, "bob", now); entry != nil {
+		t.Error("Expected a taken object to be removed from the bin, got:", entry)
+	}
+
+	expired := bin.Expired(now)
+	if len(expired) != 1 || expired[0].Name != "alice" {
+		t.Error("Expected only alice to have expired, got:", expired)
+	}
+	if len(bin) != 1 {
+		t.Error("Expected expired objects to be removed from the bin, got:", bin)
+	}
+	if expired = bin.Expired(now.Add(2 * time.Hour)); len(expired) != 1 || len(bin) != 0 {
+		t.Error("Expected bob to expire after his retention, got:", expired)
+	}
+}
+
+func TestDecodeBin(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	entry := newEntry(rbacv1.ResourceUsers, "alice", now, time.Hour)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := decodeBin(map[string][]byte{key(entry.Kind, entry.Name): data, "invalid": []byte("{}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := bin.Get(rbacv1.ResourceUsers, "alice", now)
+	if decoded == nil || !decoded.ExpiresAt.Equal(entry.ExpiresAt) || string(decoded.Data) != "alice" {
+		t.Error("Expected the entry to be decoded, got:", decoded)
+	}
+	if _, err := decodeBin(map[string][]byte{"bad": []byte("not json")}); err == nil {
+		t.Error("Expected malformed entries to fail to decode")
+	}
+}
@@ -23,11 +23,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
+	"time"
 
+	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
 	metav1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // API Request/Response types
@@ -45,6 +50,9 @@ type LoginRequest struct {
 	// State generated by requesting client to prevent CSRF and retrieve tokens
 	// from an oidc flow
 	State string `json:"state"`
+	// An override token issued by an administrator, allowing the user to log in
+	// outside of the access hours of their roles.
+	AccessOverrideToken string `json:"accessOverrideToken,omitempty"`
 	// the underlying request object for usage by auth providers
 	request *http.Request
 }
@@ -59,6 +67,9 @@ func (l *LoginRequest) GetPassword() string { return l.Password }
 // GetState returns the state secret in the request.
 func (l *LoginRequest) GetState() string { return l.State }
 
+// GetAccessOverrideToken returns the access override token in the request.
+func (l *LoginRequest) GetAccessOverrideToken() string { return l.AccessOverrideToken }
+
 // SetRequest sets the request object in the LoginRequest.
 func (l *LoginRequest) SetRequest(r *http.Request) {
 	l.request = r
@@ -97,6 +108,28 @@ type SessionResponse struct {
 	Authorized bool `json:"authorized"`
 	// The state secret generated by the client
 	State string `json:"state"`
+	// The time the session reaches its maximum age and a new login is required, if limited.
+	SessionExpiresAt int64 `json:"sessionExpiresAt,omitempty"`
+}
+
+// BreakGlassChallengeResponse contains a one-time challenge to sign with the break-glass key.
+type BreakGlassChallengeResponse struct {
+	// The challenge to sign.
+	Challenge string `json:"challenge"`
+	// The time the challenge expires.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// BreakGlassUnlockRequest presents a signed challenge to unlock the break-glass account.
+type BreakGlassUnlockRequest struct {
+	// The challenge that was signed.
+	Challenge string `json:"challenge"`
+	// The base64 encoded signature over the challenge.
+	Signature string `json:"signature"`
+	// Why the break-glass account is being used. This is recorded in the audit log.
+	Reason string `json:"reason"`
+	// State generated by the requesting client, returned in the session response.
+	State string `json:"state"`
 }
 
 // CreateUserRequest represents a request to create a new user. Not all auth
@@ -160,6 +193,99 @@ type MFAResponse struct {
 	Verified bool `json:"verified"`
 }
 
+// HomeShareCredentials are the credentials used to mount a user's home share from an
+// external file server. They are kept in the secrets backend.
+type HomeShareCredentials struct {
+	// The username to authenticate to the file server with.
+	Username string `json:"username"`
+	// The password to authenticate to the file server with.
+	Password string `json:"password,omitempty"`
+	// The domain of the user, if any.
+	Domain string `json:"domain,omitempty"`
+	// A Kerberos credential cache for the user, for templates that authenticate with
+	// Kerberos.
+	KerberosCache []byte `json:"kerberosCache,omitempty"`
+}
+
+// Validate the HomeShareCredentials
+func (r *HomeShareCredentials) Validate() error {
+	if r.Username == "" {
+		return errors.New("'username' must be provided")
+	}
+	if r.Password == "" && len(r.KerberosCache) == 0 {
+		return errors.New("You must specify either a password or a kerberos credential cache")
+	}
+	return nil
+}
+
+// AccessOverrideRequest requests an override token letting a user log in and connect
+// to their desktops outside of the access hours of their roles.
+type AccessOverrideRequest struct {
+	// How long the override is valid for (e.g. `2h`). Defaults to one hour, and may
+	// not exceed 24 hours.
+	Duration string `json:"duration,omitempty"`
+	// The reason the override was issued.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate the AccessOverrideRequest
+func (r *AccessOverrideRequest) Validate() error {
+	if r.Duration == "" {
+		return nil
+	}
+	dur, err := time.ParseDuration(r.Duration)
+	if err != nil {
+		return err
+	}
+	if dur <= 0 || dur > 24*time.Hour {
+		return errors.New("'duration' must be greater than zero and no more than 24h")
+	}
+	return nil
+}
+
+// GetDuration returns how long the override should be valid for.
+func (r *AccessOverrideRequest) GetDuration() time.Duration {
+	if r.Duration != "" {
+		if dur, err := time.ParseDuration(r.Duration); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// AccessOverrideResponse contains an issued access override token. The token is only
+// returned once and should be handed to the user out of band.
+type AccessOverrideResponse struct {
+	// The user the override was issued for.
+	User string `json:"user"`
+	// The override token to supply when logging in.
+	Token string `json:"token"`
+	// The unix time the override expires.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// DomainKeytabRequest uploads the keytab of a host that domain joined desktops may lease.
+type DomainKeytabRequest struct {
+	// The contents of the keytab.
+	Keytab []byte `json:"keytab"`
+}
+
+// Validate the DomainKeytabRequest
+func (r *DomainKeytabRequest) Validate() error {
+	if len(r.Keytab) == 0 {
+		return errors.New("'keytab' must be provided")
+	}
+	return nil
+}
+
+// DomainHost represents a host keytab stored for domain joined desktops.
+type DomainHost struct {
+	// The name of the host. Desktops leasing the host use it as their name on the realm.
+	Host string `json:"host"`
+	// The namespaced name of the desktop session currently leasing the host, if any.
+	Session string `json:"session,omitempty"`
+}
+
 // CreateRoleRequest represents a request for a new role.
 type CreateRoleRequest struct {
 	// The name of the new role
@@ -168,6 +294,16 @@ type CreateRoleRequest struct {
 	Annotations map[string]string `json:"annotations"`
 	// Rules to apply to the new role.
 	Rules []rbacv1.Rule `json:"rules"`
+	// The device policy for the new role.
+	Devices *rbacv1.DevicePolicy `json:"devices,omitempty"`
+	// The access hours for the new role.
+	AccessHours *rbacv1.AccessHours `json:"accessHours,omitempty"`
+	// The session quotas for the new role.
+	Quotas *rbacv1.SessionQuotas `json:"quotas,omitempty"`
+	// The display bandwidth weight for the new role.
+	BandwidthWeight int32 `json:"bandwidthWeight,omitempty"`
+	// The names of other roles the new role inherits rules from.
+	InheritsFrom []string `json:"inheritsFrom,omitempty"`
 }
 
 // GetName returns the name of the new role
@@ -181,8 +317,8 @@ func (r *CreateRoleRequest) Validate() error {
 	if r.Name == "" {
 		return errors.New("A name is required for the new role")
 	}
-	for _, rule := range r.Rules {
-		if err := validatePatterns(rule.ResourcePatterns); err != nil {
+	for i, rule := range r.Rules {
+		if err := validateRule(field.NewPath("rules").Index(i), rule); err != nil {
 			return err
 		}
 	}
@@ -203,6 +339,117 @@ func (r *CreateRoleRequest) GetRules() []rbacv1.Rule {
 	return r.Rules
 }
 
+// EffectiveRulesResponse contains the rules that apply to members of a role, including
+// those it inherits from other roles.
+type EffectiveRulesResponse struct {
+	// The name of the role
+	Name string `json:"name"`
+	// The rules defined on the role itself followed by the rules it inherits.
+	Rules []rbacv1.Rule `json:"rules"`
+	// The names of the roles rules were inherited from.
+	InheritedRoles []string `json:"inheritedRoles,omitempty"`
+	// The names of roles in the inheritance chain that do not exist.
+	MissingRoles []string `json:"missingRoles,omitempty"`
+}
+
+// AuthzCheckRequest requests a dry-run evaluation of whether a user would be allowed to
+// perform an action.
+type AuthzCheckRequest struct {
+	// The user to check. Defaults to the user making the request.
+	User string `json:"user,omitempty"`
+	// The action to check (e.g. `launch`).
+	Verb rbacv1.Verb `json:"verb"`
+	// The type of resource targeted by the action (e.g. `templates`).
+	Resource rbacv1.Resource `json:"resource"`
+	// The name of the targeted resource.
+	ResourceName string `json:"resourceName,omitempty"`
+	// The namespace of the targeted resource.
+	Namespace string `json:"namespace,omitempty"`
+	// Attributes of the user to match rule conditions against. Only used when checking
+	// another user, since stored users do not carry the attributes from their auth provider.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Validate the AuthzCheckRequest
+func (r *AuthzCheckRequest) Validate() error {
+	if r.Verb == "" {
+		return errors.New("A verb is required to check authorization")
+	}
+	if r.Resource == "" {
+		return errors.New("A resource is required to check authorization")
+	}
+	return nil
+}
+
+// GetAction returns the APIAction to evaluate for the request.
+func (r *AuthzCheckRequest) GetAction() *APIAction {
+	return &APIAction{
+		Verb:              r.Verb,
+		ResourceType:      r.Resource,
+		ResourceName:      r.ResourceName,
+		ResourceNamespace: r.Namespace,
+	}
+}
+
+// AuthzCheckResponse contains the result of an AuthzCheckRequest.
+type AuthzCheckResponse struct {
+	// The user that was checked
+	User string `json:"user"`
+	// Whether the action would be allowed
+	Allowed bool `json:"allowed"`
+	// A human readable explanation of the result
+	Reason string `json:"reason"`
+	// The role containing the rule that decided the result, if any
+	Role string `json:"role,omitempty"`
+	// The rule that decided the result, if any
+	Rule *rbacv1.Rule `json:"rule,omitempty"`
+}
+
+// WhoCanResponse lists the users and roles allowed to perform an action.
+type WhoCanResponse struct {
+	// The action that was checked
+	Action *APIAction `json:"action"`
+	// The users allowed to perform the action, including through their teams
+	Users []string `json:"users"`
+	// The roles that allow the action on their own
+	Roles []string `json:"roles"`
+}
+
+// PermissionMatrixResponse lists every permission held by every user, for periodic access
+// reviews.
+type PermissionMatrixResponse struct {
+	// The unix time the matrix was generated
+	GeneratedAt int64 `json:"generatedAt"`
+	// The users included in the matrix
+	Users []string `json:"users"`
+	// The templates grants were evaluated against
+	Templates []string `json:"templates"`
+	// The namespaces grants on templates were evaluated in
+	Namespaces []string `json:"namespaces"`
+	// The permissions held by the users
+	Entries []*PermissionMatrixEntry `json:"entries"`
+}
+
+// PermissionMatrixEntry is a single permission held by a user.
+type PermissionMatrixEntry struct {
+	// The user holding the permission
+	User string `json:"user"`
+	// The type of resource the permission applies to
+	Resource rbacv1.Resource `json:"resource"`
+	// The action allowed on the resource
+	Verb rbacv1.Verb `json:"verb"`
+	// The template the permission applies to, empty for permissions on the resource type as
+	// a whole
+	Template string `json:"template,omitempty"`
+	// The namespace the permission applies in, empty for permissions that do not apply
+	// to a namespace
+	Namespace string `json:"namespace,omitempty"`
+	// The role containing the rule that grants the permission
+	Role string `json:"role"`
+	// The index of the rule in the role that grants the permission
+	Rule int `json:"rule"`
+}
+
 // UpdateRoleRequest requests updates to an existing role. The existing attributes
 // will be entirely replaced with those supplied in the payload.
 type UpdateRoleRequest struct {
@@ -210,6 +457,16 @@ type UpdateRoleRequest struct {
 	Annotations map[string]string `json:"annotations"`
 	// The new rules for the role.
 	Rules []rbacv1.Rule `json:"rules"`
+	// The new device policy for the role.
+	Devices *rbacv1.DevicePolicy `json:"devices,omitempty"`
+	// The new access hours for the role.
+	AccessHours *rbacv1.AccessHours `json:"accessHours,omitempty"`
+	// The new session quotas for the role.
+	Quotas *rbacv1.SessionQuotas `json:"quotas,omitempty"`
+	// The new display bandwidth weight for the role.
+	BandwidthWeight int32 `json:"bandwidthWeight,omitempty"`
+	// The names of other roles the role inherits rules from.
+	InheritsFrom []string `json:"inheritsFrom,omitempty"`
 }
 
 // GetAnnotations returns the annotations provided in the request
@@ -231,20 +488,44 @@ func (r *UpdateRoleRequest) GetRules() []rbacv1.Rule {
 
 // Validate the UpdateRoleRequest
 func (r *UpdateRoleRequest) Validate() error {
-	for _, rule := range r.Rules {
-		if err := validatePatterns(rule.ResourcePatterns); err != nil {
+	for i, rule := range r.Rules {
+		if err := validateRule(field.NewPath("rules").Index(i), rule); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// validatePatterns takes a list of regexes and returns an error if any of them
-// are invalid.
-func validatePatterns(patterns []string) error {
-	for _, pattern := range patterns {
-		if _, err := regexp.Compile(pattern); err != nil {
-			return fmt.Errorf("%s is an invalid regex: %s", pattern, err.Error())
+// validateRule returns an error if the given rule has invalid resource patterns, an invalid
+// namespace selector, or an invalid maximum session duration. Errors name the offending
+// field under fldPath.
+func validateRule(fldPath *field.Path, rule rbacv1.Rule) error {
+	if err := validatePatterns(fldPath, rule); err != nil {
+		return err
+	}
+	if _, err := rule.GetNamespaceSelector(); err != nil {
+		return fmt.Errorf("%s: Invalid namespace selector: %s", fldPath.Child("namespaceSelector"), err.Error())
+	}
+	if rule.MaxSessionDuration != "" {
+		if dur, err := time.ParseDuration(rule.MaxSessionDuration); err != nil || dur <= 0 {
+			return fmt.Errorf("%s: %s is not a valid maximum session duration", fldPath.Child("maxSessionDuration"), rule.MaxSessionDuration)
+		}
+	}
+	return nil
+}
+
+// validatePatterns returns an error if the pattern type of the given rule is unknown
+// or any of its resource patterns are invalid for it.
+func validatePatterns(fldPath *field.Path, rule rbacv1.Rule) error {
+	patternType := rule.GetPatternType()
+	switch patternType {
+	case rbacv1.PatternTypeRegex, rbacv1.PatternTypeGlob, rbacv1.PatternTypeExact:
+	default:
+		return fmt.Errorf("%s: %s is not a supported pattern type", fldPath.Child("patternType"), patternType)
+	}
+	for i, pattern := range rule.ResourcePatterns {
+		if err := rbacv1.ValidatePattern(patternType, pattern); err != nil {
+			return fmt.Errorf("%s: %s is an invalid %s: %s", fldPath.Child("resourcePatterns").Index(i), pattern, patternType, err.Error())
 		}
 	}
 	return nil
@@ -258,6 +539,19 @@ type CreateSessionRequest struct {
 	Namespace string `json:"namespace,omitempty"`
 	// A service account to tie to the desktop session. Defaults to none.
 	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// The architecture to run the session on (e.g. `arm64`). Defaults to one chosen by
+	// the scheduler, or to the architecture with the most available nodes when the
+	// template has per-architecture images.
+	Architecture string `json:"architecture,omitempty"`
+	// The ticket or change ID to launch the session against. Required when the template
+	// requires a ticket.
+	Ticket string `json:"ticket,omitempty"`
+	// Responses to the launch prompts of the template, keyed by prompt name. Acknowledgments
+	// are answered with `true`.
+	Prompts map[string]string `json:"prompts,omitempty"`
+	// The name of a DesktopSnapshot in the namespace to restore the user's $HOME from. The
+	// snapshot must have been taken of a desktop launched from the same template.
+	FromSnapshot string `json:"fromSnapshot,omitempty"`
 }
 
 // Validate the CreateSessionRequest
@@ -283,11 +577,245 @@ func (r *CreateSessionRequest) GetNamespace() string {
 // GetServiceAccount returns the service account for this request.
 func (r *CreateSessionRequest) GetServiceAccount() string { return r.ServiceAccount }
 
+// CreateSnapshotRequest is a request to snapshot the persistent volumes of a desktop session.
+type CreateSnapshotRequest struct {
+	// A name for the snapshot. Defaults to one generated from the name of the session.
+	Name string `json:"name,omitempty"`
+	// The VolumeSnapshotClass to snapshot the volumes with. Defaults to the default class
+	// of their storage driver.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// AddMonitorRequest is a request to attach an additional monitor to a desktop session.
+// When the desktop spans a single monitor, the primary monitor is assumed to have the
+// same resolution.
+type AddMonitorRequest struct {
+	// The width of the monitor in pixels.
+	Width int32 `json:"width"`
+	// The height of the monitor in pixels.
+	Height int32 `json:"height"`
+}
+
+// Validate the AddMonitorRequest
+func (r *AddMonitorRequest) Validate() error {
+	if r.Width <= 0 || r.Height <= 0 {
+		return errors.New("Width and height must be greater than zero")
+	}
+	if r.Width > MaxDisplayDimension || r.Height > MaxDisplayDimension {
+		return fmt.Errorf("Width and height may not exceed %d", MaxDisplayDimension)
+	}
+	return nil
+}
+
+// DisplayResizeRequest is a request to change the resolution and DPI of a desktop
+// session's display.
+type DisplayResizeRequest struct {
+	// The width of the display in pixels.
+	Width int32 `json:"width"`
+	// The height of the display in pixels.
+	Height int32 `json:"height"`
+	// The DPI of the display. Leave unset to keep the current DPI.
+	DPI int32 `json:"dpi,omitempty"`
+}
+
+// Validate the DisplayResizeRequest
+func (r *DisplayResizeRequest) Validate() error {
+	if r.Width <= 0 || r.Height <= 0 {
+		return errors.New("Width and height must be greater than zero")
+	}
+	if r.Width > MaxDisplayDimension || r.Height > MaxDisplayDimension {
+		return fmt.Errorf("Width and height may not exceed %d", MaxDisplayDimension)
+	}
+	if r.DPI != 0 && (r.DPI < metav1.MinDisplayDPI || r.DPI > metav1.MaxDisplayDPI) {
+		return fmt.Errorf("DPI must be between %d and %d", metav1.MinDisplayDPI, metav1.MaxDisplayDPI)
+	}
+	return nil
+}
+
+// DisplayControlResize is the type of display control message requesting a new resolution
+// and DPI for the display.
+const DisplayControlResize = "resize"
+
+// DisplayControlMessage is sent by clients as a text message over a display websocket to
+// control the display while it is streamed. Binary messages carry the display stream
+// itself. Control messages are only accepted for displays that can be resized.
+type DisplayControlMessage struct {
+	// The type of control message. Only `resize` is currently supported.
+	Type string `json:"type"`
+	// The new resolution and DPI of the display for `resize` messages.
+	DisplayResizeRequest `json:",inline"`
+}
+
 // CreateSessionResponse returns the name of the Desktop and what namespace
 // it is running in.
 type CreateSessionResponse struct {
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
+	// Set when the session was launched from a deprecated template.
+	Warning string `json:"warning,omitempty"`
+}
+
+// ConfigValidationReport reports whether the configuration of a VDICluster is ready to
+// serve traffic.
+type ConfigValidationReport struct {
+	// The name of the VDICluster that was validated.
+	Cluster string `json:"cluster"`
+	// True when every check passed.
+	Ready bool `json:"ready"`
+	// The result of each check, in the order they were run.
+	Checks []*ConfigCheck `json:"checks"`
+}
+
+// ConfigCheck is the result of a single configuration check.
+type ConfigCheck struct {
+	// The name of the check (e.g. `secrets`, `auth`, `tls-server`).
+	Name string `json:"name"`
+	// True when the check passed.
+	Ready bool `json:"ready"`
+	// True when the check was not run because one it depends on failed.
+	Skipped bool `json:"skipped,omitempty"`
+	// A description of what was verified, or why the check was skipped.
+	Message string `json:"message,omitempty"`
+	// The error encountered when the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// CapacityResponse reports the desktop capacity of the cluster by node architecture.
+type CapacityResponse struct {
+	// The capacity of each architecture present in the cluster.
+	Architectures []*ArchitectureCapacity `json:"architectures"`
+}
+
+// ArchitectureCapacity reports the nodes of a single architecture and the templates that
+// can be launched on them.
+type ArchitectureCapacity struct {
+	// The architecture of the nodes (e.g. `amd64`).
+	Architecture string `json:"architecture"`
+	// The number of nodes with the architecture.
+	Nodes int `json:"nodes"`
+	// The number of those nodes that are ready and schedulable.
+	AvailableNodes int `json:"availableNodes"`
+	// The total allocatable CPU of the available nodes.
+	AllocatableCPU string `json:"allocatableCPU"`
+	// The total allocatable memory of the available nodes.
+	AllocatableMemory string `json:"allocatableMemory"`
+	// The number of desktop sessions running on nodes with the architecture.
+	Sessions int `json:"sessions"`
+	// The templates visible to the requesting user that can run on the architecture.
+	Templates []string `json:"templates"`
+}
+
+// AutoscalingHints reports the desktop capacity the cluster should have, for node
+// autoscalers to scale desktop node pools ahead of demand.
+type AutoscalingHints struct {
+	// The number of desktop sessions running.
+	RunningDesktops int `json:"runningDesktops"`
+	// The number of desktop sessions waiting for a node to run on.
+	QueuedLaunches int `json:"queuedLaunches"`
+	// The number of desktops spare capacity is kept for.
+	WarmPool int `json:"warmPool"`
+	// The minimum number of desktops required by the active capacity schedules.
+	ScheduledMinimum int `json:"scheduledMinimum"`
+	// The names of the capacity schedules that are active.
+	ActiveSchedules []string `json:"activeSchedules"`
+	// The number of desktops the cluster should have capacity for. This is the larger of
+	// the running, queued, and warm pool desktops combined and the scheduled minimum.
+	DesiredDesktops int `json:"desiredDesktops"`
+	// The number of nodes needed for the desired desktops, when the number of desktops per
+	// node is configured.
+	DesiredNodes int `json:"desiredNodes,omitempty"`
+}
+
+// LicenseUsageResponse reports the usage of the license pools configured on the cluster.
+type LicenseUsageResponse struct {
+	// The usage of each license pool.
+	Pools []*LicensePoolUsage `json:"pools"`
+}
+
+// LicensePoolUsage reports the seats of a single license pool.
+type LicensePoolUsage struct {
+	// The name of the pool.
+	Name string `json:"name"`
+	// The number of seats configured for the pool, zero when only a query hook is used.
+	Seats int `json:"seats"`
+	// The number of seats held by sessions.
+	InUse int `json:"inUse"`
+	// The number of sessions waiting for seats from the pool.
+	Queued int `json:"queued"`
+	// The number of seats that can be granted right now.
+	Available int `json:"available"`
+	// What happens to launches when the pool has no seats left.
+	OnExhausted string `json:"onExhausted"`
+	// An error querying the license server for available seats, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// BulkCreateSessionRequest requests the same template be launched in several namespaces
+// and/or for several users in a single call.
+type BulkCreateSessionRequest struct {
+	// The template to create the sessions from.
+	Template string `json:"template"`
+	// The namespaces to launch the template in. Defaults to default.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// The users to launch the template for. Defaults to the requesting user. Launching
+	// for other users requires the ability to update them, and each user must be able
+	// to launch the template in every namespace themselves.
+	Users []string `json:"users,omitempty"`
+	// A service account to tie to each desktop session. Defaults to none.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// The ticket or change ID to launch the sessions against. Required when the template
+	// requires a ticket.
+	Ticket string `json:"ticket,omitempty"`
+	// Responses to the launch prompts of the template, keyed by prompt name. The same
+	// responses are used for every session.
+	Prompts map[string]string `json:"prompts,omitempty"`
+	// When true, sessions that launched successfully are kept even if others fail.
+	// Otherwise any failure causes all of the sessions created by the request to be
+	// removed.
+	AllowPartial bool `json:"allowPartial,omitempty"`
+}
+
+// Validate the BulkCreateSessionRequest
+func (r *BulkCreateSessionRequest) Validate() error {
+	if r.Template == "" {
+		return errors.New("A template is required")
+	}
+	return nil
+}
+
+// GetNamespaces returns the namespaces for this request, or the default namespace
+// if none are provided.
+func (r *BulkCreateSessionRequest) GetNamespaces() []string {
+	if len(r.Namespaces) == 0 {
+		return []string{metav1.DefaultNamespace}
+	}
+	return r.Namespaces
+}
+
+// BulkCreateSessionResult is the outcome of launching a single session in a bulk request.
+type BulkCreateSessionResult struct {
+	// The user the session was launched for.
+	User string `json:"user"`
+	// The namespace the session was launched in.
+	Namespace string `json:"namespace"`
+	// The name of the session, if it was created.
+	Name string `json:"name,omitempty"`
+	// The reason the session could not be launched, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// BulkCreateSessionResponse contains the consolidated results of a bulk launch.
+type BulkCreateSessionResponse struct {
+	// The result for each requested session.
+	Results []*BulkCreateSessionResult `json:"results"`
+	// The number of sessions that launched successfully.
+	Succeeded int `json:"succeeded"`
+	// The number of sessions that failed to launch.
+	Failed int `json:"failed"`
+	// True if successfully launched sessions were removed because others failed.
+	RolledBack bool `json:"rolledBack"`
+	// Set when the sessions were launched from a deprecated template.
+	Warning string `json:"warning,omitempty"`
 }
 
 // DesktopSessionsResponse contains a list of desktop sessions and information
@@ -309,6 +837,8 @@ type DesktopSession struct {
 	ServiceAccount string `json:"serviceAccount"`
 	// The template this session is booted from.
 	Template string `json:"template"`
+	// The ticket or change ID the session was launched against, if any.
+	Ticket string `json:"ticket,omitempty"`
 	// Connection status for the session.
 	Status *DesktopSessionStatus `json:"status"`
 }
@@ -323,6 +853,23 @@ type DesktopSessionStatus struct {
 	Display *ConnectionStatus `json:"display"`
 	// Connection status for the desktop's audio.
 	Audio *ConnectionStatus `json:"audio"`
+	// Whether the template's maintenance window is open and the session will be recreated
+	// once it is no longer in use.
+	MaintenancePending bool `json:"maintenancePending,omitempty"`
+	// Whether the static host the session connects to is being powered on.
+	Booting bool `json:"booting,omitempty"`
+	// The unix time the session will be terminated for exceeding its maximum duration, if
+	// it has one.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	// The number of seconds remaining until the session is terminated, if it has a maximum
+	// duration.
+	RemainingSeconds int64 `json:"remainingSeconds,omitempty"`
+	// The number of seconds since input was last received from a client of the desktop,
+	// as last reported by its proxy.
+	IdleSeconds int64 `json:"idleSeconds,omitempty"`
+	// The unix time the session will be terminated for being idle, if its user has been
+	// warned.
+	IdleTerminatesAt int64 `json:"idleTerminatesAt,omitempty"`
 }
 
 // ConnectionStatus describes the connection status of a desktop's display or audio.
@@ -335,12 +882,111 @@ type ConnectionStatus struct {
 	ProxyPod string `json:"proxyPod,omitempty"`
 }
 
+// UserMetadataValue is a single value in a user's metadata.
+type UserMetadataValue struct {
+	// The value of the key.
+	Value string `json:"value"`
+}
+
+// SSHCertificateRequest is a request to sign an SSH public key for access to a desktop
+// session.
+type SSHCertificateRequest struct {
+	// The public key to sign, in authorized_keys format
+	PublicKey string `json:"publicKey"`
+}
+
+// Validate the SSHCertificateRequest
+func (r *SSHCertificateRequest) Validate() error {
+	if r.PublicKey == "" {
+		return errors.New("A public key is required")
+	}
+	return nil
+}
+
+// SSHCertificateResponse contains a signed SSH user certificate.
+type SSHCertificateResponse struct {
+	// The signed certificate, in authorized_keys format
+	Certificate string `json:"certificate"`
+	// The principal (username) the certificate is valid for
+	Principal string `json:"principal"`
+	// The time the certificate expires, as a unix timestamp
+	ValidBefore int64 `json:"validBefore"`
+}
+
+// SessionKubeconfigResponse contains a short-lived kubeconfig for a desktop session. The
+// credentials carry the permissions of the session's service account and are revoked when
+// the session ends.
+type SessionKubeconfigResponse struct {
+	// The kubeconfig, in YAML format
+	Kubeconfig string `json:"kubeconfig"`
+	// The time the credentials expire, as a unix timestamp
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
 // StatDesktopFileResponse contains the info for a queried file inside a desktop
 // dession.
 type StatDesktopFileResponse struct {
 	Stat *FileStat `json:"stat"`
 }
 
+// HomeUsageResponse contains the storage usage of the user's $HOME directory inside
+// a desktop session.
+type HomeUsageResponse struct {
+	// The number of bytes currently used
+	Used int64 `json:"used"`
+	// The quota in bytes, zero if no quota is enforced
+	Limit int64 `json:"limit"`
+	// True if usage is above the configured warning threshold
+	Warning bool `json:"warning"`
+	// True if usage has reached the quota
+	Exceeded bool `json:"exceeded"`
+}
+
+// UserVolume describes the persistent volume provisioned from the cluster's
+// `userdataSpec` to hold a user's $HOME directory.
+type UserVolume struct {
+	// The name of the PersistentVolume
+	Name string `json:"name"`
+	// The storage class the volume was provisioned from
+	StorageClass string `json:"storageClass,omitempty"`
+	// The current capacity of the volume
+	Capacity string `json:"capacity"`
+	// The capacity requested by the claim mounting the volume. This is larger than the
+	// capacity while an expansion is in progress.
+	Requested string `json:"requested,omitempty"`
+	// The phase of the volume
+	Phase string `json:"phase"`
+	// What happens to the volume when the user is deleted
+	ReclaimPolicy string `json:"reclaimPolicy"`
+	// The name of the claim currently mounting the volume in one of the user's desktops,
+	// empty if none of their desktops are running
+	ClaimName string `json:"claimName,omitempty"`
+	// The namespace of the claim currently mounting the volume
+	ClaimNamespace string `json:"claimNamespace,omitempty"`
+}
+
+// ExpandUserVolumeRequest requests a user's $HOME volume be expanded.
+type ExpandUserVolumeRequest struct {
+	// The new size of the volume (e.g. `20Gi`). Volumes can only grow.
+	Size string `json:"size"`
+}
+
+// Validate the ExpandUserVolumeRequest
+func (r *ExpandUserVolumeRequest) Validate() error {
+	if r.Size == "" {
+		return errors.New("'size' must be provided")
+	}
+	if _, err := r.GetSize(); err != nil {
+		return fmt.Errorf("'size' is invalid: %s", err.Error())
+	}
+	return nil
+}
+
+// GetSize returns the requested size as a quantity.
+func (r *ExpandUserVolumeRequest) GetSize() (resource.Quantity, error) {
+	return resource.ParseQuantity(r.Size)
+}
+
 // FileStat contains information about a queried file. Contents will only contain
 // nested FileStat objects when this object represents the root of the query.
 type FileStat struct {
@@ -353,3 +999,435 @@ type FileStat struct {
 	// When IsDirectory is true, the contents of the directory
 	Contents []*FileStat `json:"contents,omitempty"`
 }
+
+// TemplateLintResponse contains the findings from linting a DesktopTemplate.
+type TemplateLintResponse struct {
+	// The lint findings for the template.
+	Results []desktopsv1.LintResult `json:"results"`
+}
+
+// SaveSessionTemplateRequest requests a new draft template be saved from a desktop session.
+type SaveSessionTemplateRequest struct {
+	// The name of the new template.
+	Name string `json:"name"`
+	// The desktop image to use in the new template, such as one committed from the session.
+	// Defaults to the image the session is running.
+	Image string `json:"image,omitempty"`
+	// Tags for displaying the new template in the app UI. Defaults to the tags of the
+	// template the session was launched from.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Validate the SaveSessionTemplateRequest.
+func (r *SaveSessionTemplateRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("'name' must be provided")
+	}
+	return nil
+}
+
+// ReviewTemplateRequest is used when approving or rejecting a draft template.
+type ReviewTemplateRequest struct {
+	// An optional comment to record with the decision.
+	Comment string `json:"comment,omitempty"`
+}
+
+// MarketplaceTemplate represents a template available from a remote template index.
+type MarketplaceTemplate struct {
+	// The name of the index listing the template.
+	Index string `json:"index"`
+	// The name of the template.
+	Name string `json:"name"`
+	// The version of the template in the index.
+	Version string `json:"version"`
+	// A short description of the template.
+	Description string `json:"description,omitempty"`
+	// Tags for searching templates.
+	Tags []string `json:"tags,omitempty"`
+	// The version of the template currently installed from the index, if any.
+	InstalledVersion string `json:"installedVersion,omitempty"`
+	// True when the template is installed and the index lists a different version.
+	UpdateAvailable bool `json:"updateAvailable,omitempty"`
+}
+
+// MarketplaceResponse contains the templates available from the cluster's template
+// indexes.
+type MarketplaceResponse struct {
+	// The available templates.
+	Templates []*MarketplaceTemplate `json:"templates"`
+	// Errors retrieving or verifying indexes, keyed by the name of the index.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// LabBroadcastRequest is a request to deliver a message to every member of a lab.
+type LabBroadcastRequest struct {
+	// The message to broadcast.
+	Message string `json:"message"`
+}
+
+// Validate the LabBroadcastRequest
+func (r *LabBroadcastRequest) Validate() error {
+	if r.Message == "" {
+		return errors.New("A message is required")
+	}
+	return nil
+}
+
+// LabLockRequest is a request to lock the screens of every member of a lab.
+type LabLockRequest struct {
+	// An optional message to show to users whose screens are locked.
+	Message string `json:"message,omitempty"`
+}
+
+// LabControlResponse contains the outcome of a lab broadcast, lock, or unlock for
+// each session in the lab.
+type LabControlResponse struct {
+	// The result for each session.
+	Results []*LabSessionResult `json:"results"`
+}
+
+// LabSessionResult is the outcome of an operation against a single session in a lab.
+type LabSessionResult struct {
+	// The user the session belongs to.
+	User string `json:"user"`
+	// The name of the session.
+	Name string `json:"name"`
+	// The reason the operation failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// AccessRequestState represents the state of a template access request.
+type AccessRequestState string
+
+const (
+	// AccessRequestPending means the request is waiting for review.
+	AccessRequestPending AccessRequestState = "Pending"
+	// AccessRequestApproved means the request was approved and access was granted.
+	AccessRequestApproved AccessRequestState = "Approved"
+	// AccessRequestDenied means the request was denied.
+	AccessRequestDenied AccessRequestState = "Denied"
+)
+
+// CreateAccessRequest is a request by a user to be granted access to launch a template
+// they can see but not launch.
+type CreateAccessRequest struct {
+	// The template to request access to.
+	Template string `json:"template"`
+	// The namespace to request access to launch the template in. Defaults to default.
+	Namespace string `json:"namespace,omitempty"`
+	// The reason access is being requested.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate the CreateAccessRequest
+func (r *CreateAccessRequest) Validate() error {
+	if r.Template == "" {
+		return errors.New("A template is required")
+	}
+	return nil
+}
+
+// GetTemplate returns the template for this request
+func (r *CreateAccessRequest) GetTemplate() string { return r.Template }
+
+// GetNamespace returns the namspace for this request, or the default namespace
+// if not provided.
+func (r *CreateAccessRequest) GetNamespace() string {
+	if r.Namespace != "" {
+		return r.Namespace
+	}
+	return metav1.DefaultNamespace
+}
+
+// ReviewAccessRequest is used when approving or denying an access request.
+type ReviewAccessRequest struct {
+	// An optional comment to record with the decision.
+	Comment string `json:"comment,omitempty"`
+}
+
+// AccessRequest represents a user's request for access to launch a template.
+type AccessRequest struct {
+	// A unique ID for the request
+	ID string `json:"id"`
+	// The user that made the request
+	User string `json:"user"`
+	// The template access was requested to
+	Template string `json:"template"`
+	// The namespace access was requested to launch the template in
+	Namespace string `json:"namespace"`
+	// The reason given by the user
+	Reason string `json:"reason,omitempty"`
+	// The current state of the request
+	State AccessRequestState `json:"state"`
+	// The unix time the request was made
+	CreatedAt int64 `json:"createdAt"`
+	// The user that reviewed the request
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+	// The unix time the request was reviewed
+	ReviewedAt int64 `json:"reviewedAt,omitempty"`
+	// The comment left by the reviewer
+	Comment string `json:"comment,omitempty"`
+}
+
+// AgentHealthReport is sent by the kvdi-agent inside a desktop to report the health of the
+// apps running in it.
+type AgentHealthReport struct {
+	// The health of each app being watched by the agent.
+	Apps []AgentAppHealth `json:"apps,omitempty"`
+}
+
+// AgentAppHealth represents the health of a single app inside a desktop.
+type AgentAppHealth struct {
+	// The name of the app.
+	Name string `json:"name"`
+	// Whether the app is healthy.
+	Healthy bool `json:"healthy"`
+	// An optional message describing the state of the app.
+	Message string `json:"message,omitempty"`
+}
+
+// Validate the AgentHealthReport
+func (r *AgentHealthReport) Validate() error {
+	for _, app := range r.Apps {
+		if app.Name == "" {
+			return errors.New("Every app in a health report must have a name")
+		}
+	}
+	return nil
+}
+
+// Healthy returns true if every app in the report is healthy.
+func (r *AgentHealthReport) Healthy() bool {
+	for _, app := range r.Apps {
+		if !app.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxDisplayDimension is the largest width or height that may be requested for a desktop's
+// display.
+const MaxDisplayDimension = 8192
+
+// AgentResolutionRequest is sent by the kvdi-agent to request a new resolution for the
+// desktop's display.
+type AgentResolutionRequest struct {
+	// The requested width in pixels.
+	Width int32 `json:"width"`
+	// The requested height in pixels.
+	Height int32 `json:"height"`
+}
+
+// Validate the AgentResolutionRequest
+func (r *AgentResolutionRequest) Validate() error {
+	if r.Width <= 0 || r.Height <= 0 {
+		return errors.New("Width and height must be greater than zero")
+	}
+	if r.Width > MaxDisplayDimension || r.Height > MaxDisplayDimension {
+		return fmt.Errorf("Width and height may not exceed %d", MaxDisplayDimension)
+	}
+	return nil
+}
+
+// AgentBroadcast is the latest message broadcast to a desktop, as retrieved by the kvdi-agent.
+type AgentBroadcast struct {
+	// The message that was broadcast. Empty if nothing has been broadcast to the desktop.
+	Message string `json:"message,omitempty"`
+	// The unix time the message was broadcast.
+	Time int64 `json:"time,omitempty"`
+}
+
+// AgentMetadata is served by the kvdi-agent to tools inside a desktop.
+type AgentMetadata struct {
+	// The name of the session.
+	Name string `json:"name"`
+	// The namespace of the session.
+	Namespace string `json:"namespace"`
+	// The template the session was booted from.
+	Template string `json:"template"`
+	// The user that owns the session.
+	User string `json:"user"`
+	// The key-value metadata stored for the user, keyed by namespace.
+	Metadata map[string]map[string]string `json:"metadata"`
+}
+
+// BackupRequest is a request to export the state of the cluster to an encrypted archive.
+type BackupRequest struct {
+	// The passphrase to encrypt the archive with.
+	Passphrase string `json:"passphrase"`
+}
+
+// Validate the BackupRequest
+func (r *BackupRequest) Validate() error {
+	if r.Passphrase == "" {
+		return errors.New("'passphrase' must be provided in the request")
+	}
+	return nil
+}
+
+// BackupResponse contains an encrypted backup archive.
+type BackupResponse struct {
+	// The encrypted archive.
+	Archive []byte `json:"archive"`
+	// The time the archive was created.
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// RestoreRequest is a request to restore the state of the cluster from an encrypted archive.
+type RestoreRequest struct {
+	// The encrypted archive.
+	Archive []byte `json:"archive"`
+	// The passphrase the archive was encrypted with.
+	Passphrase string `json:"passphrase"`
+	// Do not replace the configuration of the cluster with the one in the archive.
+	SkipClusterConfig bool `json:"skipClusterConfig,omitempty"`
+}
+
+// Validate the RestoreRequest
+func (r *RestoreRequest) Validate() error {
+	if len(r.Archive) == 0 || r.Passphrase == "" {
+		return errors.New("'archive' and 'passphrase' must be provided in the request")
+	}
+	return nil
+}
+
+// RestoreResponse reports what was restored from an archive.
+type RestoreResponse struct {
+	// The name of the VDICluster that was restored to.
+	Cluster string `json:"cluster"`
+	// The number of roles restored.
+	Roles int `json:"roles"`
+	// The number of teams restored.
+	Teams int `json:"teams"`
+	// The number of templates restored.
+	Templates int `json:"templates"`
+	// The number of values restored to the secrets backend.
+	Secrets int `json:"secrets"`
+	// Whether the cluster configuration was replaced.
+	ClusterConfig bool `json:"clusterConfig"`
+}
+
+// StagedConfig is a proposed revision of the VDICluster spec waiting to be promoted.
+type StagedConfig struct {
+	// The proposed spec.
+	Spec appv1.VDIClusterSpec `json:"spec"`
+	// The user that staged the revision.
+	StagedBy string `json:"stagedBy,omitempty"`
+	// The time the revision was staged.
+	StagedAt int64 `json:"stagedAt"`
+	// The generation of the VDICluster the revision was staged against. Promotion is refused
+	// if the cluster has changed since, unless it is forced.
+	BaseGeneration int64 `json:"baseGeneration"`
+}
+
+// StageConfigRequest is a request to stage a proposed revision of the VDICluster spec.
+type StageConfigRequest struct {
+	// The proposed spec.
+	Spec appv1.VDIClusterSpec `json:"spec"`
+}
+
+// PromoteConfigRequest is a request to promote the staged revision of the VDICluster spec.
+type PromoteConfigRequest struct {
+	// Promote the revision even if it fails validation, or the cluster has changed since
+	// it was staged.
+	Force bool `json:"force,omitempty"`
+}
+
+// StagedConfigPreview describes what promoting a staged revision would change.
+type StagedConfigPreview struct {
+	// The staged revision.
+	Staged *StagedConfig `json:"staged"`
+	// Whether the VDICluster has changed since the revision was staged.
+	Stale bool `json:"stale"`
+	// The changes to the VDICluster spec.
+	Changes []*FieldChange `json:"changes"`
+	// The changes the reconciler would make to the core resources of the cluster.
+	Resources []*ResourceDiff `json:"resources"`
+	// The validation report for the staged revision.
+	Validation *ConfigValidationReport `json:"validation"`
+}
+
+// ResourceDiffAction is the action the reconciler would take on a resource.
+type ResourceDiffAction string
+
+// Valid resource diff actions
+const (
+	ResourceCreate ResourceDiffAction = "create"
+	ResourceUpdate ResourceDiffAction = "update"
+	ResourceDelete ResourceDiffAction = "delete"
+)
+
+// ResourceDiff describes the change to a single resource.
+type ResourceDiff struct {
+	// The kind of the resource.
+	Kind string `json:"kind"`
+	// The namespace of the resource, empty for cluster-scoped resources.
+	Namespace string `json:"namespace,omitempty"`
+	// The name of the resource.
+	Name string `json:"name"`
+	// The action the reconciler would take.
+	Action ResourceDiffAction `json:"action"`
+	// The changed fields, for updates.
+	Changes []*FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange describes the change to a single field. Values are JSON encoded and
+// empty when the field is added or removed.
+type FieldChange struct {
+	// The dotted path to the field.
+	Path string `json:"path"`
+	// The current value.
+	From string `json:"from,omitempty"`
+	// The new value.
+	To string `json:"to,omitempty"`
+}
+
+// FaultPoint is a point in the server where faults can be injected.
+type FaultPoint string
+
+// Valid fault injection points
+const (
+	// FaultPointSecretsRead fails or delays reads from the secrets backend.
+	FaultPointSecretsRead FaultPoint = "secrets-read"
+	// FaultPointDisplayFrames drops or delays frames sent from desktop displays to clients.
+	FaultPointDisplayFrames FaultPoint = "display-frames"
+	// FaultPointAudioFrames drops or delays frames sent from desktop audio streams to clients.
+	FaultPointAudioFrames FaultPoint = "audio-frames"
+)
+
+// Fault describes a fault to inject at a point in the server. Faults are only injected
+// by servers built with the `faults` build tag.
+type Fault struct {
+	// The point in the server to inject the fault.
+	Point FaultPoint `json:"point"`
+	// A delay to add every time the point is reached, e.g. `500ms`.
+	Delay string `json:"delay,omitempty"`
+	// Fail every nth time the point is reached. A value of 1 fails every time, while 0
+	// only adds the delay. Failing a frame drops it, failing a secrets read returns an
+	// error.
+	FailEvery int `json:"failEvery,omitempty"`
+	// The number of failures to inject before the fault is removed. Defaults to no limit.
+	Limit int `json:"limit,omitempty"`
+}
+
+// FaultInjectionRequest is a request to replace the faults injected into the server.
+type FaultInjectionRequest struct {
+	// The faults to inject. An empty list removes all faults.
+	Faults []*Fault `json:"faults"`
+}
+
+// FaultInjectionStatus describes the faults currently injected into the server.
+type FaultInjectionStatus struct {
+	// The faults currently injected and how often they have fired.
+	Faults []*InjectedFault `json:"faults"`
+}
+
+// InjectedFault is a fault injected into the server and its counters.
+type InjectedFault struct {
+	Fault
+	// The number of times the point was reached since the fault was injected.
+	Hits int `json:"hits"`
+	// The number of failures injected so far.
+	Failures int `json:"failures"`
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// AuditEvent is a record of an authenticated request to the API.
+type AuditEvent struct {
+	// The time the request was made
+	Timestamp time.Time `json:"timestamp"`
+	// The user that made the request
+	User string `json:"user"`
+	// The HTTP method of the request
+	Method string `json:"method"`
+	// The path of the request
+	Path string `json:"path"`
+	// The actions the request was evaluated against
+	Actions []*APIAction `json:"actions,omitempty"`
+	// Whether the request was allowed
+	Allowed bool `json:"allowed"`
+	// Whether the request was allowed because the user owns the resource
+	FromOwner bool `json:"fromOwner,omitempty"`
+	// The IP address the request was received from
+	SourceIP string `json:"sourceIP"`
+	// The X-Forwarded-For header of the request, if any
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	// Additional details about the event, such as the ticket a session was launched
+	// against or why a request was denied
+	Details map[string]string `json:"details,omitempty"`
+	// A human readable summary of the event
+	Message string `json:"message"`
+}
+
+// AuditQuery represents filters for retrieving audit events.
+type AuditQuery struct {
+	// Only return events for this user
+	User string `json:"user,omitempty"`
+	// Only return events at or after this time
+	Since time.Time `json:"since,omitempty"`
+	// Only return events before this time
+	Until time.Time `json:"until,omitempty"`
+	// The maximum number of events to return, starting with the most recent
+	Limit int `json:"limit,omitempty"`
+}
+
+// Validate the AuditQuery
+func (q *AuditQuery) Validate() error {
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Until.After(q.Since) {
+		return errors.New("The end of the time range must be after the start")
+	}
+	if q.Limit < 0 {
+		return errors.New("The limit cannot be negative")
+	}
+	return nil
+}
+
+// Matches returns true if the given event satisfies the filters of the query.
+func (q *AuditQuery) Matches(ev *AuditEvent) bool {
+	if q.User != "" && ev.User != q.User {
+		return false
+	}
+	if !q.Since.IsZero() && ev.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !ev.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLogResponse contains the audit events matching a query.
+type AuditLogResponse struct {
+	// The matching events, most recent first
+	Events []*AuditEvent `json:"events"`
+}
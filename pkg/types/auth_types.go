@@ -22,6 +22,7 @@ package types
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 
@@ -73,6 +74,17 @@ type AuthResult struct {
 	// without initializing a new auth flow. For now, the provider can set this to false to
 	// signal to the server that a refresh is not possible.
 	RefreshNotSupported bool
+	// The time the user's session originally started. This is set when renewing tokens so that
+	// the maximum session age is enforced across renewals. Defaults to the current time.
+	SessionStart time.Time
+	// The name of the issuer that verified the user's device, if any.
+	TrustedDevice string
+	// The device or browser the user is signing in from, if known. Devices are recorded
+	// against the user's device limit when a fully authorized token is issued.
+	Device *UserDevice
+	// When set, the user may log in and connect to desktops outside of the access hours
+	// of their roles until this time.
+	AccessOverrideExpiresAt time.Time
 }
 
 // JWTClaims represents the claims used when issuing JWT tokens.
@@ -85,10 +97,40 @@ type JWTClaims struct {
 	Renewable bool `json:"renewable"`
 	// Additional data that was provided by the authentication provider
 	Data map[string]string `json:"data"`
+	// The unix time the user's session originally started
+	SessionStart int64 `json:"sessionStart,omitempty"`
+	// The name of the issuer that verified the user's device, if any
+	TrustedDevice string `json:"trustedDevice,omitempty"`
+	// The ID of the device the token was issued to, if known
+	Device string `json:"device,omitempty"`
+	// The unix time until which an access override allows the user in outside of the
+	// access hours of their roles
+	AccessOverrideExpiresAt int64 `json:"accessOverrideExpiresAt,omitempty"`
+	// The unix time in nanoseconds the token was issued, for comparing against revocations
+	// made within the same second
+	IssuedAtNanos int64 `json:"iatNanos,omitempty"`
 	// The standard JWT claims
 	jwt.StandardClaims
 }
 
+// GetSessionStart returns the time the user's session originally started. Tokens issued
+// before the field was introduced fall back to their issue time.
+func (j *JWTClaims) GetSessionStart() time.Time {
+	if j.SessionStart == 0 {
+		return time.Unix(j.IssuedAt, 0)
+	}
+	return time.Unix(j.SessionStart, 0)
+}
+
+// GetAccessOverrideExpiresAt returns the time the user's access override expires, or
+// the zero time if they do not have one.
+func (j *JWTClaims) GetAccessOverrideExpiresAt() time.Time {
+	if j.AccessOverrideExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(j.AccessOverrideExpiresAt, 0)
+}
+
 // VDIUser represents a user in kVDI. It is the auth providers responsibility
 // to take an authentication request and generate a JWT with claims defining
 // this object.
@@ -100,6 +142,9 @@ type VDIUser struct {
 	Roles []*VDIUserRole `json:"roles"`
 	// MFA status for the user
 	MFA *UserMFAStatus `json:"mfa"`
+	// Attributes of the user populated by the auth provider, such as their department.
+	// These are matched against the conditions of rules.
+	Attributes map[string]string `json:"attributes,omitempty"`
 	// Any active sessions for the user - new field that is only populated on a
 	// /api/whoami request.
 	Sessions []*DesktopSession `json:"sessions,omitempty"`
@@ -124,11 +169,65 @@ type VDIUserRole struct {
 	Name string `json:"name"`
 	// The rules for this role.
 	Rules []rbacv1.Rule `json:"rules"`
+	// The device policy for this role.
+	Devices *rbacv1.DevicePolicy `json:"devices,omitempty"`
+	// The access hours for this role.
+	AccessHours *rbacv1.AccessHours `json:"accessHours,omitempty"`
+	// The session quotas for this role.
+	Quotas *rbacv1.SessionQuotas `json:"quotas,omitempty"`
+	// The display bandwidth weight for this role.
+	BandwidthWeight int32 `json:"bandwidthWeight,omitempty"`
 }
 
 // GetName returns the name of the role
 func (r *VDIUserRole) GetName() string { return r.Name }
 
+// SessionDevicePolicy is the effective device policy for a user after combining
+// the policies of all of their roles.
+type SessionDevicePolicy struct {
+	// Whether desktop audio may be played back on the client.
+	AudioOut bool `json:"audioOut"`
+	// Whether the client's microphone may be passed through to the desktop.
+	AudioIn bool `json:"audioIn"`
+}
+
+// UserDevice represents a device or browser a user has signed in from.
+type UserDevice struct {
+	// An ID for the device, derived from the fingerprint presented by the client.
+	ID string `json:"id"`
+	// A name for the device, as reported by the client or taken from its user agent.
+	Name string `json:"name"`
+	// The address the device last signed in from.
+	ClientAddr string `json:"clientAddr,omitempty"`
+	// When the user first signed in from the device.
+	FirstSeen time.Time `json:"firstSeen"`
+	// When a token was last issued to the device.
+	LastSeen time.Time `json:"lastSeen"`
+	// When the last token issued to the device expires. The device counts towards the
+	// user's device limit until then, or until the user signs out on it.
+	ActiveUntil time.Time `json:"activeUntil"`
+	// Whether the device is currently signed in.
+	Active bool `json:"active"`
+	// Whether this is the device making the request.
+	Current bool `json:"current,omitempty"`
+}
+
+// DeletedObject represents a user or role that was deleted and can still be restored.
+type DeletedObject struct {
+	// The type of the object, either `users` or `roles`.
+	Kind rbacv1.Resource `json:"kind"`
+	// The name of the object.
+	Name string `json:"name"`
+	// The roles bound to a deleted user. They are bound again when the user is restored.
+	Roles []string `json:"roles,omitempty"`
+	// The user who deleted the object.
+	DeletedBy string `json:"deletedBy,omitempty"`
+	// When the object was deleted.
+	DeletedAt time.Time `json:"deletedAt"`
+	// When the object will be purged permanently, after which it can no longer be restored.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
 // APIAction represents an API action to evaluate against a user's roles.
 type APIAction struct {
 	// The verb type of the action
@@ -139,6 +238,10 @@ type APIAction struct {
 	ResourceName string `json:"resourceName"`
 	// The namespace of the targeted resource
 	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+	// The teams the targeted resource belongs to, including all of their ancestors
+	ResourceTeams []string `json:"resourceTeams,omitempty"`
+	// The labels of the namespace of the targeted resource
+	ResourceNamespaceLabels map[string]string `json:"resourceNamespaceLabels,omitempty"`
 }
 
 // ResourceNameString returns a user friendly resource name string
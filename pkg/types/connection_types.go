@@ -0,0 +1,76 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+import "time"
+
+// ConnectionStats are live statistics for a websocket connection to a desktop session, as
+// seen by the app instance serving it. Rates are averaged over the last few seconds.
+type ConnectionStats struct {
+	// A unique ID for the connection.
+	ID string `json:"id"`
+	// The type of the connection, one of `display`, `audio`, `ssh`, or `clipboard`.
+	Type string `json:"type"`
+	// The user that opened the connection.
+	User string `json:"user"`
+	// The address of the client.
+	ClientAddr string `json:"clientAddr"`
+	// When the connection was opened.
+	ConnectedAt time.Time `json:"connectedAt"`
+	// A smoothed estimate of the round trip time to the client in milliseconds, measured
+	// with websocket pings. Zero until the first pong is received.
+	RTTMillis float64 `json:"rttMillis"`
+	// The total bytes sent to the client.
+	BytesSent int64 `json:"bytesSent"`
+	// The total bytes received from the client.
+	BytesReceived int64 `json:"bytesReceived"`
+	// The current rate of bytes sent to the client per second.
+	SendBytesPerSecond float64 `json:"sendBytesPerSecond"`
+	// The current rate of bytes received from the client per second.
+	ReceiveBytesPerSecond float64 `json:"receiveBytesPerSecond"`
+	// The number of messages (e.g. display updates) sent to the client.
+	FramesSent int64 `json:"framesSent"`
+	// The number of messages that could not be delivered to the client.
+	DroppedFrames int64 `json:"droppedFrames"`
+	// The number of messages that took longer than 100ms to hand to the network. Desktop
+	// streams cannot skip frames, so a client or network that cannot keep up shows up here.
+	SlowFrames int64 `json:"slowFrames"`
+	// The weight of the connection in the display bandwidth queue of the app instance,
+	// from the roles of the user. Only set for display connections.
+	BandwidthWeight int `json:"bandwidthWeight,omitempty"`
+	// The fraction of the display bandwidth of the app instance used by the connection
+	// since the last sample. Only set for display connections.
+	BandwidthShare float64 `json:"bandwidthShare,omitempty"`
+	// Statistics from the TCP socket to the client, when available. When the app is behind
+	// a load balancer or ingress, these describe the connection to it instead.
+	TCP *TCPStats `json:"tcp,omitempty"`
+}
+
+// TCPStats are statistics reported by the kernel for a TCP socket.
+type TCPStats struct {
+	// The smoothed round trip time in milliseconds.
+	RTTMillis float64 `json:"rttMillis"`
+	// The variance of the round trip time in milliseconds.
+	RTTVarMillis float64 `json:"rttVarMillis"`
+	// The total number of segments retransmitted over the life of the connection.
+	Retransmits uint32 `json:"retransmits"`
+	// The number of segments currently considered lost.
+	Lost uint32 `json:"lost"`
+}
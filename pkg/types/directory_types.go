@@ -0,0 +1,116 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// DefaultDirectorySearchLimit is the number of results returned by a directory search
+	// when no limit is given.
+	DefaultDirectorySearchLimit = 25
+	// MaxDirectorySearchLimit is the largest number of results a directory search may
+	// return.
+	MaxDirectorySearchLimit = 100
+)
+
+// DirectoryEntryKind is the kind of principal found in a user directory.
+type DirectoryEntryKind string
+
+const (
+	// DirectoryEntryUser is a user in the directory.
+	DirectoryEntryUser DirectoryEntryKind = "user"
+	// DirectoryEntryGroup is a group in the directory.
+	DirectoryEntryGroup DirectoryEntryKind = "group"
+)
+
+// DirectorySearchRequest requests a search of the user directory backing the
+// authentication provider.
+type DirectorySearchRequest struct {
+	// The text to search for in the names of users and groups
+	Query string `json:"query"`
+	// Restrict the search to `user` or `group` entries. Both are searched by default.
+	Kind DirectoryEntryKind `json:"kind,omitempty"`
+	// The maximum number of results to return
+	Limit int `json:"limit,omitempty"`
+}
+
+// Validate the DirectorySearchRequest
+func (r *DirectorySearchRequest) Validate() error {
+	if strings.TrimSpace(r.Query) == "" {
+		return errors.New("A query is required to search the directory")
+	}
+	switch r.Kind {
+	case "", DirectoryEntryUser, DirectoryEntryGroup:
+	default:
+		return errors.New("The kind must be one of 'user' or 'group'")
+	}
+	if r.Limit < 0 {
+		return errors.New("The limit cannot be negative")
+	}
+	return nil
+}
+
+// GetLimit returns the maximum number of results to return for this request.
+func (r *DirectorySearchRequest) GetLimit() int {
+	if r.Limit == 0 {
+		return DefaultDirectorySearchLimit
+	}
+	if r.Limit > MaxDirectorySearchLimit {
+		return MaxDirectorySearchLimit
+	}
+	return r.Limit
+}
+
+// IncludesUsers returns true if users should be searched.
+func (r *DirectorySearchRequest) IncludesUsers() bool {
+	return r.Kind == "" || r.Kind == DirectoryEntryUser
+}
+
+// IncludesGroups returns true if groups should be searched.
+func (r *DirectorySearchRequest) IncludesGroups() bool {
+	return r.Kind == "" || r.Kind == DirectoryEntryGroup
+}
+
+// Matches returns true if the given value contains the query, ignoring case.
+func (r *DirectorySearchRequest) Matches(val string) bool {
+	return strings.Contains(strings.ToLower(val), strings.ToLower(strings.TrimSpace(r.Query)))
+}
+
+// DirectoryEntry is a user or group found in a user directory.
+type DirectoryEntry struct {
+	// The kind of the entry
+	Kind DirectoryEntryKind `json:"kind"`
+	// The name to refer to the entry by in kVDI. For users this is the username, and for
+	// groups the value used in role group annotations.
+	Name string `json:"name"`
+	// A human friendly name for the entry, if the directory has one
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// DirectorySearchResponse contains the results of a directory search.
+type DirectorySearchResponse struct {
+	// The users and groups matching the query
+	Results []*DirectoryEntry `json:"results"`
+	// True if more entries matched than the limit allowed
+	Truncated bool `json:"truncated"`
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+// DisconnectReason is a machine-readable reason sent to clients in the close frame when the
+// app ends a websocket connection to a desktop session. The reason is sent as the text of
+// the close frame, along with a close code from the range reserved for applications.
+type DisconnectReason string
+
+// Disconnect reasons
+const (
+	// The client sent no input for longer than the display idle timeout.
+	DisconnectIdleTimeout DisconnectReason = "idle-timeout"
+	// The desktop session was deleted.
+	DisconnectAdminTerminated DisconnectReason = "admin-terminated"
+	// The pod for the desktop session was evicted or removed.
+	DisconnectPodEvicted DisconnectReason = "pod-evicted"
+	// The user's tokens were revoked or their session reached its maximum age.
+	DisconnectAuthExpired DisconnectReason = "auth-expired"
+	// The node running the desktop session stopped responding.
+	DisconnectNodeLost DisconnectReason = "node-lost"
+	// The client tried to resume a display connection that is no longer held open, or
+	// missed more output than was kept for it. It should start a new connection.
+	DisconnectResumeExpired DisconnectReason = "resume-expired"
+)
+
+var disconnectCloseCodes = map[DisconnectReason]int{
+	DisconnectIdleTimeout:     4000,
+	DisconnectAdminTerminated: 4001,
+	DisconnectPodEvicted:      4002,
+	DisconnectAuthExpired:     4003,
+	DisconnectNodeLost:        4004,
+	DisconnectResumeExpired:   4005,
+}
+
+// CloseCode returns the websocket close code sent with this reason.
+func (d DisconnectReason) CloseCode() int {
+	if code, ok := disconnectCloseCodes[d]; ok {
+		return code
+	}
+	return 1000
+}
+
+// ShouldReconnect returns true if clients may reconnect automatically after disconnecting
+// for this reason. This is only the case when the session is expected to come back on its
+// own, or is still running and only the connection to it was lost.
+func (d DisconnectReason) ShouldReconnect() bool {
+	return d == DisconnectPodEvicted || d == DisconnectNodeLost || d == DisconnectResumeExpired
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+import "errors"
+
+// Gateway represents an endpoint clients may connect to desktops through.
+type Gateway struct {
+	// The name of the gateway. The gateway serving the request is named `default`.
+	Name string `json:"name"`
+	// The external URL of the gateway. Empty for the default gateway, meaning the same
+	// origin the API was reached at.
+	URL string `json:"url"`
+	// The zone or region the gateway is in.
+	Zone string `json:"zone,omitempty"`
+	// The path under the URL clients should request to measure their latency to the
+	// gateway.
+	ProbePath string `json:"probePath"`
+}
+
+// GatewaySelection is the gateway display connections should be routed through for a
+// user.
+type GatewaySelection struct {
+	// The name of the selected gateway
+	Gateway string `json:"gateway"`
+	// The URL of the selected gateway. Empty for the default gateway.
+	URL string `json:"url"`
+	// The latency last reported by the user's client to the gateway in milliseconds, if any.
+	LatencyMillis float64 `json:"latencyMillis,omitempty"`
+}
+
+// GatewaysResponse lists the gateways available to clients along with the one currently
+// selected for the user.
+type GatewaysResponse struct {
+	// The available gateways, including the default one
+	Gateways []*Gateway `json:"gateways"`
+	// The gateway currently selected for the user
+	Selected *GatewaySelection `json:"selected"`
+}
+
+// GatewayLatencyReport contains the latencies a client measured to the available gateways.
+type GatewayLatencyReport struct {
+	// The measured latencies in milliseconds, keyed by the name of the gateway.
+	Latencies map[string]float64 `json:"latencies"`
+}
+
+// Validate the GatewayLatencyReport
+func (g *GatewayLatencyReport) Validate() error {
+	if len(g.Latencies) == 0 {
+		return errors.New("At least one latency must be reported")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package types
+
+import "time"
+
+// Recording represents a recording of the display of a desktop session kept in object
+// storage.
+type Recording struct {
+	// A unique ID for the recording.
+	ID string `json:"id"`
+	// The namespace of the recorded desktop session.
+	Namespace string `json:"namespace"`
+	// The name of the recorded desktop session.
+	Session string `json:"session"`
+	// The template the desktop session was booted from.
+	Template string `json:"template"`
+	// The user that owns the desktop session.
+	User string `json:"user"`
+	// When the recording started.
+	StartedAt time.Time `json:"startedAt"`
+	// When the recording ended. Unset while the recording is in progress.
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+	// The number of frames captured so far.
+	Frames int `json:"frames"`
+	// The number of segments the recording has been written in so far.
+	Segments int `json:"segments"`
+	// The compressed size of the segments written so far, in bytes.
+	Size int64 `json:"size"`
+}
+
+// RecordingEventType represents the type of an event in a session recording.
+type RecordingEventType string
+
+// Recording event types
+const (
+	// A frame of the display was captured.
+	RecordingEventFrame RecordingEventType = "frame"
+	// A client connected to the display.
+	RecordingEventConnect RecordingEventType = "connect"
+	// A client disconnected from the display.
+	RecordingEventDisconnect RecordingEventType = "disconnect"
+	// A client sent input to the display since the previous frame. The contents of the
+	// input, such as keystrokes, are not recorded.
+	RecordingEventInput RecordingEventType = "input"
+)
+
+// RecordingEvent is a single event in a session recording. Playback streams the events of
+// a recording in order as JSON lines.
+type RecordingEvent struct {
+	// The time of the event, in milliseconds since the start of the recording.
+	Offset int64 `json:"offset"`
+	// The type of the event.
+	Type RecordingEventType `json:"type"`
+	// The user the event is for. Not set for frames.
+	User string `json:"user,omitempty"`
+	// The address of the client the event is for. Not set for frames.
+	ClientAddr string `json:"clientAddr,omitempty"`
+	// The captured PNG image for frames, base64 encoded.
+	Image []byte `json:"image,omitempty"`
+}
+
+// RecordingsResponse is the response to a request for session recordings.
+type RecordingsResponse struct {
+	// The recordings, most recent first.
+	Recordings []*Recording `json:"recordings"`
+}
@@ -33,16 +33,30 @@ import (
 // GenerateJWT will create a new JWT with the given user object's fields
 // embedded in the claims.
 func GenerateJWT(secret []byte, authResult *types.AuthResult, authorized bool, sessionLength time.Duration) (types.JWTClaims, string, error) {
+	now := time.Now()
+	sessionStart := authResult.SessionStart
+	if sessionStart.IsZero() {
+		sessionStart = now
+	}
 	claims := types.JWTClaims{
-		User:       authResult.User,
-		Data:       authResult.Data,
-		Authorized: authorized,
-		Renewable:  !authResult.RefreshNotSupported,
+		User:          authResult.User,
+		Data:          authResult.Data,
+		Authorized:    authorized,
+		Renewable:     !authResult.RefreshNotSupported,
+		SessionStart:  sessionStart.Unix(),
+		TrustedDevice: authResult.TrustedDevice,
+		IssuedAtNanos: now.UnixNano(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(sessionLength).Unix(),
-			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: now.Add(sessionLength).Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
+	if authResult.Device != nil {
+		claims.Device = authResult.Device.ID
+	}
+	if !authResult.AccessOverrideExpiresAt.IsZero() {
+		claims.AccessOverrideExpiresAt = authResult.AccessOverrideExpiresAt.Unix()
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(secret)
 	return claims, tokenString, err
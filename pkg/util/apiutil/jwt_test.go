@@ -48,6 +48,21 @@ func TestGenerateJWT(t *testing.T) {
 	}
 }
 
+func TestGenerateJWTSessionStart(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	_, token, err := GenerateJWT(secret, &types.AuthResult{
+		User:         &types.VDIUser{Name: "test-user"},
+		SessionStart: start,
+	}, true, time.Duration(30)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := mustDecodeAndVerifyJWT(t, token)
+	if claims.GetSessionStart().Unix() != start.Unix() {
+		t.Error("Expected session start to be carried into claims, got:", claims.GetSessionStart())
+	}
+}
+
 func mustGenerateJWT(t *testing.T, authorized bool, duration time.Duration) string {
 	t.Helper()
 	_, token, err := GenerateJWT(secret, &types.AuthResult{
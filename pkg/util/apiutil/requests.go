@@ -20,7 +20,10 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package apiutil
 
 import (
+	stdcontext "context"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -55,6 +58,21 @@ func GetRequestObject(r *http.Request) interface{} {
 	return context.Get(r, ContextRequestObjectKey)
 }
 
+type connContextKey struct{}
+
+// ConnContext stores the client connection in the context of the requests made over it. It
+// is meant to be used as the ConnContext of the server serving the API.
+func ConnContext(ctx stdcontext.Context, c net.Conn) stdcontext.Context {
+	return stdcontext.WithValue(ctx, connContextKey{}, c)
+}
+
+// GetRequestConn retrieves the client connection the request was made over, or nil if it was
+// not stored by the server.
+func GetRequestConn(r *http.Request) net.Conn {
+	conn, _ := r.Context().Value(connContextKey{}).(net.Conn)
+	return conn
+}
+
 func getRequestVar(r *http.Request, name string) string {
 	vars := mux.Vars(r)
 	return vars[name]
@@ -86,6 +104,17 @@ func GetNamespacedNameFromRequest(r *http.Request) ktypes.NamespacedName {
 	}
 }
 
+// GetClientAddr returns the host portion of the remote address of the given request.
+// Addresses populated from forwarding headers by the ProxyHeaders handler wrapping the
+// router do not include a port, and are returned as is.
+func GetClientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // GetUserFromRequest will retrieve the user variable from a request path.
 func GetUserFromRequest(r *http.Request) string {
 	vars := mux.Vars(r)
@@ -104,6 +133,73 @@ func GetTemplateFromRequest(r *http.Request) string {
 	return vars["template"]
 }
 
+// GetAccessRequestFromRequest will retrieve the access request ID variable from a request path.
+func GetAccessRequestFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["request"]
+}
+
+// GetPortFromRequest will retrieve the port variable from a request path.
+func GetPortFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["port"]
+}
+
+// GetIndexFromRequest will retrieve the template index variable from a request path.
+func GetIndexFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["index"]
+}
+
+// GetKeyFromRequest will retrieve the metadata key variable from a request path.
+func GetKeyFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["key"]
+}
+
+// GetDeviceFromRequest will retrieve the device variable from a request path.
+func GetDeviceFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["device"]
+}
+
+// GetHostFromRequest will retrieve the host variable from a request path.
+func GetHostFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["host"]
+}
+
+// GetRecordingFromRequest will retrieve the recording variable from a request path.
+func GetRecordingFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["recording"]
+}
+
+// GetMonitorFromRequest will retrieve the monitor variable from a request path.
+func GetMonitorFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["monitor"]
+}
+
+// GetPluginFromRequest will retrieve the plugin variable from a request path.
+func GetPluginFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["plugin"]
+}
+
+// GetIfMatch will retrieve the version of the object the client last read from the If-Match
+// header of a request. An empty string is returned when the header is not set or matches any
+// version.
+func GetIfMatch(r *http.Request) string {
+	version := strings.TrimSpace(r.Header.Get("If-Match"))
+	version = strings.TrimPrefix(version, "W/")
+	version = strings.Trim(version, "\"")
+	if version == "*" {
+		return ""
+	}
+	return version
+}
+
 // GetGorillaPath will retrieve the URL path as it was configured in mux.
 func GetGorillaPath(r *http.Request) string {
 	rt := mux.CurrentRoute(r)
@@ -20,6 +20,7 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package apiutil
 
 import (
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -73,6 +74,54 @@ func TestRequestObject(t *testing.T) {
 	}
 }
 
+func TestRequestConn(t *testing.T) {
+	req := mustNewRequest(t, "/test")
+	if conn := GetRequestConn(req); conn != nil {
+		t.Error("Expected no connection in the request context, got:", conn)
+	}
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	req = req.WithContext(ConnContext(req.Context(), conn))
+	if GetRequestConn(req) != conn {
+		t.Error("Expected same conn to be set and retrieved from request")
+	}
+}
+
+func TestGetIfMatch(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":         "",
+		"*":        "",
+		`"12345"`:  "12345",
+		`W/"1234"`: "1234",
+		"54321":    "54321",
+	} {
+		req := mustNewRequest(t, "/")
+		if header != "" {
+			req.Header.Set("If-Match", header)
+		}
+		if got := GetIfMatch(req); got != expected {
+			t.Errorf("Expected %q for If-Match %q, got: %q", expected, header, got)
+		}
+	}
+}
+
+func TestGetClientAddr(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"10.0.0.1:54321":      "10.0.0.1",
+		"10.0.0.1":            "10.0.0.1",
+		"[2001:db8::1]:54321": "2001:db8::1",
+		"2001:db8::1":         "2001:db8::1",
+	} {
+		req := mustNewRequest(t, "/")
+		req.RemoteAddr = addr
+		if got := GetClientAddr(req); got != expected {
+			t.Errorf("Expected %q for remote address %q, got: %q", expected, addr, got)
+		}
+	}
+}
+
 func TestGorillaHelpers(t *testing.T) {
 	// Tests are executed inside router methods. Values expected configured below
 
@@ -88,6 +88,36 @@ func ReturnAPIUnauthorized(err error, msg string, w http.ResponseWriter) {
 	WriteOrLogError(errors.ToAPIError(fmt.Errorf("Unauthorized: %s", msg), errors.Unauthorized).JSON(), w, http.StatusUnauthorized)
 }
 
+// ReturnAPIQuotaExceeded returns a TooManyRequests status with a json encoded error message
+// describing the quota that was exceeded.
+func ReturnAPIQuotaExceeded(err error, w http.ResponseWriter) {
+	WriteOrLogError(errors.ToAPIError(err, errors.QuotaExceeded).JSON(), w, http.StatusTooManyRequests)
+}
+
+// ReturnAPIConflict returns a Conflict status with a json encoded error message and the
+// current state of the object the request conflicted with.
+func ReturnAPIConflict(err error, current interface{}, w http.ResponseWriter) {
+	apiErr := errors.ToAPIError(err, errors.Conflict)
+	if current != nil {
+		out, merr := json.Marshal(current)
+		if merr != nil {
+			ReturnAPIError(merr, w)
+			return
+		}
+		apiErr.Current = out
+	}
+	WriteOrLogError(apiErr.JSON(), w, http.StatusConflict)
+}
+
+// SetETag sets the ETag header of the response to the given version of the object being
+// returned. It must be called before the response is written.
+func SetETag(w http.ResponseWriter, version string) {
+	if version == "" {
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("%q", version))
+}
+
 // WriteJSON encodes the provided interface to JSON and writes it to the response
 // stream.
 func WriteJSON(i interface{}, w http.ResponseWriter) {
@@ -68,6 +68,10 @@ func TestAPIErrors(t *testing.T) {
 			Func:     ReturnAPINotFound,
 			Expected: http.StatusNotFound,
 		},
+		{
+			Func:     ReturnAPIQuotaExceeded,
+			Expected: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, test := range tests {
@@ -106,6 +110,22 @@ func TestAPIErrors(t *testing.T) {
 		t.Error("Expected forbidden response, got:", res.StatusCode)
 	}
 
+	rr = httptest.NewRecorder()
+	ReturnAPIConflict(errors.New("fake error"), map[string]string{"name": "test"}, rr)
+	if res := rr.Result(); res.StatusCode != http.StatusConflict {
+		t.Error("Expected conflict response, got:", res.StatusCode)
+	} else if body, err := readResponseBody(res); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(body), `"current"`) {
+		t.Error("Expected the current object in the conflict response, got:", string(body))
+	}
+
+	rr = httptest.NewRecorder()
+	SetETag(rr, "12345")
+	if etag := rr.Result().Header.Get("ETag"); etag != `"12345"` {
+		t.Error("Expected a quoted ETag header, got:", etag)
+	}
+
 }
 
 func TestWriteJSON(t *testing.T) {
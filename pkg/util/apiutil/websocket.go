@@ -21,6 +21,7 @@ package apiutil
 
 import (
 	"bufio"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -107,44 +108,68 @@ func (w *WebsocketWatcher) prometheusLabels() prometheus.Labels {
 }
 
 // GorillaReadWriter implements a wrapper around gorilla websocket connections. It implements a
-// ReadWriter and is used by the kvdi API for copying display/audio connections.
+// ReadWriter and is used by the kvdi API for copying display/audio connections. Reads are
+// streamed directly out of the current websocket frame without intermediate buffering.
 type GorillaReadWriter struct {
 	*websocket.Conn
-	buffered []byte
+	reader      io.Reader
+	textHandler func([]byte)
 }
 
+// maxTextMessageSize is the largest text message passed to a text handler. Larger messages
+// are discarded.
+const maxTextMessageSize = 4096
+
 // NewGorillaReadWriter returns a new gorilla websocket readwriter.
 func NewGorillaReadWriter(conn *websocket.Conn) *GorillaReadWriter {
 	return &GorillaReadWriter{Conn: conn}
 }
 
+// WithTextHandler sets a function to receive text messages from the client instead of
+// them being read with the rest of the stream. It is used for control messages sent
+// alongside a binary stream.
+func (w *GorillaReadWriter) WithTextHandler(f func([]byte)) *GorillaReadWriter {
+	w.textHandler = f
+	return w
+}
+
 // Read implements a Reader.
 func (w *GorillaReadWriter) Read(b []byte) (int, error) {
-	var pos int
-	if len(w.buffered) > 0 {
-		pos = copy(b, w.buffered)
-		if len(w.buffered) > pos {
-			w.buffered = w.buffered[pos:]
-			return pos, nil
+	for {
+		if w.reader == nil {
+			msgType, rdr, err := w.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			if msgType == websocket.TextMessage && w.textHandler != nil {
+				w.handleText(rdr)
+				continue
+			}
+			w.reader = rdr
 		}
-		w.buffered = nil
-	}
-	_, rdr, err := w.NextReader()
-	if err != nil {
-		return 0, err
-	}
-	body, err := ioutil.ReadAll(rdr)
-	if err != nil {
-		return 0, err
+		size, err := w.reader.Read(b)
+		if err == io.EOF {
+			// the current message is exhausted, move on to the next one
+			w.reader = nil
+			if size == 0 {
+				continue
+			}
+			return size, nil
+		}
+		return size, err
 	}
-	size := copy(b[pos:], body)
-	if len(body) > size {
-		w.buffered = body[size:]
+}
+
+// handleText reads a text message and passes it to the text handler.
+func (w *GorillaReadWriter) handleText(rdr io.Reader) {
+	msg, err := ioutil.ReadAll(io.LimitReader(rdr, maxTextMessageSize+1))
+	if err != nil || len(msg) > maxTextMessageSize {
+		return
 	}
-	return size, err
+	w.textHandler(msg)
 }
 
-// Write implements a Writer.
+// Write implements a Writer. Each call is sent as a single binary message.
 func (w *GorillaReadWriter) Write(b []byte) (int, error) {
 	writer, err := w.NextWriter(websocket.BinaryMessage)
 	if err != nil {
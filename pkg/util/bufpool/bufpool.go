@@ -0,0 +1,272 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bufpool
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// BufferSize is the size of the buffers handed out by the pool.
+const BufferSize = 32 * 1024
+
+// maxPendingReads is the number of reads a copy may buffer ahead of its writes. Reads
+// that are pending when the destination is ready are flushed together.
+const maxPendingReads = 4
+
+// Prometheus gatherers
+
+var (
+	// poolGetsTotal tracks the number of buffers requested from the pool
+	poolGetsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "buffer_pool_gets_total",
+		Help:      "Total number of buffers requested from the stream buffer pool.",
+	})
+
+	// poolAllocationsTotal tracks the number of buffers that had to be newly allocated
+	poolAllocationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "buffer_pool_allocations_total",
+		Help:      "Total number of buffers allocated because the stream buffer pool was empty.",
+	})
+
+	// poolBytesInUse tracks the bytes currently checked out of the pool
+	poolBytesInUse = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "buffer_pool_bytes_in_use",
+		Help:      "The number of bytes currently checked out of the stream buffer pool.",
+	})
+
+	// copyRoutines tracks the number of running stream copies
+	copyRoutines = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "stream_copy_goroutines",
+		Help:      "The current number of goroutines copying proxied streams.",
+	})
+
+	// batchedWritesTotal tracks the number of writes that flushed more than one read
+	batchedWritesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "stream_batched_writes_total",
+		Help:      "Total number of stream writes that flushed more than one read from the source at once.",
+	})
+
+	// connectionBufferBytes tracks pooled buffer memory held by each connection
+	connectionBufferBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "stream_connection_buffer_bytes",
+		Help:      "The number of pooled buffer bytes currently held by streams for each desktop.",
+	}, []string{"desktop"})
+)
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		poolAllocationsTotal.Inc()
+		buf := make([]byte, BufferSize)
+		return &buf
+	},
+}
+
+// connection buffer counts by name, used to clean up metric labels once a
+// connection releases all of its buffers.
+var (
+	connBuffers   = make(map[string]int)
+	connBuffersMu sync.Mutex
+)
+
+// Get retrieves a buffer from the pool. It should be returned with Put when
+// no longer in use.
+func Get() *[]byte {
+	poolGetsTotal.Inc()
+	poolBytesInUse.Add(BufferSize)
+	return pool.Get().(*[]byte)
+}
+
+// Put returns a buffer to the pool.
+func Put(buf *[]byte) {
+	poolBytesInUse.Sub(BufferSize)
+	pool.Put(buf)
+}
+
+// Copy copies from src to dst until either EOF is reached on src or an error occurs.
+// Reads are made ahead of writes into buffers from the pool, and reads that pile up while
+// dst is busy are flushed together, with a single writev(2) when dst is a socket, or a
+// single larger write otherwise. This keeps the number of syscalls, TLS records, and
+// websocket frames per byte down when a stream is busy, without delaying data when it is not.
+//
+// If a write fails, Copy returns without waiting for a read in progress on src. The read
+// returns once the caller closes src.
+func Copy(dst io.Writer, src io.Reader) (written int64, err error) {
+	copyRoutines.Inc()
+	defer copyRoutines.Dec()
+
+	reads := make(chan chunk, maxPendingReads)
+	done := make(chan struct{})
+	defer close(done)
+
+	var readErr error
+	go func() {
+		defer close(reads)
+		for {
+			buf := Get()
+			n, err := src.Read(*buf)
+			if n > 0 {
+				select {
+				case reads <- chunk{buf: buf, n: n}:
+				case <-done:
+					Put(buf)
+					return
+				}
+			} else {
+				Put(buf)
+			}
+			if err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+
+	batch := make([]chunk, 0, maxPendingReads)
+	for c := range reads {
+		batch = append(batch[:0], c)
+	Drain:
+		for len(batch) < maxPendingReads {
+			select {
+			case c, ok := <-reads:
+				if !ok {
+					break Drain
+				}
+				batch = append(batch, c)
+			default:
+				break Drain
+			}
+		}
+		n, werr := writeBatch(dst, batch)
+		written += n
+		if werr != nil {
+			// release anything the reader queues before it notices we are done
+			go func() {
+				for c := range reads {
+					Put(c.buf)
+				}
+			}()
+			return written, werr
+		}
+	}
+	// the reads channel is closed by the reader after it sets readErr
+	if readErr != io.EOF {
+		err = readErr
+	}
+	return written, err
+}
+
+// chunk is a pooled buffer holding n bytes read from a source.
+type chunk struct {
+	buf *[]byte
+	n   int
+}
+
+// writeBatch writes the given chunks to dst and returns their buffers to the pool.
+func writeBatch(dst io.Writer, batch []chunk) (int64, error) {
+	defer func() {
+		for _, c := range batch {
+			Put(c.buf)
+		}
+	}()
+	if len(batch) == 1 {
+		return writeFull(dst, (*batch[0].buf)[:batch[0].n])
+	}
+	batchedWritesTotal.Inc()
+	if canWritev(dst) {
+		bufs := make(net.Buffers, len(batch))
+		for i, c := range batch {
+			bufs[i] = (*c.buf)[:c.n]
+		}
+		return bufs.WriteTo(dst)
+	}
+	// pack the reads into as few buffers as possible and write each once
+	var written int64
+	head := batch[0]
+	for _, c := range batch[1:] {
+		if head.n+c.n <= BufferSize {
+			head.n += copy((*head.buf)[head.n:], (*c.buf)[:c.n])
+			continue
+		}
+		n, err := writeFull(dst, (*head.buf)[:head.n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		head = c
+	}
+	n, err := writeFull(dst, (*head.buf)[:head.n])
+	return written + n, err
+}
+
+// writeFull writes p to dst, returning io.ErrShortWrite if dst accepts less than all of it.
+func writeFull(dst io.Writer, p []byte) (int64, error) {
+	n, err := dst.Write(p)
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	return int64(n), err
+}
+
+// CopyFor is like Copy, but attributes the buffer to the named connection in metrics.
+func CopyFor(name string, dst io.Writer, src io.Reader) (int64, error) {
+	acquireConnBuffer(name)
+	defer releaseConnBuffer(name)
+	return Copy(dst, src)
+}
+
+func acquireConnBuffer(name string) {
+	connBuffersMu.Lock()
+	defer connBuffersMu.Unlock()
+	connBuffers[name]++
+	connectionBufferBytes.WithLabelValues(name).Add(BufferSize)
+}
+
+func releaseConnBuffer(name string) {
+	connBuffersMu.Lock()
+	defer connBuffersMu.Unlock()
+	connBuffers[name]--
+	if connBuffers[name] <= 0 {
+		delete(connBuffers, name)
+		connectionBufferBytes.DeleteLabelValues(name)
+		return
+	}
+	connectionBufferBytes.WithLabelValues(name).Sub(BufferSize)
+}
+
+// canWritev returns true if writes of multiple buffers to dst are made with a single
+// writev(2).
+func canWritev(dst io.Writer) bool {
+	switch dst.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetPut(t *testing.T) {
+	buf := Get()
+	if len(*buf) != BufferSize {
+		t.Errorf("Expected buffer of size %d, got %d", BufferSize, len(*buf))
+	}
+	Put(buf)
+}
+
+func TestCopy(t *testing.T) {
+	src := strings.Repeat("kvdi", BufferSize)
+	var dst bytes.Buffer
+	n, err := Copy(&dst, strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(src)) || dst.String() != src {
+		t.Errorf("Expected %d bytes copied, got %d", len(src), n)
+	}
+}
+
+func TestCopyFor(t *testing.T) {
+	var dst bytes.Buffer
+	if _, err := CopyFor("default/test", &dst, strings.NewReader("test")); err != nil {
+		t.Fatal(err)
+	}
+	if dst.String() != "test" {
+		t.Error("Expected 'test' to be copied, got:", dst.String())
+	}
+	connBuffersMu.Lock()
+	defer connBuffersMu.Unlock()
+	if _, ok := connBuffers["default/test"]; ok {
+		t.Error("Expected connection buffer tracking to be cleaned up")
+	}
+}
+
+func TestCanWritev(t *testing.T) {
+	var dst bytes.Buffer
+	if canWritev(&dst) {
+		t.Error("Expected buffers to not support writev")
+	}
+	if !canWritev(&net.TCPConn{}) || !canWritev(&net.UnixConn{}) {
+		t.Error("Expected sockets to support writev")
+	}
+}
+
+// gatedWriter blocks its first write until released, so reads pile up behind it.
+type gatedWriter struct {
+	bytes.Buffer
+	gate   chan struct{}
+	writes int
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	if g.writes == 0 {
+		<-g.gate
+	}
+	g.writes++
+	return g.Buffer.Write(p)
+}
+
+// chunkedReader returns at most size bytes per read.
+type chunkedReader struct {
+	r     io.Reader
+	size  int
+	reads int32
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(p) > c.size {
+		p = p[:c.size]
+	}
+	atomic.AddInt32(&c.reads, 1)
+	return c.r.Read(p)
+}
+
+func TestCopyBatchesPendingReads(t *testing.T) {
+	src := strings.Repeat("kvdi", BufferSize)
+	reader := &chunkedReader{r: strings.NewReader(src), size: 1024}
+	dst := &gatedWriter{gate: make(chan struct{})}
+	go func() {
+		// let the reader fill the queue before the first write completes
+		for atomic.LoadInt32(&reader.reads) <= maxPendingReads {
+			time.Sleep(time.Millisecond)
+		}
+		close(dst.gate)
+	}()
+	n, err := Copy(dst, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(src)) || dst.String() != src {
+		t.Fatalf("Expected %d bytes copied in order, got %d", len(src), n)
+	}
+	if dst.writes >= int(atomic.LoadInt32(&reader.reads)) {
+		t.Errorf("Expected reads to be batched, got %d writes for %d reads", dst.writes, reader.reads)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestCopyWriteError(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go pw.Write([]byte("test"))
+	errs := make(chan error, 1)
+	go func() {
+		_, err := Copy(failingWriter{}, pr)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err != io.ErrClosedPipe {
+			t.Error("Expected the write error, got:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected copy to return on a write error while the source is still open")
+	}
+}
+
+func TestCopyShortWrite(t *testing.T) {
+	if _, err := writeFull(shortWriter{}, []byte("test")); err != io.ErrShortWrite {
+		t.Error("Expected a short write error, got:", err)
+	}
+}
+
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) { return len(p) - 1, nil }
+
+// benchmarkSocketCopy measures copying a stream of small writes, like those of a TLS
+// connection or a display server, between two loopback TCP connections.
+func benchmarkSocketCopy(b *testing.B, copyFunc func(io.Writer, io.Reader) (int64, error)) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+	dial := func() (net.Conn, net.Conn) {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		server, err := l.Accept()
+		if err != nil {
+			b.Fatal(err)
+		}
+		return client, server
+	}
+	srcWriter, src := dial()
+	dst, dstReader := dial()
+	defer srcWriter.Close()
+	defer dst.Close()
+	defer dstReader.Close()
+
+	const writeSize = 16 * 1024
+	const writes = 256
+	payload := make([]byte, writeSize)
+	b.SetBytes(writeSize * writes)
+	b.ResetTimer()
+
+	go func() {
+		for i := 0; i < b.N*writes; i++ {
+			if _, err := srcWriter.Write(payload); err != nil {
+				return
+			}
+		}
+		srcWriter.Close()
+	}()
+	go func() {
+		copyFunc(dst, src)
+		dst.Close()
+	}()
+	if _, err := io.Copy(ioutil.Discard, dstReader); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkCopy(b *testing.B) {
+	benchmarkSocketCopy(b, Copy)
+}
+
+func BenchmarkCopyUnbatched(b *testing.B) {
+	benchmarkSocketCopy(b, func(dst io.Writer, src io.Reader) (int64, error) {
+		buf := Get()
+		defer Put(buf)
+		return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+	})
+}
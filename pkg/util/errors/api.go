@@ -30,10 +30,12 @@ type ErrorStatus string
 
 // Error types
 const (
-	Unauthorized ErrorStatus = "Unauthorized"
-	Forbidden    ErrorStatus = "Forbidden"
-	NotFound     ErrorStatus = "NotFound"
-	ServerError  ErrorStatus = "ServerError"
+	Unauthorized  ErrorStatus = "Unauthorized"
+	Forbidden     ErrorStatus = "Forbidden"
+	NotFound      ErrorStatus = "NotFound"
+	ServerError   ErrorStatus = "ServerError"
+	QuotaExceeded ErrorStatus = "QuotaExceeded"
+	Conflict      ErrorStatus = "Conflict"
 )
 
 // APIError is for errors from the API server. It's main purpose
@@ -44,6 +46,8 @@ type APIError struct {
 	ErrMsg string `json:"error"`
 	// The status for the error.
 	ErrStatus ErrorStatus `json:"status"`
+	// The current state of the object a Conflict error was returned for.
+	Current json.RawMessage `json:"current,omitempty"`
 }
 
 // CheckAPIError evaluates if the HTTP response contains an API error.
@@ -79,6 +83,15 @@ func ToAPIError(err error, errStatus ErrorStatus) *APIError {
 	}
 }
 
+// DecodeCurrent decodes the current state of the object a Conflict error was returned for
+// into the given interface.
+func (r *APIError) DecodeCurrent(out interface{}) error {
+	if len(r.Current) == 0 {
+		return New("The error does not contain the current state of the object")
+	}
+	return json.Unmarshal(r.Current, out)
+}
+
 // JSON returns the json encoded error. Error checking is skipped since
 // this is only used internally and for valid strings.
 func (r *APIError) JSON() []byte {
@@ -125,3 +138,23 @@ func IsAPIServerError(err error) bool {
 	}
 	return false
 }
+
+// IsAPIQuotaExceeded checks if the given error from the API is a QuotaExceeded error.
+func IsAPIQuotaExceeded(err error) bool {
+	if apiErr, ok := err.(*APIError); ok {
+		if apiErr.ErrStatus == QuotaExceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIConflict checks if the given error from the API is a Conflict error.
+func IsAPIConflict(err error) bool {
+	if apiErr, ok := err.(*APIError); ok {
+		if apiErr.ErrStatus == Conflict {
+			return true
+		}
+	}
+	return false
+}
@@ -39,3 +39,26 @@ func TestAPIError(t *testing.T) {
 		t.Error("Error changed during marshaling")
 	}
 }
+
+func TestAPIConflict(t *testing.T) {
+	apiErr := ToAPIError(NewConflictError("role", "admins"), Conflict)
+	apiErr.Current = json.RawMessage(`{"name": "admins"}`)
+
+	var decoded APIError
+	if err := json.Unmarshal(apiErr.JSON(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIConflict(&decoded) {
+		t.Error("Expected a conflict error after unmarshaling")
+	}
+	current := make(map[string]string)
+	if err := decoded.DecodeCurrent(&current); err != nil {
+		t.Fatal(err)
+	} else if current["name"] != "admins" {
+		t.Error("Expected the current object to survive marshaling, got:", current)
+	}
+
+	if err := ToAPIError(errors.New("fake error"), ServerError).DecodeCurrent(&current); err == nil {
+		t.Error("Expected an error decoding the current object of a non-conflict error")
+	}
+}
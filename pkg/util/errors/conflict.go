@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import "fmt"
+
+// The error message format for a ConflictError
+const conflictFormat = "The %s '%s' has been modified since it was read, reload it and try again"
+
+// ConflictError is used to signal that an update was made against a version of a user, role,
+// or template that is no longer current.
+type ConflictError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *ConflictError) Error() string {
+	return r.errMsg
+}
+
+// NewConflictError returns a new ConflictError for the given kind of object and name.
+func NewConflictError(kind, name string) error {
+	return &ConflictError{
+		errMsg: fmt.Sprintf(conflictFormat, kind, name),
+	}
+}
+
+// IsConflictError returns true if the given error is a ConflictError.
+func IsConflictError(err error) bool {
+	if _, ok := err.(*ConflictError); ok {
+		return true
+	}
+	return false
+}
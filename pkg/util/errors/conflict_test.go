@@ -0,0 +1,42 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestConflictError(t *testing.T) {
+	rerr := NewConflictError("role", "admins")
+
+	if rerr.Error() != fmt.Sprintf(conflictFormat, "role", "admins") {
+		t.Error("Error body is malformed")
+	}
+
+	if ok := IsConflictError(rerr); !ok {
+		t.Error("Should be a valid conflict error")
+	}
+
+	if ok := IsConflictError(errors.New("fake error")); ok {
+		t.Error("IsConflictError returned valid for invalid error")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import "fmt"
+
+// The error message format for a DeletedObjectNotFoundError
+const deletedObjectNotFoundFormat = "No deleted %s named '%s' can be restored"
+
+// DeletedObjectNotFoundError is used to signal that the requested user or role was not
+// deleted, or was already purged.
+type DeletedObjectNotFoundError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *DeletedObjectNotFoundError) Error() string {
+	return r.errMsg
+}
+
+// NewDeletedObjectNotFoundError returns a new DeletedObjectNotFoundError for the given kind
+// of object and name.
+func NewDeletedObjectNotFoundError(kind, name string) error {
+	return &DeletedObjectNotFoundError{
+		errMsg: fmt.Sprintf(deletedObjectNotFoundFormat, kind, name),
+	}
+}
+
+// IsDeletedObjectNotFoundError returns true if the given error is a DeletedObjectNotFoundError.
+func IsDeletedObjectNotFoundError(err error) bool {
+	if _, ok := err.(*DeletedObjectNotFoundError); ok {
+		return true
+	}
+	return false
+}
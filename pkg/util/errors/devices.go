@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import "fmt"
+
+// The error message format for a DeviceNotFoundError
+const deviceNotFoundFormat = "Device '%s' could not be found for user '%s'"
+
+// DeviceNotFoundError is used to signal that the requested device has not been seen for
+// the user.
+type DeviceNotFoundError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *DeviceNotFoundError) Error() string {
+	return r.errMsg
+}
+
+// NewDeviceNotFoundError returns a new DeviceNotFoundError for the given user and device.
+func NewDeviceNotFoundError(user, device string) error {
+	return &DeviceNotFoundError{
+		errMsg: fmt.Sprintf(deviceNotFoundFormat, device, user),
+	}
+}
+
+// IsDeviceNotFoundError returns true if the given error is a DeviceNotFoundError.
+func IsDeviceNotFoundError(err error) bool {
+	if _, ok := err.(*DeviceNotFoundError); ok {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import "fmt"
+
+// The error message format for a MetadataNotFoundError
+const metadataNotFoundFormat = "Metadata '%s' could not be found for user '%s'"
+
+// MetadataNotFoundError is used to signal that the requested user metadata does not
+// exist.
+type MetadataNotFoundError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *MetadataNotFoundError) Error() string {
+	return r.errMsg
+}
+
+// NewMetadataNotFoundError returns a new MetadataNotFoundError for the given user and
+// metadata path.
+func NewMetadataNotFoundError(user, path string) error {
+	return &MetadataNotFoundError{
+		errMsg: fmt.Sprintf(metadataNotFoundFormat, path, user),
+	}
+}
+
+// IsMetadataNotFoundError returns true if the given error is a MetadataNotFoundError.
+func IsMetadataNotFoundError(err error) bool {
+	if _, ok := err.(*MetadataNotFoundError); ok {
+		return true
+	}
+	return false
+}
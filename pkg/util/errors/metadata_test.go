@@ -0,0 +1,42 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMetadataNotFoundError(t *testing.T) {
+	qerr := NewMetadataNotFoundError("admin", "ui/theme")
+
+	if qerr.Error() != fmt.Sprintf(metadataNotFoundFormat, "ui/theme", "admin") {
+		t.Error("Error body is malformed")
+	}
+
+	if ok := IsMetadataNotFoundError(qerr); !ok {
+		t.Error("Should be a valid metadata not found error")
+	}
+
+	if ok := IsMetadataNotFoundError(errors.New("fake error")); ok {
+		t.Error("IsMetadataNotFoundError returned valid for invalid error")
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+// QuotaExceededError is used to signal that a request would exceed one of the session
+// quotas applied to the user.
+type QuotaExceededError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *QuotaExceededError) Error() string {
+	return r.errMsg
+}
+
+// NewQuotaExceededError returns a new QuotaExceededError with the given message describing
+// the quota that was exceeded.
+func NewQuotaExceededError(msg string) error {
+	return &QuotaExceededError{errMsg: msg}
+}
+
+// IsQuotaExceededError returns true if the given error is a QuotaExceededError.
+func IsQuotaExceededError(err error) bool {
+	if _, ok := err.(*QuotaExceededError); ok {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuotaExceededError(t *testing.T) {
+	qerr := NewQuotaExceededError("admin has reached the maximum allowed (2) sessions")
+
+	if qerr.Error() != "admin has reached the maximum allowed (2) sessions" {
+		t.Error("Error body is malformed")
+	}
+
+	if ok := IsQuotaExceededError(qerr); !ok {
+		t.Error("Should be a valid quota exceeded error")
+	}
+
+	if ok := IsQuotaExceededError(errors.New("fake error")); ok {
+		t.Error("IsQuotaExceededError returned valid for invalid error")
+	}
+
+	if ok := IsAPIQuotaExceeded(ToAPIError(qerr, QuotaExceeded)); !ok {
+		t.Error("Should be a valid API quota exceeded error")
+	}
+}
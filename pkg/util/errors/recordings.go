@@ -0,0 +1,51 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package errors
+
+import "fmt"
+
+// The error message format for a RecordingNotFoundError
+const recordingNotFoundFormat = "Recording '%s' could not be found"
+
+// RecordingNotFoundError is used to signal that the requested session recording does not
+// exist in object storage.
+type RecordingNotFoundError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *RecordingNotFoundError) Error() string {
+	return r.errMsg
+}
+
+// NewRecordingNotFoundError returns a new RecordingNotFoundError for the given recording.
+func NewRecordingNotFoundError(id string) error {
+	return &RecordingNotFoundError{
+		errMsg: fmt.Sprintf(recordingNotFoundFormat, id),
+	}
+}
+
+// IsRecordingNotFoundError returns true if the given error is a RecordingNotFoundError.
+func IsRecordingNotFoundError(err error) bool {
+	if _, ok := err.(*RecordingNotFoundError); ok {
+		return true
+	}
+	return false
+}
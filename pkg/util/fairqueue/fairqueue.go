@@ -0,0 +1,198 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package fairqueue
+
+import (
+	"container/heap"
+	"io"
+	"sync"
+	"time"
+)
+
+// Queue paces writes from its flows to a shared rate. It is safe for concurrent use.
+type Queue struct {
+	mu          sync.Mutex
+	rate        float64
+	virtual     float64
+	next        time.Time
+	seq         uint64
+	sent        int64
+	pending     requestHeap
+	dispatching bool
+	now         func() time.Time
+	sleep       func(time.Duration)
+}
+
+// New returns a new queue limited to the given rate in bytes per second. A rate of zero
+// or less means there is no limit and writes are never delayed.
+func New(rate int64) *Queue {
+	return &Queue{rate: float64(rate), now: time.Now, sleep: time.Sleep}
+}
+
+// SetRate changes the rate of the queue in bytes per second. A rate of zero or less
+// removes the limit.
+func (q *Queue) SetRate(rate int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rate = float64(rate)
+}
+
+// Sent returns the total number of bytes written by all flows of the queue.
+func (q *Queue) Sent() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.sent
+}
+
+// NewFlow returns a new flow on the queue with the given weight. Weights below one are
+// raised to one.
+func (q *Queue) NewFlow(weight int) *Flow {
+	if weight < 1 {
+		weight = 1
+	}
+	return &Flow{q: q, weight: weight}
+}
+
+// Flow is a single stream of writes through a queue.
+type Flow struct {
+	q      *Queue
+	weight int
+	finish float64
+	sent   int64
+}
+
+// Weight returns the weight of the flow.
+func (f *Flow) Weight() int { return f.weight }
+
+// Sent returns the number of bytes written by the flow.
+func (f *Flow) Sent() int64 {
+	f.q.mu.Lock()
+	defer f.q.mu.Unlock()
+	return f.sent
+}
+
+// Wait blocks until the flow may write n bytes.
+func (f *Flow) Wait(n int) {
+	req := f.q.enqueue(f, n)
+	if req == nil {
+		return
+	}
+	<-req.ready
+}
+
+// Writer returns a writer that waits for the flow's turn before each write to w.
+func (f *Flow) Writer(w io.Writer) io.Writer {
+	return &flowWriter{Writer: w, flow: f}
+}
+
+type flowWriter struct {
+	io.Writer
+	flow *Flow
+}
+
+// Write implements a Writer.
+func (w *flowWriter) Write(b []byte) (int, error) {
+	w.flow.Wait(len(b))
+	return w.Writer.Write(b)
+}
+
+// enqueue records a write of n bytes for the flow and, when the queue is limited, queues
+// it behind the writes of other flows with earlier start tags. Nil is returned if the
+// write does not need to wait.
+func (q *Queue) enqueue(f *Flow, n int) *request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f.sent += int64(n)
+	q.sent += int64(n)
+	if q.rate <= 0 {
+		return nil
+	}
+	start := q.virtual
+	if f.finish > start {
+		start = f.finish
+	}
+	f.finish = start + float64(n)/float64(f.weight)
+	q.seq++
+	req := &request{start: start, seq: q.seq, size: n, ready: make(chan struct{})}
+	heap.Push(&q.pending, req)
+	if !q.dispatching {
+		q.dispatching = true
+		go q.dispatch()
+	}
+	return req
+}
+
+// dispatch releases queued writes in order of their start tags, each once the link has
+// finished sending the write before it, until the queue is empty.
+func (q *Queue) dispatch() {
+	for {
+		q.mu.Lock()
+		if q.pending.Len() == 0 {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		now := q.now()
+		if q.rate > 0 && q.next.After(now) {
+			wait := q.next.Sub(now)
+			q.mu.Unlock()
+			q.sleep(wait)
+			continue
+		}
+		req := heap.Pop(&q.pending).(*request)
+		q.virtual = req.start
+		if q.rate > 0 {
+			q.next = now.Add(time.Duration(float64(req.size) / q.rate * float64(time.Second)))
+		}
+		q.mu.Unlock()
+		close(req.ready)
+	}
+}
+
+type request struct {
+	start float64
+	seq   uint64
+	size  int
+	ready chan struct{}
+}
+
+// requestHeap orders requests by start tag, and by arrival for equal tags.
+type requestHeap []*request
+
+func (h requestHeap) Len() int { return len(h) }
+
+func (h requestHeap) Less(i, j int) bool {
+	if h[i].start == h[j].start {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].start < h[j].start
+}
+
+func (h requestHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *requestHeap) Push(x interface{}) { *h = append(*h, x.(*request)) }
+
+func (h *requestHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
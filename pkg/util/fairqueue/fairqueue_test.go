@@ -0,0 +1,86 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package fairqueue
+
+import (
+	"container/heap"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairOrder(t *testing.T) {
+	q := New(1000)
+	// hold off the dispatcher so the order of the pending writes can be inspected
+	q.dispatching = true
+	light, heavy := q.NewFlow(1), q.NewFlow(3)
+	for i := 0; i < 4; i++ {
+		q.enqueue(light, 100)
+		q.enqueue(heavy, 100)
+	}
+	var heavyCount int
+	for i := 0; i < 6; i++ {
+		req := heap.Pop(&q.pending).(*request)
+		if req.seq%2 == 0 {
+			heavyCount++
+		}
+	}
+	if heavyCount != 4 {
+		t.Error("Expected the heavier flow to get 4 of the first 6 writes, got:", heavyCount)
+	}
+	if light.Sent() != 400 || heavy.Sent() != 400 || q.Sent() != 800 {
+		t.Error("Expected sent bytes to be counted, got:", light.Sent(), heavy.Sent(), q.Sent())
+	}
+	if f := q.NewFlow(0); f.Weight() != 1 {
+		t.Error("Expected weights below one to be raised to one, got:", f.Weight())
+	}
+}
+
+func TestPacing(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	var slept time.Duration
+	q := New(1000)
+	q.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return now }
+	q.sleep = func(d time.Duration) { mu.Lock(); defer mu.Unlock(); now = now.Add(d); slept += d }
+
+	f := q.NewFlow(1)
+	for i := 0; i < 5; i++ {
+		f.Wait(100)
+	}
+	mu.Lock()
+	// the first write goes out immediately, the rest each wait 100ms for the link
+	if slept != 400*time.Millisecond {
+		t.Error("Expected writes to be paced to the rate, slept:", slept)
+	}
+	mu.Unlock()
+
+	q.SetRate(0)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		f.Wait(1 << 20)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected writes not to wait without a rate")
+	}
+	if f.Sent() != 500+5<<20 {
+		t.Error("Expected all writes to be counted, got:", f.Sent())
+	}
+}
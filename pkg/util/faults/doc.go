@@ -0,0 +1,26 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package faults implements a fault injection layer for testing the resilience of
+// kVDI components. Faults such as delays, dropped display frames, and failed secrets
+// reads can be injected at runtime through the API.
+//
+// Faults are only ever injected by binaries built with the `faults` build tag. In
+// regular builds every injection point is a no-op and faults can't be configured.
+package faults
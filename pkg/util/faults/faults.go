@@ -0,0 +1,209 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// ErrDisabled is returned when configuring faults in a binary built without fault
+// injection.
+var ErrDisabled = errors.New("Fault injection is not enabled in this build")
+
+// validPoints are the points faults can be injected at.
+var validPoints = []types.FaultPoint{
+	types.FaultPointSecretsRead,
+	types.FaultPointDisplayFrames,
+	types.FaultPointAudioFrames,
+}
+
+// Set replaces the injected faults with the given ones.
+func Set(faults []*types.Fault) error { return active.set(faults) }
+
+// Status returns the currently injected faults and their counters.
+func Status() *types.FaultInjectionStatus { return active.status() }
+
+// Clear removes all injected faults.
+func Clear() { active.clear() }
+
+// Inject is called when the given point is reached. It sleeps for any delays injected
+// at the point and returns an error if a fault fails it.
+func Inject(point types.FaultPoint) error {
+	if active.hit(point) {
+		return fmt.Errorf("Injected fault at %s", point)
+	}
+	return nil
+}
+
+// Writer wraps the given writer so that writes to it are delayed or dropped by faults
+// injected at the given point. Each write is treated as a single frame. The writer is
+// returned as is when fault injection is disabled.
+func Writer(point types.FaultPoint, w io.Writer) io.Writer {
+	if active == nil {
+		return w
+	}
+	return &frameWriter{point: point, w: w}
+}
+
+type frameWriter struct {
+	point types.FaultPoint
+	w     io.Writer
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	if active.hit(f.point) {
+		// report the frame as written so the copy carries on without it
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// injectedFault is a fault with its parsed delay.
+type injectedFault struct {
+	*types.InjectedFault
+	delay time.Duration
+}
+
+// injector tracks the faults injected into the running binary. All methods are safe to
+// call on a nil injector, which never injects anything.
+type injector struct {
+	mux    sync.Mutex
+	faults []*injectedFault
+}
+
+func newInjector() *injector { return &injector{faults: make([]*injectedFault, 0)} }
+
+func (i *injector) set(faults []*types.Fault) error {
+	if i == nil {
+		return ErrDisabled
+	}
+	injected := make([]*injectedFault, len(faults))
+	for idx, fault := range faults {
+		f, err := newInjectedFault(fault)
+		if err != nil {
+			return err
+		}
+		injected[idx] = f
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.faults = injected
+	return nil
+}
+
+func (i *injector) status() *types.FaultInjectionStatus {
+	status := &types.FaultInjectionStatus{Faults: make([]*types.InjectedFault, 0)}
+	if i == nil {
+		return status
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	for _, f := range i.faults {
+		fault := *f.InjectedFault
+		status.Faults = append(status.Faults, &fault)
+	}
+	return status
+}
+
+func (i *injector) clear() {
+	if i == nil {
+		return
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.faults = make([]*injectedFault, 0)
+}
+
+// hit records that the given point was reached, sleeping for any delays injected at
+// it. It returns true if the point should fail.
+func (i *injector) hit(point types.FaultPoint) (fail bool) {
+	if i == nil {
+		return false
+	}
+	delay, fail := i.record(point)
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return fail
+}
+
+// record updates the counters of the faults at the given point and returns the total
+// delay to add and whether the point should fail. Faults that reached their limit are
+// removed.
+func (i *injector) record(point types.FaultPoint) (delay time.Duration, fail bool) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	remaining := make([]*injectedFault, 0, len(i.faults))
+	for _, f := range i.faults {
+		if f.Point != point {
+			remaining = append(remaining, f)
+			continue
+		}
+		f.Hits++
+		delay += f.delay
+		if f.FailEvery > 0 && f.Hits%f.FailEvery == 0 {
+			f.Failures++
+			fail = true
+		}
+		if f.Limit > 0 && f.Failures >= f.Limit {
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+	i.faults = remaining
+	return delay, fail
+}
+
+// newInjectedFault validates the given fault and returns it ready for injection.
+func newInjectedFault(fault *types.Fault) (*injectedFault, error) {
+	if fault == nil {
+		return nil, errors.New("Faults cannot be null")
+	}
+	if !isValidPoint(fault.Point) {
+		return nil, fmt.Errorf("%q is not a valid fault injection point, must be one of %v", fault.Point, validPoints)
+	}
+	if fault.FailEvery < 0 || fault.Limit < 0 {
+		return nil, fmt.Errorf("The failEvery and limit of faults at %s cannot be negative", fault.Point)
+	}
+	f := &injectedFault{InjectedFault: &types.InjectedFault{Fault: *fault}}
+	if fault.Delay != "" {
+		delay, err := time.ParseDuration(fault.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid delay for faults at %s: %s", fault.Point, err.Error())
+		}
+		f.delay = delay
+	}
+	return f, nil
+}
+
+func isValidPoint(point types.FaultPoint) bool {
+	for _, valid := range validPoints {
+		if point == valid {
+			return true
+		}
+	}
+	return false
+}
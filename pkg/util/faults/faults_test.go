@@ -0,0 +1,122 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package faults
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestInjectorSet(t *testing.T) {
+	var disabled *injector
+	if err := disabled.set(nil); err != ErrDisabled {
+		t.Error("Expected disabled error from nil injector, got:", err)
+	}
+	if disabled.hit(types.FaultPointSecretsRead) {
+		t.Error("Expected nil injector to never fail")
+	}
+	if len(disabled.status().Faults) != 0 {
+		t.Error("Expected no faults from nil injector")
+	}
+
+	i := newInjector()
+	for _, bad := range []*types.Fault{
+		nil,
+		{Point: "not-a-point"},
+		{Point: types.FaultPointSecretsRead, Delay: "soon"},
+		{Point: types.FaultPointSecretsRead, FailEvery: -1},
+	} {
+		if err := i.set([]*types.Fault{bad}); err == nil {
+			t.Errorf("Expected error setting invalid fault %+v", bad)
+		}
+	}
+	if err := i.set([]*types.Fault{{Point: types.FaultPointSecretsRead, Delay: "10ms", FailEvery: 1}}); err != nil {
+		t.Fatal("Expected no error setting valid fault, got:", err)
+	}
+	if len(i.status().Faults) != 1 {
+		t.Error("Expected one injected fault, got:", i.status().Faults)
+	}
+	i.clear()
+	if len(i.status().Faults) != 0 {
+		t.Error("Expected no faults after clear, got:", i.status().Faults)
+	}
+}
+
+func TestInjectorHit(t *testing.T) {
+	i := newInjector()
+	if err := i.set([]*types.Fault{
+		{Point: types.FaultPointDisplayFrames, FailEvery: 2, Limit: 2},
+		{Point: types.FaultPointSecretsRead, Delay: "20ms"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []bool{false, true, false, true, false, false}
+	for idx, shouldFail := range expected {
+		if failed := i.hit(types.FaultPointDisplayFrames); failed != shouldFail {
+			t.Errorf("Expected hit %d to fail=%v, got %v", idx+1, shouldFail, failed)
+		}
+	}
+
+	// the display fault should be removed after reaching its limit
+	status := i.status()
+	if len(status.Faults) != 1 || status.Faults[0].Point != types.FaultPointSecretsRead {
+		t.Fatal("Expected only the secrets fault to remain, got:", status.Faults)
+	}
+
+	start := time.Now()
+	if i.hit(types.FaultPointSecretsRead) {
+		t.Error("Expected delay-only fault to not fail")
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Expected hit to be delayed")
+	}
+	if hits := i.status().Faults[0].Hits; hits != 1 {
+		t.Error("Expected one recorded hit, got:", hits)
+	}
+}
+
+func TestFrameWriter(t *testing.T) {
+	orig := active
+	defer func() { active = orig }()
+
+	var buf bytes.Buffer
+	active = nil
+	if w := Writer(types.FaultPointDisplayFrames, &buf); w != &buf {
+		t.Error("Expected writer to be returned as is when disabled")
+	}
+
+	active = newInjector()
+	if err := Set([]*types.Fault{{Point: types.FaultPointDisplayFrames, FailEvery: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	w := Writer(types.FaultPointDisplayFrames, &buf)
+	for _, frame := range []string{"a", "b", "c", "d"} {
+		if n, err := w.Write([]byte(frame)); err != nil || n != 1 {
+			t.Fatal("Expected frame to be reported as written, got:", n, err)
+		}
+	}
+	if buf.String() != "ac" {
+		t.Error("Expected every second frame to be dropped, got:", buf.String())
+	}
+}
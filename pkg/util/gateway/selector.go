@@ -0,0 +1,128 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// Default is the name of the gateway serving the API itself. It is selected for users
+// that have not reported any latencies.
+const Default = "default"
+
+// Selection is the gateway selected for a user.
+type Selection struct {
+	// The name of the gateway
+	Gateway string
+	// The latency last reported to the gateway in milliseconds, if any
+	LatencyMillis float64
+}
+
+type report struct {
+	latencies  map[string]float64
+	selected   string
+	reportedAt time.Time
+}
+
+// Selector tracks the latencies reported by the clients of each user and selects the
+// gateway with the lowest latency for them. It is safe for concurrent use.
+type Selector struct {
+	mu        sync.Mutex
+	maxAge    time.Duration
+	threshold float64
+	reports   map[string]*report
+	now       func() time.Time
+}
+
+// NewSelector returns a new selector. Reports older than maxAge are discarded. To avoid
+// flapping between gateways with similar latencies, a user is only moved off of their
+// current gateway when another one is faster by more than the threshold, as a fraction
+// of the current latency (e.g. 0.2 for 20%).
+func NewSelector(maxAge time.Duration, threshold float64) *Selector {
+	return &Selector{
+		maxAge:    maxAge,
+		threshold: threshold,
+		reports:   make(map[string]*report),
+		now:       time.Now,
+	}
+}
+
+// Report records the latencies in milliseconds the user's client measured to the named
+// gateways and returns the gateway selected for them. Latencies to gateways that are not
+// in known, and negative latencies, are ignored.
+func (s *Selector) Report(user string, known []string, latencies map[string]float64) Selection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+
+	rep := &report{latencies: make(map[string]float64), reportedAt: s.now()}
+	for _, name := range known {
+		if latency, ok := latencies[name]; ok && latency >= 0 {
+			rep.latencies[name] = latency
+		}
+	}
+	if len(rep.latencies) == 0 {
+		delete(s.reports, user)
+		return Selection{Gateway: Default}
+	}
+
+	for name, latency := range rep.latencies {
+		if rep.selected == "" || latency < rep.latencies[rep.selected] ||
+			(latency == rep.latencies[rep.selected] && name < rep.selected) {
+			rep.selected = name
+		}
+	}
+	if prev, ok := s.reports[user]; ok {
+		if current, ok := rep.latencies[prev.selected]; ok && rep.latencies[rep.selected] >= current*(1-s.threshold) {
+			rep.selected = prev.selected
+		}
+	}
+	s.reports[user] = rep
+	return Selection{Gateway: rep.selected, LatencyMillis: rep.latencies[rep.selected]}
+}
+
+// Selected returns the gateway currently selected for the user. The default gateway is
+// returned when the user has no recent report, or their selected gateway is no longer
+// in known.
+func (s *Selector) Selected(user string, known []string) Selection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep, ok := s.reports[user]
+	if !ok || s.now().Sub(rep.reportedAt) > s.maxAge {
+		return Selection{Gateway: Default}
+	}
+	for _, name := range known {
+		if name == rep.selected {
+			return Selection{Gateway: rep.selected, LatencyMillis: rep.latencies[rep.selected]}
+		}
+	}
+	return Selection{Gateway: Default}
+}
+
+// expireLocked drops reports older than the max age. The lock must be held.
+func (s *Selector) expireLocked() {
+	now := s.now()
+	for user, rep := range s.reports {
+		if now.Sub(rep.reportedAt) > s.maxAge {
+			delete(s.reports, user)
+		}
+	}
+}
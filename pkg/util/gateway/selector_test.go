@@ -0,0 +1,71 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelector(t *testing.T) {
+	now := time.Now()
+	s := NewSelector(time.Minute, 0.2)
+	s.now = func() time.Time { return now }
+	known := []string{Default, "us-east", "eu-west"}
+
+	if sel := s.Selected("alice", known); sel.Gateway != Default {
+		t.Error("Expected default gateway before any reports, got:", sel.Gateway)
+	}
+
+	sel := s.Report("alice", known, map[string]float64{Default: 120, "us-east": 40, "eu-west": 90, "unknown": 1})
+	if sel.Gateway != "us-east" || sel.LatencyMillis != 40 {
+		t.Error("Expected lowest latency known gateway to be selected, got:", sel)
+	}
+	if sel := s.Selected("alice", known); sel.Gateway != "us-east" {
+		t.Error("Expected selection to be remembered, got:", sel.Gateway)
+	}
+	if sel := s.Selected("bob", known); sel.Gateway != Default {
+		t.Error("Expected other users to be unaffected, got:", sel.Gateway)
+	}
+
+	// a gateway that is only slightly faster does not take over
+	if sel := s.Report("alice", known, map[string]float64{Default: 120, "us-east": 40, "eu-west": 35}); sel.Gateway != "us-east" {
+		t.Error("Expected to stay on the current gateway within the threshold, got:", sel.Gateway)
+	}
+	if sel := s.Report("alice", known, map[string]float64{Default: 120, "us-east": 40, "eu-west": 20}); sel.Gateway != "eu-west" {
+		t.Error("Expected to move to a gateway faster by more than the threshold, got:", sel.Gateway)
+	}
+
+	// gateways removed from the configuration are no longer selected
+	if sel := s.Selected("alice", []string{Default, "us-east"}); sel.Gateway != Default {
+		t.Error("Expected default gateway when the selected one is removed, got:", sel.Gateway)
+	}
+
+	// reports expire
+	now = now.Add(2 * time.Minute)
+	if sel := s.Selected("alice", known); sel.Gateway != Default {
+		t.Error("Expected default gateway after the report expired, got:", sel.Gateway)
+	}
+
+	// reports without any usable latencies fall back to the default
+	if sel := s.Report("alice", known, map[string]float64{"unknown": 10, "us-east": -1}); sel.Gateway != Default {
+		t.Error("Expected default gateway without usable latencies, got:", sel.Gateway)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package guac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxElementLength is the longest element accepted while reading an instruction.
+const maxElementLength = 1 << 20
+
+// Instruction is a single Guacamole protocol instruction.
+type Instruction struct {
+	Opcode string
+	Args   []string
+}
+
+// NewInstruction returns a new instruction with the given opcode and arguments.
+func NewInstruction(opcode string, args ...string) *Instruction {
+	return &Instruction{Opcode: opcode, Args: args}
+}
+
+// String returns the wire encoding of the instruction. Each element is prefixed with its
+// length in characters.
+func (i *Instruction) String() string {
+	var b strings.Builder
+	for idx, elem := range append([]string{i.Opcode}, i.Args...) {
+		if idx > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(utf8.RuneCountInString(elem)))
+		b.WriteByte('.')
+		b.WriteString(elem)
+	}
+	b.WriteByte(';')
+	return b.String()
+}
+
+// WriteInstruction writes the given instruction to w.
+func WriteInstruction(w io.Writer, ins *Instruction) error {
+	_, err := io.WriteString(w, ins.String())
+	return err
+}
+
+// ReadInstruction reads a single instruction from r. The reader is read one byte at a
+// time, so nothing past the end of the instruction is consumed.
+func ReadInstruction(r io.Reader) (*Instruction, error) {
+	elems := make([]string, 0)
+	for {
+		length, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := readRunes(r, length)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		term, err := readByte(r)
+		if err != nil {
+			return nil, err
+		}
+		switch term {
+		case ',':
+		case ';':
+			return &Instruction{Opcode: elems[0], Args: elems[1:]}, nil
+		default:
+			return nil, fmt.Errorf("Unexpected %q after instruction element", term)
+		}
+	}
+}
+
+// ConnectOptions are the parameters used when asking guacd to open a connection.
+type ConnectOptions struct {
+	// The protocol guacd should connect with (e.g. `rdp`).
+	Protocol string
+	// Values for the connection parameters requested by guacd. Parameters without a
+	// value are sent empty.
+	Params map[string]string
+	// The initial size and resolution of the display.
+	Width, Height, DPI int
+	// The mimetypes supported by the client.
+	AudioMimetypes, ImageMimetypes []string
+}
+
+// Handshake performs the client side of the handshake with guacd and returns the ID of the
+// established connection. On success the connection is ready to be handed to a Guacamole
+// client.
+func Handshake(rw io.ReadWriter, opts *ConnectOptions) (string, error) {
+	if err := WriteInstruction(rw, NewInstruction("select", opts.Protocol)); err != nil {
+		return "", err
+	}
+	argsIns, err := expect(rw, "args")
+	if err != nil {
+		return "", err
+	}
+	for _, ins := range []*Instruction{
+		NewInstruction("size", strconv.Itoa(opts.Width), strconv.Itoa(opts.Height), strconv.Itoa(opts.DPI)),
+		NewInstruction("audio", opts.AudioMimetypes...),
+		NewInstruction("video"),
+		NewInstruction("image", opts.ImageMimetypes...),
+	} {
+		if err := WriteInstruction(rw, ins); err != nil {
+			return "", err
+		}
+	}
+	values := make([]string, len(argsIns.Args))
+	for idx, name := range argsIns.Args {
+		if strings.HasPrefix(name, "VERSION_") {
+			// accept the protocol version offered by the server
+			values[idx] = name
+			continue
+		}
+		values[idx] = opts.Params[name]
+	}
+	if err := WriteInstruction(rw, NewInstruction("connect", values...)); err != nil {
+		return "", err
+	}
+	ready, err := expect(rw, "ready")
+	if err != nil {
+		return "", err
+	}
+	if len(ready.Args) == 0 {
+		return "", errors.New("guacd did not return a connection ID")
+	}
+	return ready.Args[0], nil
+}
+
+// TunnelInstruction returns the internal instruction that tells Guacamole websocket
+// clients the ID of their tunnel.
+func TunnelInstruction(id string) *Instruction {
+	return NewInstruction("", id)
+}
+
+// expect reads the next instruction and returns an error if it does not have the given
+// opcode. Error instructions from guacd are returned as errors.
+func expect(r io.Reader, opcode string) (*Instruction, error) {
+	ins, err := ReadInstruction(r)
+	if err != nil {
+		return nil, err
+	}
+	if ins.Opcode == "error" && len(ins.Args) > 0 {
+		return nil, fmt.Errorf("guacd refused the connection: %s", ins.Args[0])
+	}
+	if ins.Opcode != opcode {
+		return nil, fmt.Errorf("Expected %q from guacd, got %q", opcode, ins.Opcode)
+	}
+	return ins, nil
+}
+
+func readLength(r io.Reader) (int, error) {
+	var digits []byte
+	for {
+		b, err := readByte(r)
+		if err != nil {
+			return 0, err
+		}
+		if b == '.' {
+			break
+		}
+		if b < '0' || b > '9' || len(digits) > 8 {
+			return 0, fmt.Errorf("Invalid instruction element length")
+		}
+		digits = append(digits, b)
+	}
+	length, err := strconv.Atoi(string(digits))
+	if err != nil {
+		return 0, err
+	}
+	if length > maxElementLength {
+		return 0, fmt.Errorf("Instruction element of %d characters is too long", length)
+	}
+	return length, nil
+}
+
+// readRunes reads the given number of UTF-8 encoded characters from r.
+func readRunes(r io.Reader, count int) (string, error) {
+	var b strings.Builder
+	buf := make([]byte, 0, utf8.UTFMax)
+	for count > 0 {
+		c, err := readByte(r)
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, c)
+		if !utf8.FullRune(buf) {
+			continue
+		}
+		b.Write(buf)
+		buf = buf[:0]
+		count--
+	}
+	return b.String(), nil
+}
+
+func readByte(r io.Reader) (byte, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
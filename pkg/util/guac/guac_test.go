@@ -0,0 +1,123 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package guac
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstructionEncoding(t *testing.T) {
+	ins := NewInstruction("connect", "VERSION_1_3_0", "", "héllo")
+	encoded := ins.String()
+	if expected := "7.connect,13.VERSION_1_3_0,0.,5.héllo;"; encoded != expected {
+		t.Fatalf("Expected %q, got %q", expected, encoded)
+	}
+	decoded, err := ReadInstruction(strings.NewReader(encoded + "4.sync,1.0;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, ins) {
+		t.Errorf("Expected %+v, got %+v", ins, decoded)
+	}
+	if got := TunnelInstruction("$abc").String(); got != "0.,4.$abc;" {
+		t.Error("Unexpected tunnel instruction:", got)
+	}
+
+	for _, invalid := range []string{"4.sync,1.0", "x.sync;", "4.sync|", "4.sy"} {
+		if _, err := ReadInstruction(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected error reading %q", invalid)
+		}
+	}
+}
+
+// fakeGuacd runs the server side of a handshake, requesting the given parameters and
+// answering with the given final instruction. Read errors are ignored since the client
+// may abort the handshake.
+func fakeGuacd(t *testing.T, conn net.Conn, params []string, final *Instruction) {
+	defer conn.Close()
+	sel, err := ReadInstruction(conn)
+	if err != nil {
+		return
+	}
+	if sel.Opcode != "select" || len(sel.Args) != 1 || sel.Args[0] != "rdp" {
+		t.Errorf("Expected select of rdp, got %+v", sel)
+	}
+	WriteInstruction(conn, NewInstruction("args", params...))
+	for _, opcode := range []string{"size", "audio", "video", "image"} {
+		ins, err := ReadInstruction(conn)
+		if err != nil {
+			return
+		}
+		if ins.Opcode != opcode {
+			t.Errorf("Expected %s, got %s", opcode, ins.Opcode)
+		}
+	}
+	connect, err := ReadInstruction(conn)
+	if err != nil {
+		return
+	}
+	expected := []string{"VERSION_1_3_0", "127.0.0.1", "3389", ""}
+	if connect.Opcode != "connect" || !reflect.DeepEqual(connect.Args, expected) {
+		t.Errorf("Expected connect with %v, got %+v", expected, connect)
+	}
+	WriteInstruction(conn, final)
+	// frames following the handshake must be left for the client
+	conn.Write([]byte("4.sync,1.0;"))
+}
+
+func TestHandshake(t *testing.T) {
+	params := []string{"VERSION_1_3_0", "hostname", "port", "password"}
+	opts := &ConnectOptions{
+		Protocol: "rdp",
+		Params:   map[string]string{"hostname": "127.0.0.1", "port": "3389"},
+		Width:    1024,
+		Height:   768,
+		DPI:      96,
+	}
+
+	client, server := net.Pipe()
+	go fakeGuacd(t, server, params, NewInstruction("ready", "$connection-id"))
+	id, err := Handshake(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "$connection-id" {
+		t.Error("Expected the connection ID, got:", id)
+	}
+	next := make([]byte, 11)
+	if _, err := client.Read(next); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(next, []byte("4.sync,1.0;")) {
+		t.Errorf("Expected the next frame after the handshake, got %q", next)
+	}
+	client.Close()
+
+	client, server = net.Pipe()
+	go fakeGuacd(t, server, params, NewInstruction("error", "Authentication failure", "769"))
+	if _, err := Handshake(client, opts); err == nil || !strings.Contains(err.Error(), "Authentication failure") {
+		t.Error("Expected error from guacd, got:", err)
+	}
+	client.Close()
+}
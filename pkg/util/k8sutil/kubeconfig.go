@@ -0,0 +1,125 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package k8sutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+// RequestPodBoundToken requests a token for the service account of the given pod. The token
+// is bound to the pod and stops being accepted by the API server when the pod is deleted or
+// the expiration passes, whichever comes first.
+func RequestPodBoundToken(ctx context.Context, pod *corev1.Pod, expiration time.Duration) (*authenticationv1.TokenRequestStatus, error) {
+	if DefaultClient == nil {
+		return nil, errors.New("There is no raw client configured for requesting tokens")
+	}
+	expirationSeconds := int64(expiration.Seconds())
+	req := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+			BoundObjectRef: &authenticationv1.BoundObjectReference{
+				Kind:       "Pod",
+				APIVersion: "v1",
+				Name:       pod.GetName(),
+				UID:        pod.GetUID(),
+			},
+		},
+	}
+	res, err := DefaultClient.CoreV1().ServiceAccounts(pod.GetNamespace()).
+		CreateToken(ctx, GetPodServiceAccount(pod), req, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &res.Status, nil
+}
+
+// GetPodServiceAccount returns the name of the service account the given pod runs as.
+func GetPodServiceAccount(pod *corev1.Pod) string {
+	if pod.Spec.ServiceAccountName != "" {
+		return pod.Spec.ServiceAccountName
+	}
+	return "default"
+}
+
+// GetInClusterAPIServer returns the address and CA certificate of the API server as seen
+// from inside this pod.
+func GetInClusterAPIServer() (server string, caData []byte, err error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	caData, err = ioutil.ReadFile(config.TLSClientConfig.CAFile)
+	if err != nil {
+		return "", nil, err
+	}
+	return config.Host, caData, nil
+}
+
+// NewKubeconfig returns a kubeconfig authenticating to the given server with a bearer token.
+// The context defaults to the given namespace. The kubeconfig is built from the versioned
+// types and encoded with the standard library, since the serializer behind clientcmd.Write
+// crashes on maps with the json-iterator release this module is pinned to.
+func NewKubeconfig(server string, caData []byte, namespace, user, token string) ([]byte, error) {
+	config := &clientcmdapiv1.Config{
+		APIVersion: "v1",
+		Kind:       "Config",
+		Clusters: []clientcmdapiv1.NamedCluster{{
+			Name: "kvdi",
+			Cluster: clientcmdapiv1.Cluster{
+				Server:                   server,
+				CertificateAuthorityData: caData,
+			},
+		}},
+		AuthInfos: []clientcmdapiv1.NamedAuthInfo{{
+			Name:     user,
+			AuthInfo: clientcmdapiv1.AuthInfo{Token: token},
+		}},
+		Contexts: []clientcmdapiv1.NamedContext{{
+			Name: "kvdi",
+			Context: clientcmdapiv1.Context{
+				Cluster:   "kvdi",
+				AuthInfo:  user,
+				Namespace: namespace,
+			},
+		}},
+		CurrentContext: "kvdi",
+	}
+	out, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, re-encode it in the format kubeconfigs are usually written in
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
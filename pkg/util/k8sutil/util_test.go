@@ -26,8 +26,10 @@ import (
 	appv1 "github.com/tinyzimmer/kvdi/apis/app/v1"
 	v1 "github.com/tinyzimmer/kvdi/apis/meta/v1"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -96,3 +98,49 @@ func TestCreationSpecAnnotations(t *testing.T) {
 		t.Error("Expected equal creation specs")
 	}
 }
+
+func TestGetPodServiceAccount(t *testing.T) {
+	pod := &corev1.Pod{}
+	if sa := GetPodServiceAccount(pod); sa != "default" {
+		t.Error("Expected default service account, got:", sa)
+	}
+	pod.Spec.ServiceAccountName = "desktop"
+	if sa := GetPodServiceAccount(pod); sa != "desktop" {
+		t.Error("Expected desktop service account, got:", sa)
+	}
+}
+
+func TestNewKubeconfig(t *testing.T) {
+	raw, err := NewKubeconfig("https://10.0.0.1:443", []byte("fake-ca"), "test-namespace", "desktop", "fake-token")
+	if err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	config, err := clientcmd.Load(raw)
+	if err != nil {
+		t.Fatal("Expected kubeconfig to load, got:", err)
+	}
+	ctx, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		t.Fatal("Expected current context to exist")
+	}
+	if ctx.Namespace != "test-namespace" {
+		t.Error("Expected context namespace to be set, got:", ctx.Namespace)
+	}
+	cluster, ok := config.Clusters[ctx.Cluster]
+	if !ok {
+		t.Fatal("Expected context cluster to exist")
+	}
+	if cluster.Server != "https://10.0.0.1:443" {
+		t.Error("Expected server to be set, got:", cluster.Server)
+	}
+	if string(cluster.CertificateAuthorityData) != "fake-ca" {
+		t.Error("Expected CA data to be set, got:", string(cluster.CertificateAuthorityData))
+	}
+	user, ok := config.AuthInfos[ctx.AuthInfo]
+	if !ok {
+		t.Fatal("Expected context user to exist")
+	}
+	if user.Token != "fake-token" {
+		t.Error("Expected token to be set, got:", user.Token)
+	}
+}
@@ -25,4 +25,9 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 // temporary locks on K8s resources. Also, since the user of the lock will not
 // always dissapear, an expiration key is placed in the configMap to signal to
 // another process when it's okay to release a stale lock.
+//
+// For critical sections that may run on any replica of a deployment, LeaseLock
+// provides a similar mechanism backed by coordination.k8s.io Leases. Leases do not
+// need to be owned by a pod, and stale leases are taken over once their duration
+// has passed.
 package lock
@@ -0,0 +1,226 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrLeaseHeld is returned when a lease could not be acquired because it is held
+// by another process.
+var ErrLeaseHeld = errors.New("Lease is currently held by another process")
+
+// leaseRetryInterval is how often a blocked Acquire retries the lease.
+const leaseRetryInterval = 250 * time.Millisecond
+
+// LeaseLock implements a distributed lock backed by a coordination.k8s.io Lease.
+// Unlike Lock, it does not require the holder to know the pod it is running in,
+// which makes it usable from any replica of a deployment. Holders are identified
+// by a unique identity generated for each LeaseLock, and leases left behind by
+// crashed holders become available again once their duration has passed.
+type LeaseLock struct {
+	// the k8s client
+	client client.Client
+	// the namespace and name of the lease
+	namespace, name string
+	// the identity recorded as the holder of the lease
+	identity string
+	// how long the lease is held before it is considered stale
+	duration time.Duration
+	// labels to apply to the lease
+	labels map[string]string
+}
+
+// NewLeaseLock returns a new lock on the lease with the given name and namespace.
+// The duration is how long the lease may be held before another process is allowed
+// to take it over, and should comfortably exceed the length of the critical section.
+func NewLeaseLock(c client.Client, namespace, name string, duration time.Duration) *LeaseLock {
+	return &LeaseLock{
+		client:    c,
+		namespace: namespace,
+		name:      name,
+		identity:  newLeaseIdentity(),
+		duration:  duration,
+		labels:    map[string]string{},
+	}
+}
+
+// LeaseName returns a valid lease name for the given prefix and an arbitrary key,
+// such as a user or template name, that may not be a valid object name itself.
+func LeaseName(prefix, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%s", prefix, hex.EncodeToString(sum[:10]))
+}
+
+// WithLabels configures labels to add to the lease associated with this lock.
+func (l *LeaseLock) WithLabels(labels map[string]string) *LeaseLock {
+	l.labels = labels
+	return l
+}
+
+// GetName returns the name of this lock.
+func (l *LeaseLock) GetName() string { return l.name }
+
+// GetIdentity returns the identity this lock holds leases under.
+func (l *LeaseLock) GetIdentity() string { return l.identity }
+
+// TryAcquire makes a single attempt at acquiring the lease. ErrLeaseHeld is returned
+// if the lease is currently held by another process.
+func (l *LeaseLock) TryAcquire(ctx context.Context) error {
+	now := metav1.NowMicro()
+	lease := &coordinationv1.Lease{}
+	err := l.client.Get(ctx, types.NamespacedName{Name: l.name, Namespace: l.namespace}, lease)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+		lease = l.newLease(now)
+		if err := l.client.Create(ctx, lease); err != nil {
+			if kerrors.IsAlreadyExists(err) {
+				return ErrLeaseHeld
+			}
+			return err
+		}
+		return nil
+	}
+
+	if l.isHeldByOther(lease, now.Time) {
+		return ErrLeaseHeld
+	}
+
+	// the lease is stale or already ours, take it over. The update is rejected if
+	// another process modified the lease since we read it.
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		var transitions int32
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions
+		}
+		lease.Spec.LeaseTransitions = common.Int32Ptr(transitions + 1)
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &l.identity
+	lease.Spec.LeaseDurationSeconds = common.Int32Ptr(l.durationSeconds())
+	lease.Spec.RenewTime = &now
+	if err := l.client.Update(ctx, lease); err != nil {
+		if kerrors.IsConflict(err) || kerrors.IsNotFound(err) {
+			return ErrLeaseHeld
+		}
+		return err
+	}
+	return nil
+}
+
+// Acquire will attempt to acquire the lease, retrying until it is either acquired
+// or the context is done.
+func (l *LeaseLock) Acquire(ctx context.Context) error {
+	for {
+		err := l.TryAcquire(ctx)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrLeaseHeld) {
+			lockLogger.Error(err, "Error trying to acquire lease", "Lease.Name", l.name)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Failed to acquire lease %s: %s", l.name, ctx.Err().Error())
+		case <-time.After(leaseRetryInterval):
+		}
+	}
+}
+
+// Release will delete the lease if it is still held by this lock. Releasing a lease
+// that has since been taken over by another process is a no-op.
+func (l *LeaseLock) Release(ctx context.Context) error {
+	lease := &coordinationv1.Lease{}
+	if err := l.client.Get(ctx, types.NamespacedName{Name: l.name, Namespace: l.namespace}, lease); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		lockLogger.Info("Lease is no longer held by this process, not releasing", "Lease.Name", l.name)
+		return nil
+	}
+	return client.IgnoreNotFound(l.client.Delete(ctx, lease, client.Preconditions{ResourceVersion: &lease.ResourceVersion}))
+}
+
+// isHeldByOther returns true if the lease is held by another holder and has not
+// yet expired.
+func (l *LeaseLock) isHeldByOther(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || *lease.Spec.HolderIdentity == l.identity {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiresAt := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiresAt)
+}
+
+func (l *LeaseLock) durationSeconds() int32 {
+	secs := int32(l.duration.Seconds())
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// newLease returns a new lease held by this lock.
+func (l *LeaseLock) newLease(now metav1.MicroTime) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      l.name,
+			Namespace: l.namespace,
+			Labels:    l.labels,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &l.identity,
+			LeaseDurationSeconds: common.Int32Ptr(l.durationSeconds()),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+			LeaseTransitions:     common.Int32Ptr(0),
+		},
+	}
+}
+
+// newLeaseIdentity returns a unique identity for a lease holder, prefixed with
+// the pod name or hostname when available.
+func newLeaseIdentity() string {
+	host := os.Getenv("POD_NAME")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return fmt.Sprintf("%s_%08x", host, rand.Uint32())
+}
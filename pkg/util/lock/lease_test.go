@@ -0,0 +1,150 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	types "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeLeaseClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	coordinationv1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme)
+}
+
+func TestLeaseName(t *testing.T) {
+	name := LeaseName("launch-user", "Some.User@example.com")
+	if !strings.HasPrefix(name, "launch-user-") {
+		t.Error("Expected lease name to start with the prefix, got:", name)
+	}
+	if name != LeaseName("launch-user", "Some.User@example.com") {
+		t.Error("Expected lease names to be stable for the same key")
+	}
+	if name == LeaseName("launch-user", "other-user") {
+		t.Error("Expected different keys to produce different lease names")
+	}
+}
+
+func TestLeaseLock(t *testing.T) {
+	c := getFakeLeaseClient(t)
+	ctx := context.TODO()
+
+	l := NewLeaseLock(c, "test-namespace", "test-lease", time.Minute)
+	if err := l.TryAcquire(ctx); err != nil {
+		t.Fatal("Expected to acquire lease, got:", err)
+	}
+
+	lease := &coordinationv1.Lease{}
+	nn := types.NamespacedName{Name: "test-lease", Namespace: "test-namespace"}
+	if err := c.Get(ctx, nn, lease); err != nil {
+		t.Fatal("Expected a lease called 'test-lease' in 'test-namespace', got err:", err)
+	}
+	if *lease.Spec.HolderIdentity != l.GetIdentity() {
+		t.Error("Expected lease to be held by the lock, got:", *lease.Spec.HolderIdentity)
+	}
+
+	// re-acquiring a held lease should succeed
+	if err := l.TryAcquire(ctx); err != nil {
+		t.Error("Expected to re-acquire our own lease, got:", err)
+	}
+
+	// another holder should not be able to acquire the lease
+	other := NewLeaseLock(c, "test-namespace", "test-lease", time.Minute)
+	if err := other.TryAcquire(ctx); !errors.Is(err, ErrLeaseHeld) {
+		t.Error("Expected lease to be held, got:", err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := other.Acquire(timeoutCtx); err == nil {
+		t.Error("Expected acquire to time out on a held lease")
+	}
+
+	// releasing from a process that does not hold the lease is a no-op
+	if err := other.Release(ctx); err != nil {
+		t.Error("Expected no error releasing a lease held by another process, got:", err)
+	}
+	if err := c.Get(ctx, nn, &coordinationv1.Lease{}); err != nil {
+		t.Error("Expected lease to still exist, got:", err)
+	}
+
+	if err := l.Release(ctx); err != nil {
+		t.Error("Expected to release lease, got:", err)
+	}
+	if err := c.Get(ctx, nn, &coordinationv1.Lease{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected lease to be deleted, got:", err)
+	}
+
+	// should be safe to call on an already released lease
+	if err := l.Release(ctx); err != nil {
+		t.Error("Expected to be able to release lease again, got:", err)
+	}
+
+	if err := other.Acquire(ctx); err != nil {
+		t.Error("Expected to acquire released lease, got:", err)
+	}
+}
+
+func TestLeaseLockStale(t *testing.T) {
+	c := getFakeLeaseClient(t)
+	ctx := context.TODO()
+
+	stale := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	holder := "crashed-holder"
+	duration := int32(10)
+	c.Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-lease",
+			Namespace: "test-namespace",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &stale,
+		},
+	})
+
+	l := NewLeaseLock(c, "test-namespace", "test-lease", time.Minute)
+	if err := l.TryAcquire(ctx); err != nil {
+		t.Fatal("Expected to take over stale lease, got:", err)
+	}
+
+	lease := &coordinationv1.Lease{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "test-lease", Namespace: "test-namespace"}, lease); err != nil {
+		t.Fatal(err)
+	}
+	if *lease.Spec.HolderIdentity != l.GetIdentity() {
+		t.Error("Expected lease to be taken over by the lock, got:", *lease.Spec.HolderIdentity)
+	}
+	if lease.Spec.LeaseTransitions == nil || *lease.Spec.LeaseTransitions != 1 {
+		t.Error("Expected lease transitions to be incremented, got:", lease.Spec.LeaseTransitions)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// AccessAllowed returns true if the given roles allow access at the given time. Access
+// is allowed if any role has no access hours or has a window open at the time.
+func AccessAllowed(roles []*types.VDIUserRole, now time.Time) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if role == nil {
+			continue
+		}
+		if role.AccessHours.IsOpen(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestAccessAllowed(t *testing.T) {
+	// Monday, 10:00 UTC
+	monday := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	businessHours := &types.VDIUserRole{
+		Name: "business-hours",
+		AccessHours: &rbacv1.AccessHours{
+			Windows: []rbacv1.AccessWindow{
+				{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartTime: "08:00", EndTime: "18:00"},
+			},
+		},
+	}
+	nightShift := &types.VDIUserRole{
+		Name: "night-shift",
+		AccessHours: &rbacv1.AccessHours{
+			Windows: []rbacv1.AccessWindow{
+				{Days: []string{"Monday"}, StartTime: "22:00", EndTime: "06:00"},
+			},
+		},
+	}
+	unrestricted := &types.VDIUserRole{Name: "unrestricted"}
+
+	tc := []struct {
+		roles   []*types.VDIUserRole
+		at      time.Time
+		allowed bool
+	}{
+		{[]*types.VDIUserRole{businessHours}, monday, true},
+		{[]*types.VDIUserRole{businessHours}, monday.Add(9 * time.Hour), false},
+		{[]*types.VDIUserRole{businessHours}, monday.AddDate(0, 0, 5), false},
+		{[]*types.VDIUserRole{nightShift}, monday, false},
+		{[]*types.VDIUserRole{nightShift}, monday.Add(13 * time.Hour), true},
+		// the early hours of tuesday belong to monday's window
+		{[]*types.VDIUserRole{nightShift}, monday.Add(18 * time.Hour), true},
+		{[]*types.VDIUserRole{nightShift}, monday.AddDate(0, 0, -1).Add(18 * time.Hour), false},
+		{[]*types.VDIUserRole{businessHours, nightShift}, monday.Add(13 * time.Hour), true},
+		{[]*types.VDIUserRole{businessHours, unrestricted}, monday.Add(9 * time.Hour), true},
+		{[]*types.VDIUserRole{}, monday, true},
+	}
+
+	for _, c := range tc {
+		if allowed := AccessAllowed(c.roles, c.at); allowed != c.allowed {
+			t.Errorf("Expected access at %s to be %v, got %v", c.at.Format(time.RFC3339), c.allowed, allowed)
+		}
+	}
+}
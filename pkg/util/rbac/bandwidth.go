@@ -0,0 +1,36 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import "github.com/tinyzimmer/kvdi/pkg/types"
+
+// EffectiveBandwidthWeight returns the highest display bandwidth weight of the given
+// roles, or 1 if none of them set one.
+func EffectiveBandwidthWeight(roles []*types.VDIUserRole) int {
+	weight := 1
+	for _, role := range roles {
+		if role != nil && int(role.BandwidthWeight) > weight {
+			weight = int(role.BandwidthWeight)
+		}
+	}
+	return weight
+}
@@ -0,0 +1,43 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestEffectiveBandwidthWeight(t *testing.T) {
+	if weight := EffectiveBandwidthWeight(nil); weight != 1 {
+		t.Error("Expected default weight without roles, got:", weight)
+	}
+	roles := []*types.VDIUserRole{
+		{Name: "default"},
+		{Name: "video", BandwidthWeight: 4},
+		{Name: "low", BandwidthWeight: 2},
+		nil,
+	}
+	if weight := EffectiveBandwidthWeight(roles); weight != 4 {
+		t.Error("Expected the highest weight to apply, got:", weight)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestConditionMatches(t *testing.T) {
+	attrs := map[string]string{"department": "engineering"}
+
+	tc := []struct {
+		cond    rbacv1.Condition
+		matches bool
+	}{
+		{rbacv1.Condition{Attribute: "department", Values: []string{"engineering", "qa"}}, true},
+		{rbacv1.Condition{Attribute: "department", Values: []string{"sales"}}, false},
+		{rbacv1.Condition{Attribute: "department", Operator: rbacv1.ConditionOpNotIn, Values: []string{"sales"}}, true},
+		{rbacv1.Condition{Attribute: "department", Operator: rbacv1.ConditionOpNotIn, Values: []string{"engineering"}}, false},
+		{rbacv1.Condition{Attribute: "location", Operator: rbacv1.ConditionOpNotIn, Values: []string{"remote"}}, true},
+		{rbacv1.Condition{Attribute: "department", Operator: rbacv1.ConditionOpExists}, true},
+		{rbacv1.Condition{Attribute: "location", Operator: rbacv1.ConditionOpExists}, false},
+		{rbacv1.Condition{Attribute: "location", Operator: rbacv1.ConditionOpDoesNotExist}, true},
+		{rbacv1.Condition{Attribute: "location", Values: []string{""}}, false},
+	}
+
+	for _, c := range tc {
+		if matches := c.cond.Matches(attrs); matches != c.matches {
+			t.Errorf("Expected %+v to match %v: %v, got %v", c.cond, attrs, c.matches, matches)
+		}
+	}
+}
+
+func TestConditionalRules(t *testing.T) {
+	conditioned := func(rule rbacv1.Rule, conds ...rbacv1.Condition) rbacv1.Rule {
+		rule.Conditions = conds
+		return rule
+	}
+	engineering := rbacv1.Condition{Attribute: "department", Values: []string{"engineering"}}
+	noMFA := rbacv1.Condition{Attribute: rbacv1.AttributeMFAVerified, Operator: rbacv1.ConditionOpNotIn, Values: []string{"true"}}
+
+	user := &types.VDIUser{
+		Name:       "test",
+		Attributes: map[string]string{"department": "engineering"},
+		Roles: []*types.VDIUserRole{
+			{Name: "engineers", Rules: []rbacv1.Rule{conditioned(launchAllTemplates, engineering)}},
+		},
+	}
+	if !EvaluateUser(user, launchAction("ubuntu", "default")) {
+		t.Error("Expected launching to be allowed when the conditions are met")
+	}
+	user.Attributes["department"] = "sales"
+	if EvaluateUser(user, launchAction("ubuntu", "default")) {
+		t.Error("Expected launching to be denied when the conditions are not met")
+	}
+	if EvaluateRole(user.Roles[0], launchAction("ubuntu", "default")) {
+		t.Error("Expected conditions to not be met when evaluating a role without a user")
+	}
+
+	user.Roles = append(user.Roles, &types.VDIUserRole{Name: "mfa", Rules: []rbacv1.Rule{
+		launchAllTemplates,
+		conditioned(denyAdminTemplates, noMFA),
+	}})
+	if EvaluateUser(user, launchAction("admin-tools", "default")) {
+		t.Error("Expected a deny rule to apply when the user has not completed MFA")
+	}
+	user.Attributes[rbacv1.AttributeMFAVerified] = "true"
+	if !EvaluateUser(user, launchAction("admin-tools", "default")) {
+		t.Error("Expected a deny rule to not apply when the user has completed MFA")
+	}
+
+	getter := &fakeResourceGetter{templates: []string{"ubuntu"}}
+	if RuleIncludes(conditioned(launchAllTemplates, engineering), launchAllTemplates, getter) {
+		t.Error("Expected a conditioned rule to not include an unconditioned one")
+	}
+	if !RuleIncludes(conditioned(launchAllTemplates, engineering), conditioned(launchAllTemplates, engineering, noMFA), getter) {
+		t.Error("Expected a conditioned rule to include a rule with stricter conditions")
+	}
+	if !RuleIncludes(launchAllTemplates, conditioned(launchAllTemplates, engineering), getter) {
+		t.Error("Expected an unconditioned rule to include a conditioned one")
+	}
+}
@@ -27,10 +27,15 @@ import (
 )
 
 // VDIRoleToUserRole converts the given VDIRole to the VDIUserRole format. The VDIUserRole is
-// a condensed representation meant to be stored in JWTs.
+// a condensed representation meant to be stored in JWTs. The rules the role inherits from
+// other roles are included.
 func VDIRoleToUserRole(v *rbacv1.VDIRole) *types.VDIUserRole {
 	return &types.VDIUserRole{
-		Name:  v.GetName(),
-		Rules: v.GetRules(),
+		Name:            v.GetName(),
+		Rules:           v.GetEffectiveRules(),
+		Devices:         v.GetDevicePolicy(),
+		AccessHours:     v.GetAccessHours(),
+		Quotas:          v.GetQuotas(),
+		BandwidthWeight: v.GetBandwidthWeight(),
 	}
 }
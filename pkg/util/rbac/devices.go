@@ -0,0 +1,61 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// EffectiveDevicePolicy combines the device policies of the given roles. A device is
+// allowed if any role allows it, denied if a role denies it and none allow it, and
+// otherwise allowed.
+func EffectiveDevicePolicy(roles []*types.VDIUserRole) *types.SessionDevicePolicy {
+	policies := make([]*rbacv1.DevicePolicy, 0)
+	for _, role := range roles {
+		if role != nil && role.Devices != nil {
+			policies = append(policies, role.Devices)
+		}
+	}
+	return &types.SessionDevicePolicy{
+		AudioOut: resolveDevice(policies, func(p *rbacv1.DevicePolicy) *bool { return p.AudioOut }, true),
+		AudioIn:  resolveDevice(policies, func(p *rbacv1.DevicePolicy) *bool { return p.AudioIn }, true),
+	}
+}
+
+func resolveDevice(policies []*rbacv1.DevicePolicy, field func(*rbacv1.DevicePolicy) *bool, def bool) bool {
+	var denied bool
+	for _, policy := range policies {
+		val := field(policy)
+		if val == nil {
+			continue
+		}
+		if *val {
+			return true
+		}
+		denied = true
+	}
+	if denied {
+		return false
+	}
+	return def
+}
@@ -0,0 +1,62 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestEffectiveDevicePolicy(t *testing.T) {
+	allow, deny := true, false
+
+	// no policies returns the defaults
+	policy := EffectiveDevicePolicy([]*types.VDIUserRole{{Name: "no-devices"}})
+	if !policy.AudioOut || !policy.AudioIn {
+		t.Error("Expected default device policy, got:", policy)
+	}
+
+	roles := []*types.VDIUserRole{
+		{
+			Name: "restricted",
+			Devices: &rbacv1.DevicePolicy{
+				AudioIn:  &deny,
+				AudioOut: &deny,
+			},
+		},
+		{
+			Name: "playback",
+			Devices: &rbacv1.DevicePolicy{
+				AudioOut: &allow,
+			},
+		},
+	}
+	policy = EffectiveDevicePolicy(roles)
+	if policy.AudioIn {
+		t.Error("Expected audio input to be denied")
+	}
+	if !policy.AudioOut {
+		t.Error("Expected audio output allowed by one role to be allowed")
+	}
+}
@@ -22,15 +22,23 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package rbac
 
 import (
+	"time"
+
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 )
 
 // EvaluateUser will iterate the user's roles and return true if any of them have
-// a rule that allows the given action.
+// a rule that allows the given action, and none of them have a rule that denies it.
+// The conditions of each rule are matched against the attributes of the user.
 func EvaluateUser(u *types.VDIUser, action *types.APIAction) bool {
 	for _, role := range u.Roles {
-		if ok := EvaluateRole(role, action); ok {
+		if roleDenies(role, action, u.Attributes) {
+			return false
+		}
+	}
+	for _, role := range u.Roles {
+		if ok := evaluateRole(role, action, u.Attributes); ok {
 			return true
 		}
 	}
@@ -38,10 +46,33 @@ func EvaluateUser(u *types.VDIUser, action *types.APIAction) bool {
 }
 
 // EvaluateRole iterates all the rules in the given role role and returns true if any of them
-// allow the provided action.
+// allow the provided action, and none of them deny it. Conditions are matched as if the user
+// has no attributes.
 func EvaluateRole(r *types.VDIUserRole, action *types.APIAction) bool {
+	return evaluateRole(r, action, nil)
+}
+
+func evaluateRole(r *types.VDIUserRole, action *types.APIAction, attributes map[string]string) bool {
+	if roleDenies(r, action, attributes) {
+		return false
+	}
 	for _, rule := range r.Rules {
-		if ok := EvaluateRule(rule, action); ok {
+		if ok := evaluateRule(rule, action, attributes); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleDenies returns true if any of the deny rules in the given role match the provided action.
+// Conditions are matched as if the user has no attributes.
+func RoleDenies(r *types.VDIUserRole, action *types.APIAction) bool {
+	return roleDenies(r, action, nil)
+}
+
+func roleDenies(r *types.VDIUserRole, action *types.APIAction, attributes map[string]string) bool {
+	for _, rule := range r.Rules {
+		if ruleDenies(rule, action, attributes) {
 			return true
 		}
 	}
@@ -49,25 +80,73 @@ func EvaluateRole(r *types.VDIUserRole, action *types.APIAction) bool {
 }
 
 // EvaluateRule checks if the given rule allows the given action. First the verb is matched,
-// then the resource type, and then optionally a name and namespace.
+// then the resource type, and then optionally a name and namespace. Namespace selectors are
+// matched against the namespace labels on the action. Deny rules, and rules
+// whose schedule is not active at the current time, never allow an action. Conditions are
+// matched as if the user has no attributes.
 func EvaluateRule(r rbacv1.Rule, action *types.APIAction) bool {
-	if action.ResourceType == rbacv1.ResourceServiceAccounts && action.ResourceName == "default" {
-		// Treat default service accounts as just checking the ability to launch templates
-		// in the given namespace
-		action.ResourceName = ""
-		action.ResourceType = rbacv1.ResourceTemplates
+	return evaluateRule(r, action, nil)
+}
+
+func evaluateRule(r rbacv1.Rule, action *types.APIAction, attributes map[string]string) bool {
+	if r.IsDeny() || !r.IsActive(time.Now()) || !r.ConditionsMet(attributes) {
+		return false
 	}
+	normalizeAction(action)
 	if !r.HasVerb(action.Verb) {
 		return false
 	}
 	if !r.HasResourceType(action.ResourceType) {
 		return false
 	}
-	if action.ResourceName != "" && !r.MatchesResourceName(action.ResourceName) {
+	if action.ResourceName != "" && !r.MatchesResource(action.ResourceName, action.ResourceTeams) {
 		return false
 	}
-	if action.ResourceNamespace != "" && !r.HasNamespace(action.ResourceNamespace) {
+	if action.ResourceNamespace != "" && !r.MatchesNamespace(action.ResourceNamespace, action.ResourceNamespaceLabels) {
 		return false
 	}
 	return true
 }
+
+// RuleDenies checks if the given deny rule matches the given action. Unlike allow rules, a
+// deny rule with resource patterns or teams only matches actions on a resource it names, and
+// a deny rule with namespaces or a namespace selector only matches actions in one of them.
+// This way an exception for some templates does not also deny listing the rest of them. A
+// deny rule with a schedule only matches while the schedule is active. Conditions are
+// matched as if the user has no attributes.
+func RuleDenies(r rbacv1.Rule, action *types.APIAction) bool {
+	return ruleDenies(r, action, nil)
+}
+
+func ruleDenies(r rbacv1.Rule, action *types.APIAction, attributes map[string]string) bool {
+	if !r.IsDeny() || !r.IsActive(time.Now()) || !r.ConditionsMet(attributes) {
+		return false
+	}
+	normalizeAction(action)
+	if !r.HasVerb(action.Verb) {
+		return false
+	}
+	if !r.HasResourceType(action.ResourceType) {
+		return false
+	}
+	if len(r.ResourcePatterns) > 0 || len(r.Teams) > 0 {
+		if action.ResourceName == "" || !r.MatchesResource(action.ResourceName, action.ResourceTeams) {
+			return false
+		}
+	}
+	if r.HasNamespaceRestriction() {
+		if action.ResourceNamespace == "" || !r.MatchesNamespace(action.ResourceNamespace, action.ResourceNamespaceLabels) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeAction treats default service accounts as just checking the ability to launch
+// templates in the given namespace.
+func normalizeAction(action *types.APIAction) {
+	if action.ResourceType == rbacv1.ResourceServiceAccounts && action.ResourceName == "default" {
+		action.ResourceName = ""
+		action.ResourceType = rbacv1.ResourceTemplates
+	}
+}
@@ -0,0 +1,139 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+type fakeResourceGetter struct {
+	templates []string
+}
+
+func (f *fakeResourceGetter) GetTemplates() ([]string, error)        { return f.templates, nil }
+func (f *fakeResourceGetter) GetUsers() ([]types.VDIUser, error)     { return nil, nil }
+func (f *fakeResourceGetter) GetRoles() ([]types.VDIUserRole, error) { return nil, nil }
+
+var launchAllTemplates = rbacv1.Rule{
+	Verbs:            []rbacv1.Verb{rbacv1.VerbLaunch},
+	Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+	ResourcePatterns: []string{".*"},
+	Namespaces:       []string{rbacv1.NamespaceAll},
+}
+
+var denyAdminTemplates = rbacv1.Rule{
+	Effect:           rbacv1.EffectDeny,
+	Verbs:            []rbacv1.Verb{rbacv1.VerbAll},
+	Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+	ResourcePatterns: []string{"^admin-.*"},
+}
+
+func launchAction(name, namespace string) *types.APIAction {
+	return &types.APIAction{
+		Verb:              rbacv1.VerbLaunch,
+		ResourceType:      rbacv1.ResourceTemplates,
+		ResourceName:      name,
+		ResourceNamespace: namespace,
+	}
+}
+
+func TestDenyRules(t *testing.T) {
+	user := &types.VDIUser{
+		Name: "test",
+		Roles: []*types.VDIUserRole{
+			{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}},
+			{Name: "exceptions", Rules: []rbacv1.Rule{denyAdminTemplates}},
+		},
+	}
+
+	if !EvaluateUser(user, launchAction("ubuntu", "default")) {
+		t.Error("Expected templates outside the exception to be allowed")
+	}
+	if EvaluateUser(user, launchAction("admin-tools", "default")) {
+		t.Error("Expected deny rule in another role to take precedence")
+	}
+	if !EvaluateUser(user, launchAction("", "default")) {
+		t.Error("Expected a deny rule with patterns to not match unnamed actions")
+	}
+	if EvaluateRule(denyAdminTemplates, launchAction("admin-tools", "")) {
+		t.Error("Expected deny rule to never allow an action")
+	}
+
+	role := &types.VDIUserRole{Rules: []rbacv1.Rule{launchAllTemplates, denyAdminTemplates}}
+	if EvaluateRole(role, launchAction("admin-tools", "default")) {
+		t.Error("Expected deny rule to take precedence within a role")
+	}
+
+	// A deny rule scoped to namespaces only matches actions in them
+	denyProd := rbacv1.Rule{
+		Effect:     rbacv1.EffectDeny,
+		Verbs:      []rbacv1.Verb{rbacv1.VerbLaunch},
+		Resources:  []rbacv1.Resource{rbacv1.ResourceTemplates},
+		Namespaces: []string{"prod"},
+	}
+	role = &types.VDIUserRole{Rules: []rbacv1.Rule{launchAllTemplates, denyProd}}
+	if EvaluateRole(role, launchAction("ubuntu", "prod")) {
+		t.Error("Expected launching in prod to be denied")
+	}
+	if !EvaluateRole(role, launchAction("ubuntu", "dev")) {
+		t.Error("Expected launching outside prod to be allowed")
+	}
+}
+
+func TestDenyRulesIncludes(t *testing.T) {
+	getter := &fakeResourceGetter{templates: []string{"ubuntu", "admin-tools"}}
+	user := &types.VDIUser{
+		Name: "test",
+		Roles: []*types.VDIUserRole{
+			{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}},
+			{Name: "exceptions", Rules: []rbacv1.Rule{denyAdminTemplates}},
+		},
+	}
+
+	if UserIncludesRule(user, launchAllTemplates, getter) {
+		t.Error("Expected a rule covering denied templates to be an escalation")
+	}
+	ubuntuOnly := launchAllTemplates
+	ubuntuOnly.ResourcePatterns = []string{"^ubuntu$"}
+	if !UserIncludesRule(user, ubuntuOnly, getter) {
+		t.Error("Expected a rule outside the exception to be included")
+	}
+	if !UserIncludesRule(user, denyAdminTemplates, getter) {
+		t.Error("Expected deny rules to always be included")
+	}
+	if RuleIncludes(denyAdminTemplates, ubuntuOnly, getter) {
+		t.Error("Expected deny rules to include no allow rules")
+	}
+
+	// Rules for other verbs or resources do not overlap
+	readUsers := rbacv1.Rule{
+		Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+		Resources:        []rbacv1.Resource{rbacv1.ResourceUsers},
+		ResourcePatterns: []string{".*"},
+	}
+	if DenyOverlapsRule(denyAdminTemplates, readUsers, getter) {
+		t.Error("Expected deny rule for templates to not overlap a rule for users")
+	}
+}
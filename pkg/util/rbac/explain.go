@@ -0,0 +1,99 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"fmt"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// Decision explains the outcome of evaluating an action against the roles of a user.
+type Decision struct {
+	// Whether the action is allowed
+	Allowed bool
+	// The name of the role containing the rule that decided the outcome. Empty when no
+	// rule matched the action.
+	Role string
+	// The index of the rule in the rules of the role, including inherited rules. -1 when
+	// no rule matched the action.
+	RuleIndex int
+	// The rule that decided the outcome, if any.
+	Rule *rbacv1.Rule
+}
+
+// Reason returns a human readable explanation of the decision.
+func (d *Decision) Reason() string {
+	switch {
+	case d.Rule == nil:
+		return "no rule in the user's roles allows the action"
+	case d.Allowed:
+		return fmt.Sprintf("allowed by rule %d of role %q", d.RuleIndex, d.Role)
+	default:
+		return fmt.Sprintf("denied by rule %d of role %q", d.RuleIndex, d.Role)
+	}
+}
+
+// ExplainUser evaluates the given action against the user's roles the same way as
+// EvaluateUser, but returns the rule that decided the outcome. The first matching deny
+// rule takes precedence, otherwise the first matching allow rule is returned.
+func ExplainUser(u *types.VDIUser, action *types.APIAction) *Decision {
+	for _, role := range u.Roles {
+		for i, rule := range role.Rules {
+			if ruleDenies(rule, action, u.Attributes) {
+				return &Decision{Allowed: false, Role: role.GetName(), RuleIndex: i, Rule: &role.Rules[i]}
+			}
+		}
+	}
+	for _, role := range u.Roles {
+		for i, rule := range role.Rules {
+			if evaluateRule(rule, action, u.Attributes) {
+				return &Decision{Allowed: true, Role: role.GetName(), RuleIndex: i, Rule: &role.Rules[i]}
+			}
+		}
+	}
+	return &Decision{Allowed: false, RuleIndex: -1}
+}
+
+// UsersAllowed returns the names of the users allowed to perform the given action.
+func UsersAllowed(users []*types.VDIUser, action *types.APIAction) []string {
+	out := make([]string, 0)
+	for _, user := range users {
+		if EvaluateUser(user, action) {
+			out = append(out, user.GetName())
+		}
+	}
+	return out
+}
+
+// RolesAllowed returns the names of the roles that allow the given action on their own.
+// Conditions are matched as if the user has no attributes.
+func RolesAllowed(roles []*types.VDIUserRole, action *types.APIAction) []string {
+	out := make([]string, 0)
+	for _, role := range roles {
+		if EvaluateRole(role, action) {
+			out = append(out, role.GetName())
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestExplainUser(t *testing.T) {
+	readRoles := rbacv1.Rule{
+		Verbs:     []rbacv1.Verb{rbacv1.VerbRead},
+		Resources: []rbacv1.Resource{rbacv1.ResourceRoles},
+	}
+	user := &types.VDIUser{
+		Name: "test",
+		Roles: []*types.VDIUserRole{
+			{Name: "launchers", Rules: []rbacv1.Rule{readRoles, launchAllTemplates}},
+			{Name: "exceptions", Rules: []rbacv1.Rule{denyAdminTemplates}},
+		},
+	}
+
+	decision := ExplainUser(user, launchAction("ubuntu", "default"))
+	if !decision.Allowed || decision.Role != "launchers" || decision.RuleIndex != 1 {
+		t.Error("Expected launch to be allowed by the second rule of launchers, got:", decision.Reason())
+	}
+	if !decision.Rule.DeepEqual(launchAllTemplates) {
+		t.Error("Expected the matching rule to be returned, got:", decision.Rule)
+	}
+
+	decision = ExplainUser(user, launchAction("admin-tools", "default"))
+	if decision.Allowed || decision.Role != "exceptions" || decision.RuleIndex != 0 {
+		t.Error("Expected launch to be denied by the rule in exceptions, got:", decision.Reason())
+	}
+
+	decision = ExplainUser(user, &types.APIAction{Verb: rbacv1.VerbDelete, ResourceType: rbacv1.ResourceUsers})
+	if decision.Allowed || decision.Rule != nil || decision.RuleIndex != -1 {
+		t.Error("Expected no rule to match, got:", decision.Reason())
+	}
+
+	// the decision should always agree with EvaluateUser
+	for _, action := range []*types.APIAction{
+		launchAction("ubuntu", "default"),
+		launchAction("admin-tools", "default"),
+		launchAction("", "default"),
+		{Verb: rbacv1.VerbRead, ResourceType: rbacv1.ResourceRoles, ResourceName: "any"},
+	} {
+		if ExplainUser(user, action).Allowed != EvaluateUser(user, action) {
+			t.Errorf("Expected explanation to agree with evaluation for %s", action.String())
+		}
+	}
+}
+
+func TestWhoCan(t *testing.T) {
+	launchers := &types.VDIUserRole{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}}
+	exceptions := &types.VDIUserRole{Name: "exceptions", Rules: []rbacv1.Rule{launchAllTemplates, denyAdminTemplates}}
+	users := []*types.VDIUser{
+		{Name: "alice", Roles: []*types.VDIUserRole{launchers}},
+		{Name: "bob", Roles: []*types.VDIUserRole{exceptions}},
+		{Name: "carol", Roles: []*types.VDIUserRole{}},
+	}
+
+	if got := UsersAllowed(users, launchAction("ubuntu", "default")); !reflect.DeepEqual(got, []string{"alice", "bob"}) {
+		t.Error("Expected alice and bob to be able to launch ubuntu, got:", got)
+	}
+	if got := UsersAllowed(users, launchAction("admin-tools", "default")); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Error("Expected only alice to be able to launch admin-tools, got:", got)
+	}
+	roles := []*types.VDIUserRole{launchers, exceptions}
+	if got := RolesAllowed(roles, launchAction("admin-tools", "default")); !reflect.DeepEqual(got, []string{"launchers"}) {
+		t.Error("Expected only launchers to allow launching admin-tools, got:", got)
+	}
+}
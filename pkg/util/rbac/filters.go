@@ -28,10 +28,17 @@ import (
 )
 
 // FilterTemplates will take a list of DesktopTemplates and filter them based
-// off which ones the user is allowed to use.
+// off which ones the user is allowed to use. Draft templates are only included
+// for their author and users allowed to review them.
 func FilterTemplates(u *types.VDIUser, tmpls []*desktopsv1.Template) []*desktopsv1.Template {
 	filtered := make([]*desktopsv1.Template, 0)
 	for _, tmpl := range tmpls {
+		if tmpl.IsDraft() {
+			if CanAccessDraft(u, tmpl) {
+				filtered = append(filtered, tmpl)
+			}
+			continue
+		}
 		action := &types.APIAction{
 			Verb:         rbacv1.VerbLaunch,
 			ResourceType: rbacv1.ResourceTemplates,
@@ -44,15 +51,35 @@ func FilterTemplates(u *types.VDIUser, tmpls []*desktopsv1.Template) []*desktops
 	return filtered
 }
 
+// CanAccessDraft returns true if the user may see and launch the given draft template,
+// either because they saved it or because they are allowed to review it.
+func CanAccessDraft(u *types.VDIUser, tmpl *desktopsv1.Template) bool {
+	if tmpl.GetReviewAuthor() == u.GetName() {
+		return true
+	}
+	return CanReviewTemplate(u, tmpl)
+}
+
+// CanReviewTemplate returns true if the user is allowed to review the given template.
+func CanReviewTemplate(u *types.VDIUser, tmpl *desktopsv1.Template) bool {
+	return EvaluateUser(u, &types.APIAction{
+		Verb:         rbacv1.VerbReview,
+		ResourceType: rbacv1.ResourceTemplates,
+		ResourceName: tmpl.GetName(),
+	})
+}
+
 // FilterUserNamespaces will take a list of namespaces and filter them based off
-// the ones this user can provision desktops in.
-func FilterUserNamespaces(u *types.VDIUser, nss []string) []string {
+// the ones this user can provision desktops in. The labels of each namespace are
+// used to evaluate rules with namespace selectors.
+func FilterUserNamespaces(u *types.VDIUser, nss []string, nsLabels map[string]map[string]string) []string {
 	filtered := make([]string, 0)
 	for _, ns := range nss {
 		action := &types.APIAction{
-			Verb:              rbacv1.VerbLaunch,
-			ResourceType:      rbacv1.ResourceTemplates,
-			ResourceNamespace: ns,
+			Verb:                    rbacv1.VerbLaunch,
+			ResourceType:            rbacv1.ResourceTemplates,
+			ResourceNamespace:       ns,
+			ResourceNamespaceLabels: nsLabels[ns],
 		}
 		if EvaluateUser(u, action) {
 			filtered = append(filtered, ns)
@@ -62,15 +89,17 @@ func FilterUserNamespaces(u *types.VDIUser, nss []string) []string {
 }
 
 // FilterUserServiceAccounts will take a list of service accounts and a given namespace,
-// and filter them based off the ones this user can assume with desktops.
-func FilterUserServiceAccounts(u *types.VDIUser, sas []string, ns string) []string {
+// and filter them based off the ones this user can assume with desktops. The labels of
+// the namespace are used to evaluate rules with namespace selectors.
+func FilterUserServiceAccounts(u *types.VDIUser, sas []string, ns string, nsLabels map[string]string) []string {
 	filtered := make([]string, 0)
 	for _, sa := range sas {
 		action := &types.APIAction{
-			Verb:              rbacv1.VerbUse,
-			ResourceType:      rbacv1.ResourceServiceAccounts,
-			ResourceName:      sa,
-			ResourceNamespace: ns,
+			Verb:                    rbacv1.VerbUse,
+			ResourceType:            rbacv1.ResourceServiceAccounts,
+			ResourceName:            sa,
+			ResourceNamespace:       ns,
+			ResourceNamespaceLabels: nsLabels,
 		}
 		if EvaluateUser(u, action) {
 			filtered = append(filtered, sa)
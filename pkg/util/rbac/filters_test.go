@@ -0,0 +1,95 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	desktopsv1 "github.com/tinyzimmer/kvdi/apis/desktops/v1"
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newDraftTemplate(name, author string, state desktopsv1.ReviewState) *desktopsv1.Template {
+	return &desktopsv1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: desktopsv1.TemplateSpec{
+			Review: &desktopsv1.TemplateReview{Author: author, State: state},
+		},
+	}
+}
+
+func TestFilterDraftTemplates(t *testing.T) {
+	tmpls := []*desktopsv1.Template{
+		{ObjectMeta: metav1.ObjectMeta{Name: "ubuntu"}},
+		newDraftTemplate("alice-draft", "alice", desktopsv1.ReviewPending),
+		newDraftTemplate("alice-rejected", "alice", desktopsv1.ReviewRejected),
+		newDraftTemplate("bob-approved", "bob", desktopsv1.ReviewApproved),
+	}
+	reviewAll := rbacv1.Rule{
+		Verbs:            []rbacv1.Verb{rbacv1.VerbReview},
+		Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+		ResourcePatterns: []string{".*"},
+	}
+
+	tc := []struct {
+		user     *types.VDIUser
+		expected []string
+	}{
+		{
+			user: &types.VDIUser{Name: "alice", Roles: []*types.VDIUserRole{
+				{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}},
+			}},
+			expected: []string{"ubuntu", "alice-draft", "alice-rejected", "bob-approved"},
+		},
+		{
+			user: &types.VDIUser{Name: "bob", Roles: []*types.VDIUserRole{
+				{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}},
+			}},
+			expected: []string{"ubuntu", "bob-approved"},
+		},
+		{
+			user: &types.VDIUser{Name: "carol", Roles: []*types.VDIUserRole{
+				{Name: "reviewers", Rules: []rbacv1.Rule{reviewAll}},
+			}},
+			expected: []string{"alice-draft", "alice-rejected"},
+		},
+	}
+	for _, c := range tc {
+		filtered := FilterTemplates(c.user, tmpls)
+		names := make([]string, len(filtered))
+		for i, tmpl := range filtered {
+			names[i] = tmpl.GetName()
+		}
+		if len(names) != len(c.expected) {
+			t.Errorf("Expected %s to see %v, got %v", c.user.GetName(), c.expected, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != c.expected[i] {
+				t.Errorf("Expected %s to see %v, got %v", c.user.GetName(), c.expected, names)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"sort"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+)
+
+// Inheritance is the result of resolving the roles a VDIRole inherits from.
+type Inheritance struct {
+	// The rules inherited from other roles, without duplicates or rules the role already has.
+	Rules []rbacv1.Rule
+	// The names of the roles rules were inherited from, in the order they were visited.
+	Roles []string
+	// The names of roles in the inheritance chain that do not exist.
+	Missing []string
+}
+
+// ResolveInheritance walks the roles the given role inherits from, directly or transitively,
+// and collects their rules. Only the rules defined on each role are used, so the result does
+// not depend on the status of other roles. Cycles are only traversed once.
+func ResolveInheritance(role *rbacv1.VDIRole, roles []*rbacv1.VDIRole) *Inheritance {
+	byName := make(map[string]*rbacv1.VDIRole, len(roles))
+	for _, r := range roles {
+		byName[r.GetName()] = r
+	}
+
+	out := &Inheritance{}
+	seen := map[string]struct{}{role.GetName(): {}}
+	queue := append([]string{}, role.GetInheritsFrom()...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		parent, ok := byName[name]
+		if !ok {
+			out.Missing = append(out.Missing, name)
+			continue
+		}
+		out.Roles = append(out.Roles, name)
+		for _, rule := range parent.GetRules() {
+			if !hasRule(role.GetRules(), rule) && !hasRule(out.Rules, rule) {
+				out.Rules = append(out.Rules, rule)
+			}
+		}
+		queue = append(queue, parent.GetInheritsFrom()...)
+	}
+	return out
+}
+
+// EffectiveRules returns the rules of the given role followed by the rules it inherits from
+// the other roles.
+func EffectiveRules(role *rbacv1.VDIRole, roles []*rbacv1.VDIRole) []rbacv1.Rule {
+	inherited := ResolveInheritance(role, roles).Rules
+	rules := make([]rbacv1.Rule, 0, len(role.GetRules())+len(inherited))
+	rules = append(rules, role.GetRules()...)
+	return append(rules, inherited...)
+}
+
+// Inheritors returns the names of the roles that inherit from the given role, directly or
+// transitively, sorted. Roles inheriting from it are included even if it does not exist.
+func Inheritors(name string, roles []*rbacv1.VDIRole) []string {
+	out := make([]string, 0)
+	for _, role := range roles {
+		if role.GetName() == name {
+			continue
+		}
+		inheritance := ResolveInheritance(role, roles)
+		if containsString(inheritance.Roles, name) || containsString(inheritance.Missing, name) {
+			out = append(out, role.GetName())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRule(rules []rbacv1.Rule, rule rbacv1.Rule) bool {
+	for _, r := range rules {
+		if r.DeepEqual(rule) {
+			return true
+		}
+	}
+	return false
+}
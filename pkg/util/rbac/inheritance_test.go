@@ -0,0 +1,102 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestRole(name string, rules []rbacv1.Rule, inheritsFrom ...string) *rbacv1.VDIRole {
+	return &rbacv1.VDIRole{
+		ObjectMeta:   metav1.ObjectMeta{Name: name},
+		Rules:        rules,
+		InheritsFrom: inheritsFrom,
+	}
+}
+
+var readRoles = rbacv1.Rule{
+	Verbs:            []rbacv1.Verb{rbacv1.VerbRead},
+	Resources:        []rbacv1.Resource{rbacv1.ResourceRoles},
+	ResourcePatterns: []string{".*"},
+}
+
+func TestResolveInheritance(t *testing.T) {
+	roles := []*rbacv1.VDIRole{
+		newTestRole("base", []rbacv1.Rule{launchAllTemplates}),
+		newTestRole("restricted", []rbacv1.Rule{denyAdminTemplates}, "base"),
+		newTestRole("auditor", []rbacv1.Rule{readRoles, launchAllTemplates}, "restricted", "missing"),
+		// cycles are only traversed once
+		newTestRole("cycle-a", nil, "cycle-b"),
+		newTestRole("cycle-b", []rbacv1.Rule{readRoles}, "cycle-a"),
+	}
+
+	inheritance := ResolveInheritance(roles[2], roles)
+	if !reflect.DeepEqual(inheritance.Roles, []string{"restricted", "base"}) {
+		t.Error("Unexpected inherited roles:", inheritance.Roles)
+	}
+	if !reflect.DeepEqual(inheritance.Missing, []string{"missing"}) {
+		t.Error("Unexpected missing roles:", inheritance.Missing)
+	}
+	// the launch rule is already on the role
+	if len(inheritance.Rules) != 1 || !inheritance.Rules[0].DeepEqual(denyAdminTemplates) {
+		t.Error("Unexpected inherited rules:", inheritance.Rules)
+	}
+	if rules := EffectiveRules(roles[2], roles); len(rules) != 3 {
+		t.Error("Expected three effective rules, got:", rules)
+	}
+
+	inheritance = ResolveInheritance(roles[3], roles)
+	if !reflect.DeepEqual(inheritance.Roles, []string{"cycle-b"}) || len(inheritance.Rules) != 1 {
+		t.Error("Unexpected inheritance for a cycle:", inheritance)
+	}
+
+	if inheritors := Inheritors("base", roles); !reflect.DeepEqual(inheritors, []string{"auditor", "restricted"}) {
+		t.Error("Unexpected inheritors of base:", inheritors)
+	}
+	if inheritors := Inheritors("missing", roles); !reflect.DeepEqual(inheritors, []string{"auditor"}) {
+		t.Error("Unexpected inheritors of a missing role:", inheritors)
+	}
+}
+
+func TestInheritedRulesEvaluation(t *testing.T) {
+	role := newTestRole("restricted", []rbacv1.Rule{denyAdminTemplates}, "base")
+	role.Status.InheritedRules = []rbacv1.Rule{launchAllTemplates}
+
+	userRole := VDIRoleToUserRole(role)
+	if !EvaluateRole(userRole, launchAction("ubuntu", "default")) {
+		t.Error("Expected inherited rules to allow launching")
+	}
+	if EvaluateRole(userRole, launchAction("admin-tools", "default")) {
+		t.Error("Expected the role's own deny rule to apply over inherited rules")
+	}
+
+	// inherited rules left in the status are ignored once the role stops inheriting
+	role.InheritsFrom = nil
+	if EvaluateRole(VDIRoleToUserRole(role), launchAction("ubuntu", "default")) {
+		t.Error("Expected stale inherited rules to be ignored")
+	}
+}
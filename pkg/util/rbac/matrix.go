@@ -0,0 +1,102 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// matrixTemplateVerbs are the verbs evaluated against every template in every namespace
+// when building a permission matrix.
+var matrixTemplateVerbs = []rbacv1.Verb{
+	rbacv1.VerbLaunch,
+	rbacv1.VerbUse,
+	rbacv1.VerbView,
+	rbacv1.VerbUseFileTransfer,
+	rbacv1.VerbUseClipboard,
+	rbacv1.VerbUseMicrophone,
+}
+
+// matrixResourceVerbs are the verbs evaluated against each type of resource as a whole
+// when building a permission matrix.
+var matrixResourceVerbs = []struct {
+	resource rbacv1.Resource
+	verbs    []rbacv1.Verb
+}{
+	{rbacv1.ResourceUsers, []rbacv1.Verb{rbacv1.VerbCreate, rbacv1.VerbRead, rbacv1.VerbUpdate, rbacv1.VerbDelete}},
+	{rbacv1.ResourceRoles, []rbacv1.Verb{rbacv1.VerbCreate, rbacv1.VerbRead, rbacv1.VerbUpdate, rbacv1.VerbDelete}},
+	{rbacv1.ResourceTemplates, []rbacv1.Verb{rbacv1.VerbCreate, rbacv1.VerbRead, rbacv1.VerbUpdate, rbacv1.VerbDelete, rbacv1.VerbReview}},
+	{rbacv1.ResourceAuditLogs, []rbacv1.Verb{rbacv1.VerbRead}},
+	{rbacv1.ResourceRecordings, []rbacv1.Verb{rbacv1.VerbRead, rbacv1.VerbDelete}},
+}
+
+// PermissionMatrixActions returns the actions evaluated for a permission matrix. These are
+// the management verbs on each type of resource, followed by the session verbs on every
+// template in every namespace. The labels of each namespace are used to match namespace
+// selectors.
+func PermissionMatrixActions(templates, namespaces []string, nsLabels map[string]map[string]string) []*types.APIAction {
+	actions := make([]*types.APIAction, 0)
+	for _, rv := range matrixResourceVerbs {
+		for _, verb := range rv.verbs {
+			actions = append(actions, &types.APIAction{Verb: verb, ResourceType: rv.resource})
+		}
+	}
+	for _, tmpl := range templates {
+		for _, ns := range namespaces {
+			for _, verb := range matrixTemplateVerbs {
+				actions = append(actions, &types.APIAction{
+					Verb:                    verb,
+					ResourceType:            rbacv1.ResourceTemplates,
+					ResourceName:            tmpl,
+					ResourceNamespace:       ns,
+					ResourceNamespaceLabels: nsLabels[ns],
+				})
+			}
+		}
+	}
+	return actions
+}
+
+// PermissionMatrix evaluates every action against every user and returns an entry for
+// each action a user is allowed, along with the role and rule that allows it.
+func PermissionMatrix(users []*types.VDIUser, actions []*types.APIAction) []*types.PermissionMatrixEntry {
+	out := make([]*types.PermissionMatrixEntry, 0)
+	for _, user := range users {
+		for _, action := range actions {
+			decision := ExplainUser(user, action)
+			if !decision.Allowed {
+				continue
+			}
+			out = append(out, &types.PermissionMatrixEntry{
+				User:      user.GetName(),
+				Resource:  action.ResourceType,
+				Verb:      action.Verb,
+				Template:  action.ResourceName,
+				Namespace: action.ResourceNamespace,
+				Role:      decision.Role,
+				Rule:      decision.RuleIndex,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	readRoles := rbacv1.Rule{
+		Verbs:     []rbacv1.Verb{rbacv1.VerbRead},
+		Resources: []rbacv1.Resource{rbacv1.ResourceRoles},
+	}
+	users := []*types.VDIUser{
+		{
+			Name: "alice",
+			Roles: []*types.VDIUserRole{
+				{Name: "auditors", Rules: []rbacv1.Rule{readRoles}},
+				{Name: "launchers", Rules: []rbacv1.Rule{launchAllTemplates}},
+				{Name: "exceptions", Rules: []rbacv1.Rule{denyAdminTemplates}},
+			},
+		},
+		{
+			Name:  "bob",
+			Roles: []*types.VDIUserRole{{Name: "scientists", Rules: []rbacv1.Rule{launchDataScience}}},
+		},
+		{Name: "carol"},
+	}
+	actions := PermissionMatrixActions(
+		[]string{"ubuntu", "admin-tools"},
+		[]string{"default", "notebooks"},
+		map[string]map[string]string{"notebooks": {"team": "data-science"}},
+	)
+
+	type grant struct {
+		user, verb, template, namespace, role string
+		rule                                  int
+	}
+	expected := []grant{
+		{"alice", "read", "", "", "auditors", 0},
+		{"alice", "launch", "ubuntu", "default", "launchers", 0},
+		{"alice", "launch", "ubuntu", "notebooks", "launchers", 0},
+		{"bob", "launch", "ubuntu", "notebooks", "scientists", 0},
+		{"bob", "launch", "admin-tools", "notebooks", "scientists", 0},
+	}
+
+	entries := PermissionMatrix(users, actions)
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, entry := range entries {
+		got := grant{entry.User, string(entry.Verb), entry.Template, entry.Namespace, entry.Role, entry.Rule}
+		if got != expected[i] {
+			t.Errorf("Expected entry %d to be %+v, got %+v", i, expected[i], got)
+		}
+	}
+}
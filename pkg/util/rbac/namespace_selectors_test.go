@@ -0,0 +1,106 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var launchDataScience = rbacv1.Rule{
+	Verbs:             []rbacv1.Verb{rbacv1.VerbLaunch},
+	Resources:         []rbacv1.Resource{rbacv1.ResourceTemplates},
+	ResourcePatterns:  []string{".*"},
+	NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "data-science"}},
+}
+
+func launchActionWithLabels(name, namespace string, nsLabels map[string]string) *types.APIAction {
+	action := launchAction(name, namespace)
+	action.ResourceNamespaceLabels = nsLabels
+	return action
+}
+
+func TestNamespaceSelectorRules(t *testing.T) {
+	dataScience := map[string]string{"team": "data-science"}
+	if !EvaluateRule(launchDataScience, launchActionWithLabels("ubuntu", "notebooks", dataScience)) {
+		t.Error("Expected launching in a labeled namespace to be allowed")
+	}
+	if EvaluateRule(launchDataScience, launchActionWithLabels("ubuntu", "web", map[string]string{"team": "web"})) {
+		t.Error("Expected launching in a namespace with other labels to be denied")
+	}
+	if EvaluateRule(launchDataScience, launchAction("ubuntu", "notebooks")) {
+		t.Error("Expected launching in a namespace without labels to be denied")
+	}
+	if !EvaluateRule(launchDataScience, launchAction("ubuntu", "")) {
+		t.Error("Expected actions without a namespace to ignore the selector")
+	}
+
+	// listed namespaces and the selector are combined
+	withStatic := launchDataScience
+	withStatic.Namespaces = []string{"shared"}
+	if !EvaluateRule(withStatic, launchAction("ubuntu", "shared")) {
+		t.Error("Expected listed namespaces to still be matched")
+	}
+
+	denyDataScience := launchDataScience
+	denyDataScience.Effect = rbacv1.EffectDeny
+	role := &types.VDIUserRole{Name: "test", Rules: []rbacv1.Rule{launchAllTemplates, denyDataScience}}
+	if EvaluateRole(role, launchActionWithLabels("ubuntu", "notebooks", dataScience)) {
+		t.Error("Expected the deny rule to match the labeled namespace")
+	}
+	if !EvaluateRole(role, launchAction("ubuntu", "default")) {
+		t.Error("Expected the deny rule to not match other namespaces")
+	}
+
+	getter := &fakeResourceGetter{templates: []string{"ubuntu"}}
+	if RuleIncludes(launchDataScience, launchAllTemplates, getter) {
+		t.Error("Expected a selector to not include all namespaces")
+	}
+	if !RuleIncludes(launchAllTemplates, launchDataScience, getter) {
+		t.Error("Expected all namespaces to include a selector")
+	}
+	otherSelector := launchDataScience
+	otherSelector.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "web"}}
+	if RuleIncludes(launchDataScience, otherSelector, getter) {
+		t.Error("Expected a selector to not include a different selector")
+	}
+}
+
+func TestNamespaceSelectorValidation(t *testing.T) {
+	rule := launchDataScience
+	if errs := rule.Validate(field.NewPath("rules").Index(0)); len(errs) > 0 {
+		t.Error("Expected selector to be valid, got:", errs.ToAggregate())
+	}
+	rule.NamespaceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Matches"}},
+	}
+	if errs := rule.Validate(field.NewPath("rules").Index(0)); len(errs) == 0 {
+		t.Error("Expected selector with an unknown operator to be invalid")
+	}
+	if rule.MatchesNamespaceLabels(map[string]string{"team": "data-science"}) {
+		t.Error("Expected invalid selectors to never match")
+	}
+}
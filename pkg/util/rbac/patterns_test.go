@@ -0,0 +1,83 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestPatternTypes(t *testing.T) {
+	tc := []struct {
+		patternType rbacv1.PatternType
+		pattern     string
+		name        string
+		matches     bool
+	}{
+		{"", "dev-*", "dev-ubuntu", true},
+		{"", "dev-*", "mydev-ubuntu", true},
+		{rbacv1.PatternTypeRegex, "^dev-.*$", "mydev-ubuntu", false},
+		{rbacv1.PatternTypeGlob, "dev-*", "dev-ubuntu", true},
+		{rbacv1.PatternTypeGlob, "dev-*", "mydev-ubuntu", false},
+		{rbacv1.PatternTypeGlob, "dev-?", "dev-a", true},
+		{rbacv1.PatternTypeGlob, "dev-[a-c]", "dev-d", false},
+		{rbacv1.PatternTypeExact, "dev-ubuntu", "dev-ubuntu", true},
+		{rbacv1.PatternTypeExact, "dev-*", "dev-ubuntu", false},
+		{rbacv1.PatternTypeExact, "dev", "dev-ubuntu", false},
+		{"unknown", "dev-ubuntu", "dev-ubuntu", false},
+	}
+	for _, c := range tc {
+		rule := rbacv1.Rule{
+			Verbs:            []rbacv1.Verb{rbacv1.VerbLaunch},
+			Resources:        []rbacv1.Resource{rbacv1.ResourceTemplates},
+			ResourcePatterns: []string{c.pattern},
+			PatternType:      c.patternType,
+			Namespaces:       []string{rbacv1.NamespaceAll},
+		}
+		if got := EvaluateRule(rule, launchAction(c.name, "default")); got != c.matches {
+			t.Errorf("Expected %q pattern %q matching %q to be %v, got %v", c.patternType, c.pattern, c.name, c.matches, got)
+		}
+	}
+}
+
+func TestPatternTypeValidation(t *testing.T) {
+	tc := []struct {
+		rule  rbacv1.Rule
+		valid bool
+	}{
+		{rbacv1.Rule{ResourcePatterns: []string{"dev-["}, PatternType: rbacv1.PatternTypeExact}, true},
+		{rbacv1.Rule{ResourcePatterns: []string{"*"}, PatternType: rbacv1.PatternTypeGlob}, true},
+		{rbacv1.Rule{ResourcePatterns: []string{"dev-["}, PatternType: rbacv1.PatternTypeGlob}, false},
+		{rbacv1.Rule{ResourcePatterns: []string{"*"}}, false},
+		{rbacv1.Rule{ResourcePatterns: []string{".*"}, PatternType: "wildcard"}, false},
+	}
+	for i, c := range tc {
+		errs := c.rule.Validate(field.NewPath("rules").Index(i))
+		if c.valid && len(errs) > 0 {
+			t.Errorf("Expected rule %d to be valid, got %v", i, errs.ToAggregate())
+		} else if !c.valid && len(errs) == 0 {
+			t.Errorf("Expected rule %d to be invalid", i)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// EffectiveQuotas returns the session quotas that apply to a user holding the given roles.
+// The most permissive value of each limit across the roles is used, and a role that does not
+// set a limit lifts it entirely. Nil is returned when no limits apply.
+func EffectiveQuotas(roles []*types.VDIUserRole) *rbacv1.SessionQuotas {
+	if len(roles) == 0 {
+		return nil
+	}
+	out := &rbacv1.SessionQuotas{}
+	var unlimitedSessions, unlimitedPerNamespace, unlimitedCPU, unlimitedMemory, unlimitedDevices bool
+	for _, role := range roles {
+		if role == nil {
+			continue
+		}
+		q := role.Quotas
+		if q == nil {
+			return nil
+		}
+		if q.MaxSessions == 0 {
+			unlimitedSessions = true
+		} else if q.MaxSessions > out.MaxSessions {
+			out.MaxSessions = q.MaxSessions
+		}
+		if q.MaxSessionsPerNamespace == 0 {
+			unlimitedPerNamespace = true
+		} else if q.MaxSessionsPerNamespace > out.MaxSessionsPerNamespace {
+			out.MaxSessionsPerNamespace = q.MaxSessionsPerNamespace
+		}
+		if q.MaxCPU == nil {
+			unlimitedCPU = true
+		} else if out.MaxCPU == nil || q.MaxCPU.Cmp(*out.MaxCPU) > 0 {
+			cpu := q.MaxCPU.DeepCopy()
+			out.MaxCPU = &cpu
+		}
+		if q.MaxMemory == nil {
+			unlimitedMemory = true
+		} else if out.MaxMemory == nil || q.MaxMemory.Cmp(*out.MaxMemory) > 0 {
+			memory := q.MaxMemory.DeepCopy()
+			out.MaxMemory = &memory
+		}
+		if q.MaxDevices == 0 {
+			unlimitedDevices = true
+		} else if q.MaxDevices > out.MaxDevices {
+			out.MaxDevices = q.MaxDevices
+		}
+	}
+	if unlimitedSessions {
+		out.MaxSessions = 0
+	}
+	if unlimitedPerNamespace {
+		out.MaxSessionsPerNamespace = 0
+	}
+	if unlimitedCPU {
+		out.MaxCPU = nil
+	}
+	if unlimitedMemory {
+		out.MaxMemory = nil
+	}
+	if unlimitedDevices {
+		out.MaxDevices = 0
+	}
+	if out.MaxSessions == 0 && out.MaxSessionsPerNamespace == 0 && out.MaxCPU == nil && out.MaxMemory == nil && out.MaxDevices == 0 {
+		return nil
+	}
+	return out
+}
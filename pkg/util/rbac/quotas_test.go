@@ -0,0 +1,85 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func quantity(val string) *resource.Quantity {
+	q := resource.MustParse(val)
+	return &q
+}
+
+func TestEffectiveQuotas(t *testing.T) {
+	small := &types.VDIUserRole{Name: "small", Quotas: &rbacv1.SessionQuotas{
+		MaxSessions:             1,
+		MaxSessionsPerNamespace: 1,
+		MaxCPU:                  quantity("1"),
+		MaxMemory:               quantity("2Gi"),
+		MaxDevices:              1,
+	}}
+	large := &types.VDIUserRole{Name: "large", Quotas: &rbacv1.SessionQuotas{
+		MaxSessions: 5,
+		MaxCPU:      quantity("4"),
+		MaxMemory:   quantity("8Gi"),
+		MaxDevices:  3,
+	}}
+	unrestricted := &types.VDIUserRole{Name: "unrestricted"}
+
+	if quotas := EffectiveQuotas(nil); quotas != nil {
+		t.Error("Expected no quotas without roles, got", quotas)
+	}
+	if quotas := EffectiveQuotas([]*types.VDIUserRole{small, unrestricted}); quotas != nil {
+		t.Error("Expected a role without quotas to lift them, got", quotas)
+	}
+
+	quotas := EffectiveQuotas([]*types.VDIUserRole{small})
+	if quotas == nil || quotas.MaxSessions != 1 || quotas.MaxSessionsPerNamespace != 1 {
+		t.Fatal("Expected the quotas of a single role to apply, got", quotas)
+	}
+
+	quotas = EffectiveQuotas([]*types.VDIUserRole{small, large})
+	if quotas == nil {
+		t.Fatal("Expected quotas to apply")
+	}
+	if quotas.MaxSessions != 5 {
+		t.Error("Expected the largest session limit, got", quotas.MaxSessions)
+	}
+	if quotas.MaxSessionsPerNamespace != 0 {
+		t.Error("Expected a role without a per-namespace limit to lift it, got", quotas.MaxSessionsPerNamespace)
+	}
+	if quotas.MaxCPU == nil || quotas.MaxCPU.Cmp(resource.MustParse("4")) != 0 {
+		t.Error("Expected the largest CPU limit, got", quotas.MaxCPU)
+	}
+	if quotas.MaxMemory == nil || quotas.MaxMemory.Cmp(resource.MustParse("8Gi")) != 0 {
+		t.Error("Expected the largest memory limit, got", quotas.MaxMemory)
+	}
+	if quotas.MaxDevices != 3 {
+		t.Error("Expected the largest device limit, got", quotas.MaxDevices)
+	}
+}
@@ -22,13 +22,25 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 package rbac
 
 import (
+	"reflect"
+
 	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
 	"github.com/tinyzimmer/kvdi/pkg/types"
 )
 
 // UserIncludesRule returns true if the rules applied to this user are not elevated
-// by any of the permissions in the provided rule.
+// by any of the permissions in the provided rule. Deny rules only take permissions away,
+// so they are always included. Allow rules must not overlap a deny rule in any of the
+// user's roles.
 func UserIncludesRule(u *types.VDIUser, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGetter) bool {
+	if ruleToCheck.IsDeny() {
+		return true
+	}
+	for _, role := range u.Roles {
+		if roleDeniesRule(role, ruleToCheck, resourceGetter) {
+			return false
+		}
+	}
 	for _, role := range u.Roles {
 		if ok := RoleIncludesRule(role, ruleToCheck, resourceGetter); ok {
 			return true
@@ -40,6 +52,12 @@ func UserIncludesRule(u *types.VDIUser, ruleToCheck rbacv1.Rule, resourceGetter
 // RoleIncludesRule returns true if the rules applied to this role are not elevated
 // by any of the permissions in the provided rule.
 func RoleIncludesRule(r *types.VDIUserRole, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGetter) bool {
+	if ruleToCheck.IsDeny() {
+		return true
+	}
+	if roleDeniesRule(r, ruleToCheck, resourceGetter) {
+		return false
+	}
 	for _, rule := range r.Rules {
 		if ok := RuleIncludes(rule, ruleToCheck, resourceGetter); ok {
 			return true
@@ -48,13 +66,48 @@ func RoleIncludesRule(r *types.VDIUserRole, ruleToCheck rbacv1.Rule, resourceGet
 	return false
 }
 
+func roleDeniesRule(r *types.VDIUserRole, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGetter) bool {
+	for _, rule := range r.Rules {
+		if DenyOverlapsRule(rule, ruleToCheck, resourceGetter) {
+			return true
+		}
+	}
+	return false
+}
+
 // RuleIncludes returns false if ruleToCheck matches any actions or resources that r does not.
 func RuleIncludes(r, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGetter) bool {
 
+	// Deny rules grant nothing, so they include any other deny rule and no allow rule.
+	if ruleToCheck.IsDeny() {
+		return true
+	}
+	if r.IsDeny() {
+		return false
+	}
+
 	if r.DeepEqual(ruleToCheck) {
 		return true
 	}
 
+	// A rule with a schedule only includes rules that apply on the same schedule.
+	if r.Schedule != nil && !reflect.DeepEqual(r.Schedule, ruleToCheck.Schedule) {
+		return false
+	}
+	// A rule with a maximum session duration only includes rules with the same or a
+	// shorter one.
+	if max := r.GetMaxSessionDuration(); max != 0 {
+		if dur := ruleToCheck.GetMaxSessionDuration(); dur == 0 || dur > max {
+			return false
+		}
+	}
+	// A rule with conditions only includes rules with at least the same conditions.
+	for _, cond := range r.Conditions {
+		if !hasCondition(ruleToCheck.Conditions, cond) {
+			return false
+		}
+	}
+
 	for _, verb := range ruleToCheck.Verbs {
 		if !r.HasVerb(verb) {
 			return false
@@ -65,6 +118,25 @@ func RuleIncludes(r, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGette
 			return false
 		}
 	}
+	// A namespace selector may match namespaces labeled in the future, so it is only included
+	// by a rule for all namespaces or with the same selector.
+	if ruleToCheck.NamespaceSelector != nil && !r.HasNamespace(rbacv1.NamespaceAll) &&
+		!reflect.DeepEqual(r.NamespaceSelector, ruleToCheck.NamespaceSelector) {
+		return false
+	}
+	// A rule restricted to teams only includes rules restricted to a subset of those teams.
+	// Descendant teams are not considered, so this may deny rules that would technically
+	// be included.
+	if len(r.Teams) > 0 {
+		if len(ruleToCheck.Teams) == 0 {
+			return false
+		}
+		for _, team := range ruleToCheck.Teams {
+			if !r.HasTeam(team) {
+				return false
+			}
+		}
+	}
 	for _, resource := range ruleToCheck.Resources {
 		if !r.HasResourceType(resource) {
 			return false
@@ -114,3 +186,138 @@ func RuleIncludes(r, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGette
 	}
 	return true
 }
+
+// DenyOverlapsRule returns true if the given deny rule would deny any of the actions allowed
+// by ruleToCheck. As with RuleIncludes, resource patterns are compared against the resources
+// that currently exist. When that is not possible the rules are assumed to overlap.
+func DenyOverlapsRule(deny, ruleToCheck rbacv1.Rule, resourceGetter types.ResourceGetter) bool {
+	if !deny.IsDeny() || ruleToCheck.IsDeny() {
+		return false
+	}
+
+	var verbOverlaps bool
+	for _, verb := range ruleToCheck.Verbs {
+		if verb == rbacv1.VerbAll || deny.HasVerb(verb) {
+			verbOverlaps = true
+			break
+		}
+	}
+	if !verbOverlaps {
+		return false
+	}
+
+	// A deny rule with namespaces only matches actions in one of them, and an allow
+	// rule without namespaces never allows those. The labels of namespaces may change,
+	// so namespace selectors are assumed to overlap any namespace.
+	if deny.HasNamespaceRestriction() {
+		nsOverlaps := ruleToCheck.NamespaceSelector != nil
+		for _, ns := range ruleToCheck.Namespaces {
+			if ns == rbacv1.NamespaceAll || deny.HasNamespace(ns) || deny.NamespaceSelector != nil {
+				nsOverlaps = true
+				break
+			}
+		}
+		if !nsOverlaps {
+			return false
+		}
+	}
+
+	resources := make([]rbacv1.Resource, 0)
+	for _, resource := range ruleToCheck.Resources {
+		if resource == rbacv1.ResourceAll || deny.HasResourceType(resource) {
+			resources = append(resources, resource)
+		}
+	}
+	if len(resources) == 0 {
+		return false
+	}
+
+	// A deny rule without patterns or teams denies the resource types outright.
+	if len(deny.ResourcePatterns) == 0 && len(deny.Teams) == 0 {
+		return true
+	}
+	// Otherwise it only matches named resources, which the allow rule must also name.
+	if len(ruleToCheck.ResourcePatterns) == 0 && len(ruleToCheck.Teams) == 0 {
+		return false
+	}
+	// Team membership of resources is not known here.
+	if len(deny.Teams) > 0 || len(ruleToCheck.Teams) > 0 {
+		return true
+	}
+
+	for _, resource := range resources {
+		switch resource {
+		case rbacv1.ResourceRoles:
+			if namesOverlap(deny, ruleToCheck, roleNames(resourceGetter)) {
+				return true
+			}
+		case rbacv1.ResourceUsers:
+			if namesOverlap(deny, ruleToCheck, userNames(resourceGetter)) {
+				return true
+			}
+		case rbacv1.ResourceTemplates:
+			if namesOverlap(deny, ruleToCheck, templateNames(resourceGetter)) {
+				return true
+			}
+		default:
+			// Service accounts cannot be listed, and ResourceAll covers them too.
+			return true
+		}
+	}
+	return false
+}
+
+// namesOverlap returns true if any of the given names are matched by both rules. A nil
+// slice means the names could not be retrieved, and is treated as an overlap.
+func namesOverlap(deny, rule rbacv1.Rule, names []string) bool {
+	if names == nil {
+		return true
+	}
+	for _, name := range names {
+		if deny.MatchesResourceName(name) && rule.MatchesResourceName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func roleNames(resourceGetter types.ResourceGetter) []string {
+	roles, err := resourceGetter.GetRoles()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.GetName()
+	}
+	return names
+}
+
+func userNames(resourceGetter types.ResourceGetter) []string {
+	users, err := resourceGetter.GetUsers()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.GetName()
+	}
+	return names
+}
+
+func templateNames(resourceGetter types.ResourceGetter) []string {
+	templates, err := resourceGetter.GetTemplates()
+	if err != nil {
+		return nil
+	}
+	return templates
+}
+
+func hasCondition(conditions []rbacv1.Condition, cond rbacv1.Condition) bool {
+	for _, c := range conditions {
+		if reflect.DeepEqual(c, cond) {
+			return true
+		}
+	}
+	return false
+}
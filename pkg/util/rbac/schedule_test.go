@@ -0,0 +1,110 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func TestRuleIsActive(t *testing.T) {
+	// Monday, 14:00 UTC, which is 09:00 in New York
+	monday := time.Date(2021, time.March, 1, 14, 0, 0, 0, time.UTC)
+
+	businessHours := &rbacv1.Schedule{
+		TimeZone: "America/New_York",
+		Windows: []rbacv1.AccessWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartTime: "08:00", EndTime: "17:00"},
+		},
+	}
+	badZone := &rbacv1.Schedule{
+		TimeZone: "Not/AZone",
+		Windows:  []rbacv1.AccessWindow{{}},
+	}
+
+	tc := []struct {
+		rule   rbacv1.Rule
+		at     time.Time
+		active bool
+	}{
+		{rbacv1.Rule{}, monday, true},
+		{rbacv1.Rule{Schedule: businessHours}, monday, true},
+		// 08:00 UTC is still 03:00 in New York
+		{rbacv1.Rule{Schedule: businessHours}, monday.Add(-6 * time.Hour), false},
+		{rbacv1.Rule{Schedule: businessHours}, monday.Add(8 * time.Hour), false},
+		{rbacv1.Rule{Schedule: businessHours}, monday.AddDate(0, 0, 5), false},
+		// misconfigured schedules never grant access
+		{rbacv1.Rule{Schedule: badZone}, monday, false},
+		{rbacv1.Rule{Effect: rbacv1.EffectDeny, Schedule: badZone}, monday, true},
+	}
+
+	for _, c := range tc {
+		if active := c.rule.IsActive(c.at); active != c.active {
+			t.Errorf("Expected rule to be active at %s: %v, got %v", c.at.Format(time.RFC3339), c.active, active)
+		}
+	}
+}
+
+func TestScheduledRules(t *testing.T) {
+	today := time.Now().Weekday().String()
+	tomorrow := time.Now().AddDate(0, 0, 1).Weekday().String()
+
+	scheduled := func(rule rbacv1.Rule, day string) rbacv1.Rule {
+		rule.Schedule = &rbacv1.Schedule{
+			TimeZone: time.Local.String(),
+			Windows:  []rbacv1.AccessWindow{{Days: []string{day}}},
+		}
+		return rule
+	}
+
+	role := &types.VDIUserRole{Name: "today", Rules: []rbacv1.Rule{scheduled(launchAllTemplates, today)}}
+	if !EvaluateRole(role, launchAction("ubuntu", "default")) {
+		t.Error("Expected launching to be allowed during the schedule")
+	}
+	role = &types.VDIUserRole{Name: "tomorrow", Rules: []rbacv1.Rule{scheduled(launchAllTemplates, tomorrow)}}
+	if EvaluateRole(role, launchAction("ubuntu", "default")) {
+		t.Error("Expected launching to be denied outside the schedule")
+	}
+
+	role = &types.VDIUserRole{Name: "exceptions", Rules: []rbacv1.Rule{
+		launchAllTemplates,
+		scheduled(denyAdminTemplates, tomorrow),
+	}}
+	if !EvaluateRole(role, launchAction("admin-tools", "default")) {
+		t.Error("Expected a deny rule outside its schedule to not deny anything")
+	}
+	role.Rules[1] = scheduled(denyAdminTemplates, today)
+	if EvaluateRole(role, launchAction("admin-tools", "default")) {
+		t.Error("Expected a deny rule during its schedule to deny the action")
+	}
+
+	getter := &fakeResourceGetter{templates: []string{"ubuntu"}}
+	if RuleIncludes(scheduled(launchAllTemplates, today), launchAllTemplates, getter) {
+		t.Error("Expected a scheduled rule to not include an unscheduled one")
+	}
+	if !RuleIncludes(launchAllTemplates, scheduled(launchAllTemplates, today), getter) {
+		t.Error("Expected an unscheduled rule to include a scheduled one")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+// MaxSessionDuration returns how long a session the user launches for the given action may
+// run, according to the rules that allow it. The most permissive of those rules applies, and
+// a rule that does not set a maximum lifts it entirely. Zero is returned when there is no
+// maximum, including when no rule allows the action.
+func MaxSessionDuration(u *types.VDIUser, action *types.APIAction) time.Duration {
+	var max time.Duration
+	for _, role := range u.Roles {
+		if roleDenies(role, action, u.Attributes) {
+			continue
+		}
+		for _, rule := range role.Rules {
+			if !evaluateRule(rule, action, u.Attributes) {
+				continue
+			}
+			dur := rule.GetMaxSessionDuration()
+			if dur == 0 {
+				return 0
+			}
+			if dur > max {
+				max = dur
+			}
+		}
+	}
+	return max
+}
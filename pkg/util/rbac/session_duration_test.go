@@ -0,0 +1,77 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+)
+
+func withMaxDuration(rule rbacv1.Rule, dur string) rbacv1.Rule {
+	rule.MaxSessionDuration = dur
+	return rule
+}
+
+func TestMaxSessionDuration(t *testing.T) {
+	ubuntuOnly := launchAllTemplates
+	ubuntuOnly.ResourcePatterns = []string{"^ubuntu$"}
+
+	tc := []struct {
+		rules    []rbacv1.Rule
+		template string
+		expected time.Duration
+	}{
+		{[]rbacv1.Rule{launchAllTemplates}, "ubuntu", 0},
+		{[]rbacv1.Rule{withMaxDuration(launchAllTemplates, "4h")}, "ubuntu", 4 * time.Hour},
+		// the most permissive matching rule applies
+		{[]rbacv1.Rule{withMaxDuration(launchAllTemplates, "4h"), withMaxDuration(ubuntuOnly, "8h")}, "ubuntu", 8 * time.Hour},
+		{[]rbacv1.Rule{withMaxDuration(launchAllTemplates, "4h"), withMaxDuration(ubuntuOnly, "8h")}, "debian", 4 * time.Hour},
+		// a matching rule without a maximum lifts it
+		{[]rbacv1.Rule{withMaxDuration(launchAllTemplates, "4h"), ubuntuOnly}, "ubuntu", 0},
+		// rules that don't allow the launch are ignored
+		{[]rbacv1.Rule{withMaxDuration(ubuntuOnly, "8h")}, "debian", 0},
+	}
+	for i, c := range tc {
+		user := &types.VDIUser{
+			Name:  "test",
+			Roles: []*types.VDIUserRole{{Name: "launchers", Rules: c.rules}},
+		}
+		if got := MaxSessionDuration(user, launchAction(c.template, "default")); got != c.expected {
+			t.Errorf("Case %d: expected a maximum of %s, got %s", i, c.expected, got)
+		}
+	}
+
+	// a rule with a maximum only includes rules with the same or a shorter one
+	getter := &fakeResourceGetter{templates: []string{"ubuntu"}}
+	if RuleIncludes(withMaxDuration(launchAllTemplates, "4h"), launchAllTemplates, getter) {
+		t.Error("Expected a limited rule to not include an unlimited one")
+	}
+	if RuleIncludes(withMaxDuration(launchAllTemplates, "4h"), withMaxDuration(launchAllTemplates, "8h"), getter) {
+		t.Error("Expected a limited rule to not include a longer one")
+	}
+	if !RuleIncludes(withMaxDuration(launchAllTemplates, "4h"), withMaxDuration(launchAllTemplates, "1h"), getter) {
+		t.Error("Expected a limited rule to include a shorter one")
+	}
+}
@@ -0,0 +1,138 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"sort"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+)
+
+// TeamTree resolves the parent/child relationships between VDITeams.
+type TeamTree struct {
+	teams map[string]*rbacv1.VDITeam
+}
+
+// NewTeamTree returns a TeamTree for the given teams.
+func NewTeamTree(teams []rbacv1.VDITeam) *TeamTree {
+	tree := &TeamTree{teams: make(map[string]*rbacv1.VDITeam, len(teams))}
+	for i := range teams {
+		tree.teams[teams[i].GetName()] = &teams[i]
+	}
+	return tree
+}
+
+// Ancestors returns the given team followed by each of its ancestors. Unknown parents
+// end the chain, and cycles are only traversed once.
+func (t *TeamTree) Ancestors(name string) []string {
+	out := make([]string, 0)
+	seen := make(map[string]struct{})
+	for name != "" {
+		if _, ok := seen[name]; ok {
+			break
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+		team, ok := t.teams[name]
+		if !ok {
+			break
+		}
+		name = team.GetParent()
+	}
+	return out
+}
+
+// UserTeams returns the teams the given user is a member of, along with all of
+// their ancestors.
+func (t *TeamTree) UserTeams(username string) []string {
+	return t.expand(func(team *rbacv1.VDITeam) bool { return team.HasMember(username) })
+}
+
+// RoleTeams returns the teams the given role is bound to, along with all of their
+// ancestors.
+func (t *TeamTree) RoleTeams(role string) []string {
+	return t.expand(func(team *rbacv1.VDITeam) bool { return team.HasRole(role) })
+}
+
+// UserRoles returns the names of the roles the given user is granted through the
+// teams they are a member of and the ancestors of those teams.
+func (t *TeamTree) UserRoles(username string) []string {
+	roles := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, name := range t.UserTeams(username) {
+		team, ok := t.teams[name]
+		if !ok {
+			continue
+		}
+		for _, role := range team.GetRoles() {
+			if _, ok := seen[role]; ok {
+				continue
+			}
+			seen[role] = struct{}{}
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// TeamMembers returns the members of the given team and of all of its descendants,
+// sorted and without duplicates.
+func (t *TeamTree) TeamMembers(name string) []string {
+	seen := make(map[string]struct{})
+	for teamName, team := range t.teams {
+		for _, ancestor := range t.Ancestors(teamName) {
+			if ancestor != name {
+				continue
+			}
+			for _, member := range team.GetMembers() {
+				seen[member] = struct{}{}
+			}
+			break
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for member := range seen {
+		out = append(out, member)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// expand returns the teams matching the given function along with all of their
+// ancestors, sorted and without duplicates.
+func (t *TeamTree) expand(match func(*rbacv1.VDITeam) bool) []string {
+	seen := make(map[string]struct{})
+	for name, team := range t.teams {
+		if !match(team) {
+			continue
+		}
+		for _, ancestor := range t.Ancestors(name) {
+			seen[ancestor] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
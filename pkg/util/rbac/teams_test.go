@@ -0,0 +1,114 @@
+/*
+
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "github.com/tinyzimmer/kvdi/apis/rbac/v1"
+	"github.com/tinyzimmer/kvdi/pkg/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestTeam(name, parent string, members, roles []string) rbacv1.VDITeam {
+	return rbacv1.VDITeam{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: rbacv1.VDITeamSpec{
+			Parent:  parent,
+			Members: members,
+			Roles:   roles,
+		},
+	}
+}
+
+func newTestTree() *TeamTree {
+	return NewTeamTree([]rbacv1.VDITeam{
+		newTestTeam("engineering", "", nil, []string{"engineers"}),
+		newTestTeam("platform", "engineering", []string{"alice"}, []string{"platform-admins"}),
+		newTestTeam("sre", "platform", []string{"bob"}, nil),
+		newTestTeam("sales", "", []string{"carol"}, []string{"sales"}),
+	})
+}
+
+func TestTeamAncestors(t *testing.T) {
+	tree := newTestTree()
+	if ancestors := tree.Ancestors("sre"); !reflect.DeepEqual(ancestors, []string{"sre", "platform", "engineering"}) {
+		t.Error("Unexpected ancestors for sre:", ancestors)
+	}
+
+	// cycles should not loop forever
+	cyclic := NewTeamTree([]rbacv1.VDITeam{
+		newTestTeam("a", "b", nil, nil),
+		newTestTeam("b", "a", nil, nil),
+	})
+	if ancestors := cyclic.Ancestors("a"); !reflect.DeepEqual(ancestors, []string{"a", "b"}) {
+		t.Error("Unexpected ancestors for cyclic team:", ancestors)
+	}
+}
+
+func TestTeamUserRoles(t *testing.T) {
+	tree := newTestTree()
+	if teams := tree.UserTeams("bob"); !reflect.DeepEqual(teams, []string{"engineering", "platform", "sre"}) {
+		t.Error("Unexpected teams for bob:", teams)
+	}
+	if roles := tree.UserRoles("bob"); !reflect.DeepEqual(roles, []string{"engineers", "platform-admins"}) {
+		t.Error("Unexpected roles for bob:", roles)
+	}
+	if roles := tree.UserRoles("dave"); len(roles) != 0 {
+		t.Error("Expected no roles for a user without teams, got:", roles)
+	}
+}
+
+func TestEvaluateTeamRule(t *testing.T) {
+	tree := newTestTree()
+	rule := rbacv1.Rule{
+		Verbs:     []rbacv1.Verb{rbacv1.VerbUpdate},
+		Resources: []rbacv1.Resource{rbacv1.ResourceUsers},
+		Teams:     []string{"platform"},
+	}
+	for user, expected := range map[string]bool{"alice": true, "bob": true, "carol": false} {
+		action := &types.APIAction{
+			Verb:          rbacv1.VerbUpdate,
+			ResourceType:  rbacv1.ResourceUsers,
+			ResourceName:  user,
+			ResourceTeams: tree.UserTeams(user),
+		}
+		if allowed := EvaluateRule(rule, action); allowed != expected {
+			t.Errorf("Expected evaluation of %s to be %v, got %v", user, expected, allowed)
+		}
+	}
+}
+
+func TestTeamMembers(t *testing.T) {
+	tree := newTestTree()
+	if members := tree.TeamMembers("engineering"); !reflect.DeepEqual(members, []string{"alice", "bob"}) {
+		t.Error("Unexpected members for engineering:", members)
+	}
+	if members := tree.TeamMembers("sre"); !reflect.DeepEqual(members, []string{"bob"}) {
+		t.Error("Unexpected members for sre:", members)
+	}
+	if members := tree.TeamMembers("marketing"); len(members) != 0 {
+		t.Error("Expected no members for an unknown team, got:", members)
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 	kappsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	krbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,5 +50,6 @@ func getFakeClient(t *testing.T) client.Client {
 	corev1.AddToScheme(scheme)
 	kappsv1.AddToScheme(scheme)
 	krbacv1.AddToScheme(scheme)
+	networkingv1.AddToScheme(scheme)
 	return fake.NewFakeClientWithScheme(scheme)
 }
@@ -0,0 +1,64 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package reconcile
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NetworkPolicy reconciles a provided network policy with the cluster.
+func NetworkPolicy(ctx context.Context, reqLogger logr.Logger, c client.Client, policy *networkingv1.NetworkPolicy) error {
+	if err := k8sutil.SetCreationSpecAnnotation(&policy.ObjectMeta, policy); err != nil {
+		return err
+	}
+	found := &networkingv1.NetworkPolicy{}
+	if err := c.Get(ctx, types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found); err != nil {
+		// Return API error
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		// Create the network policy
+		reqLogger.Info("Creating new NetworkPolicy", "NetworkPolicy.Name", policy.Name, "NetworkPolicy.Namespace", policy.Namespace)
+		if err := c.Create(ctx, policy); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Check the found network policy spec
+	if !k8sutil.CreationSpecsEqual(policy.ObjectMeta, found.ObjectMeta) {
+		// We need to update the network policy
+		reqLogger.Info("NetworkPolicy annotation spec has changed, updating", "NetworkPolicy.Name", policy.Name, "NetworkPolicy.Namespace", policy.Namespace)
+		found.Spec = policy.Spec
+		found.SetAnnotations(policy.GetAnnotations())
+		if err := c.Update(ctx, found); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
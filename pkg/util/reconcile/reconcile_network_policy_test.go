@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFakeNetworkPolicy() *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-policy",
+			Namespace: "fake-namespace",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func TestReconcileNetworkPolicy(t *testing.T) {
+	c := getFakeClient(t)
+	if err := NetworkPolicy(context.TODO(), testLogger, c, newFakeNetworkPolicy()); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	// should be idempotent
+	if err := NetworkPolicy(context.TODO(), testLogger, c, newFakeNetworkPolicy()); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+
+	// a changed spec should be updated in place
+	policy := newFakeNetworkPolicy()
+	policy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+	if err := NetworkPolicy(context.TODO(), testLogger, c, policy); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	found := &networkingv1.NetworkPolicy{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "fake-policy", Namespace: "fake-namespace"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Spec.Ingress) != 1 {
+		t.Error("Expected the network policy to be updated, got:", found.Spec)
+	}
+}
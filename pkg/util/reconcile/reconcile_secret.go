@@ -0,0 +1,59 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package reconcile
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Secret reconciles a provided secret with the cluster.
+func Secret(ctx context.Context, reqLogger logr.Logger, c client.Client, secret *corev1.Secret) error {
+	if err := k8sutil.SetCreationSpecAnnotation(&secret.ObjectMeta, secret); err != nil {
+		return err
+	}
+	found := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found); err != nil {
+		// Return API error
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		// Create the secret
+		reqLogger.Info("Creating new Secret", "Secret.Name", secret.Name, "Secret.Namespace", secret.Namespace)
+		return c.Create(ctx, secret)
+	}
+
+	// Check the found secret spec
+	if !k8sutil.CreationSpecsEqual(secret.ObjectMeta, found.ObjectMeta) {
+		// We need to update the secret
+		reqLogger.Info("Secret annotation spec has changed, updating", "Secret.Name", secret.Name, "Secret.Namespace", secret.Namespace)
+		found.Data = secret.Data
+		found.SetAnnotations(secret.GetAnnotations())
+		return c.Update(ctx, found)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFakeSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake-namespace",
+		},
+		Data: map[string][]byte{"username": []byte("fake-user")},
+	}
+}
+
+func TestReconcileSecret(t *testing.T) {
+	c := getFakeClient(t)
+	secret := newFakeSecret()
+	if err := Secret(context.TODO(), testLogger, c, secret); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	// should be idempotent
+	secret = newFakeSecret()
+	if err := Secret(context.TODO(), testLogger, c, secret); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+
+	// changed data should be written to the existing secret
+	secret = newFakeSecret()
+	secret.Data["username"] = []byte("new-user")
+	if err := Secret(context.TODO(), testLogger, c, secret); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	found := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "fake-secret", Namespace: "fake-namespace"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if string(found.Data["username"]) != "new-user" {
+		t.Error("Expected secret data to be updated, got:", string(found.Data["username"]))
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package replay
+
+import "sync"
+
+// Buffer records the bytes written to a stream and keeps the most recent of them. Positions
+// in the stream are absolute offsets from the first byte ever written. It is safe for
+// concurrent use.
+type Buffer struct {
+	mu      sync.Mutex
+	data    []byte
+	written int64
+}
+
+// New returns a new buffer that keeps up to size bytes.
+func New(size int) *Buffer {
+	return &Buffer{data: make([]byte, size)}
+}
+
+// Write implements a Writer. Writes never fail, the oldest bytes are dropped once the buffer
+// is full.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	size := len(b.data)
+	if size == 0 {
+		b.written += int64(n)
+		return n, nil
+	}
+	if len(p) > size {
+		// only the tail of p will fit
+		b.written += int64(len(p) - size)
+		p = p[len(p)-size:]
+	}
+	pos := int(b.written % int64(size))
+	copied := copy(b.data[pos:], p)
+	copy(b.data, p[copied:])
+	b.written += int64(len(p))
+	return n, nil
+}
+
+// Offset returns the total number of bytes written to the stream.
+func (b *Buffer) Offset() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written
+}
+
+// Since returns a copy of the bytes written after the given offset. False is returned if
+// the offset is past the end of the stream, or the bytes after it are no longer buffered.
+func (b *Buffer) Since(offset int64) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	size := int64(len(b.data))
+	if offset < 0 || offset > b.written || b.written-offset > size {
+		return nil, false
+	}
+	out := make([]byte, b.written-offset)
+	if len(out) == 0 {
+		return out, true
+	}
+	pos := int(offset % size)
+	copied := copy(out, b.data[pos:])
+	copy(out[copied:], b.data)
+	return out, true
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package replay
+
+import (
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	buf := New(8)
+
+	if out, ok := buf.Since(0); !ok || len(out) != 0 {
+		t.Error("Expected an empty replay from the start of an empty stream")
+	}
+
+	buf.Write([]byte("hello"))
+	if out, ok := buf.Since(0); !ok || string(out) != "hello" {
+		t.Error("Expected 'hello', got:", string(out))
+	}
+	if out, ok := buf.Since(3); !ok || string(out) != "lo" {
+		t.Error("Expected 'lo', got:", string(out))
+	}
+
+	// wraps around the end of the buffer
+	buf.Write([]byte(" world"))
+	if offset := buf.Offset(); offset != 11 {
+		t.Error("Expected offset 11, got:", offset)
+	}
+	if out, ok := buf.Since(3); !ok || string(out) != "lo world" {
+		t.Error("Expected 'lo world', got:", string(out))
+	}
+	if _, ok := buf.Since(2); ok {
+		t.Error("Expected bytes no longer in the buffer to not be replayable")
+	}
+	if _, ok := buf.Since(12); ok {
+		t.Error("Expected an offset past the end of the stream to not be replayable")
+	}
+
+	// writes larger than the buffer keep their tail
+	buf.Write([]byte("0123456789"))
+	if offset := buf.Offset(); offset != 21 {
+		t.Error("Expected offset 21, got:", offset)
+	}
+	if out, ok := buf.Since(13); !ok || string(out) != "23456789" {
+		t.Error("Expected '23456789', got:", string(out))
+	}
+}
+
+func TestZeroSizeBuffer(t *testing.T) {
+	buf := New(0)
+	buf.Write([]byte("test"))
+	if out, ok := buf.Since(4); !ok || len(out) != 0 {
+		t.Error("Expected an empty replay from the end of the stream")
+	}
+	if _, ok := buf.Since(0); ok {
+		t.Error("Expected nothing to be replayable")
+	}
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encodings and pseudo-encodings followed by ServerCutTextFilter.
+const (
+	encodingCopyRect            int32 = 1
+	encodingRRE                 int32 = 2
+	encodingHextile             int32 = 5
+	encodingZRLE                int32 = 16
+	encodingDesktopSize         int32 = -223
+	encodingLastRect            int32 = -224
+	encodingCursor              int32 = -239
+	encodingXCursor             int32 = -240
+	encodingQEMUPointerMotion   int32 = -257
+	encodingQEMUExtendedKey     int32 = -258
+	encodingDesktopName         int32 = -307
+	encodingExtendedDesktopSize int32 = -308
+	encodingXVP                 int32 = -309
+	encodingFence               int32 = -312
+	encodingContinuousUpdates   int32 = -313
+)
+
+// followedEncodings are the encodings whose rectangles can be skipped over without
+// decoding them, and the pseudo-encodings that only announce support for messages the
+// filter understands.
+var followedEncodings = map[int32]bool{
+	EncodingRaw:                 true,
+	encodingCopyRect:            true,
+	encodingRRE:                 true,
+	encodingHextile:             true,
+	encodingZRLE:                true,
+	encodingDesktopSize:         true,
+	encodingLastRect:            true,
+	encodingCursor:              true,
+	encodingXCursor:             true,
+	encodingQEMUPointerMotion:   true,
+	encodingQEMUExtendedKey:     true,
+	encodingDesktopName:         true,
+	encodingExtendedDesktopSize: true,
+	encodingXVP:                 true,
+	encodingFence:               true,
+	encodingContinuousUpdates:   true,
+}
+
+// Message types sent by display servers for protocol extensions.
+const (
+	endOfContinuousUpdates byte = 150
+	serverFence            byte = 248
+	serverXVP              byte = 250
+)
+
+// ServerCutTextFilter follows both directions of a brokered display stream to drop the
+// clipboard updates sent by the display server. The length of a framebuffer update
+// depends on the pixel format and encodings chosen by the client, so the client's
+// messages must be copied through FilterClient, which limits the encodings it may
+// choose to those the filter can skip over without decoding them.
+type ServerCutTextFilter struct {
+	mu            sync.Mutex
+	bytesPerPixel int
+}
+
+// NewServerCutTextFilter returns a new filter for a single display stream.
+func NewServerCutTextFilter() *ServerCutTextFilter {
+	return &ServerCutTextFilter{bytesPerPixel: 4}
+}
+
+// FilterClient copies client to server messages from src to dst like
+// FilterClientMessages, following the pixel format set by the client and removing the
+// encodings the filter can't follow from the ones it requests.
+func (f *ServerCutTextFilter) FilterClient(dst io.Writer, src io.Reader, allowCutText bool) (written int64, err error) {
+	return filterClientMessages(dst, src, allowCutText, f)
+}
+
+// FilterServer copies server to client messages from src to dst, dropping clipboard
+// updates. The stream must begin with the ServerInit message. Since the length of
+// unknown messages and encodings cannot be determined, the copy ends with an error if
+// one is received.
+func (f *ServerCutTextFilter) FilterServer(dst io.Writer, src io.Reader) (written int64, err error) {
+	s := &serverStream{r: bufio.NewReaderSize(src, 64*1024), dst: dst}
+
+	init, err := s.read(nil, 24)
+	if err != nil {
+		return s.written, err
+	}
+	f.setBitsPerPixel(init[4])
+	if err := s.write(init); err != nil {
+		return s.written, err
+	}
+	if err := s.pass(int64(binary.BigEndian.Uint32(init[20:24]))); err != nil {
+		return s.written, err
+	}
+
+	for {
+		msgType, err := s.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return s.written, nil
+			}
+			return s.written, err
+		}
+		switch msgType {
+		case FramebufferUpdate:
+			err = f.passUpdate(s)
+		case SetColourMapEntries:
+			err = s.passHeader(msgType, 5, func(h []byte) int64 { return int64(binary.BigEndian.Uint16(h[3:5])) * 6 })
+		case Bell, endOfContinuousUpdates:
+			err = s.write([]byte{msgType})
+		case ServerCutText:
+			var header []byte
+			if header, err = s.read(nil, 7); err == nil {
+				// a negative length is used by the extended clipboard format
+				length := int64(int32(binary.BigEndian.Uint32(header[3:7])))
+				if length < 0 {
+					length = -length
+				}
+				_, err = io.CopyN(io.Discard, s.r, length)
+			}
+		case serverFence:
+			err = s.passHeader(msgType, 8, func(h []byte) int64 { return int64(h[7]) })
+		case serverXVP:
+			err = s.passHeader(msgType, 3, func([]byte) int64 { return 0 })
+		default:
+			err = fmt.Errorf("Display server sent unsupported message type %d", msgType)
+		}
+		if err != nil {
+			return s.written, err
+		}
+	}
+}
+
+// passUpdate copies a framebuffer update, after its message type, to the client.
+func (f *ServerCutTextFilter) passUpdate(s *serverStream) error {
+	header, err := s.read([]byte{FramebufferUpdate}, 3)
+	if err != nil {
+		return err
+	}
+	if err := s.write(header); err != nil {
+		return err
+	}
+	numRects := int(binary.BigEndian.Uint16(header[2:4]))
+	bpp := int64(f.getBytesPerPixel())
+	for i := 0; i < numRects; i++ {
+		rect, err := s.read(nil, 12)
+		if err != nil {
+			return err
+		}
+		if err := s.write(rect); err != nil {
+			return err
+		}
+		w := int64(binary.BigEndian.Uint16(rect[4:6]))
+		h := int64(binary.BigEndian.Uint16(rect[6:8]))
+		switch encoding := int32(binary.BigEndian.Uint32(rect[8:12])); encoding {
+		case EncodingRaw:
+			err = s.pass(w * h * bpp)
+		case encodingCopyRect:
+			err = s.pass(4)
+		case encodingRRE:
+			err = s.passPrefixed(4+bpp, func(b []byte) int64 { return int64(binary.BigEndian.Uint32(b[0:4])) * (bpp + 8) })
+		case encodingHextile:
+			err = s.passHextile(int(w), int(h), int(bpp))
+		case encodingZRLE:
+			err = s.passPrefixed(4, func(b []byte) int64 { return int64(binary.BigEndian.Uint32(b)) })
+		case encodingCursor:
+			err = s.pass(w*h*bpp + (w+7)/8*h)
+		case encodingXCursor:
+			if w*h > 0 {
+				err = s.pass(6 + (w+7)/8*h*2)
+			}
+		case encodingDesktopName:
+			err = s.passPrefixed(4, func(b []byte) int64 { return int64(binary.BigEndian.Uint32(b)) })
+		case encodingExtendedDesktopSize:
+			err = s.passPrefixed(4, func(b []byte) int64 { return int64(b[0]) * 16 })
+		case encodingDesktopSize:
+		case encodingLastRect:
+			return nil
+		default:
+			err = fmt.Errorf("Display server sent an update with unsupported encoding %d", encoding)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBitsPerPixel records the pixel format used in framebuffer updates.
+func (f *ServerCutTextFilter) setBitsPerPixel(bits byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if bits >= 8 {
+		f.bytesPerPixel = int(bits) / 8
+	}
+}
+
+// getBytesPerPixel returns the size of a pixel in framebuffer updates.
+func (f *ServerCutTextFilter) getBytesPerPixel() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bytesPerPixel
+}
+
+// filterEncodings returns the encodings in a SetEncodings payload that the filter can
+// follow.
+func (f *ServerCutTextFilter) filterEncodings(payload []byte) []byte {
+	out := make([]byte, 0, len(payload))
+	for i := 0; i+4 <= len(payload); i += 4 {
+		if followedEncodings[int32(binary.BigEndian.Uint32(payload[i:i+4]))] {
+			out = append(out, payload[i:i+4]...)
+		}
+	}
+	return out
+}
+
+// serverStream reads messages from a display server and writes what is kept to the
+// client.
+type serverStream struct {
+	r       *bufio.Reader
+	dst     io.Writer
+	written int64
+}
+
+// read reads n bytes from the server and returns them appended to buf.
+func (s *serverStream) read(buf []byte, n int) ([]byte, error) {
+	start := len(buf)
+	buf = append(buf, make([]byte, n)...)
+	_, err := io.ReadFull(s.r, buf[start:])
+	return buf, err
+}
+
+// write writes the given bytes to the client.
+func (s *serverStream) write(b []byte) error {
+	n, err := s.dst.Write(b)
+	s.written += int64(n)
+	return err
+}
+
+// pass copies n bytes from the server to the client.
+func (s *serverStream) pass(n int64) error {
+	c, err := io.CopyN(s.dst, s.r, n)
+	s.written += c
+	return err
+}
+
+// passHeader copies a message with a fixed header of the given size, after its type,
+// and a payload whose length is read from the header.
+func (s *serverStream) passHeader(msgType byte, size int, payloadLength func(header []byte) int64) error {
+	header, err := s.read([]byte{msgType}, size)
+	if err != nil {
+		return err
+	}
+	if err := s.write(header); err != nil {
+		return err
+	}
+	return s.pass(payloadLength(header[1:]))
+}
+
+// passPrefixed copies a prefix of the given size and the data whose length is read
+// from it.
+func (s *serverStream) passPrefixed(size int64, dataLength func(prefix []byte) int64) error {
+	prefix, err := s.read(nil, int(size))
+	if err != nil {
+		return err
+	}
+	if err := s.write(prefix); err != nil {
+		return err
+	}
+	return s.pass(dataLength(prefix))
+}
+
+// passHextile copies a hextile encoded rectangle of the given dimensions. The tiles are
+// collected and written at once to avoid a write for every tile.
+func (s *serverStream) passHextile(w, h, bpp int) error {
+	var out []byte
+	var err error
+	for ty := 0; ty < h; ty += 16 {
+		th := minInt(16, h-ty)
+		for tx := 0; tx < w; tx += 16 {
+			tw := minInt(16, w-tx)
+			if out, err = s.read(out, 1); err != nil {
+				return err
+			}
+			sub := out[len(out)-1]
+			if sub&1 != 0 {
+				// raw tile
+				if out, err = s.read(out, tw*th*bpp); err != nil {
+					return err
+				}
+				continue
+			}
+			var colours int
+			if sub&2 != 0 {
+				colours += bpp
+			}
+			if sub&4 != 0 {
+				colours += bpp
+			}
+			if out, err = s.read(out, colours); err != nil {
+				return err
+			}
+			if sub&8 == 0 {
+				continue
+			}
+			if out, err = s.read(out, 1); err != nil {
+				return err
+			}
+			count := int(out[len(out)-1])
+			size := 2
+			if sub&16 != 0 {
+				size += bpp
+			}
+			if out, err = s.read(out, count*size); err != nil {
+				return err
+			}
+		}
+	}
+	return s.write(out)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
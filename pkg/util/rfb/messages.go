@@ -0,0 +1,245 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ClientCutText is the message type used by clients to send clipboard contents to the
+// server.
+const ClientCutText byte = 6
+
+// clientMessageSizes maps the client message types understood by FilterClientMessages
+// to the size of their fixed portion, including the message type.
+var clientMessageSizes = map[byte]int{
+	0:   20, // SetPixelFormat
+	2:   4,  // SetEncodings
+	3:   10, // FramebufferUpdateRequest
+	4:   8,  // KeyEvent
+	5:   6,  // PointerEvent
+	6:   8,  // ClientCutText
+	150: 10, // EnableContinuousUpdates
+	248: 9,  // ClientFence
+	250: 4,  // xvp
+	251: 8,  // SetDesktopSize
+	255: 12, // QEMU extended key event
+}
+
+// FilterClientMessages copies client to server messages from src to dst, dropping
+// clipboard updates when allowCutText is false. The stream must begin after the
+// ClientInit message. Since the length of unknown messages cannot be determined, the
+// copy ends with an error if one is received.
+func FilterClientMessages(dst io.Writer, src io.Reader, allowCutText bool) (written int64, err error) {
+	return filterClientMessages(dst, src, allowCutText, nil)
+}
+
+// filterClientMessages implements FilterClientMessages. When a ServerCutTextFilter is
+// given, it is kept up to date with the pixel format and encodings set by the client.
+func filterClientMessages(dst io.Writer, src io.Reader, allowCutText bool, f *ServerCutTextFilter) (written int64, err error) {
+	r := bufio.NewReader(src)
+	for {
+		msgType, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return written, nil
+			}
+			return written, err
+		}
+		size, ok := clientMessageSizes[msgType]
+		if !ok {
+			return written, fmt.Errorf("Client sent unsupported message type %d", msgType)
+		}
+		header := make([]byte, size)
+		header[0] = msgType
+		if _, err := io.ReadFull(r, header[1:]); err != nil {
+			return written, err
+		}
+		if msgType == 255 && header[1] != 0 {
+			return written, fmt.Errorf("Client sent unsupported QEMU message type %d", header[1])
+		}
+		payload := clientPayloadLength(header)
+
+		if f != nil && msgType == 0 {
+			f.setBitsPerPixel(header[4])
+		}
+		if f != nil && msgType == 2 {
+			encodings := make([]byte, payload)
+			if _, err := io.ReadFull(r, encodings); err != nil {
+				return written, err
+			}
+			encodings = f.filterEncodings(encodings)
+			binary.BigEndian.PutUint16(header[2:4], uint16(len(encodings)/4))
+			n, err := dst.Write(append(header, encodings...))
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		if msgType == ClientCutText && !allowCutText {
+			if _, err := io.CopyN(io.Discard, r, payload); err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		n, err := dst.Write(header)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		c, err := io.CopyN(dst, r, payload)
+		written += c
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// isInputEvent returns true for the client message types generated by user input.
+func isInputEvent(msgType byte) bool {
+	return msgType == 4 || msgType == 5 || msgType == 255
+}
+
+// InputWatcher follows a stream of client to server messages written to it, beginning
+// with the ClientInit message, and calls a function for every key or pointer event. It
+// never fails a write, so it may observe a copy through io.TeeReader. If a message it
+// does not understand is received, every later write is treated as input.
+type InputWatcher struct {
+	onInput func()
+	header  []byte
+	skip    int64
+	started bool
+	lost    bool
+}
+
+// NewInputWatcher returns a new InputWatcher that calls onInput for every input event.
+func NewInputWatcher(onInput func()) *InputWatcher {
+	return &InputWatcher{onInput: onInput}
+}
+
+// Write implements io.Writer.
+func (w *InputWatcher) Write(p []byte) (int, error) {
+	n := len(p)
+	if !w.started && len(p) > 0 {
+		// skip the ClientInit
+		p = p[1:]
+		w.started = true
+	}
+	for len(p) > 0 {
+		if w.lost {
+			w.onInput()
+			return n, nil
+		}
+		if w.skip > 0 {
+			k := int64(len(p))
+			if k > w.skip {
+				k = w.skip
+			}
+			p = p[k:]
+			w.skip -= k
+			continue
+		}
+		size, ok := clientMessageSizes[p[0]]
+		if len(w.header) > 0 {
+			size = clientMessageSizes[w.header[0]]
+		} else if !ok {
+			w.lost = true
+			continue
+		}
+		k := size - len(w.header)
+		if k > len(p) {
+			k = len(p)
+		}
+		w.header = append(w.header, p[:k]...)
+		p = p[k:]
+		if len(w.header) < size {
+			break
+		}
+		if w.header[0] == 255 && w.header[1] != 0 {
+			w.lost = true
+			continue
+		}
+		if isInputEvent(w.header[0]) {
+			w.onInput()
+		}
+		w.skip = clientPayloadLength(w.header)
+		w.header = w.header[:0]
+	}
+	return n, nil
+}
+
+// clientPayloadLength returns the length of the variable portion following the fixed
+// header of a client message.
+func clientPayloadLength(header []byte) int64 {
+	switch header[0] {
+	case 2:
+		return int64(binary.BigEndian.Uint16(header[2:4])) * 4
+	case ClientCutText:
+		// a negative length is used by the extended clipboard format
+		length := int64(int32(binary.BigEndian.Uint32(header[4:8])))
+		if length < 0 {
+			return -length
+		}
+		return length
+	case 248:
+		return int64(header[8])
+	case 251:
+		return int64(header[6]) * 16
+	}
+	return 0
+}
+
+// WriteClientCutText writes a ClientCutText message containing the given text.
+func WriteClientCutText(w io.Writer, text string) error {
+	encoded := EncodeCutText(text)
+	msg := make([]byte, 8, 8+len(encoded))
+	msg[0] = ClientCutText
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(encoded)))
+	return write(w, append(msg, encoded...))
+}
+
+// EncodeCutText converts text to the ISO 8859-1 encoding used in cut text messages.
+// Characters that cannot be represented are replaced with a question mark.
+func EncodeCutText(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// DecodeCutText converts ISO 8859-1 text received in a cut text message to UTF-8.
+func DecodeCutText(b []byte) string {
+	var out strings.Builder
+	for _, c := range b {
+		out.WriteRune(rune(c))
+	}
+	return out.String()
+}
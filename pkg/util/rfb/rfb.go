@@ -0,0 +1,238 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rfb
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Security types supported during the handshake.
+const (
+	SecurityNone    byte = 1
+	SecurityVNCAuth byte = 2
+)
+
+// ProtocolVersion38 is the version presented to clients by ServeNoAuth.
+const ProtocolVersion38 = "RFB 003.008\n"
+
+// Authenticate performs the client side of the protocol version and security handshakes
+// against the given server. When the server offers no authentication it is preferred,
+// otherwise the password is used for VNC authentication. On success the connection is
+// ready for a ClientInit message.
+func Authenticate(server io.ReadWriter, password string) error {
+	minor, err := negotiateVersion(server)
+	if err != nil {
+		return err
+	}
+
+	var secType byte
+	if minor < 7 {
+		// 3.3 servers decide the security type
+		var st uint32
+		if err := binary.Read(server, binary.BigEndian, &st); err != nil {
+			return err
+		}
+		if st == 0 {
+			return readReason(server)
+		}
+		secType = byte(st)
+	} else {
+		count := make([]byte, 1)
+		if _, err := io.ReadFull(server, count); err != nil {
+			return err
+		}
+		if count[0] == 0 {
+			return readReason(server)
+		}
+		offered := make([]byte, count[0])
+		if _, err := io.ReadFull(server, offered); err != nil {
+			return err
+		}
+		switch {
+		case bytes.IndexByte(offered, SecurityNone) != -1:
+			secType = SecurityNone
+		case bytes.IndexByte(offered, SecurityVNCAuth) != -1:
+			secType = SecurityVNCAuth
+		default:
+			return fmt.Errorf("Display server offered no supported security types: %v", offered)
+		}
+		if err := write(server, []byte{secType}); err != nil {
+			return err
+		}
+	}
+
+	switch secType {
+	case SecurityNone:
+		// Only 3.8 sends a security result for connections without authentication
+		if minor < 8 {
+			return nil
+		}
+	case SecurityVNCAuth:
+		if password == "" {
+			return errors.New("Display server requires a password but none was configured")
+		}
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(server, challenge); err != nil {
+			return err
+		}
+		response, err := EncryptChallenge(password, challenge)
+		if err != nil {
+			return err
+		}
+		if err := write(server, response); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Display server requires unsupported security type %d", secType)
+	}
+
+	var result uint32
+	if err := binary.Read(server, binary.BigEndian, &result); err != nil {
+		return err
+	}
+	if result != 0 {
+		if minor < 8 {
+			return errors.New("Authentication with the display server failed")
+		}
+		return readReason(server)
+	}
+	return nil
+}
+
+// ServeNoAuth performs the server side of the protocol version and security handshakes
+// with the given client, offering only connections without authentication. On success
+// the client will follow with a ClientInit message.
+func ServeNoAuth(client io.ReadWriter) error {
+	if _, err := io.WriteString(client, ProtocolVersion38); err != nil {
+		return err
+	}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(client, version); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version), "RFB %03d.%03d\n", &major, &minor); err != nil {
+		return fmt.Errorf("Client sent an invalid protocol version: %q", version)
+	}
+
+	if minor < 7 {
+		return binary.Write(client, binary.BigEndian, uint32(SecurityNone))
+	}
+
+	if _, err := client.Write([]byte{1, SecurityNone}); err != nil {
+		return err
+	}
+	choice := make([]byte, 1)
+	if _, err := io.ReadFull(client, choice); err != nil {
+		return err
+	}
+	if choice[0] != SecurityNone {
+		return fmt.Errorf("Client selected unsupported security type %d", choice[0])
+	}
+	if minor < 8 {
+		return nil
+	}
+	return binary.Write(client, binary.BigEndian, uint32(0))
+}
+
+// EncryptChallenge encrypts a VNC authentication challenge with the given password. The
+// password is truncated or padded to eight bytes and, as the protocol requires, the bits
+// of each byte are reversed before being used as a DES key.
+func EncryptChallenge(password string, challenge []byte) ([]byte, error) {
+	if len(challenge)%des.BlockSize != 0 {
+		return nil, fmt.Errorf("Invalid challenge length %d", len(challenge))
+	}
+	key := make([]byte, 8)
+	copy(key, password)
+	for i, b := range key {
+		key[i] = reverseBits(b)
+	}
+	block, err := des.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(challenge))
+	for i := 0; i < len(challenge); i += des.BlockSize {
+		block.Encrypt(out[i:i+des.BlockSize], challenge[i:i+des.BlockSize])
+	}
+	return out, nil
+}
+
+// negotiateVersion reads the server's protocol version and replies with the highest
+// version supported by both sides, returning the chosen minor version.
+func negotiateVersion(server io.ReadWriter) (int, error) {
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(server, version); err != nil {
+		return 0, err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version), "RFB %03d.%03d\n", &major, &minor); err != nil {
+		return 0, fmt.Errorf("Display server sent an invalid protocol version: %q", version)
+	}
+	switch {
+	case major > 3 || minor >= 8:
+		minor = 8
+	case minor == 7:
+	default:
+		minor = 3
+	}
+	if err := write(server, []byte(fmt.Sprintf("RFB 003.%03d\n", minor))); err != nil {
+		return 0, err
+	}
+	return minor, nil
+}
+
+// readReason reads a failure reason string from the server and returns it as an error.
+func readReason(r io.Reader) error {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return err
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(r, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("Display server refused connection: %s", string(reason))
+}
+
+// write writes the given bytes and flushes them if the writer is buffered.
+func write(w io.Writer, b []byte) error {
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func reverseBits(b byte) byte {
+	var out byte
+	for i := 0; i < 8; i++ {
+		out = out<<1 | b&1
+		b >>= 1
+	}
+	return out
+}
@@ -0,0 +1,436 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rfb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestEncryptChallenge(t *testing.T) {
+	challenge := []byte("0123456789abcdef")
+	out, err := EncryptChallenge("secret", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(challenge) {
+		t.Fatalf("Expected %d bytes, got %d", len(challenge), len(out))
+	}
+	if bytes.Equal(out, challenge) {
+		t.Error("Expected challenge to be encrypted")
+	}
+	// Passwords are truncated to eight bytes
+	long, err := EncryptChallenge("secret-with-more", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trunc, err := EncryptChallenge("secret-w", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(long, trunc) {
+		t.Error("Expected long passwords to be truncated")
+	}
+	if _, err := EncryptChallenge("secret", []byte("short")); err == nil {
+		t.Error("Expected error for invalid challenge length")
+	}
+}
+
+func TestReverseBits(t *testing.T) {
+	for in, expected := range map[byte]byte{0x01: 0x80, 0x80: 0x01, 0xf0: 0x0f, 0xa5: 0xa5, 0x00: 0x00} {
+		if got := reverseBits(in); got != expected {
+			t.Errorf("Expected %08b for %08b, got %08b", expected, in, got)
+		}
+	}
+}
+
+// fakeServer runs the server side of a 3.8 VNC authentication handshake. Read errors
+// are ignored since the client may abort the handshake.
+func fakeServer(t *testing.T, conn net.Conn, password string) {
+	defer conn.Close()
+	conn.Write([]byte(ProtocolVersion38))
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return
+	}
+	if string(version) != ProtocolVersion38 {
+		t.Errorf("Expected client to select 3.8, got %q", version)
+	}
+	conn.Write([]byte{1, SecurityVNCAuth})
+	choice := make([]byte, 1)
+	if _, err := io.ReadFull(conn, choice); err != nil {
+		return
+	}
+	challenge := []byte("fedcba9876543210")
+	conn.Write(challenge)
+	response := make([]byte, 16)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return
+	}
+	expected, _ := EncryptChallenge(password, challenge)
+	if bytes.Equal(response, expected) {
+		binary.Write(conn, binary.BigEndian, uint32(0))
+		return
+	}
+	reason := "bad password"
+	binary.Write(conn, binary.BigEndian, uint32(1))
+	binary.Write(conn, binary.BigEndian, uint32(len(reason)))
+	conn.Write([]byte(reason))
+}
+
+func TestAuthenticate(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(t, server, "secret")
+	if err := Authenticate(client, "secret"); err != nil {
+		t.Error("Expected authentication to succeed, got:", err)
+	}
+	client.Close()
+
+	client, server = net.Pipe()
+	go fakeServer(t, server, "secret")
+	if err := Authenticate(client, "wrong"); err == nil {
+		t.Error("Expected authentication to fail")
+	}
+	client.Close()
+
+	client, server = net.Pipe()
+	go fakeServer(t, server, "secret")
+	if err := Authenticate(client, ""); err == nil {
+		t.Error("Expected error when no password is configured")
+	}
+	client.Close()
+}
+
+func TestServeNoAuth(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		expected []byte
+	}{
+		{"RFB 003.003\n", []byte{0, 0, 0, 1}},
+		{"RFB 003.007\n", []byte{1, SecurityNone}},
+		{"RFB 003.008\n", []byte{1, SecurityNone, 0, 0, 0, 0}},
+	} {
+		client, server := net.Pipe()
+		errs := make(chan error, 1)
+		go func() { errs <- ServeNoAuth(server) }()
+
+		version := make([]byte, 12)
+		if _, err := io.ReadFull(client, version); err != nil {
+			t.Fatal(err)
+		}
+		if string(version) != ProtocolVersion38 {
+			t.Errorf("Expected server to present 3.8, got %q", version)
+		}
+		client.Write([]byte(tc.version))
+
+		got := make([]byte, len(tc.expected))
+		if tc.version == "RFB 003.003\n" {
+			if _, err := io.ReadFull(client, got); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if _, err := io.ReadFull(client, got[:2]); err != nil {
+				t.Fatal(err)
+			}
+			client.Write([]byte{SecurityNone})
+			if _, err := io.ReadFull(client, got[2:]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(got, tc.expected) {
+			t.Errorf("Expected %v for %q, got %v", tc.expected, tc.version, got)
+		}
+		if err := <-errs; err != nil {
+			t.Error("Expected handshake to succeed, got:", err)
+		}
+		client.Close()
+	}
+}
+
+func TestFilterClientMessages(t *testing.T) {
+	keyEvent := []byte{4, 1, 0, 0, 0, 0, 0, 0x61}
+	pointerEvent := []byte{5, 0, 0, 10, 0, 20}
+	var cutText bytes.Buffer
+	if err := WriteClientCutText(&cutText, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	stream := append(append(append([]byte{}, keyEvent...), cutText.Bytes()...), pointerEvent...)
+
+	var out bytes.Buffer
+	if _, err := FilterClientMessages(&out, bytes.NewReader(stream), true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), stream) {
+		t.Errorf("Expected all messages to be copied, got %v", out.Bytes())
+	}
+
+	out.Reset()
+	if _, err := FilterClientMessages(&out, bytes.NewReader(stream), false); err != nil {
+		t.Fatal(err)
+	}
+	if expected := append(append([]byte{}, keyEvent...), pointerEvent...); !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Expected cut text to be dropped, got %v", out.Bytes())
+	}
+
+	out.Reset()
+	if _, err := FilterClientMessages(&out, bytes.NewReader([]byte{42, 0, 0}), true); err == nil {
+		t.Error("Expected error for unsupported message type")
+	}
+}
+
+func TestInputWatcher(t *testing.T) {
+	updateRequest := []byte{3, 1, 0, 0, 0, 0, 4, 0, 3, 0}
+	keyEvent := []byte{4, 1, 0, 0, 0, 0, 0, 0x61}
+	pointerEvent := []byte{5, 0, 0, 10, 0, 20}
+	var cutText bytes.Buffer
+	if err := WriteClientCutText(&cutText, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	var inputs int
+	w := NewInputWatcher(func() { inputs++ })
+
+	// the ClientInit and update requests are not input
+	stream := append(append([]byte{1}, updateRequest...), updateRequest...)
+	if n, err := w.Write(stream); err != nil || n != len(stream) {
+		t.Fatal("Expected the full write to succeed, got", n, err)
+	}
+	if inputs != 0 {
+		t.Fatal("Expected no input events, got", inputs)
+	}
+
+	// messages may be split across writes
+	stream = append(append(append([]byte{}, keyEvent...), cutText.Bytes()...), pointerEvent...)
+	for _, b := range stream {
+		w.Write([]byte{b})
+	}
+	if inputs != 2 {
+		t.Fatal("Expected two input events, got", inputs)
+	}
+
+	// unknown messages make every write count as input
+	w.Write([]byte{42, 0, 0})
+	w.Write(updateRequest)
+	if inputs != 4 {
+		t.Fatal("Expected every write to count as input, got", inputs)
+	}
+}
+
+func TestCutText(t *testing.T) {
+	encoded := EncodeCutText("héllo €")
+	if !bytes.Equal(encoded, []byte{'h', 0xe9, 'l', 'l', 'o', ' ', '?'}) {
+		t.Errorf("Unexpected encoding: %v", encoded)
+	}
+	if decoded := DecodeCutText(encoded); decoded != "héllo ?" {
+		t.Errorf("Unexpected decoding: %q", decoded)
+	}
+}
+
+// serverCutText returns a ServerCutText message containing the given text.
+func serverCutText(text string) []byte {
+	msg := make([]byte, 8, 8+len(text))
+	msg[0] = ServerCutText
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(text)))
+	return append(msg, text...)
+}
+
+func TestClientInit(t *testing.T) {
+	serverInit := []byte{0x04, 0x00, 0x03, 0x00}
+	serverInit = append(serverInit, PixelFormatBGRX...)
+	serverInit = append(serverInit, 0, 0, 0, 4)
+	serverInit = append(serverInit, "test"...)
+	var sent bytes.Buffer
+	init, err := ClientInit(struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(serverInit), &sent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sent.Bytes(), []byte{1}) {
+		t.Error("Expected a shared ClientInit, got", sent.Bytes())
+	}
+	if init.Width != 1024 || init.Height != 768 || init.Name != "test" {
+		t.Error("Unexpected ServerInit:", init)
+	}
+}
+
+func TestServerReader(t *testing.T) {
+	colourMap := []byte{SetColourMapEntries, 0, 0, 0, 0, 1, 1, 2, 3, 4, 5, 6}
+	stream := append(append(append([]byte{}, colourMap...), Bell), serverCutText("h\xe9llo")...)
+	stream = append(stream, serverCutText("too long")...)
+
+	var texts []string
+	server := NewServerReader(bytes.NewReader(stream))
+	server.MaxCutText = 5
+	server.OnCutText = func(text string) error {
+		texts = append(texts, text)
+		return nil
+	}
+	if err := server.Run(); err != io.EOF {
+		t.Error("Expected the stream to end with EOF, got:", err)
+	}
+	if len(texts) != 1 || texts[0] != "héllo" {
+		t.Error("Expected only the decoded short cut text, got:", texts)
+	}
+
+	// cut text is discarded without a callback
+	if err := NewServerReader(bytes.NewReader(serverCutText("secret"))).Run(); err != io.EOF {
+		t.Error("Expected the stream to end with EOF, got:", err)
+	}
+
+	if err := NewServerReader(bytes.NewReader([]byte{FramebufferUpdate, 0, 0, 0})).Run(); err == nil {
+		t.Error("Expected an error for an unrequested framebuffer update")
+	}
+	if err := NewServerReader(bytes.NewReader([]byte{42})).Run(); err == nil {
+		t.Error("Expected an error for an unknown message type")
+	}
+}
+
+// rawRect returns a raw encoded rectangle filled with a single BGRX pixel.
+func rawRect(x, y, w, h uint16, encoding int32, pixel []byte) []byte {
+	rect := make([]byte, 12)
+	binary.BigEndian.PutUint16(rect[0:2], x)
+	binary.BigEndian.PutUint16(rect[2:4], y)
+	binary.BigEndian.PutUint16(rect[4:6], w)
+	binary.BigEndian.PutUint16(rect[6:8], h)
+	binary.BigEndian.PutUint32(rect[8:12], uint32(encoding))
+	for i := 0; i < int(w)*int(h); i++ {
+		rect = append(rect, pixel...)
+	}
+	return rect
+}
+
+func TestReadFrame(t *testing.T) {
+	var requested bytes.Buffer
+	if err := RequestRawFrame(&requested, 4, 2); err != nil {
+		t.Fatal(err)
+	}
+	if req := requested.Bytes(); len(req) != 20+8+10 || req[0] != 0 || req[20] != 2 || req[28] != 3 {
+		t.Fatal("Unexpected frame request:", req)
+	}
+
+	// a bell and a cut text may arrive before the update
+	stream := append([]byte{Bell}, serverCutText("ignored")...)
+	stream = append(stream, FramebufferUpdate, 0, 0, 2)
+	// a blue rectangle on the left, and a red one that runs past the right edge
+	stream = append(stream, rawRect(0, 0, 2, 2, EncodingRaw, []byte{255, 0, 0, 0})...)
+	stream = append(stream, rawRect(2, 1, 4, 1, EncodingRaw, []byte{0, 0, 255, 0})...)
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	if err := NewServerReader(bytes.NewReader(stream)).ReadFrame(img); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		x, y     int
+		expected [4]byte
+	}{
+		{0, 0, [4]byte{0, 0, 255, 255}},
+		{1, 1, [4]byte{0, 0, 255, 255}},
+		{2, 0, [4]byte{0, 0, 0, 0}},
+		{3, 1, [4]byte{255, 0, 0, 255}},
+	} {
+		off := img.PixOffset(tc.x, tc.y)
+		if got := img.Pix[off : off+4]; !bytes.Equal(got, tc.expected[:]) {
+			t.Errorf("Expected %v at %d,%d, got %v", tc.expected, tc.x, tc.y, got)
+		}
+	}
+
+	stream = append([]byte{FramebufferUpdate, 0, 0, 1}, rawRect(0, 0, 1, 1, 7, []byte{0, 0, 0, 0})...)
+	if err := NewServerReader(bytes.NewReader(stream)).ReadFrame(img); err == nil {
+		t.Error("Expected an error for an unsupported encoding")
+	}
+	if err := NewServerReader(bytes.NewReader([]byte{FramebufferUpdate, 0, 0, 1, 0})).ReadFrame(img); err == nil {
+		t.Error("Expected an error for a truncated update")
+	}
+}
+
+func TestServerCutTextFilter(t *testing.T) {
+	serverInit := []byte{0x00, 0x04, 0x00, 0x03}
+	serverInit = append(serverInit, PixelFormatBGRX...)
+	serverInit = append(serverInit, 0, 0, 0, 4)
+	serverInit = append(serverInit, "test"...)
+
+	// an update with a raw, a hextile, and a zrle rectangle
+	update := []byte{FramebufferUpdate, 0, 0, 3}
+	update = append(update, rawRect(0, 0, 1, 1, EncodingRaw, []byte{1, 2, 3, 4})...)
+	update = append(update, rawRect(0, 0, 2, 2, encodingHextile, nil)...)
+	update = append(update, 1) // a raw tile
+	update = append(update, bytes.Repeat([]byte{5}, 2*2*4)...)
+	update = append(update, rawRect(0, 0, 4, 3, encodingZRLE, nil)...)
+	update = append(update, 0, 0, 0, 3, 6, 7, 8)
+	// an update ended by a LastRect
+	lastRect := append([]byte{FramebufferUpdate, 0, 0xff, 0xff}, rawRect(0, 0, 0, 0, encodingLastRect, nil)...)
+	// an extended clipboard update, which uses a negative length
+	extendedCutText := []byte{ServerCutText, 0, 0, 0, 0xff, 0xff, 0xff, 0xfe, 0, 0}
+
+	var stream, expected []byte
+	for _, msg := range [][]byte{serverInit, serverCutText("secret"), update, {Bell}, extendedCutText, lastRect, serverCutText("secret")} {
+		stream = append(stream, msg...)
+	}
+	for _, msg := range [][]byte{serverInit, update, {Bell}, lastRect} {
+		expected = append(expected, msg...)
+	}
+
+	var out bytes.Buffer
+	f := NewServerCutTextFilter()
+	if _, err := f.FilterServer(&out, bytes.NewReader(stream)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Expected cut text to be dropped, got %v", out.Bytes())
+	}
+
+	// the client may only choose encodings the filter can follow
+	setPixelFormat := append([]byte{0, 0, 0, 0, 16}, make([]byte, 15)...)
+	setEncodings := []byte{2, 0, 0, 3, 0, 0, 0, 7, 0, 0, 0, 16, 0, 0, 0, 0}
+	out.Reset()
+	if _, err := f.FilterClient(&out, bytes.NewReader(append(append([]byte{}, setPixelFormat...), setEncodings...)), false); err != nil {
+		t.Fatal(err)
+	}
+	if expected := append(append([]byte{}, setPixelFormat...), 2, 0, 0, 2, 0, 0, 0, 16, 0, 0, 0, 0); !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Expected tight encoding to be removed, got %v", out.Bytes())
+	}
+	// and later updates use the pixel format it set
+	out.Reset()
+	smallUpdate := append([]byte{FramebufferUpdate, 0, 0, 1}, rawRect(0, 0, 2, 1, EncodingRaw, []byte{1, 2})...)
+	r := bufio.NewReader(bytes.NewReader(append(smallUpdate[1:], Bell)))
+	if err := f.passUpdate(&serverStream{r: r, dst: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), smallUpdate) || r.Buffered() != 1 {
+		t.Errorf("Expected the update to be read with two bytes per pixel, got %v", out.Bytes())
+	}
+
+	out.Reset()
+	if _, err := NewServerCutTextFilter().FilterServer(&out, bytes.NewReader(append(append([]byte{}, serverInit...), 42))); err == nil {
+		t.Error("Expected an error for an unknown message type")
+	}
+	out.Reset()
+	unsupported := append([]byte{FramebufferUpdate, 0, 0, 1}, rawRect(0, 0, 1, 1, 7, nil)...)
+	if _, err := NewServerCutTextFilter().FilterServer(&out, bytes.NewReader(append(append([]byte{}, serverInit...), unsupported...))); err == nil {
+		t.Error("Expected an error for an unsupported encoding")
+	}
+}
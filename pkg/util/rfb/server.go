@@ -0,0 +1,225 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package rfb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Message types sent by display servers.
+const (
+	FramebufferUpdate   byte = 0
+	SetColourMapEntries byte = 1
+	Bell                byte = 2
+	ServerCutText       byte = 3
+)
+
+// EncodingRaw is the only framebuffer encoding requested by ReadFrame.
+const EncodingRaw int32 = 0
+
+// PixelFormatBGRX is a pixel format where each pixel is sent as four little-endian
+// bytes in BGRX order. It is the format expected by ReadFrame.
+var PixelFormatBGRX = []byte{
+	32,     // bits-per-pixel
+	24,     // depth
+	0,      // big-endian-flag
+	1,      // true-colour-flag
+	0, 255, // red-max
+	0, 255, // green-max
+	0, 255, // blue-max
+	16,      // red-shift
+	8,       // green-shift
+	0,       // blue-shift
+	0, 0, 0, // padding
+}
+
+// ServerInit contains the details the display server sends after a ClientInit.
+type ServerInit struct {
+	Width, Height uint16
+	Name          string
+}
+
+// ClientInit sends a ClientInit message with the shared flag set, so other clients of
+// the display server are not disconnected, and reads the ServerInit that follows. The
+// connection must have completed Authenticate.
+func ClientInit(server io.ReadWriter) (*ServerInit, error) {
+	if err := write(server, []byte{1}); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(server, header); err != nil {
+		return nil, err
+	}
+	name := make([]byte, binary.BigEndian.Uint32(header[20:24]))
+	if _, err := io.ReadFull(server, name); err != nil {
+		return nil, err
+	}
+	return &ServerInit{
+		Width:  binary.BigEndian.Uint16(header[0:2]),
+		Height: binary.BigEndian.Uint16(header[2:4]),
+		Name:   string(name),
+	}, nil
+}
+
+// RequestRawFrame sets the pixel format to PixelFormatBGRX, restricts the encodings
+// to raw, and requests a full update of a framebuffer with the given dimensions.
+func RequestRawFrame(server io.Writer, width, height uint16) error {
+	// SetPixelFormat
+	msg := append([]byte{0, 0, 0, 0}, PixelFormatBGRX...)
+	// SetEncodings with only raw
+	msg = append(msg, 2, 0, 0, 1, 0, 0, 0, 0)
+	// FramebufferUpdateRequest for the full screen
+	msg = append(msg, 3, 0, 0, 0, 0, 0)
+	msg = append(msg, byte(width>>8), byte(width), byte(height>>8), byte(height))
+	return write(server, msg)
+}
+
+// ServerReader reads the messages sent by a display server after the ServerInit.
+type ServerReader struct {
+	r      io.Reader
+	header []byte
+	// OnCutText is called with the text of every ServerCutText message, decoded to
+	// UTF-8. When nil, or when the text exceeds MaxCutText, the text is discarded.
+	OnCutText func(text string) error
+	// MaxCutText is the largest cut text passed to OnCutText.
+	MaxCutText uint32
+}
+
+// NewServerReader returns a new ServerReader for the given display server stream.
+func NewServerReader(r io.Reader) *ServerReader {
+	return &ServerReader{r: r, header: make([]byte, 12)}
+}
+
+// Next reads a single message from the display server and returns its type. Colour
+// map entries, bells, and cut text are consumed. A framebuffer update is left unread
+// for ReadFrame, since its length depends on the encoding of each rectangle.
+func (s *ServerReader) Next() (byte, error) {
+	if _, err := io.ReadFull(s.r, s.header[:1]); err != nil {
+		return 0, err
+	}
+	msgType := s.header[0]
+	switch msgType {
+	case FramebufferUpdate:
+	case SetColourMapEntries:
+		if _, err := io.ReadFull(s.r, s.header[:5]); err != nil {
+			return 0, err
+		}
+		count := binary.BigEndian.Uint16(s.header[3:5])
+		if _, err := io.CopyN(io.Discard, s.r, int64(count)*6); err != nil {
+			return 0, err
+		}
+	case Bell:
+	case ServerCutText:
+		if _, err := io.ReadFull(s.r, s.header[:7]); err != nil {
+			return 0, err
+		}
+		length := binary.BigEndian.Uint32(s.header[3:7])
+		if s.OnCutText == nil || length > s.MaxCutText {
+			if _, err := io.CopyN(io.Discard, s.r, int64(length)); err != nil {
+				return 0, err
+			}
+			break
+		}
+		text := make([]byte, length)
+		if _, err := io.ReadFull(s.r, text); err != nil {
+			return 0, err
+		}
+		if err := s.OnCutText(DecodeCutText(text)); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("Display server sent unexpected message type %d", msgType)
+	}
+	return msgType, nil
+}
+
+// Run reads messages until the stream ends or fails. Framebuffer updates are not
+// supported, so it is only useful on connections that never request them.
+func (s *ServerReader) Run() error {
+	for {
+		msgType, err := s.Next()
+		if err != nil {
+			return err
+		}
+		if msgType == FramebufferUpdate {
+			return fmt.Errorf("Display server sent an unrequested framebuffer update")
+		}
+	}
+}
+
+// ReadFrame reads messages until a framebuffer update has been received and draws it
+// into the given image. The update must use raw encoding in PixelFormatBGRX, as
+// requested by RequestRawFrame.
+func (s *ServerReader) ReadFrame(img *image.RGBA) error {
+	for {
+		msgType, err := s.Next()
+		if err != nil {
+			return err
+		}
+		if msgType != FramebufferUpdate {
+			continue
+		}
+		if _, err := io.ReadFull(s.r, s.header[:3]); err != nil {
+			return err
+		}
+		numRects := binary.BigEndian.Uint16(s.header[1:3])
+		for i := uint16(0); i < numRects; i++ {
+			if err := s.readRawRect(img); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// readRawRect reads a single raw encoded rectangle into the given image. Pixels outside
+// the bounds of the image are discarded.
+func (s *ServerReader) readRawRect(img *image.RGBA) error {
+	if _, err := io.ReadFull(s.r, s.header); err != nil {
+		return err
+	}
+	x := int(binary.BigEndian.Uint16(s.header[0:2]))
+	y := int(binary.BigEndian.Uint16(s.header[2:4]))
+	w := int(binary.BigEndian.Uint16(s.header[4:6]))
+	h := int(binary.BigEndian.Uint16(s.header[6:8]))
+	if encoding := int32(binary.BigEndian.Uint32(s.header[8:12])); encoding != EncodingRaw {
+		return fmt.Errorf("Display server sent unsupported encoding %d", encoding)
+	}
+	row := make([]byte, w*4)
+	for j := 0; j < h; j++ {
+		if _, err := io.ReadFull(s.r, row); err != nil {
+			return err
+		}
+		for i := 0; i < w; i++ {
+			if !image.Pt(x+i, y+j).In(img.Rect) {
+				continue
+			}
+			off := img.PixOffset(x+i, y+j)
+			img.Pix[off] = row[i*4+2]
+			img.Pix[off+1] = row[i*4+1]
+			img.Pix[off+2] = row[i*4]
+			img.Pix[off+3] = 255
+		}
+	}
+	return nil
+}
@@ -83,6 +83,99 @@ func NewClientTLSConfig() (*tls.Config, error) {
 	}, nil
 }
 
+// NewTunnelServerTLSConfig returns a server TLS configuration for the app's reverse tunnel
+// listener. The server certificate may be user-supplied, so desktop certificates are
+// verified against the CA mounted with the app's client certificate.
+func NewTunnelServerTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(ServerKeypair())
+	if err != nil {
+		return nil, err
+	}
+	caCertPool, err := getCACertPool(clientCertMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		ClientCAs:    caCertPool,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   minTLSVersion,
+	}, nil
+}
+
+// NewTunnelClientTLSConfig returns a client TLS configuration for a desktop proxy dialing
+// the app over a reverse tunnel. The proxy presents its server certificate, and the app
+// certificate is verified against both the system roots and the kVDI CA.
+func NewTunnelClientTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(ServerKeypair())
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := ioutil.ReadFile(filepath.Join(serverCertMountPath, v1.CACertKey))
+	if err != nil {
+		return nil, err
+	}
+	caCertPool, err := x509.SystemCertPool()
+	if err != nil {
+		caCertPool = x509.NewCertPool()
+	}
+	if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
+		return nil, errors.New("Failed to create CA cert pool")
+	}
+	return &tls.Config{
+		RootCAs:      caCertPool,
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minTLSVersion,
+	}, nil
+}
+
+// NewTunnelRelayTLSConfig returns a TLS configuration for relaying reverse tunnels between
+// replicas of the app. The same configuration is used on both ends, and both present the
+// app's client certificate. Since the kVDI CA also signs the certificates of desktop
+// proxies, peers must additionally present a certificate with the same common name as
+// the app's own.
+func NewTunnelRelayTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(ClientKeypair())
+	if err != nil {
+		return nil, err
+	}
+	caCertPool, err := getCACertPool(clientCertMountPath)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		// peers are dialed by pod IP, which the certificate is not issued for, so the
+		// verification is done in VerifyPeerCertificate instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("No certificate presented by the peer")
+			}
+			peer, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if _, err := peer.Verify(x509.VerifyOptions{
+				Roots:     caCertPool,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}); err != nil {
+				return err
+			}
+			if peer.Subject.CommonName != leaf.Subject.CommonName {
+				return fmt.Errorf("%q is not a replica of the app", peer.Subject.CommonName)
+			}
+			return nil
+		},
+		MinVersion: minTLSVersion,
+	}, nil
+}
+
 // NewClientTLSConfigFromSecret returns a client TLS config from a kubernetes
 // certificate secret.
 func NewClientTLSConfigFromSecret(c client.Client, name, namespace string) (*tls.Config, error) {
@@ -154,6 +154,32 @@ func TestNewClientTLSConfigFromSecret(t *testing.T) {
 	}
 }
 
+func TestNewTunnelRelayTLSConfig(t *testing.T) {
+	var err error
+	var clean func()
+	clientCertMountPath, clean, err = writeTLSCerts(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clean()
+	config, err := NewTunnelRelayTLSConfig()
+	if err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if config.ClientAuth != tls.RequireAnyClientCert {
+		t.Error("Expected RequireAnyClientCert in TLS config, got:", config.ClientAuth)
+	}
+	if !config.InsecureSkipVerify || config.VerifyPeerCertificate == nil {
+		t.Error("Expected peers to be verified against the app certificate")
+	}
+	if err := config.VerifyPeerCertificate(nil, nil); err == nil {
+		t.Error("Expected an error when no certificate is presented")
+	}
+	if err := config.VerifyPeerCertificate([][]byte{[]byte("not a certificate")}, nil); err == nil {
+		t.Error("Expected an error for an invalid certificate")
+	}
+}
+
 func TestServerKeypair(t *testing.T) {
 	if cert, key := ServerKeypair(); cert != filepath.Join(serverCertMountPath, corev1.TLSCertKey) {
 		t.Error("Got wrong cert path for server keypair:", cert)
@@ -0,0 +1,61 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// MagicPacket returns the magic packet for the given hardware address. The packet is six
+// bytes of 0xff followed by sixteen repetitions of the address.
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("%q is not a 48-bit hardware address", mac)
+	}
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	packet = append(packet, bytes.Repeat(hw, 16)...)
+	return packet, nil
+}
+
+// Wake sends a magic packet for the given hardware address to the given broadcast
+// address.
+func Wake(mac, broadcastAddr string) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	addr, err := net.ResolveUDPAddr("udp4", broadcastAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(packet)
+	return err
+}
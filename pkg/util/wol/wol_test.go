@@ -0,0 +1,77 @@
+/*
+Copyright 2020,2021 Avi Zimmerman
+
+This file is part of kvdi.
+
+kvdi is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+kvdi is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMagicPacket(t *testing.T) {
+	packet, err := MagicPacket("01:23:45:67:89:ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != 102 {
+		t.Fatalf("Expected 102 byte packet, got %d", len(packet))
+	}
+	if !bytes.Equal(packet[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Error("Expected packet to start with sync stream, got:", packet[:6])
+	}
+	hw := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}
+	for i := 6; i < len(packet); i += 6 {
+		if !bytes.Equal(packet[i:i+6], hw) {
+			t.Errorf("Expected hardware address at offset %d, got %v", i, packet[i:i+6])
+		}
+	}
+
+	for _, invalid := range []string{"", "not-a-mac", "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"} {
+		if _, err := MagicPacket(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestWake(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := Wake("01-23-45-67-89-ab", conn.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := MagicPacket("01:23:45:67:89:ab")
+	if !bytes.Equal(buf[:n], expected) {
+		t.Error("Received unexpected packet:", buf[:n])
+	}
+}
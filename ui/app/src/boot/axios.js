@@ -19,5 +19,9 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 
 import Vue from 'vue'
 import axios from 'axios'
+import { basePath } from 'src/lib/util.js'
+
+// API requests are relative to the path prefix the app is served under
+axios.defaults.baseURL = basePath
 
 Vue.prototype.$axios = axios
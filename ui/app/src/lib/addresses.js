@@ -19,6 +19,8 @@
 
 */
 
+import { basePath } from 'src/lib/util.js'
+
 // DesktopAddressGetter is a convenience wrapper around retrieving connection
 // URLs for a given desktop instance.
 export default class DesktopAddressGetter {
@@ -36,13 +38,16 @@ export default class DesktopAddressGetter {
     }
   
     // _buildAddress builds a websocket address for the given desktop function (endpoint).
-    _buildAddress (endpoint) {
-      return `${window.location.origin.replace('http', 'ws')}/api/desktops/ws/${this.namespace}/${this.name}/${endpoint}?token=${this._getToken()}`
+    // The address is on the given gateway URL, or the current origin if none is given.
+    _buildAddress (endpoint, gateway) {
+      const base = gateway || `${window.location.origin}${basePath}`
+      return `${base.replace('http', 'ws')}/api/desktops/ws/${this.namespace}/${this.name}/${endpoint}?token=${this._getToken()}`
     }
   
-    // displayURL returns the websocket address for display connections.
-    displayURL () {
-      return this._buildAddress('display')
+    // displayURL returns the websocket address for display connections, optionally through
+    // the given gateway.
+    displayURL (gateway) {
+      return this._buildAddress('display', gateway)
     }
   
     // audioURL returns the websocket address for audio connections.
@@ -20,6 +20,7 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 import Recorder from 'opus-recorder'
 import Websock from '@novnc/novnc/core/websock.js'
 import { Emitter, Events } from './events.js'
+import { getDisconnectReason } from './disconnects.js'
 import encoderPath from 'opus-recorder/dist/encoderWorker.min.js'
 
 // AudioManager is an object for managing audio playback and recording
@@ -54,7 +55,8 @@ export default class AudioManager extends Emitter {
         return
       }
       this.stopRecording()
-      if (!event.wasClean || (event.code !== 1000 && event.code !== 1005)) {
+      // when the server gives a reason it is reported by the display connection
+      if (!getDisconnectReason(event) && (!event.wasClean || (event.code !== 1000 && event.code !== 1005))) {
         this.emit(Events.error, new Error(`Unexpected message from websocket: ${event.code} ${event.reason}`))
       }
       this.emit(Events.disconnected)
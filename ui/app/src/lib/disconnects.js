@@ -0,0 +1,63 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+
+// The reasons the server may give for closing a desktop connection, keyed by the close
+// code sent with them. These mirror the DisconnectReasons in pkg/types.
+const reasons = Object.freeze({
+    4000: {
+        reason: 'idle-timeout',
+        message: 'The display was disconnected after being idle for too long',
+        reconnect: false
+    },
+    4001: {
+        reason: 'admin-terminated',
+        message: 'The desktop session was terminated',
+        reconnect: false
+    },
+    4002: {
+        reason: 'pod-evicted',
+        message: 'The desktop was moved or restarted by the cluster - Reconnecting',
+        reconnect: true
+    },
+    4003: {
+        reason: 'auth-expired',
+        message: 'Your session has expired, please log in again',
+        reconnect: false
+    },
+    4004: {
+        reason: 'node-lost',
+        message: 'The server running the desktop stopped responding - Reconnecting',
+        reconnect: true
+    },
+    4005: {
+        reason: 'resume-expired',
+        message: 'The connection to the desktop could not be resumed - Reconnecting',
+        reconnect: true
+    }
+})
+
+// getDisconnectReason returns the reason, message, and reconnect policy for the given
+// websocket close event, or null if the server did not give a reason.
+export function getDisconnectReason (closeEvent) {
+    if (!closeEvent || !closeEvent.code) { return null }
+    return reasons[closeEvent.code] || null
+}
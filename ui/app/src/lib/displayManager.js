@@ -23,6 +23,7 @@ import AudioManager from './audioManager.js'
 import DesktopAddressGetter from './addresses.js'
 import { Emitter, Events } from './events.js'
 import { getDisplay } from './displays.js'
+import { selectGateway } from './gateways.js'
 
 // DisplayManager handles display and audio connections to remote desktop sessions.
 export default class DisplayManager extends Emitter {
@@ -286,9 +287,9 @@ export default class DisplayManager extends Emitter {
 
     // _createConnection will create a new display connection
     async _createConnection () {
-        // get the websocket display address
+        // get the websocket display address on the gateway with the lowest latency
         const urls = this._getSessionURLs()
-        const displayURL = urls.displayURL()
+        const displayURL = urls.displayURL(await selectGateway())
         // get the viewport for the display
         const view = document.getElementById('view')
         if (view === null || view === undefined) {
@@ -314,7 +315,21 @@ export default class DisplayManager extends Emitter {
     // _disconnectedFromDisplay is called when the connection is dropped to a
     // display session.
     async _disconnectedFromDisplay (event) {
-        if (!event || (event.detail && event.detail.clean)) {
+        const reason = event && event.disconnectReason
+        if (reason) {
+            // The server told us why the connection was closed
+            console.log(`Display connection closed by the server: ${reason.reason}`)
+            if (reason.reconnect) {
+                this.emit(Events.update, reason.message)
+                this._doStatusWebsocket()
+            } else {
+                if (reason.reason === 'admin-terminated' && this._currentSession) {
+                    this._sessionStore.dispatch('deleteSessionOffline', this._currentSession)
+                    this._currentSession = null
+                }
+                this.emit(Events.error, new Error(reason.message))
+            }
+        } else if (!event || (event.detail && event.detail.clean)) {
             // The server disconnecting cleanly would mean expired session,
             // but this should probably be handled better.
             if (this._currentSession) {
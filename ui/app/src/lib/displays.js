@@ -28,6 +28,7 @@ import {
     handle_file_drop,
 } from './spice/main.js'
 import { Emitter, Events } from './events.js'
+import { getDisconnectReason } from './disconnects.js'
 
 export function getDisplay(session) {
     if (session.template.spec.qemu && session.template.spec.qemu.spice) {
@@ -75,6 +76,15 @@ export class VNCDisplay extends Display {
         }
         console.log('Creating RFB connection')
         this._rfbClient = new RFB(view, displayUrl)
+        // noVNC does not pass the close event on with its disconnect event, so keep the
+        // last one to tell the user why the server closed the connection.
+        this._closeEvent = null
+        const sock = this._rfbClient._sock
+        const onclose = sock._eventHandlers.close
+        sock.on('close', (e) => {
+            this._closeEvent = e
+            onclose(e)
+        })
         this._rfbClient.addEventListener('connect', (ev) => { this._connectedToRFBServer(ev) })
         this._rfbClient.addEventListener('disconnect', (ev) => { this._disconnectedFromRFBServer(ev) })
         this._rfbClient.addEventListener('clipboard', (ev) => { this._handleRecvClipboard(ev) })
@@ -107,6 +117,7 @@ export class VNCDisplay extends Display {
         if (this._rfbClient) {
             this._rfbClient = null
         }
+        event.disconnectReason = getDisconnectReason(this._closeEvent)
         this.emit(Events.disconnected, event)
     }
 
@@ -150,7 +161,10 @@ export class SPICEDisplay extends Display {
             uri: displayUrl,
             screen_id: view.id,
             onerror: (err) => { 
-                this.emit(Events.error, err)
+                err.disconnectReason = getDisconnectReason(err.closeEvent)
+                if (!err.disconnectReason) {
+                    this.emit(Events.error, err)
+                }
                 this._disconnect(err)
             },
             onsuccess: () => { this.emit(Events.connected) },
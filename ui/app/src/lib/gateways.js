@@ -0,0 +1,94 @@
+/*
+
+   Copyright 2020,2021 Avi Zimmerman
+
+   This file is part of kvdi.
+
+   kvdi is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   kvdi is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+import Vue from 'vue'
+import { basePath } from 'src/lib/util.js'
+
+// The number of requests made to each gateway when measuring latency. The fastest one is
+// used, so a single slow request does not skew the result.
+const probeCount = 3
+
+// How long a selection is reused before the gateways are probed again. This is shorter
+// than the time the server trusts a report for.
+const selectionMaxAge = 10 * 60 * 1000
+
+let selection = null
+let selectedAt = 0
+
+// _gatewayBase returns the URL the API of a gateway is served at. The default gateway has
+// no URL and is served from the same origin as the UI.
+function _gatewayBase (gateway) {
+    return gateway.url || `${window.location.origin}${basePath}`
+}
+
+// _probe returns the lowest round trip time in milliseconds of a few requests to the probe
+// endpoint of the given gateway, or null if it could not be reached.
+async function _probe (gateway) {
+    let best = null
+    for (let i = 0; i < probeCount; i++) {
+        const start = performance.now()
+        try {
+            const res = await fetch(`${_gatewayBase(gateway)}${gateway.probePath}`, { mode: 'cors', cache: 'no-store' })
+            if (!res.ok) { return null }
+        } catch (err) {
+            console.log(`Could not reach gateway ${gateway.name}: ${err}`)
+            return null
+        }
+        const elapsed = performance.now() - start
+        if (best === null || elapsed < best) {
+            best = elapsed
+        }
+    }
+    return best
+}
+
+// selectGateway measures the latency to each of the available gateways, reports it to the
+// server, and returns the base websocket address display connections should be made to.
+// When there is only one gateway, or anything goes wrong, the default gateway is used.
+export async function selectGateway () {
+    if (selection !== null && Date.now() - selectedAt < selectionMaxAge) {
+        return selection
+    }
+    let base = ''
+    try {
+        const res = await Vue.prototype.$axios.get('/api/gateways')
+        const gateways = res.data.gateways
+        if (gateways.length > 1) {
+            const latencies = {}
+            const results = await Promise.all(gateways.map(_probe))
+            gateways.forEach((gateway, idx) => {
+                if (results[idx] !== null) {
+                    latencies[gateway.name] = results[idx]
+                }
+            })
+            if (Object.keys(latencies).length !== 0) {
+                const selected = await Vue.prototype.$axios.put('/api/gateways/latency', { latencies: latencies })
+                console.log(`Selected gateway ${selected.data.gateway} (${latencies[selected.data.gateway]}ms)`)
+                base = selected.data.url
+            }
+        }
+    } catch (err) {
+        console.log(`Could not select a gateway, using the default: ${err}`)
+    }
+    selection = base
+    selectedAt = Date.now()
+    return selection
+}
@@ -108,6 +108,7 @@ function SpiceConn(o)
         DEBUG > 0 && console.log(e);
         if (this.parent.state != "closing" && this.parent.state != "error" && this.parent.onerror !== undefined)
         {
+            var closeEvent = e;
             var e;
             if (this.parent.state == "connecting")
                 e = new Error("Connection refused.");
@@ -118,6 +119,7 @@ function SpiceConn(o)
             else
                 e = new Error("Unexpected close while " + this.parent.state);
 
+            e.closeEvent = closeEvent;
             this.parent.onerror(e);
             this.parent.log_err(e.toString());
         }
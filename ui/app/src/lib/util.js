@@ -17,6 +17,10 @@ You should have received a copy of the GNU General Public License
 along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 */
 
+// basePath is the path prefix the app is served under, without a trailing slash. Since the
+// router uses hash mode, the path of the page is always the prefix.
+export const basePath = window.location.pathname.replace(/\/(index\.html)?$/, '')
+
 // getErrorMessage turns a given error into a human readable string
 export async function getErrorMessage (err) {
   if (err.code) { console.log(err.code) }
@@ -20,6 +20,7 @@ along with kvdi.  If not, see <https://www.gnu.org/licenses/>.
 import Vue from 'vue'
 import Vuex from 'vuex'
 import axios from 'axios'
+import { basePath } from 'src/lib/util.js'
 
 function uuidv4 () {
   return 'xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx'.replace(/[xy]/g, function (c) {
@@ -264,7 +265,7 @@ export const UserStore = new Vuex.Store({
         })
       }
       delete Vue.prototype.$axios.defaults.headers.common['X-Session-Token']
-      window.location.href = '/#/login'
+      window.location.href = `${basePath}/#/login`
     }
 
   },